@@ -0,0 +1,25 @@
+//! Build script. Only does work for the `grpc` feature: compiles
+//! `proto/cqs.proto` into the tonic service and prost messages that
+//! `src/serve/grpc.rs` includes. `protox` parses the proto in-process, so no
+//! system `protoc` is required.
+
+fn main() {
+    #[cfg(feature = "grpc")]
+    grpc::compile();
+}
+
+#[cfg(feature = "grpc")]
+mod grpc {
+    const PROTO: &str = "proto/cqs.proto";
+
+    pub(super) fn compile() {
+        println!("cargo:rerun-if-changed={PROTO}");
+        let descriptors = protox::compile([PROTO], ["proto"])
+            .unwrap_or_else(|e| panic!("failed to parse {PROTO}: {e}"));
+        tonic_build::configure()
+            .build_client(false)
+            .build_server(true)
+            .compile_fds(descriptors)
+            .unwrap_or_else(|e| panic!("failed to generate gRPC code for {PROTO}: {e}"));
+    }
+}
@@ -0,0 +1,341 @@
+//! Per-chunk access statistics — which chunks show up in search results and
+//! which ones get opened.
+//!
+//! Every search samples its returned results as *hits*
+//! (`CQS_ACCESS_SAMPLE_RATE`, default 0.1); every `cqs explain` and
+//! `cqs read --focus` counts one *open* of the resolved chunk. Rows are keyed
+//! by `(origin, name)` rather than chunk id, so a function keeps its history
+//! across edits and reindexes. The database lives beside the index
+//! (`.cqs/access_stats.db`), like the eval history, so it survives a
+//! reindex and is writable even while the index is opened read-only.
+//!
+//! `cqs stats --hot` lists the hottest chunks. Search can also use the data
+//! as a popularity prior: with the `popularity_weight` scoring knob above 0
+//! (`CQS_POPULARITY_WEIGHT` or `[scoring] popularity_weight`), each
+//! candidate's score is multiplied by `1 + weight * popularity`, where
+//! popularity is the chunk's log-scaled access count normalized to `[0, 1]`.
+//!
+//! Recording is best-effort: a failure is logged at debug and never fails
+//! the command. `CQS_ACCESS_STATS=0` turns recording off.
+
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use std::sync::{Arc, Mutex};
+use std::time::{Duration, Instant};
+
+use thiserror::Error;
+
+use crate::cache::CacheError;
+
+/// File name of the access statistics database inside the project `.cqs/`
+/// directory.
+pub const ACCESS_STATS_FILENAME: &str = "access_stats.db";
+
+/// How much one open counts against one search hit when ranking. An open is
+/// a deliberate choice; a hit only means the chunk was on screen.
+const OPEN_WEIGHT: u64 = 5;
+
+/// How long a loaded popularity prior is reused before the database is read
+/// again. Matters for the daemon, which searches many times per second.
+const PRIOR_TTL: Duration = Duration::from_secs(60);
+
+#[derive(Debug, Error)]
+pub enum AccessStatsError {
+    #[error("Access stats database error: {0}")]
+    Database(#[from] sqlx::Error),
+    #[error("Failed to open access stats: {0}")]
+    Open(#[from] CacheError),
+}
+
+/// What happened to a chunk.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum AccessKind {
+    /// Returned in a search's results.
+    Hit,
+    /// Opened directly (`explain`, `read --focus`).
+    Open,
+}
+
+/// Access counters of one chunk.
+#[derive(Debug, Clone, PartialEq, serde::Serialize)]
+pub struct ChunkAccess {
+    pub origin: String,
+    pub name: String,
+    /// Sampled search-result appearances
+    pub hits: u64,
+    pub opens: u64,
+    /// Unix seconds of the latest hit or open
+    pub last_seen: i64,
+}
+
+impl ChunkAccess {
+    /// Ranking weight: hits plus weighted opens.
+    pub fn score(&self) -> u64 {
+        self.hits
+            .saturating_add(self.opens.saturating_mul(OPEN_WEIGHT))
+    }
+}
+
+/// Handle on the access statistics database.
+pub struct AccessStats {
+    pool: sqlx::SqlitePool,
+    rt: Arc<tokio::runtime::Runtime>,
+}
+
+impl AccessStats {
+    /// Open or create the access statistics database at `path`.
+    pub fn open(path: &Path) -> Result<Self, AccessStatsError> {
+        let _span = tracing::debug_span!("access_stats_open", path = %path.display()).entered();
+        let (pool, rt) = crate::cache::connect_cache_pool(path, 5_000, None, |pool| async move {
+            sqlx::query(
+                "CREATE TABLE IF NOT EXISTS chunk_access (
+                    origin TEXT NOT NULL,
+                    name TEXT NOT NULL,
+                    hits INTEGER NOT NULL DEFAULT 0,
+                    opens INTEGER NOT NULL DEFAULT 0,
+                    last_seen INTEGER NOT NULL,
+                    PRIMARY KEY (origin, name)
+                )",
+            )
+            .execute(&pool)
+            .await?;
+            Ok(())
+        })?;
+        Ok(Self { pool, rt })
+    }
+
+    /// Count one `kind` access for each `(origin, name)` in `keys`.
+    pub fn record(&self, kind: AccessKind, keys: &[(&str, &str)]) -> Result<(), AccessStatsError> {
+        let _span = tracing::debug_span!("access_stats_record", ?kind, n = keys.len()).entered();
+        let (hits, opens): (i64, i64) = match kind {
+            AccessKind::Hit => (1, 0),
+            AccessKind::Open => (0, 1),
+        };
+        let now = crate::unix_secs_i64().unwrap_or(0);
+        self.rt.block_on(async {
+            let mut tx = self.pool.begin().await?;
+            for &(origin, name) in keys {
+                sqlx::query(
+                    "INSERT INTO chunk_access (origin, name, hits, opens, last_seen)
+                     VALUES (?1, ?2, ?3, ?4, ?5)
+                     ON CONFLICT (origin, name) DO UPDATE SET
+                        hits = hits + excluded.hits,
+                        opens = opens + excluded.opens,
+                        last_seen = excluded.last_seen",
+                )
+                .bind(origin)
+                .bind(name)
+                .bind(hits)
+                .bind(opens)
+                .bind(now)
+                .execute(&mut *tx)
+                .await?;
+            }
+            tx.commit().await?;
+            Ok(())
+        })
+    }
+
+    /// The `limit` most-accessed chunks, hottest first.
+    pub fn hottest(&self, limit: usize) -> Result<Vec<ChunkAccess>, AccessStatsError> {
+        let _span = tracing::debug_span!("access_stats_hottest", limit).entered();
+        self.rt.block_on(async {
+            let rows: Vec<(String, String, i64, i64, i64)> = sqlx::query_as(
+                "SELECT origin, name, hits, opens, last_seen FROM chunk_access
+                 ORDER BY hits + opens * ?1 DESC, last_seen DESC
+                 LIMIT ?2",
+            )
+            .bind(OPEN_WEIGHT as i64)
+            .bind(limit.min(i64::MAX as usize) as i64)
+            .fetch_all(&self.pool)
+            .await?;
+            Ok(rows.into_iter().map(chunk_access_from_row).collect())
+        })
+    }
+
+    /// Every recorded chunk folded into a [`PopularityPrior`].
+    pub fn popularity_prior(&self) -> Result<PopularityPrior, AccessStatsError> {
+        let _span = tracing::debug_span!("access_stats_prior").entered();
+        self.rt.block_on(async {
+            let rows: Vec<(String, String, i64, i64, i64)> =
+                sqlx::query_as("SELECT origin, name, hits, opens, last_seen FROM chunk_access")
+                    .fetch_all(&self.pool)
+                    .await?;
+            Ok(PopularityPrior::from_counts(
+                rows.into_iter().map(chunk_access_from_row),
+            ))
+        })
+    }
+}
+
+fn chunk_access_from_row(
+    (origin, name, hits, opens, last_seen): (String, String, i64, i64, i64),
+) -> ChunkAccess {
+    ChunkAccess {
+        origin,
+        name,
+        hits: hits.max(0) as u64,
+        opens: opens.max(0) as u64,
+        last_seen,
+    }
+}
+
+/// Normalized popularity per `(origin, name)`, in `[0, 1]`.
+///
+/// `ln(1 + score) / ln(1 + max_score)`: the log keeps one very hot chunk from
+/// flattening everything else to zero.
+#[derive(Debug, Default)]
+pub struct PopularityPrior {
+    by_origin: HashMap<String, HashMap<String, f32>>,
+}
+
+impl PopularityPrior {
+    /// Build from raw counters.
+    pub fn from_counts(counts: impl IntoIterator<Item = ChunkAccess>) -> Self {
+        let counts: Vec<ChunkAccess> = counts.into_iter().collect();
+        let max = counts.iter().map(ChunkAccess::score).max().unwrap_or(0);
+        let mut by_origin: HashMap<String, HashMap<String, f32>> = HashMap::new();
+        if max == 0 {
+            return Self { by_origin };
+        }
+        let denom = (max as f64).ln_1p();
+        for c in counts {
+            let score = c.score();
+            if score == 0 {
+                continue;
+            }
+            by_origin
+                .entry(c.origin)
+                .or_default()
+                .insert(c.name, ((score as f64).ln_1p() / denom) as f32);
+        }
+        Self { by_origin }
+    }
+
+    /// Popularity of the chunk `name` in `origin`; 0.0 when never accessed.
+    pub fn get(&self, origin: &str, name: &str) -> f32 {
+        self.by_origin
+            .get(origin)
+            .and_then(|names| names.get(name))
+            .copied()
+            .unwrap_or(0.0)
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.by_origin.is_empty()
+    }
+}
+
+/// Path of the access statistics database under `cqs_dir`.
+pub fn access_stats_path(cqs_dir: &Path) -> PathBuf {
+    cqs_dir.join(ACCESS_STATS_FILENAME)
+}
+
+/// Best-effort recording for the CLI and daemon. Hits are sampled; opens are
+/// always counted. Never fails — errors are logged at debug.
+pub fn record_access(cqs_dir: &Path, kind: AccessKind, keys: &[(&str, &str)]) {
+    if keys.is_empty() || !crate::limits::access_stats_enabled() {
+        return;
+    }
+    if kind == AccessKind::Hit && rand::random::<f32>() >= crate::limits::access_sample_rate() {
+        return;
+    }
+    let path = access_stats_path(cqs_dir);
+    if let Err(e) = AccessStats::open(&path).and_then(|stats| stats.record(kind, keys)) {
+        tracing::debug!(path = %path.display(), error = %e, "Failed to record chunk access");
+    }
+}
+
+struct CachedPrior {
+    path: PathBuf,
+    loaded_at: Instant,
+    prior: Option<Arc<PopularityPrior>>,
+}
+
+static PRIOR_CACHE: Mutex<Option<CachedPrior>> = Mutex::new(None);
+
+/// Popularity prior for a search over the index in `cqs_dir`, or `None` when
+/// the `popularity_weight` knob is 0 (the default), nothing was recorded yet,
+/// or the database can't be read. Reloaded at most every [`PRIOR_TTL`].
+pub fn popularity_prior_for_search(cqs_dir: &Path) -> Option<Arc<PopularityPrior>> {
+    if crate::search::scoring::knob::resolve_knob("popularity_weight") <= 0.0 {
+        return None;
+    }
+    let path = access_stats_path(cqs_dir);
+    let mut cache = PRIOR_CACHE.lock().unwrap_or_else(|p| p.into_inner());
+    if let Some(c) = cache.as_ref() {
+        if c.path == path && c.loaded_at.elapsed() < PRIOR_TTL {
+            return c.prior.clone();
+        }
+    }
+    let prior = if path.exists() {
+        match AccessStats::open(&path).and_then(|stats| stats.popularity_prior()) {
+            Ok(prior) if !prior.is_empty() => Some(Arc::new(prior)),
+            Ok(_) => None,
+            Err(e) => {
+                tracing::debug!(path = %path.display(), error = %e, "Failed to load popularity prior");
+                None
+            }
+        }
+    } else {
+        None
+    };
+    *cache = Some(CachedPrior {
+        path,
+        loaded_at: Instant::now(),
+        prior: prior.clone(),
+    });
+    prior
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn record_accumulates_and_ranks_opens_above_hits() {
+        let dir = tempfile::tempdir().unwrap();
+        let stats = AccessStats::open(&access_stats_path(dir.path())).unwrap();
+        for _ in 0..3 {
+            stats
+                .record(
+                    AccessKind::Hit,
+                    &[("src/a.rs", "search"), ("src/b.rs", "parse")],
+                )
+                .unwrap();
+        }
+        stats
+            .record(AccessKind::Open, &[("src/b.rs", "parse")])
+            .unwrap();
+
+        let hot = stats.hottest(10).unwrap();
+        assert_eq!(hot.len(), 2);
+        assert_eq!(
+            (hot[0].name.as_str(), hot[0].hits, hot[0].opens),
+            ("parse", 3, 1)
+        );
+        assert_eq!(
+            (hot[1].name.as_str(), hot[1].hits, hot[1].opens),
+            ("search", 3, 0)
+        );
+        assert_eq!(stats.hottest(1).unwrap().len(), 1);
+    }
+
+    #[test]
+    fn prior_is_log_normalized() {
+        let access = |name: &str, hits, opens| ChunkAccess {
+            origin: "src/lib.rs".to_string(),
+            name: name.to_string(),
+            hits,
+            opens,
+            last_seen: 0,
+        };
+        let prior = PopularityPrior::from_counts([access("hot", 0, 20), access("warm", 10, 0)]);
+        assert_eq!(prior.get("src/lib.rs", "hot"), 1.0);
+        let warm = prior.get("src/lib.rs", "warm");
+        assert!(warm > 0.5 && warm < 1.0, "{warm}");
+        assert_eq!(prior.get("src/lib.rs", "cold"), 0.0);
+        assert_eq!(prior.get("src/other.rs", "hot"), 0.0);
+        assert!(PopularityPrior::from_counts([access("none", 0, 0)]).is_empty());
+    }
+}
@@ -0,0 +1,272 @@
+//! Generated-code and license-boilerplate detection.
+//!
+//! Chunks that nobody should read or edit — codegen output and license
+//! text — get tagged at index time (`chunks.boilerplate`, schema v47) and are
+//! left out of search results unless the caller opts back in with
+//! `--include-generated`. `cqs stats` reports how much of the index they
+//! make up.
+//!
+//! A chunk is **generated** when:
+//!
+//! - its file name follows a codegen convention (`*.pb.go`, `*_pb2.py`,
+//!   `*.g.dart`, `*.designer.cs`, `zz_generated.*`, …), or
+//! - a comment in its doc or the head of its content carries a codegen
+//!   header: Go's `// Code generated … DO NOT EDIT.`, `@generated`,
+//!   `<auto-generated>`, or any other `DO NOT EDIT` banner.
+//!
+//! A chunk is **license** boilerplate when it is prose (markdown or plain
+//! text) from a `LICENSE` / `COPYING` / `NOTICE` file, or prose whose body is
+//! one of the common license grants (MIT, Apache, GPL, BSD, MPL, an SPDX
+//! identifier). License headers sitting in a code chunk's doc comment do not
+//! tag the code under them.
+//!
+//! Only what a chunk carries is inspected, so a codegen header separated from
+//! the first item by a blank line is caught by the file name or not at all.
+
+use crate::parser::ChunkType;
+
+/// Most lines of a chunk's content scanned for a codegen header.
+const MAX_HEAD_LINES: usize = 16;
+
+/// File-name suffixes that codegen tools emit (matched on the lowercased
+/// base name).
+const GENERATED_SUFFIXES: &[&str] = &[
+    ".pb.go",
+    ".pb.gw.go",
+    ".pb.cc",
+    ".pb.h",
+    "_pb2.py",
+    "_pb2.pyi",
+    "_pb2_grpc.py",
+    "_generated.go",
+    ".gen.go",
+    ".g.dart",
+    ".freezed.dart",
+    ".generated.cs",
+    ".designer.cs",
+    ".g.cs",
+    ".g.i.cs",
+];
+
+/// Lowercased phrases that only appear in license grants. One is enough.
+const LICENSE_PHRASES: &[&str] = &[
+    "spdx-license-identifier:",
+    "permission is hereby granted, free of charge",
+    "licensed under the apache license",
+    "gnu general public license",
+    "gnu lesser general public license",
+    "gnu affero general public license",
+    "redistribution and use in source and binary forms",
+    "mozilla public license",
+    "the software is provided \"as is\"",
+];
+
+/// Why a chunk is kept out of search by default.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, serde::Serialize, serde::Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Boilerplate {
+    /// Output of a code generator.
+    Generated,
+    /// License text.
+    License,
+}
+
+impl Boilerplate {
+    /// Stored form in `chunks.boilerplate`.
+    pub fn as_str(self) -> &'static str {
+        match self {
+            Self::Generated => "generated",
+            Self::License => "license",
+        }
+    }
+
+    /// Parse the stored form; `None` for anything unrecognised.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s {
+            "generated" => Some(Self::Generated),
+            "license" => Some(Self::License),
+            _ => None,
+        }
+    }
+}
+
+impl std::fmt::Display for Boilerplate {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        f.write_str(self.as_str())
+    }
+}
+
+/// Classify one chunk. `origin` is the slash-normalized source path.
+/// Generated wins over license when both apply.
+pub fn detect(
+    origin: &str,
+    chunk_type: ChunkType,
+    content: &str,
+    doc: Option<&str>,
+) -> Option<Boilerplate> {
+    let file_name = origin
+        .rsplit('/')
+        .next()
+        .unwrap_or(origin)
+        .to_ascii_lowercase();
+    if is_generated_file_name(&file_name) {
+        return Some(Boilerplate::Generated);
+    }
+    let head = doc
+        .into_iter()
+        .flat_map(str::lines)
+        .chain(content.lines().take(MAX_HEAD_LINES));
+    for line in head {
+        if is_generated_header(line) {
+            return Some(Boilerplate::Generated);
+        }
+    }
+    if chunk_type == ChunkType::Section && is_license_text(&file_name, content) {
+        return Some(Boilerplate::License);
+    }
+    None
+}
+
+fn is_generated_file_name(file_name: &str) -> bool {
+    file_name.starts_with("zz_generated")
+        || GENERATED_SUFFIXES.iter().any(|s| file_name.ends_with(s))
+}
+
+/// Whether a comment line is a codegen banner. Lines without a comment
+/// leader are code and never match, so a string literal that says
+/// "DO NOT EDIT" doesn't tag the function holding it.
+fn is_generated_header(line: &str) -> bool {
+    let line = line.trim();
+    let Some(text) = ["//", "#", "/*", "*", "--", "<!--", ";"]
+        .iter()
+        .find_map(|leader| line.strip_prefix(leader))
+    else {
+        return false;
+    };
+    if text.contains("DO NOT EDIT") || text.contains("@generated") {
+        return true;
+    }
+    let lower = text
+        .trim_start_matches(['/', '!', '*', '#'])
+        .trim()
+        .to_ascii_lowercase();
+    lower.starts_with("<auto-generated")
+        || lower.starts_with("code generated by")
+        || lower.starts_with("this file was automatically generated")
+        || lower.starts_with("this file is automatically generated")
+        || lower.starts_with("this file was generated by")
+        || lower.starts_with("autogenerated by")
+        || lower.starts_with("auto-generated by")
+}
+
+fn is_license_text(file_name: &str, content: &str) -> bool {
+    let stem = file_name.split('.').next().unwrap_or(file_name);
+    if matches!(
+        stem,
+        "license" | "licence" | "copying" | "notice" | "unlicense"
+    ) || stem.starts_with("license-")
+    {
+        return true;
+    }
+    let lower = content.to_ascii_lowercase();
+    LICENSE_PHRASES.iter().any(|p| lower.contains(p))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn function(origin: &str, content: &str, doc: Option<&str>) -> Option<Boilerplate> {
+        detect(origin, ChunkType::Function, content, doc)
+    }
+
+    #[test]
+    fn generated_by_file_name() {
+        for origin in [
+            "api/v1/service.pb.go",
+            "proto/service_pb2.py",
+            "lib/models/user.g.dart",
+            "Forms/Main.Designer.cs",
+            "pkg/apis/zz_generated.deepcopy.go",
+        ] {
+            assert_eq!(
+                function(origin, "func X() {}", None),
+                Some(Boilerplate::Generated),
+                "{origin}"
+            );
+        }
+        assert_eq!(function("src/protocol.rs", "fn x() {}", None), None);
+    }
+
+    #[test]
+    fn generated_by_header_comment() {
+        assert_eq!(
+            function(
+                "src/tokens.go",
+                "func (t Token) String() string {}",
+                Some("// Code generated by \"stringer -type=Token\"; DO NOT EDIT.")
+            ),
+            Some(Boilerplate::Generated)
+        );
+        assert_eq!(
+            function(
+                "src/schema.ts",
+                "/* @generated by gen-schema */\nexport const schema = {};",
+                None
+            ),
+            Some(Boilerplate::Generated)
+        );
+        assert_eq!(
+            function(
+                "Api.cs",
+                "// <auto-generated>\n\
+                 //   This code was generated by a tool.\n\
+                 // </auto-generated>\n\
+                 class Api {}",
+                None
+            ),
+            Some(Boilerplate::Generated)
+        );
+        // A string literal is code, not a banner.
+        assert_eq!(
+            function(
+                "src/gen.rs",
+                "fn header() -> &'static str {\n    \"// DO NOT EDIT\"\n}",
+                None
+            ),
+            None
+        );
+    }
+
+    #[test]
+    fn license_only_for_prose() {
+        let mit = "Permission is hereby granted, free of charge, to any person obtaining a copy";
+        assert_eq!(
+            detect("README.md", ChunkType::Section, mit, None),
+            Some(Boilerplate::License)
+        );
+        assert_eq!(
+            detect("LICENSE", ChunkType::Section, "Copyright 2024 Acme", None),
+            Some(Boilerplate::License)
+        );
+        // A license header on a code chunk leaves the code searchable.
+        assert_eq!(function("src/lib.rs", "fn main() {}", Some(mit)), None);
+        assert_eq!(
+            detect(
+                "README.md",
+                ChunkType::Section,
+                "## License\n\nMIT, see LICENSE.",
+                None
+            ),
+            None
+        );
+    }
+
+    #[test]
+    fn stored_form_round_trips() {
+        for b in [Boilerplate::Generated, Boilerplate::License] {
+            assert_eq!(Boilerplate::parse(b.as_str()), Some(b));
+        }
+        assert_eq!(Boilerplate::parse("vendored"), None);
+    }
+}
@@ -0,0 +1,486 @@
+//! Project boost list — a checked-in `cqs-boosts.toml` at the project root.
+//!
+//! Teams know things the embedder can't: which of five retry helpers is the
+//! blessed one, which module is on its way out. The boost list writes that
+//! down next to the code:
+//!
+//! ```toml
+//! [[pin]]
+//! name = "retry_with_backoff"        # chunk name, or `Type::method`
+//! path = "src/net/**"                # glob (or directory) on the file
+//! queries = ["retry", "backoff"]     # only for queries mentioning one of these
+//! factor = 1.5                       # default 1.5
+//! reason = "the blessed retry helper"
+//!
+//! [[deprecate]]
+//! path = "src/legacy"
+//! factor = 0.6                       # default 0.6
+//! reason = "replaced by src/net"
+//! ```
+//!
+//! A rule needs a `name`, a `path`, or both; when both are given a chunk must
+//! match both. `queries` terms match case-insensitively anywhere in the query;
+//! without them the rule applies to every search. Each matching rule
+//! multiplies the candidate's score by its `factor` (pins above 1, deprecations
+//! below), once per search after fusion and the type boost and before the
+//! user ranking hook, MMR and the final truncate — so a pinned chunk in the
+//! candidate pool can climb onto the page and a deprecated one can fall off
+//! it. The factor shows up as a `boost` entry in `rank_signals`, and
+//! `--explain` prints the rule behind it.
+//!
+//! A missing file means no boosts. A file that doesn't parse is ignored with a
+//! warning, so a bad edit never breaks search.
+
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use std::sync::{Arc, Mutex};
+use std::time::{Duration, Instant, SystemTime};
+
+use serde::{Deserialize, Serialize};
+use thiserror::Error;
+
+use crate::store::helpers::{ChunkSummary, SearchResult};
+
+/// File name of the boost list at the project root.
+pub const BOOSTS_FILENAME: &str = "cqs-boosts.toml";
+
+/// Multiplier for a `[[pin]]` rule without a `factor`.
+pub const DEFAULT_PIN_FACTOR: f32 = 1.5;
+
+/// Multiplier for a `[[deprecate]]` rule without a `factor`.
+pub const DEFAULT_DEPRECATE_FACTOR: f32 = 0.6;
+
+/// Largest pin factor accepted. Past this a pin stops being a ranking
+/// preference and becomes a filter.
+const MAX_PIN_FACTOR: f32 = 10.0;
+
+/// How often the daemon re-checks the file's mtime.
+const RULES_TTL: Duration = Duration::from_secs(5);
+
+#[derive(Debug, Error)]
+pub enum BoostsError {
+    #[error("Failed to read {}: {source}", path.display())]
+    Io {
+        path: PathBuf,
+        #[source]
+        source: std::io::Error,
+    },
+    #[error("Invalid {}: {message}", path.display())]
+    Invalid { path: PathBuf, message: String },
+}
+
+/// Which table a rule came from.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum BoostKind {
+    Pin,
+    Deprecate,
+}
+
+impl BoostKind {
+    pub fn as_str(self) -> &'static str {
+        match self {
+            BoostKind::Pin => "pin",
+            BoostKind::Deprecate => "deprecate",
+        }
+    }
+}
+
+#[derive(Deserialize, Default)]
+#[serde(deny_unknown_fields)]
+struct BoostsFile {
+    #[serde(default)]
+    pin: Vec<RuleSpec>,
+    #[serde(default)]
+    deprecate: Vec<RuleSpec>,
+}
+
+#[derive(Deserialize)]
+#[serde(deny_unknown_fields)]
+struct RuleSpec {
+    name: Option<String>,
+    path: Option<String>,
+    #[serde(default)]
+    queries: Vec<String>,
+    factor: Option<f32>,
+    reason: Option<String>,
+}
+
+/// One validated rule from the boost list.
+#[derive(Debug, Clone)]
+pub struct BoostRule {
+    pub kind: BoostKind,
+    pub name: Option<String>,
+    pub path: Option<String>,
+    /// Lowercased query terms; empty = every query
+    pub queries: Vec<String>,
+    pub factor: f32,
+    pub reason: Option<String>,
+    matcher: Option<globset::GlobSet>,
+}
+
+impl BoostRule {
+    fn from_spec(kind: BoostKind, index: usize, spec: RuleSpec) -> Result<Self, String> {
+        let at = format!("[[{}]] #{}", kind.as_str(), index + 1);
+        let name = spec.name.filter(|n| !n.trim().is_empty());
+        let path = spec.path.filter(|p| !p.trim().is_empty());
+        if name.is_none() && path.is_none() {
+            return Err(format!("{at} needs a name or a path"));
+        }
+        let factor = match kind {
+            BoostKind::Pin => spec.factor.unwrap_or(DEFAULT_PIN_FACTOR),
+            BoostKind::Deprecate => spec.factor.unwrap_or(DEFAULT_DEPRECATE_FACTOR),
+        };
+        let valid = match kind {
+            BoostKind::Pin => factor > 1.0 && factor <= MAX_PIN_FACTOR,
+            BoostKind::Deprecate => factor > 0.0 && factor < 1.0,
+        };
+        if !valid {
+            let range = match kind {
+                BoostKind::Pin => format!("above 1 and at most {MAX_PIN_FACTOR}"),
+                BoostKind::Deprecate => "between 0 and 1".to_string(),
+            };
+            return Err(format!("{at} factor {factor} must be {range}"));
+        }
+        let matcher = path
+            .as_deref()
+            .map(path_matcher)
+            .transpose()
+            .map_err(|e| format!("{at}: {e}"))?;
+        Ok(Self {
+            kind,
+            name,
+            path,
+            queries: spec
+                .queries
+                .iter()
+                .map(|q| q.trim().to_lowercase())
+                .filter(|q| !q.is_empty())
+                .collect(),
+            factor,
+            reason: spec.reason,
+            matcher,
+        })
+    }
+
+    /// Whether the rule covers `chunk`, ignoring the query.
+    pub fn matches_chunk(&self, chunk: &ChunkSummary) -> bool {
+        if let Some(name) = &self.name {
+            let qualified = chunk.parent_type_name.as_deref().is_some_and(|parent| {
+                *name == format!("{parent}::{}", chunk.name)
+                    || *name == format!("{parent}.{}", chunk.name)
+            });
+            if *name != chunk.name && !qualified {
+                return false;
+            }
+        }
+        match &self.matcher {
+            Some(m) => m.is_match(crate::normalize_path(&chunk.file)),
+            None => true,
+        }
+    }
+
+    /// Whether the rule applies to a search for `query_lower` (already
+    /// lowercased).
+    fn matches_query(&self, query_lower: &str) -> bool {
+        self.queries.is_empty() || self.queries.iter().any(|q| query_lower.contains(q))
+    }
+}
+
+/// A glob when `path` has glob syntax, otherwise the path itself and
+/// everything under it (same convention as `cqs todos --path`).
+fn path_matcher(path: &str) -> Result<globset::GlobSet, String> {
+    let path = path.trim_start_matches("./").trim_end_matches('/');
+    let patterns = if path.contains(['*', '?', '[', '{']) {
+        vec![path.to_string()]
+    } else {
+        vec![path.to_string(), format!("{path}/**")]
+    };
+    let mut builder = globset::GlobSetBuilder::new();
+    for p in &patterns {
+        builder.add(globset::Glob::new(p).map_err(|e| format!("invalid path '{p}': {e}"))?);
+    }
+    builder
+        .build()
+        .map_err(|e| format!("invalid path '{path}': {e}"))
+}
+
+/// The parsed boost list.
+#[derive(Debug, Clone, Default)]
+pub struct BoostRules {
+    rules: Vec<BoostRule>,
+}
+
+impl BoostRules {
+    /// Parse the TOML text of a boost list. `path` is only used in errors.
+    pub fn parse(text: &str, path: &Path) -> Result<Self, BoostsError> {
+        let invalid = |message: String| BoostsError::Invalid {
+            path: path.to_path_buf(),
+            message,
+        };
+        let file: BoostsFile = toml::from_str(text).map_err(|e| invalid(e.to_string()))?;
+        let mut rules = Vec::with_capacity(file.pin.len() + file.deprecate.len());
+        for (kind, specs) in [
+            (BoostKind::Pin, file.pin),
+            (BoostKind::Deprecate, file.deprecate),
+        ] {
+            for (i, spec) in specs.into_iter().enumerate() {
+                rules.push(BoostRule::from_spec(kind, i, spec).map_err(invalid)?);
+            }
+        }
+        Ok(Self { rules })
+    }
+
+    /// Read and parse the boost list at `path`.
+    pub fn load(path: &Path) -> Result<Self, BoostsError> {
+        let text = std::fs::read_to_string(path).map_err(|source| BoostsError::Io {
+            path: path.to_path_buf(),
+            source,
+        })?;
+        Self::parse(&text, path)
+    }
+
+    pub fn rules(&self) -> &[BoostRule] {
+        &self.rules
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.rules.is_empty()
+    }
+
+    /// Rules that apply to `chunk` in a search for `query`.
+    pub fn matching<'a>(&'a self, query: &str, chunk: &ChunkSummary) -> Vec<&'a BoostRule> {
+        let query_lower = query.to_lowercase();
+        self.rules
+            .iter()
+            .filter(|r| r.matches_query(&query_lower) && r.matches_chunk(chunk))
+            .collect()
+    }
+
+    /// Multiply each result's score by the factors of its matching rules and
+    /// re-sort (chunk id breaks ties). Returns the combined factor per
+    /// adjusted chunk for the `boost` provenance signal.
+    pub(crate) fn apply(&self, query: &str, results: &mut [SearchResult]) -> HashMap<String, f32> {
+        let _span = tracing::debug_span!("apply_boosts", candidates = results.len()).entered();
+        let query_lower = query.to_lowercase();
+        let active: Vec<&BoostRule> = self
+            .rules
+            .iter()
+            .filter(|r| r.matches_query(&query_lower))
+            .collect();
+        let mut factors = HashMap::new();
+        if active.is_empty() {
+            return factors;
+        }
+        for r in results.iter_mut() {
+            let factor: f32 = active
+                .iter()
+                .filter(|rule| rule.matches_chunk(&r.chunk))
+                .map(|rule| rule.factor)
+                .product();
+            if factor != 1.0 {
+                r.score *= factor;
+                factors.insert(r.chunk.id.clone(), factor);
+            }
+        }
+        if !factors.is_empty() {
+            results.sort_by(|a, b| {
+                b.score
+                    .total_cmp(&a.score)
+                    .then(a.chunk.id.cmp(&b.chunk.id))
+            });
+        }
+        tracing::debug!(adjusted = factors.len(), "Project boosts applied");
+        factors
+    }
+}
+
+/// Path of the boost list for the project at `root`.
+pub fn boosts_path(root: &Path) -> PathBuf {
+    root.join(BOOSTS_FILENAME)
+}
+
+struct CachedRules {
+    path: PathBuf,
+    checked_at: Instant,
+    modified: Option<SystemTime>,
+    rules: Option<Arc<BoostRules>>,
+}
+
+/// Keyed by path so a daemon serving several projects keeps one entry each.
+static RULES_CACHE: Mutex<Vec<CachedRules>> = Mutex::new(Vec::new());
+
+/// Boost rules for a search in the project at `root`, or `None` when there
+/// is no `cqs-boosts.toml`, it is empty, or it doesn't parse (logged). The
+/// file is re-read when its mtime changes, checked at most every
+/// [`RULES_TTL`].
+pub fn boosts_for_search(root: &Path) -> Option<Arc<BoostRules>> {
+    let path = boosts_path(root);
+    let mut cache = RULES_CACHE.lock().unwrap_or_else(|p| p.into_inner());
+    let pos = cache.iter().position(|c| c.path == path);
+    if let Some(c) = pos.map(|i| &cache[i]) {
+        if c.checked_at.elapsed() < RULES_TTL {
+            return c.rules.clone();
+        }
+    }
+    let modified = std::fs::metadata(&path).and_then(|m| m.modified()).ok();
+    if let Some(i) = pos {
+        if cache[i].modified == modified {
+            cache[i].checked_at = Instant::now();
+            return cache[i].rules.clone();
+        }
+    }
+    let rules = if path.exists() {
+        match BoostRules::load(&path) {
+            Ok(rules) if !rules.is_empty() => Some(Arc::new(rules)),
+            Ok(_) => None,
+            Err(e) => {
+                tracing::warn!(error = %e, "Ignoring project boost list");
+                None
+            }
+        }
+    } else {
+        None
+    };
+    let entry = CachedRules {
+        path,
+        checked_at: Instant::now(),
+        modified,
+        rules: rules.clone(),
+    };
+    match pos {
+        Some(i) => cache[i] = entry,
+        None => cache.push(entry),
+    }
+    rules
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::parser::{ChunkType, Language};
+
+    fn chunk(file: &str, name: &str, parent: Option<&str>) -> ChunkSummary {
+        ChunkSummary {
+            id: format!("{file}:1:{name}"),
+            file: PathBuf::from(file),
+            language: Language::Rust,
+            chunk_type: ChunkType::Function,
+            name: name.to_string(),
+            signature: String::new(),
+            content: String::new(),
+            doc: None,
+            line_start: 1,
+            line_end: 5,
+            parent_id: None,
+            parent_type_name: parent.map(str::to_string),
+            content_hash: String::new(),
+            window_idx: None,
+            parser_version: 0,
+            vendored: false,
+            deprecated: false,
+            boilerplate: None,
+        }
+    }
+
+    fn parse(text: &str) -> Result<BoostRules, BoostsError> {
+        BoostRules::parse(text, Path::new(BOOSTS_FILENAME))
+    }
+
+    #[test]
+    fn parse_applies_defaults_and_rejects_bad_rules() {
+        let rules = parse(
+            "[[pin]]\nname = \"retry\"\nqueries = [\"Retry\"]\n\n\
+             [[deprecate]]\npath = \"src/legacy\"\n",
+        )
+        .unwrap();
+        assert_eq!(rules.rules().len(), 2);
+        assert_eq!(rules.rules()[0].factor, DEFAULT_PIN_FACTOR);
+        assert_eq!(rules.rules()[0].queries, ["retry"]);
+        assert_eq!(rules.rules()[1].factor, DEFAULT_DEPRECATE_FACTOR);
+
+        assert!(parse("[[pin]]\nreason = \"no target\"\n").is_err());
+        assert!(parse("[[pin]]\nname = \"a\"\nfactor = 0.5\n").is_err());
+        assert!(parse("[[deprecate]]\nname = \"a\"\nfactor = 2.0\n").is_err());
+        assert!(parse("[[pin]]\nname = \"a\"\nboost = 2.0\n").is_err());
+        assert!(parse("").unwrap().is_empty());
+    }
+
+    #[test]
+    fn rules_match_name_qualified_name_and_path() {
+        let rules = parse(
+            "[[pin]]\nname = \"Client::send\"\n\n\
+             [[deprecate]]\npath = \"src/legacy\"\n\n\
+             [[deprecate]]\nname = \"parse\"\npath = \"src/old/*.rs\"\n",
+        )
+        .unwrap();
+        let send = chunk("src/net.rs", "send", Some("Client"));
+        assert_eq!(rules.matching("q", &send).len(), 1);
+        assert!(rules
+            .matching("q", &chunk("src/net.rs", "send", None))
+            .is_empty());
+        assert_eq!(
+            rules
+                .matching("q", &chunk("src/legacy/net/a.rs", "x", None))
+                .len(),
+            1
+        );
+        assert_eq!(
+            rules
+                .matching("q", &chunk("src/old/p.rs", "parse", None))
+                .len(),
+            1
+        );
+        assert!(rules
+            .matching("q", &chunk("src/old/p.rs", "lex", None))
+            .is_empty());
+    }
+
+    #[test]
+    fn apply_reorders_only_for_matching_queries() {
+        let rules = parse(
+            "[[pin]]\nname = \"retry_with_backoff\"\nqueries = [\"retry\"]\nfactor = 2.0\n\n\
+             [[deprecate]]\npath = \"legacy\"\nfactor = 0.5\n",
+        )
+        .unwrap();
+        let mut results = vec![
+            SearchResult::new(chunk("legacy/net.rs", "old_retry", None), 0.9),
+            SearchResult::new(chunk("src/util.rs", "retry_loop", None), 0.8),
+            SearchResult::new(chunk("src/net.rs", "retry_with_backoff", None), 0.5),
+        ];
+        let factors = rules.apply("How do I Retry a request", &mut results);
+        let names: Vec<&str> = results.iter().map(|r| r.chunk.name.as_str()).collect();
+        assert_eq!(names, ["retry_with_backoff", "retry_loop", "old_retry"]);
+        assert_eq!(factors.len(), 2);
+        assert_eq!(factors["src/net.rs:1:retry_with_backoff"], 2.0);
+
+        // The pin is scoped to retry queries; the deprecation is not.
+        let mut results = vec![
+            SearchResult::new(chunk("src/net.rs", "retry_with_backoff", None), 0.5),
+            SearchResult::new(chunk("legacy/net.rs", "old_retry", None), 0.9),
+        ];
+        let factors = rules.apply("open a socket", &mut results);
+        assert_eq!(factors.len(), 1);
+        assert_eq!(results[0].chunk.name, "retry_with_backoff");
+        assert!((results[1].score - 0.45).abs() < 1e-6);
+    }
+
+    #[test]
+    fn boosts_for_search_reads_the_project_file() {
+        let dir = tempfile::tempdir().unwrap();
+        assert!(boosts_for_search(dir.path()).is_none());
+
+        let other = tempfile::tempdir().unwrap();
+        std::fs::write(
+            boosts_path(other.path()),
+            "[[deprecate]]\npath = \"legacy\"\n",
+        )
+        .unwrap();
+        let rules = boosts_for_search(other.path()).unwrap();
+        assert_eq!(rules.rules()[0].kind, BoostKind::Deprecate);
+
+        let broken = tempfile::tempdir().unwrap();
+        std::fs::write(boosts_path(broken.path()), "[[pin]]\nfactor = \"x\"\n").unwrap();
+        assert!(boosts_for_search(broken.path()).is_none());
+    }
+}
@@ -0,0 +1,118 @@
+//! Persistent cache of query-time HyDE documents.
+//!
+//! `cqs search --hyde` asks the LLM for a hypothetical code snippet that
+//! would answer the query and searches with its embedding blended into the
+//! query's. Generation costs an LLM call, so the snippet is cached on disk by
+//! `(blake3(query), model)`: repeating a query, or re-running it with other
+//! filters, reuses the snippet. Best-effort like [`super::QueryCache`] — read
+//! and write failures are logged and treated as a miss.
+
+use super::*;
+use std::path::Path;
+use std::sync::Arc;
+
+/// Persistent `(query hash, model) → hypothetical document` cache.
+pub struct HydeCache {
+    pool: sqlx::SqlitePool,
+    rt: Arc<tokio::runtime::Runtime>,
+}
+
+impl HydeCache {
+    /// Default cache location, beside the query embedding cache.
+    pub fn default_path() -> std::path::PathBuf {
+        dirs::cache_dir()
+            .or_else(|| dirs::home_dir().map(|h| h.join(".cache")))
+            .unwrap_or_else(|| std::path::PathBuf::from("."))
+            .join("cqs")
+            .join("hyde_cache.db")
+    }
+
+    /// Open or create the cache.
+    pub fn open(path: &Path) -> Result<Self, CacheError> {
+        let _span = tracing::info_span!("hyde_cache_open", path = %path.display()).entered();
+        // Private like the query cache: the key is derived from query text and
+        // the document answers it.
+        let (pool, rt) = connect_cache_pool(path, 5_000, None, |pool| async move {
+            sqlx::query(
+                "CREATE TABLE IF NOT EXISTS hyde_cache (
+                    query_hash TEXT NOT NULL,
+                    model TEXT NOT NULL,
+                    document TEXT NOT NULL,
+                    ts INTEGER NOT NULL DEFAULT (unixepoch()),
+                    PRIMARY KEY (query_hash, model)
+                )",
+            )
+            .execute(&pool)
+            .await?;
+            Ok(())
+        })?;
+        Ok(Self { pool, rt })
+    }
+
+    /// Cache key for `query`: blake3 hex of the trimmed text.
+    pub fn query_hash(query: &str) -> String {
+        blake3::hash(query.trim().as_bytes()).to_hex().to_string()
+    }
+
+    /// Cached document for `query` under `model`.
+    pub fn get(&self, query: &str, model: &str) -> Option<String> {
+        let hash = Self::query_hash(query);
+        self.rt.block_on(async {
+            match sqlx::query_scalar::<_, String>(
+                "SELECT document FROM hyde_cache WHERE query_hash = ?1 AND model = ?2",
+            )
+            .bind(&hash)
+            .bind(model)
+            .fetch_optional(&self.pool)
+            .await
+            {
+                Ok(doc) => doc,
+                Err(e) => {
+                    tracing::warn!(error = %e, "HyDE cache read failed");
+                    None
+                }
+            }
+        })
+    }
+
+    /// Store the document for `query` under `model` (write-through).
+    pub fn put(&self, query: &str, model: &str, document: &str) {
+        let hash = Self::query_hash(query);
+        if let Err(e) = self.rt.block_on(async {
+            sqlx::query(
+                "INSERT OR REPLACE INTO hyde_cache (query_hash, model, document, ts)
+                 VALUES (?1, ?2, ?3, unixepoch())",
+            )
+            .bind(&hash)
+            .bind(model)
+            .bind(document)
+            .execute(&self.pool)
+            .await
+        }) {
+            tracing::warn!(error = %e, "HyDE cache write failed (non-fatal)");
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn get_put_keyed_by_query_and_model() {
+        let dir = tempfile::tempdir().unwrap();
+        let cache = HydeCache::open(&dir.path().join("hyde_cache.db")).unwrap();
+        assert_eq!(cache.get("retry with backoff", "m1"), None);
+        cache.put("retry with backoff", "m1", "fn retry() {}");
+        assert_eq!(
+            cache.get("  retry with backoff ", "m1").as_deref(),
+            Some("fn retry() {}")
+        );
+        assert_eq!(cache.get("retry with backoff", "m2"), None);
+        cache.put("retry with backoff", "m1", "fn retry_v2() {}");
+        assert_eq!(
+            cache.get("retry with backoff", "m1").as_deref(),
+            Some("fn retry_v2() {}")
+        );
+    }
+}
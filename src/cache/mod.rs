@@ -159,10 +159,12 @@ fn apply_db_file_perms(path: &Path) {
 #[cfg(not(unix))]
 fn apply_db_file_perms(_path: &Path) {}
 
-/// Shared pool-open skeleton for [`EmbeddingCache::open_with_runtime`] and
-/// [`QueryCache::open_with_runtime`]: parent-dir prep (0o700), runtime
-/// fallback, WAL/Normal connect options honouring `CQS_BUSY_TIMEOUT_MS`, the
-/// 0o077 umask wrap around pool creation, the per-connection
+/// Shared pool-open skeleton for [`EmbeddingCache::open_with_runtime`],
+/// [`QueryCache::open_with_runtime`], the eval run history
+/// (`crate::eval::history`), and the access stats (`crate::access`):
+/// parent-dir prep (0o700), runtime fallback, WAL/Normal connect options
+/// honouring `CQS_BUSY_TIMEOUT_MS`, the 0o077 umask wrap around pool
+/// creation, the per-connection
 /// `wal_autocheckpoint` pragma, schema initialization, and the 0o600 chmod
 /// loop on the DB triplet.
 ///
@@ -171,7 +173,7 @@ fn apply_db_file_perms(_path: &Path) {}
 /// rationale at the call sites). `init_schema` runs on the freshly opened
 /// pool inside the umask window so schema writes (and any sidecar files they
 /// create) are born private.
-fn connect_cache_pool<F, Fut>(
+pub(crate) fn connect_cache_pool<F, Fut>(
     path: &Path,
     busy_timeout_default_ms: u64,
     runtime: Option<Arc<tokio::runtime::Runtime>>,
@@ -274,10 +276,14 @@ where
 }
 
 mod embedding_cache;
+mod hyde_cache;
 mod query_cache;
+mod translation_cache;
 
 pub use embedding_cache::EmbeddingCache;
+pub use hyde_cache::HydeCache;
 pub use query_cache::QueryCache;
+pub use translation_cache::TranslationCache;
 
 #[cfg(test)]
 mod shared_runtime_tests {
@@ -10,10 +10,18 @@ use std::sync::Arc;
 
 // ─── Query Cache ────────────────────────────────────────────────────────────
 
+/// A hit refreshes its row's `ts` only when the stored one is older than
+/// this, so a hot query costs one write an hour rather than one per search.
+/// Coarse enough for the 7-day prune and the size cap.
+const TOUCH_INTERVAL_SECS: i64 = 3600;
+
 /// Persistent query embedding cache backed by SQLite.
 ///
 /// Stores `(query_text, model_fingerprint) → embedding` on disk so that
 /// repeated queries across CLI invocations don't re-run ONNX inference.
+/// Eviction is least-recently-used: `ts` is the last write or hit (to the
+/// hour), so a query that keeps coming back survives both the size cap and
+/// the age prune however long ago it was first embedded.
 /// Best-effort: all failures are logged and silently skipped.
 pub struct QueryCache {
     pool: sqlx::SqlitePool,
@@ -75,6 +83,10 @@ impl QueryCache {
             )
             .execute(&pool)
             .await?;
+            // The prune and the eviction both walk rows by recency.
+            sqlx::query("CREATE INDEX IF NOT EXISTS idx_query_cache_ts ON query_cache(ts)")
+                .execute(&pool)
+                .await?;
 
             Ok(())
         })?;
@@ -95,7 +107,7 @@ impl QueryCache {
         })
     }
 
-    /// Evict the oldest entries until the cache fits within
+    /// Evict the least recently used entries until the cache fits within
     /// `CQS_QUERY_CACHE_MAX_SIZE` (default 100 MB). Best-effort — sqlite
     /// errors are logged and reported as `Ok(0)`.
     ///
@@ -111,9 +123,20 @@ impl QueryCache {
     /// The transaction is opened with `BEGIN IMMEDIATE` so a peer process
     /// running its own evict can't race us via deferred snapshots. See
     /// `EmbeddingCache::evict` for the full rationale.
+    ///
+    /// Every one-shot CLI search calls this, so it first compares the pages
+    /// in use against the cap — two pragmas, no lock, no scan — and returns
+    /// without writing while the file is under it.
     pub fn evict(&self) -> Result<usize, CacheError> {
         let _span = tracing::info_span!("query_cache_evict").entered();
 
+        if self
+            .used_bytes()
+            .is_some_and(|used| used <= self.max_size_bytes)
+        {
+            return Ok(0);
+        }
+
         // Process-global static — see QUERY_CACHE_EVICT_LOCK docs.
         let _guard = QUERY_CACHE_EVICT_LOCK
             .lock()
@@ -191,6 +214,26 @@ impl QueryCache {
         })
     }
 
+    /// Bytes of the database file holding rows: pages in use (free pages
+    /// left by deletes excluded) times the page size. `None` when the
+    /// pragmas fail, so the caller falls back to the full measure.
+    fn used_bytes(&self) -> Option<u64> {
+        self.rt.block_on(async {
+            let pages: i64 = sqlx::query_scalar(
+                "SELECT (SELECT page_count FROM pragma_page_count()) \
+                 - (SELECT freelist_count FROM pragma_freelist_count())",
+            )
+            .fetch_one(&self.pool)
+            .await
+            .ok()?;
+            let page_size: i64 = sqlx::query_scalar("PRAGMA page_size")
+                .fetch_one(&self.pool)
+                .await
+                .ok()?;
+            Some(pages.max(0) as u64 * page_size.max(0) as u64)
+        })
+    }
+
     /// Logical size of the cache in bytes (sum of embedding blobs + row overhead).
     /// Used by `cqs cache stats --json` to surface query-cache size alongside
     /// the embedding cache.
@@ -210,11 +253,13 @@ impl QueryCache {
         self.rt.block_on(async {
             // Log sqlite failures instead of treating them as a silent cache
             // miss. A corrupted / locked cache is a real signal, not noise.
-            let row: Option<(Vec<u8>,)> = match sqlx::query_as(
-                "SELECT embedding FROM query_cache WHERE query = ?1 AND model_fp = ?2",
+            let row: Option<(Vec<u8>, bool)> = match sqlx::query_as(
+                "SELECT embedding, ts < unixepoch() - ?3 FROM query_cache
+                 WHERE query = ?1 AND model_fp = ?2",
             )
             .bind(query)
             .bind(model_fp)
+            .bind(TOUCH_INTERVAL_SECS)
             .fetch_optional(&self.pool)
             .await
             {
@@ -234,7 +279,7 @@ impl QueryCache {
                 }
             };
 
-            let (bytes,) = row?;
+            let (bytes, stale) = row?;
             // A malformed embedding blob (length not a multiple of 4) means
             // the row is corrupt. Log and delete so future reads skip the cost
             // of re-checking the same bad row.
@@ -254,6 +299,23 @@ impl QueryCache {
                 }
                 return None;
             }
+            // Refresh the hit's recency for eviction, at most once per
+            // `TOUCH_INTERVAL_SECS`: an UPDATE takes the write lock even when
+            // it matches nothing, so it only runs for a stale row and the
+            // read path stays write-free for hot queries. A failed touch only
+            // costs recency, never the hit.
+            if stale {
+                if let Err(e) = sqlx::query(
+                    "UPDATE query_cache SET ts = unixepoch() WHERE query = ?1 AND model_fp = ?2",
+                )
+                .bind(query)
+                .bind(model_fp)
+                .execute(&self.pool)
+                .await
+                {
+                    tracing::debug!(error = %e, "Query cache recency update failed (non-fatal)");
+                }
+            }
             // See `read_batch` above for the zero-copy cast rationale. Same
             // producer/consumer invariants apply here.
             let floats: Vec<f32> = bytemuck::cast_slice::<u8, f32>(&bytes).to_vec();
@@ -291,7 +353,8 @@ impl QueryCache {
         }
     }
 
-    /// Prune entries older than `days` days. Returns count deleted.
+    /// Prune entries not written or hit in the last `days` days. Returns
+    /// count deleted.
     pub fn prune_older_than(&self, days: u32) -> Result<u64, CacheError> {
         let rows = self.rt.block_on(async {
             let result = sqlx::query("DELETE FROM query_cache WHERE ts < unixepoch() - ?1 * 86400")
@@ -440,6 +503,88 @@ mod query_cache_malformed_blob_tests {
     }
 }
 
+// ─── QueryCache LRU eviction ────────────────────────────────────────────────
+//
+// A hit refreshes the row's `ts`, so the size cap and the age prune drop the
+// queries nobody asks any more rather than the ones embedded first.
+#[cfg(test)]
+mod query_cache_lru_tests {
+    use super::*;
+
+    fn set_ts(cache: &QueryCache, query: &str, ts: i64) {
+        cache.rt.block_on(async {
+            sqlx::query("UPDATE query_cache SET ts = ?1 WHERE query = ?2")
+                .bind(ts)
+                .bind(query)
+                .execute(&cache.pool)
+                .await
+                .unwrap();
+        });
+    }
+
+    fn ts(cache: &QueryCache, query: &str) -> i64 {
+        cache.rt.block_on(async {
+            sqlx::query_scalar("SELECT ts FROM query_cache WHERE query = ?1")
+                .bind(query)
+                .fetch_one(&cache.pool)
+                .await
+                .unwrap()
+        })
+    }
+
+    #[test]
+    fn hits_touch_at_most_hourly_and_small_caches_skip_eviction() {
+        let dir = tempfile::TempDir::new().unwrap();
+        let cache = QueryCache::open(&dir.path().join("query_cache.db")).unwrap();
+        let emb = crate::embedder::Embedding::new(vec![0.5_f32; 8]);
+        cache.put("hot", "fp", &emb);
+        let now = std::time::SystemTime::now()
+            .duration_since(std::time::UNIX_EPOCH)
+            .unwrap()
+            .as_secs() as i64;
+
+        // Touched a minute ago: the hit leaves the row alone.
+        set_ts(&cache, "hot", now - 60);
+        assert!(cache.get("hot", "fp").is_some());
+        assert_eq!(ts(&cache, "hot"), now - 60);
+
+        // Touched two hours ago: the hit refreshes it.
+        set_ts(&cache, "hot", now - 2 * TOUCH_INTERVAL_SECS);
+        assert!(cache.get("hot", "fp").is_some());
+        assert!(ts(&cache, "hot") >= now);
+
+        // A few pages against a 100 MB cap: nothing to do.
+        assert!(cache.used_bytes().unwrap() < cache.max_size_bytes);
+        assert_eq!(cache.evict().unwrap(), 0);
+    }
+
+    #[test]
+    fn hit_keeps_a_query_past_eviction_and_prune() {
+        let dir = tempfile::TempDir::new().unwrap();
+        let mut cache = QueryCache::open(&dir.path().join("query_cache.db")).unwrap();
+        let emb = crate::embedder::Embedding::new(vec![0.5_f32; 8]);
+        cache.put("canned", "fp", &emb);
+        cache.put("one-off", "fp", &emb);
+        cache.put("stale", "fp", &emb);
+        // "canned" went in first; a week-old "stale" is past the prune window.
+        set_ts(&cache, "canned", 1_000);
+        set_ts(&cache, "one-off", 2_000);
+        set_ts(&cache, "stale", 1_500);
+
+        assert!(cache.get("canned", "fp").is_some());
+        assert_eq!(cache.prune_older_than(7).unwrap(), 2);
+        assert!(cache.get("canned", "fp").is_some());
+
+        cache.put("one-off", "fp", &emb);
+        set_ts(&cache, "one-off", 2_000);
+        // Room for one row: the least recently used goes, not the oldest.
+        cache.max_size_bytes = cache.size_bytes().unwrap() - 1;
+        assert_eq!(cache.evict().unwrap(), 1);
+        assert!(cache.get("canned", "fp").is_some());
+        assert!(cache.get("one-off", "fp").is_none());
+    }
+}
+
 // ─── shared-runtime integration tests ───────────────────────────────────────
 //
 // Confirms that one `Arc<Runtime>` can drive Store + EmbeddingCache +
@@ -0,0 +1,107 @@
+//! Persistent cache of query translations.
+//!
+//! With query translation on (`llm_query_translate`, `--translate`), a
+//! non-English search query is rewritten in English by the LLM provider
+//! before it is embedded and matched. Each rewrite costs an LLM call, so it
+//! is cached on disk by `(blake3(query), model)`. Best-effort like
+//! [`super::HydeCache`] — read and write failures are logged and treated as
+//! a miss.
+
+use super::*;
+use std::path::Path;
+use std::sync::Arc;
+
+/// Persistent `(query hash, model) → English query` cache.
+pub struct TranslationCache {
+    pool: sqlx::SqlitePool,
+    rt: Arc<tokio::runtime::Runtime>,
+}
+
+impl TranslationCache {
+    /// Default cache location, beside the HyDE cache.
+    pub fn default_path() -> std::path::PathBuf {
+        dirs::cache_dir()
+            .or_else(|| dirs::home_dir().map(|h| h.join(".cache")))
+            .unwrap_or_else(|| std::path::PathBuf::from("."))
+            .join("cqs")
+            .join("query_translation_cache.db")
+    }
+
+    /// Open or create the cache.
+    pub fn open(path: &Path) -> Result<Self, CacheError> {
+        let _span = tracing::info_span!("translation_cache_open", path = %path.display()).entered();
+        // Private like the query cache: both columns are query text.
+        let (pool, rt) = connect_cache_pool(path, 5_000, None, |pool| async move {
+            sqlx::query(
+                "CREATE TABLE IF NOT EXISTS translation_cache (
+                    query_hash TEXT NOT NULL,
+                    model TEXT NOT NULL,
+                    translation TEXT NOT NULL,
+                    ts INTEGER NOT NULL DEFAULT (unixepoch()),
+                    PRIMARY KEY (query_hash, model)
+                )",
+            )
+            .execute(&pool)
+            .await?;
+            Ok(())
+        })?;
+        Ok(Self { pool, rt })
+    }
+
+    /// Cached translation of `query` under `model`.
+    pub fn get(&self, query: &str, model: &str) -> Option<String> {
+        let hash = HydeCache::query_hash(query);
+        self.rt.block_on(async {
+            match sqlx::query_scalar::<_, String>(
+                "SELECT translation FROM translation_cache WHERE query_hash = ?1 AND model = ?2",
+            )
+            .bind(&hash)
+            .bind(model)
+            .fetch_optional(&self.pool)
+            .await
+            {
+                Ok(text) => text,
+                Err(e) => {
+                    tracing::warn!(error = %e, "Translation cache read failed");
+                    None
+                }
+            }
+        })
+    }
+
+    /// Store the translation of `query` under `model` (write-through).
+    pub fn put(&self, query: &str, model: &str, translation: &str) {
+        let hash = HydeCache::query_hash(query);
+        if let Err(e) = self.rt.block_on(async {
+            sqlx::query(
+                "INSERT OR REPLACE INTO translation_cache (query_hash, model, translation, ts)
+                 VALUES (?1, ?2, ?3, unixepoch())",
+            )
+            .bind(&hash)
+            .bind(model)
+            .bind(translation)
+            .execute(&self.pool)
+            .await
+        }) {
+            tracing::warn!(error = %e, "Translation cache write failed (non-fatal)");
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn get_put_keyed_by_query_and_model() {
+        let dir = tempfile::tempdir().unwrap();
+        let cache = TranslationCache::open(&dir.path().join("translation_cache.db")).unwrap();
+        assert_eq!(cache.get("Konfiguration laden", "m1"), None);
+        cache.put("Konfiguration laden", "m1", "load configuration");
+        assert_eq!(
+            cache.get(" Konfiguration laden ", "m1").as_deref(),
+            Some("load configuration")
+        );
+        assert_eq!(cache.get("Konfiguration laden", "m2"), None);
+    }
+}
@@ -0,0 +1,381 @@
+//! Score calibration — a result's raw score as the probability that it is
+//! the chunk the query was after.
+//!
+//! A score of 0.62 means nothing on its own: where a good hit lands depends
+//! on the embedding model, on whether the ranking is boosted cosine,
+//! reciprocal-rank fusion or a cross-encoder, and on the query. A
+//! [`Calibration`] maps one of those scales to a 0–1 confidence with a
+//! logistic curve (Platt scaling) fit on eval data: every result of every
+//! query in an eval set is a sample, labeled by whether it is the query's
+//! gold chunk. Search output then carries `confidence` next to `score`, and
+//! an agent can treat a top result at 0.08 as "retrieval failed" without
+//! knowing the scale.
+//!
+//! `cqs eval <set> --calibrate` fits and stores the curve for the index's
+//! model and the run's [`ScoreScale`] in `.cqs/calibration.json`. A search
+//! whose model and scale have no stored curve reports no confidence.
+
+use std::collections::BTreeMap;
+use std::path::{Path, PathBuf};
+
+use serde::{Deserialize, Serialize};
+use thiserror::Error;
+
+/// Calibration file inside the project `.cqs/` directory.
+pub const CALIBRATION_FILENAME: &str = "calibration.json";
+
+/// Fewest hits, and fewest misses, a fit needs.
+pub const MIN_CLASS_SAMPLES: usize = 5;
+
+/// Newton iterations before the fit gives up converging and keeps what it has.
+const MAX_ITERATIONS: usize = 100;
+
+#[derive(Debug, Error)]
+pub enum CalibrationError {
+    #[error("Calibration I/O error on {path}: {source}")]
+    Io {
+        path: PathBuf,
+        #[source]
+        source: std::io::Error,
+    },
+    #[error("Malformed calibration file {path}: {source}")]
+    Json {
+        path: PathBuf,
+        #[source]
+        source: serde_json::Error,
+    },
+    #[error(
+        "Calibration needs at least {min} hits and {min} misses, got {hits} and {misses}",
+        min = MIN_CLASS_SAMPLES
+    )]
+    TooFewSamples { hits: usize, misses: usize },
+    #[error("Scores do not separate hits from misses (fitted slope {0:.3}); not calibrating")]
+    NotMonotonic(f64),
+}
+
+/// The scale a result's `score` is on. Each gets its own curve.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum ScoreScale {
+    /// Boosted cosine similarity, fused with SPLADE when that leg runs (the
+    /// default ranking)
+    Similarity,
+    /// Reciprocal-rank fusion (`--rrf`)
+    Rrf,
+    /// Cross-encoder scores (`--rerank`)
+    Rerank,
+    /// Boosted MaxSim over token vectors (`--reranker late`)
+    Late,
+}
+
+impl ScoreScale {
+    /// The scale a search with these strategy flags ranks on; the reranker
+    /// has the last word, and fusion ranks on RRF whatever the dense leg
+    /// was scored by.
+    pub fn of(rrf: bool, rerank: bool, late: bool) -> Self {
+        match (rrf, rerank, late) {
+            (_, true, _) => Self::Rerank,
+            (true, false, _) => Self::Rrf,
+            (false, false, true) => Self::Late,
+            (false, false, false) => Self::Similarity,
+        }
+    }
+
+    pub fn as_str(self) -> &'static str {
+        match self {
+            Self::Similarity => "similarity",
+            Self::Rrf => "rrf",
+            Self::Rerank => "rerank",
+            Self::Late => "late",
+        }
+    }
+}
+
+impl std::fmt::Display for ScoreScale {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        f.write_str(self.as_str())
+    }
+}
+
+/// A fitted curve: `confidence = 1 / (1 + exp(-(slope * score + intercept)))`.
+#[derive(Debug, Clone, Copy, PartialEq, Serialize, Deserialize)]
+pub struct Calibration {
+    pub slope: f64,
+    pub intercept: f64,
+    /// Results the curve was fit on
+    pub samples: usize,
+    /// Of those, how many were the query's gold chunk
+    pub hits: usize,
+    /// Unix seconds of the fit
+    pub fitted_at: i64,
+}
+
+impl Calibration {
+    /// Probability that a result scoring `score` is what the query was after.
+    pub fn confidence(&self, score: f32) -> f32 {
+        let z = self.slope * f64::from(score) + self.intercept;
+        (1.0 / (1.0 + (-z).exp())) as f32
+    }
+
+    /// Fit a curve to `(score, hit)` samples.
+    ///
+    /// Platt's method as refined by Lin, Lin and Weng (2007): Newton steps
+    /// with a backtracking line search on the log loss, against targets
+    /// pulled in from 0 and 1 by the class counts so a perfectly separable
+    /// set does not fit an infinitely steep curve.
+    pub fn fit(samples: &[(f32, bool)], fitted_at: i64) -> Result<Self, CalibrationError> {
+        let hits = samples.iter().filter(|(_, hit)| *hit).count();
+        let misses = samples.len() - hits;
+        if hits < MIN_CLASS_SAMPLES || misses < MIN_CLASS_SAMPLES {
+            return Err(CalibrationError::TooFewSamples { hits, misses });
+        }
+
+        let hit_target = (hits as f64 + 1.0) / (hits as f64 + 2.0);
+        let miss_target = 1.0 / (misses as f64 + 2.0);
+        let points: Vec<(f64, f64)> = samples
+            .iter()
+            .map(|&(score, hit)| {
+                let target = if hit { hit_target } else { miss_target };
+                (f64::from(score), target)
+            })
+            .collect();
+
+        // Parameterized as P(hit) = 1 / (1 + exp(a * score + b)), as in the
+        // paper; the stored slope and intercept are the negations.
+        let loss = |a: f64, b: f64| -> f64 {
+            points
+                .iter()
+                .map(|&(f, t)| {
+                    let z = a * f + b;
+                    if z >= 0.0 {
+                        t * z + (-z).exp().ln_1p()
+                    } else {
+                        (t - 1.0) * z + z.exp().ln_1p()
+                    }
+                })
+                .sum()
+        };
+        let mut a = 0.0;
+        let mut b = ((misses as f64 + 1.0) / (hits as f64 + 1.0)).ln();
+        let mut value = loss(a, b);
+
+        for _ in 0..MAX_ITERATIONS {
+            let (mut h11, mut h22, mut h21) = (1e-12, 1e-12, 0.0);
+            let (mut g1, mut g2) = (0.0, 0.0);
+            for &(f, t) in &points {
+                let z = a * f + b;
+                let (p, q) = if z >= 0.0 {
+                    let e = (-z).exp();
+                    (e / (1.0 + e), 1.0 / (1.0 + e))
+                } else {
+                    let e = z.exp();
+                    (1.0 / (1.0 + e), e / (1.0 + e))
+                };
+                let d2 = p * q;
+                h11 += f * f * d2;
+                h22 += d2;
+                h21 += f * d2;
+                let d1 = t - p;
+                g1 += f * d1;
+                g2 += d1;
+            }
+            if g1.abs() < 1e-5 && g2.abs() < 1e-5 {
+                break;
+            }
+            let det = h11 * h22 - h21 * h21;
+            let da = -(h22 * g1 - h21 * g2) / det;
+            let db = -(-h21 * g1 + h11 * g2) / det;
+            let descent = g1 * da + g2 * db;
+
+            let mut step = 1.0;
+            let mut moved = false;
+            while step >= 1e-10 {
+                let (next_a, next_b) = (a + step * da, b + step * db);
+                let next = loss(next_a, next_b);
+                if next < value + 1e-4 * step * descent {
+                    (a, b, value) = (next_a, next_b, next);
+                    moved = true;
+                    break;
+                }
+                step /= 2.0;
+            }
+            if !moved {
+                tracing::debug!("Calibration line search stalled; keeping the last fit");
+                break;
+            }
+        }
+
+        let (slope, intercept) = (-a, -b);
+        if !slope.is_finite() || !intercept.is_finite() || slope <= 0.0 {
+            return Err(CalibrationError::NotMonotonic(slope));
+        }
+        Ok(Self {
+            slope,
+            intercept,
+            samples: samples.len(),
+            hits,
+            fitted_at,
+        })
+    }
+}
+
+/// Stored curves, by model name and score scale.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct CalibrationSet {
+    #[serde(default)]
+    pub models: BTreeMap<String, BTreeMap<ScoreScale, Calibration>>,
+}
+
+impl CalibrationSet {
+    /// Read a calibration file; empty when it does not exist.
+    pub fn load(path: &Path) -> Result<Self, CalibrationError> {
+        let bytes = match std::fs::read(path) {
+            Ok(b) => b,
+            Err(e) if e.kind() == std::io::ErrorKind::NotFound => return Ok(Self::default()),
+            Err(source) => {
+                return Err(CalibrationError::Io {
+                    path: path.to_path_buf(),
+                    source,
+                })
+            }
+        };
+        serde_json::from_slice(&bytes).map_err(|source| CalibrationError::Json {
+            path: path.to_path_buf(),
+            source,
+        })
+    }
+
+    /// Write the set to `path` atomically, creating the parent directory.
+    pub fn save(&self, path: &Path) -> Result<(), CalibrationError> {
+        let io_err = |source| CalibrationError::Io {
+            path: path.to_path_buf(),
+            source,
+        };
+        if let Some(parent) = path.parent() {
+            std::fs::create_dir_all(parent).map_err(io_err)?;
+        }
+        let json = serde_json::to_vec_pretty(self).map_err(|source| CalibrationError::Json {
+            path: path.to_path_buf(),
+            source,
+        })?;
+        let tmp = path.with_extension(format!("json.{}.tmp", std::process::id()));
+        std::fs::write(&tmp, json).map_err(io_err)?;
+        crate::fs::atomic_replace(&tmp, path).map_err(|e| {
+            let _ = std::fs::remove_file(&tmp);
+            io_err(e)
+        })
+    }
+
+    pub fn get(&self, model: &str, scale: ScoreScale) -> Option<&Calibration> {
+        self.models.get(model)?.get(&scale)
+    }
+
+    pub fn insert(&mut self, model: &str, scale: ScoreScale, calibration: Calibration) {
+        self.models
+            .entry(model.to_string())
+            .or_default()
+            .insert(scale, calibration);
+    }
+}
+
+/// Path of the calibration file under the project `.cqs/` dir.
+pub fn calibration_path(project_cqs_dir: &Path) -> PathBuf {
+    project_cqs_dir.join(CALIBRATION_FILENAME)
+}
+
+/// Best-effort: the stored curve for `model` on `scale`. A missing or
+/// unreadable file is no curve (unreadable logs at warn).
+pub fn load_calibration(
+    project_cqs_dir: &Path,
+    model: &str,
+    scale: ScoreScale,
+) -> Option<Calibration> {
+    match CalibrationSet::load(&calibration_path(project_cqs_dir)) {
+        Ok(set) => set.get(model, scale).copied(),
+        Err(e) => {
+            tracing::warn!(error = %e, "Ignoring unreadable calibration file");
+            None
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    /// Hits score around 0.8, misses around 0.4, with overlap.
+    fn samples() -> Vec<(f32, bool)> {
+        let mut s = Vec::new();
+        for i in 0..40 {
+            let jitter = (i % 10) as f32 * 0.03;
+            s.push((0.65 + jitter, true));
+            s.push((0.30 + jitter, false));
+            s.push((0.40 + jitter, false));
+        }
+        s.push((0.45, true));
+        s.push((0.80, false));
+        s
+    }
+
+    #[test]
+    fn fit_orders_and_bounds_confidence() {
+        let cal = Calibration::fit(&samples(), 7).unwrap();
+        assert!(cal.slope > 0.0);
+        assert_eq!((cal.samples, cal.hits, cal.fitted_at), (122, 41, 7));
+        let (low, mid, high) = (
+            cal.confidence(0.3),
+            cal.confidence(0.6),
+            cal.confidence(0.9),
+        );
+        assert!(low < 0.1, "{low}");
+        assert!(high > 0.9, "{high}");
+        assert!(low < mid && mid < high);
+        // The curve crosses one half between the two clusters.
+        let crossing = -cal.intercept / cal.slope;
+        assert!((0.55..0.7).contains(&crossing), "{crossing}");
+    }
+
+    #[test]
+    fn fit_rejects_thin_or_inverted_data() {
+        let thin = [(0.9, true), (0.8, true), (0.1, false), (0.2, false)];
+        assert!(matches!(
+            Calibration::fit(&thin, 0),
+            Err(CalibrationError::TooFewSamples { hits: 2, misses: 2 })
+        ));
+        let inverted: Vec<(f32, bool)> = samples().into_iter().map(|(s, hit)| (s, !hit)).collect();
+        assert!(matches!(
+            Calibration::fit(&inverted, 0),
+            Err(CalibrationError::NotMonotonic(_))
+        ));
+    }
+
+    #[test]
+    fn set_roundtrips_by_model_and_scale() {
+        let dir = tempfile::TempDir::new().unwrap();
+        let path = calibration_path(dir.path());
+        assert_eq!(
+            CalibrationSet::load(&path).unwrap(),
+            CalibrationSet::default()
+        );
+
+        let cal = Calibration::fit(&samples(), 1).unwrap();
+        let mut set = CalibrationSet::default();
+        set.insert("BAAI/bge-large-en-v1.5", ScoreScale::Rerank, cal);
+        set.save(&path).unwrap();
+
+        let json: serde_json::Value =
+            serde_json::from_slice(&std::fs::read(&path).unwrap()).unwrap();
+        assert!(json["models"]["BAAI/bge-large-en-v1.5"]["rerank"]["slope"].is_number());
+        assert_eq!(
+            load_calibration(dir.path(), "BAAI/bge-large-en-v1.5", ScoreScale::Rerank),
+            Some(cal)
+        );
+        assert_eq!(
+            load_calibration(dir.path(), "BAAI/bge-large-en-v1.5", ScoreScale::Rrf),
+            None
+        );
+        assert_eq!(ScoreScale::of(true, true, false), ScoreScale::Rerank);
+        assert_eq!(ScoreScale::of(true, false, true), ScoreScale::Rrf);
+        assert_eq!(ScoreScale::of(false, false, true), ScoreScale::Late);
+    }
+}
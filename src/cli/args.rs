@@ -7,7 +7,7 @@
 
 use clap::Args;
 
-use super::{parse_finite_f32, parse_nonzero_usize, parse_unit_f32};
+use super::{parse_finite_f32, parse_min_score, parse_nonzero_usize, parse_unit_f32, MinScore};
 use cqs::store::DeadConfidence;
 
 // ============ depth-flag default rule ============
@@ -68,9 +68,9 @@ pub const DEFAULT_DEPTH_TEST_MAP: u16 = 5;
 /// an unreachable pair surfaces in <1s.
 pub const DEFAULT_DEPTH_TRACE: u16 = 10;
 
-/// Cross-encoder reranker mode for retrieval surfaces.
+/// Second-stage reranker for retrieval surfaces.
 ///
-/// Search and eval share this flag shape. `--reranker none|onnx` is the
+/// Search and eval share this flag shape. `--reranker none|onnx|late` is the
 /// canonical form; `--help` lists only modes the binary supports.
 #[derive(Debug, Clone, Copy, PartialEq, Eq, clap::ValueEnum)]
 pub(crate) enum RerankerMode {
@@ -78,6 +78,73 @@ pub(crate) enum RerankerMode {
     None,
     /// Cross-encoder reranker via [`cqs::OnnxReranker`].
     Onnx,
+    /// Late-interaction MaxSim over stored token vectors
+    /// ([`cqs::late_interaction`]).
+    Late,
+}
+
+/// `--in`: which text the keyword leg of search matches.
+#[derive(
+    Debug, Clone, Copy, PartialEq, Eq, clap::ValueEnum, serde::Deserialize, schemars::JsonSchema,
+)]
+#[serde(rename_all = "lowercase")]
+pub(crate) enum SearchIn {
+    /// Code and comments alike (default).
+    Both,
+    /// Names, signatures, and code, with comments stripped.
+    Code,
+    /// Doc comments and comments inside the body — "why" questions.
+    Comments,
+}
+
+impl SearchIn {
+    pub(crate) fn as_str(self) -> &'static str {
+        match self {
+            SearchIn::Both => "both",
+            SearchIn::Code => "code",
+            SearchIn::Comments => "comments",
+        }
+    }
+}
+
+impl From<SearchIn> for cqs::store::FtsScope {
+    fn from(scope: SearchIn) -> Self {
+        match scope {
+            SearchIn::Both => cqs::store::FtsScope::Both,
+            SearchIn::Code => cqs::store::FtsScope::Code,
+            SearchIn::Comments => cqs::store::FtsScope::Comments,
+        }
+    }
+}
+
+/// `cqs todos --group-by`: how markers are bucketed.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, clap::ValueEnum)]
+pub(crate) enum TodoGroupBy {
+    /// One group per file (default).
+    File,
+    /// One group per owner: the file's first CODEOWNERS owner, else the
+    /// marker's blame author.
+    Owner,
+}
+
+impl From<TodoGroupBy> for cqs::todos::TodoGrouping {
+    fn from(g: TodoGroupBy) -> Self {
+        match g {
+            TodoGroupBy::File => cqs::todos::TodoGrouping::File,
+            TodoGroupBy::Owner => cqs::todos::TodoGrouping::Owner,
+        }
+    }
+}
+
+/// `cqs graph --format`: output shape of the module dependency graph.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, clap::ValueEnum)]
+pub(crate) enum GraphFormat {
+    /// Graphviz DOT (default) — pipe into `dot -Tsvg`.
+    Dot,
+    /// JSON envelope with modules, edges, and call counts.
+    Json,
+    /// Mermaid flowchart for markdown.
+    Mermaid,
 }
 
 /// Shared `--limit / -n` argument for graph commands (callers, callees, deps,
@@ -89,8 +156,9 @@ pub(crate) enum RerankerMode {
 /// Rejects `--limit 0` at parse time — limit=0 is meaningless everywhere.
 #[derive(Args, Debug, Clone)]
 pub(crate) struct LimitArg {
-    /// Max results to return (per category for impact/explain)
-    #[arg(short = 'n', long, default_value_t = DEFAULT_LIMIT, value_parser = parse_nonzero_usize)]
+    /// Max results to return (per category for impact/explain). `-k` is
+    /// accepted as a nearest-neighbor spelling.
+    #[arg(short = 'n', short_alias = 'k', long, default_value_t = DEFAULT_LIMIT, value_parser = parse_nonzero_usize)]
     pub limit: usize,
 }
 
@@ -150,6 +218,10 @@ pub(crate) struct SearchArgs {
     #[arg(short = 't', long, default_value = "0.3", value_parser = parse_finite_f32)]
     pub threshold: f32,
 
+    /// Drop results below this final score, or below PCT% of the top one
+    #[arg(long, value_name = "SCORE|PCT%", value_parser = parse_min_score)]
+    pub min_score: Option<MinScore>,
+
     /// Weight for name matching in hybrid search (0.0-1.0)
     ///
     /// `value_parser` is `parse_unit_f32` (bounded [0.0, 1.0]) so out-of-range
@@ -178,6 +250,30 @@ pub(crate) struct SearchArgs {
     #[arg(long)]
     pub pattern: Option<String>,
 
+    /// Only rank chunks whose source text matches this regex (applied before
+    /// hybrid ranking)
+    #[arg(long, value_name = "REGEX")]
+    pub grep: Option<String>,
+
+    /// Only rank callables with this parameter/return shape, e.g.
+    /// `'(context.Context, []byte) error'` (applied before hybrid ranking)
+    #[arg(long, value_name = "SHAPE")]
+    pub signature: Option<String>,
+
+    /// Only rank code owned by this team or person (CODEOWNERS owners and
+    /// blame authors; applied before hybrid ranking)
+    #[arg(long, value_name = "NAME")]
+    pub owner: Option<String>,
+
+    /// Only rank code whose comments reference this issue or PR, e.g.
+    /// `JIRA-1234` (applied before hybrid ranking)
+    #[arg(long, value_name = "ID")]
+    pub issue: Option<String>,
+
+    /// Match the keyword leg against code or comments only (implies `--rrf`)
+    #[arg(long = "in", value_enum)]
+    pub search_in: Option<SearchIn>,
+
     /// Definition search: find by name only, skip embedding (faster)
     #[arg(long)]
     pub name_only: bool,
@@ -190,10 +286,31 @@ pub(crate) struct SearchArgs {
     #[arg(long)]
     pub include_docs: bool,
 
-    /// Reranker mode: `none|onnx`.
+    /// Rank indexed commit messages alongside code.
+    #[arg(long)]
+    pub with_commits: bool,
+
+    /// Drop deprecated code from results instead of ranking it lower.
+    #[arg(long)]
+    pub hide_deprecated: bool,
+
+    /// Keep generated code and license text in results.
+    #[arg(long)]
+    pub include_generated: bool,
+
+    /// When nothing matches, retry with `--path`, then the type filters,
+    /// then the score floors dropped; the dropped filters come back as
+    /// `relaxed`.
+    #[arg(long)]
+    pub relax: bool,
+
+    /// Reranker mode: `none|onnx|late`.
     ///
     /// Mirrors `cqs eval --reranker`. `none` is the default; `onnx` runs the
-    /// cross-encoder configured by `[reranker]` / `CQS_RERANKER_MODEL`.
+    /// cross-encoder configured by `[reranker]` / `CQS_RERANKER_MODEL`;
+    /// `late` (experimental, not yet evaluated against `onnx`) rescores the
+    /// top candidates by MaxSim over the token vectors `[late_interaction]`
+    /// stores.
     #[arg(long = "reranker", value_enum)]
     pub reranker: Option<RerankerMode>,
 
@@ -222,6 +339,10 @@ pub(crate) struct SearchArgs {
     #[arg(short = 'C', long)]
     pub context: Option<usize>,
 
+    /// Cut each result's code to N lines at a statement boundary.
+    #[arg(long, value_name = "N", value_parser = parse_nonzero_usize)]
+    pub snippet_lines: Option<usize>,
+
     /// Expand results with parent context (small-to-big retrieval)
     ///
     /// `--expand-parent` aligns with the top-level `Cli::expand_parent` flag
@@ -229,6 +350,14 @@ pub(crate) struct SearchArgs {
     #[arg(long = "expand-parent", visible_alias = "expand")]
     pub expand_parent: bool,
 
+    /// Attach the tests linked to each result (call graph + test naming)
+    #[arg(long)]
+    pub with_tests: bool,
+
+    /// Attach ownership to each result (CODEOWNERS owners + blame authors)
+    #[arg(long)]
+    pub with_owners: bool,
+
     /// Search only this reference index (skip project index)
     #[arg(long = "ref")]
     pub ref_name: Option<String>,
@@ -237,8 +366,13 @@ pub(crate) struct SearchArgs {
     #[arg(long)]
     pub include_refs: bool,
 
+    /// Restrict results to files changed since a git ref (merge-base diff
+    /// against the working tree)
+    #[arg(long, value_name = "REF", conflicts_with_all = ["ref_name", "include_refs"])]
+    pub changed_since: Option<String>,
+
     /// Maximum token budget for results (packs highest-scoring into budget)
-    #[arg(long, value_parser = parse_nonzero_usize)]
+    #[arg(long, visible_alias = "max-tokens", value_parser = parse_nonzero_usize)]
     pub tokens: Option<usize>,
 
     /// Disable staleness checks (skip per-file mtime comparison)
@@ -254,6 +388,22 @@ pub(crate) struct SearchArgs {
     #[arg(long)]
     pub no_rank_signals: bool,
 
+    /// Expand the query with a hypothetical code snippet from the LLM (HyDE)
+    #[arg(long, conflicts_with = "no_hyde")]
+    pub hyde: bool,
+
+    /// Never expand the query with HyDE, even with `CQS_QUERY_HYDE=1`
+    #[arg(long, conflicts_with = "hyde")]
+    pub no_hyde: bool,
+
+    /// Search with an English translation of the query from the LLM
+    #[arg(long, conflicts_with = "no_translate")]
+    pub translate: bool,
+
+    /// Never translate the query, even with `CQS_QUERY_TRANSLATE` set
+    #[arg(long, conflicts_with = "translate")]
+    pub no_translate: bool,
+
     /// Shared worktree-overlay tri-state (`--overlay` / `--no-overlay` /
     /// hidden `--overlay-root`) via [`OverlayArgs`] flatten — the same struct
     /// the seed-overlaid graph commands carry, so the surfaces can't diverge.
@@ -267,7 +417,7 @@ impl SearchArgs {
         self.reranker.unwrap_or(RerankerMode::None)
     }
 
-    /// `true` if any reranker stage is selected (Onnx or Llm).
+    /// `true` if any reranker stage is selected (Onnx or Late).
     pub(crate) fn rerank_active(&self) -> bool {
         !matches!(self.rerank_mode(), RerankerMode::None)
     }
@@ -429,6 +579,11 @@ pub(crate) struct DeadArgs {
     /// actionable residue. Omit for all verdicts.
     #[arg(long, value_name = "VERDICT")]
     pub verdict: Option<String>,
+    /// Allowlist file of functions never to report (one `name`, `path/glob`,
+    /// or `path/glob:name` pattern per line). Relative paths resolve against
+    /// the project root. Defaults to `.cqs-dead-allowlist` there when present.
+    #[arg(long, value_name = "FILE")]
+    pub allowlist: Option<std::path::PathBuf>,
     /// Worktree-overlay tri-state for the merged-graph dead computation (#1858
     /// Part B). Built daemon-side only (phase 1); the CLI-direct adapter ignores
     /// it.
@@ -439,7 +594,8 @@ pub(crate) struct DeadArgs {
 /// Arguments shared between CLI `similar` and batch `similar`.
 #[derive(Args, Debug, Clone)]
 pub(crate) struct SimilarArgs {
-    /// Function name or file:function (e.g., "search_filtered" or "src/search.rs:search_filtered")
+    /// Function name, file:function, file:line, or chunk id (e.g., "search_filtered",
+    /// "src/search.rs:search_filtered", "src/search.rs:120")
     pub name: String,
     /// Shared `--limit` arg via `LimitArg` flatten.
     #[command(flatten)]
@@ -447,6 +603,9 @@ pub(crate) struct SimilarArgs {
     /// Min similarity threshold
     #[arg(short = 't', long, default_value = "0.3", value_parser = parse_finite_f32)]
     pub threshold: f32,
+    /// Keep neighbors from the target's own file (excluded by default)
+    #[arg(long)]
+    pub same_file: bool,
     /// Filter by language.
     ///
     /// On the CLI these scope flags reach `cmd_similar` via the top-level
@@ -522,7 +681,8 @@ pub(crate) struct CallersArgs {
     #[arg(long)]
     pub cross_project: bool,
     /// Restrict to call edges of one provenance kind: `call` (syntactic),
-    /// `serde_callback`, `macro_heuristic`, `fn_pointer`, or `doc_reference`.
+    /// `serde_callback`, `ffi_boundary`, `macro_heuristic`, `fn_pointer`, or
+    /// `doc_reference`.
     /// Omit for all kinds.
     #[arg(long, value_name = "KIND")]
     pub edge_kind: Option<String>,
@@ -732,6 +892,21 @@ pub(crate) struct DiffArgs {
     pub lang: Option<String>,
 }
 
+/// Arguments for CLI `diff-index`.
+#[derive(Args, Debug, Clone)]
+pub(crate) struct DiffIndexArgs {
+    /// Old index: a `.db` file, an index directory, or a git revision
+    pub old: String,
+    /// New index: a `.db` file, an index directory, or a git revision
+    pub new: String,
+    /// Embedding similarity below which a changed symbol counts as moved
+    #[arg(short = 't', long, default_value = "0.95", value_parser = parse_unit_f32)]
+    pub threshold: f32,
+    /// Filter by language
+    #[arg(short = 'l', long)]
+    pub lang: Option<String>,
+}
+
 /// Arguments shared between CLI `drift` and batch `drift`.
 #[derive(Args, Debug, Clone)]
 pub(crate) struct DriftArgs {
@@ -850,6 +1025,14 @@ pub(crate) struct IndexArgs {
     /// Re-index all files, ignore mtime cache
     #[arg(long)]
     pub force: bool,
+    /// With `--force`, rebuild beside the live index and swap it in at the end.
+    ///
+    /// The fresh database is written to `.cqs/index.db.next` and renamed over
+    /// `index.db` once every chunk is stored, so a crash or Ctrl-C mid-rebuild
+    /// leaves the old index untouched and serving. `cqs watch` reopens on the
+    /// new file. Needs disk for both databases while the rebuild runs.
+    #[arg(long, requires = "force")]
+    pub swap: bool,
     /// Show what would be indexed (default writes the index).
     ///
     /// Per the CONTRIBUTING "Dry-Run vs Apply" rule, side-effect commands
@@ -922,6 +1105,45 @@ pub(crate) struct IndexArgs {
     /// invocation; on large corpora (50k+ chunks) can take ~2 minutes CPU.
     #[arg(long)]
     pub umap: bool,
+    /// Re-index only these files, skipping the project walk and HNSW rebuild.
+    ///
+    /// For editor on-save hooks: parses, embeds and stores just the named
+    /// files (absolute, or relative to the working directory) so search sees
+    /// the change immediately instead of after the watch debounce or a full
+    /// incremental pass. A path that no longer exists drops its chunks. The
+    /// HNSW graphs are marked stale, so vector search falls back to brute
+    /// force until the next plain `cqs index` or watch rebuild.
+    #[arg(long, value_name = "PATH", num_args = 1.., conflicts_with_all = ["force", "dry_run", "umap"])]
+    pub only: Vec<std::path::PathBuf>,
+    /// Index an unsaved editor buffer read from stdin as `--origin`'s content.
+    ///
+    /// The buffer's chunks replace the origin's indexed chunks with a cleared
+    /// file fingerprint, marking them a transient overlay: the next real
+    /// index of that origin (plain `cqs index`, or a running daemon's
+    /// reconcile) re-reads the file from disk and drops them. HNSW is left
+    /// stale as with `--only`.
+    #[arg(long, requires = "origin", conflicts_with_all = ["force", "dry_run", "umap", "only"])]
+    pub stdin: bool,
+    /// Project path the `--stdin` buffer belongs to (need not exist on disk yet)
+    #[arg(long, value_name = "PATH", requires = "stdin")]
+    pub origin: Option<std::path::PathBuf>,
+    /// Index only one shard of a sharded index (`[index.shards]`).
+    ///
+    /// Takes the shard's prefix (`services/billing`), its directory name
+    /// (`services--billing`), or `root` for files under no prefix. Only that
+    /// shard's write lock is taken, so reindexing one service never waits on
+    /// another.
+    #[arg(long, value_name = "SHARD", conflicts_with_all = ["only", "stdin"])]
+    pub shard: Option<String>,
+    /// Parse in sandboxed worker processes, for untrusted trees.
+    ///
+    /// Each file is parsed by a low-privilege `cqs` child with a per-file
+    /// time limit (`CQS_SANDBOX_TIMEOUT_MS`) and memory cap
+    /// (`CQS_SANDBOX_MAX_MEMORY_MB`). A file that breaks either, or crashes
+    /// its worker, is skipped and listed in the summary. Same as
+    /// `[index] sandbox_parsing = true` or `CQS_SANDBOX_PARSE=1`.
+    #[arg(long, conflicts_with_all = ["only", "stdin"])]
+    pub sandbox: bool,
     /// Emit a structured JSON envelope summarizing the index run on
     /// completion. Suppresses progress prints in favor of a single
     /// `{indexed_files, indexed_chunks, took_ms, model, …}` summary so
@@ -133,7 +133,7 @@ pub(crate) enum BatchCmd {
         output: OutputArgs,
     },
     /// Map function to tests
-    #[command(name = "test-map")]
+    #[command(name = "test-map", visible_alias = "tests-for")]
     TestMap {
         #[command(flatten)]
         args: TestMapArgs,
@@ -152,6 +152,7 @@ pub(crate) enum BatchCmd {
         output: OutputArgs,
     },
     /// Find dead code
+    #[command(visible_alias = "deadcode")]
     Dead {
         #[command(flatten)]
         args: DeadArgs,
@@ -358,6 +359,14 @@ pub(crate) enum BatchCmd {
     /// the daemon returns the JSON payload of `cqs::watch_status::WatchSnapshot`.
     /// `cqs batch` (no watch loop) returns the default `unknown` snapshot.
     Status,
+    /// Re-read `.cqs.toml` and the user config and apply reloadable keys.
+    ///
+    /// Zero-arg command served by `cqs watch --serve`; the CLI
+    /// `cqs reload-config` is the user-facing surface. Returns the JSON
+    /// payload of `cqs::config_reload::ConfigReload` — which keys applied
+    /// and which were rejected as needing a reindex or restart. A config
+    /// that fails to parse is an error and leaves the running config as is.
+    ReloadConfig,
     /// Request an out-of-band reconciliation pass.
     ///
     /// Used by the git-hook scripts (`cqs hook fire post-checkout ...`)
@@ -498,6 +507,7 @@ macro_rules! for_each_batch_cmd {
                 (Ping,     dispatch_ping,     "ping",    false)
                 (Status,   dispatch_status,   "status",  false)
                 (Help,     dispatch_help,     "help",    false)
+                (ReloadConfig, dispatch_reload_config, "reload-config", false)
             }
         }
     };
@@ -599,6 +609,9 @@ macro_rules! gen_dispatch_impl {
                 cmd.command_name(),
                 &ctx.cqs_dir,
             );
+            // A reused worker thread must not report the previous command's
+            // `_meta.index_generation`.
+            cqs::store::clear_search_generation();
             // `output` field on each variant is intentionally dropped —
             // batch always emits JSON. Pattern-match `..` so the
             // destructure stays exhaustive even if future fields are
@@ -1160,6 +1173,7 @@ mod tests {
                 include_pub: false,
                 min_confidence: DeadConfidence::Low,
                 verdict: None,
+                allowlist: None,
                 overlay: Default::default(),
             },
             output: TextJsonArgs { json: false },
@@ -48,6 +48,16 @@ pub(super) struct CachedCrossProject {
     pub(super) published_epoch: u64,
 }
 
+/// Cached call graph of a sharded index (root plus every shard, see
+/// [`cqs::shard::graph_context`]), keyed by the indexed shard names it was
+/// opened over. Self-healing like `BatchContext::shards`:
+/// `get_shard_graph_via_cache` rebuilds it when the shard set changes or any
+/// of its stores is rewritten.
+pub(super) struct CachedShardGraph {
+    pub(super) ctx: Arc<Mutex<cqs::cross_project::CrossProjectContext>>,
+    pub(super) shards: Vec<String>,
+}
+
 // ─── BatchContext ────────────────────────────────────────────────────────────
 
 /// One bit per mutable-cache slot, used by the deferred-invalidation mask
@@ -201,6 +211,15 @@ pub(crate) struct BatchContext {
     /// each vs a ref's 50-200 MB); the `OVERLAYS` slot bit lets explicit
     /// `refresh` / invalidation clear it as the operator escape hatch.
     pub(super) overlays: Arc<Mutex<lru::LruCache<PathBuf, Arc<super::OverlayCacheEntry>>>>,
+    /// Open shard stores of a sharded index (`[index.shards]`), shared with
+    /// every `BatchView` like `refs`. Self-healing rather than slot-masked:
+    /// `get_shards_via_cache` reloads when the on-disk shard set changes or
+    /// a shard's `index.db` is rewritten.
+    pub(super) shards: Arc<Mutex<Vec<Arc<ReferenceIndex>>>>,
+    /// Call-graph context over the root and every shard, for `callers` /
+    /// `callees` / `impact` / `trace` / `test-map` / `dead` on a sharded
+    /// index. Shared with every `BatchView` and self-healing like `shards`.
+    pub(super) shard_graph: Arc<Mutex<Option<CachedShardGraph>>>,
     /// `Arc<OnceLock<...>>` mirrors the embedder pattern — see field doc above.
     pub(super) splade_encoder: Arc<OnceLock<Option<cqs::splade::SpladeEncoder>>>,
     /// `Arc<Mutex<Option<Arc<SpladeIndex>>>>` so BatchView can carry an Arc
@@ -368,6 +387,8 @@ impl BatchContext {
             cross_project: Arc::new(Mutex::new(None)),
             refs: Arc::new(Mutex::new(lru::LruCache::new(refs_lru_size()))),
             overlays: Arc::new(Mutex::new(lru::LruCache::new(super::overlays_lru_size()))),
+            shards: Arc::new(Mutex::new(Vec::new())),
+            shard_graph: Arc::new(Mutex::new(None)),
             root,
             cqs_dir,
             model_config,
@@ -1406,7 +1427,15 @@ impl BatchContext {
     /// keeping ad-hoc tweaks usable. Returning owned `Config` lets call sites
     /// use `self.config().ef_search` and `self.config().references` via
     /// auto-deref.
+    ///
+    /// Inside `cqs watch --serve` the watch loop owns the config: it reloads
+    /// on `.cqs.toml` edits, SIGHUP, and `reload-config`, keeping keys that
+    /// need a reindex or restart at their startup values. That live copy
+    /// wins over the timed re-read here.
     pub(super) fn config(&self) -> cqs::config::Config {
+        if let Some(live) = cqs::config_reload::current() {
+            return live;
+        }
         let needs_reload = match self.config.borrow().as_ref() {
             Some(c) => c.loaded_at.elapsed() >= config_reload_interval(),
             None => true,
@@ -1527,6 +1556,8 @@ impl BatchContext {
             splade_encoder_slot: Arc::clone(&self.splade_encoder),
             refs: Arc::clone(&self.refs),
             overlays: Arc::clone(&self.overlays),
+            shards: Arc::clone(&self.shards),
+            shard_graph: Arc::clone(&self.shard_graph),
             overlay_request: RefCell::new(None),
             #[cfg(unix)]
             overlay_pin: RefCell::new(None),
@@ -47,7 +47,17 @@ pub(in crate::cli::batch) fn dispatch_dead(
         include_pub: args.include_pub,
         min_confidence: args.min_confidence,
         verdict,
+        allowlist: args.allowlist.clone(),
     };
+    // A sharded index resolves callers across every shard; the overlay merges
+    // against a single store, so it doesn't apply there.
+    let sharded = super::graph::with_multi_store_graph(ctx, false, |shard_ctx| {
+        crate::cli::commands::dead_cross_core(shard_ctx, &ctx.root, &core_args)
+    })?;
+    if let Some(output) = sharded {
+        return Ok(serde_json::to_value(&output)?);
+    }
+
     // Resolve the worktree overlay (Part B): recomputes the dead set over the
     // merged caller graph. `None` ⇒ parent-truth (the default).
     let overlay = super::graph::resolve_graph_overlay(ctx, &args.overlay)?;
@@ -351,6 +361,7 @@ mod parity_tests {
                 include_pub: false,
                 min_confidence: DeadConfidence::Low,
                 verdict: None,
+                allowlist: None,
             },
         )
         .expect("dead_core");
@@ -371,6 +382,7 @@ mod parity_tests {
                 include_pub: false,
                 min_confidence: DeadConfidence::Low,
                 verdict: None,
+                allowlist: None,
                 overlay: Default::default(),
             },
         )
@@ -433,6 +445,7 @@ mod parity_tests {
                 include_pub: false,
                 min_confidence: DeadConfidence::Low,
                 verdict: None,
+                allowlist: None,
             },
         )
         .expect("dead_core");
@@ -463,6 +476,7 @@ mod parity_tests {
                 include_pub: false,
                 min_confidence: DeadConfidence::Low,
                 verdict: None,
+                allowlist: None,
                 overlay: Default::default(),
             },
         )
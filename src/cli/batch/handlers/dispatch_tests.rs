@@ -471,11 +471,13 @@ fn parity_similar_daemon_matches_core() {
     let view = ctx.build_view(None);
 
     // The seed corpus stores a unit embedding on every chunk, so cosine
-    // similarity is 1.0 across the board; threshold 0.0 keeps them all.
+    // similarity is 1.0 across the board; threshold 0.0 keeps them all. Every
+    // seed chunk lives in `src/lib.rs`, so same-file neighbors stay in.
     let wire = crate::cli::args::SimilarArgs {
         name: "foo".into(),
         limit_arg: crate::cli::args::LimitArg { limit: 3 },
         threshold: 0.0,
+        same_file: true,
         lang: None,
         path: None,
     };
@@ -488,6 +490,7 @@ fn parity_similar_daemon_matches_core() {
         name: "foo".into(),
         limit: 3,
         threshold: 0.0,
+        same_file: true,
     };
     let matches =
         similar_core(&store, index.as_deref(), &filter, &core_args).expect("similar_core");
@@ -621,6 +624,7 @@ fn parity_similar_scoped_daemon_matches_core() {
             name: "target".into(),
             limit_arg: crate::cli::args::LimitArg { limit: 10 },
             threshold: 0.0,
+            same_file: false,
             lang: None,
             path: None,
         },
@@ -644,6 +648,7 @@ fn parity_similar_scoped_daemon_matches_core() {
         name: "target".into(),
         limit_arg: crate::cli::args::LimitArg { limit: 10 },
         threshold: 0.0,
+        same_file: false,
         lang: Some("rust".into()),
         path: None,
     };
@@ -658,6 +663,7 @@ fn parity_similar_scoped_daemon_matches_core() {
             name: "target".into(),
             limit: 10,
             threshold: 0.0,
+            same_file: false,
         },
     )
     .expect("similar_core lang");
@@ -687,6 +693,7 @@ fn parity_similar_scoped_daemon_matches_core() {
         name: "target".into(),
         limit_arg: crate::cli::args::LimitArg { limit: 10 },
         threshold: 0.0,
+        same_file: false,
         lang: None,
         path: Some("src/*".into()),
     };
@@ -701,6 +708,7 @@ fn parity_similar_scoped_daemon_matches_core() {
             name: "target".into(),
             limit: 10,
             threshold: 0.0,
+            same_file: false,
         },
     )
     .expect("similar_core path");
@@ -42,6 +42,29 @@ fn parse_dispatch_edge_kind(s: Option<&str>) -> Result<Option<CallEdgeKind>> {
     }
 }
 
+/// Run `f` over the multi-store call graph when the query needs one: the
+/// cached cross-project context for `--cross-project`, otherwise the cached
+/// shard graph of a sharded index (mirrors
+/// `commands::graph::multi_store_graph`). `Ok(None)` ⇒ serve the single store.
+pub(super) fn with_multi_store_graph<T>(
+    ctx: &BatchView,
+    cross_project: bool,
+    f: impl FnOnce(&mut cqs::cross_project::CrossProjectContext) -> Result<T>,
+) -> Result<Option<T>> {
+    if cross_project {
+        let cross_ctx = ctx.cross_project()?;
+        let mut cross_ctx = cross_ctx.lock().unwrap_or_else(|p| p.into_inner());
+        return f(&mut cross_ctx).map(Some);
+    }
+    match ctx.shard_graph()? {
+        Some(shard_ctx) => {
+            let mut shard_ctx = shard_ctx.lock().unwrap_or_else(|p| p.into_inner());
+            f(&mut shard_ctx).map(Some)
+        }
+        None => Ok(None),
+    }
+}
+
 /// Prepare + resolve the worktree overlay for a call-graph dispatcher (#1858
 /// Part B). Stamps the validated request from the wire tri-state flags (a
 /// foreign `--overlay-root` is rejected as a wire error), then resolves it
@@ -142,17 +165,17 @@ pub(in crate::cli::batch) fn dispatch_callers(
     // which is where the seed path clears). Idempotent.
     cqs::worktree_overlay::clear_overlay_meta();
     let edge_kind = parse_dispatch_edge_kind(args.edge_kind.as_deref())?;
-    if cross_project {
-        let cross_ctx = ctx.cross_project()?;
-        let mut cross_ctx = cross_ctx.lock().unwrap_or_else(|p| p.into_inner());
-        let output = callers_cross_core(
-            &mut cross_ctx,
+    let cross = with_multi_store_graph(ctx, cross_project, |cross_ctx| {
+        callers_cross_core(
+            cross_ctx,
             &CallersCoreArgs {
                 name: name.to_string(),
                 limit: args.limit_arg.limit,
                 edge_kind,
             },
-        )?;
+        )
+    })?;
+    if let Some(output) = cross {
         return Ok(serde_json::to_value(&output)?);
     }
 
@@ -207,17 +230,17 @@ pub(in crate::cli::batch) fn dispatch_callees(
     // See `dispatch_callers`: clear leftover per-thread overlay meta first.
     cqs::worktree_overlay::clear_overlay_meta();
     let edge_kind = parse_dispatch_edge_kind(args.edge_kind.as_deref())?;
-    if cross_project {
-        let cross_ctx = ctx.cross_project()?;
-        let mut cross_ctx = cross_ctx.lock().unwrap_or_else(|p| p.into_inner());
-        let output = callees_cross_core(
-            &mut cross_ctx,
+    let cross = with_multi_store_graph(ctx, cross_project, |cross_ctx| {
+        callees_cross_core(
+            cross_ctx,
             &CoreCalleesArgs {
                 name: name.to_string(),
                 limit: args.limit_arg.limit,
                 edge_kind,
             },
-        )?;
+        )
+    })?;
+    if let Some(output) = cross {
         return Ok(serde_json::to_value(&output)?);
     }
 
@@ -279,11 +302,9 @@ pub(in crate::cli::batch) fn dispatch_impact(
     // branch can return, so a reused daemon worker never leaks a prior query's
     // `_meta.worktree_overlay`.
     cqs::worktree_overlay::clear_overlay_meta();
-    if cross_project {
-        let cross_ctx = ctx.cross_project()?;
-        let mut cross_ctx = cross_ctx.lock().unwrap_or_else(|p| p.into_inner());
-        let result = crate::cli::commands::impact_cross_core(
-            &mut cross_ctx,
+    let cross = with_multi_store_graph(ctx, cross_project, |cross_ctx| {
+        crate::cli::commands::impact_cross_core(
+            cross_ctx,
             &ImpactCoreArgs {
                 name: name.to_string(),
                 depth: args.depth,
@@ -291,7 +312,9 @@ pub(in crate::cli::batch) fn dispatch_impact(
                 suggest_tests: do_suggest_tests,
                 include_types,
             },
-        )?;
+        )
+    })?;
+    if let Some(result) = cross {
         // Cross-project JSON never carried `kind` / `test_suggestions`
         // (the historical path called `impact_to_json` directly). Preserve
         // that wire shape.
@@ -353,11 +376,9 @@ pub(in crate::cli::batch) fn dispatch_test_map(
         cross_project
     )
     .entered();
-    if cross_project {
-        let cross_ctx = ctx.cross_project()?;
-        let mut cross_ctx = cross_ctx.lock().unwrap_or_else(|p| p.into_inner());
-        let matches = crate::cli::commands::test_map_cross_core(
-            &mut cross_ctx,
+    let cross = with_multi_store_graph(ctx, cross_project, |cross_ctx| {
+        crate::cli::commands::test_map_cross_core(
+            cross_ctx,
             &ctx.root,
             &TestMapCoreArgs {
                 name: name.to_string(),
@@ -365,7 +386,9 @@ pub(in crate::cli::batch) fn dispatch_test_map(
                 limit: args.limit_arg.limit,
                 max_nodes: crate::cli::commands::test_map_max_nodes(),
             },
-        )?;
+        )
+    })?;
+    if let Some(matches) = cross {
         let output = crate::cli::commands::build_test_map_output(name, &matches);
         return Ok(serde_json::to_value(&output)?);
     }
@@ -415,11 +438,10 @@ pub(in crate::cli::batch) fn dispatch_trace(
     // `cmd_trace` for rationale (single shortest path today; reserved for
     // future k-shortest-paths variants). args.limit_arg.limit intentionally unused.
 
-    if cross_project {
-        let cross_ctx = ctx.cross_project()?;
-        let mut cross_ctx = cross_ctx.lock().unwrap_or_else(|p| p.into_inner());
-        let trace_result =
-            crate::cli::commands::trace_cross_core(&mut cross_ctx, source, target, max_depth)?;
+    let cross = with_multi_store_graph(ctx, cross_project, |cross_ctx| {
+        crate::cli::commands::trace_cross_core(cross_ctx, source, target, max_depth)
+    })?;
+    if let Some(trace_result) = cross {
         return Ok(serde_json::to_value(&trace_result)?);
     }
 
@@ -2873,6 +2895,7 @@ mod tests {
             include_pub: true,
             min_confidence: cqs::store::DeadConfidence::Low,
             verdict: None,
+            allowlist: None,
             overlay: Default::default(),
         }
     }
@@ -2883,6 +2906,7 @@ mod tests {
             include_pub: true,
             min_confidence: cqs::store::DeadConfidence::Low,
             verdict: None,
+            allowlist: None,
         }
     }
 
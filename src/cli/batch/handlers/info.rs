@@ -121,6 +121,7 @@ pub(in crate::cli::batch) fn dispatch_similar(
         name: name.to_string(),
         limit: args.limit_arg.limit,
         threshold: args.threshold,
+        same_file: args.same_file,
     };
     let matches = crate::cli::commands::search::similar::similar_core(
         &store,
@@ -177,7 +178,7 @@ pub(in crate::cli::batch) fn dispatch_context(
 /// # Arguments
 /// `ctx` - The batch processing context containing the store and error counter.
 /// # Returns
-/// A JSON value containing aggregated statistics with the following top-level fields: `total_chunks`, `total_files`, `notes`, `errors`, `call_graph` (with `total_calls`, `unique_callers`, `unique_callees`), `type_graph` (with `total_edges`, `unique_types`), `by_language`, `by_type`, `model`, and `schema_version`.
+/// A JSON value containing aggregated statistics with the following top-level fields: `total_chunks`, `total_files`, `notes`, `errors`, `call_graph` (with `total_calls`, `unique_callers`, `unique_callees`), `type_graph` (with `total_edges`, `unique_types`), `by_language`, `by_type`, `model`, and `index_schema_version`.
 /// # Errors
 /// Returns an error if any of the store queries fail (stats, note_count, function_call_stats, or type_edge_stats).
 pub(in crate::cli::batch) fn dispatch_stats(ctx: &BatchView) -> Result<serde_json::Value> {
@@ -270,6 +271,7 @@ pub(in crate::cli::batch) fn dispatch_read(
     );
     crate::cli::commands::read::read_core(
         &ctx.store(),
+        &ctx.cqs_dir,
         &ctx.root,
         args,
         &audit_state,
@@ -580,6 +582,7 @@ mod tests {
         );
         let core = crate::cli::commands::read::read_core(
             &view.store(),
+            &view.cqs_dir,
             &view.root,
             &args,
             &audit_state,
@@ -800,6 +800,19 @@ pub(in crate::cli::batch) fn dispatch_reconcile(
     }))
 }
 
+/// Re-read the project and user config and apply the reloadable keys.
+///
+/// Thin wrapper over [`cqs::config_reload::reload`]; the JSON payload is the
+/// serialized [`cqs::config_reload::ConfigReload`]. The daemon's cached
+/// `Config` picks up the change on the next checkout because the reload bumps
+/// the generation `BatchContext::config` compares against. A malformed
+/// config is an error and nothing is applied.
+pub(in crate::cli::batch) fn dispatch_reload_config(ctx: &BatchView) -> Result<serde_json::Value> {
+    let _span = tracing::info_span!("batch_reload_config").entered();
+    let outcome = cqs::config_reload::reload(&ctx.root)?;
+    Ok(serde_json::to_value(&outcome)?)
+}
+
 // Embedder-free misc handler tests. `dispatch_ping`, `dispatch_help`, and
 // `dispatch_refresh` are the cheap healthcheck/metadata surface. Pin the
 // contract here so a future regression in
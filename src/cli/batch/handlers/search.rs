@@ -3,10 +3,10 @@
 use anyhow::{bail, Result};
 
 use super::super::BatchView;
-use crate::cli::args::{SearchArgs, SearchLegsArgs};
+use crate::cli::args::{RerankerMode, SearchArgs, SearchLegsArgs};
 use crate::cli::commands::search::query::{
-    merge_references, prepare_query, query_core, retrieve_project, retrieve_ref_scoped,
-    search_legs_core, Prepared, ProjectSurface, QueryArgs,
+    cut_below_min_score, merge_references, prepare_query, query_core, retrieve_project,
+    retrieve_ref_scoped, search_legs_core, Prepared, ProjectSurface, QueryArgs,
 };
 // Shared search `--limit` cap. The CLI dispatcher clamps `cli.limit` to the
 // same constant (`cli::dispatch`), so daemon-up and daemon-down invocations
@@ -121,21 +121,35 @@ fn daemon_query_args(args: &SearchArgs) -> QueryArgs {
         include_type: args.include_type.clone(),
         exclude_type: args.exclude_type.clone(),
         path: args.path.clone(),
+        changed_since: args.changed_since.clone(),
+        grep: args.grep.clone(),
+        signature: args.signature.clone(),
+        owner: args.owner.clone(),
+        issue: args.issue.clone(),
+        search_in: args.search_in,
         // Pattern filter is not part of the daemon wire path (it discarded
         // `args.pattern` before the refactor); leave it None so the core skips
         // the filter, preserving the daemon's retrieval shape.
         pattern: None,
         include_docs: args.include_docs,
+        with_commits: args.with_commits,
+        hide_deprecated: args.hide_deprecated,
+        include_generated: args.include_generated,
+        relax: args.relax,
         rrf: args.rrf,
-        rerank: args.rerank_active(),
+        rerank: args.rerank_mode() == RerankerMode::Onnx,
+        late_interaction: args.rerank_mode() == RerankerMode::Late,
         splade: args.splade,
         splade_alpha: args.splade_alpha,
         threshold: args.threshold,
+        min_score: args.min_score,
         name_boost: args.name_boost,
         no_demote: args.no_demote,
         tokens: args.tokens,
         // Parent expansion is not emitted on the daemon wire.
         expand_parent: false,
+        with_tests: args.with_tests,
+        with_owners: args.with_owners,
         force_base_index: std::env::var("CQS_FORCE_BASE_INDEX").as_deref() == Ok("1"),
         json_overhead: crate::cli::commands::JSON_OVERHEAD_PER_RESULT,
         // Daemon semantics — see fn doc.
@@ -144,6 +158,12 @@ fn daemon_query_args(args: &SearchArgs) -> QueryArgs {
         // The daemon surface is always JSON, so provenance is on unless the
         // caller suppresses it for a tight token budget.
         record_rank_signals: !args.no_rank_signals,
+        // Daemon's own `CQS_QUERY_HYDE` is the default, like the overlay env.
+        hyde: crate::cli::commands::search::hyde::resolve_hyde(args.hyde, args.no_hyde),
+        translate: crate::cli::commands::search::translate::resolve_translate(
+            args.translate,
+            args.no_translate,
+        ),
         // Overlay activation (daemon-side): opt-out wins, else opt-in (wire flag
         // OR the daemon's own env). Default-on is a client-side decision, so the
         // daemon passes `overlay_eligible = false` — it only ever sees an
@@ -253,14 +273,25 @@ pub(in crate::cli::batch) fn dispatch_search(
     // shared serializer (which always includes content) honours the daemon's
     // `--no-content`. The CLI handles this in its own display path; the wire
     // path mirrors it here at the adapter boundary.
+    let extras = crate::cli::display::ResultExtras {
+        tests: args.with_tests.then_some(&output.tests),
+        owners: args.with_owners.then_some(&output.owners),
+        issue_refs: Some(&output.issue_refs),
+        commits: Some(&output.commits),
+        calibration: output.calibration.as_ref(),
+    };
     let mut value = crate::cli::display::build_unified_results_value(
         &output.results,
         &output.query,
         parents_ref,
+        extras,
         output.token_info,
     );
+    crate::cli::display::apply_relaxed(&mut value, &output.relaxed);
     if args.no_content {
         strip_content(&mut value);
+    } else if let Some(n) = args.snippet_lines {
+        crate::cli::display::apply_snippet_lines(&mut value, n);
     }
 
     let origins = result_origins(&output.results);
@@ -286,18 +317,32 @@ fn legs_query_args(args: &SearchLegsArgs) -> QueryArgs {
         include_type: args.include_type.clone(),
         exclude_type: args.exclude_type.clone(),
         path: args.path.clone(),
+        changed_since: None,
+        grep: None,
+        signature: None,
+        owner: None,
+        issue: None,
+        search_in: None,
         pattern: None,
         include_docs: false,
+        with_commits: false,
+        hide_deprecated: false,
+        include_generated: false,
+        relax: false,
         rrf: false,
         rerank: false,
+        late_interaction: false,
         // Force SPLADE on — the inspector exists to show the fusion legs.
         splade: true,
         splade_alpha: args.splade_alpha,
         threshold: args.threshold,
+        min_score: None,
         name_boost: 0.2,
         no_demote: false,
         tokens: None,
         expand_parent: false,
+        with_tests: false,
+        with_owners: false,
         force_base_index: std::env::var("CQS_FORCE_BASE_INDEX").as_deref() == Ok("1"),
         json_overhead: 0,
         always_route: true,
@@ -306,6 +351,8 @@ fn legs_query_args(args: &SearchLegsArgs) -> QueryArgs {
         // for the mechanism view and would only add cost to the final scoring.
         record_rank_signals: false,
         overlay: false,
+        hyde: false,
+        translate: cqs::config::QueryTranslate::Off,
     }
 }
 
@@ -474,6 +521,8 @@ fn dispatch_search_with_refs(ctx: &BatchView, args: &SearchArgs) -> Result<serde
         );
         if args.no_content {
             strip_content(&mut value);
+        } else if let Some(n) = args.snippet_lines {
+            crate::cli::display::apply_snippet_lines(&mut value, n);
         }
         return Ok(value);
     }
@@ -501,13 +550,15 @@ fn dispatch_search_with_refs(ctx: &BatchView, args: &SearchArgs) -> Result<serde
     );
     if args.no_content {
         strip_content(&mut value);
+    } else if let Some(n) = args.snippet_lines {
+        crate::cli::display::apply_snippet_lines(&mut value, n);
     }
     attach_stale_origins_meta(ctx, args, &project_origins, &mut value);
     Ok(value)
 }
 
-/// Token-budget packing for the tagged (`--ref` / `--include-refs`) path.
-/// Returns the packed results and the `(used, budget)` token info, or the input
+/// `--min-score` cut and token-budget packing for the tagged (`--ref` /
+/// `--include-refs`) path. Returns the packed results and the `(used, budget)` token info, or the input
 /// unchanged when `--tokens` isn't set.
 type TaggedPack = (Vec<cqs::reference::TaggedResult>, Option<(usize, usize)>);
 
@@ -516,6 +567,9 @@ fn pack_tagged(
     args: &SearchArgs,
     tagged: Vec<cqs::reference::TaggedResult>,
 ) -> Result<TaggedPack> {
+    let tagged = cut_below_min_score(tagged, args.min_score, |t| match &t.result {
+        cqs::store::UnifiedResult::Code(sr) => sr.score,
+    });
     if let Some(budget) = args.tokens {
         let embedder = ctx.embedder()?;
         Ok(crate::cli::commands::token_pack_results(
@@ -1233,6 +1287,13 @@ mod tests {
                 &output.results,
                 &output.query,
                 parents_ref,
+                crate::cli::display::ResultExtras {
+                    tests: args.with_tests.then_some(&output.tests),
+                    owners: args.with_owners.then_some(&output.owners),
+                    issue_refs: Some(&output.issue_refs),
+                    commits: Some(&output.commits),
+                    calibration: output.calibration.as_ref(),
+                },
                 output.token_info,
             );
             if args.no_content {
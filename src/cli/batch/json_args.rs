@@ -497,7 +497,7 @@ pub(crate) fn build_batch_cmd(command: &str, arguments: &serde_json::Value) -> R
         // `arguments` object is well-typed (and rejects a non-object / wrong-typed
         // value as a clean error), then is dropped. The variant carries only
         // `output`, mirroring the argv path (`stats` / `health` accept no flags
-        // on either surface). Zero input fields → zero serde-output risk: neither
+        // on the daemon; `stats --hot` always runs CLI-side). Zero input fields → zero serde-output risk: neither
         // core derives `Serialize`, and the command outputs the separate
         // `StatsOutput` / `HealthReport`.
         "stats" => {
@@ -586,15 +586,25 @@ fn search_args_from_core(c: QueryArgs, overlay: OverlayArgs) -> SearchArgs {
         query: c.query,
         limit_arg: LimitArg { limit: c.limit },
         threshold: c.threshold,
+        min_score: c.min_score,
         name_boost: c.name_boost,
         lang: c.lang,
         include_type: c.include_type,
         exclude_type: c.exclude_type,
         path: c.path,
         pattern: c.pattern,
+        grep: c.grep,
+        signature: c.signature,
+        owner: c.owner,
+        issue: c.issue,
+        search_in: c.search_in,
         name_only: c.name_only,
         rrf: c.rrf,
         include_docs: c.include_docs,
+        with_commits: c.with_commits,
+        hide_deprecated: c.hide_deprecated,
+        include_generated: c.include_generated,
+        relax: c.relax,
         reranker: if c.rerank {
             Some(RerankerMode::Onnx)
         } else {
@@ -604,15 +614,23 @@ fn search_args_from_core(c: QueryArgs, overlay: OverlayArgs) -> SearchArgs {
         splade_alpha: c.splade_alpha,
         no_content: false,
         context: None,
+        snippet_lines: None,
         expand_parent: c.expand_parent,
+        with_tests: c.with_tests,
+        with_owners: c.with_owners,
         ref_name: None,
         include_refs: false,
+        changed_since: c.changed_since,
         tokens: c.tokens,
         no_stale_check: false,
         no_demote: c.no_demote,
         // The core's `record_rank_signals` is the inverse of the CLI flag; the
         // daemon adapter recomputes it as `!no_rank_signals`, so map it back.
         no_rank_signals: !c.record_rank_signals,
+        hyde: c.hyde,
+        no_hyde: false,
+        translate: c.translate == cqs::config::QueryTranslate::Always,
+        no_translate: false,
         overlay,
     }
 }
@@ -669,6 +687,7 @@ fn similar_args_from_core(c: SimilarCore) -> SimilarArgs {
         name: c.name,
         limit_arg: LimitArg { limit: c.limit },
         threshold: c.threshold,
+        same_file: c.same_file,
         lang: None,
         path: None,
     }
@@ -2166,12 +2185,13 @@ mod tests {
             1usize..=200
         }
 
-        // The four argv-expressible edge-kind provenance strings, plus None.
+        // The five argv-expressible edge-kind provenance strings, plus None.
         fn edge_kind_strategy() -> impl Strategy<Value = Option<&'static str>> {
             prop_oneof![
                 Just(None),
                 Just(Some("call")),
                 Just(Some("serde_callback")),
+                Just(Some("ffi_boundary")),
                 Just(Some("macro_heuristic")),
                 Just(Some("fn_pointer")),
             ]
@@ -422,6 +422,11 @@ fn write_json_line(
             return write_json_line_lifting_meta(out, value);
         }
     }
+    // `--schema` older than current: borrowed unless this payload's shape
+    // actually differs, so the common line still streams without a clone.
+    let version = crate::cli::json_schema::selected();
+    let value = crate::cli::json_schema::body_for(value, version);
+    let value = value.as_ref();
 
     // Steady-state: build the line in a `Vec<u8>` so the entire envelope
     // is one `writeln!` (avoids interleaved partial writes if `out` is a
@@ -432,12 +437,14 @@ fn write_json_line(
     // `to_writer` — no intermediate `Value` allocation.
     //
     // Slim shape: drop `error: null` and `version` (always-redundant on the
-    // success path) and skip `_meta` when empty — the hot-path
-    // `meta_json_fragment` returns "" in that case so the splice is a no-op.
+    // success path), add `schema_version`, and skip `_meta` when empty — the
+    // hot-path `meta_json_fragment` returns "" in that case so the splice is
+    // a no-op.
     let mut buf: Vec<u8> = Vec::with_capacity(256);
     buf.extend_from_slice(b"{\"data\":");
     match serde_json::to_writer(&mut buf, value) {
         Ok(()) => {
+            buf.extend_from_slice(crate::cli::json_schema::envelope_fragment(version).as_bytes());
             // The fragment is "" when meta is empty; ",\"_meta\":{...}"
             // otherwise (e.g. stale worktree). Splice verbatim.
             buf.extend_from_slice(crate::cli::json_envelope::meta_json_fragment().as_bytes());
@@ -497,7 +504,8 @@ fn write_json_line_lifting_meta(
     out: &mut impl std::io::Write,
     value: &serde_json::Value,
 ) -> std::io::Result<()> {
-    let mut payload = value.clone();
+    let version = crate::cli::json_schema::selected();
+    let mut payload = crate::cli::json_schema::body_for(value, version).into_owned();
     let per_meta = match payload.as_object_mut().and_then(|o| o.remove("_meta")) {
         Some(serde_json::Value::Object(m)) => m,
         // Non-object `_meta` (handler bug) — drop it rather than emit a
@@ -512,8 +520,9 @@ fn write_json_line_lifting_meta(
         None => serde_json::Map::new(),
     };
 
-    let mut env = serde_json::Map::with_capacity(2);
+    let mut env = serde_json::Map::with_capacity(3);
     env.insert("data".to_string(), payload);
+    crate::cli::json_schema::mark_envelope(&mut env, version);
     if let Some(meta) = crate::cli::json_envelope::merged_meta_value(per_meta) {
         env.insert("_meta".to_string(), meta);
     }
@@ -766,6 +775,38 @@ mod tests {
         );
     }
 
+    /// The shard call graph is opened once and served to later views until
+    /// the indexed shard set changes; an unsharded index has none.
+    #[test]
+    fn test_shard_graph_cell_reused_until_shard_set_changes() {
+        let (_dir, cqs_dir) = setup_test_store();
+        let ctx = create_test_context(&cqs_dir).unwrap();
+        assert!(ctx.build_view(None).shard_graph().unwrap().is_none());
+
+        let add_shard = |prefix: &str| {
+            let dir = cqs::shard::shard_dir(&cqs_dir, prefix);
+            std::fs::create_dir_all(&dir).unwrap();
+            let store = Store::open(&dir.join(cqs::INDEX_DB_FILENAME)).unwrap();
+            store.init(&ModelInfo::default()).unwrap();
+            drop(store);
+            cqs::shard::write_manifest(&dir, prefix).unwrap();
+        };
+        add_shard("billing");
+
+        let first = ctx.build_view(None).shard_graph().unwrap().unwrap();
+        let second = ctx.build_view(None).shard_graph().unwrap().unwrap();
+        assert!(
+            Arc::ptr_eq(&first, &second),
+            "a later request must reuse the opened shard stores"
+        );
+        assert_eq!(first.lock().unwrap().project_count(), 2);
+
+        add_shard("orders");
+        let third = ctx.build_view(None).shard_graph().unwrap().unwrap();
+        assert!(!Arc::ptr_eq(&first, &third), "a new shard must rebuild");
+        assert_eq!(third.lock().unwrap().project_count(), 3);
+    }
+
     #[test]
     fn test_mtime_staleness_detection() {
         let (_dir, cqs_dir) = setup_test_store();
@@ -1305,6 +1346,8 @@ mod tests {
         assert_eq!(parsed["data"], val);
         assert!(parsed.get("error").is_none(), "got: {parsed}");
         assert!(parsed.get("version").is_none(), "got: {parsed}");
+        // The default v1 schema still names itself.
+        assert_eq!(parsed["schema_version"], 1, "got: {parsed}");
     }
 
     // Payload-level `_meta` is lifted onto the envelope, sibling of `data` —
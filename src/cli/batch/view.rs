@@ -373,6 +373,82 @@ pub(crate) fn get_all_refs_via_refs_lru(
     Ok(hits)
 }
 
+/// Shared helper behind `BatchView`'s `SearchCtx::shards`. Hands back the
+/// cached shard stores unless the set of indexed shards under `cqs_dir`
+/// changed or any cached shard went stale (`cqs index --shard` rewrote its
+/// `index.db`), in which case every shard is reopened outside the lock.
+pub(crate) fn get_shards_via_cache(
+    shards: &Mutex<Vec<Arc<ReferenceIndex>>>,
+    cqs_dir: &Path,
+    dim: usize,
+) -> Vec<Arc<ReferenceIndex>> {
+    let indexed = indexed_shard_names(cqs_dir);
+    {
+        let cached = shards.lock().unwrap_or_else(|p| p.into_inner());
+        let same_set = cached.len() == indexed.len()
+            && cached.iter().zip(&indexed).all(|(s, name)| s.name == *name);
+        if same_set && !cached.iter().any(|s| s.is_stale()) {
+            return cached.clone();
+        }
+    }
+    let _span = tracing::info_span!("batch_reload_shards", count = indexed.len()).entered();
+    let loaded: Vec<Arc<ReferenceIndex>> = cqs::shard::load_shards(cqs_dir, dim)
+        .into_iter()
+        .map(Arc::new)
+        .collect();
+    *shards.lock().unwrap_or_else(|p| p.into_inner()) = loaded.clone();
+    loaded
+}
+
+/// Names of the shards under `cqs_dir` that have an `index.db`, in
+/// [`cqs::shard::list_shards`] order — the key both shard caches compare.
+fn indexed_shard_names(cqs_dir: &Path) -> Vec<String> {
+    cqs::shard::list_shards(cqs_dir)
+        .into_iter()
+        .filter(|info| info.dir.join(cqs::INDEX_DB_FILENAME).exists())
+        .map(|info| info.name)
+        .collect()
+}
+
+/// Shared helper behind [`BatchView::shard_graph`]. Hands back the cached
+/// shard call-graph context unless the set of indexed shards under `cqs_dir`
+/// changed or any of its stores went stale (a root or `--shard` reindex), in
+/// which case it is rebuilt outside the lock — so the daemon opens each shard
+/// once rather than per request. `Ok(None)` when the index is not sharded.
+pub(crate) fn get_shard_graph_via_cache(
+    cell: &Mutex<Option<super::context::CachedShardGraph>>,
+    cqs_dir: &Path,
+) -> Result<Option<Arc<Mutex<cqs::cross_project::CrossProjectContext>>>> {
+    let indexed = indexed_shard_names(cqs_dir);
+    {
+        let mut guard = cell.lock().unwrap_or_else(|p| p.into_inner());
+        if indexed.is_empty() {
+            *guard = None;
+            return Ok(None);
+        }
+        if let Some(cached) = guard.as_ref() {
+            let fresh = !cached
+                .ctx
+                .lock()
+                .unwrap_or_else(|p| p.into_inner())
+                .is_stale();
+            if cached.shards == indexed && fresh {
+                return Ok(Some(Arc::clone(&cached.ctx)));
+            }
+        }
+    }
+    let _span = tracing::info_span!("batch_reload_shard_graph", count = indexed.len()).entered();
+    let Some(built) = cqs::shard::graph_context(cqs_dir)? else {
+        return Ok(None);
+    };
+    let ctx = Arc::new(Mutex::new(built));
+    *cell.lock().unwrap_or_else(|p| p.into_inner()) = Some(super::context::CachedShardGraph {
+        ctx: Arc::clone(&ctx),
+        shards: indexed,
+    });
+    Ok(Some(ctx))
+}
+
 /// Resolve a worktree overlay through the daemon's overlay LRU (result-trust
 /// §3), building it on a miss and revalidating its fingerprint on a hit.
 ///
@@ -598,6 +674,10 @@ pub(crate) struct BatchView {
     /// alias-the-context shape as `refs`. `SearchCtx::overlay()` resolves through
     /// this via `get_overlay_via_lru` when `overlay_request` is set.
     pub(super) overlays: Arc<Mutex<lru::LruCache<PathBuf, Arc<super::OverlayCacheEntry>>>>,
+    /// Shared shard-store cache (see `BatchContext::shards`).
+    pub(super) shards: Arc<Mutex<Vec<Arc<ReferenceIndex>>>>,
+    /// Shared shard call-graph cache (see `BatchContext::shard_graph`).
+    pub(super) shard_graph: Arc<Mutex<Option<super::context::CachedShardGraph>>>,
     /// The validated worktree root to overlay for the *current* dispatch, or
     /// `None` when no overlay was requested / the request failed validation.
     /// Set by the search handlers (`dispatch_search` / `dispatch_search_with_refs`)
@@ -1040,6 +1120,15 @@ impl BatchView {
         Ok(arc)
     }
 
+    /// Call-graph context over the root and every shard of a sharded index,
+    /// cached across requests (see [`get_shard_graph_via_cache`]). `Ok(None)`
+    /// when the index is not sharded.
+    pub fn shard_graph(
+        &self,
+    ) -> Result<Option<Arc<Mutex<cqs::cross_project::CrossProjectContext>>>> {
+        get_shard_graph_via_cache(&self.shard_graph, &self.cqs_dir)
+    }
+
     /// Borrowed access keeps the snapshot Config in place; clone-on-access
     /// in case a handler wants ownership.
     #[allow(
@@ -1664,4 +1753,8 @@ impl crate::cli::commands::search::search_ctx::SearchCtx for BatchView {
         // stamped; `None` here means no overlay was requested for this query.
         self.resolve_overlay()
     }
+
+    fn shards(&self) -> Vec<Arc<ReferenceIndex>> {
+        get_shards_via_cache(&self.shards, &self.cqs_dir, self.store.dim())
+    }
 }
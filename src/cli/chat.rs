@@ -145,7 +145,14 @@ fn command_names() -> Vec<String> {
 ///
 /// Panics if the history size configuration (1000) is invalid, though this should never occur with a valid u64 value.
 pub(crate) fn cmd_chat() -> Result<()> {
-    let _span = tracing::info_span!("cmd_chat").entered();
+    cmd_chat_seeded(&[])
+}
+
+/// [`cmd_chat`] with `seed` lines appended to the readline history, so
+/// up-arrow recalls them first. `cqs session open --chat` seeds the session's
+/// queries as `search …` lines.
+pub(crate) fn cmd_chat_seeded(seed: &[String]) -> Result<()> {
+    let _span = tracing::info_span!("cmd_chat", seeded = seed.len()).entered();
 
     let ctx = batch::create_context()?;
     ctx.warm(); // Pre-warm embedder so first query doesn't pay ~500ms ONNX init
@@ -180,11 +187,20 @@ pub(crate) fn cmd_chat() -> Result<()> {
     if history_enabled {
         let _ = editor.load_history(&history_path);
     }
+    for line in seed {
+        let _ = editor.add_history_entry(line.as_str());
+    }
 
     println!("cqs interactive mode. Type 'help' for commands, 'exit' to quit.");
     if !history_enabled {
         println!("(chat history disabled by CQS_CHAT_HISTORY=0)");
     }
+    if !seed.is_empty() {
+        println!(
+            "{} session queries loaded into history (up-arrow to recall).",
+            seed.len()
+        );
+    }
 
     loop {
         match editor.readline("cqs> ") {
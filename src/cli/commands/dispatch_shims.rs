@@ -77,8 +77,8 @@ pub fn cmd_init_dispatch(
     _project_cqs_dir: &Path,
     cmd: &Commands,
 ) -> Result<()> {
-    must_be!(cmd, Commands::Init { output } => {
-        commands::cmd_init(cli, cli.json || output.json)
+    must_be!(cmd, Commands::Init { yes, index, output } => {
+        commands::cmd_init(cli, cli.json || output.json, *yes, *index)
     })
 }
 
@@ -93,6 +93,18 @@ pub fn cmd_cache_dispatch(
     })
 }
 
+#[cfg(feature = "llm-summaries")]
+pub fn cmd_llm_dispatch(
+    cli: &Cli,
+    _ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    must_be!(cmd, Commands::Llm { subcmd } => {
+        commands::cmd_llm(cli, subcmd)
+    })
+}
+
 pub fn cmd_slot_dispatch(
     cli: &Cli,
     _ctx: Option<&CommandContext<'_, ReadOnly>>,
@@ -115,6 +127,39 @@ pub fn cmd_doctor_dispatch(
     })
 }
 
+pub fn cmd_backup_dispatch(
+    cli: &Cli,
+    _ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    must_be!(cmd, Commands::Backup { out, output } => {
+        commands::cmd_backup(out, cli.json || output.json)
+    })
+}
+
+pub fn cmd_restore_dispatch(
+    cli: &Cli,
+    _ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    must_be!(cmd, Commands::Restore { snapshot, output } => {
+        commands::cmd_restore(cli, snapshot, cli.json || output.json)
+    })
+}
+
+pub fn cmd_replica_dispatch(
+    cli: &Cli,
+    _ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    must_be!(cmd, Commands::Replica { source, output } => {
+        commands::cmd_replica(cli, source, cli.json || output.json)
+    })
+}
+
 pub fn cmd_ping_dispatch(
     cli: &Cli,
     _ctx: Option<&CommandContext<'_, ReadOnly>>,
@@ -126,6 +171,17 @@ pub fn cmd_ping_dispatch(
     })
 }
 
+pub fn cmd_reload_config_dispatch(
+    cli: &Cli,
+    _ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    must_be!(cmd, Commands::ReloadConfig { output } => {
+        commands::cmd_reload_config(cli.json || output.json)
+    })
+}
+
 pub fn cmd_status_dispatch(
     cli: &Cli,
     _ctx: Option<&CommandContext<'_, ReadOnly>>,
@@ -144,6 +200,17 @@ pub fn cmd_status_dispatch(
     })
 }
 
+pub fn cmd_daemon_dispatch(
+    _cli: &Cli,
+    _ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    must_be!(cmd, Commands::Daemon { subcmd } => {
+        commands::cmd_daemon(subcmd.clone())
+    })
+}
+
 pub fn cmd_hook_dispatch(
     _cli: &Cli,
     _ctx: Option<&CommandContext<'_, ReadOnly>>,
@@ -202,20 +269,31 @@ pub fn cmd_watch_dispatch(
     _project_cqs_dir: &Path,
     cmd: &Commands,
 ) -> Result<()> {
-    must_be!(cmd, Commands::Watch { debounce, no_ignore, poll, serve } => {
-        crate::cli::watch::cmd_watch(cli, *debounce, *no_ignore, *poll, *serve)
+    must_be!(cmd, Commands::Watch { debounce, no_ignore, poll, serve, metrics } => {
+        crate::cli::watch::cmd_watch(cli, *debounce, *no_ignore, *poll, *serve, *metrics)
     })
 }
 
 #[cfg(feature = "serve")]
 pub fn cmd_serve_dispatch(
-    _cli: &Cli,
+    cli: &Cli,
     _ctx: Option<&CommandContext<'_, ReadOnly>>,
     _project_cqs_dir: &Path,
     cmd: &Commands,
 ) -> Result<()> {
-    must_be!(cmd, Commands::Serve { port, bind, open, no_auth } => {
-        crate::cli::commands::serve::cmd_serve(*port, bind.clone(), *open, *no_auth)
+    must_be!(cmd, Commands::Serve { action, port, bind, open, no_auth, grpc, grpc_port, projects } => {
+        match action {
+            Some(action) => crate::cli::commands::serve::cmd_serve_action(action, cli.json),
+            None => match projects {
+                Some(file) => {
+                    crate::cli::commands::serve::cmd_serve_projects(file, *port, bind.clone(), *no_auth)
+                }
+                None => {
+                    let grpc_port = grpc.then_some(*grpc_port);
+                    crate::cli::commands::serve::cmd_serve(*port, bind.clone(), *open, *no_auth, grpc_port)
+                }
+            },
+        }
     })
 }
 
@@ -243,6 +321,17 @@ pub fn cmd_mcp_dispatch(
     })
 }
 
+pub fn cmd_parse_worker_dispatch(
+    _cli: &Cli,
+    _ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    must_be!(cmd, Commands::ParseWorker => {
+        commands::cmd_parse_worker()
+    })
+}
+
 pub fn cmd_chat_dispatch(
     _cli: &Cli,
     _ctx: Option<&CommandContext<'_, ReadOnly>>,
@@ -355,6 +444,28 @@ pub fn cmd_project_dispatch(
     })
 }
 
+pub fn cmd_languages_dispatch(
+    cli: &Cli,
+    _ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    must_be!(cmd, Commands::Languages { subcmd } => {
+        commands::cmd_languages(cli, subcmd)
+    })
+}
+
+pub fn cmd_schema_dispatch(
+    cli: &Cli,
+    _ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    must_be!(cmd, Commands::Schema { subcmd } => {
+        commands::cmd_schema(cli, subcmd)
+    })
+}
+
 pub fn cmd_model_dispatch(
     cli: &Cli,
     _ctx: Option<&CommandContext<'_, ReadOnly>>,
@@ -366,6 +477,25 @@ pub fn cmd_model_dispatch(
     })
 }
 
+pub fn cmd_diff_index_dispatch(
+    cli: &Cli,
+    ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    let ctx = group_b_ctx!(ctx);
+    must_be!(cmd, Commands::DiffIndex { args, output } => {
+        commands::cmd_diff_index(
+            ctx,
+            &args.old,
+            &args.new,
+            args.threshold,
+            args.lang.as_deref(),
+            cli.json || output.json,
+        )
+    })
+}
+
 pub fn cmd_diff_dispatch(
     cli: &Cli,
     _ctx: Option<&CommandContext<'_, ReadOnly>>,
@@ -412,6 +542,17 @@ pub fn cmd_ref_dispatch(
     })
 }
 
+pub fn cmd_compress_dispatch(
+    cli: &Cli,
+    _ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    must_be!(cmd, Commands::Compress { off, dict_kib, output } => {
+        commands::cmd_compress(cli, *off, *dict_kib, cli.json || output.json)
+    })
+}
+
 pub fn cmd_gc_dispatch(
     cli: &Cli,
     _ctx: Option<&CommandContext<'_, ReadOnly>>,
@@ -511,6 +652,73 @@ pub fn cmd_brief_dispatch(
     })
 }
 
+pub fn cmd_explain_index_dispatch(
+    cli: &Cli,
+    ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    let ctx = group_b_ctx!(ctx);
+    must_be!(cmd, Commands::ExplainIndex { path, output } => {
+        commands::cmd_explain_index(ctx, path, cli.json || output.json)
+    })
+}
+
+pub fn cmd_history_dispatch(
+    cli: &Cli,
+    ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    let ctx = group_b_ctx!(ctx);
+    must_be!(cmd, Commands::History { name, output } => {
+        commands::cmd_history(ctx, name, cli.json || output.json)
+    })
+}
+
+pub fn cmd_dupes_dispatch(
+    cli: &Cli,
+    ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    let ctx = group_b_ctx!(ctx);
+    must_be!(cmd, Commands::Dupes { threshold, min_overlap, min_lines, output } => {
+        let opts = cqs::dupes::DupesOptions {
+            threshold: *threshold,
+            min_overlap: *min_overlap,
+            min_lines: *min_lines,
+        };
+        commands::cmd_dupes(ctx, &opts, cli.json || output.json)
+    })
+}
+
+pub fn cmd_todos_dispatch(
+    cli: &Cli,
+    ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    let ctx = group_b_ctx!(ctx);
+    must_be!(cmd, Commands::Todos { path, owner, group_by, output } => {
+        commands::cmd_todos(ctx, path.clone(), owner.as_deref(), (*group_by).into(), cli.json || output.json)
+    })
+}
+
+pub fn cmd_graph_dispatch(
+    cli: &Cli,
+    ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    let ctx = group_b_ctx!(ctx);
+    must_be!(cmd, Commands::Graph { format, depth } => {
+        // Global `--json` wins over the default DOT output.
+        let format = if cli.json { crate::cli::args::GraphFormat::Json } else { *format };
+        commands::cmd_graph(ctx, format, *depth)
+    })
+}
+
 pub fn cmd_stats_dispatch(
     cli: &Cli,
     ctx: Option<&CommandContext<'_, ReadOnly>>,
@@ -518,8 +726,88 @@ pub fn cmd_stats_dispatch(
     cmd: &Commands,
 ) -> Result<()> {
     let ctx = group_b_ctx!(ctx);
-    must_be!(cmd, Commands::Stats { output } => {
-        commands::cmd_stats(ctx, cli.json || output.json)
+    must_be!(cmd, Commands::Stats { hot, limit, output } => {
+        let json = cli.json || output.json;
+        if *hot {
+            commands::cmd_stats_hot(ctx, *limit, json)
+        } else {
+            commands::cmd_stats(ctx, json)
+        }
+    })
+}
+
+pub fn cmd_feedback_dispatch(
+    cli: &Cli,
+    ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    let ctx = group_b_ctx!(ctx);
+    must_be!(cmd, Commands::Feedback {
+        target, relevant, irrelevant, query, show, reset, output
+    } => {
+        let json = cli.json || output.json;
+        let action = if *show {
+            commands::FeedbackAction::Show
+        } else if *reset {
+            commands::FeedbackAction::Reset
+        } else {
+            let target = target
+                .as_deref()
+                .ok_or_else(|| anyhow!("feedback needs a result to rate"))?;
+            let relevant = match (*relevant, *irrelevant) {
+                (true, false) => true,
+                (false, true) => false,
+                _ => return Err(anyhow!("pass --relevant or --irrelevant")),
+            };
+            commands::FeedbackAction::Rate { target, relevant, query: query.as_deref() }
+        };
+        commands::cmd_feedback(ctx, action, json)
+    })
+}
+
+pub fn cmd_ask_dispatch(
+    cli: &Cli,
+    ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    let ctx = group_b_ctx!(ctx);
+    must_be!(cmd, Commands::Ask {
+        question, limit, lang, path, code_only, sources_only, output
+    } => {
+        let args = commands::AskArgs {
+            question,
+            limit: *limit,
+            lang: lang.as_deref(),
+            path: path.as_deref(),
+            code_only: *code_only,
+            sources_only: *sources_only,
+        };
+        commands::cmd_ask(ctx, &args, cli.json || output.json)
+    })
+}
+
+pub fn cmd_session_dispatch(
+    _cli: &Cli,
+    ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    let ctx = group_b_ctx!(ctx);
+    must_be!(cmd, Commands::Session { subcmd } => {
+        commands::cmd_session(ctx, subcmd)
+    })
+}
+
+pub fn cmd_open_dispatch(
+    cli: &Cli,
+    _ctx: Option<&CommandContext<'_, ReadOnly>>,
+    project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    must_be!(cmd, Commands::Open { index, print, output } => {
+        commands::cmd_open(project_cqs_dir, *index, *print, cli.json || output.json)
     })
 }
 
@@ -582,6 +870,23 @@ pub fn cmd_callees_dispatch(
     })
 }
 
+pub fn cmd_examples_dispatch(
+    cli: &Cli,
+    ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    let ctx = group_b_ctx!(ctx);
+    must_be!(cmd, Commands::Examples { symbol, limit, include_tests, context, output } => {
+        let opts = cqs::examples::ExampleOptions {
+            limit: *limit,
+            include_tests: *include_tests,
+            context: *context,
+        };
+        commands::cmd_examples(ctx, symbol, &opts, cli.json || output.json)
+    })
+}
+
 pub fn cmd_onboard_dispatch(
     cli: &Cli,
     ctx: Option<&CommandContext<'_, ReadOnly>>,
@@ -652,6 +957,7 @@ pub fn cmd_similar_dispatch(
             &args.name,
             args.limit_arg.limit,
             args.threshold,
+            args.same_file,
             lang,
             path,
             cli.json || output.json,
@@ -659,6 +965,20 @@ pub fn cmd_similar_dispatch(
     })
 }
 
+pub fn cmd_symbol_dispatch(
+    cli: &Cli,
+    ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    let ctx = group_b_ctx!(ctx);
+    must_be!(cmd, Commands::Symbol { name, limit, lang, output } => {
+        // Same tail-then-top-level resolution as `similar`.
+        let lang = lang.as_deref().or(cli.lang.as_deref());
+        commands::cmd_symbol(ctx, name, *limit, lang, cli.json || output.json)
+    })
+}
+
 /// Top-level `--json` (cli.json) overrides whatever the subcommand's
 /// `--format` says. `effective_format()` already honours `output.json`; we OR
 /// cli.json on top so `cqs --json impact foo` works without `--json` on the
@@ -825,6 +1145,7 @@ pub fn cmd_dead_dispatch(
             args.include_pub,
             args.min_confidence,
             verdict,
+            args.allowlist.as_deref(),
         )
     })
 }
@@ -1015,6 +1336,18 @@ pub fn cmd_train_pairs_dispatch(
     })
 }
 
+pub fn cmd_bench_dispatch(
+    _cli: &Cli,
+    ctx: Option<&CommandContext<'_, ReadOnly>>,
+    _project_cqs_dir: &Path,
+    cmd: &Commands,
+) -> Result<()> {
+    let ctx = group_b_ctx!(ctx);
+    must_be!(cmd, Commands::Bench { subcmd } => {
+        commands::cmd_bench(ctx, subcmd)
+    })
+}
+
 pub fn cmd_eval_dispatch(
     _cli: &Cli,
     ctx: Option<&CommandContext<'_, ReadOnly>>,
@@ -0,0 +1,519 @@
+//! `cqs eval author` — bootstrap relevance judgments from search + labeling.
+//!
+//! Reads a plain-text list of queries, runs each through the same search
+//! path `cqs eval` scores (`runner::search_candidates`), and asks the
+//! operator which of the top candidates are relevant. The answers are
+//! written as a v2-format eval set (`primary_answer` / `acceptable_answers`
+//! / `negative_examples`, as in `evals/queries/v2_300q.json`); every entry
+//! also carries a v3 `gold_chunk` so the file runs under `cqs eval` as-is.
+//!
+//! The output is rewritten after every labeled query and an existing file
+//! is resumed — queries it already holds are not asked again — so a long
+//! set can be labeled across several sittings.
+
+use std::collections::HashSet;
+use std::path::{Path, PathBuf};
+
+use anyhow::{Context as _, Result};
+use colored::Colorize;
+use serde::{Deserialize, Serialize};
+
+use cqs::eval::schema::GoldChunk;
+use cqs::store::{ChunkSummary, ReadOnly};
+
+use crate::cli::CommandContext;
+
+use super::runner;
+
+/// CLI args for `cqs eval author`.
+#[derive(Debug, Clone, clap::Args)]
+pub(crate) struct AuthorArgs {
+    /// Plain-text queries file: one query per line, blank lines and `#`
+    /// comments skipped. A `category<TAB>query` line pins the category.
+    #[arg(long)]
+    pub queries: PathBuf,
+
+    /// Eval JSON to write (default: the queries path with a `.json`
+    /// extension). An existing file is resumed, not overwritten.
+    #[arg(short = 'o', long)]
+    pub output: Option<PathBuf>,
+
+    /// Candidates shown per query
+    #[arg(short = 'n', long, default_value = "10", value_parser = crate::cli::definitions::parse_nonzero_usize)]
+    pub limit: usize,
+
+    /// Category for lines without a `category<TAB>` prefix (default: the
+    /// query classifier's pick)
+    #[arg(long)]
+    pub category: Option<String>,
+
+    /// Mark authored queries `held_out` instead of `train`
+    #[arg(long)]
+    pub held_out: bool,
+}
+
+/// A chunk reference in the v2 format.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+struct Answer {
+    name: String,
+    file: String,
+}
+
+/// One labeled query in the v2 format, plus a v3 `gold_chunk`.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+struct AuthoredQuery {
+    id: String,
+    query: String,
+    category: String,
+    #[serde(default)]
+    tags: Vec<String>,
+    #[serde(default)]
+    language: Option<String>,
+    primary_answer: Answer,
+    #[serde(default)]
+    acceptable_answers: Vec<Answer>,
+    #[serde(default)]
+    negative_examples: Vec<Answer>,
+    split: String,
+    /// Same chunk as `primary_answer`, in the shape `cqs eval` scores.
+    gold_chunk: GoldChunk,
+}
+
+/// The v2 envelope.
+#[derive(Debug, Serialize, Deserialize)]
+struct AuthoredSet {
+    version: String,
+    created: String,
+    description: String,
+    queries: Vec<AuthoredQuery>,
+}
+
+/// A line from the queries file.
+#[derive(Debug, PartialEq, Eq)]
+struct PendingQuery {
+    query: String,
+    category: Option<String>,
+}
+
+/// The operator's reply to one candidate list.
+#[derive(Debug, PartialEq, Eq)]
+enum Label {
+    /// 0-based candidate indices, in the order given; the first is primary.
+    /// Never empty.
+    Relevant(Vec<usize>),
+    Skip,
+    Quit,
+}
+
+/// Parse the queries file. `category<TAB>query` lines pin their category.
+fn parse_queries(raw: &str) -> Vec<PendingQuery> {
+    raw.lines()
+        .map(str::trim)
+        .filter(|l| !l.is_empty() && !l.starts_with('#'))
+        .map(|l| match l.split_once('\t') {
+            Some((cat, q)) if !cat.trim().is_empty() && !q.trim().is_empty() => PendingQuery {
+                query: q.trim().to_string(),
+                category: Some(cat.trim().to_string()),
+            },
+            _ => PendingQuery {
+                query: l.to_string(),
+                category: None,
+            },
+        })
+        .collect()
+}
+
+/// Parse a label reply against `n` shown candidates. Numbers are 1-based,
+/// separated by spaces or commas; empty or `s` skips, `q` quits.
+fn parse_label(input: &str, n: usize) -> std::result::Result<Label, String> {
+    let input = input.trim();
+    match input {
+        "" | "s" | "skip" => return Ok(Label::Skip),
+        "q" | "quit" => return Ok(Label::Quit),
+        _ => {}
+    }
+    let mut picks = Vec::new();
+    for tok in input.split(|c: char| c == ',' || c.is_whitespace()) {
+        if tok.is_empty() {
+            continue;
+        }
+        let k: usize = tok
+            .parse()
+            .map_err(|_| format!("'{tok}' is not a candidate number"))?;
+        if k == 0 || k > n {
+            return Err(format!("{k} is out of range 1..={n}"));
+        }
+        if !picks.contains(&(k - 1)) {
+            picks.push(k - 1);
+        }
+    }
+    if picks.is_empty() {
+        return Ok(Label::Skip);
+    }
+    Ok(Label::Relevant(picks))
+}
+
+/// Collapse candidates that share `(file, name)` — windowed sub-chunks of
+/// one function — since eval matches on that pair anyway.
+fn dedup_candidates(candidates: Vec<ChunkSummary>) -> Vec<ChunkSummary> {
+    let mut seen = HashSet::new();
+    candidates
+        .into_iter()
+        .filter(|c| seen.insert((cqs::normalize_path(&c.file), c.name.clone())))
+        .collect()
+}
+
+fn answer_of(c: &ChunkSummary) -> Answer {
+    Answer {
+        name: c.name.clone(),
+        file: cqs::normalize_path(&c.file),
+    }
+}
+
+/// Turn a non-empty pick list into an entry. Shown candidates the operator
+/// did not pick become `negative_examples`.
+fn build_entry(
+    id: String,
+    query: &str,
+    category: String,
+    split: &str,
+    candidates: &[ChunkSummary],
+    picks: &[usize],
+) -> AuthoredQuery {
+    let primary = &candidates[picks[0]];
+    AuthoredQuery {
+        id,
+        query: query.to_string(),
+        category,
+        tags: Vec::new(),
+        language: None,
+        primary_answer: answer_of(primary),
+        acceptable_answers: picks[1..]
+            .iter()
+            .map(|&i| answer_of(&candidates[i]))
+            .collect(),
+        negative_examples: (0..candidates.len())
+            .filter(|i| !picks.contains(i))
+            .map(|i| answer_of(&candidates[i]))
+            .collect(),
+        split: split.to_string(),
+        gold_chunk: GoldChunk {
+            name: primary.name.clone(),
+            origin: cqs::normalize_path(&primary.file),
+            line_start: primary.line_start,
+            id: Some(primary.id.clone()),
+            line_end: Some(primary.line_end),
+            chunk_type: Some(primary.chunk_type.to_string()),
+            language: Some(primary.language.to_string()),
+        },
+    }
+}
+
+/// First `auth-NNN` id not already used in `set`.
+fn next_id(set: &AuthoredSet) -> String {
+    let taken: HashSet<&str> = set.queries.iter().map(|q| q.id.as_str()).collect();
+    (set.queries.len() + 1..)
+        .map(|n| format!("auth-{n:03}"))
+        .find(|id| !taken.contains(id.as_str()))
+        .expect("unbounded range always yields a free id")
+}
+
+fn load_or_new(path: &Path, queries_path: &Path) -> Result<AuthoredSet> {
+    if path.exists() {
+        let raw = std::fs::read_to_string(path)
+            .with_context(|| format!("Failed to read {}", path.display()))?;
+        return serde_json::from_str(&raw).with_context(|| {
+            format!(
+                "{} exists but is not an authored eval set; pick another --output",
+                path.display()
+            )
+        });
+    }
+    Ok(AuthoredSet {
+        version: "authored".to_string(),
+        created: chrono::Utc::now().format("%Y-%m-%d").to_string(),
+        description: format!(
+            "Eval queries labeled with `cqs eval author` from {}",
+            cqs::normalize_path(queries_path)
+        ),
+        queries: Vec::new(),
+    })
+}
+
+/// Write via a sibling temp file so a crash mid-write can't truncate a
+/// half-labeled set.
+fn save(path: &Path, set: &AuthoredSet) -> Result<()> {
+    let bytes = serde_json::to_vec_pretty(set).context("Failed to serialize eval set")?;
+    let tmp = path.with_extension("json.tmp");
+    std::fs::write(&tmp, &bytes).with_context(|| format!("Failed to write {}", tmp.display()))?;
+    std::fs::rename(&tmp, path).with_context(|| format!("Failed to write {}", path.display()))?;
+    Ok(())
+}
+
+fn print_candidates(candidates: &[ChunkSummary]) {
+    for (i, c) in candidates.iter().enumerate() {
+        println!(
+            "  {} {} {}  {}",
+            format!("[{}]", i + 1).bold(),
+            c.chunk_type.to_string().dimmed(),
+            c.name.cyan(),
+            format!("{}:{}", cqs::normalize_path(&c.file), c.line_start).dimmed()
+        );
+        let sig = c.signature.lines().next().unwrap_or("").trim();
+        if !sig.is_empty() {
+            let sig: String = sig.chars().take(100).collect();
+            println!("      {sig}");
+        }
+    }
+}
+
+/// CLI handler for `cqs eval author`.
+pub(crate) fn cmd_eval_author(ctx: &CommandContext<'_, ReadOnly>, args: &AuthorArgs) -> Result<()> {
+    use std::io::IsTerminal;
+
+    let _span = tracing::info_span!(
+        "cmd_eval_author",
+        queries = %args.queries.display(),
+        limit = args.limit,
+    )
+    .entered();
+
+    let output = match &args.output {
+        Some(p) => match p.extension().and_then(|e| e.to_str()) {
+            Some(e) if e.eq_ignore_ascii_case("json") => p.clone(),
+            _ => anyhow::bail!("--output must end in .json; eval sets are JSON-only"),
+        },
+        None => args.queries.with_extension("json"),
+    };
+    if output == args.queries {
+        anyhow::bail!("--output would overwrite the queries file; pass a different path");
+    }
+
+    let raw = std::fs::read_to_string(&args.queries)
+        .with_context(|| format!("Failed to read queries file: {}", args.queries.display()))?;
+    let pending = parse_queries(&raw);
+    if pending.is_empty() {
+        anyhow::bail!("No queries in {}", args.queries.display());
+    }
+
+    // Labeling needs a human at the keyboard — never block a pipe or CI.
+    if !std::io::stdin().is_terminal() {
+        anyhow::bail!("cqs eval author is interactive; run it from a terminal");
+    }
+
+    let mut set = load_or_new(&output, &args.queries)?;
+    let done: HashSet<String> = set.queries.iter().map(|q| q.query.clone()).collect();
+    let todo: Vec<&PendingQuery> = pending
+        .iter()
+        .filter(|p| !done.contains(&p.query))
+        .collect();
+    if todo.is_empty() {
+        eprintln!(
+            "[eval author] every query is already labeled in {}",
+            output.display()
+        );
+        return Ok(());
+    }
+    if !done.is_empty() {
+        eprintln!(
+            "[eval author] resuming {} ({} labeled, {} to go)",
+            output.display(),
+            set.queries.len(),
+            todo.len()
+        );
+    }
+
+    let store = &ctx.store;
+    let embedder = ctx.embedder()?;
+    let index = crate::cli::build_vector_index(store, &ctx.cqs_dir)?;
+    let index_ref = index.as_deref();
+    let split = if args.held_out { "held_out" } else { "train" };
+
+    let mut editor = rustyline::DefaultEditor::new()?;
+    let (mut labeled, mut skipped) = (0usize, 0usize);
+
+    'queries: for (i, p) in todo.iter().enumerate() {
+        let category = p
+            .category
+            .clone()
+            .or_else(|| args.category.clone())
+            .unwrap_or_else(|| {
+                cqs::search::router::classify_query(&p.query)
+                    .category
+                    .to_string()
+            });
+
+        let results = runner::search_candidates(
+            ctx, embedder, store, index_ref, &p.query, args.limit, None, false,
+        )?;
+        let candidates = dedup_candidates(results.into_iter().map(|r| r.chunk).collect());
+
+        println!();
+        println!(
+            "{} {}  {}",
+            format!("[{}/{}]", i + 1, todo.len()).dimmed(),
+            p.query.bold(),
+            format!("({category})").dimmed()
+        );
+        if candidates.is_empty() {
+            println!("  no candidates; skipping");
+            skipped += 1;
+            continue;
+        }
+        print_candidates(&candidates);
+
+        let picks = loop {
+            let line = match editor
+                .readline("relevant (e.g. 2 1; first = primary), Enter skips, q quits> ")
+            {
+                Ok(line) => line,
+                Err(
+                    rustyline::error::ReadlineError::Interrupted
+                    | rustyline::error::ReadlineError::Eof,
+                ) => break 'queries,
+                Err(e) => return Err(e.into()),
+            };
+            match parse_label(&line, candidates.len()) {
+                Ok(Label::Quit) => break 'queries,
+                Ok(Label::Skip) => break None,
+                Ok(Label::Relevant(picks)) => break Some(picks),
+                Err(msg) => println!("  {msg}"),
+            }
+        };
+
+        let Some(picks) = picks else {
+            skipped += 1;
+            continue;
+        };
+        let id = next_id(&set);
+        set.queries.push(build_entry(
+            id,
+            &p.query,
+            category,
+            split,
+            &candidates,
+            &picks,
+        ));
+        save(&output, &set)?;
+        labeled += 1;
+    }
+
+    eprintln!(
+        "[eval author] labeled {labeled}, skipped {skipped}; {} queries in {}",
+        set.queries.len(),
+        output.display()
+    );
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn chunk(name: &str, file: &str, line: u32) -> ChunkSummary {
+        ChunkSummary {
+            id: format!("{file}:{line}:{name}"),
+            file: PathBuf::from(file),
+            language: cqs::parser::Language::Rust,
+            chunk_type: cqs::parser::ChunkType::Function,
+            name: name.to_string(),
+            signature: format!("fn {name}()"),
+            content: String::new(),
+            doc: None,
+            line_start: line,
+            line_end: line + 5,
+            content_hash: String::new(),
+            window_idx: None,
+            parent_id: None,
+            parent_type_name: None,
+            parser_version: 0,
+            vendored: false,
+            deprecated: false,
+            boilerplate: None,
+        }
+    }
+
+    #[test]
+    fn parse_queries_skips_comments_and_reads_category_prefix() {
+        let raw = "# header\n\nfind the embedder\nidentifier_lookup\tsearch_filtered\n\t\n";
+        assert_eq!(
+            parse_queries(raw),
+            vec![
+                PendingQuery {
+                    query: "find the embedder".into(),
+                    category: None,
+                },
+                PendingQuery {
+                    query: "search_filtered".into(),
+                    category: Some("identifier_lookup".into()),
+                },
+            ]
+        );
+    }
+
+    #[test]
+    fn parse_label_accepts_numbers_skip_and_quit() {
+        assert_eq!(parse_label("", 5), Ok(Label::Skip));
+        assert_eq!(parse_label(" s ", 5), Ok(Label::Skip));
+        assert_eq!(parse_label("q", 5), Ok(Label::Quit));
+        assert_eq!(parse_label("3, 1 3", 5), Ok(Label::Relevant(vec![2, 0])));
+        assert!(parse_label("6", 5).is_err());
+        assert!(parse_label("0", 5).is_err());
+        assert!(parse_label("two", 5).is_err());
+    }
+
+    #[test]
+    fn build_entry_orders_primary_acceptable_and_negatives() {
+        let candidates = vec![
+            chunk("a", "src/a.rs", 1),
+            chunk("b", "src/b.rs", 10),
+            chunk("c", "src/c.rs", 20),
+        ];
+        let e = build_entry(
+            "auth-001".into(),
+            "q",
+            "behavioral_search".into(),
+            "train",
+            &candidates,
+            &[1, 2],
+        );
+        assert_eq!(e.primary_answer.name, "b");
+        assert_eq!(e.acceptable_answers, vec![answer_of(&candidates[2])]);
+        assert_eq!(e.negative_examples, vec![answer_of(&candidates[0])]);
+        assert_eq!(e.gold_chunk.origin, "src/b.rs");
+        assert_eq!(e.gold_chunk.line_start, 10);
+    }
+
+    #[test]
+    fn dedup_candidates_keeps_first_window() {
+        let out = dedup_candidates(vec![
+            chunk("a", "src/a.rs", 1),
+            chunk("a", "src/a.rs", 40),
+            chunk("a", "src/b.rs", 1),
+        ]);
+        let lines: Vec<u32> = out.iter().map(|c| c.line_start).collect();
+        assert_eq!(lines, vec![1, 1]);
+    }
+
+    #[test]
+    fn authored_set_loads_as_v3_query_set() {
+        let candidates = vec![chunk("a", "src/a.rs", 1)];
+        let set = AuthoredSet {
+            version: "authored".into(),
+            created: "2026-01-01".into(),
+            description: String::new(),
+            queries: vec![build_entry(
+                "auth-001".into(),
+                "q",
+                "identifier_lookup".into(),
+                "train",
+                &candidates,
+                &[0],
+            )],
+        };
+        assert_eq!(next_id(&set), "auth-002");
+        let json = serde_json::to_string(&set).unwrap();
+        let v3: cqs::eval::schema::QuerySet = serde_json::from_str(&json).unwrap();
+        assert_eq!(v3.queries[0].gold_chunk.as_ref().unwrap().name, "a");
+    }
+}
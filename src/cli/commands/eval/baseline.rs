@@ -11,6 +11,12 @@
 //! 5pp can be invisible in a 500-query overall (1pp ripple). The whole
 //! point of the gate is to catch local regressions before they bleed
 //! into aggregate metrics, so the gate fires on per-category deltas.
+//!
+//! Cost is diffed alongside quality: P50 / P95 per-query latency and peak
+//! RSS are always reported, and `--perf-tolerance PCT` turns a P95 or peak
+//! RSS increase of more than PCT percent into a regression too. The cost
+//! gate is opt-in because wall-clock latency is only comparable between
+//! runs on the same machine.
 
 use std::collections::BTreeMap;
 use std::path::{Path, PathBuf};
@@ -49,6 +55,30 @@ pub(crate) struct Regression {
     pub delta_pp: f64,
 }
 
+/// Cost side of the diff. A side is `None` when that report predates
+/// latency / RSS tracking (or the platform doesn't report RSS).
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub(crate) struct PerfDelta {
+    pub baseline_p50_ms: Option<f64>,
+    pub current_p50_ms: Option<f64>,
+    pub baseline_p95_ms: Option<f64>,
+    pub current_p95_ms: Option<f64>,
+    pub baseline_peak_rss_bytes: Option<u64>,
+    pub current_peak_rss_bytes: Option<u64>,
+}
+
+/// A cost metric that grew past `--perf-tolerance`.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub(crate) struct PerfRegression {
+    /// One of "P95 latency" / "peak RSS".
+    pub metric: String,
+    /// Milliseconds for latency, bytes for RSS.
+    pub baseline_value: f64,
+    pub current_value: f64,
+    /// Positive = regression. Always (current - baseline) / baseline in percent.
+    pub delta_pct: f64,
+}
+
 /// Lightweight slice of metadata we surface in the diff header. Pulled from
 /// the loaded baseline so the user can spot model / version drift without
 /// opening the JSON.
@@ -74,12 +104,22 @@ pub(crate) struct DiffReport {
     pub baseline_meta: BaselineMeta,
     pub current_meta: BaselineMeta,
     pub overall_delta: KDelta,
+    /// Change in the category-weighted score; `None` unless both runs
+    /// carried one.
+    #[serde(skip_serializing_if = "Option::is_none", default)]
+    pub weighted_delta: Option<KDelta>,
     /// Categories present in EITHER baseline OR current. Categories only on
     /// one side get a half-populated entry (the missing side reads as 0).
     pub by_category_delta: BTreeMap<String, KDelta>,
     pub regressions: Vec<Regression>,
     /// Tolerance (percentage points) the regressions list was filtered with.
     pub tolerance_pp: f64,
+    pub perf: PerfDelta,
+    /// Empty unless `--perf-tolerance` was given.
+    pub perf_regressions: Vec<PerfRegression>,
+    /// `--perf-tolerance` in percent; `None` = cost reported, not gated.
+    #[serde(skip_serializing_if = "Option::is_none", default)]
+    pub perf_tolerance_pct: Option<f64>,
     /// Drift warnings (e.g. baseline was saved with a different model). These
     /// are advisory — they don't gate exit, but they do print so a CI log
     /// shows them.
@@ -97,6 +137,10 @@ fn to_pp(fraction: f64) -> f64 {
 /// is tolerated without triggering a regression entry. A drop of exactly
 /// `tolerance_pp` is allowed; anything strictly greater is a regression.
 ///
+/// `perf_tolerance_pct`, when set, is the percent increase in P95 latency
+/// or peak RSS tolerated before a [`PerfRegression`] is recorded; same
+/// strict-greater rule.
+///
 /// Loads the baseline JSON, diffs it, and returns the structured report.
 /// Does not print or exit — the caller (`cmd_eval`) handles output and
 /// exit code so this stays unit-testable without subprocess dance.
@@ -104,6 +148,7 @@ pub(crate) fn compare_against_baseline(
     current: &EvalReport,
     baseline_path: &Path,
     tolerance_pp: f64,
+    perf_tolerance_pct: Option<f64>,
 ) -> Result<DiffReport> {
     let _span =
         tracing::info_span!("compare_against_baseline", path = %baseline_path.display()).entered();
@@ -137,6 +182,15 @@ pub(crate) fn compare_against_baseline(
             baseline.index_model, current.index_model
         ));
     }
+    if baseline.weighted.is_some()
+        && current.weighted.is_some()
+        && baseline.category_weights != current.category_weights
+    {
+        warnings.push(
+            "category_weights differ between baseline and current; the WEIGHTED delta mixes two weightings."
+                .to_string(),
+        );
+    }
     for w in &warnings {
         tracing::warn!(warning = %w, "baseline drift");
     }
@@ -146,6 +200,14 @@ pub(crate) fn compare_against_baseline(
         r_at_5: to_pp(current.overall.r_at_5) - to_pp(baseline.overall.r_at_5),
         r_at_20: to_pp(current.overall.r_at_20) - to_pp(baseline.overall.r_at_20),
     };
+    let weighted_delta = match (&baseline.weighted, &current.weighted) {
+        (Some(b), Some(c)) => Some(KDelta {
+            r_at_1: to_pp(c.r_at_1) - to_pp(b.r_at_1),
+            r_at_5: to_pp(c.r_at_5) - to_pp(b.r_at_5),
+            r_at_20: to_pp(c.r_at_20) - to_pp(b.r_at_20),
+        }),
+        _ => None,
+    };
 
     // Union of category keys from both sides so a category present in only
     // one of them still shows up in the diff.
@@ -203,6 +265,19 @@ pub(crate) fn compare_against_baseline(
         by_category_delta.insert(cat.to_string(), delta);
     }
 
+    let perf = PerfDelta {
+        baseline_p50_ms: baseline.latency.map(|l| l.p50_ms),
+        current_p50_ms: current.latency.map(|l| l.p50_ms),
+        baseline_p95_ms: baseline.latency.map(|l| l.p95_ms),
+        current_p95_ms: current.latency.map(|l| l.p95_ms),
+        baseline_peak_rss_bytes: baseline.peak_rss_bytes,
+        current_peak_rss_bytes: current.peak_rss_bytes,
+    };
+    let perf_regressions = match perf_tolerance_pct {
+        Some(tol) => perf_regressions(&perf, tol),
+        None => Vec::new(),
+    };
+
     Ok(DiffReport {
         baseline_path: baseline_path.to_path_buf(),
         baseline_meta: BaselineMeta {
@@ -218,13 +293,63 @@ pub(crate) fn compare_against_baseline(
             overall_n: current.overall.n,
         },
         overall_delta,
+        weighted_delta,
         by_category_delta,
         regressions,
         tolerance_pp,
+        perf,
+        perf_regressions,
+        perf_tolerance_pct,
         warnings,
     })
 }
 
+/// Percent change from `baseline` to `current`; `None` unless both sides
+/// were measured and the baseline is non-zero.
+fn pct_change(baseline: Option<f64>, current: Option<f64>) -> Option<f64> {
+    match (baseline, current) {
+        (Some(b), Some(c)) if b > 0.0 => Some((c - b) / b * 100.0),
+        _ => None,
+    }
+}
+
+/// P95 latency and peak RSS increases strictly greater than `tolerance_pct`.
+/// A metric missing on either side is never a regression.
+fn perf_regressions(perf: &PerfDelta, tolerance_pct: f64) -> Vec<PerfRegression> {
+    let rss = |b: Option<u64>| b.map(|v| v as f64);
+    [
+        ("P95 latency", perf.baseline_p95_ms, perf.current_p95_ms),
+        (
+            "peak RSS",
+            rss(perf.baseline_peak_rss_bytes),
+            rss(perf.current_peak_rss_bytes),
+        ),
+    ]
+    .into_iter()
+    .filter_map(|(metric, b, c)| {
+        let delta_pct = pct_change(b, c)?;
+        (delta_pct > tolerance_pct).then(|| PerfRegression {
+            metric: metric.to_string(),
+            baseline_value: b.unwrap_or_default(),
+            current_value: c.unwrap_or_default(),
+            delta_pct,
+        })
+    })
+    .collect()
+}
+
+/// `12.0→13.5ms (+12.5%)`, or `—` when a side wasn't measured.
+fn format_cost(baseline: Option<f64>, current: Option<f64>, unit: &str) -> String {
+    match (baseline, current) {
+        (Some(b), Some(c)) => match pct_change(Some(b), Some(c)) {
+            Some(pct) => format!("{b:.1}\u{2192}{c:.1}{unit} ({pct:+.1}%)"),
+            None => format!("{b:.1}\u{2192}{c:.1}{unit}"),
+        },
+        (None, Some(c)) => format!("{c:.1}{unit} (no baseline)"),
+        _ => "\u{2014}".to_string(),
+    }
+}
+
 /// Format a delta in percentage points with a sign and the `pp` suffix:
 /// positive `(+1.2pp)`, negative `(-0.8pp)`, exactly zero `(±0.0pp)`.
 ///
@@ -294,6 +419,28 @@ pub(crate) fn print_diff_report(report: &DiffReport, json: bool) {
         format_delta(report.overall_delta.r_at_5),
         format_delta(report.overall_delta.r_at_20),
     );
+    if let Some(d) = &report.weighted_delta {
+        println!(
+            "WEIGHTED: R@1 {} R@5 {} R@20 {}",
+            format_delta(d.r_at_1),
+            format_delta(d.r_at_5),
+            format_delta(d.r_at_20),
+        );
+    }
+    let p = &report.perf;
+    if p.current_p95_ms.is_some() || p.current_peak_rss_bytes.is_some() {
+        let mib = |b: Option<u64>| b.map(|v| v as f64 / (1024.0 * 1024.0));
+        println!(
+            "COST: P50 {}  P95 {}  peak RSS {}",
+            format_cost(p.baseline_p50_ms, p.current_p50_ms, "ms"),
+            format_cost(p.baseline_p95_ms, p.current_p95_ms, "ms"),
+            format_cost(
+                mib(p.baseline_peak_rss_bytes),
+                mib(p.current_peak_rss_bytes),
+                "MiB"
+            ),
+        );
+    }
     println!();
 
     if !report.by_category_delta.is_empty() {
@@ -334,6 +481,20 @@ pub(crate) fn print_diff_report(report: &DiffReport, json: bool) {
             );
         }
     }
+
+    if let Some(tol) = report.perf_tolerance_pct {
+        if report.perf_regressions.is_empty() {
+            println!("(no cost regressions beyond +{tol:.1}%)");
+        } else {
+            println!("COST REGRESSIONS (exit 1) — tolerance +{tol:.1}%:");
+            for reg in &report.perf_regressions {
+                println!(
+                    "  {:<24} baseline {:.1} \u{2192} current {:.1} ({:+.1}%)",
+                    reg.metric, reg.baseline_value, reg.current_value, reg.delta_pct,
+                );
+            }
+        }
+    }
 }
 
 #[cfg(test)]
@@ -376,11 +537,21 @@ mod tests {
                 r_at_20: overall.2,
             },
             by_category,
+            weighted: None,
+            category_weights: BTreeMap::new(),
             index_model: model.to_string(),
             cqs_version: version.to_string(),
             query_file: "noop.json".into(),
             limit: 20,
             category_filter: None,
+            reranker: None,
+            latency: None,
+            peak_rss_bytes: None,
+            hard_negatives: None,
+            by_query_language: BTreeMap::new(),
+            translated: None,
+            calibration: None,
+            queries: Vec::new(),
         }
     }
 
@@ -403,7 +574,7 @@ mod tests {
             "bge-large",
         );
         let tmp = save_to_tmp(&r);
-        let diff = compare_against_baseline(&r, tmp.path(), 0.5).unwrap();
+        let diff = compare_against_baseline(&r, tmp.path(), 0.5, None).unwrap();
         assert_eq!(diff.overall_delta.r_at_1, 0.0);
         assert_eq!(diff.overall_delta.r_at_5, 0.0);
         assert_eq!(diff.overall_delta.r_at_20, 0.0);
@@ -424,7 +595,7 @@ mod tests {
             "m",
         );
         let tmp = save_to_tmp(&baseline);
-        let diff = compare_against_baseline(&current, tmp.path(), 0.5).unwrap();
+        let diff = compare_against_baseline(&current, tmp.path(), 0.5, None).unwrap();
         assert!(
             diff.regressions.is_empty(),
             "drop == tolerance should NOT regress, got: {:?}",
@@ -432,6 +603,103 @@ mod tests {
         );
     }
 
+    /// The cost gate is opt-in, strict-greater, and ignores metrics a side
+    /// didn't record (a baseline saved before latency tracking).
+    #[test]
+    fn test_perf_gate_flags_p95_and_rss_growth() {
+        use super::super::runner::LatencyStats;
+        let latency = |p95| {
+            Some(LatencyStats {
+                p50_ms: 10.0,
+                p95_ms: p95,
+                max_ms: p95,
+            })
+        };
+        let mut baseline = make_report((0.4, 0.6, 0.8), &[("a", 0.4, 0.6, 0.8)], "1", "m");
+        baseline.latency = latency(40.0);
+        baseline.peak_rss_bytes = Some(1000);
+        let mut current = baseline.clone();
+        current.latency = latency(50.0); // +25%
+        current.peak_rss_bytes = Some(1100); // +10%
+        let tmp = save_to_tmp(&baseline);
+
+        let ungated = compare_against_baseline(&current, tmp.path(), 0.5, None).unwrap();
+        assert!(ungated.perf_regressions.is_empty());
+        assert_eq!(ungated.perf.baseline_p95_ms, Some(40.0));
+        assert_eq!(ungated.perf.current_p95_ms, Some(50.0));
+
+        let gated = compare_against_baseline(&current, tmp.path(), 0.5, Some(10.0)).unwrap();
+        let metrics: Vec<_> = gated
+            .perf_regressions
+            .iter()
+            .map(|r| r.metric.as_str())
+            .collect();
+        assert_eq!(metrics, vec!["P95 latency"], "RSS +10% == tolerance passes");
+        assert!((gated.perf_regressions[0].delta_pct - 25.0).abs() < 1e-9);
+
+        baseline.latency = None;
+        baseline.peak_rss_bytes = None;
+        let tmp = save_to_tmp(&baseline);
+        let old = compare_against_baseline(&current, tmp.path(), 0.5, Some(0.0)).unwrap();
+        assert!(old.perf_regressions.is_empty());
+    }
+
+    /// The weighted score diffs like OVERALL, only when both runs have one,
+    /// and a change of weights is called out.
+    #[test]
+    fn test_weighted_delta_needs_both_sides() {
+        let weighted = |r| {
+            Some(Overall {
+                n: 2,
+                r_at_1: r,
+                r_at_5: r,
+                r_at_20: r,
+            })
+        };
+        let mut baseline = make_report((0.5, 0.5, 0.5), &[("concept", 0.5, 0.5, 0.5)], "1", "m");
+        let mut current = baseline.clone();
+        let tmp = save_to_tmp(&baseline);
+        assert!(compare_against_baseline(&current, tmp.path(), 0.5, None)
+            .unwrap()
+            .weighted_delta
+            .is_none());
+
+        baseline.weighted = weighted(0.50);
+        baseline.category_weights = [("concept".to_string(), 2.0)].into();
+        current.weighted = weighted(0.40);
+        current.category_weights = baseline.category_weights.clone();
+        let tmp = save_to_tmp(&baseline);
+        let diff = compare_against_baseline(&current, tmp.path(), 0.5, None).unwrap();
+        assert!((diff.weighted_delta.unwrap().r_at_1 + 10.0).abs() < 1e-9);
+        assert!(diff.warnings.is_empty());
+
+        current.category_weights = [("concept".to_string(), 3.0)].into();
+        let diff = compare_against_baseline(&current, tmp.path(), 0.5, None).unwrap();
+        assert_eq!(diff.warnings.len(), 1);
+    }
+
+    /// A baseline saved before per-query capture, latency and weights
+    /// still loads and diffs; its missing cost numbers leave the gate off.
+    #[test]
+    fn test_diff_against_pre_per_query_baseline() {
+        let tmp = tempfile::NamedTempFile::new().unwrap();
+        std::fs::write(tmp.path(), super::super::runner::PRE_PER_QUERY_REPORT).unwrap();
+        let current = make_report(
+            (0.25, 0.50, 0.75),
+            &[("behavioral", 0.5, 0.5, 1.0), ("identifier", 0.0, 0.0, 0.5)],
+            "1.50.0",
+            "bge-large",
+        );
+        let diff = compare_against_baseline(&current, tmp.path(), 0.5, Some(10.0)).unwrap();
+        assert_eq!(diff.overall_delta.r_at_5, 0.0);
+        assert_eq!(diff.regressions.len(), 1);
+        assert_eq!(diff.regressions[0].category, "behavioral");
+        assert_eq!(diff.regressions[0].metric, "R@5");
+        assert_eq!(diff.perf.baseline_p95_ms, None);
+        assert!(diff.perf_regressions.is_empty());
+        assert!(diff.weighted_delta.is_none());
+    }
+
     /// `format_delta` rounds to one decimal and uses ± for ~zero.
     #[test]
     fn test_format_delta_shape() {
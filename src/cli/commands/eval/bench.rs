@@ -0,0 +1,813 @@
+//! `cqs bench` — throughput and latency on standard workloads.
+//!
+//! Four workloads, each drawn deterministically from the current index or
+//! project, so two runs with the same `--seed` against the same index do the
+//! same work:
+//!
+//!   `cqs bench search` — queries built from indexed symbol names (or read
+//!   from `--queries`) through the production search path
+//!   `cqs bench embed` — stored chunk contents through the document embedder
+//!   `cqs bench index` — a sample of project files through parse → embed →
+//!   write → HNSW, into a throwaway index in a temp dir
+//!   `cqs bench content` — chunk reads by ID in result-page batches, with
+//!   the index's chunk-text storage; run it before and after `cqs compress`
+//!   with the same seed to see what compression costs per read
+//!
+//! Each report carries a workload fingerprint and the environment (cqs
+//! version, model, provider, index size), so reports saved with `--save`
+//! from two versions or configs can be compared knowing whether they ran
+//! the same work. Wall-clock numbers only compare on the same machine.
+
+use std::collections::HashSet;
+use std::path::PathBuf;
+use std::sync::Arc;
+use std::time::Instant;
+
+use anyhow::{Context as _, Result};
+use serde::Serialize;
+
+use cqs::store::ReadOnly;
+use cqs::{ModelInfo, Store};
+
+use crate::cli::definitions::parse_nonzero_usize;
+use crate::cli::CommandContext;
+
+use super::runner::{peak_rss_bytes, search_candidates, LatencyStats};
+
+/// Options every workload shares.
+#[derive(Debug, Clone, clap::Args)]
+pub(crate) struct BenchOpts {
+    /// Seed for workload sampling; the same seed against the same index
+    /// picks the same queries, chunks, or files
+    #[arg(long, default_value_t = 0)]
+    pub seed: u64,
+
+    /// Output as JSON instead of text
+    #[arg(long)]
+    pub json: bool,
+
+    /// Also write the JSON report to this path
+    #[arg(long)]
+    pub save: Option<PathBuf>,
+}
+
+/// CLI args for `cqs bench search`.
+#[derive(Debug, Clone, clap::Args)]
+pub(crate) struct SearchBenchArgs {
+    /// Number of queries
+    #[arg(long, default_value = "100", value_parser = parse_nonzero_usize)]
+    pub count: usize,
+
+    /// Read queries from this file (one per line, `#` comments) instead of
+    /// deriving them from symbol names
+    #[arg(long)]
+    pub queries: Option<PathBuf>,
+
+    /// Results per query
+    #[arg(short = 'n', long, default_value = "5", value_parser = parse_nonzero_usize)]
+    pub limit: usize,
+
+    /// Timed passes over the query set, after one untimed warm-up pass
+    #[arg(long, default_value = "3", value_parser = parse_nonzero_usize)]
+    pub rounds: usize,
+
+    #[command(flatten)]
+    pub opts: BenchOpts,
+}
+
+/// CLI args for `cqs bench embed`.
+#[derive(Debug, Clone, clap::Args)]
+pub(crate) struct EmbedBenchArgs {
+    /// Number of stored chunks to embed
+    #[arg(long, default_value = "256", value_parser = parse_nonzero_usize)]
+    pub count: usize,
+
+    /// Texts per `embed_documents` call (default: the model's batch size)
+    #[arg(long, value_parser = parse_nonzero_usize)]
+    pub batch: Option<usize>,
+
+    #[command(flatten)]
+    pub opts: BenchOpts,
+}
+
+/// CLI args for `cqs bench index`.
+#[derive(Debug, Clone, clap::Args)]
+pub(crate) struct IndexBenchArgs {
+    /// Number of project files to index
+    #[arg(long, default_value = "200", value_parser = parse_nonzero_usize)]
+    pub files: usize,
+
+    #[command(flatten)]
+    pub opts: BenchOpts,
+}
+
+/// CLI args for `cqs bench content`.
+#[derive(Debug, Clone, clap::Args)]
+pub(crate) struct ContentBenchArgs {
+    /// Number of stored chunks to read
+    #[arg(long, default_value = "256", value_parser = parse_nonzero_usize)]
+    pub count: usize,
+
+    /// Chunks per read (a page of search results)
+    #[arg(long, default_value = "10", value_parser = parse_nonzero_usize)]
+    pub batch: usize,
+
+    /// Timed passes over the sample, after one untimed warm-up pass
+    #[arg(long, default_value = "3", value_parser = parse_nonzero_usize)]
+    pub rounds: usize,
+
+    #[command(flatten)]
+    pub opts: BenchOpts,
+}
+
+/// Workloads for `cqs bench`.
+#[derive(Debug, Clone, clap::Subcommand)]
+pub(crate) enum BenchCommand {
+    /// Search latency and queries/sec over seeded queries
+    Search(SearchBenchArgs),
+    /// Document-embedding throughput over seeded stored chunks
+    Embed(EmbedBenchArgs),
+    /// Cold indexing throughput over seeded project files (temp index; the
+    /// live index is never touched)
+    Index(IndexBenchArgs),
+    /// Chunk-text read latency and storage size over seeded stored chunks
+    Content(ContentBenchArgs),
+}
+
+/// What was run: enough to tell whether two reports measured the same work.
+#[derive(Debug, Clone, Serialize)]
+pub(crate) struct Workload {
+    pub seed: u64,
+    /// `index` (sampled from the store), `project` (sampled from the file
+    /// walk), or the `--queries` path
+    pub source: String,
+    pub items: usize,
+    /// Hash of the sampled items in order; equal fingerprints mean the same
+    /// workload
+    pub fingerprint: String,
+}
+
+/// Where it ran.
+#[derive(Debug, Clone, Serialize)]
+pub(crate) struct Environment {
+    pub cqs_version: String,
+    pub model: String,
+    pub model_repo: String,
+    pub dim: usize,
+    pub provider: String,
+    pub index_chunks: u64,
+    pub threads: usize,
+    pub os: String,
+    pub arch: String,
+}
+
+#[derive(Debug, Clone, Serialize)]
+pub(crate) struct SearchResults {
+    pub limit: usize,
+    pub rounds: usize,
+    pub searches: usize,
+    pub errors: usize,
+    pub latency: Option<LatencyStats>,
+    pub mean_ms: f64,
+    pub queries_per_sec: f64,
+}
+
+#[derive(Debug, Clone, Serialize)]
+pub(crate) struct EmbedResults {
+    pub batch_size: usize,
+    pub batches: usize,
+    pub chunks: usize,
+    pub mean_chars: f64,
+    /// Session load plus a probe inference, before timing starts
+    pub warm_ms: f64,
+    pub total_ms: f64,
+    pub chunks_per_sec: f64,
+    /// Per-batch latency
+    pub latency: Option<LatencyStats>,
+}
+
+#[derive(Debug, Clone, Serialize)]
+pub(crate) struct IndexResults {
+    pub files: usize,
+    pub bytes: u64,
+    pub chunks: u64,
+    pub parse_errors: usize,
+    /// Parse, embed, and write, including embedder load
+    pub pipeline_ms: f64,
+    pub hnsw_ms: f64,
+    pub total_ms: f64,
+    pub files_per_sec: f64,
+    pub chunks_per_sec: f64,
+}
+
+#[derive(Debug, Clone, Serialize)]
+pub(crate) struct ContentResults {
+    pub batch_size: usize,
+    pub rounds: usize,
+    pub reads: usize,
+    pub chunks: usize,
+    /// Dictionary chunk text is compressed against; `None` when stored plain
+    pub dict_id: Option<u32>,
+    /// Chunks in the index stored as zstd frames
+    pub compressed_chunks: u64,
+    /// Stored `content` bytes across the index
+    pub stored_bytes: u64,
+    /// Text bytes of the sampled chunks
+    pub sample_bytes: u64,
+    /// Per-read latency
+    pub latency: Option<LatencyStats>,
+    pub us_per_chunk: f64,
+    pub chunks_per_sec: f64,
+}
+
+/// `cqs bench` report. Exactly one of `search` / `embed` / `index` /
+/// `content` is set, matching `bench`.
+#[derive(Debug, Clone, Serialize)]
+pub(crate) struct BenchReport {
+    pub bench: &'static str,
+    pub workload: Workload,
+    pub environment: Environment,
+    pub peak_rss_bytes: Option<u64>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub search: Option<SearchResults>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub embed: Option<EmbedResults>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub index: Option<IndexResults>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub content: Option<ContentResults>,
+}
+
+/// CLI handler for `cqs bench`.
+pub(crate) fn cmd_bench(ctx: &CommandContext<'_, ReadOnly>, cmd: &BenchCommand) -> Result<()> {
+    let (report, opts) = match cmd {
+        BenchCommand::Search(args) => (bench_search(ctx, args)?, &args.opts),
+        BenchCommand::Embed(args) => (bench_embed(ctx, args)?, &args.opts),
+        BenchCommand::Index(args) => (bench_index(ctx, args)?, &args.opts),
+        BenchCommand::Content(args) => (bench_content(ctx, args)?, &args.opts),
+    };
+    if ctx.cli.json || opts.json {
+        crate::cli::json_envelope::emit_json(&report)?;
+    } else {
+        print_text_report(&report);
+    }
+    if let Some(path) = &opts.save {
+        let bytes = serde_json::to_vec_pretty(&report).context("Failed to serialize report")?;
+        std::fs::write(path, &bytes)
+            .with_context(|| format!("Failed to write report to {}", path.display()))?;
+        eprintln!("[bench] saved report to {}", path.display());
+    }
+    Ok(())
+}
+
+fn bench_search(ctx: &CommandContext<'_, ReadOnly>, args: &SearchBenchArgs) -> Result<BenchReport> {
+    let _span =
+        tracing::info_span!("bench_search", count = args.count, seed = args.opts.seed).entered();
+    let (queries, source) = match &args.queries {
+        Some(path) => {
+            let text = std::fs::read_to_string(path)
+                .with_context(|| format!("Failed to read queries: {}", path.display()))?;
+            (query_lines(&text), path.display().to_string())
+        }
+        None => {
+            let names: Vec<String> = ctx
+                .store
+                .all_chunk_identities()?
+                .into_iter()
+                .map(|c| c.name)
+                .collect();
+            (queries_from_names(names), "index".to_string())
+        }
+    };
+    let queries = seeded_sample(queries, |q| q.as_bytes(), args.opts.seed, args.count);
+    if queries.is_empty() {
+        anyhow::bail!("No queries to run: the index has no named chunks and no --queries file");
+    }
+
+    let embedder = ctx.embedder()?;
+    let index = crate::cli::build_vector_index(&ctx.store, &ctx.cqs_dir)?;
+    let index_ref = index.as_deref();
+    let search = |q: &str| {
+        search_candidates(
+            ctx, embedder, &ctx.store, index_ref, q, args.limit, None, false,
+        )
+    };
+
+    // The warm-up pass loads the model, SPLADE, and the index pages, and
+    // fills the query-embedding cache, so the timed passes measure retrieval
+    // the same way on every run. `bench embed` covers inference cost.
+    for q in &queries {
+        let _ = search(q);
+    }
+
+    let mut samples_ms = Vec::with_capacity(queries.len() * args.rounds);
+    let mut errors = 0usize;
+    let started = Instant::now();
+    for _ in 0..args.rounds {
+        for q in &queries {
+            let t = Instant::now();
+            match search(q) {
+                Ok(_) => samples_ms.push(t.elapsed().as_secs_f64() * 1000.0),
+                Err(e) => {
+                    tracing::warn!(query = %q, error = %e, "bench search failed");
+                    errors += 1;
+                }
+            }
+        }
+    }
+    let elapsed = started.elapsed().as_secs_f64();
+
+    Ok(BenchReport {
+        bench: "search",
+        workload: workload(args.opts.seed, source, &queries, |q| q.as_bytes()),
+        environment: environment(ctx, embedder.provider().to_string())?,
+        peak_rss_bytes: peak_rss_bytes(),
+        search: Some(SearchResults {
+            limit: args.limit,
+            rounds: args.rounds,
+            searches: samples_ms.len(),
+            errors,
+            latency: LatencyStats::from_samples(&samples_ms),
+            mean_ms: mean(&samples_ms),
+            queries_per_sec: per_sec(samples_ms.len(), elapsed),
+        }),
+        embed: None,
+        index: None,
+        content: None,
+    })
+}
+
+fn bench_embed(ctx: &CommandContext<'_, ReadOnly>, args: &EmbedBenchArgs) -> Result<BenchReport> {
+    let _span =
+        tracing::info_span!("bench_embed", count = args.count, seed = args.opts.seed).entered();
+    // One window per chunk: windows of a long function would weight the
+    // sample toward whichever functions happen to be long.
+    let ids: Vec<String> = ctx
+        .store
+        .all_chunk_identities()?
+        .into_iter()
+        .filter(|c| c.window_idx.unwrap_or(0) == 0)
+        .map(|c| c.id)
+        .collect();
+    let ids = seeded_sample(ids, |id| id.as_bytes(), args.opts.seed, args.count);
+    if ids.is_empty() {
+        anyhow::bail!("The index has no chunks to embed; run `cqs index` first");
+    }
+    let id_refs: Vec<&str> = ids.iter().map(String::as_str).collect();
+    let mut by_id = ctx.store.get_chunks_by_ids(&id_refs)?;
+    let texts: Vec<String> = ids
+        .iter()
+        .filter_map(|id| by_id.remove(id).map(|c| c.content))
+        .collect();
+
+    let embedder = ctx.embedder()?;
+    let warm_started = Instant::now();
+    embedder.warm()?;
+    let warm_ms = warm_started.elapsed().as_secs_f64() * 1000.0;
+
+    let batch_size = args
+        .batch
+        .unwrap_or_else(|| embedder.model_config().embed_batch_size());
+    let mut samples_ms = Vec::new();
+    let started = Instant::now();
+    for batch in texts.chunks(batch_size) {
+        let refs: Vec<&str> = batch.iter().map(String::as_str).collect();
+        let t = Instant::now();
+        embedder.embed_documents(&refs)?;
+        samples_ms.push(t.elapsed().as_secs_f64() * 1000.0);
+    }
+    let elapsed = started.elapsed().as_secs_f64();
+    let total_chars: usize = texts.iter().map(|t| t.chars().count()).sum();
+
+    Ok(BenchReport {
+        bench: "embed",
+        workload: workload(args.opts.seed, "index".to_string(), &ids, |id| {
+            id.as_bytes()
+        }),
+        environment: environment(ctx, embedder.provider().to_string())?,
+        peak_rss_bytes: peak_rss_bytes(),
+        search: None,
+        embed: Some(EmbedResults {
+            batch_size,
+            batches: samples_ms.len(),
+            chunks: texts.len(),
+            mean_chars: total_chars as f64 / texts.len().max(1) as f64,
+            warm_ms,
+            total_ms: elapsed * 1000.0,
+            chunks_per_sec: per_sec(texts.len(), elapsed),
+            latency: LatencyStats::from_samples(&samples_ms),
+        }),
+        index: None,
+        content: None,
+    })
+}
+
+fn bench_index(ctx: &CommandContext<'_, ReadOnly>, args: &IndexBenchArgs) -> Result<BenchReport> {
+    let _span =
+        tracing::info_span!("bench_index", files = args.files, seed = args.opts.seed).entered();
+    let parser = cqs::Parser::new().context("Failed to initialize parser")?;
+    let files = crate::cli::enumerate_files(&ctx.root, &parser, false)?;
+    let files = seeded_sample(
+        files,
+        |p| p.as_os_str().as_encoded_bytes(),
+        args.opts.seed,
+        args.files,
+    );
+    if files.is_empty() {
+        anyhow::bail!("No indexable files under {}", ctx.root.display());
+    }
+
+    // Copy the sample into a scratch project so the pipeline starts from an
+    // empty store and an empty embeddings cache, and nothing the run writes
+    // can land in the real `.cqs/`.
+    let scratch = tempfile::tempdir().context("Failed to create scratch directory")?;
+    let mut bytes = 0u64;
+    for rel in &files {
+        let dest = scratch.path().join(rel);
+        if let Some(parent) = dest.parent() {
+            std::fs::create_dir_all(parent)?;
+        }
+        bytes += std::fs::copy(ctx.root.join(rel), &dest)
+            .with_context(|| format!("Failed to copy {}", rel.display()))?;
+    }
+    let cqs_dir = scratch.path().join(cqs::INDEX_DIR);
+    std::fs::create_dir_all(&cqs_dir)?;
+    let model_config = ctx.model_config().clone();
+    let mut store = Store::open(&cqs_dir.join(cqs::INDEX_DB_FILENAME))
+        .context("Failed to create scratch index")?;
+    store.init(&ModelInfo::new(&model_config.repo, model_config.dim))?;
+    store.set_dim(model_config.dim);
+    let store = Arc::new(store);
+
+    let started = Instant::now();
+    let stats = crate::cli::run_index_pipeline(
+        scratch.path(),
+        files.clone(),
+        Arc::clone(&store),
+        true,
+        true,
+        model_config,
+        false,
+    )?;
+    let pipeline_secs = started.elapsed().as_secs_f64();
+    let hnsw_started = Instant::now();
+    crate::cli::commands::index::build_hnsw_index(&store, &cqs_dir)?;
+    let hnsw_secs = hnsw_started.elapsed().as_secs_f64();
+    let total_secs = started.elapsed().as_secs_f64();
+    let chunks = store.chunk_count()?;
+
+    Ok(BenchReport {
+        bench: "index",
+        workload: workload(args.opts.seed, "project".to_string(), &files, |p| {
+            p.as_os_str().as_encoded_bytes()
+        }),
+        environment: environment(ctx, cqs::embedder::select_provider().to_string())?,
+        peak_rss_bytes: peak_rss_bytes(),
+        search: None,
+        embed: None,
+        index: Some(IndexResults {
+            files: files.len(),
+            bytes,
+            chunks,
+            parse_errors: stats.parse_errors,
+            pipeline_ms: pipeline_secs * 1000.0,
+            hnsw_ms: hnsw_secs * 1000.0,
+            total_ms: total_secs * 1000.0,
+            files_per_sec: per_sec(files.len(), total_secs),
+            chunks_per_sec: per_sec(chunks as usize, total_secs),
+        }),
+        content: None,
+    })
+}
+
+fn bench_content(
+    ctx: &CommandContext<'_, ReadOnly>,
+    args: &ContentBenchArgs,
+) -> Result<BenchReport> {
+    let _span =
+        tracing::info_span!("bench_content", count = args.count, seed = args.opts.seed).entered();
+    let ids: Vec<String> = ctx
+        .store
+        .all_chunk_identities()?
+        .into_iter()
+        .map(|c| c.id)
+        .collect();
+    let ids = seeded_sample(ids, |id| id.as_bytes(), args.opts.seed, args.count);
+    if ids.is_empty() {
+        anyhow::bail!("The index has no chunks to read; run `cqs index` first");
+    }
+    let batches: Vec<Vec<&str>> = ids
+        .chunks(args.batch)
+        .map(|b| b.iter().map(String::as_str).collect())
+        .collect();
+
+    // The warm-up pass pulls the rows into the page cache, so the timed
+    // passes measure decoding rather than the disk.
+    let mut sample_bytes = 0u64;
+    for batch in &batches {
+        let chunks = ctx.store.get_chunks_by_ids(batch)?;
+        sample_bytes += chunks.values().map(|c| c.content.len() as u64).sum::<u64>();
+    }
+
+    let mut samples_ms = Vec::with_capacity(batches.len() * args.rounds);
+    let started = Instant::now();
+    for _ in 0..args.rounds {
+        for batch in &batches {
+            let t = Instant::now();
+            ctx.store.get_chunks_by_ids(batch)?;
+            samples_ms.push(t.elapsed().as_secs_f64() * 1000.0);
+        }
+    }
+    let elapsed = started.elapsed().as_secs_f64();
+    let chunks_read = ids.len() * args.rounds;
+    let storage = ctx.store.content_storage()?;
+
+    Ok(BenchReport {
+        bench: "content",
+        workload: workload(args.opts.seed, "index".to_string(), &ids, |id| {
+            id.as_bytes()
+        }),
+        environment: environment(ctx, cqs::embedder::select_provider().to_string())?,
+        peak_rss_bytes: peak_rss_bytes(),
+        search: None,
+        embed: None,
+        index: None,
+        content: Some(ContentResults {
+            batch_size: args.batch,
+            rounds: args.rounds,
+            reads: samples_ms.len(),
+            chunks: ids.len(),
+            dict_id: storage.dict_id,
+            compressed_chunks: storage.compressed,
+            stored_bytes: storage.stored_bytes,
+            sample_bytes,
+            latency: LatencyStats::from_samples(&samples_ms),
+            us_per_chunk: elapsed * 1_000_000.0 / chunks_read.max(1) as f64,
+            chunks_per_sec: per_sec(chunks_read, elapsed),
+        }),
+    })
+}
+
+/// Up to `n` items of `items`, ordered by `blake3(seed ‖ key)`. Stable for
+/// a given seed and item set, and independent of the order items arrive in,
+/// so a store returning rows in a different order still yields the same
+/// sample.
+fn seeded_sample<T>(items: Vec<T>, key: impl Fn(&T) -> &[u8], seed: u64, n: usize) -> Vec<T> {
+    let mut keyed: Vec<([u8; 32], T)> = items
+        .into_iter()
+        .map(|item| {
+            let mut hasher = blake3::Hasher::new();
+            hasher.update(&seed.to_le_bytes());
+            hasher.update(key(&item));
+            (*hasher.finalize().as_bytes(), item)
+        })
+        .collect();
+    keyed.sort_by(|a, b| a.0.cmp(&b.0));
+    keyed.truncate(n);
+    keyed.into_iter().map(|(_, item)| item).collect()
+}
+
+/// Natural-language-ish queries from symbol names: `parseConfigFile` →
+/// `parse config file`. Single-token names are too vague to exercise
+/// ranking and are dropped, as are duplicates (overloads, windows).
+fn queries_from_names(names: Vec<String>) -> Vec<String> {
+    let mut seen = HashSet::new();
+    names
+        .into_iter()
+        .map(|name| cqs::tokenize_identifier(&name).join(" "))
+        .filter(|q| q.split(' ').count() >= 2 && seen.insert(q.clone()))
+        .collect()
+}
+
+/// One query per non-blank line; `#` starts a comment line.
+fn query_lines(text: &str) -> Vec<String> {
+    text.lines()
+        .map(str::trim)
+        .filter(|l| !l.is_empty() && !l.starts_with('#'))
+        .map(str::to_string)
+        .collect()
+}
+
+fn workload<T>(seed: u64, source: String, items: &[T], key: impl Fn(&T) -> &[u8]) -> Workload {
+    let mut hasher = blake3::Hasher::new();
+    for item in items {
+        let bytes = key(item);
+        hasher.update(&(bytes.len() as u64).to_le_bytes());
+        hasher.update(bytes);
+    }
+    Workload {
+        seed,
+        source,
+        items: items.len(),
+        fingerprint: hasher.finalize().to_hex()[..16].to_string(),
+    }
+}
+
+fn environment(ctx: &CommandContext<'_, ReadOnly>, provider: String) -> Result<Environment> {
+    let model = ctx.model_config();
+    Ok(Environment {
+        cqs_version: env!("CARGO_PKG_VERSION").to_string(),
+        model: model.name.clone(),
+        model_repo: model.repo.clone(),
+        dim: model.dim,
+        provider,
+        index_chunks: ctx.store.chunk_count()?,
+        threads: std::thread::available_parallelism().map_or(1, |n| n.get()),
+        os: std::env::consts::OS.to_string(),
+        arch: std::env::consts::ARCH.to_string(),
+    })
+}
+
+fn mean(samples: &[f64]) -> f64 {
+    if samples.is_empty() {
+        0.0
+    } else {
+        samples.iter().sum::<f64>() / samples.len() as f64
+    }
+}
+
+fn per_sec(count: usize, secs: f64) -> f64 {
+    if secs > 0.0 {
+        count as f64 / secs
+    } else {
+        0.0
+    }
+}
+
+fn print_text_report(report: &BenchReport) {
+    let w = &report.workload;
+    let env = &report.environment;
+    let latency = |l: &Option<LatencyStats>| match l {
+        Some(l) => format!(
+            "p50 {:.1} ms  p95 {:.1} ms  max {:.1} ms",
+            l.p50_ms, l.p95_ms, l.max_ms
+        ),
+        None => "-".to_string(),
+    };
+    println!(
+        "bench {}: {} items from {} (seed {}, workload {})",
+        report.bench, w.items, w.source, w.seed, w.fingerprint
+    );
+    if let Some(s) = &report.search {
+        println!("  passes:      {} x limit {}", s.rounds, s.limit);
+        println!(
+            "  latency:     {}  mean {:.1} ms",
+            latency(&s.latency),
+            s.mean_ms
+        );
+        println!("  throughput:  {:.1} queries/s", s.queries_per_sec);
+        if s.errors > 0 {
+            println!("  errors:      {}", s.errors);
+        }
+    }
+    if let Some(e) = &report.embed {
+        println!(
+            "  batches:     {} x {} (mean {:.0} chars/chunk)",
+            e.batches, e.batch_size, e.mean_chars
+        );
+        println!("  warm-up:     {:.0} ms", e.warm_ms);
+        println!("  batch:       {}", latency(&e.latency));
+        println!("  throughput:  {:.1} chunks/s", e.chunks_per_sec);
+    }
+    if let Some(i) = &report.index {
+        println!(
+            "  indexed:     {} files, {} bytes, {} chunks ({} parse errors)",
+            i.files, i.bytes, i.chunks, i.parse_errors
+        );
+        println!(
+            "  time:        {:.0} ms pipeline + {:.0} ms HNSW = {:.0} ms",
+            i.pipeline_ms, i.hnsw_ms, i.total_ms
+        );
+        println!(
+            "  throughput:  {:.1} files/s, {:.1} chunks/s",
+            i.files_per_sec, i.chunks_per_sec
+        );
+    }
+    if let Some(c) = &report.content {
+        let dict = match c.dict_id {
+            Some(id) => format!("compressed (dictionary {id})"),
+            None => "plain".to_string(),
+        };
+        println!(
+            "  storage:     {}, {} of {} chunks as frames, {} bytes stored",
+            dict, c.compressed_chunks, env.index_chunks, c.stored_bytes
+        );
+        println!(
+            "  passes:      {} x {} reads of {} chunks ({} text bytes)",
+            c.rounds,
+            c.reads / c.rounds.max(1),
+            c.batch_size,
+            c.sample_bytes
+        );
+        println!("  read:        {}", latency(&c.latency));
+        println!(
+            "  throughput:  {:.1} chunks/s ({:.1} us/chunk)",
+            c.chunks_per_sec, c.us_per_chunk
+        );
+    }
+    println!(
+        "  environment: cqs {}, {} ({}-dim) on {}, {} chunks, {} threads, {}/{}",
+        env.cqs_version,
+        env.model,
+        env.dim,
+        env.provider,
+        env.index_chunks,
+        env.threads,
+        env.os,
+        env.arch
+    );
+    if let Some(rss) = report.peak_rss_bytes {
+        println!("  peak RSS:    {:.0} MiB", rss as f64 / (1024.0 * 1024.0));
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn seeded_sample_is_order_independent_and_seed_sensitive() {
+        let items: Vec<String> = (0..50).map(|i| format!("chunk{i}")).collect();
+        let mut reversed = items.clone();
+        reversed.reverse();
+        let a = seeded_sample(items.clone(), |s| s.as_bytes(), 7, 10);
+        let b = seeded_sample(reversed, |s| s.as_bytes(), 7, 10);
+        assert_eq!(a, b);
+        assert_eq!(a.len(), 10);
+        let c = seeded_sample(items.clone(), |s| s.as_bytes(), 8, 10);
+        assert_ne!(a, c);
+        assert_eq!(seeded_sample(items, |s| s.as_bytes(), 7, 100).len(), 50);
+    }
+
+    #[test]
+    fn queries_from_names_splits_and_dedupes() {
+        let names = vec![
+            "parseConfigFile".to_string(),
+            "parse_config_file".to_string(),
+            "main".to_string(),
+            "get_user".to_string(),
+        ];
+        assert_eq!(
+            queries_from_names(names),
+            vec!["parse config file".to_string(), "get user".to_string()]
+        );
+        assert_eq!(
+            query_lines("# header\n\n  find auth  \nretry with backoff\n"),
+            vec!["find auth".to_string(), "retry with backoff".to_string()]
+        );
+    }
+
+    #[test]
+    fn fingerprint_tracks_items_and_order() {
+        let fp = |seed, items: &[&str]| workload(seed, "index".into(), items, |s| s.as_bytes());
+        let a = fp(0, &["a", "bc"]);
+        let b = fp(0, &["ab", "c"]);
+        let c = fp(0, &["bc", "a"]);
+        assert_eq!(a.fingerprint.len(), 16);
+        assert_ne!(a.fingerprint, b.fingerprint);
+        assert_ne!(a.fingerprint, c.fingerprint);
+        // The seed picks the sample; the fingerprint describes only what was
+        // picked.
+        assert_eq!(a.fingerprint, fp(3, &["a", "bc"]).fingerprint);
+    }
+
+    #[test]
+    fn report_serializes_only_its_own_section() {
+        let report = BenchReport {
+            bench: "embed",
+            workload: workload(0, "index".into(), &["x"], |s| s.as_bytes()),
+            environment: Environment {
+                cqs_version: "0.0.0".into(),
+                model: "m".into(),
+                model_repo: "org/m".into(),
+                dim: 8,
+                provider: "cpu".into(),
+                index_chunks: 1,
+                threads: 1,
+                os: "linux".into(),
+                arch: "x86_64".into(),
+            },
+            peak_rss_bytes: None,
+            search: None,
+            embed: Some(EmbedResults {
+                batch_size: 1,
+                batches: 1,
+                chunks: 1,
+                mean_chars: 3.0,
+                warm_ms: 1.0,
+                total_ms: 2.0,
+                chunks_per_sec: 500.0,
+                latency: LatencyStats::from_samples(&[2.0]),
+            }),
+            index: None,
+            content: None,
+        };
+        let json = serde_json::to_value(&report).unwrap();
+        assert_eq!(json["bench"], "embed");
+        assert_eq!(json["embed"]["latency"]["p95_ms"], 2.0);
+        assert!(json.get("search").is_none());
+        assert!(json.get("index").is_none());
+        assert!(json.get("content").is_none());
+        assert_eq!(json["workload"]["items"], 1);
+    }
+}
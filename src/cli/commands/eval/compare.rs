@@ -0,0 +1,795 @@
+//! `cqs eval compare A.json B.json` — paired A/B comparison of two saved
+//! eval reports.
+//!
+//! `cqs eval --save` writes per-query outcomes. Queries are paired by
+//! text, scored under one metric (reciprocal rank or hit@K), and the
+//! per-query deltas (B − A) drive:
+//!   - a paired bootstrap 95% confidence interval on the mean delta;
+//!   - a paired randomization (sign-flip) test for the two-sided p-value;
+//!   - a winners / losers table of the queries that moved most.
+//!
+//! A report saved before per-query capture has only its R@K aggregates.
+//! Against one of those the comparison falls back to the overall and
+//! per-category aggregate deltas, with no interval or p-value.
+//!
+//! Resampling uses a seeded SplitMix64 rather than `rand` so a given
+//! `--seed` reproduces the same interval and p-value on every platform
+//! and `rand` release.
+//!
+//! Why not just diff the aggregates: a fusion-weight change that nets
+//! +1pp R@5 can be 12 queries rescued and 11 lost — noise. The paired test
+//! says whether the aggregate move is distinguishable from that.
+
+use std::collections::{BTreeMap, HashMap, VecDeque};
+use std::path::{Path, PathBuf};
+
+use anyhow::{Context as _, Result};
+use serde::Serialize;
+
+use super::runner::{EvalReport, QueryOutcome};
+
+/// Per-query score the comparison runs on.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, clap::ValueEnum)]
+pub(crate) enum CompareMetric {
+    /// Reciprocal rank of the gold chunk (0 on a miss)
+    Mrr,
+    /// Gold chunk ranked first
+    #[value(name = "r@1")]
+    R1,
+    /// Gold chunk in the top 5
+    #[value(name = "r@5")]
+    R5,
+    /// Gold chunk in the top 20
+    #[value(name = "r@20")]
+    R20,
+}
+
+impl CompareMetric {
+    fn label(self) -> &'static str {
+        match self {
+            Self::Mrr => "MRR",
+            Self::R1 => "R@1",
+            Self::R5 => "R@5",
+            Self::R20 => "R@20",
+        }
+    }
+
+    fn score(self, rank: Option<usize>) -> f64 {
+        let hit = |k: usize| rank.is_some_and(|r| r <= k) as u8 as f64;
+        match self {
+            Self::Mrr => rank.map_or(0.0, |r| 1.0 / r as f64),
+            Self::R1 => hit(1),
+            Self::R5 => hit(5),
+            Self::R20 => hit(20),
+        }
+    }
+
+    /// The metric from a report's R@K aggregates, for reports without
+    /// per-query outcomes. `None` for MRR, which needs the ranks.
+    fn aggregate(self, r_at_1: f64, r_at_5: f64, r_at_20: f64) -> Option<f64> {
+        match self {
+            Self::Mrr => None,
+            Self::R1 => Some(r_at_1),
+            Self::R5 => Some(r_at_5),
+            Self::R20 => Some(r_at_20),
+        }
+    }
+}
+
+/// CLI args for `cqs eval compare`.
+#[derive(Debug, Clone, clap::Args)]
+pub(crate) struct CompareArgs {
+    /// Baseline report (`cqs eval --save` output)
+    pub run_a: PathBuf,
+
+    /// Candidate report, compared against `run_a`
+    pub run_b: PathBuf,
+
+    /// Per-query score to compare
+    #[arg(long, value_enum, default_value_t = CompareMetric::R5)]
+    pub metric: CompareMetric,
+
+    /// Bootstrap / randomization resamples
+    #[arg(long, default_value = "10000", value_parser = crate::cli::definitions::parse_nonzero_usize)]
+    pub resamples: usize,
+
+    /// Resampling seed, for reproducible intervals and p-values
+    #[arg(long, default_value = "0")]
+    pub seed: u64,
+
+    /// Winners and losers listed in each table
+    #[arg(short = 'n', long, default_value = "10")]
+    pub top: usize,
+
+    /// Output as JSON instead of text
+    #[arg(long)]
+    pub json: bool,
+}
+
+/// One paired query's movement.
+#[derive(Debug, Clone, Serialize)]
+struct QueryDelta {
+    query: String,
+    category: String,
+    rank_a: Option<usize>,
+    rank_b: Option<usize>,
+    /// Metric score B − A
+    delta: f64,
+}
+
+/// Paired mean per category.
+#[derive(Debug, Clone, Serialize)]
+struct CategoryDelta {
+    n: usize,
+    mean_a: f64,
+    mean_b: f64,
+    delta: f64,
+    wins: usize,
+    losses: usize,
+}
+
+/// Full comparison output.
+#[derive(Debug, Clone, Serialize)]
+struct CompareReport {
+    run_a: String,
+    run_b: String,
+    metric: &'static str,
+    /// `false` when either report predates per-query capture: only the
+    /// aggregates were compared, so there is no interval, p-value or
+    /// winners / losers table
+    per_query: bool,
+    /// Queries present in both runs
+    paired: usize,
+    /// Queries only in A / only in B (not scored)
+    unmatched_a: usize,
+    unmatched_b: usize,
+    mean_a: f64,
+    mean_b: f64,
+    /// mean_b − mean_a
+    delta: f64,
+    /// Paired bootstrap 95% interval on `delta`
+    ci_low: Option<f64>,
+    ci_high: Option<f64>,
+    /// Two-sided paired randomization p-value
+    p_value: Option<f64>,
+    /// p < 0.05 and the interval excludes zero
+    significant: bool,
+    resamples: usize,
+    seed: u64,
+    wins: usize,
+    losses: usize,
+    ties: usize,
+    by_category: BTreeMap<String, CategoryDelta>,
+    winners: Vec<QueryDelta>,
+    losers: Vec<QueryDelta>,
+    warnings: Vec<String>,
+}
+
+/// SplitMix64 — tiny, seedable, and stable across platforms.
+struct SplitMix64(u64);
+
+impl SplitMix64 {
+    fn next_u64(&mut self) -> u64 {
+        self.0 = self.0.wrapping_add(0x9E37_79B9_7F4A_7C15);
+        let mut z = self.0;
+        z = (z ^ (z >> 30)).wrapping_mul(0xBF58_476D_1CE4_E5B9);
+        z = (z ^ (z >> 27)).wrapping_mul(0x94D0_49BB_1331_11EB);
+        z ^ (z >> 31)
+    }
+
+    /// Uniform in `0..n` (`n > 0`). Modulo bias is < n / 2^64 — irrelevant
+    /// at eval-set sizes.
+    fn below(&mut self, n: usize) -> usize {
+        (self.next_u64() % n as u64) as usize
+    }
+}
+
+fn mean(xs: &[f64]) -> f64 {
+    if xs.is_empty() {
+        0.0
+    } else {
+        xs.iter().sum::<f64>() / xs.len() as f64
+    }
+}
+
+/// Pair A's and B's outcomes by query text. Repeated query texts pair in
+/// file order. Returns the pairs plus the unmatched count on each side.
+fn pair_queries<'a>(
+    a: &'a [QueryOutcome],
+    b: &'a [QueryOutcome],
+) -> (Vec<(&'a QueryOutcome, &'a QueryOutcome)>, usize, usize) {
+    let mut by_text: HashMap<&str, VecDeque<&QueryOutcome>> = HashMap::new();
+    for q in b {
+        by_text.entry(q.query.as_str()).or_default().push_back(q);
+    }
+    let mut pairs = Vec::new();
+    let mut unmatched_a = 0;
+    for qa in a {
+        match by_text
+            .get_mut(qa.query.as_str())
+            .and_then(VecDeque::pop_front)
+        {
+            Some(qb) => pairs.push((qa, qb)),
+            None => unmatched_a += 1,
+        }
+    }
+    let unmatched_b = by_text.values().map(VecDeque::len).sum();
+    (pairs, unmatched_a, unmatched_b)
+}
+
+/// Paired bootstrap 95% percentile interval on the mean of `deltas`.
+fn bootstrap_ci(deltas: &[f64], resamples: usize, rng: &mut SplitMix64) -> (f64, f64) {
+    if deltas.is_empty() {
+        return (0.0, 0.0);
+    }
+    let n = deltas.len();
+    let mut means: Vec<f64> = (0..resamples)
+        .map(|_| (0..n).map(|_| deltas[rng.below(n)]).sum::<f64>() / n as f64)
+        .collect();
+    means.sort_by(f64::total_cmp);
+    let at = |p: f64| means[((p * resamples as f64) as usize).min(resamples - 1)];
+    (at(0.025), at(0.975))
+}
+
+/// Two-sided paired randomization test: under H0 each delta's sign is a
+/// coin flip. Add-one smoothing keeps p > 0 for a finite resample count.
+fn randomization_p(deltas: &[f64], resamples: usize, rng: &mut SplitMix64) -> f64 {
+    if deltas.is_empty() {
+        return 1.0;
+    }
+    let observed = mean(deltas).abs();
+    // Tolerance so a resample that ties the observed mean up to float noise
+    // counts as "as extreme".
+    let eps = 1e-12;
+    let extreme = (0..resamples)
+        .filter(|_| {
+            let flipped: f64 = deltas
+                .iter()
+                .map(|d| if rng.next_u64() & 1 == 0 { *d } else { -*d })
+                .sum::<f64>()
+                / deltas.len() as f64;
+            flipped.abs() + eps >= observed
+        })
+        .count();
+    (extreme + 1) as f64 / (resamples + 1) as f64
+}
+
+/// Warnings about settings that differ between the two runs.
+fn drift_warnings(a: &EvalReport, b: &EvalReport) -> Vec<String> {
+    let mut warnings = Vec::new();
+    if a.index_model != b.index_model {
+        warnings.push(format!(
+            "index model differs: A={}, B={}",
+            a.index_model, b.index_model
+        ));
+    }
+    if a.limit != b.limit {
+        warnings.push(format!(
+            "result limit differs: A={}, B={}; misses past the smaller limit aren't comparable",
+            a.limit, b.limit
+        ));
+    }
+    warnings
+}
+
+fn build_compare(
+    a: &EvalReport,
+    b: &EvalReport,
+    label_a: String,
+    label_b: String,
+    args: &CompareArgs,
+) -> Result<CompareReport> {
+    if a.queries.is_empty() || b.queries.is_empty() {
+        return build_aggregate_compare(a, b, label_a, label_b, args);
+    }
+    let metric = args.metric;
+    let (pairs, unmatched_a, unmatched_b) = pair_queries(&a.queries, &b.queries);
+
+    let mut warnings = drift_warnings(a, b);
+    if unmatched_a + unmatched_b > 0 {
+        warnings.push(format!(
+            "{unmatched_a} query(s) only in A and {unmatched_b} only in B were left out"
+        ));
+    }
+
+    let deltas_all: Vec<QueryDelta> = pairs
+        .iter()
+        .map(|(qa, qb)| QueryDelta {
+            query: qa.query.clone(),
+            category: qa.category.clone(),
+            rank_a: qa.rank,
+            rank_b: qb.rank,
+            delta: metric.score(qb.rank) - metric.score(qa.rank),
+        })
+        .collect();
+    let deltas: Vec<f64> = deltas_all.iter().map(|d| d.delta).collect();
+    let scores_a: Vec<f64> = pairs.iter().map(|(qa, _)| metric.score(qa.rank)).collect();
+    let scores_b: Vec<f64> = pairs.iter().map(|(_, qb)| metric.score(qb.rank)).collect();
+
+    // One stream, consumed in a fixed order, so the seed pins both numbers.
+    let mut rng = SplitMix64(args.seed);
+    let (ci_low, ci_high) = bootstrap_ci(&deltas, args.resamples, &mut rng);
+    let p_value = randomization_p(&deltas, args.resamples, &mut rng);
+
+    let mut by_cat: BTreeMap<String, (Vec<f64>, Vec<f64>)> = BTreeMap::new();
+    for (i, d) in deltas_all.iter().enumerate() {
+        let e = by_cat.entry(d.category.clone()).or_default();
+        e.0.push(scores_a[i]);
+        e.1.push(scores_b[i]);
+    }
+    let by_category = by_cat
+        .into_iter()
+        .map(|(cat, (sa, sb))| {
+            let wins = sa.iter().zip(&sb).filter(|(x, y)| y > x).count();
+            let losses = sa.iter().zip(&sb).filter(|(x, y)| y < x).count();
+            let (mean_a, mean_b) = (mean(&sa), mean(&sb));
+            let entry = CategoryDelta {
+                n: sa.len(),
+                mean_a,
+                mean_b,
+                delta: mean_b - mean_a,
+                wins,
+                losses,
+            };
+            (cat, entry)
+        })
+        .collect();
+
+    let wins = deltas.iter().filter(|d| **d > 0.0).count();
+    let losses = deltas.iter().filter(|d| **d < 0.0).count();
+    let mut winners: Vec<QueryDelta> = deltas_all
+        .iter()
+        .filter(|d| d.delta > 0.0)
+        .cloned()
+        .collect();
+    winners.sort_by(|x, y| y.delta.total_cmp(&x.delta));
+    winners.truncate(args.top);
+    let mut losers: Vec<QueryDelta> = deltas_all
+        .iter()
+        .filter(|d| d.delta < 0.0)
+        .cloned()
+        .collect();
+    losers.sort_by(|x, y| x.delta.total_cmp(&y.delta));
+    losers.truncate(args.top);
+
+    let (mean_a, mean_b) = (mean(&scores_a), mean(&scores_b));
+    Ok(CompareReport {
+        run_a: label_a,
+        run_b: label_b,
+        metric: metric.label(),
+        per_query: true,
+        paired: pairs.len(),
+        unmatched_a,
+        unmatched_b,
+        mean_a,
+        mean_b,
+        delta: mean_b - mean_a,
+        ci_low: Some(ci_low),
+        ci_high: Some(ci_high),
+        p_value: Some(p_value),
+        significant: p_value < 0.05 && (ci_low > 0.0 || ci_high < 0.0),
+        resamples: args.resamples,
+        seed: args.seed,
+        wins,
+        losses,
+        ties: deltas.len() - wins - losses,
+        by_category,
+        winners,
+        losers,
+        warnings,
+    })
+}
+
+/// Compare the R@K aggregates of two reports when either predates
+/// per-query capture. The means are the overall R@K and each category the
+/// two runs share gets its delta; nothing is paired, so there is no
+/// significance.
+fn build_aggregate_compare(
+    a: &EvalReport,
+    b: &EvalReport,
+    label_a: String,
+    label_b: String,
+    args: &CompareArgs,
+) -> Result<CompareReport> {
+    let metric = args.metric;
+    let overall =
+        |r: &EvalReport| metric.aggregate(r.overall.r_at_1, r.overall.r_at_5, r.overall.r_at_20);
+    let (Some(mean_a), Some(mean_b)) = (overall(a), overall(b)) else {
+        anyhow::bail!(
+            "{} needs per-query results, and {} predates them; \
+             use --metric r@1, r@5 or r@20, or re-run `cqs eval ... --save`",
+            metric.label(),
+            if a.queries.is_empty() { "A" } else { "B" }
+        );
+    };
+
+    let mut warnings = drift_warnings(a, b);
+    let older = match (a.queries.is_empty(), b.queries.is_empty()) {
+        (true, true) => "neither report has",
+        (true, false) => "A has no",
+        _ => "B has no",
+    };
+    warnings.push(format!(
+        "{older} per-query results (saved by an older cqs); compared aggregates only, \
+         without an interval or p-value"
+    ));
+
+    let by_category = a
+        .by_category
+        .iter()
+        .filter_map(|(cat, sa)| {
+            let sb = b.by_category.get(cat)?;
+            let mean_a = metric.aggregate(sa.r_at_1, sa.r_at_5, sa.r_at_20)?;
+            let mean_b = metric.aggregate(sb.r_at_1, sb.r_at_5, sb.r_at_20)?;
+            let entry = CategoryDelta {
+                n: sa.n.min(sb.n),
+                mean_a,
+                mean_b,
+                delta: mean_b - mean_a,
+                wins: 0,
+                losses: 0,
+            };
+            Some((cat.clone(), entry))
+        })
+        .collect();
+
+    Ok(CompareReport {
+        run_a: label_a,
+        run_b: label_b,
+        metric: metric.label(),
+        per_query: false,
+        paired: 0,
+        unmatched_a: a.queries.len(),
+        unmatched_b: b.queries.len(),
+        mean_a,
+        mean_b,
+        delta: mean_b - mean_a,
+        ci_low: None,
+        ci_high: None,
+        p_value: None,
+        significant: false,
+        resamples: args.resamples,
+        seed: args.seed,
+        wins: 0,
+        losses: 0,
+        ties: 0,
+        by_category,
+        winners: Vec::new(),
+        losers: Vec::new(),
+        warnings,
+    })
+}
+
+fn load_report(path: &Path) -> Result<EvalReport> {
+    let raw = std::fs::read_to_string(path)
+        .with_context(|| format!("Failed to read eval report {}", path.display()))?;
+    let report: EvalReport = serde_json::from_str(&raw).with_context(|| {
+        format!(
+            "Failed to parse {}; expected a `cqs eval --save` report",
+            path.display()
+        )
+    })?;
+    Ok(report)
+}
+
+/// CLI handler for `cqs eval compare`.
+pub(crate) fn cmd_eval_compare(json_flag: bool, args: &CompareArgs) -> Result<()> {
+    let _span = tracing::info_span!(
+        "cmd_eval_compare",
+        run_a = %args.run_a.display(),
+        run_b = %args.run_b.display(),
+        resamples = args.resamples,
+    )
+    .entered();
+    let a = load_report(&args.run_a)?;
+    let b = load_report(&args.run_b)?;
+    let report = build_compare(
+        &a,
+        &b,
+        args.run_a.display().to_string(),
+        args.run_b.display().to_string(),
+        args,
+    )?;
+    if report.per_query && report.paired == 0 {
+        anyhow::bail!("The two reports share no queries; nothing to compare");
+    }
+
+    if json_flag || args.json {
+        crate::cli::json_envelope::emit_json(&report)?;
+    } else {
+        print_compare(&report);
+    }
+    Ok(())
+}
+
+fn fmt_rank(rank: Option<usize>) -> String {
+    rank.map_or_else(|| "miss".to_string(), |r| format!("#{r}"))
+}
+
+fn print_compare(r: &CompareReport) {
+    if r.per_query {
+        println!("=== eval compare: {} (N={} paired) ===", r.metric, r.paired);
+    } else {
+        println!("=== eval compare: {} (aggregates only) ===", r.metric);
+    }
+    println!("A: {}", r.run_a);
+    println!("B: {}", r.run_b);
+    for w in &r.warnings {
+        println!("warning: {w}");
+    }
+    println!();
+    let (Some(ci_low), Some(ci_high), Some(p_value)) = (r.ci_low, r.ci_high, r.p_value) else {
+        println!(
+            "{}: A {:.3}  B {:.3}  \u{0394} {:+.3}",
+            r.metric, r.mean_a, r.mean_b, r.delta
+        );
+        println!();
+        println!(
+            "{:<24} {:>5} {:>8} {:>8} {:>8}",
+            "category", "N", "A", "B", "\u{0394}"
+        );
+        for (cat, c) in &r.by_category {
+            println!(
+                "{:<24} {:>5} {:>8.3} {:>8.3} {:>+8.3}",
+                cat, c.n, c.mean_a, c.mean_b, c.delta
+            );
+        }
+        return;
+    };
+    println!(
+        "{}: A {:.3}  B {:.3}  \u{0394} {:+.3}  95% CI [{:+.3}, {:+.3}]  p={:.4}{}",
+        r.metric,
+        r.mean_a,
+        r.mean_b,
+        r.delta,
+        ci_low,
+        ci_high,
+        p_value,
+        if r.significant {
+            "  (significant)"
+        } else {
+            "  (not significant)"
+        }
+    );
+    println!(
+        "B wins {} / loses {} / ties {} ({} resamples, seed {})",
+        r.wins, r.losses, r.ties, r.resamples, r.seed
+    );
+    println!();
+
+    println!(
+        "{:<24} {:>5} {:>8} {:>8} {:>8} {:>5} {:>6}",
+        "category", "N", "A", "B", "\u{0394}", "wins", "losses"
+    );
+    for (cat, c) in &r.by_category {
+        println!(
+            "{:<24} {:>5} {:>8.3} {:>8.3} {:>+8.3} {:>5} {:>6}",
+            cat, c.n, c.mean_a, c.mean_b, c.delta, c.wins, c.losses
+        );
+    }
+
+    for (title, rows) in [
+        ("WINNERS (B better)", &r.winners),
+        ("LOSERS (B worse)", &r.losers),
+    ] {
+        if rows.is_empty() {
+            continue;
+        }
+        println!();
+        println!("{title}:");
+        for d in rows {
+            let query: String = d.query.chars().take(60).collect();
+            println!(
+                "  {:>5} \u{2192} {:<5} {:+.3}  [{}] {}",
+                fmt_rank(d.rank_a),
+                fmt_rank(d.rank_b),
+                d.delta,
+                d.category,
+                query
+            );
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn outcome(query: &str, category: &str, rank: Option<usize>) -> QueryOutcome {
+        QueryOutcome {
+            query: query.to_string(),
+            category: category.to_string(),
+            rank,
+        }
+    }
+
+    fn report(queries: Vec<QueryOutcome>) -> EvalReport {
+        EvalReport {
+            query_count: queries.len(),
+            skipped: 0,
+            elapsed_secs: 1.0,
+            queries_per_sec: 1.0,
+            overall: super::super::runner::Overall {
+                n: queries.len(),
+                r_at_1: 0.0,
+                r_at_5: 0.0,
+                r_at_20: 0.0,
+            },
+            by_category: BTreeMap::new(),
+            weighted: None,
+            category_weights: BTreeMap::new(),
+            index_model: "m".into(),
+            cqs_version: "1".into(),
+            query_file: "q.json".into(),
+            limit: 20,
+            category_filter: None,
+            reranker: None,
+            latency: None,
+            peak_rss_bytes: None,
+            hard_negatives: None,
+            by_query_language: BTreeMap::new(),
+            translated: None,
+            calibration: None,
+            queries,
+        }
+    }
+
+    fn args(metric: CompareMetric) -> CompareArgs {
+        CompareArgs {
+            run_a: "a.json".into(),
+            run_b: "b.json".into(),
+            metric,
+            resamples: 2000,
+            seed: 7,
+            top: 10,
+            json: false,
+        }
+    }
+
+    #[test]
+    fn metric_scores() {
+        assert_eq!(CompareMetric::Mrr.score(Some(4)), 0.25);
+        assert_eq!(CompareMetric::Mrr.score(None), 0.0);
+        assert_eq!(CompareMetric::R5.score(Some(5)), 1.0);
+        assert_eq!(CompareMetric::R5.score(Some(6)), 0.0);
+        assert_eq!(CompareMetric::R1.score(Some(1)), 1.0);
+    }
+
+    #[test]
+    fn pairing_is_by_text_and_counts_leftovers() {
+        let a = vec![outcome("x", "c", Some(1)), outcome("y", "c", None)];
+        let b = vec![outcome("y", "c", Some(2)), outcome("z", "c", Some(1))];
+        let (pairs, ua, ub) = pair_queries(&a, &b);
+        assert_eq!(pairs.len(), 1);
+        assert_eq!(pairs[0].1.rank, Some(2));
+        assert_eq!((ua, ub), (1, 1));
+    }
+
+    #[test]
+    fn identical_runs_are_not_significant() {
+        let qs: Vec<_> = (0..40)
+            .map(|i| outcome(&format!("q{i}"), "c", (i % 3 != 0).then_some(i % 7 + 1)))
+            .collect();
+        let r = build_compare(
+            &report(qs.clone()),
+            &report(qs),
+            "a".into(),
+            "b".into(),
+            &args(CompareMetric::Mrr),
+        )
+        .unwrap();
+        assert_eq!(r.delta, 0.0);
+        assert_eq!((r.wins, r.losses, r.ties), (0, 0, 40));
+        assert_eq!(r.p_value, Some(1.0));
+        assert!(!r.significant);
+    }
+
+    #[test]
+    fn consistent_improvement_is_significant_and_tabled() {
+        // B rescues 30 of 40 misses into the top 5 and loses none.
+        let a: Vec<_> = (0..40)
+            .map(|i| {
+                outcome(
+                    &format!("q{i}"),
+                    if i % 2 == 0 { "even" } else { "odd" },
+                    None,
+                )
+            })
+            .collect();
+        let b: Vec<_> = (0..40)
+            .map(|i| {
+                outcome(
+                    &format!("q{i}"),
+                    if i % 2 == 0 { "even" } else { "odd" },
+                    (i < 30).then_some(2),
+                )
+            })
+            .collect();
+        let r = build_compare(
+            &report(a),
+            &report(b),
+            "a".into(),
+            "b".into(),
+            &args(CompareMetric::R5),
+        )
+        .unwrap();
+        assert!((r.delta - 0.75).abs() < 1e-12);
+        assert!(
+            r.significant,
+            "p={:?} ci=[{:?}, {:?}]",
+            r.p_value, r.ci_low, r.ci_high
+        );
+        let (ci_low, ci_high) = (r.ci_low.unwrap(), r.ci_high.unwrap());
+        assert!(ci_low > 0.5 && ci_high <= 0.9);
+        assert_eq!((r.wins, r.losses), (30, 0));
+        assert_eq!(r.winners.len(), 10);
+        assert!(r.losers.is_empty());
+        assert_eq!(r.by_category["even"].n, 20);
+    }
+
+    /// A report saved before per-query capture still loads, and compares
+    /// on its aggregates.
+    #[test]
+    fn pre_per_query_report_compares_aggregates() {
+        let tmp = tempfile::NamedTempFile::new().unwrap();
+        std::fs::write(tmp.path(), super::super::runner::PRE_PER_QUERY_REPORT).unwrap();
+        let old = load_report(tmp.path()).unwrap();
+        assert!(old.queries.is_empty());
+
+        let mut new = report(vec![outcome("q", "behavioral", Some(1))]);
+        new.overall.r_at_5 = 0.75;
+        new.by_category.insert(
+            "behavioral".into(),
+            super::super::runner::CategoryStats {
+                n: 2,
+                r_at_1: 0.5,
+                r_at_5: 0.5,
+                r_at_20: 1.0,
+            },
+        );
+        let r =
+            build_compare(&old, &new, "a".into(), "b".into(), &args(CompareMetric::R5)).unwrap();
+        assert!(!r.per_query);
+        assert_eq!((r.mean_a, r.mean_b), (0.5, 0.75));
+        assert_eq!((r.ci_low, r.p_value), (None, None));
+        assert!(!r.significant);
+        assert_eq!(r.by_category.len(), 1);
+        assert_eq!(r.by_category["behavioral"].delta, -0.5);
+        assert!(r
+            .warnings
+            .iter()
+            .any(|w| w.starts_with("A has no per-query")));
+
+        // MRR can't be rebuilt from R@K.
+        assert!(build_compare(
+            &old,
+            &new,
+            "a".into(),
+            "b".into(),
+            &args(CompareMetric::Mrr)
+        )
+        .is_err());
+    }
+
+    #[test]
+    fn seed_reproduces_interval_and_p_value() {
+        let a: Vec<_> = (0..30)
+            .map(|i| outcome(&format!("q{i}"), "c", Some(i % 4 + 1)))
+            .collect();
+        let b: Vec<_> = (0..30)
+            .map(|i| outcome(&format!("q{i}"), "c", Some(i % 5 + 1)))
+            .collect();
+        let run = || {
+            let r = build_compare(
+                &report(a.clone()),
+                &report(b.clone()),
+                "a".into(),
+                "b".into(),
+                &args(CompareMetric::Mrr),
+            )
+            .unwrap();
+            (r.ci_low, r.ci_high, r.p_value)
+        };
+        assert_eq!(run(), run());
+    }
+}
@@ -0,0 +1,501 @@
+//! `cqs eval generate` — synthetic eval queries from indexed doc comments.
+//!
+//! Every documented code chunk is a free query/gold pair: the doc comment
+//! says what the chunk does, the chunk is the answer. The comment's first
+//! sentence is rewritten into a query with the chunk's own name and other
+//! identifier-shaped words removed, so the pair measures retrieval rather
+//! than a lexical hit on the name. `--llm` asks the configured provider for
+//! a natural paraphrase instead (falling back to the heuristic per query).
+//!
+//! Sampling is per language (`--per-lang`) and deterministic — candidates
+//! are ordered by content hash — so rerunning on an unchanged index writes
+//! the same file. The output is a v3 query set (`source: "generated"`) that
+//! `cqs eval` runs directly; generated queries are cheap coverage, not a
+//! substitute for a labeled set.
+
+use std::collections::{BTreeMap, HashMap};
+use std::path::PathBuf;
+
+use anyhow::{Context as _, Result};
+use serde::Serialize;
+
+use cqs::eval::schema::{EvalQuery, GoldChunk};
+use cqs::store::{ChunkSummary, ReadOnly};
+
+use crate::cli::CommandContext;
+
+/// Chunks fetched per store page while scanning.
+const PAGE_SIZE: usize = 500;
+
+/// CLI args for `cqs eval generate`.
+#[derive(Debug, Clone, clap::Args)]
+pub(crate) struct GenerateArgs {
+    /// Eval JSON to write
+    #[arg(short = 'o', long)]
+    pub output: PathBuf,
+
+    /// Only generate queries for this language
+    #[arg(long)]
+    pub lang: Option<String>,
+
+    /// Max queries per language
+    #[arg(long, default_value = "50", value_parser = crate::cli::definitions::parse_nonzero_usize)]
+    pub per_lang: usize,
+
+    /// Drop queries shorter than this many words after identifiers are
+    /// stripped
+    #[arg(long, default_value = "4", value_parser = crate::cli::definitions::parse_nonzero_usize)]
+    pub min_words: usize,
+
+    /// Paraphrase doc comments with the configured LLM provider instead of
+    /// the heuristic rewrite
+    #[cfg(feature = "llm-summaries")]
+    #[arg(long)]
+    pub llm: bool,
+
+    /// Overwrite an existing output file
+    #[arg(long)]
+    pub force: bool,
+
+    /// Print the summary as JSON instead of text
+    #[arg(long)]
+    pub json: bool,
+}
+
+/// A documented chunk picked for the set.
+#[derive(Debug, Clone)]
+struct Candidate {
+    query: String,
+    doc: String,
+    content_hash: String,
+    gold: GoldChunk,
+}
+
+/// The output envelope. `queries` is all `cqs eval` reads.
+#[derive(Debug, Serialize)]
+struct GeneratedSet {
+    schema_version: String,
+    created: String,
+    description: String,
+    n: usize,
+    queries: Vec<EvalQuery>,
+}
+
+/// Summary printed after writing.
+#[derive(Debug, Serialize)]
+struct GenerateSummary {
+    output: String,
+    total: usize,
+    by_language: BTreeMap<String, usize>,
+    paraphrased_by_llm: usize,
+}
+
+/// Comment syntax stripped from the start of a doc line, longest first.
+const LEADING_MARKERS: &[&str] = &[
+    "/**", "/*!", "///", "//!", "/*", "//", "\"\"\"", "'''", "--", ";;", "#", "*",
+];
+
+/// Strip comment markers from one doc line.
+fn strip_markers(line: &str) -> &str {
+    let mut line = line.trim();
+    for suffix in ["*/", "\"\"\"", "'''"] {
+        line = line.strip_suffix(suffix).unwrap_or(line).trim_end();
+    }
+    if let Some(m) = LEADING_MARKERS.iter().find(|m| line.starts_with(**m)) {
+        line = &line[m.len()..];
+    }
+    line.trim()
+}
+
+/// Whether a word looks like code rather than prose: `snake_case`,
+/// `camelCase`, paths, calls.
+fn is_identifier_like(word: &str) -> bool {
+    let has_inner_upper = word
+        .char_indices()
+        .skip(1)
+        .any(|(i, c)| c.is_uppercase() && word[..i].chars().any(char::is_lowercase));
+    word.contains('_')
+        || word.contains("::")
+        || word.contains('(')
+        || word.contains('.')
+        || word.contains('/')
+        || has_inner_upper
+}
+
+/// Rewrite a raw doc comment as a query: first sentence of the first
+/// paragraph, code spans and identifier-like words removed, the chunk's own
+/// name removed. `None` when fewer than `min_words` words survive.
+fn doc_to_query(doc: &str, name: &str, min_words: usize) -> Option<String> {
+    let mut paragraph = Vec::new();
+    for line in doc.lines().map(strip_markers) {
+        if line.is_empty() {
+            if paragraph.is_empty() {
+                continue;
+            }
+            break;
+        }
+        // Section headers and tag blocks end the summary paragraph.
+        if line.starts_with('#') || line.starts_with('@') || line.starts_with(':') {
+            break;
+        }
+        paragraph.push(line);
+    }
+    let text = paragraph.join(" ");
+
+    let sentence = text
+        .match_indices(['.', '?', '!'])
+        .find(|(i, _)| text[i + 1..].starts_with(' ') || i + 1 == text.len())
+        .map_or(text.as_str(), |(i, _)| &text[..i]);
+
+    // Drop `code spans` wholesale.
+    let mut prose = String::with_capacity(sentence.len());
+    let mut in_code = false;
+    for c in sentence.chars() {
+        if c == '`' {
+            in_code = !in_code;
+            prose.push(' ');
+        } else if !in_code {
+            prose.push(c);
+        }
+    }
+
+    let words: Vec<&str> = prose
+        .split_whitespace()
+        .map(|w| w.trim_matches(|c: char| matches!(c, ',' | ';' | ':' | '"' | '\'' | '[' | ']')))
+        .filter(|w| !w.is_empty())
+        .filter(|w| !w.eq_ignore_ascii_case(name) && !is_identifier_like(w))
+        .collect();
+    if words.len() < min_words {
+        return None;
+    }
+
+    let mut query = words.join(" ");
+    // Lowercase a sentence-initial capital, but not an acronym ("HTTP").
+    let mut chars = query.chars();
+    if let (Some(first), Some(second)) = (chars.next(), chars.next()) {
+        if first.is_uppercase() && !second.is_uppercase() {
+            query = first.to_lowercase().chain(query.chars().skip(1)).collect();
+        }
+    }
+    Some(query)
+}
+
+fn candidate_of(c: &ChunkSummary, min_words: usize) -> Option<Candidate> {
+    if !c.chunk_type.is_code() || c.window_idx.is_some_and(|i| i > 0) {
+        return None;
+    }
+    let doc = c.doc.as_deref()?;
+    let query = doc_to_query(doc, &c.name, min_words)?;
+    Some(Candidate {
+        query,
+        doc: doc.to_string(),
+        content_hash: c.content_hash.clone(),
+        gold: GoldChunk {
+            name: c.name.clone(),
+            origin: cqs::normalize_path(&c.file),
+            line_start: c.line_start,
+            id: Some(c.id.clone()),
+            line_end: Some(c.line_end),
+            chunk_type: Some(c.chunk_type.to_string()),
+            language: Some(c.language.to_string()),
+        },
+    })
+}
+
+/// Drop queries that more than one chunk produced — a copy-pasted doc
+/// comment has no single right answer — then keep the first `per_lang` of
+/// each language in content-hash order.
+fn select(candidates: Vec<Candidate>, per_lang: usize) -> BTreeMap<String, Vec<Candidate>> {
+    let mut seen: HashMap<String, usize> = HashMap::new();
+    for c in &candidates {
+        *seen.entry(c.query.to_lowercase()).or_default() += 1;
+    }
+    let mut by_lang: BTreeMap<String, Vec<Candidate>> = BTreeMap::new();
+    for c in candidates {
+        if seen[&c.query.to_lowercase()] > 1 {
+            continue;
+        }
+        let lang = c.gold.language.clone().unwrap_or_default();
+        by_lang.entry(lang).or_default().push(c);
+    }
+    for picks in by_lang.values_mut() {
+        picks.sort_by(|a, b| a.content_hash.cmp(&b.content_hash));
+        picks.truncate(per_lang);
+    }
+    by_lang
+}
+
+fn to_eval_query(c: Candidate, paraphrase: &str) -> EvalQuery {
+    let category = cqs::search::router::classify_query(&c.query)
+        .category
+        .to_string();
+    let language = c.gold.language.clone();
+    EvalQuery {
+        query: c.query,
+        category: Some(category),
+        source: Some("generated".to_string()),
+        judges: None,
+        metadata: Some(serde_json::json!({
+            "paraphrase": paraphrase,
+            "doc": c.doc,
+        })),
+        pool_size: None,
+        tier: None,
+        gold_chunk_source: Some("doc_comment".to_string()),
+        tags: language.into_iter().collect(),
+        unresolved: false,
+        hard_negatives: Vec::new(),
+        query_language: None,
+        gold_chunk: Some(c.gold),
+    }
+}
+
+/// Replace heuristic queries with provider paraphrases where one came back.
+/// A paraphrase that repeats the chunk name is discarded — it would turn
+/// the query back into a name lookup. Pairs each pick with how its query
+/// was made.
+#[cfg(feature = "llm-summaries")]
+fn paraphrase_with_llm(
+    ctx: &CommandContext<'_, ReadOnly>,
+    picks: Vec<Candidate>,
+) -> Result<Vec<(Candidate, &'static str)>> {
+    let items: Vec<cqs::llm::ParaphraseItem> = picks
+        .iter()
+        .map(|c| cqs::llm::ParaphraseItem {
+            id: c.gold.id.clone().unwrap_or_default(),
+            doc: c.doc.clone(),
+            name: c.gold.name.clone(),
+            language: c.gold.language.clone().unwrap_or_default(),
+            origin: c.gold.origin.clone().into(),
+        })
+        .collect();
+    let config = cqs::config::Config::load(&ctx.root);
+    let mut results = cqs::llm::paraphrase_doc_queries(&config, &items, ctx.cli.quiet)?;
+    Ok(picks
+        .into_iter()
+        .map(|mut c| {
+            let reply = c.gold.id.as_deref().and_then(|id| results.remove(id));
+            match reply {
+                Some(q) if !q.to_lowercase().contains(&c.gold.name.to_lowercase()) => {
+                    c.query = q;
+                    (c, "llm")
+                }
+                _ => (c, "heuristic"),
+            }
+        })
+        .collect())
+}
+
+/// CLI handler for `cqs eval generate`.
+pub(crate) fn cmd_eval_generate(
+    ctx: &CommandContext<'_, ReadOnly>,
+    args: &GenerateArgs,
+) -> Result<()> {
+    let _span = tracing::info_span!(
+        "cmd_eval_generate",
+        output = %args.output.display(),
+        lang = ?args.lang,
+        per_lang = args.per_lang,
+    )
+    .entered();
+    let json = ctx.cli.json || args.json;
+
+    match args.output.extension().and_then(|e| e.to_str()) {
+        Some(e) if e.eq_ignore_ascii_case("json") => {}
+        _ => anyhow::bail!("--output must end in .json; eval sets are JSON-only"),
+    }
+    if args.output.exists() && !args.force {
+        anyhow::bail!(
+            "{} already exists; pass --force to overwrite",
+            args.output.display()
+        );
+    }
+    let lang_filter = args
+        .lang
+        .as_deref()
+        .map(|l| {
+            l.parse::<cqs::parser::Language>()
+                .map_err(|_| anyhow::anyhow!("Unknown language: {l}"))
+        })
+        .transpose()?;
+
+    let mut candidates = Vec::new();
+    let mut cursor = 0i64;
+    loop {
+        let (chunks, next) = ctx.store.chunks_paged(cursor, PAGE_SIZE)?;
+        if chunks.is_empty() {
+            break;
+        }
+        cursor = next;
+        candidates.extend(
+            chunks
+                .iter()
+                .filter(|c| lang_filter.is_none_or(|l| c.language == l))
+                .filter_map(|c| candidate_of(c, args.min_words)),
+        );
+    }
+    let scanned = candidates.len();
+    let picks: Vec<Candidate> = select(candidates, args.per_lang)
+        .into_values()
+        .flatten()
+        .collect();
+    if picks.is_empty() {
+        anyhow::bail!("No documented chunks produced a usable query; is the index empty?");
+    }
+    tracing::info!(
+        scanned,
+        picked = picks.len(),
+        "Doc-comment candidates selected"
+    );
+
+    let heuristic = |picks: Vec<Candidate>| -> Vec<(Candidate, &'static str)> {
+        picks.into_iter().map(|c| (c, "heuristic")).collect()
+    };
+    #[cfg(feature = "llm-summaries")]
+    let made = if args.llm {
+        paraphrase_with_llm(ctx, picks)?
+    } else {
+        heuristic(picks)
+    };
+    #[cfg(not(feature = "llm-summaries"))]
+    let made = heuristic(picks);
+
+    let mut by_language: BTreeMap<String, usize> = BTreeMap::new();
+    for (c, _) in &made {
+        *by_language
+            .entry(c.gold.language.clone().unwrap_or_default())
+            .or_default() += 1;
+    }
+    let paraphrased_by_llm = made.iter().filter(|(_, m)| *m == "llm").count();
+    let queries: Vec<EvalQuery> = made.into_iter().map(|(c, m)| to_eval_query(c, m)).collect();
+
+    let set = GeneratedSet {
+        schema_version: "generated".to_string(),
+        created: chrono::Utc::now().format("%Y-%m-%d").to_string(),
+        description: format!(
+            "Queries generated from doc comments with `cqs eval generate` (up to {} per language)",
+            args.per_lang
+        ),
+        n: queries.len(),
+        queries,
+    };
+    let bytes = serde_json::to_vec_pretty(&set).context("Failed to serialize eval set")?;
+    std::fs::write(&args.output, bytes)
+        .with_context(|| format!("Failed to write {}", args.output.display()))?;
+
+    let summary = GenerateSummary {
+        output: cqs::normalize_path(&args.output),
+        total: set.n,
+        by_language,
+        paraphrased_by_llm,
+    };
+    if json {
+        crate::cli::json_envelope::emit_json(&summary)?;
+    } else {
+        println!("Wrote {} queries to {}", summary.total, summary.output);
+        for (lang, n) in &summary.by_language {
+            println!("  {lang:<12} {n}");
+        }
+        if summary.paraphrased_by_llm > 0 {
+            println!("  ({} paraphrased by LLM)", summary.paraphrased_by_llm);
+        }
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn candidate(query: &str, lang: &str, hash: &str) -> Candidate {
+        Candidate {
+            query: query.to_string(),
+            doc: String::new(),
+            content_hash: hash.to_string(),
+            gold: GoldChunk {
+                name: format!("f_{hash}"),
+                origin: "src/a.rs".to_string(),
+                line_start: 1,
+                id: Some(hash.to_string()),
+                line_end: Some(2),
+                chunk_type: Some("function".to_string()),
+                language: Some(lang.to_string()),
+            },
+        }
+    }
+
+    #[test]
+    fn doc_to_query_takes_first_sentence_and_strips_markers() {
+        let doc = "/// Retry the request with exponential backoff. Gives up after\n/// five attempts.\n///\n/// # Errors\n/// Returns the last error.";
+        assert_eq!(
+            doc_to_query(doc, "retry_with_backoff", 4).as_deref(),
+            Some("retry the request with exponential backoff")
+        );
+    }
+
+    #[test]
+    fn doc_to_query_drops_own_name_and_identifiers() {
+        let doc = "// ParseConfig reads the `cqs.toml` file and fills Config_Defaults\n// for missing keys.";
+        assert_eq!(
+            doc_to_query(doc, "ParseConfig", 3).as_deref(),
+            Some("reads the file and fills for missing keys")
+        );
+        let py = "\"\"\"Open an HTTP session via requests.Session.\"\"\"";
+        assert_eq!(
+            doc_to_query(py, "open_session", 3).as_deref(),
+            Some("open an HTTP session via")
+        );
+    }
+
+    #[test]
+    fn doc_to_query_keeps_acronyms_and_rejects_short_docs() {
+        assert_eq!(
+            doc_to_query(
+                "/** HTTP client with pooled connections */",
+                "HttpClient",
+                3
+            )
+            .as_deref(),
+            Some("HTTP client with pooled connections")
+        );
+        assert_eq!(doc_to_query("/// Constructor.", "new", 2), None);
+        assert_eq!(doc_to_query("/// `new` `Foo`", "new", 1), None);
+    }
+
+    #[test]
+    fn select_drops_ambiguous_queries_and_caps_per_language() {
+        let picks = select(
+            vec![
+                candidate("same doc", "rust", "c"),
+                candidate("Same doc", "rust", "d"),
+                candidate("query three", "rust", "b"),
+                candidate("query four", "rust", "a"),
+                candidate("query five", "python", "e"),
+            ],
+            1,
+        );
+        assert_eq!(picks.len(), 2);
+        assert_eq!(picks["rust"][0].query, "query four");
+        assert_eq!(picks["python"][0].query, "query five");
+    }
+
+    #[test]
+    fn generated_set_loads_as_v3_query_set() {
+        let set = GeneratedSet {
+            schema_version: "generated".into(),
+            created: "2026-01-01".into(),
+            description: String::new(),
+            n: 1,
+            queries: vec![to_eval_query(
+                candidate("parse a config file", "rust", "a"),
+                "heuristic",
+            )],
+        };
+        let json = serde_json::to_string(&set).unwrap();
+        let v3: cqs::eval::schema::QuerySet = serde_json::from_str(&json).unwrap();
+        let q = &v3.queries[0];
+        assert_eq!(q.source.as_deref(), Some("generated"));
+        assert_eq!(q.gold_chunk.as_ref().unwrap().name, "f_a");
+        assert_eq!(q.tags, vec!["rust".to_string()]);
+    }
+}
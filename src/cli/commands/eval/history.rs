@@ -0,0 +1,195 @@
+//! `cqs eval history` — recorded eval runs, and the recorder `cqs eval`
+//! calls after every run.
+//!
+//! Rows live in `.cqs/eval_history.db` ([`cqs::eval::history`]). Each row
+//! pairs a run's configuration (query file, model, reranker, limit) with its
+//! R@K and its cost (P50/P95 latency, peak RSS).
+
+use std::path::PathBuf;
+
+use anyhow::Result;
+
+use cqs::eval::history::{EvalHistory, EvalRun, EvalRunRow, EVAL_HISTORY_FILENAME};
+use cqs::store::ReadOnly;
+
+use crate::cli::CommandContext;
+
+use super::runner::EvalReport;
+
+/// CLI args for `cqs eval history`.
+#[derive(Debug, Clone, clap::Args)]
+pub(crate) struct HistoryArgs {
+    /// Only runs of this query file (as it was passed to `cqs eval`)
+    pub query_file: Option<PathBuf>,
+
+    /// Number of runs to show, newest first
+    #[arg(short = 'n', long, default_value = "20", value_parser = crate::cli::definitions::parse_nonzero_usize)]
+    pub limit: usize,
+
+    /// Output as JSON instead of text
+    #[arg(long)]
+    pub json: bool,
+}
+
+fn history_path(ctx: &CommandContext<'_, ReadOnly>) -> PathBuf {
+    ctx.cqs_dir.join(EVAL_HISTORY_FILENAME)
+}
+
+fn run_of(report: &EvalReport, reranker: String) -> EvalRun {
+    EvalRun {
+        query_file: report.query_file.clone(),
+        category: report.category_filter.clone(),
+        limit: report.limit,
+        reranker,
+        index_model: report.index_model.clone(),
+        cqs_version: report.cqs_version.clone(),
+        n: report.overall.n,
+        r_at_1: report.overall.r_at_1,
+        r_at_5: report.overall.r_at_5,
+        r_at_20: report.overall.r_at_20,
+        elapsed_secs: report.elapsed_secs,
+        latency_p50_ms: report.latency.map(|l| l.p50_ms),
+        latency_p95_ms: report.latency.map(|l| l.p95_ms),
+        peak_rss_bytes: report.peak_rss_bytes,
+    }
+}
+
+/// Append `report` to the history. Best-effort: a history failure is logged
+/// and never fails the eval itself.
+pub(super) fn record_run(
+    ctx: &CommandContext<'_, ReadOnly>,
+    report: &EvalReport,
+    reranker: String,
+) {
+    if report.overall.n == 0 {
+        return; // nothing scored — not a measurement worth keeping
+    }
+    let path = history_path(ctx);
+    match EvalHistory::open(&path).and_then(|h| h.record(&run_of(report, reranker))) {
+        Ok(id) => tracing::debug!(id, path = %path.display(), "eval run recorded"),
+        Err(e) => {
+            tracing::warn!(error = %e, path = %path.display(), "Failed to record eval run")
+        }
+    }
+}
+
+/// CLI handler for `cqs eval history`.
+pub(crate) fn cmd_eval_history(
+    ctx: &CommandContext<'_, ReadOnly>,
+    args: &HistoryArgs,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_eval_history", limit = args.limit).entered();
+    let json = ctx.cli.json || args.json;
+    let path = history_path(ctx);
+    let query_file = args.query_file.as_ref().map(|p| p.display().to_string());
+
+    let rows = if path.exists() {
+        EvalHistory::open(&path)?.recent(query_file.as_deref(), args.limit)?
+    } else {
+        Vec::new()
+    };
+
+    if json {
+        crate::cli::json_envelope::emit_json(&rows)?;
+        return Ok(());
+    }
+    if rows.is_empty() {
+        println!("No eval runs recorded yet (run `cqs eval <queries.json>`).");
+        return Ok(());
+    }
+    for line in format_rows(&rows) {
+        println!("{line}");
+    }
+    Ok(())
+}
+
+/// Text table, one line per run plus a header.
+fn format_rows(rows: &[EvalRunRow]) -> Vec<String> {
+    let ms = |v: Option<f64>| v.map_or_else(|| "-".to_string(), |v| format!("{v:.1}"));
+    let mut out = vec![format!(
+        "{:>5}  {:<16}  {:<24}  {:<6}  {:>4}  {:>6}  {:>6}  {:>6}  {:>8}  {:>8}  {:>8}",
+        "id",
+        "recorded",
+        "model",
+        "rerank",
+        "N",
+        "R@1",
+        "R@5",
+        "R@20",
+        "p50 ms",
+        "p95 ms",
+        "RSS MiB"
+    )];
+    for row in rows {
+        let r = &row.run;
+        let recorded = chrono::DateTime::from_timestamp(row.recorded_at, 0)
+            .map(|t| t.format("%Y-%m-%d %H:%M").to_string())
+            .unwrap_or_else(|| row.recorded_at.to_string());
+        let model: String = r.index_model.chars().take(24).collect();
+        out.push(format!(
+            "{:>5}  {:<16}  {:<24}  {:<6}  {:>4}  {:>5.1}%  {:>5.1}%  {:>5.1}%  {:>8}  {:>8}  {:>8}",
+            row.id,
+            recorded,
+            model,
+            r.reranker,
+            r.n,
+            r.r_at_1 * 100.0,
+            r.r_at_5 * 100.0,
+            r.r_at_20 * 100.0,
+            ms(r.latency_p50_ms),
+            ms(r.latency_p95_ms),
+            r.peak_rss_bytes
+                .map_or_else(|| "-".to_string(), |b| (b / (1024 * 1024)).to_string()),
+        ));
+    }
+    out
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn format_rows_renders_cost_columns_and_dashes() {
+        let run = EvalRun {
+            query_file: "q.json".into(),
+            category: None,
+            limit: 20,
+            reranker: "none".into(),
+            index_model: "bge-large".into(),
+            cqs_version: "1.0.0".into(),
+            n: 100,
+            r_at_1: 0.472,
+            r_at_5: 0.707,
+            r_at_20: 0.867,
+            elapsed_secs: 12.0,
+            latency_p50_ms: Some(21.0),
+            latency_p95_ms: Some(48.5),
+            peak_rss_bytes: Some(2048 * 1024 * 1024),
+        };
+        let mut old = run.clone();
+        old.latency_p50_ms = None;
+        old.latency_p95_ms = None;
+        old.peak_rss_bytes = None;
+        let rows = [
+            EvalRunRow {
+                id: 2,
+                recorded_at: 1_767_225_600, // 2026-01-01 00:00 UTC
+                run,
+            },
+            EvalRunRow {
+                id: 1,
+                recorded_at: 1_767_225_600,
+                run: old,
+            },
+        ];
+        let lines = format_rows(&rows);
+        assert_eq!(lines.len(), 3);
+        assert!(lines[0].contains("p95 ms"));
+        assert!(lines[1].contains("2026-01-01 00:00"));
+        assert!(lines[1].contains(" 47.2%"));
+        assert!(lines[1].contains("48.5"));
+        assert!(lines[1].contains("2048"));
+        assert!(lines[2].trim_end().ends_with('-'));
+    }
+}
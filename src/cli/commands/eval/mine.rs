@@ -0,0 +1,274 @@
+//! `cqs eval mine-negatives` — append mined hard negatives to an eval set.
+//!
+//! For each query with a gold chunk, [`cqs::eval::hard_negatives`] finds
+//! indexed chunks that share the gold's identifiers but embed away from it
+//! and appends them to the query's `hard_negatives` list. `cqs eval` then
+//! reports how often one of them outranks the gold.
+//!
+//! The file is edited as JSON rather than round-tripped through the typed
+//! schema, so envelope fields and v2 extras (`primary_answer`,
+//! `negative_examples`, …) survive untouched.
+
+use std::collections::HashSet;
+use std::path::PathBuf;
+
+use anyhow::{Context as _, Result};
+use serde::Deserialize;
+
+use cqs::eval::hard_negatives::{self, MineOptions};
+use cqs::eval::schema::{EvalQuery, HardNegative};
+use cqs::store::ReadOnly;
+
+use crate::cli::CommandContext;
+
+/// CLI args for `cqs eval mine-negatives`.
+#[derive(Debug, Clone, clap::Args)]
+pub(crate) struct MineArgs {
+    /// Eval set to mine for (v3, or v2 with `gold_chunk`)
+    pub query_file: PathBuf,
+
+    /// Write here instead of updating the query file in place
+    #[arg(short = 'o', long)]
+    pub output: Option<PathBuf>,
+
+    /// Hard negatives to add per query
+    #[arg(short = 'n', long, default_value_t = hard_negatives::DEFAULT_PER_QUERY, value_parser = crate::cli::definitions::parse_nonzero_usize)]
+    pub per_query: usize,
+
+    /// Minimum identifier-token overlap with the gold's source (0-1)
+    #[arg(long, default_value_t = hard_negatives::DEFAULT_MIN_OVERLAP)]
+    pub min_overlap: f32,
+
+    /// Maximum embedding similarity to the gold; closer candidates are
+    /// treated as possible right answers, not distractors
+    #[arg(long, default_value_t = hard_negatives::DEFAULT_MAX_SIMILARITY)]
+    pub max_similarity: f32,
+
+    /// Drop existing hard negatives instead of adding to them
+    #[arg(long)]
+    pub replace: bool,
+
+    /// Print the summary as JSON instead of text
+    #[arg(long)]
+    pub json: bool,
+}
+
+/// Summary printed after writing.
+#[derive(Debug, Default, serde::Serialize)]
+struct MineSummary {
+    output: String,
+    queries: usize,
+    added: usize,
+    /// Gold label no longer matches an indexed chunk
+    stale_gold: usize,
+    /// Nothing passed both the lexical and the semantic cut
+    no_candidates: usize,
+}
+
+/// A `{name, file}` reference in the v2 format.
+#[derive(Deserialize)]
+struct V2Answer {
+    name: String,
+    file: String,
+}
+
+/// `(origin, name)` pairs a query already treats as right or wrong answers,
+/// read from v2 fields when present.
+fn already_labeled(raw: &serde_json::Value) -> HashSet<(String, String)> {
+    let mut out = HashSet::new();
+    if let Some(a) = raw
+        .get("primary_answer")
+        .and_then(|v| V2Answer::deserialize(v).ok())
+    {
+        out.insert((a.file, a.name));
+    }
+    for field in ["acceptable_answers", "negative_examples"] {
+        if let Some(list) = raw
+            .get(field)
+            .and_then(|v| Vec::<V2Answer>::deserialize(v).ok())
+        {
+            out.extend(list.into_iter().map(|a| (a.file, a.name)));
+        }
+    }
+    out
+}
+
+/// `existing` plus `mined` entries not already listed, keyed on
+/// `(origin, name)`.
+fn merge(existing: Vec<HardNegative>, mined: Vec<HardNegative>) -> (Vec<HardNegative>, usize) {
+    let mut seen: HashSet<(String, String)> = existing
+        .iter()
+        .map(|h| (h.origin.clone(), h.name.clone()))
+        .collect();
+    let mut out = existing;
+    let mut added = 0;
+    for h in mined {
+        if seen.insert((h.origin.clone(), h.name.clone())) {
+            out.push(h);
+            added += 1;
+        }
+    }
+    (out, added)
+}
+
+/// CLI handler for `cqs eval mine-negatives`.
+pub(crate) fn cmd_eval_mine(ctx: &CommandContext<'_, ReadOnly>, args: &MineArgs) -> Result<()> {
+    let _span = tracing::info_span!(
+        "cmd_eval_mine",
+        query_file = %args.query_file.display(),
+        per_query = args.per_query,
+    )
+    .entered();
+    let json = ctx.cli.json || args.json;
+
+    if !(0.0..=1.0).contains(&args.min_overlap) {
+        anyhow::bail!(
+            "--min-overlap must be between 0 and 1, got {}",
+            args.min_overlap
+        );
+    }
+    if !(-1.0..=1.0).contains(&args.max_similarity) {
+        anyhow::bail!(
+            "--max-similarity must be between -1 and 1, got {}",
+            args.max_similarity
+        );
+    }
+    let output = args
+        .output
+        .clone()
+        .unwrap_or_else(|| args.query_file.clone());
+    match output.extension().and_then(|e| e.to_str()) {
+        Some(e) if e.eq_ignore_ascii_case("json") => {}
+        _ => anyhow::bail!("--output must end in .json; eval sets are JSON-only"),
+    }
+
+    let raw = std::fs::read_to_string(&args.query_file)
+        .with_context(|| format!("Failed to read query file: {}", args.query_file.display()))?;
+    let mut doc: serde_json::Value = serde_json::from_str(&raw)
+        .with_context(|| format!("Failed to parse query JSON: {}", args.query_file.display()))?;
+    let queries = doc
+        .get_mut("queries")
+        .and_then(|v| v.as_array_mut())
+        .with_context(|| format!("{} has no `queries` array", args.query_file.display()))?;
+
+    let opts = MineOptions {
+        per_query: args.per_query,
+        min_overlap: args.min_overlap,
+        max_similarity: args.max_similarity,
+    };
+    let mut summary = MineSummary::default();
+    for raw_q in queries.iter_mut() {
+        let q = EvalQuery::deserialize(&*raw_q).context("Query row does not match the schema")?;
+        let Some(gold) = &q.gold_chunk else {
+            continue;
+        };
+        summary.queries += 1;
+        let Some(gold_chunk) = hard_negatives::resolve_gold(&ctx.store, gold)? else {
+            tracing::debug!(name = %gold.name, origin = %gold.origin, "Gold not in index");
+            summary.stale_gold += 1;
+            continue;
+        };
+
+        let mut exclude = already_labeled(raw_q);
+        let existing = if args.replace {
+            Vec::new()
+        } else {
+            q.hard_negatives.clone()
+        };
+        exclude.extend(existing.iter().map(|h| (h.origin.clone(), h.name.clone())));
+
+        let mined = hard_negatives::mine_hard_negatives(
+            &ctx.store,
+            &q.query,
+            &gold_chunk,
+            &exclude,
+            &opts,
+        )?;
+        if mined.is_empty() {
+            summary.no_candidates += 1;
+        }
+        let (merged, added) = merge(existing, mined);
+        summary.added += added;
+
+        let obj = raw_q
+            .as_object_mut()
+            .context("Query row is not a JSON object")?;
+        if merged.is_empty() {
+            obj.remove("hard_negatives");
+        } else {
+            obj.insert(
+                "hard_negatives".to_string(),
+                serde_json::to_value(&merged).context("Failed to serialize hard negatives")?,
+            );
+        }
+    }
+
+    let bytes = serde_json::to_vec_pretty(&doc).context("Failed to serialize eval set")?;
+    let tmp = output.with_extension("json.tmp");
+    std::fs::write(&tmp, &bytes).with_context(|| format!("Failed to write {}", tmp.display()))?;
+    std::fs::rename(&tmp, &output)
+        .with_context(|| format!("Failed to write {}", output.display()))?;
+
+    summary.output = cqs::normalize_path(&output);
+    if json {
+        crate::cli::json_envelope::emit_json(&summary)?;
+    } else {
+        println!(
+            "Added {} hard negatives across {} queries to {}",
+            summary.added, summary.queries, summary.output
+        );
+        if summary.stale_gold > 0 {
+            println!(
+                "  {} queries skipped: gold chunk not in the index",
+                summary.stale_gold
+            );
+        }
+        if summary.no_candidates > 0 {
+            println!(
+                "  {} queries had no candidate past --min-overlap / --max-similarity",
+                summary.no_candidates
+            );
+        }
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn hn(origin: &str, name: &str) -> HardNegative {
+        HardNegative {
+            name: name.into(),
+            origin: origin.into(),
+            line_start: 1,
+            lexical_overlap: None,
+            similarity: None,
+        }
+    }
+
+    #[test]
+    fn already_labeled_reads_v2_answers() {
+        let raw = serde_json::json!({
+            "query": "q",
+            "primary_answer": {"name": "a", "file": "x.rs"},
+            "acceptable_answers": [{"name": "b", "file": "x.rs"}],
+            "negative_examples": [{"name": "c", "file": "y.rs"}],
+        });
+        let got = already_labeled(&raw);
+        assert_eq!(got.len(), 3);
+        assert!(got.contains(&("y.rs".to_string(), "c".to_string())));
+        assert!(already_labeled(&serde_json::json!({"query": "q"})).is_empty());
+    }
+
+    #[test]
+    fn merge_appends_only_new_entries() {
+        let (merged, added) = merge(
+            vec![hn("a.go", "HeapSort")],
+            vec![hn("a.go", "HeapSort"), hn("a.go", "QuickSort")],
+        );
+        assert_eq!(added, 1);
+        let names: Vec<&str> = merged.iter().map(|h| h.name.as_str()).collect();
+        assert_eq!(names, vec!["HeapSort", "QuickSort"]);
+    }
+}
@@ -8,8 +8,23 @@
 //!   `cqs eval evals/queries/v3_test.json --json` — machine-readable
 //!   `cqs eval evals/queries/v3_test.json --save baseline.json` — capture
 //!   `cqs eval evals/queries/v3_test.json --baseline baseline.json` — diff
+//!   `cqs eval evals/queries/v3_test.json --calibrate` — fit search confidence
+//!   `cqs eval author --queries q.txt` — label search results into a new set
+//!   `cqs eval history` — past runs (R@K, P50/P95 latency, peak RSS)
+//!   `cqs eval compare a.json b.json` — paired A/B with significance
+//!   `cqs eval generate -o gen.json` — synthetic queries from doc comments
+//!   `cqs eval mine-negatives q.json` — add hard negatives to a set
+//!
+//! `cqs bench` (in [`bench`]) shares the latency and RSS helpers: it times
+//! seeded search / embed / index workloads instead of scoring quality.
 
+mod author;
 mod baseline;
+mod bench;
+mod compare;
+mod generate;
+mod history;
+mod mine;
 mod runner;
 
 use std::path::PathBuf;
@@ -22,6 +37,7 @@ use crate::cli::args::RerankerMode;
 use crate::cli::commands::{daemon_control_hint, DaemonHint};
 use crate::cli::CommandContext;
 
+pub(crate) use bench::{cmd_bench, BenchCommand};
 pub(crate) use runner::EvalReport;
 
 /// CLI args for `cqs eval`.
@@ -31,9 +47,14 @@ pub(crate) use runner::EvalReport;
 /// is CLI-only by design (long-running, progress to stderr, file I/O).
 /// Adding a batch handler later is a one-line move into `args.rs`.
 #[derive(Debug, Clone, clap::Args)]
+#[command(args_conflicts_with_subcommands = true, subcommand_negates_reqs = true)]
 pub(crate) struct EvalCmdArgs {
+    #[command(subcommand)]
+    pub action: Option<EvalCommand>,
+
     /// Path to the queries JSON file (v3 schema)
-    pub query_file: PathBuf,
+    #[arg(required = true)]
+    pub query_file: Option<PathBuf>,
 
     /// Output as JSON instead of text
     #[arg(long)]
@@ -96,7 +117,9 @@ pub(crate) struct EvalCmdArgs {
 
     /// Apply a reranker stage after retrieval. Default `none` skips
     /// reranking. `onnx` runs the cross-encoder configured by `[reranker]` /
-    /// `CQS_RERANKER_MODEL`.
+    /// `CQS_RERANKER_MODEL`. `late` rescores the top `[late_interaction]
+    /// candidates` by MaxSim over stored token vectors; compare it with an
+    /// `onnx` run on the same query set.
     ///
     /// When `onnx` or `llm` is selected, stage 1 over-retrieves to the
     /// `rerank_pool_size(limit)` cap (mirrors `cqs <q> --rerank`); stage 2
@@ -106,13 +129,75 @@ pub(crate) struct EvalCmdArgs {
     /// scorer (R@K loss).
     #[arg(long = "reranker", value_enum, default_value_t = RerankerMode::None)]
     pub reranker: RerankerMode,
+
+    /// With `--baseline`, also fail when P95 query latency or peak RSS grew
+    /// by more than this many percent. Off by default: wall-clock numbers
+    /// only compare between runs on the same machine.
+    #[arg(long)]
+    pub perf_tolerance: Option<f64>,
+
+    /// Don't append this run to `.cqs/eval_history.db`
+    #[arg(long)]
+    pub no_history: bool,
+
+    /// Search queries that don't read as English with an LLM translation,
+    /// as `llm_query_translate = "auto"` does for `cqs search`
+    ///
+    /// Compare against a run without it to see what translation buys on a
+    /// non-English query set (`query_language` on each query).
+    #[arg(long)]
+    pub translate: bool,
+
+    /// Fit a confidence curve to this run's result scores and store it for
+    /// the index's model in `.cqs/calibration.json`
+    ///
+    /// Search JSON then reports a `confidence` per result: how often, on
+    /// this query set, a result at that score was the gold chunk. A plain
+    /// run calibrates the default ranking; a `--reranker onnx` or
+    /// `--reranker late` run calibrates searches with that reranker.
+    #[arg(long)]
+    pub calibrate: bool,
+}
+
+/// Eval tooling beyond scoring a query set.
+#[derive(Debug, Clone, clap::Subcommand)]
+pub(crate) enum EvalCommand {
+    /// Build an eval set by labeling search results for a list of queries
+    Author(author::AuthorArgs),
+    /// List recorded eval runs: quality, latency, and peak RSS per run
+    History(history::HistoryArgs),
+    /// Compare two saved reports per query, with bootstrap / randomization
+    /// significance
+    Compare(compare::CompareArgs),
+    /// Generate a query set from indexed doc comments (query = paraphrased
+    /// comment, gold = the documented chunk)
+    Generate(generate::GenerateArgs),
+    /// Append hard negatives (lexically close, semantically different
+    /// chunks) to each query of an eval set
+    MineNegatives(mine::MineArgs),
 }
 
 /// CLI handler for `cqs eval`.
 pub(crate) fn cmd_eval(ctx: &CommandContext<'_, ReadOnly>, args: &EvalCmdArgs) -> Result<()> {
+    if let Some(action) = &args.action {
+        return match action {
+            EvalCommand::Author(a) => author::cmd_eval_author(ctx, a),
+            EvalCommand::History(a) => history::cmd_eval_history(ctx, a),
+            EvalCommand::Compare(a) => compare::cmd_eval_compare(ctx.cli.json, a),
+            EvalCommand::Generate(a) => generate::cmd_eval_generate(ctx, a),
+            EvalCommand::MineNegatives(a) => mine::cmd_eval_mine(ctx, a),
+        };
+    }
+    // clap enforces this when no subcommand is given; guard the invariant
+    // for callers that build `EvalCmdArgs` directly.
+    let query_file = args
+        .query_file
+        .as_deref()
+        .context("cqs eval needs a query file")?;
+
     let _span = tracing::info_span!(
         "cmd_eval",
-        query_file = %args.query_file.display(),
+        query_file = %query_file.display(),
         category = ?args.category,
         limit = args.limit,
     )
@@ -133,6 +218,11 @@ pub(crate) fn cmd_eval(ctx: &CommandContext<'_, ReadOnly>, args: &EvalCmdArgs) -
             args.tolerance
         );
     }
+    if let Some(t) = args.perf_tolerance {
+        if !t.is_finite() || t < 0.0 {
+            anyhow::bail!("--perf-tolerance must be a finite non-negative number, got {t}");
+        }
+    }
 
     // Validate --save path: eval reports are JSON-only. Reject foreign
     // extensions so a typo (e.g. `--save baseline.txt`) surfaces immediately
@@ -169,17 +259,31 @@ pub(crate) fn cmd_eval(ctx: &CommandContext<'_, ReadOnly>, args: &EvalCmdArgs) -
     // CLI search path uses (`CommandContext::reranker`), so eval doesn't
     // accidentally diverge from production reranker config.
     let reranker = match args.reranker {
-        RerankerMode::None => None,
+        RerankerMode::None | RerankerMode::Late => None,
         RerankerMode::Onnx => Some(ctx.reranker()?),
     };
+    let late_interaction = args.reranker == RerankerMode::Late;
 
-    let report = runner::run_eval(
+    let (mut report, score_samples) = runner::run_eval(
         ctx,
-        &args.query_file,
+        query_file,
         args.category.as_deref(),
         args.limit,
         reranker.as_deref(),
+        late_interaction,
+        args.translate,
     )?;
+    report.reranker = (args.reranker != RerankerMode::None).then(|| reranker_name(args.reranker));
+    if args.calibrate {
+        let scale = cqs::calibration::ScoreScale::of(false, reranker.is_some(), late_interaction);
+        report.calibration = Some(calibrate(ctx, &report.index_model, scale, &score_samples)?);
+    }
+
+    // Record before any output or gate so a run that fails `--baseline`
+    // still lands in the history it is judged against.
+    if !args.no_history {
+        history::record_run(ctx, &report, reranker_name(args.reranker));
+    }
 
     // When --baseline is set, prefer the diff output over the raw report —
     // a CI-shaped invocation just wants the diff. The raw report still
@@ -203,8 +307,13 @@ pub(crate) fn cmd_eval(ctx: &CommandContext<'_, ReadOnly>, args: &EvalCmdArgs) -
     }
 
     if let Some(baseline_path) = &args.baseline {
-        let diff = baseline::compare_against_baseline(&report, baseline_path, args.tolerance)?;
-        if !diff.regressions.is_empty() {
+        let diff = baseline::compare_against_baseline(
+            &report,
+            baseline_path,
+            args.tolerance,
+            args.perf_tolerance,
+        )?;
+        if !diff.regressions.is_empty() || !diff.perf_regressions.is_empty() {
             // Per-category regression past tolerance → CI-friendly exit 1.
             // In JSON mode, emit a single error envelope — failure paths
             // advertise `{data:null, error:{code,message}, version:1}`.
@@ -212,11 +321,17 @@ pub(crate) fn cmd_eval(ctx: &CommandContext<'_, ReadOnly>, args: &EvalCmdArgs) -
             // error envelope on the same stdout would produce two JSON documents
             // back-to-back, breaking single-doc consumers. Users who want the
             // structured diff on regression should re-run without --json or use --save.
-            let msg = format!(
+            let mut msg = format!(
                 "{} regression(s) past tolerance \u{00b1}{:.1}pp",
                 diff.regressions.len(),
                 diff.tolerance_pp
             );
+            if let Some(tol) = diff.perf_tolerance_pct {
+                msg.push_str(&format!(
+                    ", {} cost regression(s) past +{tol:.1}%",
+                    diff.perf_regressions.len()
+                ));
+            }
             if json {
                 // INVALID_INPUT fits the regression case: the eval ran fine, but the
                 // inputs (current run + baseline) failed the user-defined gate.
@@ -238,6 +353,38 @@ pub(crate) fn cmd_eval(ctx: &CommandContext<'_, ReadOnly>, args: &EvalCmdArgs) -
     Ok(())
 }
 
+/// `--calibrate`: fit a curve to `samples` and store it for `model` on
+/// `scale`, replacing any earlier fit for the pair.
+fn calibrate(
+    ctx: &CommandContext<'_, ReadOnly>,
+    model: &str,
+    scale: cqs::calibration::ScoreScale,
+    samples: &[(f32, bool)],
+) -> Result<runner::CalibrationFit> {
+    let _span = tracing::info_span!("calibrate", model, %scale, samples = samples.len()).entered();
+    let curve = cqs::calibration::Calibration::fit(samples, cqs::unix_secs_i64().unwrap_or(0))
+        .context("Failed to calibrate scores")?;
+    let path = cqs::calibration::calibration_path(&ctx.project_cqs_dir);
+    let mut set = cqs::calibration::CalibrationSet::load(&path)?;
+    set.insert(model, scale, curve);
+    set.save(&path)?;
+    eprintln!(
+        "[eval] calibrated {scale} scores for {model} on {} results ({} gold); saved to {}",
+        curve.samples,
+        curve.hits,
+        path.display()
+    );
+    Ok(runner::CalibrationFit { scale, curve })
+}
+
+/// `--reranker` value as typed on the command line (`none`, `onnx`, `late`).
+fn reranker_name(mode: RerankerMode) -> String {
+    use clap::ValueEnum;
+    mode.to_possible_value()
+        .map(|v| v.get_name().to_string())
+        .unwrap_or_else(|| format!("{mode:?}").to_lowercase())
+}
+
 /// Consult the watch daemon and block until the index is fresh, or bail with
 /// an actionable error.
 ///
@@ -474,26 +621,82 @@ fn write_text_report<W: std::io::Write>(w: &mut W, report: &EvalReport) -> std::
         pct(report.overall.r_at_5),
         pct(report.overall.r_at_20)
     )?;
+    if let Some(wt) = &report.weighted {
+        writeln!(
+            w,
+            "WEIGHTED: R@1={}  R@5={}  R@20={}",
+            pct(wt.r_at_1),
+            pct(wt.r_at_5),
+            pct(wt.r_at_20)
+        )?;
+    }
+    if let Some(hn) = &report.hard_negatives {
+        writeln!(
+            w,
+            "HARD NEGATIVES: outranked gold on {}/{} queries ({})",
+            hn.outranked,
+            hn.queries,
+            pct(hn.rate).trim_start()
+        )?;
+    }
+    if let Some(n) = report.translated {
+        writeln!(w, "TRANSLATED: {n}/{} queries", report.overall.n)?;
+    }
     if report.skipped > 0 {
         writeln!(w, "(skipped {} queries with no gold_chunk)", report.skipped)?;
     }
     writeln!(w)?;
 
     if !report.by_category.is_empty() {
+        // The weight column only appears when the file declares weights.
+        let weights = &report.category_weights;
+        let weight_col = |cat: &str| {
+            if weights.is_empty() {
+                String::new()
+            } else {
+                format!(" {:>7}", weights.get(cat).copied().unwrap_or(1.0))
+            }
+        };
+        let weight_header = if weights.is_empty() {
+            String::new()
+        } else {
+            format!(" {:>7}", "weight")
+        };
         writeln!(
             w,
-            "{:<24} {:>5} {:>7} {:>7} {:>7}",
-            "category", "N", "R@1", "R@5", "R@20"
+            "{:<24} {:>5} {:>7} {:>7} {:>7}{}",
+            "category", "N", "R@1", "R@5", "R@20", weight_header
         )?;
         for (cat, stats) in &report.by_category {
             writeln!(
                 w,
-                "{:<24} {:>5} {:>7} {:>7} {:>7}",
+                "{:<24} {:>5} {:>7} {:>7} {:>7}{}",
                 cat,
                 stats.n,
                 pct(stats.r_at_1),
                 pct(stats.r_at_5),
                 pct(stats.r_at_20),
+                weight_col(cat),
+            )?;
+        }
+        writeln!(w)?;
+    }
+
+    if !report.by_query_language.is_empty() {
+        writeln!(
+            w,
+            "{:<24} {:>5} {:>7} {:>7} {:>7}",
+            "query language", "N", "R@1", "R@5", "R@20"
+        )?;
+        for (lang, stats) in &report.by_query_language {
+            writeln!(
+                w,
+                "{:<24} {:>5} {:>7} {:>7} {:>7}",
+                lang,
+                stats.n,
+                pct(stats.r_at_1),
+                pct(stats.r_at_5),
+                pct(stats.r_at_20),
             )?;
         }
         writeln!(w)?;
@@ -504,9 +707,33 @@ fn write_text_report<W: std::io::Write>(w: &mut W, report: &EvalReport) -> std::
         "(eval took {:.1}s, {:.1} queries/sec, model={})",
         report.elapsed_secs, report.queries_per_sec, report.index_model
     )?;
+    if let Some(perf) = perf_line(report) {
+        writeln!(w, "{perf}")?;
+    }
     Ok(())
 }
 
+/// `(latency p50=… p95=… max=…, peak RSS …)`, or `None` when the report
+/// carries neither measurement.
+fn perf_line(report: &EvalReport) -> Option<String> {
+    let mut parts = Vec::new();
+    if let Some(l) = &report.latency {
+        parts.push(format!(
+            "latency p50={:.1}ms p95={:.1}ms max={:.1}ms",
+            l.p50_ms, l.p95_ms, l.max_ms
+        ));
+    }
+    if let Some(rss) = report.peak_rss_bytes {
+        parts.push(format!("peak RSS {}", mib(rss)));
+    }
+    (!parts.is_empty()).then(|| format!("({})", parts.join(", ")))
+}
+
+/// Bytes as whole mebibytes, e.g. `1536 MiB`.
+fn mib(bytes: u64) -> String {
+    format!("{} MiB", bytes / (1024 * 1024))
+}
+
 /// Format a fraction in [0.0, 1.0] as a percentage with one decimal place,
 /// e.g. 0.4220 → "42.2%".
 fn pct(x: f64) -> String {
@@ -541,6 +768,8 @@ mod tests {
             args: EvalCmdArgs,
         }
         let w = Wrapper::try_parse_from(["test", "queries.json"]).unwrap();
+        assert_eq!(w.args.query_file, Some(PathBuf::from("queries.json")));
+        assert!(w.args.action.is_none());
         assert_eq!(w.args.limit, 20);
         assert!(!w.args.json);
         assert!(w.args.category.is_none());
@@ -550,6 +779,9 @@ mod tests {
         // Gate is on by default — no_require_fresh stays false.
         assert!(!w.args.no_require_fresh);
         assert_eq!(w.args.require_fresh_secs, 600);
+        // Cost gate is opt-in; history recording is on.
+        assert!(w.args.perf_tolerance.is_none());
+        assert!(!w.args.no_history);
     }
 
     /// Parser accepts `--no-require-fresh` and a custom budget.
@@ -573,6 +805,66 @@ mod tests {
         assert_eq!(w.args.require_fresh_secs, 30);
     }
 
+    /// `author` parses as a subcommand and the positional query file is
+    /// only required when no subcommand is given.
+    #[test]
+    fn test_author_subcommand_parses() {
+        use clap::Parser;
+        #[derive(Parser)]
+        struct Wrapper {
+            #[command(flatten)]
+            args: EvalCmdArgs,
+        }
+        let w =
+            Wrapper::try_parse_from(["test", "author", "--queries", "q.txt", "-n", "5"]).unwrap();
+        assert!(w.args.query_file.is_none());
+        match w.args.action {
+            Some(EvalCommand::Author(a)) => {
+                assert_eq!(a.queries, PathBuf::from("q.txt"));
+                assert_eq!(a.limit, 5);
+                assert!(a.output.is_none());
+                assert!(!a.held_out);
+            }
+            other => panic!("expected author subcommand, got {other:?}"),
+        }
+        assert!(Wrapper::try_parse_from(["test"]).is_err());
+    }
+
+    #[test]
+    fn test_generate_subcommand_parses() {
+        use clap::Parser;
+        #[derive(Parser)]
+        struct Wrapper {
+            #[command(flatten)]
+            args: EvalCmdArgs,
+        }
+        let w = Wrapper::try_parse_from([
+            "test",
+            "generate",
+            "-o",
+            "gen.json",
+            "--lang",
+            "go",
+            "--per-lang",
+            "10",
+        ])
+        .unwrap();
+        match w.args.action {
+            Some(EvalCommand::Generate(a)) => {
+                assert_eq!(a.output, PathBuf::from("gen.json"));
+                assert_eq!(a.lang.as_deref(), Some("go"));
+                assert_eq!(a.per_lang, 10);
+                assert_eq!(a.min_words, 4);
+                assert!(!a.force);
+            }
+            other => panic!("expected generate subcommand, got {other:?}"),
+        }
+        assert!(
+            Wrapper::try_parse_from(["test", "generate", "--per-lang", "0", "-o", "g.json"])
+                .is_err()
+        );
+    }
+
     /// Env-var falsy values disable the gate. Drives the actual
     /// `env_disables_freshness_gate` helper so the helper's `matches!`
     /// pattern is what gets covered (re-implementing the logic inline would
@@ -702,6 +994,7 @@ mod tests {
             "base.json",
             "--tolerance",
             "2.5",
+            "--calibrate",
         ])
         .unwrap();
         assert!(w.args.json);
@@ -710,6 +1003,7 @@ mod tests {
         assert_eq!(w.args.save.unwrap().to_str().unwrap(), "out.json");
         assert_eq!(w.args.baseline.unwrap().to_str().unwrap(), "base.json");
         assert!((w.args.tolerance - 2.5).abs() < 1e-9);
+        assert!(w.args.calibrate);
         // When not passed, the gate stays on by default even alongside every
         // other flag.
         assert!(!w.args.no_require_fresh);
@@ -729,7 +1023,11 @@ mod tests {
             #[command(flatten)]
             args: EvalCmdArgs,
         }
-        for (input, expected) in [("none", RerankerMode::None), ("onnx", RerankerMode::Onnx)] {
+        for (input, expected) in [
+            ("none", RerankerMode::None),
+            ("onnx", RerankerMode::Onnx),
+            ("late", RerankerMode::Late),
+        ] {
             let w = Wrapper::try_parse_from(["test", "queries.json", "--reranker", input]).unwrap();
             assert_eq!(
                 w.args.reranker, expected,
@@ -774,11 +1072,54 @@ mod tests {
                 r_at_20: 1.0,
             },
             by_category,
+            weighted: Some(Overall {
+                n: 2,
+                r_at_1: 0.25,
+                r_at_5: 1.0,
+                r_at_20: 1.0,
+            }),
+            category_weights: [("structural_search".to_string(), 2.0)].into(),
             index_model: "BAAI/bge-large-en-v1.5".to_string(),
             cqs_version: "1.30.1".to_string(),
             query_file: "fixture.json".to_string(),
             limit: 20,
             category_filter: None,
+            reranker: None,
+            latency: Some(super::runner::LatencyStats {
+                p50_ms: 12.04,
+                p95_ms: 40.0,
+                max_ms: 51.3,
+            }),
+            peak_rss_bytes: Some(1536 * 1024 * 1024),
+            hard_negatives: Some(super::runner::HardNegativeStats {
+                queries: 2,
+                outranked: 1,
+                rate: 0.5,
+            }),
+            by_query_language: [
+                (
+                    "de".to_string(),
+                    CategoryStats {
+                        n: 1,
+                        r_at_1: 0.0,
+                        r_at_5: 1.0,
+                        r_at_20: 1.0,
+                    },
+                ),
+                (
+                    "en".to_string(),
+                    CategoryStats {
+                        n: 1,
+                        r_at_1: 1.0,
+                        r_at_5: 1.0,
+                        r_at_20: 1.0,
+                    },
+                ),
+            ]
+            .into(),
+            translated: Some(1),
+            calibration: None,
+            queries: Vec::new(),
         };
 
         let mut buf = Vec::new();
@@ -795,6 +1136,14 @@ mod tests {
             out.contains("OVERALL: R@1= 50.0%  R@5=100.0%  R@20=100.0%"),
             "OVERALL line missing or reformatted: {out}"
         );
+        assert!(
+            out.contains("WEIGHTED: R@1= 25.0%  R@5=100.0%  R@20=100.0%"),
+            "WEIGHTED line missing or reformatted: {out}"
+        );
+        assert!(
+            out.contains("HARD NEGATIVES: outranked gold on 1/2 queries (50.0%)"),
+            "hard-negative line missing or reformatted: {out}"
+        );
         // Per-category table header row.
         assert!(
             out.contains("category"),
@@ -803,15 +1152,33 @@ mod tests {
         assert!(out.contains("R@1"), "R@1 column header missing: {out}");
         assert!(out.contains("R@5"), "R@5 column header missing: {out}");
         assert!(out.contains("R@20"), "R@20 column header missing: {out}");
-        // Category row.
+        // Category row, with the weight column the fixture declares.
         assert!(
             out.contains("structural_search"),
             "category row missing: {out}"
         );
+        assert!(
+            out.contains("weight"),
+            "weight column header missing: {out}"
+        );
+        // Per-language table, shown because the fixture mixes languages.
+        assert!(
+            out.contains("TRANSLATED: 1/2 queries"),
+            "translated line missing or reformatted: {out}"
+        );
+        assert!(
+            out.contains("query language"),
+            "query-language table missing: {out}"
+        );
         // Footer with elapsed + qps + model.
         assert!(
             out.contains("(eval took 1.5s, 1.3 queries/sec, model=BAAI/bge-large-en-v1.5)"),
             "footer line missing or reformatted: {out}"
         );
+        // Cost line under the footer.
+        assert!(
+            out.contains("(latency p50=12.0ms p95=40.0ms max=51.3ms, peak RSS 1536 MiB)"),
+            "perf line missing or reformatted: {out}"
+        );
     }
 }
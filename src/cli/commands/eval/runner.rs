@@ -19,7 +19,8 @@ use std::time::Instant;
 use anyhow::{Context as _, Result};
 use serde::{Deserialize, Serialize};
 
-use cqs::eval::schema::{GoldChunk, QuerySet};
+use cqs::calibration::{Calibration, ScoreScale};
+use cqs::eval::schema::{GoldChunk, HardNegative, QuerySet};
 use cqs::language::ChunkType;
 use cqs::store::{ReadOnly, UnifiedResult};
 use cqs::{SearchFilter, Store};
@@ -61,6 +62,14 @@ pub(crate) struct EvalReport {
     pub queries_per_sec: f64,
     pub overall: Overall,
     pub by_category: BTreeMap<String, CategoryStats>,
+    /// `by_category` averaged with the query file's `category_weights`, so a
+    /// set can say concept queries matter more than name lookups. `None`
+    /// when the file declares no weights.
+    #[serde(skip_serializing_if = "Option::is_none", default)]
+    pub weighted: Option<Overall>,
+    /// The weights `weighted` was computed with, as declared in the file.
+    #[serde(skip_serializing_if = "BTreeMap::is_empty", default)]
+    pub category_weights: BTreeMap<String, f64>,
     pub index_model: String,
     pub cqs_version: String,
     pub query_file: String,
@@ -69,12 +78,184 @@ pub(crate) struct EvalReport {
     /// When `--category` filtered the run, the category name; otherwise `None`.
     #[serde(skip_serializing_if = "Option::is_none", default)]
     pub category_filter: Option<String>,
+    /// Reranker mode the run used (`onnx`); `None` for retrieval-only runs.
+    #[serde(skip_serializing_if = "Option::is_none", default)]
+    pub reranker: Option<String>,
+    /// Per-query search latency. `None` in reports saved before latency
+    /// tracking, or when no query was scored.
+    #[serde(skip_serializing_if = "Option::is_none", default)]
+    pub latency: Option<LatencyStats>,
+    /// Process peak resident set size at the end of the run, in bytes —
+    /// model load included, since that is what a deployment pays. `None`
+    /// where the platform doesn't report it.
+    #[serde(skip_serializing_if = "Option::is_none", default)]
+    pub peak_rss_bytes: Option<u64>,
+    /// Per-query outcome in query-file order — what `cqs eval compare`
+    /// pairs two runs on. Empty in reports saved before per-query capture.
+    #[serde(skip_serializing_if = "Vec::is_empty", default)]
+    pub queries: Vec<QueryOutcome>,
+    /// How often a mined hard negative beat the gold. `None` when no scored
+    /// query lists hard negatives.
+    #[serde(skip_serializing_if = "Option::is_none", default)]
+    pub hard_negatives: Option<HardNegativeStats>,
+    /// R@K per query language (`EvalQuery::query_language`). Empty unless
+    /// the scored queries span more than one language.
+    #[serde(skip_serializing_if = "BTreeMap::is_empty", default)]
+    pub by_query_language: BTreeMap<String, CategoryStats>,
+    /// With `--translate`, how many queries were searched in English
+    /// translation; `None` when the run didn't translate.
+    #[serde(skip_serializing_if = "Option::is_none", default)]
+    pub translated: Option<usize>,
+    /// With `--calibrate`, the confidence curve fit on this run's scores.
+    #[serde(skip_serializing_if = "Option::is_none", default)]
+    pub calibration: Option<CalibrationFit>,
+}
+
+/// A confidence curve `cqs eval --calibrate` fit, and the scale it is for.
+#[derive(Debug, Clone, Copy, PartialEq, Serialize, Deserialize)]
+pub(crate) struct CalibrationFit {
+    pub scale: ScoreScale,
+    #[serde(flatten)]
+    pub curve: Calibration,
+}
+
+/// Hard-negative confusion over the queries that list any.
+#[derive(Debug, Clone, Copy, PartialEq, Serialize, Deserialize)]
+pub(crate) struct HardNegativeStats {
+    /// Scored queries with at least one hard negative
+    pub queries: usize,
+    /// Of those, queries where a hard negative ranked above the gold (or
+    /// was retrieved while the gold was not)
+    pub outranked: usize,
+    /// `outranked / queries`
+    pub rate: f64,
+}
+
+impl HardNegativeStats {
+    /// Aggregate `(has_hard_negatives, outranked)` pairs; `None` when no
+    /// query had any.
+    fn from_hits(hits: &[QueryHit]) -> Option<Self> {
+        let queries = hits.iter().filter(|h| h.has_hard_negatives).count();
+        if queries == 0 {
+            return None;
+        }
+        let outranked = hits.iter().filter(|h| h.hard_negative_won()).count();
+        Some(Self {
+            queries,
+            outranked,
+            rate: outranked as f64 / queries as f64,
+        })
+    }
+}
+
+/// A `cqs eval --save` report as written before per-query capture (and
+/// before latency, weights and the other optional fields): the shape old
+/// baselines on disk still have.
+#[cfg(test)]
+pub(crate) const PRE_PER_QUERY_REPORT: &str = r#"{
+  "query_count": 4,
+  "skipped": 0,
+  "elapsed_secs": 3.2,
+  "queries_per_sec": 1.25,
+  "overall": { "n": 4, "r_at_1": 0.25, "r_at_5": 0.5, "r_at_20": 0.75 },
+  "by_category": {
+    "behavioral": { "n": 2, "r_at_1": 0.5, "r_at_5": 1.0, "r_at_20": 1.0 },
+    "identifier": { "n": 2, "r_at_1": 0.0, "r_at_5": 0.0, "r_at_20": 0.5 }
+  },
+  "index_model": "bge-large",
+  "cqs_version": "1.50.0",
+  "query_file": "evals/queries/v3_test.json",
+  "limit": 20
+}"#;
+
+/// One scored query's outcome.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub(crate) struct QueryOutcome {
+    pub query: String,
+    pub category: String,
+    /// 1-indexed rank of the gold chunk; `None` = not in the top `limit`.
+    pub rank: Option<usize>,
+}
+
+/// Per-query search latency percentiles, in milliseconds. Covers the
+/// production search path only (embed + retrieve + rerank), not gold
+/// matching or progress logging.
+#[derive(Debug, Clone, Copy, PartialEq, Serialize, Deserialize)]
+pub(crate) struct LatencyStats {
+    pub p50_ms: f64,
+    pub p95_ms: f64,
+    pub max_ms: f64,
+}
+
+impl LatencyStats {
+    /// Nearest-rank percentiles over `samples_ms`; `None` when empty.
+    pub(crate) fn from_samples(samples_ms: &[f64]) -> Option<Self> {
+        if samples_ms.is_empty() {
+            return None;
+        }
+        let mut sorted = samples_ms.to_vec();
+        sorted.sort_by(f64::total_cmp);
+        let rank = |p: f64| {
+            let idx = ((p * sorted.len() as f64).ceil() as usize).max(1) - 1;
+            sorted[idx.min(sorted.len() - 1)]
+        };
+        Some(Self {
+            p50_ms: rank(0.50),
+            p95_ms: rank(0.95),
+            max_ms: sorted[sorted.len() - 1],
+        })
+    }
+}
+
+/// Peak resident set size of this process so far, in bytes.
+#[cfg(unix)]
+pub(crate) fn peak_rss_bytes() -> Option<u64> {
+    // SAFETY: getrusage only writes into the zeroed struct we hand it.
+    let mut usage: libc::rusage = unsafe { std::mem::zeroed() };
+    if unsafe { libc::getrusage(libc::RUSAGE_SELF, &mut usage) } != 0 {
+        return None;
+    }
+    let max_rss = u64::try_from(usage.ru_maxrss).ok()?;
+    // `ru_maxrss` is bytes on macOS and KiB everywhere else.
+    if cfg!(target_os = "macos") {
+        Some(max_rss)
+    } else {
+        Some(max_rss.saturating_mul(1024))
+    }
+}
+
+/// Peak resident set size of this process so far, in bytes.
+#[cfg(not(unix))]
+pub(crate) fn peak_rss_bytes() -> Option<u64> {
+    None
 }
 
 /// Per-query rank tracker. `None` = gold not found in top `limit`.
 struct QueryHit {
+    query: String,
     category: String,
+    query_language: String,
     rank: Option<usize>, // 1-indexed; None = miss
+    has_hard_negatives: bool,
+    /// 1-indexed rank of the best-placed hard negative; None = none retrieved
+    hard_negative_rank: Option<usize>,
+}
+
+impl QueryHit {
+    fn hard_negative_won(&self) -> bool {
+        match (self.hard_negative_rank, self.rank) {
+            (Some(hn), Some(gold)) => hn < gold,
+            (Some(_), None) => true,
+            (None, _) => false,
+        }
+    }
+}
+
+/// Where the gold and the best hard negative landed in one query's results.
+#[derive(Debug, Default, PartialEq, Eq)]
+struct Ranks {
+    gold: Option<usize>,
+    hard_negative: Option<usize>,
 }
 
 /// Run the eval and produce an `EvalReport`.
@@ -84,19 +265,30 @@ struct QueryHit {
 /// `limit` is the per-query result count used to compute R@K (typically 20).
 /// `reranker` opts each query into stage-2 cross-encoder / LLM scoring;
 /// `None` preserves the historical retrieval-only pipeline.
+/// `late_interaction` rescores each query's top candidates by MaxSim over
+/// stored token vectors instead (`--reranker late`).
+/// `translate` searches queries that don't read as English with an LLM
+/// translation, as `llm_query_translate = "auto"` does for `cqs search`.
+///
+/// Alongside the report, returns every retrieved result as a
+/// `(score, is_gold)` sample for `--calibrate`.
 pub(crate) fn run_eval(
     ctx: &CommandContext<'_, ReadOnly>,
     query_file: &Path,
     category_filter: Option<&str>,
     limit: usize,
     reranker: Option<&dyn cqs::Reranker>,
-) -> Result<EvalReport> {
+    late_interaction: bool,
+    translate: bool,
+) -> Result<(EvalReport, Vec<(f32, bool)>)> {
     let _span = tracing::info_span!(
         "run_eval",
         query_file = %query_file.display(),
         category = ?category_filter,
         limit,
         reranker_enabled = reranker.is_some(),
+        late_interaction,
+        translate,
     )
     .entered();
 
@@ -104,6 +296,25 @@ pub(crate) fn run_eval(
         .with_context(|| format!("Failed to read query file: {}", query_file.display()))?;
     let set: QuerySet = serde_json::from_str(&raw)
         .with_context(|| format!("Failed to parse query JSON: {}", query_file.display()))?;
+    if let Some((cat, w)) = set
+        .category_weights
+        .iter()
+        .find(|(_, w)| !w.is_finite() || **w < 0.0)
+    {
+        anyhow::bail!(
+            "{}: category_weights[{cat:?}] must be a non-negative number, got {w}",
+            query_file.display()
+        );
+    }
+    for cat in set.category_weights.keys() {
+        if !set
+            .queries
+            .iter()
+            .any(|q| q.category.as_deref() == Some(cat.as_str()))
+        {
+            tracing::warn!(category = %cat, "category_weights names a category no query uses");
+        }
+    }
 
     // Pre-build the vector index once and reuse across queries — eval can
     // be hundreds of queries and rebuilding HNSW per query is the dominant
@@ -116,7 +327,10 @@ pub(crate) fn run_eval(
 
     let total_queries = set.queries.len();
     let mut hits: Vec<QueryHit> = Vec::with_capacity(total_queries);
+    let mut latencies_ms: Vec<f64> = Vec::with_capacity(total_queries);
+    let mut score_samples: Vec<(f32, bool)> = Vec::new();
     let mut skipped = 0usize;
+    let mut translated = 0usize;
 
     let started = Instant::now();
     let mut last_progress = Instant::now();
@@ -147,21 +361,62 @@ pub(crate) fn run_eval(
             .clone()
             .unwrap_or_else(|| "uncategorized".to_string());
 
-        let rank = match search_for_rank(
-            ctx, embedder, store, index_ref, &q.query, gold, limit, reranker,
-        ) {
-            Ok(r) => r,
+        // A gold label names the symbol as it was when the query was written;
+        // follow recorded renames so a renamed function still counts as a hit.
+        let renamed = resolve_gold_rename(store, gold);
+        let gold = renamed.as_ref().unwrap_or(gold);
+
+        // Translation is an LLM call (or a cache hit) ahead of the search;
+        // it stays outside the latency sample, which measures retrieval.
+        let translation = translate
+            .then(|| {
+                crate::cli::commands::search::translate::translate(
+                    &ctx.root,
+                    &q.query,
+                    cqs::config::QueryTranslate::Auto,
+                )
+            })
+            .flatten()
+            .and_then(|meta| meta.text.filter(|_| meta.fired));
+        translated += usize::from(translation.is_some());
+
+        let search_started = Instant::now();
+        let searched = search_for_rank(
+            ctx,
+            embedder,
+            store,
+            index_ref,
+            translation.as_deref().unwrap_or(&q.query),
+            gold,
+            &q.hard_negatives,
+            limit,
+            reranker,
+            late_interaction,
+        );
+        latencies_ms.push(search_started.elapsed().as_secs_f64() * 1000.0);
+        let ranks = match searched {
+            Ok((ranks, samples)) => {
+                score_samples.extend(samples);
+                ranks
+            }
             Err(e) => {
                 tracing::warn!(
                     query = %q.query,
                     error = %e,
                     "Search failed for query, scoring as miss"
                 );
-                None
+                Ranks::default()
             }
         };
 
-        hits.push(QueryHit { category, rank });
+        hits.push(QueryHit {
+            query: q.query.clone(),
+            category,
+            query_language: q.query_language().to_string(),
+            rank: ranks.gold,
+            has_hard_negatives: !q.hard_negatives.is_empty(),
+            hard_negative_rank: ranks.hard_negative,
+        });
 
         // Progress every 10 queries (or at least every 5s). Routed through
         // `tracing::info!` so it honors `RUST_LOG`, JSON-log redirect, and
@@ -239,6 +494,17 @@ pub(crate) fn run_eval(
         },
     };
 
+    let by_query_language = stats_by(&hits, |h| h.query_language.as_str());
+    let by_query_language = if by_query_language.len() > 1 {
+        by_query_language
+    } else {
+        BTreeMap::new()
+    };
+
+    let weighted = (!set.category_weights.is_empty())
+        .then(|| weighted_overall(&by_category, |cat| set.weight_for(cat)))
+        .flatten();
+
     let index_model = store
         .stats()
         .map(|s| s.model_name.clone())
@@ -247,23 +513,198 @@ pub(crate) fn run_eval(
             "unknown".to_string()
         });
 
-    Ok(EvalReport {
+    let report = EvalReport {
         query_count: scored,
         skipped,
         elapsed_secs,
         queries_per_sec,
         overall,
         by_category,
+        weighted,
+        category_weights: set.category_weights.clone(),
         index_model,
         cqs_version: env!("CARGO_PKG_VERSION").to_string(),
         query_file: query_file.display().to_string(),
         limit,
         category_filter: category_filter.map(|s| s.to_string()),
+        reranker: None,
+        latency: LatencyStats::from_samples(&latencies_ms),
+        peak_rss_bytes: peak_rss_bytes(),
+        hard_negatives: HardNegativeStats::from_hits(&hits),
+        by_query_language,
+        translated: translate.then_some(translated),
+        calibration: None,
+        queries: hits
+            .into_iter()
+            .map(|h| QueryOutcome {
+                query: h.query,
+                category: h.category,
+                rank: h.rank,
+            })
+            .collect(),
+    };
+    Ok((report, score_samples))
+}
+
+/// R@1/5/20 of `hits` grouped by `key`.
+fn stats_by<'h>(
+    hits: &'h [QueryHit],
+    key: impl Fn(&'h QueryHit) -> &'h str,
+) -> BTreeMap<String, CategoryStats> {
+    let mut counts: BTreeMap<&str, [usize; 4]> = BTreeMap::new();
+    for h in hits {
+        let c = counts.entry(key(h)).or_default();
+        c[0] += 1;
+        if let Some(r) = h.rank {
+            c[1] += usize::from(r <= 1);
+            c[2] += usize::from(r <= 5);
+            c[3] += usize::from(r <= 20);
+        }
+    }
+    counts
+        .into_iter()
+        .map(|(k, [n, h1, h5, h20])| {
+            let n_f = n as f64;
+            let stats = CategoryStats {
+                n,
+                r_at_1: h1 as f64 / n_f,
+                r_at_5: h5 as f64 / n_f,
+                r_at_20: h20 as f64 / n_f,
+            };
+            (k.to_string(), stats)
+        })
+        .collect()
+}
+
+/// Weighted mean of per-category recall. Each category counts by its
+/// weight, not its query count, so a thin category is not drowned out by a
+/// large one. `None` when every scored category weighs zero.
+fn weighted_overall(
+    by_category: &BTreeMap<String, CategoryStats>,
+    weight_for: impl Fn(&str) -> f64,
+) -> Option<Overall> {
+    let (mut total_w, mut n) = (0.0, 0usize);
+    let (mut r1, mut r5, mut r20) = (0.0, 0.0, 0.0);
+    for (cat, stats) in by_category {
+        let w = weight_for(cat);
+        if w <= 0.0 || stats.n == 0 {
+            continue;
+        }
+        total_w += w;
+        n += stats.n;
+        r1 += w * stats.r_at_1;
+        r5 += w * stats.r_at_5;
+        r20 += w * stats.r_at_20;
+    }
+    (total_w > 0.0).then(|| Overall {
+        n,
+        r_at_1: r1 / total_w,
+        r_at_5: r5 / total_w,
+        r_at_20: r20 / total_w,
     })
 }
 
-/// Issue one search and return the 1-indexed rank of the gold chunk
-/// (or `None` if it doesn't appear in the top `limit`).
+/// `gold` with its name replaced by the symbol's current name when the index
+/// recorded a rename for it, `None` otherwise. A lookup failure (e.g. a
+/// pre-v33 index opened read-only) degrades to the label as written.
+fn resolve_gold_rename(store: &Store<ReadOnly>, gold: &GoldChunk) -> Option<GoldChunk> {
+    match store.current_name(&gold.origin, &gold.name) {
+        Ok(Some(name)) => {
+            tracing::debug!(old = %gold.name, new = %name, "Gold chunk resolved through rename");
+            Some(GoldChunk {
+                name,
+                ..gold.clone()
+            })
+        }
+        Ok(None) => None,
+        Err(e) => {
+            tracing::debug!(error = %e, "Rename lookup failed; using gold label as written");
+            None
+        }
+    }
+}
+
+/// Issue one search and return the 1-indexed rank of the gold chunk (or
+/// `None` if it doesn't appear in the top `limit`), plus the best rank any
+/// of `hard_negatives` reached, and each result's `(score, is_gold)`.
+//
+// 10 args is three over clippy's default. Factoring into a context struct
+// offers no real readability win for one-arg additions; revisit if this grows
+// again.
+#[allow(clippy::too_many_arguments)]
+fn search_for_rank(
+    ctx: &CommandContext<'_, ReadOnly>,
+    embedder: &cqs::Embedder,
+    store: &Store<ReadOnly>,
+    index: Option<&dyn cqs::index::VectorIndex>,
+    query: &str,
+    gold: &GoldChunk,
+    hard_negatives: &[HardNegative],
+    limit: usize,
+    reranker: Option<&dyn cqs::Reranker>,
+    late_interaction: bool,
+) -> Result<(Ranks, Vec<(f32, bool)>)> {
+    let results = search_candidates(
+        ctx,
+        embedder,
+        store,
+        index,
+        query,
+        limit,
+        reranker,
+        late_interaction,
+    )?;
+    let ranked: Vec<(String, &str)> = results
+        .iter()
+        .map(|sr| (cqs::normalize_path(&sr.chunk.file), sr.chunk.name.as_str()))
+        .collect();
+    // Every match of the gold key is a hit here, not just the first: a second
+    // window of the gold chunk is still what the query was after.
+    let samples = results
+        .iter()
+        .zip(&ranked)
+        .map(|(sr, (file, name))| (sr.score, *file == gold.origin && *name == gold.name))
+        .collect();
+    Ok((rank_in(&ranked, gold, hard_negatives), samples))
+}
+
+/// Ranks of the gold and the best hard negative in `(file, name)` results.
+fn rank_in(ranked: &[(String, &str)], gold: &GoldChunk, hard_negatives: &[HardNegative]) -> Ranks {
+    // Find gold rank (1-indexed). Match on (file == origin) AND
+    // (name == gold.name).
+    //
+    // We deliberately *don't* check `line_start`. The fixture's line numbers
+    // are frozen at the moment it was generated/refreshed, but every audit
+    // wave shifts function definitions up or down by a few lines as code
+    // moves around. Including `line_start` in the match key means a 1-line
+    // shift in the source turns a correct retrieval into a counted miss —
+    // an artifact of fixture staleness, not a real search regression.
+    // Re-pinning the fixture only postpones the problem: the drift
+    // re-accumulates and reproduces a phantom regression (~24pp R@5 in one
+    // observed case). Matching on `(file, name)` is loose
+    // enough to be drift-resilient, strict enough that retrieval has to
+    // surface the right function in the right file. Where a file has
+    // multiple chunks with the same name (overloads, windowed sub-chunks
+    // of the same section), the first ranked match wins — that's the most
+    // generous interpretation of "did search find this," which is what
+    // R@K is asking.
+    let position = |origin: &str, name: &str| {
+        ranked
+            .iter()
+            .position(|(file, n)| file == origin && *n == name)
+            .map(|i| i + 1)
+    };
+    Ranks {
+        gold: position(&gold.origin, &gold.name),
+        hard_negative: hard_negatives
+            .iter()
+            .filter_map(|hn| position(&hn.origin, &hn.name))
+            .min(),
+    }
+}
+
+/// Run one query through the production search path and return the top
+/// `limit` code results, best first.
 ///
 /// Mirrors the production search path (`cmd_query` in
 /// `src/cli/commands/search/query.rs`) so eval scores reflect actual
@@ -272,20 +713,20 @@ pub(crate) fn run_eval(
 ///   - Centroid reclassification with α floor
 ///   - DenseBase / Enriched index routing
 ///   - `code_types()` default include filter
-//
-// 8 args is one over clippy's default. Factoring into a context struct offers
-// no real readability win for a one-arg addition; revisit if this grows again.
+///
+/// Shared by the scoring loop and `cqs eval author`, so the candidates a
+/// labeler sees are exactly what the harness will later rank.
 #[allow(clippy::too_many_arguments)]
-fn search_for_rank(
+pub(super) fn search_candidates(
     ctx: &CommandContext<'_, ReadOnly>,
     embedder: &cqs::Embedder,
     store: &Store<ReadOnly>,
     index: Option<&dyn cqs::index::VectorIndex>,
     query: &str,
-    gold: &GoldChunk,
     limit: usize,
     reranker: Option<&dyn cqs::Reranker>,
-) -> Result<Option<usize>> {
+    late_interaction: bool,
+) -> Result<Vec<cqs::store::SearchResult>> {
     let query_embedding = embedder.embed_query(query)?;
 
     // Mirror cmd_query: when reranking, over-retrieve so the cross-encoder
@@ -328,6 +769,11 @@ fn search_for_rank(
         f.enable_splade = use_splade;
         f.splade_alpha = splade_alpha;
         f.type_boost_types = classification.type_hints.clone();
+        if late_interaction {
+            f.late_interaction =
+                cqs::late_interaction::query_for_search(&ctx.root, store, embedder, query)
+                    .map(std::sync::Arc::new);
+        }
         f
     };
     filter
@@ -371,54 +817,24 @@ fn search_for_rank(
     // `NoopReranker` is a valid mode but never reaches here — cmd_eval
     // only constructs a reranker for `Onnx` / `Llm`, leaving `None` to
     // fall through to retrieval-only.
-    let results: Vec<UnifiedResult> = if let Some(reranker) = reranker {
-        let mut code_results: Vec<cqs::store::SearchResult> = stage1_results
-            .into_iter()
-            .map(|r| match r {
-                UnifiedResult::Code(sr) => sr,
-            })
-            .collect();
+    let mut code_results: Vec<cqs::store::SearchResult> = stage1_results
+        .into_iter()
+        .map(|r| match r {
+            UnifiedResult::Code(sr) => sr,
+        })
+        .collect();
+    if let Some(reranker) = reranker {
         if code_results.len() > 1 {
             reranker
                 .rerank(query, &mut code_results, limit)
                 .map_err(|e| anyhow::anyhow!("Reranking failed: {e}"))?;
         }
-        // `Reranker::rerank` truncates to `limit` internally for impls that
-        // honor the contract; double-truncate as defense-in-depth so a
-        // misbehaving impl can't silently return more rows than asked.
-        code_results.truncate(limit);
-        code_results.into_iter().map(UnifiedResult::Code).collect()
-    } else {
-        stage1_results
-    };
-
-    // Find gold rank (1-indexed). Match on (file == origin) AND
-    // (name == gold.name).
-    //
-    // We deliberately *don't* check `line_start`. The fixture's line numbers
-    // are frozen at the moment it was generated/refreshed, but every audit
-    // wave shifts function definitions up or down by a few lines as code
-    // moves around. Including `line_start` in the match key means a 1-line
-    // shift in the source turns a correct retrieval into a counted miss —
-    // an artifact of fixture staleness, not a real search regression.
-    // Re-pinning the fixture only postpones the problem: the drift
-    // re-accumulates and reproduces a phantom regression (~24pp R@5 in one
-    // observed case). Matching on `(file, name)` is loose
-    // enough to be drift-resilient, strict enough that retrieval has to
-    // surface the right function in the right file. Where a file has
-    // multiple chunks with the same name (overloads, windowed sub-chunks
-    // of the same section), the first ranked match wins — that's the most
-    // generous interpretation of "did search find this," which is what
-    // R@K is asking.
-    let target_file = gold.origin.as_str();
-    for (i, r) in results.iter().enumerate() {
-        let UnifiedResult::Code(sr) = r;
-        let file_str = cqs::normalize_path(&sr.chunk.file);
-        if file_str == target_file && sr.chunk.name == gold.name {
-            return Ok(Some(i + 1));
-        }
     }
-    Ok(None)
+    // `Reranker::rerank` truncates to `limit` internally for impls that
+    // honor the contract; truncate here too as defense-in-depth so a
+    // misbehaving impl can't silently return more rows than asked.
+    code_results.truncate(limit);
+    Ok(code_results)
 }
 
 #[cfg(test)]
@@ -456,4 +872,125 @@ mod tests {
         assert_eq!(set.queries.len(), 1);
         assert_eq!(set.queries[0].gold_chunk.as_ref().unwrap().line_start, 42);
     }
+
+    /// Nearest-rank percentiles: with 20 samples P95 is the 19th, not an
+    /// interpolation, and unsorted input is handled.
+    #[test]
+    fn test_latency_stats_nearest_rank() {
+        let samples: Vec<f64> = (1..=20).rev().map(f64::from).collect();
+        let stats = LatencyStats::from_samples(&samples).unwrap();
+        assert_eq!(stats.p50_ms, 10.0);
+        assert_eq!(stats.p95_ms, 19.0);
+        assert_eq!(stats.max_ms, 20.0);
+
+        let one = LatencyStats::from_samples(&[7.5]).unwrap();
+        assert_eq!((one.p50_ms, one.p95_ms, one.max_ms), (7.5, 7.5, 7.5));
+        assert!(LatencyStats::from_samples(&[]).is_none());
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn test_peak_rss_is_reported_on_unix() {
+        assert!(peak_rss_bytes().unwrap() > 0);
+    }
+
+    #[test]
+    fn test_rank_in_reports_gold_and_best_hard_negative() {
+        let gold = GoldChunk {
+            name: "MergeSort".into(),
+            origin: "sort.go".into(),
+            line_start: 1,
+            id: None,
+            line_end: None,
+            chunk_type: None,
+            language: None,
+        };
+        let hn = |name: &str| HardNegative {
+            name: name.into(),
+            origin: "sort.go".into(),
+            line_start: 1,
+            lexical_overlap: None,
+            similarity: None,
+        };
+        let ranked = vec![
+            ("sort.go".to_string(), "QuickSort"),
+            ("sort.go".to_string(), "HeapSort"),
+            ("sort.go".to_string(), "MergeSort"),
+        ];
+        let ranks = rank_in(&ranked, &gold, &[hn("Missing"), hn("HeapSort")]);
+        assert_eq!(
+            ranks,
+            Ranks {
+                gold: Some(3),
+                hard_negative: Some(2),
+            }
+        );
+
+        let mk = |rank, hard_negative_rank| QueryHit {
+            query: String::new(),
+            category: String::new(),
+            query_language: "en".to_string(),
+            rank,
+            has_hard_negatives: true,
+            hard_negative_rank,
+        };
+        let mut hits = vec![
+            mk(Some(3), Some(2)),
+            mk(None, Some(4)),
+            mk(Some(1), Some(2)),
+        ];
+        hits.push(QueryHit {
+            has_hard_negatives: false,
+            ..mk(None, None)
+        });
+        let stats = HardNegativeStats::from_hits(&hits).unwrap();
+        assert_eq!((stats.queries, stats.outranked), (3, 2));
+        assert!(HardNegativeStats::from_hits(&hits[3..]).is_none());
+    }
+
+    #[test]
+    fn stats_by_groups_recall_per_key() {
+        let mk = |lang: &str, rank| QueryHit {
+            query: String::new(),
+            category: String::new(),
+            query_language: lang.to_string(),
+            rank,
+            has_hard_negatives: false,
+            hard_negative_rank: None,
+        };
+        let hits = [
+            mk("en", Some(1)),
+            mk("en", Some(7)),
+            mk("ja", Some(4)),
+            mk("ja", None),
+        ];
+        let by_lang = stats_by(&hits, |h| h.query_language.as_str());
+        assert_eq!(by_lang.len(), 2);
+        let en = &by_lang["en"];
+        assert_eq!((en.n, en.r_at_1, en.r_at_5, en.r_at_20), (2, 0.5, 0.5, 1.0));
+        let ja = &by_lang["ja"];
+        assert_eq!((ja.n, ja.r_at_1, ja.r_at_5, ja.r_at_20), (2, 0.0, 0.5, 0.5));
+    }
+
+    #[test]
+    fn test_weighted_overall_averages_categories_by_weight() {
+        let stats = |n, r| CategoryStats {
+            n,
+            r_at_1: r,
+            r_at_5: r,
+            r_at_20: r,
+        };
+        let mut by_category = BTreeMap::new();
+        by_category.insert("api-lookup".to_string(), stats(30, 0.9));
+        by_category.insert("concept".to_string(), stats(10, 0.3));
+        by_category.insert("bugfix".to_string(), stats(5, 0.0));
+
+        let weights: BTreeMap<&str, f64> = [("concept", 3.0), ("bugfix", 0.0)].into();
+        let w = weighted_overall(&by_category, |c| weights.get(c).copied().unwrap_or(1.0)).unwrap();
+        // (1.0 * 0.9 + 3.0 * 0.3) / 4.0; bugfix weighs nothing.
+        assert!((w.r_at_1 - 0.45).abs() < 1e-9);
+        assert_eq!(w.n, 40);
+
+        assert!(weighted_overall(&by_category, |_| 0.0).is_none());
+    }
 }
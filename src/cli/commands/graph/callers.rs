@@ -84,11 +84,12 @@ pub(crate) fn parse_edge_kind(s: &str) -> std::result::Result<CallEdgeKind, Stri
     match s.to_ascii_lowercase().as_str() {
         "call" => Ok(CallEdgeKind::Call),
         "serde_callback" => Ok(CallEdgeKind::SerdeCallback),
+        "ffi_boundary" => Ok(CallEdgeKind::FfiBoundary),
         "macro_heuristic" => Ok(CallEdgeKind::MacroHeuristic),
         "fn_pointer" => Ok(CallEdgeKind::FnPointer),
         "doc_reference" => Ok(CallEdgeKind::DocReference),
         other => Err(format!(
-            "invalid edge kind '{other}' (expected call|serde_callback|macro_heuristic|fn_pointer|doc_reference)"
+            "invalid edge kind '{other}' (expected call|serde_callback|ffi_boundary|macro_heuristic|fn_pointer|doc_reference)"
         )),
     }
 }
@@ -877,8 +878,12 @@ pub(crate) fn cmd_callers(
     let store = &ctx.store;
     let limit = limit.clamp(1, crate::cli::GRAPH_LIMIT_CAP);
 
-    if cross_project {
-        let mut cross_ctx = cqs::cross_project::CrossProjectContext::from_config(&ctx.root)?;
+    if let Some(mut cross_ctx) = super::multi_store_graph(ctx, cross_project)? {
+        let scope = if cross_project {
+            "cross-project"
+        } else {
+            "all shards"
+        };
         let output = callers_cross_core(
             &mut cross_ctx,
             &CallersArgs {
@@ -891,9 +896,9 @@ pub(crate) fn cmd_callers(
         if json {
             crate::cli::json_envelope::emit_json(&output)?;
         } else if output.callers.is_empty() {
-            println!("No callers found for '{}' (cross-project)", name);
+            println!("No callers found for '{}' ({scope})", name);
         } else {
-            println!("Functions that call '{}' (cross-project):", name);
+            println!("Functions that call '{}' ({scope}):", name);
             println!();
             for c in &output.callers {
                 let kind_suffix = if c.edge_kind.is_empty() {
@@ -1096,8 +1101,12 @@ pub(crate) fn cmd_callees(
     // See cmd_callers — same clamp range.
     let limit = limit.clamp(1, crate::cli::GRAPH_LIMIT_CAP);
 
-    if cross_project {
-        let mut cross_ctx = cqs::cross_project::CrossProjectContext::from_config(&ctx.root)?;
+    if let Some(mut cross_ctx) = super::multi_store_graph(ctx, cross_project)? {
+        let scope = if cross_project {
+            "cross-project"
+        } else {
+            "all shards"
+        };
         let output = callees_cross_core(
             &mut cross_ctx,
             &CalleesArgs {
@@ -1110,7 +1119,7 @@ pub(crate) fn cmd_callees(
         if json {
             crate::cli::json_envelope::emit_json(&output)?;
         } else {
-            println!("Functions called by '{}' (cross-project):", name.cyan());
+            println!("Functions called by '{}' ({scope}):", name.cyan());
             println!();
             if output.calls.is_empty() {
                 println!("  (no function calls found)");
@@ -1348,6 +1357,8 @@ mod tests {
             parent_type_name: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 
@@ -1490,6 +1501,10 @@ mod tests {
             parse_edge_kind("fn_pointer").unwrap(),
             CallEdgeKind::FnPointer
         );
+        assert_eq!(
+            parse_edge_kind("ffi_boundary").unwrap(),
+            CallEdgeKind::FfiBoundary
+        );
         assert!(parse_edge_kind("bogus").is_err());
     }
 
@@ -1506,6 +1521,74 @@ mod tests {
         .is_err());
     }
 
+    /// Write one function's calls into a fresh store at `db_path`.
+    fn seed_calls(db_path: &std::path::Path, file: &str, caller: &str, callees: &[&str]) {
+        std::fs::create_dir_all(db_path.parent().unwrap()).unwrap();
+        let store = cqs::Store::open(db_path).unwrap();
+        store.init(&cqs::store::ModelInfo::default()).unwrap();
+        let calls = [cqs::parser::FunctionCalls {
+            name: caller.to_string(),
+            line_start: 1,
+            calls: callees
+                .iter()
+                .map(|callee| cqs::parser::CallSite {
+                    callee_name: callee.to_string(),
+                    line_number: 2,
+                    kind: CallEdgeKind::Call,
+                })
+                .collect(),
+        }];
+        store
+            .upsert_function_calls(std::path::Path::new(file), &calls)
+            .unwrap();
+    }
+
+    /// On a sharded index the graph commands walk every shard: `charge`
+    /// lives in the billing shard, but its callers sit in the root shard and
+    /// in another shard, and its callees in a third.
+    #[test]
+    fn callers_and_callees_span_shards() {
+        let slot = tempfile::TempDir::new().unwrap();
+        seed_calls(
+            &slot.path().join(cqs::INDEX_DB_FILENAME),
+            "main.rs",
+            "main",
+            &["charge"],
+        );
+        for (prefix, file, caller, callees) in [
+            ("billing", "billing/pay.rs", "charge", &["ledger_write"][..]),
+            ("orders", "orders/refund.rs", "refund", &["charge"][..]),
+        ] {
+            let dir = cqs::shard::shard_dir(slot.path(), prefix);
+            seed_calls(&dir.join(cqs::INDEX_DB_FILENAME), file, caller, callees);
+            cqs::shard::write_manifest(&dir, prefix).unwrap();
+        }
+
+        let mut ctx = cqs::shard::graph_context(slot.path()).unwrap().unwrap();
+        let args = CallersArgs {
+            name: "charge".into(),
+            limit: 10,
+            edge_kind: None,
+        };
+        let callers = callers_cross_core(&mut ctx, &args).unwrap();
+        let found: Vec<(&str, &str)> = callers
+            .callers
+            .iter()
+            .map(|c| (c.project.as_str(), c.name.as_str()))
+            .collect();
+        assert_eq!(found, vec![("orders", "refund"), ("root", "main")]);
+
+        let callees = callees_cross_core(&mut ctx, &args).unwrap();
+        assert_eq!(callees.calls.len(), 1);
+        assert_eq!(callees.calls[0].name, "ledger_write");
+        assert_eq!(callees.calls[0].project, "billing");
+
+        let unsharded = tempfile::TempDir::new().unwrap();
+        assert!(cqs::shard::graph_context(unsharded.path())
+            .unwrap()
+            .is_none());
+    }
+
     // ----- Type::method qualifier parsing -----
 
     #[test]
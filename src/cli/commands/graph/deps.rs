@@ -374,6 +374,8 @@ mod tests {
             parent_type_name: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 
@@ -0,0 +1,164 @@
+//! Examples command — ranked usage examples of a symbol
+//!
+//! Call sites from the call graph, ranked by how much each shows about using
+//! the API (error handling, result use, a caller short enough to read)
+//! rather than by similarity to a query. See [`cqs::examples`].
+
+use anyhow::Result;
+
+use cqs::examples::{find_examples, ExampleOptions, ExampleSet, ExampleSignal};
+use cqs::rel_display;
+
+// ─── Output types ──────────────────────────────────────────────────────────
+
+/// One example in JSON output.
+#[derive(Debug, serde::Serialize)]
+struct ExampleEntry {
+    caller: String,
+    file: String,
+    line_start: u32,
+    call_line: u32,
+    language: String,
+    score: f32,
+    signals: Vec<ExampleSignal>,
+    snippet_start: u32,
+    snippet: String,
+}
+
+/// Typed JSON output for the examples command.
+#[derive(Debug, serde::Serialize)]
+struct ExamplesOutput {
+    symbol: String,
+    examples: Vec<ExampleEntry>,
+    call_sites: usize,
+    tests_skipped: usize,
+}
+
+// ─── Shared builder ────────────────────────────────────────────────────────
+
+/// Build typed examples output with root-relative file paths.
+fn build_examples_output(symbol: &str, set: ExampleSet, root: &std::path::Path) -> ExamplesOutput {
+    ExamplesOutput {
+        symbol: symbol.to_string(),
+        examples: set
+            .examples
+            .into_iter()
+            .map(|e| ExampleEntry {
+                caller: e.caller,
+                file: rel_display(&e.file, root),
+                line_start: e.line_start,
+                call_line: e.call_line,
+                language: e.language.to_string(),
+                score: e.score,
+                signals: e.signals,
+                snippet_start: e.snippet_start,
+                snippet: e.snippet,
+            })
+            .collect(),
+        call_sites: set.call_sites,
+        tests_skipped: set.tests_skipped,
+    }
+}
+
+/// Short labels for the text header line of an example.
+fn signal_label(signal: ExampleSignal) -> &'static str {
+    match signal {
+        ExampleSignal::HandlesErrors => "handles errors",
+        ExampleSignal::ResultUsed => "uses result",
+        ExampleSignal::QualifierMatch => "qualifier match",
+        ExampleSignal::Compact => "compact",
+        ExampleSignal::Documented => "documented",
+        ExampleSignal::Test => "test",
+    }
+}
+
+// ─── CLI command ───────────────────────────────────────────────────────────
+
+pub(crate) fn cmd_examples(
+    ctx: &crate::cli::CommandContext<'_, cqs::store::ReadOnly>,
+    symbol: &str,
+    opts: &ExampleOptions,
+    json: bool,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_examples", symbol, limit = opts.limit).entered();
+    let set = find_examples(&ctx.store, symbol, opts)?;
+    let output = build_examples_output(symbol, set, &ctx.root);
+
+    if json {
+        crate::cli::json_envelope::emit_json(&output)?;
+        return Ok(());
+    }
+
+    use colored::Colorize;
+    if output.examples.is_empty() {
+        if output.tests_skipped > 0 {
+            println!(
+                "No examples of '{}' outside tests ({} in tests; pass --include-tests).",
+                symbol, output.tests_skipped
+            );
+        } else {
+            println!("No call sites of '{}' found.", symbol);
+        }
+        return Ok(());
+    }
+    for e in &output.examples {
+        let signals: Vec<&str> = e.signals.iter().map(|s| signal_label(*s)).collect();
+        println!(
+            "{} {} {}",
+            format!("{}:{}", e.file, e.call_line).bold(),
+            format!("in {}", e.caller).cyan(),
+            format!("[{}]", signals.join(", ")).dimmed()
+        );
+        for line in e.snippet.lines() {
+            println!("  {}", line);
+        }
+        println!();
+    }
+    let skipped = if output.tests_skipped > 0 {
+        format!(", {} in tests skipped", output.tests_skipped)
+    } else {
+        String::new()
+    };
+    println!(
+        "{} of {} call site{}{}",
+        output.examples.len(),
+        output.call_sites,
+        if output.call_sites == 1 { "" } else { "s" },
+        skipped
+    );
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use cqs::examples::UsageExample;
+    use cqs::parser::Language;
+    use std::path::{Path, PathBuf};
+
+    #[test]
+    fn examples_output_uses_relative_paths() {
+        let set = ExampleSet {
+            examples: vec![UsageExample {
+                caller: "load".to_string(),
+                file: PathBuf::from("/project/src/net.rs"),
+                language: Language::Rust,
+                line_start: 10,
+                call_line: 12,
+                score: 0.65,
+                signals: vec![ExampleSignal::HandlesErrors, ExampleSignal::Compact],
+                snippet_start: 10,
+                snippet: "fn load() {\n    client.send(req)?;\n}".to_string(),
+            }],
+            call_sites: 4,
+            tests_skipped: 2,
+        };
+        let output = build_examples_output("Client.send", set, Path::new("/project"));
+        let json = serde_json::to_value(&output).unwrap();
+        assert_eq!(json["symbol"], "Client.send");
+        assert_eq!(json["examples"][0]["file"], "src/net.rs");
+        assert_eq!(json["examples"][0]["signals"][0], "handles_errors");
+        assert_eq!(json["call_sites"], 4);
+        assert_eq!(json["tests_skipped"], 2);
+    }
+}
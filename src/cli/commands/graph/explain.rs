@@ -70,7 +70,8 @@ pub(crate) struct ExplainData {
 
 /// Build explain data: resolve target, fetch callers/callees/similar, compute hints,
 /// and optionally pack content within a token budget.
-/// Shared between CLI `cmd_explain` and batch `dispatch_explain`.
+/// Shared between CLI `cmd_explain` and batch `dispatch_explain`; counts one
+/// access-stats open of the resolved chunk.
 /// * `index` — pre-loaded vector index (batch passes its cached one, CLI passes `None`
 ///   to load fresh).
 /// * `embedder` — required only when `max_tokens` is `Some`. Batch passes its cached one;
@@ -88,6 +89,14 @@ pub(crate) fn build_explain_data<Mode>(
     // Resolve target
     let resolved = cqs::resolve_target(store, target)?;
     let chunk = resolved.chunk;
+    cqs::access::record_access(
+        cqs_dir,
+        cqs::access::AccessKind::Open,
+        &[(
+            cqs::normalize_path(&chunk.file).as_str(),
+            chunk.name.as_str(),
+        )],
+    );
 
     // Get callers
     let callers = match store.get_callers_full(&chunk.name) {
@@ -601,6 +610,8 @@ mod output_tests {
             parent_type_name: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 
@@ -1018,6 +1029,8 @@ mod output_tests {
             parent_type_name: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 
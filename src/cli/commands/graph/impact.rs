@@ -261,8 +261,7 @@ pub(crate) fn cmd_impact(
     let store = &ctx.store;
     let root = &ctx.root;
 
-    if cross_project {
-        let mut cross_ctx = cqs::cross_project::CrossProjectContext::from_config(root)?;
+    if let Some(mut cross_ctx) = super::multi_store_graph(ctx, cross_project)? {
         let result = impact_cross_core(
             &mut cross_ctx,
             &ImpactArgs {
@@ -292,7 +291,11 @@ pub(crate) fn cmd_impact(
                 crate::cli::json_envelope::emit_json(&out.to_value()?)?;
             }
             OutputFormat::Text => {
-                let rel_file = "(cross-project)";
+                let rel_file = if cross_project {
+                    "(cross-project)"
+                } else {
+                    "(all shards)"
+                };
                 display_impact_text(&result, root, rel_file);
             }
         }
@@ -553,6 +556,8 @@ mod tests {
             parent_type_name: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 
@@ -684,6 +689,8 @@ mod tests {
             parent_type_name: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 
@@ -2,9 +2,11 @@
 
 mod callers;
 mod deps;
+mod examples;
 pub(crate) mod explain;
 mod impact;
 mod impact_diff;
+mod module_graph;
 pub(crate) mod notes_text;
 mod test_map;
 pub(crate) mod trace;
@@ -20,6 +22,7 @@ pub(crate) use callers::{
 #[cfg(test)]
 pub(crate) use callers::{callees_core, callers_core};
 pub(crate) use deps::{cmd_deps, deps_core, DepsArgs as DepsCoreArgs};
+pub(crate) use examples::cmd_examples;
 pub(crate) use explain::cmd_explain;
 pub(crate) use impact::{
     cmd_impact, impact_cross_core, impact_overlay, ImpactArgs as ImpactCoreArgs,
@@ -29,9 +32,10 @@ pub(crate) use impact::{
 #[cfg(test)]
 pub(crate) use impact::impact_core;
 pub(crate) use impact_diff::{cmd_impact_diff, ImpactDiffArgs as ImpactDiffCoreArgs};
+pub(crate) use module_graph::cmd_graph;
 pub(crate) use test_map::{
-    build_test_map_output, cmd_test_map, test_map_core, test_map_cross_core, test_map_max_nodes,
-    TestMapArgs as TestMapCoreArgs,
+    build_test_map_output, cmd_test_map, link_label, related_tests, test_map_core,
+    test_map_cross_core, test_map_max_nodes, TestMapArgs as TestMapCoreArgs, TestMapEntry,
 };
 pub(crate) use trace::{
     cmd_trace, trace_core, trace_cross_core, trace_max_nodes, TraceArgs as TraceCoreArgs,
@@ -372,6 +376,23 @@ pub(crate) fn chunks_to_definitions(chunks: &[cqs::store::ChunkSummary]) -> Vec<
         .collect()
 }
 
+/// The multi-store call graph a CLI `callers` / `callees` / `impact` /
+/// `trace` / `test-map` walks instead of the single store: every configured
+/// reference for `--cross-project`, otherwise the shards of a sharded index
+/// (the root store alone would miss every edge held by another shard).
+/// `None` ⇒ the single-store path.
+pub(crate) fn multi_store_graph(
+    ctx: &crate::cli::CommandContext<'_, ReadOnly>,
+    cross_project: bool,
+) -> anyhow::Result<Option<cqs::cross_project::CrossProjectContext>> {
+    if cross_project {
+        return Ok(Some(cqs::cross_project::CrossProjectContext::from_config(
+            &ctx.root,
+        )?));
+    }
+    Ok(cqs::shard::graph_context(&ctx.cqs_dir)?)
+}
+
 #[cfg(test)]
 mod tests {
     use super::chunk_to_definition_value;
@@ -399,6 +420,8 @@ mod tests {
             parent_type_name: None,
             parser_version: 0,
             vendored,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 
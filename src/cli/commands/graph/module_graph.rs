@@ -0,0 +1,27 @@
+//! Graph command — module-level dependency graph (DOT / JSON / Mermaid)
+
+use anyhow::Result;
+
+use crate::cli::args::GraphFormat;
+
+pub(crate) fn cmd_graph(
+    ctx: &crate::cli::CommandContext<'_, cqs::store::ReadOnly>,
+    format: GraphFormat,
+    depth: Option<usize>,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_graph", ?format, ?depth).entered();
+    let graph = cqs::module_graph::build_module_graph(&ctx.store, depth)?;
+    if graph.unresolved_calls > 0 {
+        tracing::info!(
+            unresolved = graph.unresolved_calls,
+            "calls to names defined in several modules left out of the graph"
+        );
+    }
+
+    match format {
+        GraphFormat::Json => crate::cli::json_envelope::emit_json(&graph)?,
+        GraphFormat::Dot => print!("{}", graph.to_dot()),
+        GraphFormat::Mermaid => print!("{}", graph.to_mermaid()),
+    }
+    Ok(())
+}
@@ -65,24 +65,49 @@ impl Default for TestMapArgs {
 
 // ─── Shared data structures ─────────────────────────────────────────────────
 
-/// A test that exercises the target function, found via reverse BFS.
+/// How a test was tied to its target.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, PartialOrd, Ord, serde::Serialize)]
+#[serde(rename_all = "snake_case")]
+pub(crate) enum TestLink {
+    /// The test reaches the target through the call graph.
+    #[default]
+    Call,
+    /// The test's name names the target (`test_parse` → `parse`) but no call
+    /// path was found — see [`cqs::impact::test_names_subject`].
+    Name,
+}
+
+impl TestLink {
+    fn is_call(&self) -> bool {
+        *self == TestLink::Call
+    }
+}
+
+/// A test that exercises the target function, found via reverse BFS or by
+/// its name.
 pub(crate) struct TestMatch {
     pub name: String,
     pub file: String,
     pub line: u32,
+    /// Call depth; 0 for a [`TestLink::Name`] match.
     pub depth: usize,
+    /// Call chain from the test to the target; empty for a name match.
     pub chain: Vec<String>,
+    pub link: TestLink,
 }
 
 // ─── Output types ───────────────────────────────────────────────────────────
 
-#[derive(Debug, serde::Serialize)]
+#[derive(Debug, Clone, serde::Serialize)]
 pub(crate) struct TestMapEntry {
     pub name: String,
     pub file: String,
     pub line_start: u32,
     pub call_depth: usize,
     pub call_chain: Vec<String>,
+    /// Skip-when-default: absent means the test calls the target.
+    #[serde(skip_serializing_if = "TestLink::is_call")]
+    pub linked_by: TestLink,
 }
 
 #[derive(Debug, serde::Serialize)]
@@ -138,7 +163,9 @@ pub(crate) fn test_map_max_nodes() -> usize {
 }
 
 /// Reverse BFS through the call graph to find all test chunks that call the
-/// target, up to `max_depth`. Returns sorted matches.
+/// target, up to `max_depth`, then add the tests whose names name the target
+/// but that no call path reached. Returns call matches by depth, then name
+/// matches, each group sorted by name.
 ///
 /// Capped at `CQS_TEST_MAP_MAX_NODES` (default 10,000) visited nodes to prevent
 /// OOM on dense graphs.
@@ -253,11 +280,36 @@ where
                 line: test.line_start,
                 depth: *depth,
                 chain: chain.clone(),
+                link: TestLink::Call,
             });
         }
     }
 
-    matches.sort_by(|a, b| a.depth.cmp(&b.depth).then_with(|| a.name.cmp(&b.name)));
+    // Naming conventions cover tests the graph can't connect: a call through
+    // a macro, a table-driven case list, a trait object.
+    for (name, tests) in &by_name {
+        let reached = ancestors.get(*name).is_some_and(|(depth, _)| *depth > 0);
+        if reached || *name == target_name || !cqs::impact::test_names_subject(name, target_name) {
+            continue;
+        }
+        for test in tests {
+            matches.push(TestMatch {
+                name: test.name.clone(),
+                file: cqs::rel_display(&test.file, root),
+                line: test.line_start,
+                depth: 0,
+                chain: Vec::new(),
+                link: TestLink::Name,
+            });
+        }
+    }
+
+    matches.sort_by(|a, b| {
+        a.link
+            .cmp(&b.link)
+            .then_with(|| a.depth.cmp(&b.depth))
+            .then_with(|| a.name.cmp(&b.name))
+    });
     matches
 }
 
@@ -275,12 +327,43 @@ pub(crate) fn build_test_map_output(target_name: &str, matches: &[TestMatch]) ->
                 line_start: m.line,
                 call_depth: m.depth,
                 call_chain: m.chain.clone(),
+                linked_by: m.link,
             })
             .collect(),
         count: matches.len(),
     }
 }
 
+/// Text-mode tag for one test: its call depth, or `name match`.
+pub(crate) fn link_label(link: TestLink, depth: usize) -> String {
+    match link {
+        TestLink::Call => format!("depth {depth}"),
+        TestLink::Name => "name match".to_string(),
+    }
+}
+
+/// Tests linked to one search hit, for `cqs "<query>" --with-tests`: the
+/// same call-graph and naming links as `cqs test-map`, at the default depth,
+/// closest first and capped at `limit`.
+pub(crate) fn related_tests(
+    target_name: &str,
+    graph: &CallGraph,
+    test_chunks: &[ChunkSummary],
+    root: &Path,
+    limit: usize,
+) -> Vec<TestMapEntry> {
+    let mut matches = build_test_map(
+        target_name,
+        graph,
+        test_chunks.iter(),
+        root,
+        crate::cli::args::DEFAULT_DEPTH_TEST_MAP as usize,
+        test_map_max_nodes(),
+    );
+    matches.truncate(limit);
+    build_test_map_output(target_name, &matches).tests
+}
+
 // ─── Core ───────────────────────────────────────────────────────────────────
 
 /// Surface-agnostic core for `cqs test-map <name>` (single-project).
@@ -381,8 +464,12 @@ pub(crate) fn cmd_test_map(
     // BFS-derived matches AFTER sorting so the "closest" tests rank first.
     let limit = limit.clamp(1, crate::cli::GRAPH_LIMIT_CAP);
 
-    if cross_project {
-        let mut cross_ctx = cqs::cross_project::CrossProjectContext::from_config(&ctx.root)?;
+    if let Some(mut cross_ctx) = super::multi_store_graph(ctx, cross_project)? {
+        let scope = if cross_project {
+            "cross-project"
+        } else {
+            "all shards"
+        };
         let matches = test_map_cross_core(
             &mut cross_ctx,
             &ctx.root,
@@ -399,12 +486,18 @@ pub(crate) fn cmd_test_map(
             crate::cli::json_envelope::emit_json(&output)?;
         } else {
             use colored::Colorize;
-            println!("{} {} (cross-project)", "Tests for:".cyan(), name.bold());
+            println!("{} {} ({scope})", "Tests for:".cyan(), name.bold());
             if matches.is_empty() {
                 println!("  No tests found");
             } else {
                 for m in &matches {
-                    println!("  {} ({}:{}) [depth {}]", m.name, m.file, m.line, m.depth);
+                    println!(
+                        "  {} ({}:{}) [{}]",
+                        m.name,
+                        m.file,
+                        m.line,
+                        link_label(m.link, m.depth)
+                    );
                     if m.chain.len() > 2 {
                         println!("    chain: {}", m.chain.join(" -> "));
                     }
@@ -455,8 +548,11 @@ pub(crate) fn cmd_test_map(
                 } else {
                     for t in &output.tests {
                         println!(
-                            "  {} ({}:{}) [depth {}]",
-                            t.name, t.file, t.line_start, t.call_depth
+                            "  {} ({}:{}) [{}]",
+                            t.name,
+                            t.file,
+                            t.line_start,
+                            link_label(t.linked_by, t.call_depth)
                         );
                         if t.call_chain.len() > 2 {
                             println!("    chain: {}", t.call_chain.join(" -> "));
@@ -511,6 +607,7 @@ mod output_tests {
                 line_start: 10,
                 call_depth: 1,
                 call_chain: vec!["my_func".into()],
+                linked_by: TestLink::Call,
             }],
             count: 1,
         };
@@ -518,6 +615,8 @@ mod output_tests {
         assert_eq!(json["name"], "my_func");
         assert!(json.get("function").is_none());
         assert_eq!(json["tests"][0]["line_start"], 10);
+        // Skip-when-default: a call-linked entry carries no `linked_by`.
+        assert!(json["tests"][0].get("linked_by").is_none());
     }
 
     #[test]
@@ -551,9 +650,57 @@ mod output_tests {
             parent_type_name: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 
+    /// A test the graph reaches is a call match; one that only names the
+    /// target follows it as a name match; an unrelated test is dropped.
+    #[test]
+    fn build_test_map_adds_name_matches_after_call_matches() {
+        use std::collections::HashMap;
+        let mut reverse = HashMap::new();
+        reverse.insert("parse".to_string(), vec!["test_roundtrip".to_string()]);
+        let graph = CallGraph::from_string_maps(HashMap::new(), reverse);
+        let tests = [
+            make_chunk(
+                cqs::parser::ChunkType::Test,
+                "test_roundtrip",
+                "tests/a.rs",
+                1,
+            ),
+            make_chunk(
+                cqs::parser::ChunkType::Test,
+                "test_parse_empty",
+                "tests/a.rs",
+                9,
+            ),
+            make_chunk(
+                cqs::parser::ChunkType::Test,
+                "test_render",
+                "tests/a.rs",
+                20,
+            ),
+        ];
+        let matches = build_test_map("parse", &graph, tests.iter(), Path::new(""), 5, 100);
+        let got: Vec<(&str, TestLink, usize)> = matches
+            .iter()
+            .map(|m| (m.name.as_str(), m.link, m.depth))
+            .collect();
+        assert_eq!(
+            got,
+            vec![
+                ("test_roundtrip", TestLink::Call, 1),
+                ("test_parse_empty", TestLink::Name, 0),
+            ]
+        );
+        let output = build_test_map_output("parse", &matches);
+        let json = serde_json::to_value(&output).unwrap();
+        assert_eq!(json["tests"][1]["linked_by"], "name");
+        assert_eq!(link_label(TestLink::Name, 0), "name match");
+    }
+
     #[test]
     fn test_map_fallback_payload_shape() {
         // Pin the {kind, fallback_from: "test-map", name, definitions, note}
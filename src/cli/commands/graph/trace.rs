@@ -400,8 +400,12 @@ pub(crate) fn cmd_trace(
     // in the signature so a future k-shortest-paths variant can read it
     // without a re-flatten and so batch users get a uniform flag set.
 
-    if cross_project {
-        let mut cross_ctx = cqs::cross_project::CrossProjectContext::from_config(&ctx.root)?;
+    if let Some(mut cross_ctx) = super::multi_store_graph(ctx, cross_project)? {
+        let scope = if cross_project {
+            "cross-project"
+        } else {
+            "all shards"
+        };
         let trace_result = trace_cross_core(&mut cross_ctx, source, target, max_depth)?;
 
         // Exhaustive match instead of if/else-if chains so a future
@@ -442,7 +446,7 @@ pub(crate) fn cmd_trace(
             OutputFormat::Text => {
                 if let Some(ref path) = trace_result.path {
                     println!(
-                        "Call path from {} to {} ({} hop{}, cross-project):",
+                        "Call path from {} to {} ({} hop{}, {scope}):",
                         source.cyan(),
                         target.cyan(),
                         path.len().saturating_sub(1),
@@ -467,7 +471,7 @@ pub(crate) fn cmd_trace(
                     }
                 } else {
                     println!(
-                        "No call path found from {} to {} within depth {} (cross-project).",
+                        "No call path found from {} to {} within depth {} ({scope}).",
                         source.cyan(),
                         target.cyan(),
                         max_depth
@@ -983,6 +987,8 @@ mod tests {
             parent_type_name: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 
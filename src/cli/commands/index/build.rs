@@ -3,7 +3,7 @@
 //! Indexes codebase files for semantic search.
 
 use std::collections::HashSet;
-use std::path::Path;
+use std::path::{Path, PathBuf};
 
 use anyhow::{Context, Result};
 
@@ -14,7 +14,8 @@ use cqs::{parse_notes, Embedder, HnswIndex, HnswKind, ModelInfo, Parser as CqPar
 use crate::cli::commands::{daemon_control_hint, DaemonHint};
 use crate::cli::{
     acquire_index_lock, args::IndexArgs, check_interrupted, enumerate_files, find_project_root,
-    reset_interrupted, run_index_pipeline, signal, Cli,
+    print_sandbox_skips, print_secret_redactions, reset_interrupted, run_index_pipeline, signal,
+    Cli, LockWait,
 };
 
 /// `cqs index --json` summary envelope. `cqs index` is pipeline orchestration
@@ -34,6 +35,26 @@ pub(crate) struct IndexSummaryOutput {
     pub parse_errors: usize,
     pub total_calls: usize,
     pub total_type_edges: usize,
+    /// Files the parse sandbox skipped (`--sandbox`); omitted when none.
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub sandbox_skipped: Vec<SandboxSkipEntry>,
+    /// Secrets masked per file (`[secrets]`); omitted when none.
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub redactions: Vec<RedactionEntry>,
+}
+
+/// One file `cqs index --sandbox` skipped.
+#[derive(Debug, serde::Serialize)]
+pub(crate) struct SandboxSkipEntry {
+    pub file: String,
+    pub reason: String,
+}
+
+/// Secrets masked in one file during `cqs index`, by kind.
+#[derive(Debug, serde::Serialize)]
+pub(crate) struct RedactionEntry {
+    pub file: String,
+    pub counts: std::collections::BTreeMap<String, usize>,
 }
 
 /// Run the UMAP projection on the daemon-delegation path.
@@ -95,11 +116,108 @@ fn project_umap_on_delegation(umap_flag: bool, index_path: &Path, quiet: bool) -
     }
 }
 
+/// One shard's pass of a sharded `cqs index` (see [`cqs::shard`]).
+struct ShardRun<'a> {
+    /// Prefix the shard holds, or `None` for the root shard (the slot's own
+    /// `index.db`).
+    prefix: Option<&'a str>,
+    /// The shard's slice of the project walk.
+    files: Vec<PathBuf>,
+}
+
 /// Index codebase files for semantic search
 ///
 /// Parses source files, generates embeddings, and stores them in the index database.
 /// Uses incremental indexing by default (only re-embeds changed files).
+///
+/// With `[index.shards]` configured, walks the project once and indexes each
+/// shard's files into its own store under its own lock; `--shard` narrows the
+/// run to one shard.
 pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
+    if args.sandbox {
+        cqs::parser::sandbox::force_enable();
+    }
+    let root = find_project_root();
+    let layout = match cqs::config::Config::load(&root)
+        .index
+        .and_then(|index| index.shards)
+    {
+        Some(cfg) => cqs::shard::ShardLayout::from_config(&cfg)?,
+        None => None,
+    };
+    let Some(layout) = layout else {
+        if args.shard.is_some() {
+            anyhow::bail!("--shard needs an [index.shards] section in .cqs.toml");
+        }
+        return index_one(cli, args, None);
+    };
+    if !args.only.is_empty() || args.stdin {
+        anyhow::bail!(
+            "--only and --stdin do not support a sharded index yet; \
+             reindex the file's shard with `cqs index --shard <prefix>` instead"
+        );
+    }
+
+    let parser = CqParser::new()?;
+    let files = enumerate_files(&root, &parser, args.no_ignore)?;
+    let mut groups = layout.partition(files);
+
+    // Shards already on disk that no longer own any file (a top-level dir
+    // was deleted, or a prefix dropped from the config) still get a pass, so
+    // their chunks are pruned instead of shadowing the files' new shard.
+    let project_cqs_dir = cqs::resolve_index_dir(&root);
+    if let Ok(slot) = cqs::slot::resolve_slot_name(cli.slot.as_deref(), &project_cqs_dir) {
+        let slot_dir = cqs::resolve_slot_dir(&project_cqs_dir, &slot.name);
+        for info in cqs::shard::list_shards(&slot_dir) {
+            groups.entry(Some(info.prefix)).or_default();
+        }
+    }
+
+    let selected = match args.shard.as_deref() {
+        None => None,
+        Some("root") => Some(None),
+        Some(wanted) => {
+            let found = groups.keys().flatten().find(|prefix| {
+                prefix.as_str() == wanted.trim_end_matches('/')
+                    || cqs::shard::shard_name(prefix) == wanted
+            });
+            match found {
+                Some(prefix) => Some(Some(prefix.clone())),
+                None => anyhow::bail!(
+                    "No shard '{wanted}'. Known shards: {}",
+                    std::iter::once("root".to_string())
+                        .chain(groups.keys().flatten().cloned())
+                        .collect::<Vec<_>>()
+                        .join(", ")
+                ),
+            }
+        }
+    };
+
+    for (prefix, files) in groups {
+        if selected.as_ref().is_some_and(|s| *s != prefix) {
+            continue;
+        }
+        if check_interrupted() {
+            break;
+        }
+        if !cli.quiet {
+            println!("Shard {}:", prefix.as_deref().unwrap_or("root"));
+        }
+        index_one(
+            cli,
+            args,
+            Some(ShardRun {
+                prefix: prefix.as_deref(),
+                files,
+            }),
+        )?;
+    }
+    Ok(())
+}
+
+/// Index the whole project (`shard = None`) or one shard of it.
+fn index_one(cli: &Cli, args: &IndexArgs, shard: Option<ShardRun<'_>>) -> Result<()> {
     // Hard-fail on `cqs index --model <typo>`. The shared resolver
     // `ModelConfig::resolve` silently falls back to the default preset when
     // the name doesn't match any built-in — fine for read paths (`cqs <q>`),
@@ -250,6 +368,23 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
         }
     }
 
+    // A non-root shard is a complete index of its own under
+    // `<slot>/shards/<name>/`: everything below (lock, store, HNSW, SPLADE)
+    // runs against that dir, so shards never contend for one lock.
+    let (cqs_dir, index_path) = match shard.as_ref().and_then(|run| run.prefix) {
+        Some(prefix) => {
+            let dir = cqs::shard::shard_dir(&cqs_dir, prefix);
+            if !dry_run {
+                std::fs::create_dir_all(&dir)
+                    .with_context(|| format!("Failed to create shard dir {}", dir.display()))?;
+                cqs::shard::write_manifest(&dir, prefix)?;
+            }
+            let index_path = dir.join(cqs::INDEX_DB_FILENAME);
+            (dir, index_path)
+        }
+        None => (cqs_dir, index_path),
+    };
+
     // Span carries slot name + resolution source so failed-index logs
     // surface which slot was being touched without reading code.
     let _slot_span = tracing::info_span!(
@@ -288,8 +423,16 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
     //
     // Daemon socket is hashed from the project-level `.cqs/` (one daemon
     // per project, regardless of slot) so we use `project_cqs_dir` here.
+    //
+    // `--stdin` never delegates: the buffer exists only in this process, so
+    // it is written CLI-side and the daemon's next reconcile treats the
+    // origin as stale (see `only::cmd_index_stdin`).
+    //
+    // Sharded runs never delegate either: the daemon's reconcile writes every
+    // file into the slot's own `index.db`, which would undo the sharding.
+    // Nor do sandboxed runs: the daemon parses in-process.
     #[cfg(unix)]
-    if !dry_run {
+    if !dry_run && !args.stdin && shard.is_none() && !cqs::parser::sandbox::enabled() {
         let sock_path = cqs::daemon_translate::daemon_socket_path(&project_cqs_dir);
         if sock_path.exists() {
             use std::os::unix::net::UnixStream;
@@ -319,10 +462,24 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
 
                     // Plain `cqs index`: route to the daemon's reconcile
                     // RPC instead of fighting it for the lock.
+                    // `--only` rides the same RPC: the daemon's reconcile
+                    // picks the saved files up on its next pass, and the
+                    // paths travel along for its logs.
+                    let (hook, hook_args) = if args.only.is_empty() {
+                        ("cli-index", Vec::new())
+                    } else {
+                        (
+                            "cli-index-only",
+                            args.only
+                                .iter()
+                                .map(|p| p.display().to_string())
+                                .collect::<Vec<_>>(),
+                        )
+                    };
                     match cqs::daemon_translate::daemon_reconcile(
                         &project_cqs_dir,
-                        Some("cli-index"),
-                        &[],
+                        Some(hook),
+                        &hook_args,
                     ) {
                         Ok(resp) => {
                             if !cli.quiet {
@@ -398,13 +555,21 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
 
     // Acquire lock (unless dry run)
     let _lock = if !dry_run {
-        Some(acquire_index_lock(&cqs_dir)?)
+        Some(acquire_index_lock(&cqs_dir, LockWait::from_cli(cli))?)
     } else {
         None
     };
 
     signal::setup_signal_handler();
 
+    // `--only`: targeted refresh of named files — no walk, no HNSW rebuild.
+    if !args.only.is_empty() {
+        return super::only::cmd_index_only(cli, args, &root, &index_path);
+    }
+    if args.stdin {
+        return super::only::cmd_index_stdin(cli, args, &root, &index_path);
+    }
+
     let _span = tracing::info_span!("cmd_index", force = force, dry_run = dry_run).entered();
 
     // Capture wall-clock start so the optional JSON envelope can report
@@ -417,7 +582,11 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
     }
 
     let parser = CqParser::new()?;
-    let files = enumerate_files(&root, &parser, no_ignore)?;
+    let is_root_shard = shard.as_ref().is_none_or(|run| run.prefix.is_none());
+    let files = match shard {
+        Some(run) => run.files,
+        None => enumerate_files(&root, &parser, no_ignore)?,
+    };
 
     if !cli.quiet {
         println!("Found {} files", files.len());
@@ -436,7 +605,10 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
     // When --force, back up the old DB instead of deleting it.
     // If interrupted during rebuild, the backup remains recoverable.
     let backup_path = cqs_dir.join("index.db.bak");
-    let store = if index_path.exists() && !force {
+    // With --swap, the rebuild goes to a sibling file instead and the live
+    // DB stays in place until the swap point below.
+    let swap_path = args.swap.then(|| cqs_dir.join("index.db.next"));
+    let mut store = if index_path.exists() && !force {
         let store = Store::open(&index_path)
             .with_context(|| format!("Failed to open store at {}", index_path.display()))?;
 
@@ -501,7 +673,10 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
             Vec::new()
         };
 
-        if index_path.exists() {
+        if let Some(next) = &swap_path {
+            // A build left behind by a crashed --swap run is never resumed.
+            remove_swap_build(next)?;
+        } else if index_path.exists() {
             std::fs::rename(&index_path, &backup_path)
                 .with_context(|| format!("Failed to back up {}", index_path.display()))?;
             // Also remove WAL/SHM files left by SQLite — stale journal
@@ -516,8 +691,9 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
                 }
             }
         }
-        let mut store = Store::open(&index_path)
-            .with_context(|| format!("Failed to create store at {}", index_path.display()))?;
+        let target = swap_path.as_deref().unwrap_or(&index_path);
+        let mut store = Store::open(target)
+            .with_context(|| format!("Failed to create store at {}", target.display()))?;
         let mc = cli.try_model_config()?;
         store.init(&ModelInfo::new(&mc.repo, mc.dim))?;
         store.set_dim(mc.dim);
@@ -536,12 +712,29 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
     // `.cqs.toml`; falls back to the built-in default list when absent.
     // Idempotent setter — safe to call regardless of which branch above
     // produced `store`.
-    let cfg_for_vendored = cqs::config::Config::load(&root);
-    let vendored_override = cfg_for_vendored
+    let project_cfg = cqs::config::Config::load(&root);
+    let vendored_override = project_cfg
         .index
         .as_ref()
         .and_then(|ic| ic.vendored_paths.as_deref());
-    store.set_vendored_prefixes(cqs::vendored::effective_prefixes(vendored_override));
+    let vendored_prefixes = cqs::vendored::effective_prefixes(vendored_override);
+    store.set_vendored_prefixes(vendored_prefixes.clone());
+
+    // Apply `[index.fts]` before any chunk upsert so new FTS rows use the
+    // configured analyzer. A changed analyzer re-derives both FTS tables
+    // from the stored text (no re-embedding); an unchanged one is a no-op.
+    let previous_analyzer = store.fts_analyzer().signature();
+    if store
+        .set_fts_analyzer(project_cfg.fts_analyzer())
+        .context("Failed to rebuild FTS tables for the configured analyzer")?
+        && !cli.quiet
+    {
+        println!(
+            "FTS analyzer changed ({} -> {}); keyword index rebuilt",
+            previous_analyzer,
+            store.fts_analyzer().signature()
+        );
+    }
 
     let store = Arc::new(store);
 
@@ -625,6 +818,8 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
                 stats.parse_errors
             );
         }
+        print_sandbox_skips(&stats, &root);
+        print_secret_redactions(&stats, &root);
     }
 
     if !cli.quiet && stats.total_calls > 0 {
@@ -634,6 +829,18 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
         println!("  Type edges: {} edges", stats.total_type_edges);
     }
 
+    // Blame summaries for `search --owner` / `--with-owners`. Only chunks
+    // without a summary for their current content are blamed, so an
+    // incremental index touches just the files that changed. Best-effort:
+    // a failure here never fails the index.
+    if !check_interrupted() {
+        match cqs::ownership::refresh_chunk_authors(&store, &root) {
+            Ok(n) if n > 0 && !cli.quiet => println!("  Blame authors: {} chunks", n),
+            Ok(_) => {}
+            Err(e) => tracing::warn!(error = %e, "Blame summary pass failed"),
+        }
+    }
+
     // LLM summary pass: generate one-sentence summaries via Claude API.
     // Runs BEFORE enrichment so summaries are incorporated into enrichment NL.
     #[cfg(feature = "llm-summaries")]
@@ -661,6 +868,7 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
             &config,
             max_docs.unwrap_or(0),
             improve_all,
+            false,
             Some(&cqs_dir),
         )
         .context("Doc comment generation failed")?;
@@ -795,6 +1003,14 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
         }
     }
 
+    // Stamp whatever summaries survived the prune (tombstoned code, or all
+    // orphans under `--no-prune-summaries`) as superseded, and revive rows
+    // whose hash this run brought back, so `cqs stats` and watch's idle
+    // re-summarize see an accurate picture.
+    if let Err(e) = store.mark_superseded_summaries() {
+        tracing::warn!(error = %e, "cmd_index: failed to mark superseded llm_summaries; non-fatal");
+    }
+
     // Call-graph enrichment pass: re-embed chunks with caller/callee
     // context. Also fires whenever any chunk is at `needs_embedding=1`
     // — the parser stage marks chunks unembedded when a `--llm-summaries`
@@ -846,8 +1062,15 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
     // exists, confirm with the user before proceeding. Bypassable with
     // `--accept-shared-notes` for CI / scripted use; non-TTY stdin
     // auto-skips the index-notes step (loud warn, never hangs).
-    let proceed_with_notes =
-        first_encounter_notes_gate(&root, &project_cqs_dir, args.accept_shared_notes, cli.quiet)?;
+    //
+    // Notes are project-wide, so a sharded index keeps them in the root shard.
+    let proceed_with_notes = is_root_shard
+        && first_encounter_notes_gate(
+            &root,
+            &project_cqs_dir,
+            args.accept_shared_notes,
+            cli.quiet,
+        )?;
     if !check_interrupted() && proceed_with_notes {
         if !cli.quiet {
             println!("Indexing notes...");
@@ -870,6 +1093,81 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
         }
     }
 
+    // Recent commit messages as `commit` chunks (`crate::commits`). Like
+    // notes, they're project-wide and live in the root shard. Runs before
+    // SPLADE and the HNSW build so both pick the new chunks up. A git
+    // failure never fails the index.
+    if !check_interrupted() && is_root_shard {
+        let embedder = Embedder::new(cli.try_model_config()?.clone())
+            .context("Failed to create embedder for commit history")?;
+        match cqs::commits::index_commits(&store, &embedder, &root) {
+            Ok(cs) => {
+                if !cli.quiet && (cs.indexed > 0 || cs.dropped > 0) {
+                    println!("  Commits: {} new, {} dropped", cs.indexed, cs.dropped);
+                }
+            }
+            Err(e) => {
+                tracing::warn!(error = %e, "Commit history indexing failed, continuing without");
+                if !cli.quiet {
+                    eprintln!("  Warning: commit history indexing failed: {:?}", e);
+                }
+            }
+        }
+    }
+
+    // Facet vectors (`crate::facets`), when `[facets]` is enabled. Only
+    // chunks added or changed since the last run are embedded. Search falls
+    // back to the whole-chunk vector for any chunk left without facets, so
+    // a failure here only costs ranking, not the index.
+    if !check_interrupted() && project_cfg.facets.is_some_and(|f| f.is_enabled()) {
+        if !cli.quiet {
+            println!("Embedding facet vectors (name, body)...");
+        }
+        let embedder = Embedder::new(cli.try_model_config()?.clone())
+            .context("Failed to create embedder for facet vectors")?;
+        match cqs::facets::embed_missing_facets(&store, &embedder) {
+            Ok(n) => {
+                if !cli.quiet && n > 0 {
+                    println!("  Facets: {} chunks", n);
+                }
+            }
+            Err(e) => {
+                tracing::warn!(error = %e, "Facet embedding failed, continuing without");
+                if !cli.quiet {
+                    eprintln!("  Warning: facet embedding failed: {:?}", e);
+                }
+            }
+        }
+    }
+
+    // Token vectors (`crate::late_interaction`), when `[late_interaction]`
+    // is enabled. Incremental like the facet pass; `--reranker late` leaves
+    // a chunk without them at its first-stage score.
+    if let Some(late) = project_cfg
+        .late_interaction
+        .as_ref()
+        .filter(|l| l.is_enabled() && !check_interrupted())
+    {
+        if !cli.quiet {
+            println!("Embedding token vectors (late interaction)...");
+        }
+        let embedder = Embedder::new(cli.try_model_config()?.clone())
+            .context("Failed to create embedder for token vectors")?;
+        match cqs::late_interaction::embed_missing_tokens(&store, &embedder, late) {
+            Ok(n) => {
+                if !cli.quiet && n > 0 {
+                    println!("  Token vectors: {} chunks", n);
+                }
+            }
+            Err(e) => {
+                tracing::warn!(error = %e, "Token embedding failed, continuing without");
+                if !cli.quiet {
+                    eprintln!("  Warning: token embedding failed: {:?}", e);
+                }
+            }
+        }
+    }
+
     // SPLADE sparse encoding (if model available).
     //
     // Path resolution is delegated to cqs::splade::resolve_splade_model_dir
@@ -1088,6 +1386,46 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
         }
     }
 
+    // --swap: every chunk, embedding and sparse vector is written. Close the
+    // build and rename it over the live index; watch and the daemon notice
+    // the new file identity and reopen. UMAP and HNSW below then run against
+    // the swapped-in DB. An interrupted build is discarded instead, leaving
+    // the old index serving.
+    let store = match &swap_path {
+        None => store,
+        Some(next) => {
+            let built = Arc::try_unwrap(store)
+                .map_err(|_| anyhow::anyhow!("Index store still shared at swap time"))?;
+            if check_interrupted() {
+                if let Err(e) = built.close() {
+                    tracing::warn!(error = %e, "Failed to close interrupted --swap build");
+                }
+                remove_swap_build(next)?;
+                anyhow::bail!(
+                    "Interrupted; discarded the partial rebuild, {} is unchanged",
+                    index_path.display()
+                );
+            }
+            built
+                .close()
+                .context("Failed to close the rebuilt index before swapping it in")?;
+            cqs::store::swap_in_db(&index_path, next).with_context(|| {
+                format!(
+                    "Failed to swap {} over {}",
+                    next.display(),
+                    index_path.display()
+                )
+            })?;
+            if !cli.quiet {
+                println!("Swapped rebuilt index into {}", index_path.display());
+            }
+            let store = Store::open(&index_path)
+                .with_context(|| format!("Failed to reopen store at {}", index_path.display()))?;
+            store.set_vendored_prefixes(vendored_prefixes);
+            Arc::new(store)
+        }
+    };
+
     // Optional UMAP projection — runs once per `cqs index --umap` invocation.
     // Lives between SPLADE and HNSW build because all final embeddings are
     // settled by this point and HNSW only depends on the dense vectors. The
@@ -1175,6 +1513,30 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
             }
         }
 
+        // Mirror vectors into the external store when `[index.vector]`
+        // names one. Non-fatal: until a sync succeeds, search keeps using
+        // the HNSW index built above.
+        match cqs::vector_backend::sync_configured(&store, &cqs_dir) {
+            Ok(Some((kind, stats))) => {
+                if !cli.quiet {
+                    println!(
+                        "  Vector store ({}): {} uploaded, {} deleted, {} total",
+                        kind.as_str(),
+                        stats.upserted,
+                        stats.deleted,
+                        stats.total
+                    );
+                }
+            }
+            Ok(None) => {}
+            Err(e) => {
+                tracing::warn!(error = %e, "External vector store sync failed");
+                if !cli.quiet {
+                    eprintln!("  Warning: vector store sync failed ({e}); search uses HNSW");
+                }
+            }
+        }
+
         // Invalidate any pre-existing CAGRA index file. cqs index rebuilds
         // HNSW above but has no parallel CAGRA save path — CAGRA is built
         // lazily on first search via cagra_build_from_store. If `index.cagra`
@@ -1203,6 +1565,19 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
         }
     }
 
+    // A queued `cqs model upgrade` is staged by watch, not here; say where
+    // it stands so it isn't forgotten.
+    if !cli.quiet {
+        if let Ok(Some(upgrade)) = store.pending_embedding_upgrade() {
+            if let Ok(p) = store.embedding_upgrade_progress(&upgrade.model) {
+                println!(
+                    "Embedding upgrade to {}: {}/{} chunks staged (`cqs model upgrade` for details)",
+                    upgrade.model, p.staged, p.total
+                );
+            }
+        }
+    }
+
     // Clean up backup from --force (rebuild succeeded)
     if backup_path.exists() {
         let _ = std::fs::remove_file(&backup_path);
@@ -1252,6 +1627,24 @@ pub(crate) fn cmd_index(cli: &Cli, args: &IndexArgs) -> Result<()> {
             parse_errors: stats.parse_errors,
             total_calls: stats.total_calls,
             total_type_edges: stats.total_type_edges,
+            sandbox_skipped: stats
+                .sandbox_skipped
+                .iter()
+                .map(|skip| SandboxSkipEntry {
+                    file: cqs::normalize_path(skip.path.strip_prefix(&root).unwrap_or(&skip.path)),
+                    reason: skip.kind.to_string(),
+                })
+                .collect(),
+            redactions: stats
+                .redactions
+                .iter()
+                .map(|report| RedactionEntry {
+                    file: cqs::normalize_path(
+                        report.file.strip_prefix(&root).unwrap_or(&report.file),
+                    ),
+                    counts: report.counts.clone(),
+                })
+                .collect(),
         })?;
     }
 
@@ -1401,6 +1794,23 @@ fn write_notes_acceptance_marker(marker_path: &Path) -> Result<()> {
     Ok(())
 }
 
+/// Delete a `--swap` build file and its SQLite sidecars, if present.
+fn remove_swap_build(next: &Path) -> Result<()> {
+    for suffix in ["", "-wal", "-shm"] {
+        let mut name = next.as_os_str().to_owned();
+        name.push(suffix);
+        let path = std::path::PathBuf::from(name);
+        match std::fs::remove_file(&path) {
+            Ok(()) => {}
+            Err(e) if e.kind() == std::io::ErrorKind::NotFound => {}
+            Err(e) => {
+                return Err(e).with_context(|| format!("Failed to remove {}", path.display()))
+            }
+        }
+    }
+    Ok(())
+}
+
 /// Index notes from notes.toml if it exists and needs reindexing
 ///
 /// Returns (indexed_count, was_skipped) where was_skipped is true if notes were up to date.
@@ -1685,7 +2095,7 @@ pub(crate) fn consume_dirty_marker(cqs_dir: &Path) -> bool {
 /// `bge-large`) and the repo string (e.g. `BAAI/bge-large-en-v1.5`) —
 /// operators pass either form interchangeably, as `cmd_model_swap`'s no-op
 /// short-circuit also does.
-fn check_index_model_drift(
+pub(super) fn check_index_model_drift(
     stored: Option<&str>,
     requested_name: &str,
     requested_repo: &str,
@@ -0,0 +1,128 @@
+//! Compress command for cqs
+//!
+//! Trains a zstd dictionary on the index's chunks and stores chunk text
+//! compressed against it (`cqs compress`), or stores it plain again
+//! (`cqs compress --off`). See `cqs::store::compression` for the format.
+//!
+//! Core struct is [`CompressOutput`]; CLI-only (mutating, no daemon path).
+
+use anyhow::{Context as _, Result};
+
+use crate::cli::commands::infra::human_bytes;
+use crate::cli::{acquire_index_lock, LockWait};
+
+// ---------------------------------------------------------------------------
+// Output struct
+// ---------------------------------------------------------------------------
+
+#[derive(Debug, serde::Serialize)]
+pub(crate) struct CompressOutput {
+    /// `true` after `cqs compress`, `false` after `--off`.
+    pub enabled: bool,
+    #[serde(flatten)]
+    pub report: cqs::store::CompressionReport,
+    /// `stored_bytes_after / raw_bytes`.
+    pub ratio: f64,
+}
+
+// ---------------------------------------------------------------------------
+// CLI command
+// ---------------------------------------------------------------------------
+
+/// Compress (or with `off`, decompress) the chunk text of the index.
+pub(crate) fn cmd_compress(
+    cli: &crate::cli::definitions::Cli,
+    off: bool,
+    dict_kib: u16,
+    json: bool,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_compress", off, dict_kib).entered();
+
+    let ctx = crate::cli::CommandContext::open_readwrite(cli)?;
+    let store = &ctx.store;
+
+    // Rewrites every chunk row; keep index/watch out while it runs.
+    let _lock = acquire_index_lock(&ctx.cqs_dir, LockWait::from_cli(cli))?;
+
+    let report = if off {
+        store
+            .decompress_content()
+            .context("Failed to decompress chunk text")?
+    } else {
+        store
+            .compress_content(dict_kib)
+            .context("Failed to compress chunk text")?
+    };
+    let ratio = if report.raw_bytes == 0 {
+        1.0
+    } else {
+        report.stored_bytes_after as f64 / report.raw_bytes as f64
+    };
+    let output = CompressOutput {
+        enabled: !off,
+        report,
+        ratio,
+    };
+
+    if json {
+        crate::cli::json_envelope::emit_json(&output)?;
+    } else {
+        render_compress_text(&output);
+    }
+    Ok(())
+}
+
+fn render_compress_text(output: &CompressOutput) {
+    let r = &output.report;
+    if output.enabled {
+        println!(
+            "Trained a {} KiB dictionary on {} chunks (id {})",
+            r.dict_bytes.div_ceil(1024),
+            r.samples,
+            r.dict_id.unwrap_or_default(),
+        );
+        println!(
+            "Compressed {} of {} chunks ({} kept plain for test lookup)",
+            r.compressed, r.chunks, r.kept_plain,
+        );
+    } else {
+        println!(
+            "Stored {} chunk{} as plain text; compression is off",
+            r.rewritten,
+            if r.rewritten == 1 { "" } else { "s" },
+        );
+    }
+    println!(
+        "Chunk text: {} raw, {} stored before, {} stored now ({:.0}%)",
+        human_bytes(r.raw_bytes),
+        human_bytes(r.stored_bytes_before),
+        human_bytes(r.stored_bytes_after),
+        output.ratio * 100.0,
+    );
+    if r.stored_bytes_after < r.stored_bytes_before {
+        println!("Freed pages are reused by later writes; the file itself does not shrink.");
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn output_flattens_report() {
+        let output = CompressOutput {
+            enabled: true,
+            report: cqs::store::CompressionReport {
+                dict_id: Some(7),
+                raw_bytes: 1000,
+                stored_bytes_after: 400,
+                ..Default::default()
+            },
+            ratio: 0.4,
+        };
+        let json = serde_json::to_value(&output).unwrap();
+        assert_eq!(json["dict_id"], 7);
+        assert_eq!(json["stored_bytes_after"], 400);
+        assert_eq!(json["ratio"], 0.4);
+    }
+}
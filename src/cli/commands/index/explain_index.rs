@@ -0,0 +1,319 @@
+//! `cqs explain-index <path>` — why a file is or isn't in the index.
+//!
+//! Replays the walk's decisions for one path (hidden, nested worktree,
+//! ignore rule with its file and line, language detection, size cap; see
+//! [`cqs::walk_explain`]) and sets them beside what the index holds: chunk
+//! count, last write, stored vs. disk fingerprint, and any watch-journal
+//! entries still waiting for a flush. The `verdict` line sums it up.
+
+use std::path::{Path, PathBuf};
+
+use anyhow::Result;
+
+use cqs::store::{FileFingerprint, FingerprintPolicy, OriginRecord, ReadOnly};
+use cqs::walk_explain::{LanguageDetection, WalkExclusion};
+
+use crate::cli::CommandContext;
+
+/// Size-cap decision (`CQS_MAX_FILE_SIZE`).
+#[derive(Debug, serde::Serialize)]
+struct SizeCheck {
+    bytes: Option<u64>,
+    cap: u64,
+    over_cap: bool,
+}
+
+/// Stored fingerprint vs. the file on disk.
+#[derive(Debug, serde::Serialize)]
+struct ContentCheck {
+    stored_mtime: Option<i64>,
+    disk_mtime: Option<i64>,
+    stored_hash: Option<String>,
+    disk_hash: Option<String>,
+    /// Whether reconcile would treat the file as unchanged; `None` when
+    /// there is no stored fingerprint or no file to compare.
+    in_sync: Option<bool>,
+}
+
+/// A watch-journal entry for the path that hasn't been flushed yet.
+#[derive(Debug, serde::Serialize)]
+struct PendingEvent {
+    reason: &'static str,
+    queued_at: i64,
+}
+
+#[derive(Debug, serde::Serialize)]
+struct ExplainIndexOutput {
+    path: String,
+    exists: bool,
+    verdict: String,
+    /// First walk filter that drops the path; `null` when the walk keeps it.
+    excluded_by: Option<WalkExclusion>,
+    language: LanguageDetection,
+    size: SizeCheck,
+    chunks: u64,
+    last_indexed: Option<String>,
+    content: ContentCheck,
+    parse_failed_parser_version: Option<u32>,
+    pending: Vec<PendingEvent>,
+}
+
+pub(crate) fn cmd_explain_index(
+    ctx: &CommandContext<'_, ReadOnly>,
+    path: &str,
+    json: bool,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_explain_index", path).entered();
+    let output = explain_index(&ctx.store, &ctx.root, path)?;
+    if json {
+        crate::cli::json_envelope::emit_json(&output)?;
+    } else {
+        print_text(&output);
+    }
+    Ok(())
+}
+
+fn explain_index(
+    store: &cqs::Store<ReadOnly>,
+    root: &Path,
+    path: &str,
+) -> Result<ExplainIndexOutput> {
+    let (abs, rel) = resolve_path(root, path);
+    let origin = cqs::normalize_path(&rel);
+    let meta = std::fs::metadata(&abs).ok().filter(|m| m.is_file());
+
+    let record = store.origin_record(&origin)?;
+    let pending = store
+        .watch_journal_entries()?
+        .into_iter()
+        .filter(|e| e.path == origin)
+        .map(|e| PendingEvent {
+            reason: e.reason.as_str(),
+            queued_at: e.queued_at,
+        })
+        .collect();
+
+    let cap = cqs::max_file_size();
+    let size = SizeCheck {
+        bytes: meta.as_ref().map(|m| m.len()),
+        cap,
+        over_cap: meta.as_ref().is_some_and(|m| m.len() > cap),
+    };
+    let content = content_check(&abs, meta.is_some(), record.fingerprint.as_ref());
+
+    let mut output = ExplainIndexOutput {
+        path: origin,
+        exists: meta.is_some(),
+        verdict: String::new(),
+        excluded_by: cqs::walk_explain::walk_exclusion(root, &rel),
+        language: cqs::walk_explain::detect_language(&abs),
+        size,
+        chunks: record.chunk_count,
+        last_indexed: record.last_indexed.clone(),
+        content,
+        parse_failed_parser_version: record.parse_failed_parser_version,
+        pending,
+    };
+    output.verdict = verdict(&output, &record);
+    Ok(output)
+}
+
+/// `(absolute, project-relative)` for a user-supplied path. Relative input
+/// is taken from the project root, like every other file argument.
+fn resolve_path(root: &Path, path: &str) -> (PathBuf, PathBuf) {
+    let given = Path::new(path);
+    if given.is_absolute() {
+        let abs = dunce::canonicalize(given).unwrap_or_else(|_| given.to_path_buf());
+        let root = dunce::canonicalize(root).unwrap_or_else(|_| root.to_path_buf());
+        let rel = cqs::paths::strip_root(&abs, &root)
+            .map(Path::to_path_buf)
+            .unwrap_or_else(|| abs.clone());
+        (abs, rel)
+    } else {
+        (root.join(given), given.to_path_buf())
+    }
+}
+
+fn content_check(abs: &Path, exists: bool, stored: Option<&FileFingerprint>) -> ContentCheck {
+    let disk = if exists {
+        FileFingerprint::read_disk(
+            abs,
+            &FileFingerprint::default(),
+            FingerprintPolicy::HashOnly,
+        )
+    } else {
+        None
+    };
+    let hex = |h: &[u8; 32]| blake3::Hash::from(*h).to_hex().to_string();
+    ContentCheck {
+        stored_mtime: stored.and_then(|s| s.mtime),
+        disk_mtime: disk.as_ref().and_then(|d| d.mtime),
+        stored_hash: stored.and_then(|s| s.content_hash.as_ref()).map(hex),
+        disk_hash: disk.as_ref().and_then(|d| d.content_hash.as_ref()).map(hex),
+        in_sync: stored
+            .zip(disk.as_ref())
+            .map(|(s, d)| s.matches(d, FingerprintPolicy::MtimeOrHash)),
+    }
+}
+
+/// One-line summary, checked in the order the walk and index apply them.
+fn verdict(out: &ExplainIndexOutput, record: &OriginRecord) -> String {
+    let indexed = record.chunk_count > 0 || record.fingerprint.is_some();
+    if !out.exists {
+        return if indexed {
+            "indexed, but the file is gone from disk; the next gc prunes it".to_string()
+        } else {
+            "no such file".to_string()
+        };
+    }
+    if let Some(exclusion) = &out.excluded_by {
+        let why = match exclusion {
+            WalkExclusion::Hidden { component } => format!("hidden path component '{component}'"),
+            WalkExclusion::NestedWorktree { dir } => format!("'{dir}' is a nested git worktree"),
+            WalkExclusion::Ignored {
+                file,
+                line,
+                pattern,
+                ..
+            } => match line {
+                Some(line) => format!("ignored by '{pattern}' ({file}:{line})"),
+                None => format!("ignored by '{pattern}' ({file})"),
+            },
+        };
+        return format!("not indexed: {why}");
+    }
+    if out.language.language.is_none() {
+        return "not indexed: no language detected for this file".to_string();
+    }
+    if out.size.over_cap {
+        return format!(
+            "not indexed: larger than the {}-byte cap (CQS_MAX_FILE_SIZE)",
+            out.size.cap
+        );
+    }
+    // The marker only holds while it names the running parser; a newer
+    // parser retries the file.
+    if record.parse_failed_parser_version == Some(cqs::parser::parser_version()) {
+        return "indexed with old chunks: the file fails to parse with this parser".to_string();
+    }
+    if !indexed {
+        return if out.pending.is_empty() {
+            "eligible but not indexed yet; run `cqs index`".to_string()
+        } else {
+            "eligible; a watch reindex is pending".to_string()
+        };
+    }
+    if out.content.in_sync == Some(false) {
+        return if out.pending.is_empty() {
+            "indexed, but stale: the file changed since it was indexed".to_string()
+        } else {
+            "indexed, but stale; a watch reindex is pending".to_string()
+        };
+    }
+    if record.chunk_count == 0 {
+        return "indexed, but the file produced no chunks".to_string();
+    }
+    "indexed and up to date".to_string()
+}
+
+fn print_text(out: &ExplainIndexOutput) {
+    use colored::Colorize;
+    let opt = |v: Option<String>| v.unwrap_or_else(|| "-".to_string());
+    println!("{}: {}", out.path.bold(), out.verdict);
+    let walk = match &out.excluded_by {
+        None => "kept".to_string(),
+        Some(WalkExclusion::Hidden { component }) => format!("hidden ({component})"),
+        Some(WalkExclusion::NestedWorktree { dir }) => format!("nested worktree ({dir})"),
+        Some(WalkExclusion::Ignored {
+            file,
+            line,
+            pattern,
+            matched,
+        }) => format!(
+            "ignored: '{pattern}' at {file}{} matches {matched}",
+            line.map(|l| format!(":{l}")).unwrap_or_default()
+        ),
+    };
+    println!("  walk:          {walk}");
+    let via = match &out.language.source {
+        cqs::walk_explain::LanguageSource::Override { pattern, target } => {
+            format!("override '{pattern}' = \"{target}\"")
+        }
+        cqs::walk_explain::LanguageSource::Extension { extension } => {
+            format!("extension .{extension}")
+        }
+        cqs::walk_explain::LanguageSource::Unsupported => "unsupported".to_string(),
+    };
+    println!(
+        "  language:      {} ({via})",
+        out.language.language.as_deref().unwrap_or("none")
+    );
+    println!(
+        "  size:          {} / cap {}{}",
+        opt(out.size.bytes.map(|b| b.to_string())),
+        out.size.cap,
+        if out.size.over_cap { " (over)" } else { "" }
+    );
+    println!("  chunks:        {}", out.chunks);
+    println!("  last indexed:  {}", opt(out.last_indexed.clone()));
+    let in_sync = match out.content.in_sync {
+        Some(true) => "matches disk",
+        Some(false) => "differs from disk",
+        None => "-",
+    };
+    println!("  content:       {in_sync}");
+    if let Some(version) = out.parse_failed_parser_version {
+        println!("  parse failed:  parser version {version}");
+    }
+    if out.pending.is_empty() {
+        println!("  pending:       none");
+    } else {
+        for event in &out.pending {
+            println!(
+                "  pending:       {} (queued at {})",
+                event.reason, event.queued_at
+            );
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn explains_unindexed_and_ignored_files() {
+        let dir = tempfile::tempdir().unwrap();
+        let root = dir.path();
+        let db = root.join(cqs::INDEX_DB_FILENAME);
+        let store = cqs::Store::open(&db).unwrap();
+        store.init(&cqs::store::ModelInfo::default()).unwrap();
+        drop(store);
+        let store = cqs::Store::open_readonly(&db).unwrap();
+
+        std::fs::create_dir_all(root.join("src")).unwrap();
+        std::fs::write(root.join("src/lib.rs"), "fn a() {}\n").unwrap();
+        std::fs::write(root.join("src/gen.rs"), "fn b() {}\n").unwrap();
+        std::fs::write(root.join(".cqsignore"), "src/gen.rs\n").unwrap();
+
+        let out = explain_index(&store, root, "src/lib.rs").unwrap();
+        assert!(out.exists);
+        assert!(out.excluded_by.is_none());
+        assert_eq!(out.language.language.as_deref(), Some("rust"));
+        assert_eq!(out.chunks, 0);
+        assert_eq!(out.verdict, "eligible but not indexed yet; run `cqs index`");
+
+        let out = explain_index(&store, root, "src/gen.rs").unwrap();
+        assert_eq!(
+            out.verdict,
+            "not indexed: ignored by 'src/gen.rs' (.cqsignore:1)"
+        );
+        let json = serde_json::to_value(&out).unwrap();
+        assert_eq!(json["excluded_by"]["kind"], "ignored");
+        assert_eq!(json["language"]["via"], "extension");
+
+        let out = explain_index(&store, root, "src/missing.rs").unwrap();
+        assert!(!out.exists);
+        assert_eq!(out.verdict, "no such file");
+    }
+}
@@ -1,7 +1,7 @@
 //! GC command for cqs
 //!
-//! Removes chunks for deleted/stale files, cleans orphan call graph entries,
-//! and rebuilds the HNSW index.
+//! Removes chunks for deleted/stale files, cleans orphan call graph entries
+//! and chunk dependents, and rebuilds the HNSW index.
 //!
 //! Core struct is [`GcOutput`]; CLI builds inline, batch builds inline.
 
@@ -11,7 +11,7 @@ use anyhow::{Context as _, Result};
 
 use cqs::{HnswKind, Parser};
 
-use crate::cli::acquire_index_lock;
+use crate::cli::{acquire_index_lock, LockWait};
 
 use super::build_hnsw_index;
 
@@ -26,6 +26,7 @@ pub(crate) struct GcOutput {
     pub pruned_chunks: usize,
     pub pruned_calls: usize,
     pub pruned_type_edges: usize,
+    pub pruned_dependents: usize,
     pub pruned_summaries: usize,
     pub hnsw_rebuilt: bool,
     #[serde(skip_serializing_if = "Option::is_none")]
@@ -86,6 +87,7 @@ pub(crate) fn gc_core(
     let pruned_chunks = prune.pruned_chunks as usize;
     let pruned_calls = prune.pruned_calls as usize;
     let pruned_type_edges = prune.pruned_type_edges as usize;
+    let pruned_dependents = prune.pruned_dependents as usize;
     let pruned_summaries = prune.pruned_summaries;
     // Prune orphaned sparse vectors
     let pruned_sparse = match store.prune_orphan_sparse_vectors() {
@@ -104,6 +106,7 @@ pub(crate) fn gc_core(
         pruned_chunks,
         pruned_calls,
         pruned_type_edges,
+        pruned_dependents,
         pruned_summaries,
         pruned_sparse,
         "GC prune complete"
@@ -165,6 +168,7 @@ pub(crate) fn gc_core(
         pruned_chunks,
         pruned_calls,
         pruned_type_edges,
+        pruned_dependents,
         pruned_summaries,
         hnsw_rebuilt: pruned_chunks > 0,
         hnsw_vectors,
@@ -185,7 +189,7 @@ pub(crate) fn cmd_gc(cli: &crate::cli::definitions::Cli, json: bool) -> Result<(
     let cqs_dir = &ctx.cqs_dir;
 
     // Acquire lock to prevent race with watch/index
-    let _lock = acquire_index_lock(cqs_dir)?;
+    let _lock = acquire_index_lock(cqs_dir, LockWait::from_cli(cli))?;
 
     let output = gc_core(store, root, cqs_dir, &GcArgs::default())?;
 
@@ -206,12 +210,14 @@ fn render_gc_text(output: &GcOutput) {
     let pruned_chunks = output.pruned_chunks;
     let pruned_calls = output.pruned_calls;
     let pruned_type_edges = output.pruned_type_edges;
+    let pruned_dependents = output.pruned_dependents;
     let pruned_summaries = output.pruned_summaries;
     let hnsw_vectors = output.hnsw_vectors;
     {
         if pruned_chunks == 0
             && pruned_calls == 0
             && pruned_type_edges == 0
+            && pruned_dependents == 0
             && pruned_summaries == 0
         {
             println!("Index is clean. Nothing to do.");
@@ -239,6 +245,13 @@ fn render_gc_text(output: &GcOutput) {
                     if pruned_type_edges == 1 { "" } else { "s" },
                 );
             }
+            if pruned_dependents > 0 {
+                println!(
+                    "Removed {} orphan chunk dependent{}",
+                    pruned_dependents,
+                    if pruned_dependents == 1 { "" } else { "s" },
+                );
+            }
             if pruned_summaries > 0 {
                 println!(
                     "Removed {} orphan LLM summar{}",
@@ -284,6 +297,7 @@ mod tests {
             pruned_chunks: 15,
             pruned_calls: 30,
             pruned_type_edges: 5,
+            pruned_dependents: 4,
             pruned_summaries: 3,
             hnsw_rebuilt: true,
             hnsw_vectors: Some(500),
@@ -302,6 +316,7 @@ mod tests {
             pruned_chunks: 0,
             pruned_calls: 0,
             pruned_type_edges: 0,
+            pruned_dependents: 0,
             pruned_summaries: 0,
             hnsw_rebuilt: false,
             hnsw_vectors: None,
@@ -310,7 +325,7 @@ mod tests {
         assert!(json.get("hnsw_vectors").is_none());
     }
 
-    // Assert ALL 8 fields of GcOutput are correctly named in JSON
+    // Assert ALL 9 fields of GcOutput are correctly named in JSON
     #[test]
     fn test_gc_output_all_fields() {
         let output = GcOutput {
@@ -319,6 +334,7 @@ mod tests {
             pruned_chunks: 15,
             pruned_calls: 30,
             pruned_type_edges: 5,
+            pruned_dependents: 4,
             pruned_summaries: 3,
             hnsw_rebuilt: true,
             hnsw_vectors: Some(500),
@@ -331,6 +347,7 @@ mod tests {
         assert_eq!(json["pruned_chunks"], 15);
         assert_eq!(json["pruned_calls"], 30);
         assert_eq!(json["pruned_type_edges"], 5);
+        assert_eq!(json["pruned_dependents"], 4);
         assert_eq!(json["pruned_summaries"], 3);
         assert_eq!(json["hnsw_rebuilt"], true);
         assert_eq!(json["hnsw_vectors"], 500);
@@ -339,8 +356,8 @@ mod tests {
         let obj = json.as_object().unwrap();
         assert_eq!(
             obj.len(),
-            8,
-            "GcOutput should serialize to exactly 8 fields, got: {:?}",
+            9,
+            "GcOutput should serialize to exactly 9 fields, got: {:?}",
             obj.keys().collect::<Vec<_>>()
         );
     }
@@ -1,8 +1,13 @@
-//! Index commands — indexing, stats, staleness, garbage collection
+//! Index commands — indexing, stats, staleness, per-file index explanation,
+//! garbage collection, chunk text compression, sandboxed parse worker
 
 mod build;
+mod compress;
+mod explain_index;
 mod gc;
 mod index_args;
+mod only;
+mod parse_worker;
 mod stale;
 mod stats;
 mod umap;
@@ -11,10 +16,13 @@ pub(crate) use build::{
     build_hnsw_base_index, build_hnsw_index, build_hnsw_index_owned, cmd_index,
     snapshot_fingerprint,
 };
+pub(crate) use compress::cmd_compress;
+pub(crate) use explain_index::cmd_explain_index;
 pub(crate) use gc::cmd_gc;
+pub(crate) use parse_worker::cmd_parse_worker;
 // The Phase-0 JsonSchema core for the `cqs_index` MCP tool (Phase 2b). Distinct
 // from the clap-side `crate::cli::args::IndexArgs` — this is the non-destructive
 // wire slice the bridge advertises and the daemon deserializes.
 pub(crate) use index_args::IndexArgs;
 pub(crate) use stale::{cmd_stale, stale_core, StaleArgs};
-pub(crate) use stats::{cmd_stats, stats_core, StatsArgs};
+pub(crate) use stats::{cmd_stats, cmd_stats_hot, stats_core, StatsArgs, StatsOutput};
@@ -0,0 +1,387 @@
+//! `cqs index --only` / `--stdin` — targeted re-index for editor integrations
+//!
+//! Editor on-save hooks know exactly which file changed; walking the project
+//! and rebuilding both HNSW graphs for that is wasted work. `--only` skips
+//! the walk, re-indexes just the named origins through the same incremental
+//! pipeline the watch loop uses, and leaves the HNSW graphs marked stale for
+//! the next full build to absorb.
+//!
+//! `--stdin --origin <path>` goes one step further for unsaved buffers: the
+//! piped content is indexed as the origin's chunks, then the origin's file
+//! fingerprint is cleared. A NULL fingerprint reads as stale to every
+//! freshness check, so the next real index of that origin re-reads the disk
+//! file and the buffer overlay disappears without any extra bookkeeping.
+
+use std::collections::HashSet;
+use std::io::Read;
+use std::path::{Path, PathBuf};
+
+use anyhow::{bail, Context, Result};
+
+use cqs::cache::EmbeddingCache;
+use cqs::store::FileFingerprint;
+use cqs::{Embedder, Parser as CqParser, Store};
+
+use crate::cli::args::IndexArgs;
+use crate::cli::Cli;
+
+/// `cqs index --only --json` / `--stdin --json` summary envelope.
+#[derive(Debug, serde::Serialize)]
+pub(crate) struct IndexOnlyOutput {
+    pub files: Vec<String>,
+    pub indexed_chunks: usize,
+    pub took_ms: u64,
+    pub hnsw_stale: bool,
+    /// `true` for a `--stdin` buffer overlay, dropped by the next real index.
+    pub transient: bool,
+}
+
+/// Resolve `--only` arguments to deduplicated root-relative origins.
+///
+/// Relative arguments resolve against `cwd` (how an editor or shell passes
+/// them), absolute ones are taken as-is. Both sides are canonicalized before
+/// the prefix strip so symlinked checkouts and `..` segments agree; a file
+/// that no longer exists canonicalizes through its parent so a deleted path
+/// can still drop its chunks. Bails on a path outside `root`, a directory, or
+/// an extension no parser handles — silently skipping any of those would let
+/// an editor hook believe the refresh happened.
+pub(crate) fn resolve_only_paths(
+    root: &Path,
+    cwd: &Path,
+    paths: &[PathBuf],
+    exts: &[&str],
+) -> Result<Vec<PathBuf>> {
+    let root_canon = dunce::canonicalize(root).unwrap_or_else(|_| root.to_path_buf());
+    let mut seen = HashSet::new();
+    let mut origins = Vec::with_capacity(paths.len());
+    for p in paths {
+        let abs = if p.is_absolute() {
+            p.clone()
+        } else {
+            cwd.join(p)
+        };
+        let canon = match dunce::canonicalize(&abs) {
+            Ok(c) => c,
+            Err(_) => match (abs.parent(), abs.file_name()) {
+                (Some(parent), Some(name)) => dunce::canonicalize(parent)
+                    .with_context(|| format!("Cannot resolve --only path {}", p.display()))?
+                    .join(name),
+                _ => bail!("Cannot resolve --only path {}", p.display()),
+            },
+        };
+        if canon.is_dir() {
+            bail!(
+                "--only takes files, not directories: {} (run `cqs index` for a full pass)",
+                p.display()
+            );
+        }
+        let rel = match cqs::paths::strip_root(&canon, &root_canon) {
+            Some(r) => r.to_path_buf(),
+            None => bail!(
+                "--only path {} is outside the project root {}",
+                p.display(),
+                root.display()
+            ),
+        };
+        let ext = rel.extension().and_then(|e| e.to_str()).unwrap_or("");
+        let supported = match cqs::language::overrides::lookup(&rel) {
+            Some(decision) => decision.is_some(),
+            None => exts.contains(&ext),
+        };
+        if !supported {
+            bail!(
+                "--only path {} has no supported language (extension `{}`)",
+                p.display(),
+                ext
+            );
+        }
+        if seen.insert(rel.clone()) {
+            origins.push(rel);
+        }
+    }
+    Ok(origins)
+}
+
+/// Open the existing index plus the embedder and project cache for a
+/// targeted write, with the same model-drift guard and vendored stamping as
+/// the full pipeline.
+fn open_targeted(
+    cli: &Cli,
+    root: &Path,
+    index_path: &Path,
+) -> Result<(Store, Embedder, Option<EmbeddingCache>)> {
+    if !index_path.exists() {
+        bail!(
+            "No index at {}. Run `cqs index` first.",
+            index_path.display()
+        );
+    }
+    let mut store = Store::open(index_path)
+        .with_context(|| format!("Failed to open store at {}", index_path.display()))?;
+    let mc = cli.try_model_config()?;
+    let stored = store.try_stored_model_name().with_context(|| {
+        format!(
+            "Failed to read model_name from {} metadata while checking for model drift",
+            index_path.display()
+        )
+    })?;
+    super::build::check_index_model_drift(stored.as_deref(), &mc.name, &mc.repo, index_path)?;
+    // Same vendored stamping as the full pipeline so re-written rows keep
+    // their `vendored` flag.
+    let project_cfg = cqs::config::Config::load(root);
+    let vendored_override = project_cfg
+        .index
+        .as_ref()
+        .and_then(|ic| ic.vendored_paths.as_deref());
+    store.set_vendored_prefixes(cqs::vendored::effective_prefixes(vendored_override));
+
+    let embedder = Embedder::new(mc.clone()).context("Failed to create embedder")?;
+    let global_cache = if std::env::var("CQS_CACHE_ENABLED").as_deref() == Ok("0") {
+        None
+    } else {
+        let project_cqs_dir = cqs::resolve_index_dir(root);
+        let cache_path = EmbeddingCache::project_default_path(&project_cqs_dir);
+        match EmbeddingCache::open(&cache_path) {
+            Ok(c) => Some(c),
+            Err(e) => {
+                tracing::warn!(
+                    error = %e,
+                    path = %cache_path.display(),
+                    "Project embeddings cache unavailable; embedding without it"
+                );
+                None
+            }
+        }
+    };
+
+    Ok((store, embedder, global_cache))
+}
+
+/// Run the `--only` branch of `cqs index`.
+///
+/// Called by `cmd_index` after the index lock is held. Requires an existing
+/// index — a targeted refresh of one file is not a way to bootstrap one.
+pub(super) fn cmd_index_only(
+    cli: &Cli,
+    args: &IndexArgs,
+    root: &Path,
+    index_path: &Path,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_index_only", paths = args.only.len()).entered();
+    let started = std::time::Instant::now();
+    let want_json = cli.json || args.json;
+
+    let parser = CqParser::new()?;
+    let cwd = std::env::current_dir().context("Failed to read current directory")?;
+    let origins = resolve_only_paths(root, &cwd, &args.only, &parser.supported_extensions())?;
+
+    let (store, embedder, global_cache) = open_targeted(cli, root, index_path)?;
+
+    let count = crate::cli::watch::reindex_origins(
+        root,
+        &store,
+        &origins,
+        &parser,
+        &embedder,
+        global_cache.as_ref(),
+        cli.quiet || want_json,
+    )?;
+
+    if want_json {
+        let output = IndexOnlyOutput {
+            files: origins.iter().map(|p| cqs::normalize_path(p)).collect(),
+            indexed_chunks: count,
+            took_ms: started.elapsed().as_millis() as u64,
+            hnsw_stale: true,
+            transient: false,
+        };
+        crate::cli::json_envelope::emit_json(&output)?;
+    } else if !cli.quiet {
+        println!(
+            "Re-indexed {} chunk(s) from {} file(s); vector search uses brute force until the next `cqs index`.",
+            count,
+            origins.len()
+        );
+    }
+    Ok(())
+}
+
+/// Read a `--stdin` buffer, capped at the parser's file-size limit.
+///
+/// Bails rather than truncating: a half-indexed buffer would silently drop
+/// every chunk past the cut. Non-UTF-8 input is an error for the same reason
+/// the file parser skips such files.
+pub(crate) fn read_buffer(reader: impl Read) -> Result<String> {
+    let cap = cqs::limits::parser_max_file_size();
+    let mut bytes = Vec::new();
+    reader
+        .take(cap.saturating_add(1))
+        .read_to_end(&mut bytes)
+        .context("Failed to read buffer from stdin")?;
+    if bytes.len() as u64 > cap {
+        bail!(
+            "stdin buffer exceeds the {} MB parser limit (CQS_PARSER_MAX_FILE_SIZE)",
+            cap / (1024 * 1024)
+        );
+    }
+    String::from_utf8(bytes).context("stdin buffer is not valid UTF-8")
+}
+
+/// Index `buffer` as the content of `origin`, tagged as a transient overlay.
+///
+/// The buffer is written to a scratch root under the origin's relative path
+/// so it runs through [`crate::cli::watch::reindex_origins`] unchanged — same
+/// chunk ids, call graph, FTS and rename tracking as a saved file. The
+/// fingerprint stamped from the scratch file is then cleared, which is the
+/// overlay tag: freshness checks read a NULL fingerprint as stale, so the
+/// next real index of `origin` replaces these chunks from disk.
+fn index_buffer(
+    store: &Store,
+    origin: &Path,
+    buffer: &str,
+    parser: &CqParser,
+    embedder: &Embedder,
+    global_cache: Option<&EmbeddingCache>,
+    quiet: bool,
+) -> Result<usize> {
+    let scratch = tempfile::TempDir::new().context("Failed to create scratch dir for buffer")?;
+    let scratch_file = scratch.path().join(origin);
+    if let Some(parent) = scratch_file.parent() {
+        std::fs::create_dir_all(parent)
+            .with_context(|| format!("Failed to create {}", parent.display()))?;
+    }
+    std::fs::write(&scratch_file, buffer)
+        .with_context(|| format!("Failed to write {}", scratch_file.display()))?;
+    let count = crate::cli::watch::reindex_origins(
+        scratch.path(),
+        store,
+        &[origin.to_path_buf()],
+        parser,
+        embedder,
+        global_cache,
+        quiet,
+    )?;
+    store
+        .set_file_fingerprint(origin, &FileFingerprint::default())
+        .context("Failed to mark buffer overlay transient")?;
+    Ok(count)
+}
+
+/// Run the `--stdin --origin <path>` branch of `cqs index`.
+///
+/// Called by `cmd_index` after the index lock is held. Never delegated to a
+/// daemon — the buffer exists only in this process.
+pub(super) fn cmd_index_stdin(
+    cli: &Cli,
+    args: &IndexArgs,
+    root: &Path,
+    index_path: &Path,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_index_stdin").entered();
+    let started = std::time::Instant::now();
+    let want_json = cli.json || args.json;
+
+    let origin_arg = args
+        .origin
+        .as_ref()
+        .context("--stdin requires --origin <PATH>")?;
+    let parser = CqParser::new()?;
+    let cwd = std::env::current_dir().context("Failed to read current directory")?;
+    let origin = resolve_only_paths(
+        root,
+        &cwd,
+        std::slice::from_ref(origin_arg),
+        &parser.supported_extensions(),
+    )?
+    .remove(0);
+    let buffer = read_buffer(std::io::stdin().lock())?;
+
+    let (store, embedder, global_cache) = open_targeted(cli, root, index_path)?;
+    let count = index_buffer(
+        &store,
+        &origin,
+        &buffer,
+        &parser,
+        &embedder,
+        global_cache.as_ref(),
+        cli.quiet || want_json,
+    )?;
+
+    if want_json {
+        let output = IndexOnlyOutput {
+            files: vec![cqs::normalize_path(&origin)],
+            indexed_chunks: count,
+            took_ms: started.elapsed().as_millis() as u64,
+            hnsw_stale: true,
+            transient: true,
+        };
+        crate::cli::json_envelope::emit_json(&output)?;
+    } else if !cli.quiet {
+        println!(
+            "Indexed {} chunk(s) from the unsaved buffer for {}; the next `cqs index` restores the on-disk version.",
+            count,
+            origin.display()
+        );
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tempfile::TempDir;
+
+    fn project() -> TempDir {
+        let dir = TempDir::new().unwrap();
+        std::fs::create_dir_all(dir.path().join("src")).unwrap();
+        std::fs::write(dir.path().join("src/lib.rs"), "pub fn a() {}").unwrap();
+        dir
+    }
+
+    #[test]
+    fn resolves_relative_and_absolute_paths_to_origins() {
+        let dir = project();
+        let root = dir.path();
+        let abs = root.join("src/lib.rs");
+        let got = resolve_only_paths(
+            root,
+            &root.join("src"),
+            &[PathBuf::from("lib.rs"), abs],
+            &["rs"],
+        )
+        .unwrap();
+        assert_eq!(got, vec![PathBuf::from("src/lib.rs")], "deduplicated");
+    }
+
+    #[test]
+    fn deleted_file_still_resolves() {
+        let dir = project();
+        let got = resolve_only_paths(
+            dir.path(),
+            dir.path(),
+            &[PathBuf::from("src/gone.rs")],
+            &["rs"],
+        )
+        .unwrap();
+        assert_eq!(got, vec![PathBuf::from("src/gone.rs")]);
+    }
+
+    #[test]
+    fn rejects_outside_root_directory_and_unsupported() {
+        let dir = project();
+        let other = TempDir::new().unwrap();
+        std::fs::write(other.path().join("x.rs"), "").unwrap();
+        let root = dir.path();
+        assert!(resolve_only_paths(root, root, &[other.path().join("x.rs")], &["rs"]).is_err());
+        assert!(resolve_only_paths(root, root, &[PathBuf::from("src")], &["rs"]).is_err());
+        std::fs::write(root.join("notes.xyz"), "").unwrap();
+        assert!(resolve_only_paths(root, root, &[PathBuf::from("notes.xyz")], &["rs"]).is_err());
+    }
+
+    #[test]
+    fn read_buffer_accepts_utf8_and_rejects_binary() {
+        let text = read_buffer(std::io::Cursor::new(b"fn unsaved() {}".to_vec())).unwrap();
+        assert_eq!(text, "fn unsaved() {}");
+        assert!(read_buffer(std::io::Cursor::new(vec![0xff, 0xfe, 0x00])).is_err());
+    }
+}
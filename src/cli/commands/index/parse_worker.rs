@@ -0,0 +1,24 @@
+//! `cqs parse-worker` — the child side of `cqs index --sandbox`.
+//!
+//! Started by `cqs::parser::sandbox::SandboxPool`, never by hand. Confines
+//! itself, then parses one file per stdin line and answers on stdout until
+//! the parent closes the pipe. Logs go to stderr, which the parent shares.
+
+use anyhow::{Context, Result};
+
+use cqs::parser::sandbox;
+use cqs::Parser as CqParser;
+
+pub(crate) fn cmd_parse_worker() -> Result<()> {
+    let _span = tracing::info_span!("cmd_parse_worker").entered();
+    let limits = sandbox::SandboxLimits::from_env();
+    // Limits go on before the parser exists, so nothing it loads escapes them.
+    sandbox::restrict_current_process(&limits).context("Failed to confine parse worker")?;
+    let parser = CqParser::new().context("Failed to initialize parser")?;
+    sandbox::serve(
+        &parser,
+        std::io::stdin().lock(),
+        std::io::BufWriter::new(std::io::stdout().lock()),
+    )
+    .context("Parse worker pipe failed")
+}
@@ -1,6 +1,7 @@
 //! Stats command for cqs
 //!
-//! Displays index statistics.
+//! Displays index statistics; `--hot` lists the most-accessed chunks from the
+//! access stats (`cqs::access`) instead.
 //!
 //! Core struct is [`StatsOutput`]; build with [`build_stats`].
 //! CLI uses `print_stats_text()` for human output, batch serializes with `serde_json::to_value()`.
@@ -18,20 +19,29 @@ use cqs::{HnswIndex, Parser};
 // Output structs
 // ---------------------------------------------------------------------------
 
-#[derive(Debug, serde::Serialize)]
+#[derive(Debug, serde::Serialize, schemars::JsonSchema)]
 pub(crate) struct CallGraphStats {
     pub total_calls: usize,
     pub unique_callers: usize,
     pub unique_callees: usize,
 }
 
-#[derive(Debug, serde::Serialize)]
+#[derive(Debug, serde::Serialize, schemars::JsonSchema)]
 pub(crate) struct TypeGraphStats {
     pub total_edges: usize,
     pub unique_types: usize,
 }
 
-#[derive(Debug, serde::Serialize)]
+/// Chunk and file counts of one shard of a sharded index (`cqs::shard`).
+#[derive(Debug, serde::Serialize, schemars::JsonSchema)]
+pub(crate) struct ShardStats {
+    pub name: String,
+    pub prefix: String,
+    pub total_chunks: usize,
+    pub total_files: usize,
+}
+
+#[derive(Debug, serde::Serialize, schemars::JsonSchema)]
 pub(crate) struct StatsOutput {
     pub total_chunks: usize,
     pub total_files: usize,
@@ -77,7 +87,25 @@ pub(crate) struct StatsOutput {
     /// when there are no chunks at all (avoids spurious 0/0 reporting on a
     /// fresh DB).
     pub llm_summary_chunk_coverage_pct: Option<f64>,
+    /// Summary rows marked superseded: the code they describe has changed or
+    /// gone, and no chunk carries their content_hash. Re-generated for the
+    /// new code by `cqs index --llm-summaries` or, under `cqs watch`, on idle.
+    pub llm_summaries_superseded: usize,
+    /// Chunks tagged as codegen output (`chunks.boilerplate`). Search leaves
+    /// them out unless `--include-generated`.
+    pub generated_chunks: usize,
+    /// Chunks tagged as license text, likewise left out of search.
+    pub license_chunks: usize,
+    /// `generated_chunks` as a percentage of `total_chunks`. `None` when
+    /// there are no chunks at all.
+    pub generated_pct: Option<f64>,
+    /// Schema version of the index database. `schema_version` in the v1
+    /// JSON shape; renamed so it can't be mistaken for the output marker.
+    #[serde(rename = "index_schema_version")]
     pub schema_version: u32,
+    /// Current index generation (`<epoch>-<counter>`), the ID searches report
+    /// as `_meta.index_generation`. `None` when it can't be read.
+    pub index_generation: Option<String>,
     // CLI-specific (batch omits these via Option)
     #[serde(skip_serializing_if = "Option::is_none")]
     pub stale_files: Option<usize>,
@@ -90,6 +118,10 @@ pub(crate) struct StatsOutput {
     // Batch-specific
     #[serde(skip_serializing_if = "Option::is_none")]
     pub errors: Option<usize>,
+    /// Every other shard of a sharded index; the fields above describe the
+    /// root shard. Empty (and omitted) when the index is not sharded.
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub shards: Vec<ShardStats>,
 }
 
 // ---------------------------------------------------------------------------
@@ -116,8 +148,9 @@ fn file_size_bytes(path: &Path) -> Option<u64> {
 /// Build the core stats shared between CLI and batch.
 ///
 /// Contains: total_chunks, total_files, notes, call_graph, type_graph,
-/// by_language, by_type, model, schema_version, plus the index-introspection
-/// fields (`dim`, `splade_*`, `hnsw_*`, `cagra_size_bytes`, `llm_summary_count`).
+/// by_language, by_type, model, index_schema_version, plus the index-introspection
+/// fields (`dim`, `splade_*`, `hnsw_*`, `cagra_size_bytes`, `llm_summary_count`) and
+/// the boilerplate counts (`generated_chunks`, `license_chunks`, `generated_pct`).
 /// Callers add context-specific fields (stale_files, errors, etc.).
 pub(crate) fn build_stats<Mode>(store: &cqs::Store<Mode>, cqs_dir: &Path) -> Result<StatsOutput> {
     let _span = tracing::info_span!("build_stats").entered();
@@ -158,11 +191,30 @@ pub(crate) fn build_stats<Mode>(store: &cqs::Store<Mode>, cqs_dir: &Path) -> Res
             0
         }
     };
+    let llm_summaries_superseded = match store.llm_summary_superseded_count() {
+        Ok(n) => n as usize,
+        Err(e) => {
+            tracing::warn!(error = %e, "Failed to count superseded llm_summaries");
+            0
+        }
+    };
     let llm_summary_chunk_coverage_pct = if total_chunks > 0 {
         Some((llm_summary_chunks_covered as f64 / total_chunks as f64) * 100.0)
     } else {
         None
     };
+    let (generated_chunks, license_chunks) = match store.boilerplate_chunk_counts() {
+        Ok(counts) => counts,
+        Err(e) => {
+            tracing::warn!(error = %e, "Failed to count generated and license chunks");
+            (0, 0)
+        }
+    };
+    let generated_pct = if total_chunks > 0 {
+        Some((generated_chunks as f64 / total_chunks as f64) * 100.0)
+    } else {
+        None
+    };
 
     Ok(StatsOutput {
         total_chunks: total_chunks as usize,
@@ -198,6 +250,10 @@ pub(crate) fn build_stats<Mode>(store: &cqs::Store<Mode>, cqs_dir: &Path) -> Res
         llm_summary_count,
         llm_summary_chunks_covered,
         llm_summary_chunk_coverage_pct,
+        llm_summaries_superseded,
+        generated_chunks: generated_chunks as usize,
+        license_chunks: license_chunks as usize,
+        generated_pct,
         // schema_version is read as i64 from SQLite; an explicit cast would
         // silently wrap a negative value. Surface the breach instead.
         schema_version: u32::try_from(stats.schema_version).unwrap_or_else(|_| {
@@ -207,14 +263,48 @@ pub(crate) fn build_stats<Mode>(store: &cqs::Store<Mode>, cqs_dir: &Path) -> Res
             );
             0
         }),
+        index_generation: match store.index_generation() {
+            Ok(generation) => Some(generation.to_string()),
+            Err(e) => {
+                tracing::warn!(error = %e, "Failed to read index generation");
+                None
+            }
+        },
         stale_files: None,
         missing_files: None,
         created_at: None,
         hnsw_vectors: None,
         errors: None,
+        shards: shard_stats(cqs_dir),
     })
 }
 
+/// Counts for each indexed shard under `cqs_dir`. A shard whose store can't
+/// be read is left out with a warning rather than failing the command.
+fn shard_stats(cqs_dir: &Path) -> Vec<ShardStats> {
+    cqs::shard::list_shards(cqs_dir)
+        .into_iter()
+        .filter_map(|info| {
+            let db_path = info.dir.join(cqs::INDEX_DB_FILENAME);
+            if !db_path.exists() {
+                return None;
+            }
+            match cqs::Store::open_readonly_small(&db_path).and_then(|store| store.stats()) {
+                Ok(stats) => Some(ShardStats {
+                    name: info.name,
+                    prefix: info.prefix,
+                    total_chunks: stats.total_chunks as usize,
+                    total_files: stats.total_files as usize,
+                }),
+                Err(e) => {
+                    tracing::warn!(shard = %info.name, error = %e, "Failed to read shard statistics");
+                    None
+                }
+            }
+        })
+        .collect()
+}
+
 // ---------------------------------------------------------------------------
 // Args + core (surface-agnostic, MCP-ready)
 // ---------------------------------------------------------------------------
@@ -330,6 +420,30 @@ fn print_stats_text(output: &StatsOutput) {
     println!();
     println!("Total chunks: {}", output.total_chunks);
     println!("Total files:  {}", output.total_files);
+    if !output.shards.is_empty() {
+        // The totals above are the root shard's; list the rest and the sum.
+        println!();
+        println!("Shards (root shard counted above):");
+        for shard in &output.shards {
+            println!(
+                "  {}: {} chunks, {} files",
+                shard.prefix, shard.total_chunks, shard.total_files
+            );
+        }
+        println!(
+            "  all shards: {} chunks, {} files",
+            output.total_chunks + output.shards.iter().map(|s| s.total_chunks).sum::<usize>(),
+            output.total_files + output.shards.iter().map(|s| s.total_files).sum::<usize>(),
+        );
+    }
+    if output.generated_chunks > 0 || output.license_chunks > 0 {
+        println!(
+            "Generated:    {} ({:.1}%), license: {} (excluded from search)",
+            output.generated_chunks,
+            output.generated_pct.unwrap_or(0.0),
+            output.license_chunks
+        );
+    }
     println!();
     println!("By language:");
     for (lang, count) in &output.by_language {
@@ -343,6 +457,9 @@ fn print_stats_text(output: &StatsOutput) {
     println!();
     println!("Model: {}", output.model);
     println!("Schema: v{}", output.schema_version);
+    if let Some(ref generation) = output.index_generation {
+        println!("Generation: {generation}");
+    }
     if let Some(ref created) = output.created_at {
         println!("Created: {created}");
     }
@@ -432,6 +549,47 @@ pub(crate) fn cmd_stats(
     Ok(())
 }
 
+/// `cqs stats --hot --json` payload.
+#[derive(Debug, serde::Serialize)]
+pub(crate) struct HotOutput {
+    pub path: String,
+    pub chunks: Vec<cqs::access::ChunkAccess>,
+}
+
+/// List the most-accessed chunks recorded in `.cqs/access_stats.db`.
+pub(crate) fn cmd_stats_hot(
+    ctx: &crate::cli::CommandContext<'_, cqs::store::ReadOnly>,
+    limit: usize,
+    json: bool,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_stats_hot", limit).entered();
+    let path = cqs::access::access_stats_path(&ctx.cqs_dir);
+    let chunks = if path.exists() {
+        cqs::access::AccessStats::open(&path)
+            .and_then(|stats| stats.hottest(limit))
+            .with_context(|| format!("Failed to read {}", path.display()))?
+    } else {
+        Vec::new()
+    };
+
+    if json || ctx.cli.json {
+        crate::cli::json_envelope::emit_json(&HotOutput {
+            path: path.display().to_string(),
+            chunks,
+        })?;
+        return Ok(());
+    }
+    if chunks.is_empty() {
+        println!("No chunk accesses recorded yet ({})", path.display());
+        return Ok(());
+    }
+    println!("{:>6} {:>6}  chunk", "hits", "opens");
+    for c in &chunks {
+        println!("{:>6} {:>6}  {} ({})", c.hits, c.opens, c.name, c.origin);
+    }
+    Ok(())
+}
+
 // ---------------------------------------------------------------------------
 // Tests
 // ---------------------------------------------------------------------------
@@ -468,18 +626,27 @@ mod tests {
             llm_summary_count: 0,
             llm_summary_chunks_covered: 0,
             llm_summary_chunk_coverage_pct: None,
+            llm_summaries_superseded: 0,
+            generated_chunks: 0,
+            license_chunks: 0,
+            generated_pct: None,
             schema_version: 17,
+            index_generation: None,
             stale_files: None,
             missing_files: None,
             created_at: None,
             hnsw_vectors: None,
             errors: None,
+            shards: Vec::new(),
         };
         let json = serde_json::to_value(&output).unwrap();
         // Verify normalized field names
         assert!(json.get("total_chunks").is_some());
         assert!(json.get("call_graph").is_some());
         assert!(json.get("by_language").is_some());
+        // The database schema is renamed off the output marker's key.
+        assert_eq!(json["index_schema_version"], 17);
+        assert!(json.get("schema_version").is_none());
         // Index-introspection fields are always present
         // (Option fields serialize to null, not omitted).
         assert_eq!(json["dim"], 1024);
@@ -522,12 +689,18 @@ mod tests {
             llm_summary_count: 12_345,
             llm_summary_chunks_covered: 11_500,
             llm_summary_chunk_coverage_pct: Some(95.83),
+            llm_summaries_superseded: 42,
+            generated_chunks: 5,
+            license_chunks: 1,
+            generated_pct: Some(10.0),
             schema_version: 17,
+            index_generation: Some("9c1e04ab-1842".into()),
             stale_files: Some(3),
             missing_files: Some(1),
             created_at: Some("2026-01-01".into()),
             hnsw_vectors: Some(48),
             errors: None,
+            shards: Vec::new(),
         };
         let json = serde_json::to_value(&output).unwrap();
         assert_eq!(json["stale_files"], 3);
@@ -544,6 +717,10 @@ mod tests {
         assert_eq!(json["hnsw_graph_bytes"], 8_084_767);
         assert_eq!(json["cagra_size_bytes"], 67_527_348);
         assert_eq!(json["llm_summary_count"], 12_345);
+        assert_eq!(json["generated_chunks"], 5);
+        assert_eq!(json["generated_pct"], 10.0);
+        assert_eq!(json["index_generation"], "9c1e04ab-1842");
+        assert_eq!(json["llm_summaries_superseded"], 42);
         assert!(json.get("errors").is_none());
     }
 
@@ -0,0 +1,564 @@
+//! `cqs backup` / `cqs restore` — point-in-time copies of `index.db`.
+//!
+//! `cqs backup --out <file>` writes a single-file snapshot with the same
+//! `VACUUM INTO` path the migration backups use. The copy is taken under a
+//! SQLite read transaction, so it is consistent while `cqs watch` keeps
+//! committing and never blocks the writer.
+//!
+//! `cqs restore <file>` vets the snapshot first — integrity, a schema this
+//! binary can open or migrate, and the same embedding model and dimension as
+//! the index it replaces — and only then stops the daemon, takes
+//! `index.lock`, and renames the snapshot over the live DB. The HNSW, CAGRA
+//! and SPLADE files describe the old DB, so they are deleted; search falls
+//! back to brute force until the next `cqs index` rebuilds them.
+//!
+//! `cqs replica <source>` is the live variant for a primary/replica layout,
+//! where CI (or another machine) indexes and this one only serves. The
+//! source is a synced copy of the primary's `index.db` or of its whole
+//! `.cqs` directory. It is checkpointed and vetted beside the live DB, the
+//! primary's vector index files (directory source) are put in place, and the
+//! DB is renamed over under `index.lock` — without stopping the daemon,
+//! which reopens on the new file while in-flight queries finish on the old.
+
+use std::path::{Path, PathBuf};
+
+use anyhow::{bail, Context, Result};
+use serde::Serialize;
+
+use cqs::store::SnapshotInfo;
+use cqs::Store;
+
+use super::model::{human_bytes, restart_daemon_if_needed, stop_daemon_best_effort};
+use crate::cli::config::find_project_root;
+use crate::cli::definitions::Cli;
+use crate::cli::json_envelope::error_codes;
+
+/// `cqs backup --json` payload.
+#[derive(Debug, Serialize)]
+struct BackupOutput {
+    path: String,
+    size_bytes: u64,
+    chunks: u64,
+    /// `schema_version` in the v1 JSON shape.
+    #[serde(rename = "index_schema_version")]
+    schema_version: i32,
+    model: Option<String>,
+}
+
+/// `cqs restore --json` payload.
+#[derive(Debug, Serialize)]
+struct RestoreOutput {
+    snapshot: String,
+    index_path: String,
+    chunks: u64,
+    /// `schema_version` in the v1 JSON shape.
+    #[serde(rename = "index_schema_version")]
+    schema_version: i32,
+    model: Option<String>,
+    /// Snapshot schema is older than this binary's; the next read-write open
+    /// migrates it.
+    needs_migration: bool,
+    /// Derived index files deleted because they described the old DB.
+    removed: Vec<String>,
+}
+
+/// `cqs replica --json` payload.
+#[derive(Debug, Serialize)]
+struct ReplicaOutput {
+    source: String,
+    index_path: String,
+    chunks: u64,
+    /// `schema_version` in the v1 JSON shape.
+    #[serde(rename = "index_schema_version")]
+    schema_version: i32,
+    model: Option<String>,
+    /// Derived index files copied from a directory source.
+    copied: Vec<String>,
+    /// Derived index files deleted because the source had no counterpart.
+    removed: Vec<String>,
+}
+
+/// Report `msg` as a JSON envelope error (exit 1) or an anyhow error.
+fn fail(json: bool, code: &str, msg: String) -> Result<()> {
+    if json {
+        crate::cli::json_envelope::emit_json_error(code, &msg)?;
+        std::process::exit(1);
+    }
+    bail!("{msg}");
+}
+
+// ---------------------------------------------------------------------------
+// `cqs backup`
+// ---------------------------------------------------------------------------
+
+/// Snapshot the project index into `out`.
+pub(crate) fn cmd_backup(out: &Path, json: bool) -> Result<()> {
+    let _span = tracing::info_span!("cmd_backup", out = %out.display()).entered();
+
+    let root = find_project_root();
+    let cqs_dir = cqs::resolve_index_dir(&root);
+    let index_path = cqs::resolve_index_db(&cqs_dir);
+    if !index_path.exists() {
+        return fail(
+            json,
+            "no_index",
+            format!(
+                "No index at {}. Run `cqs init && cqs index` first.",
+                index_path.display()
+            ),
+        );
+    }
+    if out.exists() {
+        return fail(
+            json,
+            error_codes::INVALID_INPUT,
+            format!("{} already exists; refusing to overwrite", out.display()),
+        );
+    }
+    if let Some(parent) = out.parent().filter(|p| !p.as_os_str().is_empty()) {
+        std::fs::create_dir_all(parent)
+            .with_context(|| format!("Failed to create {}", parent.display()))?;
+    }
+
+    // Snapshot to a sibling temp and rename, so an interrupted backup never
+    // leaves a truncated file at `out` that a later restore would trust.
+    let tmp = staging_path(out);
+    let snapshot = (|| -> Result<SnapshotInfo> {
+        let store = Store::open_readonly(&index_path)
+            .with_context(|| format!("Failed to open index at {}", index_path.display()))?;
+        store
+            .snapshot_to(&tmp)
+            .with_context(|| format!("Failed to snapshot {}", index_path.display()))?;
+        drop(store);
+        let info = cqs::store::inspect_snapshot(&tmp).context("Snapshot failed verification")?;
+        cqs::fs::atomic_replace(&tmp, out)
+            .with_context(|| format!("Failed to move snapshot to {}", out.display()))?;
+        Ok(info)
+    })();
+    let info = match snapshot {
+        Ok(info) => info,
+        Err(e) => {
+            let _ = std::fs::remove_file(&tmp);
+            return Err(e);
+        }
+    };
+
+    let size_bytes = std::fs::metadata(out).map(|m| m.len()).unwrap_or(0);
+    if json {
+        crate::cli::json_envelope::emit_json(&BackupOutput {
+            path: out.display().to_string(),
+            size_bytes,
+            chunks: info.chunk_count,
+            schema_version: info.schema_version,
+            model: info.model_name,
+        })?;
+    } else {
+        println!(
+            "backed up {} -> {} ({}, {} chunks, schema v{})",
+            index_path.display(),
+            out.display(),
+            human_bytes(size_bytes),
+            info.chunk_count,
+            info.schema_version
+        );
+    }
+    Ok(())
+}
+
+/// Hidden temp path next to `out`, unique per process.
+fn staging_path(out: &Path) -> PathBuf {
+    let name = out
+        .file_name()
+        .map(|s| s.to_string_lossy().into_owned())
+        .unwrap_or_else(|| "snapshot.db".to_string());
+    out.with_file_name(format!(
+        ".{}.{}.{:016x}.tmp",
+        name,
+        std::process::id(),
+        cqs::temp_suffix()
+    ))
+}
+
+// ---------------------------------------------------------------------------
+// `cqs restore`
+// ---------------------------------------------------------------------------
+
+/// Replace the project index with `snapshot` after checking it fits.
+pub(crate) fn cmd_restore(cli: &Cli, snapshot: &Path, json: bool) -> Result<()> {
+    let _span = tracing::info_span!("cmd_restore", snapshot = %snapshot.display()).entered();
+
+    let root = find_project_root();
+    let cqs_dir = cqs::resolve_index_dir(&root);
+    let index_path = cqs::resolve_index_db(&cqs_dir);
+
+    // 1. Vet the snapshot on its own: integrity and schema.
+    let info = match cqs::store::inspect_snapshot(snapshot) {
+        Ok(info) => info,
+        Err(e) => {
+            let code = match &e {
+                cqs::store::StoreError::NotFound(_) => error_codes::NOT_FOUND,
+                _ => error_codes::INVALID_INPUT,
+            };
+            return fail(
+                json,
+                code,
+                format!("Cannot restore {}: {e}", snapshot.display()),
+            );
+        }
+    };
+
+    // 2. Model compatibility against what the index is built with now.
+    if let Some(msg) = model_mismatch(cli, &index_path, &info) {
+        return fail(
+            json,
+            error_codes::INVALID_INPUT,
+            format!("Cannot restore {}: {msg}", snapshot.display()),
+        );
+    }
+
+    // 3. Nothing may hold the live DB open across the swap.
+    std::fs::create_dir_all(&cqs_dir)
+        .with_context(|| format!("Failed to create {}", cqs_dir.display()))?;
+    let daemon_was_running = stop_daemon_best_effort(&cqs_dir);
+    if !cli.quiet && daemon_was_running {
+        eprintln!("stopped cqs-watch daemon");
+    }
+    let swapped = (|| -> Result<Vec<String>> {
+        let _lock = crate::cli::acquire_index_lock(&cqs_dir, crate::cli::LockWait::from_cli(cli))?;
+        cqs::store::restore_snapshot(&index_path, snapshot).with_context(|| {
+            format!(
+                "Failed to restore {} over {}",
+                snapshot.display(),
+                index_path.display()
+            )
+        })?;
+        Ok(remove_derived_indexes(&cqs_dir, &[]))
+    })();
+    restart_daemon_if_needed(daemon_was_running, cli.quiet);
+    let removed = swapped?;
+
+    let needs_migration = info.schema_version < cqs::store::CURRENT_SCHEMA_VERSION;
+    if json {
+        crate::cli::json_envelope::emit_json(&RestoreOutput {
+            snapshot: snapshot.display().to_string(),
+            index_path: index_path.display().to_string(),
+            chunks: info.chunk_count,
+            schema_version: info.schema_version,
+            model: info.model_name,
+            needs_migration,
+            removed,
+        })?;
+    } else {
+        println!(
+            "restored {} -> {} ({} chunks, schema v{})",
+            snapshot.display(),
+            index_path.display(),
+            info.chunk_count,
+            info.schema_version
+        );
+        if needs_migration {
+            println!(
+                "schema v{} migrates to v{} on the next `cqs index`",
+                info.schema_version,
+                cqs::store::CURRENT_SCHEMA_VERSION
+            );
+        }
+        if !removed.is_empty() {
+            println!(
+                "removed {} stale vector index file(s); run `cqs index` to rebuild them",
+                removed.len()
+            );
+        }
+    }
+    Ok(())
+}
+
+// ---------------------------------------------------------------------------
+// `cqs replica`
+// ---------------------------------------------------------------------------
+
+/// Install a synced primary index over the live one while the daemon keeps
+/// serving.
+pub(crate) fn cmd_replica(cli: &Cli, source: &Path, json: bool) -> Result<()> {
+    let _span = tracing::info_span!("cmd_replica", source = %source.display()).entered();
+
+    let root = find_project_root();
+    let cqs_dir = cqs::resolve_index_dir(&root);
+    let index_path = cqs::resolve_index_db(&cqs_dir);
+    // The vector index files live beside `index.db` (the slot directory).
+    let index_dir = index_path
+        .parent()
+        .unwrap_or(cqs_dir.as_path())
+        .to_path_buf();
+
+    // A directory source is a synced `.cqs`: take its active index and the
+    // vector index files next to it.
+    let (source_db, source_dir) = if source.is_dir() {
+        let db = cqs::resolve_index_db(source);
+        let dir = db.parent().map(Path::to_path_buf);
+        (db, dir)
+    } else {
+        (source.to_path_buf(), None)
+    };
+    if !source_db.is_file() {
+        return fail(
+            json,
+            error_codes::NOT_FOUND,
+            format!("No index at {}", source_db.display()),
+        );
+    }
+
+    std::fs::create_dir_all(&index_dir)
+        .with_context(|| format!("Failed to create {}", index_dir.display()))?;
+    let _lock = crate::cli::acquire_index_lock(&cqs_dir, crate::cli::LockWait::from_cli(cli))?;
+
+    let mut mismatch = None;
+    let mut copied = Vec::new();
+    let installed = cqs::store::install_replica(&index_path, &source_db, |info| {
+        mismatch = model_mismatch(cli, &index_path, info);
+        if let Some(msg) = &mismatch {
+            return Err(cqs::store::StoreError::Runtime(msg.clone()));
+        }
+        if let Some(dir) = &source_dir {
+            copied = copy_derived_indexes(dir, &index_dir)?;
+        }
+        Ok(())
+    });
+    let info = match installed {
+        Ok(info) => info,
+        Err(e) => {
+            use cqs::store::StoreError;
+            let refusal = match (mismatch, &e) {
+                (Some(msg), _) => Some(msg),
+                (
+                    None,
+                    StoreError::Corruption(_)
+                    | StoreError::SchemaNewerThanCq(_)
+                    | StoreError::MigrationNotSupported { .. }
+                    | StoreError::SchemaMismatch { .. },
+                ) => Some(e.to_string()),
+                (None, _) => None,
+            };
+            if let Some(msg) = refusal {
+                return fail(
+                    json,
+                    error_codes::INVALID_INPUT,
+                    format!("Cannot install replica {}: {msg}", source_db.display()),
+                );
+            }
+            return Err(anyhow::Error::new(e).context(format!(
+                "Failed to install replica {} over {}",
+                source_db.display(),
+                index_path.display()
+            )));
+        }
+    };
+    let removed = remove_derived_indexes(&index_dir, &copied);
+
+    if json {
+        crate::cli::json_envelope::emit_json(&ReplicaOutput {
+            source: source_db.display().to_string(),
+            index_path: index_path.display().to_string(),
+            chunks: info.chunk_count,
+            schema_version: info.schema_version,
+            model: info.model_name,
+            copied,
+            removed,
+        })?;
+    } else {
+        println!(
+            "installed replica {} -> {} ({} chunks, schema v{})",
+            source_db.display(),
+            index_path.display(),
+            info.chunk_count,
+            info.schema_version
+        );
+        if !copied.is_empty() {
+            println!("copied {} vector index file(s)", copied.len());
+        }
+        if !removed.is_empty() {
+            println!(
+                "removed {} stale vector index file(s); run `cqs index` to rebuild them",
+                removed.len()
+            );
+        }
+    }
+    Ok(())
+}
+
+/// Why `info` can't replace the index at `index_path`, if it can't.
+///
+/// The reference is the live index's recorded model and dimension. When
+/// there is no live index, or it can't be opened (an older schema is
+/// refused read-only), the configured model stands in. Fields missing on
+/// either side don't count as a mismatch.
+fn model_mismatch(cli: &Cli, index_path: &Path, info: &SnapshotInfo) -> Option<String> {
+    let live = index_path
+        .exists()
+        .then(|| Store::open_readonly(index_path))
+        .and_then(|r| match r {
+            Ok(store) => Some(store),
+            Err(e) => {
+                tracing::warn!(
+                    error = %e,
+                    "Could not open live index; checking snapshot against configured model"
+                );
+                None
+            }
+        });
+    let (names, dim): (Vec<String>, Option<usize>) = match live {
+        Some(store) => (
+            store.stored_model_name().into_iter().collect(),
+            Some(store.dim()),
+        ),
+        None => match cli.try_model_config() {
+            Ok(cfg) => (vec![cfg.name.clone(), cfg.repo.clone()], Some(cfg.dim)),
+            Err(_) => (Vec::new(), None),
+        },
+    };
+
+    if let Some(model) = &info.model_name {
+        if !names.is_empty() && !names.contains(model) {
+            return Some(format!(
+                "snapshot was built with {model} but the index uses {}; \
+                 run `cqs model swap` first or restore into a matching project",
+                names[0]
+            ));
+        }
+    }
+    match (info.dim, dim) {
+        (Some(snap), Some(want)) if snap != want => Some(format!(
+            "snapshot has {snap}-dim embeddings but the index expects {want}"
+        )),
+        _ => None,
+    }
+}
+
+/// File names of the vector indexes derived from `index.db`: HNSW (enriched
+/// and base), CAGRA, and SPLADE.
+fn derived_index_names() -> Vec<String> {
+    let mut names: Vec<String> = ["index", "index_base"]
+        .iter()
+        .flat_map(|base| {
+            cqs::hnsw::HNSW_ALL_EXTENSIONS
+                .iter()
+                .map(move |ext| format!("{base}.{ext}"))
+        })
+        .collect();
+    names.extend(
+        [
+            "index.cagra",
+            "index.cagra.meta",
+            cqs::splade::index::SPLADE_INDEX_FILENAME,
+        ]
+        .map(str::to_string),
+    );
+    names
+}
+
+/// Copy the vector index files present in `src_dir` into `dst_dir`, each
+/// through a hidden temp and a rename so a reader never loads a half-written
+/// file. Returns the names copied.
+fn copy_derived_indexes(src_dir: &Path, dst_dir: &Path) -> std::io::Result<Vec<String>> {
+    let mut copied = Vec::new();
+    for name in derived_index_names() {
+        let src = src_dir.join(&name);
+        if !src.is_file() {
+            continue;
+        }
+        let dst = dst_dir.join(&name);
+        let tmp = staging_path(&dst);
+        if let Err(e) = std::fs::copy(&src, &tmp).and_then(|_| cqs::fs::atomic_replace(&tmp, &dst))
+        {
+            let _ = std::fs::remove_file(&tmp);
+            return Err(e);
+        }
+        copied.push(name);
+    }
+    Ok(copied)
+}
+
+/// Delete the vector index files derived from the replaced DB, except the
+/// names in `keep`. Returns the names actually removed.
+fn remove_derived_indexes(cqs_dir: &Path, keep: &[String]) -> Vec<String> {
+    derived_index_names()
+        .into_iter()
+        .filter(|name| !keep.contains(name))
+        .filter(|name| {
+            let path = cqs_dir.join(name);
+            match std::fs::remove_file(&path) {
+                Ok(()) => true,
+                Err(e) if e.kind() == std::io::ErrorKind::NotFound => false,
+                Err(e) => {
+                    tracing::warn!(
+                        path = %path.display(),
+                        error = %e,
+                        "Failed to delete stale index file after restore"
+                    );
+                    false
+                }
+            }
+        })
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn staging_path_is_hidden_sibling() {
+        let tmp = staging_path(Path::new("/backups/snap.db"));
+        assert_eq!(tmp.parent(), Some(Path::new("/backups")));
+        let name = tmp.file_name().unwrap().to_string_lossy();
+        assert!(name.starts_with(".snap.db."), "{name}");
+        assert!(name.ends_with(".tmp"), "{name}");
+    }
+
+    #[test]
+    fn remove_derived_indexes_clears_vector_files_only() {
+        let dir = tempfile::tempdir().unwrap();
+        for name in ["index.hnsw.graph", "index_base.hnsw.ids", "index.cagra"] {
+            std::fs::write(dir.path().join(name), b"x").unwrap();
+        }
+        std::fs::write(dir.path().join("index.db"), b"db").unwrap();
+
+        let mut removed = remove_derived_indexes(dir.path(), &[]);
+        removed.sort();
+        assert_eq!(
+            removed,
+            vec!["index.cagra", "index.hnsw.graph", "index_base.hnsw.ids"]
+        );
+        assert!(dir.path().join("index.db").exists());
+    }
+
+    #[test]
+    fn copy_derived_indexes_brings_only_vector_files() {
+        let src = tempfile::tempdir().unwrap();
+        let dst = tempfile::tempdir().unwrap();
+        for name in ["index.hnsw.graph", "index.cagra", "index.db", "notes.toml"] {
+            std::fs::write(src.path().join(name), name).unwrap();
+        }
+        std::fs::write(dst.path().join("index_base.hnsw.ids"), b"old").unwrap();
+
+        let mut copied = copy_derived_indexes(src.path(), dst.path()).unwrap();
+        copied.sort();
+        assert_eq!(copied, vec!["index.cagra", "index.hnsw.graph"]);
+        assert_eq!(
+            std::fs::read(dst.path().join("index.cagra")).unwrap(),
+            b"index.cagra"
+        );
+        assert!(!dst.path().join("index.db").exists());
+
+        // What the source lacked is stale and goes; what it brought stays.
+        let removed = remove_derived_indexes(dst.path(), &copied);
+        assert_eq!(removed, vec!["index_base.hnsw.ids"]);
+        assert!(dst.path().join("index.hnsw.graph").exists());
+        let leftovers: Vec<_> = std::fs::read_dir(dst.path())
+            .unwrap()
+            .map(|e| e.unwrap().file_name().to_string_lossy().into_owned())
+            .filter(|n| n.ends_with(".tmp"))
+            .collect();
+        assert!(leftovers.is_empty(), "{leftovers:?}");
+    }
+}
@@ -0,0 +1,880 @@
+//! `cqs daemon` — install `cqs watch --serve` as a per-user service.
+//!
+//! `cqs daemon install --user` writes a systemd user unit on Linux
+//! (`~/.config/systemd/user/cqs-watch.service`) or a launchd agent on macOS
+//! (`~/Library/LaunchAgents/<LAUNCHD_LABEL>.plist`) that runs
+//! `cqs watch --serve` from the current project root, then enables and
+//! starts it. `uninstall` stops and removes it; `status` reports whether it
+//! is installed, enabled, running, and answering on the daemon socket.
+//!
+//! The unit name stays `cqs-watch` because the rest of the CLI (daemon
+//! control hints, the `cqs model` restart path) already addresses it by
+//! that name. One unit per user: it serves the project it was installed
+//! from. `--all-projects` instead installs one unit per project in the
+//! registry (`cqs project add`), named `cqs-watch-<name>` (label
+//! `<LAUNCHD_LABEL>.<name>`), each running in its project's root.
+//!
+//! Restart policy: restart on failure after 5 s, give up after 5 failures in
+//! 5 minutes (systemd) / throttle to one start per 5 s (launchd). A clean
+//! exit — `systemctl --user stop`, SIGTERM — is not restarted. The stop
+//! timeout leaves room for the daemon's drain (`CQS_DAEMON_DRAIN_SECS`).
+//! SIGHUP (`systemctl --user reload cqs-watch`) reloads `.cqs.toml`.
+//!
+//! Generated files carry [`SERVICE_MARKER_PREFIX`]. Install refuses to
+//! overwrite, and uninstall to remove, a file without it unless `--force`:
+//! it is a unit the user wrote by hand.
+
+use anyhow::{bail, Context, Result};
+use serde::Serialize;
+use std::io::ErrorKind;
+use std::path::{Path, PathBuf};
+
+use crate::cli::find_project_root;
+
+/// Marker line embedded in every generated service file. Bump the version
+/// suffix when a template changes.
+pub(crate) const SERVICE_MARKER_PREFIX: &str = "cqs:daemon";
+const SERVICE_MARKER_CURRENT: &str = "cqs:daemon v1";
+
+/// systemd user unit name.
+const SYSTEMD_UNIT: &str = "cqs-watch";
+
+/// launchd agent label (also the plist file stem).
+const LAUNCHD_LABEL: &str = "com.github.jamie8johnson.cqs-watch";
+
+/// Seconds the service manager waits for a clean stop before SIGKILL.
+/// Covers the default 10 s drain plus the store flush and WAL checkpoint.
+const STOP_TIMEOUT_SECS: u32 = 60;
+
+/// Seconds between a failed exit and the restart.
+const RESTART_DELAY_SECS: u32 = 5;
+
+/// `cqs daemon` subcommand surface.
+#[derive(clap::Subcommand, Debug, Clone)]
+/// Every variant flattens [`crate::cli::definitions::TextJsonArgs`]
+/// (`output: TextJsonArgs`) rather than an inline `json: bool`, matching the
+/// codebase-wide pattern.
+pub(crate) enum DaemonCommand {
+    /// Install, enable, and start `cqs watch --serve` for this project as a
+    /// per-user service (systemd user unit on Linux, launchd agent on macOS).
+    /// Re-running updates the unit in place and restarts it.
+    Install {
+        /// Install for the current user. Required — system-wide services
+        /// are not supported.
+        #[arg(long)]
+        user: bool,
+        /// Overwrite a unit that cqs did not generate.
+        #[arg(long)]
+        force: bool,
+        /// Write the unit but don't enable or start it.
+        #[arg(long)]
+        no_start: bool,
+        /// Install one service per registered project (`cqs project add`)
+        /// instead of one for the current project. Projects without an
+        /// index are skipped.
+        #[arg(long)]
+        all_projects: bool,
+        #[command(flatten)]
+        output: crate::cli::definitions::TextJsonArgs,
+    },
+    /// Stop, disable, and remove the per-user service.
+    Uninstall {
+        /// Remove the current user's service. Required, as for `install`.
+        #[arg(long)]
+        user: bool,
+        /// Remove a unit that cqs did not generate.
+        #[arg(long)]
+        force: bool,
+        /// Remove the services of every registered project, as installed
+        /// by `install --all-projects`.
+        #[arg(long)]
+        all_projects: bool,
+        #[command(flatten)]
+        output: crate::cli::definitions::TextJsonArgs,
+    },
+    /// Show whether the service is installed, enabled, and running, and
+    /// whether the daemon answers on its socket.
+    Status {
+        /// Inspect the current user's service (the default and only scope).
+        #[arg(long)]
+        user: bool,
+        #[command(flatten)]
+        output: crate::cli::definitions::TextJsonArgs,
+    },
+}
+
+/// Service manager for the current platform.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "lowercase")]
+enum ServiceManager {
+    Systemd,
+    Launchd,
+}
+
+impl ServiceManager {
+    /// The platform's manager, or `None` where `cqs daemon` isn't supported.
+    fn detect() -> Option<Self> {
+        if cfg!(target_os = "linux") {
+            Some(Self::Systemd)
+        } else if cfg!(target_os = "macos") {
+            Some(Self::Launchd)
+        } else {
+            None
+        }
+    }
+
+    /// Where the per-user service file lives.
+    fn unit_path(self, project: Option<&str>) -> Result<PathBuf> {
+        match self {
+            Self::Systemd => {
+                let dir = dirs::config_dir().context("cannot resolve the user config directory")?;
+                Ok(dir
+                    .join("systemd")
+                    .join("user")
+                    .join(format!("{}.service", systemd_unit(project))))
+            }
+            Self::Launchd => {
+                let home = dirs::home_dir().context("cannot resolve the home directory")?;
+                Ok(home
+                    .join("Library")
+                    .join("LaunchAgents")
+                    .join(format!("{}.plist", launchd_label(project))))
+            }
+        }
+    }
+
+    fn render(self, exe: &Path, root: &Path, project: Option<&str>) -> Result<String> {
+        match self {
+            Self::Systemd => render_systemd_unit(exe, root),
+            Self::Launchd => {
+                let home = dirs::home_dir().context("cannot resolve the home directory")?;
+                let log_name = match project {
+                    Some(name) => format!("cqs-watch-{}.log", service_suffix(name)),
+                    None => "cqs-watch.log".to_string(),
+                };
+                let log = home.join("Library").join("Logs").join(log_name);
+                render_launchd_plist(exe, root, &log, &launchd_label(project))
+            }
+        }
+    }
+}
+
+/// A project name as it may appear in a unit name or launchd label:
+/// characters outside `[A-Za-z0-9_.-]` become `_`.
+fn service_suffix(name: &str) -> String {
+    name.chars()
+        .map(|c| {
+            if c.is_ascii_alphanumeric() || matches!(c, '_' | '.' | '-') {
+                c
+            } else {
+                '_'
+            }
+        })
+        .collect()
+}
+
+/// systemd unit name: [`SYSTEMD_UNIT`], or `cqs-watch-<name>` for a
+/// registered project's service.
+fn systemd_unit(project: Option<&str>) -> String {
+    match project {
+        Some(name) => format!("{SYSTEMD_UNIT}-{}", service_suffix(name)),
+        None => SYSTEMD_UNIT.to_string(),
+    }
+}
+
+/// launchd label: [`LAUNCHD_LABEL`], or `<LAUNCHD_LABEL>.<name>` for a
+/// registered project's service.
+fn launchd_label(project: Option<&str>) -> String {
+    match project {
+        Some(name) => format!("{LAUNCHD_LABEL}.{}", service_suffix(name)),
+        None => LAUNCHD_LABEL.to_string(),
+    }
+}
+
+/// What install did to the unit file.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "snake_case")]
+enum UnitWrite {
+    Created,
+    Updated,
+    Unchanged,
+}
+
+// Paths carry forward-slash-normalized strings, as in the hook reports.
+#[derive(Debug, Serialize)]
+struct InstallReport {
+    /// Registered project name, for `--all-projects`.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    project: Option<String>,
+    manager: ServiceManager,
+    unit_path: String,
+    working_directory: String,
+    exec: String,
+    unit: UnitWrite,
+    started: bool,
+}
+
+#[derive(Debug, Serialize)]
+struct UninstallReport {
+    #[serde(skip_serializing_if = "Option::is_none")]
+    project: Option<String>,
+    manager: ServiceManager,
+    unit_path: String,
+    removed: bool,
+}
+
+#[derive(Debug, Default, Serialize)]
+struct StatusReport {
+    manager: Option<ServiceManager>,
+    unit_path: Option<String>,
+    installed: bool,
+    /// `false` for a hand-written unit at the same path.
+    generated_by_cqs: bool,
+    working_directory: Option<String>,
+    enabled: bool,
+    active: bool,
+    daemon_up: bool,
+}
+
+/// Top-level dispatch. Each variant handles its own JSON-vs-text output.
+pub(crate) fn cmd_daemon(subcmd: DaemonCommand) -> Result<()> {
+    match subcmd {
+        DaemonCommand::Install {
+            user,
+            force,
+            no_start,
+            all_projects,
+            output,
+        } => {
+            if all_projects {
+                cmd_install_all(user, force, no_start, output.json)
+            } else {
+                cmd_install(user, force, no_start, output.json)
+            }
+        }
+        DaemonCommand::Uninstall {
+            user,
+            force,
+            all_projects,
+            output,
+        } => {
+            if all_projects {
+                cmd_uninstall_all(user, force, output.json)
+            } else {
+                cmd_uninstall(user, force, output.json)
+            }
+        }
+        DaemonCommand::Status { user: _, output } => cmd_status(output.json),
+    }
+}
+
+fn require_manager() -> Result<ServiceManager> {
+    ServiceManager::detect().context(
+        "cqs daemon supports systemd (Linux) and launchd (macOS) only; \
+         run `cqs watch --serve` under your own service manager",
+    )
+}
+
+fn require_user_scope(user: bool, verb: &str) -> Result<()> {
+    if !user {
+        bail!(
+            "cqs daemon {verb} manages a per-user service; pass --user \
+             (system-wide services are not supported)"
+        );
+    }
+    Ok(())
+}
+
+// ─── install ──────────────────────────────────────────────────────────────
+
+fn cmd_install(user: bool, force: bool, no_start: bool, json: bool) -> Result<()> {
+    let _span = tracing::info_span!("cmd_daemon_install", force, no_start).entered();
+    require_user_scope(user, "install")?;
+    let manager = require_manager()?;
+    let root = find_project_root();
+    let cqs_dir = cqs::resolve_index_dir(&root);
+    if !cqs_dir.exists() {
+        bail!(
+            "no index at {} — run `cqs init && cqs index` first; the service \
+             would otherwise restart-loop",
+            cqs_dir.display()
+        );
+    }
+    let exe = current_exe()?;
+    let report = install_service(manager, &exe, &root, None, force, no_start)?;
+    emit(&report, json)?;
+    if !json && manager == ServiceManager::Systemd {
+        eprintln!(
+            "note: user services stop at logout unless lingering is on — \
+             `loginctl enable-linger $USER`. Logs: `journalctl --user -u {SYSTEMD_UNIT}`."
+        );
+    }
+    Ok(())
+}
+
+/// `install --all-projects`: one service per registered project with an
+/// index.
+fn cmd_install_all(user: bool, force: bool, no_start: bool, json: bool) -> Result<()> {
+    let _span = tracing::info_span!("cmd_daemon_install_all", force, no_start).entered();
+    require_user_scope(user, "install")?;
+    let manager = require_manager()?;
+    let registry = cqs::ProjectRegistry::load()?;
+    if registry.project.is_empty() {
+        bail!("no projects registered — add them with `cqs project add <name> <path>`");
+    }
+    let exe = current_exe()?;
+    let mut reports = Vec::new();
+    for entry in &registry.project {
+        if !cqs::resolve_index_dir(&entry.path).exists() {
+            // Skipped rather than fatal: a service without an index would
+            // restart-loop, and the other projects are still worth serving.
+            tracing::warn!(project = %entry.name, "Project has no index, skipping its service");
+            if !json {
+                eprintln!(
+                    "skipping '{}': no index at {} — run `cqs -P {} index` first",
+                    entry.name,
+                    entry.path.display(),
+                    entry.name
+                );
+            }
+            continue;
+        }
+        let report = install_service(
+            manager,
+            &exe,
+            &entry.path,
+            Some(&entry.name),
+            force,
+            no_start,
+        )
+        .with_context(|| format!("project '{}'", entry.name))?;
+        reports.push(report);
+    }
+    emit(&reports, json)?;
+    if !json && manager == ServiceManager::Systemd {
+        eprintln!(
+            "note: user services stop at logout unless lingering is on — \
+             `loginctl enable-linger $USER`. Logs: `journalctl --user -u {SYSTEMD_UNIT}-<name>`."
+        );
+    }
+    Ok(())
+}
+
+fn current_exe() -> Result<PathBuf> {
+    let exe = std::env::current_exe().context("cannot resolve the cqs binary path")?;
+    Ok(dunce::canonicalize(&exe).unwrap_or(exe))
+}
+
+/// Write, then (unless `no_start`) enable and start, the service running
+/// `cqs watch --serve` in `root`.
+fn install_service(
+    manager: ServiceManager,
+    exe: &Path,
+    root: &Path,
+    project: Option<&str>,
+    force: bool,
+    no_start: bool,
+) -> Result<InstallReport> {
+    let unit_path = manager.unit_path(project)?;
+    let body = manager.render(exe, root, project)?;
+    let unit = match read_unit(&unit_path)? {
+        None => UnitWrite::Created,
+        Some(existing) if existing == body => UnitWrite::Unchanged,
+        Some(existing) if existing.contains(SERVICE_MARKER_PREFIX) || force => UnitWrite::Updated,
+        Some(_) => bail!(
+            "{} exists and was not generated by cqs; pass --force to replace it",
+            unit_path.display()
+        ),
+    };
+    if unit != UnitWrite::Unchanged {
+        if let Some(parent) = unit_path.parent() {
+            std::fs::create_dir_all(parent)
+                .with_context(|| format!("create {}", parent.display()))?;
+        }
+        std::fs::write(&unit_path, &body)
+            .with_context(|| format!("write {}", unit_path.display()))?;
+        tracing::info!(path = %unit_path.display(), ?unit, "Wrote daemon service file");
+    }
+
+    let started = if no_start {
+        false
+    } else {
+        start_service(manager, &unit_path, project, unit == UnitWrite::Updated)?;
+        true
+    };
+
+    Ok(InstallReport {
+        project: project.map(str::to_string),
+        manager,
+        unit_path: cqs::normalize_path(&unit_path),
+        working_directory: cqs::normalize_path(root),
+        exec: cqs::normalize_path(exe),
+        unit,
+        started,
+    })
+}
+
+/// Enable and start the service; restart it when the unit changed under a
+/// running instance.
+fn start_service(
+    manager: ServiceManager,
+    unit_path: &Path,
+    project: Option<&str>,
+    changed: bool,
+) -> Result<()> {
+    match manager {
+        ServiceManager::Systemd => {
+            let unit = systemd_unit(project);
+            run("systemctl", &["--user", "daemon-reload"])?;
+            run("systemctl", &["--user", "enable", "--now", &unit])?;
+            if changed {
+                run("systemctl", &["--user", "restart", &unit])?;
+            }
+        }
+        ServiceManager::Launchd => {
+            // `bootstrap` fails on an already-loaded label; unload first so a
+            // reinstall picks up the new plist. Not loaded is fine.
+            let target = launchd_target(project)?;
+            if let Err(e) = run("launchctl", &["bootout", &target]) {
+                tracing::debug!(error = %e, "launchctl bootout before bootstrap failed (not loaded)");
+            }
+            let domain = launchd_domain()?;
+            let plist = unit_path.to_string_lossy();
+            run("launchctl", &["bootstrap", &domain, &plist])?;
+        }
+    }
+    Ok(())
+}
+
+// ─── uninstall ────────────────────────────────────────────────────────────
+
+fn cmd_uninstall(user: bool, force: bool, json: bool) -> Result<()> {
+    let _span = tracing::info_span!("cmd_daemon_uninstall", force).entered();
+    require_user_scope(user, "uninstall")?;
+    let manager = require_manager()?;
+    emit(&uninstall_service(manager, None, force)?, json)
+}
+
+/// `uninstall --all-projects`: the service of every registered project.
+/// A project removed from the registry keeps its unit; remove that one by
+/// hand.
+fn cmd_uninstall_all(user: bool, force: bool, json: bool) -> Result<()> {
+    let _span = tracing::info_span!("cmd_daemon_uninstall_all", force).entered();
+    require_user_scope(user, "uninstall")?;
+    let manager = require_manager()?;
+    let registry = cqs::ProjectRegistry::load()?;
+    let reports = registry
+        .project
+        .iter()
+        .map(|entry| {
+            uninstall_service(manager, Some(&entry.name), force)
+                .with_context(|| format!("project '{}'", entry.name))
+        })
+        .collect::<Result<Vec<_>>>()?;
+    emit(&reports, json)
+}
+
+/// Stop, disable, and remove one service. Not installed is not an error.
+fn uninstall_service(
+    manager: ServiceManager,
+    project: Option<&str>,
+    force: bool,
+) -> Result<UninstallReport> {
+    let unit_path = manager.unit_path(project)?;
+
+    let removed = match read_unit(&unit_path)? {
+        None => false,
+        Some(existing) => {
+            if !existing.contains(SERVICE_MARKER_PREFIX) && !force {
+                bail!(
+                    "{} was not generated by cqs; pass --force to remove it",
+                    unit_path.display()
+                );
+            }
+            // Stop first so the daemon drains before its unit disappears.
+            // A unit that isn't loaded fails to stop; that's fine.
+            let stop = match manager {
+                ServiceManager::Systemd => run(
+                    "systemctl",
+                    &["--user", "disable", "--now", &systemd_unit(project)],
+                ),
+                ServiceManager::Launchd => {
+                    launchd_target(project).and_then(|t| run("launchctl", &["bootout", &t]))
+                }
+            };
+            if let Err(e) = stop {
+                tracing::warn!(error = %e, "Stopping the daemon service failed; removing the unit anyway");
+            }
+            std::fs::remove_file(&unit_path)
+                .with_context(|| format!("remove {}", unit_path.display()))?;
+            if manager == ServiceManager::Systemd {
+                run("systemctl", &["--user", "daemon-reload"])?;
+            }
+            tracing::info!(path = %unit_path.display(), "Removed daemon service file");
+            true
+        }
+    };
+
+    Ok(UninstallReport {
+        project: project.map(str::to_string),
+        manager,
+        unit_path: cqs::normalize_path(&unit_path),
+        removed,
+    })
+}
+
+// ─── status ───────────────────────────────────────────────────────────────
+
+fn cmd_status(json: bool) -> Result<()> {
+    let _span = tracing::info_span!("cmd_daemon_status").entered();
+    let mut report = StatusReport::default();
+    if let Some(manager) = ServiceManager::detect() {
+        let unit_path = manager.unit_path(None)?;
+        report.manager = Some(manager);
+        report.unit_path = Some(cqs::normalize_path(&unit_path));
+        if let Some(body) = read_unit(&unit_path)? {
+            report.installed = true;
+            report.generated_by_cqs = body.contains(SERVICE_MARKER_PREFIX);
+            report.working_directory = parse_working_directory(manager, &body);
+        }
+        let (enabled, active) = probe_service(manager);
+        report.enabled = enabled;
+        report.active = active;
+    }
+
+    // Ask the daemon of the project the unit serves, falling back to the
+    // current project for a hand-run `cqs watch --serve`.
+    #[cfg(unix)]
+    {
+        let root = report
+            .working_directory
+            .as_ref()
+            .map(PathBuf::from)
+            .unwrap_or_else(find_project_root);
+        let cqs_dir = cqs::resolve_index_dir(&root);
+        report.daemon_up = cqs::daemon_translate::daemon_ping(&cqs_dir).is_ok();
+    }
+
+    emit(&report, json)
+}
+
+/// `(enabled, active)` as the service manager reports them. Both `false`
+/// when the manager can't be queried.
+fn probe_service(manager: ServiceManager) -> (bool, bool) {
+    match manager {
+        ServiceManager::Systemd => (
+            probe(
+                "systemctl",
+                &["--user", "is-enabled", "--quiet", SYSTEMD_UNIT],
+            )
+            .is_some(),
+            probe(
+                "systemctl",
+                &["--user", "is-active", "--quiet", SYSTEMD_UNIT],
+            )
+            .is_some(),
+        ),
+        ServiceManager::Launchd => {
+            let Ok(target) = launchd_target(None) else {
+                return (false, false);
+            };
+            match probe("launchctl", &["print", &target]) {
+                // A loaded agent is enabled (RunAtLoad); `state = running`
+                // means the process is up right now.
+                Some(out) => (true, out.contains("state = running")),
+                None => (false, false),
+            }
+        }
+    }
+}
+
+// ─── templates ────────────────────────────────────────────────────────────
+
+/// The systemd user unit running `cqs watch --serve` in `root`.
+fn render_systemd_unit(exe: &Path, root: &Path) -> Result<String> {
+    let exe = exe.to_str().context("cqs binary path is not valid UTF-8")?;
+    let root = root.to_str().context("project root is not valid UTF-8")?;
+    Ok(format!(
+        "# {SERVICE_MARKER_CURRENT} — generated by `cqs daemon install --user`.\n\
+         # Re-running install overwrites this file; `cqs daemon uninstall --user` removes it.\n\
+         [Unit]\n\
+         Description=cqs watch daemon ({desc})\n\
+         Documentation=https://github.com/jamie8johnson/cqs\n\
+         StartLimitIntervalSec=300\n\
+         StartLimitBurst=5\n\
+         \n\
+         [Service]\n\
+         Type=simple\n\
+         WorkingDirectory={workdir}\n\
+         ExecStart={exec} watch --serve\n\
+         ExecReload=/bin/kill -HUP $MAINPID\n\
+         Restart=on-failure\n\
+         RestartSec={RESTART_DELAY_SECS}\n\
+         TimeoutStopSec={STOP_TIMEOUT_SECS}\n\
+         \n\
+         [Install]\n\
+         WantedBy=default.target\n",
+        desc = systemd_escape_specifiers(root),
+        workdir = systemd_escape_specifiers(root),
+        exec = systemd_quote_arg(exe),
+    ))
+}
+
+/// Escape `%` specifiers, which systemd expands in most unit settings.
+fn systemd_escape_specifiers(s: &str) -> String {
+    s.replace('%', "%%")
+}
+
+/// Quote one `ExecStart=` word: double quotes with `\` and `"` escaped,
+/// `%` specifiers and `$` variable expansion neutralised.
+fn systemd_quote_arg(s: &str) -> String {
+    let escaped = systemd_escape_specifiers(s)
+        .replace('\\', "\\\\")
+        .replace('"', "\\\"")
+        .replace('$', "$$");
+    format!("\"{escaped}\"")
+}
+
+/// The launchd agent plist `label` running `cqs watch --serve` in `root`,
+/// logging stdout and stderr to `log`.
+fn render_launchd_plist(exe: &Path, root: &Path, log: &Path, label: &str) -> Result<String> {
+    let exe = exe.to_str().context("cqs binary path is not valid UTF-8")?;
+    let root = root.to_str().context("project root is not valid UTF-8")?;
+    let log = log.to_str().context("log path is not valid UTF-8")?;
+    Ok(format!(
+        r#"<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<!-- {SERVICE_MARKER_CURRENT} — generated by `cqs daemon install --user`. -->
+<plist version="1.0">
+<dict>
+    <key>Label</key>
+    <string>{label}</string>
+    <key>ProgramArguments</key>
+    <array>
+        <string>{exe}</string>
+        <string>watch</string>
+        <string>--serve</string>
+    </array>
+    <key>WorkingDirectory</key>
+    <string>{root}</string>
+    <key>RunAtLoad</key>
+    <true/>
+    <key>KeepAlive</key>
+    <dict>
+        <key>SuccessfulExit</key>
+        <false/>
+    </dict>
+    <key>ThrottleInterval</key>
+    <integer>{RESTART_DELAY_SECS}</integer>
+    <key>ExitTimeOut</key>
+    <integer>{STOP_TIMEOUT_SECS}</integer>
+    <key>StandardOutPath</key>
+    <string>{log}</string>
+    <key>StandardErrorPath</key>
+    <string>{log}</string>
+</dict>
+</plist>
+"#,
+        exe = xml_escape(exe),
+        root = xml_escape(root),
+        log = xml_escape(log),
+        label = xml_escape(label),
+    ))
+}
+
+fn xml_escape(s: &str) -> String {
+    s.replace('&', "&amp;")
+        .replace('<', "&lt;")
+        .replace('>', "&gt;")
+        .replace('"', "&quot;")
+}
+
+fn xml_unescape(s: &str) -> String {
+    s.replace("&quot;", "\"")
+        .replace("&lt;", "<")
+        .replace("&gt;", ">")
+        .replace("&amp;", "&")
+}
+
+/// The project root a service file runs in, read back from its body.
+fn parse_working_directory(manager: ServiceManager, body: &str) -> Option<String> {
+    match manager {
+        ServiceManager::Systemd => body
+            .lines()
+            .find_map(|l| l.trim().strip_prefix("WorkingDirectory="))
+            .map(|v| v.trim().replace("%%", "%")),
+        ServiceManager::Launchd => {
+            let after_key = body.split("<key>WorkingDirectory</key>").nth(1)?;
+            let start = after_key.find("<string>")? + "<string>".len();
+            let len = after_key[start..].find("</string>")?;
+            Some(xml_unescape(&after_key[start..start + len]))
+        }
+    }
+}
+
+// ─── helpers ──────────────────────────────────────────────────────────────
+
+/// Read a service file; `None` when it doesn't exist.
+fn read_unit(path: &Path) -> Result<Option<String>> {
+    match std::fs::read_to_string(path) {
+        Ok(s) => Ok(Some(s)),
+        Err(e) if e.kind() == ErrorKind::NotFound => Ok(None),
+        Err(e) => Err(e).with_context(|| format!("read {}", path.display())),
+    }
+}
+
+/// Run a service-manager command; an error carries its stderr.
+fn run(program: &str, args: &[&str]) -> Result<()> {
+    let out = std::process::Command::new(program)
+        .args(args)
+        .stdin(std::process::Stdio::null())
+        .output()
+        .with_context(|| format!("failed to run {program}"))?;
+    if !out.status.success() {
+        bail!(
+            "`{program} {}` failed ({}): {}",
+            args.join(" "),
+            out.status,
+            String::from_utf8_lossy(&out.stderr).trim()
+        );
+    }
+    tracing::debug!(program, ?args, "Service manager command succeeded");
+    Ok(())
+}
+
+/// Run a read-only query; `Some(stdout)` when it exits 0.
+fn probe(program: &str, args: &[&str]) -> Option<String> {
+    let out = std::process::Command::new(program)
+        .args(args)
+        .stdin(std::process::Stdio::null())
+        .stderr(std::process::Stdio::null())
+        .output()
+        .ok()?;
+    out.status
+        .success()
+        .then(|| String::from_utf8_lossy(&out.stdout).into_owned())
+}
+
+/// `gui/<uid>` — the launchd domain of the logged-in user.
+fn launchd_domain() -> Result<String> {
+    #[cfg(unix)]
+    {
+        // SAFETY: getuid has no preconditions and cannot fail.
+        let uid = unsafe { libc::getuid() };
+        Ok(format!("gui/{uid}"))
+    }
+    #[cfg(not(unix))]
+    {
+        bail!("launchd is macOS-only")
+    }
+}
+
+/// `gui/<uid>/<label>` — the service target for `launchctl`.
+fn launchd_target(project: Option<&str>) -> Result<String> {
+    Ok(format!("{}/{}", launchd_domain()?, launchd_label(project)))
+}
+
+fn emit<T: Serialize>(report: &T, json: bool) -> Result<()> {
+    if json {
+        crate::cli::json_envelope::emit_json(report)?;
+    } else {
+        // Same one-field-per-line dump as `cqs hook`: rare interactive runs.
+        let pretty = serde_json::to_string_pretty(report)?;
+        println!("{pretty}");
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn systemd_unit_runs_watch_serve_with_restart_policy() {
+        let unit = render_systemd_unit(Path::new("/usr/local/bin/cqs"), Path::new("/home/u/proj"))
+            .unwrap();
+        assert!(unit.contains(SERVICE_MARKER_CURRENT));
+        assert!(unit.contains("WorkingDirectory=/home/u/proj\n"));
+        assert!(unit.contains("ExecStart=\"/usr/local/bin/cqs\" watch --serve\n"));
+        assert!(unit.contains("ExecReload=/bin/kill -HUP $MAINPID\n"));
+        assert!(unit.contains("Restart=on-failure\n"));
+        assert!(unit.contains(&format!("TimeoutStopSec={STOP_TIMEOUT_SECS}\n")));
+        assert!(unit.contains("WantedBy=default.target\n"));
+    }
+
+    #[test]
+    fn systemd_unit_escapes_specifiers_and_quotes() {
+        let unit = render_systemd_unit(
+            Path::new("/opt/my tools/c$qs\"%"),
+            Path::new("/home/u/100% proj"),
+        )
+        .unwrap();
+        assert!(
+            unit.contains("WorkingDirectory=/home/u/100%% proj\n"),
+            "{unit}"
+        );
+        assert!(
+            unit.contains("ExecStart=\"/opt/my tools/c$$qs\\\"%%\" watch --serve\n"),
+            "{unit}"
+        );
+    }
+
+    #[test]
+    fn launchd_plist_escapes_xml_and_keeps_alive_on_failure() {
+        let plist = render_launchd_plist(
+            Path::new("/usr/local/bin/cqs"),
+            Path::new("/Users/u/a&b"),
+            Path::new("/Users/u/Library/Logs/cqs-watch.log"),
+            LAUNCHD_LABEL,
+        )
+        .unwrap();
+        assert!(plist.contains(SERVICE_MARKER_CURRENT));
+        assert!(plist.contains(&format!("<string>{LAUNCHD_LABEL}</string>")));
+        assert!(plist.contains("<string>/Users/u/a&amp;b</string>"));
+        assert!(plist.contains("<key>SuccessfulExit</key>\n        <false/>"));
+        assert!(plist.contains("<string>--serve</string>"));
+    }
+
+    #[test]
+    fn working_directory_round_trips_through_both_templates() {
+        let root = Path::new("/home/u/50% <proj> & co");
+        let exe = Path::new("/usr/bin/cqs");
+        let unit = render_systemd_unit(exe, root).unwrap();
+        assert_eq!(
+            parse_working_directory(ServiceManager::Systemd, &unit).as_deref(),
+            root.to_str()
+        );
+        let plist = render_launchd_plist(exe, root, Path::new("/tmp/log"), LAUNCHD_LABEL).unwrap();
+        assert_eq!(
+            parse_working_directory(ServiceManager::Launchd, &plist).as_deref(),
+            root.to_str()
+        );
+    }
+
+    #[test]
+    fn project_services_get_their_own_names() {
+        assert_eq!(systemd_unit(None), SYSTEMD_UNIT);
+        assert_eq!(systemd_unit(Some("billing")), "cqs-watch-billing");
+        assert_eq!(systemd_unit(Some("my app/v2")), "cqs-watch-my_app_v2");
+        assert_eq!(launchd_label(None), LAUNCHD_LABEL);
+        assert_eq!(
+            launchd_label(Some("billing")),
+            format!("{LAUNCHD_LABEL}.billing")
+        );
+    }
+
+    #[test]
+    fn read_unit_missing_is_none() {
+        let dir = tempfile::TempDir::new().unwrap();
+        assert!(read_unit(&dir.path().join("absent.service"))
+            .unwrap()
+            .is_none());
+    }
+
+    #[test]
+    fn install_without_user_scope_is_refused() {
+        let err = require_user_scope(false, "install").unwrap_err();
+        assert!(err.to_string().contains("--user"), "{err}");
+        assert!(require_user_scope(true, "install").is_ok());
+    }
+}
@@ -25,6 +25,10 @@ enum IssueKind {
     NoIndex,
     /// Model error — needs reinstall
     ModelError,
+    /// Chunk dependents outlived their chunks — needs gc
+    Orphans,
+    /// Stored chunk text fails its checksum — needs a full re-index
+    Corrupt,
 }
 
 /// A single doctor issue with its fix action.
@@ -65,7 +69,7 @@ fn run_fixes(issues: &[DoctorIssue]) -> Result<()> {
                     );
                 }
             }
-            IssueKind::Schema => {
+            IssueKind::Schema | IssueKind::Corrupt => {
                 println!(
                     "  Fixing: {} — running 'cqs index --force'...",
                     issue.message
@@ -75,9 +79,9 @@ fn run_fixes(issues: &[DoctorIssue]) -> Result<()> {
                     .status()
                     .map_err(|e| anyhow::anyhow!("Failed to run 'cqs index --force': {}", e))?;
                 if status.success() {
-                    println!("  {} Index rebuilt with schema migration", "[✓]".green());
+                    println!("  {} Index rebuilt from source", "[✓]".green());
                 } else {
-                    println!("  {} Schema migration failed", "[✗]".red());
+                    println!("  {} Index rebuild failed", "[✗]".red());
                     // P3 #91: same structured form as the sibling arm.
                     tracing::warn!(
                         code = ?status.code(),
@@ -86,6 +90,23 @@ fn run_fixes(issues: &[DoctorIssue]) -> Result<()> {
                     );
                 }
             }
+            IssueKind::Orphans => {
+                println!("  Fixing: {} — running 'cqs gc'...", issue.message);
+                let status = std::process::Command::new(&cqs_path)
+                    .arg("gc")
+                    .status()
+                    .map_err(|e| anyhow::anyhow!("Failed to run 'cqs gc': {}", e))?;
+                if status.success() {
+                    println!("  {} Orphan rows purged", "[✓]".green());
+                } else {
+                    println!("  {} Orphan purge failed", "[✗]".red());
+                    tracing::warn!(
+                        code = ?status.code(),
+                        op = "cqs gc",
+                        "Sub-process gc command exited non-zero"
+                    );
+                }
+            }
             IssueKind::ModelError => {
                 println!(
                     "  Skipping: {} — model issues require manual intervention",
@@ -100,7 +121,8 @@ fn run_fixes(issues: &[DoctorIssue]) -> Result<()> {
 /// Run diagnostic checks on cqs installation and index.
 ///
 /// Reports runtime info, embedding provider, model status, and index statistics.
-/// With `--fix`, automatically remediates issues: stale→index, schema→migrate.
+/// With `--fix`, automatically remediates issues: stale→index, schema→migrate,
+/// orphans→gc, corrupt content→full re-index.
 /// With `--verbose`, also dumps the full setup introspection (resolved model
 /// config, env vars, daemon socket, index metadata, config precedence) — the
 /// one-call diagnostic for "queries return zero results" / "weird daemon state".
@@ -384,6 +406,127 @@ pub(crate) fn cmd_doctor(
                     }
                 }
 
+                // Chunk dependents: FTS rows, call edges, sparse vectors, git
+                // metadata and embedding versions must all point at a live
+                // chunk. Deletes cascade now, but an index written before a
+                // table gained its foreign key can still carry orphans —
+                // phantom keyword hits and ghost edges. `cqs gc` purges them.
+                match store.dependent_orphans() {
+                    Ok(orphans) if orphans.is_empty() => {
+                        out(
+                            json,
+                            &format!("  {} Chunk dependents: clean", "[✓]".green()),
+                        );
+                        check_records.push(CheckRecord::ok(
+                            "index",
+                            "chunk_dependents",
+                            "no orphaned dependent rows".to_string(),
+                        ));
+                    }
+                    Ok(orphans) => {
+                        let total: u64 = orphans.iter().map(|o| o.rows).sum();
+                        let tables: Vec<String> = orphans
+                            .iter()
+                            .map(|o| format!("{} {}", o.table, o.rows))
+                            .collect();
+                        let msg = format!(
+                            "{} row(s) reference deleted chunks ({}); run `cqs gc`",
+                            total,
+                            tables.join(", ")
+                        );
+                        out(
+                            json,
+                            &format!("  {} Chunk dependents: {}", "[!]".yellow(), msg),
+                        );
+                        check_records.push(CheckRecord::warn(
+                            "index",
+                            "chunk_dependents",
+                            msg.clone(),
+                        ));
+                        issues.push(DoctorIssue {
+                            kind: IssueKind::Orphans,
+                            message: msg,
+                        });
+                        any_failed = true;
+                    }
+                    Err(e) => {
+                        out(
+                            json,
+                            &format!("  {} Chunk dependents check failed: {}", "[✗]".red(), e),
+                        );
+                        check_records.push(CheckRecord::err(
+                            "index",
+                            "chunk_dependents",
+                            e.to_string(),
+                        ));
+                        any_failed = true;
+                    }
+                }
+
+                // Content checksums: rehash every chunk's stored text against
+                // the content_hash written with it. Reads only sample this
+                // (`verify_reads`); doctor checks all of it. A mismatch is
+                // storage damage the SQLite page checks can't see, and the
+                // file fingerprints still match, so only a forced re-index
+                // rewrites the rows.
+                match store.verify_chunk_content() {
+                    Ok(report) if report.corrupt.is_empty() => {
+                        out(
+                            json,
+                            &format!(
+                                "  {} Content checksums: {} chunks verified",
+                                "[✓]".green(),
+                                report.checked
+                            ),
+                        );
+                        check_records.push(CheckRecord::ok(
+                            "index",
+                            "content_checksums",
+                            format!("{} chunks verified", report.checked),
+                        ));
+                    }
+                    Ok(report) => {
+                        let sample: Vec<&str> = report
+                            .corrupt
+                            .iter()
+                            .take(5)
+                            .map(|c| c.id.as_str())
+                            .collect();
+                        let msg = format!(
+                            "{} of {} chunks fail their content_hash (e.g. {}); run `cqs index --force`",
+                            report.corrupt.len(),
+                            report.checked,
+                            sample.join(", ")
+                        );
+                        out(
+                            json,
+                            &format!("  {} Content checksums: {}", "[✗]".red(), msg),
+                        );
+                        check_records.push(CheckRecord::err(
+                            "index",
+                            "content_checksums",
+                            msg.clone(),
+                        ));
+                        issues.push(DoctorIssue {
+                            kind: IssueKind::Corrupt,
+                            message: msg,
+                        });
+                        any_failed = true;
+                    }
+                    Err(e) => {
+                        out(
+                            json,
+                            &format!("  {} Content checksum check failed: {}", "[✗]".red(), e),
+                        );
+                        check_records.push(CheckRecord::err(
+                            "index",
+                            "content_checksums",
+                            e.to_string(),
+                        ));
+                        any_failed = true;
+                    }
+                }
+
                 // Check model mismatch between index and configured model
                 let stored = store.stored_model_name();
                 let configured = &model_config.name;
@@ -629,6 +772,16 @@ fn check_local_llm(json: bool, records: &mut Vec<CheckRecord>, any_failed: &mut
         }
     }
 
+    // Offline mode: a non-loopback endpoint would be refused at submit time
+    // too, so report that instead of probing it.
+    if let Err(e) = cqs::offline::check_url("doctor endpoint probe", &base) {
+        let msg = e.to_string();
+        out(json, &format!("  {} {}", "[✗]".red(), msg));
+        records.push(CheckRecord::err("local_llm", "offline", msg));
+        *any_failed = true;
+        return;
+    }
+
     // Endpoint reachability probe: GET `{api_base}/models` with a tight
     // timeout. We don't want doctor to hang if the user typo'd a URL.
     let probe_url = format!("{}/models", base.trim_end_matches('/'));
@@ -849,6 +1002,8 @@ struct IndexMeta {
     total_files: Option<u64>,
     created_at: Option<String>,
     last_indexed_at: Option<String>,
+    /// Recorded schema migration steps, oldest first (`schema_migrations`).
+    migrations: Vec<cqs::store::AppliedMigration>,
     /// Open / stats error text, if reading the index failed.
     open_error: Option<String>,
 }
@@ -1069,6 +1224,7 @@ fn read_index_meta(index_path: &Path) -> (Option<String>, IndexMeta) {
                 total_files: None,
                 created_at: None,
                 last_indexed_at: None,
+                migrations: Vec::new(),
                 open_error: None,
             },
         );
@@ -1092,6 +1248,10 @@ fn read_index_meta(index_path: &Path) -> (Option<String>, IndexMeta) {
                         (None, None, None, None, None, Some(e.to_string()))
                     }
                 };
+            let migrations = store.migration_history().unwrap_or_else(|e| {
+                tracing::debug!(error = %e, "Failed to read migration history");
+                Vec::new()
+            });
             (
                 stored.clone(),
                 IndexMeta {
@@ -1104,6 +1264,7 @@ fn read_index_meta(index_path: &Path) -> (Option<String>, IndexMeta) {
                     total_files,
                     created_at,
                     last_indexed_at: updated_at,
+                    migrations,
                     open_error,
                 },
             )
@@ -1122,6 +1283,7 @@ fn read_index_meta(index_path: &Path) -> (Option<String>, IndexMeta) {
                     total_files: None,
                     created_at: None,
                     last_indexed_at: None,
+                    migrations: Vec::new(),
                     open_error: Some(e.to_string()),
                 },
             )
@@ -1497,6 +1659,16 @@ fn print_verbose_report(r: &VerboseReport) {
         if let Some(v) = r.index.schema_version {
             println!("  schema_version:   {}", v);
         }
+        if let Some(m) = r.index.migrations.last() {
+            println!(
+                "  last_migration:   v{} -> v{} ({}, cqs {}; {} recorded)",
+                m.from_version,
+                m.to_version,
+                m.applied_at,
+                m.cqs_version,
+                r.index.migrations.len()
+            );
+        }
         if let Some(d) = r.index.dim {
             println!("  dim:              {}", d);
         }
@@ -1607,6 +1779,14 @@ mod tests {
             kind: IssueKind::ModelError,
             message: "model error".to_string(),
         };
+        let orphans = DoctorIssue {
+            kind: IssueKind::Orphans,
+            message: "orphan rows".to_string(),
+        };
+        let corrupt = DoctorIssue {
+            kind: IssueKind::Corrupt,
+            message: "checksum mismatch".to_string(),
+        };
 
         // Stale and NoIndex both map to "cqs index"
         assert_eq!(stale.kind, IssueKind::Stale);
@@ -1615,6 +1795,10 @@ mod tests {
         assert_eq!(schema.kind, IssueKind::Schema);
         // Model is manual
         assert_eq!(model.kind, IssueKind::ModelError);
+        // Orphans map to "cqs gc"
+        assert_eq!(orphans.kind, IssueKind::Orphans);
+        // Corrupt rows share Schema's "cqs index --force"
+        assert_eq!(corrupt.kind, IssueKind::Corrupt);
     }
 
     /// Helper: build a `VerboseReport` for an empty tempdir (no `.cqs/`,
@@ -1,13 +1,72 @@
 //! Init command for cqs
 //!
-//! Creates .cqs/ directory and downloads the embedding model.
+//! Creates .cqs/ and downloads the embedding model. Before that, a wizard
+//! surveys the project: which languages the walk would index, which
+//! generated or vendored trees it would drag in (proposed as `.cqsignore`
+//! rules), and which embedding model fits the repo size and hardware
+//! (written to `.cqs.toml`). When the project's docs aren't in English it
+//! points at the multilingual settings: query translation and the FTS
+//! stemmer. It then offers the first `cqs index` with a time estimate.
+//!
+//! Proposals are applied when the user accepts them at the prompt, or all
+//! at once with `--yes`. Without a terminal and without `--yes` the plan is
+//! only printed, so scripted `cqs init` keeps its old effect.
+
+use std::collections::BTreeMap;
+use std::io::{IsTerminal, Write};
+use std::path::{Path, PathBuf};
 
 use anyhow::{Context, Result};
 
+use cqs::embedder::{ExecutionProvider, ModelConfig};
 use cqs::Embedder;
+use cqs::FtsLanguage;
 
 use crate::cli::{find_project_root, Cli};
 
+/// Generated, vendored, and build-output trees worth keeping out of the
+/// index, with why. Proposed only when the walk would actually pick up
+/// files under them.
+const IGNORE_CANDIDATES: &[(&str, &str)] = &[
+    ("node_modules/", "JavaScript dependencies"),
+    ("bower_components/", "JavaScript dependencies"),
+    ("vendor/", "vendored dependencies"),
+    ("third_party/", "vendored dependencies"),
+    ("target/", "build output"),
+    ("build/", "build output"),
+    ("dist/", "build output"),
+    ("out/", "build output"),
+    ("coverage/", "coverage reports"),
+    ("__pycache__/", "Python bytecode caches"),
+    ("*.min.js", "minified bundles"),
+    ("*.pb.go", "generated protobuf code"),
+    ("*_pb2.py", "generated protobuf code"),
+    ("*.generated.*", "generated code"),
+];
+
+/// Average bytes of source per chunk, for estimating the chunk count from
+/// file sizes before anything is parsed.
+const AVG_CHUNK_BYTES: u64 = 1_500;
+
+/// Above this many estimated chunks, a CPU-only machine gets the lighter
+/// model: the default's first index would run past half an hour.
+const CPU_LIGHT_MODEL_CHUNKS: u64 = 5_000;
+
+/// The lighter code-search model proposed for large repos on CPU.
+const LIGHT_MODEL: &str = "v9-200k";
+
+/// Embedding throughput, in chunks per second, of a model the size of
+/// [`LIGHT_MODEL`] (a ~440 MB download). Larger models scale down by
+/// download size.
+const GPU_CHUNKS_PER_SEC: f64 = 400.0;
+const CPU_CHUNKS_PER_SEC: f64 = 30.0;
+const REFERENCE_MODEL_BYTES: f64 = 440.0 * 1024.0 * 1024.0;
+
+/// Markdown files sampled, and bytes read from each, to guess the language
+/// the project's prose is written in.
+const PROSE_SAMPLE_FILES: usize = 20;
+const PROSE_SAMPLE_BYTES: u64 = 8 * 1024;
+
 /// `cqs init --json` success payload. init is orchestration (downloads the
 /// model, warms the embedder), so it has no surface-agnostic core — the typed
 /// output is the schema for its single success envelope.
@@ -16,12 +75,80 @@ pub(crate) struct InitOutput {
     pub initialized: bool,
     pub cqs_dir: String,
     pub model: String,
+    /// What the wizard found and proposed, and which proposals it applied.
+    pub wizard: InitPlan,
+    /// Whether the first index ran.
+    pub indexed: bool,
+}
+
+/// Files per detected language.
+#[derive(Debug, Clone, serde::Serialize)]
+pub(crate) struct LanguageCount {
+    pub language: String,
+    pub files: usize,
+    pub bytes: u64,
+}
+
+/// A proposed `.cqsignore` rule.
+#[derive(Debug, Clone, serde::Serialize)]
+pub(crate) struct IgnoreProposal {
+    pub rule: String,
+    pub reason: String,
+    /// Files the walk would index today that the rule drops.
+    pub files: usize,
+    pub applied: bool,
+}
+
+/// The proposed embedding model; `None` in [`InitPlan`] when one is
+/// already chosen (flag, env, config, slot) or an index exists.
+#[derive(Debug, Clone, serde::Serialize)]
+pub(crate) struct ModelProposal {
+    pub name: String,
+    pub reason: String,
+    pub applied: bool,
+}
+
+#[derive(Debug, Clone, serde::Serialize)]
+pub(crate) struct InitPlan {
+    pub files: usize,
+    pub bytes: u64,
+    pub languages: Vec<LanguageCount>,
+    pub ignore_rules: Vec<IgnoreProposal>,
+    pub model: Option<ModelProposal>,
+    /// Execution provider the embedder will use (`CPU`, `CUDA (device 0)`, …).
+    pub provider: String,
+    /// Chunk count estimated from file sizes, after applied ignore rules.
+    pub estimated_chunks: u64,
+    /// Rough wall time of the first index, in seconds.
+    pub estimated_index_secs: u64,
+    /// Language the project's markdown is written in, when not English.
+    pub prose_language: Option<FtsLanguage>,
+}
+
+/// One walked file.
+struct CensusFile {
+    rel: PathBuf,
+    language: String,
+    bytes: u64,
+}
+
+/// How proposals get decided.
+#[derive(Clone, Copy, PartialEq, Eq)]
+enum Decide {
+    /// `--yes`: accept everything.
+    All,
+    /// Ask on the terminal.
+    Prompt,
+    /// No terminal: print the plan, change nothing.
+    None,
 }
 
 /// Initialize cqs in a project directory
-/// Creates `.cqs/` directory, downloads the embedding model, and warms up the embedder.
-pub(crate) fn cmd_init(cli: &Cli, json: bool) -> Result<()> {
-    let _span = tracing::info_span!("cmd_init").entered();
+/// Surveys the project, applies the accepted proposals, creates `.cqs/`,
+/// downloads the embedding model, warms up the embedder, and optionally
+/// runs the first index.
+pub(crate) fn cmd_init(cli: &Cli, json: bool, yes: bool, index: bool) -> Result<()> {
+    let _span = tracing::info_span!("cmd_init", yes, index).entered();
     let root = find_project_root();
     let cqs_dir = root.join(cqs::INDEX_DIR);
 
@@ -30,6 +157,13 @@ pub(crate) fn cmd_init(cli: &Cli, json: bool) -> Result<()> {
     // and the local `--json` honor it.
     let want_json = cli.json || json;
     let quiet = cli.quiet || want_json;
+    let decide = if yes {
+        Decide::All
+    } else if !quiet && std::io::stdin().is_terminal() {
+        Decide::Prompt
+    } else {
+        Decide::None
+    };
 
     if !quiet {
         println!("Initializing cqs...");
@@ -66,20 +200,65 @@ pub(crate) fn cmd_init(cli: &Cli, json: bool) -> Result<()> {
     let gitignore_contents = "*\n!.gitignore\n";
     std::fs::write(&gitignore, gitignore_contents).context("Failed to create .gitignore")?;
 
+    // Survey and propose
+    let census = census(&root)?;
+    let provider = cqs::embedder::select_provider();
+    let mut plan = plan(
+        &root,
+        &census,
+        model_already_chosen(cli, &root, &cqs_dir),
+        provider,
+    );
+    if !quiet {
+        print_survey(&plan);
+    }
+
+    if !plan.ignore_rules.is_empty() && confirm(decide, "Add these rules to .cqsignore?", true)? {
+        let rules: Vec<&str> = plan.ignore_rules.iter().map(|p| p.rule.as_str()).collect();
+        append_ignore_rules(&root.join(".cqsignore"), &rules)?;
+        for proposal in &mut plan.ignore_rules {
+            proposal.applied = true;
+        }
+        if !quiet {
+            println!("Wrote {} rule(s) to .cqsignore", rules.len());
+        }
+    }
+    if let Some(model) = plan.model.as_mut() {
+        let question = format!("Use {} and record it in .cqs.toml?", model.name);
+        if confirm(decide, &question, true)? {
+            write_model_config(&root.join(".cqs.toml"), &model.name, &model.reason)?;
+            model.applied = true;
+            if !quiet {
+                println!("Wrote [embedding] model = \"{}\" to .cqs.toml", model.name);
+            }
+        }
+    }
+    let model_config = match plan.model.as_ref().filter(|m| m.applied) {
+        Some(m) => ModelConfig::from_preset(&m.name)
+            .with_context(|| format!("Unknown model preset '{}'", m.name))?
+            .apply_env_overrides(),
+        None => cli.try_model_config()?.clone(),
+    };
+    let (chunks, secs) = estimate(&census, &plan.ignore_rules, &root, &model_config, provider);
+    plan.estimated_chunks = chunks;
+    plan.estimated_index_secs = secs;
+    if decide == Decide::None && !quiet && has_open_proposals(&plan) {
+        println!("Nothing above was written; run `cqs init --yes` to apply it.");
+    }
+
     // Download model
     if !quiet {
         // Read the exact preset-declared download size. Custom models
         // (user-supplied repo) carry `None` and surface as "(size unknown)"
         // rather than misreporting a preset's number.
-        let size = match cli.try_model_config()?.approx_download_bytes {
+        let size = match model_config.approx_download_bytes {
             Some(bytes) => format_download_size(bytes),
             None => "(size unknown)".to_string(),
         };
         println!("Downloading model ({size})...");
     }
 
-    let embedder =
-        Embedder::new(cli.try_model_config()?.clone()).context("Failed to initialize embedder")?;
+    let embedder = Embedder::new(model_config.clone()).context("Failed to initialize embedder")?;
 
     if !quiet {
         println!("Detecting hardware... {}", embedder.provider());
@@ -87,28 +266,406 @@ pub(crate) fn cmd_init(cli: &Cli, json: bool) -> Result<()> {
 
     // Warm up
     embedder.warm()?;
+    drop(embedder);
 
     if !quiet {
         println!("Created .cqs/");
         println!();
+        println!(
+            "First index: {} chunks, {} on {}.",
+            plan.estimated_chunks,
+            format_duration(plan.estimated_index_secs),
+            plan.provider
+        );
+    }
+
+    let run_index = index || confirm(decide, "Index now?", true)?;
+    if run_index {
+        first_index(cli, model_config.clone(), quiet)?;
+    } else if !quiet {
         println!("Run 'cqs index' to index your codebase.");
     }
 
     if want_json {
-        let model_name = cli
-            .try_model_config()
-            .map(|c| c.name.clone())
-            .unwrap_or_default();
         crate::cli::json_envelope::emit_json(&InitOutput {
             initialized: true,
             cqs_dir: cqs_dir.display().to_string(),
-            model: model_name,
+            model: model_config.name.clone(),
+            wizard: plan,
+            indexed: run_index,
         })?;
     }
 
     Ok(())
 }
 
+/// Walk the project the way `cqs index` does and record each file's
+/// language and size.
+fn census(root: &Path) -> Result<Vec<CensusFile>> {
+    let _span = tracing::info_span!("init_census").entered();
+    let parser = cqs::Parser::new()?;
+    let files = crate::cli::enumerate_files(root, &parser, false)?;
+    Ok(files
+        .into_iter()
+        .filter_map(|rel| {
+            let language = cqs::walk_explain::detect_language(&rel).language?;
+            let bytes = std::fs::metadata(root.join(&rel)).map_or(0, |m| m.len());
+            Some(CensusFile {
+                rel,
+                language,
+                bytes,
+            })
+        })
+        .collect())
+}
+
+/// Whether something already names the model: `--model`, the env, a
+/// config `[embedding]`, the slot, or an existing index built with one.
+fn model_already_chosen(cli: &Cli, root: &Path, cqs_dir: &Path) -> bool {
+    if cli.model.is_some() || std::env::var_os("CQS_EMBEDDING_MODEL").is_some() {
+        return true;
+    }
+    if cqs::config::Config::load(root).embedding.is_some() {
+        return true;
+    }
+    let slot_model = cqs::slot::resolve_slot_name(cli.slot.as_deref(), cqs_dir)
+        .ok()
+        .and_then(|s| cqs::slot::read_slot_model(cqs_dir, &s.name));
+    slot_model.is_some() || cqs::resolve_index_db(cqs_dir).exists()
+}
+
+fn plan(
+    root: &Path,
+    census: &[CensusFile],
+    model_chosen: bool,
+    provider: ExecutionProvider,
+) -> InitPlan {
+    let mut by_language: BTreeMap<&str, (usize, u64)> = BTreeMap::new();
+    for file in census {
+        let entry = by_language.entry(file.language.as_str()).or_default();
+        entry.0 += 1;
+        entry.1 += file.bytes;
+    }
+    let mut languages: Vec<LanguageCount> = by_language
+        .into_iter()
+        .map(|(language, (files, bytes))| LanguageCount {
+            language: language.to_string(),
+            files,
+            bytes,
+        })
+        .collect();
+    languages.sort_by(|a, b| b.files.cmp(&a.files).then(a.language.cmp(&b.language)));
+
+    let ignore_rules = propose_ignore_rules(root, census);
+    let estimated_chunks = estimate_chunks(census.iter().map(|f| f.bytes));
+    let prose = prose_language(root, census);
+    let model = (!model_chosen).then(|| {
+        let (name, reason) = pick_model(estimated_chunks, is_gpu(provider), prose);
+        ModelProposal {
+            name: name.to_string(),
+            reason,
+            applied: false,
+        }
+    });
+    InitPlan {
+        files: census.len(),
+        bytes: census.iter().map(|f| f.bytes).sum(),
+        languages,
+        ignore_rules,
+        model,
+        provider: provider.to_string(),
+        estimated_chunks,
+        estimated_index_secs: 0,
+        prose_language: prose,
+    }
+}
+
+/// The language most of the project's markdown is written in, when that
+/// isn't English. Votes [`FtsLanguage::detect`] per paragraph over the
+/// first [`PROSE_SAMPLE_BYTES`] of up to [`PROSE_SAMPLE_FILES`] files, so a
+/// stray CJK name in an English README doesn't tip it.
+fn prose_language(root: &Path, census: &[CensusFile]) -> Option<FtsLanguage> {
+    use std::io::Read;
+
+    let mut votes: std::collections::HashMap<FtsLanguage, usize> = Default::default();
+    for file in census
+        .iter()
+        .filter(|f| f.language == "markdown")
+        .take(PROSE_SAMPLE_FILES)
+    {
+        let mut head = Vec::new();
+        let read = std::fs::File::open(root.join(&file.rel))
+            .and_then(|f| f.take(PROSE_SAMPLE_BYTES).read_to_end(&mut head));
+        if read.is_err() {
+            continue;
+        }
+        let text = String::from_utf8_lossy(&head);
+        for paragraph in text.split("\n\n").filter(|p| !p.trim().is_empty()) {
+            *votes.entry(FtsLanguage::detect(paragraph)).or_default() += 1;
+        }
+    }
+    votes
+        .into_iter()
+        .max_by_key(|&(lang, n)| (n, lang == FtsLanguage::English, lang.as_str()))
+        .map(|(lang, _)| lang)
+        .filter(|&lang| lang != FtsLanguage::English)
+}
+
+/// [`IGNORE_CANDIDATES`] that match at least one walked file.
+fn propose_ignore_rules(root: &Path, census: &[CensusFile]) -> Vec<IgnoreProposal> {
+    IGNORE_CANDIDATES
+        .iter()
+        .filter_map(|&(rule, reason)| {
+            let matcher = rule_matcher(root, &[rule])?;
+            let files = census
+                .iter()
+                .filter(|f| {
+                    matcher
+                        .matched_path_or_any_parents(root.join(&f.rel), false)
+                        .is_ignore()
+                })
+                .count();
+            (files > 0).then(|| IgnoreProposal {
+                rule: rule.to_string(),
+                reason: reason.to_string(),
+                files,
+                applied: false,
+            })
+        })
+        .collect()
+}
+
+fn rule_matcher(root: &Path, rules: &[&str]) -> Option<ignore::gitignore::Gitignore> {
+    let mut builder = ignore::gitignore::GitignoreBuilder::new(root);
+    for rule in rules {
+        builder.add_line(None, rule).ok()?;
+    }
+    builder.build().ok()
+}
+
+fn is_gpu(provider: ExecutionProvider) -> bool {
+    !matches!(provider, ExecutionProvider::CPU)
+}
+
+/// Default model unless the machine is CPU-only and the repo is large. The
+/// lighter model is an English fine-tune, so a project whose docs are in
+/// another language keeps the multilingual default regardless.
+fn pick_model(
+    estimated_chunks: u64,
+    gpu: bool,
+    prose: Option<FtsLanguage>,
+) -> (&'static str, String) {
+    let default = ModelConfig::PRESET_NAMES
+        .iter()
+        .copied()
+        .find(|&n| n == ModelConfig::default_model().name)
+        .unwrap_or(LIGHT_MODEL);
+    if gpu {
+        (default, "GPU available; the default model".to_string())
+    } else if let Some(lang) = prose {
+        (
+            default,
+            format!(
+                "docs read as {}; the default model is multilingual, the smaller one English-only",
+                lang.as_str()
+            ),
+        )
+    } else if estimated_chunks > CPU_LIGHT_MODEL_CHUNKS {
+        (
+            LIGHT_MODEL,
+            format!(
+                "~{estimated_chunks} chunks on CPU; the smaller code-search model indexes about 3x faster"
+            ),
+        )
+    } else {
+        (
+            default,
+            format!("~{estimated_chunks} chunks; the default model is quick enough on CPU"),
+        )
+    }
+}
+
+fn estimate_chunks(bytes: impl Iterator<Item = u64>) -> u64 {
+    bytes.map(|b| b.div_ceil(AVG_CHUNK_BYTES).max(1)).sum()
+}
+
+/// `(chunks, seconds)` for the first index, leaving out files the applied
+/// ignore rules drop.
+fn estimate(
+    census: &[CensusFile],
+    rules: &[IgnoreProposal],
+    root: &Path,
+    model: &ModelConfig,
+    provider: ExecutionProvider,
+) -> (u64, u64) {
+    let applied: Vec<&str> = rules
+        .iter()
+        .filter(|r| r.applied)
+        .map(|r| r.rule.as_str())
+        .collect();
+    let matcher = (!applied.is_empty())
+        .then(|| rule_matcher(root, &applied))
+        .flatten();
+    let kept = census.iter().filter(|f| {
+        matcher.as_ref().is_none_or(|m| {
+            !m.matched_path_or_any_parents(root.join(&f.rel), false)
+                .is_ignore()
+        })
+    });
+    let chunks = estimate_chunks(kept.map(|f| f.bytes));
+    (
+        chunks,
+        index_seconds(chunks, model.approx_download_bytes, is_gpu(provider)),
+    )
+}
+
+fn index_seconds(chunks: u64, model_bytes: Option<u64>, gpu: bool) -> u64 {
+    let base = if gpu {
+        GPU_CHUNKS_PER_SEC
+    } else {
+        CPU_CHUNKS_PER_SEC
+    };
+    let scale = model_bytes.map_or(1.0, |b| (REFERENCE_MODEL_BYTES / b as f64).min(1.0));
+    (chunks as f64 / (base * scale)).ceil() as u64
+}
+
+fn has_open_proposals(plan: &InitPlan) -> bool {
+    plan.ignore_rules.iter().any(|r| !r.applied) || plan.model.as_ref().is_some_and(|m| !m.applied)
+}
+
+/// Yes/no for one proposal. `[Y/n]` when `default` is yes.
+fn confirm(decide: Decide, question: &str, default: bool) -> Result<bool> {
+    match decide {
+        Decide::All => Ok(true),
+        Decide::None => Ok(false),
+        Decide::Prompt => {
+            eprint!("{question} {} ", if default { "[Y/n]" } else { "[y/N]" });
+            std::io::stderr().flush().ok();
+            let mut reply = String::new();
+            std::io::stdin()
+                .read_line(&mut reply)
+                .context("Failed to read response from stdin")?;
+            let reply = reply.trim();
+            Ok(if reply.is_empty() {
+                default
+            } else {
+                reply.eq_ignore_ascii_case("y") || reply.eq_ignore_ascii_case("yes")
+            })
+        }
+    }
+}
+
+/// Append `rules` to `.cqsignore`, skipping lines already there.
+fn append_ignore_rules(path: &Path, rules: &[&str]) -> Result<()> {
+    let existing = match std::fs::read_to_string(path) {
+        Ok(s) => s,
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => String::new(),
+        Err(e) => return Err(e).with_context(|| format!("Failed to read {}", path.display())),
+    };
+    let present: std::collections::HashSet<&str> = existing.lines().map(str::trim).collect();
+    let new: Vec<&str> = rules
+        .iter()
+        .copied()
+        .filter(|r| !present.contains(r))
+        .collect();
+    if new.is_empty() {
+        return Ok(());
+    }
+    let mut text = existing.clone();
+    if !text.is_empty() && !text.ends_with('\n') {
+        text.push('\n');
+    }
+    if !text.is_empty() {
+        text.push('\n');
+    }
+    text.push_str("# Added by `cqs init`\n");
+    for rule in new {
+        text.push_str(rule);
+        text.push('\n');
+    }
+    std::fs::write(path, text).with_context(|| format!("Failed to write {}", path.display()))
+}
+
+/// Record the model in `.cqs.toml` as an `[embedding]` table appended to
+/// whatever the file holds, so existing comments and layout survive.
+fn write_model_config(path: &Path, model: &str, reason: &str) -> Result<()> {
+    let existing = match std::fs::read_to_string(path) {
+        Ok(s) => s,
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => String::new(),
+        Err(e) => return Err(e).with_context(|| format!("Failed to read {}", path.display())),
+    };
+    let table: toml::Table = existing
+        .parse()
+        .with_context(|| format!("Failed to parse {}", path.display()))?;
+    if table.contains_key("embedding") {
+        return Ok(());
+    }
+    let mut text = existing;
+    if !text.is_empty() && !text.ends_with('\n') {
+        text.push('\n');
+    }
+    if !text.is_empty() {
+        text.push('\n');
+    }
+    text.push_str(&format!(
+        "# Picked by `cqs init`: {reason}\n[embedding]\nmodel = {}\n",
+        toml::Value::String(model.to_string())
+    ));
+    std::fs::write(path, text).with_context(|| format!("Failed to write {}", path.display()))
+}
+
+/// Run `cqs index` with the model init just set up. Same fresh-`Cli`
+/// shape as `cqs model swap`'s reindex: dispatch resolved the model before
+/// the wizard could change it.
+fn first_index(cli: &Cli, model: ModelConfig, quiet: bool) -> Result<()> {
+    use clap::Parser as _;
+    let mut fresh = Cli::try_parse_from(["cqs", "index"]).expect("`cqs index` must parse");
+    fresh.quiet = quiet;
+    fresh.verbose = cli.verbose;
+    fresh.resolved_model = Some(model);
+    let Some(crate::cli::definitions::Commands::Index { args }) = fresh.command.take() else {
+        unreachable!("`cqs index` parses to Commands::Index");
+    };
+    crate::cli::commands::cmd_index(&fresh, &args)
+}
+
+fn print_survey(plan: &InitPlan) {
+    println!(
+        "Found {} indexable file(s), {}:",
+        plan.files,
+        format_size(plan.bytes)
+    );
+    for lang in &plan.languages {
+        println!("  {:<12} {:>6} file(s)", lang.language, lang.files);
+    }
+    if !plan.ignore_rules.is_empty() {
+        println!("Proposed .cqsignore rules:");
+        for proposal in &plan.ignore_rules {
+            println!(
+                "  {:<16} {} file(s), {}",
+                proposal.rule, proposal.files, proposal.reason
+            );
+        }
+    }
+    if let Some(model) = &plan.model {
+        println!("Proposed model: {} ({})", model.name, model.reason);
+    }
+    if let Some(lang) = plan.prose_language {
+        let lang = lang.as_str();
+        println!("Docs read as {lang}. For searching in {lang}, add to .cqs.toml:");
+        println!(
+            "  llm_query_translate = \"auto\"   # search non-English queries in English translation"
+        );
+        println!("  [index.fts]");
+        if lang == "japanese" {
+            println!("  stop_words = [\"japanese\"]");
+        } else {
+            println!("  stemmer = \"{lang}\"");
+            println!("  stop_words = [\"{lang}\"]");
+        }
+    }
+}
+
 /// Render bytes as GB or MB with one decimal ("~1.3GB" / "~547MB").
 /// GB kicks in at 1 GiB.
 fn format_download_size(bytes: u64) -> String {
@@ -121,6 +678,27 @@ fn format_download_size(bytes: u64) -> String {
     }
 }
 
+/// Source size: KB below 1 MiB, else MB with one decimal.
+fn format_size(bytes: u64) -> String {
+    const MIB: f64 = 1024.0 * 1024.0;
+    if bytes < 1024 * 1024 {
+        format!("{} KB", bytes.div_ceil(1024))
+    } else {
+        format!("{:.1} MB", bytes as f64 / MIB)
+    }
+}
+
+/// "under a minute" / "~7 min" / "~2.5 h".
+fn format_duration(secs: u64) -> String {
+    if secs < 60 {
+        "under a minute".to_string()
+    } else if secs < 3600 {
+        format!("~{} min", secs.div_ceil(60))
+    } else {
+        format!("~{:.1} h", secs as f64 / 3600.0)
+    }
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -144,4 +722,127 @@ mod tests {
         let s = format_download_size(1024);
         assert_eq!(s, "~1MB");
     }
+
+    fn file(rel: &str, language: &str, bytes: u64) -> CensusFile {
+        CensusFile {
+            rel: PathBuf::from(rel),
+            language: language.to_string(),
+            bytes,
+        }
+    }
+
+    #[test]
+    fn plan_counts_languages_and_proposes_matching_rules() {
+        let dir = tempfile::tempdir().unwrap();
+        let census = vec![
+            file("src/main.rs", "rust", 3_000),
+            file("src/lib.rs", "rust", 1_000),
+            file("web/app.js", "javascript", 500),
+            file("web/node_modules/left-pad/index.js", "javascript", 200),
+            file("web/vendor.min.js", "javascript", 90_000),
+        ];
+        let survey = plan(dir.path(), &census, false, ExecutionProvider::CPU);
+        assert_eq!(survey.files, 5);
+        assert_eq!(survey.languages[0].language, "javascript");
+        assert_eq!(survey.languages[0].files, 3);
+        assert_eq!(survey.languages[1].files, 2);
+        let rules: Vec<(&str, usize)> = survey
+            .ignore_rules
+            .iter()
+            .map(|p| (p.rule.as_str(), p.files))
+            .collect();
+        assert_eq!(rules, vec![("node_modules/", 1), ("*.min.js", 1)]);
+        assert_eq!(survey.estimated_chunks, 2 + 1 + 1 + 1 + 60);
+        assert!(survey.model.is_some());
+        assert!(plan(dir.path(), &census, true, ExecutionProvider::CPU)
+            .model
+            .is_none());
+    }
+
+    #[test]
+    fn large_cpu_repos_get_the_light_model() {
+        let default = ModelConfig::default_model().name;
+        assert_eq!(pick_model(100, false, None).0, default);
+        assert_eq!(pick_model(50_000, true, None).0, default);
+        assert_eq!(pick_model(50_000, false, None).0, LIGHT_MODEL);
+        assert_eq!(
+            pick_model(50_000, false, Some(FtsLanguage::German)).0,
+            default
+        );
+        assert!(ModelConfig::from_preset(LIGHT_MODEL).is_some());
+    }
+
+    #[test]
+    fn prose_language_votes_over_markdown_paragraphs() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(
+            dir.path().join("README.md"),
+            "Das ist ein Werkzeug für die Suche in dem Code.\n\n\
+             Die Konfiguration wird aus der Datei gelesen und ist nicht optional.\n",
+        )
+        .unwrap();
+        std::fs::write(
+            dir.path().join("NOTES.md"),
+            "The index is rebuilt when the model changes.\n",
+        )
+        .unwrap();
+        let census = vec![
+            file("README.md", "markdown", 100),
+            file("NOTES.md", "markdown", 50),
+            file("src/main.rs", "rust", 3_000),
+        ];
+        assert_eq!(
+            prose_language(dir.path(), &census),
+            Some(FtsLanguage::German)
+        );
+        assert_eq!(prose_language(dir.path(), &census[1..]), None);
+    }
+
+    #[test]
+    fn estimate_skips_files_under_applied_rules() {
+        let dir = tempfile::tempdir().unwrap();
+        let census = vec![
+            file("a.rs", "rust", 3_000),
+            file("dist/b.js", "javascript", 30_000),
+        ];
+        let mut rules = propose_ignore_rules(dir.path(), &census);
+        let model = ModelConfig::from_preset(LIGHT_MODEL).unwrap();
+        let (all, _) = estimate(&census, &rules, dir.path(), &model, ExecutionProvider::CPU);
+        assert_eq!(all, 22);
+        rules[0].applied = true;
+        let (kept, secs) = estimate(&census, &rules, dir.path(), &model, ExecutionProvider::CPU);
+        assert_eq!(kept, 2);
+        assert_eq!(secs, 1);
+    }
+
+    #[test]
+    fn config_and_ignore_writes_append_and_keep_existing_content() {
+        let dir = tempfile::tempdir().unwrap();
+        let toml_path = dir.path().join(".cqs.toml");
+        std::fs::write(&toml_path, "# mine\nlimit = 7").unwrap();
+        write_model_config(&toml_path, "v9-200k", "because").unwrap();
+        let text = std::fs::read_to_string(&toml_path).unwrap();
+        assert!(text.starts_with("# mine\nlimit = 7\n"));
+        let parsed: cqs::config::Config = toml::from_str(&text).unwrap();
+        assert_eq!(parsed.limit, Some(7));
+        assert_eq!(parsed.embedding.unwrap().model, "v9-200k");
+        // A second run leaves an existing [embedding] alone.
+        write_model_config(&toml_path, "bge-large", "because").unwrap();
+        assert_eq!(std::fs::read_to_string(&toml_path).unwrap(), text);
+
+        let ignore_path = dir.path().join(".cqsignore");
+        std::fs::write(&ignore_path, "dist/\n").unwrap();
+        append_ignore_rules(&ignore_path, &["dist/", "*.min.js"]).unwrap();
+        assert_eq!(
+            std::fs::read_to_string(&ignore_path).unwrap(),
+            "dist/\n\n# Added by `cqs init`\n*.min.js\n"
+        );
+    }
+
+    #[test]
+    fn durations_render_coarsely() {
+        assert_eq!(format_duration(12), "under a minute");
+        assert_eq!(format_duration(61), "~2 min");
+        assert_eq!(format_duration(9_000), "~2.5 h");
+    }
 }
@@ -0,0 +1,180 @@
+//! Language detection report — `cqs languages list`.
+//!
+//! Shows every language this build knows, whether its parser is compiled
+//! in, which extensions route to it, and how many project files the walk
+//! detects as that language once `[languages] overrides` are applied.
+//! Each loaded `[[grammar]]` plugin gets its own row.
+
+use std::collections::HashMap;
+
+use anyhow::Result;
+use serde::Serialize;
+
+use cqs::language::{overrides, plugin, Language};
+
+use crate::cli::config::find_project_root;
+use crate::cli::definitions::Cli;
+
+#[derive(clap::Subcommand)]
+pub(crate) enum LanguagesCommand {
+    /// List languages with their grammar, extensions, and detected file counts.
+    List {
+        #[command(flatten)]
+        output: crate::cli::definitions::TextJsonArgs,
+    },
+}
+
+/// One row of `cqs languages list --json`.
+#[derive(Debug, Serialize)]
+struct LanguageEntry {
+    name: String,
+    /// `tree-sitter`, `custom`, `plugin` (loaded at runtime from
+    /// `[[grammar]]`), or `disabled` (feature compiled out).
+    parser: &'static str,
+    enabled: bool,
+    extensions: Vec<&'static str>,
+    /// Project files detected as this language.
+    files: usize,
+}
+
+/// One active `[languages] overrides` rule.
+#[derive(Debug, Serialize)]
+struct OverrideEntry {
+    pattern: String,
+    target: String,
+    /// Resolved language; `None` when the rule skips matching files.
+    language: Option<String>,
+    /// Files the rule claims. Always 0 for skip rules: the walk never
+    /// yields the files they match.
+    files: usize,
+}
+
+/// `cqs languages list --json` envelope.
+#[derive(Debug, Serialize)]
+struct LanguagesListOutput {
+    languages: Vec<LanguageEntry>,
+    overrides: Vec<OverrideEntry>,
+    total_files: usize,
+}
+
+pub(crate) fn cmd_languages(cli: &Cli, subcmd: &LanguagesCommand) -> Result<()> {
+    let _span = tracing::info_span!("cmd_languages").entered();
+    match subcmd {
+        LanguagesCommand::List { output } => cmd_languages_list(cli.json || output.json),
+    }
+}
+
+/// Walk the project once and tally detected languages and override hits.
+fn languages_list_core() -> Result<LanguagesListOutput> {
+    let root = find_project_root();
+    let exts: Vec<&str> = cqs::language::REGISTRY.supported_extensions().collect();
+    let files = cqs::enumerate_files(&root, &exts, false)?;
+
+    let table = overrides::active();
+    let mut by_language: HashMap<Language, usize> = HashMap::new();
+    let mut by_plugin: HashMap<&str, usize> = HashMap::new();
+    let mut by_rule: HashMap<&str, usize> = HashMap::new();
+    for file in &files {
+        if let Some(rule) = table.lookup(file) {
+            *by_rule.entry(rule.pattern.as_str()).or_default() += 1;
+        }
+        match Language::from_path(file) {
+            Some(Language::Plugin) => {
+                if let Some(p) = plugin::for_path(file) {
+                    *by_plugin.entry(p.name.as_str()).or_default() += 1;
+                }
+            }
+            Some(language) => *by_language.entry(language).or_default() += 1,
+            None => {}
+        }
+    }
+
+    // The `plugin` variant is an umbrella; list the loaded grammars instead.
+    let mut languages: Vec<LanguageEntry> = Language::all_variants()
+        .iter()
+        .filter(|language| **language != Language::Plugin)
+        .map(|language| {
+            let def = language.try_def();
+            let parser = match def {
+                None => "disabled",
+                Some(def) if def.grammar.is_some() => "tree-sitter",
+                Some(_) => "custom",
+            };
+            LanguageEntry {
+                name: language.to_string(),
+                parser,
+                enabled: def.is_some(),
+                extensions: def.map(|d| d.extensions.to_vec()).unwrap_or_default(),
+                files: by_language.get(language).copied().unwrap_or(0),
+            }
+        })
+        .collect();
+    languages.extend(plugin::plugins().iter().map(|p| LanguageEntry {
+        name: p.name.clone(),
+        parser: "plugin",
+        enabled: true,
+        extensions: p.extensions.iter().map(String::as_str).collect(),
+        files: by_plugin.get(p.name.as_str()).copied().unwrap_or(0),
+    }));
+    // Most-detected first, then by name so the order is stable.
+    languages.sort_by(|a, b| b.files.cmp(&a.files).then_with(|| a.name.cmp(&b.name)));
+
+    let overrides = table
+        .rules()
+        .iter()
+        .map(|rule| OverrideEntry {
+            pattern: rule.pattern.clone(),
+            target: rule.target.clone(),
+            language: rule.language.map(|l| l.to_string()),
+            files: by_rule.get(rule.pattern.as_str()).copied().unwrap_or(0),
+        })
+        .collect();
+
+    Ok(LanguagesListOutput {
+        languages,
+        overrides,
+        total_files: files.len(),
+    })
+}
+
+fn cmd_languages_list(json: bool) -> Result<()> {
+    let _span = tracing::info_span!("cmd_languages_list").entered();
+
+    let out = languages_list_core()?;
+
+    if json {
+        crate::cli::json_envelope::emit_json(&out)?;
+        return Ok(());
+    }
+
+    println!(
+        "{:<16} {:<12} {:>7}  EXTENSIONS",
+        "LANGUAGE", "PARSER", "FILES"
+    );
+    println!("{}", "-".repeat(60));
+    for e in &out.languages {
+        println!(
+            "{:<16} {:<12} {:>7}  {}",
+            e.name,
+            e.parser,
+            e.files,
+            e.extensions.join(", ")
+        );
+    }
+    println!();
+    println!("{} files detected", out.total_files);
+
+    if !out.overrides.is_empty() {
+        println!();
+        println!("Overrides ([languages] in .cqs.toml):");
+        for o in &out.overrides {
+            let target = o.language.as_deref().unwrap_or("(skipped)");
+            println!(
+                "  {:<24} {} -> {} ({} files)",
+                o.pattern, o.target, target, o.files
+            );
+        }
+    }
+
+    Ok(())
+}
@@ -0,0 +1,145 @@
+//! `cqs llm` subcommands — LLM passes run on demand rather than as part of
+//! `cqs index`.
+//!
+//! `docgen --apply-as-patch <out.diff>` generates doc comments for
+//! undocumented public symbols (the `--improve-docs` pass, restricted to each
+//! language's public surface) and writes them as one reviewable unified diff.
+//! Source files are never touched: apply with `git apply <out.diff>` after
+//! review. Generated docs are cached by content hash like the index pass, so
+//! rerunning after a partial apply only pays for new candidates.
+
+use std::collections::BTreeMap;
+use std::path::PathBuf;
+
+use anyhow::{Context, Result};
+use clap::Subcommand;
+use serde::Serialize;
+
+use crate::cli::definitions::TextJsonArgs;
+use crate::cli::files::{acquire_index_lock, LockWait};
+use crate::cli::Cli;
+
+#[derive(Subcommand, Clone, Debug)]
+pub(crate) enum LlmCommand {
+    /// Generate doc comments for undocumented public symbols as a patch
+    ///
+    /// Comments follow each language's convention (`///`, docstrings,
+    /// Javadoc, …) and are inserted above the symbol, or inside the body for
+    /// Python. Nothing is written to source files.
+    Docgen {
+        /// Write the unified diff here (review, then `git apply <path>`)
+        #[arg(long, value_name = "PATH")]
+        apply_as_patch: PathBuf,
+        /// Also replace existing doc comments, not just missing or thin ones
+        #[arg(long)]
+        improve_all: bool,
+        /// Maximum number of symbols to document (0 = unlimited)
+        #[arg(long)]
+        max_docs: Option<usize>,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
+}
+
+#[derive(Debug, Serialize)]
+struct DocgenOutput {
+    patch: String,
+    /// Files the patch touches
+    files: usize,
+    /// Doc comments the patch inserts or replaces
+    functions: usize,
+    /// Generated docs that produced no edit (symbol moved, already documented)
+    skipped: usize,
+}
+
+pub(crate) fn cmd_llm(cli: &Cli, subcmd: &LlmCommand) -> Result<()> {
+    let _span = tracing::info_span!("cmd_llm").entered();
+    match subcmd {
+        LlmCommand::Docgen {
+            apply_as_patch,
+            improve_all,
+            max_docs,
+            output,
+        } => cmd_docgen(
+            cli,
+            apply_as_patch,
+            *improve_all,
+            max_docs.unwrap_or(0),
+            cli.json || output.json,
+        ),
+    }
+}
+
+fn cmd_docgen(
+    cli: &Cli,
+    out: &std::path::Path,
+    improve_all: bool,
+    max_docs: usize,
+    json: bool,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_docgen", max_docs).entered();
+
+    let ctx = crate::cli::CommandContext::open_readwrite(cli)?;
+    let store = &ctx.store;
+    let root = &ctx.root;
+    let cqs_dir = &ctx.cqs_dir;
+
+    // The pass writes generated docs into the summaries cache; serialize with
+    // watch/index like the `--improve-docs` pass does by running under it.
+    let _lock = acquire_index_lock(cqs_dir, LockWait::from_cli(cli))?;
+
+    if !cli.quiet && !json {
+        eprintln!("Generating doc comments for public symbols...");
+    }
+    let config = cqs::config::Config::load(root);
+    let doc_results =
+        cqs::llm::doc_comment_pass(store, &config, max_docs, improve_all, true, Some(cqs_dir))
+            .context("Doc comment generation failed")?;
+    let generated = doc_results.len();
+
+    // Sorted by path so the patch reads (and diffs across runs) stably.
+    let mut by_file: BTreeMap<PathBuf, Vec<_>> = BTreeMap::new();
+    for r in doc_results {
+        by_file.entry(r.file.clone()).or_default().push(r);
+    }
+    let parser = cqs::Parser::new()?;
+    let mut patches = Vec::with_capacity(by_file.len());
+    for (path, edits) in &by_file {
+        // Chunk paths are project-relative; the rewriter reads from disk.
+        let abs = root.join(path);
+        match cqs::doc_writer::rewriter::render_patch(&abs, root, edits, &parser) {
+            Ok(Some(patch)) => patches.push(patch),
+            Ok(None) => {}
+            Err(e) => tracing::warn!(
+                file = %path.display(),
+                error = %e,
+                "Doc patch render failed"
+            ),
+        }
+    }
+    let functions: usize = patches.iter().map(|p| p.applied).sum();
+
+    if !patches.is_empty() {
+        cqs::doc_writer::rewriter::write_combined_patch(out, &patches)
+            .with_context(|| format!("Failed to write {}", out.display()))?;
+    }
+
+    let result = DocgenOutput {
+        patch: cqs::normalize_path(out),
+        files: patches.len(),
+        functions,
+        skipped: generated.saturating_sub(functions),
+    };
+    if json {
+        crate::cli::json_envelope::emit_json(&result)?;
+    } else if patches.is_empty() {
+        println!("No undocumented public symbols to document; nothing written.");
+    } else {
+        println!(
+            "Wrote {} doc comment(s) across {} file(s) to {}",
+            result.functions, result.files, result.patch
+        );
+        println!("  Review, then apply with: git apply {}", result.patch);
+    }
+    Ok(())
+}
@@ -1,31 +1,47 @@
-//! Infrastructure commands — init, doctor, audit mode, telemetry, projects, references, cache, ping, model
+//! Infrastructure commands — init, doctor, daemon service, audit mode, telemetry, projects, references, cache, ping, reload-config, model, languages, schema, backup/restore, on-demand LLM passes
 
 mod audit_mode;
+mod backup;
 mod cache_cmd;
 #[cfg(feature = "convert")]
 mod convert;
+mod daemon_cmd;
 mod doctor;
 mod hook;
 mod init;
+mod languages;
+#[cfg(feature = "llm-summaries")]
+mod llm_cmd;
 mod model;
 mod ping;
 mod project;
 mod reference;
+mod reload_config;
+mod schema_cmd;
 mod slot;
 mod status;
 mod telemetry_cmd;
 
 pub(crate) use audit_mode::cmd_audit_mode;
+pub(crate) use backup::{cmd_backup, cmd_replica, cmd_restore};
 pub(crate) use cache_cmd::{cmd_cache, CacheCommand};
 #[cfg(feature = "convert")]
 pub(crate) use convert::cmd_convert;
+pub(crate) use daemon_cmd::{cmd_daemon, DaemonCommand};
 pub(crate) use doctor::cmd_doctor;
 pub(crate) use hook::{cmd_hook, HookCommand};
 pub(crate) use init::cmd_init;
-pub(crate) use model::{cmd_model, daemon_control_hint, DaemonHint, ModelCommand};
+pub(crate) use languages::{cmd_languages, LanguagesCommand};
+#[cfg(feature = "llm-summaries")]
+pub(crate) use llm_cmd::{cmd_llm, LlmCommand};
+pub(crate) use model::{
+    cmd_model, daemon_control_hint, human_bytes, stage_upgrade_batch, DaemonHint, ModelCommand,
+};
 pub(crate) use ping::cmd_ping;
-pub(crate) use project::{cmd_project, ProjectCommand};
+pub(crate) use project::{cmd_project, cmd_query_federated, ProjectCommand};
 pub(crate) use reference::{cmd_ref, RefCommand};
+pub(crate) use reload_config::cmd_reload_config;
+pub(crate) use schema_cmd::{cmd_schema, SchemaCommand};
 pub(crate) use slot::{cmd_slot, SlotCommand};
 pub(crate) use status::cmd_status;
 pub(crate) use telemetry_cmd::{cmd_telemetry, cmd_telemetry_reset};
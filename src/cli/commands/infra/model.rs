@@ -1,4 +1,4 @@
-//! Model swap commands — `cqs model { show, list, swap }`.
+//! Model swap commands — `cqs model { show, list, swap, upgrade }`.
 //!
 //! `cqs model swap <preset>` automates the embedder backup-and-rebuild
 //! sequence with restore-on-failure semantics:
@@ -11,6 +11,12 @@
 //!      the daemon, and surface the error.
 //!   6. On success: leave the backup in place (user can `rm -rf` after they
 //!      verify the new index is healthy) and restart the daemon.
+//!
+//! `cqs model upgrade <preset>` is the non-blocking alternative: it only
+//! queues the target model. `cqs watch` embeds chunks with it in idle time
+//! and stages the vectors beside the served ones (schema v45), search keeps
+//! using the current model, and `cqs model upgrade --finish` swaps the
+//! staged vectors in once every chunk has one.
 
 use std::path::{Path, PathBuf};
 
@@ -18,12 +24,14 @@ use anyhow::{bail, Context, Result};
 use serde::Serialize;
 
 use cqs::embedder::ModelConfig;
-use cqs::Store;
+use cqs::store::EmbeddingUpgrade;
+use cqs::{Embedder, HnswKind, Store};
 
 use crate::cli::args::IndexArgs;
-use crate::cli::commands::index::cmd_index;
+use crate::cli::commands::index::{build_hnsw_base_index, build_hnsw_index, cmd_index};
 use crate::cli::config::find_project_root;
 use crate::cli::definitions::Cli;
+use crate::cli::{acquire_index_lock, LockWait};
 
 // ---------------------------------------------------------------------------
 // Daemon control hints
@@ -140,6 +148,25 @@ pub(crate) enum ModelCommand {
         #[command(flatten)]
         output: crate::cli::definitions::TextJsonArgs,
     },
+    /// Move to another embedder without a blocking reindex: queue the
+    /// preset, let `cqs watch` embed chunks with it in idle time while
+    /// search keeps using the current model, then cut over with `--finish`.
+    /// With no preset and no flag, shows the pending upgrade's progress.
+    Upgrade {
+        /// Preset short name to upgrade to.
+        preset: Option<String>,
+        /// Embed the remaining chunks now, in the foreground, then cut over.
+        #[arg(long, conflicts_with_all = ["finish", "cancel"])]
+        now: bool,
+        /// Swap the staged vectors in. Fails while any chunk lacks one.
+        #[arg(long, conflicts_with_all = ["preset", "cancel"])]
+        finish: bool,
+        /// Drop the pending upgrade and everything staged for it.
+        #[arg(long, conflicts_with = "preset")]
+        cancel: bool,
+        #[command(flatten)]
+        output: crate::cli::definitions::TextJsonArgs,
+    },
 }
 
 // ---------------------------------------------------------------------------
@@ -227,6 +254,20 @@ struct ModelSwapOutput {
     backup_path: Option<String>,
 }
 
+/// `cqs model upgrade --json` payload.
+#[derive(Debug, Serialize)]
+struct ModelUpgradeOutput {
+    /// What this run did: `status`, `queued`, `finished`, or `cancelled`.
+    action: &'static str,
+    /// Model search uses now (after a cutover, the new one).
+    current: String,
+    /// Pending upgrade's model; `None` when nothing is queued.
+    target: Option<String>,
+    /// Chunks with a current staged vector.
+    staged: u64,
+    total_chunks: u64,
+}
+
 // ---------------------------------------------------------------------------
 // Entry point
 // ---------------------------------------------------------------------------
@@ -242,6 +283,18 @@ pub(crate) fn cmd_model(cli: &Cli, subcmd: &ModelCommand) -> Result<()> {
             no_backup,
             output,
         } => cmd_model_swap(cli, preset, *no_backup, cli.json || output.json),
+        ModelCommand::Upgrade {
+            preset,
+            now,
+            finish,
+            cancel,
+            output,
+        } => cmd_model_upgrade(
+            cli,
+            preset.as_deref(),
+            UpgradeAction::from_flags(*now, *finish, *cancel),
+            cli.json || output.json,
+        ),
     }
 }
 
@@ -598,6 +651,331 @@ fn cmd_model_swap(cli: &Cli, preset: &str, no_backup: bool, json: bool) -> Resul
     }
 }
 
+// ---------------------------------------------------------------------------
+// `cqs model upgrade [<preset>]`
+// ---------------------------------------------------------------------------
+
+/// Chunks embedded per batch by `cqs model upgrade --now`.
+const UPGRADE_NOW_BATCH: usize = 256;
+
+/// Which `cqs model upgrade` flag was given (clap rejects combinations).
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum UpgradeAction {
+    Queue,
+    Now,
+    Finish,
+    Cancel,
+}
+
+impl UpgradeAction {
+    fn from_flags(now: bool, finish: bool, cancel: bool) -> Self {
+        if finish {
+            Self::Finish
+        } else if cancel {
+            Self::Cancel
+        } else if now {
+            Self::Now
+        } else {
+            Self::Queue
+        }
+    }
+}
+
+/// Queue, advance, finish, or cancel a background embedder upgrade, then
+/// report where it stands.
+fn cmd_model_upgrade(
+    cli: &Cli,
+    preset: Option<&str>,
+    action: UpgradeAction,
+    json: bool,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_model_upgrade", ?preset, ?action).entered();
+
+    let root = find_project_root();
+    let cqs_dir = cqs::resolve_index_dir(&root);
+    let index_path = cqs::resolve_index_db(&cqs_dir);
+    if !index_path.exists() {
+        let msg = format!(
+            "No index at {}. Run `cqs init && cqs index` first.",
+            index_path.display()
+        );
+        if json {
+            crate::cli::json_envelope::emit_json_error("no_index", &msg)?;
+            std::process::exit(1);
+        }
+        bail!("{msg}");
+    }
+
+    let target = match preset {
+        Some(name) => match ModelConfig::from_preset(name) {
+            Some(c) => Some(c),
+            None => {
+                let valid = ModelConfig::PRESET_NAMES.join(", ");
+                let msg = format!(
+                    "Unknown preset '{name}'. Valid presets: {valid}. Run `cqs model list` for repos."
+                );
+                if json {
+                    crate::cli::json_envelope::emit_json_error("unknown_preset", &msg)?;
+                    std::process::exit(1);
+                }
+                bail!("{msg}");
+            }
+        },
+        None => None,
+    };
+
+    let mut reported = match action {
+        UpgradeAction::Finish => "finished",
+        UpgradeAction::Cancel => "cancelled",
+        UpgradeAction::Queue if target.is_some() => "queued",
+        UpgradeAction::Queue => "status",
+        UpgradeAction::Now => "finished",
+    };
+
+    if action != UpgradeAction::Finish {
+        let _lock = acquire_index_lock(&cqs_dir, LockWait::from_cli(cli))?;
+        let store = Store::open(&index_path)
+            .with_context(|| format!("Failed to open index at {}", index_path.display()))?;
+
+        if action == UpgradeAction::Cancel && !store.cancel_embedding_upgrade()? {
+            reported = "status";
+        }
+
+        if let Some(cfg) = &target {
+            let current = store.try_stored_model_name()?.unwrap_or_default();
+            if current == cfg.name || current == cfg.repo {
+                if store.pending_embedding_upgrade()?.is_some() {
+                    bail!(
+                        "already on {}; cancel the pending upgrade with `cqs model upgrade --cancel`",
+                        cfg.name
+                    );
+                }
+                if json {
+                    let out = serde_json::json!({
+                        "current": current,
+                        "noop": true,
+                        "message": format!("already on {}, no-op", cfg.name),
+                    });
+                    crate::cli::json_envelope::emit_json(&out)?;
+                } else {
+                    println!("already on {}, no-op", cfg.name);
+                }
+                return Ok(());
+            }
+            store.begin_embedding_upgrade(&cfg.repo, cfg.dim)?;
+        }
+
+        if action == UpgradeAction::Now {
+            let upgrade = store.pending_embedding_upgrade()?.ok_or_else(|| {
+                anyhow::anyhow!("No embedding upgrade is pending. Start one with `cqs model upgrade <preset> --now`.")
+            })?;
+            stage_upgrade_now(cli, &store, &upgrade)?;
+        }
+    }
+
+    if matches!(action, UpgradeAction::Finish | UpgradeAction::Now) {
+        let (from, swapped) = finish_embedding_upgrade(cli, &cqs_dir, &index_path)?;
+        if !cli.quiet && !json {
+            eprintln!("swapped {swapped} chunk embeddings (was {from})");
+        }
+    }
+
+    let store = Store::open_readonly(&index_path)
+        .with_context(|| format!("Failed to open index at {}", index_path.display()))?;
+    let current = store.try_stored_model_name()?.unwrap_or_default();
+    let pending = store.pending_embedding_upgrade()?;
+    let (staged, total_chunks) = match &pending {
+        Some(upgrade) => {
+            let p = store.embedding_upgrade_progress(&upgrade.model)?;
+            (p.staged, p.total)
+        }
+        None => (0, store.chunk_count()?),
+    };
+    let out = ModelUpgradeOutput {
+        action: reported,
+        current,
+        target: pending.map(|u| u.model),
+        staged,
+        total_chunks,
+    };
+
+    if json {
+        crate::cli::json_envelope::emit_json(&out)?;
+        return Ok(());
+    }
+    match (out.action, &out.target) {
+        ("finished", _) => {
+            println!(
+                "upgraded: now on {} ({} chunks)",
+                out.current, out.total_chunks
+            );
+            println!("run `cqs index` to re-apply call-graph enrichment with the new model.");
+        }
+        ("cancelled", _) => println!("upgrade cancelled; staying on {}", out.current),
+        (_, None) => println!("no upgrade pending (current model: {})", out.current),
+        (action, Some(target)) => {
+            let pct = if out.total_chunks == 0 {
+                100.0
+            } else {
+                out.staged as f64 * 100.0 / out.total_chunks as f64
+            };
+            if action == "queued" {
+                println!("queued upgrade: {} -> {}", out.current, target);
+            } else {
+                println!("upgrade: {} -> {}", out.current, target);
+            }
+            println!(
+                "staged:  {}/{} chunks ({pct:.1}%)",
+                out.staged, out.total_chunks
+            );
+            if out.staged >= out.total_chunks {
+                println!("ready: run `cqs model upgrade --finish` to switch.");
+            } else {
+                println!(
+                    "`cqs watch` embeds the rest while idle; `cqs model upgrade --now` does it in the foreground."
+                );
+            }
+        }
+    }
+    Ok(())
+}
+
+/// Embed every chunk still missing a staged vector, in the foreground.
+fn stage_upgrade_now(cli: &Cli, store: &Store, upgrade: &EmbeddingUpgrade) -> Result<()> {
+    let _span = tracing::info_span!("stage_upgrade_now", model = %upgrade.model).entered();
+    let cfg = ModelConfig::from_preset(&upgrade.model).ok_or_else(|| {
+        anyhow::anyhow!("upgrade target {:?} is not a known preset", upgrade.model)
+    })?;
+    let embedder = Embedder::new(cfg).context("Failed to load the upgrade model")?;
+    loop {
+        let embedded = stage_upgrade_batch(store, &embedder, upgrade, UPGRADE_NOW_BATCH)?;
+        if embedded == 0 {
+            return Ok(());
+        }
+        if !cli.quiet {
+            let p = store.embedding_upgrade_progress(&upgrade.model)?;
+            eprint!(
+                "\rembedding with {}: {}/{} chunks",
+                upgrade.model, p.staged, p.total
+            );
+            if p.is_complete() {
+                eprintln!();
+            }
+        }
+    }
+}
+
+/// Embed up to `limit` chunks that still lack a vector from `upgrade`'s
+/// model and stage them. Returns how many were embedded — 0 once every
+/// chunk has a current one.
+///
+/// The text is the base NL description the first index pass embeds; the
+/// call-graph enrichment is redone by `cqs index` after the cutover. Shared
+/// by `--now` and the watch loop's idle-time upgrade.
+pub(crate) fn stage_upgrade_batch(
+    store: &Store,
+    embedder: &Embedder,
+    upgrade: &EmbeddingUpgrade,
+    limit: usize,
+) -> Result<usize> {
+    let chunks = store.chunks_awaiting_upgrade(&upgrade.model, limit)?;
+    if chunks.is_empty() {
+        return Ok(0);
+    }
+    let max_seq = embedder.model_config().max_seq_length;
+    let texts: Vec<String> = chunks
+        .iter()
+        .map(|cs| cqs::generate_nl_description_with_seq_len(&cs.into(), max_seq))
+        .collect();
+    let text_refs: Vec<&str> = texts.iter().map(String::as_str).collect();
+    let embeddings = embedder.embed_documents(&text_refs)?;
+    let embedded = chunks.len();
+    let rows: Vec<_> = chunks
+        .into_iter()
+        .zip(embeddings)
+        .map(|(cs, emb)| (cs.id, cs.content_hash, emb))
+        .collect();
+    store.stage_upgrade_embeddings(&upgrade.model, upgrade.dim, &rows)?;
+    Ok(embedded)
+}
+
+/// Cut over to the staged vectors: stop the daemon (its embedder and HNSW
+/// graph belong to the old model), swap the vectors in, rebuild both HNSW
+/// indexes, and restart the daemon. Returns the old model and the number of
+/// chunks swapped.
+fn finish_embedding_upgrade(
+    cli: &Cli,
+    cqs_dir: &Path,
+    index_path: &Path,
+) -> Result<(String, usize)> {
+    let _span = tracing::info_span!("finish_embedding_upgrade").entered();
+
+    // Check before touching the daemon, so an early `--finish` costs nothing.
+    {
+        let store = Store::open_readonly(index_path)
+            .with_context(|| format!("Failed to open index at {}", index_path.display()))?;
+        let upgrade = store.pending_embedding_upgrade()?.ok_or_else(|| {
+            anyhow::anyhow!(
+                "No embedding upgrade is pending. Start one with `cqs model upgrade <preset>`."
+            )
+        })?;
+        let progress = store.embedding_upgrade_progress(&upgrade.model)?;
+        if !progress.is_complete() {
+            bail!(
+                "Upgrade to {} has {}/{} chunks staged. Leave `cqs watch` running, or run \
+                 `cqs model upgrade --now` to embed the rest in the foreground.",
+                upgrade.model,
+                progress.staged,
+                progress.total
+            );
+        }
+    }
+
+    let daemon_was_running = stop_daemon_best_effort(cqs_dir);
+    if !cli.quiet && daemon_was_running {
+        eprintln!("stopped cqs-watch daemon");
+    }
+    let result = (|| -> Result<(String, usize)> {
+        let _lock = acquire_index_lock(cqs_dir, LockWait::from_cli(cli))?;
+        let mut store = Store::open(index_path)
+            .with_context(|| format!("Failed to open index at {}", index_path.display()))?;
+        let from = store.try_stored_model_name()?.unwrap_or_default();
+        let swapped = store.finish_embedding_upgrade()?;
+        rebuild_vector_indexes(cli, &store, cqs_dir)?;
+        Ok((from, swapped))
+    })();
+    restart_daemon_if_needed(daemon_was_running, cli.quiet);
+    result
+}
+
+/// Rebuild the enriched and base HNSW indexes after a cutover and drop any
+/// CAGRA file, which was built from the old vectors. Until the rebuild
+/// saves, the dirty flags keep search on brute force.
+fn rebuild_vector_indexes(cli: &Cli, store: &Store, cqs_dir: &Path) -> Result<()> {
+    if let Some((total, cqs::hnsw::SaveOutcome::Saved)) = build_hnsw_index(store, cqs_dir)? {
+        store.set_hnsw_dirty(HnswKind::Enriched, false)?;
+        if !cli.quiet {
+            eprintln!("HNSW index: {total} vectors");
+        }
+    }
+    match build_hnsw_base_index(store, cqs_dir, None) {
+        Ok(Some((_, cqs::hnsw::SaveOutcome::Saved))) => {
+            store.set_hnsw_dirty(HnswKind::Base, false)?;
+        }
+        Ok(_) => {}
+        Err(e) => {
+            tracing::warn!(error = %e, "Base HNSW build failed after upgrade, enriched index still usable");
+        }
+    }
+    if let Err(e) = cqs::vector_backend::sync_configured(store, cqs_dir) {
+        tracing::warn!(error = %e, "External vector store sync failed after upgrade");
+    }
+    for name in ["index.cagra", "index.cagra.meta"] {
+        let _ = std::fs::remove_file(cqs_dir.join(name));
+    }
+    Ok(())
+}
+
 // ---------------------------------------------------------------------------
 // Helpers
 // ---------------------------------------------------------------------------
@@ -617,6 +995,7 @@ fn reindex_with_new_model(cli: &Cli, new_cfg: ModelConfig) -> Result<()> {
 
     let args = IndexArgs {
         force: true,
+        swap: false,
         dry_run: false,
         no_ignore: false,
         accept_shared_notes: true,
@@ -638,6 +1017,12 @@ fn reindex_with_new_model(cli: &Cli, new_cfg: ModelConfig) -> Result<()> {
         // pass, so the prune is a no-op here. Default-on is fine.
         no_prune_summaries: false,
         umap: false,
+        only: Vec::new(),
+        stdin: false,
+        origin: None,
+        shard: None,
+        // `[index] sandbox_parsing` / env still apply through the pipeline.
+        sandbox: false,
         // Model swap drives a programmatic reindex; we never want the swap
         // path to spit a JSON envelope to stdout (the caller is already
         // mid-text-rendering). Keep the inner index run on the text path
@@ -675,7 +1060,7 @@ fn clone_cli_for_reindex(cli: &Cli) -> Cli {
 /// disappearance. Without this, `cqs model swap` on macOS would proceed
 /// against a live daemon holding the OLD model in memory and the old store
 /// pool open against the database file the swap is rewriting.
-fn stop_daemon_best_effort(cqs_dir: &Path) -> bool {
+pub(super) fn stop_daemon_best_effort(cqs_dir: &Path) -> bool {
     let _span = tracing::info_span!("stop_daemon_best_effort").entered();
     let was_running = daemon_socket_alive(cqs_dir);
     if !was_running {
@@ -822,7 +1207,7 @@ fn stop_daemon_via_sigterm_macos(cqs_dir: &Path) -> Result<()> {
 /// Linux: `systemctl --user start cqs-watch`.
 /// macOS — spawn `cqs watch --serve` directly (no systemctl); detached
 /// stdio so we don't block on the long-lived child.
-fn restart_daemon_if_needed(was_running: bool, quiet: bool) {
+pub(super) fn restart_daemon_if_needed(was_running: bool, quiet: bool) {
     if !was_running {
         return;
     }
@@ -1048,8 +1433,8 @@ fn file_size_or_zero(path: &Path) -> u64 {
 }
 
 /// Pretty-print a byte count as KiB / MiB / GiB. Used for `cqs model show`
-/// human output.
-fn human_bytes(n: u64) -> String {
+/// and `cqs compress` human output.
+pub(crate) fn human_bytes(n: u64) -> String {
     const KIB: u64 = 1024;
     const MIB: u64 = KIB * 1024;
     const GIB: u64 = MIB * 1024;
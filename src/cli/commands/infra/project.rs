@@ -1,6 +1,7 @@
 //! Project management command — register, list, remove, search across projects
 //!
-//! Core struct is [`ProjectSearchResult`]; built in the `Search` subcommand handler.
+//! Core struct is [`ProjectSearchResult`]; built by the `Search` subcommand
+//! handler and by the top-level `cqs <q> --index <NAME>` federated search.
 
 use std::path::PathBuf;
 
@@ -11,7 +12,9 @@ use cqs::embedder::ModelConfig;
 use cqs::normalize_path;
 use cqs::Embedder;
 use cqs::SearchFilter;
-use cqs::{search_across_projects, ProjectRegistry};
+use cqs::{
+    project_freshness, search_federated, CrossProjectResult, ProjectFreshness, ProjectRegistry,
+};
 
 use crate::cli::definitions::TextJsonArgs;
 use crate::cli::Cli;
@@ -29,16 +32,21 @@ pub(crate) struct ProjectSearchResult {
     #[serde(skip_serializing_if = "Option::is_none")]
     pub signature: Option<String>,
     pub score: f32,
+    /// Similarity as the project's own index scored it; differs from
+    /// `score` only when the federated projects use different models.
+    pub raw_score: f32,
 }
 
 /// JSON envelope row for `cqs --json project list`.
-/// `indexed` is true if either `.cqs/index.db` or `.cq/index.db` sits on
-/// disk — mirrors the text-mode `ok` / `missing index` status string.
+/// `indexed` is true when the project's index (active slot, or legacy
+/// `.cq/index.db`) opens; `freshness` then says how current it is.
 #[derive(Debug, serde::Serialize)]
 pub(crate) struct ProjectListEntry {
     pub name: String,
     pub path: String,
     pub indexed: bool,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub freshness: Option<ProjectFreshness>,
 }
 
 /// `cqs project list --json` envelope: `{projects: [...]}`.
@@ -108,6 +116,9 @@ pub(crate) enum ProjectCommand {
     Search {
         /// Search query
         query: String,
+        /// Search only these registered projects (repeatable). Default: all.
+        #[arg(long = "index", value_name = "NAME")]
+        indexes: Vec<String>,
         /// Max results
         #[arg(short = 'n', long, default_value = "5")]
         limit: usize,
@@ -191,32 +202,58 @@ pub(crate) fn cmd_project(
         ProjectCommand::List { output } => {
             let json = cli.json || output.json;
             let registry = ProjectRegistry::load()?;
-            if json {
-                let entries: Vec<ProjectListEntry> = registry
-                    .project
-                    .iter()
-                    .map(|e| ProjectListEntry {
+            let entries: Vec<ProjectListEntry> = registry
+                .project
+                .iter()
+                .map(|e| {
+                    // A broken index lists as unindexed rather than failing
+                    // the whole list.
+                    let freshness = project_freshness(e).unwrap_or_else(|err| {
+                        tracing::warn!(project = %e.name, error = %err, "Failed to check project index");
+                        None
+                    });
+                    ProjectListEntry {
                         name: e.name.clone(),
                         path: normalize_path(&e.path),
-                        indexed: e.path.join(".cqs/index.db").exists()
-                            || e.path.join(".cq/index.db").exists(),
-                    })
-                    .collect();
+                        indexed: freshness.is_some(),
+                        freshness,
+                    }
+                })
+                .collect();
+            if json {
                 crate::cli::json_envelope::emit_json(&ProjectListOutput { projects: entries })?;
-            } else if registry.project.is_empty() {
+            } else if entries.is_empty() {
                 println!("No projects registered.");
                 println!("Use 'cqs project register <name> <path>' to add one.");
             } else {
                 println!("Registered projects:");
-                for entry in &registry.project {
-                    let status = if entry.path.join(".cqs/index.db").exists()
-                        || entry.path.join(".cq/index.db").exists()
-                    {
-                        "ok".green().to_string()
-                    } else {
-                        "missing index".red().to_string()
+                let now = std::time::SystemTime::now()
+                    .duration_since(std::time::UNIX_EPOCH)
+                    .map(|d| d.as_secs() as i64)
+                    .unwrap_or(0);
+                for entry in &entries {
+                    let status = match &entry.freshness {
+                        None => "missing index".red().to_string(),
+                        Some(f) => {
+                            let mut status = format!("{} files", f.files);
+                            if f.stale > 0 {
+                                status.push_str(&format!(", {} stale", f.stale));
+                            }
+                            if f.missing > 0 {
+                                status.push_str(&format!(", {} missing", f.missing));
+                            }
+                            if let Some(at) = f.indexed_at {
+                                status
+                                    .push_str(&format!(", indexed {} ago", since_label(now - at)));
+                            }
+                            if f.stale > 0 || f.missing > 0 {
+                                status.yellow().to_string()
+                            } else {
+                                status.green().to_string()
+                            }
+                        }
                     };
-                    println!("  {} — {} [{}]", entry.name, entry.path.display(), status);
+                    println!("  {} — {} [{}]", entry.name, entry.path, status);
                 }
             }
             Ok(())
@@ -240,6 +277,7 @@ pub(crate) fn cmd_project(
         }
         ProjectCommand::Search {
             query,
+            indexes,
             limit,
             threshold,
             name_boost,
@@ -252,8 +290,6 @@ pub(crate) fn cmd_project(
             output,
         } => {
             let embedder = Embedder::new(model_config.clone())?;
-            let query_embedding = embedder.embed_query(query)?;
-
             let filter = build_project_search_filter(
                 query,
                 *name_boost,
@@ -265,45 +301,91 @@ pub(crate) fn cmd_project(
                 *include_docs,
             );
 
-            let results = search_across_projects(&query_embedding, &filter, *limit, *threshold)?;
+            let results = search_federated(indexes, query, &embedder, &filter, *limit, *threshold)?;
 
             // Top-level `--json` always wins (mirrors `cmd_model` at
             // `src/cli/commands/infra/model.rs:113`). `cqs --json project search foo`
             // must emit envelope JSON without `--json` after the subcommand.
-            let json = cli.json || output.json;
-            if json {
-                let json_results: Vec<_> = results
-                    .iter()
-                    .map(|r| ProjectSearchResult {
-                        project: r.project_name.clone(),
-                        name: r.name.clone(),
-                        file: normalize_path(&r.file),
-                        line_start: r.line_start,
-                        signature: r.signature.clone(),
-                        score: r.score,
-                    })
-                    .collect();
-                crate::cli::json_envelope::emit_json(&json_results)?;
-            } else if results.is_empty() {
-                println!("No results found across registered projects.");
-            } else {
-                for r in &results {
-                    println!(
-                        "[{}] {} {}:{} ({:.3})",
-                        r.project_name.cyan(),
-                        r.name.bold(),
-                        r.file.display(),
-                        r.line_start,
-                        r.score,
-                    );
-                    if let Some(ref sig) = r.signature {
-                        println!("  {}", sig.dimmed());
-                    }
-                }
+            emit_project_results(&results, cli.json || output.json)
+        }
+    }
+}
+
+/// `cqs <q> --index <NAME>...`: federated search over the named registered
+/// projects, with the top-level filter flags. Runs in the CLI process
+/// against each project's own store; the current project's index is only
+/// searched when it is one of the named projects.
+pub(crate) fn cmd_query_federated(cli: &Cli, query: &str) -> Result<()> {
+    let _span =
+        tracing::info_span!("cmd_query_federated", indexes = ?cli.indexes, limit = cli.limit)
+            .entered();
+    let embedder = Embedder::new(cli.try_model_config()?.clone())?;
+    let filter = build_project_search_filter(
+        query,
+        cli.name_boost,
+        cli.rrf,
+        cli.path.as_deref(),
+        cli.lang.as_deref(),
+        cli.include_type.as_deref(),
+        cli.exclude_type.as_deref(),
+        cli.include_docs,
+    );
+    let results = search_federated(
+        &cli.indexes,
+        query,
+        &embedder,
+        &filter,
+        cli.limit,
+        cli.threshold,
+    )?;
+    emit_project_results(&results, cli.json)
+}
+
+/// Print cross-project results: a JSON array of [`ProjectSearchResult`], or
+/// one `[project] name file:line (score)` line per result.
+/// Elapsed time as a short duration: `40s`, `12m`, `5h`, `3d`.
+fn since_label(secs: i64) -> String {
+    match secs.max(0) {
+        s @ ..=59 => format!("{s}s"),
+        s @ 60..=3599 => format!("{}m", s / 60),
+        s @ 3600..=172_799 => format!("{}h", s / 3600),
+        s => format!("{}d", s / 86_400),
+    }
+}
+
+fn emit_project_results(results: &[CrossProjectResult], json: bool) -> Result<()> {
+    if json {
+        let json_results: Vec<_> = results
+            .iter()
+            .map(|r| ProjectSearchResult {
+                project: r.project_name.clone(),
+                name: r.name.clone(),
+                file: normalize_path(&r.file),
+                line_start: r.line_start,
+                signature: r.signature.clone(),
+                score: r.score,
+                raw_score: r.raw_score,
+            })
+            .collect();
+        crate::cli::json_envelope::emit_json(&json_results)?;
+    } else if results.is_empty() {
+        println!("No results found across registered projects.");
+    } else {
+        for r in results {
+            println!(
+                "[{}] {} {}:{} ({:.3})",
+                r.project_name.cyan(),
+                r.name.bold(),
+                r.file.display(),
+                r.line_start,
+                r.score,
+            );
+            if let Some(ref sig) = r.signature {
+                println!("  {}", sig.dimmed());
             }
-            Ok(())
         }
     }
+    Ok(())
 }
 
 /// Build the per-project `SearchFilter` from the `cqs project search`
@@ -397,6 +479,7 @@ mod tests {
             line_start: 42,
             signature: Some("fn do_stuff(x: i32) -> bool".into()),
             score: 0.875,
+            raw_score: 0.875,
         };
         let json = serde_json::to_value(&result).unwrap();
         assert_eq!(json["project"], "my-lib");
@@ -415,11 +498,21 @@ mod tests {
             line_start: 10,
             signature: None,
             score: 0.5,
+            raw_score: 0.5,
         };
         let json = serde_json::to_value(&result).unwrap();
         assert!(json.get("signature").is_none());
     }
 
+    #[test]
+    fn since_label_scales_units() {
+        assert_eq!(since_label(-5), "0s");
+        assert_eq!(since_label(42), "42s");
+        assert_eq!(since_label(600), "10m");
+        assert_eq!(since_label(7200), "2h");
+        assert_eq!(since_label(3 * 86_400), "3d");
+    }
+
     // ===== build_project_search_filter behavior =====
     //
     // The parse-only test at `src/cli/mod.rs::test_cmd_project_search_full_flag_parity`
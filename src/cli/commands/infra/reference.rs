@@ -17,7 +17,10 @@ use cqs::{ModelInfo, Parser as CqParser, Store};
 
 use crate::cli::commands::index::build_hnsw_index;
 use crate::cli::definitions::TextJsonArgs;
-use crate::cli::{enumerate_files, find_project_root, run_index_pipeline, Cli};
+use crate::cli::{
+    enumerate_files, find_project_root, print_sandbox_skips, print_secret_redactions,
+    run_index_pipeline, Cli,
+};
 
 // ---------------------------------------------------------------------------
 // Output struct
@@ -400,6 +403,8 @@ fn cmd_ref_add(
 
     if !cli.quiet && !json {
         println!("  Embedded: {} chunks", stats.total_embedded);
+        print_sandbox_skips(&stats, &source);
+        print_secret_redactions(&stats, &source);
     }
 
     // Build HNSW index. Reference indexes have no concurrent writers, so a
@@ -756,6 +761,8 @@ fn cmd_ref_update(cli: &Cli, name: &str, json: bool, opts: RefUpdateLlmOpts) ->
             "  Chunks: {} ({} cached, {} embedded)",
             stats.total_embedded, stats.total_cached, newly
         );
+        print_sandbox_skips(&stats, source);
+        print_secret_redactions(&stats, source);
     }
 
     // Prune chunks for deleted files
@@ -830,6 +837,7 @@ fn cmd_ref_update(cli: &Cli, name: &str, json: bool, opts: RefUpdateLlmOpts) ->
             &config,
             opts.max_docs.unwrap_or(0),
             opts.improve_all,
+            false,
             Some(ref_dir),
         ) {
             Ok(doc_results) => {
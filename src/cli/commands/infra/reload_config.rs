@@ -0,0 +1,105 @@
+//! `cqs reload-config` — ask the running daemon to re-read its config.
+//!
+//! Same shape as `cqs ping`: talks to the daemon socket directly and exits 1
+//! when no daemon is running, since there is no in-process config to reload.
+//! Editing `.cqs.toml` or sending SIGHUP to `cqs watch` does the same thing;
+//! this command reports which keys applied and which were rejected.
+
+use anyhow::Result;
+
+use crate::cli::find_project_root;
+
+/// Render a reload outcome as the text-mode summary.
+#[cfg(unix)]
+fn format_text(resp: &cqs::daemon_translate::DaemonReloadResponse) -> String {
+    if resp.applied.is_empty() && resp.needs_reindex.is_empty() && resp.needs_restart.is_empty() {
+        return "Config unchanged".to_string();
+    }
+    let mut out = format!("Config reloaded (generation {})", resp.generation);
+    let sections = [
+        ("applied", &resp.applied),
+        ("kept, needs reindex", &resp.needs_reindex),
+        ("kept, needs restart", &resp.needs_restart),
+    ];
+    for (label, keys) in sections {
+        if !keys.is_empty() {
+            out.push_str(&format!("\n  {label}: {}", keys.join(", ")));
+        }
+    }
+    out
+}
+
+/// Run `cqs reload-config`.
+///
+/// Exit codes:
+/// - `0` — the daemon re-read its config (rejected keys are not an error).
+/// - `1` — no daemon running, the config failed to parse, or transport error.
+pub(crate) fn cmd_reload_config(json: bool) -> Result<()> {
+    let _span = tracing::info_span!("cmd_reload_config", json).entered();
+
+    #[cfg(unix)]
+    {
+        let root = find_project_root();
+        let cqs_dir = cqs::resolve_index_dir(&root);
+        match cqs::daemon_translate::daemon_reload_config(&cqs_dir) {
+            Ok(resp) => {
+                if json {
+                    crate::cli::json_envelope::emit_json(&resp)?;
+                } else {
+                    println!("{}", format_text(&resp));
+                }
+                Ok(())
+            }
+            Err(err) => {
+                let msg = err.as_message();
+                if json {
+                    crate::cli::json_envelope::emit_json_error(
+                        crate::cli::json_envelope::error_codes::IO_ERROR,
+                        &msg,
+                    )?;
+                } else {
+                    eprintln!("cqs: {msg}");
+                }
+                std::process::exit(1);
+            }
+        }
+    }
+
+    #[cfg(not(unix))]
+    {
+        let _ = json;
+        let _ = find_project_root;
+        eprintln!("cqs: reload-config is unix-only (daemon socket uses Unix domain sockets)");
+        std::process::exit(1);
+    }
+}
+
+#[cfg(all(test, unix))]
+mod tests {
+    use super::*;
+    use cqs::daemon_translate::DaemonReloadResponse;
+
+    fn resp(applied: &[&str], reindex: &[&str], restart: &[&str]) -> DaemonReloadResponse {
+        let owned = |keys: &[&str]| keys.iter().map(|k| k.to_string()).collect();
+        DaemonReloadResponse {
+            generation: 3,
+            applied: owned(applied),
+            needs_reindex: owned(reindex),
+            needs_restart: owned(restart),
+        }
+    }
+
+    #[test]
+    fn format_text_unchanged() {
+        assert_eq!(format_text(&resp(&[], &[], &[])), "Config unchanged");
+    }
+
+    #[test]
+    fn format_text_lists_only_non_empty_classes() {
+        let text = format_text(&resp(&["scoring", "limit"], &["embedding"], &[]));
+        assert_eq!(
+            text,
+            "Config reloaded (generation 3)\n  applied: scoring, limit\n  kept, needs reindex: embedding"
+        );
+    }
+}
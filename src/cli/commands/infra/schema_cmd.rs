@@ -0,0 +1,77 @@
+//! JSON output schemas — `cqs schema dump`.
+//!
+//! Prints a JSON Schema for each documented output type, in the shape the
+//! active `--schema` selection renders. See [`crate::cli::json_schema`].
+
+use anyhow::{bail, Result};
+use serde_json::{Map, Value};
+
+use crate::cli::definitions::Cli;
+use crate::cli::json_schema::{self, OUTPUT_SCHEMAS};
+
+#[derive(clap::Subcommand)]
+pub(crate) enum SchemaCommand {
+    /// Print the JSON Schema of each output type (`--schema v1` for the old shapes).
+    Dump {
+        /// Only this output type (`envelope`, `error`, `search`, `stats`, `items`)
+        #[arg(value_name = "TYPE")]
+        name: Option<String>,
+    },
+}
+
+pub(crate) fn cmd_schema(_cli: &Cli, subcmd: &SchemaCommand) -> Result<()> {
+    let _span = tracing::info_span!("cmd_schema").entered();
+    match subcmd {
+        SchemaCommand::Dump { name } => cmd_schema_dump(name.as_deref()),
+    }
+}
+
+/// `{"schemas": {<type>: <JSON Schema>, ...}, "supported": [1, 2]}`; always JSON.
+fn schema_dump_value(name: Option<&str>) -> Result<Value> {
+    let version = json_schema::selected();
+    let mut schemas = Map::new();
+    for schema in OUTPUT_SCHEMAS
+        .iter()
+        .filter(|s| name.is_none_or(|n| n == s.name))
+    {
+        schemas.insert(
+            schema.name.to_string(),
+            json_schema::versioned_schema(schema, version),
+        );
+    }
+    if let (Some(n), true) = (name, schemas.is_empty()) {
+        let known: Vec<&str> = OUTPUT_SCHEMAS.iter().map(|s| s.name).collect();
+        bail!("Unknown output type '{n}'. Known: {}", known.join(", "));
+    }
+    let supported: Vec<u32> = json_schema::SchemaVersion::ALL
+        .iter()
+        .map(|v| v.number())
+        .collect();
+    Ok(serde_json::json!({
+        "schemas": schemas,
+        "supported": supported,
+    }))
+}
+
+fn cmd_schema_dump(name: Option<&str>) -> Result<()> {
+    crate::cli::json_envelope::emit_json(&schema_dump_value(name)?)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn dump_lists_every_type_or_one() {
+        let all = schema_dump_value(None).unwrap();
+        assert_eq!(
+            all["schemas"].as_object().unwrap().len(),
+            OUTPUT_SCHEMAS.len()
+        );
+        assert_eq!(all["supported"], serde_json::json!([1, 2]));
+        let one = schema_dump_value(Some("search")).unwrap();
+        assert_eq!(one["schemas"].as_object().unwrap().len(), 1);
+        assert!(one["schemas"]["search"]["properties"]["results"].is_object());
+        assert!(schema_dump_value(Some("nope")).is_err());
+    }
+}
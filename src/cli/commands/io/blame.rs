@@ -486,6 +486,8 @@ mod tests {
                 window_idx: None,
                 parser_version: 0,
                 vendored: false,
+                deprecated: false,
+                boilerplate: None,
             },
             commits: vec![BlameEntry {
                 hash: "abc1234".to_string(),
@@ -536,6 +538,8 @@ mod tests {
                 window_idx: None,
                 parser_version: 0,
                 vendored: false,
+                deprecated: false,
+                boilerplate: None,
             },
             commits: vec![],
             callers: vec![],
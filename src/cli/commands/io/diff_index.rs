@@ -0,0 +1,211 @@
+//! Diff-index command — semantic diff of two whole index snapshots
+//!
+//! Each side is a `.db` file, a directory holding one, or a git revision
+//! (resolved through the `--at` snapshot cache, built on first use). The
+//! comparison itself is [`cqs::index_diff`].
+
+use std::path::Path;
+
+use anyhow::{Context, Result};
+use colored::Colorize;
+
+use cqs::store::ReadOnly;
+use cqs::{normalize_path, IndexDiff, Store};
+
+use crate::cli::commands::search::snapshot::{open_snapshot, snapshot_label};
+use crate::cli::CommandContext;
+
+/// An added or removed symbol.
+#[derive(Debug, serde::Serialize)]
+struct SymbolEntry {
+    name: String,
+    file: String,
+    #[serde(rename = "type")]
+    chunk_type: String,
+}
+
+/// A symbol whose source changed between the two indexes.
+#[derive(Debug, serde::Serialize)]
+struct ModifiedEntry {
+    name: String,
+    file: String,
+    #[serde(rename = "type")]
+    chunk_type: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    similarity: Option<f32>,
+    moved: bool,
+    summary_changed: bool,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    old_summary: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    new_summary: Option<String>,
+}
+
+/// Summary counts for the index diff.
+#[derive(Debug, serde::Serialize)]
+struct DiffIndexSummary {
+    added: usize,
+    removed: usize,
+    modified: usize,
+    moved: usize,
+    summary_changed: usize,
+    unchanged: usize,
+}
+
+/// Top-level JSON output for the diff-index command.
+#[derive(Debug, serde::Serialize)]
+struct DiffIndexOutput {
+    source: String,
+    target: String,
+    threshold: f32,
+    added: Vec<SymbolEntry>,
+    removed: Vec<SymbolEntry>,
+    modified: Vec<ModifiedEntry>,
+    summary: DiffIndexSummary,
+}
+
+fn build_diff_index_output(diff: &IndexDiff, threshold: f32) -> DiffIndexOutput {
+    let symbol = |e: &cqs::DiffEntry| SymbolEntry {
+        name: e.name.clone(),
+        file: normalize_path(&e.file),
+        chunk_type: e.chunk_type.to_string(),
+    };
+    let modified: Vec<ModifiedEntry> = diff
+        .modified
+        .iter()
+        .map(|m| ModifiedEntry {
+            name: m.name.clone(),
+            file: normalize_path(&m.file),
+            chunk_type: m.chunk_type.to_string(),
+            similarity: m.similarity,
+            moved: m.moved,
+            summary_changed: m.summary_changed(),
+            old_summary: m.old_summary.clone(),
+            new_summary: m.new_summary.clone(),
+        })
+        .collect();
+    DiffIndexOutput {
+        source: diff.source.clone(),
+        target: diff.target.clone(),
+        threshold,
+        added: diff.added.iter().map(symbol).collect(),
+        removed: diff.removed.iter().map(symbol).collect(),
+        summary: DiffIndexSummary {
+            added: diff.added.len(),
+            removed: diff.removed.len(),
+            modified: modified.len(),
+            moved: modified.iter().filter(|m| m.moved).count(),
+            summary_changed: modified.iter().filter(|m| m.summary_changed).count(),
+            unchanged: diff.unchanged_count,
+        },
+        modified,
+    }
+}
+
+/// Open one side of the diff: an index file, a directory containing
+/// `index.db`, or otherwise a git revision. Returns `(label, store)`.
+fn open_side(ctx: &CommandContext<'_, ReadOnly>, spec: &str) -> Result<(String, Store<ReadOnly>)> {
+    let _span = tracing::info_span!("diff_index_open_side", spec).entered();
+    let path = Path::new(spec);
+    let db = if path.is_dir() {
+        Some(path.join(cqs::INDEX_DB_FILENAME))
+    } else if path.is_file() {
+        Some(path.to_path_buf())
+    } else {
+        None
+    };
+    match db {
+        Some(db) => {
+            let store = Store::open_readonly(&db)
+                .with_context(|| format!("Failed to open index at {}", db.display()))?;
+            Ok((normalize_path(path), store))
+        }
+        None => {
+            let snapshot = open_snapshot(ctx, spec)?;
+            Ok((snapshot_label(spec), snapshot.store))
+        }
+    }
+}
+
+pub(crate) fn cmd_diff_index(
+    ctx: &CommandContext<'_, ReadOnly>,
+    old: &str,
+    new: &str,
+    threshold: f32,
+    lang: Option<&str>,
+    json: bool,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_diff_index", old, new).entered();
+    let (old_label, old_store) = open_side(ctx, old)?;
+    let (new_label, new_store) = open_side(ctx, new)?;
+
+    let diff = cqs::index_diff(
+        &old_store, &new_store, &old_label, &new_label, threshold, lang,
+    )?;
+    let output = build_diff_index_output(&diff, threshold);
+
+    if json {
+        crate::cli::json_envelope::emit_json(&output)?;
+    } else {
+        display_diff_index(&output);
+    }
+    Ok(())
+}
+
+fn display_diff_index(output: &DiffIndexOutput) {
+    println!("Diff: {} → {}", output.source.bold(), output.target.bold());
+    println!();
+
+    if !output.added.is_empty() {
+        println!("{} ({}):", "Added".green().bold(), output.added.len());
+        for e in &output.added {
+            println!("  + {} {} ({})", e.chunk_type, e.name, e.file);
+        }
+        println!();
+    }
+
+    if !output.removed.is_empty() {
+        println!("{} ({}):", "Removed".red().bold(), output.removed.len());
+        for e in &output.removed {
+            println!("  - {} {} ({})", e.chunk_type, e.name, e.file);
+        }
+        println!();
+    }
+
+    if !output.modified.is_empty() {
+        println!(
+            "{} ({}):",
+            "Modified".yellow().bold(),
+            output.modified.len()
+        );
+        for e in &output.modified {
+            let sim = e
+                .similarity
+                .map(|s| format!("[{:.2}]", s))
+                .unwrap_or_else(|| "[?]".to_string());
+            let marker = if e.moved { "~".yellow() } else { "·".dimmed() };
+            println!(
+                "  {} {} {} ({}) {}",
+                marker, e.chunk_type, e.name, e.file, sim
+            );
+            if e.summary_changed {
+                if let (Some(old), Some(new)) = (&e.old_summary, &e.new_summary) {
+                    println!("      was: {}", old.dimmed());
+                    println!("      now: {}", new);
+                }
+            }
+        }
+        println!();
+    }
+
+    println!(
+        "Summary: {} added, {} removed, {} modified ({} moved past {:.2}, {} summaries changed), {} unchanged",
+        output.summary.added,
+        output.summary.removed,
+        output.summary.modified,
+        output.summary.moved,
+        output.threshold,
+        output.summary.summary_changed,
+        output.summary.unchanged,
+    );
+}
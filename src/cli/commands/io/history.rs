@@ -0,0 +1,106 @@
+//! History command — rename history of a symbol across reindexes
+
+use anyhow::Result;
+
+use cqs::store::{RenameEdge, Store};
+
+/// Top-level JSON output for the history command.
+#[derive(Debug, serde::Serialize)]
+struct HistoryOutput {
+    name: String,
+    /// Every name the symbol has had, in rename order (first = oldest).
+    names: Vec<String>,
+    renames: Vec<RenameEdge>,
+    total: usize,
+}
+
+/// Distinct names along `edges` (oldest first), starting from the first
+/// edge's old name. Falls back to `name` alone when nothing was renamed.
+fn name_chain(name: &str, edges: &[RenameEdge]) -> Vec<String> {
+    let mut names: Vec<String> = Vec::new();
+    for e in edges {
+        for n in [&e.old_name, &e.new_name] {
+            if !names.contains(n) {
+                names.push(n.clone());
+            }
+        }
+    }
+    if names.is_empty() {
+        names.push(name.to_string());
+    }
+    names
+}
+
+fn build_history<Mode>(store: &Store<Mode>, name: &str) -> Result<HistoryOutput> {
+    let renames = store.rename_history(name)?;
+    Ok(HistoryOutput {
+        name: name.to_string(),
+        names: name_chain(name, &renames),
+        total: renames.len(),
+        renames,
+    })
+}
+
+pub(crate) fn cmd_history(
+    ctx: &crate::cli::CommandContext<'_, cqs::store::ReadOnly>,
+    name: &str,
+    json: bool,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_history", name).entered();
+    let output = build_history(&ctx.store, name)?;
+
+    if json {
+        crate::cli::json_envelope::emit_json(&output)?;
+        return Ok(());
+    }
+
+    use colored::Colorize;
+    if output.renames.is_empty() {
+        println!("No renames recorded for {}", name.bold());
+        return Ok(());
+    }
+    println!("{}", output.names.join(" → ").bold());
+    for e in &output.renames {
+        let detected = e.detected_at.get(..10).unwrap_or(&e.detected_at);
+        println!(
+            "  {}  {} → {}  ({}, {}, similarity {:.2})",
+            detected.dimmed(),
+            e.old_name,
+            e.new_name.green(),
+            e.origin,
+            e.chunk_type,
+            e.similarity
+        );
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn edge(old: &str, new: &str) -> RenameEdge {
+        RenameEdge {
+            origin: "src/lib.rs".to_string(),
+            chunk_type: "function".to_string(),
+            old_name: old.to_string(),
+            new_name: new.to_string(),
+            similarity: 1.0,
+            detected_at: "2026-01-01T00:00:00Z".to_string(),
+        }
+    }
+
+    #[test]
+    fn name_chain_follows_edges_in_order() {
+        let edges = [edge("load", "open"), edge("open", "open_config")];
+        assert_eq!(
+            name_chain("open", &edges),
+            vec!["load", "open", "open_config"]
+        );
+    }
+
+    #[test]
+    fn name_chain_without_renames_is_the_name() {
+        assert_eq!(name_chain("solo", &[]), vec!["solo"]);
+    }
+}
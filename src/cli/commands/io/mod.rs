@@ -1,11 +1,15 @@
-//! IO commands — file reading, reconstruction, blame, context, notes, diffs
+//! IO commands — file reading, reconstruction, blame, history, context, notes, diffs,
+//! opening results in an editor
 
 pub(crate) mod blame;
 mod brief;
 pub(crate) mod context;
 pub(crate) mod diff;
+mod diff_index;
 pub(crate) mod drift;
+mod history;
 pub(crate) mod notes;
+pub(crate) mod open;
 pub(crate) mod read;
 mod reconstruct;
 
@@ -13,7 +17,10 @@ pub(crate) use blame::cmd_blame;
 pub(crate) use brief::cmd_brief;
 pub(crate) use context::cmd_context;
 pub(crate) use diff::cmd_diff;
+pub(crate) use diff_index::cmd_diff_index;
 pub(crate) use drift::cmd_drift;
+pub(crate) use history::cmd_history;
 pub(crate) use notes::{cmd_notes, NotesCommand};
+pub(crate) use open::cmd_open;
 pub(crate) use read::cmd_read;
 pub(crate) use reconstruct::cmd_reconstruct;
@@ -0,0 +1,364 @@
+//! Open command for cqs
+//!
+//! `cqs open <N>` opens the Nth result of the latest search in an editor, at
+//! the result's first line; `cqs search --open-first` does the same for the
+//! top hit as soon as the results are printed. The results come from the
+//! working session (see `cqs::session`), which every search updates.
+//!
+//! The editor is the user config's `[editor] open` — a preset such as
+//! `vscode` or `idea` that opens a URL, or a `{path}`/`{line}` template —
+//! else `$VISUAL`, else `$EDITOR`, whose line syntax is looked up by
+//! program name. Nothing goes through a shell.
+//!
+//! Core struct is [`OpenOutput`]; CLI-only (it launches a local program).
+
+use std::path::Path;
+use std::process::Command;
+
+use anyhow::{bail, Context as _, Result};
+
+use cqs::session::{working_session_path, Session, SessionResult};
+
+/// `[editor] open` presets and the URL each one opens.
+const URL_PRESETS: &[(&str, &str)] = &[
+    ("vscode", "vscode://file{path}:{line}"),
+    ("vscode-insiders", "vscode-insiders://file{path}:{line}"),
+    ("cursor", "cursor://file{path}:{line}"),
+    ("idea", "idea://open?file={path}&line={line}"),
+    ("pycharm", "pycharm://open?file={path}&line={line}"),
+    ("goland", "goland://open?file={path}&line={line}"),
+    ("webstorm", "webstorm://open?file={path}&line={line}"),
+    ("clion", "clion://open?file={path}&line={line}"),
+    ("phpstorm", "phpstorm://open?file={path}&line={line}"),
+];
+
+/// Line syntax of `$VISUAL` / `$EDITOR` programs, by program name.
+const EDITOR_LINE_ARGS: &[(&[&str], &[&str])] = &[
+    (
+        &[
+            "vi",
+            "vim",
+            "nvim",
+            "gvim",
+            "mvim",
+            "view",
+            "nano",
+            "pico",
+            "emacs",
+            "emacsclient",
+            "micro",
+            "kak",
+            "joe",
+            "mg",
+            "ne",
+        ],
+        &["+{line}", "{path}"],
+    ),
+    (
+        &["code", "code-insiders", "codium", "cursor"],
+        &["--goto", "{path}:{line}"],
+    ),
+    (&["hx", "helix", "subl", "zed", "mate"], &["{path}:{line}"]),
+    (
+        &[
+            "idea",
+            "idea.sh",
+            "pycharm",
+            "pycharm.sh",
+            "goland",
+            "goland.sh",
+            "webstorm",
+            "webstorm.sh",
+            "clion",
+            "clion.sh",
+            "rustrover",
+            "rustrover.sh",
+            "phpstorm",
+            "phpstorm.sh",
+        ],
+        &["--line", "{line}", "{path}"],
+    ),
+];
+
+// ---------------------------------------------------------------------------
+// Output struct
+// ---------------------------------------------------------------------------
+
+#[derive(Debug, serde::Serialize)]
+pub(crate) struct OpenOutput {
+    /// 1-based position in the latest search's results.
+    pub index: usize,
+    pub file: String,
+    pub line: u32,
+    pub name: String,
+    /// The URL or command line, as [`Launch`] displays it.
+    pub launch: String,
+    /// `false` with `--print`.
+    pub launched: bool,
+}
+
+/// What opening a location runs.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub(crate) enum Launch {
+    /// Handed to the desktop URL opener.
+    Url(String),
+    /// Program and arguments, run directly and waited for (terminal editors
+    /// need the TTY).
+    Command(Vec<String>),
+}
+
+impl std::fmt::Display for Launch {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        match self {
+            Launch::Url(url) => f.write_str(url),
+            Launch::Command(argv) => f.write_str(&argv.join(" ")),
+        }
+    }
+}
+
+impl Launch {
+    /// Resolve how to open `path` (absolute) at `line`. `configured` is
+    /// `[editor] open`; `env_editor` is `$VISUAL`, else `$EDITOR`.
+    pub(crate) fn resolve(
+        configured: Option<&str>,
+        env_editor: Option<&str>,
+        path: &Path,
+        line: u32,
+    ) -> Result<Self> {
+        let path = path.to_string_lossy().replace('\\', "/");
+        if let Some(open) = configured.map(str::trim).filter(|s| !s.is_empty()) {
+            let template = URL_PRESETS
+                .iter()
+                .find(|(name, _)| *name == open)
+                .map_or(open, |(_, url)| url);
+            return Ok(if template.contains("://") {
+                Launch::Url(fill(template, &url_path(&path), line))
+            } else {
+                Launch::Command(
+                    template
+                        .split_whitespace()
+                        .map(|arg| fill(arg, &path, line))
+                        .collect(),
+                )
+            });
+        }
+        let Some(editor) = env_editor.map(str::trim).filter(|s| !s.is_empty()) else {
+            bail!(
+                "No editor configured: set [editor] open in ~/.config/cqs/config.toml \
+                 (e.g. \"vscode\"), or $VISUAL / $EDITOR"
+            );
+        };
+        let mut argv: Vec<String> = editor.split_whitespace().map(str::to_string).collect();
+        let program = Path::new(&argv[0])
+            .file_name()
+            .and_then(|n| n.to_str())
+            .unwrap_or_default()
+            .to_ascii_lowercase();
+        let program = program.strip_suffix(".exe").unwrap_or(&program);
+        match EDITOR_LINE_ARGS
+            .iter()
+            .find(|(names, _)| names.contains(&program))
+        {
+            Some((_, args)) => argv.extend(args.iter().map(|arg| fill(arg, &path, line))),
+            None => {
+                tracing::debug!(program, "Unknown editor line syntax, opening the file only");
+                argv.push(path);
+            }
+        }
+        Ok(Launch::Command(argv))
+    }
+
+    /// Run it; an editor that exits non-zero is an error.
+    pub(crate) fn run(&self) -> Result<()> {
+        let (program, args) = match self {
+            Launch::Url(url) => {
+                let (opener, pre): (&str, &[&str]) = if cfg!(target_os = "macos") {
+                    ("open", &[])
+                } else if cfg!(windows) {
+                    ("rundll32", &["url.dll,FileProtocolHandler"])
+                } else {
+                    ("xdg-open", &[])
+                };
+                let mut args: Vec<String> = pre.iter().map(|s| s.to_string()).collect();
+                args.push(url.clone());
+                (opener.to_string(), args)
+            }
+            Launch::Command(argv) => (argv[0].clone(), argv[1..].to_vec()),
+        };
+        let status = Command::new(&program)
+            .args(&args)
+            .status()
+            .with_context(|| format!("Failed to run {program}"))?;
+        if !status.success() {
+            bail!("{program} exited with {status}");
+        }
+        Ok(())
+    }
+}
+
+/// Substitute `{path}` and `{line}`.
+fn fill(template: &str, path: &str, line: u32) -> String {
+    template
+        .replace("{line}", &line.to_string())
+        .replace("{path}", path)
+}
+
+/// `path` for a URL: leading `/` (so `C:/src` becomes `/C:/src`) and
+/// percent-encoded outside the unreserved set, `/` and `:`.
+fn url_path(path: &str) -> String {
+    let mut out = String::with_capacity(path.len() + 1);
+    if !path.starts_with('/') {
+        out.push('/');
+    }
+    for b in path.bytes() {
+        if b.is_ascii_alphanumeric() || matches!(b, b'-' | b'.' | b'_' | b'~' | b'/' | b':') {
+            out.push(b as char);
+        } else {
+            out.push_str(&format!("%{b:02X}"));
+        }
+    }
+    out
+}
+
+/// Open `result` (a path relative to `root`) with the configured editor.
+pub(crate) fn open_result(root: &Path, result: &SessionResult, print_only: bool) -> Result<Launch> {
+    let config = cqs::config::Config::load(root);
+    let env_editor = ["VISUAL", "EDITOR"]
+        .iter()
+        .find_map(|var| std::env::var(var).ok().filter(|v| !v.trim().is_empty()));
+    let launch = Launch::resolve(
+        config.editor.as_ref().and_then(|e| e.open.as_deref()),
+        env_editor.as_deref(),
+        &root.join(&result.file),
+        result.line,
+    )?;
+    if !print_only {
+        tracing::info!(launch = %launch, "Opening result");
+        launch.run()?;
+    }
+    Ok(launch)
+}
+
+// ---------------------------------------------------------------------------
+// CLI command
+// ---------------------------------------------------------------------------
+
+/// Open the `index`th (1-based) result of the latest search.
+pub(crate) fn cmd_open(
+    project_cqs_dir: &Path,
+    index: usize,
+    print_only: bool,
+    json: bool,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_open", index, print_only).entered();
+
+    let session = Session::load(&working_session_path(project_cqs_dir))?.unwrap_or_default();
+    if session.last_results.is_empty() {
+        bail!(
+            "No search results to open: run a search first \
+             (results are kept in the working session unless CQS_SESSION_TRACKING=0)"
+        );
+    }
+    let Some(result) = index
+        .checked_sub(1)
+        .and_then(|i| session.last_results.get(i))
+    else {
+        bail!(
+            "The latest search returned {} result{}; there is no result {index}",
+            session.last_results.len(),
+            if session.last_results.len() == 1 {
+                ""
+            } else {
+                "s"
+            },
+        );
+    };
+
+    let root = crate::cli::find_project_root();
+    let launch = open_result(&root, result, print_only)?;
+    let output = OpenOutput {
+        index,
+        file: result.file.clone(),
+        line: result.line,
+        name: result.name.clone(),
+        launch: launch.to_string(),
+        launched: !print_only,
+    };
+
+    if json {
+        crate::cli::json_envelope::emit_json(&output)?;
+    } else if print_only {
+        println!("{}", output.launch);
+    } else {
+        println!("Opened {}:{} ({})", output.file, output.line, output.name);
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn resolve(configured: Option<&str>, editor: Option<&str>) -> Result<Launch> {
+        Launch::resolve(configured, editor, Path::new("/src/my app/lib.rs"), 42)
+    }
+
+    fn command(argv: &[&str]) -> Launch {
+        Launch::Command(argv.iter().map(|s| s.to_string()).collect())
+    }
+
+    #[test]
+    fn presets_open_urls() {
+        assert_eq!(
+            resolve(Some("vscode"), Some("vim")).unwrap(),
+            Launch::Url("vscode://file/src/my%20app/lib.rs:42".into())
+        );
+        assert_eq!(
+            resolve(Some("idea"), None).unwrap(),
+            Launch::Url("idea://open?file=/src/my%20app/lib.rs&line=42".into())
+        );
+        let windows = Launch::resolve(Some("vscode"), None, Path::new(r"C:\src\lib.rs"), 7);
+        assert_eq!(
+            windows.unwrap(),
+            Launch::Url("vscode://file/C:/src/lib.rs:7".into())
+        );
+    }
+
+    #[test]
+    fn templates_are_urls_or_commands() {
+        assert_eq!(
+            resolve(Some("myeditor://{path}#L{line}"), None).unwrap(),
+            Launch::Url("myeditor:///src/my%20app/lib.rs#L42".into())
+        );
+        // Split before substituting, so a space in the path stays one argument.
+        assert_eq!(
+            resolve(Some("code --goto {path}:{line}"), None).unwrap(),
+            command(&["code", "--goto", "/src/my app/lib.rs:42"])
+        );
+    }
+
+    #[test]
+    fn env_editor_line_syntax() {
+        assert_eq!(
+            resolve(None, Some("nvim")).unwrap(),
+            command(&["nvim", "+42", "/src/my app/lib.rs"])
+        );
+        assert_eq!(
+            resolve(None, Some("/usr/bin/code --wait")).unwrap(),
+            command(&["/usr/bin/code", "--wait", "--goto", "/src/my app/lib.rs:42"])
+        );
+        assert_eq!(
+            resolve(None, Some("hx")).unwrap(),
+            command(&["hx", "/src/my app/lib.rs:42"])
+        );
+        assert_eq!(
+            resolve(None, Some("idea.sh")).unwrap(),
+            command(&["idea.sh", "--line", "42", "/src/my app/lib.rs"])
+        );
+        assert_eq!(
+            resolve(None, Some("ed")).unwrap(),
+            command(&["ed", "/src/my app/lib.rs"])
+        );
+        assert!(resolve(Some("  "), Some(" ")).is_err());
+    }
+}
@@ -171,8 +171,10 @@ pub(crate) struct FocusedReadResult {
 
 /// Build focused-read output: header + hints + notes + target + type deps.
 /// Shared between CLI `cmd_read --focus` and batch `dispatch_read --focus`.
+/// Counts one open of the resolved chunk in the access stats under `cqs_dir`.
 pub(crate) fn build_focused_output<Mode>(
     store: &Store<Mode>,
+    cqs_dir: &Path,
     focus: &str,
     root: &Path,
     audit_state: &cqs::audit::AuditMode,
@@ -181,6 +183,14 @@ pub(crate) fn build_focused_output<Mode>(
     let resolved = cqs::resolve_target(store, focus)?;
     let chunk = &resolved.chunk;
     let rel_file = cqs::rel_display(&chunk.file, root);
+    cqs::access::record_access(
+        cqs_dir,
+        cqs::access::AccessKind::Open,
+        &[(
+            cqs::normalize_path(&chunk.file).as_str(),
+            chunk.name.as_str(),
+        )],
+    );
 
     let mut output = String::new();
 
@@ -459,9 +469,11 @@ struct FocusedReadJsonOutput {
 ///
 /// The adapter supplies the resolved `vendored_prefixes` (CLI loads config,
 /// daemon reuses its cached `Config`) so the path-vendored detection for full
-/// reads is shared rather than daemon-only. Reads no env and never prints.
+/// reads is shared rather than daemon-only. Reads no env and never prints;
+/// a focused read counts an access-stats open under `cqs_dir`.
 pub(crate) fn read_core<Mode>(
     store: &Store<Mode>,
+    cqs_dir: &Path,
     root: &Path,
     args: &crate::cli::args::ReadArgs,
     audit_state: &cqs::audit::AuditMode,
@@ -472,7 +484,7 @@ pub(crate) fn read_core<Mode>(
 
     // Focused read mode.
     if let Some(focus) = args.focus.as_deref() {
-        let result = build_focused_output(store, focus, root, audit_state, notes)?;
+        let result = build_focused_output(store, cqs_dir, focus, root, audit_state, notes)?;
         let hints = result.hints.as_ref().map(|h| ReadHints {
             caller_count: h.caller_count,
             test_count: h.test_count,
@@ -574,14 +586,23 @@ pub(crate) fn cmd_read(
                 .as_ref()
                 .and_then(|ic| ic.vendored_paths.as_deref()),
         );
-        let value = read_core(&ctx.store, root, &args, &audit_mode, &notes, &prefixes)?;
+        let value = read_core(
+            &ctx.store,
+            &ctx.cqs_dir,
+            root,
+            &args,
+            &audit_mode,
+            &notes,
+            &prefixes,
+        )?;
         crate::cli::json_envelope::emit_json(&value)?;
         return Ok(());
     }
 
     // Focused text read.
     if let Some(focus) = focus {
-        let result = build_focused_output(&ctx.store, focus, root, &audit_mode, &notes)?;
+        let result =
+            build_focused_output(&ctx.store, &ctx.cqs_dir, focus, root, &audit_mode, &notes)?;
         // Surface warnings on stderr so non-JSON callers also see them.
         for w in &result.warnings {
             eprintln!("warning: {w}");
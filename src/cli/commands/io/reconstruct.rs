@@ -111,6 +111,8 @@ mod tests {
             window_idx: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 
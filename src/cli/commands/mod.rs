@@ -37,6 +37,7 @@ pub(crate) use io::context;
 pub(crate) use io::diff;
 pub(crate) use io::drift;
 pub(crate) use io::notes;
+pub(crate) use io::open;
 pub(crate) use io::read;
 pub(crate) use search::gather;
 pub(crate) use search::onboard;
@@ -47,15 +48,22 @@ pub(crate) use train::task;
 pub(crate) use search::build_gather_output;
 pub(crate) use search::build_related_output;
 pub(crate) use search::build_where_output;
+pub(crate) use search::cmd_ask;
+pub(crate) use search::cmd_feedback;
 pub(crate) use search::cmd_gather;
 pub(crate) use search::cmd_neighbors;
 pub(crate) use search::cmd_onboard;
 pub(crate) use search::cmd_query;
 pub(crate) use search::cmd_related;
 pub(crate) use search::cmd_scout;
+pub(crate) use search::cmd_session;
 pub(crate) use search::cmd_similar;
+pub(crate) use search::cmd_symbol;
 pub(crate) use search::cmd_where;
+pub(crate) use search::AskArgs;
+pub(crate) use search::FeedbackAction;
 pub(crate) use search::GatherContext;
+pub(crate) use search::SessionCommand;
 
 // -- graph --
 // `build_test_map` / `build_test_map_output` are still called directly by
@@ -67,12 +75,16 @@ pub(crate) use graph::build_test_map_output;
 pub(crate) use graph::cmd_callees;
 pub(crate) use graph::cmd_callers;
 pub(crate) use graph::cmd_deps;
+pub(crate) use graph::cmd_examples;
 pub(crate) use graph::cmd_explain;
+pub(crate) use graph::cmd_graph;
 pub(crate) use graph::cmd_impact;
 pub(crate) use graph::cmd_impact_diff;
 pub(crate) use graph::cmd_test_map;
 pub(crate) use graph::cmd_trace;
 pub(crate) use graph::parse_edge_kind;
+// `--with-tests` search enrichment reuses the test-map linkage.
+pub(crate) use graph::{link_label, related_tests, TestMapEntry};
 // graph cores + arg types (daemon dispatch handlers call these). The
 // `*CoreOutput` types are returned by the cores and serialized via
 // `serde_json::to_value` / `to_value()` without being named at the call
@@ -94,12 +106,14 @@ pub(crate) use graph::{callees_core, callers_core, impact_core};
 pub(crate) use review::cmd_affected;
 pub(crate) use review::cmd_ci;
 pub(crate) use review::cmd_dead;
+pub(crate) use review::cmd_dupes;
 pub(crate) use review::cmd_health;
 pub(crate) use review::cmd_review;
 pub(crate) use review::cmd_suggest;
+pub(crate) use review::cmd_todos;
 pub(crate) use review::{
-    ci_overlay, dead_overlay, health_core, review_overlay, suggest_core, CiArgs, DeadArgs,
-    DeadVerdict, HealthArgs, ReviewArgs, SuggestArgs,
+    ci_overlay, dead_cross_core, dead_overlay, health_core, review_overlay, suggest_core, CiArgs,
+    DeadArgs, DeadVerdict, HealthArgs, ReviewArgs, SuggestArgs,
 };
 // `ci_core` / `dead_core` / `review_core` are the no-overlay entry points.
 // Production dispatch routes through `ci_overlay` / `dead_overlay` /
@@ -113,50 +127,73 @@ pub(crate) use review::{ci_core, dead_core, review_core};
 // -- index --
 pub(crate) use index::build_hnsw_base_index;
 pub(crate) use index::build_hnsw_index_owned;
+pub(crate) use index::cmd_compress;
+pub(crate) use index::cmd_explain_index;
 pub(crate) use index::cmd_gc;
 pub(crate) use index::cmd_index;
+pub(crate) use index::cmd_parse_worker;
 pub(crate) use index::cmd_stale;
 pub(crate) use index::cmd_stats;
+pub(crate) use index::cmd_stats_hot;
 pub(crate) use index::snapshot_fingerprint;
 pub(crate) use index::stale_core;
-pub(crate) use index::stats_core;
 pub(crate) use index::StaleArgs;
 pub(crate) use index::StatsArgs;
+pub(crate) use index::{stats_core, StatsOutput};
 
 // -- io --
 pub(crate) use io::cmd_blame;
 pub(crate) use io::cmd_brief;
 pub(crate) use io::cmd_context;
 pub(crate) use io::cmd_diff;
+pub(crate) use io::cmd_diff_index;
 pub(crate) use io::cmd_drift;
+pub(crate) use io::cmd_history;
 pub(crate) use io::cmd_notes;
+pub(crate) use io::cmd_open;
 pub(crate) use io::cmd_read;
 pub(crate) use io::cmd_reconstruct;
 pub(crate) use io::NotesCommand;
 
 // -- infra --
 pub(crate) use infra::cmd_audit_mode;
+pub(crate) use infra::cmd_backup;
 pub(crate) use infra::cmd_cache;
 #[cfg(feature = "convert")]
 pub(crate) use infra::cmd_convert;
+pub(crate) use infra::cmd_daemon;
 pub(crate) use infra::cmd_doctor;
 pub(crate) use infra::cmd_hook;
 pub(crate) use infra::cmd_init;
+pub(crate) use infra::cmd_languages;
+#[cfg(feature = "llm-summaries")]
+pub(crate) use infra::cmd_llm;
 pub(crate) use infra::cmd_model;
 pub(crate) use infra::cmd_ping;
-pub(crate) use infra::cmd_project;
 pub(crate) use infra::cmd_ref;
+pub(crate) use infra::cmd_reload_config;
+pub(crate) use infra::cmd_replica;
+pub(crate) use infra::cmd_restore;
+pub(crate) use infra::cmd_schema;
 pub(crate) use infra::cmd_slot;
 pub(crate) use infra::cmd_status;
 pub(crate) use infra::cmd_telemetry;
 pub(crate) use infra::cmd_telemetry_reset;
 pub(crate) use infra::CacheCommand;
+pub(crate) use infra::DaemonCommand;
 pub(crate) use infra::HookCommand;
+pub(crate) use infra::LanguagesCommand;
+#[cfg(feature = "llm-summaries")]
+pub(crate) use infra::LlmCommand;
 pub(crate) use infra::ModelCommand;
 pub(crate) use infra::ProjectCommand;
 pub(crate) use infra::RefCommand;
+pub(crate) use infra::SchemaCommand;
 pub(crate) use infra::SlotCommand;
-pub(crate) use infra::{daemon_control_hint, DaemonHint};
+pub(crate) use infra::{cmd_project, cmd_query_federated};
+pub(crate) use infra::{daemon_control_hint, stage_upgrade_batch, DaemonHint};
+#[cfg(feature = "serve")]
+pub(crate) use serve::ServeCommand;
 
 // -- train --
 pub(crate) use train::cmd_export_model;
@@ -166,7 +203,7 @@ pub(crate) use train::cmd_train_pairs;
 pub(crate) use train::{cmd_plan, plan_core, PlanArgs};
 
 // -- eval --
-pub(crate) use eval::{cmd_eval, EvalCmdArgs};
+pub(crate) use eval::{cmd_bench, cmd_eval, BenchCommand, EvalCmdArgs};
 
 // ---------------------------------------------------------------------------
 // Shared token-packing utilities (used by both CLI commands and batch handlers)
@@ -10,7 +10,7 @@
 //! `test-only` → `low-confidence-live` → `known-gap` → `dead`. The `dead`
 //! verdict is the actionable residue; `--verdict dead` is the consumable list.
 
-use std::path::Path;
+use std::path::{Path, PathBuf};
 
 use anyhow::{Context as _, Result};
 use cqs::store::{DeadConfidence, DeadFunction};
@@ -379,6 +379,9 @@ pub(crate) struct DeadOutput {
     pub possibly_dead_pub: Vec<DeadFunctionEntry>,
     pub count: usize,
     pub possibly_pub_count: usize,
+    /// Entries suppressed by the allowlist file (skip-when-zero).
+    #[serde(default, skip_serializing_if = "cqs::serde_helpers::is_zero_usize")]
+    pub allowlisted: usize,
 }
 
 // ---------------------------------------------------------------------------
@@ -406,6 +409,11 @@ pub(crate) struct DeadArgs {
     /// `--verdict dead` is the actionable residue.
     #[serde(default, deserialize_with = "de_opt_verdict")]
     pub verdict: Option<DeadVerdict>,
+    /// Allowlist file to read instead of the project's
+    /// [`DEAD_ALLOWLIST_FILE`]. CLI / daemon argv only — never deserialized,
+    /// so an MCP caller can't point the reader at an arbitrary file.
+    #[serde(skip)]
+    pub allowlist: Option<PathBuf>,
 }
 
 fn default_dead_confidence() -> DeadConfidence {
@@ -566,13 +574,52 @@ pub(crate) fn dead_overlay(
         None => std::collections::HashMap::new(),
     };
 
-    let mut output = build_dead_output(
+    let output = build_dead_output(
         &confident,
         &possibly_pub,
         root,
         &low_conf,
         &overlay_candidate,
     );
+    Ok((finish_dead_output(output, root, args)?, participated))
+}
+
+/// Core for `cqs dead` on a sharded index. A shard's store only sees the
+/// edges indexed into it, so a function called solely from another shard
+/// would be reported dead by [`dead_core`]; this asks the shard graph
+/// ([`cqs::shard::graph_context`]) for the populations across every shard
+/// instead. No overlay: the worktree overlay merges against one store.
+pub(crate) fn dead_cross_core(
+    cross_ctx: &mut cqs::cross_project::CrossProjectContext,
+    root: &Path,
+    args: &DeadArgs,
+) -> Result<DeadOutput> {
+    let _span = tracing::info_span!("dead_cross_core", include_pub = args.include_pub).entered();
+    let mut found = cross_ctx
+        .find_dead_code_cross(args.include_pub)
+        .context("Failed to detect dead code across shards")?;
+    found
+        .confident
+        .retain(|d| d.confidence >= args.min_confidence);
+    found
+        .possibly_dead_pub
+        .retain(|d| d.confidence >= args.min_confidence);
+    let output = build_dead_output(
+        &found.confident,
+        &found.possibly_dead_pub,
+        root,
+        &found.low_confidence_live,
+        &std::collections::HashMap::new(),
+    );
+    finish_dead_output(output, root, args)
+}
+
+/// Apply the allowlist and the `--verdict` filter shared by every dead core.
+fn finish_dead_output(mut output: DeadOutput, root: &Path, args: &DeadArgs) -> Result<DeadOutput> {
+    // Drop allowlisted entries before the verdict filter so `allowlisted`
+    // counts every suppression, whatever verdict the entry would have had.
+    let allowlist = DeadAllowlist::load(root, args.allowlist.as_deref())?;
+    output.allowlisted = allowlist.apply(&mut output);
 
     // Apply the `--verdict` filter to both lists, then recount.
     if let Some(want) = args.verdict {
@@ -582,8 +629,107 @@ pub(crate) fn dead_overlay(
         output.count = output.dead.len();
         output.possibly_pub_count = output.possibly_dead_pub.len();
     }
+    Ok(output)
+}
 
-    Ok((output, participated))
+// ---------------------------------------------------------------------------
+// Allowlist
+// ---------------------------------------------------------------------------
+
+/// Project-root allowlist file read by `cqs dead` when `--allowlist` is not
+/// given. Absent file = empty allowlist.
+pub(crate) const DEAD_ALLOWLIST_FILE: &str = ".cqs-dead-allowlist";
+
+/// One allowlist line. Either side may be absent (matches anything).
+struct AllowRule {
+    path: Option<globset::GlobMatcher>,
+    name: Option<globset::GlobMatcher>,
+}
+
+/// Functions the user has declared intentionally uncalled (FFI exports,
+/// reflection targets, plugin hooks). Each non-blank, non-`#` line is one of:
+///
+/// - `name_glob` — no `/` or `:`; matches the function name (`handle_*`)
+/// - `path_glob` — contains `/`; matches the project-relative file
+///   (`src/ffi/**`)
+/// - `path_glob:name_glob` — both must match (`src/ffi/*.rs:export_*`)
+#[derive(Default)]
+struct DeadAllowlist {
+    rules: Vec<AllowRule>,
+}
+
+impl DeadAllowlist {
+    /// Read `explicit` (relative paths resolve against `root`; missing is an
+    /// error), else `root/.cqs-dead-allowlist` when it exists.
+    fn load(root: &Path, explicit: Option<&Path>) -> Result<Self> {
+        let path = match explicit {
+            Some(p) => root.join(p),
+            None => {
+                let default = root.join(DEAD_ALLOWLIST_FILE);
+                if !default.exists() {
+                    return Ok(Self::default());
+                }
+                default
+            }
+        };
+        let text = std::fs::read_to_string(&path)
+            .with_context(|| format!("Failed to read dead-code allowlist {}", path.display()))?;
+        Self::parse(&text).with_context(|| format!("Invalid allowlist {}", path.display()))
+    }
+
+    fn parse(text: &str) -> Result<Self> {
+        let glob = |pattern: &str, lineno: usize| -> Result<globset::GlobMatcher> {
+            globset::Glob::new(pattern)
+                .map(|g| g.compile_matcher())
+                .with_context(|| format!("line {lineno}: bad pattern '{pattern}'"))
+        };
+        let mut rules = Vec::new();
+        for (i, line) in text.lines().enumerate() {
+            let line = line.trim();
+            if line.is_empty() || line.starts_with('#') {
+                continue;
+            }
+            let lineno = i + 1;
+            let rule = match line.rsplit_once(':') {
+                Some((path, name)) => AllowRule {
+                    path: Some(glob(path, lineno)?),
+                    name: Some(glob(name, lineno)?),
+                },
+                None if line.contains('/') => AllowRule {
+                    path: Some(glob(line, lineno)?),
+                    name: None,
+                },
+                None => AllowRule {
+                    path: None,
+                    name: Some(glob(line, lineno)?),
+                },
+            };
+            rules.push(rule);
+        }
+        Ok(Self { rules })
+    }
+
+    fn allows(&self, entry: &DeadFunctionEntry) -> bool {
+        let file = cqs::normalize_slashes(&entry.file);
+        self.rules.iter().any(|r| {
+            r.path.as_ref().is_none_or(|m| m.is_match(&file))
+                && r.name.as_ref().is_none_or(|m| m.is_match(&entry.name))
+        })
+    }
+
+    /// Remove allowlisted entries from both lists, recount, and return how
+    /// many were removed.
+    fn apply(&self, output: &mut DeadOutput) -> usize {
+        if self.rules.is_empty() {
+            return 0;
+        }
+        let before = output.dead.len() + output.possibly_dead_pub.len();
+        output.dead.retain(|e| !self.allows(e));
+        output.possibly_dead_pub.retain(|e| !self.allows(e));
+        output.count = output.dead.len();
+        output.possibly_pub_count = output.possibly_dead_pub.len();
+        before - output.count - output.possibly_pub_count
+    }
 }
 
 // ---------------------------------------------------------------------------
@@ -638,6 +784,7 @@ pub(crate) fn build_dead_output(
     DeadOutput {
         count: confident.len(),
         possibly_pub_count: possibly_pub.len(),
+        allowlisted: 0,
         dead: confident.iter().map(&format).collect(),
         possibly_dead_pub: possibly_pub.iter().map(&format).collect(),
     }
@@ -654,6 +801,7 @@ pub(crate) fn cmd_dead(
     include_pub: bool,
     min_level: DeadConfidence,
     verdict: Option<DeadVerdict>,
+    allowlist: Option<&Path>,
 ) -> Result<()> {
     let _span = tracing::info_span!("cmd_dead").entered();
 
@@ -661,8 +809,13 @@ pub(crate) fn cmd_dead(
         include_pub,
         min_confidence: min_level,
         verdict,
+        allowlist: allowlist.map(Path::to_path_buf),
+    };
+    // A sharded index resolves callers across every shard.
+    let output = match cqs::shard::graph_context(&ctx.cqs_dir)? {
+        Some(mut shard_ctx) => dead_cross_core(&mut shard_ctx, &ctx.root, &args)?,
+        None => dead_core(&ctx.store, &ctx.root, &args)?,
     };
-    let output = dead_core(&ctx.store, &ctx.root, &args)?;
 
     if json {
         crate::cli::json_envelope::emit_json(&output)?;
@@ -676,6 +829,9 @@ pub(crate) fn cmd_dead(
 /// Render the typed [`DeadOutput`] as human-readable text, grouped by verdict.
 /// Reads the same struct the JSON path emits so the two renderings can't drift.
 fn display_dead_text(output: &DeadOutput, quiet: bool) {
+    if output.allowlisted > 0 && !quiet {
+        println!("({} allowlisted entries hidden)", output.allowlisted);
+    }
     if output.dead.is_empty() && output.possibly_dead_pub.is_empty() {
         println!("No dead code found.");
         return;
@@ -764,6 +920,70 @@ mod tests {
         );
     }
 
+    fn entry(name: &str, file: &str) -> DeadFunctionEntry {
+        DeadFunctionEntry {
+            name: name.into(),
+            file: file.into(),
+            line_start: 1,
+            line_end: 2,
+            chunk_type: "function".into(),
+            signature: format!("fn {name}()"),
+            language: "rust".into(),
+            confidence: "high".into(),
+            verdict: "dead".into(),
+            verdict_reason: String::new(),
+        }
+    }
+
+    #[test]
+    fn allowlist_matches_name_path_and_both() {
+        let allow =
+            DeadAllowlist::parse("# comment\n\nexport_*\nsrc/ffi/**\nsrc/plugin.rs:on_load\n")
+                .unwrap();
+        assert!(allow.allows(&entry("export_foo", "src/lib.rs")));
+        assert!(allow.allows(&entry("anything", "src/ffi/bindings.rs")));
+        assert!(allow.allows(&entry("on_load", "src/plugin.rs")));
+        assert!(!allow.allows(&entry("on_load", "src/other.rs")));
+        assert!(!allow.allows(&entry("helper", "src/lib.rs")));
+    }
+
+    #[test]
+    fn allowlist_apply_removes_and_counts() {
+        let allow = DeadAllowlist::parse("keep_me").unwrap();
+        let mut output = DeadOutput {
+            dead: vec![entry("keep_me", "src/a.rs"), entry("gone", "src/a.rs")],
+            possibly_dead_pub: vec![entry("keep_me", "src/b.rs")],
+            count: 2,
+            possibly_pub_count: 1,
+            allowlisted: 0,
+        };
+        assert_eq!(allow.apply(&mut output), 2);
+        assert_eq!(output.count, 1);
+        assert_eq!(output.possibly_pub_count, 0);
+        assert_eq!(output.dead[0].name, "gone");
+    }
+
+    #[test]
+    fn allowlist_bad_pattern_names_the_line() {
+        let err = DeadAllowlist::parse("ok\nbad[").err().unwrap();
+        assert!(format!("{err:#}").contains("line 2"), "{err:#}");
+    }
+
+    #[test]
+    fn allowlist_missing_default_is_empty_but_missing_explicit_errors() {
+        let tmp = tempfile::tempdir().unwrap();
+        assert!(DeadAllowlist::load(tmp.path(), None)
+            .unwrap()
+            .rules
+            .is_empty());
+        assert!(DeadAllowlist::load(tmp.path(), Some(Path::new("nope.txt"))).is_err());
+        std::fs::write(tmp.path().join(DEAD_ALLOWLIST_FILE), "main\n").unwrap();
+        assert_eq!(
+            DeadAllowlist::load(tmp.path(), None).unwrap().rules.len(),
+            1
+        );
+    }
+
     #[test]
     fn dead_output_empty() {
         let output = DeadOutput {
@@ -771,6 +991,7 @@ mod tests {
             possibly_dead_pub: vec![],
             count: 0,
             possibly_pub_count: 0,
+            allowlisted: 0,
         };
         let json = serde_json::to_value(&output).unwrap();
         assert_eq!(json["count"], 0);
@@ -797,6 +1018,7 @@ mod tests {
             possibly_dead_pub: vec![],
             count: 1,
             possibly_pub_count: 0,
+            allowlisted: 0,
         };
         let json = serde_json::to_value(&output).unwrap();
         assert_eq!(json["count"], 1);
@@ -895,6 +1117,8 @@ mod tests {
                 parent_type_name: None,
                 parser_version: 0,
                 vendored: false,
+                deprecated: false,
+                boilerplate: None,
             },
             confidence: DeadConfidence::High,
             overlay_dead: false,
@@ -0,0 +1,120 @@
+//! Dupes command — copy-paste candidates clustered by embedding similarity
+
+use anyhow::Result;
+
+use cqs::dupes::{find_duplicates, DupeCluster, DupesOptions};
+use cqs::rel_display;
+
+/// A cluster member in JSON output.
+#[derive(Debug, serde::Serialize)]
+struct DupeMemberEntry {
+    name: String,
+    file: String,
+    line_start: u32,
+    line_end: u32,
+    chunk_type: String,
+}
+
+/// A cluster in JSON output.
+#[derive(Debug, serde::Serialize)]
+struct DupeClusterEntry {
+    min_similarity: f32,
+    max_similarity: f32,
+    min_overlap: f32,
+    members: Vec<DupeMemberEntry>,
+}
+
+/// Top-level JSON output for the dupes command.
+#[derive(Debug, serde::Serialize)]
+struct DupesOutput {
+    threshold: f32,
+    min_overlap: f32,
+    clusters: Vec<DupeClusterEntry>,
+    total: usize,
+}
+
+fn build_dupes_output(
+    clusters: &[DupeCluster],
+    opts: &DupesOptions,
+    root: &std::path::Path,
+) -> DupesOutput {
+    let clusters: Vec<DupeClusterEntry> = clusters
+        .iter()
+        .map(|c| DupeClusterEntry {
+            min_similarity: c.min_similarity,
+            max_similarity: c.max_similarity,
+            min_overlap: c.min_overlap,
+            members: c
+                .members
+                .iter()
+                .map(|m| DupeMemberEntry {
+                    name: m.name.clone(),
+                    file: rel_display(&m.file, root),
+                    line_start: m.line_start,
+                    line_end: m.line_end,
+                    chunk_type: m.chunk_type.to_string(),
+                })
+                .collect(),
+        })
+        .collect();
+    DupesOutput {
+        threshold: opts.threshold,
+        min_overlap: opts.min_overlap,
+        total: clusters.len(),
+        clusters,
+    }
+}
+
+pub(crate) fn cmd_dupes(
+    ctx: &crate::cli::CommandContext<'_, cqs::store::ReadOnly>,
+    opts: &DupesOptions,
+    json: bool,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_dupes", threshold = opts.threshold).entered();
+    crate::cli::validate_finite_f32(opts.threshold, "threshold")?;
+    let store = &ctx.store;
+
+    let index = cqs::HnswIndex::try_load_with_ef(&ctx.cqs_dir, None, store.dim());
+    let clusters = find_duplicates(store, index.as_deref(), opts)?;
+    let output = build_dupes_output(&clusters, opts, &ctx.root);
+
+    if json {
+        crate::cli::json_envelope::emit_json(&output)?;
+        return Ok(());
+    }
+
+    use colored::Colorize;
+    if output.clusters.is_empty() {
+        println!(
+            "No duplicates at similarity ≥ {:.2} with token overlap ≥ {:.2}.",
+            opts.threshold, opts.min_overlap
+        );
+        return Ok(());
+    }
+    println!(
+        "{} duplicate cluster{}:",
+        output.total.to_string().bold(),
+        if output.total == 1 { "" } else { "s" }
+    );
+    for c in &output.clusters {
+        println!();
+        println!(
+            "  {} copies, similarity {:.3}–{:.3}, overlap ≥ {:.2}",
+            c.members.len(),
+            c.min_similarity,
+            c.max_similarity,
+            c.min_overlap
+        );
+        for m in &c.members {
+            println!(
+                "    {} [{}] ({}:{}-{})",
+                m.name.bold(),
+                m.chunk_type,
+                m.file.dimmed(),
+                m.line_start,
+                m.line_end
+            );
+        }
+    }
+    Ok(())
+}
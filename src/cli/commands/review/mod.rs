@@ -4,12 +4,14 @@ mod affected;
 pub(crate) mod ci;
 pub(crate) mod dead;
 pub(crate) mod diff_review;
+mod dupes;
 pub(crate) mod health;
 pub(crate) mod suggest;
+mod todos;
 
 pub(crate) use affected::cmd_affected;
 pub(crate) use ci::{ci_overlay, cmd_ci, CiArgs};
-pub(crate) use dead::{cmd_dead, dead_overlay, DeadArgs, DeadVerdict};
+pub(crate) use dead::{cmd_dead, dead_cross_core, dead_overlay, DeadArgs, DeadVerdict};
 // `ci_core` / `dead_core` / `review_core` (no-overlay entry points) are consumed
 // only by the test-gated re-exports in `commands/mod.rs`; production routes through
 // `ci_overlay` / `dead_overlay` / `review_overlay`. (`cmd_ci` / `cmd_review` reach
@@ -22,5 +24,7 @@ pub(crate) use dead::dead_core;
 #[cfg(test)]
 pub(crate) use diff_review::review_core;
 pub(crate) use diff_review::{cmd_review, review_overlay, ReviewArgs};
+pub(crate) use dupes::cmd_dupes;
 pub(crate) use health::{cmd_health, health_core, HealthArgs};
 pub(crate) use suggest::{cmd_suggest, suggest_core, SuggestArgs};
+pub(crate) use todos::cmd_todos;
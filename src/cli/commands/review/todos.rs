@@ -0,0 +1,122 @@
+//! Todos command — TODO/FIXME/XXX comments with blame author and age
+
+use anyhow::{Context, Result};
+
+use cqs::todos::{file_count, find_todos, group_todos, TodoGrouping, TodoItem, TodoOptions};
+
+/// One group of markers in JSON output.
+#[derive(Debug, serde::Serialize)]
+struct TodoGroupEntry {
+    key: String,
+    todos: Vec<TodoItem>,
+}
+
+/// Top-level JSON output for the todos command.
+#[derive(Debug, serde::Serialize)]
+struct TodosOutput {
+    group_by: &'static str,
+    groups: Vec<TodoGroupEntry>,
+    files: usize,
+    total: usize,
+}
+
+/// Age as a short duration: `12d`, `5mo`, `2y`.
+fn age_label(days: i64) -> String {
+    match days {
+        ..=59 => format!("{days}d"),
+        60..=729 => format!("{}mo", days / 30),
+        _ => format!("{}y", days / 365),
+    }
+}
+
+pub(crate) fn cmd_todos(
+    ctx: &crate::cli::CommandContext<'_, cqs::store::ReadOnly>,
+    path: Option<String>,
+    owner: Option<&str>,
+    group_by: TodoGrouping,
+    json: bool,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_todos", ?path, ?owner).entered();
+    let owner = match owner {
+        Some("me") => {
+            let me = cqs::todos::git_user(&ctx.root)
+                .context("--owner me needs git's user.email or user.name")?;
+            Some(me)
+        }
+        other => other.map(str::to_string),
+    };
+    let opts = TodoOptions { path, owner };
+    let items = find_todos(&ctx.store, &ctx.root, &opts)?;
+    let files = file_count(&items);
+    let total = items.len();
+    let output = TodosOutput {
+        group_by: match group_by {
+            TodoGrouping::File => "file",
+            TodoGrouping::Owner => "owner",
+        },
+        groups: group_todos(items, group_by)
+            .into_iter()
+            .map(|(key, todos)| TodoGroupEntry { key, todos })
+            .collect(),
+        files,
+        total,
+    };
+
+    if json {
+        crate::cli::json_envelope::emit_json(&output)?;
+        return Ok(());
+    }
+
+    use colored::Colorize;
+    if output.total == 0 {
+        println!("No TODO/FIXME/XXX comments found.");
+        return Ok(());
+    }
+    for group in &output.groups {
+        println!("{} ({})", group.key.bold(), group.todos.len());
+        for t in &group.todos {
+            let location = match group_by {
+                TodoGrouping::File => format!("{:>5}", t.line),
+                TodoGrouping::Owner => format!("{}:{}", t.file, t.line),
+            };
+            let marker = match &t.tag {
+                Some(tag) => format!("{}({tag})", t.kind.as_str()),
+                None => t.kind.as_str().to_string(),
+            };
+            // No author: uncommitted line, or not a git work tree.
+            let who = match (&t.author, t.age_days) {
+                (Some(author), Some(days)) => format!("  ({author}, {})", age_label(days)),
+                _ => String::new(),
+            };
+            println!(
+                "  {}  {} {}{}",
+                location.dimmed(),
+                marker.yellow(),
+                t.text,
+                who.dimmed()
+            );
+        }
+    }
+    println!();
+    println!(
+        "{} marker{} in {} file{}",
+        output.total,
+        if output.total == 1 { "" } else { "s" },
+        output.files,
+        if output.files == 1 { "" } else { "s" }
+    );
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn age_label_scales_units() {
+        assert_eq!(age_label(0), "0d");
+        assert_eq!(age_label(45), "45d");
+        assert_eq!(age_label(90), "3mo");
+        assert_eq!(age_label(800), "2y");
+    }
+}
@@ -0,0 +1,173 @@
+//! `cqs ask "<question>"` — answer a question from the indexed code.
+//!
+//! Retrieval is a plain `cqs search` ([`query_core`]) with docs included by
+//! default; the top chunks then go to the configured LLM provider, which
+//! answers with `[N]` citations (see [`cqs::llm::answer_question`]). The
+//! answer is printed followed by the cited sources as `file:start-end`.
+//! `--sources-only` stops after retrieval and prints what would be sent.
+//! Plain search calls no LLM unless `--hyde` is on.
+
+use anyhow::{bail, Result};
+use serde::Serialize;
+
+use cqs::store::{ReadOnly, UnifiedResult};
+
+use crate::cli::commands::search::query::{query_core, QueryArgs};
+use crate::cli::CommandContext;
+
+/// Retrieval options for `cqs ask`.
+pub(crate) struct AskArgs<'a> {
+    pub question: &'a str,
+    pub limit: usize,
+    pub lang: Option<&'a str>,
+    pub path: Option<&'a str>,
+    pub code_only: bool,
+    pub sources_only: bool,
+}
+
+/// One retrieved chunk in `cqs ask` output.
+#[derive(Debug, Serialize)]
+struct SourceEntry {
+    /// 1-based number the answer cites it by
+    n: usize,
+    location: String,
+    name: String,
+    score: f32,
+    cited: bool,
+}
+
+#[derive(Debug, Serialize)]
+struct AskOutput {
+    question: String,
+    /// `None` with `--sources-only`
+    #[serde(skip_serializing_if = "Option::is_none")]
+    answer: Option<String>,
+    sources: Vec<SourceEntry>,
+}
+
+/// Retrieve chunks for the question and, unless `--sources-only`, have the
+/// configured LLM answer from them.
+pub(crate) fn cmd_ask(
+    ctx: &CommandContext<'_, ReadOnly>,
+    args: &AskArgs<'_>,
+    json: bool,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_ask", limit = args.limit).entered();
+    if args.question.trim().is_empty() {
+        bail!("ask needs a question");
+    }
+    let qargs = QueryArgs {
+        query: args.question.to_string(),
+        limit: args.limit,
+        lang: args.lang.map(str::to_string),
+        path: args.path.map(str::to_string),
+        include_docs: !args.code_only,
+        force_base_index: std::env::var("CQS_FORCE_BASE_INDEX").as_deref() == Ok("1"),
+        record_rank_signals: false,
+        ..QueryArgs::default()
+    };
+    let output = query_core(ctx, &qargs)?;
+    if output.results.is_empty() {
+        bail!("No indexed code matched the question; nothing to answer from");
+    }
+    let hits: Vec<_> = output
+        .results
+        .iter()
+        .map(|r| match r {
+            UnifiedResult::Code(r) => r,
+        })
+        .collect();
+
+    let answer = if args.sources_only {
+        None
+    } else {
+        Some(synthesize(ctx, args.question, &hits)?)
+    };
+
+    let result = AskOutput {
+        question: args.question.to_string(),
+        sources: hits
+            .iter()
+            .enumerate()
+            .map(|(i, hit)| SourceEntry {
+                n: i + 1,
+                location: format!(
+                    "{}:{}-{}",
+                    cqs::normalize_path(&hit.chunk.file),
+                    hit.chunk.line_start,
+                    hit.chunk.line_end
+                ),
+                name: hit.chunk.name.clone(),
+                score: hit.score,
+                cited: answer.as_ref().is_some_and(|a| a.cited.contains(&(i + 1))),
+            })
+            .collect(),
+        answer: answer.map(|a| a.text),
+    };
+    if json {
+        crate::cli::json_envelope::emit_json(&result)?;
+        return Ok(());
+    }
+    match &result.answer {
+        Some(text) => {
+            println!("{text}");
+            println!();
+            let cited: Vec<_> = result.sources.iter().filter(|s| s.cited).collect();
+            if cited.is_empty() {
+                println!("(no sources cited)");
+            }
+            for s in cited {
+                println!("[{}] {}  {}", s.n, s.location, s.name);
+            }
+        }
+        None => {
+            for s in &result.sources {
+                println!("[{}] {}  {}  ({:.3})", s.n, s.location, s.name, s.score);
+            }
+        }
+    }
+    Ok(())
+}
+
+/// Hand the retrieved chunks to the configured LLM.
+#[cfg(feature = "llm-summaries")]
+fn synthesize(
+    ctx: &CommandContext<'_, ReadOnly>,
+    question: &str,
+    hits: &[&cqs::store::SearchResult],
+) -> Result<cqs::llm::Answer> {
+    let sources: Vec<cqs::llm::AskSource> = hits
+        .iter()
+        .map(|r| cqs::llm::AskSource {
+            origin: cqs::normalize_path(&r.chunk.file),
+            name: r.chunk.name.clone(),
+            line_start: r.chunk.line_start,
+            line_end: r.chunk.line_end,
+            language: r.chunk.language.to_string(),
+            content: r.chunk.content.clone(),
+        })
+        .collect();
+    let config = cqs::config::Config::load(&ctx.root);
+    Ok(cqs::llm::answer_question(
+        &config,
+        question,
+        &sources,
+        ctx.cli.quiet,
+    )?)
+}
+
+#[cfg(not(feature = "llm-summaries"))]
+fn synthesize(
+    _ctx: &CommandContext<'_, ReadOnly>,
+    _question: &str,
+    _hits: &[&cqs::store::SearchResult],
+) -> Result<Answer> {
+    bail!("cqs ask needs the llm-summaries feature; rebuild with it or pass --sources-only")
+}
+
+/// Stand-in for `cqs::llm::Answer` when the LLM layer is compiled out.
+#[cfg(not(feature = "llm-summaries"))]
+struct Answer {
+    text: String,
+    cited: Vec<usize>,
+}
@@ -0,0 +1,121 @@
+//! `--changed-since <ref>` origin resolution for diff-scoped search.
+//!
+//! Turns a git ref into the set of index origins the branch changed: the
+//! working tree is diffed against the merge-base of `ref` and `HEAD`, so the
+//! scope is the PR surface — the branch's own commits plus uncommitted edits —
+//! and never picks up commits that landed on `ref` after the branch point.
+
+use std::collections::HashSet;
+use std::path::Path;
+
+use anyhow::{Context, Result};
+
+use crate::cli::commands::blame::truncate_git_stderr;
+use crate::cli::commands::run_git_diff;
+
+/// Origins (index-relative, `/`-separated) of every file changed between the
+/// merge-base of `base` and `HEAD` and the working tree of `root`.
+///
+/// Deleted files drop out (nothing left to search) and untracked files are
+/// not part of `git diff`, so neither appears in the set. An empty set is a
+/// valid answer — the branch changed nothing — and restricts the search to
+/// zero results rather than disabling the restriction.
+pub(crate) fn changed_origins(root: &Path, base: &str) -> Result<HashSet<String>> {
+    let _span = tracing::info_span!("changed_origins", base).entered();
+    let base_sha = cqs::snapshot::resolve_rev(root, base)
+        .with_context(|| format!("Invalid --changed-since ref '{base}'"))?;
+    let merge_base = merge_base(root, &base_sha)?;
+    let diff = run_git_diff(Some(&merge_base), root)?;
+    let origins = origins_from_diff(&diff);
+    tracing::info!(
+        base,
+        merge_base = %merge_base,
+        changed = origins.len(),
+        "Resolved --changed-since origins"
+    );
+    Ok(origins)
+}
+
+/// `git merge-base <sha> HEAD` in `root`. `sha` is already a resolved full
+/// commit SHA, so it can't be mistaken for an option.
+fn merge_base(root: &Path, sha: &str) -> Result<String> {
+    let output = std::process::Command::new("git")
+        .args(["merge-base", sha, "HEAD"])
+        .current_dir(root)
+        .output()
+        .context("Failed to run 'git merge-base'. Is git installed?")?;
+
+    if !output.status.success() {
+        let stderr = String::from_utf8_lossy(&output.stderr);
+        let stderr = stderr.trim();
+        if stderr.is_empty() {
+            anyhow::bail!(
+                "No common ancestor between {} and HEAD",
+                &sha[..12.min(sha.len())]
+            );
+        }
+        anyhow::bail!("git merge-base failed: {}", truncate_git_stderr(stderr));
+    }
+
+    Ok(String::from_utf8_lossy(&output.stdout).trim().to_string())
+}
+
+/// Distinct new-side file paths of a unified diff, normalized to the origin
+/// form chunks are indexed under.
+fn origins_from_diff(diff: &str) -> HashSet<String> {
+    cqs::parse_unified_diff(diff)
+        .iter()
+        .map(|hunk| cqs::normalize_path(&hunk.file))
+        .collect()
+}
+
+/// `true` when `origins` is unrestricted (`None`) or contains `file`.
+pub(crate) fn origin_allowed(origins: Option<&HashSet<String>>, file: &Path) -> bool {
+    origins.is_none_or(|set| set.contains(&cqs::normalize_path(file)))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn origins_from_diff_collects_distinct_new_side_paths() {
+        let diff = "\
+diff --git a/src/lib.rs b/src/lib.rs
+--- a/src/lib.rs
++++ b/src/lib.rs
+@@ -1,2 +1,3 @@
++fn a() {}
+@@ -10,1 +11,2 @@
++fn b() {}
+diff --git a/src/gone.rs b/src/gone.rs
+--- a/src/gone.rs
++++ /dev/null
+@@ -1,1 +0,0 @@
+-fn c() {}
+diff --git a/src/cli/mod.rs b/src/cli/mod.rs
+--- a/src/cli/mod.rs
++++ b/src/cli/mod.rs
+@@ -5,3 +5,0 @@
+-fn d() {}
+";
+        let origins = origins_from_diff(diff);
+        let expected: HashSet<String> = ["src/lib.rs", "src/cli/mod.rs"]
+            .into_iter()
+            .map(String::from)
+            .collect();
+        assert_eq!(origins, expected);
+    }
+
+    #[test]
+    fn origin_allowed_none_is_unrestricted() {
+        assert!(origin_allowed(None, Path::new("src/lib.rs")));
+        let set: HashSet<String> = ["src/lib.rs".to_string()].into_iter().collect();
+        assert!(origin_allowed(Some(&set), Path::new("src/lib.rs")));
+        assert!(!origin_allowed(Some(&set), Path::new("src/main.rs")));
+        assert!(!origin_allowed(
+            Some(&HashSet::new()),
+            Path::new("src/lib.rs")
+        ));
+    }
+}
@@ -0,0 +1,154 @@
+//! `cqs feedback` — rate search results to personalize ranking.
+//!
+//! `cqs feedback <result> --relevant|--irrelevant [--query <query>]` stores one
+//! judgement in `.cqs/feedback.db`; `--show` lists the per-path and
+//! per-language priors learned from them; `--reset` deletes them all. The
+//! priors only affect search when the `feedback_weight` scoring knob is
+//! above 0 (see [`cqs::feedback`]).
+
+use anyhow::{Context as _, Result};
+use serde::Serialize;
+
+use cqs::feedback::{FeedbackStore, Judgement, Votes};
+use cqs::store::ReadOnly;
+
+use crate::cli::CommandContext;
+
+/// What `cqs feedback` was asked to do.
+pub(crate) enum FeedbackAction<'a> {
+    Rate {
+        target: &'a str,
+        relevant: bool,
+        query: Option<&'a str>,
+    },
+    Show,
+    Reset,
+}
+
+/// `cqs feedback --show --json` row.
+#[derive(Debug, Serialize)]
+struct PriorEntry {
+    key: String,
+    relevant: u64,
+    irrelevant: u64,
+    score: f32,
+}
+
+impl PriorEntry {
+    fn new(key: &str, votes: Votes) -> Self {
+        Self {
+            key: key.to_string(),
+            relevant: votes.relevant,
+            irrelevant: votes.irrelevant,
+            score: votes.score(),
+        }
+    }
+}
+
+#[derive(Debug, Serialize)]
+struct ShowOutput {
+    path: String,
+    /// `feedback_weight` in effect; 0 means search ignores the priors.
+    weight: f32,
+    paths: Vec<PriorEntry>,
+    languages: Vec<PriorEntry>,
+}
+
+/// Rate a result, list the learned priors, or delete them.
+pub(crate) fn cmd_feedback(
+    ctx: &CommandContext<'_, ReadOnly>,
+    action: FeedbackAction<'_>,
+    json: bool,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_feedback").entered();
+    let path = cqs::feedback::feedback_path(&ctx.cqs_dir);
+    let open =
+        || FeedbackStore::open(&path).with_context(|| format!("Failed to open {}", path.display()));
+
+    match action {
+        FeedbackAction::Rate {
+            target,
+            relevant,
+            query,
+        } => {
+            let chunk = match ctx.store.get_chunks_by_ids(&[target])?.remove(target) {
+                Some(chunk) => chunk,
+                None => cqs::resolve_target(&ctx.store, target)?.chunk,
+            };
+            let judgement = Judgement {
+                query: query.map(str::to_string),
+                chunk_id: chunk.id.clone(),
+                origin: cqs::normalize_path(&chunk.file),
+                language: chunk.language.to_string(),
+                relevant,
+            };
+            open()?.record(&judgement)?;
+            if json {
+                crate::cli::json_envelope::emit_json(&judgement)?;
+            } else {
+                println!(
+                    "Marked {} ({}) {}",
+                    chunk.name,
+                    judgement.origin,
+                    if relevant { "relevant" } else { "irrelevant" }
+                );
+            }
+        }
+        FeedbackAction::Show => {
+            let prior = if path.exists() {
+                open()?.prior()?
+            } else {
+                Default::default()
+            };
+            let output = ShowOutput {
+                path: path.display().to_string(),
+                weight: cqs::search::scoring::knob::resolve_knob("feedback_weight"),
+                paths: prior
+                    .paths()
+                    .into_iter()
+                    .map(|(k, v)| PriorEntry::new(k, v))
+                    .collect(),
+                languages: prior
+                    .languages()
+                    .into_iter()
+                    .map(|(k, v)| PriorEntry::new(k, v))
+                    .collect(),
+            };
+            if json {
+                crate::cli::json_envelope::emit_json(&output)?;
+                return Ok(());
+            }
+            if prior.is_empty() {
+                println!("No feedback recorded yet ({})", output.path);
+                return Ok(());
+            }
+            if output.weight <= 0.0 {
+                println!("feedback_weight is 0: set CQS_FEEDBACK_WEIGHT to apply these priors");
+            }
+            println!("{:>5} {:>5} {:>7}  path", "up", "down", "score");
+            for e in &output.paths {
+                let key = if e.key.is_empty() { "." } else { &e.key };
+                println!(
+                    "{:>5} {:>5} {:>+7.3}  {key}",
+                    e.relevant, e.irrelevant, e.score
+                );
+            }
+            println!("{:>5} {:>5} {:>7}  language", "up", "down", "score");
+            for e in &output.languages {
+                println!(
+                    "{:>5} {:>5} {:>+7.3}  {}",
+                    e.relevant, e.irrelevant, e.score, e.key
+                );
+            }
+        }
+        FeedbackAction::Reset => {
+            let removed = if path.exists() { open()?.reset()? } else { 0 };
+            if json {
+                crate::cli::json_envelope::emit_json(&serde_json::json!({ "removed": removed }))?;
+            } else {
+                println!("Removed {removed} feedback judgement(s)");
+            }
+        }
+    }
+    Ok(())
+}
@@ -0,0 +1,209 @@
+//! Query-time HyDE for the shared search core (`--hyde` / `--no-hyde`).
+//!
+//! When active, [`super::query::prepare_query`] asks the LLM provider for a
+//! hypothetical snippet answering the query (cached by query hash + model,
+//! see [`cqs::cache::HydeCache`]), embeds it as a document, and searches with
+//! the normalized mean of that and the query embedding. Only the dense path
+//! is affected; name-only and FTS short-circuits never call the LLM. Any
+//! failure (no provider configured, API error) falls back to the plain query
+//! embedding with a warning.
+//!
+//! What happened is recorded per query for `_meta.hyde` in JSON output and
+//! the `--explain` line in text output.
+
+use serde::Serialize;
+
+use cqs::Embedding;
+
+use super::search_ctx::SearchCtx;
+
+/// Tri-state read of `CQS_QUERY_HYDE`, resolved at the adapter boundary like
+/// the overlay: `--no-hyde` wins, then `--hyde`, then `CQS_QUERY_HYDE=1`.
+/// Off by default — HyDE costs an LLM call per uncached query.
+pub(crate) fn resolve_hyde(flag_on: bool, flag_off: bool) -> bool {
+    if flag_off {
+        return false;
+    }
+    flag_on || std::env::var("CQS_QUERY_HYDE").as_deref() == Ok("1")
+}
+
+/// What query-time HyDE did for one search.
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub(crate) struct HydeMeta {
+    /// The hypothetical document was embedded into the query
+    pub fired: bool,
+    /// Served from the HyDE cache, no LLM call made
+    #[serde(skip_serializing_if = "cqs::serde_helpers::is_false")]
+    pub cached: bool,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub model: Option<String>,
+    /// The generated hypothetical document
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub document: Option<String>,
+    /// Why HyDE did not fire
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub error: Option<String>,
+}
+
+impl HydeMeta {
+    fn failed(error: String) -> Self {
+        Self {
+            fired: false,
+            cached: false,
+            model: None,
+            document: None,
+            error: Some(error),
+        }
+    }
+
+    /// One-line summary for `--explain`.
+    pub(crate) fn explain_line(&self) -> String {
+        match (&self.document, &self.error) {
+            (Some(doc), _) if self.fired => format!(
+                "HyDE: fired ({}, {}): {}",
+                self.model.as_deref().unwrap_or("?"),
+                if self.cached { "cached" } else { "generated" },
+                doc.lines().next().unwrap_or_default()
+            ),
+            (_, Some(e)) => format!("HyDE: not applied: {e}"),
+            _ => "HyDE: not applied".to_string(),
+        }
+    }
+}
+
+thread_local! {
+    /// Per-query HyDE outcome, read by the JSON envelope and `--explain`.
+    /// Cleared at the start of every search (the daemon reuses threads).
+    static HYDE_META: std::cell::RefCell<Option<HydeMeta>> =
+        const { std::cell::RefCell::new(None) };
+}
+
+/// Clear any HyDE outcome left over from a previous query on this thread.
+pub(crate) fn clear_hyde_meta() {
+    HYDE_META.with(|cell| *cell.borrow_mut() = None);
+}
+
+/// Read (and clear) the HyDE outcome of the current search; `None` when HyDE
+/// was not requested or the query short-circuited before embedding.
+pub(crate) fn take_hyde_meta() -> Option<HydeMeta> {
+    HYDE_META.with(|cell| cell.borrow_mut().take())
+}
+
+fn set_hyde_meta(meta: HydeMeta) {
+    HYDE_META.with(|cell| *cell.borrow_mut() = Some(meta));
+}
+
+/// Blend a hypothetical document for `query` into `query_embedding`. Never
+/// fails: on any error the query embedding comes back unchanged and the
+/// reason lands in the HyDE meta.
+pub(crate) fn expand_query_embedding(
+    ctx: &dyn SearchCtx,
+    query: &str,
+    query_embedding: Embedding,
+) -> Embedding {
+    let _span = tracing::info_span!("expand_query_embedding").entered();
+    match hyde_document_embedding(ctx, query) {
+        Ok((doc_embedding, meta)) => {
+            set_hyde_meta(meta);
+            blend(&query_embedding, &doc_embedding).unwrap_or(query_embedding)
+        }
+        Err(e) => {
+            tracing::warn!(error = %e, "HyDE expansion failed, searching with the plain query");
+            set_hyde_meta(HydeMeta::failed(format!("{e:#}")));
+            query_embedding
+        }
+    }
+}
+
+#[cfg(feature = "llm-summaries")]
+fn hyde_document_embedding(
+    ctx: &dyn SearchCtx,
+    query: &str,
+) -> anyhow::Result<(Embedding, HydeMeta)> {
+    let config = cqs::config::Config::load(ctx.root());
+    let doc = cqs::llm::hypothetical_document(&config, query, true)?;
+    let embedding = ctx
+        .embedder()?
+        .embed_documents(&[doc.document.as_str()])?
+        .into_iter()
+        .next()
+        .ok_or_else(|| anyhow::anyhow!("embedder returned no vector for the HyDE document"))?;
+    Ok((
+        embedding,
+        HydeMeta {
+            fired: true,
+            cached: doc.cached,
+            model: Some(doc.model),
+            document: Some(doc.document),
+            error: None,
+        },
+    ))
+}
+
+#[cfg(not(feature = "llm-summaries"))]
+fn hyde_document_embedding(
+    _ctx: &dyn SearchCtx,
+    _query: &str,
+) -> anyhow::Result<(Embedding, HydeMeta)> {
+    anyhow::bail!("built without the llm-summaries feature")
+}
+
+/// L2-normalized mean of two embeddings; `None` on a dimension mismatch or
+/// a zero sum.
+fn blend(a: &Embedding, b: &Embedding) -> Option<Embedding> {
+    if a.len() != b.len() {
+        return None;
+    }
+    let sum: Vec<f32> = a
+        .as_slice()
+        .iter()
+        .zip(b.as_slice())
+        .map(|(x, y)| x + y)
+        .collect();
+    let norm = sum.iter().map(|x| x * x).sum::<f32>().sqrt();
+    if !norm.is_finite() || norm == 0.0 {
+        return None;
+    }
+    Some(Embedding::new(sum.into_iter().map(|x| x / norm).collect()))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn blend_is_normalized_mean() {
+        let a = Embedding::new(vec![1.0, 0.0]);
+        let b = Embedding::new(vec![0.0, 1.0]);
+        let m = blend(&a, &b).unwrap();
+        let h = std::f32::consts::FRAC_1_SQRT_2;
+        assert!((m.as_slice()[0] - h).abs() < 1e-6 && (m.as_slice()[1] - h).abs() < 1e-6);
+        assert!(blend(&a, &Embedding::new(vec![1.0])).is_none());
+        assert!(blend(&a, &Embedding::new(vec![-1.0, 0.0])).is_none());
+    }
+
+    #[test]
+    fn hyde_flags_resolve_off_over_on() {
+        assert!(!resolve_hyde(true, true));
+        assert!(resolve_hyde(true, false));
+    }
+
+    #[test]
+    fn explain_line_reports_outcome() {
+        let fired = HydeMeta {
+            fired: true,
+            cached: true,
+            model: Some("m".into()),
+            document: Some("fn verify() {}\n// more".into()),
+            error: None,
+        };
+        assert_eq!(
+            fired.explain_line(),
+            "HyDE: fired (m, cached): fn verify() {}"
+        );
+        assert_eq!(
+            HydeMeta::failed("no key".into()).explain_line(),
+            "HyDE: not applied: no key"
+        );
+    }
+}
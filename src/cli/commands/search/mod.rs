@@ -1,20 +1,34 @@
-//! Search commands — semantic code search, context assembly, exploration
+//! Search commands — semantic code search, context assembly, exploration, feedback, sessions, answers
 
+mod ask;
+mod changed_since;
+mod feedback;
 pub(crate) mod gather;
+pub(crate) mod hyde;
 mod neighbors;
 pub(crate) mod onboard;
+pub(crate) mod prompt;
 pub(crate) mod query;
 pub(crate) mod related;
 pub(crate) mod scout;
 pub(crate) mod search_ctx;
+mod session;
 pub(crate) mod similar;
+pub(crate) mod snapshot;
+mod stream;
+mod symbol;
+pub(crate) mod translate;
 pub(crate) mod where_cmd;
 
+pub(crate) use ask::{cmd_ask, AskArgs};
+pub(crate) use feedback::{cmd_feedback, FeedbackAction};
 pub(crate) use gather::{build_gather_output, cmd_gather, GatherContext};
 pub(crate) use neighbors::cmd_neighbors;
 pub(crate) use onboard::cmd_onboard;
 pub(crate) use query::cmd_query;
 pub(crate) use related::{build_related_output, cmd_related};
 pub(crate) use scout::cmd_scout;
+pub(crate) use session::{cmd_session, SessionCommand};
 pub(crate) use similar::cmd_similar;
+pub(crate) use symbol::cmd_symbol;
 pub(crate) use where_cmd::{build_where_output, cmd_where};
@@ -0,0 +1,238 @@
+//! `--format prompt` — search results as a context block to paste into an
+//! LLM chat.
+//!
+//! Each result becomes a path-annotated, fenced block:
+//!
+//! ````text
+//! src/auth.rs:40-58 (refresh_token)
+//! ```rust
+//! fn refresh_token(...) { ... }
+//! ```
+//! ````
+//!
+//! Blocks keep rank order. A result whose lines overlap a higher-ranked
+//! result's in the same file, or whose text repeats one, is dropped, and
+//! each block is dedented. With `--tokens` / `--max-tokens` the blocks are
+//! counted whole — annotation and fences included — and the lowest-ranked
+//! ones dropped until the rest fit (the top block always stays).
+
+use std::path::Path;
+
+use anyhow::Result;
+
+use cqs::store::UnifiedResult;
+
+/// Estimated tokens a block adds around the chunk text (annotation line and
+/// fences), for the first-pass packing in `query_core`.
+pub(crate) const PROMPT_OVERHEAD_PER_RESULT: usize = 20;
+
+/// One result as the prompt block needs it.
+#[derive(Debug, Clone)]
+pub(crate) struct PromptChunk<'a> {
+    pub file: String,
+    pub line_start: u32,
+    pub line_end: u32,
+    pub name: &'a str,
+    /// Fence info string (`rust`, `python`, ...).
+    pub language: String,
+    pub content: &'a str,
+}
+
+impl<'a> PromptChunk<'a> {
+    pub(crate) fn from_result(result: &'a UnifiedResult, root: &Path) -> Self {
+        let UnifiedResult::Code(sr) = result;
+        let chunk = &sr.chunk;
+        Self {
+            file: cqs::rel_display(&chunk.file, root),
+            line_start: chunk.line_start,
+            line_end: chunk.line_end,
+            name: &chunk.name,
+            language: chunk.language.to_string(),
+            content: &chunk.content,
+        }
+    }
+
+    fn overlaps(&self, other: &Self) -> bool {
+        self.file == other.file
+            && self.line_start <= other.line_end
+            && other.line_start <= self.line_end
+    }
+
+    /// The fenced, annotated block.
+    fn block(&self) -> String {
+        let body = dedent(self.content);
+        // A fence longer than any backtick run in the body, so a chunk that
+        // holds a fence (docs, tests of markdown output) cannot close it.
+        let longest_run = body.split(|c| c != '`').map(str::len).max().unwrap_or(0);
+        let fence = "`".repeat(longest_run.max(2) + 1);
+        format!(
+            "{}:{}-{} ({})\n{fence}{}\n{body}\n{fence}\n",
+            self.file, self.line_start, self.line_end, self.name, self.language
+        )
+    }
+}
+
+/// Blocks for `chunks` (rank order), minus overlapping and repeated ones.
+pub(crate) fn prompt_blocks(chunks: &[PromptChunk<'_>]) -> Vec<String> {
+    let mut kept: Vec<&PromptChunk<'_>> = Vec::new();
+    for chunk in chunks {
+        let repeated = kept
+            .iter()
+            .any(|k| k.overlaps(chunk) || k.content.trim() == chunk.content.trim());
+        if !repeated {
+            kept.push(chunk);
+        }
+    }
+    kept.into_iter().map(PromptChunk::block).collect()
+}
+
+/// How many leading blocks fit `budget` tokens, given each block's count;
+/// at least one. Returns `(kept, tokens_used)`.
+pub(crate) fn fit_blocks(token_counts: &[usize], budget: usize) -> (usize, usize) {
+    let mut used = 0;
+    for (kept, &tokens) in token_counts.iter().enumerate() {
+        if kept > 0 && used + tokens > budget {
+            return (kept, used);
+        }
+        used += tokens;
+    }
+    (token_counts.len(), used)
+}
+
+/// Strip the indentation every non-blank line shares.
+fn dedent(text: &str) -> String {
+    let indent = text
+        .lines()
+        .filter(|l| !l.trim().is_empty())
+        .map(|l| l.len() - l.trim_start_matches([' ', '\t']).len())
+        .min()
+        .unwrap_or(0);
+    text.lines()
+        .map(|l| l.get(indent..).unwrap_or_else(|| l.trim_start()))
+        .collect::<Vec<_>>()
+        .join("\n")
+        .trim_matches('\n')
+        .to_string()
+}
+
+/// Print `results` as a prompt context block, enforcing `budget` (tokens)
+/// with `embedder`'s tokenizer.
+pub(crate) fn print_prompt(
+    results: &[UnifiedResult],
+    root: &Path,
+    budget: Option<usize>,
+    embedder: Option<&cqs::Embedder>,
+    quiet: bool,
+) -> Result<()> {
+    let chunks: Vec<PromptChunk<'_>> = results
+        .iter()
+        .map(|r| PromptChunk::from_result(r, root))
+        .collect();
+    let mut blocks = prompt_blocks(&chunks);
+    let deduped = chunks.len() - blocks.len();
+
+    let mut dropped = 0;
+    let mut used = None;
+    if let (Some(budget), Some(embedder)) = (budget, embedder) {
+        let texts: Vec<&str> = blocks.iter().map(String::as_str).collect();
+        let counts = crate::cli::commands::count_tokens_batch(embedder, &texts);
+        let (kept, tokens) = fit_blocks(&counts, budget);
+        dropped = blocks.len() - kept;
+        blocks.truncate(kept);
+        used = Some(tokens);
+    }
+
+    println!("{}", blocks.join("\n").trim_end());
+
+    if !quiet {
+        let mut notes = Vec::new();
+        if deduped > 0 {
+            notes.push(format!("{deduped} overlapping dropped"));
+        }
+        if dropped > 0 {
+            notes.push(format!("{dropped} lower-ranked dropped for the budget"));
+        }
+        let tokens = match (used, budget) {
+            (Some(used), Some(budget)) => format!(", {used}/{budget} tokens"),
+            _ => String::new(),
+        };
+        let notes = if notes.is_empty() {
+            String::new()
+        } else {
+            format!(" ({})", notes.join(", "))
+        };
+        eprintln!(
+            "{} chunk{}{tokens}{notes}",
+            blocks.len(),
+            if blocks.len() == 1 { "" } else { "s" }
+        );
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn chunk<'a>(
+        file: &str,
+        lines: (u32, u32),
+        name: &'a str,
+        content: &'a str,
+    ) -> PromptChunk<'a> {
+        PromptChunk {
+            file: file.to_string(),
+            line_start: lines.0,
+            line_end: lines.1,
+            name,
+            language: "rust".to_string(),
+            content,
+        }
+    }
+
+    #[test]
+    fn blocks_are_annotated_dedented_and_fenced() {
+        let blocks = prompt_blocks(&[chunk(
+            "src/auth.rs",
+            (40, 43),
+            "refresh",
+            "    fn refresh() {\n        renew();\n\n    }\n",
+        )]);
+        assert_eq!(
+            blocks,
+            ["src/auth.rs:40-43 (refresh)\n```rust\nfn refresh() {\n    renew();\n\n}\n```\n"]
+        );
+        let fenced = prompt_blocks(&[chunk("README.md", (1, 3), "Usage", "```sh\ncqs\n```")]);
+        assert!(fenced[0].contains("````rust\n```sh\ncqs\n```\n````\n"));
+    }
+
+    #[test]
+    fn overlapping_and_repeated_chunks_are_dropped() {
+        let chunks = [
+            chunk("src/a.rs", (10, 30), "Store", "impl Store {}"),
+            chunk("src/a.rs", (12, 20), "Store::open", "fn open() {}"),
+            chunk("src/b.rs", (12, 20), "open", "fn open() {}"),
+            chunk("src/c.rs", (1, 5), "copy", "impl Store {}\n"),
+            chunk("src/a.rs", (31, 40), "close", "fn close() {}"),
+        ];
+        let blocks = prompt_blocks(&chunks);
+        let heads: Vec<&str> = blocks.iter().map(|b| b.lines().next().unwrap()).collect();
+        assert_eq!(
+            heads,
+            [
+                "src/a.rs:10-30 (Store)",
+                "src/b.rs:12-20 (open)",
+                "src/a.rs:31-40 (close)"
+            ]
+        );
+    }
+
+    #[test]
+    fn budget_drops_from_the_bottom() {
+        assert_eq!(fit_blocks(&[50, 30, 40], 100), (2, 80));
+        // A smaller block further down does not jump the queue.
+        assert_eq!(fit_blocks(&[50, 60, 10], 100), (1, 50));
+        assert_eq!(fit_blocks(&[500, 10], 100), (1, 500));
+        assert_eq!(fit_blocks(&[], 100), (0, 0));
+    }
+}
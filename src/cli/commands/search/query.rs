@@ -37,8 +37,14 @@ use cqs::parser::ChunkType;
 use cqs::store::{ParentContext, UnifiedResult};
 use cqs::{reference, Embedder, Embedding, Pattern, SearchFilter, Store};
 
+use crate::cli::args::{RerankerMode, SearchIn};
+use crate::cli::commands::search::changed_since;
 use crate::cli::commands::search::search_ctx;
 use crate::cli::commands::search::search_ctx::SearchCtx;
+use crate::cli::commands::TestMapEntry;
+use crate::cli::display::{
+    ResultCommits, ResultExtras, ResultIssueRefs, ResultOwners, ResultTests,
+};
 use crate::cli::{display, signal, staleness, Cli};
 
 // ─── Args (surface-agnostic, MCP-ready) ────────────────────────────────────
@@ -67,20 +73,56 @@ pub(crate) struct QueryArgs {
     pub exclude_type: Option<Vec<String>>,
     /// Glob path filter.
     pub path: Option<String>,
+    /// Restrict results to files changed since this git ref (merge-base
+    /// diff against the working tree). Resolved to an origin set by
+    /// [`prepare_query`] against `SearchCtx::root`.
+    pub changed_since: Option<String>,
+    /// Restrict results to chunks whose source text matches this regex,
+    /// applied before ranking (`SearchFilter::content_regex`).
+    pub grep: Option<String>,
+    /// Restrict results to callables whose parameter/return shape matches,
+    /// applied before ranking (`SearchFilter::signature`).
+    pub signature: Option<String>,
+    /// Restrict results to code owned by this team or person (CODEOWNERS
+    /// owners and stored blame authors), applied before ranking
+    /// (`SearchFilter::owner`).
+    pub owner: Option<String>,
+    /// Restrict results to chunks whose comments reference this issue or PR,
+    /// applied before ranking (`SearchFilter::issue`).
+    pub issue: Option<String>,
+    /// Keyword-leg scope (`--in code|comments`); implies RRF when not `both`.
+    pub search_in: Option<SearchIn>,
     /// Structural pattern filter (builder, async, unsafe, …).
     pub pattern: Option<String>,
     /// Include documentation / markdown / config chunks (default: code only).
     pub include_docs: bool,
+    /// Rank indexed commit messages alongside code (`--with-commits`).
+    pub with_commits: bool,
+    /// Drop deprecated chunks instead of downranking them
+    /// (`SearchFilter::hide_deprecated`).
+    pub hide_deprecated: bool,
+    /// Keep generated code and license text in results
+    /// (`SearchFilter::include_generated`).
+    pub include_generated: bool,
+    /// On an empty result, retry with the filters relaxed step by step
+    /// (see [`relaxation_steps`]).
+    pub relax: bool,
     /// Enable RRF hybrid (keyword + semantic) fusion.
     pub rrf: bool,
     /// `true` when a cross-encoder reranker stage is requested.
     pub rerank: bool,
+    /// `true` for `--reranker late`: MaxSim rescoring of the top candidates
+    /// (`SearchFilter::late_interaction`).
+    pub late_interaction: bool,
     /// Force SPLADE on even for Unknown-category queries.
     pub splade: bool,
     /// Constant SPLADE fusion weight (None = per-category router).
     pub splade_alpha: Option<f32>,
     /// Minimum similarity threshold.
     pub threshold: f32,
+    /// Final-score floor (`--min-score`), applied to the finished ranking.
+    #[schemars(with = "Option<String>")]
+    pub min_score: Option<crate::cli::MinScore>,
     /// Name-match weight in hybrid scoring (0.0–1.0).
     pub name_boost: f32,
     /// Disable test/underscore-prefix demotion.
@@ -89,6 +131,11 @@ pub(crate) struct QueryArgs {
     pub tokens: Option<usize>,
     /// Expand results with parent type/module context (small-to-big).
     pub expand_parent: bool,
+    /// Attach linked tests to each callable result (`--with-tests`).
+    pub with_tests: bool,
+    /// Attach CODEOWNERS owners and blame authors to each result
+    /// (`--with-owners`).
+    pub with_owners: bool,
     /// Force the non-enriched base HNSW. Resolved once at the adapter boundary
     /// from `CQS_FORCE_BASE_INDEX` so the core stays env-free.
     pub force_base_index: bool,
@@ -132,6 +179,15 @@ pub(crate) struct QueryArgs {
     /// (`cmd_query`) uses to detect overlay-eligibility for the honest-degradation
     /// warn + `_meta.worktree_overlay = "skipped-no-daemon"`.
     pub overlay: bool,
+    /// Blend a hypothetical LLM-written snippet into the query embedding
+    /// (query-time HyDE, see [`super::hyde`]). Resolved at the adapter
+    /// boundary from `--hyde` / `--no-hyde` / `CQS_QUERY_HYDE`.
+    pub hyde: bool,
+    /// Search with an English rewrite of the query (see
+    /// [`super::translate`]). Resolved at the adapter boundary from
+    /// `--translate` / `--no-translate` / `CQS_QUERY_TRANSLATE` /
+    /// `llm_query_translate`.
+    pub translate: cqs::config::QueryTranslate,
 }
 
 impl Default for QueryArgs {
@@ -146,17 +202,31 @@ impl Default for QueryArgs {
             include_type: None,
             exclude_type: None,
             path: None,
+            changed_since: None,
+            grep: None,
+            signature: None,
+            owner: None,
+            issue: None,
+            search_in: None,
             pattern: None,
             include_docs: false,
+            with_commits: false,
+            hide_deprecated: false,
+            include_generated: false,
+            relax: false,
             rrf: false,
             rerank: false,
+            late_interaction: false,
             splade: false,
             splade_alpha: None,
             threshold: 0.3,
+            min_score: None,
             name_boost: 0.2,
             no_demote: false,
             tokens: None,
             expand_parent: false,
+            with_tests: false,
+            with_owners: false,
             force_base_index: false,
             json_overhead: 0,
             // CLI defaults: classification is gated on explicit flags, and the
@@ -168,6 +238,10 @@ impl Default for QueryArgs {
             record_rank_signals: true,
             // Overlay off by default; opt-in via `--overlay` / env.
             overlay: false,
+            // HyDE off by default; it costs an LLM call per uncached query.
+            hyde: false,
+            // Translation off by default, same cost.
+            translate: cqs::config::QueryTranslate::Off,
         }
     }
 }
@@ -252,7 +326,7 @@ impl QueryArgs {
     /// Build `QueryArgs` from the top-level CLI struct, resolving the
     /// `CQS_FORCE_BASE_INDEX` env override and the format-dependent JSON
     /// overhead once here at the adapter boundary.
-    fn from_cli(cli: &Cli, overlay_eligible: bool) -> Self {
+    pub(crate) fn from_cli(cli: &Cli, overlay_eligible: bool) -> Self {
         QueryArgs {
             query: cli.query.clone().unwrap_or_default(),
             limit: cli.limit,
@@ -261,23 +335,33 @@ impl QueryArgs {
             include_type: cli.include_type.clone(),
             exclude_type: cli.exclude_type.clone(),
             path: cli.path.clone(),
+            changed_since: cli.changed_since.clone(),
+            grep: cli.grep.clone(),
+            signature: cli.signature.clone(),
+            owner: cli.owner.clone(),
+            issue: cli.issue.clone(),
+            search_in: cli.search_in,
             pattern: cli.pattern.clone(),
             include_docs: cli.include_docs,
+            with_commits: cli.with_commits,
+            hide_deprecated: cli.hide_deprecated,
+            include_generated: cli.include_generated,
+            relax: cli.relax,
             rrf: cli.rrf,
-            rerank: cli.rerank_active(),
+            rerank: cli.rerank_mode() == RerankerMode::Onnx,
+            late_interaction: cli.rerank_mode() == RerankerMode::Late,
             splade: cli.splade,
             splade_alpha: cli.splade_alpha,
             threshold: cli.threshold,
+            min_score: cli.min_score,
             name_boost: cli.name_boost,
             no_demote: cli.no_demote,
             tokens: cli.tokens,
             expand_parent: cli.expand_parent,
+            with_tests: cli.with_tests,
+            with_owners: cli.with_owners,
             force_base_index: std::env::var("CQS_FORCE_BASE_INDEX").as_deref() == Ok("1"),
-            json_overhead: if cli.json {
-                crate::cli::commands::JSON_OVERHEAD_PER_RESULT
-            } else {
-                0
-            },
+            json_overhead: json_overhead_for(cli),
             // CLI semantics: explicit-flag classification gating + FTS-first.
             always_route: false,
             fts_first: true,
@@ -290,6 +374,8 @@ impl QueryArgs {
             // resolved once here at the adapter boundary like `force_base_index`.
             // `overlay_eligible` is the caller's `overlay_root(cwd, root).is_some()`.
             overlay: resolve_overlay_active(cli.overlay, cli.no_overlay, overlay_eligible),
+            hyde: super::hyde::resolve_hyde(cli.hyde, cli.no_hyde),
+            translate: super::translate::resolve_translate(cli.translate, cli.no_translate),
         }
     }
 
@@ -303,7 +389,7 @@ impl QueryArgs {
     /// short-circuited to project-only results.
     fn from_cli_ref(cli: &Cli, overlay_eligible: bool) -> Self {
         QueryArgs {
-            fts_first: cli.ref_name.is_none(),
+            fts_first: cli.ref_name.is_none() && cli.at.is_none(),
             ..Self::from_cli(cli, overlay_eligible)
         }
     }
@@ -324,14 +410,54 @@ pub(crate) struct QueryOutput {
     /// Resolved parent context keyed by chunk id (empty unless
     /// `expand_parent`).
     pub parents: HashMap<String, ParentContext>,
+    /// Linked tests keyed by chunk id (empty unless `with_tests`).
+    pub tests: ResultTests,
+    /// Ownership keyed by chunk id (empty unless `with_owners`).
+    pub owners: ResultOwners,
+    /// Issue/PR references keyed by chunk id. Always resolved; results
+    /// whose comments reference nothing are absent.
+    pub issue_refs: ResultIssueRefs,
+    /// Commit metadata keyed by chunk id. Always resolved; only commit
+    /// results have an entry.
+    pub commits: ResultCommits,
     /// `(used, budget)` when `--tokens` packed the results.
     pub token_info: Option<(usize, usize)>,
+    /// Confidence curve for the results' score scale (see
+    /// [`score_calibration`]); `None` when none is stored or the results came
+    /// from a name lookup.
+    pub calibration: Option<cqs::calibration::Calibration>,
+    /// Filters `--relax` dropped to get these results, in the order dropped;
+    /// empty when the query ran as given.
+    pub relaxed: Vec<Relaxation>,
+}
+
+/// One filter `--relax` dropped, with the value it had.
+#[derive(Debug, Clone, PartialEq, serde::Serialize)]
+pub(crate) struct Relaxation {
+    /// `path`, `include_type`, `exclude_type`, `threshold` or `min_score`.
+    pub filter: &'static str,
+    /// The dropped value as it would be typed (`src/**`, `function,method`).
+    pub was: String,
+}
+
+impl Relaxation {
+    fn new(filter: &'static str, was: String) -> Self {
+        Self { filter, was }
+    }
+}
+
+impl std::fmt::Display for Relaxation {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        write!(f, "--{} {}", self.filter.replace('_', "-"), self.was)
+    }
 }
 
 /// Compute JSON overhead for token budgeting based on output format.
 fn json_overhead_for(cli: &Cli) -> usize {
-    if cli.json {
+    if cli.json || cli.stream {
         crate::cli::commands::JSON_OVERHEAD_PER_RESULT
+    } else if cli.format == Some(crate::cli::definitions::SearchFormat::Prompt) {
+        super::prompt::PROMPT_OVERHEAD_PER_RESULT
     } else {
         0
     }
@@ -434,7 +560,10 @@ pub(crate) struct PreparedQuery<'a> {
 /// packing, parent-context resolution). Returns an empty `results` vec rather
 /// than printing or exiting — the adapter maps empty to the `NoResults` exit
 /// code. Reads no env: the base-index override arrives via
-/// [`QueryArgs::force_base_index`].
+/// [`QueryArgs::force_base_index`]. The side effects are the sampled
+/// access-stats hit count ([`cqs::access`]) and the working-session entry
+/// ([`cqs::session`]), so CLI and daemon searches feed the same
+/// `cqs stats --hot` table and `cqs session save`.
 ///
 /// The query-preparation prelude (classification, NameOnly-FTS-first,
 /// embedding, filter/SPLADE/index resolution) lives in [`prepare_query`], which
@@ -444,13 +573,204 @@ pub(crate) fn query_core(ctx: &dyn search_ctx::SearchCtx, args: &QueryArgs) -> R
     let query = args.query.as_str();
     let _span = tracing::info_span!("query_core", query_len = query.len()).entered();
 
-    let prepared = match prepare_query(ctx, args, ProjectSurface::Resolve)? {
-        Prepared::ShortCircuit(results) => return assemble_output(ctx, args, results),
-        Prepared::Dense(p) => p,
+    let mut output = search_project(ctx, args)?;
+    if output.results.is_empty() && args.relax {
+        for (relaxed_args, dropped) in relaxation_steps(args) {
+            tracing::info!(dropped = dropped.len(), "No results; relaxing filters");
+            output = QueryOutput {
+                relaxed: dropped,
+                ..search_project(ctx, &relaxed_args)?
+            };
+            if !output.results.is_empty() {
+                break;
+            }
+        }
+    }
+    record_search_hits(ctx.cqs_dir(), &output.results);
+    cqs::session::record_query(
+        &cqs::resolve_index_dir(ctx.root()),
+        query,
+        session_flags(args),
+        session_results(&output.results),
+    );
+    Ok(output)
+}
+
+/// One pass of [`query_core`]: prepare, retrieve, assemble.
+fn search_project(ctx: &dyn search_ctx::SearchCtx, args: &QueryArgs) -> Result<QueryOutput> {
+    Ok(match prepare_query(ctx, args, ProjectSurface::Resolve)? {
+        Prepared::ShortCircuit(results) => assemble_output(ctx, args, results)?,
+        Prepared::Dense(prepared) => {
+            let results = retrieve_project(ctx, args, &prepared)?;
+            QueryOutput {
+                calibration: score_calibration(ctx, args),
+                ..assemble_output(ctx, args, results)?
+            }
+        }
+    })
+}
+
+/// `--relax`: the queries to retry, narrowest first — path scope dropped,
+/// then the chunk types as well, then the score floors as well — each paired
+/// with every filter it has dropped. A step with nothing set to drop is
+/// skipped.
+pub(crate) fn relaxation_steps(args: &QueryArgs) -> Vec<(QueryArgs, Vec<Relaxation>)> {
+    type Step = fn(&mut QueryArgs, &mut Vec<Relaxation>);
+    const STEPS: [Step; 3] = [
+        |a, dropped| {
+            if let Some(path) = a.path.take() {
+                dropped.push(Relaxation::new("path", path));
+            }
+        },
+        |a, dropped| {
+            if let Some(types) = a.include_type.take() {
+                dropped.push(Relaxation::new("include_type", types.join(",")));
+            }
+            if let Some(types) = a.exclude_type.take() {
+                dropped.push(Relaxation::new("exclude_type", types.join(",")));
+            }
+        },
+        |a, dropped| {
+            if a.threshold > 0.0 {
+                dropped.push(Relaxation::new("threshold", a.threshold.to_string()));
+                a.threshold = 0.0;
+            }
+            if let Some(min_score) = a.min_score.take() {
+                dropped.push(Relaxation::new("min_score", min_score.to_string()));
+            }
+        },
+    ];
+
+    let mut relaxed = QueryArgs {
+        relax: false,
+        ..args.clone()
     };
+    let mut dropped = Vec::new();
+    STEPS
+        .iter()
+        .filter_map(|step| {
+            let before = dropped.len();
+            step(&mut relaxed, &mut dropped);
+            (dropped.len() > before).then(|| (relaxed.clone(), dropped.clone()))
+        })
+        .collect()
+}
+
+/// The `search` flags that reproduce `args`' filters, for the working
+/// session. Only non-default values; scoring knobs (`--threshold`,
+/// `--name-boost`) and output shaping are left out.
+pub(crate) fn session_flags(args: &QueryArgs) -> Vec<String> {
+    let defaults = QueryArgs::default();
+    let mut flags = Vec::new();
+    let mut push = |flag: &str, value: Option<String>| {
+        flags.push(flag.to_string());
+        flags.extend(value);
+    };
+    if args.limit != defaults.limit {
+        push("--limit", Some(args.limit.to_string()));
+    }
+    if args.name_only {
+        push("--name-only", None);
+    }
+    if let Some(lang) = &args.lang {
+        push("--lang", Some(lang.clone()));
+    }
+    for t in args.include_type.iter().flatten() {
+        push("--include-type", Some(t.clone()));
+    }
+    for t in args.exclude_type.iter().flatten() {
+        push("--exclude-type", Some(t.clone()));
+    }
+    if let Some(path) = &args.path {
+        push("--path", Some(path.clone()));
+    }
+    if let Some(rev) = &args.changed_since {
+        push("--changed-since", Some(rev.clone()));
+    }
+    if let Some(re) = &args.grep {
+        push("--grep", Some(re.clone()));
+    }
+    if let Some(shape) = &args.signature {
+        push("--signature", Some(shape.clone()));
+    }
+    if let Some(owner) = &args.owner {
+        push("--owner", Some(owner.clone()));
+    }
+    if let Some(issue) = &args.issue {
+        push("--issue", Some(issue.clone()));
+    }
+    if let Some(scope) = args.search_in {
+        push("--in", Some(scope.as_str().to_string()));
+    }
+    if let Some(pattern) = &args.pattern {
+        push("--pattern", Some(pattern.clone()));
+    }
+    if args.include_docs {
+        push("--include-docs", None);
+    }
+    if args.with_commits {
+        push("--with-commits", None);
+    }
+    if args.hide_deprecated {
+        push("--hide-deprecated", None);
+    }
+    if args.include_generated {
+        push("--include-generated", None);
+    }
+    if args.rrf {
+        push("--rrf", None);
+    }
+    if args.rerank {
+        push("--reranker", Some("onnx".to_string()));
+    } else if args.late_interaction {
+        push("--reranker", Some("late".to_string()));
+    }
+    if args.splade {
+        push("--splade", None);
+    }
+    if let Some(alpha) = args.splade_alpha {
+        push("--splade-alpha", Some(alpha.to_string()));
+    }
+    if args.no_demote {
+        push("--no-demote", None);
+    }
+    if let Some(tokens) = args.tokens {
+        push("--tokens", Some(tokens.to_string()));
+    }
+    if args.hyde {
+        push("--hyde", None);
+    }
+    if args.translate == cqs::config::QueryTranslate::Always {
+        push("--translate", None);
+    }
+    flags
+}
 
-    let results = retrieve_project(ctx, args, &prepared)?;
-    assemble_output(ctx, args, results)
+/// The results as the working session keeps them, for `cqs open <N>`.
+pub(crate) fn session_results(results: &[UnifiedResult]) -> Vec<cqs::session::SessionResult> {
+    results
+        .iter()
+        .map(|r| match r {
+            UnifiedResult::Code(r) => cqs::session::SessionResult {
+                file: cqs::normalize_path(&r.chunk.file),
+                line: r.chunk.line_start,
+                name: r.chunk.name.clone(),
+            },
+        })
+        .collect()
+}
+
+/// Count the returned results as (sampled) search hits in the access stats.
+/// Best-effort; see [`cqs::access::record_access`].
+pub(crate) fn record_search_hits(cqs_dir: &std::path::Path, results: &[UnifiedResult]) {
+    let origins: Vec<(String, &str)> = results
+        .iter()
+        .map(|r| match r {
+            UnifiedResult::Code(r) => (cqs::normalize_path(&r.chunk.file), r.chunk.name.as_str()),
+        })
+        .collect();
+    let keys: Vec<(&str, &str)> = origins.iter().map(|(o, n)| (o.as_str(), *n)).collect();
+    cqs::access::record_access(cqs_dir, cqs::access::AccessKind::Hit, &keys);
 }
 
 /// The shared query-preparation prelude: classification, the NameOnly-FTS-first
@@ -480,6 +800,9 @@ pub(crate) fn prepare_query<'a>(
     let query = args.query.as_str();
     let store = ctx.store();
     let cqs_dir = ctx.cqs_dir();
+    super::hyde::clear_hyde_meta();
+    super::translate::clear_translation_meta();
+    cqs::store::clear_search_generation();
 
     // Overlay-active fetch over-fetch (plan §7.2, risk #5): masking the delta's
     // origins out of a `limit`-sized parent fetch can hollow the top-k below
@@ -497,6 +820,98 @@ pub(crate) fn prepare_query<'a>(
         }
     };
 
+    // `--changed-since`: resolve the diff surface to an origin set once. The
+    // dense path hands it to the store as `SearchFilter::origins` (gated in
+    // scoring and in the index traversal); the FTS-by-name short-circuits
+    // below post-filter their hits on it.
+    let changed_origins = match args.changed_since.as_deref() {
+        Some(base) => Some(changed_since::changed_origins(ctx.root(), base)?),
+        None => None,
+    };
+
+    // `--grep`: the dense path hands the regex to the store as
+    // `SearchFilter::content_regex` (resolved to a chunk allowlist before
+    // ranking); the FTS-by-name short-circuits post-filter on it, same as
+    // `--changed-since`. Compiled here so a bad pattern fails before any work.
+    let grep_re = args
+        .grep
+        .as_deref()
+        .map(regex::Regex::new)
+        .transpose()
+        .context("Invalid --grep regex")?;
+    // `--signature`: same split — `SearchFilter::signature` on the dense path,
+    // a post-filter on the short-circuits, which compute each hit's shape
+    // from its signature text.
+    let signature_pattern = args
+        .signature
+        .as_deref()
+        .map(cqs::parser::signature::SignaturePattern::parse)
+        .transpose()
+        .map_err(|e| anyhow::anyhow!("Invalid --signature shape: {e}"))?;
+    // `--owner`: CODEOWNERS is resolved here, against the working tree, to
+    // the indexed origins it assigns to the owner; the store adds chunks
+    // whose blame authors match. The short-circuits check both directly.
+    let owner_filter = args
+        .owner
+        .as_deref()
+        .map(|name| resolve_owner_filter(store, ctx.root(), name))
+        .transpose()?;
+    let owner_author_ids = match &owner_filter {
+        Some(owner) => Some(
+            store
+                .chunk_ids_by_author(&owner.name)
+                .context("Failed to read blame authors")?,
+        ),
+        None => None,
+    };
+    // `--issue`: canonicalized here so `jira-1234` and `567` find what the
+    // index stored; the store resolves it to an allowlist, which the
+    // short-circuits reuse directly.
+    let issue = args
+        .issue
+        .as_deref()
+        .map(|id| {
+            cqs::parser::issue_refs::canonical_issue_id(id).ok_or_else(|| {
+                anyhow::anyhow!(
+                    "--issue expects one issue or PR reference (JIRA-1234, #567, owner/repo#567), got '{id}'"
+                )
+            })
+        })
+        .transpose()?;
+    let issue_ids = match &issue {
+        Some(issue) => Some(
+            store
+                .chunk_ids_by_issue(issue)
+                .context("Failed to read issue references")?,
+        ),
+        None => None,
+    };
+    // `--hide-deprecated` and the boilerplate exclusion read the flags
+    // straight off each short-circuit hit.
+    let chunk_allowed = |chunk: &cqs::store::ChunkSummary| {
+        !(args.hide_deprecated && chunk.deprecated)
+            && (args.include_generated || chunk.boilerplate.is_none())
+            && issue_ids.as_ref().is_none_or(|ids| ids.contains(&chunk.id))
+            && owner_filter.as_ref().is_none_or(|owner| {
+                owner.origins.contains(&cqs::normalize_path(&chunk.file))
+                    || owner_author_ids
+                        .as_ref()
+                        .is_some_and(|ids| ids.contains(&chunk.id))
+            })
+            && grep_re
+                .as_ref()
+                .is_none_or(|re| re.is_match(&chunk.content))
+            && signature_pattern.as_ref().is_none_or(|p| {
+                cqs::parser::signature::SignatureShape::extract(
+                    &chunk.signature,
+                    &chunk.name,
+                    chunk.language,
+                )
+                .filter(|_| chunk.chunk_type.is_callable())
+                .is_some_and(|shape| p.matches(&shape))
+            })
+    };
+
     // Name-only path: FTS by name, skip embedding entirely. With an overlay,
     // mask the delta's parent name hits and merge the overlay store's name
     // hits in their place (plan §7.3). The `--name-only` flag has no dense
@@ -509,19 +924,33 @@ pub(crate) fn prepare_query<'a>(
             .search_by_name(query, overlay_fetch(args.limit))
             .context("Failed to search by name")?;
         let merged = overlay_mask_name_results(ctx.overlay().as_deref(), parent, query, args)?;
-        let unified: Vec<UnifiedResult> = merged.into_iter().map(UnifiedResult::Code).collect();
+        let unified: Vec<UnifiedResult> = merged
+            .into_iter()
+            .filter(|r| changed_since::origin_allowed(changed_origins.as_ref(), &r.chunk.file))
+            .filter(|r| chunk_allowed(&r.chunk))
+            .map(UnifiedResult::Code)
+            .collect();
         return Ok(Prepared::ShortCircuit(unified));
     }
 
+    // Everything past a name lookup runs on the English rewrite when
+    // translation fires; the filter's `query_text` carries it on to FTS and
+    // the rerankers.
+    let translated = super::translate::translate_for_search(ctx.root(), query, args.translate);
+    let query = translated.as_deref().unwrap_or(query);
+
     // Adaptive routing: classify query BEFORE embedding to potentially skip it.
     // --splade is NOT a routing override (it only controls SPLADE fusion);
-    // --rrf/--rerank override the search strategy. (--ref reaches here too: it
-    // drives the same prepared query, fanning out over a reference store.)
+    // --rrf/--reranker and a scoped --in (which implies RRF) override the search
+    // strategy. (--ref reaches here too: it drives the same prepared query,
+    // fanning out over a reference store.)
     //
     // `always_route` (daemon) keeps the router live even with explicit flags —
     // `cqs search` always classifies — while the CLI suppresses classification
     // when the user pins a strategy.
-    let has_explicit_flags = (args.rrf || args.rerank) && !args.always_route;
+    let scoped_keywords = args.search_in.is_some_and(|s| s != SearchIn::Both);
+    let has_explicit_flags =
+        (args.rrf || args.rerank || args.late_interaction || scoped_keywords) && !args.always_route;
     let classification = if !has_explicit_flags {
         let c = cqs::search::router::classify_query(query);
         tracing::info!(
@@ -550,7 +979,16 @@ pub(crate) fn prepare_query<'a>(
             // masked (every match lives in a changed file) must fall through to
             // the dense path — where the overlay leg can still answer — rather
             // than short-circuiting to an empty result.
-            let results = overlay_mask_name_results(ctx.overlay().as_deref(), parent, query, args)?;
+            let mut results =
+                overlay_mask_name_results(ctx.overlay().as_deref(), parent, query, args)?;
+            // Same ordering rule for `--changed-since`, `--grep`,
+            // `--signature`, `--owner`, and `--issue`: restrict first, so a name hit set entirely outside
+            // the diff (or without a regex or shape match) falls through to
+            // dense.
+            results.retain(|r| {
+                changed_since::origin_allowed(changed_origins.as_ref(), &r.chunk.file)
+                    && chunk_allowed(&r.chunk)
+            });
             if !results.is_empty() {
                 tracing::info!(results = results.len(), "NameOnly search succeeded");
                 crate::cli::telemetry::log_routed(
@@ -600,6 +1038,14 @@ pub(crate) fn prepare_query<'a>(
         .map(|c| cqs::search::router::reclassify_with_centroid(c, query_embedding.as_slice()));
     let centroid_applied = classification.as_ref().map(|c| c.category) != pre_centroid_cat;
 
+    // Query-time HyDE: after classification, which should see the query as
+    // typed, blend the hypothetical snippet's embedding into the query's.
+    let query_embedding = if args.hyde {
+        super::hyde::expand_query_embedding(ctx, query, query_embedding)
+    } else {
+        query_embedding
+    };
+
     let languages = match &args.lang {
         Some(l) => Some(vec![l.parse().context(format!(
             "Invalid language. Valid: {}",
@@ -620,8 +1066,13 @@ pub(crate) fn prepare_query<'a>(
         }
         None if args.include_docs => None, // --include-docs: search everything
         None => {
-            // Default: search code only (callable types + type definitions).
-            Some(ChunkType::code_types())
+            // Default: search code only (callable types + type definitions),
+            // plus commit messages under --with-commits.
+            let mut types = ChunkType::code_types();
+            if args.with_commits {
+                types.push(ChunkType::Commit);
+            }
+            Some(types)
         }
     };
 
@@ -637,6 +1088,14 @@ pub(crate) fn prepare_query<'a>(
         }
         None => None,
     };
+    // Commits are opt-in: `--include-docs` alone still leaves them out.
+    let exclude_types = if include_types.is_none() && !args.with_commits {
+        let mut types = exclude_types.unwrap_or_default();
+        types.push(ChunkType::Commit);
+        Some(types)
+    } else {
+        exclude_types
+    };
 
     // Type boost from adaptive routing (boost, not filter — won't exclude).
     let type_boost_types = classification.as_ref().and_then(|c| c.type_hints.clone());
@@ -670,6 +1129,27 @@ pub(crate) fn prepare_query<'a>(
         f.splade_alpha = splade_alpha;
         f.type_boost_types = type_boost_types;
         f.record_rank_signals = args.record_rank_signals;
+        f.origins = changed_origins;
+        f.content_regex = args.grep.clone();
+        f.signature = args.signature.clone();
+        f.owner = owner_filter;
+        f.issue = issue;
+        f.fts_scope = args.search_in.map(Into::into).unwrap_or_default();
+        f.popularity = cqs::access::popularity_prior_for_search(cqs_dir);
+        f.feedback = cqs::feedback::feedback_prior_for_search(cqs_dir);
+        f.boosts = cqs::boosts::boosts_for_search(ctx.root());
+        f.facet_weights = cqs::facets::weights_for_search(ctx.root());
+        if args.late_interaction {
+            f.late_interaction = cqs::late_interaction::query_for_search(
+                ctx.root(),
+                ctx.store(),
+                ctx.embedder()?,
+                query,
+            )
+            .map(std::sync::Arc::new);
+        }
+        f.hide_deprecated = args.hide_deprecated;
+        f.include_generated = args.include_generated;
         f
     };
     filter.validate().map_err(|e| anyhow::anyhow!(e))?;
@@ -792,7 +1272,22 @@ pub(crate) fn retrieve_project(
     args: &QueryArgs,
     prepared: &PreparedQuery<'_>,
 ) -> Result<Vec<UnifiedResult>> {
-    let query = args.query.as_str();
+    retrieve_project_staged(ctx, args, prepared, &mut |_| Ok(()))
+}
+
+/// [`retrieve_project`] with a hook on the first-stage pool: when a reranker
+/// is set, `on_candidates` sees the pattern-filtered candidates in retrieval
+/// order just before the cross-encoder runs. `--stream` uses it to emit
+/// provisional hits while the rerank is still in flight. Not called without a
+/// reranker — the first-stage order is then already final.
+pub(crate) fn retrieve_project_staged(
+    ctx: &dyn search_ctx::SearchCtx,
+    args: &QueryArgs,
+    prepared: &PreparedQuery<'_>,
+    on_candidates: &mut dyn FnMut(&[UnifiedResult]) -> Result<()>,
+) -> Result<Vec<UnifiedResult>> {
+    // The prepared query text — the English rewrite when translation fired.
+    let query = prepared.filter.query_text.as_str();
     let store = ctx.store();
 
     // Worktree overlay (result-trust §3). The store fetch already over-fetched
@@ -815,6 +1310,12 @@ pub(crate) fn retrieve_project(
 
     let results = run_project_search(store, args, prepared)?;
 
+    // Sharded index: fan the same prepared query out to every shard and merge
+    // before the pattern filter and rerank, so both see the federated pool as
+    // they would one store. Unsharded ⇒ `results` unchanged.
+    let shards = ctx.shards();
+    let results = merge_shards(args, prepared, results, &shards);
+
     // Pattern filter.
     let pattern: Option<Pattern> = args
         .pattern
@@ -843,6 +1344,7 @@ pub(crate) fn retrieve_project(
     // — this project-only rerank still serves the no-overlay rerank path and
     // supplies a frame-consistent, truncated project pool to the merge.
     let results = if let Some(reranker) = prepared.reranker.as_deref() {
+        on_candidates(&results)?;
         rerank_unified(reranker, query, results, post_limit)?
     } else {
         results
@@ -1000,7 +1502,7 @@ pub(crate) fn apply_overlay(
     let merged = if let Some(reranker) = prepared.reranker.as_deref() {
         let pool_limit = crate::cli::limits::rerank_pool_size(args.limit);
         let pool = reference::merge_results_for_rerank(masked, leg, pool_limit);
-        rerank_tagged(reranker, args.query.as_str(), pool, args.limit)?
+        rerank_tagged(reranker, &prepared.filter.query_text, pool, args.limit)?
     } else {
         reference::merge_results(masked, leg, args.limit)
     };
@@ -1017,6 +1519,55 @@ pub(crate) fn apply_overlay(
     Ok(merged.into_iter().map(|t| t.result).collect())
 }
 
+/// Federated search over a sharded index (`cqs::shard`): search every shard
+/// with the prepared embedding and filter, then merge the hits with the root
+/// shard's `results` by score, truncated to the same `search_limit` pool the
+/// root fetch used. Content-hash dedup in `merge_results` collapses a file
+/// that moved shards and has not been pruned from the old one yet.
+///
+/// Shards are searched dense-only through their own HNSW, as references are;
+/// on the SPLADE/audit hybrid path the root shard's fused scores are merged
+/// against shard cosine scores as-is.
+fn merge_shards(
+    args: &QueryArgs,
+    prepared: &PreparedQuery<'_>,
+    results: Vec<UnifiedResult>,
+    shards: &[std::sync::Arc<cqs::reference::ReferenceIndex>],
+) -> Vec<UnifiedResult> {
+    if shards.is_empty() {
+        return results;
+    }
+    let _span = tracing::info_span!("merge_shards", shards = shards.len()).entered();
+
+    use rayon::prelude::*;
+    let legs: Vec<_> = shards
+        .par_iter()
+        .filter_map(|shard| {
+            match reference::search_reference(
+                shard,
+                &prepared.query_embedding,
+                &prepared.filter,
+                prepared.search_limit,
+                args.threshold,
+                false,
+            ) {
+                Ok(r) if !r.is_empty() => Some((shard.name.clone(), r)),
+                Err(e) => {
+                    tracing::warn!(shard = %shard.name, error = %e, "Shard search failed; serving the other shards");
+                    None
+                }
+                _ => None,
+            }
+        })
+        .collect();
+
+    // Shards are all the project, so the per-leg source tags are dropped.
+    reference::merge_results(results, legs, prepared.search_limit)
+        .into_iter()
+        .map(|t| t.result)
+        .collect()
+}
+
 /// The project-store dense/hybrid retrieval call itself, with no post-filtering.
 ///
 /// Audit mode and SPLADE both require the hybrid path (`search_unified` doesn't
@@ -1255,7 +1806,7 @@ pub(crate) fn merge_references(
         // rerank step.
         let pool_limit = crate::cli::limits::rerank_pool_size(args.limit);
         let pool = reference::merge_results_for_rerank(project_results, ref_results, pool_limit);
-        rerank_tagged(reranker, args.query.as_str(), pool, args.limit)
+        rerank_tagged(reranker, &prepared.filter.query_text, pool, args.limit)
     } else {
         // No reranker: today's score-sort path, unchanged. The default
         // cosine-family legs are frame-adjacent and recall-sensitive.
@@ -1281,7 +1832,18 @@ pub(crate) fn retrieve_ref_scoped(
     ref_name: &str,
 ) -> Result<Vec<reference::TaggedResult>> {
     let ref_idx = ctx.reference_by_name(ref_name)?;
+    retrieve_from_reference(&ref_idx, args, prepared, ref_name)
+}
 
+/// Retrieval against one already-loaded reference store, shared by `--ref`
+/// (a configured reference) and `--at` (a git-rev snapshot). Every result is
+/// tagged with `label`.
+fn retrieve_from_reference(
+    ref_idx: &reference::ReferenceIndex,
+    args: &QueryArgs,
+    prepared: &PreparedQuery<'_>,
+    label: &str,
+) -> Result<Vec<reference::TaggedResult>> {
     // Shared pool sizing: over-fetch when reranking so the cross-encoder sees
     // more candidates, then trim to `limit` in the rerank step.
     let ref_limit = if prepared.reranker.is_some() {
@@ -1291,7 +1853,7 @@ pub(crate) fn retrieve_ref_scoped(
     };
 
     let mut results = reference::search_reference(
-        &ref_idx,
+        ref_idx,
         &prepared.query_embedding,
         &prepared.filter,
         ref_limit,
@@ -1302,7 +1864,7 @@ pub(crate) fn retrieve_ref_scoped(
     if let Some(reranker) = prepared.reranker.as_deref() {
         if results.len() > 1 {
             reranker
-                .rerank(args.query.as_str(), &mut results, args.limit)
+                .rerank(&prepared.filter.query_text, &mut results, args.limit)
                 .map_err(|e| anyhow::anyhow!("Reranking failed: {e}"))?;
         }
     }
@@ -1311,7 +1873,7 @@ pub(crate) fn retrieve_ref_scoped(
         .into_iter()
         .map(|r| reference::TaggedResult {
             result: UnifiedResult::Code(r),
-            source: Some(ref_name.to_string()),
+            source: Some(label.to_string()),
         })
         .collect())
 }
@@ -1319,7 +1881,7 @@ pub(crate) fn retrieve_ref_scoped(
 /// Final assembly shared by the core's name-only, NameOnly-FTS, and dense
 /// paths: token-budget packing + parent-context resolution. The input is
 /// already a ranked `Vec<UnifiedResult>`.
-fn assemble_output(
+pub(crate) fn assemble_output(
     ctx: &dyn search_ctx::SearchCtx,
     args: &QueryArgs,
     results: Vec<UnifiedResult>,
@@ -1327,6 +1889,18 @@ fn assemble_output(
     let store = ctx.store();
     let root = ctx.root();
 
+    let results = cut_below_min_score(results, args.min_score, unified_score);
+
+    // v35: under --expand-parent a container hit (impl/class) is redundant
+    // when one of its methods also matched — the method carries it as parent
+    // context — so fold it before packing spends budget on it.
+    let (results, containers) = if args.expand_parent {
+        let containers = result_containers(&results, store);
+        (fold_contained_hits(results, &containers), containers)
+    } else {
+        (results, HashMap::new())
+    };
+
     // Token-budget packing. The per-result JSON overhead is resolved by the
     // adapter into `args.json_overhead` (the CLI's format-dependent estimate),
     // so packing keeps the exact same survivors as before the core split.
@@ -1347,34 +1921,279 @@ fn assemble_output(
     };
 
     let parents = if args.expand_parent {
-        resolve_parent_context(&results, store, root)
+        resolve_parent_context(&results, store, root, &containers)
+    } else {
+        HashMap::new()
+    };
+
+    let tests = if args.with_tests {
+        resolve_result_tests(&results, store, root)?
+    } else {
+        HashMap::new()
+    };
+
+    let owners = if args.with_owners {
+        resolve_result_owners(&results, store, root)?
     } else {
         HashMap::new()
     };
 
+    let ids: Vec<&str> = results
+        .iter()
+        .map(|r| {
+            let UnifiedResult::Code(sr) = r;
+            sr.chunk.id.as_str()
+        })
+        .collect();
+    let issue_refs = store
+        .chunk_issue_refs(&ids)
+        .context("Failed to load issue references")?;
+    let commits = store
+        .commits_by_chunk(&ids)
+        .context("Failed to load commit metadata")?;
+
     Ok(QueryOutput {
         query: args.query.clone(),
         results,
         parents,
+        tests,
+        owners,
+        issue_refs,
+        commits,
         token_info,
+        calibration: None,
+        relaxed: Vec::new(),
     })
 }
 
+/// The stored confidence curve (`cqs eval --calibrate`) for the index's
+/// model and the score scale `args` rank on. Only dense rankings use it: a
+/// name lookup's scores are not on any calibrated scale.
+pub(crate) fn score_calibration(
+    ctx: &dyn search_ctx::SearchCtx,
+    args: &QueryArgs,
+) -> Option<cqs::calibration::Calibration> {
+    let model = ctx.store().stored_model_name()?;
+    let scoped_keywords = args.search_in.is_some_and(|s| s != SearchIn::Both);
+    let scale = cqs::calibration::ScoreScale::of(
+        args.rrf || scoped_keywords,
+        args.rerank,
+        args.late_interaction,
+    );
+    let calibration =
+        cqs::calibration::load_calibration(&cqs::resolve_index_dir(ctx.root()), &model, scale);
+    tracing::debug!(%model, %scale, found = calibration.is_some(), "Score calibration");
+    calibration
+}
+
+/// `--owner <NAME>`: the indexed origins CODEOWNERS assigns to `name`, as a
+/// [`cqs::ownership::OwnerFilter`]. A project without CODEOWNERS resolves to
+/// no origins, leaving blame authors as the only match.
+fn resolve_owner_filter(
+    store: &Store<cqs::store::ReadOnly>,
+    root: &std::path::Path,
+    name: &str,
+) -> Result<cqs::ownership::OwnerFilter> {
+    let _span = tracing::info_span!("resolve_owner_filter", name).entered();
+    if name.trim().trim_start_matches('@').is_empty() {
+        anyhow::bail!("--owner needs a team or person name");
+    }
+    let origins = match cqs::ownership::CodeOwners::load(root) {
+        Some(codeowners) => {
+            let indexed = store
+                .indexed_file_origins()
+                .context("Failed to list indexed files")?;
+            codeowners.origins_owned_by(name, indexed.keys().map(String::as_str))
+        }
+        None => std::collections::HashSet::new(),
+    };
+    tracing::info!(owned = origins.len(), "Resolved --owner origins");
+    Ok(cqs::ownership::OwnerFilter {
+        name: name.to_string(),
+        origins,
+    })
+}
+
+/// `--with-owners`: CODEOWNERS owners (read now, from the working tree) and
+/// stored blame authors for each result. Results neither source knows
+/// about get no entry.
+fn resolve_result_owners(
+    results: &[UnifiedResult],
+    store: &Store<cqs::store::ReadOnly>,
+    root: &std::path::Path,
+) -> Result<ResultOwners> {
+    let _span = tracing::info_span!("resolve_result_owners", results = results.len()).entered();
+    let codeowners = cqs::ownership::CodeOwners::load(root).unwrap_or_default();
+    let ids: Vec<&str> = results
+        .iter()
+        .map(|r| {
+            let UnifiedResult::Code(sr) = r;
+            sr.chunk.id.as_str()
+        })
+        .collect();
+    let mut authors = store
+        .chunk_authors(&ids)
+        .context("Failed to load blame authors")?;
+    let mut owners = HashMap::new();
+    for r in results {
+        let UnifiedResult::Code(sr) = r;
+        let ownership = cqs::ownership::ChunkOwnership {
+            owners: codeowners
+                .owners_of(&cqs::normalize_path(&sr.chunk.file))
+                .to_vec(),
+            authors: authors.remove(&sr.chunk.id).unwrap_or_default(),
+        };
+        if !ownership.is_empty() {
+            owners.insert(sr.chunk.id.clone(), ownership);
+        }
+    }
+    Ok(owners)
+}
+
+/// Linked tests shown per result under `--with-tests`.
+const WITH_TESTS_PER_RESULT: usize = 3;
+
+/// `--with-tests`: the tests linked to each callable result, as `cqs
+/// test-map` finds them. Results that share a name (windows of one long
+/// function) share one lookup; results with no linked test get no entry.
+fn resolve_result_tests(
+    results: &[UnifiedResult],
+    store: &Store<cqs::store::ReadOnly>,
+    root: &std::path::Path,
+) -> Result<ResultTests> {
+    let _span = tracing::info_span!("resolve_result_tests", results = results.len()).entered();
+    let graph = store
+        .get_call_graph()
+        .context("Failed to load call graph")?;
+    let test_chunks = store
+        .find_test_chunks()
+        .context("Failed to find test chunks")?;
+    let mut by_name: HashMap<&str, Vec<TestMapEntry>> = HashMap::new();
+    let mut tests = HashMap::new();
+    for r in results {
+        let UnifiedResult::Code(sr) = r;
+        if !sr.chunk.chunk_type.is_callable() {
+            continue;
+        }
+        let linked = by_name.entry(sr.chunk.name.as_str()).or_insert_with(|| {
+            crate::cli::commands::related_tests(
+                &sr.chunk.name,
+                &graph,
+                &test_chunks,
+                root,
+                WITH_TESTS_PER_RESULT,
+            )
+        });
+        if !linked.is_empty() {
+            tests.insert(sr.chunk.id.clone(), linked.clone());
+        }
+    }
+    Ok(tests)
+}
+
+/// `--explain` lines for the results the project boost list moved, one per
+/// matching rule: `Boost: src/net.rs:40 retry — pin x1.5 (the blessed helper)`.
+fn boost_explain_lines(
+    rules: &cqs::boosts::BoostRules,
+    query: &str,
+    results: &[UnifiedResult],
+    root: &std::path::Path,
+) -> Vec<String> {
+    let mut lines = Vec::new();
+    for r in results {
+        let UnifiedResult::Code(sr) = r;
+        for rule in rules.matching(query, &sr.chunk) {
+            let reason = rule
+                .reason
+                .as_deref()
+                .map(|reason| format!(" ({reason})"))
+                .unwrap_or_default();
+            lines.push(format!(
+                "Boost: {}:{} {} — {} x{}{}",
+                cqs::rel_display(&sr.chunk.file, root),
+                sr.chunk.line_start,
+                sr.chunk.name,
+                rule.kind.as_str(),
+                rule.factor,
+                reason
+            ));
+        }
+    }
+    lines
+}
+
+/// `--explain` lines for the results whose score blended facet vectors, from
+/// their `facet_*` rank signals:
+/// `Facets: src/net.rs:40 retry — whole 0.61, name 0.82, body 0.55`.
+fn facet_explain_lines(results: &[UnifiedResult], root: &std::path::Path) -> Vec<String> {
+    let mut lines = Vec::new();
+    for r in results {
+        let UnifiedResult::Code(sr) = r;
+        let parts: Vec<String> = sr
+            .rank_signals
+            .iter()
+            .filter_map(|s| {
+                let facet = s.signal.strip_prefix("facet_")?;
+                Some(format!("{facet} {:.2}", s.value))
+            })
+            .collect();
+        if !parts.is_empty() {
+            lines.push(format!(
+                "Facets: {}:{} {} — {}",
+                cqs::rel_display(&sr.chunk.file, root),
+                sr.chunk.line_start,
+                sr.chunk.name,
+                parts.join(", ")
+            ));
+        }
+    }
+    lines
+}
+
 /// Render a [`QueryOutput`] for the CLI: staleness warning, empty-result exit,
 /// and text/JSON emission via the typed display structs.
 fn render_query_output(
-    cli: &Cli,
-    root: &std::path::Path,
-    store: &Store<cqs::store::ReadOnly>,
+    ctx: &crate::cli::CommandContext<'_, cqs::store::ReadOnly>,
     output: QueryOutput,
 ) -> Result<()> {
+    let cli = ctx.cli;
+    let root = &ctx.root;
+    let store = &ctx.store;
     let QueryOutput {
         query,
         results,
         parents,
+        tests,
+        owners,
+        issue_refs,
+        commits,
         token_info,
+        calibration,
+        relaxed,
     } = output;
 
+    // `--explain` in text mode; JSON carries the same records as
+    // `_meta.translation` / `_meta.hyde` / `_meta.index_generation`.
+    if cli.explain && !cli.json {
+        if let Some(generation) = cqs::store::take_search_generation() {
+            eprintln!("Index generation: {generation}");
+        }
+        if let Some(meta) = super::translate::take_translation_meta() {
+            eprintln!("{}", meta.explain_line());
+        }
+        if let Some(meta) = super::hyde::take_hyde_meta() {
+            eprintln!("{}", meta.explain_line());
+        }
+        if let Some(rules) = cqs::boosts::boosts_for_search(root) {
+            for line in boost_explain_lines(&rules, &query, &results, root) {
+                eprintln!("{line}");
+            }
+        }
+        for line in facet_explain_lines(&results, root) {
+            eprintln!("{line}");
+        }
+    }
+
     // Staleness warning (surface I/O — adapter owns it).
     if !cli.quiet && !cli.no_stale_check {
         let origins: Vec<&str> = results
@@ -1391,6 +2210,18 @@ fn render_query_output(
         }
     }
 
+    if !relaxed.is_empty() && !cli.quiet && !cli.json {
+        let dropped: Vec<String> = relaxed.iter().map(ToString::to_string).collect();
+        if results.is_empty() {
+            eprintln!("No results even after dropping {}", dropped.join(", "));
+        } else {
+            eprintln!(
+                "No results as given; showing results after dropping {}",
+                dropped.join(", ")
+            );
+        }
+    }
+
     if results.is_empty() {
         emit_empty_results(&query, cli.json, None);
     }
@@ -1401,10 +2232,46 @@ fn render_query_output(
         None
     };
 
-    if cli.json {
-        display::display_unified_results_json(&results, &query, parents_ref, token_info)?;
+    let extras = ResultExtras {
+        tests: cli.with_tests.then_some(&tests),
+        owners: cli.with_owners.then_some(&owners),
+        issue_refs: Some(&issue_refs),
+        commits: Some(&commits),
+        calibration: calibration.as_ref(),
+    };
+
+    if cli.format == Some(crate::cli::definitions::SearchFormat::Prompt) {
+        let embedder = match cli.tokens {
+            Some(_) => Some(ctx.embedder()?),
+            None => None,
+        };
+        super::prompt::print_prompt(&results, root, cli.tokens, embedder, cli.quiet)?;
+    } else if cli.json {
+        display::display_unified_results_json(
+            &results,
+            &query,
+            parents_ref,
+            extras,
+            token_info,
+            cli.snippet_lines,
+            &relaxed,
+        )?;
     } else {
-        display::display_unified_results(&results, root, cli.no_content, cli.context, parents_ref)?;
+        display::display_unified_results(
+            &results,
+            root,
+            cli.no_content,
+            cli.context,
+            cli.snippet_lines,
+            parents_ref,
+            extras,
+        )?;
+    }
+
+    if cli.open_first {
+        if let Some(top) = session_results(&results).into_iter().next() {
+            crate::cli::commands::open::open_result(root, &top, false)?;
+        }
     }
     Ok(())
 }
@@ -1471,6 +2338,25 @@ pub(crate) fn cmd_query(
         );
     }
 
+    // `--at <rev>`: search a git-rev snapshot instead of the project index.
+    if let Some(ref rev) = cli.at {
+        return cmd_query_at(ctx, query, rev);
+    }
+
+    // `--stream`: NDJSON events on stdout as results finalize. Plain
+    // single-store path only — the multi-store fan-outs merge after every leg
+    // is in, so there is nothing earlier to emit.
+    if cli.stream {
+        if cli.ref_name.is_some() || cli.include_refs {
+            bail!("--stream is not supported with --ref or --include-refs");
+        }
+        if cli.name_only && cli.rerank_active() {
+            bail!("--rerank requires embedding search, incompatible with --name-only");
+        }
+        let args = QueryArgs::from_cli(cli, overlay_eligible);
+        return super::stream::cmd_query_stream(ctx, &args);
+    }
+
     // Name-only mode: search by function/struct name, skip embedding entirely.
     if cli.name_only {
         if cli.rerank_active() {
@@ -1488,14 +2374,14 @@ pub(crate) fn cmd_query(
         // name-only path, matching prior behavior.
         let args = QueryArgs::from_cli(cli, overlay_eligible);
         let output = query_core(ctx, &args)?;
-        return render_query_output(cli, root, store, output);
+        return render_query_output(ctx, output);
     }
 
     // Plain (non-ref, non-multi-index) search routes through the shared core.
     if cli.ref_name.is_none() && !cli.include_refs {
         let args = QueryArgs::from_cli(cli, overlay_eligible);
         let output = query_core(ctx, &args)?;
-        return render_query_output(cli, root, store, output);
+        return render_query_output(ctx, output);
     }
 
     // `--ref` / `--include-refs`: the multi-store paths. They share the entire
@@ -1521,7 +2407,7 @@ pub(crate) fn cmd_query(
         // `--include-refs` rendered project results only, no reference merge.
         Prepared::ShortCircuit(results) => {
             let output = assemble_output(ctx, &args, results)?;
-            return render_query_output(cli, root, store, output);
+            return render_query_output(ctx, output);
         }
         Prepared::Dense(p) => p,
     };
@@ -1536,9 +2422,22 @@ pub(crate) fn cmd_query(
             emit_empty_results(query, cli.json, Some(ref_name.as_str()));
         }
         if cli.json {
-            display::display_tagged_results_json(&tagged, query, None, token_info)?;
+            display::display_tagged_results_json(
+                &tagged,
+                query,
+                None,
+                token_info,
+                cli.snippet_lines,
+            )?;
         } else {
-            display::display_tagged_results(&tagged, root, cli.no_content, cli.context, None)?;
+            display::display_tagged_results(
+                &tagged,
+                root,
+                cli.no_content,
+                cli.context,
+                cli.snippet_lines,
+                None,
+            )?;
         }
         return Ok(());
     }
@@ -1568,10 +2467,13 @@ pub(crate) fn cmd_query(
         }
     }
 
-    let parents = if cli.expand_parent {
-        resolve_parent_context(&project_results, store, root)
+    let (project_results, parents) = if cli.expand_parent {
+        let containers = result_containers(&project_results, store);
+        let project_results = fold_contained_hits(project_results, &containers);
+        let parents = resolve_parent_context(&project_results, store, root, &containers);
+        (project_results, parents)
     } else {
-        HashMap::new()
+        (project_results, HashMap::new())
     };
     let parents_ref = if cli.expand_parent {
         Some(&parents)
@@ -1588,9 +2490,22 @@ pub(crate) fn cmd_query(
         emit_empty_results(query, cli.json, None);
     }
     if cli.json {
-        display::display_tagged_results_json(&tagged, query, parents_ref, token_info)?;
+        display::display_tagged_results_json(
+            &tagged,
+            query,
+            parents_ref,
+            token_info,
+            cli.snippet_lines,
+        )?;
     } else {
-        display::display_tagged_results(&tagged, root, cli.no_content, cli.context, parents_ref)?;
+        display::display_tagged_results(
+            &tagged,
+            root,
+            cli.no_content,
+            cli.context,
+            cli.snippet_lines,
+            parents_ref,
+        )?;
     }
     Ok(())
 }
@@ -1606,6 +2521,7 @@ fn pack_tagged_cli(
     args: &QueryArgs,
     tagged: Vec<reference::TaggedResult>,
 ) -> Result<TaggedPack> {
+    let tagged = cut_below_min_score(tagged, args.min_score, |r| unified_score(&r.result));
     if let Some(budget) = args.tokens {
         let embedder = ctx.embedder()?;
         Ok(token_pack_results(
@@ -1622,6 +2538,31 @@ fn pack_tagged_cli(
     }
 }
 
+/// `--min-score`: drop results scoring below the floor, keeping rank order.
+/// The best score in `results` anchors the percentage form.
+pub(crate) fn cut_below_min_score<T>(
+    mut results: Vec<T>,
+    min_score: Option<crate::cli::MinScore>,
+    score_fn: impl Fn(&T) -> f32,
+) -> Vec<T> {
+    let Some(min_score) = min_score else {
+        return results;
+    };
+    let Some(top) = results.iter().map(&score_fn).reduce(f32::max) else {
+        return results;
+    };
+    let floor = min_score.floor(top);
+    let before = results.len();
+    results.retain(|r| score_fn(r) >= floor);
+    tracing::debug!(
+        floor,
+        kept = results.len(),
+        dropped = before - results.len(),
+        "Applied --min-score"
+    );
+    results
+}
+
 // token_pack_results lives in crate::cli::commands
 use crate::cli::commands::token_pack_results;
 
@@ -1720,6 +2661,63 @@ fn rerank_tagged(
         .collect())
 }
 
+/// `--at <rev>` search: the `--ref` retrieval path pointed at the snapshot
+/// index of one commit. Like `--ref`, there is no staleness warning or parent
+/// context — the snapshot's files are not the working tree's.
+fn cmd_query_at(
+    ctx: &crate::cli::CommandContext<'_, cqs::store::ReadOnly>,
+    query: &str,
+    rev: &str,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_query_at", rev).entered();
+    let cli = ctx.cli;
+    let root = &ctx.root;
+    let snapshot = super::snapshot::open_snapshot(ctx, rev)?;
+    let label = super::snapshot::snapshot_label(rev);
+
+    let args = QueryArgs::from_cli_ref(cli, false);
+    let tagged = if cli.name_only {
+        if cli.rerank_active() {
+            bail!("--rerank requires embedding search, incompatible with --name-only");
+        }
+        reference::search_reference_by_name(&snapshot, query, cli.limit, cli.threshold, false)?
+            .into_iter()
+            .map(|r| reference::TaggedResult {
+                result: UnifiedResult::Code(r),
+                source: Some(label.clone()),
+            })
+            .collect()
+    } else {
+        let prepared = match prepare_query(ctx, &args, ProjectSurface::Skip)? {
+            // `from_cli_ref` disables `fts_first` under `--at`, so the
+            // project-store short-circuit cannot fire here.
+            Prepared::ShortCircuit(_) => {
+                bail!("internal error: name-only short-circuit on a snapshot search")
+            }
+            Prepared::Dense(p) => p,
+        };
+        retrieve_from_reference(&snapshot, &args, &prepared, &label)?
+    };
+
+    let (tagged, token_info) = pack_tagged_cli(ctx, &args, tagged)?;
+    if tagged.is_empty() {
+        emit_empty_results(query, cli.json, Some(label.as_str()));
+    }
+    if cli.json {
+        display::display_tagged_results_json(&tagged, query, None, token_info, cli.snippet_lines)?;
+    } else {
+        display::display_tagged_results(
+            &tagged,
+            root,
+            cli.no_content,
+            cli.context,
+            cli.snippet_lines,
+            None,
+        )?;
+    }
+    Ok(())
+}
+
 /// Ref-scoped name-only search: search only the named reference by name
 fn cmd_query_ref_name_only(
     cli: &Cli,
@@ -1764,30 +2762,99 @@ fn cmd_query_ref_name_only(
     }
 
     if cli.json {
-        display::display_tagged_results_json(&tagged, query, None, token_info)?;
+        display::display_tagged_results_json(&tagged, query, None, token_info, cli.snippet_lines)?;
     } else {
-        display::display_tagged_results(&tagged, root, cli.no_content, cli.context, None)?;
+        display::display_tagged_results(
+            &tagged,
+            root,
+            cli.no_content,
+            cli.context,
+            cli.snippet_lines,
+            None,
+        )?;
     }
 
     Ok(())
 }
 
-/// Resolve parent context for results with parent_id.
+/// Batch-fetch the enclosing container (v35) of each code result, keyed by
+/// chunk id. A lookup failure degrades to "no containers" — parent context is
+/// best-effort, same as the parent fetch below.
+fn result_containers<Mode>(
+    results: &[UnifiedResult],
+    store: &Store<Mode>,
+) -> HashMap<String, String> {
+    let ids: Vec<&str> = results
+        .iter()
+        .map(|r| {
+            let UnifiedResult::Code(sr) = r;
+            sr.chunk.id.as_str()
+        })
+        .collect();
+    store.get_container_ids(&ids).unwrap_or_else(|e| {
+        tracing::warn!(error = %e, "Failed to fetch chunk containers");
+        HashMap::new()
+    })
+}
+
+/// Drop results that directly contain another result, keeping the most
+/// specific hit. The survivor reaches the dropped container as its parent
+/// context. Order of the remaining results is unchanged.
+fn fold_contained_hits(
+    results: Vec<UnifiedResult>,
+    containers: &HashMap<String, String>,
+) -> Vec<UnifiedResult> {
+    let present: std::collections::HashSet<&str> = results
+        .iter()
+        .map(|r| {
+            let UnifiedResult::Code(sr) = r;
+            sr.chunk.id.as_str()
+        })
+        .collect();
+    let covered: std::collections::HashSet<String> = containers
+        .iter()
+        .filter(|(child, _)| present.contains(child.as_str()))
+        .map(|(_, container)| container.clone())
+        .collect();
+    if covered.is_empty() {
+        return results;
+    }
+    results
+        .into_iter()
+        .filter(|r| {
+            let UnifiedResult::Code(sr) = r;
+            !covered.contains(&sr.chunk.id)
+        })
+        .collect()
+}
+
+/// Resolve parent context for results with parent_id or a container.
 ///
 /// For table chunks: parent is a stored section chunk → fetch from DB.
 /// For windowed chunks: parent was never stored → read source file at line range.
+/// For methods (v35): parent is the enclosing impl/class container → fetch from DB.
 fn resolve_parent_context<Mode>(
     results: &[UnifiedResult],
     store: &Store<Mode>,
     root: &std::path::Path,
+    containers: &HashMap<String, String>,
 ) -> HashMap<String, ParentContext> {
     let mut parents = HashMap::new();
 
-    // Collect unique parent_ids from code results
+    // A window/table `parent_id` wins; otherwise the enclosing container
+    // (method → impl/class) is the parent.
+    let parent_of = |sr: &cqs::store::SearchResult| -> Option<String> {
+        sr.chunk
+            .parent_id
+            .clone()
+            .or_else(|| containers.get(&sr.chunk.id).cloned())
+    };
+
+    // Collect unique parent ids from code results
     let parent_ids: Vec<String> = results
         .iter()
         .filter_map(|r| match r {
-            UnifiedResult::Code(sr) => sr.chunk.parent_id.clone(),
+            UnifiedResult::Code(sr) => parent_of(sr),
         })
         .collect::<std::collections::HashSet<_>>()
         .into_iter()
@@ -1811,20 +2878,22 @@ fn resolve_parent_context<Mode>(
     let mut resolved_parents: HashMap<String, ParentContext> = HashMap::new();
     for result in results {
         let UnifiedResult::Code(sr) = result;
-        let parent_id = match &sr.chunk.parent_id {
+        let parent_id = match parent_of(sr) {
             Some(id) => id,
             None => continue,
         };
 
         // Reuse cached ParentContext if this parent was already resolved
-        if let Some(cached) = resolved_parents.get(parent_id) {
+        if let Some(cached) = resolved_parents.get(&parent_id) {
             parents.insert(sr.chunk.id.clone(), cached.clone());
             continue;
         }
 
-        if let Some(parent) = stored_parents.get(parent_id) {
-            // Parent found in DB (table chunk → section parent)
+        if let Some(parent) = stored_parents.get(&parent_id) {
+            // Parent found in DB (table chunk → section parent, method →
+            // container)
             let ctx = ParentContext {
+                id: parent_id.clone(),
                 name: parent.name.clone(),
                 content: parent.content.clone(),
                 line_start: parent.line_start,
@@ -1832,6 +2901,9 @@ fn resolve_parent_context<Mode>(
             };
             resolved_parents.insert(parent_id.clone(), ctx.clone());
             parents.insert(sr.chunk.id.clone(), ctx);
+        } else if sr.chunk.parent_id.is_none() {
+            // Container vanished between lookups (concurrent reindex).
+            continue;
         } else {
             // Parent not in DB (windowed chunk → read source file)
             // RT-FS-1: Validate the resolved path stays within project root
@@ -1870,6 +2942,7 @@ fn resolve_parent_context<Mode>(
                     if start < end {
                         let parent_content = lines[start..end].join("\n");
                         let ctx = ParentContext {
+                            id: parent_id.clone(),
                             name: sr.chunk.name.clone(),
                             content: parent_content,
                             line_start: sr.chunk.line_start,
@@ -2234,6 +3307,8 @@ mod tests {
                 parent_type_name: None,
                 parser_version: 0,
                 vendored: false,
+                deprecated: false,
+                boilerplate: None,
             };
             UnifiedResult::Code(cqs::store::SearchResult::new(summary, score))
         }
@@ -2468,6 +3543,7 @@ mod tests {
             "splade": true,
             "splade_alpha": 0.5,
             "threshold": 0.1,
+            "min_score": "60%",
             "name_boost": 0.7,
             "no_demote": true,
             "tokens": 4000,
@@ -2494,6 +3570,7 @@ mod tests {
         assert!(args.rerank);
         assert!(args.splade);
         assert_eq!(args.splade_alpha, Some(0.5));
+        assert_eq!(args.min_score, Some(crate::cli::MinScore::OfTop(0.6)));
         assert_eq!(args.tokens, Some(4000));
         assert!(args.expand_parent);
         assert!(args.force_base_index);
@@ -2557,6 +3634,69 @@ mod tests {
         assert_eq!(ref_args, QueryArgs::from_cli(&cli, false));
     }
 
+    /// `--min-score` keeps results at or above the floor, in rank order; the
+    /// percentage form is relative to the best score.
+    #[test]
+    fn min_score_cuts_the_tail() {
+        use crate::cli::MinScore;
+        let scores = vec![0.82_f32, 0.79, 0.41, 0.5, 0.12];
+        let cut = |m| cut_below_min_score(scores.clone(), m, |s| *s);
+        assert_eq!(cut(None), scores);
+        assert_eq!(cut(Some(MinScore::Absolute(0.45))), [0.82, 0.79, 0.5]);
+        assert_eq!(cut(Some(MinScore::OfTop(0.5))), [0.82, 0.79, 0.41, 0.5]);
+        assert_eq!(cut(Some(MinScore::Absolute(0.9))), Vec::<f32>::new());
+        assert_eq!("60%".parse::<MinScore>(), Ok(MinScore::OfTop(0.6)));
+        assert_eq!("0.45".parse::<MinScore>(), Ok(MinScore::Absolute(0.45)));
+        assert_eq!(MinScore::OfTop(0.6).to_string(), "60%");
+        assert!("150%".parse::<MinScore>().is_err());
+        assert!("NaN".parse::<MinScore>().is_err());
+    }
+
+    /// `--relax` widens cumulatively — path, then types, then floors — and
+    /// skips a step with nothing set to drop.
+    #[test]
+    fn relaxation_steps_drop_filters_cumulatively() {
+        let args = QueryArgs {
+            path: Some("src/**".into()),
+            include_type: Some(vec!["function".into()]),
+            min_score: Some(crate::cli::MinScore::OfTop(0.6)),
+            relax: true,
+            ..QueryArgs::default()
+        };
+        let steps = relaxation_steps(&args);
+        let dropped: Vec<Vec<String>> = steps
+            .iter()
+            .map(|(_, d)| d.iter().map(ToString::to_string).collect())
+            .collect();
+        assert_eq!(
+            dropped,
+            [
+                vec!["--path src/**"],
+                vec!["--path src/**", "--include-type function"],
+                vec![
+                    "--path src/**",
+                    "--include-type function",
+                    "--threshold 0.3",
+                    "--min-score 60%"
+                ],
+            ]
+        );
+        let (last, _) = &steps[2];
+        assert_eq!(
+            (last.path.as_deref(), last.include_type.as_ref()),
+            (None, None)
+        );
+        assert_eq!(
+            (last.threshold, last.min_score, last.relax),
+            (0.0, None, false)
+        );
+
+        // Defaults set only the similarity threshold.
+        let steps = relaxation_steps(&QueryArgs::default());
+        assert_eq!(steps.len(), 1);
+        assert_eq!(steps[0].1[0].to_string(), "--threshold 0.3");
+    }
+
     /// The `QueryOutput` envelope is a plain data carrier: an empty result set
     /// is a valid output (the adapter, not the core, maps it to NoResults).
     #[test]
@@ -2565,13 +3705,70 @@ mod tests {
             query: "nothing".to_string(),
             results: Vec::new(),
             parents: HashMap::new(),
+            tests: HashMap::new(),
+            owners: HashMap::new(),
+            issue_refs: HashMap::new(),
+            commits: HashMap::new(),
             token_info: None,
+            calibration: None,
+            relaxed: Vec::new(),
         };
         assert!(out.results.is_empty());
         assert_eq!(out.query, "nothing");
         assert!(out.token_info.is_none());
     }
 
+    /// v35: a class hit is folded when one of its methods also matched; an
+    /// unrelated hit and the method keep their order.
+    #[test]
+    fn fold_contained_hits_keeps_most_specific() {
+        use cqs::parser::Language;
+        use cqs::store::{ChunkSummary, SearchResult};
+
+        let hit = |id: &str, chunk_type: ChunkType, score: f32| {
+            UnifiedResult::Code(SearchResult::new(
+                ChunkSummary {
+                    id: id.to_string(),
+                    file: std::path::PathBuf::from("src/a.rs"),
+                    language: Language::Rust,
+                    chunk_type,
+                    name: id.to_string(),
+                    signature: String::new(),
+                    content: String::new(),
+                    doc: None,
+                    line_start: 1,
+                    line_end: 2,
+                    content_hash: id.to_string(),
+                    window_idx: None,
+                    parent_id: None,
+                    parent_type_name: None,
+                    parser_version: 0,
+                    vendored: false,
+                    deprecated: false,
+                    boilerplate: None,
+                },
+                score,
+            ))
+        };
+        let results = vec![
+            hit("Parser", ChunkType::Impl, 0.9),
+            hit("parse", ChunkType::Method, 0.8),
+            hit("helper", ChunkType::Function, 0.7),
+        ];
+        let containers = HashMap::from([
+            ("parse".to_string(), "Parser".to_string()),
+            ("Other".to_string(), "helper".to_string()),
+        ]);
+        let ids: Vec<String> = fold_contained_hits(results, &containers)
+            .into_iter()
+            .map(|r| {
+                let UnifiedResult::Code(sr) = r;
+                sr.chunk.id
+            })
+            .collect();
+        assert_eq!(ids, vec!["parse", "helper"]);
+    }
+
     // ─── Overlay activation resolution (the default-on flip) ─────────────────
     //
     // Hermetic precedence tests over the pure `resolve_overlay_active_with`
@@ -2685,6 +3882,8 @@ mod tests {
                 parent_type_name: None,
                 parser_version: 0,
                 vendored: false,
+                deprecated: false,
+                boilerplate: None,
             };
             SearchResult::new(summary, score)
         }
@@ -3438,6 +4637,105 @@ mod tests {
             );
         }
     }
+
+    /// `--explain` names the rule and its reason for each boosted result,
+    /// and says nothing about results no rule covers.
+    #[test]
+    fn boost_explain_lines_name_the_rule() {
+        let rules = cqs::boosts::BoostRules::parse(
+            "[[deprecate]]\npath = \"legacy\"\nreason = \"replaced by src/net\"\n",
+            std::path::Path::new(cqs::boosts::BOOSTS_FILENAME),
+        )
+        .unwrap();
+        let chunk = |file: &str, name: &str| cqs::store::ChunkSummary {
+            id: format!("{file}:1:{name}"),
+            file: std::path::PathBuf::from(file),
+            language: cqs::parser::Language::Rust,
+            chunk_type: cqs::parser::ChunkType::Function,
+            name: name.to_string(),
+            signature: String::new(),
+            content: String::new(),
+            doc: None,
+            line_start: 3,
+            line_end: 9,
+            content_hash: String::new(),
+            window_idx: None,
+            parent_id: None,
+            parent_type_name: None,
+            parser_version: 0,
+            vendored: false,
+            deprecated: false,
+            boilerplate: None,
+        };
+        let results = vec![
+            UnifiedResult::Code(cqs::store::SearchResult::new(
+                chunk("src/net.rs", "retry"),
+                0.9,
+            )),
+            UnifiedResult::Code(cqs::store::SearchResult::new(
+                chunk("legacy/net.rs", "old_retry"),
+                0.4,
+            )),
+        ];
+        let lines = boost_explain_lines(&rules, "retry", &results, std::path::Path::new("/"));
+        assert_eq!(
+            lines,
+            ["Boost: legacy/net.rs:3 old_retry — deprecate x0.6 (replaced by src/net)"]
+        );
+    }
+
+    /// `--explain` prints each facet cosine of the results that blended
+    /// them, and nothing for results scored on the whole vector alone.
+    #[test]
+    fn facet_explain_lines_list_the_cosines() {
+        let chunk = |file: &str, name: &str| cqs::store::ChunkSummary {
+            id: format!("{file}:1:{name}"),
+            file: std::path::PathBuf::from(file),
+            language: cqs::parser::Language::Rust,
+            chunk_type: cqs::parser::ChunkType::Function,
+            name: name.to_string(),
+            signature: String::new(),
+            content: String::new(),
+            doc: None,
+            line_start: 40,
+            line_end: 52,
+            content_hash: String::new(),
+            window_idx: None,
+            parent_id: None,
+            parent_type_name: None,
+            parser_version: 0,
+            vendored: false,
+            deprecated: false,
+            boilerplate: None,
+        };
+        let mut blended = cqs::store::SearchResult::new(chunk("src/net.rs", "retry"), 0.7);
+        blended.rank_signals = vec![
+            cqs::store::RankSignal {
+                signal: "dense",
+                value: 1.0,
+            },
+            cqs::store::RankSignal {
+                signal: "facet_whole",
+                value: 0.614,
+            },
+            cqs::store::RankSignal {
+                signal: "facet_name",
+                value: 0.82,
+            },
+        ];
+        let results = vec![
+            UnifiedResult::Code(blended),
+            UnifiedResult::Code(cqs::store::SearchResult::new(
+                chunk("src/io.rs", "read"),
+                0.5,
+            )),
+        ];
+        let lines = facet_explain_lines(&results, std::path::Path::new("/"));
+        assert_eq!(
+            lines,
+            ["Facets: src/net.rs:40 retry — whole 0.61, name 0.82"]
+        );
+    }
 }
 
 // ─── MCP Phase 0: JsonSchema smoke test ─────────────────────────────────────
@@ -3565,12 +4863,13 @@ mod jsonschema_smoke {
         }
 
         // CallersArgs.edge_kind → CallEdgeKind in snake_case (`serde_callback`,
-        // `macro_heuristic`, `fn_pointer`, `doc_reference`) — the stored / wire
-        // spelling `parse_edge_kind` accepts.
+        // `ffi_boundary`, `macro_heuristic`, `fn_pointer`, `doc_reference`) —
+        // the stored / wire spelling `parse_edge_kind` accepts.
         let callers = serde_json::to_value(schemars::schema_for!(CallersCoreArgs)).unwrap();
         let cvalues = collect_enum_values(&callers);
         for want in [
             "serde_callback",
+            "ffi_boundary",
             "macro_heuristic",
             "fn_pointer",
             "doc_reference",
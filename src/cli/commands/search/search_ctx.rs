@@ -150,6 +150,20 @@ pub(crate) trait SearchCtx {
     fn overlay(&self) -> Option<Arc<cqs::worktree_overlay::WorktreeOverlay>> {
         None
     }
+
+    /// The shard stores of a sharded index (`[index.shards]`), searched
+    /// alongside [`store`](Self::store), which holds the root shard. Empty
+    /// when the index is not sharded.
+    ///
+    /// Default: open every shard under [`cqs_dir`](Self::cqs_dir) per call,
+    /// which is what a one-shot CLI query wants. The daemon overrides this
+    /// with a cache that reloads a shard only when its `index.db` changes.
+    fn shards(&self) -> Vec<Arc<ReferenceIndex>> {
+        cqs::shard::load_shards(self.cqs_dir(), self.store().dim())
+            .into_iter()
+            .map(Arc::new)
+            .collect()
+    }
 }
 
 // ─── CLI adapter ────────────────────────────────────────────────────────────
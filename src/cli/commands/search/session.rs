@@ -0,0 +1,453 @@
+//! `cqs session` — save, name, and reopen search sessions.
+//!
+//! Searches land in the working session automatically (see
+//! [`cqs::session`]); `pin` / `unpin` manage its pinned chunks. `save <name>`
+//! snapshots it under `.cqs/sessions/`, `open <name>` makes a saved session
+//! the working one again and prints its queries and pinned chunks —
+//! `--replay` re-runs the queries, `--chat` drops into `cqs chat` with them
+//! in the up-arrow history.
+
+use anyhow::{bail, Context as _, Result};
+use clap::{Parser as _, Subcommand};
+use serde::Serialize;
+
+use cqs::session::{
+    list_sessions, session_path, update_working_session, working_session_path, PinnedChunk,
+    Session, SessionError,
+};
+use cqs::store::ReadOnly;
+
+use crate::cli::definitions::TextJsonArgs;
+use crate::cli::CommandContext;
+
+#[derive(Subcommand, Clone, Debug)]
+pub(crate) enum SessionCommand {
+    /// Save the working session under a name
+    Save {
+        /// Session name (letters, digits, `_`, `-`, `.`; max 64 chars)
+        name: String,
+        /// Overwrite an existing session of that name
+        #[arg(long)]
+        force: bool,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
+    /// Make a saved session the working one and show it
+    Open {
+        /// Session name
+        name: String,
+        /// Re-run every query and show its results
+        #[arg(long)]
+        replay: bool,
+        /// Start `cqs chat` with the session's queries in the history
+        #[arg(long, conflicts_with = "replay")]
+        chat: bool,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
+    /// Show a saved session, or the working one without a name
+    Show {
+        name: Option<String>,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
+    /// List saved sessions
+    List {
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
+    /// Pin a chunk (name, `file:name`, or chunk id) to the working session
+    Pin {
+        target: String,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
+    /// Remove a pinned chunk from the working session
+    Unpin {
+        target: String,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
+    /// Delete a saved session
+    Delete {
+        name: String,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
+    /// Empty the working session (queries and pins)
+    Clear {
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
+}
+
+impl SessionCommand {
+    fn json(&self) -> bool {
+        match self {
+            SessionCommand::Save { output, .. }
+            | SessionCommand::Open { output, .. }
+            | SessionCommand::Show { output, .. }
+            | SessionCommand::List { output }
+            | SessionCommand::Pin { output, .. }
+            | SessionCommand::Unpin { output, .. }
+            | SessionCommand::Delete { output, .. }
+            | SessionCommand::Clear { output } => output.json,
+        }
+    }
+}
+
+/// A pinned chunk resolved against the current index.
+#[derive(Debug, Serialize)]
+struct PinnedView {
+    origin: String,
+    name: String,
+    /// `false` when the chunk no longer resolves (renamed or deleted)
+    found: bool,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    line_start: Option<u32>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    line_end: Option<u32>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    signature: Option<String>,
+}
+
+/// One replayed query: the batch `search` JSON, or the error it raised.
+#[derive(Debug, Serialize)]
+struct ReplayEntry {
+    query: String,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    args: Vec<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    results: Option<serde_json::Value>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    error: Option<String>,
+}
+
+/// `cqs session open|show --json` payload.
+#[derive(Debug, Serialize)]
+struct SessionView {
+    name: Option<String>,
+    updated_at: i64,
+    queries: Vec<cqs::session::SessionQuery>,
+    pinned: Vec<PinnedView>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    replay: Option<Vec<ReplayEntry>>,
+}
+
+/// `cqs session list --json` row.
+#[derive(Debug, Serialize)]
+struct SessionListEntry {
+    name: String,
+    updated_at: i64,
+    queries: usize,
+    pinned: usize,
+}
+
+pub(crate) fn cmd_session(
+    ctx: &CommandContext<'_, ReadOnly>,
+    subcmd: &SessionCommand,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_session").entered();
+    let json = ctx.cli.json || subcmd.json();
+    let dir = &ctx.project_cqs_dir;
+    let now = cqs::unix_secs_i64().unwrap_or(0);
+
+    match subcmd {
+        SessionCommand::Save { name, force, .. } => {
+            let path = session_path(dir, name)?;
+            if path.exists() && !force {
+                return Err(SessionError::Exists(name.clone()))
+                    .context("pass --force to overwrite it");
+            }
+            let mut session = load_working(ctx)?;
+            session.name = Some(name.clone());
+            session.save(&path)?;
+            update_working_session(dir, |s| s.name = Some(name.clone()))?;
+            if json {
+                crate::cli::json_envelope::emit_json(&serde_json::json!({
+                    "name": name,
+                    "path": path.display().to_string(),
+                    "queries": session.queries.len(),
+                    "pinned": session.pinned.len(),
+                }))?;
+            } else {
+                println!(
+                    "Saved session '{name}' ({} queries, {} pinned) to {}",
+                    session.queries.len(),
+                    session.pinned.len(),
+                    path.display()
+                );
+            }
+        }
+        SessionCommand::Open {
+            name, replay, chat, ..
+        } => {
+            let session = load_saved(ctx, name)?;
+            session.save(&working_session_path(dir))?;
+            if *chat {
+                print_session(&view(ctx, &session, None));
+                let seed: Vec<String> = session
+                    .queries
+                    .iter()
+                    .map(|q| shell_words::join(search_tokens(q)))
+                    .collect();
+                return crate::cli::chat::cmd_chat_seeded(&seed);
+            }
+            let replayed = if *replay {
+                Some(replay_queries(&session)?)
+            } else {
+                None
+            };
+            let v = view(ctx, &session, replayed);
+            if json {
+                crate::cli::json_envelope::emit_json(&v)?;
+            } else {
+                print_session(&v);
+            }
+        }
+        SessionCommand::Show { name, .. } => {
+            let session = match name {
+                Some(name) => load_saved(ctx, name)?,
+                None => load_working(ctx)?,
+            };
+            let v = view(ctx, &session, None);
+            if json {
+                crate::cli::json_envelope::emit_json(&v)?;
+            } else {
+                print_session(&v);
+            }
+        }
+        SessionCommand::List { .. } => {
+            let rows: Vec<SessionListEntry> = list_sessions(dir)?
+                .into_iter()
+                .map(|(name, s)| SessionListEntry {
+                    name,
+                    updated_at: s.updated_at,
+                    queries: s.queries.len(),
+                    pinned: s.pinned.len(),
+                })
+                .collect();
+            if json {
+                crate::cli::json_envelope::emit_json(&rows)?;
+            } else if rows.is_empty() {
+                println!("No saved sessions. Save the working one with `cqs session save <name>`.");
+            } else {
+                for r in &rows {
+                    println!(
+                        "{:<24} {:>3} queries {:>3} pinned",
+                        r.name, r.queries, r.pinned
+                    );
+                }
+            }
+        }
+        SessionCommand::Pin { target, .. } => {
+            let chunk = resolve_chunk(ctx, target)?;
+            let pin = PinnedChunk {
+                origin: cqs::normalize_path(&chunk.file),
+                name: chunk.name.clone(),
+                id: chunk.id.clone(),
+                pinned_at: now,
+            };
+            let added = update_working_session(dir, |s| s.pin(pin.clone()))?;
+            if json {
+                crate::cli::json_envelope::emit_json(&serde_json::json!({
+                    "pinned": pin,
+                    "added": added,
+                }))?;
+            } else if added {
+                println!("Pinned {} ({})", pin.name, pin.origin);
+            } else {
+                println!("{} ({}) is already pinned", pin.name, pin.origin);
+            }
+        }
+        SessionCommand::Unpin { target, .. } => {
+            let working = load_working(ctx)?;
+            // Match the stored pins first, so a pin whose chunk is gone from
+            // the index can still be removed by `file:name` or bare name.
+            let key = working
+                .pinned
+                .iter()
+                .find(|p| p.target() == *target || p.name == *target || p.id == *target)
+                .map(|p| (p.origin.clone(), p.name.clone()));
+            let (origin, name) = match key {
+                Some(key) => key,
+                None => {
+                    let chunk = resolve_chunk(ctx, target)?;
+                    (cqs::normalize_path(&chunk.file), chunk.name)
+                }
+            };
+            let removed = update_working_session(dir, |s| s.unpin(&origin, &name, now))?;
+            if json {
+                crate::cli::json_envelope::emit_json(&serde_json::json!({
+                    "origin": origin,
+                    "name": name,
+                    "removed": removed,
+                }))?;
+            } else if removed {
+                println!("Unpinned {name} ({origin})");
+            } else {
+                println!("{name} ({origin}) was not pinned");
+            }
+        }
+        SessionCommand::Delete { name, .. } => {
+            let path = session_path(dir, name)?;
+            if !path.exists() {
+                bail!(SessionError::NotFound(name.clone()));
+            }
+            std::fs::remove_file(&path)
+                .with_context(|| format!("Failed to delete {}", path.display()))?;
+            if json {
+                crate::cli::json_envelope::emit_json(&serde_json::json!({ "deleted": name }))?;
+            } else {
+                println!("Deleted session '{name}'");
+            }
+        }
+        SessionCommand::Clear { .. } => {
+            Session::default().save(&working_session_path(dir))?;
+            if json {
+                crate::cli::json_envelope::emit_json(&serde_json::json!({ "cleared": true }))?;
+            } else {
+                println!("Working session cleared");
+            }
+        }
+    }
+    Ok(())
+}
+
+fn load_working(ctx: &CommandContext<'_, ReadOnly>) -> Result<Session> {
+    Ok(Session::load(&working_session_path(&ctx.project_cqs_dir))?.unwrap_or_default())
+}
+
+fn load_saved(ctx: &CommandContext<'_, ReadOnly>, name: &str) -> Result<Session> {
+    Session::load(&session_path(&ctx.project_cqs_dir, name)?)?
+        .ok_or_else(|| SessionError::NotFound(name.to_string()).into())
+}
+
+/// Resolve a pin target: an exact chunk id first, then anything
+/// `resolve_target` accepts (`name`, `file:name`).
+fn resolve_chunk(
+    ctx: &CommandContext<'_, ReadOnly>,
+    target: &str,
+) -> Result<cqs::store::ChunkSummary> {
+    if let Some(chunk) = ctx.store.get_chunks_by_ids(&[target])?.remove(target) {
+        return Ok(chunk);
+    }
+    Ok(cqs::resolve_target(&ctx.store, target)?.chunk)
+}
+
+/// `search <query> <flags…>` — the batch / chat spelling of a session query.
+fn search_tokens(q: &cqs::session::SessionQuery) -> Vec<&str> {
+    let mut tokens = vec!["search", q.query.as_str()];
+    tokens.extend(q.args.iter().map(String::as_str));
+    tokens
+}
+
+/// Re-run every query through the batch `search` handler — the same parser
+/// and core `cqs chat` uses, so flags round-trip exactly.
+fn replay_queries(session: &Session) -> Result<Vec<ReplayEntry>> {
+    let _span = tracing::info_span!("session_replay", n = session.queries.len()).entered();
+    let batch_ctx =
+        std::sync::Arc::new(std::sync::Mutex::new(crate::cli::batch::create_context()?));
+    let view = crate::cli::batch::checkout_view_from_arc(&batch_ctx);
+    Ok(session
+        .queries
+        .iter()
+        .map(|q| {
+            let outcome = crate::cli::batch::BatchInput::try_parse_from(search_tokens(q))
+                .map_err(anyhow::Error::from)
+                .and_then(|input| crate::cli::batch::dispatch(&view, input.cmd));
+            let (results, error) = match outcome {
+                Ok(value) => (Some(value), None),
+                Err(e) => (None, Some(e.to_string())),
+            };
+            ReplayEntry {
+                query: q.query.clone(),
+                args: q.args.clone(),
+                results,
+                error,
+            }
+        })
+        .collect())
+}
+
+fn view(
+    ctx: &CommandContext<'_, ReadOnly>,
+    session: &Session,
+    replay: Option<Vec<ReplayEntry>>,
+) -> SessionView {
+    let pinned = session
+        .pinned
+        .iter()
+        .map(|p| match cqs::resolve_target(&ctx.store, &p.target()) {
+            Ok(resolved) => PinnedView {
+                origin: p.origin.clone(),
+                name: p.name.clone(),
+                found: true,
+                line_start: Some(resolved.chunk.line_start),
+                line_end: Some(resolved.chunk.line_end),
+                signature: Some(resolved.chunk.signature),
+            },
+            Err(_) => PinnedView {
+                origin: p.origin.clone(),
+                name: p.name.clone(),
+                found: false,
+                line_start: None,
+                line_end: None,
+                signature: None,
+            },
+        })
+        .collect();
+    SessionView {
+        name: session.name.clone(),
+        updated_at: session.updated_at,
+        queries: session.queries.clone(),
+        pinned,
+        replay,
+    }
+}
+
+fn print_session(v: &SessionView) {
+    println!(
+        "Session: {}",
+        v.name.as_deref().unwrap_or("(working, unsaved)")
+    );
+    if v.queries.is_empty() {
+        println!("  no queries");
+    }
+    for (i, q) in v.queries.iter().enumerate() {
+        println!("  {:>2}. {}", i + 1, shell_words::join(search_tokens(q)));
+    }
+    if !v.pinned.is_empty() {
+        println!("Pinned:");
+    }
+    for p in &v.pinned {
+        match (p.found, p.line_start, p.line_end) {
+            (true, Some(start), Some(end)) => {
+                println!("  {}:{}-{}  {}", p.origin, start, end, p.name)
+            }
+            _ => println!("  {}  {}  (no longer in the index)", p.origin, p.name),
+        }
+    }
+    for r in v.replay.iter().flatten() {
+        println!();
+        println!("== {}", r.query);
+        if let Some(e) = &r.error {
+            println!("  error: {e}");
+            continue;
+        }
+        let results = r
+            .results
+            .as_ref()
+            .and_then(|v| v.get("results"))
+            .and_then(|v| v.as_array());
+        for hit in results.into_iter().flatten() {
+            println!(
+                "  {}:{}  {}  ({:.3})",
+                hit["file"].as_str().unwrap_or("?"),
+                hit["line_start"].as_u64().unwrap_or(0),
+                hit["name"].as_str().unwrap_or("?"),
+                hit["score"].as_f64().unwrap_or(0.0)
+            );
+        }
+    }
+}
@@ -1,4 +1,4 @@
-//! Similar command — find code similar to a given function
+//! Similar command — find code similar to a given function or chunk
 //!
 //! Core retrieval logic lives in [`similar_core`] so the CLI (`cmd_similar`)
 //! and the batch/daemon handler (`dispatch_similar` in
@@ -26,12 +26,14 @@ use crate::cli::display;
 #[derive(Debug, Clone, PartialEq, serde::Deserialize, schemars::JsonSchema)]
 #[serde(default)]
 pub(crate) struct SimilarArgs {
-    /// Function name or `file:function`.
+    /// Function name, `file:function`, `file:line`, or a chunk id.
     pub name: String,
     /// Cap on results returned (clamped to `SIMILAR_LIMIT_MAX` in the core).
     pub limit: usize,
     /// Min similarity threshold.
     pub threshold: f32,
+    /// Keep neighbors from the target's own file (dropped by default).
+    pub same_file: bool,
 }
 
 impl Default for SimilarArgs {
@@ -42,6 +44,7 @@ impl Default for SimilarArgs {
             limit: crate::cli::args::DEFAULT_LIMIT,
             // Mirrors clap `--threshold` default in `args::SimilarArgs`.
             threshold: 0.3,
+            same_file: false,
         }
     }
 }
@@ -57,6 +60,8 @@ impl Default for SimilarArgs {
 pub(crate) struct SimilarOutput {
     /// Resolved name of the queried chunk.
     pub target: String,
+    /// File of the queried chunk.
+    pub target_file: String,
     /// Matched chunks, nearest first, self-match excluded.
     pub results: Vec<serde_json::Value>,
     /// Number of results returned.
@@ -74,6 +79,8 @@ pub(crate) struct SimilarOutput {
 pub(crate) struct SimilarMatches {
     /// Resolved name of the queried chunk.
     pub target: String,
+    /// File of the queried chunk.
+    pub target_file: std::path::PathBuf,
     /// Matched chunks, nearest first, self-match excluded, truncated to limit.
     pub results: Vec<SearchResult>,
 }
@@ -105,11 +112,15 @@ pub(crate) fn build_similar_filter(lang: Option<&str>, path: Option<&str>) -> Re
 
 // ─── Core ───────────────────────────────────────────────────────────────────
 
-/// Surface-agnostic core for `cqs similar <name>`.
+/// Over-fetch factor when same-file neighbors are excluded.
+const SAME_FILE_OVERFETCH: usize = 3;
+
+/// Surface-agnostic core for `cqs similar <target>`.
 ///
-/// Resolves the target via the shared [`cqs::resolve_target`] helper, loads its
-/// embedding, runs the index-guided search (requesting one extra to exclude the
-/// self-match), drops the source chunk, and truncates to the clamped limit. The
+/// Resolves the target via the shared [`cqs::resolve_chunk_target`] helper (a
+/// name, `file:line`, or chunk id), loads its embedding, runs the index-guided
+/// search, drops the source chunk and — unless `same_file` — every other chunk
+/// from the source file, and truncates to the clamped limit. The
 /// vector index and filter are passed in rather than built internally so each
 /// adapter supplies its own (the CLI's concrete HNSW handle, the daemon's
 /// cached `dyn VectorIndex`) without the core knowing which surface it runs on.
@@ -130,7 +141,7 @@ pub(crate) fn similar_core(
     // search_filtered_with_index.
     let limit = args.limit.clamp(1, crate::cli::SIMILAR_LIMIT_MAX);
 
-    let resolved = cqs::resolve_target(store, &args.name)?;
+    let resolved = cqs::resolve_chunk_target(store, &args.name)?;
     let chunk_id = resolved.chunk.id.clone();
     let chunk_name = resolved.chunk.name.clone();
 
@@ -145,23 +156,27 @@ pub(crate) fn similar_core(
                 )
             })?;
 
-    // Request one extra to exclude the self-match below.
-    let results = store.search_filtered_with_index(
-        &embedding,
-        filter,
-        limit.saturating_add(1),
-        args.threshold,
-        index,
-    )?;
+    // Request one extra to exclude the self-match below. Siblings from the
+    // same file are usually the nearest neighbors of all, so over-fetch when
+    // they are going to be dropped too.
+    let fetch = if args.same_file {
+        limit.saturating_add(1)
+    } else {
+        limit.saturating_mul(SAME_FILE_OVERFETCH).saturating_add(1)
+    };
+    let results =
+        store.search_filtered_with_index(&embedding, filter, fetch, args.threshold, index)?;
 
     let filtered: Vec<SearchResult> = results
         .into_iter()
         .filter(|r| r.chunk.id != source_chunk.id)
+        .filter(|r| args.same_file || r.chunk.file != source_chunk.file)
         .take(limit)
         .collect();
 
     Ok(SimilarMatches {
         target: chunk_name,
+        target_file: source_chunk.file,
         results: filtered,
     })
 }
@@ -182,6 +197,7 @@ pub(crate) fn build_similar_output(matches: &SimilarMatches) -> SimilarOutput {
     let total = json_results.len();
     SimilarOutput {
         target: matches.target.clone(),
+        target_file: cqs::normalize_path(&matches.target_file),
         results: json_results,
         total,
     }
@@ -194,6 +210,7 @@ pub(crate) fn cmd_similar(
     name: &str,
     limit: usize,
     threshold: f32,
+    same_file: bool,
     lang: Option<&str>,
     path: Option<&str>,
     json: bool,
@@ -215,6 +232,7 @@ pub(crate) fn cmd_similar(
         name: name.to_string(),
         limit,
         threshold,
+        same_file,
     };
     let matches = similar_core(store, index.as_deref(), &filter, &args)?;
 
@@ -230,14 +248,10 @@ pub(crate) fn cmd_similar(
     }
 
     if !ctx.cli.quiet {
-        // The first result's source file isn't the target's — re-resolve for
-        // the header (cheap indexed lookup). Text mode only; the JSON path
-        // returns above without it.
-        let resolved = cqs::resolve_target(store, &matches.target)?;
         println!(
             "Similar to '{}' ({}):",
             matches.target,
-            resolved.chunk.file.display()
+            matches.target_file.display()
         );
         println!();
     }
@@ -247,7 +261,15 @@ pub(crate) fn cmd_similar(
         .into_iter()
         .map(cqs::store::UnifiedResult::Code)
         .collect();
-    display::display_unified_results(&unified, root, ctx.cli.no_content, ctx.cli.context, None)?;
+    display::display_unified_results(
+        &unified,
+        root,
+        ctx.cli.no_content,
+        ctx.cli.context,
+        ctx.cli.snippet_lines,
+        None,
+        display::ResultExtras::default(),
+    )?;
 
     Ok(())
 }
@@ -312,6 +334,7 @@ mod tests {
     fn similar_output_serializes() {
         let output = SimilarOutput {
             target: "my_func".to_string(),
+            target_file: "src/my_func.rs".to_string(),
             results: vec![serde_json::json!({"name": "near_fn", "score": 0.9})],
             total: 1,
         };
@@ -340,6 +363,8 @@ mod tests {
                 window_idx: None,
                 parser_version: 0,
                 vendored: false,
+                deprecated: false,
+                boilerplate: None,
             },
             score,
         )
@@ -351,6 +376,7 @@ mod tests {
     fn build_similar_output_carries_canonical_schema() {
         let matches = SimilarMatches {
             target: "my_target".to_string(),
+            target_file: std::path::PathBuf::from("src/my_target.rs"),
             results: vec![
                 make_search_result("alpha", 0.95, None),
                 make_search_result("beta", 0.80, Some("parent-1")),
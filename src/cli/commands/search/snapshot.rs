@@ -0,0 +1,143 @@
+//! `--at <rev>` snapshot resolution for time-travel search.
+//!
+//! Resolves a git revision to its commit, builds the commit's snapshot index
+//! on first use (see [`cqs::snapshot`]), and loads it as a read-only
+//! [`ReferenceIndex`] so the query path can search it exactly like `--ref`.
+
+use std::path::Path;
+use std::sync::Arc;
+
+use anyhow::{bail, Context, Result};
+
+use cqs::config::ReferenceConfig;
+use cqs::reference::{self, ReferenceIndex};
+use cqs::store::ReadOnly;
+use cqs::{ModelInfo, Parser as CqParser, Store};
+
+use crate::cli::commands::index::build_hnsw_index;
+use crate::cli::{enumerate_files, run_index_pipeline, CommandContext};
+
+/// Result label for snapshot hits: `@<rev>` as the user typed it, so text and
+/// JSON output show `[@v2.3.0]` rather than an opaque SHA.
+pub(crate) fn snapshot_label(rev: &str) -> String {
+    format!("@{rev}")
+}
+
+/// Resolve `rev`, building its snapshot index if this is the first query
+/// against that commit, and load it for searching.
+pub(crate) fn open_snapshot(
+    ctx: &CommandContext<'_, ReadOnly>,
+    rev: &str,
+) -> Result<ReferenceIndex> {
+    let _span = tracing::info_span!("open_snapshot", rev).entered();
+    let sha = cqs::snapshot::resolve_rev(&ctx.root, rev)?;
+    let dir = cqs::snapshot::snapshot_dir(&ctx.project_cqs_dir, &sha);
+
+    if !cqs::snapshot::is_complete(&dir) {
+        build_snapshot(ctx, rev, &sha, &dir)?;
+    }
+
+    let cfg = ReferenceConfig {
+        name: snapshot_label(rev),
+        path: dir.clone(),
+        source: None,
+        weight: 1.0,
+    };
+    reference::load_references(std::slice::from_ref(&cfg))
+        .into_iter()
+        .next()
+        .with_context(|| format!("Failed to open snapshot index at {}", dir.display()))
+}
+
+/// Build the snapshot for `sha` into a private staging directory, then rename
+/// it into place. The rename is the commit point: a crash mid-build leaves
+/// only a `.tmp-*` directory that no reader ever opens, and two concurrent
+/// `--at` queries for the same commit both build but only one rename lands —
+/// the loser discards its copy and uses the winner's.
+fn build_snapshot(
+    ctx: &CommandContext<'_, ReadOnly>,
+    rev: &str,
+    sha: &str,
+    dir: &Path,
+) -> Result<()> {
+    let _span = tracing::info_span!("build_snapshot", sha).entered();
+    let cli = ctx.cli;
+    let snapshots_root = dir
+        .parent()
+        .context("snapshot directory has no parent")?
+        .to_path_buf();
+    std::fs::create_dir_all(&snapshots_root)
+        .with_context(|| format!("Failed to create {}", snapshots_root.display()))?;
+
+    let staging = tempfile::Builder::new()
+        .prefix(&format!(".tmp-{sha}-"))
+        .tempdir_in(&snapshots_root)
+        .with_context(|| {
+            format!(
+                "Failed to create staging dir in {}",
+                snapshots_root.display()
+            )
+        })?;
+    let export = tempfile::tempdir().context("Failed to create export dir")?;
+
+    cqs::snapshot::export_tree(&ctx.root, sha, export.path())?;
+    let parser = CqParser::new()?;
+    let files = enumerate_files(export.path(), &parser, false)?;
+    if files.is_empty() {
+        bail!("No supported source files found at '{rev}' ({sha})");
+    }
+
+    if !cli.quiet {
+        eprintln!(
+            "Building snapshot index for {rev} ({}): {} files...",
+            &sha[..12.min(sha.len())],
+            files.len()
+        );
+    }
+
+    // The snapshot must be embedded with the project's model: queries are
+    // embedded once (by the project embedder) and compared against it.
+    let model = ctx.model_config().clone();
+    let db_path = staging.path().join(cqs::INDEX_DB_FILENAME);
+    let store = Arc::new(
+        Store::open(&db_path)
+            .with_context(|| format!("Failed to open snapshot store at {}", db_path.display()))?,
+    );
+    store.init(&ModelInfo::new(&model.repo, model.dim))?;
+    run_index_pipeline(
+        export.path(),
+        files,
+        Arc::clone(&store),
+        false,
+        cli.quiet,
+        model,
+        false,
+    )?;
+    build_hnsw_index(&store, staging.path())?;
+    // Close the pool (checkpointing the WAL) before the directory moves.
+    drop(store);
+
+    cqs::snapshot::mark_complete(staging.path())?;
+    let staged = staging.keep();
+    if let Err(e) = std::fs::rename(&staged, dir) {
+        // A concurrent build won the race; ours is redundant.
+        let _ = std::fs::remove_dir_all(&staged);
+        if !cqs::snapshot::is_complete(dir) {
+            return Err(e).with_context(|| {
+                format!("Failed to move snapshot into place at {}", dir.display())
+            });
+        }
+        tracing::debug!(sha, "Concurrent snapshot build landed first; using it");
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn snapshot_label_prefixes_rev() {
+        assert_eq!(snapshot_label("v2.3.0"), "@v2.3.0");
+    }
+}
@@ -0,0 +1,149 @@
+//! `cqs "<query>" --stream` — search results as NDJSON events.
+//!
+//! A reranked search spends most of its time in the cross-encoder, after the
+//! first-stage pool is already known. Streaming lets a UI render that pool
+//! straight away and replace it as the final ranking lands:
+//!
+//! ```text
+//! {"event":"candidates","query":"…","provisional":true,"results":[…]}   (reranker only)
+//! {"event":"result","rank":1,"result":{…}}
+//! {"event":"result","rank":2,"result":{…}}
+//! {"event":"done","query":"…","total":2}
+//! ```
+//!
+//! Each line is flushed as it is written. A `result` object has the same shape
+//! as one entry of the `--json` `results` array. The `cqs serve` counterpart is
+//! `GET /api/search/stream` (server-sent events).
+
+use std::collections::HashMap;
+use std::io::Write;
+
+use anyhow::Result;
+use cqs::store::{ParentContext, UnifiedResult};
+
+use super::query::{self, Prepared, ProjectSurface, QueryArgs};
+use super::search_ctx::SearchCtx;
+use crate::cli::{display, signal};
+
+/// Run the plain search path, writing events to stdout.
+pub(crate) fn cmd_query_stream(ctx: &dyn SearchCtx, args: &QueryArgs) -> Result<()> {
+    let _span = tracing::info_span!("cmd_query_stream", query_len = args.query.len()).entered();
+    let stdout = std::io::stdout();
+    let mut out = stdout.lock();
+    let total = stream_query(ctx, args, &mut out)?;
+    if total == 0 {
+        std::process::exit(signal::ExitCode::NoResults as i32);
+    }
+    Ok(())
+}
+
+/// Search and write events to `out`. Returns the number of final results.
+fn stream_query(ctx: &dyn SearchCtx, args: &QueryArgs, out: &mut dyn Write) -> Result<usize> {
+    let query_text = args.query.as_str();
+    let (results, calibration) = match query::prepare_query(ctx, args, ProjectSurface::Resolve)? {
+        Prepared::ShortCircuit(results) => (results, None),
+        Prepared::Dense(prepared) => {
+            let results =
+                query::retrieve_project_staged(ctx, args, &prepared, &mut |candidates| {
+                    tracing::debug!(candidates = candidates.len(), "streaming first-stage pool");
+                    write_event(&mut *out, &candidates_event(query_text, candidates))
+                })?;
+            (results, query::score_calibration(ctx, args))
+        }
+    };
+    let output = query::assemble_output(ctx, args, results)?;
+    query::record_search_hits(ctx.cqs_dir(), &output.results);
+    cqs::session::record_query(
+        &cqs::resolve_index_dir(ctx.root()),
+        query_text,
+        query::session_flags(args),
+        query::session_results(&output.results),
+    );
+
+    let parents = args.expand_parent.then_some(&output.parents);
+    let extras = display::ResultExtras {
+        tests: args.with_tests.then_some(&output.tests),
+        owners: args.with_owners.then_some(&output.owners),
+        issue_refs: Some(&output.issue_refs),
+        commits: Some(&output.commits),
+        calibration: calibration.as_ref(),
+    };
+    for (i, result) in output.results.iter().enumerate() {
+        write_event(&mut *out, &result_event(i + 1, result, parents, extras))?;
+    }
+    write_event(
+        &mut *out,
+        &done_event(query_text, output.results.len(), output.token_info),
+    )?;
+    Ok(output.results.len())
+}
+
+/// One NDJSON line, flushed so the reader sees it now.
+fn write_event(out: &mut dyn Write, event: &serde_json::Value) -> Result<()> {
+    serde_json::to_writer(&mut *out, event)?;
+    out.write_all(b"\n")?;
+    out.flush()?;
+    Ok(())
+}
+
+fn candidates_event(query: &str, candidates: &[UnifiedResult]) -> serde_json::Value {
+    let results: Vec<_> = candidates
+        .iter()
+        .map(|r| display::unified_result_value(r, None, display::ResultExtras::default()))
+        .collect();
+    serde_json::json!({
+        "event": "candidates",
+        "query": query,
+        "provisional": true,
+        "results": results,
+    })
+}
+
+fn result_event(
+    rank: usize,
+    result: &UnifiedResult,
+    parents: Option<&HashMap<String, ParentContext>>,
+    extras: display::ResultExtras<'_>,
+) -> serde_json::Value {
+    serde_json::json!({
+        "event": "result",
+        "rank": rank,
+        "result": display::unified_result_value(result, parents, extras),
+    })
+}
+
+fn done_event(query: &str, total: usize, token_info: Option<(usize, usize)>) -> serde_json::Value {
+    let mut event = serde_json::json!({
+        "event": "done",
+        "query": query,
+        "total": total,
+    });
+    if let Some((used, budget)) = token_info {
+        event["token_count"] = used.into();
+        event["token_budget"] = budget.into();
+    }
+    event
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn events_are_single_lines_tagged_by_kind() {
+        let mut buf = Vec::new();
+        write_event(&mut buf, &candidates_event("q", &[])).unwrap();
+        write_event(&mut buf, &done_event("q", 0, Some((120, 500)))).unwrap();
+        let text = String::from_utf8(buf).unwrap();
+        let lines: Vec<serde_json::Value> = text
+            .lines()
+            .map(|l| serde_json::from_str(l).unwrap())
+            .collect();
+        assert_eq!(lines.len(), 2);
+        assert_eq!(lines[0]["event"], "candidates");
+        assert_eq!(lines[0]["provisional"], true);
+        assert_eq!(lines[1]["event"], "done");
+        assert_eq!(lines[1]["token_budget"], 500);
+        assert!(done_event("q", 3, None).get("token_count").is_none());
+    }
+}
@@ -0,0 +1,143 @@
+//! Symbol command — fuzzy symbol name lookup
+//!
+//! Editor-style Ctrl-P over indexed names: subsequence and edit-distance
+//! matching ranked by match quality, nudged by reference count. No
+//! embeddings, so it works before the model is loaded and never drifts with
+//! the semantic pipeline.
+
+use anyhow::{Context as _, Result};
+
+use cqs::fuzzy::{find_symbols, SymbolMatch, SymbolSearchOptions};
+use cqs::rel_display;
+
+// ─── Output types ──────────────────────────────────────────────────────────
+
+/// A single fuzzy symbol match.
+#[derive(Debug, serde::Serialize)]
+struct SymbolEntry {
+    name: String,
+    file: String,
+    line_start: u32,
+    chunk_type: String,
+    language: String,
+    quality: f32,
+    references: u64,
+}
+
+/// Typed JSON output for the symbol command.
+#[derive(Debug, serde::Serialize)]
+struct SymbolOutput {
+    pattern: String,
+    results: Vec<SymbolEntry>,
+    total: usize,
+}
+
+// ─── Shared builder ────────────────────────────────────────────────────────
+
+/// Build typed symbol output with root-relative file paths.
+fn build_symbol_output(
+    pattern: &str,
+    matches: &[SymbolMatch],
+    root: &std::path::Path,
+) -> SymbolOutput {
+    let results: Vec<SymbolEntry> = matches
+        .iter()
+        .map(|m| SymbolEntry {
+            name: m.name.clone(),
+            file: rel_display(&m.file, root),
+            line_start: m.line_start,
+            chunk_type: m.chunk_type.to_string(),
+            language: m.language.to_string(),
+            quality: m.quality,
+            references: m.references,
+        })
+        .collect();
+    let total = results.len();
+    SymbolOutput {
+        pattern: pattern.to_string(),
+        results,
+        total,
+    }
+}
+
+// ─── CLI command ───────────────────────────────────────────────────────────
+
+pub(crate) fn cmd_symbol(
+    ctx: &crate::cli::CommandContext<'_, cqs::store::ReadOnly>,
+    pattern: &str,
+    limit: usize,
+    lang: Option<&str>,
+    json: bool,
+) -> Result<()> {
+    let _span = tracing::info_span!("cmd_symbol", pattern, limit).entered();
+    let language = match lang {
+        Some(l) => Some(l.parse().context(format!(
+            "Invalid language. Valid: {}",
+            cqs::parser::Language::valid_names_display()
+        ))?),
+        None => None,
+    };
+    let opts = SymbolSearchOptions { limit, language };
+    let matches = find_symbols(&ctx.store, pattern, &opts)?;
+    let output = build_symbol_output(pattern, &matches, &ctx.root);
+
+    if json {
+        crate::cli::json_envelope::emit_json(&output)?;
+    } else {
+        use colored::Colorize;
+        if output.results.is_empty() {
+            println!("No symbols match '{}'.", pattern);
+            return Ok(());
+        }
+        for e in &output.results {
+            let refs = match e.references {
+                0 => String::new(),
+                1 => "  1 ref".to_string(),
+                n => format!("  {n} refs"),
+            };
+            println!(
+                "  {:.2}  {} [{}] {}{}",
+                e.quality,
+                e.name.bold(),
+                e.chunk_type,
+                format!("{}:{}", e.file, e.line_start).dimmed(),
+                refs.dimmed()
+            );
+        }
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use cqs::parser::{ChunkType, Language};
+    use std::path::{Path, PathBuf};
+
+    #[test]
+    fn symbol_output_uses_relative_paths() {
+        let m = SymbolMatch {
+            name: "parse_config".to_string(),
+            file: PathBuf::from("/project/src/config.rs"),
+            line_start: 12,
+            chunk_type: ChunkType::Function,
+            language: Language::Rust,
+            quality: 0.9,
+            references: 3,
+        };
+        let output = build_symbol_output("prscfg", &[m], Path::new("/project"));
+        assert_eq!(output.total, 1);
+        let json = serde_json::to_value(&output).unwrap();
+        assert_eq!(json["pattern"], "prscfg");
+        assert_eq!(json["results"][0]["file"], "src/config.rs");
+        assert_eq!(json["results"][0]["references"], 3);
+        assert_eq!(json["results"][0]["language"], "rust");
+    }
+
+    #[test]
+    fn symbol_output_empty() {
+        let output = build_symbol_output("zzz", &[], Path::new("/project"));
+        assert!(output.results.is_empty());
+        assert_eq!(output.total, 0);
+    }
+}
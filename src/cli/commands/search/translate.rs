@@ -0,0 +1,227 @@
+//! Query translation for the shared search core (`--translate` /
+//! `--no-translate`).
+//!
+//! When active, [`super::query::prepare_query`] asks the LLM provider for an
+//! English rewrite of the query (cached by query hash + model, see
+//! [`cqs::cache::TranslationCache`]) and classifies, embeds, keyword-matches
+//! and reranks with the rewrite. `auto` translates only queries that don't
+//! read as English ([`cqs::FtsLanguage::detect`]); `--name-only` never
+//! translates. Any failure (no provider configured, API error) falls back to
+//! the query as typed with a warning.
+//!
+//! What happened is recorded per query for `_meta.translation` in JSON output
+//! and the `--explain` line in text output.
+
+use std::path::Path;
+use std::sync::OnceLock;
+
+use serde::Serialize;
+
+use cqs::config::QueryTranslate;
+use cqs::FtsLanguage;
+
+/// `llm_query_translate` from the config, pushed in once at startup.
+static CONFIG_MODE: OnceLock<QueryTranslate> = OnceLock::new();
+
+/// Install the config value. Later calls are no-ops (OnceLock).
+pub(crate) fn set_translate_from_config(mode: QueryTranslate) {
+    let _ = CONFIG_MODE.set(mode);
+}
+
+/// Resolved at the adapter boundary like HyDE: `--no-translate` wins, then
+/// `--translate` (every query), then `CQS_QUERY_TRANSLATE`
+/// (`off` / `auto` / `always`), then `llm_query_translate` in the config.
+/// Off by default — translation costs an LLM call per uncached query.
+pub(crate) fn resolve_translate(flag_on: bool, flag_off: bool) -> QueryTranslate {
+    if flag_off {
+        return QueryTranslate::Off;
+    }
+    if flag_on {
+        return QueryTranslate::Always;
+    }
+    if let Ok(value) = std::env::var("CQS_QUERY_TRANSLATE") {
+        match QueryTranslate::parse(&value) {
+            Some(mode) => return mode,
+            None => tracing::warn!(
+                value = %value,
+                "Ignoring CQS_QUERY_TRANSLATE: expected off, auto, or always"
+            ),
+        }
+    }
+    CONFIG_MODE.get().copied().unwrap_or_default()
+}
+
+/// What query translation did for one search.
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub(crate) struct TranslationMeta {
+    /// The search ran on the translated query
+    pub fired: bool,
+    /// Served from the translation cache, no LLM call made
+    #[serde(skip_serializing_if = "cqs::serde_helpers::is_false")]
+    pub cached: bool,
+    /// Language the query was detected as (`german`, `japanese`, …)
+    pub detected: &'static str,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub model: Option<String>,
+    /// The English query searched with
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub text: Option<String>,
+    /// Why the translation was not used
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub error: Option<String>,
+}
+
+impl TranslationMeta {
+    fn failed(detected: FtsLanguage, error: String) -> Self {
+        Self {
+            fired: false,
+            cached: false,
+            detected: detected.as_str(),
+            model: None,
+            text: None,
+            error: Some(error),
+        }
+    }
+
+    /// One-line summary for `--explain`.
+    pub(crate) fn explain_line(&self) -> String {
+        match (&self.text, &self.error) {
+            (Some(text), _) if self.fired => format!(
+                "Translation: fired ({}, {}, {}): {text}",
+                self.detected,
+                self.model.as_deref().unwrap_or("?"),
+                if self.cached { "cached" } else { "generated" },
+            ),
+            (_, Some(e)) => format!("Translation: not applied: {e}"),
+            _ => "Translation: not applied".to_string(),
+        }
+    }
+}
+
+thread_local! {
+    /// Per-query translation outcome, read by the JSON envelope and
+    /// `--explain`. Cleared at the start of every search (the daemon reuses
+    /// threads).
+    static TRANSLATION_META: std::cell::RefCell<Option<TranslationMeta>> =
+        const { std::cell::RefCell::new(None) };
+}
+
+/// Clear any translation outcome left over from a previous query on this
+/// thread.
+pub(crate) fn clear_translation_meta() {
+    TRANSLATION_META.with(|cell| *cell.borrow_mut() = None);
+}
+
+/// Read (and clear) the translation outcome of the current search; `None`
+/// when the query was not translated or the mode didn't call for it.
+pub(crate) fn take_translation_meta() -> Option<TranslationMeta> {
+    TRANSLATION_META.with(|cell| cell.borrow_mut().take())
+}
+
+fn set_translation_meta(meta: TranslationMeta) {
+    TRANSLATION_META.with(|cell| *cell.borrow_mut() = Some(meta));
+}
+
+/// Translate `query` if `mode` calls for it. `None` when it doesn't (off,
+/// or `auto` on a query that reads as English). Never fails: an LLM error
+/// comes back as a meta with `fired = false`.
+pub(crate) fn translate(root: &Path, query: &str, mode: QueryTranslate) -> Option<TranslationMeta> {
+    let detected = FtsLanguage::detect(query);
+    match mode {
+        QueryTranslate::Off => return None,
+        QueryTranslate::Auto if detected == FtsLanguage::English => return None,
+        QueryTranslate::Auto | QueryTranslate::Always => {}
+    }
+    let _span =
+        tracing::info_span!("translate_search_query", detected = detected.as_str()).entered();
+    Some(match translated_meta(root, query, detected) {
+        Ok(meta) => meta,
+        Err(e) => {
+            tracing::warn!(error = %e, "Query translation failed, searching with the query as typed");
+            TranslationMeta::failed(detected, format!("{e:#}"))
+        }
+    })
+}
+
+/// [`translate`] for the search core: records the outcome for `_meta` and
+/// `--explain`, and returns the text to search with when it fired.
+pub(crate) fn translate_for_search(
+    root: &Path,
+    query: &str,
+    mode: QueryTranslate,
+) -> Option<String> {
+    let meta = translate(root, query, mode)?;
+    let text = meta.text.clone().filter(|_| meta.fired);
+    set_translation_meta(meta);
+    text
+}
+
+#[cfg(feature = "llm-summaries")]
+fn translated_meta(
+    root: &Path,
+    query: &str,
+    detected: FtsLanguage,
+) -> anyhow::Result<TranslationMeta> {
+    let config = cqs::config::Config::load(root);
+    let translation = cqs::llm::translate_query(&config, query, true)?;
+    Ok(TranslationMeta {
+        fired: true,
+        cached: translation.cached,
+        detected: detected.as_str(),
+        model: Some(translation.model),
+        text: Some(translation.text),
+        error: None,
+    })
+}
+
+#[cfg(not(feature = "llm-summaries"))]
+fn translated_meta(
+    _root: &Path,
+    _query: &str,
+    _detected: FtsLanguage,
+) -> anyhow::Result<TranslationMeta> {
+    anyhow::bail!("built without the llm-summaries feature")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn translate_flags_resolve_off_over_on() {
+        assert_eq!(resolve_translate(true, true), QueryTranslate::Off);
+        assert_eq!(resolve_translate(true, false), QueryTranslate::Always);
+    }
+
+    #[test]
+    fn auto_skips_english_queries() {
+        let root = Path::new(".");
+        assert!(translate(root, "where is the config loaded", QueryTranslate::Auto).is_none());
+        assert!(translate(
+            root,
+            "wo wird die Konfiguration gelesen",
+            QueryTranslate::Off
+        )
+        .is_none());
+    }
+
+    #[test]
+    fn explain_line_reports_outcome() {
+        let fired = TranslationMeta {
+            fired: true,
+            cached: true,
+            detected: "german",
+            model: Some("m".into()),
+            text: Some("where is the config read".into()),
+            error: None,
+        };
+        assert_eq!(
+            fired.explain_line(),
+            "Translation: fired (german, m, cached): where is the config read"
+        );
+        assert_eq!(
+            TranslationMeta::failed(FtsLanguage::Japanese, "no key".into()).explain_line(),
+            "Translation: not applied: no key"
+        );
+    }
+}
@@ -2,13 +2,146 @@
 //!
 //! Thin CLI wrapper around `cqs::serve::run_server`. Resolves the
 //! project's read-only store and binds the requested address.
+//! `cqs serve token create|list|revoke` manages the named tokens in
+//! `.cqs/serve_tokens.json` ([`cqs::serve::TokenStore`]).
+//! `cqs serve --projects FILE` serves several projects from one process.
 
 use std::net::SocketAddr;
+use std::path::Path;
 
 use anyhow::{Context, Result};
+use clap::Subcommand;
 
+use cqs::serve::{tokens_path, Scope, ServedProject, TokenStore};
+
+use crate::cli::definitions::TextJsonArgs;
 use crate::cli::find_project_root;
 
+/// `cqs serve <action>` — everything besides running the server.
+#[derive(Subcommand, Clone, Debug)]
+pub(crate) enum ServeCommand {
+    /// Manage the named API tokens a shared server accepts
+    Token {
+        #[command(subcommand)]
+        subcmd: TokenCommand,
+    },
+}
+
+/// `cqs serve token ...`
+#[derive(Subcommand, Clone, Debug)]
+pub(crate) enum TokenCommand {
+    /// Mint a named token; it is printed once and only its hash is kept
+    Create {
+        /// Token name (letters, digits, `_`, `-`, `.`; max 64 chars)
+        name: String,
+        /// `read` covers every read-only route; `admin` adds `/api/admin/*`
+        #[arg(long, value_enum, default_value_t = Scope::Read)]
+        scope: Scope,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
+    /// List named tokens (names and scopes, never the tokens)
+    List {
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
+    /// Delete a named token; a running server accepts it until restarted
+    Revoke {
+        name: String,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
+}
+
+/// Entry point for `cqs serve <action>`. `json` is the global `--json`.
+pub(crate) fn cmd_serve_action(action: &ServeCommand, json: bool) -> Result<()> {
+    match action {
+        ServeCommand::Token { subcmd } => cmd_serve_token(subcmd, json),
+    }
+}
+
+fn cmd_serve_token(subcmd: &TokenCommand, json: bool) -> Result<()> {
+    let _span = tracing::info_span!("cmd_serve_token").entered();
+    let root = find_project_root();
+    let path = tokens_path(&cqs::resolve_index_dir(&root));
+    let mut store = TokenStore::load(&path)?;
+
+    match subcmd {
+        TokenCommand::Create {
+            name,
+            scope,
+            output,
+        } => {
+            let token = store.create(name, *scope, cqs::unix_secs_i64().unwrap_or(0))?;
+            store.save(&path)?;
+            tracing::info!(name = %name, scope = %scope, "serve token created");
+            if json || output.json {
+                crate::cli::json_envelope::emit_json(&serde_json::json!({
+                    "name": name,
+                    "scope": scope,
+                    "token": token.as_str(),
+                }))?;
+            } else {
+                println!("Created {scope} token '{name}'. It will not be shown again:");
+                println!("{}", token.as_str());
+                println!("Restart `cqs serve` for it to take effect.");
+            }
+        }
+        TokenCommand::List { output } => {
+            if json || output.json {
+                let tokens: Vec<_> = store
+                    .records()
+                    .iter()
+                    .map(|r| {
+                        serde_json::json!({
+                            "name": r.name,
+                            "scope": r.scope,
+                            "created_at": r.created_at,
+                        })
+                    })
+                    .collect();
+                crate::cli::json_envelope::emit_json(&serde_json::json!({ "tokens": tokens }))?;
+            } else if store.records().is_empty() {
+                println!("No named tokens. Create one with `cqs serve token create <name>`.");
+            } else {
+                for record in store.records() {
+                    println!(
+                        "{:<24} {:<6} created {}",
+                        record.name,
+                        record.scope,
+                        format_created(record.created_at)
+                    );
+                }
+            }
+        }
+        TokenCommand::Revoke { name, output } => {
+            let record = store.revoke(name)?;
+            store.save(&path)?;
+            tracing::info!(name = %name, "serve token revoked");
+            if json || output.json {
+                crate::cli::json_envelope::emit_json(&serde_json::json!({
+                    "name": record.name,
+                    "scope": record.scope,
+                    "revoked": true,
+                }))?;
+            } else {
+                println!(
+                    "Revoked {} token '{}'. Restart `cqs serve` for it to take effect.",
+                    record.scope, record.name
+                );
+            }
+        }
+    }
+    Ok(())
+}
+
+/// `created_at` as a UTC date-time, or the raw seconds if out of range.
+fn format_created(secs: i64) -> String {
+    chrono::DateTime::from_timestamp(secs, 0)
+        .map(|t| t.format("%Y-%m-%d %H:%M UTC").to_string())
+        .unwrap_or_else(|| secs.to_string())
+}
+
 /// Entry point for `cqs serve`. Dispatched from `src/cli/dispatch.rs`.
 ///
 /// # Arguments
@@ -17,31 +150,32 @@ use crate::cli::find_project_root;
 /// * `open` — open the system browser on start (token-aware URL)
 /// * `no_auth` — disable per-launch auth; opt-out for scripted
 ///   automation, with loud-warning banner on boot
+/// * `grpc_port` — also serve the gRPC API on this port (`--grpc`)
 ///
 /// The "non-loopback + --no-auth" warning lives in
 /// `serve/mod.rs::run_server`, which emits an unconditional
 /// `WARN: --no-auth in use` on the listening banner. The CLI side stays
 /// silent to avoid a redundant second surface.
-pub(crate) fn cmd_serve(port: u16, bind: String, open: bool, no_auth: bool) -> Result<()> {
-    let _span = tracing::info_span!("cmd_serve", port, bind = %bind, open, no_auth).entered();
+pub(crate) fn cmd_serve(
+    port: u16,
+    bind: String,
+    open: bool,
+    no_auth: bool,
+    grpc_port: Option<u16>,
+) -> Result<()> {
+    let _span =
+        tracing::info_span!("cmd_serve", port, bind = %bind, open, no_auth, ?grpc_port).entered();
 
     // The `--no-auth` warning lives in `serve/mod.rs::run_server`, which
     // emits an unconditional `WARN: --no-auth in use` on the listening
     // banner, a structured `tracing::error!` regardless of `quiet`, and a
     // loud-warn for the non-loopback case. No second CLI-side surface.
 
-    // Resolve "localhost" to 127.0.0.1 before parse, since
-    // `SocketAddr::parse` only accepts numeric IPs. CLI docs treat "localhost"
-    // as a valid bind value; without this resolution the literal hostname
-    // would always fail.
-    let bind_str: &str = if bind == "localhost" {
-        "127.0.0.1"
-    } else {
-        bind.as_str()
-    };
-    let bind_addr: SocketAddr = format!("{bind_str}:{port}")
-        .parse()
-        .with_context(|| format!("Failed to parse {bind_str}:{port} as a SocketAddr"))?;
+    let bind_addr = parse_bind_addr(&bind, port)?;
+    let grpc_addr = grpc_port.map(|p| SocketAddr::new(bind_addr.ip(), p));
+    if grpc_addr == Some(bind_addr) {
+        anyhow::bail!("--grpc-port must differ from --port ({port})");
+    }
 
     let root = find_project_root();
     let cqs_dir = cqs::resolve_index_dir(&root);
@@ -68,10 +202,16 @@ pub(crate) fn cmd_serve(port: u16, bind: String, open: bool, no_auth: bool) -> R
     // AuthMode::Disabled requires `NoAuthAcknowledgement`, constructed via
     // `from_cli_no_auth_flag()` — the function name is the audit trail for
     // "user explicitly opted into a no-auth server."
+    //
+    // Named tokens from `.cqs/serve_tokens.json` are accepted alongside the
+    // per-launch one; a malformed registry is an error rather than a silent
+    // lock-out of every named token.
     let auth = if no_auth {
         cqs::serve::AuthMode::disabled(cqs::serve::NoAuthAcknowledgement::from_cli_no_auth_flag())
     } else {
+        let tokens = TokenStore::load(&tokens_path(&cqs_dir))?;
         cqs::serve::AuthMode::required(cqs::serve::AuthToken::random(), bind_addr.port())
+            .with_tokens(&tokens)
     };
 
     if open {
@@ -123,7 +263,86 @@ pub(crate) fn cmd_serve(port: u16, bind: String, open: bool, no_auth: bool) -> R
     // to the project root so the fixtures stay fresh with the checkout. Pass the
     // same root the index was resolved from; the route 503s cleanly when the
     // fixtures aren't present there.
-    cqs::serve::run_server(store, bind_addr, false, auth, daemon_socket, Some(root))
+    cqs::serve::run_server(
+        store,
+        bind_addr,
+        false,
+        auth,
+        daemon_socket,
+        Some(root),
+        grpc_addr,
+    )
+}
+
+/// `cqs serve --projects FILE`: every project in `FILE` behind one listener
+/// ([`cqs::serve::run_projects_server`]). Each project needs an index; its
+/// named tokens and daemon socket come from its own `.cqs/`. The per-launch
+/// token, unless `--no-auth`, opens every project.
+pub(crate) fn cmd_serve_projects(
+    file: &Path,
+    port: u16,
+    bind: String,
+    no_auth: bool,
+) -> Result<()> {
+    let _span =
+        tracing::info_span!("cmd_serve_projects", file = %file.display(), port, bind = %bind)
+            .entered();
+    let bind_addr = parse_bind_addr(&bind, port)?;
+    let specs = cqs::serve::load_projects(file)?;
+
+    let mut projects = Vec::with_capacity(specs.len());
+    for spec in specs {
+        let cqs_dir = cqs::resolve_index_dir(&spec.root);
+        let index_path = cqs::resolve_index_db(&cqs_dir);
+        if !index_path.exists() {
+            anyhow::bail!(
+                "Project '{}': no cqs index found at {}. Run `cqs index` in {} first.",
+                spec.name,
+                index_path.display(),
+                spec.root.display()
+            );
+        }
+        let store = cqs::Store::open_readonly(&index_path).with_context(|| {
+            format!(
+                "Project '{}': failed to open store at {}",
+                spec.name,
+                index_path.display()
+            )
+        })?;
+        let tokens = TokenStore::load(&tokens_path(&cqs_dir))?;
+        // Same daemon-socket resolution as `cmd_serve`, per project.
+        #[cfg(unix)]
+        let daemon_socket = Some(cqs::daemon_translate::daemon_socket_path(&cqs_dir));
+        #[cfg(not(unix))]
+        let daemon_socket: Option<std::path::PathBuf> = None;
+        projects.push(ServedProject {
+            spec,
+            store,
+            tokens,
+            daemon_socket,
+        });
+    }
+
+    let auth = if no_auth {
+        cqs::serve::AuthMode::disabled(cqs::serve::NoAuthAcknowledgement::from_cli_no_auth_flag())
+    } else {
+        cqs::serve::AuthMode::required(cqs::serve::AuthToken::random(), bind_addr.port())
+    };
+    cqs::serve::run_projects_server(projects, bind_addr, false, auth)
+}
+
+/// `bind:port` as a socket address. "localhost" is resolved to 127.0.0.1
+/// first, since `SocketAddr::parse` only accepts numeric IPs and the CLI
+/// docs treat "localhost" as a valid bind value.
+fn parse_bind_addr(bind: &str, port: u16) -> Result<SocketAddr> {
+    let bind_str = if bind == "localhost" {
+        "127.0.0.1"
+    } else {
+        bind
+    };
+    format!("{bind_str}:{port}")
+        .parse()
+        .with_context(|| format!("Failed to parse {bind_str}:{port} as a SocketAddr"))
 }
 
 /// Reject URLs containing shell metacharacters before handing them to
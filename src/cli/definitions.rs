@@ -36,6 +36,15 @@ impl std::fmt::Display for OutputFormat {
     }
 }
 
+/// Output format of a bare-query search (`cqs --format prompt "query"`).
+#[derive(Clone, Copy, Debug, PartialEq, Eq, clap::ValueEnum)]
+pub enum SearchFormat {
+    Text,
+    Json,
+    /// Fenced, path-annotated blocks to paste into an LLM chat
+    Prompt,
+}
+
 /// Common output format arguments shared across commands that support text/json/mermaid.
 ///
 /// **`--json` vs `--format`:** the two flags are
@@ -153,6 +162,75 @@ pub(crate) fn parse_unit_f32(s: &str) -> std::result::Result<f32, String> {
     Ok(v)
 }
 
+/// `--min-score`: the final-score floor a result must reach to be returned.
+///
+/// `0.45` is an absolute floor on the `score` results report; `60%` keeps
+/// results scoring at least that share of the top result's, which works the
+/// same whatever scale the search ran on. Serializes as the string the flag
+/// takes, so the daemon wire and MCP carry it unchanged.
+#[derive(Debug, Clone, Copy, PartialEq, serde::Serialize, serde::Deserialize)]
+#[serde(try_from = "String", into = "String")]
+pub enum MinScore {
+    /// Keep results with `score >= floor`.
+    Absolute(f32),
+    /// Keep results with `score >= fraction * top score`.
+    OfTop(f32),
+}
+
+impl MinScore {
+    /// The floor for a result list whose best score is `top`.
+    pub fn floor(self, top: f32) -> f32 {
+        match self {
+            Self::Absolute(floor) => floor,
+            Self::OfTop(fraction) => fraction * top,
+        }
+    }
+}
+
+impl std::str::FromStr for MinScore {
+    type Err = String;
+    fn from_str(s: &str) -> std::result::Result<Self, String> {
+        match s.trim().strip_suffix('%') {
+            Some(pct) => {
+                let pct = parse_finite_f32(pct.trim())?;
+                if !(0.0..=100.0).contains(&pct) {
+                    return Err(format!("percentage must be in [0, 100], got {pct}"));
+                }
+                Ok(Self::OfTop(pct / 100.0))
+            }
+            None => parse_finite_f32(s.trim()).map(Self::Absolute),
+        }
+    }
+}
+
+impl std::fmt::Display for MinScore {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        match self {
+            Self::Absolute(floor) => write!(f, "{floor}"),
+            // Rounded so `60%` does not come back as `60.000004%`.
+            Self::OfTop(fraction) => write!(f, "{}%", (fraction * 1e6).round() / 1e4),
+        }
+    }
+}
+
+impl TryFrom<String> for MinScore {
+    type Error = String;
+    fn try_from(s: String) -> std::result::Result<Self, String> {
+        s.parse()
+    }
+}
+
+impl From<MinScore> for String {
+    fn from(m: MinScore) -> String {
+        m.to_string()
+    }
+}
+
+/// Clap parser for [`MinScore`].
+pub(crate) fn parse_min_score(s: &str) -> std::result::Result<MinScore, String> {
+    s.parse()
+}
+
 #[derive(Parser)]
 #[command(name = "cqs")]
 #[command(about = "Semantic code search with local embeddings")]
@@ -182,6 +260,18 @@ pub struct Cli {
     #[arg(short = 't', long, default_value = "0.3", value_parser = parse_finite_f32)]
     pub threshold: f32,
 
+    /// Drop results whose final score is below SCORE, or below PCT% of the
+    /// top result's; fewer than `--limit` results may come back
+    ///
+    /// `--threshold` gates each candidate's dense similarity before fusion;
+    /// this cuts the finished ranking, on the scale of the `score` each
+    /// result reports: boosted cosine similarity (about 0-1) by default,
+    /// reciprocal-rank fusion (about 0.01-0.05) under `--rrf`, the
+    /// cross-encoder's 0-1 under `--rerank`. The `60%` form is the same on
+    /// all of them.
+    #[arg(long, value_name = "SCORE|PCT%", value_parser = parse_min_score)]
+    pub min_score: Option<MinScore>,
+
     /// Weight for name matching in hybrid search (0.0-1.0)
     ///
     /// Bounded parser — see `SearchArgs::name_boost`.
@@ -208,6 +298,48 @@ pub struct Cli {
     #[arg(long)]
     pub pattern: Option<String>,
 
+    /// Only rank chunks whose source text matches this regex (e.g. `'rand\.'`)
+    ///
+    /// Applied before hybrid ranking: the regex narrows the candidates, the
+    /// semantic ranking orders what survives.
+    #[arg(long, value_name = "REGEX")]
+    pub grep: Option<String>,
+
+    /// Only rank callables with this parameter/return shape, e.g.
+    /// `'(context.Context, []byte) error'`
+    ///
+    /// `_` matches any one type, a trailing `..` any remaining parameters;
+    /// parameter names are ignored and `Context` matches `context.Context`.
+    /// Applied before hybrid ranking, like `--grep`.
+    #[arg(long, value_name = "SHAPE")]
+    pub signature: Option<String>,
+
+    /// Only rank code owned by this team or person, e.g. `platform-team`
+    ///
+    /// Matches CODEOWNERS owners (`@org/` and `@` optional) and the top
+    /// `git blame` authors recorded at index time (name or email). Applied
+    /// before hybrid ranking, like `--grep`.
+    #[arg(long, value_name = "NAME")]
+    pub owner: Option<String>,
+
+    /// Only rank code whose comments reference this issue or PR, e.g.
+    /// `JIRA-1234` or `567`
+    ///
+    /// References are read from comments and doc comments at index time:
+    /// tracker keys, `#567`, `owner/repo#567`, and GitHub issue/PR links. A
+    /// bare number matches `#567` in any repo. Applied before hybrid
+    /// ranking, like `--grep`.
+    #[arg(long, value_name = "ID")]
+    pub issue: Option<String>,
+
+    /// Match the keyword leg against code or comments only (implies `--rrf`)
+    ///
+    /// `comments` searches doc comments and the comments inside bodies —
+    /// where "why" questions are answered — without code tokens diluting
+    /// the match. The semantic leg is unchanged.
+    #[arg(long = "in", value_enum)]
+    pub search_in: Option<args::SearchIn>,
+
     /// Definition search: find by name only, skip embedding (faster)
     #[arg(long)]
     pub name_only: bool,
@@ -220,10 +352,50 @@ pub struct Cli {
     #[arg(long)]
     pub include_docs: bool,
 
-    /// Reranker mode: `none|onnx`.
+    /// Rank indexed commit messages alongside code
+    ///
+    /// Commits come from `cqs index` (`[index] commit_history`, default the
+    /// last 500) and carry their sha, author, date, and touched files. Use
+    /// `--include-type commit` to search commits alone.
+    #[arg(long)]
+    pub with_commits: bool,
+
+    /// Drop deprecated code from results instead of ranking it lower
+    ///
+    /// A chunk is deprecated when its doc or declaration carries a marker:
+    /// `#[deprecated]`, `@Deprecated`, `[Obsolete]`, JSDoc `@deprecated`,
+    /// Go's `// Deprecated:`. Without the flag such results are multiplied
+    /// by `CQS_DEPRECATED_PENALTY` (default 0.7) and marked `[deprecated]`.
+    #[arg(long)]
+    pub hide_deprecated: bool,
+
+    /// Keep generated code and license text in results
+    ///
+    /// Chunks from codegen output (`// Code generated ... DO NOT EDIT.`,
+    /// `@generated`, `*.pb.go`, `*_pb2.py`, ...) and license boilerplate are
+    /// left out of search by default; this flag lets them back in, marked
+    /// `[generated]` / `[license]`.
+    #[arg(long)]
+    pub include_generated: bool,
+
+    /// When nothing matches, retry with the filters relaxed step by step
+    ///
+    /// Drops `--path` first, then `--include-type` / `--exclude-type`, then
+    /// the `--threshold` / `--min-score` floors, stopping at the first step
+    /// that finds results. Each step keeps the drops before it. The dropped
+    /// filters are reported on stderr and as `relaxed` in JSON, so an empty
+    /// scoped search is not mistaken for the code not existing. Project
+    /// search only: `--ref`, `--include-refs` and `--stream` ignore it.
+    #[arg(long)]
+    pub relax: bool,
+
+    /// Reranker mode: `none|onnx|late`.
     ///
     /// Mirrors `cqs eval --reranker`. `none` is the default; `onnx` runs the
-    /// cross-encoder configured by `[reranker]` / `CQS_RERANKER_MODEL`.
+    /// cross-encoder configured by `[reranker]` / `CQS_RERANKER_MODEL`;
+    /// `late` (experimental, not yet evaluated against `onnx`) rescores the
+    /// top candidates by MaxSim over the token vectors `[late_interaction]`
+    /// stores.
     #[arg(long = "reranker", value_enum)]
     pub reranker: Option<args::RerankerMode>,
 
@@ -248,6 +420,21 @@ pub struct Cli {
     #[arg(long)]
     pub json: bool,
 
+    /// Search output format: text (default), json (same as `--json`), or
+    /// prompt
+    ///
+    /// `prompt` prints the results as one context block for an LLM chat:
+    /// each chunk dedented in a fenced block under its `path:lines (name)`,
+    /// chunks that overlap a higher-ranked one dropped. Pair it with
+    /// `--max-tokens`. Plain project search only.
+    #[arg(
+        long,
+        value_enum,
+        value_name = "FORMAT",
+        conflicts_with_all = ["json", "stream", "ref_name", "include_refs", "at", "indexes"]
+    )]
+    pub format: Option<SearchFormat>,
+
     /// Show only file:line, no code
     #[arg(long)]
     pub no_content: bool,
@@ -256,14 +443,42 @@ pub struct Cli {
     #[arg(short = 'C', long)]
     pub context: Option<usize>,
 
+    /// Show at most N lines of each result's code, cut at a statement or
+    /// brace boundary and ended with a `... M more lines` marker
+    ///
+    /// Text output shows 10 lines by default; JSON carries whole chunks
+    /// unless this is set, and then reports the cut as `content_cut_lines`.
+    #[arg(long, value_name = "N", value_parser = parse_nonzero_usize)]
+    pub snippet_lines: Option<usize>,
+
     /// Expand search results with their parent type/module context (small-to-big retrieval).
     ///
+    /// A method's parent is its enclosing impl/class; when both match, only
+    /// the method is returned and carries the class as parent context.
+    ///
     /// Named `--expand-parent` to disambiguate from `gather --expand <N>`
     /// (graph depth, `usize`) — same flag name with two incompatible types
     /// would bite agents that batch both commands.
     #[arg(long = "expand-parent")]
     pub expand_parent: bool,
 
+    /// Attach the tests linked to each result (as `cqs tests-for` finds them)
+    ///
+    /// Up to three per callable result: tests that reach it through the call
+    /// graph, then tests named after it (`test_parse`, `TestParse`).
+    /// Ignored with `--ref` / `--include-refs`.
+    #[arg(long)]
+    pub with_tests: bool,
+
+    /// Attach ownership to each result: CODEOWNERS owners and the top blame
+    /// authors of its lines
+    ///
+    /// Authors come from the index (`[index] blame_authors`); owners are
+    /// read from CODEOWNERS at query time. Ignored with `--ref` /
+    /// `--include-refs`.
+    #[arg(long)]
+    pub with_owners: bool,
+
     /// Search only this reference index (skip project index)
     #[arg(long = "ref")]
     pub ref_name: Option<String>,
@@ -272,8 +487,46 @@ pub struct Cli {
     #[arg(long)]
     pub include_refs: bool,
 
+    /// Search the code as it was at a git revision (tag, branch, or SHA)
+    ///
+    /// The first query against a commit builds a snapshot index of that
+    /// commit's tracked tree under `.cqs/snapshots/<sha>/`; later queries
+    /// reuse it. Runs in the CLI process (never forwarded to the daemon,
+    /// which serves the working tree only).
+    #[arg(long, value_name = "REV", conflicts_with_all = ["ref_name", "include_refs"])]
+    pub at: Option<String>,
+
+    /// Federated search: query these registered projects (repeatable)
+    ///
+    /// Names come from `cqs project add`. Each project is searched with the
+    /// model its index was built with; results are merged by score and
+    /// labeled with their project. Honors the filter flags (`--lang`,
+    /// `--include-type`, `--exclude-type`, `--path`, `--name-boost`,
+    /// `--rrf`, `--include-docs`). Runs in the CLI process (never forwarded
+    /// to the daemon, which serves one project).
+    #[arg(
+        long = "index",
+        value_name = "NAME",
+        conflicts_with_all = ["ref_name", "include_refs", "at"]
+    )]
+    pub indexes: Vec<String>,
+
+    /// Restrict results to files changed since a git ref (e.g. `origin/main`)
+    ///
+    /// Diffs the working tree against the merge-base of REF and `HEAD`, so
+    /// the scope is the branch's own changes, committed or not.
+    #[arg(
+        long,
+        value_name = "REF",
+        conflicts_with_all = ["ref_name", "include_refs", "at"]
+    )]
+    pub changed_since: Option<String>,
+
     /// Maximum token budget for results (packs highest-scoring into budget)
-    #[arg(long, value_parser = parse_nonzero_usize)]
+    ///
+    /// With `--format prompt` the budget covers the whole context block,
+    /// and the lowest-ranked blocks are dropped until it fits.
+    #[arg(long, visible_alias = "max-tokens", value_parser = parse_nonzero_usize)]
     pub tokens: Option<usize>,
 
     /// Suppress progress output
@@ -316,6 +569,56 @@ pub struct Cli {
     #[arg(long, conflicts_with = "overlay")]
     pub no_overlay: bool,
 
+    /// Expand the query with a hypothetical code snippet from the LLM (HyDE)
+    ///
+    /// The snippet's embedding is blended into the query's; snippets are
+    /// cached by query hash + model. Off by default (`CQS_QUERY_HYDE=1` turns
+    /// it on); needs a configured LLM provider and falls back to the plain
+    /// query on any error.
+    #[arg(long, conflicts_with = "no_hyde")]
+    pub hyde: bool,
+
+    /// Never expand the query with HyDE, even with `CQS_QUERY_HYDE=1`
+    #[arg(long, conflicts_with = "hyde")]
+    pub no_hyde: bool,
+
+    /// Search with an English translation of the query from the LLM
+    ///
+    /// For queries written in another language. Translations are cached by
+    /// query hash + model. `CQS_QUERY_TRANSLATE=auto` (or
+    /// `llm_query_translate = "auto"`) translates only queries that don't
+    /// read as English; falls back to the query as typed on any error.
+    #[arg(long, conflicts_with = "no_translate")]
+    pub translate: bool,
+
+    /// Never translate the query, even with `CQS_QUERY_TRANSLATE` set
+    #[arg(long, conflicts_with = "translate")]
+    pub no_translate: bool,
+
+    /// Print how the query was translated and expanded (HyDE) after the results
+    ///
+    /// JSON output always carries them as `_meta.translation` / `_meta.hyde`
+    /// when requested.
+    #[arg(long)]
+    pub explain: bool,
+
+    /// Stream results as NDJSON events on stdout as they finalize
+    ///
+    /// With a reranker, the first-stage candidates arrive as one
+    /// `candidates` event before the cross-encoder runs; then one `result`
+    /// event per final hit and a closing `done` event. Always JSON; runs
+    /// in-process (the daemon answers in one piece).
+    #[arg(long, conflicts_with = "at")]
+    pub stream: bool,
+
+    /// Open the top result in your editor after printing the results
+    ///
+    /// Same as running `cqs open 1` next; see `cqs open --help` for how the
+    /// editor is chosen. Runs in-process (the daemon cannot reach your
+    /// desktop).
+    #[arg(long, conflicts_with_all = ["stream", "at", "ref_name", "include_refs", "indexes"])]
+    pub open_first: bool,
+
     /// Embedding model: embeddinggemma-300m (default), bge-large, e5-base, or custom.
     ///
     /// Honored across all commands: `cqs <q> --model X` selects the query embedder,
@@ -362,6 +665,65 @@ pub struct Cli {
     #[arg(long, global = true)]
     pub parent_index: bool,
 
+    /// Queue behind another cqs process holding the index lock instead of failing
+    ///
+    /// Applies to commands that write the index (`index`, `gc`, `restore`,
+    /// `llm docgen`): the command blocks with a progress message on stderr
+    /// until the other `cqs index` / `cqs gc` finishes. `global = true` so it
+    /// can follow the subcommand (`cqs index --wait`).
+    #[arg(long, global = true)]
+    pub wait: bool,
+
+    /// Like `--wait`, but give up after SECS seconds
+    #[arg(long, global = true, value_name = "SECS")]
+    pub lock_timeout: Option<u64>,
+
+    /// Run against a registered project instead of the current directory
+    ///
+    /// NAME comes from `cqs project add`. cqs changes into the project's
+    /// root before anything else resolves, like `git -C`, so every command
+    /// (search, index, watch, daemon install) works from any directory:
+    /// `cqs -P billing "retry backoff"`. `global = true` so it can follow
+    /// the subcommand.
+    #[arg(short = 'P', long = "project", value_name = "NAME", global = true)]
+    pub project: Option<String>,
+
+    /// Render JSON output in a given shape (`v1`, `v2`; default: v1)
+    ///
+    /// Objects and envelopes name their shape with a top-level
+    /// `schema_version`; v2 also wraps array payloads in `items` so they
+    /// carry it. Pin the shape a script was written against and it keeps
+    /// parsing across releases. Also `CQS_JSON_SCHEMA`. `cqs schema dump`
+    /// prints the JSON Schemas.
+    #[arg(long, global = true, value_name = "VERSION")]
+    pub schema: Option<crate::cli::json_schema::SchemaVersion>,
+
+    /// Log line format on stderr or the log file (`text`, `json`)
+    ///
+    /// `json` writes one object per line (`timestamp`, `level`, `target`,
+    /// `message`, `fields`, `spans`) for log shippers. Overrides
+    /// `CQS_LOG_FORMAT` and `[log] format`.
+    #[arg(long, global = true, value_name = "FORMAT")]
+    pub log_format: Option<cqs::logging::LogFormat>,
+
+    /// Write logs to PATH instead of stderr, rotated by size
+    ///
+    /// The file rotates to `PATH.1` … once it passes 10 MiB, keeping five
+    /// (`[log] max_bytes` / `max_files`, `CQS_LOG_MAX_BYTES` /
+    /// `CQS_LOG_MAX_FILES`). Overrides `CQS_LOG_FILE` and `[log] file`.
+    #[arg(long, global = true, value_name = "PATH")]
+    pub log_file: Option<std::path::PathBuf>,
+
+    /// Write a per-stage timing trace to PATH when the command finishes
+    ///
+    /// Chrome trace-event JSON (`chrome://tracing`, Perfetto, speedscope),
+    /// or folded stacks for `flamegraph.pl` / `inferno` when PATH ends in
+    /// `.folded` or `.txt`. Spans are recorded at `cqs=debug` whatever the
+    /// log filter (`CQS_TRACE_FILTER` to change). Runs the command in this
+    /// process, never the daemon, so the stages are the ones timed here.
+    #[arg(long = "trace", global = true, value_name = "PATH")]
+    pub trace_out: Option<std::path::PathBuf>,
+
     /// Resolved model config (set by dispatch, not CLI).
     ///
     /// `pub(super)` because the field is `#[arg(skip)]` — only `cli::dispatch`
@@ -387,7 +749,7 @@ impl Cli {
         self.reranker.unwrap_or(args::RerankerMode::None)
     }
 
-    /// `true` if any reranker stage is selected (Onnx or Llm).
+    /// `true` if any reranker stage is selected (Onnx or Late).
     pub(crate) fn rerank_active(&self) -> bool {
         !matches!(self.rerank_mode(), args::RerankerMode::None)
     }
@@ -395,9 +757,22 @@ impl Cli {
 
 #[derive(Subcommand, cqs_macros::CqsCommands)]
 pub(super) enum Commands {
-    /// Download model and create .cqs/
+    /// Set up cqs here: survey the repo, propose ignore rules and a model, create .cqs/
+    ///
+    /// Lists the languages the index would cover, proposes `.cqsignore`
+    /// rules for generated or vendored trees it would pick up, and picks an
+    /// embedding model for the repo size and hardware (written to
+    /// `.cqs.toml`). Each proposal is asked about on a terminal; without
+    /// one, and without `--yes`, the plan is only printed. Then downloads
+    /// the model and offers the first index with a time estimate.
     #[cqs_cmd(group = "a", batch = "cli")]
     Init {
+        /// Apply every proposal (and skip the prompts)
+        #[arg(short = 'y', long)]
+        yes: bool,
+        /// Run the first `cqs index` once setup finishes
+        #[arg(long)]
+        index: bool,
         /// Emit a structured JSON envelope summarizing the init, for parity
         /// with the rest of the CLI's `--json` contract so JSON-driven agents
         /// can confirm the directory and model that got created.
@@ -415,6 +790,18 @@ pub(super) enum Commands {
         #[command(flatten)]
         output: TextJsonArgs,
     },
+    /// Explain why a file is or isn't in the index
+    ///
+    /// Reports the ignore rule that matched (with its file and line),
+    /// language detection, the size-cap decision, chunk count, last index
+    /// time, stored vs. on-disk content hash, and pending watch events.
+    #[cqs_cmd(group = "b", batch = "cli")]
+    ExplainIndex {
+        /// File path (relative to project root, or absolute)
+        path: String,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
     /// Check model, index, hardware
     #[cqs_cmd(group = "a", batch = "cli")]
     Doctor {
@@ -447,6 +834,99 @@ pub(super) enum Commands {
     /// Show index statistics
     #[cqs_cmd(group = "b", batch = "daemon")]
     Stats {
+        /// List the most-accessed chunks (search hits and opens) from
+        /// `.cqs/access_stats.db` instead of index statistics
+        #[arg(long)]
+        hot: bool,
+        /// Number of chunks `--hot` lists
+        #[arg(short = 'n', long, default_value = "20", requires = "hot")]
+        limit: usize,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
+    /// Mark a search result relevant or irrelevant
+    ///
+    /// Judgements go to `.cqs/feedback.db` and fold into per-path and
+    /// per-language priors. Search applies them only when the
+    /// `feedback_weight` scoring knob (`CQS_FEEDBACK_WEIGHT`) is above 0.
+    #[cqs_cmd(group = "b", batch = "cli")]
+    Feedback {
+        /// Result to rate: a chunk id, or a name / `file:name` as `cqs explain` takes
+        #[arg(required_unless_present_any = ["show", "reset"])]
+        target: Option<String>,
+        /// The result answered the query
+        #[arg(long, requires = "target", conflicts_with = "irrelevant")]
+        relevant: bool,
+        /// The result did not answer the query
+        #[arg(long, requires = "target")]
+        irrelevant: bool,
+        /// Query the result was returned for, stored with the judgement
+        #[arg(long, requires = "target")]
+        query: Option<String>,
+        /// List the learned per-path and per-language priors
+        #[arg(long, conflicts_with_all = ["target", "reset"])]
+        show: bool,
+        /// Delete all recorded feedback
+        #[arg(long, conflicts_with = "target")]
+        reset: bool,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
+    /// Save, name, and reopen search sessions (queries, filters, pinned chunks)
+    ///
+    /// Every search is appended to the working session (`.cqs/session.json`;
+    /// `CQS_SESSION_TRACKING=0` turns that off). `save` names it, `open`
+    /// restores a saved one — `--replay` re-runs its queries, `--chat` starts
+    /// `cqs chat` with them in the history.
+    #[cqs_cmd(group = "b", batch = "cli")]
+    Session {
+        #[command(subcommand)]
+        subcmd: SessionCommand,
+    },
+    /// Open the Nth result of the latest search in your editor
+    ///
+    /// The editor comes from `[editor] open` in `~/.config/cqs/config.toml`
+    /// — a preset (`vscode`, `cursor`, `idea`, `pycharm`, …) that opens a
+    /// `vscode://` / `idea://` URL, or a template with `{path}` and `{line}`
+    /// — else `$VISUAL`, else `$EDITOR`. Results come from the working
+    /// session, so `CQS_SESSION_TRACKING=0` leaves nothing to open.
+    #[cqs_cmd(group = "a", batch = "cli")]
+    Open {
+        /// Result number, as listed by the search (1 = top hit)
+        #[arg(value_parser = parse_nonzero_usize)]
+        index: usize,
+        /// Print the URL or command instead of running it
+        #[arg(long)]
+        print: bool,
+        /// Flattens shared `TextJsonArgs` — see `Init` above.
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
+    /// Answer a question from the indexed code with the configured LLM
+    ///
+    /// Retrieves the top chunks as `cqs search` would, then asks the LLM
+    /// provider (`CQS_LLM_PROVIDER`, as for `--llm-summaries`) to answer from
+    /// them only, citing each claim as `[N]`; the cited chunks are listed by
+    /// `file:line` after the answer. Plain search only calls an LLM with `--hyde`.
+    #[cqs_cmd(group = "b", batch = "cli")]
+    Ask {
+        /// The question (quote it)
+        question: String,
+        /// Chunks to retrieve and offer as sources
+        #[arg(short = 'n', long, default_value = "8", value_parser = parse_nonzero_usize)]
+        limit: usize,
+        /// Filter by language
+        #[arg(short = 'l', long)]
+        lang: Option<String>,
+        /// Filter by path pattern (glob)
+        #[arg(short = 'p', long)]
+        path: Option<String>,
+        /// Leave documentation / markdown / config chunks out of the sources
+        #[arg(long)]
+        code_only: bool,
+        /// Print the retrieved sources without calling the LLM
+        #[arg(long)]
+        sources_only: bool,
         #[command(flatten)]
         output: TextJsonArgs,
     },
@@ -473,6 +953,11 @@ pub(super) enum Commands {
         /// Also listen on a Unix socket for query requests (daemon mode)
         #[arg(long)]
         serve: bool,
+        /// Serve Prometheus metrics at http://ADDR/metrics (e.g.
+        /// 127.0.0.1:9464). Unauthenticated; bind loopback unless the
+        /// port is otherwise firewalled
+        #[arg(long, value_name = "ADDR")]
+        metrics: Option<std::net::SocketAddr>,
     },
     /// What functions, callers, and tests are affected by current diff
     ///
@@ -509,6 +994,10 @@ pub(super) enum Commands {
     /// JSON-RPC channel.
     #[cqs_cmd(group = "a", batch = "cli")]
     Mcp,
+    /// Parse worker for `cqs index --sandbox` (internal; speaks JSON lines on stdio)
+    #[cqs_cmd(group = "a", batch = "cli")]
+    #[command(hide = true)]
+    ParseWorker,
     /// Semantic git blame: who changed a function, when, and why
     #[cqs_cmd(group = "b", batch = "daemon")]
     Blame {
@@ -517,6 +1006,14 @@ pub(super) enum Commands {
         #[command(flatten)]
         output: TextJsonArgs,
     },
+    /// Rename history of a symbol: every name it has had, oldest first
+    #[cqs_cmd(group = "b", batch = "cli")]
+    History {
+        /// Symbol name — any name it has had (old or current)
+        name: String,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
     /// Interactive REPL for cqs commands
     #[cqs_cmd(group = "a", batch = "cli")]
     Chat,
@@ -527,6 +1024,16 @@ pub(super) enum Commands {
         #[arg(value_enum)]
         shell: clap_complete::Shell,
     },
+    /// Module dependency graph: call edges aggregated to directories
+    #[cqs_cmd(group = "b", batch = "cli")]
+    Graph {
+        /// Output format
+        #[arg(long, value_enum, default_value = "dot")]
+        format: args::GraphFormat,
+        /// Truncate module paths to the first N directory components
+        #[arg(long, value_parser = parse_nonzero_usize)]
+        depth: Option<usize>,
+    },
     /// Show type dependencies: who uses a type, or what types a function uses
     #[cqs_cmd(group = "b", batch = "daemon")]
     Deps {
@@ -551,6 +1058,23 @@ pub(super) enum Commands {
         #[command(flatten)]
         output: TextJsonArgs,
     },
+    /// Usage examples: call sites of a symbol ranked by how much they show
+    #[cqs_cmd(group = "b", batch = "cli")]
+    Examples {
+        /// Function or method name (`send`, `Client.send`, `Client::send`)
+        symbol: String,
+        /// Max examples to return
+        #[arg(short = 'n', long, default_value_t = cqs::examples::DEFAULT_EXAMPLE_LIMIT)]
+        limit: usize,
+        /// Also consider call sites inside tests
+        #[arg(long)]
+        include_tests: bool,
+        /// Lines of context around the call when the caller is too long to show whole
+        #[arg(short = 'C', long, default_value_t = cqs::examples::DEFAULT_EXAMPLE_CONTEXT)]
+        context: usize,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
     /// Guided codebase tour: entry point → call chain → types → tests
     #[cqs_cmd(group = "b", batch = "daemon")]
     Onboard {
@@ -590,6 +1114,14 @@ pub(super) enum Commands {
         #[command(flatten)]
         output: TextJsonArgs,
     },
+    /// Diff two whole indexes (db files or git revisions): symbols, summaries, embeddings
+    #[cqs_cmd(group = "b", batch = "cli")]
+    DiffIndex {
+        #[command(flatten)]
+        args: args::DiffIndexArgs,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
     /// Detect semantic drift between a reference and the project
     #[cqs_cmd(group = "a", batch = "cli")]
     Drift {
@@ -614,6 +1146,20 @@ pub(super) enum Commands {
         #[command(flatten)]
         output: TextJsonArgs,
     },
+    /// Fuzzy symbol name search (editor-style Ctrl-P over indexed names)
+    #[cqs_cmd(group = "b", batch = "cli")]
+    Symbol {
+        /// Partial, abbreviated, or misspelled symbol name
+        name: String,
+        /// Max matches to return
+        #[arg(short = 'n', long, default_value_t = cqs::fuzzy::DEFAULT_SYMBOL_LIMIT)]
+        limit: usize,
+        /// Filter by language
+        #[arg(short = 'l', long)]
+        lang: Option<String>,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
     /// Impact analysis: what breaks if you change a function
     #[cqs_cmd(group = "b", batch = "daemon")]
     Impact {
@@ -655,7 +1201,8 @@ pub(super) enum Commands {
         #[command(flatten)]
         output: OutputArgs,
     },
-    /// Find tests that exercise a function
+    /// Find tests that exercise a function (call graph + test naming)
+    #[command(visible_alias = "tests-for")]
     #[cqs_cmd(group = "b", batch = "daemon")]
     TestMap {
         #[command(flatten)]
@@ -672,6 +1219,7 @@ pub(super) enum Commands {
         output: TextJsonArgs,
     },
     /// Find functions with no callers (dead code detection)
+    #[command(visible_alias = "deadcode")]
     #[cqs_cmd(group = "b", batch = "daemon")]
     Dead {
         #[command(flatten)]
@@ -679,6 +1227,37 @@ pub(super) enum Commands {
         #[command(flatten)]
         output: TextJsonArgs,
     },
+    /// Find copy-paste candidates: chunk clusters with near-identical embeddings
+    #[cqs_cmd(group = "b", batch = "cli")]
+    Dupes {
+        /// Minimum embedding similarity for a candidate pair
+        #[arg(short = 't', long, default_value_t = cqs::dupes::DEFAULT_DUPES_THRESHOLD, value_parser = parse_unit_f32)]
+        threshold: f32,
+        /// Minimum identifier-token overlap to confirm a candidate
+        #[arg(long, default_value_t = cqs::dupes::DEFAULT_DUPES_MIN_OVERLAP, value_parser = parse_unit_f32)]
+        min_overlap: f32,
+        /// Ignore chunks shorter than this many lines
+        #[arg(long, default_value_t = cqs::dupes::DEFAULT_DUPES_MIN_LINES)]
+        min_lines: u32,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
+    /// List TODO/FIXME/XXX comments with their author and age
+    #[cqs_cmd(group = "b", batch = "cli")]
+    Todos {
+        /// Only files under this directory, or matching this glob
+        #[arg(short = 'p', long)]
+        path: Option<String>,
+        /// Only markers owned by this team or person: CODEOWNERS owners or
+        /// the line's blame author (`me` = your git user.email)
+        #[arg(long, value_name = "NAME")]
+        owner: Option<String>,
+        /// Group markers by file or by owner
+        #[arg(long, value_enum, default_value = "file")]
+        group_by: args::TodoGroupBy,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
     /// Gather minimal code context to answer a question
     #[cqs_cmd(group = "b", batch = "daemon")]
     Gather {
@@ -688,6 +1267,7 @@ pub(super) enum Commands {
         output: TextJsonArgs,
     },
     /// Manage cross-project search registry
+    #[command(visible_alias = "projects")]
     #[cqs_cmd(group = "a", batch = "cli")]
     Project {
         #[command(subcommand)]
@@ -699,6 +1279,28 @@ pub(super) enum Commands {
         #[command(flatten)]
         output: TextJsonArgs,
     },
+    /// Compress stored chunk text with a dictionary trained on this index
+    ///
+    /// Trains a zstd dictionary on a sample of the index's chunks and stores
+    /// chunk text compressed against it; reads decode transparently and new
+    /// writes compress too. `--off` stores everything as plain text again.
+    /// `cqs bench content` measures what decoding costs per read.
+    #[cqs_cmd(group = "a", batch = "cli")]
+    Compress {
+        /// Store chunk text uncompressed again
+        #[arg(long)]
+        off: bool,
+        /// Dictionary size in KiB
+        #[arg(
+            long,
+            default_value_t = cqs::store::DEFAULT_DICT_KIB,
+            value_parser = clap::value_parser!(u16).range(1..=1024),
+            conflicts_with = "off"
+        )]
+        dict_kib: u16,
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
     /// Codebase quality snapshot — dead code, staleness, hotspots, coverage
     #[cqs_cmd(group = "b", batch = "daemon")]
     Health {
@@ -916,12 +1518,62 @@ pub(super) enum Commands {
         #[command(subcommand)]
         subcmd: CacheCommand,
     },
+    /// Run LLM passes on demand (`docgen` writes doc comments as a patch)
+    #[cfg(feature = "llm-summaries")]
+    #[cqs_cmd(group = "a", batch = "cli")]
+    Llm {
+        #[command(subcommand)]
+        subcmd: LlmCommand,
+    },
     /// Manage named slots — side-by-side full indexes under `.cqs/slots/<name>/`
     #[cqs_cmd(group = "a", batch = "cli")]
     Slot {
         #[command(subcommand)]
         subcmd: SlotCommand,
     },
+    /// Write a consistent single-file snapshot of the index database
+    ///
+    /// Uses `VACUUM INTO` under a SQLite read transaction, so it is safe to
+    /// run while `cqs watch` keeps indexing. Refuses to overwrite `--out`.
+    #[cqs_cmd(group = "a", batch = "cli")]
+    Backup {
+        /// Snapshot file to write
+        #[arg(long)]
+        out: std::path::PathBuf,
+        /// Flattens shared `TextJsonArgs` — see `Init` above.
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
+    /// Replace the index database with a `cqs backup` snapshot
+    ///
+    /// Checks integrity, schema, and embedding model/dimension before
+    /// touching anything, then stops the daemon, swaps the file in
+    /// atomically under `index.lock`, and deletes the now-stale HNSW/SPLADE
+    /// files (`cqs index` rebuilds them).
+    #[cqs_cmd(group = "a", batch = "cli")]
+    Restore {
+        /// Snapshot file written by `cqs backup`
+        snapshot: std::path::PathBuf,
+        /// Flattens shared `TextJsonArgs` — see `Init` above.
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
+    /// Install a synced copy of a primary index without stopping the daemon
+    ///
+    /// SOURCE is a primary's `index.db` (with its `-wal`, if any) or its
+    /// whole `.cqs` directory, e.g. a CI-published index fetched with
+    /// `rsync`. The copy is checkpointed, vetted like `cqs restore`, and
+    /// renamed over the live DB under `index.lock`; the daemon reopens on
+    /// the new file while in-flight queries finish on the old one. A
+    /// directory source also brings its HNSW/CAGRA/SPLADE files.
+    #[cqs_cmd(group = "a", batch = "cli")]
+    Replica {
+        /// Synced primary `index.db` or `.cqs` directory
+        source: std::path::PathBuf,
+        /// Flattens shared `TextJsonArgs` — see `Init` above.
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
     /// Daemon healthcheck — show daemon model, uptime, and counters
     ///
     /// Connects to the running daemon socket and prints its current state.
@@ -936,6 +1588,20 @@ pub(super) enum Commands {
         #[command(flatten)]
         output: TextJsonArgs,
     },
+    /// Re-read `.cqs.toml` in the running daemon
+    ///
+    /// Asks `cqs watch --serve` to reload its config and reports which keys
+    /// took effect and which were kept because they need a reindex or a
+    /// restart. Exits 1 if no daemon is running or the config doesn't parse.
+    /// Uses [`cqs::daemon_translate::daemon_reload_config`].
+    #[cqs_cmd(group = "a", batch = "cli")]
+    ReloadConfig {
+        /// Output as JSON.
+        ///
+        /// Flattens shared `TextJsonArgs` — see `Init` above.
+        #[command(flatten)]
+        output: TextJsonArgs,
+    },
     /// Watch-mode freshness — show whether the index is caught up
     ///
     /// Connects to the running `cqs watch --serve` daemon and reports the
@@ -1009,6 +1675,23 @@ pub(super) enum Commands {
         #[command(flatten)]
         args: super::commands::EvalCmdArgs,
     },
+    /// Time seeded search / embed / index workloads; throughput and latency
+    /// with a workload fingerprint, for comparing versions and configs
+    #[cqs_cmd(group = "b", batch = "cli")]
+    Bench {
+        #[command(subcommand)]
+        subcmd: super::commands::BenchCommand,
+    },
+    /// Run `cqs watch --serve` as a per-user service: install/uninstall/status.
+    ///
+    /// `install --user` writes a systemd user unit (Linux) or launchd agent
+    /// (macOS) for the current project, with restart-on-failure, then
+    /// enables and starts it.
+    #[cqs_cmd(group = "a", batch = "cli")]
+    Daemon {
+        #[command(subcommand)]
+        subcmd: DaemonCommand,
+    },
     /// Manage cqs git hooks: install/uninstall/fire/status.
     ///
     /// Hooks live in `.git/hooks/post-{checkout,merge,rewrite}` and post a
@@ -1022,6 +1705,18 @@ pub(super) enum Commands {
         #[command(subcommand)]
         subcmd: HookCommand,
     },
+    /// List languages, their grammars, and detected file counts
+    #[cqs_cmd(group = "a", batch = "cli")]
+    Languages {
+        #[command(subcommand)]
+        subcmd: LanguagesCommand,
+    },
+    /// Dump the JSON Schema of each JSON output type
+    #[cqs_cmd(group = "a", batch = "cli")]
+    Schema {
+        #[command(subcommand)]
+        subcmd: SchemaCommand,
+    },
     /// Show / list / swap the embedding model recorded in the index
     #[cqs_cmd(group = "a", batch = "cli")]
     Model {
@@ -1032,10 +1727,14 @@ pub(super) enum Commands {
     ///
     /// Binds to `127.0.0.1:8080` by default. Read-only — single-user
     /// local exploration. Pair `--open` to launch a browser tab.
-    /// Spec: `docs/plans/2026-04-21-cqs-serve-v1.md`.
+    /// `cqs serve token ...` manages the named tokens a shared server
+    /// accepts. Spec: `docs/plans/2026-04-21-cqs-serve-v1.md`.
     #[cfg(feature = "serve")]
     #[cqs_cmd(group = "a", batch = "cli")]
+    #[command(args_conflicts_with_subcommands = true)]
     Serve {
+        #[command(subcommand)]
+        action: Option<ServeCommand>,
         /// TCP port to bind. Default 8080.
         #[arg(long, default_value_t = 8080)]
         port: u16,
@@ -1064,12 +1763,34 @@ pub(super) enum Commands {
         /// localhost.
         #[arg(long)]
         no_auth: bool,
+        /// Also serve the gRPC API (`proto/cqs.proto`) on `--grpc-port`.
+        ///
+        /// Same index, handlers, and auth token as the HTTP server; calls
+        /// carry the token as `authorization: Bearer <token>` metadata.
+        /// Requires a build with the `grpc` feature.
+        #[arg(long)]
+        grpc: bool,
+        /// gRPC port, on the same bind address. Default 50051.
+        #[arg(long, default_value_t = 50051, requires = "grpc")]
+        grpc_port: u16,
+        /// Serve every `[[project]]` in this TOML file from one process.
+        ///
+        /// Each project keeps its own index, named tokens, daemon socket
+        /// and quotas; requests reach one by `/p/<name>/` prefix, by named
+        /// token, or by the cookie the `/p/<name>/` pages set.
+        #[arg(long, value_name = "FILE", conflicts_with_all = ["grpc", "open"])]
+        projects: Option<std::path::PathBuf>,
     },
 }
 
 // Re-export the subcommand types used in Commands variants
+#[cfg(feature = "llm-summaries")]
+pub(super) use super::commands::LlmCommand;
+#[cfg(feature = "serve")]
+pub(super) use super::commands::ServeCommand;
 pub(super) use super::commands::{
-    CacheCommand, HookCommand, ModelCommand, NotesCommand, ProjectCommand, RefCommand, SlotCommand,
+    CacheCommand, DaemonCommand, HookCommand, LanguagesCommand, ModelCommand, NotesCommand,
+    ProjectCommand, RefCommand, SchemaCommand, SessionCommand, SlotCommand,
 };
 
 impl Commands {
@@ -1094,7 +1815,7 @@ impl Commands {
     /// rather than leaking a JSON payload daemon-up.
     pub(crate) fn effective_output_format(&self) -> Option<OutputFormat> {
         match self {
-            Commands::Stats { output }
+            Commands::Stats { output, .. }
             | Commands::Health { output }
             | Commands::Refresh { output } => Some(output.effective_format()),
             Commands::Blame { output, .. }
@@ -1182,8 +1903,8 @@ impl Commands {
     /// Subcommand-bearing variants (`notes` / `cache` / `slot` / `ref` /
     /// `model`) classify per inner subcommand: `list` / `stats` /
     /// `active` / `show` are reads; `add` / `update` / `remove` /
-    /// `prune` / `clear` / `compact` / `create` / `promote` / `swap`
-    /// mutate. The match is intentionally explicit (no `..` wildcard on
+    /// `prune` / `clear` / `compact` / `create` / `promote` / `swap` /
+    /// `upgrade` mutate. The match is intentionally explicit (no `..` wildcard on
     /// the inner enums) so a new mutating subcommand fails to compile
     /// here rather than silently escaping the guard.
     pub(crate) fn mutates_index(&self) -> bool {
@@ -1195,7 +1916,10 @@ impl Commands {
             Commands::Init { .. }
             | Commands::Index { .. }
             | Commands::Watch { .. }
-            | Commands::Gc { .. } => true,
+            | Commands::Gc { .. }
+            | Commands::Compress { .. }
+            | Commands::Restore { .. }
+            | Commands::Replica { .. } => true,
             // `notes add|update|remove` write notes.toml + reindex; `list` reads.
             Commands::Notes { subcmd } => match subcmd {
                 NotesCommand::Add { .. }
@@ -1224,16 +1948,33 @@ impl Commands {
                 }
                 RefCommand::List { .. } => false,
             },
-            // `model swap` backs up + reindexes; `show`/`list` read.
+            // `model swap` backs up + reindexes, `model upgrade` stages or
+            // swaps vectors; `show`/`list` read.
             Commands::Model { subcmd } => match subcmd {
-                ModelCommand::Swap { .. } => true,
+                ModelCommand::Swap { .. } | ModelCommand::Upgrade { .. } => true,
                 ModelCommand::Show { .. } | ModelCommand::List { .. } => false,
             },
+            // `llm docgen` caches generated docs in the index's summaries table.
+            #[cfg(feature = "llm-summaries")]
+            Commands::Llm { subcmd } => match subcmd {
+                crate::cli::commands::LlmCommand::Docgen { .. } => true,
+            },
             // Everything else is a read, a daemon-forwarded query, or a
             // non-index-mutating utility (doctor, completions, eval, …).
             _ => false,
         }
     }
+
+    /// Which SQLite tuning profile this invocation opens the index under:
+    /// bulk `index`, long-running `watch` (and the daemon it serves), or an
+    /// interactive one-shot command. Picks the `[store]` built-in defaults.
+    pub(crate) fn store_profile(&self) -> cqs::store::StoreProfile {
+        match self {
+            Commands::Index { .. } => cqs::store::StoreProfile::Index,
+            Commands::Watch { .. } => cqs::store::StoreProfile::Daemon,
+            _ => cqs::store::StoreProfile::Search,
+        }
+    }
 }
 
 /// Classifier used by `try_daemon_query` to decide whether a CLI command can
@@ -1549,6 +2290,8 @@ mod tests {
             &["init"][..],
             &["index"][..],
             &["gc"][..],
+            &["compress"][..],
+            &["compress", "--off"][..],
             &["watch"][..],
             &["notes", "add", "n"][..],
             &["notes", "update", "n"][..],
@@ -1562,6 +2305,8 @@ mod tests {
             &["ref", "add", "name", "/src"][..],
             &["ref", "remove", "name"][..],
             &["model", "swap", "bge-large"][..],
+            &["restore", "snap.db"][..],
+            &["replica", "ci/index.db"][..],
         ] {
             assert!(
                 parse(argv).mutates_index(),
@@ -1584,6 +2329,7 @@ mod tests {
             &["read", "src/lib.rs"][..],
             &["doctor"][..],
             &["stats"][..],
+            &["backup", "--out", "snap.db"][..],
         ] {
             assert!(
                 !parse(argv).mutates_index(),
@@ -1592,6 +2338,27 @@ mod tests {
         }
     }
 
+    /// `store_profile()` picks the `[store]` built-in defaults.
+    #[test]
+    fn store_profile_follows_command_kind() {
+        use clap::Parser;
+        use cqs::store::StoreProfile;
+        let profile = |argv: &[&str]| {
+            let mut full = vec!["cqs"];
+            full.extend_from_slice(argv);
+            Cli::try_parse_from(full)
+                .unwrap_or_else(|e| panic!("argv {argv:?} must parse: {e}"))
+                .command
+                .expect("argv must produce a subcommand")
+                .store_profile()
+        };
+        assert_eq!(profile(&["index", "--force"]), StoreProfile::Index);
+        assert_eq!(profile(&["watch", "--serve"]), StoreProfile::Daemon);
+        assert_eq!(profile(&["watch"]), StoreProfile::Daemon);
+        assert_eq!(profile(&["callers", "foo"]), StoreProfile::Search);
+        assert_eq!(profile(&["gc"]), StoreProfile::Search);
+    }
+
     /// `--parent-index` and `-q`/`--quiet` are `global = true`: they parse
     /// after a subcommand. Pins the globalization so a regression
     /// (dropping `global = true`) fails here rather than at agent runtime.
@@ -1711,7 +2478,9 @@ mod tests {
         const EXPECTED_SUBCOMMANDS: &[&str] = &[
             "affected",
             "audit-mode",
+            "backup",
             "batch",
+            "bench",
             "blame",
             "brief",
             "cache",
@@ -1720,29 +2489,40 @@ mod tests {
             "chat",
             "ci",
             "completions",
+            "compress",
             "context",
             "convert",
+            "daemon",
             "dead",
             "deps",
             "diff",
+            "diff-index",
             "doctor",
             "drift",
+            "dupes",
             "eval",
+            "examples",
             "explain",
+            "explain-index",
             "export-model",
             "gather",
             "gc",
+            "graph",
             "health",
+            "history",
             "hook",
             "impact",
             "impact-diff",
             "index",
             "init",
+            "languages",
             "mcp",
             "model",
             "neighbors",
             "notes",
             "onboard",
+            "open",
+            "parse-worker",
             "ping",
             "plan",
             "project",
@@ -1751,7 +2531,11 @@ mod tests {
             "ref",
             "refresh",
             "related",
+            "reload-config",
+            "replica",
+            "restore",
             "review",
+            "schema",
             "scout",
             "serve",
             "similar",
@@ -1760,9 +2544,11 @@ mod tests {
             "stats",
             "status",
             "suggest",
+            "symbol",
             "task",
             "telemetry",
             "test-map",
+            "todos",
             "trace",
             "train-data",
             "train-pairs",
@@ -17,7 +17,17 @@ use super::telemetry;
 // attributes drive the routing.
 
 /// Run CLI with pre-parsed arguments (used when main.rs needs to inspect args first)
-pub fn run_with(cli: Cli) -> Result<()> {
+pub fn run_with(mut cli: Cli) -> Result<()> {
+    // `-P <NAME>` first: everything below resolves the project from the CWD.
+    if let Some(ref name) = cli.project {
+        enter_registered_project(name)?;
+    }
+    // `--format json` is `--json` spelled out.
+    if cli.format == Some(crate::cli::definitions::SearchFormat::Json) {
+        cli.json = true;
+    }
+    crate::cli::json_schema::select(cli.schema);
+
     // Log command for telemetry (opt-in via CQS_TELEMETRY=1)
     let project_cqs_dir = cqs::resolve_index_dir(&find_project_root());
     let telem_args: Vec<String> = std::env::args().collect();
@@ -42,6 +52,23 @@ pub fn run_with(cli: Cli) -> Result<()> {
     result
 }
 
+/// Change into the root of the registered project `name` (`-P`), like
+/// `git -C`. Every later [`find_project_root`] call — index dir, config,
+/// daemon socket — then resolves to that project.
+fn enter_registered_project(name: &str) -> Result<()> {
+    let _span = tracing::info_span!("enter_registered_project", name).entered();
+    let registry = cqs::ProjectRegistry::load()?;
+    let entry = registry.lookup(name)?;
+    std::env::set_current_dir(&entry.path).map_err(|e| {
+        anyhow::anyhow!(
+            "cannot enter project '{name}' at {}: {e}",
+            entry.path.display()
+        )
+    })?;
+    tracing::debug!(path = %entry.path.display(), "Entered registered project");
+    Ok(())
+}
+
 /// Inner dispatch body — separated from [`run_with`] so the outer can
 /// uniformly observe the completion outcome via [`telemetry::log_command_complete`].
 /// All early returns from the body land back here as the inner function's
@@ -91,11 +118,88 @@ fn run_with_dispatch(
     let config = cqs::config::Config::load(&find_project_root());
     apply_config_defaults(&mut cli, &config);
 
+    // `[log]` first, so everything after lands in the configured sink.
+    // Flags and env pinned at `logging::init` still win.
+    if let Some(ref log) = config.log {
+        let command = cli.command.as_ref().map_or("search", |c| c.variant_name());
+        cqs::logging::set_from_config(log, command, &find_project_root());
+    }
+
+    // `offline = true` refuses model downloads and remote LLM endpoints.
+    // Env still wins.
+    if let Some(offline) = config.offline {
+        cqs::offline::set_from_config(offline);
+    }
+    // `llm_exclude` keeps matching files' chunks away from every provider.
+    #[cfg(feature = "llm-summaries")]
+    if let Some(ref patterns) = config.llm_exclude {
+        cqs::llm::exclude::set_from_config(patterns);
+    }
     // Wire the [scoring] config section to the RRF K override so a user
     // writing `[scoring] rrf_k = 40` in `.cqs.toml` is honored.
     if let Some(ref scoring) = config.scoring {
         cqs::search::scoring::set_rrf_k_from_config(scoring);
     }
+    // `[index] attach_doc_comments` decides whether the parser folds a
+    // symbol's leading comment into its chunk. Env still wins.
+    if let Some(attach) = config.index.as_ref().and_then(|ic| ic.attach_doc_comments) {
+        cqs::parser::set_doc_attachment_from_config(attach);
+    }
+    // `[index] follow_symlinks` drives both the file walk and the watcher,
+    // so they agree on what a symlinked path means. Env still wins.
+    if let Some(policy) = config.index.as_ref().and_then(|ic| ic.follow_symlinks) {
+        cqs::symlinks::set_policy_from_config(policy);
+    }
+    // `[index] sandbox_parsing` moves parsing into limited worker
+    // processes. `--sandbox` and env still win.
+    if let Some(sandbox) = config.index.as_ref().and_then(|ic| ic.sandbox_parsing) {
+        cqs::parser::sandbox::set_enabled_from_config(sandbox);
+    }
+    // `[index] blame_authors` gates the index-time `git blame` pass. Env
+    // still wins.
+    if let Some(blame) = config.index.as_ref().and_then(|ic| ic.blame_authors) {
+        cqs::ownership::set_blame_from_config(blame);
+    }
+    // `[index] commit_history` sizes the commit-message window. Env still
+    // wins.
+    if let Some(limit) = config.index.as_ref().and_then(|ic| ic.commit_history) {
+        cqs::commits::set_history_from_config(limit);
+    }
+    // `[index.vector]` picks an external vector store. Env still wins.
+    if let Some(vector) = config.index.as_ref().and_then(|ic| ic.vector.as_ref()) {
+        cqs::vector_backend::set_from_config(vector);
+    }
+    // `[languages] overrides` feeds every detection site (walk, watcher,
+    // `--only`, parser), so it has to land before any of them run.
+    if let Some(ref languages) = config.languages {
+        cqs::language::overrides::set_overrides_from_config(&languages.overrides);
+    }
+    // `[[grammar]]` plugins from the user config. Registered only; each
+    // library is loaded the first time a walk or parse asks for it.
+    cqs::language::plugin::set_plugins_from_config(&config.grammars);
+    // `[secrets]` extra patterns and the storage switch. Sandboxed parse
+    // workers load the same config, so they mask identically.
+    if let Some(ref secrets) = config.secrets {
+        cqs::secrets::set_from_config(secrets);
+    }
+    // `[rank_hook]` rescores search candidates. User config only; env
+    // still wins.
+    if let Some(ref hook) = config.rank_hook {
+        cqs::search::rank_hook::set_from_config(hook);
+    }
+    // `llm_query_translate` picks the search translation mode. Flags and
+    // env still win.
+    if let Some(mode) = config.llm_query_translate {
+        crate::cli::commands::search::translate::set_translate_from_config(mode);
+    }
+    // `[store]` pragmas and pool sizing, with built-in defaults picked by
+    // what this process is. Must run before the first store open.
+    cqs::store::configure_store(
+        cli.command
+            .as_ref()
+            .map_or(cqs::store::StoreProfile::Search, |c| c.store_profile()),
+        config.store.as_ref(),
+    );
 
     // Resolve embedding model config once. Priority:
     //   1. `--model` CLI flag           (explicit override)
@@ -275,6 +379,15 @@ fn run_with_dispatch(
         return result;
     }
 
+    // `--index <NAME>`: federated search over registered projects. It needs
+    // no store of this project's, so it runs before the read-only open (and
+    // works outside any project).
+    if cli.command.is_none() && !cli.indexes.is_empty() {
+        if let Some(query) = cli.query.as_deref() {
+            return crate::cli::commands::cmd_query_federated(&cli, query);
+        }
+    }
+
     let ctx = crate::cli::CommandContext::open_readonly(&cli)?;
     dispatch_group_b(&cli, &ctx, project_cqs_dir)
 }
@@ -409,9 +522,43 @@ fn cqs_slot_env_pins_slot() -> bool {
 /// `every_top_level_cli_arg_is_classified_for_daemon_translation` — adding a
 /// top-level flag without classifying it here or there fails that test
 /// instead of failing daemon-up at runtime.
+///
+/// `at` is listed here rather than as a search knob because a `--at <rev>`
+/// query never reaches the daemon at all: the daemon serves the working-tree
+/// index only, so `try_daemon_query` keeps snapshot searches on the CLI path.
+/// `indexes` (`--index`) likewise: a federated query opens other projects'
+/// stores, which the daemon never loads. `project` (`-P`) is consumed by
+/// [`run_with`] changing directory; the daemon it then reaches is already
+/// that project's. `schema` (`--schema`) is applied client-side to whatever
+/// shape the daemon sends. `log_format` / `log_file` configure this
+/// process's logging; the daemon logs under its own settings. `trace_out`
+/// (`--trace`) keeps the command off the daemon entirely. `stream`
+/// (`--stream`) and `open_first` (`--open-first`) keep the search on the CLI
+/// path too: one writes events as they finalize, the other launches an
+/// editor on this desktop. `format` (`--format`) is rendering: `json` sets
+/// `--json` before dispatch, and `prompt` is text mode.
 #[cfg(unix)]
-const PROCESS_LOCAL_ARG_IDS: &[&str] =
-    &["json", "quiet", "model", "slot", "verbose", "parent_index"];
+const PROCESS_LOCAL_ARG_IDS: &[&str] = &[
+    "json",
+    "quiet",
+    "model",
+    "slot",
+    "verbose",
+    "parent_index",
+    "at",
+    "indexes",
+    "project",
+    "explain",
+    "wait",
+    "lock_timeout",
+    "schema",
+    "log_format",
+    "log_file",
+    "trace_out",
+    "stream",
+    "open_first",
+    "format",
+];
 
 /// Top-level `Cli` arg IDs that are search knobs, mirrored spelling-for-
 /// spelling by `args::SearchArgs` (plus `LimitArg` for `limit`). Forwarded
@@ -425,27 +572,45 @@ const PROCESS_LOCAL_ARG_IDS: &[&str] =
 const SEARCH_KNOB_ARG_IDS: &[&str] = &[
     "limit",
     "threshold",
+    "min_score",
     "name_boost",
     "lang",
     "include_type",
     "exclude_type",
     "path",
     "pattern",
+    "grep",
+    "signature",
+    "owner",
+    "issue",
+    "search_in",
     "name_only",
     "rrf",
     "include_docs",
+    "with_commits",
+    "hide_deprecated",
+    "include_generated",
+    "relax",
     "reranker",
     "splade",
     "splade_alpha",
     "no_content",
     "context",
+    "snippet_lines",
     "expand_parent",
+    "with_tests",
+    "with_owners",
     "ref_name",
     "include_refs",
+    "changed_since",
     "tokens",
     "no_stale_check",
     "no_demote",
     "no_rank_signals",
+    "hyde",
+    "no_hyde",
+    "translate",
+    "no_translate",
     "overlay",
     "no_overlay",
 ];
@@ -558,6 +723,40 @@ fn try_daemon_query(cqs_dir: &std::path::Path, cli: &Cli) -> Result<Option<Strin
         return Ok(None);
     }
 
+    // `--trace` times spans in this process; a daemon answer would leave the
+    // trace with nothing but the socket round trip.
+    if cli.trace_out.is_some() {
+        tracing::debug!("--trace invocation kept on CLI path");
+        return Ok(None);
+    }
+
+    // `--at <rev>` searches a git-rev snapshot index the daemon never loads
+    // (it serves the working tree). Forwarding would silently answer from HEAD.
+    if cli.command.is_none() && cli.at.is_some() {
+        tracing::debug!("--at snapshot search kept on CLI path");
+        return Ok(None);
+    }
+
+    // `--index <NAME>` federates over other projects' stores; the daemon
+    // serves this project only.
+    if cli.command.is_none() && !cli.indexes.is_empty() {
+        tracing::debug!("--index federated search kept on CLI path");
+        return Ok(None);
+    }
+
+    // `--stream` emits events as the search progresses; the daemon wire is
+    // one request, one response, so it would arrive all at once.
+    if cli.command.is_none() && cli.stream {
+        tracing::debug!("--stream search kept on CLI path");
+        return Ok(None);
+    }
+
+    // `--open-first` launches an editor, which only this process can do.
+    if cli.command.is_none() && cli.open_first {
+        tracing::debug!("--open-first search kept on CLI path");
+        return Ok(None);
+    }
+
     // `--stdin` invocations (review / ci / impact-diff with a piped diff) stay
     // on the CLI path even in JSON mode. The daemon reads its diff in the
     // *server* process and never sees the client's stdin, so forwarding would
@@ -574,6 +773,15 @@ fn try_daemon_query(cqs_dir: &std::path::Path, cli: &Cli) -> Result<Option<Strin
         }
     }
 
+    // `stats --hot` reads `.cqs/access_stats.db`, which the daemon's `stats`
+    // handler doesn't serve; forwarding would answer with index statistics.
+    if matches!(
+        cli.command,
+        Some(crate::cli::definitions::Commands::Stats { hot: true, .. })
+    ) {
+        return Ok(None);
+    }
+
     let sock_path = super::daemon_socket_path(cqs_dir);
     if !sock_path.exists() {
         return Ok(None);
@@ -819,7 +1027,11 @@ fn try_daemon_query(cqs_dir: &std::path::Path, cli: &Cli) -> Result<Option<Strin
             // a daemon happened to serve the query. Translate before
             // printing; anything that isn't a slim envelope prints verbatim.
             other => match cqs::daemon_translate::classify_slim_envelope(other) {
-                Some(cqs::daemon_translate::SlimEnvelope::Data { payload, meta }) => {
+                Some(cqs::daemon_translate::SlimEnvelope::Data {
+                    payload,
+                    meta,
+                    schema_version,
+                }) => {
                     // PARITY: the daemon's search handler runs the per-origin
                     // staleness check (`attach_stale_origins_meta` in
                     // `batch::handlers::search`) and reports stale files via
@@ -832,7 +1044,17 @@ fn try_daemon_query(cqs_dir: &std::path::Path, cli: &Cli) -> Result<Option<Strin
                     if !cli.quiet {
                         crate::cli::staleness::print_stale_warning_from_meta(meta);
                     }
-                    match crate::cli::json_envelope::daemon_payload_to_cli_text(payload, meta) {
+                    // The daemon renders its own schema default; bring the
+                    // payload to this invocation's `--schema`. No marker is a
+                    // daemon from before versioning (v1); a version this
+                    // binary doesn't know is passed through as current.
+                    use crate::cli::json_schema::SchemaVersion;
+                    let wire = match schema_version {
+                        None => SchemaVersion::V1,
+                        Some(n) => SchemaVersion::from_number(n).unwrap_or(SchemaVersion::CURRENT),
+                    };
+                    match crate::cli::json_envelope::daemon_payload_to_cli_text(payload, meta, wire)
+                    {
                         Ok(s) => s,
                         Err(e) => {
                             tracing::warn!(
@@ -1389,6 +1611,11 @@ mod tests {
                     Just(vec![format!("-t={val}")]),
                 ]
             }),
+            // `--min-score`: finite f32 or a percentage.
+            (0u32..100).prop_flat_map(|h| prop_oneof![
+                Just(vec!["--min-score".to_string(), format!("0.{:02}", h)]),
+                Just(vec!["--min-score".to_string(), format!("{h}%")]),
+            ]),
             // `--name-boost`: unit f32 [0,1].
             (0u32..=100)
                 .prop_map(|h| { vec!["--name-boost".to_string(), format!("0.{:02}", h.min(99))] }),
@@ -1406,6 +1633,17 @@ mod tests {
             ]),
             // `--pattern`: string value.
             eq_str_value().prop_map(|v| vec!["--pattern".to_string(), v]),
+            // `--grep`: string value (regex; parsed, not compiled, by clap).
+            eq_str_value().prop_map(|v| vec!["--grep".to_string(), v]),
+            // `--signature`: string value (shape; parsed at query time).
+            eq_str_value().prop_map(|v| vec!["--signature".to_string(), v]),
+            // `--owner`: string value.
+            eq_str_value().prop_map(|v| vec!["--owner".to_string(), v]),
+            // `--issue`: string value (canonicalized at query time).
+            eq_str_value().prop_map(|v| vec!["--issue".to_string(), v]),
+            // `--in`: value-enum (both|code|comments).
+            prop_oneof![Just("both"), Just("code"), Just("comments")]
+                .prop_map(|m| vec!["--in".to_string(), m.to_string()]),
             // `--include-type` / `--exclude-type`: Option<Vec<String>>.
             eq_str_value().prop_map(|v| vec!["--include-type".to_string(), v]),
             eq_str_value().prop_map(|v| vec!["--exclude-type".to_string(), v]),
@@ -1418,23 +1656,33 @@ mod tests {
                 Just(vec!["--context".to_string(), n.to_string()]),
                 Just(vec!["-C".to_string(), n.to_string()]),
             ]),
+            // `--snippet-lines`: nonzero usize.
+            (1u32..999).prop_map(|n| vec!["--snippet-lines".to_string(), n.to_string()]),
             // `--splade-alpha`: finite f32.
             (0u32..=100)
                 .prop_map(|h| vec!["--splade-alpha".to_string(), format!("0.{:02}", h.min(99))]),
-            // `--reranker`: value-enum (none|onnx).
-            prop_oneof![Just("none"), Just("onnx")]
+            // `--reranker`: value-enum (none|onnx|late).
+            prop_oneof![Just("none"), Just("onnx"), Just("late")]
                 .prop_map(|m| vec!["--reranker".to_string(), m.to_string()]),
             // Boolean search knobs (no value). Each forwards verbatim.
             Just(vec!["--rrf".to_string()]),
             Just(vec!["--name-only".to_string()]),
             Just(vec!["--include-docs".to_string()]),
+            Just(vec!["--with-commits".to_string()]),
+            Just(vec!["--hide-deprecated".to_string()]),
+            Just(vec!["--include-generated".to_string()]),
+            Just(vec!["--relax".to_string()]),
             Just(vec!["--splade".to_string()]),
             Just(vec!["--no-content".to_string()]),
             Just(vec!["--expand-parent".to_string()]),
+            Just(vec!["--with-tests".to_string()]),
+            Just(vec!["--with-owners".to_string()]),
             Just(vec!["--include-refs".to_string()]),
             Just(vec!["--no-stale-check".to_string()]),
             Just(vec!["--no-demote".to_string()]),
             Just(vec!["--no-rank-signals".to_string()]),
+            Just(vec!["--hyde".to_string()]),
+            Just(vec!["--translate".to_string()]),
         ]
     }
 
@@ -1697,27 +1945,59 @@ mod tests {
             // A divergence here is the bug class the structural seed can't see.
             prop_assert_eq!(sa.limit_arg.limit, cli.limit, "limit: argv={:?}", argv);
             prop_assert_eq!(sa.threshold, cli.threshold, "threshold: argv={:?}", argv);
+            prop_assert_eq!(sa.min_score, cli.min_score, "min_score: argv={:?}", argv);
             prop_assert_eq!(sa.name_boost, cli.name_boost, "name_boost: argv={:?}", argv);
             prop_assert_eq!(&sa.lang, &cli.lang, "lang: argv={:?}", argv);
             prop_assert_eq!(&sa.include_type, &cli.include_type, "include_type: argv={:?}", argv);
             prop_assert_eq!(&sa.exclude_type, &cli.exclude_type, "exclude_type: argv={:?}", argv);
             prop_assert_eq!(&sa.path, &cli.path, "path: argv={:?}", argv);
             prop_assert_eq!(&sa.pattern, &cli.pattern, "pattern: argv={:?}", argv);
+            prop_assert_eq!(&sa.grep, &cli.grep, "grep: argv={:?}", argv);
+            prop_assert_eq!(&sa.signature, &cli.signature, "signature: argv={:?}", argv);
+            prop_assert_eq!(&sa.owner, &cli.owner, "owner: argv={:?}", argv);
+            prop_assert_eq!(&sa.issue, &cli.issue, "issue: argv={:?}", argv);
+            prop_assert_eq!(sa.search_in, cli.search_in, "search_in: argv={:?}", argv);
             prop_assert_eq!(sa.name_only, cli.name_only, "name_only: argv={:?}", argv);
             prop_assert_eq!(sa.rrf, cli.rrf, "rrf: argv={:?}", argv);
             prop_assert_eq!(sa.include_docs, cli.include_docs, "include_docs: argv={:?}", argv);
+            prop_assert_eq!(sa.with_commits, cli.with_commits, "with_commits: argv={:?}", argv);
+            prop_assert_eq!(
+                sa.hide_deprecated,
+                cli.hide_deprecated,
+                "hide_deprecated: argv={:?}",
+                argv
+            );
+            prop_assert_eq!(
+                sa.include_generated,
+                cli.include_generated,
+                "include_generated: argv={:?}",
+                argv
+            );
+            prop_assert_eq!(sa.relax, cli.relax, "relax: argv={:?}", argv);
             prop_assert_eq!(sa.reranker, cli.reranker, "reranker: argv={:?}", argv);
             prop_assert_eq!(sa.splade, cli.splade, "splade: argv={:?}", argv);
             prop_assert_eq!(sa.splade_alpha, cli.splade_alpha, "splade_alpha: argv={:?}", argv);
             prop_assert_eq!(sa.no_content, cli.no_content, "no_content: argv={:?}", argv);
             prop_assert_eq!(sa.context, cli.context, "context: argv={:?}", argv);
+            prop_assert_eq!(sa.snippet_lines, cli.snippet_lines, "snippet_lines: argv={:?}", argv);
             prop_assert_eq!(sa.expand_parent, cli.expand_parent, "expand_parent: argv={:?}", argv);
+            prop_assert_eq!(sa.with_tests, cli.with_tests, "with_tests: argv={:?}", argv);
+            prop_assert_eq!(sa.with_owners, cli.with_owners, "with_owners: argv={:?}", argv);
             prop_assert_eq!(&sa.ref_name, &cli.ref_name, "ref_name: argv={:?}", argv);
             prop_assert_eq!(sa.include_refs, cli.include_refs, "include_refs: argv={:?}", argv);
             prop_assert_eq!(sa.tokens, cli.tokens, "tokens: argv={:?}", argv);
             prop_assert_eq!(sa.no_stale_check, cli.no_stale_check, "no_stale_check: argv={:?}", argv);
             prop_assert_eq!(sa.no_demote, cli.no_demote, "no_demote: argv={:?}", argv);
             prop_assert_eq!(sa.no_rank_signals, cli.no_rank_signals, "no_rank_signals: argv={:?}", argv);
+            prop_assert_eq!(sa.hyde, cli.hyde, "hyde: argv={:?}", argv);
+            prop_assert_eq!(sa.no_hyde, cli.no_hyde, "no_hyde: argv={:?}", argv);
+            prop_assert_eq!(sa.translate, cli.translate, "translate: argv={:?}", argv);
+            prop_assert_eq!(
+                sa.no_translate,
+                cli.no_translate,
+                "no_translate: argv={:?}",
+                argv
+            );
             prop_assert_eq!(sa.overlay.overlay, cli.overlay, "overlay: argv={:?}", argv);
             prop_assert_eq!(
                 sa.overlay.no_overlay,
@@ -7,19 +7,64 @@ use anyhow::{Context, Result};
 use colored::Colorize;
 use serde::Serialize;
 
+use cqs::calibration::Calibration;
+use cqs::ownership::ChunkOwnership;
+use cqs::parser::issue_refs::IssueRef;
 use cqs::reference::TaggedResult;
-use cqs::store::{ParentContext, UnifiedResult};
+use cqs::store::{CommitMeta, ParentContext, UnifiedResult};
+
+use super::commands::search::query::Relaxation;
+use super::commands::{link_label, TestMapEntry};
+
+/// Linked tests per result, keyed by chunk id (`--with-tests`).
+pub type ResultTests = HashMap<String, Vec<TestMapEntry>>;
+
+/// Ownership per result, keyed by chunk id (`--with-owners`).
+pub type ResultOwners = HashMap<String, ChunkOwnership>;
+
+/// Issue/PR references per result, keyed by chunk id.
+pub type ResultIssueRefs = HashMap<String, Vec<IssueRef>>;
+
+/// Commit metadata per commit result, keyed by chunk id.
+pub type ResultCommits = HashMap<String, CommitMeta>;
+
+/// Optional per-result attachments layered onto search output. Each is
+/// `Some` only when its flag is set (issue references: whenever the surface
+/// resolved them); results absent from a map get nothing.
+#[derive(Clone, Copy, Default)]
+pub struct ResultExtras<'a> {
+    /// Linked tests (`--with-tests`).
+    pub tests: Option<&'a ResultTests>,
+    /// CODEOWNERS owners and blame authors (`--with-owners`).
+    pub owners: Option<&'a ResultOwners>,
+    /// Issue/PR references found in the result's comments.
+    pub issue_refs: Option<&'a ResultIssueRefs>,
+    /// Sha, author, date, and touched files of commit results.
+    pub commits: Option<&'a ResultCommits>,
+    /// Confidence curve for the results' score scale, when one is stored.
+    pub calibration: Option<&'a Calibration>,
+}
 
 /// One search result in the CLI search JSON, the typed schema source for the
 /// per-result shape emitted by `cqs <query> --json` (and `--name-only`,
 /// `--ref`, `--include-refs`).
 ///
 /// The base chunk fields and the skip-when-default behavior
-/// (`has_parent`, `trust_level`, `injection_flags`, `reference_name`,
-/// `type: "code"`) are owned by `UnifiedResult::to_json_with_origin` in the
-/// store layer — the single serializer for a chunk's trust-aware wire shape.
+/// (`has_parent`, `has_doc`, `deprecated`, `boilerplate`, `trust_level`,
+/// `injection_flags`, `reference_name`, `type: "code"`) are owned by
+/// `UnifiedResult::to_json_with_origin` in the store layer — the single
+/// serializer for a chunk's trust-aware wire shape.
 /// This struct layers the search-display-only fields on top:
 ///   - `parent_*`: parent context emitted under `--expand-parent`.
+///   - `tests`: linked tests emitted under `--with-tests`, in the
+///     `cqs test-map` entry shape.
+///   - `ownership`: CODEOWNERS owners and top blame authors emitted under
+///     `--with-owners`.
+///   - `issue_refs`: issue/PR references from the chunk's comments, emitted
+///     whenever it has any.
+///   - `commit`: sha, author, date, and touched files of a commit result.
+///   - `confidence`: the score through the stored calibration curve
+///     (`cqs eval --calibrate`), when the search's model and scale have one.
 ///   - `source`: originating reference name under `--include-refs` / `--ref`
 ///     (distinct from the typed `reference_name` that `to_json_with_origin`
 ///     already carries — kept for backward-compatible consumers).
@@ -38,6 +83,16 @@ pub struct SearchResultOutput<'a> {
     /// Parent context (small-to-big retrieval) when `--expand-parent` is set
     /// and the chunk has a resolved parent.
     parent: Option<&'a ParentContext>,
+    /// Linked tests when `--with-tests` is set and the result has any.
+    tests: Option<&'a [TestMapEntry]>,
+    /// Ownership when `--with-owners` is set and the result has any.
+    ownership: Option<&'a ChunkOwnership>,
+    /// Issue/PR references when the result's comments have any.
+    issue_refs: Option<&'a [IssueRef]>,
+    /// Commit metadata when the result is an indexed commit.
+    commit: Option<&'a CommitMeta>,
+    /// Calibrated probability that this is the chunk the query was after.
+    confidence: Option<f32>,
     /// Originating reference name surfaced as the legacy `source` field
     /// (multi-index / `--ref` paths only).
     source: Option<&'a str>,
@@ -51,12 +106,28 @@ impl<'a> SearchResultOutput<'a> {
         // the canonical store serializer so this surface can never drift from
         // the rest of the CLI on the injection/trust schema.
         let mut obj = self.result.to_json_with_origin(self.ref_name);
+        if let Some(confidence) = self.confidence {
+            obj["confidence"] = serde_json::json!(confidence);
+        }
         if let Some(parent) = self.parent {
+            obj["parent_id"] = serde_json::json!(parent.id);
             obj["parent_name"] = serde_json::json!(parent.name);
             obj["parent_content"] = serde_json::json!(parent.content);
             obj["parent_line_start"] = serde_json::json!(parent.line_start);
             obj["parent_line_end"] = serde_json::json!(parent.line_end);
         }
+        if let Some(tests) = self.tests {
+            obj["tests"] = serde_json::json!(tests);
+        }
+        if let Some(ownership) = self.ownership {
+            obj["ownership"] = serde_json::json!(ownership);
+        }
+        if let Some(issue_refs) = self.issue_refs {
+            obj["issue_refs"] = serde_json::json!(issue_refs);
+        }
+        if let Some(commit) = self.commit {
+            obj["commit"] = serde_json::json!(commit);
+        }
         if let Some(source) = self.source {
             obj["source"] = serde_json::json!(source);
         }
@@ -338,13 +409,98 @@ pub fn read_context_lines(
     Ok((before, after))
 }
 
+/// One-line text form of a result's ownership:
+/// `owners: @acme/payments · authors: Ada 67%, Grace 33%`.
+fn ownership_line(o: &ChunkOwnership) -> String {
+    let mut parts = Vec::new();
+    if !o.owners.is_empty() {
+        parts.push(format!("owners: {}", o.owners.join(" ")));
+    }
+    if !o.authors.is_empty() {
+        let authors: Vec<String> = o
+            .authors
+            .iter()
+            .map(|a| format!("{} {:.0}%", a.name, a.share * 100.0))
+            .collect();
+        parts.push(format!("authors: {}", authors.join(", ")));
+    }
+    parts.join(" · ")
+}
+
+/// Text form of a commit result: `commit 3f2a9c1b0d4e · Ada · 2024-05-01`,
+/// then the files it touched (first five, then a count).
+fn commit_lines(c: &CommitMeta) -> Vec<String> {
+    const SHOWN_FILES: usize = 5;
+    let date = chrono::DateTime::from_timestamp(c.committed_at, 0)
+        .map(|d| d.format("%Y-%m-%d").to_string())
+        .unwrap_or_default();
+    let mut lines = vec![format!(
+        "commit {} · {} · {}",
+        &c.sha[..c.sha.len().min(12)],
+        c.author,
+        date
+    )];
+    if !c.files.is_empty() {
+        let mut files = c.files[..c.files.len().min(SHOWN_FILES)].join(", ");
+        if c.files.len() > SHOWN_FILES {
+            files.push_str(&format!(" (+{} more)", c.files.len() - SHOWN_FILES));
+        }
+        lines.push(format!("files: {files}"));
+    }
+    lines
+}
+
+/// Lines of chunk text shown per result in text output when
+/// `--snippet-lines` is not given.
+pub const DEFAULT_SNIPPET_LINES: usize = 10;
+
+/// Print a result's chunk text cut to `snippet_lines` (default
+/// [`DEFAULT_SNIPPET_LINES`]) at a statement boundary, with the marker line
+/// dimmed.
+fn print_snippet(content: &str, snippet_lines: Option<usize>) {
+    let snippet = cqs::snippet::truncate(content, snippet_lines.unwrap_or(DEFAULT_SNIPPET_LINES));
+    println!("{}", sanitize_for_terminal(&snippet.text));
+    if let Some(marker) = snippet.marker() {
+        println!("{}", marker.dimmed());
+    }
+}
+
+/// Cut the `content` of each result in a search envelope to `max_lines`
+/// (`--snippet-lines`): the kept lines plus the marker line, with the number
+/// of lines cut as `content_cut_lines`. Results that fit are left alone.
+pub fn apply_snippet_lines(value: &mut serde_json::Value, max_lines: usize) {
+    let Some(results) = value.get_mut("results").and_then(|r| r.as_array_mut()) else {
+        return;
+    };
+    for r in results {
+        let snippet = match r.get("content").and_then(|c| c.as_str()) {
+            Some(content) => cqs::snippet::truncate(content, max_lines),
+            None => continue,
+        };
+        if snippet.cut_lines > 0 {
+            r["content"] = serde_json::json!(snippet.with_marker());
+            r["content_cut_lines"] = serde_json::json!(snippet.cut_lines);
+        }
+    }
+}
+
+/// Record in a search envelope the filters `--relax` dropped to find its
+/// results, as `relaxed`; nothing when the query ran as given.
+pub fn apply_relaxed(value: &mut serde_json::Value, relaxed: &[Relaxation]) {
+    if !relaxed.is_empty() {
+        value["relaxed"] = serde_json::json!(relaxed);
+    }
+}
+
 /// Display unified search results (code + notes)
 pub fn display_unified_results(
     results: &[UnifiedResult],
     root: &Path,
     no_content: bool,
     context: Option<usize>,
+    snippet_lines: Option<usize>,
     parents: Option<&HashMap<String, ParentContext>>,
+    extras: ResultExtras<'_>,
 ) -> Result<()> {
     for result in results {
         match result {
@@ -357,19 +513,59 @@ pub fn display_unified_results(
                 } else {
                     ""
                 };
+                let deprecated_tag = if r.chunk.deprecated {
+                    " [deprecated]"
+                } else {
+                    ""
+                };
+                // Only present under --include-generated.
+                let boilerplate_tag = r
+                    .chunk
+                    .boilerplate
+                    .map(|b| format!(" [{b}]"))
+                    .unwrap_or_default();
                 let header = format!(
-                    "{}:{} ({} {}) [{}] [{:.2}]{}",
+                    "{}:{} ({} {}) [{}] [{:.2}]{}{}{}",
                     rel_path,
                     r.chunk.line_start,
                     r.chunk.chunk_type,
                     r.chunk.name,
                     r.chunk.language,
                     r.score,
-                    parent_tag
+                    parent_tag,
+                    deprecated_tag,
+                    boilerplate_tag
                 );
 
                 println!("{}", header.cyan());
 
+                // Linked tests under --with-tests, shown even with --no-content.
+                for t in extras
+                    .tests
+                    .and_then(|t| t.get(&r.chunk.id))
+                    .into_iter()
+                    .flatten()
+                {
+                    let line = format!(
+                        "  test: {} ({}:{}) [{}]",
+                        t.name,
+                        t.file,
+                        t.line_start,
+                        link_label(t.linked_by, t.call_depth)
+                    );
+                    println!("{}", line.dimmed());
+                }
+                // Ownership under --with-owners, likewise.
+                if let Some(o) = extras.owners.and_then(|o| o.get(&r.chunk.id)) {
+                    println!("{}", format!("  {}", ownership_line(o)).dimmed());
+                }
+                // Commit results: who, when, and what they touched.
+                if let Some(c) = extras.commits.and_then(|c| c.get(&r.chunk.id)) {
+                    for line in commit_lines(c) {
+                        println!("{}", format!("  {}", sanitize_for_terminal(&line)).dimmed());
+                    }
+                }
+
                 if !no_content {
                     println!("{}", "─".repeat(50));
 
@@ -399,15 +595,7 @@ pub fn display_unified_results(
                         }
                     }
 
-                    // Show signature or truncated content
-                    if r.chunk.content.lines().count() <= 10 {
-                        println!("{}", sanitize_for_terminal(&r.chunk.content));
-                    } else {
-                        for line in r.chunk.content.lines().take(8) {
-                            println!("{}", sanitize_for_terminal(line));
-                        }
-                        println!("    ...");
-                    }
+                    print_snippet(&r.chunk.content, snippet_lines);
 
                     // Print after context if requested
                     if let Some(n) = context {
@@ -474,6 +662,7 @@ pub fn build_unified_results_value(
     results: &[UnifiedResult],
     query: &str,
     parents: Option<&HashMap<String, ParentContext>>,
+    extras: ResultExtras<'_>,
     token_info: Option<(usize, usize)>,
 ) -> serde_json::Value {
     let json_results: Vec<_> = results
@@ -481,15 +670,8 @@ pub fn build_unified_results_value(
         .map(|r| {
             // Per-result schema lives in `SearchResultOutput`; it delegates the
             // chunk base + skip-when-default trust fields to the store serializer
-            // and layers parent context on top.
-            let UnifiedResult::Code(sr) = r;
-            SearchResultOutput {
-                result: r,
-                ref_name: None,
-                parent: parents.and_then(|p| p.get(&sr.chunk.id)),
-                source: None,
-            }
-            .to_value()
+            // and layers parent context, linked tests, and ownership on top.
+            unified_result_value(r, parents, extras)
         })
         .collect();
 
@@ -503,6 +685,35 @@ pub fn build_unified_results_value(
     })
 }
 
+/// One project result as its JSON value — the per-result shape of
+/// [`build_unified_results_value`], for surfaces that emit results one at a
+/// time (`--stream`).
+pub fn unified_result_value(
+    result: &UnifiedResult,
+    parents: Option<&HashMap<String, ParentContext>>,
+    extras: ResultExtras<'_>,
+) -> serde_json::Value {
+    let UnifiedResult::Code(sr) = result;
+    SearchResultOutput {
+        result,
+        ref_name: None,
+        parent: parents.and_then(|p| p.get(&sr.chunk.id)),
+        tests: extras
+            .tests
+            .and_then(|t| t.get(&sr.chunk.id))
+            .map(Vec::as_slice),
+        ownership: extras.owners.and_then(|o| o.get(&sr.chunk.id)),
+        issue_refs: extras
+            .issue_refs
+            .and_then(|i| i.get(&sr.chunk.id))
+            .map(Vec::as_slice),
+        commit: extras.commits.and_then(|c| c.get(&sr.chunk.id)),
+        confidence: extras.calibration.map(|c| c.confidence(sr.score)),
+        source: None,
+    }
+    .to_value()
+}
+
 /// Build a tagged (multi-index / `--ref`) search-results envelope as a
 /// [`serde_json::Value`] without emitting it.
 ///
@@ -526,6 +737,11 @@ pub fn build_tagged_results_value(
                 result: &t.result,
                 ref_name: t.source.as_deref(),
                 parent: parents.and_then(|p| p.get(&sr.chunk.id)),
+                tests: None,
+                ownership: None,
+                issue_refs: None,
+                commit: None,
+                confidence: None,
                 source: t.source.as_deref(),
             }
             .to_value()
@@ -544,9 +760,16 @@ pub fn display_unified_results_json(
     results: &[UnifiedResult],
     query: &str,
     parents: Option<&HashMap<String, ParentContext>>,
+    extras: ResultExtras<'_>,
     token_info: Option<(usize, usize)>,
+    snippet_lines: Option<usize>,
+    relaxed: &[Relaxation],
 ) -> Result<()> {
-    let output = build_unified_results_value(results, query, parents, token_info);
+    let mut output = build_unified_results_value(results, query, parents, extras, token_info);
+    if let Some(n) = snippet_lines {
+        apply_snippet_lines(&mut output, n);
+    }
+    apply_relaxed(&mut output, relaxed);
     super::json_envelope::emit_json(&output)?;
     Ok(())
 }
@@ -557,6 +780,7 @@ pub fn display_tagged_results(
     root: &Path,
     no_content: bool,
     context: Option<usize>,
+    snippet_lines: Option<usize>,
     parents: Option<&HashMap<String, ParentContext>>,
 ) -> Result<()> {
     for tagged in results {
@@ -576,8 +800,19 @@ pub fn display_tagged_results(
                 } else {
                     ""
                 };
+                let deprecated_tag = if r.chunk.deprecated {
+                    " [deprecated]"
+                } else {
+                    ""
+                };
+                // Only present under --include-generated.
+                let boilerplate_tag = r
+                    .chunk
+                    .boilerplate
+                    .map(|b| format!(" [{b}]"))
+                    .unwrap_or_default();
                 let header = format!(
-                    "{}{}:{} ({} {}) [{}] [{:.2}]{}",
+                    "{}{}:{} ({} {}) [{}] [{:.2}]{}{}{}",
                     source_prefix,
                     rel_path,
                     r.chunk.line_start,
@@ -585,7 +820,9 @@ pub fn display_tagged_results(
                     r.chunk.name,
                     r.chunk.language,
                     r.score,
-                    parent_tag
+                    parent_tag,
+                    deprecated_tag,
+                    boilerplate_tag
                 );
 
                 println!("{}", header.cyan());
@@ -621,14 +858,7 @@ pub fn display_tagged_results(
                         }
                     }
 
-                    if r.chunk.content.lines().count() <= 10 {
-                        println!("{}", sanitize_for_terminal(&r.chunk.content));
-                    } else {
-                        for line in r.chunk.content.lines().take(8) {
-                            println!("{}", sanitize_for_terminal(line));
-                        }
-                        println!("    ...");
-                    }
+                    print_snippet(&r.chunk.content, snippet_lines);
 
                     // After context only for project results
                     if tagged.source.is_none() {
@@ -690,12 +920,16 @@ pub fn display_tagged_results_json(
     query: &str,
     parents: Option<&HashMap<String, ParentContext>>,
     token_info: Option<(usize, usize)>,
+    snippet_lines: Option<usize>,
 ) -> Result<()> {
     // Shares the per-result + envelope builder with the daemon ref path, so the
     // CLI `--include-refs` / `--ref` JSON and the daemon's are one schema. The
     // top-level `source` label stays `None` here — the project + include-refs
     // path tags per-result, not at the envelope.
-    let output = build_tagged_results_value(results, query, parents, token_info, None);
+    let mut output = build_tagged_results_value(results, query, parents, token_info, None);
+    if let Some(n) = snippet_lines {
+        apply_snippet_lines(&mut output, n);
+    }
     super::json_envelope::emit_json(&output)?;
     Ok(())
 }
@@ -898,4 +1132,69 @@ mod tests {
         assert_eq!(after.len(), 1);
         assert_eq!(after[0], "g");
     }
+
+    #[test]
+    fn ownership_line_joins_owners_and_author_shares() {
+        let author = |name: &str, share: f32| cqs::ownership::AuthorShare {
+            name: name.to_string(),
+            email: String::new(),
+            lines: 1,
+            share,
+        };
+        let both = ChunkOwnership {
+            owners: vec!["@acme/payments".to_string(), "@ada".to_string()],
+            authors: vec![author("Ada", 0.67), author("Grace", 0.33)],
+        };
+        assert_eq!(
+            ownership_line(&both),
+            "owners: @acme/payments @ada · authors: Ada 67%, Grace 33%"
+        );
+        let authors_only = ChunkOwnership {
+            owners: Vec::new(),
+            authors: vec![author("Ada", 1.0)],
+        };
+        assert_eq!(ownership_line(&authors_only), "authors: Ada 100%");
+    }
+
+    #[test]
+    fn commit_lines_show_short_sha_date_and_files() {
+        let meta = CommitMeta {
+            sha: "3f2a9c1b0d4e5f60718293a4b5c6d7e8f9012345".to_string(),
+            author: "Ada".to_string(),
+            email: "ada@example.com".to_string(),
+            committed_at: 1_714_521_600,
+            files: (0..7).map(|i| format!("src/f{i}.rs")).collect(),
+        };
+        assert_eq!(
+            commit_lines(&meta),
+            [
+                "commit 3f2a9c1b0d4e · Ada · 2024-05-01",
+                "files: src/f0.rs, src/f1.rs, src/f2.rs, src/f3.rs, src/f4.rs (+2 more)",
+            ]
+        );
+    }
+
+    #[test]
+    fn apply_snippet_lines_cuts_long_content_only() {
+        let long = (0..30).map(|i| format!("step{i}();")).collect::<Vec<_>>();
+        let mut value = serde_json::json!({
+            "results": [
+                { "name": "long", "content": long.join("\n") },
+                { "name": "short", "content": "fn f() {}" },
+                { "name": "stripped" },
+            ],
+            "query": "q",
+            "total": 3,
+        });
+        apply_snippet_lines(&mut value, 5);
+        let results = value["results"].as_array().unwrap();
+        assert_eq!(
+            results[0]["content"],
+            "step0();\nstep1();\nstep2();\nstep3();\nstep4();\n... 25 more lines"
+        );
+        assert_eq!(results[0]["content_cut_lines"], 25);
+        assert_eq!(results[1]["content"], "fn f() {}");
+        assert!(results[1].get("content_cut_lines").is_none());
+        assert!(results[2].get("content").is_none());
+    }
 }
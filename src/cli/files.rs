@@ -171,10 +171,29 @@ fn open_lock_file(lock_path: &Path) -> Result<std::fs::File> {
     }
 }
 
-/// Write our PID to the lock file (truncate + write + sync).
+/// File the lock holder's PID is recorded in.
+///
+/// On Unix that is `index.lock` itself. On Windows `LockFileEx` is
+/// mandatory, so a waiting process cannot read the locked file to find out
+/// who holds it; the PID goes to an `index.lock.pid` sidecar instead.
+fn lock_pid_path(lock_path: &Path) -> PathBuf {
+    if cfg!(windows) {
+        lock_path.with_extension("lock.pid")
+    } else {
+        lock_path.to_path_buf()
+    }
+}
+
+/// Write our PID to the lock file (truncate + write + sync), or to the
+/// sidecar from [`lock_pid_path`] on Windows.
 ///
 /// Called only after successfully acquiring the OS lock.
-fn write_pid(file: &mut std::fs::File) -> Result<()> {
+fn write_pid(file: &mut std::fs::File, lock_path: &Path) -> Result<()> {
+    if cfg!(windows) {
+        let pid_path = lock_pid_path(lock_path);
+        return std::fs::write(&pid_path, format!("{}\n", std::process::id()))
+            .with_context(|| format!("Failed to write {}", pid_path.display()));
+    }
     file.set_len(0)?;
     file.seek(std::io::SeekFrom::Start(0))?;
     writeln!(file, "{}", std::process::id())?;
@@ -193,7 +212,7 @@ pub(crate) fn try_acquire_index_lock(cqs_dir: &Path) -> Result<Option<std::fs::F
     match lock_file.try_lock() {
         Ok(()) => {
             let mut file = lock_file;
-            write_pid(&mut file)?;
+            write_pid(&mut file, &lock_path)?;
             Ok(Some(file))
         }
         Err(std::fs::TryLockError::WouldBlock) => Ok(None),
@@ -204,8 +223,55 @@ pub(crate) fn try_acquire_index_lock(cqs_dir: &Path) -> Result<Option<std::fs::F
     }
 }
 
+/// Poll interval while `--wait` blocks on a held index lock.
+const LOCK_WAIT_POLL: std::time::Duration = std::time::Duration::from_millis(200);
+
+/// How often a blocked `--wait` repeats its progress line.
+const LOCK_WAIT_PROGRESS_EVERY: std::time::Duration = std::time::Duration::from_secs(10);
+
+/// What a write command does when another process holds `index.lock`.
+///
+/// Resolved once from the global `--wait` / `--lock-timeout` flags by
+/// [`LockWait::from_cli`]. The watch daemon never blocks on the lock — it
+/// keeps its pending changes queued and retries each tick instead.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub(crate) enum LockWait {
+    /// Fail at once, naming the holder's PID (the default).
+    #[default]
+    Fail,
+    /// Block until the holder releases the lock, printing progress to stderr
+    /// unless `quiet`. `timeout: None` waits indefinitely.
+    Wait {
+        timeout: Option<std::time::Duration>,
+        quiet: bool,
+    },
+}
+
+impl LockWait {
+    /// `--lock-timeout` implies waiting; `--wait` alone waits forever.
+    pub(crate) fn from_cli(cli: &super::Cli) -> Self {
+        match (cli.wait, cli.lock_timeout) {
+            (_, Some(secs)) => Self::Wait {
+                timeout: Some(std::time::Duration::from_secs(secs)),
+                quiet: cli.quiet,
+            },
+            (true, None) => Self::Wait {
+                timeout: None,
+                quiet: cli.quiet,
+            },
+            (false, None) => Self::Fail,
+        }
+    }
+}
+
+/// PID recorded for `index.lock`, if the file exists and parses. Best-effort:
+/// the file keeps the last holder's PID after release.
+pub(crate) fn index_lock_holder(cqs_dir: &Path) -> Option<u32> {
+    read_pid_capped(&lock_pid_path(&cqs_dir.join("index.lock")))
+}
+
 /// Acquire file lock to prevent concurrent indexing
-/// Writes PID to lock file for stale lock detection.
+/// Writes PID to lock file (the `.pid` sidecar on Windows) for stale lock detection.
 ///
 /// # Concurrency contract by platform
 ///
@@ -251,7 +317,11 @@ pub(crate) fn try_acquire_index_lock(cqs_dir: &Path) -> Result<Option<std::fs::F
 /// transient release without ever unlinking the file. If the second attempt
 /// also fails we return a clearer error mentioning the PID and the manual
 /// remediation path.
-pub(crate) fn acquire_index_lock(cqs_dir: &Path) -> Result<std::fs::File> {
+///
+/// With [`LockWait::Wait`] a live holder is waited out instead: the lock is
+/// polled every 200 ms with a progress line on stderr, until it frees or the
+/// timeout lapses.
+pub(crate) fn acquire_index_lock(cqs_dir: &Path, wait: LockWait) -> Result<std::fs::File> {
     // Emit a one-shot warning on Windows so operators can correlate
     // third-party "sharing violation" errors with cqs holding index.lock.
     #[cfg(windows)]
@@ -267,6 +337,8 @@ pub(crate) fn acquire_index_lock(cqs_dir: &Path) -> Result<std::fs::File> {
 
     let lock_path = cqs_dir.join("index.lock");
     let mut retried = false;
+    let mut waiting_since: Option<std::time::Instant> = None;
+    let mut last_progress = std::time::Instant::now();
 
     loop {
         let lock_file = open_lock_file(&lock_path)?;
@@ -274,13 +346,19 @@ pub(crate) fn acquire_index_lock(cqs_dir: &Path) -> Result<std::fs::File> {
         match lock_file.try_lock() {
             Ok(()) => {
                 let mut file = lock_file;
-                write_pid(&mut file)?;
+                write_pid(&mut file, &lock_path)?;
+                if let Some(since) = waiting_since {
+                    tracing::info!(
+                        waited_ms = since.elapsed().as_millis() as u64,
+                        "Index lock acquired after waiting"
+                    );
+                }
                 return Ok(file);
             }
             Err(_) => {
                 // Lock is held - check if the owning process is still alive
                 if !retried {
-                    let stale_pid = read_pid_capped(&lock_path);
+                    let stale_pid = read_pid_capped(&lock_pid_path(&lock_path));
                     if let Some(pid) = stale_pid {
                         if !process_exists(pid) {
                             // Stale lock by best-effort PID check: drop the
@@ -297,14 +375,47 @@ pub(crate) fn acquire_index_lock(cqs_dir: &Path) -> Result<std::fs::File> {
                         }
                     }
                 }
-                let pid_msg = match read_pid_capped(&lock_path) {
+                let holder = read_pid_capped(&lock_pid_path(&lock_path));
+                if let LockWait::Wait { timeout, quiet } = wait {
+                    let since = *waiting_since.get_or_insert_with(|| {
+                        if !quiet {
+                            eprintln!(
+                                "Waiting for the index lock held by {}...",
+                                holder.map_or_else(
+                                    || "another cqs process".to_string(),
+                                    |pid| format!("PID {pid}")
+                                )
+                            );
+                        }
+                        tracing::info!(pid = ?holder, ?timeout, "Waiting for index lock");
+                        std::time::Instant::now()
+                    });
+                    let waited = since.elapsed();
+                    if timeout.is_some_and(|t| waited >= t) {
+                        bail!(
+                            "Timed out after {}s waiting for the index lock at {}{}",
+                            waited.as_secs(),
+                            lock_path.display(),
+                            holder.map_or_else(String::new, |pid| format!(" (held by PID {pid})"))
+                        );
+                    }
+                    if !quiet && last_progress.elapsed() >= LOCK_WAIT_PROGRESS_EVERY {
+                        eprintln!("  still waiting ({}s)...", waited.as_secs());
+                        last_progress = std::time::Instant::now();
+                    }
+                    drop(lock_file);
+                    std::thread::sleep(LOCK_WAIT_POLL);
+                    continue;
+                }
+                let pid_msg = match holder {
                     Some(pid) => format!(" (PID {pid} may be stale)"),
                     None => String::new(),
                 };
                 bail!(
                     "Another cqs process holds the index lock at {}{pid_msg}. \
-                     Hint: wait for it to finish, or manually delete the lock file \
-                     only if you are confident no other cqs process is running.",
+                     Hint: rerun with --wait (or --lock-timeout <SECS>) to queue behind it, \
+                     or manually delete the lock file only if you are confident no other \
+                     cqs process is running.",
                     lock_path.display()
                 )
             }
@@ -482,4 +593,78 @@ mod tests {
         assert_eq!(decode_tasklist_stdout(b""), "");
         assert_eq!(decode_tasklist_stdout(b"\xFF"), "\u{FFFD}");
     }
+
+    #[test]
+    fn lock_wait_times_out_while_held_and_fail_hints_wait() {
+        let dir = tempfile::TempDir::new().unwrap();
+        let _held = try_acquire_index_lock(dir.path())
+            .unwrap()
+            .expect("uncontended lock");
+
+        let err = acquire_index_lock(dir.path(), LockWait::Fail).unwrap_err();
+        assert!(err.to_string().contains("--wait"), "got: {err}");
+
+        let started = std::time::Instant::now();
+        let wait = LockWait::Wait {
+            timeout: Some(std::time::Duration::from_millis(300)),
+            quiet: true,
+        };
+        let err = acquire_index_lock(dir.path(), wait).unwrap_err();
+        assert!(err.to_string().contains("Timed out"), "got: {err}");
+        assert!(started.elapsed() >= std::time::Duration::from_millis(300));
+    }
+
+    #[test]
+    fn lock_wait_acquires_once_holder_releases() {
+        let dir = tempfile::TempDir::new().unwrap();
+        let held = try_acquire_index_lock(dir.path()).unwrap().unwrap();
+        let releaser = std::thread::spawn(move || {
+            std::thread::sleep(std::time::Duration::from_millis(300));
+            drop(held);
+        });
+        let wait = LockWait::Wait {
+            timeout: Some(std::time::Duration::from_secs(10)),
+            quiet: true,
+        };
+        assert!(acquire_index_lock(dir.path(), wait).is_ok());
+        releaser.join().unwrap();
+    }
+
+    #[test]
+    fn lock_wait_from_cli_flags() {
+        use clap::Parser as _;
+        let parse = |argv: &[&str]| {
+            let cli = crate::cli::Cli::try_parse_from(argv).expect("argv must parse");
+            LockWait::from_cli(&cli)
+        };
+        assert_eq!(parse(&["cqs", "index"]), LockWait::Fail);
+        assert_eq!(
+            parse(&["cqs", "index", "--wait"]),
+            LockWait::Wait {
+                timeout: None,
+                quiet: false
+            }
+        );
+        assert_eq!(
+            parse(&["cqs", "gc", "--lock-timeout", "30", "-q"]),
+            LockWait::Wait {
+                timeout: Some(std::time::Duration::from_secs(30)),
+                quiet: true
+            }
+        );
+    }
+
+    #[test]
+    fn index_lock_holder_reads_pid_while_held() {
+        // On Windows the PID lives in a sidecar precisely so this read works
+        // against the mandatory lock.
+        let dir = tempfile::TempDir::new().unwrap();
+        let held = try_acquire_index_lock(dir.path()).unwrap().unwrap();
+        assert_eq!(index_lock_holder(dir.path()), Some(std::process::id()));
+        drop(held);
+        assert_eq!(
+            lock_pid_path(Path::new("index.lock")) == Path::new("index.lock"),
+            !cfg!(windows)
+        );
+    }
 }
@@ -21,6 +21,11 @@
 //! the full `{data, error, version, _meta}` envelope for consumers that
 //! want the wrapped shape.
 //!
+//! Independently of the envelope, `--schema vN` selects the payload shape.
+//! Each output names it with a top-level `schema_version` (on the envelope
+//! when there is one, else on the bare payload, where v1 has room for it).
+//! See [`crate::cli::json_schema`].
+//!
 //! ## Surfaces
 //!
 //! - **CLI**: handlers call [`emit_json`] (pretty-printed) instead of
@@ -34,6 +39,8 @@
 use anyhow::Result;
 use serde::Serialize;
 
+use super::json_schema::{self, SchemaVersion};
+
 /// Wire-format version. Bump on any breaking change to the `data` payload
 /// shapes for any command. The envelope structure itself (data/error/version
 /// keys) is stable across versions.
@@ -76,6 +83,24 @@ pub struct EnvelopeMeta {
     /// than per-process (the daemon serves many searches per process).
     #[serde(skip_serializing_if = "Option::is_none")]
     pub worktree_overlay: Option<serde_json::Value>,
+    /// Per-query HyDE outcome (`--hyde`): whether the hypothetical document
+    /// fired, whether it came from the cache, and what it said — or why it
+    /// did not apply. Present only when the current search requested HyDE;
+    /// take-on-read like `worktree_overlay`.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub hyde: Option<serde_json::Value>,
+    /// Per-query translation outcome (`--translate`, `llm_query_translate`):
+    /// the detected language and the English query searched with — or why
+    /// the query was searched as typed. Absent when the mode didn't call for
+    /// translation; take-on-read like `hyde`.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub translation: Option<serde_json::Value>,
+    /// Index generation the command's search read (`<epoch>-<counter>`, see
+    /// [`cqs::store::IndexGeneration`]), taken from the same snapshot as the
+    /// results. Agents paginating a query re-query when it changes between
+    /// pages. Absent when the command ran no search; take-on-read like `hyde`.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub index_generation: Option<String>,
 }
 
 impl EnvelopeMeta {
@@ -92,14 +117,25 @@ impl EnvelopeMeta {
             // before emission, and a single `current()` per envelope consumes
             // it. Absent ⇒ no overlay requested ⇒ key omitted.
             worktree_overlay: cqs::worktree_overlay::take_overlay_meta().map(|m| m.to_json()),
+            hyde: crate::cli::commands::search::hyde::take_hyde_meta()
+                .and_then(|m| serde_json::to_value(m).ok()),
+            translation: crate::cli::commands::search::translate::take_translation_meta()
+                .and_then(|m| serde_json::to_value(m).ok()),
+            index_generation: cqs::store::take_search_generation().map(|g| g.to_string()),
         }
     }
 
     /// `true` when every field is at its default (not a stale worktree,
-    /// no worktree name, no overlay outcome). Drives "skip `_meta` when empty"
-    /// emission in the slim envelope shape.
+    /// no worktree name, no overlay, HyDE, or translation outcome, no search
+    /// generation). Drives "skip `_meta` when empty" emission in the slim
+    /// envelope shape.
     pub fn is_empty(&self) -> bool {
-        !self.worktree_stale && self.worktree_name.is_none() && self.worktree_overlay.is_none()
+        !self.worktree_stale
+            && self.worktree_name.is_none()
+            && self.worktree_overlay.is_none()
+            && self.hyde.is_none()
+            && self.translation.is_none()
+            && self.index_generation.is_none()
     }
 }
 
@@ -227,6 +263,8 @@ pub struct Envelope<T: Serialize> {
     pub data: Option<T>,
     pub error: Option<JsonError>,
     pub version: u32,
+    /// Payload shape (see [`crate::cli::json_schema`]).
+    pub schema_version: u32,
     /// Constant advisory block surfaced on every envelope. Named `_meta` on
     /// the wire to signal "envelope metadata, not part of data" to consumers.
     #[serde(rename = "_meta")]
@@ -247,6 +285,7 @@ impl<T: Serialize> Envelope<T> {
             data: Some(data),
             error: None,
             version: JSON_OUTPUT_VERSION,
+            schema_version: json_schema::selected().number(),
             meta: EnvelopeMeta::current(),
         }
     }
@@ -265,6 +304,7 @@ impl Envelope<serde_json::Value> {
                 message: message.into(),
             }),
             version: JSON_OUTPUT_VERSION,
+            schema_version: json_schema::selected().number(),
             meta: EnvelopeMeta::current(),
         }
     }
@@ -279,18 +319,24 @@ impl Envelope<serde_json::Value> {
 /// object plus a shallow clone of the payload (necessary because
 /// `serde_json::json!` macro takes ownership).
 ///
-/// **Wire shape** (the slim success shape): `{"data": <payload>}`, plus
-/// `"_meta": {...}` only when non-empty (worktree-stale or other
-/// non-default fields). Drops `error: null` and `version` — both redundant
-/// on the success path. `CQS_OUTPUT_FORMAT=v1` is handled one layer up by
+/// **Wire shape** (the slim success shape): `{"data": <payload>,
+/// "schema_version": N}`, plus `"_meta": {...}` only when non-empty
+/// (worktree-stale or other non-default fields). Drops `error: null` and
+/// `version` — both redundant on the success path. The payload is converted
+/// to the `--schema` selection. `CQS_OUTPUT_FORMAT=v1` is handled one layer up by
 /// [`emit_json`] for the CLI direct path; the batch / daemon JSONL path
 /// always uses this slim shape.
 pub fn wrap_value(payload: &serde_json::Value) -> serde_json::Value {
     // Build the envelope as a Map directly to avoid the deep clone that
     // `to_value(Envelope::ok(...))` would do.
     let meta = EnvelopeMeta::current();
-    let mut env = serde_json::Map::with_capacity(2);
-    env.insert("data".to_string(), payload.clone());
+    let version = json_schema::selected();
+    let mut env = serde_json::Map::with_capacity(3);
+    env.insert(
+        "data".to_string(),
+        json_schema::body_for(payload, version).into_owned(),
+    );
+    json_schema::mark_envelope(&mut env, version);
     if !meta.is_empty() {
         env.insert("_meta".to_string(), meta_value_for_envelope(&meta));
     }
@@ -320,6 +366,18 @@ fn meta_value_for_envelope(meta: &EnvelopeMeta) -> serde_json::Value {
         if let Some(overlay) = &meta.worktree_overlay {
             m.insert("worktree_overlay".to_string(), overlay.clone());
         }
+        if let Some(hyde) = &meta.hyde {
+            m.insert("hyde".to_string(), hyde.clone());
+        }
+        if let Some(translation) = &meta.translation {
+            m.insert("translation".to_string(), translation.clone());
+        }
+        if let Some(generation) = &meta.index_generation {
+            m.insert(
+                "index_generation".to_string(),
+                serde_json::Value::String(generation.clone()),
+            );
+        }
         serde_json::Value::Object(m)
     })
 }
@@ -391,15 +449,17 @@ pub fn meta_json_fragment() -> String {
 /// `&'static str` from `error_codes::FOO`. New code should prefer
 /// [`ErrorCode::as_str`] for compile-checked emission.
 ///
-/// **Wire shape** (slim error shape): `{"error": {...}}`, plus
-/// `"_meta": {...}` only when non-empty. Drops `data: null` and `version`.
+/// **Wire shape** (slim error shape): `{"error": {...}, "schema_version":
+/// N}`, plus `"_meta": {...}` only when non-empty. Drops `data: null` and
+/// `version`.
 pub fn wrap_error(code: &str, message: &str) -> serde_json::Value {
     let meta = EnvelopeMeta::current();
-    let mut env = serde_json::Map::with_capacity(2);
+    let mut env = serde_json::Map::with_capacity(3);
     env.insert(
         "error".to_string(),
         serde_json::json!({"code": code, "message": message}),
     );
+    json_schema::mark_envelope(&mut env, json_schema::selected());
     if !meta.is_empty() {
         env.insert("_meta".to_string(), meta_value_for_envelope(&meta));
     }
@@ -499,10 +559,12 @@ pub fn format_envelope_to_string<T: Serialize>(value: &T) -> Result<String> {
 ///
 /// **Wire shape** depends on the active [`EnvelopeShape`]:
 /// - [`EnvelopeShape::V2Bare`] (default, `CQS_OUTPUT_FORMAT` unset) ⇒ bare
-///   payload to stdout, no envelope.
+///   payload to stdout, no envelope, carrying `schema_version` itself.
 /// - [`EnvelopeShape::V1Envelope`] (`CQS_OUTPUT_FORMAT=v1`) ⇒ full envelope
-///   `{data, error: null, version: 1, _meta: {...}}` wrapped around
-///   the value, pretty-printed.
+///   `{data, error: null, version: 1, schema_version, _meta: {...}}`
+///   wrapped around the value, pretty-printed.
+///
+/// Either way the payload is rendered in the `--schema` selection.
 ///
 /// Sanitizes NaN / Infinity floats via the same try → on-Err sanitize-and-retry
 /// pattern as the batch / daemon socket path (see [`format_envelope_to_string`]
@@ -516,7 +578,9 @@ pub fn emit_json<T: Serialize>(value: &T) -> Result<()> {
         // and unaffected.
         emit_bare_payload_stdout(value)
     } else {
-        let env = Envelope::ok(value);
+        let mut body = serde_json::to_value(value)?;
+        json_schema::convert_body(&mut body, SchemaVersion::CURRENT, json_schema::selected());
+        let env = Envelope::ok(body);
         let s = format_envelope_to_string(&env)?;
         println!("{s}");
         Ok(())
@@ -532,12 +596,13 @@ pub fn emit_json<T: Serialize>(value: &T) -> Result<()> {
 /// When `EnvelopeMeta::current()` carries non-default state
 /// (e.g. `worktree_stale=true`), splice a `_meta` field onto the bare
 /// payload so the operational warning isn't silently dropped under the
-/// V2Bare shape. JSON-object payloads accept the splice in-place;
-/// array / scalar payloads can't carry `_meta`, so we fall back to a
-/// `tracing::warn!` on stderr — the operator still sees the signal even
-/// though the bare wire shape can't carry it.
+/// V2Bare shape. JSON-object payloads accept the splice in-place. Under
+/// `--schema v2` every payload is an object (arrays are wrapped to carry
+/// `schema_version`); under v1 array / scalar payloads can't carry `_meta`,
+/// so we fall back to a `tracing::warn!` on stderr — the operator still
+/// sees the signal even though the bare wire shape can't carry it.
 fn emit_bare_payload_stdout<T: Serialize>(value: &T) -> Result<()> {
-    let mut buf = serde_json::to_value(value)?;
+    let mut buf = json_schema::bare_payload(serde_json::to_value(value)?, json_schema::selected());
 
     let meta = EnvelopeMeta::current();
     if !meta.is_empty() {
@@ -602,22 +667,38 @@ fn emit_bare_payload_stdout<T: Serialize>(value: &T) -> Result<()> {
 ///
 /// Non-object payloads can't carry `_meta`; the operational signal degrades
 /// to a `tracing::warn!`, same as the in-process bare path.
+///
+/// `wire` is the payload shape the daemon sent (the line's `schema_version`;
+/// v1 when absent, from a daemon that predates it). The payload is brought
+/// up to the current shape and then rendered in this process's `--schema`
+/// selection, so an old daemon and a pinned client still agree.
 pub fn daemon_payload_to_cli_text(
     payload: &serde_json::Value,
     meta: Option<&serde_json::Value>,
+    wire: SchemaVersion,
 ) -> Result<String> {
-    daemon_payload_to_cli_text_with(EnvelopeShape::current(), payload, meta)
+    daemon_payload_to_cli_text_with(
+        EnvelopeShape::current(),
+        json_schema::selected(),
+        payload,
+        meta,
+        wire,
+    )
 }
 
 /// Format-explicit core of [`daemon_payload_to_cli_text`], split out so tests
 /// can pin both branches without racing the process-cached env read.
 fn daemon_payload_to_cli_text_with(
     format: EnvelopeShape,
+    version: SchemaVersion,
     payload: &serde_json::Value,
     meta: Option<&serde_json::Value>,
+    wire: SchemaVersion,
 ) -> Result<String> {
+    let mut body = payload.clone();
+    json_schema::convert_body(&mut body, wire, SchemaVersion::CURRENT);
     if format.emits_bare_payload() {
-        let mut v = payload.clone();
+        let mut v = json_schema::bare_payload(body, version);
         if let Some(m) = meta {
             match v {
                 serde_json::Value::Object(ref mut map) => {
@@ -642,7 +723,9 @@ fn daemon_payload_to_cli_text_with(
         // Value, then fold the daemon meta into its `_meta` object (client
         // process-level keys first, daemon per-query keys layered on top —
         // they never collide with worktree_stale/name).
-        let env = Envelope::ok(payload);
+        json_schema::convert_body(&mut body, SchemaVersion::CURRENT, version);
+        let mut env = Envelope::ok(body);
+        env.schema_version = version.number();
         let mut env_value = serde_json::to_value(&env)?;
         if let (Some(daemon_meta), Some(obj)) = (meta, env_value.as_object_mut()) {
             if let Some(daemon_obj) = daemon_meta.as_object() {
@@ -739,6 +822,7 @@ fn error_with_data_envelope(
             "version".to_string(),
             serde_json::Value::Number(JSON_OUTPUT_VERSION.into()),
         );
+        json_schema::mark_envelope(&mut env, json_schema::selected());
         env.insert(
             "_meta".to_string(),
             serde_json::to_value(EnvelopeMeta::current())?,
@@ -883,6 +967,9 @@ mod tests {
         // minimum line.
         let v = wrap_value(&serde_json::json!([1, 2, 3]));
         assert_eq!(v["data"], serde_json::json!([1, 2, 3]));
+        // The marker rides the envelope beside `data`, under the default v1
+        // shape too.
+        assert_eq!(v["schema_version"], 1, "got: {v}");
         assert!(
             v.get("error").is_none(),
             "slim shape drops error key entirely; got: {v}"
@@ -992,8 +1079,10 @@ mod tests {
         });
         let s = daemon_payload_to_cli_text_with(
             EnvelopeShape::V1Envelope,
+            SchemaVersion::V2,
             &payload,
             Some(&daemon_meta),
+            SchemaVersion::V2,
         )
         .expect("v1 daemon presentation");
         let v: serde_json::Value = serde_json::from_str(&s).expect("valid JSON");
@@ -1016,8 +1105,14 @@ mod tests {
     #[test]
     fn daemon_v1_envelope_without_meta_is_plain() {
         let payload = serde_json::json!({"query": "q", "results": []});
-        let s = daemon_payload_to_cli_text_with(EnvelopeShape::V1Envelope, &payload, None)
-            .expect("v1 daemon presentation");
+        let s = daemon_payload_to_cli_text_with(
+            EnvelopeShape::V1Envelope,
+            SchemaVersion::V2,
+            &payload,
+            None,
+            SchemaVersion::V2,
+        )
+        .expect("v1 daemon presentation");
         let v: serde_json::Value = serde_json::from_str(&s).expect("valid JSON");
         assert_eq!(v["data"]["query"], "q");
         assert_eq!(v["version"], 1);
@@ -1532,8 +1627,14 @@ mod tests {
     fn daemon_payload_bare_splices_meta_into_object() {
         let payload = serde_json::json!({"query": "q", "results": []});
         let meta = serde_json::json!({"worktree_stale": true});
-        let s = daemon_payload_to_cli_text_with(EnvelopeShape::V2Bare, &payload, Some(&meta))
-            .expect("render");
+        let s = daemon_payload_to_cli_text_with(
+            EnvelopeShape::V2Bare,
+            SchemaVersion::V2,
+            &payload,
+            Some(&meta),
+            SchemaVersion::V2,
+        )
+        .expect("render");
         assert!(s.ends_with('\n'), "trailing newline for println parity");
         let v: serde_json::Value = serde_json::from_str(&s).expect("valid JSON");
         assert_eq!(v["query"], "q");
@@ -1546,21 +1647,87 @@ mod tests {
 
     #[test]
     fn daemon_payload_bare_array_drops_meta_with_shape_intact() {
+        // `--schema v1` keeps array payloads bare, so `_meta` has nowhere to go.
         let payload = serde_json::json!([1, 2, 3]);
         let meta = serde_json::json!({"worktree_stale": true});
-        let s = daemon_payload_to_cli_text_with(EnvelopeShape::V2Bare, &payload, Some(&meta))
-            .expect("render");
+        let s = daemon_payload_to_cli_text_with(
+            EnvelopeShape::V2Bare,
+            SchemaVersion::V1,
+            &payload,
+            Some(&meta),
+            SchemaVersion::V2,
+        )
+        .expect("render");
         let v: serde_json::Value = serde_json::from_str(&s).expect("valid JSON");
         assert_eq!(v, serde_json::json!([1, 2, 3]));
     }
 
+    #[test]
+    fn daemon_payload_bare_array_is_wrapped_under_v2() {
+        let payload = serde_json::json!([1, 2, 3]);
+        let meta = serde_json::json!({"worktree_stale": true});
+        let s = daemon_payload_to_cli_text_with(
+            EnvelopeShape::V2Bare,
+            SchemaVersion::V2,
+            &payload,
+            Some(&meta),
+            SchemaVersion::V2,
+        )
+        .expect("render");
+        let v: serde_json::Value = serde_json::from_str(&s).expect("valid JSON");
+        assert_eq!(v["schema_version"], 2);
+        assert_eq!(v["items"], serde_json::json!([1, 2, 3]));
+        assert_eq!(v["_meta"]["worktree_stale"], true);
+    }
+
+    /// A daemon that predates `schema_version` sends v1 bodies; the client
+    /// renders them in its own selection either way.
+    #[test]
+    fn daemon_payload_converts_between_wire_and_selected_versions() {
+        let old = serde_json::json!({"total_chunks": 3, "schema_version": 45});
+        let s = daemon_payload_to_cli_text_with(
+            EnvelopeShape::V2Bare,
+            SchemaVersion::V2,
+            &old,
+            None,
+            SchemaVersion::V1,
+        )
+        .expect("render");
+        let v: serde_json::Value = serde_json::from_str(&s).expect("valid JSON");
+        assert_eq!(v["index_schema_version"], 45);
+        assert_eq!(v["schema_version"], 2);
+
+        let new = serde_json::json!({"total_chunks": 3, "index_schema_version": 45});
+        let s = daemon_payload_to_cli_text_with(
+            EnvelopeShape::V2Bare,
+            SchemaVersion::V1,
+            &new,
+            None,
+            SchemaVersion::V2,
+        )
+        .expect("render");
+        let v: serde_json::Value = serde_json::from_str(&s).expect("valid JSON");
+        assert_eq!(
+            v,
+            serde_json::json!({"total_chunks": 3, "schema_version": 45})
+        );
+    }
+
     #[test]
     fn daemon_payload_v1_rebuilds_full_envelope() {
         let payload = serde_json::json!({"k": 1});
-        let s = daemon_payload_to_cli_text_with(EnvelopeShape::V1Envelope, &payload, None)
-            .expect("render");
+        let s = daemon_payload_to_cli_text_with(
+            EnvelopeShape::V1Envelope,
+            SchemaVersion::V2,
+            &payload,
+            None,
+            SchemaVersion::V2,
+        )
+        .expect("render");
         let v: serde_json::Value = serde_json::from_str(&s).expect("valid JSON");
         assert_eq!(v["data"]["k"], 1);
         assert!(v.get("version").is_some(), "v1 envelope carries version");
+        assert_eq!(v["schema_version"], 2, "marker sits beside data");
+        assert!(v["data"].get("schema_version").is_none());
     }
 }
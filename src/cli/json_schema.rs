@@ -0,0 +1,493 @@
+//! Versioned shapes for JSON output.
+//!
+//! JSON fields change between releases, and scripts break when they do.
+//! `--schema vN` (or `CQS_JSON_SCHEMA=vN`) pins the shape a script was
+//! written against, and each output names its shape with a top-level
+//! `schema_version`. An unpinned consumer gets [`SchemaVersion::DEFAULT`] —
+//! still v1, so existing scripts keep the shape they parse until the
+//! default moves in a later release.
+//!
+//! Producers build the current shape; the emit paths in
+//! [`crate::cli::json_envelope`] convert it to the selected version on the
+//! way out. The marker sits on the outermost object a consumer reads:
+//!
+//! - **Bare CLI payloads** (the default) carry it as a payload key. Array
+//!   and scalar payloads can't hold a key, so from v2 they are wrapped as
+//!   `{"schema_version": 2, "items": [...]}`.
+//! - **Envelopes** (`CQS_OUTPUT_FORMAT=v1`, batch and daemon JSONL lines)
+//!   carry it beside `data`, which stays the unwrapped body.
+//!
+//! Versions:
+//!
+//! - **v1**: the shapes from before versioning, marked `1` where the shape
+//!   has room: array payloads stay bare and unmarked, and `stats` /
+//!   `backup` / `restore` keep the index database's schema under
+//!   `schema_version`.
+//! - **v2** (current, opt-in): the marker everywhere, wrapped non-object
+//!   payloads, and the database schema renamed to `index_schema_version`
+//!   so it can't be mistaken for the marker.
+//!
+//! Adding a version means a new variant, a step in [`convert_body`], and a
+//! matching step in [`versioned_schema`] so `cqs schema dump` describes it.
+
+use std::borrow::Cow;
+use std::sync::OnceLock;
+
+use serde_json::{json, Map, Value};
+
+/// Top-level key naming the shape of a JSON output.
+pub(crate) const SCHEMA_VERSION_KEY: &str = "schema_version";
+
+/// v2 name for the index database's schema version.
+const INDEX_SCHEMA_KEY: &str = "index_schema_version";
+
+/// v2 key holding a non-object payload.
+const ITEMS_KEY: &str = "items";
+
+/// A JSON output shape, selected with `--schema`.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, clap::ValueEnum)]
+pub(crate) enum SchemaVersion {
+    /// Shapes from before versioning, marked where they have room.
+    V1,
+    /// Marker everywhere, wrapped arrays, `index_schema_version`.
+    V2,
+}
+
+impl SchemaVersion {
+    /// What producers build; the emit paths convert from it.
+    pub(crate) const CURRENT: Self = Self::V2;
+
+    /// What an unpinned consumer gets. v2 wraps array payloads, which would
+    /// break every script reading a bare array, so it stays opt-in.
+    pub(crate) const DEFAULT: Self = Self::V1;
+
+    /// Every version `--schema` accepts, oldest first.
+    pub(crate) const ALL: [Self; 2] = [Self::V1, Self::V2];
+
+    pub(crate) fn number(self) -> u32 {
+        match self {
+            Self::V1 => 1,
+            Self::V2 => 2,
+        }
+    }
+
+    pub(crate) fn from_number(n: u64) -> Option<Self> {
+        Self::ALL.into_iter().find(|v| u64::from(v.number()) == n)
+    }
+
+    /// `v2`, `V2`, or `2`.
+    fn parse(raw: &str) -> Option<Self> {
+        let raw = raw.trim().to_ascii_lowercase();
+        let digits = raw.strip_prefix('v').unwrap_or(&raw);
+        digits.parse::<u64>().ok().and_then(Self::from_number)
+    }
+}
+
+static SELECTED: OnceLock<SchemaVersion> = OnceLock::new();
+
+/// Pin the version for this process from `--schema`, falling back to
+/// `CQS_JSON_SCHEMA`. Called once by dispatch before any output.
+pub(crate) fn select(flag: Option<SchemaVersion>) {
+    let _ = SELECTED.set(flag.unwrap_or_else(from_env));
+}
+
+/// The version JSON output is rendered in: `--schema` > `CQS_JSON_SCHEMA` >
+/// [`SchemaVersion::DEFAULT`].
+pub(crate) fn selected() -> SchemaVersion {
+    *SELECTED.get_or_init(from_env)
+}
+
+fn from_env() -> SchemaVersion {
+    match std::env::var("CQS_JSON_SCHEMA") {
+        Ok(raw) if !raw.trim().is_empty() => SchemaVersion::parse(&raw).unwrap_or_else(|| {
+            tracing::warn!(
+                raw = %raw,
+                "CQS_JSON_SCHEMA not recognized; using the default schema. \
+                 Recognized values: v1, v2."
+            );
+            SchemaVersion::DEFAULT
+        }),
+        _ => SchemaVersion::DEFAULT,
+    }
+}
+
+/// Convert an unmarked body from `from` to `to`, a version step at a time.
+pub(crate) fn convert_body(body: &mut Value, from: SchemaVersion, to: SchemaVersion) {
+    let Some(obj) = body.as_object_mut() else {
+        return;
+    };
+    match (from, to) {
+        (SchemaVersion::V1, SchemaVersion::V2) => {
+            rename_key(obj, SCHEMA_VERSION_KEY, INDEX_SCHEMA_KEY)
+        }
+        (SchemaVersion::V2, SchemaVersion::V1) => {
+            rename_key(obj, INDEX_SCHEMA_KEY, SCHEMA_VERSION_KEY)
+        }
+        _ => {}
+    }
+}
+
+fn rename_key(obj: &mut Map<String, Value>, from: &str, to: &str) {
+    if let Some(v) = obj.remove(from) {
+        obj.insert(to.to_string(), v);
+    }
+}
+
+/// A current-shape body converted to `version`, borrowed when nothing
+/// changes — the batch hot path serializes most lines without a clone.
+pub(crate) fn body_for(body: &Value, version: SchemaVersion) -> Cow<'_, Value> {
+    let changes = version != SchemaVersion::CURRENT
+        && body
+            .as_object()
+            .is_some_and(|obj| obj.contains_key(INDEX_SCHEMA_KEY));
+    if !changes {
+        return Cow::Borrowed(body);
+    }
+    let mut owned = body.clone();
+    convert_body(&mut owned, SchemaVersion::CURRENT, version);
+    Cow::Owned(owned)
+}
+
+/// A current-shape body as the bare payload of `version`: converted, then
+/// marked. From v2, non-object payloads are wrapped under `items`; v1
+/// prints them as they are, unmarked.
+pub(crate) fn bare_payload(mut body: Value, version: SchemaVersion) -> Value {
+    convert_body(&mut body, SchemaVersion::CURRENT, version);
+    let mut obj = match body {
+        Value::Object(obj) => obj,
+        other if version == SchemaVersion::V1 => return other,
+        other => {
+            let mut obj = Map::with_capacity(2);
+            obj.insert(ITEMS_KEY.to_string(), other);
+            obj
+        }
+    };
+    // v1 `stats` / `backup` / `restore` already hold the index database's
+    // schema under the key; it stays.
+    obj.entry(SCHEMA_VERSION_KEY)
+        .or_insert_with(|| version.number().into());
+    Value::Object(obj)
+}
+
+/// Mark an envelope object (the sibling of `data` / `error`) for `version`.
+pub(crate) fn mark_envelope(env: &mut Map<String, Value>, version: SchemaVersion) {
+    env.insert(SCHEMA_VERSION_KEY.to_string(), version.number().into());
+}
+
+/// `,"schema_version":N` for the hand-built batch line.
+pub(crate) fn envelope_fragment(version: SchemaVersion) -> &'static str {
+    match version {
+        SchemaVersion::V1 => ",\"schema_version\":1",
+        SchemaVersion::V2 => ",\"schema_version\":2",
+    }
+}
+
+// ─── JSON Schema registry (`cqs schema dump`) ──────────────────────────────
+
+/// One documented output type.
+pub(crate) struct OutputSchema {
+    pub(crate) name: &'static str,
+    pub(crate) description: &'static str,
+    /// Builds the schema of the current-shape body.
+    build: fn() -> Value,
+    /// Whether the object carries the `schema_version` marker. The error
+    /// object sits inside an envelope that carries it instead.
+    marked: bool,
+}
+
+/// Every output type `cqs schema dump` knows, in dump order.
+pub(crate) const OUTPUT_SCHEMAS: &[OutputSchema] = &[
+    OutputSchema {
+        name: "envelope",
+        description: "Batch and daemon JSONL line, and the CQS_OUTPUT_FORMAT=v1 wrapper",
+        build: envelope_schema,
+        marked: true,
+    },
+    OutputSchema {
+        name: "error",
+        description: "`error` object of a failed command",
+        build: error_schema,
+        marked: false,
+    },
+    OutputSchema {
+        name: "search",
+        description: "`cqs <query> --json` and the batch `search` command",
+        build: search_schema,
+        marked: true,
+    },
+    OutputSchema {
+        name: "stats",
+        description: "`cqs stats --json` and the batch `stats` command",
+        build: stats_schema,
+        marked: true,
+    },
+    OutputSchema {
+        name: "items",
+        description: "Any array-shaped payload, wrapped so it can carry the marker",
+        build: items_schema,
+        marked: true,
+    },
+];
+
+/// The JSON Schema for `schema` as consumers of `version` see it.
+pub(crate) fn versioned_schema(schema: &OutputSchema, version: SchemaVersion) -> Value {
+    let mut out = if schema.name == "items" && version == SchemaVersion::V1 {
+        // v1 prints array payloads as they are.
+        json!({ "type": "array" })
+    } else {
+        (schema.build)()
+    };
+    if version == SchemaVersion::V1 {
+        if let Some(props) = out.get_mut("properties").and_then(Value::as_object_mut) {
+            rename_key(props, INDEX_SCHEMA_KEY, SCHEMA_VERSION_KEY);
+        }
+        if let Some(required) = out.get_mut("required").and_then(Value::as_array_mut) {
+            for key in required.iter_mut().filter(|k| *k == INDEX_SCHEMA_KEY) {
+                *key = json!(SCHEMA_VERSION_KEY);
+            }
+        }
+    }
+    if schema.marked {
+        // v1 `stats` keeps the database schema under the key.
+        if let Some(props) = out.get_mut("properties").and_then(Value::as_object_mut) {
+            props.entry(SCHEMA_VERSION_KEY).or_insert_with(|| {
+                json!({
+                    "const": version.number(),
+                    "description": "Shape of this output; select another with --schema",
+                })
+            });
+        }
+    }
+    if let Some(obj) = out.as_object_mut() {
+        obj.insert(
+            "$schema".to_string(),
+            json!("https://json-schema.org/draft/2020-12/schema"),
+        );
+        obj.insert("title".to_string(), json!(schema.name));
+        obj.insert("description".to_string(), json!(schema.description));
+    }
+    out
+}
+
+fn envelope_schema() -> Value {
+    json!({
+        "type": "object",
+        "properties": {
+            "data": { "description": "The command's payload (its bare body)" },
+            "error": { "$ref": "#/$defs/error" },
+            "version": {
+                "type": "integer",
+                "description": "Envelope version; CQS_OUTPUT_FORMAT=v1 only",
+            },
+            "_meta": {
+                "type": "object",
+                "description": "Operational signals (worktree_stale, stale_origins, hyde, index_generation, ...); omitted when empty",
+            },
+        },
+        "$defs": { "error": error_schema() },
+    })
+}
+
+fn error_schema() -> Value {
+    json!({
+        "type": "object",
+        "properties": {
+            "code": {
+                "type": "string",
+                "description": "not_found, invalid_input, parse_error, io_error, internal, timeout; treat unknown codes as internal",
+            },
+            "message": { "type": "string" },
+        },
+        "required": ["code", "message"],
+    })
+}
+
+fn search_schema() -> Value {
+    json!({
+        "type": "object",
+        "properties": {
+            "results": { "type": "array", "items": { "$ref": "#/$defs/result" } },
+            "query": { "type": "string" },
+            "total": { "type": "integer", "minimum": 0 },
+            "token_count": { "type": "integer", "description": "Only under --tokens" },
+            "token_budget": { "type": "integer", "description": "Only under --tokens" },
+            "source": { "type": "string", "description": "Reference name of a --ref search" },
+            "relaxed": {
+                "type": "array",
+                "items": {
+                    "type": "object",
+                    "properties": {
+                        "filter": { "type": "string" },
+                        "was": { "type": "string" },
+                    },
+                },
+                "description": "Filters --relax dropped to get these results, in the order dropped; only when it dropped any",
+            },
+        },
+        "required": ["results", "query", "total"],
+        "$defs": {
+            "result": {
+                "type": "object",
+                "properties": {
+                    "file": { "type": "string" },
+                    "line_start": { "type": "integer" },
+                    "line_end": { "type": "integer" },
+                    "name": { "type": "string" },
+                    "signature": { "type": "string" },
+                    "language": { "type": "string" },
+                    "chunk_type": { "type": "string" },
+                    "score": { "type": ["number", "null"] },
+                    "confidence": {
+                        "type": "number",
+                        "minimum": 0,
+                        "maximum": 1,
+                        "description": "Calibrated probability the result is what the query was after; only once cqs eval --calibrate stored a curve for the index model and score scale",
+                    },
+                    "content": { "type": "string" },
+                    "content_cut_lines": {
+                        "type": "integer",
+                        "description": "Lines cut from content; only under --snippet-lines",
+                    },
+                    "type": { "const": "code" },
+                    "has_parent": { "const": true },
+                    "has_doc": { "const": true },
+                    "deprecated": { "const": true },
+                    "boilerplate": {
+                        "enum": ["generated", "license"],
+                        "description": "Absent means ordinary code; only under --include-generated",
+                    },
+                    "trust_level": {
+                        "enum": ["reference-code", "vendored-code"],
+                        "description": "Absent means user-code",
+                    },
+                    "injection_flags": { "type": "array", "items": { "type": "string" } },
+                    "reference_name": { "type": "string" },
+                    "source": { "type": "string" },
+                    "rank_signals": {
+                        "type": "array",
+                        "items": {
+                            "type": "object",
+                            "properties": {
+                                "signal": { "type": "string" },
+                                "value": { "type": "number" },
+                            },
+                        },
+                    },
+                    "parent_id": { "type": "string" },
+                    "parent_name": { "type": "string" },
+                    "tests": { "type": "array" },
+                    "ownership": {},
+                    "issue_refs": { "type": "array" },
+                    "commit": {},
+                },
+                "required": [
+                    "file", "line_start", "line_end", "name", "signature",
+                    "language", "chunk_type", "score", "content",
+                ],
+            },
+        },
+    })
+}
+
+fn stats_schema() -> Value {
+    serde_json::to_value(schemars::schema_for!(crate::cli::commands::StatsOutput))
+        .unwrap_or_else(|_| json!({ "type": "object" }))
+}
+
+fn items_schema() -> Value {
+    json!({
+        "type": "object",
+        "properties": {
+            "items": { "type": "array" },
+        },
+        "required": ["items"],
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn parse_accepts_prefixed_and_bare_numbers() {
+        assert_eq!(SchemaVersion::parse("v1"), Some(SchemaVersion::V1));
+        assert_eq!(SchemaVersion::parse(" V2 "), Some(SchemaVersion::V2));
+        assert_eq!(SchemaVersion::parse("2"), Some(SchemaVersion::V2));
+        assert_eq!(SchemaVersion::parse("v9"), None);
+        assert_eq!(SchemaVersion::parse("latest"), None);
+    }
+
+    #[test]
+    fn bare_v2_marks_objects_and_wraps_arrays() {
+        let v = bare_payload(json!({"total": 1}), SchemaVersion::V2);
+        assert_eq!(v, json!({"total": 1, "schema_version": 2}));
+        let v = bare_payload(json!([1, 2]), SchemaVersion::V2);
+        assert_eq!(v, json!({"items": [1, 2], "schema_version": 2}));
+    }
+
+    #[test]
+    fn bare_v1_marks_objects_and_keeps_the_unversioned_shape() {
+        let v = bare_payload(json!({"total": 1}), SchemaVersion::V1);
+        assert_eq!(v, json!({"total": 1, "schema_version": 1}));
+        let v = bare_payload(json!([1, 2]), SchemaVersion::V1);
+        assert_eq!(v, json!([1, 2]));
+        let v = bare_payload(
+            json!({"total_chunks": 3, "index_schema_version": 45}),
+            SchemaVersion::V1,
+        );
+        assert_eq!(v, json!({"total_chunks": 3, "schema_version": 45}));
+    }
+
+    #[test]
+    fn convert_body_round_trips() {
+        let v1 = json!({"schema_version": 45, "model": "m"});
+        let mut body = v1.clone();
+        convert_body(&mut body, SchemaVersion::V1, SchemaVersion::V2);
+        assert_eq!(body, json!({"index_schema_version": 45, "model": "m"}));
+        convert_body(&mut body, SchemaVersion::V2, SchemaVersion::V1);
+        assert_eq!(body, v1);
+    }
+
+    #[test]
+    fn body_for_borrows_when_unchanged() {
+        let body = json!({"results": []});
+        assert!(matches!(
+            body_for(&body, SchemaVersion::V1),
+            Cow::Borrowed(_)
+        ));
+        let stats = json!({"index_schema_version": 45});
+        assert_eq!(
+            body_for(&stats, SchemaVersion::V1).into_owned(),
+            json!({"schema_version": 45})
+        );
+        assert!(matches!(
+            body_for(&stats, SchemaVersion::V2),
+            Cow::Borrowed(_)
+        ));
+    }
+
+    #[test]
+    fn stats_schema_follows_the_selected_version() {
+        let stats = OUTPUT_SCHEMAS.iter().find(|s| s.name == "stats").unwrap();
+        let v2 = versioned_schema(stats, SchemaVersion::V2);
+        assert_eq!(v2["properties"]["schema_version"]["const"], 2);
+        assert!(v2["properties"]["index_schema_version"].is_object());
+        let v1 = versioned_schema(stats, SchemaVersion::V1);
+        assert!(v1["properties"].get("index_schema_version").is_none());
+        assert_eq!(v1["properties"]["schema_version"]["type"], "integer");
+        let search = OUTPUT_SCHEMAS.iter().find(|s| s.name == "search").unwrap();
+        let v1 = versioned_schema(search, SchemaVersion::V1);
+        assert_eq!(v1["properties"]["schema_version"]["const"], 1);
+    }
+
+    #[test]
+    fn every_registered_schema_is_an_object_schema() {
+        for schema in OUTPUT_SCHEMAS {
+            for version in SchemaVersion::ALL {
+                let v = versioned_schema(schema, version);
+                assert_eq!(v["title"], schema.name, "{} {version:?}", schema.name);
+                assert!(v.get("type").is_some(), "{} {version:?}", schema.name);
+            }
+        }
+    }
+}
@@ -287,7 +287,8 @@ fn read_tools() -> &'static [ToolDef] {
             command: "callers",
             description:
                 "Who calls this function? Direct callers from the call graph, each tagged with an \
-                 edge_kind (call / serde_callback / macro_heuristic / fn_pointer / doc_reference).",
+                 edge_kind (call / serde_callback / ffi_boundary / macro_heuristic / fn_pointer / \
+                 doc_reference).",
             annotations: ToolAnnotations::READ,
         },
         ToolDef {
@@ -985,7 +986,7 @@ fn classify_output(output: &Value) -> Value {
             })
         }
         // Success → structuredContent + content[text] mirror + _meta hoist.
-        Some(SlimEnvelope::Data { payload, meta }) => {
+        Some(SlimEnvelope::Data { payload, meta, .. }) => {
             success_result(payload.clone(), meta.cloned())
         }
         // Not a recognized slim envelope. If the unrecognized shape carries an
@@ -11,6 +11,7 @@ mod display;
 mod enrichment;
 mod files;
 pub(crate) mod json_envelope;
+pub(crate) mod json_schema;
 mod limits;
 mod mcp;
 mod pipeline;
@@ -23,8 +24,8 @@ mod worktree_overlay_build;
 
 // Re-export definitions (clap structs, enums, helpers) for external use
 pub(crate) use definitions::{
-    parse_finite_f32, parse_nonzero_usize, parse_unit_f32, validate_finite_f32, AuditModeState,
-    BatchSupport, GateThreshold,
+    parse_finite_f32, parse_min_score, parse_nonzero_usize, parse_unit_f32, validate_finite_f32,
+    AuditModeState, BatchSupport, GateThreshold, MinScore,
 };
 pub use definitions::{Cli, OutputFormat};
 
@@ -43,8 +44,10 @@ pub(crate) use config::find_project_root;
 pub(crate) use enrichment::enrichment_pass;
 #[cfg(unix)]
 pub(crate) use files::daemon_socket_path;
-pub(crate) use files::{acquire_index_lock, enumerate_files, try_acquire_index_lock};
-pub(crate) use pipeline::run_index_pipeline;
+pub(crate) use files::{
+    acquire_index_lock, enumerate_files, index_lock_holder, try_acquire_index_lock, LockWait,
+};
+pub(crate) use pipeline::{print_sandbox_skips, print_secret_redactions, run_index_pipeline};
 pub(crate) use signal::{check_interrupted, reset_interrupted};
 
 // Re-export store openers, context, and vector index builders
@@ -143,7 +146,23 @@ mod tests {
     #[test]
     fn test_cmd_init() {
         let cli = Cli::try_parse_from(["cqs", "init"]).unwrap();
-        assert!(matches!(cli.command, Some(Commands::Init { output: _ })));
+        assert!(matches!(
+            cli.command,
+            Some(Commands::Init {
+                yes: false,
+                index: false,
+                ..
+            })
+        ));
+        let cli = Cli::try_parse_from(["cqs", "init", "-y", "--index"]).unwrap();
+        assert!(matches!(
+            cli.command,
+            Some(Commands::Init {
+                yes: true,
+                index: true,
+                ..
+            })
+        ));
     }
 
     #[test]
@@ -171,12 +190,188 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_cmd_index_swap_requires_force() {
+        let cli = Cli::try_parse_from(["cqs", "index", "--force", "--swap"]).unwrap();
+        match cli.command {
+            Some(Commands::Index { ref args }) => assert!(args.force && args.swap),
+            _ => panic!("Expected Index command"),
+        }
+        assert!(Cli::try_parse_from(["cqs", "index", "--swap"]).is_err());
+    }
+
     #[test]
     fn test_cmd_stats() {
         let cli = Cli::try_parse_from(["cqs", "stats"]).unwrap();
         assert!(matches!(cli.command, Some(Commands::Stats { .. })));
     }
 
+    #[test]
+    fn test_cmd_stats_hot() {
+        let cli = Cli::try_parse_from(["cqs", "stats", "--hot", "-n", "5"]).unwrap();
+        assert!(matches!(
+            cli.command,
+            Some(Commands::Stats {
+                hot: true,
+                limit: 5,
+                ..
+            })
+        ));
+        assert!(Cli::try_parse_from(["cqs", "stats", "-n", "5"]).is_err());
+    }
+
+    #[test]
+    fn test_cmd_feedback() {
+        let cli = Cli::try_parse_from([
+            "cqs",
+            "feedback",
+            "parse_config",
+            "--relevant",
+            "--query",
+            "load toml",
+        ])
+        .unwrap();
+        match cli.command {
+            Some(Commands::Feedback {
+                target,
+                relevant,
+                irrelevant,
+                query,
+                ..
+            }) => {
+                assert_eq!(target.as_deref(), Some("parse_config"));
+                assert!(relevant && !irrelevant);
+                assert_eq!(query.as_deref(), Some("load toml"));
+            }
+            _ => panic!("Expected Feedback command"),
+        }
+        assert!(Cli::try_parse_from(["cqs", "feedback", "--reset"]).is_ok());
+        assert!(Cli::try_parse_from(["cqs", "feedback"]).is_err());
+        assert!(
+            Cli::try_parse_from(["cqs", "feedback", "x", "--relevant", "--irrelevant"]).is_err()
+        );
+        assert!(Cli::try_parse_from(["cqs", "feedback", "x", "--show"]).is_err());
+    }
+
+    #[test]
+    fn test_cmd_session() {
+        let cli =
+            Cli::try_parse_from(["cqs", "session", "open", "auth-refactor", "--replay"]).unwrap();
+        match cli.command {
+            Some(Commands::Session {
+                subcmd:
+                    commands::SessionCommand::Open {
+                        name, replay, chat, ..
+                    },
+            }) => {
+                assert_eq!(name, "auth-refactor");
+                assert!(replay && !chat);
+            }
+            _ => panic!("Expected Session Open command"),
+        }
+        assert!(Cli::try_parse_from(["cqs", "session", "save", "x", "--force"]).is_ok());
+        assert!(Cli::try_parse_from(["cqs", "session", "show"]).is_ok());
+        assert!(
+            Cli::try_parse_from(["cqs", "session", "open", "x", "--replay", "--chat"]).is_err()
+        );
+    }
+
+    #[test]
+    fn test_format_prompt() {
+        use crate::cli::definitions::SearchFormat;
+        let cli = Cli::try_parse_from([
+            "cqs",
+            "auth flow",
+            "--format",
+            "prompt",
+            "--max-tokens",
+            "2000",
+        ])
+        .unwrap();
+        assert_eq!(cli.format, Some(SearchFormat::Prompt));
+        assert_eq!(cli.tokens, Some(2000));
+        assert!(Cli::try_parse_from(["cqs", "x", "--format", "prompt", "--json"]).is_err());
+        assert!(Cli::try_parse_from(["cqs", "x", "--format", "prompt", "--ref", "tokio"]).is_err());
+        assert!(Cli::try_parse_from(["cqs", "x", "--format", "mermaid"]).is_err());
+    }
+
+    #[test]
+    fn test_cmd_open() {
+        let cli = Cli::try_parse_from(["cqs", "open", "3", "--print"]).unwrap();
+        match cli.command {
+            Some(Commands::Open { index, print, .. }) => {
+                assert_eq!(index, 3);
+                assert!(print);
+            }
+            _ => panic!("Expected Open command"),
+        }
+        assert!(Cli::try_parse_from(["cqs", "open", "0"]).is_err());
+        let cli = Cli::try_parse_from(["cqs", "auth flow", "--open-first"]).unwrap();
+        assert!(cli.open_first);
+        assert!(Cli::try_parse_from(["cqs", "x", "--open-first", "--stream"]).is_err());
+    }
+
+    #[test]
+    fn test_cmd_ask() {
+        let cli = Cli::try_parse_from([
+            "cqs",
+            "ask",
+            "where do we validate webhook signatures?",
+            "-n",
+            "4",
+            "--sources-only",
+        ])
+        .unwrap();
+        match cli.command {
+            Some(Commands::Ask {
+                question,
+                limit,
+                code_only,
+                sources_only,
+                ..
+            }) => {
+                assert_eq!(question, "where do we validate webhook signatures?");
+                assert_eq!(limit, 4);
+                assert!(sources_only && !code_only);
+            }
+            _ => panic!("Expected Ask command"),
+        }
+        assert!(Cli::try_parse_from(["cqs", "ask"]).is_err());
+        assert!(Cli::try_parse_from(["cqs", "ask", "q", "-n", "0"]).is_err());
+    }
+
+    #[cfg(feature = "llm-summaries")]
+    #[test]
+    fn test_cmd_llm_docgen() {
+        let cli = Cli::try_parse_from([
+            "cqs",
+            "llm",
+            "docgen",
+            "--apply-as-patch",
+            "out.diff",
+            "--max-docs",
+            "20",
+        ])
+        .unwrap();
+        match cli.command {
+            Some(Commands::Llm {
+                subcmd:
+                    commands::LlmCommand::Docgen {
+                        apply_as_patch,
+                        max_docs,
+                        improve_all,
+                        ..
+                    },
+            }) => {
+                assert_eq!(apply_as_patch, std::path::PathBuf::from("out.diff"));
+                assert_eq!(max_docs, Some(20));
+                assert!(!improve_all);
+            }
+            _ => panic!("Expected Llm docgen command"),
+        }
+        assert!(Cli::try_parse_from(["cqs", "llm", "docgen"]).is_err());
+    }
+
     #[test]
     fn test_cmd_watch() {
         let cli = Cli::try_parse_from(["cqs", "watch"]).unwrap();
@@ -186,11 +381,13 @@ mod tests {
                 no_ignore,
                 poll,
                 serve,
+                metrics,
             }) => {
                 assert_eq!(debounce, 500); // default
                 assert!(!no_ignore);
                 assert!(!serve);
                 assert!(!poll);
+                assert!(metrics.is_none());
             }
             _ => panic!("Expected Watch command"),
         }
@@ -207,6 +404,18 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_cmd_watch_metrics_addr() {
+        let cli = Cli::try_parse_from(["cqs", "watch", "--metrics", "127.0.0.1:9464"]).unwrap();
+        match cli.command {
+            Some(Commands::Watch { metrics, .. }) => {
+                assert_eq!(metrics, Some("127.0.0.1:9464".parse().unwrap()));
+            }
+            _ => panic!("Expected Watch command"),
+        }
+        assert!(Cli::try_parse_from(["cqs", "watch", "--metrics", "not-an-addr"]).is_err());
+    }
+
     #[test]
     fn test_cmd_watch_poll() {
         let cli = Cli::try_parse_from(["cqs", "watch", "--poll"]).unwrap();
@@ -485,6 +694,10 @@ mod tests {
             "src/**",
             "--rrf",
             "--include-docs",
+            "--index",
+            "svc-a",
+            "--index",
+            "svc-b",
         ])
         .expect("project search must accept full top-level filter surface");
         match cli.command {
@@ -500,6 +713,7 @@ mod tests {
                     path,
                     rrf,
                     include_docs,
+                    indexes,
                     ..
                 } => {
                     assert_eq!(query, "license activation");
@@ -515,6 +729,7 @@ mod tests {
                     assert_eq!(path.as_deref(), Some("src/**"));
                     assert!(*rrf);
                     assert!(*include_docs);
+                    assert_eq!(indexes, &["svc-a", "svc-b"]);
                 }
                 _ => panic!("Expected Search subcommand"),
             },
@@ -522,6 +737,48 @@ mod tests {
         }
     }
 
+    // ===== --index (federated search) tests =====
+
+    #[test]
+    fn test_cli_index_flag_repeats() {
+        let cli = Cli::try_parse_from([
+            "cqs",
+            "--index",
+            "svc-a",
+            "--index",
+            "svc-b",
+            "idempotency key handling",
+        ])
+        .unwrap();
+        assert_eq!(cli.indexes, ["svc-a", "svc-b"]);
+        assert_eq!(cli.query.as_deref(), Some("idempotency key handling"));
+    }
+
+    #[test]
+    fn test_cli_index_flag_conflicts_with_ref_and_at() {
+        assert!(Cli::try_parse_from(["cqs", "--index", "svc-a", "--ref", "tokio", "q"]).is_err());
+        assert!(Cli::try_parse_from(["cqs", "--index", "svc-a", "--at", "v1.0", "q"]).is_err());
+    }
+
+    // ===== -P/--project tests =====
+
+    #[test]
+    fn test_cli_project_flag_before_and_after_subcommand() {
+        let cli = Cli::try_parse_from(["cqs", "-P", "billing", "retry backoff"]).unwrap();
+        assert_eq!(cli.project.as_deref(), Some("billing"));
+        assert_eq!(cli.query.as_deref(), Some("retry backoff"));
+
+        let cli = Cli::try_parse_from(["cqs", "index", "--project", "billing"]).unwrap();
+        assert_eq!(cli.project.as_deref(), Some("billing"));
+        assert!(matches!(cli.command, Some(Commands::Index { .. })));
+    }
+
+    #[test]
+    fn test_cli_projects_alias() {
+        let cli = Cli::try_parse_from(["cqs", "projects", "list"]).unwrap();
+        assert!(matches!(cli.command, Some(Commands::Project { .. })));
+    }
+
     // ===== --ref flag tests =====
 
     #[test]
@@ -562,6 +819,26 @@ mod tests {
         assert!(cli.name_only);
     }
 
+    #[test]
+    fn test_cli_changed_since_conflicts_with_other_scopes() {
+        let cli =
+            Cli::try_parse_from(["cqs", "--changed-since", "origin/main", "flag check"]).unwrap();
+        assert_eq!(cli.changed_since.as_deref(), Some("origin/main"));
+        for other in [
+            &["--ref", "aveva"][..],
+            &["--include-refs"],
+            &["--at", "v1.0"],
+        ] {
+            let mut argv = vec!["cqs", "--changed-since", "origin/main"];
+            argv.extend_from_slice(other);
+            argv.push("q");
+            assert!(
+                Cli::try_parse_from(&argv).is_err(),
+                "--changed-since must conflict with {other:?}"
+            );
+        }
+    }
+
     // ===== --rerank / --reranker flag tests =====
 
     /// The `--rerank` bool is rejected by clap outright; callers must use the
@@ -605,6 +882,15 @@ mod tests {
         assert!(cli.rerank_active());
     }
 
+    /// `--reranker late` selects MaxSim rescoring, which counts as a rerank
+    /// stage (so `--name-only` rejects it too).
+    #[test]
+    fn test_cli_reranker_late() {
+        let cli = Cli::try_parse_from(["cqs", "--reranker", "late", "query"]).unwrap();
+        assert_eq!(cli.rerank_mode(), super::args::RerankerMode::Late);
+        assert!(cli.rerank_active());
+    }
+
     /// `--reranker none` is the default and stays inactive even when the flag
     /// is passed explicitly.
     #[test]
@@ -84,6 +84,19 @@ pub(crate) fn run_index_pipeline(
     let embedded_count = Arc::new(AtomicUsize::new(0));
     let gpu_failures = Arc::new(AtomicUsize::new(0));
     let parse_errors = Arc::new(AtomicUsize::new(0));
+    // `--sandbox` / `[index] sandbox_parsing`: parse in worker processes run
+    // from this binary, so a hostile file can only take down a worker.
+    let sandbox = if cqs::parser::sandbox::enabled() {
+        let exe = std::env::current_exe()
+            .context("Failed to locate the cqs binary for sandboxed parse workers")?;
+        tracing::info!("Parsing in sandboxed worker processes");
+        Some(Arc::new(cqs::parser::sandbox::SandboxPool::new(
+            exe,
+            cqs::parser::sandbox::SandboxLimits::from_env(),
+        )))
+    } else {
+        None
+    };
 
     // CPU embedder also races on parse_rx
     let parse_rx_cpu = parse_rx.clone();
@@ -97,6 +110,7 @@ pub(crate) fn run_index_pipeline(
         let parse_errors = Arc::clone(&parse_errors);
         let root = root.to_path_buf();
         let model_config = model_config.clone();
+        let sandbox = sandbox.clone();
         thread::spawn(move || {
             parser_stage(
                 files,
@@ -108,6 +122,7 @@ pub(crate) fn run_index_pipeline(
                     parsed_count,
                     parse_errors,
                     model_config,
+                    sandbox,
                 },
                 parse_tx,
             )
@@ -228,6 +243,18 @@ pub(crate) fn run_index_pipeline(
         parse_errors: parse_errors.load(Ordering::Relaxed),
         total_type_edges,
         total_calls,
+        sandbox_skipped: sandbox
+            .as_ref()
+            .map(|pool| pool.violations())
+            .unwrap_or_default(),
+        redactions: {
+            let mut redactions = parser.take_redactions();
+            if let Some(pool) = &sandbox {
+                redactions.extend(pool.take_redactions());
+            }
+            redactions.sort_by(|a, b| a.file.cmp(&b.file));
+            redactions
+        },
     };
 
     tracing::info!(
@@ -237,12 +264,53 @@ pub(crate) fn run_index_pipeline(
         parse_errors = stats.parse_errors,
         total_type_edges = stats.total_type_edges,
         total_calls = stats.total_calls,
+        redacted_files = stats.redactions.len(),
         "Pipeline indexing complete"
     );
 
     Ok(stats)
 }
 
+/// Print the files the parse sandbox skipped, relative to `root`. Prints
+/// nothing when sandboxing was off or every file parsed.
+pub(crate) fn print_sandbox_skips(stats: &PipelineStats, root: &Path) {
+    if stats.sandbox_skipped.is_empty() {
+        return;
+    }
+    println!(
+        "  Sandbox skipped: {} (not indexed)",
+        stats.sandbox_skipped.len()
+    );
+    for skip in &stats.sandbox_skipped {
+        let rel = skip.path.strip_prefix(root).unwrap_or(&skip.path);
+        println!("    {}: {}", cqs::normalize_path(rel), skip.kind);
+    }
+}
+
+/// Print the secrets masked during the run, per file and kind, relative to
+/// `root`. Prints nothing when no file held a match.
+pub(crate) fn print_secret_redactions(stats: &PipelineStats, root: &Path) {
+    if stats.redactions.is_empty() {
+        return;
+    }
+    let total: usize = stats.redactions.iter().map(|r| r.total()).sum();
+    println!(
+        "  Secrets redacted: {} in {} file{}",
+        total,
+        stats.redactions.len(),
+        if stats.redactions.len() == 1 { "" } else { "s" }
+    );
+    for report in &stats.redactions {
+        let rel = report.file.strip_prefix(root).unwrap_or(&report.file);
+        let kinds: Vec<String> = report
+            .counts
+            .iter()
+            .map(|(kind, n)| format!("{kind} x{n}"))
+            .collect();
+        println!("    {}: {}", cqs::normalize_path(rel), kinds.join(", "));
+    }
+}
+
 #[cfg(test)]
 mod tests {
     use super::embedding::create_embedded_batch;
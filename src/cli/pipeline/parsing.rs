@@ -27,6 +27,10 @@ pub(super) struct ParserStageContext {
     /// size. At batch=64 nomic-coderank (768 dim, 2048 seq) OOMs an 8 GB GPU;
     /// the model-aware helper drops it to 16.
     pub model_config: cqs::embedder::ModelConfig,
+    /// Parse through sandboxed worker processes instead of `parser` when set
+    /// (`cqs index --sandbox`). Files that break a sandbox limit come back as
+    /// parse errors; the pool keeps the report.
+    pub sandbox: Option<Arc<cqs::parser::sandbox::SandboxPool>>,
 }
 
 /// Read a full disk fingerprint (mtime + size + BLAKE3) for a file that is
@@ -257,6 +261,7 @@ pub(super) fn parser_stage(
         parsed_count,
         parse_errors,
         model_config,
+        sandbox,
     } = ctx;
     let batch_size = embed_batch_size_for(&model_config);
     let file_batch_size = file_batch_size();
@@ -327,7 +332,15 @@ pub(super) fn parser_stage(
                 || (Vec::new(), RelationshipData::default(), Vec::new()),
                 |(mut all_chunks, mut all_rels, mut all_failed), rel_path| {
                     let abs_path = root.join(rel_path);
-                    match parser.parse_file_all_with_chunk_calls(&abs_path) {
+                    let parsed = match sandbox.as_deref() {
+                        Some(pool) => pool
+                            .parse_file_all_with_chunk_calls(&abs_path)
+                            .map_err(anyhow::Error::from),
+                        None => parser
+                            .parse_file_all_with_chunk_calls(&abs_path)
+                            .map_err(anyhow::Error::from),
+                    };
+                    match parsed {
                         Ok((
                             mut chunks,
                             function_calls,
@@ -849,6 +862,7 @@ mod tests {
             parsed_count: Arc::new(AtomicUsize::new(0)),
             parse_errors: Arc::new(AtomicUsize::new(0)),
             model_config: cqs::embedder::ModelConfig::resolve(None, None),
+            sandbox: None,
         };
         parser_stage(rel_paths, ctx, tx).unwrap();
 
@@ -964,6 +978,7 @@ mod tests {
             parse_errors: Arc::new(AtomicUsize::new(0)),
             // `resolve` returns `Self`, not Result/Option — no `.unwrap()`.
             model_config: cqs::embedder::ModelConfig::resolve(None, None),
+            sandbox: None,
         };
         parser_stage(rel_paths, ctx, tx).unwrap();
 
@@ -1078,6 +1093,7 @@ mod tests {
             parsed_count: Arc::new(AtomicUsize::new(0)),
             parse_errors: Arc::new(AtomicUsize::new(0)),
             model_config: cqs::embedder::ModelConfig::resolve(None, None),
+            sandbox: None,
         };
         parser_stage(vec![rel.clone()], ctx, tx).unwrap();
 
@@ -1236,6 +1252,7 @@ mod tests {
             parsed_count: Arc::new(AtomicUsize::new(0)),
             parse_errors: Arc::new(AtomicUsize::new(0)),
             model_config: cqs::embedder::ModelConfig::resolve(None, None),
+            sandbox: None,
         };
         parser_stage(rel_paths, ctx, tx).unwrap();
 
@@ -1359,6 +1376,7 @@ mod tests {
             parsed_count: Arc::new(AtomicUsize::new(0)),
             parse_errors: Arc::new(AtomicUsize::new(0)),
             model_config: cqs::embedder::ModelConfig::resolve(None, None),
+            sandbox: None,
         };
         parser_stage(rel_paths, ctx, tx).unwrap();
 
@@ -1459,6 +1477,7 @@ mod tests {
             parsed_count: Arc::new(AtomicUsize::new(0)),
             parse_errors: Arc::clone(&parse_errors),
             model_config: cqs::embedder::ModelConfig::resolve(None, None),
+            sandbox: None,
         };
         parser_stage(vec![PathBuf::from("broken.rs")], ctx, tx).unwrap();
         assert!(
@@ -1531,6 +1550,7 @@ mod tests {
             parsed_count: Arc::new(AtomicUsize::new(0)),
             parse_errors: Arc::new(AtomicUsize::new(0)),
             model_config: cqs::embedder::ModelConfig::resolve(None, None),
+            sandbox: None,
         };
         parser_stage(vec![PathBuf::from("straddle.rs")], ctx, tx).unwrap();
 
@@ -1605,6 +1625,7 @@ mod tests {
             parsed_count: Arc::new(AtomicUsize::new(0)),
             parse_errors: Arc::new(AtomicUsize::new(0)),
             model_config: cqs::embedder::ModelConfig::resolve(None, None),
+            sandbox: None,
         };
         parser_stage(vec![PathBuf::from("empty.rs")], ctx, tx).unwrap();
 
@@ -1702,6 +1723,7 @@ mod tests {
             parsed_count: Arc::new(AtomicUsize::new(0)),
             parse_errors: Arc::clone(&parse_errors),
             model_config: cqs::embedder::ModelConfig::resolve(None, None),
+            sandbox: None,
         };
         parser_stage(vec![PathBuf::from("broken.rs")], ctx, tx).unwrap();
 
@@ -1797,6 +1819,7 @@ mod tests {
             parsed_count: Arc::new(AtomicUsize::new(0)),
             parse_errors: Arc::new(AtomicUsize::new(0)),
             model_config: cqs::embedder::ModelConfig::resolve(None, None),
+            sandbox: None,
         };
         parser_stage(vec![PathBuf::from("broken.rs")], ctx, parse_tx).unwrap();
 
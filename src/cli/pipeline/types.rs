@@ -82,6 +82,12 @@ pub(crate) struct PipelineStats {
     pub parse_errors: usize,
     pub total_type_edges: usize,
     pub total_calls: usize,
+    /// Files the parse sandbox skipped (also counted in `parse_errors`).
+    /// Empty unless sandboxing was on.
+    pub sandbox_skipped: Vec<cqs::parser::sandbox::SandboxViolation>,
+    /// Secrets masked in parsed files, sorted by path. Empty when redaction
+    /// is off or nothing matched.
+    pub redactions: Vec<cqs::secrets::FileRedactions>,
 }
 
 /// Result of preparing a batch for embedding.
@@ -39,6 +39,8 @@ pub(crate) fn window_overlap_tokens(max_tokens: usize) -> usize {
 
 /// Apply windowing to chunks that exceed the token limit.
 /// Long chunks are split into overlapping windows; short chunks pass through unchanged.
+/// Windows keep the symbol's name, signature, and line range; search stitches
+/// neighbouring window hits back into one result (`search::scoring::windows`).
 pub(crate) fn apply_windowing(chunks: Vec<Chunk>, embedder: &Embedder) -> Vec<Chunk> {
     let _span = tracing::info_span!("apply_windowing", chunk_count = chunks.len()).entered();
     let mut result = Vec::with_capacity(chunks.len());
@@ -62,8 +64,9 @@ pub(crate) fn apply_windowing(chunks: Vec<Chunk>, embedder: &Embedder) -> Vec<Ch
                 for (window_content, window_idx) in windows {
                     let window_hash = blake3::hash(window_content.as_bytes()).to_hex().to_string();
                     // Fallback canonicalization (whitespace-collapse only): a
-                    // comment edit inside a long windowed chunk shifts window
-                    // boundaries anyway, so tree-precision buys nothing here.
+                    // comment edit re-cuts the windows around it anyway, so
+                    // tree-precision buys nothing here. Windows away from
+                    // the edit keep their text and hit the cache.
                     let window_canonical = cqs::canonical_hash_fallback(&window_content);
                     result.push(Chunk {
                         // Code windows are split at the embedding stage, AFTER
@@ -0,0 +1,274 @@
+//! File watcher backend: the platform watcher, plus a poll fallback for the
+//! subtrees it cannot cover.
+//!
+//! `notify`'s inotify backend registers one watch per directory. When a tree
+//! has more directories than `fs.inotify.max_user_watches` allows, the
+//! recursive registration stops partway through and every directory past
+//! that point is silently unwatched — saves there never trigger a reindex.
+//! [`WatchBackend::start`] detects the limit error, reports it with the
+//! sysctl fix, and re-registers top-level subtree by subtree (skipping
+//! gitignored ones, which are usually what blew the budget). Subtrees that
+//! still don't fit are handed to a [`PollWatcher`]. Directories created later
+//! that hit the limit arrive as error events; [`WatchBackend::handle_error`]
+//! moves them to the poll watcher the same way.
+//!
+//! Both watchers feed the same channel. A path reported by both is
+//! deduplicated by the pending set.
+
+use std::path::{Path, PathBuf};
+use std::sync::mpsc::Sender;
+
+use anyhow::Result;
+use notify::{Config, PollWatcher, RecommendedWatcher, RecursiveMode, Watcher};
+
+type EventTx = Sender<notify::Result<notify::Event>>;
+
+/// Directories never handed to the fallback: watch ignores their events.
+const SKIPPED_TOP_LEVEL: &[&str] = &[".git", ".cqs"];
+
+/// Whether `err` means the platform watcher ran out of watch descriptors.
+/// inotify reports `ENOSPC` from `inotify_add_watch`; notify maps most of
+/// those to `MaxFilesWatch`, but not on every path.
+pub(super) fn is_watch_limit_error(err: &notify::Error) -> bool {
+    match &err.kind {
+        notify::ErrorKind::MaxFilesWatch => true,
+        #[cfg(target_os = "linux")]
+        notify::ErrorKind::Io(io) => io.raw_os_error() == Some(libc::ENOSPC),
+        _ => false,
+    }
+}
+
+/// Limit to suggest in the sysctl hint: enough for the tree with headroom,
+/// and never less than four times the current setting.
+fn suggested_watch_limit(limit: usize, dir_count: usize) -> usize {
+    limit.saturating_mul(4).max(dir_count.saturating_mul(2))
+}
+
+/// Non-ignored top-level directories of `root`, the unit of the fallback.
+fn top_level_dirs(root: &Path) -> Vec<PathBuf> {
+    let mut dirs: Vec<PathBuf> = ignore::WalkBuilder::new(root)
+        .hidden(false)
+        .require_git(false)
+        .max_depth(Some(1))
+        .build()
+        .flatten()
+        .filter(|e| e.depth() == 1 && e.file_type().is_some_and(|t| t.is_dir()))
+        .map(|e| e.into_path())
+        .filter(|p| {
+            p.file_name()
+                .and_then(|n| n.to_str())
+                .is_none_or(|n| !SKIPPED_TOP_LEVEL.contains(&n))
+        })
+        .collect();
+    dirs.sort();
+    dirs
+}
+
+pub(super) struct WatchBackend {
+    primary: Box<dyn Watcher>,
+    /// Created on first use, so a tree that fits the limit never spawns
+    /// the poll thread.
+    fallback: Option<PollWatcher>,
+    /// Roots of the polled subtrees.
+    polled: Vec<PathBuf>,
+    tx: EventTx,
+    config: Config,
+    quiet: bool,
+    limit_reported: bool,
+}
+
+impl WatchBackend {
+    /// Watch `root` recursively. `use_poll` selects the poll watcher for the
+    /// whole tree (`--poll`, WSL DrvFS); otherwise the platform watcher runs
+    /// with the poll fallback for subtrees past the watch limit.
+    pub(super) fn start(
+        root: &Path,
+        use_poll: bool,
+        config: Config,
+        tx: EventTx,
+        quiet: bool,
+    ) -> Result<Self> {
+        let primary: Box<dyn Watcher> = if use_poll {
+            Box::new(PollWatcher::new(tx.clone(), config)?)
+        } else {
+            Box::new(RecommendedWatcher::new(tx.clone(), config)?)
+        };
+        let mut backend = Self {
+            primary,
+            fallback: None,
+            polled: Vec::new(),
+            tx,
+            config,
+            quiet,
+            limit_reported: false,
+        };
+        match backend.primary.watch(root, RecursiveMode::Recursive) {
+            Ok(()) => return Ok(backend),
+            Err(e) if !use_poll && is_watch_limit_error(&e) => {
+                tracing::debug!(error = %e, "Recursive watch hit the watch limit");
+            }
+            Err(e) => return Err(e.into()),
+        }
+
+        // The recursive registration stopped partway. Drop what it managed
+        // and rebuild subtree by subtree so the budget goes to the
+        // directories that matter.
+        if let Err(e) = backend.primary.unwatch(root) {
+            tracing::debug!(error = %e, "Unwatch after partial registration failed");
+        }
+        backend.report_limit(root);
+        backend.primary.watch(root, RecursiveMode::NonRecursive)?;
+        let dirs = top_level_dirs(root);
+        let mut native = 0usize;
+        for dir in &dirs {
+            match backend.primary.watch(dir, RecursiveMode::Recursive) {
+                Ok(()) => native += 1,
+                Err(e) if is_watch_limit_error(&e) => {
+                    if let Err(e) = backend.primary.unwatch(dir) {
+                        tracing::debug!(error = %e, dir = %dir.display(), "Unwatch after partial registration failed");
+                    }
+                    backend.poll(dir)?;
+                }
+                Err(e) => return Err(e.into()),
+            }
+        }
+        tracing::warn!(
+            native,
+            polled = backend.polled.len(),
+            polled_dirs = ?backend.polled,
+            "Watching with poll fallback for subtrees past the inotify limit"
+        );
+        if !quiet && !backend.polled.is_empty() {
+            eprintln!(
+                "[warn] Polling {} subtree(s) the inotify limit can't cover: {}",
+                backend.polled.len(),
+                backend
+                    .polled
+                    .iter()
+                    .map(|p| p.strip_prefix(root).unwrap_or(p).display().to_string())
+                    .collect::<Vec<_>>()
+                    .join(", ")
+            );
+        }
+        Ok(backend)
+    }
+
+    /// Handle a watcher error event. A watch-limit error (a directory created
+    /// after startup that inotify could not register) moves the affected
+    /// paths to the poll fallback; returns `false` for any other error so
+    /// the caller logs it as before.
+    pub(super) fn handle_error(&mut self, root: &Path, err: &notify::Error) -> bool {
+        if !is_watch_limit_error(err) {
+            return false;
+        }
+        self.report_limit(root);
+        for path in &err.paths {
+            let dir = if path.is_dir() {
+                path.as_path()
+            } else {
+                path.parent().unwrap_or(root)
+            };
+            if let Err(e) = self.poll(dir) {
+                tracing::error!(error = %e, dir = %dir.display(), "Poll fallback failed — saves under this directory will not trigger reindex");
+            }
+        }
+        true
+    }
+
+    /// Add `dir` to the poll watcher unless a polled ancestor covers it.
+    fn poll(&mut self, dir: &Path) -> Result<()> {
+        if self.polled.iter().any(|p| dir.starts_with(p)) {
+            return Ok(());
+        }
+        if self.fallback.is_none() {
+            self.fallback = Some(PollWatcher::new(self.tx.clone(), self.config)?);
+        }
+        if let Some(fallback) = self.fallback.as_mut() {
+            fallback.watch(dir, RecursiveMode::Recursive)?;
+        }
+        tracing::info!(dir = %dir.display(), "Subtree moved to the poll watcher");
+        self.polled.push(dir.to_path_buf());
+        Ok(())
+    }
+
+    /// Log the watch-limit error with the sysctl fix, once per daemon.
+    fn report_limit(&mut self, root: &Path) {
+        if std::mem::replace(&mut self.limit_reported, true) {
+            return;
+        }
+        #[cfg(target_os = "linux")]
+        let limit = std::fs::read_to_string("/proc/sys/fs/inotify/max_user_watches")
+            .ok()
+            .and_then(|s| s.trim().parse::<usize>().ok());
+        #[cfg(not(target_os = "linux"))]
+        let limit: Option<usize> = None;
+        #[cfg(target_os = "linux")]
+        let dir_count = super::count_watchable_dirs(root);
+        #[cfg(not(target_os = "linux"))]
+        let dir_count = {
+            let _ = root;
+            0
+        };
+        let suggested = suggested_watch_limit(limit.unwrap_or(8192), dir_count);
+        tracing::error!(
+            ?limit,
+            dir_count,
+            suggested,
+            "inotify watch limit exhausted — falling back to polling for the subtrees it can't \
+             cover. Raise the limit with: sudo sysctl -w fs.inotify.max_user_watches={suggested} \
+             (persist it in /etc/sysctl.d/), then restart cqs watch"
+        );
+        if !self.quiet {
+            eprintln!(
+                "[error] inotify watch limit ({}) exhausted by {} dirs in this tree.\n\
+                 [error]   Falling back to polling for the subtrees it can't cover. Raise the limit:\n\
+                 [error]     sudo sysctl -w fs.inotify.max_user_watches={}",
+                limit.map_or_else(|| "unknown".to_string(), |l| l.to_string()),
+                dir_count,
+                suggested
+            );
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn limit_error_detection() {
+        assert!(is_watch_limit_error(&notify::Error::new(
+            notify::ErrorKind::MaxFilesWatch
+        )));
+        assert!(!is_watch_limit_error(&notify::Error::generic("boom")));
+        #[cfg(target_os = "linux")]
+        assert!(is_watch_limit_error(&notify::Error::io(
+            std::io::Error::from_raw_os_error(libc::ENOSPC)
+        )));
+        assert!(!is_watch_limit_error(&notify::Error::io(
+            std::io::Error::from(std::io::ErrorKind::PermissionDenied)
+        )));
+    }
+
+    #[test]
+    fn suggested_limit_covers_tree_with_headroom() {
+        assert_eq!(suggested_watch_limit(8192, 1000), 32768);
+        assert_eq!(suggested_watch_limit(8192, 50_000), 100_000);
+    }
+
+    #[test]
+    fn top_level_dirs_skip_ignored_and_internal() {
+        let tmp = tempfile::TempDir::new().unwrap();
+        let root = tmp.path();
+        for d in ["src", "docs", "target", ".cqs", ".git", "src/nested"] {
+            std::fs::create_dir_all(root.join(d)).unwrap();
+        }
+        std::fs::write(root.join(".gitignore"), "target/\n").unwrap();
+        std::fs::write(root.join("README.md"), "x").unwrap();
+        let names: Vec<String> = top_level_dirs(root)
+            .iter()
+            .map(|p| p.file_name().unwrap().to_string_lossy().into_owned())
+            .collect();
+        assert_eq!(names, vec!["docs".to_string(), "src".to_string()]);
+    }
+}
@@ -48,6 +48,9 @@ use super::{
 /// `cmd_watch` scope (not local to this thread) so the watch loop can
 /// sample it into the `cqs status --watch` ops block. This
 /// thread is the only writer; the watch loop only `load`s.
+///
+/// `drain_budget`: how long, once the accept loop stops, the thread waits
+/// for in-flight queries before returning (see [`wait_for_drain`]).
 /// RAII guard for the daemon's in-flight connection counter. The counter is
 /// bumped before a client thread is spawned (so the concurrency-cap check sees
 /// it immediately); this guard, held inside that thread, decrements on `Drop`.
@@ -78,6 +81,7 @@ pub(super) fn spawn_daemon_thread(
     daemon_pending_notes_signal: cqs::watch_status::SharedNotesSignal,
     daemon_fresh_notifier: cqs::watch_status::SharedFreshNotifier,
     in_flight: Arc<AtomicUsize>,
+    drain_budget: Duration,
 ) -> JoinHandle<()> {
     std::thread::spawn(move || {
         // BatchContext created inside the thread — RefCell is !Send
@@ -305,14 +309,59 @@ pub(super) fn spawn_daemon_thread(
                 }
             }
         }
+        // Stop accepting before draining: with the listener closed, a client
+        // connecting during the drain gets ECONNREFUSED and falls back to
+        // the CLI path instead of queueing behind a process that is exiting.
+        drop(listener);
+        let report = wait_for_drain(&in_flight, drain_budget, Duration::from_millis(50));
+        if report.remaining == 0 {
+            tracing::info!(
+                waited_ms = report.waited.as_millis() as u64,
+                "Daemon in-flight queries drained"
+            );
+        } else {
+            tracing::warn!(
+                remaining = report.remaining,
+                budget_secs = drain_budget.as_secs(),
+                "Daemon drain deadline reached — abandoning in-flight queries"
+            );
+        }
     })
 }
 
+/// Outcome of [`wait_for_drain`].
+#[derive(Debug)]
+pub(super) struct DrainReport {
+    /// Queries still running when the wait ended; `0` means fully drained.
+    pub remaining: usize,
+    pub waited: Duration,
+}
+
+/// Wait, polling every `poll`, for the in-flight counter to reach zero or
+/// `budget` to run out. A zero budget returns the current count without
+/// waiting.
+pub(super) fn wait_for_drain(
+    in_flight: &AtomicUsize,
+    budget: Duration,
+    poll: Duration,
+) -> DrainReport {
+    let start = std::time::Instant::now();
+    loop {
+        let remaining = in_flight.load(Ordering::Acquire);
+        let waited = start.elapsed();
+        if remaining == 0 || waited >= budget {
+            return DrainReport { remaining, waited };
+        }
+        std::thread::sleep(poll.min(budget - waited));
+    }
+}
+
 #[cfg(test)]
 mod inflight_tests {
-    use super::InFlightGuard;
+    use super::{wait_for_drain, InFlightGuard};
     use std::sync::atomic::{AtomicUsize, Ordering};
     use std::sync::Arc;
+    use std::time::Duration;
 
     #[test]
     fn guard_decrements_on_normal_drop() {
@@ -342,4 +391,43 @@ mod inflight_tests {
             "in-flight slot must be released even when the handler panics"
         );
     }
+
+    #[test]
+    fn drain_returns_immediately_when_idle() {
+        let c = AtomicUsize::new(0);
+        let r = wait_for_drain(&c, Duration::from_secs(10), Duration::from_millis(10));
+        assert_eq!(r.remaining, 0);
+        assert!(r.waited < Duration::from_secs(1));
+    }
+
+    #[test]
+    fn drain_waits_for_in_flight_query_to_finish() {
+        let c = Arc::new(AtomicUsize::new(1));
+        let guard = InFlightGuard(Arc::clone(&c));
+        let query = std::thread::spawn(move || {
+            std::thread::sleep(Duration::from_millis(100));
+            drop(guard);
+        });
+        let r = wait_for_drain(&c, Duration::from_secs(10), Duration::from_millis(10));
+        query.join().unwrap();
+        assert_eq!(r.remaining, 0, "query finished inside the budget");
+        assert!(r.waited >= Duration::from_millis(100));
+    }
+
+    #[test]
+    fn drain_gives_up_at_deadline_on_stuck_query() {
+        let c = AtomicUsize::new(2);
+        let r = wait_for_drain(&c, Duration::from_millis(150), Duration::from_millis(10));
+        assert_eq!(r.remaining, 2, "stuck queries are reported, not waited out");
+        assert!(r.waited >= Duration::from_millis(150));
+        assert!(r.waited < Duration::from_secs(2));
+    }
+
+    #[test]
+    fn zero_budget_skips_the_wait() {
+        let c = AtomicUsize::new(1);
+        let r = wait_for_drain(&c, Duration::ZERO, Duration::from_millis(10));
+        assert_eq!(r.remaining, 1);
+        assert!(r.waited < Duration::from_millis(100));
+    }
 }
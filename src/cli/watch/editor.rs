@@ -0,0 +1,162 @@
+//! Editor save artifacts: swap, backup, lock, and atomic-save temp files.
+//!
+//! Editors rarely write a file in place. Vim renames the original to `foo~`
+//! and writes a fresh `foo` (probing the directory with a `4913` file first)
+//! while keeping `.foo.swp` open; Emacs holds a `.#foo` lock symlink and
+//! autosaves to `#foo#`; VS Code, JetBrains, GNOME, and most atomic-write
+//! libraries write a temp file beside the origin and rename it over the top.
+//! Every one of those steps fires events, and some temp names (`.#lib.rs`)
+//! even carry a supported extension.
+//!
+//! [`is_editor_temp`] drops the artifacts before any other filter runs.
+//! [`rename_pair_class`] files the rename that completes a save as what it
+//! is — a content change of the origin — rather than a delete of the old
+//! inode and a create of the new one, so the origin waits the modify gap and
+//! is reindexed once instead of being deleted and recreated across two
+//! flushes.
+
+use std::path::Path;
+
+use notify::event::{ModifyKind, RenameMode};
+use notify::EventKind;
+
+use super::EventClass;
+
+/// File-name suffixes of editor temp and backup files.
+const TEMP_SUFFIXES: &[&str] = &[
+    "~",    // Vim / Emacs / nano backup
+    ".tmp", // VS Code, atomic-write libraries
+    ".temp",
+    ".kate-swp",    // Kate
+    ".crswap",      // Chromium File System Access
+    "___jb_tmp___", // JetBrains safe write
+    "___jb_old___",
+];
+
+/// File-name prefixes of editor temp and lock files.
+const TEMP_PREFIXES: &[&str] = &[
+    ".#",              // Emacs lock symlink
+    ".goutputstream-", // GNOME / GIO atomic save
+    ".~lock.",         // LibreOffice lock
+];
+
+/// Whether `path` names an editor swap, backup, lock, or atomic-save temp
+/// file. Matches on the file name only; never touches the filesystem.
+pub(super) fn is_editor_temp(path: &Path) -> bool {
+    let Some(name) = path.file_name().and_then(|n| n.to_str()) else {
+        return false;
+    };
+    if name == "4913" {
+        // Vim's write-permission probe.
+        return true;
+    }
+    if name.len() > 2 && name.starts_with('#') && name.ends_with('#') {
+        // Emacs autosave.
+        return true;
+    }
+    if TEMP_SUFFIXES.iter().any(|s| name.ends_with(s))
+        || TEMP_PREFIXES.iter().any(|p| name.starts_with(p))
+    {
+        return true;
+    }
+    // Vim swap files: `.swp`, then `.swo`, `.swn`, ... down to `.swa`.
+    path.extension()
+        .and_then(|e| e.to_str())
+        .is_some_and(|ext| {
+            let ext = ext.as_bytes();
+            ext.len() == 3 && ext[0] == b's' && ext[1] == b'w' && (b'a'..=b'p').contains(&ext[2])
+        })
+}
+
+/// Debounce class for `path` in `event`, folding the renames of an atomic
+/// save into a content change:
+///
+/// - a rename pair with an editor temp on either side (`foo.tmp → foo`, or
+///   Vim's `foo → foo~` backup) is a save of the origin;
+/// - a remove or rename-away of a path that exists again by the time the
+///   event is read means the save already put the new file in place.
+///
+/// Everything else keeps its [`EventClass::of`] class.
+pub(super) fn rename_pair_class(event: &notify::Event, path_exists: bool) -> EventClass {
+    let class = EventClass::of(&event.kind);
+    match &event.kind {
+        EventKind::Modify(ModifyKind::Name(RenameMode::Both))
+            if event.paths.iter().any(|p| is_editor_temp(p)) =>
+        {
+            EventClass::Modify
+        }
+        EventKind::Remove(_) | EventKind::Modify(ModifyKind::Name(RenameMode::From))
+            if path_exists =>
+        {
+            EventClass::Modify
+        }
+        _ => class,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::path::PathBuf;
+
+    fn event(kind: EventKind, paths: &[&str]) -> notify::Event {
+        notify::Event {
+            kind,
+            paths: paths.iter().map(PathBuf::from).collect(),
+            attrs: Default::default(),
+        }
+    }
+
+    #[test]
+    fn editor_artifacts_are_recognized() {
+        for name in [
+            "src/lib.rs~",
+            "src/.lib.rs.swp",
+            "src/.lib.rs.swo",
+            "src/4913",
+            "src/.#lib.rs",
+            "src/#lib.rs#",
+            "src/lib.rs.tmp",
+            "src/lib.rs___jb_tmp___",
+            "src/.goutputstream-X1Y2Z3",
+            "src/lib.rs.kate-swp",
+        ] {
+            assert!(is_editor_temp(Path::new(name)), "{name}");
+        }
+    }
+
+    #[test]
+    fn source_files_are_not_editor_artifacts() {
+        for name in [
+            "src/lib.rs",
+            "src/swap.rs",
+            "src/#",
+            "src/tmp.rs",
+            "src/a.swift",
+        ] {
+            assert!(!is_editor_temp(Path::new(name)), "{name}");
+        }
+    }
+
+    #[test]
+    fn atomic_save_renames_classify_as_modify() {
+        let both = EventKind::Modify(ModifyKind::Name(RenameMode::Both));
+        let save = event(both, &["src/lib.rs.tmp", "src/lib.rs"]);
+        assert_eq!(rename_pair_class(&save, true), EventClass::Modify);
+        let backup = event(both, &["src/lib.rs", "src/lib.rs~"]);
+        assert_eq!(rename_pair_class(&backup, false), EventClass::Modify);
+        // A real rename between two source files is still a create.
+        let mv = event(both, &["src/a.rs", "src/b.rs"]);
+        assert_eq!(rename_pair_class(&mv, true), EventClass::Create);
+    }
+
+    #[test]
+    fn remove_of_a_path_that_exists_again_is_a_modify() {
+        let rm = event(
+            EventKind::Remove(notify::event::RemoveKind::File),
+            &["src/lib.rs"],
+        );
+        assert_eq!(rename_pair_class(&rm, true), EventClass::Modify);
+        assert_eq!(rename_pair_class(&rm, false), EventClass::Delete);
+    }
+}
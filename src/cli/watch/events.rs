@@ -32,25 +32,42 @@ pub(super) fn collect_events(event: &notify::Event, cfg: &WatchConfig, state: &m
     )
     .entered();
     for path in &event.paths {
+        // Editor swap / backup / lock / atomic-save temp files never reach
+        // the index; the rename that lands the save is filed under the
+        // origin below.
+        if super::editor::is_editor_temp(path) {
+            tracing::trace!(path = %path.display(), "Skipping editor temp file");
+            continue;
+        }
         // Skip canonicalize for deleted files — dunce::canonicalize
         // requires the file to exist (calls std::fs::canonicalize internally).
-        let path = if path.exists() {
+        let exists = path.exists();
+        let path = if exists {
             dunce::canonicalize(path).unwrap_or_else(|_| path.clone())
         } else {
             path.clone()
         };
+        // Config and ignore-file edits queue a hot reload. Checked before
+        // the filters below: `.cqs.toml` shares a string prefix with `.cqs/`
+        // and may be gitignored. The path then falls through like any other.
+        if is_reload_trigger(cfg.root, &path) {
+            tracing::debug!(path = %path.display(), "Config file changed; reload queued");
+            state.config_reload_pending = true;
+        }
+
         // Skip .cqs directory. Deleted files can't be canonicalized (they
         // don't exist), so compare normalized string forms to handle slash
         // differences on WSL.
         let norm_path = cqs::normalize_path(&path);
         let norm_cqs = cqs::normalize_path(cfg.cqs_dir);
-        if norm_path.starts_with(&norm_cqs) {
+        if norm_path.starts_with(&norm_cqs) || cqs::paths::strip_root(&path, cfg.cqs_dir).is_some()
+        {
             tracing::debug!(path = %norm_path, "Skipping .cqs directory event");
             continue;
         }
 
-        // .gitignore-matched paths are skipped. The matcher was built once
-        // at cmd_watch startup; when it's None the user either
+        // .gitignore-matched paths are skipped. The matcher is built at
+        // cmd_watch startup and rebuilt on reload; when it's None the user either
         // set CQS_WATCH_RESPECT_GITIGNORE=0, passed --no-ignore, or has no
         // .gitignore. The hardcoded `.cqs/` skip above still runs
         // regardless so the system's own files are always excluded.
@@ -85,86 +102,140 @@ pub(super) fn collect_events(event: &notify::Event, cfg: &WatchConfig, state: &m
             continue;
         }
 
-        // Skip if not a supported extension
+        // Skip if not a supported extension, unless a `[languages]
+        // override` claims the file (or skips a supported one).
         let ext_raw = path.extension().and_then(|e| e.to_str()).unwrap_or("");
         let ext = ext_raw.to_ascii_lowercase();
-        if !cfg.supported_ext.contains(ext.as_str()) {
+        let supported = match cqs::language::overrides::lookup(&path) {
+            Some(decision) => decision.is_some(),
+            None => cfg.supported_ext.contains(ext.as_str()),
+        };
+        if !supported {
             tracing::debug!(path = %path.display(), ext = %ext, "Skipping unsupported extension");
             continue;
         }
 
-        // Convert to relative path
-        if let Ok(rel) = path.strip_prefix(cfg.root) {
-            // mtime-equality skip is gated on the filesystem's actual mtime
-            // resolution, not just WSL drvfs.
-            //
-            // - `mtime < last`: rewind (e.g. `git checkout` restoring a
-            //   commit-time mtime). Skip — the inotify path is for real save
-            //   events; a rewound mtime without a matching save is treated
-            //   as already-indexed.
-            // - `mtime == last`: ambiguous on coarse FS (two saves
-            //   inside the same resolution window collide on identical
-            //   mtimes), unambiguous on fine FS (nanosecond equality
-            //   means the same save). Skip iff `resolution.is_zero()`.
-            // - `mtime > last`: a real save advanced the mtime → not
-            //   stale, regardless of FS resolution.
-            //
-            // `coarse_fs_resolution` covers HFS+ / NFS / SMB / FAT32 on
-            // plain Linux + macOS too, so they don't silently drop rapid
-            // re-saves.
-            if let Ok(mtime) = std::fs::metadata(&path).and_then(|m| m.modified()) {
-                let resolution = cqs::config::coarse_fs_resolution(&path);
-                let stale = state.last_indexed_mtime.get(rel).is_some_and(|last| {
-                    if mtime < *last {
-                        true
-                    } else if mtime == *last {
-                        resolution.is_zero()
-                    } else {
-                        false
-                    }
-                });
-                if stale {
-                    tracing::trace!(path = %rel.display(), "Skipping unchanged mtime");
-                    continue;
+        // Convert to relative path. `strip_root` ignores case where the
+        // filesystem does, so a Windows event reported as `C:\Proj\src\x.rs`
+        // under a canonical `c:\proj` root is not silently dropped. A path
+        // through a symlink maps per `follow_symlinks` like the walk: dropped
+        // under `false`, rewritten to its real origin when it stays inside
+        // the project.
+        let Some(resolved) =
+            cqs::symlinks::resolve_event_path(cfg.root, &path, cfg.follow_symlinks)
+        else {
+            tracing::trace!(path = %norm_path, "Skipping event outside project or through excluded symlink");
+            continue;
+        };
+        let rel = resolved.as_path();
+        // mtime-equality skip is gated on the filesystem's actual mtime
+        // resolution, not just WSL drvfs.
+        //
+        // - `mtime < last`: rewind (e.g. `git checkout` restoring a
+        //   commit-time mtime). Skip — the inotify path is for real save
+        //   events; a rewound mtime without a matching save is treated
+        //   as already-indexed.
+        // - `mtime == last`: ambiguous on coarse FS (two saves
+        //   inside the same resolution window collide on identical
+        //   mtimes), unambiguous on fine FS (nanosecond equality
+        //   means the same save). Skip iff `resolution.is_zero()`.
+        // - `mtime > last`: a real save advanced the mtime → not
+        //   stale, regardless of FS resolution.
+        //
+        // `coarse_fs_resolution` covers HFS+ / NFS / SMB / FAT32 on
+        // plain Linux + macOS too, so they don't silently drop rapid
+        // re-saves.
+        if let Ok(mtime) = std::fs::metadata(&path).and_then(|m| m.modified()) {
+            let resolution = cqs::config::coarse_fs_resolution(&path);
+            let stale = state.last_indexed_mtime.get(rel).is_some_and(|last| {
+                if mtime < *last {
+                    true
+                } else if mtime == *last {
+                    resolution.is_zero()
+                } else {
+                    false
                 }
+            });
+            if stale {
+                tracing::trace!(path = %rel.display(), "Skipping unchanged mtime");
+                continue;
             }
-            if state.pending_files.len() < max_pending_files() {
-                // Keep the queue keyed on slash-normalized paths so a
-                // Windows-side edit and a reconcile-side walk can't
-                // double-queue the same file under two separators.
-                state
-                    .pending_files
-                    .insert(super::reconcile::normalize_pending_path(rel));
-                // Debug the accept-and-queue branch. Operators investigating
-                // "why didn't my save get reindexed?" can
-                // `RUST_LOG=cqs::cli::watch=debug` and see the full
-                // event → queue chain.
-                tracing::debug!(
-                    path = %rel.display(),
-                    event_kind = ?event.kind,
-                    "Queued for reindex"
-                );
-            } else {
-                // Log per-event at debug (spammy on bulk drops) and
-                // accumulate a counter; the once-per-cycle summary fires in
-                // process_file_changes so operators see the total
-                // truncation even if the level is info.
-                state.dropped_this_cycle = state.dropped_this_cycle.saturating_add(1);
-                tracing::debug!(
-                    max = max_pending_files(),
-                    path = %rel.display(),
-                    "Watch pending_files full, dropping file event"
+        }
+        // Keep the queue keyed on slash-normalized, on-disk-cased paths so
+        // a Windows-side edit and a reconcile-side walk can't double-queue
+        // the same file under two separators or two casings (and index it
+        // as a duplicate origin).
+        let key =
+            super::reconcile::normalize_pending_path(&cqs::paths::on_disk_case(cfg.root, rel));
+        let class = super::editor::rename_pair_class(event, exists);
+        let now = std::time::Instant::now();
+        match state.storm.record(now) {
+            super::storm::StormVerdict::Queue => {}
+            super::storm::StormVerdict::Started => {
+                // A branch switch or similar bulk rewrite: stop queueing
+                // per file and let the bulk pass walk the tree once the
+                // storm is over.
+                super::storm::hold_dirty_marker(cfg.cqs_dir);
+                cqs::metrics::WATCH_EVENT_STORMS.inc();
+                tracing::warn!(
+                    pending = state.pending_files.len(),
+                    "File event storm detected — suspending per-file reindex until it settles"
                 );
+                if !cfg.quiet {
+                    println!(
+                        "Event storm detected (branch switch?); reindexing in bulk once it settles"
+                    );
+                }
+                state.last_event = now;
+                continue;
             }
-            state.last_event = std::time::Instant::now();
-            // Arm the max-latency clock on the first event of a burst.
-            // `last_event` restarts the quiet-gap timer on every event;
-            // this one is sticky until the flush drains the pending
-            // sets, bounding total delay under a never-quiet stream.
-            if state.first_pending_event.is_none() {
-                state.first_pending_event = Some(state.last_event);
+            super::storm::StormVerdict::Suppressed => {
+                tracing::trace!(path = %rel.display(), "Suppressed during event storm");
+                state.last_event = now;
+                continue;
             }
         }
+        if state.pending_files.contains(&key) {
+            // Same origin already queued: a save-save-save loop folds into
+            // the one pending entry and is reindexed (and embedded) once.
+            // Still counts as an event — it restarts the quiet gap below.
+            state.coalesced_this_cycle = state.coalesced_this_cycle.saturating_add(1);
+            state.pending_classes |= class.bit();
+            cqs::metrics::WATCH_COALESCED_EVENTS.inc();
+            tracing::trace!(path = %rel.display(), event_kind = ?event.kind, "Coalesced into pending entry");
+        } else if state.pending_files.len() < max_pending_files() {
+            state.pending_files.insert(key);
+            state.pending_classes |= class.bit();
+            // Debug the accept-and-queue branch. Operators investigating
+            // "why didn't my save get reindexed?" can
+            // `RUST_LOG=cqs::cli::watch=debug` and see the full
+            // event → queue chain.
+            tracing::debug!(
+                path = %rel.display(),
+                event_kind = ?event.kind,
+                "Queued for reindex"
+            );
+        } else {
+            // Log per-event at debug (spammy on bulk drops) and
+            // accumulate a counter; the once-per-cycle summary fires in
+            // process_file_changes so operators see the total
+            // truncation even if the level is info.
+            state.dropped_this_cycle = state.dropped_this_cycle.saturating_add(1);
+            cqs::metrics::WATCH_DROPPED_EVENTS.inc();
+            tracing::debug!(
+                max = max_pending_files(),
+                path = %rel.display(),
+                "Watch pending_files full, dropping file event"
+            );
+        }
+        state.last_event = now;
+        // Arm the max-latency clock on the first event of a burst.
+        // `last_event` restarts the quiet-gap timer on every event;
+        // this one is sticky until the flush drains the pending
+        // sets, bounding total delay under a never-quiet stream.
+        if state.first_pending_event.is_none() {
+            state.first_pending_event = Some(state.last_event);
+        }
     }
 }
 
@@ -209,9 +280,11 @@ pub(super) fn process_file_changes(
     // (journald JSON or stderr text) and honours filter levels.
     tracing::info!(
         file_count = files.len(),
+        coalesced = state.coalesced_this_cycle,
         files = ?files,
         "watch: reindexing changed files",
     );
+    state.coalesced_this_cycle = 0;
 
     let emb = match try_init_embedder(cfg.embedder, &mut state.embedder_backoff, cfg.model_config) {
         Some(e) => e,
@@ -275,6 +348,15 @@ pub(super) fn process_file_changes(
                     .unwrap_or(u64::MAX),
                 files: u64::try_from(files.len()).unwrap_or(u64::MAX),
             });
+            cqs::metrics::REINDEX_DURATION.observe(reindex_started.elapsed());
+            match store.splade_generation() {
+                Ok(generation) => cqs::metrics::INDEX_GENERATION
+                    .set(i64::try_from(generation).unwrap_or(i64::MAX)),
+                Err(e) => tracing::debug!(error = %e, "index generation read failed"),
+            }
+            // Changed chunks carry new hashes; the idle tick reconciles
+            // which summaries that left behind.
+            state.summaries_dirty = true;
             // Record mtimes to skip duplicate events
             for (file, mtime) in pre_mtimes {
                 state.last_indexed_mtime.insert(file, mtime);
@@ -0,0 +1,160 @@
+//! Crash-recovery journal for the pending queue.
+//!
+//! `WatchState::pending_files` / `pending_notes` live in memory between an
+//! event and the debounced flush. [`PendingJournal`] keeps the store's
+//! `watch_journal` table in step with them: paths are journaled when they are
+//! queued, cleared once the flush has consumed them, and re-queued at startup
+//! if a previous daemon died in between. Journal writes are best-effort — a
+//! failed write is retried on the next sync and never blocks a reindex.
+
+use std::collections::HashSet;
+use std::path::{Path, PathBuf};
+use std::time::{Duration, Instant};
+
+use cqs::store::{JournalReason, Store};
+
+use super::events::max_pending_files;
+use super::reconcile::normalize_pending_path;
+use super::WatchState;
+
+/// Minimum spacing of the per-iteration sync under a continuous event
+/// stream. Reconcile walks and the flush sync unconditionally.
+const SYNC_INTERVAL: Duration = Duration::from_millis(250);
+
+/// In-memory view of what `watch_journal` holds for this daemon.
+pub(super) struct PendingJournal {
+    /// Queue paths already written to the journal.
+    journaled: HashSet<PathBuf>,
+    /// Whether the notes row is journaled.
+    notes_journaled: bool,
+    /// Journal key of the notes file (project-relative).
+    notes_key: String,
+    last_sync: Instant,
+}
+
+impl PendingJournal {
+    pub(super) fn new(root: &Path, notes_path: &Path) -> Self {
+        let rel = notes_path.strip_prefix(root).unwrap_or(notes_path);
+        Self {
+            journaled: HashSet::new(),
+            notes_journaled: false,
+            notes_key: cqs::normalize_path(rel),
+            last_sync: Instant::now(),
+        }
+    }
+
+    /// Re-queue what a previous daemon journaled but never flushed. Entries
+    /// past the queue cap count as dropped (the reconcile picks them up) and
+    /// their rows are cleared by the first flush. Returns the number of
+    /// entries re-queued.
+    pub(super) fn restore(&mut self, store: &Store, state: &mut WatchState) -> usize {
+        let entries = match store.watch_journal_entries() {
+            Ok(entries) => entries,
+            Err(e) => {
+                tracing::warn!(error = %e, "Failed to read watch journal — skipping crash recovery");
+                return 0;
+            }
+        };
+        if entries.is_empty() {
+            return 0;
+        }
+        let mut requeued = 0;
+        for entry in &entries {
+            if entry.reason == JournalReason::Notes {
+                state.pending_notes = true;
+                self.notes_journaled = true;
+                requeued += 1;
+                continue;
+            }
+            let path = normalize_pending_path(Path::new(&entry.path));
+            if state.pending_files.len() < max_pending_files() {
+                state.pending_files.insert(path.clone());
+                requeued += 1;
+            } else {
+                state.dropped_this_cycle = state.dropped_this_cycle.saturating_add(1);
+            }
+            self.journaled.insert(path);
+        }
+        if requeued > 0 {
+            state.last_event = Instant::now();
+            state.first_pending_event = Some(state.last_event);
+        }
+        let oldest = entries.iter().map(|e| e.queued_at).min().unwrap_or(0);
+        tracing::info!(
+            requeued,
+            journaled = entries.len(),
+            oldest_queued_at = oldest,
+            "Resumed pending work from the watch journal (previous daemon did not flush)"
+        );
+        requeued
+    }
+
+    /// Whether the throttled per-iteration sync should run.
+    pub(super) fn sync_due(&self) -> bool {
+        self.last_sync.elapsed() >= SYNC_INTERVAL
+    }
+
+    /// Journal every queued path not journaled yet, tagged `reason`.
+    pub(super) fn sync(&mut self, store: &Store, state: &WatchState, reason: JournalReason) {
+        self.last_sync = Instant::now();
+        let mut fresh: Vec<(String, JournalReason)> = state
+            .pending_files
+            .iter()
+            .filter(|p| !self.journaled.contains(*p))
+            .map(|p| (cqs::normalize_path(p), reason))
+            .collect();
+        let notes = state.pending_notes && !self.notes_journaled;
+        if notes {
+            fresh.push((self.notes_key.clone(), JournalReason::Notes));
+        }
+        if fresh.is_empty() {
+            return;
+        }
+        match store.journal_watch_paths(&fresh) {
+            Ok(_) => {
+                self.journaled.extend(state.pending_files.iter().cloned());
+                self.notes_journaled |= notes;
+            }
+            Err(e) => {
+                tracing::warn!(error = %e, count = fresh.len(), "Failed to journal pending watch paths");
+            }
+        }
+    }
+
+    /// Clear the rows of journaled paths the flush consumed — everything no
+    /// longer in the pending sets.
+    pub(super) fn settle(&mut self, store: &Store, state: &WatchState) {
+        let done: Vec<PathBuf> = self
+            .journaled
+            .iter()
+            .filter(|p| !state.pending_files.contains(*p))
+            .cloned()
+            .collect();
+        let notes_done = self.notes_journaled && !state.pending_notes;
+        if done.is_empty() && !notes_done {
+            return;
+        }
+        let mut keys: Vec<String> = done.iter().map(|p| cqs::normalize_path(p)).collect();
+        if notes_done {
+            keys.push(self.notes_key.clone());
+        }
+        match store.clear_watch_journal_paths(&keys) {
+            Ok(_) => {
+                for path in &done {
+                    self.journaled.remove(path);
+                }
+                self.notes_journaled &= !notes_done;
+            }
+            Err(e) => {
+                tracing::warn!(error = %e, count = keys.len(), "Failed to clear watch journal rows");
+            }
+        }
+    }
+
+    /// Forget what was journaled — the store was replaced, so the next sync
+    /// writes the whole queue into the new database.
+    pub(super) fn reset(&mut self) {
+        self.journaled.clear();
+        self.notes_journaled = false;
+    }
+}
@@ -43,7 +43,7 @@ use std::sync::{mpsc, Arc};
 use std::time::{Duration, SystemTime};
 
 use anyhow::{bail, Context, Result};
-use notify::{Config, PollWatcher, RecommendedWatcher, RecursiveMode, Watcher};
+use notify::Config;
 use tracing::{info, info_span, warn};
 
 use cqs::embedder::{Embedder, Embedding, ModelConfig};
@@ -53,7 +53,7 @@ use cqs::note::parse_notes;
 use cqs::parser::{ChunkTypeRefs, Parser as CqParser};
 use cqs::store::Store;
 
-use super::{check_interrupted, find_project_root, try_acquire_index_lock, Cli};
+use super::{check_interrupted, find_project_root, index_lock_holder, try_acquire_index_lock, Cli};
 
 #[cfg(unix)]
 mod socket;
@@ -65,7 +65,10 @@ use socket::{
 mod runtime;
 use runtime::build_shared_runtime;
 #[cfg(unix)]
-use runtime::{daemon_should_exit, install_sigterm_handler, is_shutdown_requested};
+use runtime::{
+    daemon_should_exit, install_sighup_handler, install_sigterm_handler, is_shutdown_requested,
+    take_reload_request,
+};
 
 mod rebuild;
 use rebuild::{
@@ -87,6 +90,15 @@ use gc::{LAST_INDEXED_PRUNE_AGE_SECS, LAST_INDEXED_PRUNE_SIZE_THRESHOLD_DEFAULT}
 mod reconcile;
 use reconcile::{reconcile_enabled, run_daemon_reconcile};
 
+mod journal;
+use cqs::store::JournalReason;
+use journal::PendingJournal;
+
+mod resummarize;
+use resummarize::PendingResummary;
+mod upgrade;
+use upgrade::UpgradeWorker;
+
 mod events;
 use events::max_pending_files;
 use events::{collect_events, process_file_changes, process_note_changes};
@@ -94,6 +106,14 @@ use events::{collect_events, process_file_changes, process_note_changes};
 mod siblings;
 use siblings::{SiblingPolicy, SiblingSet};
 
+mod backend;
+use backend::WatchBackend;
+
+mod storm;
+use storm::{StormConfig, StormDetector};
+
+mod editor;
+
 mod reindex;
 #[cfg(target_os = "linux")]
 use reindex::count_watchable_dirs;
@@ -108,6 +128,8 @@ use reindex::{
 // (`src/cli/worktree_overlay_build.rs`) can reach it without the private
 // `reindex` submodule being visible cli-wide.
 pub(crate) use reindex::reindex_files as overlay_reindex_files;
+// Targeted re-index behind `cqs index --only`.
+pub(crate) use reindex::reindex_origins;
 
 #[cfg(unix)]
 mod daemon;
@@ -163,6 +185,38 @@ struct WatchConfig<'a> {
     /// (or under a previous model) doesn't pay GPU cost on every save.
     /// Mirrors the bulk pipeline's `prepare_for_embedding` shape.
     global_cache: Option<&'a cqs::cache::EmbeddingCache>,
+    /// `[index] follow_symlinks`, resolved once at startup so events map
+    /// through links exactly as the initial walk did.
+    follow_symlinks: cqs::symlinks::SymlinkPolicy,
+}
+
+/// Re-read `.cqs.toml` / the user config and rebuild the ignore matcher.
+///
+/// Config errors keep the running config; see `cqs::config_reload` for
+/// which keys apply live. The matcher is rebuilt unconditionally (it is
+/// cheap) unless `--no-ignore` disabled it.
+fn reload_watch_config(
+    root: &Path,
+    gitignore: &std::sync::RwLock<Option<ignore::gitignore::Gitignore>>,
+    no_ignore: bool,
+) {
+    let _span = tracing::info_span!("reload_watch_config").entered();
+    if let Err(e) = cqs::config_reload::reload(root) {
+        warn!(error = %e, "Config reload failed; keeping the running config");
+    }
+    if !no_ignore {
+        let matcher = build_gitignore_matcher(root);
+        *gitignore.write().unwrap_or_else(|e| e.into_inner()) = matcher;
+    }
+}
+
+/// `true` for the root-level files whose edit triggers a config reload.
+fn is_reload_trigger(root: &Path, path: &Path) -> bool {
+    path.parent().is_some_and(|dir| dir == root)
+        && path
+            .file_name()
+            .and_then(|n| n.to_str())
+            .is_some_and(|n| matches!(n, ".cqs.toml" | ".gitignore" | ".cqsignore"))
 }
 
 /// Mutable session state that evolves across watch cycles.
@@ -178,6 +232,12 @@ struct WatchState {
     /// never-quiet event stream still flushes within
     /// `DebounceConfig::max_latency` of this instant.
     first_pending_event: Option<std::time::Instant>,
+    /// [`EventClass`] bits of the file events queued since the last
+    /// flush; selects the quiet gap in [`flush_due`].
+    pending_classes: u8,
+    /// File events for a path that was already pending, folded into the
+    /// existing entry this cycle. Logged with the flush, cleared after.
+    coalesced_this_cycle: usize,
     last_indexed_mtime: HashMap<PathBuf, SystemTime>,
     hnsw_index: Option<HnswIndex>,
     incremental_count: usize,
@@ -224,6 +284,26 @@ struct WatchState {
     /// of overwriting the newer on-disk index. `None` until the first write
     /// cycle (saves then fall back to the live stamp).
     observed_stamp: Option<cqs::hnsw::StoreStamp>,
+    /// Set by every successful reindex cycle: chunks may have changed hash,
+    /// so their summaries may now be superseded. Cleared by the idle tick
+    /// that reconciles summary freshness (see `resummarize`).
+    summaries_dirty: bool,
+    /// Background summary pass for changed chunks, while one is running.
+    /// At most one at a time; reaped on the next idle tick after it exits.
+    pending_resummary: Option<PendingResummary>,
+    /// When the flush first found `index.lock` held by another process, while
+    /// it still is. Pending changes stay queued meanwhile; the first flush
+    /// that gets the lock logs the wait and clears this.
+    lock_blocked_since: Option<std::time::Instant>,
+    /// Set by `collect_events` when a root `.cqs.toml`, `.gitignore`, or
+    /// `.cqsignore` changes; the next quiet tick reloads and clears it.
+    config_reload_pending: bool,
+    /// Event-storm detector. While a storm is active `collect_events`
+    /// stops queueing and [`flush_due`] holds; a bulk pass replaces the
+    /// per-file events once the tree is quiet.
+    storm: StormDetector,
+    /// Idle-time staging for a pending `cqs model upgrade`.
+    upgrade: UpgradeWorker,
 }
 
 /// How often the watch loop re-stats `index.db` for the `last_synced_at`
@@ -278,6 +358,8 @@ fn publish_watch_snapshot(
         .as_ref()
         .map(|p| p.delta_saturated)
         .unwrap_or(false);
+    cqs::metrics::WATCH_QUEUE_DEPTH
+        .set(i64::try_from(state.pending_files.len()).unwrap_or(i64::MAX));
     let snap = cqs::watch_status::WatchSnapshot::compute(
         cqs::watch_status::WatchSnapshotInput::new(
             state.pending_files.len(),
@@ -352,12 +434,92 @@ fn publish_watch_snapshot(
 ///   `max_latency` — bounds total delay when the stream never goes
 ///   quiet (e.g. a generator continuously rewriting files), which
 ///   would otherwise keep restarting the quiet-gap timer forever.
+///
+/// The quiet gap depends on what is queued: each [`EventClass`] has its
+/// own, and the longest among the classes pending since the last flush
+/// wins. Queue entries with no class (reconcile, the notes signal,
+/// journal restore) use `quiet_gap`, which is also the modify gap.
 #[derive(Debug, Clone, Copy, PartialEq, Eq)]
 struct DebounceConfig {
     quiet_gap: Duration,
+    create_gap: Duration,
+    delete_gap: Duration,
     max_latency: Duration,
 }
 
+impl DebounceConfig {
+    /// Quiet gap for a set of pending [`EventClass`] bits.
+    fn gap_for(&self, classes: u8) -> Duration {
+        if classes == 0 {
+            return self.quiet_gap;
+        }
+        [
+            (EventClass::Modify, self.quiet_gap),
+            (EventClass::Create, self.create_gap),
+            (EventClass::Delete, self.delete_gap),
+        ]
+        .into_iter()
+        .filter(|(class, _)| classes & class.bit() != 0)
+        .map(|(_, gap)| gap)
+        .max()
+        .unwrap_or(self.quiet_gap)
+    }
+}
+
+/// Debounce class of a filesystem event. Editors' save-save-save loops
+/// are modifies; a delete rarely has a follow-up, so it can flush sooner.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum EventClass {
+    Modify,
+    Create,
+    Delete,
+}
+
+impl EventClass {
+    fn of(kind: &notify::EventKind) -> Self {
+        use notify::event::ModifyKind;
+        use notify::EventKind;
+        match kind {
+            EventKind::Create(_) | EventKind::Modify(ModifyKind::Name(_)) => EventClass::Create,
+            EventKind::Remove(_) => EventClass::Delete,
+            _ => EventClass::Modify,
+        }
+    }
+
+    /// Bit in `WatchState::pending_classes`.
+    fn bit(self) -> u8 {
+        match self {
+            EventClass::Modify => 1,
+            EventClass::Create => 2,
+            EventClass::Delete => 4,
+        }
+    }
+}
+
+/// Per-kind debounce overrides, env over `[watch]` config.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+struct KindOverrides {
+    modify_ms: Option<u64>,
+    create_ms: Option<u64>,
+    delete_ms: Option<u64>,
+    max_ms: Option<u64>,
+}
+
+impl KindOverrides {
+    /// Read `CQS_WATCH_DEBOUNCE_{MODIFY,CREATE,DELETE}_MS` and
+    /// `CQS_WATCH_MAX_DEBOUNCE_MS`, falling back to the `[watch]` section.
+    fn resolve(config: Option<&cqs::config::WatchConfig>) -> Self {
+        let env = |name: &str| std::env::var(name).ok().and_then(|v| v.parse::<u64>().ok());
+        let section = config.copied().unwrap_or_default();
+        Self {
+            modify_ms: env("CQS_WATCH_DEBOUNCE_MODIFY_MS").or(section.modify_debounce_ms),
+            create_ms: env("CQS_WATCH_DEBOUNCE_CREATE_MS").or(section.create_debounce_ms),
+            delete_ms: env("CQS_WATCH_DEBOUNCE_DELETE_MS").or(section.delete_debounce_ms),
+            max_ms: env("CQS_WATCH_MAX_DEBOUNCE_MS").or(section.max_coalesce_ms),
+        }
+    }
+}
+
 /// Multiplier applied to the quiet gap to derive the default
 /// max-latency cap when `CQS_WATCH_MAX_DEBOUNCE_MS` is unset.
 /// 6× = 3 s at the 500 ms inotify default, 9 s at the 1500 ms
@@ -367,25 +529,26 @@ struct DebounceConfig {
 const MAX_DEBOUNCE_FACTOR: u32 = 6;
 
 /// Resolve the idle-flush debounce config from the `--debounce` flag,
-/// poll-mode detection, and the two env overrides (passed in as already
-/// parsed values so this stays pure and unit-testable).
+/// poll-mode detection, and the overrides (passed in as already parsed
+/// values so this stays pure and unit-testable).
 ///
 /// Quiet gap precedence: `CQS_WATCH_DEBOUNCE_MS` env > `--debounce`
 /// flag, with the WSL/poll auto-bump (500 → 1500 ms) applied only when
 /// the user overrode neither. The poll watcher delivers events in scan
 /// batches, so the quiet gap there must exceed NTFS's 1 s mtime
-/// resolution or a single save risks double-firing.
+/// resolution or a single save risks double-firing. A per-kind
+/// override replaces that kind's gap; unset kinds use the quiet gap.
 ///
-/// Max latency: `CQS_WATCH_MAX_DEBOUNCE_MS` env, defaulting to
-/// [`MAX_DEBOUNCE_FACTOR`] × the resolved quiet gap, and clamped to at
-/// least the quiet gap (a cap below the gap would turn the idle-flush
-/// into a fixed window at the cap, which is never what the two knobs
-/// together are asking for).
+/// Max latency: `kinds.max_ms` (`CQS_WATCH_MAX_DEBOUNCE_MS` or
+/// `[watch] max_coalesce_ms`), defaulting to [`MAX_DEBOUNCE_FACTOR`] ×
+/// the longest resolved gap, and clamped to at least that gap (a cap
+/// below the gap would turn the idle-flush into a fixed window at the
+/// cap, which is never what the knobs together are asking for).
 fn resolve_debounce(
     flag_ms: u64,
     use_poll: bool,
     env_gap_ms: Option<u64>,
-    env_max_ms: Option<u64>,
+    kinds: KindOverrides,
 ) -> DebounceConfig {
     let quiet_gap_ms = if let Some(env_ms) = env_gap_ms {
         env_ms
@@ -397,11 +560,18 @@ fn resolve_debounce(
     } else {
         flag_ms
     };
-    let max_latency_ms = env_max_ms
-        .unwrap_or_else(|| quiet_gap_ms.saturating_mul(u64::from(MAX_DEBOUNCE_FACTOR)))
-        .max(quiet_gap_ms);
+    let modify_ms = kinds.modify_ms.unwrap_or(quiet_gap_ms);
+    let create_ms = kinds.create_ms.unwrap_or(modify_ms);
+    let delete_ms = kinds.delete_ms.unwrap_or(modify_ms);
+    let longest_ms = modify_ms.max(create_ms).max(delete_ms);
+    let max_latency_ms = kinds
+        .max_ms
+        .unwrap_or_else(|| longest_ms.saturating_mul(u64::from(MAX_DEBOUNCE_FACTOR)))
+        .max(longest_ms);
     DebounceConfig {
-        quiet_gap: Duration::from_millis(quiet_gap_ms),
+        quiet_gap: Duration::from_millis(modify_ms),
+        create_gap: Duration::from_millis(create_ms),
+        delete_gap: Duration::from_millis(delete_ms),
         max_latency: Duration::from_millis(max_latency_ms),
     }
 }
@@ -446,7 +616,10 @@ fn flush_due(state: &WatchState, debounce: &DebounceConfig) -> bool {
     if state.pending_files.is_empty() && !state.pending_notes {
         return false;
     }
-    if state.last_event.elapsed() >= debounce.quiet_gap {
+    if state.storm.is_active() {
+        return false;
+    }
+    if state.last_event.elapsed() >= debounce.gap_for(state.pending_classes) {
         return true;
     }
     state
@@ -560,6 +733,8 @@ fn build_gitignore_matcher(root: &Path) -> Option<ignore::gitignore::Gitignore>
 ///   loop queries the project's `.gitignore` for every event and ignores matches.
 ///   Also overridable at runtime via `CQS_WATCH_RESPECT_GITIGNORE=0`.
 /// * `poll` - If true, uses polling instead of inotify for file system monitoring
+/// * `serve` - If true, also answers queries on the daemon socket
+/// * `metrics_addr` - If set, serves Prometheus metrics at `http://<addr>/metrics`
 ///
 /// # Returns
 ///
@@ -575,6 +750,7 @@ pub fn cmd_watch(
     no_ignore: bool,
     poll: bool,
     serve: bool,
+    metrics_addr: Option<std::net::SocketAddr>,
 ) -> Result<()> {
     let _span = tracing::info_span!("cmd_watch", debounce_ms, poll, serve, no_ignore).entered();
 
@@ -583,8 +759,32 @@ pub fn cmd_watch(
     // immediately when systemd stops the unit.
     #[cfg(unix)]
     install_sigterm_handler();
+    // SIGHUP re-reads .cqs.toml instead of stopping the watcher.
+    #[cfg(unix)]
+    install_sighup_handler();
 
     let root = find_project_root();
+    let startup_config = cqs::config::Config::load(&root);
+
+    // The watcher writes every change into the slot's own `index.db`; on a
+    // sharded index that would copy shard files into the root shard, where
+    // federated search would then see them twice.
+    if let Some(cfg) = startup_config
+        .index
+        .as_ref()
+        .and_then(|index| index.shards.clone())
+    {
+        if cqs::shard::ShardLayout::from_config(&cfg)?.is_some() {
+            bail!(
+                "cqs watch does not support a sharded index ([index.shards] in .cqs.toml) yet. \
+                 Reindex changed shards with `cqs index --shard <prefix>` instead."
+            );
+        }
+    }
+    // Debounce is resolved once below; a `[watch]` edit needs a restart.
+    let watch_section = startup_config.watch;
+    // Baseline for hot reloads (.cqs.toml edit, SIGHUP, `reload-config`).
+    cqs::config_reload::init(startup_config);
 
     // Auto-detect when polling is needed: WSL + DrvFS mount path.
     //
@@ -609,24 +809,31 @@ pub fn cmd_watch(
     // Idle-flush debounce resolution. `CQS_WATCH_DEBOUNCE_MS` is the
     // quiet gap (takes precedence over --debounce; WSL/poll auto-bump
     // 500 → 1500 ms because NTFS mtime resolution is 1 s and the poll
-    // watcher delivers scan batches); `CQS_WATCH_MAX_DEBOUNCE_MS` is
-    // the max-latency cap, defaulting to 6× the quiet gap. See
-    // `DebounceConfig` for the flush semantics.
+    // watcher delivers scan batches). Per-kind gaps and the max-latency
+    // cap come from `CQS_WATCH_DEBOUNCE_*_MS` / `[watch]`; the cap
+    // defaults to 6× the longest gap. See `DebounceConfig` for the
+    // flush semantics.
     let debounce = resolve_debounce(
         debounce_ms,
         use_poll,
         std::env::var("CQS_WATCH_DEBOUNCE_MS")
             .ok()
             .and_then(|v| v.parse::<u64>().ok()),
-        std::env::var("CQS_WATCH_MAX_DEBOUNCE_MS")
-            .ok()
-            .and_then(|v| v.parse::<u64>().ok()),
+        KindOverrides::resolve(watch_section.as_ref()),
     );
     tracing::info!(
         quiet_gap_ms = debounce.quiet_gap.as_millis() as u64,
+        create_gap_ms = debounce.create_gap.as_millis() as u64,
+        delete_gap_ms = debounce.delete_gap.as_millis() as u64,
         max_latency_ms = debounce.max_latency.as_millis() as u64,
         "watch debounce resolved (idle-flush)"
     );
+    let storm_config = StormConfig::resolve(watch_section.as_ref(), &debounce);
+    tracing::info!(
+        storm_events = storm_config.threshold(),
+        storm_window_ms = storm_config.window().as_millis() as u64,
+        "watch event-storm detection resolved"
+    );
 
     let project_cqs_dir = cqs::resolve_index_dir(&root);
 
@@ -664,6 +871,13 @@ pub fn cmd_watch(
         bail!("No index found at {}. Run 'cqs index' first (or 'cqs index --slot {}' if the slot exists but is empty).", index_path.display(), active_slot.name);
     }
 
+    // Prometheus exporter. A bind failure is fatal: the operator asked for
+    // scrapes, and a daemon that silently serves none looks healthy.
+    if let Some(addr) = metrics_addr {
+        cqs::metrics::spawn_exporter(addr)
+            .with_context(|| format!("Failed to bind metrics exporter on {addr}"))?;
+    }
+
     // Socket listener BEFORE watcher scan — daemon is immediately queryable
     // while the (potentially slow) poll watcher initializes.
     // Unix domain sockets are not available on Windows.
@@ -905,6 +1119,11 @@ pub fn cmd_watch(
     let in_flight_clients_handle: Arc<std::sync::atomic::AtomicUsize> =
         Arc::new(std::sync::atomic::AtomicUsize::new(0));
 
+    // How long shutdown waits for in-flight daemon queries. Resolved once so
+    // the daemon thread's drain and the outer join deadline agree.
+    #[cfg(unix)]
+    let drain_budget = Duration::from_secs(cqs::limits::daemon_drain_secs());
+
     // Pick up a leftover `.cqs/.dirty` marker from a previous session where
     // a git hook fired without a daemon listening.
     // The hook touches this file as a fallback; on next daemon start we
@@ -980,6 +1199,7 @@ pub fn cmd_watch(
                 daemon_pending_notes_signal,
                 daemon_fresh_notifier,
                 daemon_in_flight,
+                drain_budget,
             );
             Some(thread)
         } else {
@@ -1038,23 +1258,25 @@ pub fn cmd_watch(
         .and_then(|v| v.parse::<u64>().ok())
         .filter(|&ms| ms >= 100)
         .unwrap_or(5000);
-    let config = Config::default().with_poll_interval(Duration::from_millis(poll_ms));
-
-    // Box<dyn Watcher> so both watcher types work with the same variable
-    let mut watcher: Box<dyn Watcher> = if use_poll {
+    // The watcher descends through symlinked directories only when the walk
+    // does; `collect_events` then maps each event through the same policy,
+    // so a file the walk would skip (or index under its real path) is
+    // treated identically on save.
+    let follow_symlinks = cqs::symlinks::policy();
+    let config = Config::default()
+        .with_poll_interval(Duration::from_millis(poll_ms))
+        .with_follow_symlinks(follow_symlinks.follows());
+
+    if use_poll {
         println!("Using poll watcher (interval: {}ms)", poll_ms);
-        Box::new(PollWatcher::new(tx, config)?)
-    } else {
-        Box::new(RecommendedWatcher::new(tx, config)?)
-    };
+    }
 
     // Warn when the project tree approaches the inotify watch limit.
     // notify::watch(Recursive) registers a watch per directory; on distros
     // with the old default of 8192 a moderately-deep monorepo exhausts the
-    // limit and per-subdir registration failures are silent. We don't fail
-    // here — the watch still works for whatever directories were registered
-    // — but we emit a loud warning with the recommended fix so operators
-    // know why some saves stopped triggering reindex.
+    // limit. Exhaustion itself is handled by `WatchBackend` (poll fallback
+    // for the overflowed subtrees); this early warning fires while there is
+    // still headroom, so operators can raise the limit before it bites.
     #[cfg(target_os = "linux")]
     if !use_poll {
         if let Ok(limit_str) = std::fs::read_to_string("/proc/sys/fs/inotify/max_user_watches") {
@@ -1082,7 +1304,9 @@ pub fn cmd_watch(
         }
     }
 
-    watcher.watch(&root, RecursiveMode::Recursive)?;
+    // Past the inotify limit the backend reports the sysctl fix and polls
+    // the subtrees it couldn't register instead of leaving them unwatched.
+    let mut watcher = WatchBackend::start(&root, use_poll, config, tx, cli.quiet)?;
 
     let notes_path = root.join("docs/notes.toml");
     let cqs_dir = dunce::canonicalize(&cqs_dir).unwrap_or_else(|e| {
@@ -1112,9 +1336,9 @@ pub fn cmd_watch(
     // Captured into a local `Vec<String>` so the DB-replaced reopen
     // paths below can re-stamp the freshly-opened Store without
     // re-reading the config file.
+    let project_cfg = cqs::config::Config::load(&root);
     let vendored_prefixes_for_store: Vec<String> = {
-        let cfg = cqs::config::Config::load(&root);
-        let vendored_override = cfg
+        let vendored_override = project_cfg
             .index
             .as_ref()
             .and_then(|ic| ic.vendored_paths.as_deref());
@@ -1122,6 +1346,19 @@ pub fn cmd_watch(
     };
     store.set_vendored_prefixes(vendored_prefixes_for_store.clone());
 
+    // Watch keeps writing FTS rows with the analyzer the index records —
+    // mixing two analyzers in one table would make half the rows
+    // unmatchable. A changed `[index.fts]` waits for `cqs index`.
+    let configured_analyzer = project_cfg.fts_analyzer();
+    if &configured_analyzer != store.fts_analyzer() {
+        tracing::warn!(
+            index = %store.fts_analyzer().signature(),
+            configured = %configured_analyzer.signature(),
+            "[index.fts] differs from the analyzer this index was built with; \
+             run `cqs index` to rebuild the FTS tables"
+        );
+    }
+
     // Track the database file identity so we detect when `cqs index --force`
     // replaces it. Without this check, watch's Store handle would point at the
     // orphaned (renamed) inode and writes would silently vanish.
@@ -1359,6 +1596,7 @@ pub fn cmd_watch(
         gitignore: &gitignore,
         splade_encoder: splade_encoder_ref,
         global_cache: global_cache_ref,
+        follow_symlinks,
     };
 
     let mut state = WatchState {
@@ -1367,6 +1605,8 @@ pub fn cmd_watch(
         pending_notes: false,
         last_event: std::time::Instant::now(),
         first_pending_event: None,
+        pending_classes: 0,
+        coalesced_this_cycle: 0,
         // Track last-indexed mtime per file to skip duplicate WSL/NTFS events.
         // On WSL, inotify over 9P delivers repeated events for the same file change.
         // Bounded: pruned when >10k entries or >1k entries on single-file reindex.
@@ -1393,8 +1633,19 @@ pub fn cmd_watch(
         // HNSW save already detects foreign writers. Read failure → None;
         // the first save falls back to the live stamp.
         observed_stamp: cqs::hnsw::StoreStamp::read(&store).ok(),
+        summaries_dirty: false,
+        pending_resummary: None,
+        lock_blocked_since: None,
+        config_reload_pending: false,
+        storm: StormDetector::new(storm_config),
+        upgrade: UpgradeWorker::default(),
     };
 
+    // Crash recovery: re-queue whatever a previous daemon journaled but
+    // never flushed, then keep the journal in step with the queue.
+    let mut journal = PendingJournal::new(&root, &notes_path);
+    journal.restore(&store, &mut state);
+
     let mut cycles_since_clear: u32 = 0;
     // Track last eviction of the global embedding cache so the reindex path
     // only trims once per hour, keeping the WAL file from churning on every
@@ -1440,10 +1691,14 @@ pub fn cmd_watch(
             Ok(Ok(event)) => {
                 collect_events(&event, &watch_cfg, &mut state);
             }
+            Ok(Err(e)) if watcher.handle_error(&root, &e) => {
+                // Watch limit hit by a directory created after startup;
+                // the backend moved it to the poll watcher.
+            }
             Ok(Err(e)) => {
                 // Include `kind` and `paths` so operators can distinguish
-                // MaxFilesWatch (raise sysctl) from Io(broken pipe) (restart
-                // daemon). Display alone collapses the discriminator and drops
+                // Io(broken pipe) (restart daemon) from other failures.
+                // Display alone collapses the discriminator and drops
                 // paths.
                 warn!(
                     error = %e,
@@ -1453,6 +1708,18 @@ pub fn cmd_watch(
                 );
             }
             Err(mpsc::RecvTimeoutError::Timeout) => {
+                // Config hot reload: a root `.cqs.toml` / `.gitignore` /
+                // `.cqsignore` edit seen by `collect_events`, or SIGHUP.
+                // Runs on the first quiet tick so an editor's
+                // write-rename-chmod burst reloads once.
+                #[cfg(unix)]
+                let sighup = take_reload_request();
+                #[cfg(not(unix))]
+                let sighup = false;
+                if std::mem::take(&mut state.config_reload_pending) || sighup {
+                    reload_watch_config(&root, &gitignore, no_ignore);
+                }
+
                 // Out-of-band reconcile request. The daemon's
                 // `dispatch_reconcile` flips this AtomicBool to
                 // `true` when a `cqs hook fire` client posts a
@@ -1466,9 +1733,17 @@ pub fn cmd_watch(
                 // Coalesces repeated requests into a single walk: a
                 // `git rebase -i` firing post-rewrite once per replayed
                 // commit only triggers one walk on the next tick.
+                //
+                // A request that lands mid-storm (the post-checkout hook of
+                // the very branch switch that caused it) is absorbed by the
+                // storm's bulk pass below.
                 let on_demand_reconcile_requested =
                     reconcile_signal_handle.swap(false, std::sync::atomic::Ordering::AcqRel);
-                if on_demand_reconcile_requested && reconcile_enabled_flag {
+                if on_demand_reconcile_requested
+                    && reconcile_enabled_flag
+                    && !state.storm.is_active()
+                {
+                    journal.sync(&store, &state, JournalReason::Event);
                     let queued = run_daemon_reconcile(
                         &store,
                         &root,
@@ -1498,6 +1773,7 @@ pub fn cmd_watch(
                         );
                     }
                     if queued > 0 {
+                        journal.sync(&store, &state, JournalReason::Reconcile);
                         // Reset `last_event` so the synthetic pending
                         // entries flush one quiet-gap from queue time
                         // (otherwise a stale `last_event` could fire
@@ -1516,6 +1792,65 @@ pub fn cmd_watch(
                     last_reconcile = std::time::Instant::now();
                 }
 
+                // Event storm over: one walk stands in for every event it
+                // suppressed. The queued files flush as one batch on the
+                // next `flush_due`; a pass that hit the queue cap runs again
+                // once that flush has drained the queue.
+                if state
+                    .storm
+                    .bulk_pass_due(state.last_event, state.pending_files.is_empty())
+                {
+                    journal.sync(&store, &state, JournalReason::Event);
+                    let pass = storm::run_bulk_pass(
+                        &store,
+                        &root,
+                        &cqs_dir,
+                        &parser,
+                        no_ignore,
+                        &mut state.pending_files,
+                        max_pending_files(),
+                    );
+                    if !sibling_slots.is_empty() {
+                        sibling_slots.reconcile_siblings(
+                            &root,
+                            &parser,
+                            no_ignore,
+                            max_pending_files(),
+                            None,
+                            &shared_rt,
+                        );
+                    }
+                    if pass.pruned > 0 {
+                        // Same refresh as the periodic GC: the prune bumped
+                        // the write generation.
+                        store.clear_caches();
+                        db_id = db_file_identity(&index_path);
+                        state.observed_stamp = cqs::hnsw::StoreStamp::read(&store).ok();
+                    }
+                    let at_cap = state.pending_files.len() >= max_pending_files();
+                    let (suppressed, lasted) = state.storm.finish(at_cap);
+                    info!(
+                        suppressed,
+                        storm_secs = lasted.as_secs(),
+                        pruned = pass.pruned,
+                        queued = pass.queued,
+                        pending_total = state.pending_files.len(),
+                        more = at_cap,
+                        "Event storm settled; bulk pass queued divergent files"
+                    );
+                    if pass.queued > 0 {
+                        journal.sync(&store, &state, JournalReason::Reconcile);
+                        state.last_event = std::time::Instant::now();
+                        if state.first_pending_event.is_none() {
+                            state.first_pending_event = Some(state.last_event);
+                        }
+                    }
+                    if !state.storm.unsettled() {
+                        storm::release_dirty_marker(&cqs_dir);
+                    }
+                    last_reconcile = std::time::Instant::now();
+                }
+
                 // Flush decision lives after the match (see the
                 // `flush_due` block below) so it is evaluated on event
                 // arrivals too, not just on quiet ticks. The idle
@@ -1662,6 +1997,56 @@ pub fn cmd_watch(
                         last_periodic_gc = std::time::Instant::now();
                     }
 
+                    // Summary freshness. Only after a reindex cycle since
+                    // the last pass (`summaries_dirty`), behind the same idle
+                    // threshold as GC so a burst of edits is summarized once
+                    // at the end, not per save. Marking takes the index lock
+                    // non-blockingly like GC; a held lock retries next tick.
+                    // The LLM pass itself runs on a background thread — see
+                    // `resummarize` for why it needs no index lock.
+                    if state
+                        .pending_resummary
+                        .as_ref()
+                        .is_some_and(PendingResummary::is_finished)
+                    {
+                        if let Some(done) = state.pending_resummary.take() {
+                            done.finish();
+                        }
+                    }
+                    if state.summaries_dirty
+                        && state.pending_resummary.is_none()
+                        && state.last_event.elapsed()
+                            >= Duration::from_secs(super::limits::daemon_periodic_gc_idle_secs())
+                    {
+                        match try_acquire_index_lock(&cqs_dir) {
+                            Ok(Some(lock)) => {
+                                state.summaries_dirty = false;
+                                let has_summaries = resummarize::mark_stale_summaries(&store);
+                                drop(lock);
+                                if has_summaries && resummarize::resummarize_enabled() {
+                                    state.pending_resummary = resummarize::spawn_resummary(
+                                        &root,
+                                        &cqs_dir,
+                                        &index_path,
+                                        Arc::clone(&shared_rt),
+                                    );
+                                }
+                            }
+                            Ok(None) => {
+                                tracing::debug!(
+                                    "Summary freshness: index lock held, retrying next tick"
+                                );
+                            }
+                            Err(e) => {
+                                tracing::warn!(
+                                    error = %e,
+                                    "Summary freshness: failed to acquire index lock — skipping"
+                                );
+                                state.summaries_dirty = false;
+                            }
+                        }
+                    }
+
                     // Periodic full-tree reconciliation. Sibling of the GC
                     // tick; same idle-gating model:
                     //   (a) `--serve` on AND `CQS_WATCH_RECONCILE` != "0",
@@ -1706,6 +2091,7 @@ pub fn cmd_watch(
                             // Re-stamp vendored prefixes on the fresh Store —
                             // OnceLock is per-instance.
                             store.set_vendored_prefixes(vendored_prefixes_for_store.clone());
+                            journal.reset();
                             db_id = current_id;
                             state.hnsw_index = None;
                             state.incremental_count = 0;
@@ -1720,6 +2106,7 @@ pub fn cmd_watch(
                             }
                             last_reconcile = std::time::Instant::now();
                         } else {
+                            journal.sync(&store, &state, JournalReason::Event);
                             let queued = reconcile::run_daemon_reconcile_with_walk(
                                 &store,
                                 &root,
@@ -1745,6 +2132,7 @@ pub fn cmd_watch(
                                 );
                             }
                             if queued > 0 {
+                                journal.sync(&store, &state, JournalReason::Reconcile);
                                 // Reset `last_event` so the synthetic
                                 // pending entries flush one quiet-gap
                                 // from queue time; arm the max-latency
@@ -1775,6 +2163,16 @@ pub fn cmd_watch(
                             state.pending_rebuild.is_some(),
                         );
                     }
+
+                    // Background embedding upgrade (`cqs model upgrade`).
+                    // Behind the same idle threshold as GC, one batch per
+                    // tick, so the flush above preempts it within a batch.
+                    if upgrade::upgrade_enabled()
+                        && state.last_event.elapsed()
+                            >= Duration::from_secs(super::limits::daemon_periodic_gc_idle_secs())
+                    {
+                        state.upgrade.tick(&store, &cqs_dir);
+                    }
                 }
 
                 // Socket queries handled by dedicated thread (see _socket_thread above).
@@ -1798,6 +2196,12 @@ pub fn cmd_watch(
             tracing::info!("Daemon notes-mutation signal drained — note reindex queued");
         }
 
+        // Journal newly queued work, throttled so an event storm costs one
+        // small write per interval rather than one per event.
+        if journal.sync_due() {
+            journal.sync(&store, &state, JournalReason::Event);
+        }
+
         // Pre-drain decision. Evaluated on every loop iteration —
         // event arrivals included — because a continuous stream of
         // events arriving faster than the 100 ms recv timeout never
@@ -1809,10 +2213,29 @@ pub fn cmd_watch(
 
             // Acquire index lock before reindexing. If another process
             // (cqs index, cqs gc) holds it, skip this cycle.
+            // Another writer (`cqs index`, `cqs gc`) holds the lock: keep the
+            // pending set queued and retry every tick. Logged once per
+            // contention episode, not per 100 ms retry.
             let lock = match try_acquire_index_lock(&cqs_dir) {
-                Ok(Some(lock)) => lock,
+                Ok(Some(lock)) => {
+                    if let Some(since) = state.lock_blocked_since.take() {
+                        info!(
+                            waited_secs = since.elapsed().as_secs(),
+                            pending = state.pending_files.len(),
+                            "Index lock released, flushing queued changes"
+                        );
+                    }
+                    lock
+                }
                 Ok(None) => {
-                    info!("Index lock held by another process, skipping reindex cycle");
+                    if state.lock_blocked_since.is_none() {
+                        state.lock_blocked_since = Some(std::time::Instant::now());
+                        info!(
+                            holder_pid = ?index_lock_holder(&cqs_dir),
+                            pending = state.pending_files.len(),
+                            "Index lock held by another process, queuing changes until it is released"
+                        );
+                    }
                     continue;
                 }
                 Err(e) => {
@@ -1843,6 +2266,8 @@ pub fn cmd_watch(
                 // starts empty. Use the cached resolution from startup
                 // so we don't re-read .cqs.toml mid-watch.
                 store.set_vendored_prefixes(vendored_prefixes_for_store.clone());
+                // The journal lived in the replaced DB; re-journal the queue.
+                journal.reset();
                 // db_id updated below in the cache-clear path.
                 state.hnsw_index = None;
                 state.incremental_count = 0;
@@ -1861,6 +2286,10 @@ pub fn cmd_watch(
                 }
             }
 
+            // Everything about to be consumed must be journaled first, so a
+            // crash mid-reindex resumes it.
+            journal.sync(&store, &state, JournalReason::Event);
+
             if !state.pending_files.is_empty() {
                 process_file_changes(&watch_cfg, &store, &mut state, &mut sibling_slots);
             }
@@ -1869,6 +2298,7 @@ pub fn cmd_watch(
                 state.pending_notes = false;
                 process_note_changes(&root, &store, cli.quiet, &mut state);
             }
+            journal.settle(&store, &state);
 
             // Clear stale OnceLock caches (call_graph_cache,
             // test_chunks_cache) after index changes. Clear caches
@@ -1903,6 +2333,7 @@ pub fn cmd_watch(
             // Pending sets drained — disarm the max-latency clock so
             // the next accepted event starts a fresh burst.
             state.first_pending_event = None;
+            state.pending_classes = 0;
         }
         // Publish freshness snapshot once per outer iteration.
         // Cheap — counter reads, one optional `metadata()` on `index.db`,
@@ -1936,16 +2367,30 @@ pub fn cmd_watch(
         }
     }
 
+    // Graceful shutdown, in order: stop accepting, drain in-flight queries,
+    // flush queued writes under WRITE_LOCK, checkpoint the WAL. Every phase
+    // is bounded so a wedged one can't keep systemd waiting for its
+    // SIGKILL. Files still pending in the debounce window are not indexed
+    // here: they are journaled, so the next `cqs watch` re-queues them at
+    // startup (and the reconcile pass or `cqs index` catches them anyway).
+    if !state.pending_files.is_empty() || state.pending_notes {
+        journal.sync(&store, &state, JournalReason::Event);
+        tracing::info!(
+            pending = state.pending_files.len(),
+            "Leaving pending file changes in the watch journal for the next start"
+        );
+    }
+
     // Bounded join of the daemon socket thread. The thread already observes
     // `daemon_should_exit()` at the top of its accept
-    // loop (Ctrl+C and SIGTERM both satisfy it), so in the common case
-    // this returns within one poll cycle (~100ms). Enforce an outer
-    // timeout so a wedged handler (e.g. waiting on a long embedder
-    // inference) can't keep the process alive past ~5 s after the
-    // user asked it to stop.
+    // loop (Ctrl+C and SIGTERM both satisfy it), closes the listener, and
+    // then waits up to `drain_budget` (`CQS_DAEMON_DRAIN_SECS`) for
+    // in-flight queries. Enforce an outer timeout 5 s past that so a
+    // thread wedged outside the drain can't keep the process alive.
     #[cfg(unix)]
     if let Some(handle) = socket_thread.take() {
-        let deadline = std::time::Instant::now() + Duration::from_secs(5);
+        let join_budget = drain_budget + Duration::from_secs(5);
+        let deadline = std::time::Instant::now() + join_budget;
         let poll = Duration::from_millis(50);
         let mut handle_opt = Some(handle);
         while std::time::Instant::now() < deadline {
@@ -1978,11 +2423,11 @@ pub fn cmd_watch(
             // The "joined cleanly" line above is reachable only from the
             // `is_finished` arm, which already calls `.join()`.
             tracing::warn!(
-                deadline_secs = 5,
+                deadline_secs = join_budget.as_secs(),
                 "Daemon socket thread did not exit within shutdown window — detaching (BatchContext Drop may race with process exit; in-flight embedder inference is the usual culprit)"
             );
-            // Intentionally drop `handle_opt` to detach when the 5 s budget
-            // is exhausted.
+            // Intentionally drop `handle_opt` to detach when the budget is
+            // exhausted.
         }
     }
 
@@ -2039,6 +2484,15 @@ pub fn cmd_watch(
         }
     }
 
+    // Queries are drained and the rebuild is done (or detached): commit the
+    // store's queued writes and fold the WAL into index.db so the next open
+    // doesn't replay it. `shutdown` waits for any write transaction still
+    // holding WRITE_LOCK before it checkpoints.
+    match store.shutdown() {
+        Ok(flushed) => tracing::info!(flushed, "Index flushed and WAL checkpointed"),
+        Err(e) => tracing::warn!(error = %e, "Index shutdown flush failed"),
+    }
+
     Ok(())
 }
 
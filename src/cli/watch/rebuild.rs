@@ -440,6 +440,18 @@ pub(super) fn spawn_hnsw_rebuild(
                         "base HNSW rebuild failed in background; router falls back to enriched-only"
                     ),
                 }
+                // Keep an `[index.vector]` external store in step. Search
+                // falls through to HNSW while it lags, so failure only logs.
+                match cqs::vector_backend::sync_configured(&store, &cqs_dir) {
+                    Ok(Some((kind, stats))) => tracing::info!(
+                        backend = kind.as_str(),
+                        upserted = stats.upserted,
+                        deleted = stats.deleted,
+                        "external vector store synced in background"
+                    ),
+                    Ok(None) => {}
+                    Err(e) => tracing::warn!(error = %e, "external vector store sync failed"),
+                }
                 // Package the (index, snapshot_keys) pair so the drain can
                 // detect mid-rebuild re-embeddings via fingerprint set.
                 Ok(
@@ -542,8 +542,10 @@ pub(crate) fn reindex_files(
         .flat_map(|rel_path| {
             let abs_path = root.join(rel_path);
             if !abs_path.exists() {
-                // File was deleted — remove its chunks from the store
-                if let Err(e) = store.delete_by_origin(rel_path) {
+                // File was deleted — remove its chunks from the store,
+                // tombstoned so a restore within the retention window
+                // reuses their embeddings.
+                if let Err(e) = store.tombstone_by_origin(rel_path) {
                     tracing::warn!(
                         path = %rel_path.display(),
                         error = %e,
@@ -652,6 +654,16 @@ pub(crate) fn reindex_files(
         })
         .collect();
 
+    // The watch parser lives as long as the daemon; drain its redaction
+    // reports each cycle so they don't pile up, logging what was masked.
+    for report in parser.take_redactions() {
+        tracing::info!(
+            path = %report.file.display(),
+            redactions = report.total(),
+            "Watch reindex redacted secrets"
+        );
+    }
+
     // Apply windowing to split long chunks into overlapping windows
     let chunks = crate::cli::pipeline::apply_windowing(chunks, embedder);
 
@@ -996,6 +1008,41 @@ pub(crate) fn reindex_files(
     Ok((chunk_count, content_hashes))
 }
 
+/// Targeted re-index of a known set of root-relative origins.
+///
+/// The programmatic entry behind `cqs index --only`: editor plugins that know
+/// exactly which file was saved refresh it here without the watch debounce or
+/// a project walk. Marks both HNSW kinds dirty before writing (same
+/// crash-safety invariant as the bulk pipeline) and leaves them dirty — the
+/// caller holds no in-memory graph to patch, so vector search brute-forces
+/// until the next full build or watch rebuild. Origins missing on disk have
+/// their chunks dropped by [`reindex_files`].
+///
+/// Returns the number of chunks written.
+pub(crate) fn reindex_origins(
+    root: &Path,
+    store: &Store,
+    origins: &[PathBuf],
+    parser: &CqParser,
+    embedder: &Embedder,
+    global_cache: Option<&cqs::cache::EmbeddingCache>,
+    quiet: bool,
+) -> Result<usize> {
+    let _span = info_span!("reindex_origins", origin_count = origins.len()).entered();
+    if origins.is_empty() {
+        return Ok(0);
+    }
+    store
+        .set_hnsw_dirty(cqs::HnswKind::Enriched, true)
+        .context("Failed to mark enriched HNSW dirty before targeted reindex")?;
+    store
+        .set_hnsw_dirty(cqs::HnswKind::Base, true)
+        .context("Failed to mark base HNSW dirty before targeted reindex")?;
+    let (count, _content_hashes) =
+        reindex_files(root, store, origins, parser, embedder, global_cache, quiet)?;
+    Ok(count)
+}
+
 /// Reindex notes from docs/notes.toml
 pub(super) fn reindex_notes(root: &Path, store: &Store, quiet: bool) -> Result<usize> {
     let _span = info_span!("reindex_notes").entered();
@@ -0,0 +1,152 @@
+//! Idle-time summary freshness for the watch loop.
+//!
+//! LLM summaries are keyed by content_hash, so an edited chunk comes back
+//! from reindex under a new hash with no summary, while the old row lingers
+//! describing code that no longer exists. Once a reindex cycle has run and
+//! the tree has been quiet for `daemon_periodic_gc_idle_secs()`, the loop:
+//!
+//! 1. stamps rows whose hash left the index as superseded
+//!    ([`Store::mark_superseded_summaries`]) — a pair of UPDATEs, always on;
+//! 2. when the index already carries summaries (`cqs index --llm-summaries`
+//!    ran at some point), re-runs the summary pass on a background thread so
+//!    the changed chunks get fresh ones. The pass only sends chunks that
+//!    lack a summary, so the cost tracks the edit, not the corpus.
+//!
+//! Step 2 needs the `llm-summaries` feature and a configured provider, and
+//! is disabled by `CQS_WATCH_RESUMMARIZE=0`. New summaries reach the
+//! enriched embeddings on the next `cqs index` enrichment pass.
+
+use std::path::Path;
+
+use cqs::store::Store;
+
+/// Re-summarize knob. `CQS_WATCH_RESUMMARIZE=0` opts out; any other value or
+/// unset → enabled. Read per idle tick like `CQS_WATCH_RECONCILE`.
+pub(super) fn resummarize_enabled() -> bool {
+    std::env::var("CQS_WATCH_RESUMMARIZE").as_deref() != Ok("0")
+}
+
+/// Background summary pass spawned by [`spawn_resummary`].
+pub(super) struct PendingResummary {
+    handle: std::thread::JoinHandle<anyhow::Result<usize>>,
+    started_at: std::time::Instant,
+}
+
+impl PendingResummary {
+    pub(super) fn is_finished(&self) -> bool {
+        self.handle.is_finished()
+    }
+
+    /// Join the finished thread and log its outcome.
+    pub(super) fn finish(self) {
+        let elapsed_ms = self.started_at.elapsed().as_millis() as u64;
+        match self.handle.join() {
+            Ok(Ok(generated)) => {
+                tracing::info!(generated, elapsed_ms, "Idle re-summarize pass complete")
+            }
+            Ok(Err(e)) => {
+                tracing::warn!(error = %e, elapsed_ms, "Idle re-summarize pass failed")
+            }
+            Err(_) => tracing::error!("Idle re-summarize thread panicked"),
+        }
+    }
+}
+
+/// Stamp summaries of changed or removed code as superseded (and revive any
+/// whose hash came back). Returns whether the index holds live summaries,
+/// i.e. whether a re-summarize pass is worth starting.
+pub(super) fn mark_stale_summaries(store: &Store) -> bool {
+    let _span = tracing::info_span!("mark_stale_summaries").entered();
+    match store.mark_superseded_summaries() {
+        Ok((superseded, revived)) => {
+            tracing::debug!(superseded, revived, "Summary freshness reconciled");
+        }
+        Err(e) => {
+            tracing::warn!(error = %e, "Failed to mark superseded summaries");
+        }
+    }
+    match store.llm_summary_chunk_coverage() {
+        Ok(covered) => covered > 0,
+        Err(e) => {
+            tracing::warn!(error = %e, "Failed to read summary coverage; skipping re-summarize");
+            false
+        }
+    }
+}
+
+/// Run the summary pass for chunks without a summary on a background thread
+/// with its own store handle, so a slow provider never stalls the watch
+/// loop. No index lock is held: the pass only writes `llm_summaries` and the
+/// pending-batch metadata key, and serializes with `cqs index` through the
+/// batch lock in `cqs_dir`.
+#[cfg(feature = "llm-summaries")]
+pub(super) fn spawn_resummary(
+    root: &Path,
+    cqs_dir: &Path,
+    index_path: &Path,
+    rt: std::sync::Arc<tokio::runtime::Runtime>,
+) -> Option<PendingResummary> {
+    let root = root.to_path_buf();
+    let cqs_dir = cqs_dir.to_path_buf();
+    let index_path = index_path.to_path_buf();
+    let span = tracing::info_span!("watch_resummarize_bg");
+    let spawned = std::thread::Builder::new()
+        .name("cqs-resummarize".to_string())
+        .spawn(move || {
+            let _enter = span.entered();
+            let store = Store::open_with_runtime(&index_path, rt)?;
+            let config = cqs::config::Config::load(&root);
+            Ok(cqs::llm::llm_summary_pass(
+                &store,
+                true,
+                &config,
+                Some(&cqs_dir),
+            )?)
+        });
+    match spawned {
+        Ok(handle) => Some(PendingResummary {
+            handle,
+            started_at: std::time::Instant::now(),
+        }),
+        Err(e) => {
+            tracing::warn!(error = %e, "Failed to spawn re-summarize thread");
+            None
+        }
+    }
+}
+
+/// Without the `llm-summaries` feature there is no provider to call; only
+/// the superseded marking runs.
+#[cfg(not(feature = "llm-summaries"))]
+pub(super) fn spawn_resummary(
+    _root: &Path,
+    _cqs_dir: &Path,
+    _index_path: &Path,
+    _rt: std::sync::Arc<tokio::runtime::Runtime>,
+) -> Option<PendingResummary> {
+    None
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn resummarize_enabled_zero_disables() {
+        let prev = std::env::var("CQS_WATCH_RESUMMARIZE").ok();
+        // SAFETY: tests run sequentially within a process; we restore the
+        // previous value below.
+        unsafe { std::env::remove_var("CQS_WATCH_RESUMMARIZE") };
+        assert!(resummarize_enabled());
+        // SAFETY: see above.
+        unsafe { std::env::set_var("CQS_WATCH_RESUMMARIZE", "0") };
+        assert!(!resummarize_enabled());
+        // SAFETY: see above.
+        unsafe {
+            match prev {
+                Some(v) => std::env::set_var("CQS_WATCH_RESUMMARIZE", v),
+                None => std::env::remove_var("CQS_WATCH_RESUMMARIZE"),
+            }
+        }
+    }
+}
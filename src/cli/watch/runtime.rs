@@ -1,8 +1,9 @@
 //! Daemon runtime helpers: shutdown signal flags, shared tokio runtime
-//! builder, SIGTERM handler.
+//! builder, SIGTERM handler, SIGHUP config-reload handler.
 //!
 //! The unix-only items here drive the daemon drain path (SIGTERM → flag →
-//! accept-loop break → main loop joins the socket thread).
+//! accept-loop break → in-flight drain → main loop joins the socket thread
+//! → store flush + WAL checkpoint).
 //! `build_shared_runtime` is cross-platform and powers `Store` /
 //! `EmbeddingCache` / `QueryCache` from a single pool.
 
@@ -92,3 +93,39 @@ pub(super) fn install_sigterm_handler() {
         }
     }
 }
+
+/// Set on SIGHUP; the watch loop takes it and re-reads the config (see
+/// `cqs::config_reload`). `ctrlc`'s `termination` feature also maps SIGHUP
+/// to `INTERRUPTED`, so [`install_sighup_handler`] must run after
+/// `ctrlc::set_handler` to take SIGHUP back.
+#[cfg(unix)]
+static RELOAD_REQUESTED: AtomicBool = AtomicBool::new(false);
+
+/// Signal handler — async-signal-safe: only an atomic store.
+#[cfg(unix)]
+extern "C" fn on_sighup(_sig: libc::c_int) {
+    RELOAD_REQUESTED.store(true, Ordering::Release);
+}
+
+/// Consume a pending SIGHUP reload request.
+#[cfg(unix)]
+pub(super) fn take_reload_request() -> bool {
+    RELOAD_REQUESTED.swap(false, Ordering::AcqRel)
+}
+
+/// Install a SIGHUP handler so `systemctl reload cqs-watch` (or
+/// `kill -HUP`) re-reads the config instead of stopping the watcher.
+#[cfg(unix)]
+pub(super) fn install_sighup_handler() {
+    // SAFETY: same as `install_sigterm_handler` — called once at the top
+    // of cmd_watch, before the socket thread starts.
+    unsafe {
+        let prev = libc::signal(libc::SIGHUP, on_sighup as *const () as libc::sighandler_t);
+        if prev == libc::SIG_ERR {
+            let e = std::io::Error::last_os_error();
+            tracing::warn!(error = %e, "Failed to install SIGHUP handler; config reload needs a .cqs.toml edit or reload-config");
+        } else {
+            tracing::debug!("SIGHUP handler installed for config reload");
+        }
+    }
+}
@@ -1044,6 +1044,7 @@ mod tests {
             gitignore: &gitignore,
             splade_encoder: None,
             global_cache: None,
+            follow_symlinks: cqs::symlinks::SymlinkPolicy::Never,
         };
         let mut backoff = EmbedderBackoff::new();
         let rt = build_shared_runtime().unwrap();
@@ -1131,6 +1132,7 @@ mod tests {
             gitignore: &gitignore,
             splade_encoder: None,
             global_cache: Some(&cache),
+            follow_symlinks: cqs::symlinks::SymlinkPolicy::Never,
         };
 
         let mut slot = test_slot("sib", &sib_dir, SiblingKind::SameModel);
@@ -362,6 +362,11 @@ pub(super) fn handle_socket_client(
         }
     };
 
+    cqs::metrics::record_query(
+        cqs::metrics::QuerySurface::Daemon,
+        start.elapsed(),
+        status == "ok",
+    );
     tracing::info!(
         status,
         delivered,
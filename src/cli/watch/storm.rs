@@ -0,0 +1,330 @@
+//! Event-storm detection: a branch switch becomes one bulk pass.
+//!
+//! `git checkout` between divergent branches rewrites thousands of files in a
+//! few seconds, and each file fires several events. Queued one by one, that
+//! stream keeps the max-latency cap flushing a partial batch every few
+//! seconds — each with its own parse, embed, and HNSW cycle — and a large
+//! tree spends the better part of an hour catching up.
+//!
+//! [`StormDetector`] counts accepted file events in a fixed window. Past the
+//! threshold it declares a storm: `collect_events` stops queueing paths and
+//! `flush_due` holds whatever was already queued. Once the tree has been
+//! quiet for the settle time, the watch loop runs [`run_bulk_pass`] — one walk
+//! that prunes files the storm deleted and queues every file whose mtime
+//! diverges from the index — and the next flush reindexes the lot as a single
+//! batch. A pass that stops at the queue cap runs again once that flush has
+//! drained the queue.
+//!
+//! While a storm is active `.cqs/.dirty` is held, the same marker the git
+//! hooks fall back to, so a daemon that dies mid-storm reconciles on its next
+//! start. Disable detection with `CQS_WATCH_STORM_EVENTS=0`.
+
+use std::collections::HashSet;
+use std::path::{Path, PathBuf};
+use std::time::{Duration, Instant};
+
+use cqs::parser::Parser as CqParser;
+use cqs::store::Store;
+
+use super::reconcile::run_daemon_reconcile_with_walk;
+use super::DebounceConfig;
+
+/// Default storm threshold: file events per window.
+const DEFAULT_STORM_EVENTS: u64 = 1000;
+/// Default storm window.
+const DEFAULT_STORM_WINDOW_MS: u64 = 1000;
+
+/// Storm thresholds, resolved once at startup.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub(super) struct StormConfig {
+    /// Events within `window` that start a storm; `0` disables detection.
+    threshold: u64,
+    window: Duration,
+    /// Quiet time after the last event before the bulk pass runs.
+    settle: Duration,
+}
+
+impl StormConfig {
+    /// Read `CQS_WATCH_STORM_EVENTS` / `CQS_WATCH_STORM_WINDOW_MS`, falling
+    /// back to the `[watch]` section. The settle time is the longer of the
+    /// window and the longest debounce gap, so a storm never ends sooner than
+    /// an ordinary burst would flush.
+    pub(super) fn resolve(
+        config: Option<&cqs::config::WatchConfig>,
+        debounce: &DebounceConfig,
+    ) -> Self {
+        let env = |name: &str| std::env::var(name).ok().and_then(|v| v.parse::<u64>().ok());
+        let section = config.copied().unwrap_or_default();
+        let threshold = env("CQS_WATCH_STORM_EVENTS")
+            .or(section.storm_events)
+            .unwrap_or(DEFAULT_STORM_EVENTS);
+        let window_ms = env("CQS_WATCH_STORM_WINDOW_MS")
+            .or(section.storm_window_ms)
+            .unwrap_or(DEFAULT_STORM_WINDOW_MS)
+            .max(1);
+        Self::new(threshold, Duration::from_millis(window_ms), debounce)
+    }
+
+    pub(super) fn new(threshold: u64, window: Duration, debounce: &DebounceConfig) -> Self {
+        Self {
+            threshold,
+            window,
+            settle: window.max(debounce.gap_for(u8::MAX)),
+        }
+    }
+
+    pub(super) fn threshold(&self) -> u64 {
+        self.threshold
+    }
+
+    pub(super) fn window(&self) -> Duration {
+        self.window
+    }
+}
+
+/// What [`StormDetector::record`] made of one event.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub(super) enum StormVerdict {
+    /// No storm: queue the path as usual.
+    Queue,
+    /// This event crossed the threshold; the storm starts now.
+    Started,
+    /// Inside a storm: the bulk pass covers the path.
+    Suppressed,
+}
+
+pub(super) struct StormDetector {
+    config: StormConfig,
+    window_start: Instant,
+    window_events: u64,
+    /// When the current storm began; `None` outside a storm.
+    active_since: Option<Instant>,
+    /// File events the current storm kept out of the queue.
+    suppressed: u64,
+    /// The last bulk pass stopped at the queue cap; run another once the
+    /// flush has drained the queue.
+    rerun: bool,
+}
+
+impl StormDetector {
+    pub(super) fn new(config: StormConfig) -> Self {
+        Self {
+            config,
+            window_start: Instant::now(),
+            window_events: 0,
+            active_since: None,
+            suppressed: 0,
+            rerun: false,
+        }
+    }
+
+    /// Count one accepted file event arriving at `now`.
+    pub(super) fn record(&mut self, now: Instant) -> StormVerdict {
+        if self.config.threshold == 0 {
+            return StormVerdict::Queue;
+        }
+        if self.active_since.is_some() {
+            self.suppressed = self.suppressed.saturating_add(1);
+            return StormVerdict::Suppressed;
+        }
+        if now.saturating_duration_since(self.window_start) >= self.config.window {
+            self.window_start = now;
+            self.window_events = 0;
+        }
+        self.window_events += 1;
+        if self.window_events < self.config.threshold {
+            return StormVerdict::Queue;
+        }
+        self.active_since = Some(now);
+        self.suppressed = 1;
+        self.window_events = 0;
+        StormVerdict::Started
+    }
+
+    pub(super) fn is_active(&self) -> bool {
+        self.active_since.is_some()
+    }
+
+    /// Whether the bulk pass should run: the storm has been quiet for the
+    /// settle time, or a capped pass is waiting for the queue to drain.
+    pub(super) fn bulk_pass_due(&self, last_event: Instant, queue_empty: bool) -> bool {
+        if self.active_since.is_some() {
+            return last_event.elapsed() >= self.config.settle;
+        }
+        self.rerun && queue_empty
+    }
+
+    /// Record a completed bulk pass. `at_cap` means the queue filled before
+    /// the walk finished, so another pass follows the next flush. Returns
+    /// the storm's suppressed-event count and duration (zero for a rerun).
+    pub(super) fn finish(&mut self, at_cap: bool) -> (u64, Duration) {
+        let lasted = self
+            .active_since
+            .take()
+            .map_or(Duration::ZERO, |since| since.elapsed());
+        self.rerun = at_cap;
+        (std::mem::take(&mut self.suppressed), lasted)
+    }
+
+    /// Whether `.cqs/.dirty` should still be held: a storm or a capped
+    /// pass is outstanding.
+    pub(super) fn unsettled(&self) -> bool {
+        self.active_since.is_some() || self.rerun
+    }
+}
+
+/// Leave the reconcile-on-start marker for the duration of a storm.
+pub(super) fn hold_dirty_marker(cqs_dir: &Path) {
+    let marker = cqs_dir.join(".dirty");
+    if let Err(e) = std::fs::write(&marker, b"") {
+        tracing::warn!(error = %e, path = %marker.display(), "Failed to write storm marker");
+    }
+}
+
+/// Drop the marker once the bulk pass has covered the storm.
+pub(super) fn release_dirty_marker(cqs_dir: &Path) {
+    let marker = cqs_dir.join(".dirty");
+    match std::fs::remove_file(&marker) {
+        Ok(()) => {}
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => {}
+        Err(e) => {
+            tracing::warn!(error = %e, path = %marker.display(), "Failed to remove storm marker");
+        }
+    }
+}
+
+/// Outcome of one [`run_bulk_pass`].
+#[derive(Debug, Default, Clone, Copy, PartialEq, Eq)]
+pub(super) struct BulkPass {
+    /// Chunks pruned for files the storm deleted.
+    pub(super) pruned: u32,
+    /// Files queued for reindex.
+    pub(super) queued: usize,
+}
+
+/// One walk of the tree in place of the storm's per-file events: prune
+/// chunks of files that no longer exist, then queue every divergent file
+/// into `pending_files`. The prune needs the index lock; when another
+/// writer holds it, the prune is left to the periodic GC and only the
+/// queueing runs.
+pub(super) fn run_bulk_pass(
+    store: &Store,
+    root: &Path,
+    cqs_dir: &Path,
+    parser: &CqParser,
+    no_ignore: bool,
+    pending_files: &mut HashSet<PathBuf>,
+    max_pending: usize,
+) -> BulkPass {
+    let _span = tracing::info_span!("watch_storm_bulk_pass").entered();
+    let exts = parser.supported_extensions();
+    let disk_files: Option<HashSet<PathBuf>> = match cqs::enumerate_files(root, &exts, no_ignore) {
+        Ok(files) => Some(files.into_iter().collect()),
+        Err(e) => {
+            tracing::warn!(error = %e, "Storm bulk pass: walk failed; reconcile walks on its own");
+            None
+        }
+    };
+    let mut pass = BulkPass::default();
+    if let Some(disk_files) = disk_files.as_ref() {
+        match super::try_acquire_index_lock(cqs_dir) {
+            Ok(Some(lock)) => {
+                match store.prune_missing(disk_files, root) {
+                    Ok(n) => pass.pruned = n,
+                    Err(e) => {
+                        tracing::warn!(error = %e, "Storm bulk pass: prune_missing failed");
+                    }
+                }
+                drop(lock);
+            }
+            Ok(None) => {
+                tracing::debug!("Storm bulk pass: index lock held, leaving deletions to GC");
+            }
+            Err(e) => {
+                tracing::warn!(error = %e, "Storm bulk pass: failed to acquire index lock");
+            }
+        }
+    }
+    pass.queued = run_daemon_reconcile_with_walk(
+        store,
+        root,
+        parser,
+        no_ignore,
+        pending_files,
+        max_pending,
+        disk_files.as_ref(),
+    );
+    pass
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn debounce() -> DebounceConfig {
+        DebounceConfig {
+            quiet_gap: Duration::from_millis(500),
+            create_gap: Duration::from_millis(500),
+            delete_gap: Duration::from_millis(500),
+            max_latency: Duration::from_secs(3),
+        }
+    }
+
+    #[test]
+    fn storm_starts_at_threshold_within_window() {
+        let cfg = StormConfig::new(3, Duration::from_secs(1), &debounce());
+        let mut storm = StormDetector::new(cfg);
+        let t0 = Instant::now();
+        assert_eq!(storm.record(t0), StormVerdict::Queue);
+        assert_eq!(storm.record(t0), StormVerdict::Queue);
+        assert_eq!(storm.record(t0), StormVerdict::Started);
+        assert!(storm.is_active());
+        assert_eq!(storm.record(t0), StormVerdict::Suppressed);
+        let (suppressed, _) = storm.finish(false);
+        assert_eq!(suppressed, 2);
+        assert!(!storm.unsettled());
+    }
+
+    #[test]
+    fn slow_events_never_start_a_storm() {
+        let cfg = StormConfig::new(3, Duration::from_millis(100), &debounce());
+        let mut storm = StormDetector::new(cfg);
+        let t0 = Instant::now();
+        for i in 0..10u64 {
+            let at = t0 + Duration::from_millis(60 * i);
+            assert_eq!(storm.record(at), StormVerdict::Queue, "event {i}");
+        }
+        assert!(!storm.is_active());
+    }
+
+    #[test]
+    fn zero_threshold_disables_detection() {
+        let cfg = StormConfig::new(0, Duration::from_secs(1), &debounce());
+        let mut storm = StormDetector::new(cfg);
+        let t0 = Instant::now();
+        for _ in 0..5000 {
+            assert_eq!(storm.record(t0), StormVerdict::Queue);
+        }
+    }
+
+    #[test]
+    fn bulk_pass_waits_for_settle_and_reruns_after_cap() {
+        let cfg = StormConfig::new(1, Duration::from_millis(10), &debounce());
+        assert_eq!(cfg.settle, Duration::from_millis(500));
+        let mut storm = StormDetector::new(cfg);
+        assert_eq!(storm.record(Instant::now()), StormVerdict::Started);
+        assert!(!storm.bulk_pass_due(Instant::now(), true));
+        let quiet = Instant::now()
+            .checked_sub(Duration::from_secs(1))
+            .expect("clock far enough from boot");
+        assert!(storm.bulk_pass_due(quiet, false));
+
+        storm.finish(true);
+        assert!(!storm.is_active());
+        assert!(storm.unsettled());
+        assert!(!storm.bulk_pass_due(quiet, false), "waits for the flush");
+        assert!(storm.bulk_pass_due(quiet, true));
+        storm.finish(false);
+        assert!(!storm.unsettled());
+    }
+}
@@ -86,6 +86,7 @@ fn test_watch_config<'a>(
         gitignore: &TEST_GITIGNORE_NONE,
         splade_encoder: None,
         global_cache: None,
+        follow_symlinks: cqs::symlinks::SymlinkPolicy::Never,
     }
 }
 
@@ -113,6 +114,7 @@ fn test_watch_config_with_gitignore<'a>(
         gitignore,
         splade_encoder: None,
         global_cache: None,
+        follow_symlinks: cqs::symlinks::SymlinkPolicy::Never,
     }
 }
 
@@ -123,6 +125,8 @@ fn test_watch_state() -> WatchState {
         pending_notes: false,
         last_event: std::time::Instant::now(),
         first_pending_event: None,
+        pending_classes: 0,
+        coalesced_this_cycle: 0,
         last_indexed_mtime: HashMap::new(),
         hnsw_index: None,
         incremental_count: 0,
@@ -140,6 +144,16 @@ fn test_watch_state() -> WatchState {
         last_reindex: None,
         last_error: None,
         observed_stamp: None,
+        summaries_dirty: false,
+        pending_resummary: None,
+        lock_blocked_since: None,
+        config_reload_pending: false,
+        storm: StormDetector::new(StormConfig::new(
+            0,
+            std::time::Duration::from_secs(1),
+            &dbc(500, 3000),
+        )),
+        upgrade: UpgradeWorker::default(),
     }
 }
 
@@ -244,6 +258,32 @@ fn collect_events_skips_cqs_dir() {
     );
 }
 
+#[test]
+fn collect_events_queues_config_reload_for_root_config_files() {
+    let root = PathBuf::from("/tmp/test_project");
+    let cqs_dir = PathBuf::from("/tmp/test_project/.cqs");
+    let notes_path = PathBuf::from("/tmp/test_project/docs/notes.toml");
+    let supported: HashSet<&str> = ["rs"].iter().cloned().collect();
+    let cfg = test_watch_config(&root, &cqs_dir, &notes_path, &supported);
+    let modify = EventKind::Modify(notify::event::ModifyKind::Data(
+        notify::event::DataChange::Content,
+    ));
+
+    for name in [".cqs.toml", ".gitignore", ".cqsignore"] {
+        let mut state = test_watch_state();
+        let event = make_event(vec![root.join(name)], modify);
+        collect_events(&event, &cfg, &mut state);
+        assert!(state.config_reload_pending, "{name} should queue a reload");
+        assert!(state.pending_files.is_empty());
+    }
+
+    // Only the project-root copies count.
+    let mut state = test_watch_state();
+    let event = make_event(vec![root.join("sub/.cqs.toml")], modify);
+    collect_events(&event, &cfg, &mut state);
+    assert!(!state.config_reload_pending);
+}
+
 /// Helper: build a `Gitignore` matcher in-memory from lines (no file IO).
 fn gitignore_from_lines(root: &Path, lines: &[&str]) -> ignore::gitignore::Gitignore {
     let mut b = ignore::gitignore::GitignoreBuilder::new(root);
@@ -1918,6 +1958,7 @@ fn dropped_this_cycle_resets_after_successful_drain() {
         gitignore: &gitignore,
         splade_encoder: None,
         global_cache: None,
+        follow_symlinks: cqs::symlinks::SymlinkPolicy::Never,
     };
 
     let mut state = test_watch_state();
@@ -2315,10 +2356,19 @@ fn publish_watch_snapshot_ops_zeros_without_daemon() {
 fn dbc(gap_ms: u64, max_ms: u64) -> DebounceConfig {
     DebounceConfig {
         quiet_gap: Duration::from_millis(gap_ms),
+        create_gap: Duration::from_millis(gap_ms),
+        delete_gap: Duration::from_millis(gap_ms),
         max_latency: Duration::from_millis(max_ms),
     }
 }
 
+fn max_override(ms: u64) -> KindOverrides {
+    KindOverrides {
+        max_ms: Some(ms),
+        ..KindOverrides::default()
+    }
+}
+
 fn backdate(ms: u64) -> std::time::Instant {
     std::time::Instant::now()
         .checked_sub(Duration::from_millis(ms))
@@ -2327,7 +2377,7 @@ fn backdate(ms: u64) -> std::time::Instant {
 
 #[test]
 fn resolve_debounce_defaults() {
-    let cfg = resolve_debounce(500, false, None, None);
+    let cfg = resolve_debounce(500, false, None, KindOverrides::default());
     assert_eq!(cfg.quiet_gap, Duration::from_millis(500));
     assert_eq!(
         cfg.max_latency,
@@ -2337,7 +2387,7 @@ fn resolve_debounce_defaults() {
 
 #[test]
 fn resolve_debounce_wsl_poll_auto_bump_applies_to_quiet_gap() {
-    let cfg = resolve_debounce(500, true, None, None);
+    let cfg = resolve_debounce(500, true, None, KindOverrides::default());
     assert_eq!(cfg.quiet_gap, Duration::from_millis(1500));
     assert_eq!(
         cfg.max_latency,
@@ -2347,7 +2397,7 @@ fn resolve_debounce_wsl_poll_auto_bump_applies_to_quiet_gap() {
 
 #[test]
 fn resolve_debounce_env_gap_overrides_flag_and_suppresses_bump() {
-    let cfg = resolve_debounce(500, true, Some(800), None);
+    let cfg = resolve_debounce(500, true, Some(800), KindOverrides::default());
     assert_eq!(cfg.quiet_gap, Duration::from_millis(800));
     assert_eq!(
         cfg.max_latency,
@@ -2357,19 +2407,19 @@ fn resolve_debounce_env_gap_overrides_flag_and_suppresses_bump() {
 
 #[test]
 fn resolve_debounce_explicit_flag_suppresses_bump() {
-    let cfg = resolve_debounce(1000, true, None, None);
+    let cfg = resolve_debounce(1000, true, None, KindOverrides::default());
     assert_eq!(cfg.quiet_gap, Duration::from_millis(1000));
 }
 
 #[test]
 fn resolve_debounce_env_max_override() {
-    let cfg = resolve_debounce(500, false, None, Some(2000));
+    let cfg = resolve_debounce(500, false, None, max_override(2000));
     assert_eq!(cfg.max_latency, Duration::from_millis(2000));
 }
 
 #[test]
 fn resolve_debounce_max_clamped_to_at_least_quiet_gap() {
-    let cfg = resolve_debounce(500, false, None, Some(100));
+    let cfg = resolve_debounce(500, false, None, max_override(100));
     assert_eq!(
         cfg.max_latency,
         Duration::from_millis(500),
@@ -2377,6 +2427,164 @@ fn resolve_debounce_max_clamped_to_at_least_quiet_gap() {
     );
 }
 
+#[test]
+fn resolve_debounce_per_kind_overrides_and_cap_follows_longest() {
+    let kinds = KindOverrides {
+        modify_ms: Some(800),
+        delete_ms: Some(100),
+        ..KindOverrides::default()
+    };
+    let cfg = resolve_debounce(500, false, None, kinds);
+    assert_eq!(cfg.quiet_gap, Duration::from_millis(800));
+    assert_eq!(
+        cfg.create_gap,
+        Duration::from_millis(800),
+        "an unset kind follows the modify gap"
+    );
+    assert_eq!(cfg.delete_gap, Duration::from_millis(100));
+    assert_eq!(
+        cfg.max_latency,
+        Duration::from_millis(800 * u64::from(MAX_DEBOUNCE_FACTOR))
+    );
+}
+
+#[test]
+fn flush_due_uses_longest_gap_of_pending_classes() {
+    let debounce = DebounceConfig {
+        delete_gap: Duration::from_millis(100),
+        ..dbc(800, 5000)
+    };
+    let mut state = test_watch_state();
+    state.pending_files.insert(PathBuf::from("src/gone.rs"));
+    state.pending_classes = EventClass::Delete.bit();
+    state.last_event = backdate(200);
+    state.first_pending_event = Some(state.last_event);
+    assert!(flush_due(&state, &debounce), "a lone delete flushes early");
+
+    state.pending_classes |= EventClass::Modify.bit();
+    assert!(
+        !flush_due(&state, &debounce),
+        "a pending modify holds the flush to the modify gap"
+    );
+}
+
+#[test]
+fn collect_events_coalesces_repeat_saves_of_one_origin() {
+    let root = PathBuf::from("/tmp/test_project");
+    let cqs_dir = PathBuf::from("/tmp/test_project/.cqs");
+    let notes_path = PathBuf::from("/tmp/test_project/docs/notes.toml");
+    let supported: HashSet<&str> = ["rs"].iter().cloned().collect();
+    let cfg = test_watch_config(&root, &cqs_dir, &notes_path, &supported);
+    let mut state = test_watch_state();
+    let save = || {
+        make_event(
+            vec![PathBuf::from("/tmp/test_project/src/lib.rs")],
+            EventKind::Modify(notify::event::ModifyKind::Data(
+                notify::event::DataChange::Content,
+            )),
+        )
+    };
+
+    for _ in 0..3 {
+        collect_events(&save(), &cfg, &mut state);
+    }
+    assert_eq!(state.pending_files.len(), 1);
+    assert_eq!(state.coalesced_this_cycle, 2);
+    assert_eq!(state.pending_classes, EventClass::Modify.bit());
+}
+
+#[test]
+fn collect_events_atomic_save_queues_origin_once_as_modify() {
+    let tmp = tempfile::TempDir::new().unwrap();
+    let root = tmp.path().to_path_buf();
+    let cqs_dir = root.join(".cqs");
+    let notes_path = root.join("docs/notes.toml");
+    let supported: HashSet<&str> = ["rs"].iter().cloned().collect();
+    let cfg = test_watch_config(&root, &cqs_dir, &notes_path, &supported);
+    let mut state = test_watch_state();
+    std::fs::create_dir_all(root.join("src")).unwrap();
+    let origin = root.join("src/lib.rs");
+    std::fs::write(&origin, "fn saved() {}").unwrap();
+
+    // Vim: swap file write, origin renamed to a backup, fresh origin
+    // written, backup removed. Emacs lock symlink alongside.
+    let events = [
+        make_event(
+            vec![root.join("src/.lib.rs.swp")],
+            EventKind::Modify(notify::event::ModifyKind::Any),
+        ),
+        make_event(
+            vec![root.join("src/.#lib.rs")],
+            EventKind::Create(notify::event::CreateKind::File),
+        ),
+        make_event(
+            vec![origin.clone(), root.join("src/lib.rs~")],
+            EventKind::Modify(notify::event::ModifyKind::Name(
+                notify::event::RenameMode::Both,
+            )),
+        ),
+        make_event(
+            vec![origin.clone()],
+            EventKind::Create(notify::event::CreateKind::File),
+        ),
+        make_event(
+            vec![root.join("src/lib.rs~")],
+            EventKind::Remove(notify::event::RemoveKind::File),
+        ),
+    ];
+    for event in &events {
+        collect_events(event, &cfg, &mut state);
+    }
+    assert_eq!(state.pending_files.len(), 1, "{:?}", state.pending_files);
+    assert!(state.pending_files.contains(Path::new("src/lib.rs")));
+    assert_eq!(state.coalesced_this_cycle, 1);
+    assert_ne!(state.pending_classes & EventClass::Modify.bit(), 0);
+    assert_eq!(state.pending_classes & EventClass::Delete.bit(), 0);
+}
+
+#[test]
+fn event_storm_suspends_queueing_and_holds_the_flush() {
+    let tmp = tempfile::TempDir::new().unwrap();
+    let root = tmp.path().to_path_buf();
+    let cqs_dir = root.join(".cqs");
+    std::fs::create_dir_all(&cqs_dir).unwrap();
+    let notes_path = root.join("docs/notes.toml");
+    let supported: HashSet<&str> = ["rs"].iter().cloned().collect();
+    let cfg = test_watch_config(&root, &cqs_dir, &notes_path, &supported);
+    let mut state = test_watch_state();
+    state.storm = StormDetector::new(StormConfig::new(
+        3,
+        std::time::Duration::from_secs(60),
+        &dbc(500, 3000),
+    ));
+
+    for i in 0..10 {
+        let event = make_event(
+            vec![root.join(format!("src/f{i}.rs"))],
+            EventKind::Create(notify::event::CreateKind::File),
+        );
+        collect_events(&event, &cfg, &mut state);
+    }
+    assert_eq!(
+        state.pending_files.len(),
+        2,
+        "events past the threshold are left to the bulk pass"
+    );
+    assert!(state.storm.is_active());
+    assert!(cqs_dir.join(".dirty").exists(), "storm holds the marker");
+
+    // Long past both the quiet gap and the max-latency cap: still held.
+    state.last_event = backdate(60_000);
+    state.first_pending_event = Some(backdate(60_000));
+    assert!(!flush_due(&state, &dbc(500, 3000)));
+    assert!(state
+        .storm
+        .bulk_pass_due(state.last_event, state.pending_files.is_empty()));
+
+    state.storm.finish(false);
+    assert!(flush_due(&state, &dbc(500, 3000)));
+}
+
 #[test]
 fn flush_due_no_pending_never_flushes() {
     let mut state = test_watch_state();
@@ -2585,3 +2793,38 @@ fn collect_events_filtered_event_does_not_arm_burst_clock() {
         "filtered events queue nothing and must not arm the burst clock"
     );
 }
+
+#[test]
+fn journal_resumes_queue_after_crash_and_clears_on_flush() {
+    let fx = drain_test_fixture(cqs::EMBEDDING_DIM);
+    let root = fx.tmp.path();
+
+    // First daemon queues work, journals it, then "crashes" before flushing.
+    let mut state = test_watch_state();
+    state.pending_files.insert(PathBuf::from("src/a.rs"));
+    state.pending_files.insert(PathBuf::from("src/b.rs"));
+    state.pending_notes = true;
+    let mut journal = PendingJournal::new(root, &fx.notes_path);
+    journal.sync(&fx.store, &state, JournalReason::Event);
+    drop(journal);
+    drop(state);
+
+    // Restarted daemon re-queues it and arms the flush clock.
+    let mut state = test_watch_state();
+    let mut journal = PendingJournal::new(root, &fx.notes_path);
+    assert_eq!(journal.restore(&fx.store, &mut state), 3);
+    assert!(state.pending_notes);
+    assert!(state.first_pending_event.is_some());
+    let mut queued: Vec<_> = state.pending_files.iter().cloned().collect();
+    queued.sort();
+    assert_eq!(
+        queued,
+        vec![PathBuf::from("src/a.rs"), PathBuf::from("src/b.rs")]
+    );
+
+    // A flush consumes the queue; settling empties the journal.
+    state.pending_files.clear();
+    state.pending_notes = false;
+    journal.settle(&fx.store, &state);
+    assert!(fx.store.watch_journal_entries().unwrap().is_empty());
+}
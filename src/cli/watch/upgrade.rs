@@ -0,0 +1,165 @@
+//! Idle-time embedding upgrade for the watch loop.
+//!
+//! `cqs model upgrade <preset>` only queues a move to another embedder;
+//! re-embedding a large index in one go would block for hours. Watch works
+//! through it instead: once the tree has been quiet for
+//! `daemon_periodic_gc_idle_secs()`, each idle tick embeds one batch of
+//! chunks with the target model and stages the vectors beside the served
+//! ones. Search keeps answering from the current model throughout, and a
+//! file event preempts the upgrade on the next tick. When every chunk is
+//! staged the loop logs that the upgrade is ready; the cutover is left to
+//! `cqs model upgrade --finish`, because this process's embedder and HNSW
+//! graph belong to the current model and it is restarted around the swap.
+//!
+//! `CQS_UPGRADE_BATCH` sets the batch size (default 32);
+//! `CQS_WATCH_UPGRADE=0` pauses the background work.
+
+use std::path::Path;
+use std::time::{Duration, Instant};
+
+use cqs::embedder::ModelConfig;
+use cqs::store::{EmbeddingUpgrade, Store};
+use cqs::Embedder;
+
+use crate::cli::try_acquire_index_lock;
+
+/// Chunks embedded per idle tick unless `CQS_UPGRADE_BATCH` says otherwise.
+/// Small enough that a tick stays well under a second on CPU, so an edit
+/// arriving mid-upgrade waits at most one batch.
+const DEFAULT_UPGRADE_BATCH: usize = 32;
+
+/// How long to wait before looking for an upgrade again when none is
+/// pending, the pending one is fully staged, or a batch failed.
+const UPGRADE_RECHECK: Duration = Duration::from_secs(60);
+
+/// Background-upgrade knob. `CQS_WATCH_UPGRADE=0` opts out; any other value
+/// or unset → enabled. Read per idle tick like `CQS_WATCH_RESUMMARIZE`.
+pub(super) fn upgrade_enabled() -> bool {
+    std::env::var("CQS_WATCH_UPGRADE").as_deref() != Ok("0")
+}
+
+fn upgrade_batch() -> usize {
+    std::env::var("CQS_UPGRADE_BATCH")
+        .ok()
+        .and_then(|v| v.parse::<usize>().ok())
+        .filter(|&n| n > 0)
+        .unwrap_or(DEFAULT_UPGRADE_BATCH)
+}
+
+/// Upgrade progress carried across idle ticks.
+#[derive(Default)]
+pub(super) struct UpgradeWorker {
+    /// The target model's embedder, loaded on the first batch and dropped
+    /// once the upgrade is fully staged or gone. Unlike the watch embedder
+    /// it survives the long-idle clear — idle time is when it works.
+    embedder: Option<(String, Embedder)>,
+    /// Model whose "ready" line was logged, so it is logged once.
+    announced: Option<String>,
+    /// Skip ticks until then.
+    snooze_until: Option<Instant>,
+}
+
+impl UpgradeWorker {
+    fn snooze(&mut self) {
+        self.snooze_until = Some(Instant::now() + UPGRADE_RECHECK);
+    }
+
+    /// Stage one batch for the pending upgrade, if there is one. Takes the
+    /// index lock non-blockingly like GC; a held lock retries next tick.
+    pub(super) fn tick(&mut self, store: &Store, cqs_dir: &Path) {
+        if self.snooze_until.is_some_and(|t| Instant::now() < t) {
+            return;
+        }
+        self.snooze_until = None;
+
+        let upgrade = match store.pending_embedding_upgrade() {
+            Ok(Some(u)) => u,
+            Ok(None) => {
+                self.embedder = None;
+                self.announced = None;
+                self.snooze();
+                return;
+            }
+            Err(e) => {
+                tracing::warn!(error = %e, "Embedding upgrade: cannot read pending upgrade");
+                self.snooze();
+                return;
+            }
+        };
+
+        // Load the model before taking the lock, so a slow first load
+        // doesn't hold off a concurrent `cqs index`.
+        let Some(embedder) = self.embedder_for(&upgrade) else {
+            self.snooze();
+            return;
+        };
+        let lock = match try_acquire_index_lock(cqs_dir) {
+            Ok(Some(lock)) => lock,
+            Ok(None) => {
+                tracing::debug!("Embedding upgrade: index lock held, retrying next tick");
+                return;
+            }
+            Err(e) => {
+                tracing::warn!(error = %e, "Embedding upgrade: failed to acquire index lock");
+                self.snooze();
+                return;
+            }
+        };
+        let result =
+            crate::cli::commands::stage_upgrade_batch(store, embedder, &upgrade, upgrade_batch());
+        drop(lock);
+
+        match result {
+            Ok(0) => {
+                self.embedder = None;
+                if self.announced.as_deref() != Some(upgrade.model.as_str()) {
+                    tracing::info!(
+                        model = %upgrade.model,
+                        "Embedding upgrade fully staged; run `cqs model upgrade --finish` to switch"
+                    );
+                    self.announced = Some(upgrade.model);
+                }
+                self.snooze();
+            }
+            Ok(embedded) => {
+                self.announced = None;
+                tracing::debug!(model = %upgrade.model, embedded, "Embedding upgrade batch staged");
+            }
+            Err(e) => {
+                tracing::warn!(error = %e, model = %upgrade.model, "Embedding upgrade batch failed");
+                self.snooze();
+            }
+        }
+    }
+
+    /// The target model's embedder, loading it on first use.
+    fn embedder_for(&mut self, upgrade: &EmbeddingUpgrade) -> Option<&Embedder> {
+        if self
+            .embedder
+            .as_ref()
+            .is_some_and(|(model, _)| *model != upgrade.model)
+        {
+            self.embedder = None;
+        }
+        if self.embedder.is_none() {
+            let Some(cfg) = ModelConfig::from_preset(&upgrade.model) else {
+                tracing::warn!(
+                    model = %upgrade.model,
+                    "Embedding upgrade target is not a known preset; cancel it with `cqs model upgrade --cancel`"
+                );
+                return None;
+            };
+            match Embedder::new(cfg) {
+                Ok(e) => {
+                    tracing::info!(model = %upgrade.model, "Embedding upgrade: target model loaded");
+                    self.embedder = Some((upgrade.model.clone(), e));
+                }
+                Err(e) => {
+                    tracing::warn!(error = %e, model = %upgrade.model, "Embedding upgrade: failed to load target model");
+                    return None;
+                }
+            }
+        }
+        self.embedder.as_ref().map(|(_, e)| e)
+    }
+}
@@ -602,6 +602,7 @@ mod tests {
                 include_pub: true,
                 min_confidence: DeadConfidence::Low,
                 verdict: None,
+                allowlist: None,
             },
             None,
         )
@@ -650,6 +651,7 @@ mod tests {
                 include_pub: true,
                 min_confidence: DeadConfidence::Low,
                 verdict: None,
+                allowlist: None,
             },
             Some(&overlay),
         )
@@ -0,0 +1,319 @@
+//! Commit messages in the index: "which commit introduced this?"
+//!
+//! `cqs index` reads the most recent non-merge commits that touched the
+//! project ([`recent_commits`]) and stores each message as a chunk of type
+//! `commit` with `source_type = 'commit'`, so the file walk, staleness, and
+//! GC never mistake it for a file. The files each commit touched are stored
+//! beside it (`commits` / `commit_files`, schema v44). Only commits not yet
+//! in the index are embedded; commits that fall out of the window (or out
+//! of the history, after a rewrite) are dropped.
+//!
+//! Commits are not code, so the default search leaves them out:
+//! `search --with-commits` ranks them alongside code, `--include-type
+//! commit` searches them alone.
+
+use std::collections::HashSet;
+use std::path::{Path, PathBuf};
+use std::sync::OnceLock;
+
+use serde::{Deserialize, Serialize};
+
+use crate::embedder::{Embedder, Embedding};
+use crate::parser::{Chunk, ChunkType, Language};
+use crate::store::{ReadWrite, Store};
+
+/// Commits indexed when neither `CQS_COMMIT_HISTORY` nor `[index]
+/// commit_history` says otherwise.
+pub const DEFAULT_COMMIT_HISTORY: usize = 500;
+
+/// Touched files listed in a commit's embedding text. The full list is
+/// stored either way; past this many, the names stop helping the vector.
+const MAX_EMBEDDED_FILES: usize = 20;
+
+/// Commits embedded and stored per batch.
+const COMMIT_BATCH: usize = 256;
+
+/// Origin prefix of commit chunks: `commit:<12-char sha>`.
+pub const COMMIT_ORIGIN_PREFIX: &str = "commit:";
+
+/// `[index] commit_history`, pushed in once at startup.
+static COMMIT_HISTORY: OnceLock<usize> = OnceLock::new();
+
+/// Push `[index] commit_history` into the index pipeline. Later calls are
+/// no-ops (OnceLock).
+pub fn set_history_from_config(limit: usize) {
+    let _ = COMMIT_HISTORY.set(limit);
+}
+
+/// How many recent commits `cqs index` keeps, `0` meaning none.
+/// Resolution: `CQS_COMMIT_HISTORY` env > `[index] commit_history` >
+/// [`DEFAULT_COMMIT_HISTORY`].
+pub fn history_limit() -> usize {
+    match std::env::var("CQS_COMMIT_HISTORY") {
+        Ok(v) => v.trim().parse().unwrap_or_else(|_| {
+            tracing::warn!(
+                value = %v,
+                "Invalid CQS_COMMIT_HISTORY (must be a count), using default {DEFAULT_COMMIT_HISTORY}"
+            );
+            DEFAULT_COMMIT_HISTORY
+        }),
+        Err(_) => COMMIT_HISTORY
+            .get()
+            .copied()
+            .unwrap_or(DEFAULT_COMMIT_HISTORY),
+    }
+}
+
+/// One commit as read from `git log`.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct CommitRecord {
+    /// Full commit hash.
+    pub sha: String,
+    pub author: String,
+    pub email: String,
+    /// Author time, Unix seconds.
+    pub time: i64,
+    /// Full message, subject first.
+    pub message: String,
+    /// Project-relative paths the commit touched, forward slashes.
+    pub files: Vec<String>,
+}
+
+impl CommitRecord {
+    /// First line of the message.
+    pub fn subject(&self) -> &str {
+        self.message.lines().next().unwrap_or("").trim()
+    }
+
+    /// Origin the commit's chunk is stored under.
+    pub fn origin(&self) -> String {
+        format!(
+            "{COMMIT_ORIGIN_PREFIX}{}",
+            &self.sha[..self.sha.len().min(12)]
+        )
+    }
+
+    /// Chunk id of the commit's chunk.
+    pub fn chunk_id(&self) -> String {
+        format!("{COMMIT_ORIGIN_PREFIX}{}", self.sha)
+    }
+
+    /// The commit as a chunk: the message is the content, the short sha the
+    /// name, the subject the signature.
+    pub fn to_chunk(&self) -> Chunk {
+        let content = self.message.trim_end().to_string();
+        let content_hash = blake3::hash(content.as_bytes()).to_hex().to_string();
+        Chunk {
+            id: self.chunk_id(),
+            file: PathBuf::from(self.origin()),
+            language: Language::Markdown,
+            chunk_type: ChunkType::Commit,
+            name: self.sha[..self.sha.len().min(12)].to_string(),
+            signature: self.subject().to_string(),
+            line_start: 1,
+            line_end: content.lines().count().max(1) as u32,
+            content,
+            doc: None,
+            byte_start: 0,
+            content_hash,
+            canonical_hash: String::new(),
+            parent_id: None,
+            window_idx: None,
+            parent_type_name: None,
+            parser_version: 0,
+        }
+    }
+
+    /// Text the commit is embedded from: the message, then the files it
+    /// touched, so "the commit that changed retry backoff in the store"
+    /// finds it by either.
+    pub fn embedding_text(&self) -> String {
+        let mut text = self.message.trim_end().to_string();
+        if !self.files.is_empty() {
+            let shown: Vec<&str> = self
+                .files
+                .iter()
+                .take(MAX_EMBEDDED_FILES)
+                .map(String::as_str)
+                .collect();
+            text.push_str("\n\nFiles: ");
+            text.push_str(&shown.join(", "));
+        }
+        text
+    }
+}
+
+/// Parse `git log` output written with [`LOG_FORMAT`] and `--name-only`.
+/// Records start with `\x1e`; fields are split by `\x1f`, the last one
+/// holding the touched paths, one per line.
+fn parse_log(text: &str) -> Vec<CommitRecord> {
+    text.split('\x1e')
+        .filter(|record| !record.trim().is_empty())
+        .filter_map(|record| {
+            let fields: Vec<&str> = record.splitn(6, '\x1f').collect();
+            let [sha, author, email, time, message, files] = fields.as_slice() else {
+                tracing::debug!("Skipping malformed git log record");
+                return None;
+            };
+            Some(CommitRecord {
+                sha: sha.trim().to_string(),
+                author: author.to_string(),
+                email: email.to_string(),
+                time: time.trim().parse().unwrap_or(0),
+                message: message.trim().to_string(),
+                files: files
+                    .lines()
+                    .map(str::trim)
+                    .filter(|l| !l.is_empty())
+                    .map(str::to_string)
+                    .collect(),
+            })
+        })
+        .collect()
+}
+
+/// `--format` for [`recent_commits`]: record separator, then hash, author,
+/// email, author time, and raw body, unit-separated.
+const LOG_FORMAT: &str = "--format=%x1e%H%x1f%an%x1f%ae%x1f%at%x1f%B%x1f";
+
+/// The `limit` most recent non-merge commits that touched `root`, newest
+/// first, with paths relative to `root`. `None` when git fails (not a
+/// repository, no commits yet).
+pub fn recent_commits(root: &Path, limit: usize) -> Option<Vec<CommitRecord>> {
+    let _span = tracing::info_span!("recent_commits", limit).entered();
+    let output = std::process::Command::new("git")
+        .args(["-c", "core.quotePath=false", "log", "--no-merges"])
+        .arg(format!("--max-count={limit}"))
+        .args([LOG_FORMAT, "--name-only", "--relative", "--", "."])
+        .current_dir(root)
+        .output()
+        .ok()?;
+    if !output.status.success() {
+        tracing::debug!(
+            stderr = %String::from_utf8_lossy(&output.stderr).trim(),
+            "git log failed, skipping commit history"
+        );
+        return None;
+    }
+    Some(parse_log(&String::from_utf8_lossy(&output.stdout)))
+}
+
+/// What one [`index_commits`] run changed.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct CommitIndexStats {
+    /// Commits embedded and stored this run.
+    pub indexed: usize,
+    /// Commit chunks dropped because they left the window.
+    pub dropped: usize,
+}
+
+/// Bring the indexed commits in line with the last [`history_limit`]
+/// commits: embed the new ones, drop the ones that left the window.
+///
+/// A no-op outside a git work tree. With the limit at `0`, every commit
+/// chunk is dropped.
+pub fn index_commits(
+    store: &Store<ReadWrite>,
+    embedder: &Embedder,
+    root: &Path,
+) -> anyhow::Result<CommitIndexStats> {
+    let _span = tracing::info_span!("index_commits").entered();
+    let limit = history_limit();
+    let commits = if limit == 0 {
+        Vec::new()
+    } else {
+        if !crate::ownership::in_git_work_tree(root) {
+            tracing::debug!("Not a git work tree, skipping commit history");
+            return Ok(CommitIndexStats::default());
+        }
+        match recent_commits(root, limit) {
+            Some(commits) => commits,
+            None => return Ok(CommitIndexStats::default()),
+        }
+    };
+
+    let keep: HashSet<String> = commits.iter().map(|c| c.sha.clone()).collect();
+    let dropped = store.prune_commits(&keep)?;
+
+    let indexed = store.indexed_commit_shas()?;
+    let new: Vec<&CommitRecord> = commits
+        .iter()
+        .filter(|c| !indexed.contains(&c.sha))
+        .collect();
+    for batch in new.chunks(COMMIT_BATCH) {
+        let texts: Vec<String> = batch.iter().map(|c| c.embedding_text()).collect();
+        let refs: Vec<&str> = texts.iter().map(String::as_str).collect();
+        let embeddings: Vec<Embedding> = embedder.embed_documents(&refs)?;
+        let rows: Vec<(CommitRecord, Embedding)> =
+            batch.iter().map(|c| (*c).clone()).zip(embeddings).collect();
+        store.upsert_commits(&rows)?;
+    }
+
+    tracing::info!(indexed = new.len(), dropped, "Commit history indexed");
+    Ok(CommitIndexStats {
+        indexed: new.len(),
+        dropped,
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn record(sha: &str, message: &str, files: &[&str]) -> CommitRecord {
+        CommitRecord {
+            sha: sha.to_string(),
+            author: "Ada".to_string(),
+            email: "ada@example.com".to_string(),
+            time: 1_700_000_000,
+            message: message.to_string(),
+            files: files.iter().map(|f| f.to_string()).collect(),
+        }
+    }
+
+    #[test]
+    fn parse_log_splits_records_and_files() {
+        let text = "\x1e3f2a9c1b0d4e5f60718293a4b5c6d7e8f9012345\x1fAda\x1fada@example.com\x1f1700000000\x1fAdd retry backoff\n\nJitter the delay.\n\x1f\nsrc/store/retry.rs\nsrc/lib.rs\n\
+                    \x1e0123456789abcdef0123456789abcdef01234567\x1fGrace\x1fgrace@example.com\x1f1690000000\x1fInitial commit\n\x1f\nREADME.md\n";
+        let commits = parse_log(text);
+        assert_eq!(commits.len(), 2);
+        assert_eq!(commits[0].author, "Ada");
+        assert_eq!(commits[0].time, 1_700_000_000);
+        assert_eq!(commits[0].message, "Add retry backoff\n\nJitter the delay.");
+        assert_eq!(commits[0].subject(), "Add retry backoff");
+        assert_eq!(commits[0].files, ["src/store/retry.rs", "src/lib.rs"]);
+        assert_eq!(commits[1].files, ["README.md"]);
+    }
+
+    #[test]
+    fn parse_log_skips_malformed_records() {
+        assert!(parse_log("\x1eonly\x1ftwo fields").is_empty());
+        assert!(parse_log("").is_empty());
+    }
+
+    #[test]
+    fn chunk_carries_message_and_short_sha() {
+        let c = record(
+            "3f2a9c1b0d4e5f60718293a4b5c6d7e8f9012345",
+            "Add retry backoff\n\nJitter the delay.\n",
+            &["src/store/retry.rs"],
+        );
+        let chunk = c.to_chunk();
+        assert_eq!(chunk.id, "commit:3f2a9c1b0d4e5f60718293a4b5c6d7e8f9012345");
+        assert_eq!(chunk.file, PathBuf::from("commit:3f2a9c1b0d4e"));
+        assert_eq!(chunk.name, "3f2a9c1b0d4e");
+        assert_eq!(chunk.signature, "Add retry backoff");
+        assert_eq!(chunk.chunk_type, ChunkType::Commit);
+        assert_eq!(chunk.line_end, 3);
+    }
+
+    #[test]
+    fn embedding_text_lists_touched_files() {
+        let c = record("abc", "Fix race", &["src/a.rs", "src/b.rs"]);
+        assert_eq!(c.embedding_text(), "Fix race\n\nFiles: src/a.rs, src/b.rs");
+        let many: Vec<String> = (0..30).map(|i| format!("f{i}.rs")).collect();
+        let refs: Vec<&str> = many.iter().map(String::as_str).collect();
+        let c = record("abc", "Rename", &refs);
+        assert!(c.embedding_text().ends_with("f19.rs"));
+    }
+}
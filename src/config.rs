@@ -426,7 +426,9 @@ pub struct AuxModelSection {
 /// Known knob names (full set in [`crate::search::scoring::knob`]): `rrf_k`,
 /// `type_boost`, `name_exact`, `name_contains`, `name_contained_by`,
 /// `name_max_overlap`, `note_boost_factor`, `importance_test`,
-/// `importance_private`, `parent_boost_per_child`, `parent_boost_cap`.
+/// `importance_private`, `parent_boost_per_child`, `parent_boost_cap`,
+/// `fts_code_weight`, `fts_comment_weight`, `popularity_weight`,
+/// `feedback_weight`, `deprecated_penalty`.
 /// Unknown keys are logged at WARN; out-of-range values are clamped at
 /// load time using each knob's `[min, max]`.
 ///
@@ -485,6 +487,13 @@ pub struct Config {
     pub verbose: Option<bool>,
     /// Disable staleness checks (useful on NFS or slow filesystems)
     pub stale_check: Option<bool>,
+    /// Refuse every network access: model downloads, remote LLM providers,
+    /// endpoint probes (overridden by CQS_OFFLINE env var). See
+    /// [`crate::offline`].
+    pub offline: Option<bool>,
+    /// Paths whose chunks are never sent to an LLM provider (globs). See
+    /// [`crate::llm::exclude`].
+    pub llm_exclude: Option<Vec<String>>,
     /// HNSW search width (higher = more accurate but slower, default 100)
     pub ef_search: Option<usize>,
     /// LLM model name (overridden by CQS_LLM_MODEL env var)
@@ -495,6 +504,9 @@ pub struct Config {
     pub llm_max_tokens: Option<u32>,
     /// LLM max tokens for HyDE query predictions (overridden by CQS_HYDE_MAX_TOKENS env var)
     pub llm_hyde_max_tokens: Option<u32>,
+    /// Translate non-English search queries to English with the LLM provider
+    /// before searching (overridden by CQS_QUERY_TRANSLATE env var)
+    pub llm_query_translate: Option<QueryTranslate>,
     /// Embedding model configuration
     #[serde(default)]
     pub embedding: Option<crate::embedder::EmbeddingConfig>,
@@ -521,13 +533,53 @@ pub struct Config {
     /// Index-pipeline configuration (`[index]` section).
     #[serde(default)]
     pub index: Option<IndexConfig>,
+    /// SQLite pragmas and pool sizing (`[store]` section).
+    #[serde(default)]
+    pub store: Option<StoreConfig>,
+    /// Language detection overrides (`[languages]` section).
+    #[serde(default)]
+    pub languages: Option<LanguagesConfig>,
+    /// External tree-sitter grammars (`[[grammar]]`). Read from the user
+    /// config only; see [`GrammarConfig`].
+    #[serde(default, rename = "grammar")]
+    pub grammars: Vec<GrammarConfig>,
+    /// Secret redaction at index time (`[secrets]` section).
+    #[serde(default)]
+    pub secrets: Option<SecretsConfig>,
+    /// `cqs watch` event debounce (`[watch]` section).
+    #[serde(default)]
+    pub watch: Option<WatchConfig>,
+    /// User ranking hook (`[rank_hook]` section). Read from the user config
+    /// only; see [`RankHookConfig`].
+    #[serde(default)]
+    pub rank_hook: Option<RankHookConfig>,
+    /// Log filter, format, and file (`[log]` section).
+    #[serde(default)]
+    pub log: Option<LogConfig>,
+    /// How `cqs open` launches an editor (`[editor]` section). Read from the
+    /// user config only; see [`EditorConfig`].
+    #[serde(default)]
+    pub editor: Option<EditorConfig>,
+    /// Facet vectors and their blend weights (`[facets]` section).
+    #[serde(default)]
+    pub facets: Option<FacetsConfig>,
+    /// Token vectors for `--reranker late` (`[late_interaction]` section).
+    #[serde(default)]
+    pub late_interaction: Option<LateInteractionConfig>,
 }
 
 /// `[index]` section of `.cqs.toml`. Drives index-pipeline behaviour
 /// that doesn't fit cleanly under the existing top-level fields.
 ///
 ///   - `vendored_paths`: override the vendored-path prefix list
+///   - `attach_doc_comments`: fold leading doc comments into the symbol chunk
 ///   - `[index.policy]`: backend selection knobs
+///   - `[index.fts]`: FTS stemming and stop words
+///   - `follow_symlinks`: symlink policy for the walk and the watcher
+///   - `[index.shards]`: split the store by path prefix
+///   - `blame_authors`: record per-chunk `git blame` summaries
+///   - `commit_history`: how many recent commit messages to index
+///   - `[index.vector]`: mirror vectors into Qdrant or pgvector
 #[derive(Debug, Clone, Default, Serialize, Deserialize)]
 pub struct IndexConfig {
     /// Override list of bare directory-segment names that flag a chunk
@@ -537,6 +589,13 @@ pub struct IndexConfig {
     /// matching algorithm + default list.
     #[serde(default)]
     pub vendored_paths: Option<Vec<String>>,
+    /// Attach the doc comment above a symbol to that symbol's chunk
+    /// (`doc`, surfaced as `has_doc` in JSON). `false` keeps comments out
+    /// of symbol chunks for teams that index documentation separately.
+    /// Env override: `CQS_ATTACH_DOC_COMMENTS`. Built-in default: `true`.
+    /// Takes effect on the next `cqs index --force`.
+    #[serde(default)]
+    pub attach_doc_comments: Option<bool>,
     /// Backend selection policy (`[index.policy]` sub-table).
     ///
     /// Exposes the backend knobs (`CQS_CAGRA_THRESHOLD`,
@@ -545,6 +604,42 @@ pub struct IndexConfig {
     /// backend's `try_open` is `env > [index.policy] > built-in default`.
     #[serde(default)]
     pub policy: Option<IndexPolicy>,
+    /// FTS analyzer settings (`[index.fts]` sub-table).
+    #[serde(default)]
+    pub fts: Option<FtsConfig>,
+    /// Symlink handling for the file walk and `cqs watch`: `false` never
+    /// follows, `"within_root"` follows links that resolve inside the
+    /// project, `true` follows all. See `crate::symlinks`.
+    /// Env override: `CQS_FOLLOW_SYMLINKS`. Built-in default: `false`.
+    #[serde(default)]
+    pub follow_symlinks: Option<crate::symlinks::SymlinkPolicy>,
+    /// Path-prefix sharding (`[index.shards]` sub-table). Unset → one
+    /// store per slot. See `crate::shard`.
+    #[serde(default)]
+    pub shards: Option<ShardsConfig>,
+    /// Parse in low-privilege worker processes with per-file time and
+    /// memory limits, skipping files that break them. For indexing
+    /// untrusted trees. See `crate::parser::sandbox`.
+    /// Env override: `CQS_SANDBOX_PARSE`. Built-in default: `false`.
+    #[serde(default)]
+    pub sandbox_parsing: Option<bool>,
+    /// Blame each indexed file and store the top authors of every chunk
+    /// (`search --owner`, `--with-owners`). `false` skips the `git blame`
+    /// pass on large histories. See `crate::ownership`.
+    /// Env override: `CQS_BLAME_AUTHORS`. Built-in default: `true`.
+    #[serde(default)]
+    pub blame_authors: Option<bool>,
+    /// Index this many of the most recent non-merge commit messages as
+    /// `commit` chunks linked to the files they touched (`search
+    /// --with-commits`). `0` keeps commits out. See `crate::commits`.
+    /// Env override: `CQS_COMMIT_HISTORY`. Built-in default: `500`.
+    #[serde(default)]
+    pub commit_history: Option<usize>,
+    /// External vector store (`[index.vector]` sub-table). Unset → vectors
+    /// are searched from SQLite through HNSW / CAGRA. Read from the user
+    /// config only.
+    #[serde(default)]
+    pub vector: Option<VectorConfig>,
 }
 
 /// `[index.policy]` — backend selection knobs.
@@ -566,6 +661,550 @@ pub struct IndexPolicy {
     pub cagra_persist: Option<bool>,
 }
 
+/// `[index.vector]` — where the dense search leg looks up vectors.
+///
+/// ```toml
+/// [index.vector]
+/// backend = "qdrant"              # or "pgvector", "sqlite" (default)
+/// url = "http://localhost:6333"   # Qdrant URL / PostgreSQL connection string
+/// collection = "billing"          # default: cqs_<hash of the index dir>
+/// ```
+///
+/// Chunks, FTS, and metadata stay in SQLite either way; see
+/// `crate::vector_backend`. Env overrides: `CQS_VECTOR_BACKEND`,
+/// `CQS_VECTOR_URL`, `CQS_VECTOR_COLLECTION`.
+///
+/// The store receives every chunk id and embedding, so like `[rank_hook]`
+/// a project `.cqs.toml` entry is ignored: set it in the user config.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct VectorConfig {
+    #[serde(default)]
+    pub backend: Option<crate::vector_backend::VectorBackendKind>,
+    #[serde(default)]
+    pub url: Option<String>,
+    #[serde(default)]
+    pub collection: Option<String>,
+}
+
+/// `[index.fts]` — natural-language handling in the keyword (FTS) index.
+///
+/// ```toml
+/// [index.fts]
+/// stemmer = "german"
+/// stop_words = ["german", "japanese"]
+/// ```
+///
+/// The analyzer belongs to the index: `cqs index` applies a changed
+/// section by rebuilding the FTS tables (no re-embedding), and every other
+/// command keeps querying with the analyzer the index records until then.
+/// Unset → no stemming, no stop words.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct FtsConfig {
+    /// Snowball stemmer language (`english`, `german`, `french`,
+    /// `spanish`). `japanese` has none and is dropped with a warning.
+    #[serde(default)]
+    pub stemmer: Option<crate::nl::FtsLanguage>,
+    /// Languages whose stop words are dropped from indexed text and
+    /// queries. Any supported language, including `japanese`.
+    #[serde(default)]
+    pub stop_words: Vec<crate::nl::FtsLanguage>,
+}
+
+/// `[index.shards]` — split the index into one store per path prefix.
+///
+/// ```toml
+/// [index.shards]
+/// top_level = true                              # one shard per top-level dir
+/// prefixes = ["services/billing", "services/search"]
+/// ```
+///
+/// A file goes to the longest matching `prefixes` entry, else (with
+/// `top_level`) to the shard of its first path component. Everything else,
+/// including files at the project root, stays in the root shard. Changing
+/// the section takes effect on the next `cqs index`, which moves files to
+/// their new shard.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct ShardsConfig {
+    /// Give every top-level directory its own shard.
+    #[serde(default)]
+    pub top_level: bool,
+    /// Project-relative directory prefixes, each its own shard.
+    #[serde(default)]
+    pub prefixes: Vec<String>,
+}
+
+/// `[languages]` — per-project language detection overrides.
+///
+/// ```toml
+/// [languages.overrides]
+/// "BUILD" = "starlark"
+/// "*.gotmpl" = "go-template"
+/// "*.tsx.snap" = "none"
+/// ```
+///
+/// Keys are file globs, values a language name, alias, extension, or
+/// `none`. See [`crate::language::overrides`] for the matching rules.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct LanguagesConfig {
+    /// Glob → language target.
+    #[serde(default)]
+    pub overrides: std::collections::BTreeMap<String, String>,
+}
+
+/// `[secrets]` — credential redaction at index time.
+///
+/// ```toml
+/// [secrets]
+/// redact = true
+///
+/// [secrets.patterns]
+/// internal_token = "MYCO-[0-9a-f]{32}"
+/// ```
+///
+/// Built-in patterns always apply; `patterns` adds named regexes on top.
+/// See [`crate::secrets`].
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct SecretsConfig {
+    /// Mask matches in stored chunks. LLM prompts are masked either way.
+    /// Env override: `CQS_REDACT_SECRETS`. Built-in default: `true`.
+    #[serde(default)]
+    pub redact: Option<bool>,
+    /// Extra patterns: name → regex. A regex with a capture group masks
+    /// only the first group.
+    #[serde(default)]
+    pub patterns: std::collections::BTreeMap<String, String>,
+}
+
+/// `[watch]` — per-event-kind debounce and event-storm detection for
+/// `cqs watch`.
+///
+/// ```toml
+/// [watch]
+/// modify_debounce_ms = 800   # editor saves
+/// create_debounce_ms = 300
+/// delete_debounce_ms = 100
+/// max_coalesce_ms = 5000     # flush a never-quiet stream after this long
+/// storm_events = 1000        # this many events ...
+/// storm_window_ms = 1000     # ... inside this window is a storm
+/// ```
+///
+/// Pending changes flush once no event has arrived for the longest quiet gap
+/// among the kinds queued, or when the oldest has waited `max_coalesce_ms`.
+/// Unset kinds use the `--debounce` quiet gap. Each key has a
+/// `CQS_WATCH_DEBOUNCE_{MODIFY,CREATE,DELETE}_MS` / `CQS_WATCH_MAX_DEBOUNCE_MS`
+/// env override. An event storm (a branch switch) suspends per-file
+/// processing until the tree is quiet, then runs one bulk reconcile;
+/// `storm_events = 0` disables detection (`CQS_WATCH_STORM_EVENTS`,
+/// `CQS_WATCH_STORM_WINDOW_MS`). Read at startup; a hot reload reports it as
+/// needing a restart.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Serialize, Deserialize)]
+pub struct WatchConfig {
+    /// Quiet gap after a content change (and for reconcile-queued paths).
+    #[serde(default)]
+    pub modify_debounce_ms: Option<u64>,
+    /// Quiet gap after a file appears (create, rename target).
+    #[serde(default)]
+    pub create_debounce_ms: Option<u64>,
+    /// Quiet gap after a file disappears.
+    #[serde(default)]
+    pub delete_debounce_ms: Option<u64>,
+    /// Longest a pending change waits under a continuous event stream.
+    #[serde(default)]
+    pub max_coalesce_ms: Option<u64>,
+    /// File events within `storm_window_ms` that mark an event storm.
+    /// `0` disables storm detection.
+    #[serde(default)]
+    pub storm_events: Option<u64>,
+    /// Window the storm threshold is counted over.
+    #[serde(default)]
+    pub storm_window_ms: Option<u64>,
+}
+
+/// `[rank_hook]` — an external command that rescores search candidates.
+///
+/// ```toml
+/// [rank_hook]
+/// command = ["python3", "/home/me/.config/cqs/rank.py"]
+/// timeout_ms = 500
+/// ```
+///
+/// The command reads NDJSON candidates on stdin and prints `{"id", "score"}`
+/// lines; see [`crate::search::rank_hook`] for the protocol. Like
+/// `[[grammar]]`, it runs code, so it is honored only in the user config; a
+/// project `.cqs.toml` entry is ignored with a warning. Env overrides:
+/// `CQS_RANK_HOOK`, `CQS_RANK_HOOK_TIMEOUT_MS`.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct RankHookConfig {
+    /// Program and arguments, run directly (no shell).
+    #[serde(default)]
+    pub command: Vec<String>,
+    /// Per-search budget before the hook is killed and ignored. Built-in
+    /// default: 500.
+    #[serde(default)]
+    pub timeout_ms: Option<u64>,
+}
+
+/// `[log]` — log verbosity, line format, and an optional rotated log file.
+///
+/// ```toml
+/// [log]
+/// filter = "cqs=info,cqs::store=debug,warn"
+/// format = "json"
+/// file = ".cqs/cqs.log"
+/// max_bytes = 10485760
+/// max_files = 5
+///
+/// [log.commands]
+/// watch = "cqs=debug,warn"
+/// ```
+///
+/// `filter` takes `RUST_LOG` directives; a `[log.commands]` entry replaces
+/// it for that command (`search` for a bare query). `--verbose` and
+/// `RUST_LOG` win over both, `--log-format` / `CQS_LOG_FORMAT` over
+/// `format`, and `--log-file` / `CQS_LOG_FILE` over `file`. A relative
+/// `file` resolves against the project root. The whole section applies on a
+/// hot reload; see [`crate::logging`].
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct LogConfig {
+    /// Level filter in `RUST_LOG` syntax. Built-in default:
+    /// `cqs=info,warn,ort=error`.
+    #[serde(default)]
+    pub filter: Option<String>,
+    /// `text` (default) or `json`.
+    #[serde(default)]
+    pub format: Option<crate::logging::LogFormat>,
+    /// Write logs here instead of stderr.
+    #[serde(default)]
+    pub file: Option<PathBuf>,
+    /// Rotate the log file once it would pass this size. Built-in default:
+    /// 10 MiB (`CQS_LOG_MAX_BYTES`).
+    #[serde(default)]
+    pub max_bytes: Option<u64>,
+    /// Rotated files kept (`<file>.1` …). Built-in default: 5
+    /// (`CQS_LOG_MAX_FILES`).
+    #[serde(default)]
+    pub max_files: Option<usize>,
+    /// Per-command filter, keyed by command name.
+    #[serde(default)]
+    pub commands: std::collections::BTreeMap<String, String>,
+}
+
+/// `[editor]` — how `cqs open <N>` and `--open-first` show a result.
+///
+/// ```toml
+/// [editor]
+/// open = "vscode"
+/// ```
+///
+/// `open` is a preset (`vscode`, `vscode-insiders`, `cursor`, `idea`,
+/// `pycharm`, `goland`, `webstorm`, `clion`, `rustrover`) that opens a URL
+/// such as `vscode://file/{path}:{line}`, or a template of its own with
+/// `{path}` and `{line}` placeholders. A template containing `://` is a URL
+/// handed to the desktop opener; anything else is a command run directly
+/// (`"code --goto {path}:{line}"`). Unset, `$VISUAL` or `$EDITOR` is used.
+/// Like `[rank_hook]`, it runs a command, so a project `.cqs.toml` entry is
+/// ignored with a warning.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct EditorConfig {
+    /// Preset name or `{path}`/`{line}` template.
+    #[serde(default)]
+    pub open: Option<String>,
+}
+
+/// `[facets]` — embed each chunk's name and body on their own and blend
+/// them with the whole-chunk vector at query time.
+///
+/// ```toml
+/// [facets]
+/// enabled = true
+/// whole = 0.5   # the whole-chunk vector
+/// name = 0.25   # name and signature
+/// body = 0.25   # doc comment and code
+/// ```
+///
+/// With `enabled`, `cqs index` embeds the facets of chunks that lack them
+/// (two extra embeddings per chunk on the first run), and dense search
+/// scores a chunk as the weighted mean of the query's cosine against each
+/// of its vectors. Unset weights take the defaults shown; only their ratios
+/// matter. See [`crate::facets`].
+#[derive(Debug, Clone, Copy, Default, PartialEq, Serialize, Deserialize)]
+pub struct FacetsConfig {
+    /// Embed facets at index time and blend them into search scores.
+    /// Built-in default: `false`.
+    #[serde(default)]
+    pub enabled: Option<bool>,
+    /// Weight of the whole-chunk vector.
+    #[serde(default)]
+    pub whole: Option<f32>,
+    /// Weight of the name-and-signature vector.
+    #[serde(default)]
+    pub name: Option<f32>,
+    /// Weight of the doc-and-code vector.
+    #[serde(default)]
+    pub body: Option<f32>,
+}
+
+impl FacetsConfig {
+    /// Whether facets are on.
+    pub fn is_enabled(&self) -> bool {
+        self.enabled.unwrap_or(false)
+    }
+
+    /// The configured weights, defaults filled in and sanitized.
+    pub fn weights(&self) -> crate::facets::FacetWeights {
+        let defaults = crate::facets::FacetWeights::default();
+        crate::facets::FacetWeights {
+            whole: self.whole.unwrap_or(defaults.whole),
+            name: self.name.unwrap_or(defaults.name),
+            body: self.body.unwrap_or(defaults.body),
+        }
+        .sanitized()
+    }
+}
+
+/// `[late_interaction]` — per-token chunk vectors for MaxSim rescoring
+/// (`--reranker late`).
+///
+/// ```toml
+/// [late_interaction]
+/// enabled = true
+/// candidates = 100               # top candidates rescored per search
+/// max_tokens = 128               # token vectors kept per chunk
+/// output = "last_hidden_state"   # the model's per-token output tensor
+/// ```
+///
+/// With `enabled`, `cqs index` stores up to `max_tokens` vectors per chunk,
+/// one model dimension of bytes each (~100 KB per chunk at the defaults),
+/// which is why it is off by default. See [`crate::late_interaction`].
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct LateInteractionConfig {
+    /// Store token vectors at index time. Built-in default: `false`.
+    #[serde(default)]
+    pub enabled: Option<bool>,
+    /// Candidates rescored by MaxSim. Built-in default: 100.
+    #[serde(default)]
+    pub candidates: Option<usize>,
+    /// Token vectors kept per chunk. Built-in default: 128.
+    #[serde(default)]
+    pub max_tokens: Option<usize>,
+    /// Name of the model output holding per-token vectors
+    /// (`[batch, seq, dim]`). Built-in default: `last_hidden_state`.
+    #[serde(default)]
+    pub output: Option<String>,
+}
+
+impl LateInteractionConfig {
+    /// Whether `cqs index` stores token vectors.
+    pub fn is_enabled(&self) -> bool {
+        self.enabled.unwrap_or(false)
+    }
+
+    /// Candidates rescored per search, at least 1.
+    pub fn candidates(&self) -> usize {
+        self.candidates.unwrap_or(100).max(1)
+    }
+
+    /// Token vectors kept per chunk, at least 1.
+    pub fn max_tokens(&self) -> usize {
+        self.max_tokens.unwrap_or(128).max(1)
+    }
+
+    /// The per-token output tensor's name.
+    pub fn output(&self) -> &str {
+        self.output.as_deref().unwrap_or("last_hidden_state")
+    }
+}
+
+/// `[[grammar]]` — a tree-sitter grammar loaded from a shared library at
+/// runtime, for languages this build does not compile in.
+///
+/// ```toml
+/// [[grammar]]
+/// name = "vhdl"
+/// library = "grammars/libtree-sitter-vhdl.so"
+/// extensions = ["vhd", "vhdl"]
+/// query = "grammars/vhdl.scm"
+/// ```
+///
+/// Loading a grammar runs native code, so `[[grammar]]` is honored only in
+/// the user config (`~/.config/cqs/config.toml`); a project `.cqs.toml`
+/// entry is ignored with a warning. Relative paths resolve against the
+/// user config directory. See [`crate::language::plugin`] for the query
+/// format.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct GrammarConfig {
+    /// Grammar name, shown by `cqs languages list`.
+    pub name: String,
+    /// Shared library (`.so`, `.dylib`, `.dll`) exporting the grammar.
+    pub library: PathBuf,
+    /// Exported language function. Defaults to `tree_sitter_<name>`.
+    #[serde(default)]
+    pub symbol: Option<String>,
+    /// File extensions routed to this grammar, without the dot.
+    pub extensions: Vec<String>,
+    /// Tree-sitter query mapping node kinds to cqs chunk types.
+    pub query: PathBuf,
+}
+
+impl GrammarConfig {
+    /// Resolve relative `library` / `query` paths against `dir`.
+    fn resolve_paths(&mut self, dir: &Path) {
+        if self.library.is_relative() {
+            self.library = dir.join(&self.library);
+        }
+        if self.query.is_relative() {
+            self.query = dir.join(&self.query);
+        }
+    }
+}
+
+/// `[store]` — SQLite pragmas and pool sizing for the index database.
+///
+/// ```toml
+/// [store]
+/// busy_timeout_ms = 15000
+/// synchronous = "full"
+///
+/// [store.index]        # bulk `cqs index`
+/// wal_autocheckpoint = 20000
+///
+/// [store.daemon]       # `cqs watch` and the daemon it serves
+/// read_pool_size = 8
+/// ```
+///
+/// Top-level keys apply in every mode; a `[store.search]`, `[store.index]`
+/// or `[store.daemon]` sub-table overrides them for that mode. Each knob
+/// resolves env var > mode sub-table > `[store]` > the mode's built-in
+/// default (see [`crate::store::StoreProfile`]).
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct StoreConfig {
+    /// Settings for every mode.
+    #[serde(flatten)]
+    pub pragmas: StorePragmas,
+    /// Overrides for one-shot CLI commands (`[store.search]`).
+    #[serde(default)]
+    pub search: Option<StorePragmas>,
+    /// Overrides for `cqs index` (`[store.index]`).
+    #[serde(default)]
+    pub index: Option<StorePragmas>,
+    /// Overrides for `cqs watch` / the daemon (`[store.daemon]`).
+    #[serde(default)]
+    pub daemon: Option<StorePragmas>,
+}
+
+/// One set of `[store]` knobs. `None` falls through to the next layer.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Serialize, Deserialize)]
+pub struct StorePragmas {
+    /// How long a connection waits on a locked database before failing.
+    /// Env override: `CQS_BUSY_TIMEOUT_MS`.
+    #[serde(default)]
+    pub busy_timeout_ms: Option<u64>,
+    /// WAL pages between automatic checkpoints (`0` disables them).
+    /// Env override: `CQS_WAL_AUTOCHECKPOINT_PAGES`.
+    #[serde(default)]
+    pub wal_autocheckpoint: Option<u32>,
+    /// `PRAGMA mmap_size` in bytes. Beats slow-filesystem detection.
+    /// Env override: `CQS_MMAP_SIZE`.
+    #[serde(default)]
+    pub mmap_size: Option<u64>,
+    /// `PRAGMA cache_size`: negative is KiB, positive is pages.
+    /// Env override: `CQS_SQLITE_CACHE_SIZE`.
+    #[serde(default)]
+    pub cache_size: Option<i64>,
+    /// `PRAGMA synchronous`. Env override: `CQS_SQLITE_SYNCHRONOUS`.
+    #[serde(default)]
+    pub synchronous: Option<SynchronousLevel>,
+    /// Connections in the read-only pool search queries run on.
+    /// Env override: `CQS_READ_POOL_SIZE`. Clamped to `[1, 64]`.
+    #[serde(default)]
+    pub read_pool_size: Option<u32>,
+    /// Times a query reruns on a fresh snapshot after `SQLITE_BUSY`.
+    /// Env override: `CQS_READ_RETRIES`. Clamped to `[0, 20]`.
+    #[serde(default)]
+    pub read_retries: Option<u32>,
+    /// Which chunk reads recheck the stored text against its `content_hash`.
+    /// Env override: `CQS_VERIFY_READS`.
+    #[serde(default)]
+    pub verify_reads: Option<VerifyReads>,
+}
+
+/// `PRAGMA synchronous` level, spelled as in SQLite.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum SynchronousLevel {
+    Off,
+    Normal,
+    Full,
+    Extra,
+}
+
+impl SynchronousLevel {
+    /// Parse SQLite's spelling, case-insensitively.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.trim().to_ascii_lowercase().as_str() {
+            "off" => Some(Self::Off),
+            "normal" => Some(Self::Normal),
+            "full" => Some(Self::Full),
+            "extra" => Some(Self::Extra),
+            _ => None,
+        }
+    }
+}
+
+/// How often a chunk read rehashes the stored text to catch corruption.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum VerifyReads {
+    /// Never.
+    Off,
+    /// One row in [`crate::store::VERIFY_SAMPLE_EVERY`].
+    Sample,
+    /// Every row.
+    Full,
+}
+
+impl VerifyReads {
+    /// Parse `off` / `sample` / `full`, case-insensitively.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.trim().to_ascii_lowercase().as_str() {
+            "off" => Some(Self::Off),
+            "sample" => Some(Self::Sample),
+            "full" => Some(Self::Full),
+            _ => None,
+        }
+    }
+}
+
+/// When a search query goes through the LLM provider for translation into
+/// English before it is embedded and matched.
+#[derive(
+    Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize, schemars::JsonSchema,
+)]
+#[serde(rename_all = "lowercase")]
+pub enum QueryTranslate {
+    /// Never.
+    #[default]
+    Off,
+    /// When the query doesn't read as English (see
+    /// [`crate::nl::FtsLanguage::detect`]).
+    Auto,
+    /// Every query.
+    Always,
+}
+
+impl QueryTranslate {
+    /// Parse `off` / `auto` / `always`, case-insensitively; `0` and `1` are
+    /// accepted for `off` and `always`.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.trim().to_ascii_lowercase().as_str() {
+            "off" | "0" => Some(Self::Off),
+            "auto" => Some(Self::Auto),
+            "always" | "1" => Some(Self::Always),
+            _ => None,
+        }
+    }
+}
+
 /// Redact a URL for logging — masks credentials (user:pass@host) and
 /// returns only the scheme + host. Returns "[redacted]" for unparseable URLs.
 fn redact_url(url: &str) -> String {
@@ -595,6 +1234,8 @@ impl std::fmt::Debug for Config {
             .field("quiet", &self.quiet)
             .field("verbose", &self.verbose)
             .field("stale_check", &self.stale_check)
+            .field("offline", &self.offline)
+            .field("llm_exclude", &self.llm_exclude)
             .field("ef_search", &self.ef_search)
             .field("llm_model", &self.llm_model)
             .field(
@@ -603,6 +1244,7 @@ impl std::fmt::Debug for Config {
             )
             .field("llm_max_tokens", &self.llm_max_tokens)
             .field("llm_hyde_max_tokens", &self.llm_hyde_max_tokens)
+            .field("llm_query_translate", &self.llm_query_translate)
             .field("embedding", &self.embedding)
             .field("reranker_model", &self.reranker_model)
             .field("reranker_max_length", &self.reranker_max_length)
@@ -610,6 +1252,16 @@ impl std::fmt::Debug for Config {
             .field("splade", &self.splade)
             .field("reranker", &self.reranker)
             .field("references", &self.references)
+            .field("store", &self.store)
+            .field("languages", &self.languages)
+            .field("grammars", &self.grammars)
+            .field("secrets", &self.secrets)
+            .field("watch", &self.watch)
+            .field("rank_hook", &self.rank_hook)
+            .field("log", &self.log)
+            .field("editor", &self.editor)
+            .field("facets", &self.facets)
+            .field("late_interaction", &self.late_interaction)
             .finish()
     }
 }
@@ -671,27 +1323,88 @@ fn clamp_config_usize(value: &mut usize, name: &str, min: usize, max: usize) {
 impl Config {
     /// Load configuration from user and project config files
     pub fn load(project_root: &Path) -> Self {
-        let user_config = dirs::config_dir()
-            .map(|d| d.join("cqs/config.toml"))
-            .and_then(|p| match Self::load_file(&p) {
-                Ok(c) => c,
-                Err(e) => {
-                    tracing::warn!(error = %e, "Failed to load config file");
-                    None
-                }
-            })
-            .unwrap_or_default();
-
-        let project_config = match Self::load_file(&project_root.join(".cqs.toml")) {
-            Ok(c) => c.unwrap_or_default(),
-            Err(e) => {
-                tracing::warn!(error = %e, "Failed to load config file");
-                Config::default()
-            }
+        let lenient = |e: String| {
+            tracing::warn!(error = %e, "Failed to load config file");
+            Ok(None)
         };
+        // `lenient` never returns `Err`, so neither does the load.
+        Self::load_layered(project_root, lenient).unwrap_or_default()
+    }
+
+    /// [`Config::load`] that fails instead of falling back to defaults when
+    /// either config file is unreadable or malformed. Used by hot reload: a
+    /// typo in `.cqs.toml` must leave the running config in place, not reset
+    /// it to built-in defaults.
+    pub fn try_load(project_root: &Path) -> Result<Self, ConfigError> {
+        Self::load_layered(project_root, |e| Err(ConfigError::InvalidFormat(e)))
+    }
+
+    /// Read, merge, and validate the user and project config files.
+    /// `on_error` decides what a file that fails to load becomes.
+    fn load_layered(
+        project_root: &Path,
+        on_error: impl Fn(String) -> Result<Option<Self>, ConfigError>,
+    ) -> Result<Self, ConfigError> {
+        let user_config_dir = dirs::config_dir().map(|d| d.join("cqs"));
+        let mut user_config = match user_config_dir.as_ref() {
+            Some(d) => Self::load_file(&d.join("config.toml")).or_else(&on_error)?,
+            None => None,
+        }
+        .unwrap_or_default();
+        if let Some(ref dir) = user_config_dir {
+            for grammar in &mut user_config.grammars {
+                grammar.resolve_paths(dir);
+            }
+        }
+
+        let mut project_config = Self::load_file(&project_root.join(".cqs.toml"))
+            .or_else(&on_error)?
+            .unwrap_or_default();
+        // A grammar plugin is a native library cqs will dlopen. A cloned
+        // repo's `.cqs.toml` must not be able to make `cqs index` run code.
+        if !project_config.grammars.is_empty() {
+            tracing::warn!(
+                count = project_config.grammars.len(),
+                "Ignoring [[grammar]] in .cqs.toml: grammar plugins load native code \
+                 and are read from the user config only"
+            );
+            project_config.grammars.clear();
+        }
+        // Same for the ranking hook: it would run on every search.
+        if project_config.rank_hook.take().is_some() {
+            tracing::warn!(
+                "Ignoring [rank_hook] in .cqs.toml: the hook runs a command \
+                 and is read from the user config only"
+            );
+        }
+        // And the editor command, which `cqs open` runs.
+        if project_config.editor.take().is_some() {
+            tracing::warn!(
+                "Ignoring [editor] in .cqs.toml: it names a command to run \
+                 and is read from the user config only"
+            );
+        }
+        // An external vector store receives every chunk id and embedding,
+        // so a cloned repo must not be able to name the server.
+        if project_config
+            .index
+            .as_mut()
+            .and_then(|ic| ic.vector.take())
+            .is_some()
+        {
+            tracing::warn!(
+                "Ignoring [index.vector] in .cqs.toml: the store receives the index's \
+                 embeddings and is read from the user config only"
+            );
+        }
+        let user_vector = user_config.index.as_ref().and_then(|ic| ic.vector.clone());
 
         // Project overrides user
         let mut merged = user_config.override_with(project_config);
+        // `[index]` is replaced whole, so carry the user's store across.
+        if let Some(vector) = user_vector {
+            merged.index.get_or_insert_with(Default::default).vector = Some(vector);
+        }
         merged.validate();
 
         // Don't log `?merged` — the merged Config carries `llm_api_base`
@@ -699,7 +1412,15 @@ impl Config {
         // line re-adding contents must redact userinfo at the field level
         // (see `redact_userinfo` in `llm/mod.rs`).
         tracing::debug!("Effective config built");
-        merged
+        Ok(merged)
+    }
+
+    /// The FTS analyzer `[index.fts]` asks for (the default when unset).
+    pub fn fts_analyzer(&self) -> crate::nl::FtsAnalyzer {
+        match self.index.as_ref().and_then(|ic| ic.fts.as_ref()) {
+            Some(fts) => crate::nl::FtsAnalyzer::new(fts.stemmer, &fts.stop_words),
+            None => crate::nl::FtsAnalyzer::default(),
+        }
     }
 
     /// Clamp all fields to valid ranges and enforce invariants.
@@ -838,6 +1559,37 @@ impl Config {
                 }
             }
         }
+        if let Some(sc) = self.store.as_mut() {
+            let tables = [
+                ("store", Some(&mut sc.pragmas)),
+                ("store.search", sc.search.as_mut()),
+                ("store.index", sc.index.as_mut()),
+                ("store.daemon", sc.daemon.as_mut()),
+            ];
+            for (table, pragmas) in tables {
+                let Some(pragmas) = pragmas else { continue };
+                if let Some(n) = pragmas.read_pool_size.as_mut() {
+                    let mut v = *n as usize;
+                    clamp_config_usize(&mut v, &format!("{table}.read_pool_size"), 1, 64);
+                    *n = v as u32;
+                }
+                if let Some(n) = pragmas.read_retries.as_mut() {
+                    let mut v = *n as usize;
+                    clamp_config_usize(&mut v, &format!("{table}.read_retries"), 0, 20);
+                    *n = v as u32;
+                }
+            }
+        }
+        if let Some(fts) = self.index.as_mut().and_then(|ic| ic.fts.as_mut()) {
+            if let Some(lang) = fts.stemmer.filter(|l| !l.has_stemmer()) {
+                tracing::warn!(
+                    language = lang.as_str(),
+                    "[index.fts] stemmer has no algorithm for this language — ignoring it \
+                     (stop_words still apply)"
+                );
+                fts.stemmer = None;
+            }
+        }
     }
 
     /// Load configuration from a specific file
@@ -924,11 +1676,32 @@ impl Config {
             quiet: other.quiet.or(self.quiet),
             verbose: other.verbose.or(self.verbose),
             stale_check: other.stale_check.or(self.stale_check),
+            // A project config can turn offline mode on but not off: an
+            // air-gapped user config must not be undone by a checked-out repo.
+            offline: if self.offline == Some(true) {
+                Some(true)
+            } else {
+                other.offline.or(self.offline)
+            },
+            // Exclusions accumulate: a project can withhold more paths but
+            // cannot release ones the user config withholds.
+            llm_exclude: match (self.llm_exclude, other.llm_exclude) {
+                (Some(mut user), Some(project)) => {
+                    for pattern in project {
+                        if !user.contains(&pattern) {
+                            user.push(pattern);
+                        }
+                    }
+                    Some(user)
+                }
+                (user, project) => project.or(user),
+            },
             ef_search: other.ef_search.or(self.ef_search),
             llm_model: other.llm_model.or(self.llm_model),
             llm_api_base: other.llm_api_base.or(self.llm_api_base),
             llm_max_tokens: other.llm_max_tokens.or(self.llm_max_tokens),
             llm_hyde_max_tokens: other.llm_hyde_max_tokens.or(self.llm_hyde_max_tokens),
+            llm_query_translate: other.llm_query_translate.or(self.llm_query_translate),
             embedding: other.embedding.or(self.embedding),
             reranker_model: other.reranker_model.or(self.reranker_model),
             reranker_max_length: other.reranker_max_length.or(self.reranker_max_length),
@@ -937,6 +1710,19 @@ impl Config {
             reranker: other.reranker.or(self.reranker),
             references: refs,
             index: other.index.or(self.index),
+            store: other.store.or(self.store),
+            languages: other.languages.or(self.languages),
+            // User config only; `load` drops project entries before merging.
+            grammars: self.grammars,
+            secrets: other.secrets.or(self.secrets),
+            watch: other.watch.or(self.watch),
+            // User config only; `load` drops the project entry before merging.
+            rank_hook: self.rank_hook,
+            log: other.log.or(self.log),
+            // User config only; `load` drops the project entry before merging.
+            editor: self.editor,
+            facets: other.facets.or(self.facets),
+            late_interaction: other.late_interaction.or(self.late_interaction),
         }
     }
 }
@@ -1207,6 +1993,56 @@ mod tests {
         assert_eq!(merged.name_boost, Some(0.3));
     }
 
+    #[test]
+    fn test_merge_project_cannot_disable_offline() {
+        let user = Config {
+            offline: Some(true),
+            ..Default::default()
+        };
+        let project = Config {
+            offline: Some(false),
+            ..Default::default()
+        };
+        assert_eq!(user.override_with(project).offline, Some(true));
+
+        let user = Config::default();
+        let project = Config {
+            offline: Some(true),
+            ..Default::default()
+        };
+        assert_eq!(user.override_with(project).offline, Some(true));
+    }
+
+    #[test]
+    fn test_merge_llm_exclude_is_a_union() {
+        let user = Config {
+            llm_exclude: Some(vec!["*.pem".into(), "internal/crypto/**".into()]),
+            ..Default::default()
+        };
+        let project = Config {
+            llm_exclude: Some(vec!["internal/crypto/**".into(), "secrets/*".into()]),
+            ..Default::default()
+        };
+        assert_eq!(
+            user.override_with(project).llm_exclude,
+            Some(vec![
+                "*.pem".to_string(),
+                "internal/crypto/**".to_string(),
+                "secrets/*".to_string(),
+            ])
+        );
+
+        let user = Config::default();
+        let project = Config {
+            llm_exclude: Some(vec!["*.key".into()]),
+            ..Default::default()
+        };
+        assert_eq!(
+            user.override_with(project).llm_exclude,
+            Some(vec!["*.key".to_string()])
+        );
+    }
+
     #[test]
     fn test_parse_config_with_references() {
         let dir = TempDir::new().unwrap();
@@ -1240,6 +2076,149 @@ path = "/home/user/.local/share/cqs/refs/serde"
         assert!(config.references[1].source.is_none());
     }
 
+    #[test]
+    fn test_project_config_cannot_declare_grammars() {
+        let dir = TempDir::new().unwrap();
+        std::fs::write(
+            dir.path().join(".cqs.toml"),
+            r#"
+[[grammar]]
+name = "evil"
+library = "evil.so"
+extensions = ["rs"]
+query = "evil.scm"
+"#,
+        )
+        .unwrap();
+
+        let parsed = Config::load_file(&dir.path().join(".cqs.toml"))
+            .unwrap()
+            .unwrap();
+        assert_eq!(parsed.grammars.len(), 1);
+        assert_eq!(parsed.grammars[0].symbol, None);
+
+        let merged = Config::load(dir.path());
+        assert!(merged.grammars.iter().all(|g| g.name != "evil"));
+    }
+
+    #[test]
+    fn test_project_config_cannot_declare_rank_hook() {
+        let dir = TempDir::new().unwrap();
+        std::fs::write(
+            dir.path().join(".cqs.toml"),
+            "[rank_hook]\ncommand = [\"sh\", \"-c\", \"evil\"]\ntimeout_ms = 50\n",
+        )
+        .unwrap();
+
+        let parsed = Config::load_file(&dir.path().join(".cqs.toml"))
+            .unwrap()
+            .unwrap();
+        let hook = parsed.rank_hook.unwrap();
+        assert_eq!(hook.command, ["sh", "-c", "evil"]);
+        assert_eq!(hook.timeout_ms, Some(50));
+
+        let merged = Config::load(dir.path());
+        assert!(merged
+            .rank_hook
+            .is_none_or(|h| !h.command.contains(&"evil".to_string())));
+    }
+
+    #[test]
+    fn test_project_config_cannot_declare_editor() {
+        let dir = TempDir::new().unwrap();
+        std::fs::write(
+            dir.path().join(".cqs.toml"),
+            "[editor]\nopen = \"sh -c evil {path}\"\n",
+        )
+        .unwrap();
+
+        let parsed = Config::load_file(&dir.path().join(".cqs.toml"))
+            .unwrap()
+            .unwrap();
+        assert_eq!(
+            parsed.editor.and_then(|e| e.open).as_deref(),
+            Some("sh -c evil {path}")
+        );
+
+        let merged = Config::load(dir.path());
+        assert!(merged
+            .editor
+            .and_then(|e| e.open)
+            .is_none_or(|open| !open.contains("evil")));
+    }
+
+    #[test]
+    fn test_project_config_cannot_declare_vector_store() {
+        let dir = TempDir::new().unwrap();
+        std::fs::write(
+            dir.path().join(".cqs.toml"),
+            "[index]\ncommit_history = 10\n\n[index.vector]\nbackend = \"qdrant\"\n\
+             url = \"https://evil.example\"\n",
+        )
+        .unwrap();
+
+        let parsed = Config::load_file(&dir.path().join(".cqs.toml"))
+            .unwrap()
+            .unwrap();
+        let vector = parsed.index.and_then(|ic| ic.vector).unwrap();
+        assert_eq!(vector.url.as_deref(), Some("https://evil.example"));
+
+        let merged = Config::load(dir.path());
+        let index = merged.index.unwrap();
+        assert_eq!(index.commit_history, Some(10));
+        assert!(index
+            .vector
+            .and_then(|v| v.url)
+            .is_none_or(|url| !url.contains("evil")));
+    }
+
+    #[test]
+    fn test_facets_weights_fill_defaults() {
+        let dir = TempDir::new().unwrap();
+        std::fs::write(
+            dir.path().join(".cqs.toml"),
+            "[facets]\nenabled = true\nname = 0.5\n",
+        )
+        .unwrap();
+
+        let facets = Config::load_file(&dir.path().join(".cqs.toml"))
+            .unwrap()
+            .unwrap()
+            .facets
+            .unwrap();
+        assert!(facets.is_enabled());
+        assert_eq!(
+            facets.weights(),
+            crate::facets::FacetWeights {
+                whole: 0.5,
+                name: 0.5,
+                body: 0.25
+            }
+        );
+        assert!(!FacetsConfig::default().is_enabled());
+    }
+
+    #[test]
+    fn test_late_interaction_defaults() {
+        let dir = TempDir::new().unwrap();
+        std::fs::write(
+            dir.path().join(".cqs.toml"),
+            "[late_interaction]\nenabled = true\ncandidates = 0\n",
+        )
+        .unwrap();
+
+        let late = Config::load_file(&dir.path().join(".cqs.toml"))
+            .unwrap()
+            .unwrap()
+            .late_interaction
+            .unwrap();
+        assert!(late.is_enabled());
+        assert_eq!(late.candidates(), 1);
+        assert_eq!(late.max_tokens(), 128);
+        assert_eq!(late.output(), "last_hidden_state");
+        assert!(!LateInteractionConfig::default().is_enabled());
+    }
+
     #[test]
     fn test_merge_references_replace_by_name() {
         let user = Config {
@@ -1809,6 +2788,58 @@ llm_max_tokens = 200
         );
     }
 
+    /// `[index.fts]` parses language names and feeds the analyzer; a
+    /// stemmer for a language without one is dropped by validation.
+    #[test]
+    fn index_fts_builds_analyzer() {
+        let toml = r#"
+        [index.fts]
+        stemmer = "german"
+        stop_words = ["japanese", "german"]
+        "#;
+        let config: Config = toml::from_str(toml).expect("toml must parse");
+        assert_eq!(
+            config.fts_analyzer().signature(),
+            "stemmer=german;stop_words=german,japanese"
+        );
+        assert!(Config::default().fts_analyzer().is_default());
+
+        let mut config: Config =
+            toml::from_str("[index.fts]\nstemmer = \"japanese\"\n").expect("toml must parse");
+        config.validate();
+        assert!(config.index.unwrap().fts.unwrap().stemmer.is_none());
+
+        assert!(toml::from_str::<Config>("[index.fts]\nstemmer = \"klingon\"\n").is_err());
+    }
+
+    /// `[store]` keeps top-level knobs and per-mode sub-tables apart, and
+    /// validation clamps `read_pool_size` into `[1, 64]` in each of them.
+    #[test]
+    fn store_section_parses_and_clamps_pool() {
+        let toml = r#"
+        [store]
+        busy_timeout_ms = 5000
+        read_pool_size = 0
+        [store.daemon]
+        read_pool_size = 500
+        read_retries = 99
+        synchronous = "off"
+        verify_reads = "full"
+        "#;
+        let mut config: Config = toml::from_str(toml).expect("toml must parse");
+        config.validate();
+        let store = config.store.expect("[store] present");
+        assert_eq!(store.pragmas.busy_timeout_ms, Some(5000));
+        assert_eq!(store.pragmas.read_pool_size, Some(1));
+        let daemon = store.daemon.expect("[store.daemon] present");
+        assert_eq!(daemon.read_pool_size, Some(64));
+        assert_eq!(daemon.read_retries, Some(20));
+        assert_eq!(daemon.synchronous, Some(SynchronousLevel::Off));
+        assert_eq!(daemon.verify_reads, Some(VerifyReads::Full));
+        assert!(daemon.busy_timeout_ms.is_none());
+        assert!(store.index.is_none());
+    }
+
     // ===== ScoringOverrides config parsing =====
 
     #[test]
@@ -0,0 +1,288 @@
+//! Hot reload of the user and project config for long-running processes.
+//!
+//! `cqs watch` (and the daemon it hosts under `--serve`) reads `.cqs.toml`
+//! and `~/.config/cqs/config.toml` once at start. A reload — an edit to
+//! `.cqs.toml`, SIGHUP, or the daemon's `reload-config` command — re-reads
+//! both with [`Config::try_load`] and compares the result with the config
+//! the process is running. Each changed key lands in one class:
+//!
+//! | Class | Keys | Effect |
+//! |-------|------|--------|
+//! | applied | `[scoring]` (fusion weights, boosts), `ef_search`, `[[reference]]`, `limit`, `threshold`, `name_boost`, `quiet`, `verbose`, `stale_check`, `[log]` | takes effect for the next query (`[log]` at once) |
+//! | needs reindex | `[embedding]`, `[index]`, `[languages]`, `[[grammar]]`, `[secrets]`, `[splade]` | rejected; run `cqs index` (or `--force`) and restart |
+//! | needs restart | `offline`, `llm_*`, `[reranker]`, `reranker_*`, `[store]`, `[watch]` | rejected; restart the daemon |
+//!
+//! A file that fails to read or parse rejects the whole reload and the
+//! running config stays. Otherwise the applied keys swap in together under
+//! one lock, and rejected keys keep their running values, so the process
+//! never runs a half-edited mix of an index-shaping setting.
+//!
+//! `.gitignore` / `.cqsignore` changes are picked up by the watch loop on
+//! their own; they are not part of this config.
+
+use std::path::Path;
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::Mutex;
+
+use serde::Serialize;
+
+use crate::config::{Config, ConfigError};
+
+/// What one reload did.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize)]
+pub struct ConfigReload {
+    /// Reload generation after this call. Bumped only when something applied.
+    pub generation: u64,
+    /// Changed keys now in effect.
+    pub applied: Vec<&'static str>,
+    /// Changed keys rejected because the index was built with the old value.
+    pub needs_reindex: Vec<&'static str>,
+    /// Changed keys rejected because they are read once at startup.
+    pub needs_restart: Vec<&'static str>,
+}
+
+impl ConfigReload {
+    /// `true` when the files on disk match the running config.
+    pub fn is_noop(&self) -> bool {
+        self.applied.is_empty() && self.needs_reindex.is_empty() && self.needs_restart.is_empty()
+    }
+
+    /// `true` when at least one changed key was refused.
+    pub fn has_rejections(&self) -> bool {
+        !self.needs_reindex.is_empty() || !self.needs_restart.is_empty()
+    }
+}
+
+/// The config the process is running. `None` until [`init`] (or the first
+/// reload, which then only records a baseline).
+static LIVE: Mutex<Option<Config>> = Mutex::new(None);
+
+/// Bumped on every reload that applied something; reported to callers so
+/// they can tell whether two reloads saw the same config.
+static GENERATION: AtomicU64 = AtomicU64::new(0);
+
+/// Record the config the process started with as the reload baseline.
+pub fn init(config: Config) {
+    *LIVE.lock().unwrap_or_else(|e| e.into_inner()) = Some(config);
+}
+
+/// The config the process is running, when [`init`] has recorded one.
+///
+/// `None` outside `cqs watch`; callers then read the files themselves.
+pub fn current() -> Option<Config> {
+    LIVE.lock().unwrap_or_else(|e| e.into_inner()).clone()
+}
+
+/// Current reload generation; `0` until the first applied reload.
+pub fn generation() -> u64 {
+    GENERATION.load(Ordering::Acquire)
+}
+
+/// Re-read the config files under `project_root` and apply the reloadable
+/// changes. `Err` when a file can't be read or parsed — nothing is applied.
+pub fn reload(project_root: &Path) -> Result<ConfigReload, ConfigError> {
+    let _span = tracing::info_span!("config_reload").entered();
+    let loaded = Config::try_load(project_root)?;
+    let mut live = LIVE.lock().unwrap_or_else(|e| e.into_inner());
+    let Some(running) = live.as_mut() else {
+        *live = Some(loaded);
+        tracing::info!("Config reload recorded a baseline; nothing to compare against");
+        return Ok(ConfigReload {
+            generation: generation(),
+            ..ConfigReload::default()
+        });
+    };
+
+    let mut outcome = diff(running, &loaded);
+    if !outcome.applied.is_empty() {
+        let scoring_changed = outcome.applied.contains(&"scoring");
+        let log_changed = outcome.applied.contains(&"log");
+        apply(running, loaded);
+        if scoring_changed {
+            crate::search::scoring::reload_scoring_from_config(running.scoring.as_ref());
+        }
+        if log_changed {
+            crate::logging::reload_from_config(running.log.as_ref(), project_root);
+        }
+        GENERATION.fetch_add(1, Ordering::AcqRel);
+    }
+    outcome.generation = generation();
+    if outcome.has_rejections() {
+        tracing::warn!(
+            needs_reindex = ?outcome.needs_reindex,
+            needs_restart = ?outcome.needs_restart,
+            "Config reload rejected changes that need a reindex or restart; running values kept"
+        );
+    }
+    tracing::info!(
+        applied = ?outcome.applied,
+        generation = outcome.generation,
+        "Config reloaded"
+    );
+    Ok(outcome)
+}
+
+/// Classify every key that differs between `running` and `loaded`.
+pub fn diff(running: &Config, loaded: &Config) -> ConfigReload {
+    // `[scoring]` is a HashMap whose Debug order isn't stable; compare it
+    // directly. Every other section is compared by its Debug rendering —
+    // none holds a HashMap, so equal values render identically.
+    let scoring_changed =
+        running.scoring.as_ref().map(|s| &s.knobs) != loaded.scoring.as_ref().map(|s| &s.knobs);
+    let applied = [
+        ("scoring", scoring_changed),
+        ("ef_search", differs(&running.ef_search, &loaded.ef_search)),
+        (
+            "references",
+            differs(&running.references, &loaded.references),
+        ),
+        ("limit", differs(&running.limit, &loaded.limit)),
+        ("threshold", differs(&running.threshold, &loaded.threshold)),
+        (
+            "name_boost",
+            differs(&running.name_boost, &loaded.name_boost),
+        ),
+        ("quiet", differs(&running.quiet, &loaded.quiet)),
+        ("verbose", differs(&running.verbose, &loaded.verbose)),
+        (
+            "stale_check",
+            differs(&running.stale_check, &loaded.stale_check),
+        ),
+        ("log", differs(&running.log, &loaded.log)),
+        ("editor", differs(&running.editor, &loaded.editor)),
+    ];
+    let needs_reindex = [
+        ("embedding", differs(&running.embedding, &loaded.embedding)),
+        ("index", differs(&running.index, &loaded.index)),
+        ("languages", differs(&running.languages, &loaded.languages)),
+        ("grammars", differs(&running.grammars, &loaded.grammars)),
+        ("secrets", differs(&running.secrets, &loaded.secrets)),
+        ("splade", differs(&running.splade, &loaded.splade)),
+    ];
+    let needs_restart = [
+        ("offline", differs(&running.offline, &loaded.offline)),
+        (
+            "llm_exclude",
+            differs(&running.llm_exclude, &loaded.llm_exclude),
+        ),
+        ("llm_model", differs(&running.llm_model, &loaded.llm_model)),
+        (
+            "llm_api_base",
+            differs(&running.llm_api_base, &loaded.llm_api_base),
+        ),
+        (
+            "llm_max_tokens",
+            differs(&running.llm_max_tokens, &loaded.llm_max_tokens),
+        ),
+        (
+            "llm_hyde_max_tokens",
+            differs(&running.llm_hyde_max_tokens, &loaded.llm_hyde_max_tokens),
+        ),
+        (
+            "llm_query_translate",
+            differs(&running.llm_query_translate, &loaded.llm_query_translate),
+        ),
+        ("reranker", differs(&running.reranker, &loaded.reranker)),
+        (
+            "reranker_model",
+            differs(&running.reranker_model, &loaded.reranker_model),
+        ),
+        (
+            "reranker_max_length",
+            differs(&running.reranker_max_length, &loaded.reranker_max_length),
+        ),
+        ("store", differs(&running.store, &loaded.store)),
+        ("watch", differs(&running.watch, &loaded.watch)),
+        ("rank_hook", differs(&running.rank_hook, &loaded.rank_hook)),
+    ];
+    let changed = |keys: &[(&'static str, bool)]| -> Vec<&'static str> {
+        keys.iter().filter(|(_, c)| *c).map(|(k, _)| *k).collect()
+    };
+    ConfigReload {
+        generation: 0,
+        applied: changed(&applied),
+        needs_reindex: changed(&needs_reindex),
+        needs_restart: changed(&needs_restart),
+    }
+}
+
+fn differs<T: std::fmt::Debug>(a: &T, b: &T) -> bool {
+    format!("{a:?}") != format!("{b:?}")
+}
+
+/// Copy the reloadable keys of `loaded` into `running`; everything else in
+/// `running` keeps its startup value.
+fn apply(running: &mut Config, loaded: Config) {
+    running.scoring = loaded.scoring;
+    running.ef_search = loaded.ef_search;
+    running.references = loaded.references;
+    running.limit = loaded.limit;
+    running.threshold = loaded.threshold;
+    running.name_boost = loaded.name_boost;
+    running.quiet = loaded.quiet;
+    running.verbose = loaded.verbose;
+    running.stale_check = loaded.stale_check;
+    running.log = loaded.log;
+    running.editor = loaded.editor;
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn parse(toml: &str) -> Config {
+        toml::from_str(toml).unwrap()
+    }
+
+    #[test]
+    fn identical_configs_are_a_noop() {
+        let a = parse("limit = 5\n[scoring]\nrrf_k = 40.0\nname_exact = 0.9\n");
+        let b = parse("[scoring]\nname_exact = 0.9\nrrf_k = 40.0\n\nlimit = 5\n");
+        assert!(diff(&a, &b).is_noop());
+    }
+
+    #[test]
+    fn scoring_and_references_are_reloadable() {
+        let a = parse("[scoring]\nrrf_k = 40.0\n");
+        let b = parse(
+            "ef_search = 200\n[scoring]\nrrf_k = 30.0\n[[reference]]\nname = \"tokio\"\npath = \"/refs/tokio\"\n",
+        );
+        let d = diff(&a, &b);
+        assert_eq!(d.applied, vec!["scoring", "ef_search", "references"]);
+        assert!(!d.has_rejections());
+    }
+
+    #[test]
+    fn log_section_is_reloadable() {
+        let a = parse("[log]\nfilter = \"cqs=info\"\n");
+        let b = parse("[log]\nfilter = \"cqs=debug\"\n[log.commands]\nwatch = \"cqs=trace\"\n");
+        let d = diff(&a, &b);
+        assert_eq!(d.applied, vec!["log"]);
+        assert!(!d.has_rejections());
+    }
+
+    #[test]
+    fn index_shaping_changes_are_rejected() {
+        let a = Config::default();
+        let b = parse(
+            "offline = true\n[index]\nattach_doc_comments = false\n[embedding]\nmodel = \"bge-large\"\n[scoring]\nrrf_k = 30.0\n",
+        );
+        let d = diff(&a, &b);
+        assert_eq!(d.applied, vec!["scoring"]);
+        assert_eq!(d.needs_reindex, vec!["embedding", "index"]);
+        assert_eq!(d.needs_restart, vec!["offline"]);
+    }
+
+    #[test]
+    fn apply_keeps_rejected_keys_at_running_values() {
+        let mut running = parse("limit = 5\n[index]\nattach_doc_comments = true\n");
+        let loaded = parse("limit = 8\n[index]\nattach_doc_comments = false\n");
+        apply(&mut running, loaded);
+        assert_eq!(running.limit, Some(8));
+        assert_eq!(
+            running.index.and_then(|ic| ic.attach_doc_comments),
+            Some(true),
+            "index-shaping key must not change under a running index"
+        );
+    }
+}
@@ -421,8 +421,8 @@ pub fn resolve_daemon_timeout_ms() -> std::time::Duration {
 /// the arg-scanning logic here. Supports both `--model VAL` and `--model=VAL`.
 /// Classification of a daemon dispatch output against the slim batch
 /// envelope contract (`wrap_value` / `wrap_error` in `json_envelope`):
-/// `{"data": <payload>}` or `{"error": {"code","message"}}`, each with an
-/// optional `_meta` sibling and NO other keys. Full v1 envelopes (which
+/// `{"data": <payload>}` or `{"error": {"code","message"}}`, each with
+/// optional `schema_version` and `_meta` siblings and NO other keys. Full v1 envelopes (which
 /// carry `version`) and payloads that merely contain a `data` field among
 /// other keys deliberately do not match — they pass through verbatim.
 pub enum SlimEnvelope<'a> {
@@ -430,6 +430,9 @@ pub enum SlimEnvelope<'a> {
     Data {
         payload: &'a serde_json::Value,
         meta: Option<&'a serde_json::Value>,
+        /// Shape of `payload`; `None` from a daemon that predates the
+        /// marker, whose payloads are the v1 shape.
+        schema_version: Option<u64>,
     },
     /// Failure: redacted code + message from the slim error object.
     Error { code: String, message: String },
@@ -443,13 +446,17 @@ pub fn classify_slim_envelope(v: &serde_json::Value) -> Option<SlimEnvelope<'_>>
     if obj.is_empty()
         || !obj
             .keys()
-            .all(|k| k == "data" || k == "error" || k == "_meta")
+            .all(|k| k == "data" || k == "error" || k == "_meta" || k == "schema_version")
     {
         return None;
     }
     let meta = obj.get("_meta");
     match (obj.get("data"), obj.get("error")) {
-        (Some(payload), None) => Some(SlimEnvelope::Data { payload, meta }),
+        (Some(payload), None) => Some(SlimEnvelope::Data {
+            payload,
+            meta,
+            schema_version: obj.get("schema_version").and_then(|v| v.as_u64()),
+        }),
         (None, Some(err)) => Some(SlimEnvelope::Error {
             code: err
                 .get("code")
@@ -1281,6 +1288,38 @@ pub fn daemon_reconcile(
     )
 }
 
+/// Response shape for [`daemon_reload_config`]. Mirrors
+/// [`crate::config_reload::ConfigReload`] with owned key names.
+#[cfg(unix)]
+#[derive(Debug, Clone, serde::Serialize, serde::Deserialize)]
+pub struct DaemonReloadResponse {
+    /// Reload generation after the call.
+    pub generation: u64,
+    /// Changed keys now in effect.
+    pub applied: Vec<String>,
+    /// Changed keys rejected until the next reindex.
+    pub needs_reindex: Vec<String>,
+    /// Changed keys rejected until the daemon restarts.
+    pub needs_restart: Vec<String>,
+}
+
+/// Ask the running daemon to re-read its config. Used by
+/// `cqs reload-config`.
+///
+/// A config that fails to parse comes back as [`DaemonRpcError`] carrying
+/// the daemon's message; the daemon keeps its running config.
+#[cfg(unix)]
+pub fn daemon_reload_config(
+    cqs_dir: &std::path::Path,
+) -> Result<DaemonReloadResponse, DaemonRpcError> {
+    daemon_request(
+        cqs_dir,
+        "reload-config",
+        serde_json::json!([]),
+        "DaemonReloadResponse",
+    )
+}
+
 /// Outcome of [`wait_for_fresh`].
 ///
 /// Five cases callers need to distinguish so the caller-side advice
@@ -2985,20 +3024,32 @@ mod tests {
     fn classify_slim_data_envelope() {
         let v = serde_json::json!({"data": {"x": 1}});
         match classify_slim_envelope(&v) {
-            Some(SlimEnvelope::Data { payload, meta }) => {
+            Some(SlimEnvelope::Data {
+                payload,
+                meta,
+                schema_version,
+            }) => {
                 assert_eq!(payload, &serde_json::json!({"x": 1}));
                 assert!(meta.is_none());
+                assert!(schema_version.is_none());
             }
             other => panic!("expected Data, got {:?}", other.is_some()),
         }
         let v = serde_json::json!({"data": [1, 2], "_meta": {"worktree_stale": true}});
         match classify_slim_envelope(&v) {
-            Some(SlimEnvelope::Data { payload, meta }) => {
+            Some(SlimEnvelope::Data { payload, meta, .. }) => {
                 assert_eq!(payload, &serde_json::json!([1, 2]));
                 assert!(meta.is_some());
             }
             _ => panic!("expected Data with meta"),
         }
+        let v = serde_json::json!({"data": {"x": 1}, "schema_version": 2});
+        match classify_slim_envelope(&v) {
+            Some(SlimEnvelope::Data { schema_version, .. }) => {
+                assert_eq!(schema_version, Some(2));
+            }
+            _ => panic!("expected Data with schema_version"),
+        }
     }
 
     #[test]
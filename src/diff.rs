@@ -2,6 +2,10 @@
 //!
 //! Compares chunks by identity match + embedding similarity.
 //! Reports added, removed, modified, and unchanged functions.
+//!
+//! [`index_diff`] is the whole-index variant behind `cqs diff-index`: it
+//! keys "modified" on the content hash and carries LLM summaries of both
+//! versions.
 
 use std::collections::HashMap;
 use std::path::PathBuf;
@@ -89,6 +93,19 @@ fn embedding_batch_size() -> usize {
     )
 }
 
+/// Load chunk identities (language filter pushed into SQL), collapsing
+/// windowed chunks to their first window.
+fn load_identities<Mode>(
+    store: &Store<Mode>,
+    language_filter: Option<&str>,
+) -> Result<Vec<ChunkIdentity>, StoreError> {
+    Ok(store
+        .all_chunk_identities_filtered(language_filter)?
+        .into_iter()
+        .filter(|c| c.window_idx.is_none_or(|i| i == 0))
+        .collect())
+}
+
 /// Run a semantic diff between two stores.
 ///
 /// # Memory
@@ -107,19 +124,8 @@ pub fn semantic_diff<Mode1, Mode2>(
     let _span =
         tracing::info_span!("semantic_diff", source_label, target_label, threshold).entered();
 
-    // Load identities from both stores (push language filter into SQL when present)
-    let source_ids = source_store.all_chunk_identities_filtered(language_filter)?;
-    let target_ids = target_store.all_chunk_identities_filtered(language_filter)?;
-
-    // Collapse windowed chunks: keep only window_idx=0 (or None)
-    let source_ids: Vec<_> = source_ids
-        .into_iter()
-        .filter(|c| c.window_idx.is_none_or(|i| i == 0))
-        .collect();
-    let target_ids: Vec<_> = target_ids
-        .into_iter()
-        .filter(|c| c.window_idx.is_none_or(|i| i == 0))
-        .collect();
+    let source_ids = load_identities(source_store, language_filter)?;
+    let target_ids = load_identities(target_store, language_filter)?;
 
     tracing::debug!(
         source_count = source_ids.len(),
@@ -245,6 +251,156 @@ pub fn semantic_diff<Mode1, Mode2>(
     })
 }
 
+/// A symbol present in both indexes whose source changed.
+#[derive(Debug, Clone, serde::Serialize)]
+pub struct ModifiedSymbol {
+    /// Function/class name
+    pub name: String,
+    /// Source file path
+    pub file: PathBuf,
+    /// Type of code element
+    pub chunk_type: ChunkType,
+    /// Embedding similarity between the two versions (`None` when either
+    /// side has no embedding)
+    pub similarity: Option<f32>,
+    /// Embedding moved past the threshold — the change is semantic, not
+    /// cosmetic
+    pub moved: bool,
+    /// LLM summary of the old version, when one was generated
+    pub old_summary: Option<String>,
+    /// LLM summary of the new version, when one was generated
+    pub new_summary: Option<String>,
+}
+
+impl ModifiedSymbol {
+    /// Both versions were summarized and the summaries differ.
+    pub fn summary_changed(&self) -> bool {
+        matches!((&self.old_summary, &self.new_summary), (Some(a), Some(b)) if a != b)
+    }
+}
+
+/// Result of diffing two whole indexes.
+///
+/// Unlike [`DiffResult`], "modified" means the source changed (content hash
+/// differs); [`ModifiedSymbol::moved`] then says whether the embedding
+/// followed. Byte-identical symbols are unchanged regardless of threshold.
+#[derive(Debug, Clone, serde::Serialize)]
+pub struct IndexDiff {
+    /// Label of the old index
+    pub source: String,
+    /// Label of the new index
+    pub target: String,
+    /// Symbols only in the new index, sorted by file then name
+    pub added: Vec<DiffEntry>,
+    /// Symbols only in the old index, sorted by file then name
+    pub removed: Vec<DiffEntry>,
+    /// Symbols whose source changed, most-moved first
+    pub modified: Vec<ModifiedSymbol>,
+    /// Symbols with byte-identical source in both indexes
+    pub unchanged_count: usize,
+}
+
+/// Diff two complete indexes: added, removed, and source-changed symbols,
+/// with embedding movement (`similarity < threshold` marks `moved`) and the
+/// LLM summaries of both versions so summary drift is visible.
+pub fn index_diff<Mode1, Mode2>(
+    source_store: &Store<Mode1>,
+    target_store: &Store<Mode2>,
+    source_label: &str,
+    target_label: &str,
+    threshold: f32,
+    language_filter: Option<&str>,
+) -> Result<IndexDiff, StoreError> {
+    let _span = tracing::info_span!("index_diff", source_label, target_label, threshold).entered();
+
+    let source_ids = load_identities(source_store, language_filter)?;
+    let target_ids = load_identities(target_store, language_filter)?;
+    let source_map: HashMap<ChunkKey, &ChunkIdentity> =
+        source_ids.iter().map(|c| (ChunkKey::from(c), c)).collect();
+    let target_map: HashMap<ChunkKey, &ChunkIdentity> =
+        target_ids.iter().map(|c| (ChunkKey::from(c), c)).collect();
+
+    let entry = |c: &ChunkIdentity| DiffEntry {
+        name: c.name.clone(),
+        file: c.file.clone(),
+        chunk_type: c.chunk_type,
+        similarity: None,
+    };
+
+    let mut added = Vec::new();
+    let mut changed: Vec<(&ChunkIdentity, &ChunkIdentity)> = Vec::new();
+    let mut unchanged_count = 0usize;
+    for (key, target_chunk) in &target_map {
+        match source_map.get(key) {
+            None => added.push(entry(target_chunk)),
+            Some(source_chunk) if source_chunk.content_hash == target_chunk.content_hash => {
+                unchanged_count += 1
+            }
+            Some(source_chunk) => changed.push((source_chunk, target_chunk)),
+        }
+    }
+    let mut removed: Vec<DiffEntry> = source_map
+        .iter()
+        .filter(|(key, _)| !target_map.contains_key(key))
+        .map(|(_, c)| entry(c))
+        .collect();
+
+    let mut modified = Vec::with_capacity(changed.len());
+    for batch in changed.chunks(embedding_batch_size()) {
+        let source_chunk_ids: Vec<&str> = batch.iter().map(|(s, _)| s.id.as_str()).collect();
+        let target_chunk_ids: Vec<&str> = batch.iter().map(|(_, t)| t.id.as_str()).collect();
+        let source_hashes: Vec<&str> = batch.iter().map(|(s, _)| s.content_hash.as_str()).collect();
+        let target_hashes: Vec<&str> = batch.iter().map(|(_, t)| t.content_hash.as_str()).collect();
+
+        let source_embeddings = source_store.get_embeddings_by_ids(&source_chunk_ids)?;
+        let target_embeddings = target_store.get_embeddings_by_ids(&target_chunk_ids)?;
+        let source_summaries = source_store.get_summaries_by_hashes(&source_hashes, "summary")?;
+        let target_summaries = target_store.get_summaries_by_hashes(&target_hashes, "summary")?;
+
+        for (s, t) in batch {
+            let similarity = match (source_embeddings.get(&s.id), target_embeddings.get(&t.id)) {
+                (Some(a), Some(b)) => full_cosine_similarity(a.as_slice(), b.as_slice()),
+                _ => None,
+            };
+            modified.push(ModifiedSymbol {
+                name: t.name.clone(),
+                file: t.file.clone(),
+                chunk_type: t.chunk_type,
+                similarity,
+                moved: similarity.is_none_or(|sim| sim < threshold),
+                old_summary: source_summaries.get(&s.content_hash).cloned(),
+                new_summary: target_summaries.get(&t.content_hash).cloned(),
+            });
+        }
+    }
+
+    let by_location =
+        |a: &DiffEntry, b: &DiffEntry| a.file.cmp(&b.file).then_with(|| a.name.cmp(&b.name));
+    added.sort_by(by_location);
+    removed.sort_by(by_location);
+    // Same ordering contract as `semantic_diff`: lowest similarity first,
+    // unknown similarity last, then a stable (file, name) tiebreak.
+    modified.sort_by(|a, b| {
+        match (a.similarity, b.similarity) {
+            (Some(sa), Some(sb)) => sa.total_cmp(&sb),
+            (Some(_), None) => std::cmp::Ordering::Less,
+            (None, Some(_)) => std::cmp::Ordering::Greater,
+            (None, None) => std::cmp::Ordering::Equal,
+        }
+        .then_with(|| a.file.cmp(&b.file))
+        .then_with(|| a.name.cmp(&b.name))
+    });
+
+    Ok(IndexDiff {
+        source: source_label.to_string(),
+        target: target_label.to_string(),
+        added,
+        removed,
+        modified,
+        unchanged_count,
+    })
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -558,29 +558,35 @@ fn apply_resolved_edits(content: &str, resolved: &[ResolvedEdit]) -> String {
     }
 }
 
-/// Compute the proposed doc-comment edits for `path` against the project
-/// root, render them as a unified diff (`git apply`-compatible), and write
-/// to `out_dir/<rel-path>.patch`. The file under the project root is **not**
-/// modified — this is the review-gate path that backs the default
-/// `cqs index --improve-docs` behaviour.
+/// One file's proposed doc-comment edits rendered as a unified diff.
+pub struct ProposedPatch {
+    /// Path of the source file relative to the project root (the diff's
+    /// `a/` / `b/` header path).
+    pub rel_path: std::path::PathBuf,
+    /// `git apply`-compatible unified diff, newline-terminated.
+    pub diff: String,
+    /// Number of doc comments the diff inserts or replaces.
+    pub applied: usize,
+}
+
+/// Compute the proposed doc-comment edits for `path` and render them as a
+/// unified diff against `project_root`, without touching the file.
 ///
-/// Returns `Ok(true)` when a non-empty patch was written, `Ok(false)` when
-/// there were no edits to propose (every edit skipped, or the rewrite was
-/// a no-op).
-pub fn write_proposed_patch(
+/// Returns `Ok(None)` when there were no edits to propose (every edit
+/// skipped, or the rewrite was a no-op).
+pub fn render_patch(
     path: &Path,
     project_root: &Path,
     edits: &[DocCommentResult],
     parser: &Parser,
-    out_dir: &Path,
-) -> Result<bool, DocWriterError> {
-    let _span = tracing::info_span!("write_proposed_patch", file = %path.display()).entered();
+) -> Result<Option<ProposedPatch>, DocWriterError> {
+    let _span = tracing::info_span!("render_patch", file = %path.display()).entered();
 
     let Some(outcome) = compute_rewrite(path, edits, parser)? else {
-        return Ok(false);
+        return Ok(None);
     };
     if outcome.old_content == outcome.new_content {
-        return Ok(false);
+        return Ok(None);
     }
 
     // Compute the relative path under project_root for the patch header
@@ -600,9 +606,37 @@ pub fn write_proposed_patch(
     if !unified.ends_with('\n') {
         unified.push('\n');
     }
+    Ok(Some(ProposedPatch {
+        rel_path: rel,
+        diff: unified,
+        applied: outcome.applied,
+    }))
+}
+
+/// Compute the proposed doc-comment edits for `path` against the project
+/// root, render them as a unified diff (`git apply`-compatible), and write
+/// to `out_dir/<rel-path>.patch`. The file under the project root is **not**
+/// modified — this is the review-gate path that backs the default
+/// `cqs index --improve-docs` behaviour.
+///
+/// Returns `Ok(true)` when a non-empty patch was written, `Ok(false)` when
+/// there were no edits to propose (every edit skipped, or the rewrite was
+/// a no-op).
+pub fn write_proposed_patch(
+    path: &Path,
+    project_root: &Path,
+    edits: &[DocCommentResult],
+    parser: &Parser,
+    out_dir: &Path,
+) -> Result<bool, DocWriterError> {
+    let _span = tracing::info_span!("write_proposed_patch", file = %path.display()).entered();
+
+    let Some(patch) = render_patch(path, project_root, edits, parser)? else {
+        return Ok(false);
+    };
 
     // out_dir/<rel>.patch — mirror source layout under the proposed-docs root.
-    let mut patch_path = out_dir.join(&rel);
+    let mut patch_path = out_dir.join(&patch.rel_path);
     let new_filename = match patch_path.file_name() {
         Some(name) => format!("{}.patch", name.to_string_lossy()),
         None => "doc.patch".to_string(),
@@ -616,16 +650,29 @@ pub fn write_proposed_patch(
     // `.patch` file at the final path. The review workflow is
     // `git apply .cqs/proposed-docs/**/*.patch`; `git apply` on a truncated
     // unified diff produces partial source changes — silent corruption.
-    atomic_write(&patch_path, unified.as_bytes())?;
+    atomic_write(&patch_path, patch.diff.as_bytes())?;
 
     tracing::info!(
         patch = %patch_path.display(),
-        applied = outcome.applied,
+        applied = patch.applied,
         "Wrote proposed doc patch"
     );
     Ok(true)
 }
 
+/// Concatenate `patches` into one unified diff at `out` (atomically, like
+/// [`write_proposed_patch`]). Files appear in the order given; the result
+/// applies with a single `git apply <out>`.
+pub fn write_combined_patch(out: &Path, patches: &[ProposedPatch]) -> Result<(), DocWriterError> {
+    let _span = tracing::info_span!("write_combined_patch", out = %out.display()).entered();
+    let combined: String = patches.iter().map(|p| p.diff.as_str()).collect();
+    if let Some(parent) = out.parent().filter(|p| !p.as_os_str().is_empty()) {
+        std::fs::create_dir_all(parent)?;
+    }
+    atomic_write(out, combined.as_bytes())?;
+    Ok(())
+}
+
 /// Write bytes to a file atomically: write to a temp file in the same
 /// directory, then promote it via [`crate::fs::atomic_replace`], which
 /// fsyncs the temp file before the rename and fsyncs the parent directory
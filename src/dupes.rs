@@ -0,0 +1,403 @@
+//! Duplicate detection — clusters of near-identical chunks
+//!
+//! Two passes over the stored embeddings:
+//! 1. **Candidates.** Every chunk's embedding is looked up in the vector index
+//!    (or brute-force scanned when no index is on disk) and pairs at or above
+//!    the cosine threshold are kept.
+//! 2. **Confirmation.** Embeddings of short or boilerplate-shaped code sit
+//!    close together without being copies, so each candidate pair must also
+//!    share enough of its identifier tokens (multiset Jaccard over the source
+//!    text). Windows of the same parent chunk and chunks shorter than
+//!    `min_lines` are never paired.
+//!
+//! Confirmed pairs are merged into clusters (union-find), so three copies of
+//! the same helper report as one cluster rather than three pairs.
+
+use std::collections::{BTreeMap, HashMap};
+
+use crate::embedder::Embedding;
+use crate::index::VectorIndex;
+use crate::store::{ChunkSummary, Store, StoreError};
+
+/// Default cosine threshold for a candidate pair.
+pub const DEFAULT_DUPES_THRESHOLD: f32 = 0.95;
+
+/// Default identifier-token overlap a candidate pair must reach.
+pub const DEFAULT_DUPES_MIN_OVERLAP: f32 = 0.6;
+
+/// Default minimum chunk length in lines. One-liners (getters, re-exports)
+/// embed almost identically and are never worth reporting.
+pub const DEFAULT_DUPES_MIN_LINES: u32 = 5;
+
+/// Nearest neighbours requested per chunk from the vector index. A cluster
+/// larger than this still forms — every member pulls in its own neighbours.
+const NEIGHBORS_PER_CHUNK: usize = 10;
+
+/// Embeddings per batch while streaming the store.
+const BATCH_SIZE: usize = 5000;
+
+/// Knobs for [`find_duplicates`].
+#[derive(Debug, Clone, Copy)]
+pub struct DupesOptions {
+    /// Minimum cosine similarity for a candidate pair
+    pub threshold: f32,
+    /// Minimum identifier-token overlap to confirm a candidate
+    pub min_overlap: f32,
+    /// Chunks shorter than this many lines are ignored
+    pub min_lines: u32,
+}
+
+impl Default for DupesOptions {
+    fn default() -> Self {
+        Self {
+            threshold: DEFAULT_DUPES_THRESHOLD,
+            min_overlap: DEFAULT_DUPES_MIN_OVERLAP,
+            min_lines: DEFAULT_DUPES_MIN_LINES,
+        }
+    }
+}
+
+/// A set of chunks that are copies (or near-copies) of each other.
+#[derive(Debug, Clone)]
+pub struct DupeCluster {
+    /// Members sorted by file then line
+    pub members: Vec<ChunkSummary>,
+    /// Lowest cosine similarity among the confirmed pairs
+    pub min_similarity: f32,
+    /// Highest cosine similarity among the confirmed pairs
+    pub max_similarity: f32,
+    /// Lowest token overlap among the confirmed pairs
+    pub min_overlap: f32,
+}
+
+/// Identifier-token multiset Jaccard of two source texts: shared token
+/// occurrences over total occurrences. Counting repeats (rather than set
+/// membership) keeps a function that merely uses the same vocabulary from
+/// matching a real copy.
+pub fn token_overlap(a: &str, b: &str) -> f32 {
+    fn counts(text: &str) -> HashMap<&str, usize> {
+        let mut m = HashMap::new();
+        for t in text
+            .split(|c: char| !(c.is_alphanumeric() || c == '_'))
+            .filter(|t| !t.is_empty())
+        {
+            *m.entry(t).or_insert(0) += 1;
+        }
+        m
+    }
+    let (ca, cb) = (counts(a), counts(b));
+    let mut inter = 0usize;
+    let mut union = 0usize;
+    for (t, &na) in &ca {
+        let nb = cb.get(t).copied().unwrap_or(0);
+        inter += na.min(nb);
+        union += na.max(nb);
+    }
+    for (t, &nb) in &cb {
+        if !ca.contains_key(t) {
+            union += nb;
+        }
+    }
+    if union == 0 {
+        return 0.0;
+    }
+    inter as f32 / union as f32
+}
+
+/// True when two chunks are slices of the same source unit (windows of one
+/// parent, or a parent and its own window) rather than independent code.
+fn same_unit(a: &ChunkSummary, b: &ChunkSummary) -> bool {
+    match (&a.parent_id, &b.parent_id) {
+        (Some(pa), Some(pb)) => pa == pb,
+        (Some(pa), None) => *pa == b.id,
+        (None, Some(pb)) => *pb == a.id,
+        (None, None) => false,
+    }
+}
+
+fn line_count(c: &ChunkSummary) -> u32 {
+    c.line_end.saturating_sub(c.line_start) + 1
+}
+
+/// Candidate pairs keyed `(lower id, higher id)` → cosine similarity.
+type Candidates = HashMap<(String, String), f32>;
+
+fn insert_pair(out: &mut Candidates, a: &str, b: &str, score: f32) {
+    if a == b {
+        return;
+    }
+    let key = if a < b {
+        (a.to_string(), b.to_string())
+    } else {
+        (b.to_string(), a.to_string())
+    };
+    let slot = out.entry(key).or_insert(score);
+    *slot = slot.max(score);
+}
+
+fn candidates_from_index<Mode>(
+    store: &Store<Mode>,
+    index: &dyn VectorIndex,
+    threshold: f32,
+) -> Result<Candidates, StoreError> {
+    let mut out = Candidates::new();
+    for batch in store.embedding_batches(BATCH_SIZE) {
+        for (id, embedding) in batch? {
+            for hit in index.search(&embedding, NEIGHBORS_PER_CHUNK + 1) {
+                if hit.score >= threshold {
+                    insert_pair(&mut out, &id, &hit.id, hit.score);
+                }
+            }
+        }
+    }
+    Ok(out)
+}
+
+fn candidates_brute_force<Mode>(
+    store: &Store<Mode>,
+    threshold: f32,
+) -> Result<Candidates, StoreError> {
+    let mut all: Vec<(String, Embedding)> = Vec::new();
+    for batch in store.embedding_batches(BATCH_SIZE) {
+        all.extend(batch?);
+    }
+    let mut out = Candidates::new();
+    for (i, (id_a, a)) in all.iter().enumerate() {
+        for (id_b, b) in &all[i + 1..] {
+            if let Some(score) = crate::math::cosine_similarity(a.as_slice(), b.as_slice()) {
+                if score >= threshold {
+                    insert_pair(&mut out, id_a, id_b, score);
+                }
+            }
+        }
+    }
+    Ok(out)
+}
+
+/// Find clusters of duplicated code across the index.
+///
+/// `index` is the project's vector index; `None` falls back to an exact
+/// all-pairs scan, which is quadratic in the chunk count.
+///
+/// Clusters are ordered largest first, then by highest similarity.
+pub fn find_duplicates<Mode>(
+    store: &Store<Mode>,
+    index: Option<&dyn VectorIndex>,
+    opts: &DupesOptions,
+) -> Result<Vec<DupeCluster>, StoreError> {
+    let _span = tracing::info_span!(
+        "find_duplicates",
+        threshold = opts.threshold,
+        min_overlap = opts.min_overlap,
+        min_lines = opts.min_lines
+    )
+    .entered();
+
+    let candidates = match index {
+        Some(idx) => candidates_from_index(store, idx, opts.threshold)?,
+        None => {
+            tracing::warn!("No vector index on disk; scanning all embedding pairs");
+            candidates_brute_force(store, opts.threshold)?
+        }
+    };
+    tracing::debug!(
+        candidates = candidates.len(),
+        "candidate pairs above threshold"
+    );
+    if candidates.is_empty() {
+        return Ok(Vec::new());
+    }
+
+    let mut ids: Vec<&str> = candidates
+        .keys()
+        .flat_map(|(a, b)| [a.as_str(), b.as_str()])
+        .collect();
+    ids.sort_unstable();
+    ids.dedup();
+    let chunks = store.get_chunks_by_ids(&ids)?;
+
+    // Confirmed edges as (a, b, cosine, overlap)
+    let mut confirmed: Vec<(&str, &str, f32, f32)> = Vec::new();
+    for ((a, b), &score) in &candidates {
+        let (Some(ca), Some(cb)) = (chunks.get(a), chunks.get(b)) else {
+            continue; // deleted since the index was built
+        };
+        if line_count(ca) < opts.min_lines || line_count(cb) < opts.min_lines {
+            continue;
+        }
+        if same_unit(ca, cb) {
+            continue;
+        }
+        let overlap = token_overlap(&ca.content, &cb.content);
+        if overlap >= opts.min_overlap {
+            confirmed.push((a.as_str(), b.as_str(), score, overlap));
+        }
+    }
+
+    Ok(cluster(&confirmed, &chunks))
+}
+
+/// Merge confirmed pairs into clusters with a union-find over chunk ids.
+fn cluster(
+    confirmed: &[(&str, &str, f32, f32)],
+    chunks: &HashMap<String, ChunkSummary>,
+) -> Vec<DupeCluster> {
+    let mut slot: HashMap<&str, usize> = HashMap::new();
+    let mut parent: Vec<usize> = Vec::new();
+    for &(a, b, _, _) in confirmed {
+        for id in [a, b] {
+            slot.entry(id).or_insert_with(|| {
+                parent.push(parent.len());
+                parent.len() - 1
+            });
+        }
+    }
+    fn find(parent: &mut [usize], mut x: usize) -> usize {
+        while parent[x] != x {
+            parent[x] = parent[parent[x]];
+            x = parent[x];
+        }
+        x
+    }
+    for &(a, b, _, _) in confirmed {
+        let (ra, rb) = (find(&mut parent, slot[a]), find(&mut parent, slot[b]));
+        if ra != rb {
+            parent[ra.max(rb)] = ra.min(rb);
+        }
+    }
+
+    // root -> (members, min sim, max sim, min overlap)
+    let mut groups: BTreeMap<usize, (Vec<&str>, f32, f32, f32)> = BTreeMap::new();
+    for &(a, b, score, overlap) in confirmed {
+        let root = find(&mut parent, slot[a]);
+        let g = groups
+            .entry(root)
+            .or_insert_with(|| (Vec::new(), f32::MAX, f32::MIN, f32::MAX));
+        g.0.extend([a, b]);
+        g.1 = g.1.min(score);
+        g.2 = g.2.max(score);
+        g.3 = g.3.min(overlap);
+    }
+
+    let mut clusters: Vec<DupeCluster> = groups
+        .into_values()
+        .map(|(mut ids, min_similarity, max_similarity, min_overlap)| {
+            ids.sort_unstable();
+            ids.dedup();
+            let mut members: Vec<ChunkSummary> = ids
+                .iter()
+                .filter_map(|id| chunks.get(*id).cloned())
+                .collect();
+            members.sort_by(|a, b| a.file.cmp(&b.file).then(a.line_start.cmp(&b.line_start)));
+            DupeCluster {
+                members,
+                min_similarity,
+                max_similarity,
+                min_overlap,
+            }
+        })
+        .collect();
+    clusters.sort_by(|a, b| {
+        b.members
+            .len()
+            .cmp(&a.members.len())
+            .then(b.max_similarity.total_cmp(&a.max_similarity))
+            .then_with(|| a.members[0].id.cmp(&b.members[0].id))
+    });
+    clusters
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::path::PathBuf;
+
+    fn chunk(id: &str, file: &str, line_start: u32, parent: Option<&str>) -> ChunkSummary {
+        ChunkSummary {
+            id: id.to_string(),
+            file: PathBuf::from(file),
+            language: crate::parser::Language::Rust,
+            chunk_type: crate::parser::ChunkType::Function,
+            name: id.to_string(),
+            signature: String::new(),
+            content: String::new(),
+            doc: None,
+            line_start,
+            line_end: line_start + 9,
+            content_hash: String::new(),
+            window_idx: None,
+            parent_id: parent.map(str::to_string),
+            parent_type_name: None,
+            parser_version: 0,
+            vendored: false,
+            deprecated: false,
+            boilerplate: None,
+        }
+    }
+
+    #[test]
+    fn token_overlap_identical_and_disjoint() {
+        let body = "fn add(a: i32, b: i32) -> i32 { a + b }";
+        assert_eq!(token_overlap(body, body), 1.0);
+        assert_eq!(token_overlap("alpha beta", "gamma delta"), 0.0);
+        assert_eq!(token_overlap("", ""), 0.0);
+    }
+
+    #[test]
+    fn token_overlap_counts_repeats() {
+        // Same vocabulary, different multiplicity: a set Jaccard would say 1.0
+        let a = "x x x x y";
+        let b = "x y y y y";
+        let o = token_overlap(a, b);
+        assert!((o - 2.0 / 8.0).abs() < 1e-6, "got {o}");
+    }
+
+    #[test]
+    fn same_unit_detects_windows_and_parents() {
+        let p = chunk("p", "a.rs", 1, None);
+        let w1 = chunk("w1", "a.rs", 1, Some("p"));
+        let w2 = chunk("w2", "a.rs", 20, Some("p"));
+        let other = chunk("o", "b.rs", 1, None);
+        assert!(same_unit(&w1, &w2));
+        assert!(same_unit(&p, &w1));
+        assert!(!same_unit(&p, &other));
+    }
+
+    #[test]
+    fn cluster_merges_transitive_pairs() {
+        let chunks: HashMap<String, ChunkSummary> = [
+            chunk("a", "src/x.rs", 1, None),
+            chunk("b", "src/y.rs", 1, None),
+            chunk("c", "src/z.rs", 1, None),
+            chunk("d", "src/p.rs", 1, None),
+            chunk("e", "src/q.rs", 1, None),
+        ]
+        .into_iter()
+        .map(|c| (c.id.clone(), c))
+        .collect();
+        let confirmed = [
+            ("a", "b", 0.97, 0.9),
+            ("b", "c", 0.96, 0.8),
+            ("d", "e", 0.99, 1.0),
+        ];
+        let clusters = cluster(&confirmed, &chunks);
+        assert_eq!(clusters.len(), 2);
+        // Largest cluster first
+        let names: Vec<_> = clusters[0].members.iter().map(|m| m.id.as_str()).collect();
+        assert_eq!(names, vec!["a", "b", "c"]);
+        assert_eq!(clusters[0].min_similarity, 0.96);
+        assert_eq!(clusters[0].max_similarity, 0.97);
+        assert_eq!(clusters[0].min_overlap, 0.8);
+        assert_eq!(clusters[1].members.len(), 2);
+    }
+
+    #[test]
+    fn insert_pair_normalizes_order_and_keeps_max() {
+        let mut c = Candidates::new();
+        insert_pair(&mut c, "b", "a", 0.96);
+        insert_pair(&mut c, "a", "b", 0.98);
+        insert_pair(&mut c, "a", "a", 1.0);
+        assert_eq!(c.len(), 1);
+        assert_eq!(c[&("a".to_string(), "b".to_string())], 0.98);
+    }
+}
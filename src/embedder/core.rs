@@ -6,6 +6,7 @@
 //! via `use super::*`.
 
 use super::*;
+use crate::late_interaction::TokenEmbeddings;
 use crate::ort_helpers::ort_err;
 use lru::LruCache;
 use ndarray::{Array2, Array3};
@@ -249,8 +250,9 @@ impl Embedder {
     }
 
     /// Lazy accessor for the on-disk query embedding cache. Opens (and runs
-    /// the 7-day prune) on first call; subsequent calls return the cached
-    /// `Option<&QueryCache>`. Failure to open is non-fatal — caller treats
+    /// the 7-day prune and the size-cap eviction, so one-shot CLI runs stay
+    /// bounded without the daemon) on first call; subsequent calls return
+    /// the cached `Option<&QueryCache>`. Failure to open is non-fatal — caller treats
     /// `None` as "no disk cache available" and proceeds.
     fn disk_query_cache(&self) -> Option<&crate::cache::QueryCache> {
         self.disk_query_cache
@@ -258,6 +260,7 @@ impl Embedder {
                 match crate::cache::QueryCache::open(&crate::cache::QueryCache::default_path()) {
                     Ok(c) => {
                         let _ = c.prune_older_than(7);
+                        let _ = c.evict();
                         Some(c)
                     }
                     Err(e) => {
@@ -624,6 +627,10 @@ impl Embedder {
     /// Returns Vec of (window_content, window_index).
     /// If text fits in max_tokens, returns single window with index 0.
     ///
+    /// Cuts are content-defined and fall on line starts (`plan_windows`), so
+    /// an edit re-cuts only the windows near it and the rest keep their text
+    /// (and their cached embeddings).
+    ///
     /// # Panics
     /// Panics if `overlap >= max_tokens / 2` as this creates exponential window count.
     pub fn split_into_windows(
@@ -679,14 +686,12 @@ impl Embedder {
         let offsets = encoding.get_offsets();
 
         let mut windows = Vec::new();
-        // Step size: tokens per window minus overlap.
-        // The assertion above guarantees step > max_tokens/2, ensuring linear window count.
-        let step = max_tokens - overlap;
-        let mut start = 0;
-        let mut window_idx = 0u32;
-
-        while start < ids.len() {
-            let end = (start + max_tokens).min(ids.len());
+        // The overlap check above keeps every window at least max_tokens/2
+        // tokens past the previous start, ensuring linear window count.
+        for (window_idx, (start, end)) in plan_windows(text, offsets, max_tokens, overlap)
+            .into_iter()
+            .enumerate()
+        {
             let char_start = offsets[start].0;
             let char_end = offsets[end - 1].1;
             // Some tokens (added special tokens, BOS/EOS with add_special_tokens=false
@@ -698,13 +703,7 @@ impl Embedder {
                 text[char_start..char_end].to_string()
             };
 
-            windows.push((window_text, window_idx));
-            window_idx += 1;
-
-            if end >= ids.len() {
-                break;
-            }
-            start += step;
+            windows.push((window_text, window_idx as u32));
         }
 
         Ok(windows)
@@ -739,6 +738,8 @@ impl Embedder {
         // carries inputs; without this, operators have no signal that the call
         // actually produced what was asked for.
         if let Ok(ref embeddings) = result {
+            crate::metrics::EMBED_TEXTS.add(embeddings.len() as u64);
+            crate::metrics::EMBED_SECONDS.add(started.elapsed());
             tracing::info!(
                 total = embeddings.len(),
                 dim = self.embedding_dim(),
@@ -976,9 +977,81 @@ impl Embedder {
         }
     }
 
+    /// Per-token vectors of a query for late-interaction scoring (see
+    /// [`crate::late_interaction`]), read from the model's 3D `output`
+    /// tensor. Adds the query prefix. Not cached: only `--reranker late`
+    /// asks for them, once per search.
+    pub fn embed_query_tokens(
+        &self,
+        text: &str,
+        output: &str,
+    ) -> Result<TokenEmbeddings, EmbedderError> {
+        let _span = tracing::info_span!("embed_query_tokens", output).entered();
+        let text = text.trim();
+        if text.is_empty() {
+            return Err(EmbedderError::EmptyQuery);
+        }
+        let text = truncate_at_char_boundary(text, Self::max_query_bytes());
+        let prefixed = format!("{}{}", self.model_config.query_prefix, text);
+        self.token_batch(&[prefixed], output, usize::MAX)?
+            .into_iter()
+            .next()
+            .ok_or_else(|| {
+                EmbedderError::InferenceFailed("token_batch returned empty result".to_string())
+            })
+    }
+
+    /// Per-token vectors of documents, at most `max_tokens` per text, read
+    /// from the model's 3D `output` tensor. Adds the document prefix and
+    /// batches like [`Self::embed_documents`].
+    pub fn embed_document_tokens(
+        &self,
+        texts: &[&str],
+        output: &str,
+        max_tokens: usize,
+    ) -> Result<Vec<TokenEmbeddings>, EmbedderError> {
+        let _span =
+            tracing::info_span!("embed_document_tokens", count = texts.len(), output).entered();
+        let prefix = &self.model_config.doc_prefix;
+        let mut all = Vec::with_capacity(texts.len());
+        for chunk in texts.chunks(self.model_config.embed_batch_size().max(1)) {
+            let prefixed: Vec<String> = chunk.iter().map(|t| format!("{prefix}{t}")).collect();
+            all.extend(self.token_batch(&prefixed, output, max_tokens)?);
+        }
+        Ok(all)
+    }
+
+    /// Token vectors for one batch: the non-padding rows of `output`, L2
+    /// normalized, first `max_tokens` per text.
+    fn token_batch(
+        &self,
+        texts: &[String],
+        output: &str,
+        max_tokens: usize,
+    ) -> Result<Vec<TokenEmbeddings>, EmbedderError> {
+        if texts.is_empty() {
+            return Ok(vec![]);
+        }
+        let out = self.run_model(texts, output)?;
+        if out.shape.len() != 3 || out.shape[0] as usize != texts.len() {
+            return Err(EmbedderError::InferenceFailed(format!(
+                "late-interaction output '{output}' must be [batch, seq, dim] for {} texts; got shape {:?}",
+                texts.len(),
+                out.shape
+            )));
+        }
+        let (seq_len, dim) = (out.shape[1] as usize, out.shape[2] as usize);
+        let hidden = Array3::from_shape_vec((texts.len(), seq_len, dim), out.data)
+            .map_err(|e| EmbedderError::InferenceFailed(format!("tensor reshape failed: {e}")))?;
+        Ok(token_vectors(&hidden, &out.attention_mask, max_tokens)
+            .into_iter()
+            .map(|data| TokenEmbeddings::new(dim, data))
+            .collect())
+    }
+
     /// Generates embeddings for a batch of text inputs.
     ///
-    /// This method tokenizes the input texts, prepares them as padded tensors suitable for the ONNX model, and runs inference to produce embedding vectors. Texts are padded to the maximum length within the batch (up to the model's configured maximum length).
+    /// This method runs the batch through the model ([`Self::run_model`]) and pools the configured output tensor into one L2-normalized vector per text.
     ///
     /// # Arguments
     ///
@@ -992,16 +1065,152 @@ impl Embedder {
     ///
     /// Returns `EmbedderError::Tokenizer` if tokenization of the batch fails.
     fn embed_batch(&self, texts: &[String]) -> Result<Vec<Embedding>, EmbedderError> {
-        use ort::session::SessionInputValue;
-        use ort::value::Tensor;
-        use std::borrow::Cow;
-
         let _span = tracing::info_span!("embed_batch", count = texts.len()).entered();
 
         if texts.is_empty() {
             return Ok(vec![]);
         }
 
+        let ModelOutput {
+            shape: shape_vec,
+            data: data_vec,
+            attention_mask: attention_mask_arr,
+        } = self.run_model(texts, &self.model_config.output_name)?;
+        let shape: &[i64] = &shape_vec;
+        let data: &[f32] = &data_vec;
+
+        let batch_size = texts.len();
+        let seq_len = attention_mask_arr.ncols();
+
+        // PoolingStrategy::Identity: the ONNX output is already pooled to
+        // `[batch, dim]`. Skip the 3D reshape + pool dispatch and emit
+        // L2-normalized rows directly. Used by EmbeddingGemma's
+        // `sentence_embedding` output.
+        if self.model_config.pooling == PoolingStrategy::Identity {
+            if shape.len() != 2 {
+                return Err(EmbedderError::InferenceFailed(format!(
+                    "PoolingStrategy::Identity expects 2D [batch, dim] output; got {} dimensions",
+                    shape.len()
+                )));
+            }
+            if shape[0] as usize != batch_size {
+                return Err(EmbedderError::InferenceFailed(format!(
+                    "Tensor batch size mismatch: expected {}, got {}",
+                    batch_size, shape[0]
+                )));
+            }
+            let embedding_dim = shape[1] as usize;
+            {
+                // Lock-and-set the Mutex<Option<usize>> slot.
+                let mut guard = self.detected_dim.lock().unwrap_or_else(|p| p.into_inner());
+                match *guard {
+                    Some(expected) if expected != embedding_dim => {
+                        return Err(EmbedderError::InferenceFailed(format!(
+                            "Embedding dimension changed: expected {expected}, got {embedding_dim}"
+                        )));
+                    }
+                    None => {
+                        *guard = Some(embedding_dim);
+                        tracing::info!(
+                            dim = embedding_dim,
+                            "Detected embedding dimension from model (Identity pooling)"
+                        );
+                    }
+                    _ => {}
+                }
+            }
+            let results: Vec<Embedding> = (0..batch_size)
+                .map(|b| {
+                    let start = b * embedding_dim;
+                    let v = data[start..start + embedding_dim].to_vec();
+                    Embedding::new(normalize_l2(v))
+                })
+                .collect();
+            return Ok(results);
+        }
+
+        // Validate tensor shape: expect [batch_size, seq_len, dim]
+        if shape.len() != 3 {
+            return Err(EmbedderError::InferenceFailed(format!(
+                "Unexpected tensor shape: expected 3 dimensions [batch, seq, dim], got {} dimensions",
+                shape.len()
+            )));
+        }
+        let embedding_dim = shape[2] as usize;
+        // Set or validate embedding dimension from model output.
+        // Lock-and-set the Mutex<Option<usize>> slot.
+        {
+            let mut guard = self.detected_dim.lock().unwrap_or_else(|p| p.into_inner());
+            match *guard {
+                Some(expected) if expected != embedding_dim => {
+                    return Err(EmbedderError::InferenceFailed(format!(
+                        "Embedding dimension changed: expected {expected}, got {embedding_dim}"
+                    )));
+                }
+                None => {
+                    *guard = Some(embedding_dim);
+                    tracing::info!(
+                        dim = embedding_dim,
+                        "Detected embedding dimension from model"
+                    );
+                }
+                _ => {} // matches expected — OK
+            }
+        }
+        if shape[0] as usize != batch_size {
+            return Err(EmbedderError::InferenceFailed(format!(
+                "Tensor batch size mismatch: expected {}, got {}",
+                batch_size, shape[0]
+            )));
+        }
+        // Reshape flat output into [batch, seq, dim] for pooling dispatch.
+        let hidden = Array3::from_shape_vec((batch_size, seq_len, embedding_dim), data.to_vec())
+            .map_err(|e| EmbedderError::InferenceFailed(format!("tensor reshape failed: {e}")))?;
+
+        // Dispatch on the configured pooling strategy. Each pooler returns
+        // an unnormalized per-batch vector; L2 normalization is applied
+        // uniformly after to keep the contract (unit-length embeddings)
+        // invariant across strategies.
+        let pooled_batch: Vec<Vec<f32>> = match self.model_config.pooling {
+            PoolingStrategy::Mean => mean_pool(&hidden, &attention_mask_arr, embedding_dim),
+            PoolingStrategy::Cls => cls_pool(&hidden),
+            PoolingStrategy::LastToken => last_token_pool(&hidden, &attention_mask_arr),
+            // Surface as a structured error rather than panic. Identity is
+            // intercepted by the 2D shortcut above — reaching here implies the
+            // ONNX model emitted 3D output AND configured Identity pooling, a
+            // config-shape mismatch. Error cleanly rather than crash the daemon.
+            PoolingStrategy::Identity => {
+                return Err(EmbedderError::InferenceFailed(
+                    "PoolingStrategy::Identity is not supported on 3D model outputs — \
+                     the ONNX model must already produce a 2D [batch, dim] tensor \
+                     for Identity pooling. Re-export with mean/cls/last-token pooling \
+                     baked in, or change the model_config pooling value."
+                        .to_string(),
+                ));
+            }
+        };
+
+        let results = pooled_batch
+            .into_iter()
+            .map(|v| Embedding::new(normalize_l2(v)))
+            .collect();
+
+        Ok(results)
+    }
+
+    /// Tokenizes `texts`, pads them to the longest in the batch (up to the
+    /// model's maximum length), runs inference, and returns the `output_name`
+    /// tensor flattened to f32 with the attention mask the batch ran under.
+    ///
+    /// # Errors
+    ///
+    /// Returns `EmbedderError::Tokenizer` if tokenization of the batch fails,
+    /// and `EmbedderError::InferenceFailed` if the model has no such output.
+    fn run_model(&self, texts: &[String], output_name: &str) -> Result<ModelOutput, EmbedderError> {
+        use ort::session::SessionInputValue;
+        use ort::value::Tensor;
+        use std::borrow::Cow;
+
         // Tokenize (lazy init tokenizer).
         // `encode_batch` takes `Vec<E>` where `E: Into<EncodeInput<'s>>`, and
         // `&str` satisfies that bound (`InputSequence: From<&'s str>`), so we
@@ -1096,8 +1305,8 @@ impl Embedder {
         let _inference = tracing::debug_span!("inference", max_len).entered();
         let outputs = session.run(inputs).map_err(ort_err)?;
 
-        // Get the configured output tensor: shape [batch, seq_len, dim]
-        let output_name = self.model_config.output_name.as_str();
+        // Get the requested output tensor: [batch, seq_len, dim], or
+        // [batch, dim] for already-pooled outputs.
         let output = outputs.get(output_name).ok_or_else(|| {
             EmbedderError::InferenceFailed(format!(
                 "ONNX model has no '{}' output. Available: {:?}",
@@ -1150,129 +1359,22 @@ impl Embedder {
                 }
             }
         };
-        let shape: &[i64] = &shape_vec;
-        let data: &[f32] = &data_vec;
-
-        let batch_size = texts.len();
-        let seq_len = max_len;
-
-        // PoolingStrategy::Identity: the ONNX output is already pooled to
-        // `[batch, dim]`. Skip the 3D reshape + pool dispatch and emit
-        // L2-normalized rows directly. Used by EmbeddingGemma's
-        // `sentence_embedding` output.
-        if self.model_config.pooling == PoolingStrategy::Identity {
-            if shape.len() != 2 {
-                return Err(EmbedderError::InferenceFailed(format!(
-                    "PoolingStrategy::Identity expects 2D [batch, dim] output; got {} dimensions",
-                    shape.len()
-                )));
-            }
-            if shape[0] as usize != batch_size {
-                return Err(EmbedderError::InferenceFailed(format!(
-                    "Tensor batch size mismatch: expected {}, got {}",
-                    batch_size, shape[0]
-                )));
-            }
-            let embedding_dim = shape[1] as usize;
-            {
-                // Lock-and-set the Mutex<Option<usize>> slot.
-                let mut guard = self.detected_dim.lock().unwrap_or_else(|p| p.into_inner());
-                match *guard {
-                    Some(expected) if expected != embedding_dim => {
-                        return Err(EmbedderError::InferenceFailed(format!(
-                            "Embedding dimension changed: expected {expected}, got {embedding_dim}"
-                        )));
-                    }
-                    None => {
-                        *guard = Some(embedding_dim);
-                        tracing::info!(
-                            dim = embedding_dim,
-                            "Detected embedding dimension from model (Identity pooling)"
-                        );
-                    }
-                    _ => {}
-                }
-            }
-            let results: Vec<Embedding> = (0..batch_size)
-                .map(|b| {
-                    let start = b * embedding_dim;
-                    let v = data[start..start + embedding_dim].to_vec();
-                    Embedding::new(normalize_l2(v))
-                })
-                .collect();
-            return Ok(results);
-        }
-
-        // Validate tensor shape: expect [batch_size, seq_len, dim]
-        if shape.len() != 3 {
-            return Err(EmbedderError::InferenceFailed(format!(
-                "Unexpected tensor shape: expected 3 dimensions [batch, seq, dim], got {} dimensions",
-                shape.len()
-            )));
-        }
-        let embedding_dim = shape[2] as usize;
-        // Set or validate embedding dimension from model output.
-        // Lock-and-set the Mutex<Option<usize>> slot.
-        {
-            let mut guard = self.detected_dim.lock().unwrap_or_else(|p| p.into_inner());
-            match *guard {
-                Some(expected) if expected != embedding_dim => {
-                    return Err(EmbedderError::InferenceFailed(format!(
-                        "Embedding dimension changed: expected {expected}, got {embedding_dim}"
-                    )));
-                }
-                None => {
-                    *guard = Some(embedding_dim);
-                    tracing::info!(
-                        dim = embedding_dim,
-                        "Detected embedding dimension from model"
-                    );
-                }
-                _ => {} // matches expected — OK
-            }
-        }
-        if shape[0] as usize != batch_size {
-            return Err(EmbedderError::InferenceFailed(format!(
-                "Tensor batch size mismatch: expected {}, got {}",
-                batch_size, shape[0]
-            )));
-        }
-        // Reshape flat output into [batch, seq, dim] for pooling dispatch.
-        let hidden = Array3::from_shape_vec((batch_size, seq_len, embedding_dim), data.to_vec())
-            .map_err(|e| EmbedderError::InferenceFailed(format!("tensor reshape failed: {e}")))?;
-
-        // Dispatch on the configured pooling strategy. Each pooler returns
-        // an unnormalized per-batch vector; L2 normalization is applied
-        // uniformly after to keep the contract (unit-length embeddings)
-        // invariant across strategies.
-        let pooled_batch: Vec<Vec<f32>> = match self.model_config.pooling {
-            PoolingStrategy::Mean => mean_pool(&hidden, &attention_mask_arr, embedding_dim),
-            PoolingStrategy::Cls => cls_pool(&hidden),
-            PoolingStrategy::LastToken => last_token_pool(&hidden, &attention_mask_arr),
-            // Surface as a structured error rather than panic. Identity is
-            // intercepted by the 2D shortcut above — reaching here implies the
-            // ONNX model emitted 3D output AND configured Identity pooling, a
-            // config-shape mismatch. Error cleanly rather than crash the daemon.
-            PoolingStrategy::Identity => {
-                return Err(EmbedderError::InferenceFailed(
-                    "PoolingStrategy::Identity is not supported on 3D model outputs — \
-                     the ONNX model must already produce a 2D [batch, dim] tensor \
-                     for Identity pooling. Re-export with mean/cls/last-token pooling \
-                     baked in, or change the model_config pooling value."
-                        .to_string(),
-                ));
-            }
-        };
-
-        let results = pooled_batch
-            .into_iter()
-            .map(|v| Embedding::new(normalize_l2(v)))
-            .collect();
-
-        Ok(results)
+        Ok(ModelOutput {
+            shape: shape_vec,
+            data: data_vec,
+            attention_mask: attention_mask_arr,
+        })
     }
 }
 
+/// One batch's output tensor, flattened to f32, with the attention mask the
+/// batch ran under.
+struct ModelOutput {
+    shape: Vec<i64>,
+    data: Vec<f32>,
+    attention_mask: Array2<i64>,
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -1656,6 +1758,24 @@ mod tests {
         assert!((pooled[0][1] - 3.0).abs() < 1e-6);
     }
 
+    #[test]
+    fn token_vectors_keep_masked_tokens_normalized() {
+        let hidden = make_hidden(vec![
+            vec![vec![3.0, 4.0], vec![0.0, 2.0], vec![9.0, 9.0]],
+            vec![vec![1.0, 0.0], vec![5.0, 5.0], vec![7.0, 7.0]],
+        ]);
+        let mask = mask_2d(vec![vec![1i64, 1, 0], vec![1, 1, 1]]);
+        let tokens = token_vectors(&hidden, &mask, 2);
+        // Padding dropped, each row unit length.
+        assert_eq!(tokens[0].len(), 4);
+        for (got, want) in tokens[0].iter().zip([0.6, 0.8, 0.0, 1.0]) {
+            assert!((got - want).abs() < 1e-6);
+        }
+        // Capped at max_tokens.
+        assert_eq!(tokens[1].len(), 4);
+        assert_eq!(&tokens[1][..2], &[1.0, 0.0]);
+    }
+
     #[test]
     fn mean_pool_zero_mask_returns_zero_vector() {
         let hidden = make_hidden(vec![vec![vec![5.0, 5.0], vec![6.0, 6.0]]]);
@@ -40,66 +40,78 @@ pub(crate) fn ensure_model(config: &ModelConfig) -> Result<(PathBuf, PathBuf), E
         tracing::warn!(dir = %dir.display(), "CQS_ONNX_DIR set but model files not found, falling back to HF download");
     }
 
-    use hf_hub::api::sync::ApiBuilder;
+    // Offline mode: resolve from the HF cache only, never the Hub. A cached
+    // external-data sidecar sits next to model.onnx in the same snapshot
+    // directory, so ORT finds it without a separate lookup.
+    let (model_path, tokenizer_path) = if crate::offline::enabled() {
+        let model_path = crate::offline::hf_cached(&config.repo, &config.onnx_path)?;
+        let tokenizer_path = crate::offline::hf_cached(&config.repo, &config.tokenizer_path)?;
+        tracing::info!(repo = %config.repo, "Offline mode: using cached model");
+        (model_path, tokenizer_path)
+    } else {
+        use hf_hub::api::sync::ApiBuilder;
 
-    // hf-hub defaults to max_retries=0 — a single transient ureq error
-    // (TLS handshake glitch, HTTP/2 reset, throttling) aborts the download
-    // with no retry. The Python `huggingface-cli` retries internally and
-    // succeeds, so the same file from the same server can fail in cqs and
-    // succeed in Python. 5 retries make >2 GB external-data downloads
-    // (Gemma, Qwen3) reliable: a silently-failed `model.onnx_data` fetch
-    // otherwise makes ORT panic at session init with "cannot get file size".
-    let api = ApiBuilder::from_env()
-        .with_retries(5)
-        .build()
-        .map_err(|e| EmbedderError::ModelDownload(e.to_string()))?;
-    let repo = api.model(config.repo.clone());
+        // hf-hub defaults to max_retries=0 — a single transient ureq error
+        // (TLS handshake glitch, HTTP/2 reset, throttling) aborts the download
+        // with no retry. The Python `huggingface-cli` retries internally and
+        // succeeds, so the same file from the same server can fail in cqs and
+        // succeed in Python. 5 retries make >2 GB external-data downloads
+        // (Gemma, Qwen3) reliable: a silently-failed `model.onnx_data` fetch
+        // otherwise makes ORT panic at session init with "cannot get file size".
+        let api = ApiBuilder::from_env()
+            .with_retries(5)
+            .build()
+            .map_err(|e| EmbedderError::ModelDownload(e.to_string()))?;
+        let repo = api.model(config.repo.clone());
 
-    let model_path = repo
-        .get(&config.onnx_path)
-        .map_err(|e| EmbedderError::ModelDownload(e.to_string()))?;
-    let tokenizer_path = repo
-        .get(&config.tokenizer_path)
-        .map_err(|e| EmbedderError::ModelDownload(e.to_string()))?;
+        let model_path = repo
+            .get(&config.onnx_path)
+            .map_err(|e| EmbedderError::ModelDownload(e.to_string()))?;
+        let tokenizer_path = repo
+            .get(&config.tokenizer_path)
+            .map_err(|e| EmbedderError::ModelDownload(e.to_string()))?;
 
-    // Fetch the ONNX external-data sidecar for models that exceed the 2GB
-    // protobuf limit. The Rust ONNX Runtime expects the .onnx_data file to
-    // sit next to model.onnx; without it, session init fails with
-    // "filesystem error: cannot get file size" when the graph references
-    // external tensors. Most presets (BGE, E5, etc.) ship a single
-    // self-contained model.onnx and do not have this file — the sidecar
-    // attempt returns a 404 wrapped in `ApiError::RequestError`. That's
-    // expected for self-contained models and gets silenced at debug level.
-    //
-    // Anything other than a clean 404 (network error, IoError,
-    // LockAcquisition, etc.) is unexpected: either the operator is on
-    // an external-data preset and the file genuinely couldn't be fetched
-    // (broken setup), or there's a transient that even retries didn't
-    // recover from. Either way the operator needs to see it — log at
-    // warn so it surfaces at the default RUST_LOG level. The pipeline
-    // continues; if ORT later panics with "cannot get file size", the
-    // warn line is the breadcrumb.
-    let external_data_path = format!("{}_data", config.onnx_path);
-    match repo.get(&external_data_path) {
-        Ok(_) => {} // Sidecar fetched (or already cached) — common path for Gemma / Qwen3.
-        Err(e) if is_likely_not_found(&e) => {
-            tracing::debug!(
-                file = %external_data_path,
-                error = %e,
-                "ONNX external-data sidecar not present in repo (expected for self-contained models)"
-            );
-        }
-        Err(e) => {
-            tracing::warn!(
-                file = %external_data_path,
-                error = %e,
-                "ONNX external-data sidecar fetch failed unexpectedly. \
-                 If the model exceeds 2GB, ORT will panic with 'cannot get file size' \
-                 at session init. Check network, HF auth, or run \
-                 `hf download <repo> --include='{external_data_path}'` manually."
-            );
+        // Fetch the ONNX external-data sidecar for models that exceed the 2GB
+        // protobuf limit. The Rust ONNX Runtime expects the .onnx_data file to
+        // sit next to model.onnx; without it, session init fails with
+        // "filesystem error: cannot get file size" when the graph references
+        // external tensors. Most presets (BGE, E5, etc.) ship a single
+        // self-contained model.onnx and do not have this file — the sidecar
+        // attempt returns a 404 wrapped in `ApiError::RequestError`. That's
+        // expected for self-contained models and gets silenced at debug level.
+        //
+        // Anything other than a clean 404 (network error, IoError,
+        // LockAcquisition, etc.) is unexpected: either the operator is on
+        // an external-data preset and the file genuinely couldn't be fetched
+        // (broken setup), or there's a transient that even retries didn't
+        // recover from. Either way the operator needs to see it — log at
+        // warn so it surfaces at the default RUST_LOG level. The pipeline
+        // continues; if ORT later panics with "cannot get file size", the
+        // warn line is the breadcrumb.
+        let external_data_path = format!("{}_data", config.onnx_path);
+        match repo.get(&external_data_path) {
+            Ok(_) => {} // Sidecar fetched (or already cached) — common path for Gemma / Qwen3.
+            Err(e) if is_likely_not_found(&e) => {
+                tracing::debug!(
+                    file = %external_data_path,
+                    error = %e,
+                    "ONNX external-data sidecar not present in repo (expected for self-contained models)"
+                );
+            }
+            Err(e) => {
+                tracing::warn!(
+                    file = %external_data_path,
+                    error = %e,
+                    "ONNX external-data sidecar fetch failed unexpectedly. \
+                     If the model exceeds 2GB, ORT will panic with 'cannot get file size' \
+                     at session init. Check network, HF auth, or run \
+                     `hf download <repo> --include='{external_data_path}'` manually."
+                );
+            }
         }
-    }
+
+        (model_path, tokenizer_path)
+    };
 
     // Verify checksums (skip if already verified via marker file)
     if !MODEL_BLAKE3.is_empty() || !TOKENIZER_BLAKE3.is_empty() {
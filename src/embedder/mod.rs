@@ -5,6 +5,7 @@ mod download;
 pub mod models;
 mod pooling;
 mod provider;
+mod windows;
 
 pub use models::{EmbeddingConfig, InputNames, ModelConfig, ModelInfo, PoolingStrategy};
 
@@ -18,7 +19,9 @@ pub const DEFAULT_DIM: usize = ModelConfig::DEFAULT_DIM;
 /// `&'static str` rather than `default_model().repo` (a `String`).
 pub const DEFAULT_MODEL_REPO: &str = ModelConfig::DEFAULT_REPO;
 
-pub(crate) use provider::{create_session, select_provider};
+pub(crate) use provider::create_session;
+pub use provider::select_provider;
+pub(crate) use windows::plan_windows;
 
 use thiserror::Error;
 
@@ -29,7 +32,7 @@ const TOKENIZER_BLAKE3: &str = "";
 pub use core::Embedder;
 pub(crate) use download::ensure_model;
 pub(crate) use pooling::{
-    cls_pool, last_token_pool, mean_pool, normalize_l2, pad_2d_i64_from_encodings,
+    cls_pool, last_token_pool, mean_pool, normalize_l2, pad_2d_i64_from_encodings, token_vectors,
     truncate_at_char_boundary,
 };
 
@@ -57,6 +60,9 @@ pub enum EmbedderError {
     /// the embedder + reranker boundary.
     #[error("Model download failed: {0}")]
     ModelDownload(String),
+    /// Offline mode refused a download (`offline = true` / `CQS_OFFLINE`).
+    #[error(transparent)]
+    Offline(#[from] crate::offline::OfflineError),
 }
 
 /// Route a stringified ORT message into
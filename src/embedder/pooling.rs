@@ -115,6 +115,29 @@ pub(crate) fn mean_pool(
         .collect()
 }
 
+/// Token vectors for late interaction: each batch item's masked positions,
+/// the first `max_tokens` of them, L2-normalized and concatenated
+/// (`tokens × dim` values). Not a pooler — nothing is reduced.
+pub(crate) fn token_vectors(
+    hidden: &Array3<f32>,
+    attention_mask: &Array2<i64>,
+    max_tokens: usize,
+) -> Vec<Vec<f32>> {
+    let (batch_size, seq_len, dim) = hidden.dim();
+    (0..batch_size)
+        .map(|i| {
+            let mut data = Vec::with_capacity(seq_len.min(max_tokens) * dim);
+            for j in (0..seq_len)
+                .filter(|&j| attention_mask.get([i, j]).is_some_and(|&m| m != 0))
+                .take(max_tokens)
+            {
+                data.extend(normalize_l2(hidden.slice(ndarray::s![i, j, ..]).to_vec()));
+            }
+            data
+        })
+        .collect()
+}
+
 /// CLS-pool: return the hidden state of the first token for each batch item.
 ///
 /// Used by some DistilBERT-derived embedders trained specifically for CLS
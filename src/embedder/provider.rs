@@ -251,7 +251,7 @@ static CACHED_PROVIDER: OnceCell<ExecutionProvider> = OnceCell::new();
 /// Select the best available execution provider (cached)
 /// Provider detection is expensive (checks CUDA/TensorRT availability).
 /// Result is cached in a static OnceCell for subsequent calls.
-pub(crate) fn select_provider() -> ExecutionProvider {
+pub fn select_provider() -> ExecutionProvider {
     *CACHED_PROVIDER.get_or_init(detect_provider)
 }
 
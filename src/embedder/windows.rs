@@ -0,0 +1,171 @@
+//! Content-defined window boundaries for chunks over the token limit.
+//!
+//! Fixed-stride windows cut at token counts, so one line inserted near the
+//! top of a long function shifts every later window and all of them are
+//! re-embedded. Here each cut is chosen by the text of the lines around it:
+//! among the line starts in the back half of a window, the one whose line
+//! hashes highest wins. An edit moves the candidate range by a few tokens,
+//! which rarely changes which line has the top hash, so the cuts fall on the
+//! same lines again within a window or two, and every window past that point
+//! is byte-identical to the old one — its canonical hash hits the embedding
+//! cache instead of the model. This is content-defined chunking (rsync,
+//! restic) at line granularity.
+
+use std::cmp::Reverse;
+
+/// Token ranges `[start, end)` of the windows over `text`.
+///
+/// `offsets` are the byte spans of the tokens in `text`, as the tokenizer
+/// reports them. Every window but the last holds between `max_tokens / 2`
+/// and `max_tokens` tokens and ends at the strongest line start in that
+/// range, or at `max_tokens` when the range holds no line start (one very
+/// long line). The next window starts at the first line start within
+/// `overlap` tokens before the cut, else exactly `overlap` tokens before
+/// it. The caller guarantees `overlap < max_tokens / 2`, so every step
+/// moves forward.
+pub(crate) fn plan_windows(
+    text: &str,
+    offsets: &[(usize, usize)],
+    max_tokens: usize,
+    overlap: usize,
+) -> Vec<(usize, usize)> {
+    let n = offsets.len();
+    if max_tokens == 0 || n == 0 {
+        return Vec::new();
+    }
+    if n <= max_tokens {
+        return vec![(0, n)];
+    }
+
+    let line_start: Vec<bool> = (0..n).map(|i| starts_line(text, offsets, i)).collect();
+    let min_tokens = (max_tokens / 2).max(1);
+
+    let mut windows = Vec::new();
+    let mut start = 0;
+    while n - start > max_tokens {
+        let (lo, hi) = (start + min_tokens, start + max_tokens);
+        // Ties go to the earlier line, so a run of identical lines still
+        // cuts deterministically.
+        let cut = (lo..=hi)
+            .filter(|&i| line_start[i])
+            .max_by_key(|&i| (strength(line_at(text, offsets[i].0)), Reverse(i)))
+            .unwrap_or(hi);
+        windows.push((start, cut));
+        let back = cut - overlap;
+        start = (back..cut).find(|&i| line_start[i]).unwrap_or(back);
+    }
+    windows.push((start, n));
+    windows
+}
+
+/// Whether token `i` is the first token on its line (indentation aside).
+fn starts_line(text: &str, offsets: &[(usize, usize)], i: usize) -> bool {
+    match text.get(..offsets[i].0) {
+        Some(before) => {
+            let before = before.trim_end_matches([' ', '\t']);
+            before.is_empty() || before.ends_with('\n')
+        }
+        None => false,
+    }
+}
+
+/// The trimmed text of the line containing byte `at`, from `at` on.
+fn line_at(text: &str, at: usize) -> &str {
+    let rest = text.get(at..).unwrap_or_default();
+    rest.split('\n').next().unwrap_or_default().trim()
+}
+
+/// How strongly a line attracts a cut: the head of its blake3 hash. Blank
+/// lines never win over text.
+fn strength(line: &str) -> u64 {
+    if line.is_empty() {
+        return 0;
+    }
+    let mut head = [0u8; 8];
+    head.copy_from_slice(&blake3::hash(line.as_bytes()).as_bytes()[..8]);
+    u64::from_le_bytes(head)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    /// One token per whitespace-separated word, with its byte span.
+    fn words(text: &str) -> Vec<(usize, usize)> {
+        let mut out = Vec::new();
+        let mut start = None;
+        for (i, c) in text.char_indices() {
+            match (c.is_whitespace(), start) {
+                (true, Some(s)) => {
+                    out.push((s, i));
+                    start = None;
+                }
+                (false, None) => start = Some(i),
+                _ => {}
+            }
+        }
+        if let Some(s) = start {
+            out.push((s, text.len()));
+        }
+        out
+    }
+
+    fn body(lines: impl Iterator<Item = String>) -> String {
+        lines.map(|l| format!("    {l}\n")).collect()
+    }
+
+    fn window_texts(text: &str, max_tokens: usize, overlap: usize) -> Vec<String> {
+        let offsets = words(text);
+        plan_windows(text, &offsets, max_tokens, overlap)
+            .into_iter()
+            .map(|(s, e)| text[offsets[s].0..offsets[e - 1].1].to_string())
+            .collect()
+    }
+
+    #[test]
+    fn short_text_is_one_window() {
+        let text = "fn f() { 1 }";
+        assert_eq!(plan_windows(text, &words(text), 64, 8), vec![(0, 5)]);
+        assert!(plan_windows(text, &words(text), 0, 0).is_empty());
+    }
+
+    #[test]
+    fn windows_cover_the_text_within_bounds() {
+        let text = body((0..300).map(|i| format!("let value_{i} = compute({i});")));
+        let offsets = words(&text);
+        let plan = plan_windows(&text, &offsets, 120, 16);
+        assert!(plan.len() > 1);
+        assert_eq!(plan.first().unwrap().0, 0);
+        assert_eq!(plan.last().unwrap().1, offsets.len());
+        for pair in plan.windows(2) {
+            let ((s0, e0), (s1, _)) = (pair[0], pair[1]);
+            assert!(e0 - s0 >= 60 && e0 - s0 <= 120, "window {s0}..{e0}");
+            assert!(s1 > s0 && s1 <= e0 && e0 - s1 <= 16, "overlap {s1}..{e0}");
+            // Both sides of the cut sit on line starts.
+            assert!(starts_line(&text, &offsets, e0));
+            assert!(starts_line(&text, &offsets, s1));
+        }
+    }
+
+    #[test]
+    fn an_edit_only_disturbs_nearby_windows() {
+        let lines: Vec<String> = (0..400)
+            .map(|i| format!("let value_{i} = compute({i});"))
+            .collect();
+        let before = window_texts(&body(lines.iter().cloned()), 120, 16);
+
+        let mut edited = lines.clone();
+        edited.insert(5, "let inserted = 1;".to_string());
+        let after = window_texts(&body(edited.into_iter()), 120, 16);
+
+        // Fixed-stride windows would share none past the edit.
+        let shared = after.iter().filter(|w| before.contains(w)).count();
+        assert!(
+            shared + 3 >= before.len(),
+            "{shared} of {} windows survived",
+            before.len()
+        );
+        assert_eq!(before.last(), after.last());
+        assert_eq!(before, window_texts(&body(lines.into_iter()), 120, 16));
+    }
+}
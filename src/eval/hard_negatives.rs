@@ -0,0 +1,295 @@
+//! Hard-negative mining for eval sets.
+//!
+//! A corpus where every wrong answer is obviously wrong inflates recall:
+//! the gold wins because nothing else competes. A hard negative is a chunk
+//! a keyword match would confuse with the gold — high identifier-token
+//! overlap with the gold's source — whose embedding says it is about
+//! something else (the `MergeSort` / `HeapSort` pairs in
+//! `tests/fixtures/eval_hard_go.go`).
+//!
+//! Candidates come from FTS probes on the gold's name tokens and the query's
+//! words, so mining costs a handful of keyword lookups per query rather than
+//! a scan of the index.
+
+use std::collections::HashSet;
+
+use crate::embedder::Embedding;
+use crate::eval::schema::{GoldChunk, HardNegative};
+use crate::store::{ChunkSummary, Store, StoreError};
+
+/// Default hard negatives kept per query.
+pub const DEFAULT_PER_QUERY: usize = 3;
+
+/// Default minimum identifier-token overlap with the gold's source.
+pub const DEFAULT_MIN_OVERLAP: f32 = 0.25;
+
+/// Default maximum cosine similarity to the gold. Above this a candidate is
+/// more likely an acceptable answer or a copy than a distractor.
+pub const DEFAULT_MAX_SIMILARITY: f32 = 0.85;
+
+/// FTS probes issued per query, and hits kept per probe.
+const MAX_PROBES: usize = 8;
+const HITS_PER_PROBE: usize = 25;
+
+/// Probe words too common in code and prose to narrow anything.
+const PROBE_STOPWORDS: &[&str] = &[
+    "the", "and", "for", "with", "that", "this", "from", "into", "get", "set", "new", "all", "not",
+    "are", "how", "what", "where", "which", "when", "function", "method", "code", "fn", "func",
+];
+
+/// Knobs for [`mine_hard_negatives`].
+#[derive(Debug, Clone, Copy)]
+pub struct MineOptions {
+    pub per_query: usize,
+    pub min_overlap: f32,
+    pub max_similarity: f32,
+}
+
+impl Default for MineOptions {
+    fn default() -> Self {
+        Self {
+            per_query: DEFAULT_PER_QUERY,
+            min_overlap: DEFAULT_MIN_OVERLAP,
+            max_similarity: DEFAULT_MAX_SIMILARITY,
+        }
+    }
+}
+
+/// The indexed chunk a gold label names: same origin and name, first by
+/// line (window 0 of a windowed function). `None` when the label is stale.
+pub fn resolve_gold<Mode>(
+    store: &Store<Mode>,
+    gold: &GoldChunk,
+) -> Result<Option<ChunkSummary>, StoreError> {
+    Ok(store
+        .get_chunks_by_origin(&gold.origin)?
+        .into_iter()
+        .find(|c| c.name == gold.name))
+}
+
+/// FTS probe words: the gold name's identifier parts, then the query's
+/// words, deduplicated, stopwords and short tokens dropped.
+fn probe_terms(gold_name: &str, query: &str) -> Vec<String> {
+    let text = format!("{gold_name} {query}");
+    let mut seen = HashSet::new();
+    crate::normalize_for_fts(&text)
+        .split_whitespace()
+        .filter(|t| t.len() >= 3 && !PROBE_STOPWORDS.contains(t))
+        .filter(|t| seen.insert(t.to_string()))
+        .take(MAX_PROBES)
+        .map(str::to_string)
+        .collect()
+}
+
+/// Mine hard negatives for one query. `exclude` holds `(origin, name)`
+/// pairs that must never be returned — the gold and any answer the set
+/// already accepts, plus negatives it already lists.
+pub fn mine_hard_negatives<Mode>(
+    store: &Store<Mode>,
+    query: &str,
+    gold: &ChunkSummary,
+    exclude: &HashSet<(String, String)>,
+    opts: &MineOptions,
+) -> Result<Vec<HardNegative>, StoreError> {
+    let _span = tracing::debug_span!("mine_hard_negatives", gold = %gold.name).entered();
+
+    let mut ids: Vec<String> = Vec::new();
+    let mut seen = HashSet::new();
+    for term in probe_terms(&gold.name, query) {
+        for id in store.search_fts(&term, HITS_PER_PROBE)? {
+            if id != gold.id && seen.insert(id.clone()) {
+                ids.push(id);
+            }
+        }
+    }
+    if ids.is_empty() {
+        return Ok(Vec::new());
+    }
+
+    let id_refs: Vec<&str> = ids.iter().map(String::as_str).collect();
+    let chunks = store.get_chunks_by_ids(&id_refs)?;
+    let mut want: Vec<&str> = id_refs.clone();
+    want.push(gold.id.as_str());
+    let embeddings = store.get_embeddings_by_ids(&want)?;
+    let Some(gold_emb) = embeddings.get(&gold.id) else {
+        tracing::debug!(gold = %gold.id, "Gold chunk has no embedding; skipping");
+        return Ok(Vec::new());
+    };
+
+    let candidates: Vec<(ChunkSummary, &Embedding)> = ids
+        .iter()
+        .filter_map(|id| Some((chunks.get(id)?.clone(), embeddings.get(id)?)))
+        .collect();
+    Ok(rank_negatives(gold, gold_emb, candidates, exclude, opts))
+}
+
+/// Score and filter candidates: lexically close (token overlap at least
+/// `min_overlap`), semantically apart (cosine at most `max_similarity`).
+/// Best first — most overlap, then least similar.
+fn rank_negatives(
+    gold: &ChunkSummary,
+    gold_emb: &Embedding,
+    candidates: Vec<(ChunkSummary, &Embedding)>,
+    exclude: &HashSet<(String, String)>,
+    opts: &MineOptions,
+) -> Vec<HardNegative> {
+    let gold_key = (crate::normalize_path(&gold.file), gold.name.clone());
+    let mut seen: HashSet<(String, String)> = HashSet::new();
+    let mut scored: Vec<HardNegative> = Vec::new();
+    for (c, emb) in candidates {
+        if !c.chunk_type.is_code() || c.parent_id.as_deref() == Some(gold.id.as_str()) {
+            continue;
+        }
+        let key = (crate::normalize_path(&c.file), c.name.clone());
+        if key == gold_key || exclude.contains(&key) || seen.contains(&key) {
+            continue;
+        }
+        let overlap = crate::dupes::token_overlap(&gold.content, &c.content);
+        if overlap < opts.min_overlap {
+            continue;
+        }
+        let Some(similarity) = crate::math::cosine_similarity(gold_emb.as_slice(), emb.as_slice())
+        else {
+            continue;
+        };
+        if similarity > opts.max_similarity {
+            continue;
+        }
+        seen.insert(key.clone());
+        scored.push(HardNegative {
+            name: key.1,
+            origin: key.0,
+            line_start: c.line_start,
+            lexical_overlap: Some(overlap),
+            similarity: Some(similarity),
+        });
+    }
+    scored.sort_by(|a, b| {
+        b.lexical_overlap
+            .partial_cmp(&a.lexical_overlap)
+            .unwrap_or(std::cmp::Ordering::Equal)
+            .then_with(|| {
+                a.similarity
+                    .partial_cmp(&b.similarity)
+                    .unwrap_or(std::cmp::Ordering::Equal)
+            })
+    });
+    scored.truncate(opts.per_query);
+    scored
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::language::{ChunkType, Language};
+    use std::path::PathBuf;
+
+    fn chunk(name: &str, file: &str, content: &str) -> ChunkSummary {
+        ChunkSummary {
+            id: format!("{file}:{name}"),
+            file: PathBuf::from(file),
+            language: Language::Go,
+            chunk_type: ChunkType::Function,
+            name: name.to_string(),
+            signature: String::new(),
+            content: content.to_string(),
+            doc: None,
+            line_start: 1,
+            line_end: 10,
+            content_hash: String::new(),
+            window_idx: None,
+            parent_id: None,
+            parent_type_name: None,
+            parser_version: 0,
+            vendored: false,
+            deprecated: false,
+            boilerplate: None,
+        }
+    }
+
+    /// Unit vector at `deg` degrees in the plane — cosine to `unit(0)` is
+    /// `cos(deg)`.
+    fn unit(deg: f32) -> Embedding {
+        let r = deg.to_radians();
+        Embedding::new(vec![r.cos(), r.sin()])
+    }
+
+    #[test]
+    fn probe_terms_split_identifiers_and_drop_stopwords() {
+        assert_eq!(
+            probe_terms("MergeSort", "sort the slice with merge"),
+            vec!["merge", "sort", "slice"]
+        );
+    }
+
+    #[test]
+    fn rank_negatives_keeps_lexical_lookalikes_that_embed_apart() {
+        let gold = chunk(
+            "MergeSort",
+            "sort.go",
+            "func MergeSort(arr []int) []int { left right arr mid len }",
+        );
+        let gold_emb = unit(0.0);
+        let heap = chunk(
+            "HeapSort",
+            "sort.go",
+            "func HeapSort(arr []int) { arr len heapify }",
+        );
+        let near_copy = chunk(
+            "MergeSortCopy",
+            "copy.go",
+            "func MergeSortCopy(arr []int) []int { left right arr mid len }",
+        );
+        let unrelated = chunk(
+            "ParseURL",
+            "url.go",
+            "func ParseURL(s string) { scheme host }",
+        );
+        let accepted = chunk(
+            "MergeSortInPlace",
+            "sort.go",
+            "func MergeSortInPlace(arr []int) { left right arr mid len }",
+        );
+        let (e_heap, e_copy, e_url, e_acc) = (unit(60.0), unit(5.0), unit(80.0), unit(70.0));
+        let exclude: HashSet<(String, String)> =
+            [("sort.go".to_string(), "MergeSortInPlace".to_string())].into();
+
+        let out = rank_negatives(
+            &gold,
+            &gold_emb,
+            vec![
+                (heap, &e_heap),
+                (near_copy, &e_copy),
+                (unrelated, &e_url),
+                (accepted, &e_acc),
+            ],
+            &exclude,
+            &MineOptions::default(),
+        );
+        let names: Vec<&str> = out.iter().map(|n| n.name.as_str()).collect();
+        assert_eq!(names, vec!["HeapSort"]);
+        assert!(out[0].similarity.unwrap() < 0.51);
+        assert!(out[0].lexical_overlap.unwrap() >= DEFAULT_MIN_OVERLAP);
+    }
+
+    #[test]
+    fn rank_negatives_orders_by_overlap_and_caps() {
+        let gold = chunk("a", "a.go", "x y z w");
+        let gold_emb = unit(0.0);
+        let two = chunk("two", "b.go", "x y q r");
+        let three = chunk("three", "c.go", "x y z r");
+        let e = unit(90.0);
+        let out = rank_negatives(
+            &gold,
+            &gold_emb,
+            vec![(two, &e), (three, &e)],
+            &HashSet::new(),
+            &MineOptions {
+                per_query: 1,
+                ..MineOptions::default()
+            },
+        );
+        assert_eq!(out.len(), 1);
+        assert_eq!(out[0].name, "three");
+    }
+}
@@ -0,0 +1,228 @@
+//! Eval run history — one row per `cqs eval` run, in SQLite.
+//!
+//! Quality (R@K) and cost (per-query latency percentiles, peak RSS) land in
+//! the same row, so a performance regression shows up next to the relevance
+//! numbers of the run that introduced it. The database lives beside the
+//! index (`.cqs/eval_history.db`) rather than inside it so a reindex or a
+//! slot promote never drops the runs it is meant to be compared against.
+
+use std::path::Path;
+use std::sync::Arc;
+
+use thiserror::Error;
+
+use crate::cache::CacheError;
+
+/// File name of the history database inside the project `.cqs/` directory.
+pub const EVAL_HISTORY_FILENAME: &str = "eval_history.db";
+
+#[derive(Debug, Error)]
+pub enum EvalHistoryError {
+    #[error("Eval history database error: {0}")]
+    Database(#[from] sqlx::Error),
+    #[error("Failed to open eval history: {0}")]
+    Open(#[from] CacheError),
+}
+
+/// Configuration and results of one eval run.
+#[derive(Debug, Clone, PartialEq, serde::Serialize)]
+pub struct EvalRun {
+    pub query_file: String,
+    /// `--category` filter, when the run was restricted to one category
+    pub category: Option<String>,
+    pub limit: usize,
+    /// Reranker mode (`none`, `onnx`)
+    pub reranker: String,
+    pub index_model: String,
+    pub cqs_version: String,
+    /// Scored queries
+    pub n: usize,
+    pub r_at_1: f64,
+    pub r_at_5: f64,
+    pub r_at_20: f64,
+    pub elapsed_secs: f64,
+    pub latency_p50_ms: Option<f64>,
+    pub latency_p95_ms: Option<f64>,
+    pub peak_rss_bytes: Option<u64>,
+}
+
+/// A recorded run.
+#[derive(Debug, Clone, serde::Serialize)]
+pub struct EvalRunRow {
+    pub id: i64,
+    /// Unix seconds when the run was recorded
+    pub recorded_at: i64,
+    #[serde(flatten)]
+    pub run: EvalRun,
+}
+
+/// Handle on the eval history database.
+pub struct EvalHistory {
+    pool: sqlx::SqlitePool,
+    rt: Arc<tokio::runtime::Runtime>,
+}
+
+impl EvalHistory {
+    /// Open or create the history database at `path`.
+    pub fn open(path: &Path) -> Result<Self, EvalHistoryError> {
+        let _span = tracing::info_span!("eval_history_open", path = %path.display()).entered();
+        let (pool, rt) = crate::cache::connect_cache_pool(path, 15_000, None, |pool| async move {
+            sqlx::query(
+                "CREATE TABLE IF NOT EXISTS eval_runs (
+                    id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    recorded_at INTEGER NOT NULL DEFAULT (unixepoch()),
+                    query_file TEXT NOT NULL,
+                    category TEXT,
+                    result_limit INTEGER NOT NULL,
+                    reranker TEXT NOT NULL,
+                    index_model TEXT NOT NULL,
+                    cqs_version TEXT NOT NULL,
+                    n INTEGER NOT NULL,
+                    r_at_1 REAL NOT NULL,
+                    r_at_5 REAL NOT NULL,
+                    r_at_20 REAL NOT NULL,
+                    elapsed_secs REAL NOT NULL,
+                    latency_p50_ms REAL,
+                    latency_p95_ms REAL,
+                    peak_rss_bytes INTEGER
+                )",
+            )
+            .execute(&pool)
+            .await?;
+            sqlx::query(
+                "CREATE INDEX IF NOT EXISTS idx_eval_runs_file
+                 ON eval_runs (query_file, recorded_at)",
+            )
+            .execute(&pool)
+            .await?;
+            Ok(())
+        })?;
+        Ok(Self { pool, rt })
+    }
+
+    /// Append a run and return its id.
+    pub fn record(&self, run: &EvalRun) -> Result<i64, EvalHistoryError> {
+        let _span =
+            tracing::info_span!("eval_history_record", query_file = %run.query_file).entered();
+        self.rt.block_on(async {
+            let result = sqlx::query(
+                "INSERT INTO eval_runs (
+                    query_file, category, result_limit, reranker, index_model,
+                    cqs_version, n, r_at_1, r_at_5, r_at_20, elapsed_secs,
+                    latency_p50_ms, latency_p95_ms, peak_rss_bytes
+                 ) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13, ?14)",
+            )
+            .bind(&run.query_file)
+            .bind(&run.category)
+            .bind(run.limit as i64)
+            .bind(&run.reranker)
+            .bind(&run.index_model)
+            .bind(&run.cqs_version)
+            .bind(run.n as i64)
+            .bind(run.r_at_1)
+            .bind(run.r_at_5)
+            .bind(run.r_at_20)
+            .bind(run.elapsed_secs)
+            .bind(run.latency_p50_ms)
+            .bind(run.latency_p95_ms)
+            .bind(run.peak_rss_bytes.map(|b| b.min(i64::MAX as u64) as i64))
+            .execute(&self.pool)
+            .await?;
+            Ok(result.last_insert_rowid())
+        })
+    }
+
+    /// The `limit` most recent runs, newest first, optionally restricted to
+    /// one query file.
+    pub fn recent(
+        &self,
+        query_file: Option<&str>,
+        limit: usize,
+    ) -> Result<Vec<EvalRunRow>, EvalHistoryError> {
+        let _span = tracing::info_span!("eval_history_recent", ?query_file, limit).entered();
+        self.rt.block_on(async {
+            use sqlx::Row;
+            let rows = sqlx::query(
+                "SELECT id, recorded_at, query_file, category, result_limit, reranker,
+                        index_model, cqs_version, n, r_at_1, r_at_5, r_at_20,
+                        elapsed_secs, latency_p50_ms, latency_p95_ms, peak_rss_bytes
+                 FROM eval_runs
+                 WHERE ?1 IS NULL OR query_file = ?1
+                 ORDER BY id DESC
+                 LIMIT ?2",
+            )
+            .bind(query_file)
+            .bind(limit.min(i64::MAX as usize) as i64)
+            .fetch_all(&self.pool)
+            .await?;
+            Ok(rows
+                .into_iter()
+                .map(|row| EvalRunRow {
+                    id: row.get("id"),
+                    recorded_at: row.get("recorded_at"),
+                    run: EvalRun {
+                        query_file: row.get("query_file"),
+                        category: row.get("category"),
+                        limit: row.get::<i64, _>("result_limit").max(0) as usize,
+                        reranker: row.get("reranker"),
+                        index_model: row.get("index_model"),
+                        cqs_version: row.get("cqs_version"),
+                        n: row.get::<i64, _>("n").max(0) as usize,
+                        r_at_1: row.get("r_at_1"),
+                        r_at_5: row.get("r_at_5"),
+                        r_at_20: row.get("r_at_20"),
+                        elapsed_secs: row.get("elapsed_secs"),
+                        latency_p50_ms: row.get("latency_p50_ms"),
+                        latency_p95_ms: row.get("latency_p95_ms"),
+                        peak_rss_bytes: row
+                            .get::<Option<i64>, _>("peak_rss_bytes")
+                            .map(|b| b.max(0) as u64),
+                    },
+                })
+                .collect())
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn run(file: &str, r1: f64, p95: Option<f64>) -> EvalRun {
+        EvalRun {
+            query_file: file.to_string(),
+            category: None,
+            limit: 20,
+            reranker: "none".to_string(),
+            index_model: "m".to_string(),
+            cqs_version: "1.0.0".to_string(),
+            n: 10,
+            r_at_1: r1,
+            r_at_5: 0.5,
+            r_at_20: 0.9,
+            elapsed_secs: 2.0,
+            latency_p50_ms: p95.map(|p| p / 2.0),
+            latency_p95_ms: p95,
+            peak_rss_bytes: Some(1 << 30),
+        }
+    }
+
+    #[test]
+    fn record_and_read_back_newest_first() {
+        let dir = tempfile::tempdir().unwrap();
+        let history = EvalHistory::open(&dir.path().join(EVAL_HISTORY_FILENAME)).unwrap();
+        history.record(&run("a.json", 0.1, Some(40.0))).unwrap();
+        history.record(&run("b.json", 0.2, None)).unwrap();
+        let id = history.record(&run("a.json", 0.3, Some(55.0))).unwrap();
+
+        let all = history.recent(None, 10).unwrap();
+        assert_eq!(all.len(), 3);
+        assert_eq!(all[0].id, id);
+        assert_eq!(all[0].run, run("a.json", 0.3, Some(55.0)));
+        assert_eq!(all[1].run.latency_p95_ms, None);
+
+        let a = history.recent(Some("a.json"), 1).unwrap();
+        assert_eq!(a.len(), 1);
+        assert_eq!(a[0].run.r_at_1, 0.3);
+    }
+}
@@ -6,4 +6,6 @@
 //! it needs to be added; downstream call sites borrow these types via
 //! `cqs::eval::schema::*`.
 
+pub mod hard_negatives;
+pub mod history;
 pub mod schema;
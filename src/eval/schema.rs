@@ -15,17 +15,32 @@
 //!   3. Bump the round-trip test in `tests/eval_test.rs` to assert the
 //!      new field's deserialization invariants.
 
+use std::collections::BTreeMap;
+
 use serde::{Deserialize, Serialize};
 
 /// Top-level container for an eval query set as serialized to JSON.
 ///
-/// The `queries` array is the only field the runner reads; envelope
-/// metadata (`schema_version`, `n`, `category_counts`, `tier_counts`,
-/// `split`, `created_at`) is intentionally not modeled here so a future
-/// eval generator can extend the envelope without breaking the runner.
+/// The runner reads `queries` and the optional `category_weights`; other
+/// envelope metadata (`schema_version`, `n`, `category_counts`,
+/// `tier_counts`, `split`, `created_at`) is intentionally not modeled here
+/// so a future eval generator can extend the envelope without breaking the
+/// runner.
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct QuerySet {
     pub queries: Vec<EvalQuery>,
+    /// Relative weight per `category` for the weighted score, e.g.
+    /// `{"concept": 2.0, "api-lookup": 1.0}`. Categories not listed weigh
+    /// 1.0. Empty = no weighted score is reported.
+    #[serde(default, skip_serializing_if = "BTreeMap::is_empty")]
+    pub category_weights: BTreeMap<String, f64>,
+}
+
+impl QuerySet {
+    /// Weight of `category` in the weighted score.
+    pub fn weight_for(&self, category: &str) -> f64 {
+        self.category_weights.get(category).copied().unwrap_or(1.0)
+    }
 }
 
 /// One eval query with its expected gold chunk.
@@ -44,6 +59,10 @@ pub struct QuerySet {
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct EvalQuery {
     pub query: String,
+    /// What kind of question the query asks; results are broken down by it.
+    /// v3 sets use router names (`identifier_lookup`, `multi_step`, …);
+    /// hand-authored sets typically use `api-lookup`, `concept`, `bugfix`
+    /// and `cross-file`. Missing = `uncategorized`.
     #[serde(default)]
     pub category: Option<String>,
     #[serde(default)]
@@ -85,6 +104,23 @@ pub struct EvalQuery {
         skip_serializing_if = "crate::serde_helpers::is_false"
     )]
     pub unresolved: bool,
+    /// Chunks that share the gold's vocabulary but not its meaning, mined by
+    /// `cqs eval mine-negatives`. The runner reports how often one of them
+    /// outranks the gold; they never affect R@K.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub hard_negatives: Vec<HardNegative>,
+    /// Natural language the query is written in (`en`, `de`, `ja`, …), for
+    /// non-English query sets. Results are broken down by it when a set
+    /// mixes languages. Missing = `en`.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub query_language: Option<String>,
+}
+
+impl EvalQuery {
+    /// [`Self::query_language`], defaulting to `en`.
+    pub fn query_language(&self) -> &str {
+        self.query_language.as_deref().unwrap_or("en")
+    }
 }
 
 /// The expected matching chunk. `origin` is the file path as indexed.
@@ -107,6 +143,21 @@ pub struct GoldChunk {
     pub language: Option<String>,
 }
 
+/// A wrong answer that looks right to a keyword match. Matched like a gold
+/// chunk, on `(origin, name)`.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct HardNegative {
+    pub name: String,
+    pub origin: String,
+    pub line_start: u32,
+    /// Identifier-token overlap with the gold chunk's source, in [0, 1]
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub lexical_overlap: Option<f32>,
+    /// Embedding cosine similarity to the gold chunk at mining time
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub similarity: Option<f32>,
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -158,4 +209,18 @@ mod tests {
         assert!(set.queries[0].gold_chunk.is_none());
         assert!(set.queries[0].category.is_none());
     }
+
+    /// `category_weights` is optional; unlisted categories weigh 1.0.
+    #[test]
+    fn test_category_weights_default_to_one() {
+        let raw = r#"{
+            "category_weights": {"concept": 2.0},
+            "queries": [{"query": "why retry", "category": "concept"}]
+        }"#;
+        let set: QuerySet = serde_json::from_str(raw).unwrap();
+        assert_eq!(set.weight_for("concept"), 2.0);
+        assert_eq!(set.weight_for("api-lookup"), 1.0);
+        let bare: QuerySet = serde_json::from_str(r#"{"queries": []}"#).unwrap();
+        assert!(bare.category_weights.is_empty());
+    }
 }
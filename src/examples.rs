@@ -0,0 +1,487 @@
+//! Usage examples of an API — "show me how this is called"
+//!
+//! Semantic search ranks chunks by how well they match a description. An
+//! example wants something else: a real call of a known symbol, in
+//! surroundings that show how it is meant to be used. Call sites come from
+//! the call graph; each is scored on the caller it sits in:
+//!
+//! - **Error handling** next to the call (`?`, `if err != nil`, `catch`,
+//!   `.map_err(`, …) — the part a copy-paste most often gets wrong.
+//! - **Result used** — bound or returned rather than discarded.
+//! - **Qualifier match** — for `http.Client.Do`, the caller mentions
+//!   `client` or `http` (any case), so a same-named method on another type
+//!   ranks below.
+//! - **Compact caller** — short enough to show whole.
+//! - **Documented caller.**
+//!
+//! Test callers are dropped unless asked for (and then rank below the rest);
+//! non-call edges (doc references) never count. Selection is greedy with a
+//! per-file penalty, so the top examples come from different files.
+
+use std::collections::{HashMap, HashSet};
+use std::path::PathBuf;
+
+use crate::parser::{CallEdgeKind, Language};
+use crate::store::{ChunkSummary, Store, StoreError};
+
+/// Default number of examples returned.
+pub const DEFAULT_EXAMPLE_LIMIT: usize = 5;
+
+/// Lines shown on each side of the call when the caller is too long to
+/// show whole.
+pub const DEFAULT_EXAMPLE_CONTEXT: usize = 6;
+
+/// Callers up to this many lines are shown whole, and count as compact.
+const WHOLE_CALLER_LINES: usize = 30;
+
+/// Lines after the call searched for error handling; one before covers a
+/// `try` opened just above it.
+const ERROR_LINES_AFTER: usize = 4;
+
+/// Signal weights. Error handling is what a reader most needs to see.
+const ERROR_WEIGHT: f32 = 0.4;
+const QUALIFIER_WEIGHT: f32 = 0.25;
+const COMPACT_WEIGHT: f32 = 0.15;
+const RESULT_WEIGHT: f32 = 0.1;
+const DOC_WEIGHT: f32 = 0.1;
+/// Heuristic edges (macro bodies, function pointers) may not be calls at all.
+const HEURISTIC_EDGE_PENALTY: f32 = 0.2;
+const TEST_PENALTY: f32 = 0.5;
+/// Per example already picked from the same file.
+const SAME_FILE_PENALTY: f32 = 0.2;
+
+/// Substrings that mark error handling on or right after a call line.
+const ERROR_MARKERS: &[&str] = &[
+    "?;",
+    "?)",
+    "?.",
+    "? {",
+    "err != nil",
+    "err == nil",
+    "catch",
+    "except",
+    "rescue",
+    "try {",
+    "try:",
+    ".map_err(",
+    ".context(",
+    ".with_context(",
+    ".unwrap_or",
+    ".ok_or",
+    "Err(",
+    "if let Ok",
+    "if let Some",
+    ".catch(",
+    "throws",
+];
+
+/// Why an example ranked where it did.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, serde::Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum ExampleSignal {
+    HandlesErrors,
+    ResultUsed,
+    QualifierMatch,
+    Compact,
+    Documented,
+    Test,
+}
+
+/// One call site picked by [`find_examples`].
+#[derive(Debug, Clone, serde::Serialize)]
+pub struct UsageExample {
+    /// Function the call sits in
+    pub caller: String,
+    #[serde(serialize_with = "crate::serialize_path_normalized")]
+    pub file: PathBuf,
+    pub language: Language,
+    /// First line of the caller
+    pub line_start: u32,
+    pub call_line: u32,
+    pub score: f32,
+    pub signals: Vec<ExampleSignal>,
+    /// Line number of the snippet's first line
+    pub snippet_start: u32,
+    /// The caller whole, or its signature, `...`, and the lines around the
+    /// call; common indentation removed
+    pub snippet: String,
+}
+
+/// What [`find_examples`] found.
+#[derive(Debug, Clone, Default, serde::Serialize)]
+pub struct ExampleSet {
+    pub examples: Vec<UsageExample>,
+    /// Distinct call sites of the symbol, before ranking
+    pub call_sites: usize,
+    /// Call sites left out because they sit in tests
+    pub tests_skipped: usize,
+}
+
+/// Knobs for [`find_examples`].
+#[derive(Debug, Clone, Copy)]
+pub struct ExampleOptions {
+    /// Maximum examples returned
+    pub limit: usize,
+    /// Keep call sites in tests (ranked below the rest)
+    pub include_tests: bool,
+    /// Lines on each side of the call in a windowed snippet
+    pub context: usize,
+}
+
+impl Default for ExampleOptions {
+    fn default() -> Self {
+        Self {
+            limit: DEFAULT_EXAMPLE_LIMIT,
+            include_tests: false,
+            context: DEFAULT_EXAMPLE_CONTEXT,
+        }
+    }
+}
+
+/// Split `http.Client.Do`, `Client::do_request`, or `Foo#bar` into its
+/// qualifiers and the called name.
+pub fn split_symbol(symbol: &str) -> (Vec<&str>, &str) {
+    let mut parts: Vec<&str> = symbol
+        .split(['.', ':', '#'])
+        .filter(|p| !p.is_empty())
+        .collect();
+    let name = parts.pop().unwrap_or("");
+    (parts, name)
+}
+
+/// Find and rank usage examples of `symbol`.
+pub fn find_examples<Mode>(
+    store: &Store<Mode>,
+    symbol: &str,
+    opts: &ExampleOptions,
+) -> Result<ExampleSet, StoreError> {
+    let _span = tracing::info_span!("find_examples", symbol, limit = opts.limit).entered();
+    let (qualifiers, name) = split_symbol(symbol.trim());
+    if name.is_empty() || opts.limit == 0 {
+        return Ok(ExampleSet::default());
+    }
+
+    // Call edges record a bare name, or `Type::name` when the receiver was
+    // resolved at parse time; ask for both.
+    let mut sites = store.get_callers_with_context(name)?;
+    if let Some(owner) = qualifiers.last() {
+        sites.extend(store.get_callers_with_context(&format!("{owner}::{name}"))?);
+    }
+    let mut seen = HashSet::new();
+    sites.retain(|s| {
+        s.edge_kind != CallEdgeKind::DocReference && seen.insert((s.file.clone(), s.call_line))
+    });
+    let call_sites = sites.len();
+
+    let origins: Vec<String> = sites
+        .iter()
+        .map(|s| s.file.to_string_lossy().into_owned())
+        .collect::<HashSet<_>>()
+        .into_iter()
+        .collect();
+    let origin_refs: Vec<&str> = origins.iter().map(String::as_str).collect();
+    let chunks_by_origin = store.get_chunks_by_origins_batch(&origin_refs)?;
+
+    let mut tests_skipped = 0;
+    let mut candidates = Vec::new();
+    for site in &sites {
+        let file = site.file.to_string_lossy();
+        let is_test = crate::is_test_chunk(&site.name, &file);
+        if is_test && !opts.include_tests {
+            tests_skipped += 1;
+            continue;
+        }
+        let Some(chunk) = chunks_by_origin
+            .get(file.as_ref())
+            .and_then(|chunks| enclosing_chunk(chunks, &site.name, site.call_line))
+        else {
+            tracing::debug!(file = %file, caller = %site.name, "Call site has no indexed caller chunk");
+            continue;
+        };
+        let (score, signals) = score_call_site(chunk, site.call_line, name, &qualifiers, is_test);
+        let score = if site.edge_kind == CallEdgeKind::Call {
+            score
+        } else {
+            score - HEURISTIC_EDGE_PENALTY
+        };
+        let (snippet_start, snippet) = snippet_for(chunk, site.call_line, opts.context);
+        candidates.push(UsageExample {
+            caller: site.name.clone(),
+            file: site.file.clone(),
+            language: chunk.language,
+            line_start: chunk.line_start,
+            call_line: site.call_line,
+            score,
+            signals,
+            snippet_start,
+            snippet,
+        });
+    }
+
+    Ok(ExampleSet {
+        examples: pick_diverse(candidates, opts.limit),
+        call_sites,
+        tests_skipped,
+    })
+}
+
+/// The narrowest chunk named `caller` whose lines cover `call_line` (a long
+/// function is stored as several windows).
+fn enclosing_chunk<'a>(
+    chunks: &'a [ChunkSummary],
+    caller: &str,
+    call_line: u32,
+) -> Option<&'a ChunkSummary> {
+    chunks
+        .iter()
+        .filter(|c| c.name == caller && (c.line_start..=c.line_end).contains(&call_line))
+        .min_by_key(|c| c.line_end - c.line_start)
+}
+
+/// Score one call site on its caller chunk.
+fn score_call_site(
+    chunk: &ChunkSummary,
+    call_line: u32,
+    name: &str,
+    qualifiers: &[&str],
+    is_test: bool,
+) -> (f32, Vec<ExampleSignal>) {
+    let lines: Vec<&str> = chunk.content.lines().collect();
+    let call_idx =
+        (call_line.saturating_sub(chunk.line_start) as usize).min(lines.len().saturating_sub(1));
+    let call_text = lines.get(call_idx).copied().unwrap_or("");
+    let near_call =
+        &lines[call_idx.saturating_sub(1)..(call_idx + ERROR_LINES_AFTER + 1).min(lines.len())];
+
+    let mut score = 0.0;
+    let mut signals = Vec::new();
+    let mut signal = |hit: bool, weight: f32, s: ExampleSignal| {
+        if hit {
+            score += weight;
+            signals.push(s);
+        }
+    };
+    signal(
+        near_call
+            .iter()
+            .any(|l| ERROR_MARKERS.iter().any(|m| l.contains(m))),
+        ERROR_WEIGHT,
+        ExampleSignal::HandlesErrors,
+    );
+    signal(
+        result_used(call_text, name),
+        RESULT_WEIGHT,
+        ExampleSignal::ResultUsed,
+    );
+    let content = chunk.content.to_lowercase();
+    signal(
+        qualifiers
+            .iter()
+            .any(|q| content.contains(&q.to_lowercase())),
+        QUALIFIER_WEIGHT,
+        ExampleSignal::QualifierMatch,
+    );
+    signal(
+        lines.len() <= WHOLE_CALLER_LINES,
+        COMPACT_WEIGHT,
+        ExampleSignal::Compact,
+    );
+    signal(chunk.doc.is_some(), DOC_WEIGHT, ExampleSignal::Documented);
+    signal(is_test, -TEST_PENALTY, ExampleSignal::Test);
+    (score, signals)
+}
+
+/// Whether the call's value is bound, returned, or passed on: something
+/// other than whitespace or a receiver chain precedes the name on its line.
+fn result_used(call_text: &str, name: &str) -> bool {
+    let Some(pos) = call_text.find(name) else {
+        return false;
+    };
+    let before = call_text[..pos].trim_start();
+    before.contains('=')
+        || before.starts_with("return")
+        || before.starts_with("yield")
+        || before.contains('(')
+}
+
+/// `(first line number, text)` of the snippet for a call in `chunk`: the
+/// whole caller when it is short, else its first line, `...`, and
+/// `context` lines either side of the call.
+fn snippet_for(chunk: &ChunkSummary, call_line: u32, context: usize) -> (u32, String) {
+    let lines: Vec<&str> = chunk.content.lines().collect();
+    if lines.len() <= WHOLE_CALLER_LINES {
+        return (chunk.line_start, dedent(&lines).join("\n"));
+    }
+    let call_idx = (call_line.saturating_sub(chunk.line_start) as usize).min(lines.len() - 1);
+    let start = call_idx.saturating_sub(context);
+    let end = (call_idx + context + 1).min(lines.len());
+    let mut shown: Vec<&str> = Vec::with_capacity(end - start + 2);
+    if start > 0 {
+        shown.push(lines[0]);
+    }
+    shown.extend_from_slice(&lines[start..end]);
+    let mut text = dedent(&shown);
+    if start > 1 {
+        text.insert(1, "...".to_string());
+    }
+    (chunk.line_start + start as u32, text.join("\n"))
+}
+
+/// Strip the indentation every non-blank line shares.
+fn dedent(lines: &[&str]) -> Vec<String> {
+    let indent = lines
+        .iter()
+        .filter(|l| !l.trim().is_empty())
+        .map(|l| l.len() - l.trim_start().len())
+        .min()
+        .unwrap_or(0);
+    lines
+        .iter()
+        .map(|l| l.get(indent..).unwrap_or(l.trim_start()).to_string())
+        .collect()
+}
+
+/// Take the best `limit` candidates, each pick lowering the score of the
+/// rest from the same file.
+fn pick_diverse(mut candidates: Vec<UsageExample>, limit: usize) -> Vec<UsageExample> {
+    let mut per_file: HashMap<PathBuf, usize> = HashMap::new();
+    let mut picked = Vec::new();
+    while picked.len() < limit && !candidates.is_empty() {
+        let adjusted = |c: &UsageExample| {
+            c.score - SAME_FILE_PENALTY * per_file.get(&c.file).copied().unwrap_or(0) as f32
+        };
+        let best = candidates
+            .iter()
+            .enumerate()
+            .max_by(|(_, a), (_, b)| {
+                adjusted(a)
+                    .total_cmp(&adjusted(b))
+                    // Ties go to the earlier file and line.
+                    .then_with(|| b.file.cmp(&a.file))
+                    .then_with(|| b.call_line.cmp(&a.call_line))
+            })
+            .map(|(i, _)| i)
+            .expect("candidates is non-empty");
+        let example = candidates.swap_remove(best);
+        *per_file.entry(example.file.clone()).or_default() += 1;
+        picked.push(example);
+    }
+    picked
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::parser::ChunkType;
+
+    fn chunk(content: &str, line_start: u32, doc: Option<&str>) -> ChunkSummary {
+        ChunkSummary {
+            id: "src/a.rs:1:00000000".to_string(),
+            file: PathBuf::from("src/a.rs"),
+            language: Language::Rust,
+            chunk_type: ChunkType::Function,
+            name: "caller".to_string(),
+            signature: content.lines().next().unwrap_or("").to_string(),
+            content: content.to_string(),
+            doc: doc.map(str::to_string),
+            line_start,
+            line_end: line_start + content.lines().count() as u32 - 1,
+            content_hash: String::new(),
+            window_idx: None,
+            parent_id: None,
+            parent_type_name: None,
+            parser_version: 0,
+            vendored: false,
+            deprecated: false,
+            boilerplate: None,
+        }
+    }
+
+    fn example(file: &str, call_line: u32, score: f32) -> UsageExample {
+        UsageExample {
+            caller: "f".to_string(),
+            file: PathBuf::from(file),
+            language: Language::Rust,
+            line_start: 1,
+            call_line,
+            score,
+            signals: Vec::new(),
+            snippet_start: 1,
+            snippet: String::new(),
+        }
+    }
+
+    #[test]
+    fn split_symbol_handles_dotted_and_path_forms() {
+        assert_eq!(
+            split_symbol("http.Client.Do"),
+            (vec!["http", "Client"], "Do")
+        );
+        assert_eq!(split_symbol("Store::open"), (vec!["Store"], "open"));
+        assert_eq!(split_symbol("Foo#bar"), (vec!["Foo"], "bar"));
+        assert_eq!(split_symbol("open"), (vec![], "open"));
+    }
+
+    #[test]
+    fn error_handling_and_qualifier_outrank_a_bare_call() {
+        let handled = chunk(
+            "fn load() -> Result<()> {\n    let resp = client.Do(req)?;\n    Ok(())\n}",
+            10,
+            None,
+        );
+        let bare = chunk("fn fire() {\n    other.Do(req);\n}", 20, None);
+        let (good, signals) = score_call_site(&handled, 11, "Do", &["Client"], false);
+        let (poor, _) = score_call_site(&bare, 21, "Do", &["Client"], false);
+        assert!(good > poor, "{good} vs {poor}");
+        assert!(signals.contains(&ExampleSignal::HandlesErrors));
+        assert!(signals.contains(&ExampleSignal::ResultUsed));
+        assert!(signals.contains(&ExampleSignal::QualifierMatch));
+
+        let (in_test, signals) = score_call_site(&bare, 21, "Do", &[], true);
+        assert!(in_test < poor);
+        assert!(signals.contains(&ExampleSignal::Test));
+    }
+
+    #[test]
+    fn result_used_sees_bindings_returns_and_arguments() {
+        assert!(result_used("    let x = open(p)?;", "open"));
+        assert!(result_used("    return open(p)", "open"));
+        assert!(result_used("    use_it(open(p));", "open"));
+        assert!(!result_used("    open(p);", "open"));
+        assert!(!result_used("    store.open(p);", "open"));
+    }
+
+    #[test]
+    fn long_callers_are_windowed_around_the_call() {
+        let body: Vec<String> = (0..60).map(|i| format!("    let v{i} = {i};")).collect();
+        let content = format!("fn long() {{\n{}\n}}", body.join("\n"));
+        let c = chunk(&content, 100, None);
+        let (start, text) = snippet_for(&c, 140, 2);
+        assert_eq!(start, 138);
+        let lines: Vec<&str> = text.lines().collect();
+        assert_eq!(lines[0], "fn long() {");
+        assert_eq!(lines[1], "...");
+        assert_eq!(lines.len(), 2 + 5);
+        assert!(lines[4].contains("v39"), "{text}");
+
+        let short = chunk("    fn short() {\n        go();\n    }", 5, None);
+        assert_eq!(
+            snippet_for(&short, 6, 2),
+            (5, "fn short() {\n    go();\n}".to_string())
+        );
+    }
+
+    #[test]
+    fn picks_spread_across_files() {
+        let picked = pick_diverse(
+            vec![
+                example("a.rs", 1, 0.9),
+                example("a.rs", 2, 0.85),
+                example("b.rs", 1, 0.7),
+            ],
+            2,
+        );
+        let files: Vec<_> = picked.iter().map(|e| e.file.to_str().unwrap()).collect();
+        assert_eq!(files, ["a.rs", "b.rs"]);
+    }
+}
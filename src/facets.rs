@@ -0,0 +1,327 @@
+//! Multi-vector chunk representation: facet embeddings.
+//!
+//! One vector per chunk blurs two questions a query can ask — what is this
+//! called, and what does it do. With `[facets] enabled = true`, `cqs index`
+//! also embeds two parts of every chunk on their own and stores them beside
+//! the whole-chunk vector (`chunk_facet_embeddings`, schema v50):
+//!
+//! - [`Facet::Name`]: the symbol name, split into words, and its signature.
+//! - [`Facet::Body`]: the doc comment and the code, comments included.
+//!
+//! Dense search then scores a candidate as the weighted mean of the query's
+//! cosine against each vector the chunk has ([`FacetScores::blend`]), with
+//! the `[facets]` weights ([`FacetWeights`]). A chunk without facet vectors
+//! (not yet embedded, or indexed before facets were enabled) scores on the
+//! whole-chunk vector alone, as it always did. The blend applies where the
+//! dense cosine is the base score — the vector-index path and candidate
+//! rescoring; the brute-force scan and the SPLADE-fused path keep the
+//! whole-chunk score.
+//!
+//! The cosines behind each blended score are recorded as the `facet_whole`,
+//! `facet_name`, and `facet_body` rank signals, which `search --explain`
+//! prints per result.
+
+use std::path::Path;
+
+use crate::embedder::Embedder;
+use crate::store::helpers::{ChunkSummary, RankSignal};
+use crate::store::{ReadWrite, Store};
+
+/// Chunks embedded per batch by [`embed_missing_facets`].
+const FACET_BATCH: usize = 64;
+
+/// A part of a chunk embedded on its own.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, PartialOrd, Ord)]
+pub enum Facet {
+    /// Name and signature.
+    Name,
+    /// Doc comment and code.
+    Body,
+}
+
+impl Facet {
+    /// Every facet, in storage order.
+    pub const ALL: [Facet; 2] = [Facet::Name, Facet::Body];
+
+    /// The `chunk_facet_embeddings.facet` value.
+    pub fn as_str(self) -> &'static str {
+        match self {
+            Facet::Name => "name",
+            Facet::Body => "body",
+        }
+    }
+
+    /// Inverse of [`Self::as_str`].
+    pub fn parse(s: &str) -> Option<Self> {
+        Self::ALL.into_iter().find(|f| f.as_str() == s)
+    }
+
+    /// The text embedded for this facet of `chunk`, at most `max_chars`
+    /// characters (the embedder truncates to its sequence length anyway;
+    /// this keeps the tokenizer off megabyte-sized chunks).
+    pub fn text(self, chunk: &ChunkSummary, max_chars: usize) -> String {
+        let text = match self {
+            Facet::Name => {
+                let words = crate::nl::tokenize_identifier(&chunk.name).join(" ");
+                if chunk.signature.is_empty() {
+                    words
+                } else {
+                    format!("{words}\n{}", chunk.signature)
+                }
+            }
+            Facet::Body => match chunk.doc.as_deref().map(str::trim) {
+                Some(doc) if !doc.is_empty() => format!("{doc}\n{}", chunk.content),
+                _ => chunk.content.clone(),
+            },
+        };
+        match text.char_indices().nth(max_chars) {
+            Some((end, _)) => text[..end].to_string(),
+            None => text,
+        }
+    }
+}
+
+/// How much each vector counts in the blended score. Only the ratios
+/// matter: a chunk's score is the weighted mean over the vectors it has.
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub struct FacetWeights {
+    /// The whole-chunk vector (`chunks.embedding`).
+    pub whole: f32,
+    /// The [`Facet::Name`] vector.
+    pub name: f32,
+    /// The [`Facet::Body`] vector.
+    pub body: f32,
+}
+
+impl Default for FacetWeights {
+    fn default() -> Self {
+        Self {
+            whole: 0.5,
+            name: 0.25,
+            body: 0.25,
+        }
+    }
+}
+
+impl FacetWeights {
+    /// Negative and non-finite weights become 0; all-zero weights fall back
+    /// to the defaults, since a mean over nothing is no score.
+    pub fn sanitized(self) -> Self {
+        let clean = |w: f32| if w.is_finite() { w.max(0.0) } else { 0.0 };
+        let weights = Self {
+            whole: clean(self.whole),
+            name: clean(self.name),
+            body: clean(self.body),
+        };
+        if weights.whole + weights.name + weights.body > 0.0 {
+            weights
+        } else {
+            tracing::warn!("[facets] weights are all zero; using the defaults");
+            Self::default()
+        }
+    }
+}
+
+/// A chunk's stored facet vectors.
+#[derive(Debug, Clone, Default, PartialEq)]
+pub struct FacetVectors {
+    pub name: Option<Vec<f32>>,
+    pub body: Option<Vec<f32>>,
+}
+
+impl FacetVectors {
+    /// The query's cosine against each vector, given `whole` — its cosine
+    /// against the whole-chunk vector, which the caller already has.
+    pub fn scores(&self, query: &[f32], whole: f32) -> FacetScores {
+        let cosine = |v: &Option<Vec<f32>>| {
+            v.as_deref()
+                .and_then(|v| crate::math::cosine_similarity(query, v))
+        };
+        FacetScores {
+            whole,
+            name: cosine(&self.name),
+            body: cosine(&self.body),
+        }
+    }
+}
+
+/// The query's cosine against each of a chunk's vectors.
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub struct FacetScores {
+    pub whole: f32,
+    pub name: Option<f32>,
+    pub body: Option<f32>,
+}
+
+impl FacetScores {
+    /// Weighted mean of the cosines present.
+    pub fn blend(&self, weights: &FacetWeights) -> f32 {
+        let (sum, total) = [
+            (Some(self.whole), weights.whole),
+            (self.name, weights.name),
+            (self.body, weights.body),
+        ]
+        .into_iter()
+        .filter_map(|(score, weight)| score.map(|s| (s * weight, weight)))
+        .fold((0.0, 0.0), |(sum, total), (s, w)| (sum + s, total + w));
+        if total > 0.0 {
+            sum / total
+        } else {
+            self.whole
+        }
+    }
+
+    /// `facet_whole` / `facet_name` / `facet_body` rank signals, valued in
+    /// cosine.
+    pub(crate) fn signals(&self) -> Vec<RankSignal> {
+        let mut signals = vec![RankSignal {
+            signal: "facet_whole",
+            value: self.whole,
+        }];
+        if let Some(value) = self.name {
+            signals.push(RankSignal {
+                signal: "facet_name",
+                value,
+            });
+        }
+        if let Some(value) = self.body {
+            signals.push(RankSignal {
+                signal: "facet_body",
+                value,
+            });
+        }
+        signals
+    }
+}
+
+/// Blend weights for a search in the project at `root`: the `[facets]`
+/// weights when facets are enabled, else `None` (whole-chunk scoring).
+pub fn weights_for_search(root: &Path) -> Option<FacetWeights> {
+    crate::config::Config::load(root)
+        .facets
+        .filter(|f| f.is_enabled())
+        .map(|f| f.weights())
+}
+
+/// Embed the facets of every chunk still missing a current vector for one
+/// of them. Returns the number of chunks embedded — on a re-run, only the
+/// chunks added or changed since.
+pub fn embed_missing_facets(
+    store: &Store<ReadWrite>,
+    embedder: &Embedder,
+) -> anyhow::Result<usize> {
+    let _span = tracing::info_span!("embed_missing_facets").entered();
+    let max_chars = embedder.model_config().max_seq_length.saturating_mul(4);
+    let mut embedded = 0;
+    loop {
+        let chunks = store.chunks_awaiting_facets(FACET_BATCH)?;
+        if chunks.is_empty() {
+            break;
+        }
+        let mut rows = Vec::with_capacity(chunks.len() * Facet::ALL.len());
+        for facet in Facet::ALL {
+            let texts: Vec<String> = chunks.iter().map(|c| facet.text(c, max_chars)).collect();
+            let refs: Vec<&str> = texts.iter().map(String::as_str).collect();
+            let embeddings = embedder.embed_documents(&refs)?;
+            rows.extend(
+                chunks
+                    .iter()
+                    .zip(embeddings)
+                    .map(|(c, emb)| (c.id.clone(), c.content_hash.clone(), facet, emb)),
+            );
+        }
+        // A batch whose chunks all vanished mid-run writes nothing; stop
+        // rather than read the same gap again.
+        if store.upsert_facet_embeddings(&rows)? == 0 {
+            break;
+        }
+        embedded += chunks.len();
+    }
+    tracing::info!(embedded, "Facet vectors embedded");
+    Ok(embedded)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn blend_is_the_weighted_mean_of_present_vectors() {
+        let weights = FacetWeights::default();
+        let full = FacetScores {
+            whole: 0.6,
+            name: Some(0.9),
+            body: Some(0.5),
+        };
+        assert!((full.blend(&weights) - 0.65).abs() < 1e-6);
+        // A missing facet drops out of the mean instead of counting as 0.
+        let no_body = FacetScores { body: None, ..full };
+        assert!((no_body.blend(&weights) - 0.7).abs() < 1e-6);
+        let whole_only = FacetScores {
+            whole: 0.6,
+            name: None,
+            body: None,
+        };
+        assert_eq!(whole_only.blend(&weights), 0.6);
+        let signals: Vec<&str> = no_body.signals().iter().map(|s| s.signal).collect();
+        assert_eq!(signals, ["facet_whole", "facet_name"]);
+    }
+
+    #[test]
+    fn weights_are_sanitized() {
+        let weights = FacetWeights {
+            whole: -1.0,
+            name: f32::NAN,
+            body: 2.0,
+        };
+        assert_eq!(
+            weights.sanitized(),
+            FacetWeights {
+                whole: 0.0,
+                name: 0.0,
+                body: 2.0
+            }
+        );
+        let zero = FacetWeights {
+            whole: 0.0,
+            name: 0.0,
+            body: 0.0,
+        };
+        assert_eq!(zero.sanitized(), FacetWeights::default());
+    }
+
+    #[test]
+    fn facet_texts() {
+        let chunk = ChunkSummary {
+            id: "src/config.rs:1:abcd".to_string(),
+            file: std::path::PathBuf::from("src/config.rs"),
+            language: crate::parser::Language::Rust,
+            chunk_type: crate::parser::ChunkType::Function,
+            name: "parseConfigFile".to_string(),
+            signature: "fn parseConfigFile(path: &Path) -> Config".to_string(),
+            content: "fn parseConfigFile(path: &Path) -> Config { load(path) }".to_string(),
+            doc: Some("/// Reads the config.\n".to_string()),
+            line_start: 1,
+            line_end: 1,
+            parent_id: None,
+            parent_type_name: None,
+            content_hash: String::new(),
+            window_idx: None,
+            parser_version: 0,
+            vendored: false,
+            deprecated: false,
+            boilerplate: None,
+        };
+        assert_eq!(
+            Facet::Name.text(&chunk, 1000),
+            "parse config file\nfn parseConfigFile(path: &Path) -> Config"
+        );
+        assert_eq!(
+            Facet::Body.text(&chunk, 1000),
+            "/// Reads the config.\nfn parseConfigFile(path: &Path) -> Config { load(path) }"
+        );
+        assert_eq!(Facet::Body.text(&chunk, 8), "/// Read");
+        assert_eq!(Facet::parse("body"), Some(Facet::Body));
+        assert_eq!(Facet::parse("whole"), None);
+    }
+}
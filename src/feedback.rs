@@ -0,0 +1,351 @@
+//! Relevance feedback — `cqs feedback <result> --relevant|--irrelevant`.
+//!
+//! Each judgement is stored with the query it answered (when given), the
+//! chunk id, its origin and its language, in `.cqs/feedback.db` beside the
+//! index, so it survives a reindex like the access stats.
+//!
+//! The judgements fold into a [`FeedbackPrior`]: a score in `(-1, 1)` per
+//! path (the file, and its directory) and per language,
+//! `(relevant - irrelevant) / (relevant + irrelevant + 2)`. The `+ 2` keeps
+//! one vote from swinging a whole directory. Search applies it only when
+//! the `feedback_weight` scoring knob is above 0 (`CQS_FEEDBACK_WEIGHT` or
+//! `[scoring] feedback_weight`; off by default): each candidate's score is
+//! multiplied by `(1 + weight * path) * (1 + weight * language)`, where
+//! `path` is the file's own score if it has feedback and its directory's
+//! otherwise.
+//!
+//! `cqs feedback --reset` deletes every judgement.
+
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use std::sync::{Arc, Mutex};
+use std::time::{Duration, Instant};
+
+use thiserror::Error;
+
+use crate::cache::CacheError;
+
+/// File name of the feedback database inside the project `.cqs/` directory.
+pub const FEEDBACK_FILENAME: &str = "feedback.db";
+
+/// How long a loaded prior is reused before the database is read again.
+/// Shorter than the popularity prior's: a judgement should show up in the
+/// daemon's next searches without a restart.
+const PRIOR_TTL: Duration = Duration::from_secs(5);
+
+#[derive(Debug, Error)]
+pub enum FeedbackError {
+    #[error("Feedback database error: {0}")]
+    Database(#[from] sqlx::Error),
+    #[error("Failed to open feedback: {0}")]
+    Open(#[from] CacheError),
+}
+
+/// One relevance judgement on a search result.
+#[derive(Debug, Clone, PartialEq, serde::Serialize)]
+pub struct Judgement {
+    /// The query the result was returned for, if the caller passed it
+    pub query: Option<String>,
+    pub chunk_id: String,
+    /// Normalized origin path of the chunk
+    pub origin: String,
+    /// Language name as the chunk was indexed (`rust`, `python`, …)
+    pub language: String,
+    pub relevant: bool,
+}
+
+/// Relevant/irrelevant counts behind one prior entry.
+#[derive(Debug, Clone, Copy, Default, PartialEq, serde::Serialize)]
+pub struct Votes {
+    pub relevant: u64,
+    pub irrelevant: u64,
+}
+
+impl Votes {
+    fn add(&mut self, relevant: bool) {
+        if relevant {
+            self.relevant += 1;
+        } else {
+            self.irrelevant += 1;
+        }
+    }
+
+    /// `(relevant - irrelevant) / (relevant + irrelevant + 2)`, in `(-1, 1)`.
+    pub fn score(&self) -> f32 {
+        let (p, n) = (self.relevant as f64, self.irrelevant as f64);
+        ((p - n) / (p + n + 2.0)) as f32
+    }
+}
+
+/// Handle on the feedback database.
+pub struct FeedbackStore {
+    pool: sqlx::SqlitePool,
+    rt: Arc<tokio::runtime::Runtime>,
+}
+
+impl FeedbackStore {
+    /// Open or create the feedback database at `path`.
+    pub fn open(path: &Path) -> Result<Self, FeedbackError> {
+        let _span = tracing::debug_span!("feedback_open", path = %path.display()).entered();
+        let (pool, rt) = crate::cache::connect_cache_pool(path, 5_000, None, |pool| async move {
+            sqlx::query(
+                "CREATE TABLE IF NOT EXISTS feedback (
+                    id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    recorded_at INTEGER NOT NULL,
+                    query TEXT,
+                    chunk_id TEXT NOT NULL,
+                    origin TEXT NOT NULL,
+                    language TEXT NOT NULL,
+                    relevant INTEGER NOT NULL
+                )",
+            )
+            .execute(&pool)
+            .await?;
+            Ok(())
+        })?;
+        Ok(Self { pool, rt })
+    }
+
+    /// Store one judgement.
+    pub fn record(&self, judgement: &Judgement) -> Result<(), FeedbackError> {
+        let _span = tracing::debug_span!(
+            "feedback_record",
+            chunk_id = %judgement.chunk_id,
+            relevant = judgement.relevant
+        )
+        .entered();
+        let now = crate::unix_secs_i64().unwrap_or(0);
+        self.rt.block_on(async {
+            sqlx::query(
+                "INSERT INTO feedback (recorded_at, query, chunk_id, origin, language, relevant)
+                 VALUES (?1, ?2, ?3, ?4, ?5, ?6)",
+            )
+            .bind(now)
+            .bind(judgement.query.as_deref())
+            .bind(&judgement.chunk_id)
+            .bind(&judgement.origin)
+            .bind(&judgement.language)
+            .bind(judgement.relevant)
+            .execute(&self.pool)
+            .await?;
+            Ok(())
+        })
+    }
+
+    /// Every stored judgement, oldest first.
+    pub fn judgements(&self) -> Result<Vec<Judgement>, FeedbackError> {
+        let _span = tracing::debug_span!("feedback_judgements").entered();
+        self.rt.block_on(async {
+            let rows: Vec<(Option<String>, String, String, String, bool)> = sqlx::query_as(
+                "SELECT query, chunk_id, origin, language, relevant FROM feedback ORDER BY id",
+            )
+            .fetch_all(&self.pool)
+            .await?;
+            Ok(rows
+                .into_iter()
+                .map(|(query, chunk_id, origin, language, relevant)| Judgement {
+                    query,
+                    chunk_id,
+                    origin,
+                    language,
+                    relevant,
+                })
+                .collect())
+        })
+    }
+
+    /// Delete every judgement. Returns how many were removed.
+    pub fn reset(&self) -> Result<u64, FeedbackError> {
+        let _span = tracing::info_span!("feedback_reset").entered();
+        self.rt.block_on(async {
+            Ok(sqlx::query("DELETE FROM feedback")
+                .execute(&self.pool)
+                .await?
+                .rows_affected())
+        })
+    }
+
+    /// Every judgement folded into a [`FeedbackPrior`].
+    pub fn prior(&self) -> Result<FeedbackPrior, FeedbackError> {
+        Ok(FeedbackPrior::from_judgements(&self.judgements()?))
+    }
+}
+
+/// Learned per-path and per-language reweighting. See the module docs.
+#[derive(Debug, Default)]
+pub struct FeedbackPrior {
+    /// Keyed by origin file and by its parent directory (`""` for the root).
+    by_path: HashMap<String, Votes>,
+    by_language: HashMap<String, Votes>,
+}
+
+impl FeedbackPrior {
+    /// Fold judgements into per-path and per-language votes.
+    pub fn from_judgements(judgements: &[Judgement]) -> Self {
+        let mut prior = Self::default();
+        for j in judgements {
+            prior
+                .by_path
+                .entry(j.origin.clone())
+                .or_default()
+                .add(j.relevant);
+            prior
+                .by_path
+                .entry(parent_dir(&j.origin).to_string())
+                .or_default()
+                .add(j.relevant);
+            prior
+                .by_language
+                .entry(j.language.clone())
+                .or_default()
+                .add(j.relevant);
+        }
+        prior
+    }
+
+    /// Path score of `origin`: its own votes if it has any, else its
+    /// directory's; 0.0 without either.
+    pub fn path_score(&self, origin: &str) -> f32 {
+        self.by_path
+            .get(origin)
+            .or_else(|| self.by_path.get(parent_dir(origin)))
+            .map_or(0.0, Votes::score)
+    }
+
+    /// Language score of `origin`, from its file extension; 0.0 for an
+    /// unknown extension or a language without votes.
+    pub fn language_score(&self, origin: &str) -> f32 {
+        Path::new(origin)
+            .extension()
+            .and_then(|ext| ext.to_str())
+            .and_then(|ext| crate::language::REGISTRY.from_extension(ext))
+            .and_then(|def| self.by_language.get(def.name))
+            .map_or(0.0, Votes::score)
+    }
+
+    /// Per-path votes, sorted by key (files and directories mixed).
+    pub fn paths(&self) -> Vec<(&str, Votes)> {
+        let mut v: Vec<_> = self.by_path.iter().map(|(k, v)| (k.as_str(), *v)).collect();
+        v.sort_by(|a, b| a.0.cmp(b.0));
+        v
+    }
+
+    /// Per-language votes, sorted by language name.
+    pub fn languages(&self) -> Vec<(&str, Votes)> {
+        let mut v: Vec<_> = self
+            .by_language
+            .iter()
+            .map(|(k, v)| (k.as_str(), *v))
+            .collect();
+        v.sort_by(|a, b| a.0.cmp(b.0));
+        v
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.by_path.is_empty()
+    }
+}
+
+/// Directory part of a normalized (forward-slash) origin; `""` at the root.
+fn parent_dir(origin: &str) -> &str {
+    origin.rsplit_once('/').map_or("", |(dir, _)| dir)
+}
+
+/// Path of the feedback database under `cqs_dir`.
+pub fn feedback_path(cqs_dir: &Path) -> PathBuf {
+    cqs_dir.join(FEEDBACK_FILENAME)
+}
+
+struct CachedPrior {
+    path: PathBuf,
+    loaded_at: Instant,
+    prior: Option<Arc<FeedbackPrior>>,
+}
+
+static PRIOR_CACHE: Mutex<Option<CachedPrior>> = Mutex::new(None);
+
+/// Feedback prior for a search over the index in `cqs_dir`, or `None` when
+/// the `feedback_weight` knob is 0 (the default), no feedback was given, or
+/// the database can't be read. Reloaded at most every [`PRIOR_TTL`].
+pub fn feedback_prior_for_search(cqs_dir: &Path) -> Option<Arc<FeedbackPrior>> {
+    if crate::search::scoring::knob::resolve_knob("feedback_weight") <= 0.0 {
+        return None;
+    }
+    let path = feedback_path(cqs_dir);
+    let mut cache = PRIOR_CACHE.lock().unwrap_or_else(|p| p.into_inner());
+    if let Some(c) = cache.as_ref() {
+        if c.path == path && c.loaded_at.elapsed() < PRIOR_TTL {
+            return c.prior.clone();
+        }
+    }
+    let prior = if path.exists() {
+        match FeedbackStore::open(&path).and_then(|store| store.prior()) {
+            Ok(prior) if !prior.is_empty() => Some(Arc::new(prior)),
+            Ok(_) => None,
+            Err(e) => {
+                tracing::debug!(path = %path.display(), error = %e, "Failed to load feedback prior");
+                None
+            }
+        }
+    } else {
+        None
+    };
+    *cache = Some(CachedPrior {
+        path,
+        loaded_at: Instant::now(),
+        prior: prior.clone(),
+    });
+    prior
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn judgement(origin: &str, language: &str, relevant: bool) -> Judgement {
+        Judgement {
+            query: Some("parse config".to_string()),
+            chunk_id: format!("{origin}:1:abc"),
+            origin: origin.to_string(),
+            language: language.to_string(),
+            relevant,
+        }
+    }
+
+    #[test]
+    fn record_reset_roundtrip() {
+        let dir = tempfile::tempdir().unwrap();
+        let store = FeedbackStore::open(&feedback_path(dir.path())).unwrap();
+        store
+            .record(&judgement("src/config.rs", "rust", true))
+            .unwrap();
+        let mut unscoped = judgement("docs/config.md", "markdown", false);
+        unscoped.query = None;
+        store.record(&unscoped).unwrap();
+
+        let all = store.judgements().unwrap();
+        assert_eq!(all.len(), 2);
+        assert_eq!(all[1], unscoped);
+        assert_eq!(store.reset().unwrap(), 2);
+        assert!(store.prior().unwrap().is_empty());
+    }
+
+    #[test]
+    fn prior_prefers_file_then_directory() {
+        let prior = FeedbackPrior::from_judgements(&[
+            judgement("src/search/query.rs", "rust", true),
+            judgement("src/search/query.rs", "rust", true),
+            judgement("src/search/mmr.rs", "rust", false),
+            judgement("build.py", "python", false),
+        ]);
+        assert_eq!(prior.path_score("src/search/query.rs"), 0.5);
+        assert_eq!(prior.path_score("src/search/mmr.rs"), -1.0 / 3.0);
+        // No votes of its own: falls back to `src/search` (2 up, 1 down).
+        assert_eq!(prior.path_score("src/search/fusion.rs"), 0.2);
+        assert_eq!(prior.path_score("src/store/mod.rs"), 0.0);
+        assert_eq!(prior.path_score("setup.py"), -1.0 / 3.0);
+
+        assert_eq!(prior.language_score("src/store/mod.rs"), 0.2);
+        assert_eq!(prior.language_score("README"), 0.0);
+    }
+}
@@ -0,0 +1,360 @@
+//! Fuzzy symbol lookup — "I almost remember the name"
+//!
+//! Matches a typed pattern against every indexed symbol name the way an
+//! editor's Ctrl-P does, without touching the embedder or the vector index:
+//!
+//! 1. **Subsequence.** The pattern's characters appear in order in the name
+//!    (`prsCfg` → `parse_config`). The best alignment is scored by dynamic
+//!    programming: matches at word starts (after `_`, at a camel hump, at the
+//!    start of the name) and runs of consecutive matches score higher, so
+//!    `pc` prefers `parse_config` over `spec`.
+//! 2. **Edit distance.** A pattern that is not a subsequence — a typo such as
+//!    `pasre_config` — still matches when it is within a small optimal-string-
+//!    alignment distance of the name, scored below every subsequence match.
+//!
+//! Results are ranked by match quality, nudged by reference count (call sites
+//! in the call graph) so the symbol the codebase actually uses wins a tie.
+
+use std::collections::HashMap;
+use std::path::PathBuf;
+
+use crate::parser::{ChunkType, Language};
+use crate::store::{Store, StoreError};
+
+/// Default number of symbols returned.
+pub const DEFAULT_SYMBOL_LIMIT: usize = 20;
+
+/// Weight of the reference-count nudge. The most-referenced match gains this
+/// much over an unreferenced one of equal quality — enough to order near
+/// ties, never enough to lift a subsequence match over an exact one.
+const REFERENCE_WEIGHT: f32 = 0.05;
+
+/// Score bands. Each kind of match lands in its own band, so a worse kind
+/// never outranks a better one on quality alone.
+const EXACT: f32 = 1.0;
+const PREFIX_BASE: f32 = 0.8;
+const SUBSEQUENCE_BASE: f32 = 0.35;
+const TYPO_BASE: f32 = 0.05;
+
+/// One symbol matched by [`find_symbols`].
+#[derive(Debug, Clone, serde::Serialize)]
+pub struct SymbolMatch {
+    pub name: String,
+    #[serde(serialize_with = "crate::serialize_path_normalized")]
+    pub file: PathBuf,
+    pub line_start: u32,
+    pub chunk_type: ChunkType,
+    pub language: Language,
+    /// Match quality in (0, 1]; 1.0 is an exact (case-insensitive) match
+    pub quality: f32,
+    /// Call sites naming this symbol (shared by every symbol of that name)
+    pub references: u64,
+}
+
+/// Knobs for [`find_symbols`].
+#[derive(Debug, Clone, Copy)]
+pub struct SymbolSearchOptions {
+    /// Maximum matches returned
+    pub limit: usize,
+    /// Only symbols in this language
+    pub language: Option<Language>,
+}
+
+impl Default for SymbolSearchOptions {
+    fn default() -> Self {
+        Self {
+            limit: DEFAULT_SYMBOL_LIMIT,
+            language: None,
+        }
+    }
+}
+
+/// Word-start test for `chars[j]`: first character, after a separator, a
+/// lowercase→uppercase hump, or the first digit of a run.
+fn is_word_start(chars: &[char], j: usize) -> bool {
+    if j == 0 {
+        return true;
+    }
+    let (prev, cur) = (chars[j - 1], chars[j]);
+    !prev.is_alphanumeric()
+        || (prev.is_lowercase() && cur.is_uppercase())
+        || (!prev.is_numeric() && cur.is_numeric())
+}
+
+/// Best subsequence alignment score of `pattern` in `candidate`, normalized
+/// to [0, 1]; `None` when `pattern` is not a case-insensitive subsequence.
+///
+/// Each matched character scores 1, plus 2 at a word start, plus 1 when it
+/// directly follows the previous match. The DP keeps, per pattern position,
+/// the best score ending at each candidate position; a running maximum over
+/// earlier positions makes it O(pattern × candidate).
+fn subsequence_score(pattern: &[char], candidate: &[char]) -> Option<f32> {
+    const MATCH: f32 = 1.0;
+    const WORD_START: f32 = 2.0;
+    const CONSECUTIVE: f32 = 1.0;
+
+    let lower: Vec<char> = candidate
+        .iter()
+        .map(|c| c.to_lowercase().next().unwrap_or(*c))
+        .collect();
+    let n = lower.len();
+    // prev[j]: best score with the previous pattern char matched at j.
+    let mut prev: Vec<Option<f32>> = vec![None; n];
+    for (i, &p) in pattern.iter().enumerate() {
+        let mut cur: Vec<Option<f32>> = vec![None; n];
+        let mut best_before: Option<f32> = None;
+        for j in 0..n {
+            if lower[j] == p {
+                let base = MATCH
+                    + if is_word_start(candidate, j) {
+                        WORD_START
+                    } else {
+                        0.0
+                    };
+                cur[j] = if i == 0 {
+                    Some(base)
+                } else {
+                    let gapped = best_before.map(|s| s + base);
+                    let adjacent = (j > 0)
+                        .then(|| prev[j - 1])
+                        .flatten()
+                        .map(|s| s + base + CONSECUTIVE);
+                    match (gapped, adjacent) {
+                        (Some(a), Some(b)) => Some(a.max(b)),
+                        (a, b) => a.or(b),
+                    }
+                };
+            }
+            // Positions < j are usable as the previous match for j + 1.
+            if i > 0 {
+                if let Some(s) = prev[j] {
+                    best_before = Some(best_before.map_or(s, |b| b.max(s)));
+                }
+            }
+        }
+        prev = cur;
+    }
+    let best = prev.into_iter().flatten().reduce(f32::max)?;
+    let max = pattern.len() as f32 * (MATCH + WORD_START + CONSECUTIVE) - CONSECUTIVE;
+    Some((best / max).clamp(0.0, 1.0))
+}
+
+/// Optimal-string-alignment distance (Levenshtein plus adjacent
+/// transposition) between two lowercase strings.
+fn osa_distance(a: &[char], b: &[char]) -> usize {
+    let (n, m) = (a.len(), b.len());
+    let mut d = vec![vec![0usize; m + 1]; n + 1];
+    for (i, row) in d.iter_mut().enumerate() {
+        row[0] = i;
+    }
+    for j in 0..=m {
+        d[0][j] = j;
+    }
+    for i in 1..=n {
+        for j in 1..=m {
+            let cost = usize::from(a[i - 1] != b[j - 1]);
+            let mut v = (d[i - 1][j] + 1)
+                .min(d[i][j - 1] + 1)
+                .min(d[i - 1][j - 1] + cost);
+            if i > 1 && j > 1 && a[i - 1] == b[j - 2] && a[i - 2] == b[j - 1] {
+                v = v.min(d[i - 2][j - 2] + 1);
+            }
+            d[i][j] = v;
+        }
+    }
+    d[n][m]
+}
+
+/// Typos tolerated for a pattern of `len` characters: one per four. Under
+/// four characters none are, so `ab` doesn't match every two-letter name.
+fn max_typos(len: usize) -> usize {
+    len / 4
+}
+
+/// Match quality of `candidate` for `pattern`, in (0, 1], or `None` for no
+/// match. Case-insensitive. Exact beats prefix beats subsequence beats a
+/// near-miss typo; within a band, tighter matches score higher.
+pub fn fuzzy_score(pattern: &str, candidate: &str) -> Option<f32> {
+    let pattern: Vec<char> = pattern.chars().flat_map(char::to_lowercase).collect();
+    if pattern.is_empty() {
+        return None;
+    }
+    let chars: Vec<char> = candidate.chars().collect();
+    let lower: Vec<char> = candidate.chars().flat_map(char::to_lowercase).collect();
+    if lower == pattern {
+        return Some(EXACT);
+    }
+    // Coverage: how much of the name the pattern accounts for.
+    let coverage = pattern.len() as f32 / lower.len().max(1) as f32;
+    if lower.starts_with(&pattern) {
+        return Some(PREFIX_BASE + (EXACT - PREFIX_BASE) * 0.9 * coverage.min(1.0));
+    }
+    if let Some(s) = subsequence_score(&pattern, &chars) {
+        let span = PREFIX_BASE - SUBSEQUENCE_BASE;
+        return Some(SUBSEQUENCE_BASE + span * 0.95 * (0.7 * s + 0.3 * coverage.min(1.0)));
+    }
+    let allowed = max_typos(pattern.len());
+    if allowed == 0 || lower.len().abs_diff(pattern.len()) > allowed {
+        return None;
+    }
+    let distance = osa_distance(&pattern, &lower);
+    (distance <= allowed).then(|| {
+        let span = SUBSEQUENCE_BASE - TYPO_BASE;
+        TYPO_BASE + span * 0.95 * (1.0 - distance as f32 / (allowed + 1) as f32)
+    })
+}
+
+/// Rank score: quality plus the reference-count nudge, scaled against the
+/// most-referenced match so the nudge is bounded by [`REFERENCE_WEIGHT`].
+fn rank_score(quality: f32, references: u64, max_references: u64) -> f32 {
+    if max_references == 0 {
+        return quality;
+    }
+    let nudge = (references as f32).ln_1p() / (max_references as f32).ln_1p();
+    quality + REFERENCE_WEIGHT * nudge
+}
+
+/// Find indexed symbols whose names fuzzily match `pattern`.
+///
+/// Scans the lightweight identity columns of every chunk (no content, no
+/// embeddings); windows past the first are skipped so a long function is
+/// one result. Each distinct name is scored once. Best first; ties break on
+/// name, file, then line so the order is stable.
+pub fn find_symbols<Mode>(
+    store: &Store<Mode>,
+    pattern: &str,
+    opts: &SymbolSearchOptions,
+) -> Result<Vec<SymbolMatch>, StoreError> {
+    let _span = tracing::info_span!("find_symbols", pattern, limit = opts.limit).entered();
+    let pattern = pattern.trim();
+    if pattern.is_empty() || opts.limit == 0 {
+        return Ok(Vec::new());
+    }
+
+    let language = opts.language.map(|l| l.to_string());
+    let identities = store.all_chunk_identities_filtered(language.as_deref())?;
+
+    let mut quality_by_name: HashMap<&str, Option<f32>> = HashMap::new();
+    let mut matched = Vec::new();
+    for id in &identities {
+        if id.window_idx.is_some_and(|w| w > 0) {
+            continue;
+        }
+        let quality = *quality_by_name
+            .entry(id.name.as_str())
+            .or_insert_with(|| fuzzy_score(pattern, &id.name));
+        if let Some(quality) = quality {
+            matched.push((id, quality));
+        }
+    }
+    tracing::debug!(
+        symbols = identities.len(),
+        matched = matched.len(),
+        "Fuzzy symbol scan"
+    );
+    if matched.is_empty() {
+        return Ok(Vec::new());
+    }
+
+    let names: Vec<&str> = quality_by_name
+        .iter()
+        .filter(|(_, q)| q.is_some())
+        .map(|(n, _)| *n)
+        .collect();
+    let references = store.get_caller_counts_batch(&names)?;
+    let max_references = references.values().copied().max().unwrap_or(0);
+
+    let mut results: Vec<(f32, SymbolMatch)> = matched
+        .into_iter()
+        .map(|(id, quality)| {
+            let refs = references.get(&id.name).copied().unwrap_or(0);
+            (
+                rank_score(quality, refs, max_references),
+                SymbolMatch {
+                    name: id.name.clone(),
+                    file: id.file.clone(),
+                    line_start: id.line_start,
+                    chunk_type: id.chunk_type,
+                    language: id.language,
+                    quality,
+                    references: refs,
+                },
+            )
+        })
+        .collect();
+    results.sort_by(|(sa, a), (sb, b)| {
+        sb.total_cmp(sa)
+            .then_with(|| a.name.cmp(&b.name))
+            .then_with(|| a.file.cmp(&b.file))
+            .then_with(|| a.line_start.cmp(&b.line_start))
+    });
+    results.truncate(opts.limit);
+    Ok(results.into_iter().map(|(_, m)| m).collect())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn exact_beats_prefix_beats_subsequence_beats_typo() {
+        let exact = fuzzy_score("parse_config", "parse_config").unwrap();
+        let prefix = fuzzy_score("parse_c", "parse_config").unwrap();
+        let subseq = fuzzy_score("prscfg", "parse_config").unwrap();
+        let typo = fuzzy_score("pasre_config", "parse_config").unwrap();
+        assert_eq!(exact, 1.0);
+        assert!(exact > prefix && prefix > subseq && subseq > typo && typo > 0.0);
+    }
+
+    #[test]
+    fn matching_is_case_insensitive() {
+        assert_eq!(fuzzy_score("PARSECONFIG", "parseConfig"), Some(1.0));
+        assert!(fuzzy_score("pc", "ParseConfig").is_some());
+    }
+
+    #[test]
+    fn word_starts_beat_scattered_letters() {
+        // `pc` hits both word starts in parse_config, mid-word letters in spec.
+        let words = fuzzy_score("pc", "parse_config").unwrap();
+        let scattered = fuzzy_score("pc", "xspec").unwrap();
+        assert!(words > scattered, "{words} vs {scattered}");
+        // Camel humps count as word starts too.
+        let camel = fuzzy_score("gubi", "getUserById").unwrap();
+        let flat = fuzzy_score("gubi", "xgxuxbxi").unwrap();
+        assert!(camel > flat, "{camel} vs {flat}");
+    }
+
+    #[test]
+    fn consecutive_runs_score_higher() {
+        let run = fuzzy_score("conf", "xconfx").unwrap();
+        let split = fuzzy_score("conf", "xcxoxnxfx").unwrap();
+        assert!(run > split, "{run} vs {split}");
+    }
+
+    #[test]
+    fn typos_are_bounded() {
+        // One transposition is one edit.
+        assert!(fuzzy_score("serach", "search").is_some());
+        // Short patterns tolerate no edits.
+        assert!(fuzzy_score("ab", "ax").is_none());
+        assert!(fuzzy_score("", "anything").is_none());
+    }
+
+    #[test]
+    fn osa_counts_transpositions_once() {
+        let c = |s: &str| s.chars().collect::<Vec<_>>();
+        assert_eq!(osa_distance(&c("ab"), &c("ba")), 1);
+        assert_eq!(osa_distance(&c("kitten"), &c("sitting")), 3);
+        assert_eq!(osa_distance(&c(""), &c("abc")), 3);
+    }
+
+    #[test]
+    fn references_order_near_ties_but_not_bands() {
+        // Same quality: the referenced one wins.
+        assert!(rank_score(0.5, 10, 10) > rank_score(0.5, 0, 10));
+        // The nudge never lifts a subsequence match over an exact one.
+        let subseq_max = SUBSEQUENCE_BASE + (PREFIX_BASE - SUBSEQUENCE_BASE) * 0.95;
+        assert!(rank_score(subseq_max, 1000, 1000) < rank_score(EXACT, 0, 1000));
+        assert_eq!(rank_score(0.4, 0, 0), 0.4);
+    }
+}
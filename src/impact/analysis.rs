@@ -781,6 +781,8 @@ mod tests {
                 window_idx: None,
                 parser_version: 0,
                 vendored: false,
+                deprecated: false,
+                boilerplate: None,
             },
             ChunkSummary {
                 id: "2".into(),
@@ -799,6 +801,8 @@ mod tests {
                 window_idx: None,
                 parser_version: 0,
                 vendored: false,
+                deprecated: false,
+                boilerplate: None,
             },
         ];
 
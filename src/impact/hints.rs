@@ -430,6 +430,8 @@ mod tests {
             window_idx: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }];
         let hints = compute_hints_with_graph(&graph, &test_chunks, "target", None);
         assert_eq!(hints.test_count, 0, "Unreachable test should not count");
@@ -494,6 +496,8 @@ mod tests {
             window_idx: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }];
         let scores = compute_risk_batch(&["target"], &graph, &test_chunks);
         assert_eq!(scores[0].risk_level, RiskLevel::Low);
@@ -546,6 +550,8 @@ mod tests {
                 window_idx: None,
                 parser_version: 0,
                 vendored: false,
+                deprecated: false,
+                boilerplate: None,
             },
             crate::store::ChunkSummary {
                 id: "t2".to_string(),
@@ -564,6 +570,8 @@ mod tests {
                 window_idx: None,
                 parser_version: 0,
                 vendored: false,
+                deprecated: false,
+                boilerplate: None,
             },
             crate::store::ChunkSummary {
                 id: "t3".to_string(),
@@ -582,6 +590,8 @@ mod tests {
                 window_idx: None,
                 parser_version: 0,
                 vendored: false,
+                deprecated: false,
+                boilerplate: None,
             },
         ];
         let scores = compute_risk_batch(&["target"], &graph, &test_chunks);
@@ -662,6 +672,8 @@ mod tests {
             window_idx: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }];
 
         let scores = compute_risk_batch(&["target"], &graph, &test_chunks);
@@ -762,6 +774,8 @@ mod tests {
             window_idx: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }];
         let scores = compute_risk_batch(&["target"], &graph, &test_chunks);
         assert_eq!(scores[0].risk_level, RiskLevel::Low);
@@ -805,6 +819,8 @@ mod tests {
             window_idx: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 
@@ -35,7 +35,7 @@ pub use hints::{
     compute_hints, compute_hints_batch, compute_hints_with_graph, compute_risk_and_tests,
     compute_risk_batch, find_hotspots,
 };
-pub use test_map::{find_test_matches, TestMatch};
+pub use test_map::{find_test_matches, test_names_subject, TestMatch};
 
 /// Default maximum depth for test search BFS.
 /// Exposed via `max_test_depth` parameters on analysis functions.
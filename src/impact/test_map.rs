@@ -108,6 +108,62 @@ pub fn find_test_matches(
     matches
 }
 
+/// Whether a test's name says which symbol it exercises — the naming
+/// conventions test frameworks encourage, for tests that reach their subject
+/// through something the call graph can't see (a macro, a table of cases, a
+/// trait object).
+///
+/// The test name is stripped of its marker — a `test_` / `test` / `Test`
+/// prefix (`test_parse`, `testParse`, `TestParse`) or a `_test` / `_tests` /
+/// `Test` suffix (`parse_test`, `ParserTest`) — and what remains must equal
+/// `target`, or start with it followed by `_` (`test_parse_empty`,
+/// `TestParse_Empty`). Comparison ignores case and underscores, so
+/// `test_parse_file` names `parseFile`.
+pub fn test_names_subject(test_name: &str, target: &str) -> bool {
+    fn fold(s: &str) -> String {
+        s.chars()
+            .filter(|&c| c != '_')
+            .flat_map(char::to_lowercase)
+            .collect()
+    }
+    let Some(subject) = test_subject(test_name) else {
+        return false;
+    };
+    let want = fold(target);
+    if want.is_empty() {
+        return false;
+    }
+    fold(subject) == want
+        || subject
+            .match_indices('_')
+            .any(|(i, _)| fold(&subject[..i]) == want)
+}
+
+/// The part of a test name left after its test marker, or `None` when the
+/// name carries no marker.
+fn test_subject(name: &str) -> Option<&str> {
+    let upper_next = |rest: &str| rest.starts_with(|c: char| c.is_uppercase() || c == '_');
+    let subject = if let Some(rest) = name.strip_prefix("test_") {
+        rest
+    } else if let Some(rest) = name
+        .strip_prefix("test")
+        .or_else(|| name.strip_prefix("Test"))
+        .filter(|rest| upper_next(rest))
+    {
+        rest
+    } else if let Some(rest) = name
+        .strip_suffix("_tests")
+        .or_else(|| name.strip_suffix("_test"))
+        .or_else(|| name.strip_suffix("Test"))
+    {
+        rest
+    } else {
+        return None;
+    };
+    let subject = subject.trim_matches('_');
+    (!subject.is_empty()).then_some(subject)
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -132,6 +188,8 @@ mod tests {
             parent_type_name: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 
@@ -173,6 +231,37 @@ mod tests {
         );
     }
 
+    #[test]
+    fn test_names_subject_conventions() {
+        for (test, target) in [
+            ("test_parse", "parse"),
+            ("test_parse_empty_input", "parse"),
+            ("test_parse_file", "parseFile"),
+            ("TestParse", "Parse"),
+            ("TestParse_Empty", "parse"),
+            ("testParseFile", "parseFile"),
+            ("parse_test", "parse"),
+            ("ParserTest", "Parser"),
+        ] {
+            assert!(
+                test_names_subject(test, target),
+                "{test} should name {target}"
+            );
+        }
+        for (test, target) in [
+            ("test_parser", "parse"),
+            ("testimony", "mony"),
+            ("parse", "parse"),
+            ("test_", "test"),
+            ("TestParseFile", "parse"),
+        ] {
+            assert!(
+                !test_names_subject(test, target),
+                "{test} must not name {target}"
+            );
+        }
+    }
+
     #[test]
     fn test_find_test_matches_no_tests() {
         let graph = CallGraph::from_string_maps(HashMap::new(), HashMap::new());
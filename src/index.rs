@@ -337,6 +337,10 @@ macro_rules! register_index_backends {
 
 register_index_backends! {
     crate::hnsw::HnswBackend;
+    // External store (Qdrant / pgvector) sits above everything (priority
+    // 200) but only answers when `[index.vector]` names one and it is in
+    // sync; the client features gate inside `vector_backend::open`.
+    crate::vector_backend::ExternalBackend;
     crate::cagra::CagraBackend, cfg(feature = "cuda-index");
     // Tiered backend sits above CAGRA (priority 150 > 100) so, when opted in
     // via CQS_TIERED_INDEX=1, it shadows CAGRA. The env gate lives in its
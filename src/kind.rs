@@ -158,7 +158,8 @@ pub fn classify_chunk_type(ct: ChunkType) -> Kind {
         | ChunkType::StoredProc
         | ChunkType::Extern
         | ChunkType::Modifier
-        | ChunkType::Extension => Kind::Other,
+        | ChunkType::Extension
+        | ChunkType::Commit => Kind::Other,
     }
 }
 
@@ -342,7 +343,8 @@ mod tests {
                 | ChunkType::StoredProc
                 | ChunkType::Extern
                 | ChunkType::Modifier
-                | ChunkType::Extension => Kind::Other,
+                | ChunkType::Extension
+                | ChunkType::Commit => Kind::Other,
             }
         }
 
@@ -555,6 +557,8 @@ mod tests {
             parent_type_name: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         };
         let hit = KindHit::from(&summary);
         assert_eq!(hit.name, "foo");
@@ -30,6 +30,8 @@ const DEFAULTS: LanguageDef = LanguageDef {
     call_query: None,
     signature_style: SignatureStyle::UntilBrace,
     doc_nodes: &[],
+    doc_skip_nodes: &[],
+    doc_wrapper_nodes: &[],
     method_node_kinds: &[],
     method_containers: &[],
     stopwords: &[],
@@ -506,6 +508,7 @@ static LANG_CPP: LanguageDef = LanguageDef {
     patterns: Some(&patterns_data::CPP_LIKE),
     line_comment_prefixes: &["//", "/*"],
     aliases: &["c++", "cxx"],
+    doc_wrapper_nodes: &["template_declaration"],
     ..DEFAULTS
 };
 
@@ -3398,6 +3401,8 @@ static LANG_JAVASCRIPT: LanguageDef = LanguageDef {
     patterns: Some(&patterns_data::TS_JS),
     line_comment_prefixes: &["//", "/*"],
     aliases: &["js"],
+    doc_skip_nodes: &["decorator"],
+    doc_wrapper_nodes: &["export_statement"],
     ..DEFAULTS
 };
 
@@ -5500,6 +5505,8 @@ static LANG_PYTHON: LanguageDef = LanguageDef {
     patterns: Some(&patterns_data::PYTHON),
     line_comment_prefixes: &["#"],
     aliases: &["py"],
+    doc_skip_nodes: &["decorator"],
+    doc_wrapper_nodes: &["decorated_definition"],
     ..DEFAULTS
 };
 
@@ -6501,6 +6508,7 @@ static LANG_RUST: LanguageDef = LanguageDef {
     // test marker.
     patterns: Some(&patterns_data::RUST),
     line_comment_prefixes: &["//", "/*"],
+    doc_skip_nodes: &["attribute_item"],
     ..DEFAULTS
 };
 
@@ -7687,6 +7695,8 @@ static LANG_TYPESCRIPT: LanguageDef = LanguageDef {
     patterns: Some(&patterns_data::TS_JS),
     line_comment_prefixes: &["//", "/*"],
     aliases: &["ts"],
+    doc_skip_nodes: &["decorator"],
+    doc_wrapper_nodes: &["export_statement"],
     ..DEFAULTS
 };
 
@@ -8322,6 +8332,66 @@ pub fn definition_l5x() -> &'static LanguageDef {
     &LANG_L5X
 }
 
+// ============================================================================
+// Plain text (grammar-less fallback — overlapping line windows)
+// ============================================================================
+
+static LANG_PLAIN: LanguageDef = LanguageDef {
+    name: "plain",
+    grammar: None, // Custom parser — token-budgeted line windows with overlap.
+    // Prose and config-ish formats with no grammar of their own. Anything
+    // routed here is searchable but has no symbols, calls, or types.
+    extensions: &[
+        "txt",
+        "text",
+        "rst",
+        "adoc",
+        "asciidoc",
+        "org",
+        "rdoc",
+        "pod",
+        "conf",
+        "properties",
+        "gradle",
+        "cmake",
+    ],
+    signature_style: SignatureStyle::FirstLine,
+    stopwords: &["the", "and", "for", "with", "this", "that", "from", "are"],
+    // Both paths must be set: without `custom_all_parser` the combined
+    // `parse_file_all` path would fall back to the markdown parser.
+    custom_chunk_parser: Some(crate::parser::plain::parse_plain_chunks),
+    custom_all_parser: Some(crate::parser::plain::parse_plain_all),
+    ..DEFAULTS
+};
+
+pub fn definition_plain() -> &'static LanguageDef {
+    &LANG_PLAIN
+}
+
+// ============================================================================
+// Grammar plugins (runtime-loaded tree-sitter grammars)
+// ============================================================================
+
+static LANG_PLUGIN: LanguageDef = LanguageDef {
+    name: "plugin",
+    // The grammar lives in a shared library picked per file by extension,
+    // so this row has none; the custom parsers look it up.
+    grammar: None,
+    // Claimed at runtime from `[[grammar]]`; see `REGISTRY.from_extension`.
+    extensions: &[],
+    signature_style: SignatureStyle::FirstLine,
+    doc_nodes: &["comment", "line_comment", "block_comment"],
+    // Keywords common enough across languages to be noise in any grammar.
+    stopwords: &["if", "else", "then", "end", "return", "begin", "is", "of"],
+    custom_chunk_parser: Some(crate::parser::plugin::parse_plugin_chunks),
+    custom_all_parser: Some(crate::parser::plugin::parse_plugin_all),
+    ..DEFAULTS
+};
+
+pub fn definition_plugin() -> &'static LanguageDef {
+    &LANG_PLUGIN
+}
+
 // ============================================================================
 // Yaml (yaml)
 // ============================================================================
@@ -63,12 +63,15 @@
 //! - `lang-aspx` - ASP.NET Web Forms support (enabled by default, no external deps)
 //! - `lang-st` - IEC 61131-3 Structured Text support (enabled by default)
 //! - `lang-dart` - Dart support (enabled by default)
+//! - `grammar-plugins` - runtime-loaded tree-sitter grammars, see [`plugin`] (enabled by default)
 //! - `lang-all` - All languages
 
 use std::collections::HashMap;
 use std::sync::LazyLock;
 
 mod languages;
+pub mod overrides;
+pub mod plugin;
 
 // ---------------------------------------------------------------------------
 // Macro: define_languages!
@@ -113,7 +116,7 @@ macro_rules! define_languages {
         )+
     ) => {
         /// Supported programming languages
-        #[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, serde::Serialize)]
+        #[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, serde::Serialize, serde::Deserialize)]
         #[serde(rename_all = "lowercase")]
         pub enum Language {
             $(
@@ -299,6 +302,16 @@ pub struct LanguageDef {
     pub signature_style: SignatureStyle,
     /// Node types that contain doc comments
     pub doc_nodes: &'static [&'static str],
+    /// Sibling node kinds passed over while walking back from a definition to
+    /// its leading doc comment — attributes and decorators that sit between
+    /// the comment and the definition (Rust `#[derive]`, Python `@cache`).
+    /// Empty `&[]` stops the walk at the first non-comment sibling.
+    pub doc_skip_nodes: &'static [&'static str],
+    /// Wrapper node kinds whose leading comments belong to the definition
+    /// they wrap (TypeScript `export_statement`, Python
+    /// `decorated_definition`, C++ `template_declaration`). When the walk
+    /// from the definition finds no doc, it retries from the wrapper.
+    pub doc_wrapper_nodes: &'static [&'static str],
     /// Node kinds that are themselves methods (e.g., Go's "method_declaration")
     pub method_node_kinds: &'static [&'static str],
     /// Parent node kinds that make a child function a method (e.g., Rust's "impl_item")
@@ -565,7 +578,7 @@ macro_rules! define_chunk_types {
         )+
     ) => {
         /// Type of code element extracted by the parser
-        #[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, serde::Serialize)]
+        #[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, serde::Serialize, serde::Deserialize)]
         #[serde(rename_all = "lowercase")]
         pub enum ChunkType {
             $(
@@ -779,6 +792,8 @@ define_chunk_types! {
     Middleware => "middleware", hints = ["middleware", "all middleware", "every middleware"];
     /// Solidity access control modifier (modifier onlyOwner)
     Modifier => "modifier", hints = ["all modifiers", "every modifier"];
+    /// Commit message from the git history, linked to the files it touched (`crate::commits`)
+    Commit => "commit", hints = ["which commit", "commit message", "all commits"];
 }
 
 /// Coarse classification of a `ChunkType` for the call graph and the
@@ -789,7 +804,7 @@ pub enum ChunkClass {
     Callable,
     /// Type / value definition — appears in the default search filter only.
     Code,
-    /// Markdown sections, modules, namespaces, config keys — excluded from
+    /// Markdown sections, modules, namespaces, config keys, commits — excluded from
     /// the default search filter and the call graph.
     NonCode,
 }
@@ -839,7 +854,8 @@ impl ChunkType {
             | ChunkType::Event
             | ChunkType::ConfigKey
             | ChunkType::MacroInvocation
-            | ChunkType::Namespace => ChunkClass::NonCode,
+            | ChunkType::Namespace
+            | ChunkType::Commit => ChunkClass::NonCode,
         }
     }
 
@@ -953,8 +969,14 @@ impl LanguageRegistry {
         self.by_name.get(name).copied()
     }
 
-    /// Get a language definition by file extension
+    /// Get a language definition by file extension. An extension claimed
+    /// by a `[[grammar]]` plugin resolves to the plugin language first.
     pub fn from_extension(&self, ext: &str) -> Option<&'static LanguageDef> {
+        if plugin::for_extension(ext).is_some() {
+            if let Some(def) = self.get("plugin") {
+                return Some(def);
+            }
+        }
         self.by_extension.get(ext).copied()
     }
 
@@ -963,9 +985,13 @@ impl LanguageRegistry {
         self.by_name.values().copied()
     }
 
-    /// Get all supported extensions
+    /// Get all supported extensions, including those claimed by loaded
+    /// `[[grammar]]` plugins.
     pub fn supported_extensions(&self) -> impl Iterator<Item = &'static str> + '_ {
-        self.by_extension.keys().copied()
+        self.by_extension
+            .keys()
+            .copied()
+            .chain(plugin::extensions())
     }
 
     /// Collect all unique test content markers from all enabled languages.
@@ -1208,8 +1234,12 @@ define_languages! {
     StructuredText => "structured_text", feature = "lang-st", def = languages::definition_structured_text;
     /// Rockwell/Allen-Bradley PLC exports (.l5x, .l5k files — embedded Structured Text)
     L5x => "l5x", feature = "lang-l5x", def = languages::definition_l5x;
+    /// Plain-text fallback (.txt, .rst, .adoc, .org, … — overlapping line windows)
+    Plain => "plain", feature = "lang-plain", def = languages::definition_plain;
     /// Dart (.dart files)
     Dart => "dart", feature = "lang-dart", def = languages::definition_dart;
+    /// Runtime-loaded tree-sitter grammars (`[[grammar]]` in the user config)
+    Plugin => "plugin", feature = "grammar-plugins", def = languages::definition_plugin;
 }
 
 // ---------------------------------------------------------------------------
@@ -1252,6 +1282,17 @@ impl Language {
             .and_then(|def| def.name.parse().ok())
     }
 
+    /// Detect a file's language: a `[languages] overrides` rule first (see
+    /// [`overrides`]), then the extension table. `None` means the file is
+    /// unsupported or an override says to skip it.
+    pub fn from_path(path: &std::path::Path) -> Option<Self> {
+        if let Some(decision) = overrides::lookup(path) {
+            return decision;
+        }
+        let ext = path.extension().and_then(|e| e.to_str())?;
+        Self::from_extension(&ext.to_ascii_lowercase())
+    }
+
     /// Check if this language's feature flag is enabled
     pub fn is_enabled(&self) -> bool {
         REGISTRY.get(&self.to_string()).is_some()
@@ -1428,7 +1469,8 @@ mod tests {
                 | ChunkType::Event
                 | ChunkType::ConfigKey
                 | ChunkType::MacroInvocation
-                | ChunkType::Namespace => {
+                | ChunkType::Namespace
+                | ChunkType::Commit => {
                     assert!(!ct.is_code(), "{ct} should not be code");
                 }
             };
@@ -1846,6 +1888,14 @@ mod tests {
         {
             expected += 1;
         }
+        #[cfg(feature = "lang-plain")]
+        {
+            expected += 1;
+        }
+        #[cfg(feature = "grammar-plugins")]
+        {
+            expected += 1;
+        }
         assert_eq!(all.len(), expected);
     }
 
@@ -1963,6 +2013,8 @@ mod tests {
         assert_eq!(Language::from_extension("tese"), Some(Language::Glsl));
         assert_eq!(Language::from_extension("md"), Some(Language::Markdown));
         assert_eq!(Language::from_extension("mdx"), Some(Language::Markdown));
+        assert_eq!(Language::from_extension("txt"), Some(Language::Plain));
+        assert_eq!(Language::from_extension("rst"), Some(Language::Plain));
         assert_eq!(Language::from_extension("unknown"), None);
     }
 
@@ -2679,7 +2731,7 @@ mod tests {
     /// fails and reminds the contributor to update the constant.
     #[test]
     fn test_language_variant_count() {
-        const EXPECTED_LANGUAGE_COUNT: usize = 55;
+        const EXPECTED_LANGUAGE_COUNT: usize = 57;
         let actual = Language::all_variants().len();
         assert_eq!(
             actual, EXPECTED_LANGUAGE_COUNT,
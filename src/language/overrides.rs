@@ -0,0 +1,293 @@
+//! Per-project language detection overrides.
+//!
+//! The extension table misses files with no extension (`BUILD`,
+//! `Jenkinsfile`) and misreads compound ones (`*.tsx.snap`, `*.gotmpl`).
+//! `[languages] overrides` in `.cqs.toml` maps file globs to a target:
+//!
+//! ```toml
+//! [languages.overrides]
+//! "BUILD" = "starlark"
+//! "*.gotmpl" = "go-template"
+//! "*.tsx.snap" = "none"
+//! "include/**/*.h" = "cpp"
+//! ```
+//!
+//! A pattern without `/` matches the file name in any directory. A pattern
+//! with `/` matches the trailing components of the path, so `include/*.h`
+//! also matches `third_party/include/x.h`. When several patterns match, the
+//! longest wins.
+//!
+//! A target is a language name (`cqs languages list`), a dialect alias from
+//! [`ALIASES`], an extension routed through the normal table (`"md"`), or
+//! `none` to skip the file. Rules with a bad glob or an unknown target are
+//! logged and dropped; the rest still apply.
+//!
+//! Overrides decide what the walk, the watcher, `--only`, and the parser
+//! see. A file whose language changes keeps its old chunks until its next
+//! reparse, so run `cqs index --force` after editing the section.
+
+use std::collections::BTreeMap;
+use std::path::Path;
+use std::sync::OnceLock;
+
+use super::Language;
+
+/// Dialects with no grammar of their own, mapped to the closest one.
+pub const ALIASES: &[(&str, &str)] = &[
+    ("starlark", "python"),
+    ("bazel", "python"),
+    ("go-template", "plain"),
+    ("gotmpl", "plain"),
+    ("jinja", "plain"),
+    ("shell", "bash"),
+    ("sh", "bash"),
+];
+
+/// Why an override rule was dropped.
+#[derive(Debug, thiserror::Error)]
+pub enum OverrideError {
+    #[error("invalid glob \"{pattern}\": {source}")]
+    InvalidGlob {
+        pattern: String,
+        source: globset::Error,
+    },
+    #[error("\"{pattern}\" maps to unknown language \"{target}\"")]
+    UnknownTarget { pattern: String, target: String },
+    #[error("\"{pattern}\" maps to \"{target}\", which this build was compiled without")]
+    Disabled { pattern: String, target: String },
+}
+
+/// One accepted rule.
+#[derive(Debug, Clone)]
+pub struct OverrideRule {
+    /// Pattern as written in `.cqs.toml`.
+    pub pattern: String,
+    /// Target as written in `.cqs.toml`.
+    pub target: String,
+    /// Resolved language; `None` means skip the file.
+    pub language: Option<Language>,
+}
+
+/// Compiled override table.
+#[derive(Debug, Default)]
+pub struct LanguageOverrides {
+    set: globset::GlobSet,
+    rules: Vec<OverrideRule>,
+}
+
+impl LanguageOverrides {
+    /// Compile `overrides`, returning the table and the rules that were
+    /// dropped.
+    pub fn build(overrides: &BTreeMap<String, String>) -> (Self, Vec<OverrideError>) {
+        let mut builder = globset::GlobSetBuilder::new();
+        let mut rules = Vec::new();
+        let mut errors = Vec::new();
+        for (pattern, target) in overrides {
+            let language = match resolve_target(target) {
+                Ok(language) => language,
+                Err(kind) => {
+                    errors.push(kind.into_error(pattern, target));
+                    continue;
+                }
+            };
+            let glob = globset::GlobBuilder::new(&anchor(pattern))
+                .literal_separator(true)
+                .build();
+            match glob {
+                Ok(glob) => {
+                    builder.add(glob);
+                    rules.push(OverrideRule {
+                        pattern: pattern.clone(),
+                        target: target.clone(),
+                        language,
+                    });
+                }
+                Err(source) => errors.push(OverrideError::InvalidGlob {
+                    pattern: pattern.clone(),
+                    source,
+                }),
+            }
+        }
+        let set = match builder.build() {
+            Ok(set) => set,
+            // Every glob compiled on its own; a set failure means none apply.
+            Err(source) => {
+                errors.push(OverrideError::InvalidGlob {
+                    pattern: "<set>".to_string(),
+                    source,
+                });
+                return (Self::default(), errors);
+            }
+        };
+        (Self { set, rules }, errors)
+    }
+
+    /// Accepted rules, in pattern order.
+    pub fn rules(&self) -> &[OverrideRule] {
+        &self.rules
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.rules.is_empty()
+    }
+
+    /// The rule for `path`, if any pattern matches. `path` may be absolute
+    /// or project-relative.
+    pub fn lookup(&self, path: &Path) -> Option<&OverrideRule> {
+        if self.rules.is_empty() {
+            return None;
+        }
+        self.set
+            .matches(path)
+            .into_iter()
+            .map(|i| &self.rules[i])
+            // Longest pattern wins; `max_by_key` keeps the last of equals,
+            // so ties go to the later key in sorted order.
+            .max_by_key(|rule| rule.pattern.len())
+    }
+}
+
+/// A pattern without `/` matches the name in any directory; one with `/`
+/// matches trailing components. Both become `**/`-prefixed globs so the
+/// same set works on absolute and relative paths.
+fn anchor(pattern: &str) -> String {
+    let trimmed = pattern.trim_start_matches("./").trim_start_matches('/');
+    if trimmed.starts_with("**/") {
+        trimmed.to_string()
+    } else {
+        format!("**/{trimmed}")
+    }
+}
+
+enum TargetError {
+    Unknown,
+    Disabled,
+}
+
+impl TargetError {
+    fn into_error(self, pattern: &str, target: &str) -> OverrideError {
+        let (pattern, target) = (pattern.to_string(), target.to_string());
+        match self {
+            TargetError::Unknown => OverrideError::UnknownTarget { pattern, target },
+            TargetError::Disabled => OverrideError::Disabled { pattern, target },
+        }
+    }
+}
+
+/// `none` → skip, alias → its language, language name, then extension.
+fn resolve_target(target: &str) -> Result<Option<Language>, TargetError> {
+    let lower = target.trim().to_ascii_lowercase();
+    if lower == "none" {
+        return Ok(None);
+    }
+    let name = ALIASES
+        .iter()
+        .find(|(alias, _)| *alias == lower)
+        .map_or(lower.as_str(), |(_, name)| name);
+    if let Ok(language) = name.parse::<Language>() {
+        return if language.is_enabled() {
+            Ok(Some(language))
+        } else {
+            Err(TargetError::Disabled)
+        };
+    }
+    Language::from_extension(lower.trim_start_matches('.'))
+        .map(Some)
+        .ok_or(TargetError::Unknown)
+}
+
+/// `[languages] overrides` from `.cqs.toml`, pushed in once at startup by
+/// [`set_overrides_from_config`]. Unset means extensions only.
+static OVERRIDES: OnceLock<LanguageOverrides> = OnceLock::new();
+
+/// Compile `[languages] overrides` and install it for every detection
+/// site. Dropped rules are logged. Must be called before the first walk;
+/// later calls are no-ops (OnceLock).
+pub fn set_overrides_from_config(overrides: &BTreeMap<String, String>) {
+    let (table, errors) = LanguageOverrides::build(overrides);
+    for err in &errors {
+        tracing::warn!(error = %err, "Ignoring [languages] override");
+    }
+    let _ = OVERRIDES.set(table);
+}
+
+/// The installed override table (empty when none was configured).
+pub fn active() -> &'static LanguageOverrides {
+    static EMPTY: OnceLock<LanguageOverrides> = OnceLock::new();
+    OVERRIDES
+        .get()
+        .unwrap_or_else(|| EMPTY.get_or_init(LanguageOverrides::default))
+}
+
+/// Override decision for `path`: `None` when no rule matches, `Some(None)`
+/// when a rule says to skip it, `Some(Some(lang))` otherwise.
+pub fn lookup(path: &Path) -> Option<Option<Language>> {
+    active().lookup(path).map(|rule| rule.language)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn table(pairs: &[(&str, &str)]) -> (LanguageOverrides, Vec<OverrideError>) {
+        let map = pairs
+            .iter()
+            .map(|(k, v)| (k.to_string(), v.to_string()))
+            .collect();
+        LanguageOverrides::build(&map)
+    }
+
+    fn lang_of(t: &LanguageOverrides, path: &str) -> Option<Option<Language>> {
+        t.lookup(Path::new(path)).map(|r| r.language)
+    }
+
+    #[test]
+    fn file_name_patterns_match_in_any_directory() {
+        let (t, errors) = table(&[("BUILD", "starlark"), ("*.gotmpl", "go-template")]);
+        assert!(errors.is_empty());
+        assert_eq!(lang_of(&t, "BUILD"), Some(Some(Language::Python)));
+        assert_eq!(lang_of(&t, "pkg/lib/BUILD"), Some(Some(Language::Python)));
+        assert_eq!(
+            lang_of(&t, "/abs/proj/charts/x.gotmpl"),
+            Some(Some(Language::Plain))
+        );
+        assert_eq!(lang_of(&t, "BUILD.md"), None);
+        assert_eq!(lang_of(&t, "src/lib.rs"), None);
+    }
+
+    #[test]
+    fn path_patterns_respect_separators() {
+        let (t, _) = table(&[("include/*.h", "cpp")]);
+        assert_eq!(lang_of(&t, "include/a.h"), Some(Some(Language::Cpp)));
+        assert_eq!(lang_of(&t, "vendor/include/a.h"), Some(Some(Language::Cpp)));
+        // `*` does not cross a separator.
+        assert_eq!(lang_of(&t, "include/sub/a.h"), None);
+    }
+
+    #[test]
+    fn longest_pattern_wins_and_none_skips() {
+        let (t, _) = table(&[("*.snap", "plain"), ("*.tsx.snap", "none")]);
+        assert_eq!(lang_of(&t, "a/b.tsx.snap"), Some(None));
+        assert_eq!(lang_of(&t, "a/b.snap"), Some(Some(Language::Plain)));
+    }
+
+    #[test]
+    fn targets_resolve_names_aliases_and_extensions() {
+        let (t, errors) = table(&[("a", "Rust"), ("b", "bazel"), ("c", ".md"), ("d", "NONE")]);
+        assert!(errors.is_empty());
+        assert_eq!(lang_of(&t, "a"), Some(Some(Language::Rust)));
+        assert_eq!(lang_of(&t, "b"), Some(Some(Language::Python)));
+        assert_eq!(lang_of(&t, "c"), Some(Some(Language::Markdown)));
+        assert_eq!(lang_of(&t, "d"), Some(None));
+    }
+
+    #[test]
+    fn bad_rules_are_dropped_not_fatal() {
+        let (t, errors) = table(&[("[", "rust"), ("x", "klingon"), ("BUILD", "python")]);
+        assert_eq!(errors.len(), 2);
+        assert!(matches!(errors[0], OverrideError::InvalidGlob { .. }));
+        assert!(matches!(errors[1], OverrideError::UnknownTarget { .. }));
+        assert_eq!(t.rules().len(), 1);
+        assert_eq!(lang_of(&t, "BUILD"), Some(Some(Language::Python)));
+    }
+}
@@ -0,0 +1,353 @@
+//! External tree-sitter grammars loaded at runtime.
+//!
+//! A `[[grammar]]` entry in the user config names a shared library built
+//! from a tree-sitter grammar (`tree-sitter build` produces one), the
+//! extensions it claims, and a query file that maps the grammar's node
+//! kinds to cqs chunk types:
+//!
+//! ```scheme
+//! (entity_declaration name: (identifier) @name) @struct
+//! (architecture_body name: (identifier) @name) @class
+//! (procedure_body designator: (identifier) @name) @function
+//! ```
+//!
+//! Definition captures use the chunk type names (`@function`, `@method`,
+//! `@class`, `@struct`, `@enum`, `@interface`, `@module`, `@constant`, …);
+//! `@name` marks the symbol name. Files with a plugin extension are parsed
+//! as [`Language::Plugin`](super::Language) and win over a compiled-in
+//! language claiming the same extension. Plugins contribute chunks only:
+//! no call or type edges yet.
+//!
+//! Libraries load lazily, on the first lookup, and stay loaded for the life
+//! of the process. A grammar that fails to load (missing file or symbol, an
+//! ABI this tree-sitter can't read, a query that doesn't compile) is logged
+//! and skipped. WASM grammars are not supported; build a native library.
+
+use std::path::{Path, PathBuf};
+use std::sync::OnceLock;
+
+use super::ChunkType;
+use crate::config::GrammarConfig;
+
+/// Why a `[[grammar]]` entry was skipped.
+#[derive(Debug, thiserror::Error)]
+pub enum PluginError {
+    #[error("grammar '{name}': cannot read {path}: {source}")]
+    Io {
+        name: String,
+        path: PathBuf,
+        source: std::io::Error,
+    },
+    #[error("grammar '{name}': {path} is a WASM grammar; only native libraries are supported")]
+    Wasm { name: String, path: PathBuf },
+    #[error("grammar '{name}': failed to load {path}: {message}")]
+    Load {
+        name: String,
+        path: PathBuf,
+        message: String,
+    },
+    #[error("grammar '{name}': incompatible with this tree-sitter: {message}")]
+    Abi { name: String, message: String },
+    #[error("grammar '{name}': query {path} does not compile: {message}")]
+    Query {
+        name: String,
+        path: PathBuf,
+        message: String,
+    },
+    #[error("grammar '{name}': query {path} has no definition capture (@function, @class, ...)")]
+    NoCaptures { name: String, path: PathBuf },
+    #[error("grammar '{name}': no extensions configured")]
+    NoExtensions { name: String },
+    #[error("grammar '{name}': this build was compiled without the grammar-plugins feature")]
+    NotCompiled { name: String },
+}
+
+/// A loaded grammar with its compiled chunk query.
+pub struct GrammarPlugin {
+    pub name: String,
+    /// Lowercased, without the leading dot.
+    pub extensions: Vec<String>,
+    pub library: PathBuf,
+    pub language: tree_sitter::Language,
+    pub query: tree_sitter::Query,
+    /// Keeps the library mapped; `language` points into it.
+    #[cfg(feature = "grammar-plugins")]
+    _library: libloading::Library,
+}
+
+impl std::fmt::Debug for GrammarPlugin {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        f.debug_struct("GrammarPlugin")
+            .field("name", &self.name)
+            .field("extensions", &self.extensions)
+            .field("library", &self.library)
+            .finish_non_exhaustive()
+    }
+}
+
+impl GrammarPlugin {
+    /// Load the library named by `cfg`, check its ABI, and compile its query.
+    pub fn load(cfg: &GrammarConfig) -> Result<Self, PluginError> {
+        let name = cfg.name.clone();
+        if cfg.extensions.is_empty() {
+            return Err(PluginError::NoExtensions { name });
+        }
+        let is_wasm = cfg
+            .library
+            .extension()
+            .is_some_and(|e| e.eq_ignore_ascii_case("wasm"));
+        if is_wasm {
+            return Err(PluginError::Wasm {
+                name,
+                path: cfg.library.clone(),
+            });
+        }
+        let query_src = std::fs::read_to_string(&cfg.query).map_err(|source| PluginError::Io {
+            name: name.clone(),
+            path: cfg.query.clone(),
+            source,
+        })?;
+        let symbol = cfg
+            .symbol
+            .clone()
+            .unwrap_or_else(|| default_symbol(&cfg.name));
+        let (_library, language) = open_library(&name, &cfg.library, &symbol)?;
+
+        // `set_language` is where tree-sitter checks the grammar's ABI
+        // version; fail here rather than on the first file.
+        tree_sitter::Parser::new()
+            .set_language(&language)
+            .map_err(|e| PluginError::Abi {
+                name: name.clone(),
+                message: e.to_string(),
+            })?;
+        let query = compile_query(&name, &cfg.query, &language, &query_src)?;
+
+        Ok(Self {
+            name,
+            extensions: cfg
+                .extensions
+                .iter()
+                .map(|e| e.trim_start_matches('.').to_ascii_lowercase())
+                .collect(),
+            library: cfg.library.clone(),
+            language,
+            query,
+            #[cfg(feature = "grammar-plugins")]
+            _library,
+        })
+    }
+}
+
+/// `tree_sitter_<name>` with `-` folded to `_`, the symbol `tree-sitter
+/// generate` emits.
+fn default_symbol(name: &str) -> String {
+    format!(
+        "tree_sitter_{}",
+        name.replace('-', "_").to_ascii_lowercase()
+    )
+}
+
+/// Compile `src` and make sure it captures at least one chunk type.
+fn compile_query(
+    name: &str,
+    path: &Path,
+    language: &tree_sitter::Language,
+    src: &str,
+) -> Result<tree_sitter::Query, PluginError> {
+    let query = tree_sitter::Query::new(language, src).map_err(|e| PluginError::Query {
+        name: name.to_string(),
+        path: path.to_path_buf(),
+        message: e.to_string(),
+    })?;
+    let has_definition = ChunkType::CAPTURE_NAMES
+        .iter()
+        .any(|c| query.capture_index_for_name(c).is_some());
+    if !has_definition {
+        return Err(PluginError::NoCaptures {
+            name: name.to_string(),
+            path: path.to_path_buf(),
+        });
+    }
+    Ok(query)
+}
+
+#[cfg(feature = "grammar-plugins")]
+fn open_library(
+    name: &str,
+    path: &Path,
+    symbol: &str,
+) -> Result<(libloading::Library, tree_sitter::Language), PluginError> {
+    let load_err = |message: String| PluginError::Load {
+        name: name.to_string(),
+        path: path.to_path_buf(),
+        message,
+    };
+    if !path.is_file() {
+        return Err(load_err("no such file".to_string()));
+    }
+    // SAFETY: loading a library runs its initializers. The path comes from
+    // the user's own config (project configs cannot declare grammars), the
+    // same trust as a binary on their PATH.
+    let library = unsafe { libloading::Library::new(path) }.map_err(|e| load_err(e.to_string()))?;
+    // SAFETY: tree-sitter grammars export `const TSLanguage *tree_sitter_<name>(void)`,
+    // which matches `LanguageFn`'s signature. The pointer it returns lives in
+    // the library's static data, kept mapped by storing `library` alongside.
+    let language = unsafe {
+        let func = library
+            .get::<unsafe extern "C" fn() -> *const ()>(symbol.as_bytes())
+            .map_err(|e| load_err(format!("symbol {symbol}: {e}")))?;
+        tree_sitter::Language::new(tree_sitter::LanguageFn::from_raw(*func))
+    };
+    Ok((library, language))
+}
+
+#[cfg(not(feature = "grammar-plugins"))]
+fn open_library(
+    name: &str,
+    _path: &Path,
+    _symbol: &str,
+) -> Result<((), tree_sitter::Language), PluginError> {
+    Err(PluginError::NotCompiled {
+        name: name.to_string(),
+    })
+}
+
+/// `[[grammar]]` entries from the user config, pushed in once at startup by
+/// [`set_plugins_from_config`]. Loaded on first use by [`plugins`].
+static CONFIGURED: OnceLock<Vec<GrammarConfig>> = OnceLock::new();
+static LOADED: OnceLock<Vec<GrammarPlugin>> = OnceLock::new();
+
+/// Register `[[grammar]]` entries. Nothing is loaded until a lookup needs
+/// it. Must be called before the first walk; later calls are no-ops
+/// (OnceLock).
+pub fn set_plugins_from_config(grammars: &[GrammarConfig]) {
+    let _ = CONFIGURED.set(grammars.to_vec());
+}
+
+/// Every grammar that loaded, in config order. The first call loads them.
+pub fn plugins() -> &'static [GrammarPlugin] {
+    LOADED.get_or_init(|| {
+        let Some(configured) = CONFIGURED.get() else {
+            return Vec::new();
+        };
+        configured
+            .iter()
+            .filter_map(|cfg| match GrammarPlugin::load(cfg) {
+                Ok(plugin) => {
+                    tracing::info!(
+                        name = %plugin.name,
+                        library = %plugin.library.display(),
+                        extensions = ?plugin.extensions,
+                        "Loaded grammar plugin"
+                    );
+                    Some(plugin)
+                }
+                Err(e) => {
+                    tracing::warn!(error = %e, "Skipping grammar plugin");
+                    None
+                }
+            })
+            .collect()
+    })
+}
+
+/// Whether any grammar is configured (without loading one).
+fn configured() -> bool {
+    CONFIGURED.get().is_some_and(|c| !c.is_empty())
+}
+
+/// The plugin claiming extension `ext` (case-insensitive). The first entry
+/// in config order wins a shared extension.
+pub fn for_extension(ext: &str) -> Option<&'static GrammarPlugin> {
+    if !configured() {
+        return None;
+    }
+    plugins()
+        .iter()
+        .find(|p| p.extensions.iter().any(|e| e.eq_ignore_ascii_case(ext)))
+}
+
+/// The plugin for `path`, by its extension.
+pub fn for_path(path: &Path) -> Option<&'static GrammarPlugin> {
+    for_extension(path.extension()?.to_str()?)
+}
+
+/// Extensions claimed by loaded plugins.
+pub fn extensions() -> impl Iterator<Item = &'static str> {
+    let loaded: &'static [GrammarPlugin] = if configured() { plugins() } else { &[] };
+    loaded
+        .iter()
+        .flat_map(|p| p.extensions.iter().map(String::as_str))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn cfg(library: &str, query: &Path, extensions: &[&str]) -> GrammarConfig {
+        GrammarConfig {
+            name: "vhdl".to_string(),
+            library: PathBuf::from(library),
+            symbol: None,
+            extensions: extensions.iter().map(|e| e.to_string()).collect(),
+            query: query.to_path_buf(),
+        }
+    }
+
+    #[test]
+    fn default_symbol_follows_tree_sitter_naming() {
+        assert_eq!(default_symbol("vhdl"), "tree_sitter_vhdl");
+        assert_eq!(default_symbol("go-template"), "tree_sitter_go_template");
+    }
+
+    #[test]
+    fn load_rejects_bad_entries_before_dlopen() {
+        let dir = tempfile::TempDir::new().unwrap();
+        let query = dir.path().join("q.scm");
+        std::fs::write(&query, "(x) @function").unwrap();
+
+        let err = GrammarPlugin::load(&cfg("g.so", &query, &[])).unwrap_err();
+        assert!(matches!(err, PluginError::NoExtensions { .. }));
+
+        let err = GrammarPlugin::load(&cfg("g.wasm", &query, &["vhd"])).unwrap_err();
+        assert!(matches!(err, PluginError::Wasm { .. }));
+
+        let missing = dir.path().join("missing.scm");
+        let err = GrammarPlugin::load(&cfg("g.so", &missing, &["vhd"])).unwrap_err();
+        assert!(matches!(err, PluginError::Io { .. }));
+    }
+
+    #[cfg(feature = "grammar-plugins")]
+    #[test]
+    fn load_reports_missing_library() {
+        let dir = tempfile::TempDir::new().unwrap();
+        let query = dir.path().join("q.scm");
+        std::fs::write(&query, "(x) @function").unwrap();
+        let lib = dir.path().join("libtree-sitter-vhdl.so");
+        let err = GrammarPlugin::load(&cfg(lib.to_str().unwrap(), &query, &["vhd"])).unwrap_err();
+        assert!(matches!(err, PluginError::Load { .. }), "{err}");
+    }
+
+    #[cfg(feature = "lang-rust")]
+    #[test]
+    fn compile_query_requires_a_definition_capture() {
+        let rust = crate::language::Language::Rust.try_grammar().unwrap();
+        let path = Path::new("q.scm");
+        assert!(compile_query(
+            "t",
+            path,
+            &rust,
+            "(function_item name: (identifier) @name) @function"
+        )
+        .is_ok());
+        assert!(matches!(
+            compile_query("t", path, &rust, "(identifier) @name"),
+            Err(PluginError::NoCaptures { .. })
+        ));
+        assert!(matches!(
+            compile_query("t", path, &rust, "(not_a_node) @function"),
+            Err(PluginError::Query { .. })
+        ));
+    }
+}
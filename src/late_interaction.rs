@@ -0,0 +1,266 @@
+//! Late-interaction (ColBERT-style) rescoring of the top candidates.
+//! Experimental: not yet evaluated against the cross-encoder.
+//!
+//! A single vector per chunk has to squeeze every token into one point;
+//! late interaction keeps a vector per token and scores a chunk by MaxSim:
+//! each query token takes its best match among the chunk's tokens, and the
+//! score is the mean of those matches ([`TokenEmbeddings::maxsim`]).
+//!
+//! Token vectors are big — one model dimension per token, up to
+//! `max_tokens` tokens per chunk — so storing them is opt-in:
+//! `[late_interaction] enabled = true` makes `cqs index` fill
+//! `chunk_token_embeddings` (schema v51) from the model's per-token output
+//! ([`embed_missing_tokens`]). Each token is stored as an `f32` scale and
+//! `dim` signed bytes, about a quarter of the `f32` size.
+//!
+//! `--reranker late` (search and `cqs eval`) then embeds the query's tokens
+//! and rescores the first-stage top `candidates` by MaxSim, after filtering
+//! and before the result limit. The usual boosts and the threshold apply to
+//! the MaxSim score as they do to a cosine. A candidate without token
+//! vectors keeps its first-stage score; the brute-force scan (no vector
+//! index) is not rescored. Each rescored result carries a
+//! `late_interaction` rank signal with its MaxSim.
+
+use std::path::Path;
+
+use crate::embedder::Embedder;
+use crate::store::{ReadWrite, Store};
+
+/// Chunks embedded per batch by [`embed_missing_tokens`].
+const TOKEN_BATCH: usize = 32;
+
+/// Bytes of the per-token scale ahead of each quantized row.
+const SCALE_BYTES: usize = std::mem::size_of::<f32>();
+
+/// A text's per-token vectors, each L2-normalized, row-major.
+#[derive(Debug, Clone, PartialEq)]
+pub struct TokenEmbeddings {
+    dim: usize,
+    data: Vec<f32>,
+}
+
+impl TokenEmbeddings {
+    /// Wrap `data`, `tokens × dim` values. A trailing partial row is
+    /// dropped.
+    pub fn new(dim: usize, mut data: Vec<f32>) -> Self {
+        if dim == 0 {
+            data.clear();
+        } else {
+            data.truncate(data.len() - data.len() % dim);
+        }
+        Self { dim, data }
+    }
+
+    /// Vector width.
+    pub fn dim(&self) -> usize {
+        self.dim
+    }
+
+    /// Number of token vectors.
+    pub fn len(&self) -> usize {
+        if self.dim == 0 {
+            0
+        } else {
+            self.data.len() / self.dim
+        }
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.len() == 0
+    }
+
+    /// The token vectors.
+    pub fn rows(&self) -> impl Iterator<Item = &[f32]> {
+        self.data.chunks_exact(self.dim.max(1))
+    }
+
+    /// MaxSim of `self` (the query) against `doc`: the mean over query
+    /// tokens of the best dot product with any doc token. `None` when
+    /// either side is empty or the widths differ.
+    pub fn maxsim(&self, doc: &TokenEmbeddings) -> Option<f32> {
+        if self.is_empty() || doc.is_empty() || self.dim != doc.dim {
+            return None;
+        }
+        let total: f32 = self
+            .rows()
+            .map(|q| {
+                doc.rows()
+                    .map(|d| q.iter().zip(d).map(|(a, b)| a * b).sum::<f32>())
+                    .fold(f32::NEG_INFINITY, f32::max)
+            })
+            .sum();
+        Some(total / self.len() as f32)
+    }
+
+    /// Storage form: per token, its largest magnitude as a little-endian
+    /// `f32`, then each value scaled into `i8`.
+    pub fn to_bytes(&self) -> Vec<u8> {
+        let mut bytes = Vec::with_capacity(self.len() * (SCALE_BYTES + self.dim));
+        for row in self.rows() {
+            let scale = row.iter().fold(0.0f32, |m, v| m.max(v.abs()));
+            bytes.extend_from_slice(&scale.to_le_bytes());
+            bytes.extend(row.iter().map(|v| {
+                let q = if scale > 0.0 {
+                    (v / scale * 127.0).round()
+                } else {
+                    0.0
+                };
+                q.clamp(-127.0, 127.0) as i8 as u8
+            }));
+        }
+        bytes
+    }
+
+    /// Inverse of [`Self::to_bytes`] for `tokens` rows. `None` when the
+    /// length does not divide into that many rows.
+    pub fn from_bytes(bytes: &[u8], tokens: usize) -> Option<Self> {
+        if tokens == 0 || bytes.len() % tokens != 0 {
+            return None;
+        }
+        let row_len = bytes.len() / tokens;
+        let dim = row_len.checked_sub(SCALE_BYTES).filter(|&d| d > 0)?;
+        let mut data = Vec::with_capacity(tokens * dim);
+        for row in bytes.chunks_exact(row_len) {
+            let (scale, values) = row.split_at(SCALE_BYTES);
+            let scale = f32::from_le_bytes(scale.try_into().ok()?) / 127.0;
+            data.extend(values.iter().map(|&b| b as i8 as f32 * scale));
+        }
+        Some(Self { dim, data })
+    }
+}
+
+/// A search's late-interaction stage: the query's token vectors and how
+/// many first-stage candidates to rescore.
+#[derive(Debug, Clone)]
+pub struct LateQuery {
+    pub tokens: TokenEmbeddings,
+    pub candidates: usize,
+}
+
+/// The late-interaction stage for `query` in the project at `root`, or
+/// `None` — with a warning — when the index has no token vectors or the
+/// model has no per-token output.
+pub fn query_for_search<Mode>(
+    root: &Path,
+    store: &Store<Mode>,
+    embedder: &Embedder,
+    query: &str,
+) -> Option<LateQuery> {
+    let _span = tracing::info_span!("late_query_for_search").entered();
+    let config = crate::config::Config::load(root)
+        .late_interaction
+        .unwrap_or_default();
+    match store.token_embedding_progress() {
+        Ok(progress) if progress.embedded == 0 => {
+            tracing::warn!(
+                "Index has no token vectors; set [late_interaction] enabled = true and run \
+                 `cqs index`. Skipping late-interaction rescoring"
+            );
+            return None;
+        }
+        Ok(_) => {}
+        Err(e) => {
+            tracing::warn!(error = %e, "Failed to read token-vector coverage");
+            return None;
+        }
+    }
+    match embedder.embed_query_tokens(query, config.output()) {
+        Ok(tokens) => Some(LateQuery {
+            tokens,
+            candidates: config.candidates(),
+        }),
+        Err(e) => {
+            tracing::warn!(error = %e, "Query token embedding failed; skipping late interaction");
+            None
+        }
+    }
+}
+
+/// Store token vectors for every chunk still missing current ones, with
+/// the project's `[late_interaction]` settings. The text is what the body
+/// facet embeds: doc comment and code. Returns the number of chunks
+/// embedded — on a re-run, only the chunks added or changed since.
+pub fn embed_missing_tokens(
+    store: &Store<ReadWrite>,
+    embedder: &Embedder,
+    config: &crate::config::LateInteractionConfig,
+) -> anyhow::Result<usize> {
+    let _span = tracing::info_span!("embed_missing_tokens").entered();
+    let max_chars = embedder.model_config().max_seq_length.saturating_mul(4);
+    let mut embedded = 0;
+    loop {
+        let chunks = store.chunks_awaiting_token_embeddings(TOKEN_BATCH)?;
+        if chunks.is_empty() {
+            break;
+        }
+        let texts: Vec<String> = chunks
+            .iter()
+            .map(|c| crate::facets::Facet::Body.text(c, max_chars))
+            .collect();
+        let refs: Vec<&str> = texts.iter().map(String::as_str).collect();
+        let tokens = embedder.embed_document_tokens(&refs, config.output(), config.max_tokens())?;
+        let rows: Vec<_> = chunks
+            .iter()
+            .zip(tokens)
+            .map(|(c, t)| (c.id.clone(), c.content_hash.clone(), t))
+            .collect();
+        // A batch whose chunks all vanished mid-run writes nothing; stop
+        // rather than read the same gap again.
+        if store.upsert_token_embeddings(&rows)? == 0 {
+            break;
+        }
+        embedded += chunks.len();
+    }
+    tracing::info!(embedded, "Token vectors embedded");
+    Ok(embedded)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn unit(v: &[f32]) -> Vec<f32> {
+        let norm = v.iter().map(|x| x * x).sum::<f32>().sqrt();
+        v.iter().map(|x| x / norm).collect()
+    }
+
+    #[test]
+    fn maxsim_averages_best_token_matches() {
+        let query = TokenEmbeddings::new(2, [unit(&[1.0, 0.0]), unit(&[0.0, 1.0])].concat());
+        let doc = TokenEmbeddings::new(2, [unit(&[1.0, 0.0]), unit(&[1.0, 1.0])].concat());
+        // Token 1 matches exactly; token 2's best is the diagonal, 1/√2.
+        let expected = (1.0 + std::f32::consts::FRAC_1_SQRT_2) / 2.0;
+        assert!((query.maxsim(&doc).unwrap() - expected).abs() < 1e-6);
+        assert_eq!(query.maxsim(&TokenEmbeddings::new(2, vec![])), None);
+        assert_eq!(query.maxsim(&TokenEmbeddings::new(3, vec![0.0; 3])), None);
+    }
+
+    #[test]
+    fn quantized_round_trip() {
+        let tokens = TokenEmbeddings::new(
+            3,
+            [
+                unit(&[0.2, -0.5, 0.1]),
+                vec![0.0; 3],
+                unit(&[3.0, 4.0, 0.0]),
+            ]
+            .concat(),
+        );
+        let bytes = tokens.to_bytes();
+        assert_eq!(bytes.len(), 3 * (SCALE_BYTES + 3));
+        let back = TokenEmbeddings::from_bytes(&bytes, 3).unwrap();
+        assert_eq!(back.len(), 3);
+        for (a, b) in tokens.rows().flatten().zip(back.rows().flatten()) {
+            assert!((a - b).abs() < 0.01, "{a} vs {b}");
+        }
+        assert_eq!(TokenEmbeddings::from_bytes(&bytes, 4), None);
+        assert_eq!(TokenEmbeddings::from_bytes(&bytes[..4], 1), None);
+    }
+
+    #[test]
+    fn partial_rows_are_dropped() {
+        let tokens = TokenEmbeddings::new(2, vec![1.0, 0.0, 0.5]);
+        assert_eq!(tokens.len(), 1);
+        assert!(TokenEmbeddings::new(0, vec![1.0]).is_empty());
+    }
+}
@@ -70,36 +70,59 @@
 compile_error!("cqs requires a 64-bit target (target_pointer_width = \"64\")");
 
 // Public library API modules
+pub mod access;
 pub mod audit;
 pub mod aux_model;
+pub mod boilerplate;
+pub mod boosts;
 pub mod cache;
+pub mod calibration;
 pub mod config;
+pub mod config_reload;
 pub mod convert;
 pub mod embedder;
+pub mod facets;
+pub mod feedback;
 pub mod fs;
 pub mod hnsw;
 pub mod index;
 pub mod kind;
 pub mod language;
+pub mod late_interaction;
+pub mod logging;
 pub mod note;
+pub mod offline;
 pub mod output_format;
+pub mod ownership;
 pub mod parser;
+pub mod paths;
 pub mod reference;
+pub mod secrets;
+pub mod session;
+pub mod snippet;
+pub mod span_trace;
 pub mod splade;
 pub mod store;
+pub mod symlinks;
 pub mod train_data;
 pub mod vendored;
+pub mod walk_explain;
 pub mod worktree;
 pub mod worktree_overlay;
 
 pub mod ci;
+pub mod commits;
 pub mod eval;
 pub mod health;
 pub mod reranker;
 #[cfg(feature = "serve")]
 pub mod serve;
+pub mod shard;
 pub mod slot;
+pub mod snapshot;
 pub mod suggest;
+pub mod todos;
+pub mod vector_backend;
 
 // Internal modules - not part of public library API
 // These are pub(crate) to hide implementation details, but specific items are
@@ -109,11 +132,15 @@ pub(crate) mod diff_parse;
 pub use diff_parse::{parse_unified_diff, DiffHunk};
 pub mod drift;
 pub use drift::{detect_drift, DriftEntry, DriftResult};
+pub mod dupes;
+pub mod examples;
 pub(crate) mod focused_read;
+pub mod fuzzy;
 pub(crate) mod gather;
 pub(crate) mod impact;
 pub mod limits;
 pub(crate) mod math;
+pub mod module_graph;
 pub(crate) mod nl;
 pub(crate) mod onboard;
 pub(crate) mod ort_helpers;
@@ -152,6 +179,11 @@ pub mod daemon_translate;
 // wire shape.
 pub mod watch_status;
 
+// Prometheus-format counters shared by `cqs serve` (`/metrics`) and the
+// watch daemon's exporter. Lives in lib so the store and embedder can record
+// into the same statics the CLI renders.
+pub mod metrics;
+
 #[cfg(test)]
 pub mod test_helpers;
 
@@ -207,7 +239,7 @@ pub use store::{HnswKind, ModelInfo, SearchFilter, Store};
 // surface area — a new `pub struct InternalScratch` in `gather/mod.rs`
 // would auto-leak to `cqs::InternalScratch`. New pub items in submodules
 // stay internal until explicitly added here.
-pub use diff::{semantic_diff, DiffEntry, DiffResult};
+pub use diff::{index_diff, semantic_diff, DiffEntry, DiffResult, IndexDiff, ModifiedSymbol};
 pub use focused_read::COMMON_TYPES;
 pub use gather::{
     gather, gather_cross_index_with_index, gather_max_nodes, gather_with_graph,
@@ -220,8 +252,8 @@ pub mod cross_project {
         analyze_impact_cross, trace_cross, CrossProjectHop, CrossProjectTraceResult,
     };
     pub use crate::store::calls::cross_project::{
-        CrossProjectCallee, CrossProjectCaller, CrossProjectContext, CrossProjectTestChunk,
-        NamedStore,
+        CrossProjectCallee, CrossProjectCaller, CrossProjectContext, CrossProjectDeadCode,
+        CrossProjectTestChunk, NamedStore,
     };
 }
 pub use impact::{
@@ -237,21 +269,22 @@ pub use impact::{
 pub use nl::{
     generate_nl_description_with_seq_len, generate_nl_with_call_context_and_summary,
     generate_nl_with_template_and_seq_len, normalize_for_fts, tokenize_identifier, CallContext,
-    NlTemplate,
+    FtsAnalyzer, FtsLanguage, NlTemplate,
 };
 pub use onboard::{
     onboard, OnboardEntry, OnboardResult, OnboardSummary, TestEntry, TypeInfo,
     DEFAULT_ONBOARD_DEPTH,
 };
 pub use project::{
-    search_across_projects, CrossProjectResult, ProjectEntry, ProjectError, ProjectRegistry,
+    project_freshness, search_across_projects, search_federated, CrossProjectResult, ProjectEntry,
+    ProjectError, ProjectFreshness, ProjectRegistry,
 };
 pub use related::{find_related, RelatedFunction, RelatedResult};
 pub use scout::{
     scout, scout_with_options, scout_with_overlay, ChunkRole, FileGroup, ScoutChunk, ScoutOptions,
     ScoutResult, ScoutSummary, DEFAULT_SCOUT_SEARCH_LIMIT, DEFAULT_SCOUT_SEARCH_THRESHOLD,
 };
-pub use search::{parse_target, resolve_target, ResolvedTarget};
+pub use search::{parse_target, resolve_chunk_target, resolve_target, ResolvedTarget};
 pub use structural::Pattern;
 pub use task::{
     extract_modify_targets, task, task_with_resources, task_with_resources_overlay, FunctionRisk,
@@ -665,20 +698,20 @@ where
 ///
 /// Strips `root` prefix if present, converts backslashes to forward slashes.
 pub fn rel_display(path: &Path, root: &Path) -> String {
-    normalize_path(path.strip_prefix(root).unwrap_or(path))
+    normalize_path(paths::strip_root(path, root).unwrap_or(path))
 }
 
 /// Relativize `file` against `root`. On case-insensitive filesystems
-/// (Windows NTFS, macOS HFS+/APFS default), `path.starts_with(root)` can
-/// pass while `path.strip_prefix(root)` byte-equals fails — case skew
-/// between the canonicalized project root and the indexed chunk path.
-/// Centralizes the "warn-and-fall-back" shim `enumerate_files` also uses, so
-/// caller sites don't silently leak absolute paths into JSON envelopes
-/// documented as "relative to project root".
+/// (Windows NTFS, macOS HFS+/APFS default) the root is matched ignoring
+/// case ([`paths::strip_root`]), so case skew between the canonicalized
+/// project root and the indexed chunk path no longer leaks absolute paths
+/// into JSON envelopes documented as "relative to project root". A file
+/// that is genuinely outside `root` warns and falls back to the absolute
+/// path.
 pub fn relativize_or_warn(file: &Path, root: &Path) -> std::path::PathBuf {
-    match file.strip_prefix(root) {
-        Ok(rel) => rel.to_path_buf(),
-        Err(_) => {
+    match paths::strip_root(file, root) {
+        Some(rel) => rel.to_path_buf(),
+        None => {
             tracing::warn!(
                 file = %file.display(),
                 root = %root.display(),
@@ -802,13 +835,31 @@ pub fn enumerate_files(
 ///
 /// Memory: O(WalkBuilder internals + per-iteration scratch). The
 /// `ignore` crate keeps a small per-directory ignore stack; everything
-/// else is stack-local. Peak heap is independent of tree size.
+/// else is stack-local. Peak heap is independent of tree size, except
+/// when symlinks are followed: then a set of yielded origins dedupes files
+/// reached through more than one link.
+///
+/// Symlinks follow [`symlinks::policy`] (`[index] follow_symlinks`).
 pub fn enumerate_files_iter(
     root: &Path,
     extensions: &[&str],
     no_ignore: bool,
 ) -> anyhow::Result<impl Iterator<Item = PathBuf>> {
-    let _span = tracing::debug_span!("enumerate_files_iter", root = %root.display()).entered();
+    enumerate_files_iter_with(root, extensions, no_ignore, symlinks::policy())
+}
+
+fn enumerate_files_iter_with(
+    root: &Path,
+    extensions: &[&str],
+    no_ignore: bool,
+    follow: symlinks::SymlinkPolicy,
+) -> anyhow::Result<impl Iterator<Item = PathBuf>> {
+    let _span = tracing::debug_span!(
+        "enumerate_files_iter",
+        root = %root.display(),
+        follow_symlinks = %follow
+    )
+    .entered();
     use anyhow::Context;
     use ignore::WalkBuilder;
 
@@ -822,13 +873,15 @@ pub fn enumerate_files_iter(
     // `--no-ignore` disables it alongside .gitignore.
     // DoS rails: bound the walk's recursion depth and the number of files it
     // can yield. An adversarial or pathological tree (deep nesting, symlink
-    // farms — symlinks are never followed, but a real directory layout can
-    // still nest arbitrarily — or millions of matching files) would otherwise
-    // make every index / reconcile walk unbounded. These are generous
+    // farms — symlinks are not followed by default, and cycles are cut when
+    // they are, but a real directory layout can still nest arbitrarily — or
+    // millions of matching files) would otherwise make every index /
+    // reconcile walk unbounded. These are generous
     // ceilings, not tuning knobs; no real source tree approaches them.
     let max_depth = crate::limits::walk_max_depth();
     let max_files = crate::limits::walk_max_files();
 
+    let root_for_links = root.clone();
     let mut wb = WalkBuilder::new(&root);
     if !no_ignore {
         wb.add_custom_ignore_filename(".cqsignore");
@@ -839,9 +892,23 @@ pub fn enumerate_files_iter(
         .git_exclude(!no_ignore)
         .ignore(!no_ignore)
         .hidden(!no_ignore)
-        .follow_links(false)
+        .follow_links(follow.follows())
         .max_depth(Some(max_depth))
-        .filter_entry(|entry| {
+        .filter_entry(move |entry| {
+            // `within_root`: don't descend through a link that leaves the
+            // project. Files behind it would be dropped anyway; this saves
+            // walking an arbitrary foreign tree.
+            if follow == symlinks::SymlinkPolicy::WithinRoot
+                && entry.path_is_symlink()
+                && !dunce::canonicalize(entry.path())
+                    .is_ok_and(|t| paths::strip_root(&t, &root_for_links).is_some())
+            {
+                tracing::debug!(
+                    path = %entry.path().display(),
+                    "Skipping symlink leaving the project (follow_symlinks = within_root)"
+                );
+                return false;
+            }
             // Skip nested git worktrees. A linked worktree's `.git` is a file
             // (not a directory) that contains a `gitdir: ...` pointer. Indexing
             // the worktree would duplicate the entire source tree under a
@@ -883,10 +950,18 @@ pub fn enumerate_files_iter(
     let exts_for_filter = exts_owned.clone();
     let root_for_filter = root.clone();
     let root_for_cap = root.clone();
+    // Only populated while following links (see the doc comment).
+    let mut seen_origins: std::collections::HashSet<PathBuf> = std::collections::HashSet::new();
     Ok(walker
         .filter_map(|e| {
             e.map_err(|err| {
-                tracing::debug!(error = %err, "Failed to read directory entry during walk");
+                if symlinks::is_walk_loop(&err) {
+                    // The walker already refused to re-enter the ancestor;
+                    // say so, since the cycle is usually an accident.
+                    tracing::warn!(error = %err, "Symlink cycle in project tree; not descending");
+                } else {
+                    tracing::debug!(error = %err, "Failed to read directory entry during walk");
+                }
             })
             .ok()
         })
@@ -949,6 +1024,11 @@ pub fn enumerate_files_iter(
             }
         })
         .filter(move |e| {
+            // A `[languages] overrides` rule decides on its own: it can pull
+            // in an extensionless `BUILD` or drop a `*.tsx.snap`.
+            if let Some(decision) = language::overrides::lookup(e.path()) {
+                return decision.is_some();
+            }
             // `eq_ignore_ascii_case` compares case-insensitively without
             // allocating, unlike `to_ascii_lowercase`.
             e.path()
@@ -983,30 +1063,22 @@ pub fn enumerate_files_iter(
                     return None;
                 }
             };
-            if path.starts_with(&root_for_filter) {
-                // `starts_with` and `strip_prefix` can disagree on
-                // case-insensitive filesystems (NTFS, HFS+) — `Cqs` vs
-                // `cqs` matches under `starts_with` but `strip_prefix`
-                // does a byte-equal segment compare and refuses. Falling
-                // back to the absolute path would silently leak it into
-                // the relative-path workflow, breaking every downstream
-                // lookup keyed by relative origin. Skipping with a warn
-                // surfaces the disagreement so the operator can fix the
-                // case skew (or re-canonicalize the project root).
-                match path.strip_prefix(&root_for_filter) {
-                    Ok(rel) => Some(rel.to_path_buf()),
-                    Err(_) => {
-                        tracing::warn!(
-                            path = %path.display(),
-                            root = %root_for_filter.display(),
-                            "enumerate_files: starts_with passed but strip_prefix failed (case-insensitive filesystem?) — skipping"
-                        );
-                        None
-                    }
+            // `strip_prefix` compares bytes, so on case-insensitive
+            // filesystems (NTFS, HFS+) a `Cqs` vs `cqs` skew between the
+            // walked path and the canonical root would make it refuse.
+            // `strip_root` folds case there; anything it still rejects is
+            // genuinely outside the project, or behind a followed link
+            // that `origin_for` maps per the symlink policy.
+            match symlinks::origin_for(&root_for_filter, e.path(), &path, follow) {
+                Some(rel) if follow.follows() && !seen_origins.insert(rel.clone()) => {
+                    tracing::trace!(path = %e.path().display(), "Already reached through another link");
+                    None
+                }
+                Some(rel) => Some(rel),
+                None => {
+                    tracing::warn!(path = %e.path().display(), "Skipping path outside project");
+                    None
                 }
-            } else {
-                tracing::warn!(path = %e.path().display(), "Skipping path outside project");
-                None
             }
         })
         // File-count DoS rail: stop the walk once `max_files` paths have been
@@ -1479,6 +1551,42 @@ mentions = ["store.rs"]
         );
     }
 
+    /// `follow_symlinks` policies: a linked directory inside the project is
+    /// one set of origins (the real paths), a link leaving the project is
+    /// kept only under `true`, and a cycle terminates.
+    #[test]
+    #[cfg(unix)]
+    #[serial_test::serial(enumerate_files)]
+    fn test_enumerate_files_symlink_policies() {
+        use std::os::unix::fs::symlink;
+        use symlinks::SymlinkPolicy;
+
+        let outside = tempfile::TempDir::new().unwrap();
+        std::fs::write(outside.path().join("shared.rs"), "fn shared() {}").unwrap();
+        let dir = tempfile::TempDir::new().unwrap();
+        std::fs::create_dir(dir.path().join("src")).unwrap();
+        std::fs::write(dir.path().join("src/lib.rs"), "fn lib() {}").unwrap();
+        symlink(dir.path().join("src"), dir.path().join("alias")).unwrap();
+        symlink(outside.path(), dir.path().join("vendor")).unwrap();
+        symlink(dir.path(), dir.path().join("src/loop")).unwrap();
+
+        let walk = |policy| {
+            let mut files: Vec<String> =
+                enumerate_files_iter_with(dir.path(), &["rs"], false, policy)
+                    .unwrap()
+                    .map(|p| normalize_path(&p))
+                    .collect();
+            files.sort();
+            files
+        };
+        assert_eq!(walk(SymlinkPolicy::Never), vec!["src/lib.rs"]);
+        assert_eq!(walk(SymlinkPolicy::WithinRoot), vec!["src/lib.rs"]);
+        assert_eq!(
+            walk(SymlinkPolicy::Always),
+            vec!["src/lib.rs", "vendor/shared.rs"]
+        );
+    }
+
     /// Files exceeding `CQS_MAX_FILE_SIZE` must be silently filtered. Pins
     /// the size cap behaviour.
     #[test]
@@ -54,7 +54,10 @@ pub(crate) const PARSER_MAX_FILE_SIZE: u64 = 50 * 1024 * 1024;
 pub(crate) const PARSER_MAX_CHUNK_BYTES: usize = 100_000;
 
 /// Resolve the parser per-file size cap honoring `CQS_PARSER_MAX_FILE_SIZE`.
-pub(crate) fn parser_max_file_size() -> u64 {
+///
+/// `pub` so `cqs index --stdin` (binary crate) caps a piped buffer at the
+/// same size the parser would accept from disk.
+pub fn parser_max_file_size() -> u64 {
     parse_env_u64("CQS_PARSER_MAX_FILE_SIZE", PARSER_MAX_FILE_SIZE)
 }
 
@@ -202,6 +205,29 @@ pub(crate) fn parser_timeout_ms() -> Option<u64> {
     }
 }
 
+// ============ sandboxed parse caps ============
+
+/// Default wall-clock budget (milliseconds) for one file in a sandboxed parse
+/// worker (`parser::sandbox`). Covers the read, the parse, and extraction, so
+/// it sits well above `PARSER_TIMEOUT_MS`; the worker is killed when it runs
+/// out. Override via `CQS_SANDBOX_TIMEOUT_MS`.
+pub(crate) const SANDBOX_TIMEOUT_MS: u64 = 30_000;
+
+/// Default address-space cap (MiB) for a sandboxed parse worker. Applied with
+/// `RLIMIT_AS` on unix; the worker parses one file at a time, so this is the
+/// per-file budget. Override via `CQS_SANDBOX_MAX_MEMORY_MB`.
+pub(crate) const SANDBOX_MAX_MEMORY_MB: u64 = 2048;
+
+/// Resolve the per-file sandbox budget honoring `CQS_SANDBOX_TIMEOUT_MS`.
+pub(crate) fn sandbox_timeout_ms() -> u64 {
+    parse_env_u64("CQS_SANDBOX_TIMEOUT_MS", SANDBOX_TIMEOUT_MS)
+}
+
+/// Resolve the sandbox worker memory cap honoring `CQS_SANDBOX_MAX_MEMORY_MB`.
+pub(crate) fn sandbox_max_memory_mb() -> u64 {
+    parse_env_u64("CQS_SANDBOX_MAX_MEMORY_MB", SANDBOX_MAX_MEMORY_MB)
+}
+
 // ============ file-enumeration walk caps ============
 
 /// Default recursion-depth ceiling for `enumerate_files_iter`'s directory
@@ -227,6 +253,82 @@ pub(crate) fn walk_max_files() -> usize {
     parse_env_usize("CQS_WALK_MAX_FILES", WALK_MAX_FILES)
 }
 
+// ============ plain-text window sizing ============
+
+/// Default token budget per window of the plain-text fallback chunker
+/// (`parser::plain`). Sized well under every supported model's max sequence
+/// so a window embeds without further splitting.
+pub(crate) const PLAIN_WINDOW_TOKENS: usize = 256;
+
+/// Default overlap, in tokens, carried from the tail of one plain-text
+/// window into the head of the next so a sentence straddling the cut is
+/// still searchable whole.
+pub(crate) const PLAIN_WINDOW_OVERLAP: usize = 32;
+
+/// Resolve the plain-text window budget honoring `CQS_PLAIN_WINDOW_TOKENS`.
+pub(crate) fn plain_window_tokens() -> usize {
+    parse_env_usize_clamped("CQS_PLAIN_WINDOW_TOKENS", PLAIN_WINDOW_TOKENS, 16, 8192)
+}
+
+/// Resolve the plain-text window overlap honoring `CQS_PLAIN_WINDOW_OVERLAP`.
+/// Always strictly below half the window so every window advances.
+pub(crate) fn plain_window_overlap() -> usize {
+    let window = plain_window_tokens();
+    parse_env_usize("CQS_PLAIN_WINDOW_OVERLAP", PLAIN_WINDOW_OVERLAP).min(window / 2 - 1)
+}
+
+// ============ chunk tombstones ============
+
+/// Default number of days a deleted file's chunks stay in `chunk_tombstones`
+/// before the next prune purges them. A week covers a branch switch, an
+/// accidental `rm -rf` restored from git, or a file moved out and back.
+pub(crate) const TOMBSTONE_RETENTION_DAYS: u64 = 7;
+
+/// Resolve the tombstone retention window in days honoring
+/// `CQS_TOMBSTONE_RETENTION_DAYS`. `0` disables tombstones: deleted files'
+/// chunks are dropped outright, as before v37.
+pub(crate) fn tombstone_retention_days() -> u64 {
+    // Read directly so an explicit `0` means "disabled" rather than falling
+    // back to the default (which `parse_env_u64` would do, since it rejects 0).
+    match std::env::var("CQS_TOMBSTONE_RETENTION_DAYS") {
+        Ok(v) => v.trim().parse::<u64>().unwrap_or_else(|_| {
+            tracing::warn!(
+                env = "CQS_TOMBSTONE_RETENTION_DAYS",
+                value = %v,
+                "Invalid env var (must be a u64 day count, 0 to disable), using default {TOMBSTONE_RETENTION_DAYS}"
+            );
+            TOMBSTONE_RETENTION_DAYS
+        }),
+        Err(_) => TOMBSTONE_RETENTION_DAYS,
+    }
+}
+
+// ============ access statistics ============
+
+/// Default fraction of searches whose top results are counted as hits in
+/// `.cqs/access_stats.db`. Opens (`explain`, `read --focus`) are always
+/// counted; searches are frequent enough that a 1-in-10 sample ranks the same.
+pub(crate) const ACCESS_SAMPLE_RATE: f32 = 0.1;
+
+/// Whether access statistics are recorded at all. `CQS_ACCESS_STATS=0`
+/// turns recording off; existing rows are kept and still readable.
+pub(crate) fn access_stats_enabled() -> bool {
+    std::env::var("CQS_ACCESS_STATS").map_or(true, |v| v.trim() != "0")
+}
+
+/// Resolve the search-hit sample rate honoring `CQS_ACCESS_SAMPLE_RATE`,
+/// capped at 1.0 (every search).
+pub(crate) fn access_sample_rate() -> f32 {
+    parse_env_f32("CQS_ACCESS_SAMPLE_RATE", ACCESS_SAMPLE_RATE).min(1.0)
+}
+
+/// Whether searches are appended to the working session
+/// (`.cqs/session.json`, see `crate::session`). `CQS_SESSION_TRACKING=0`
+/// turns it off; `cqs session pin` and saved sessions still work.
+pub(crate) fn session_tracking_enabled() -> bool {
+    std::env::var("CQS_SESSION_TRACKING").map_or(true, |v| v.trim() != "0")
+}
+
 // ============ doc converter caps ============
 
 /// Default ceiling on per-archive page count for CHM, web help, and any
@@ -642,6 +744,39 @@ pub fn max_daemon_response_bytes() -> u64 {
     parse_env_u64("CQS_DAEMON_MAX_RESPONSE_BYTES", MAX_DAEMON_RESPONSE_BYTES)
 }
 
+// ============ daemon shutdown drain ============
+
+/// Default seconds the watch daemon gives in-flight socket queries to finish
+/// after SIGTERM / Ctrl+C before it stops waiting and moves on to flushing
+/// and checkpointing the index. Sits well inside systemd's default 90 s
+/// `TimeoutStopSec`.
+pub const DAEMON_DRAIN_SECS_DEFAULT: u64 = 10;
+
+/// Upper clamp on `CQS_DAEMON_DRAIN_SECS`.
+const DAEMON_DRAIN_SECS_MAX: u64 = 300;
+
+/// Resolve the in-flight query drain budget for `cqs watch --serve` shutdown.
+///
+/// `CQS_DAEMON_DRAIN_SECS=0` skips the wait (in-flight handlers are abandoned
+/// as before). Values above 300 clamp to 300; garbage warns and falls back to
+/// [`DAEMON_DRAIN_SECS_DEFAULT`].
+pub fn daemon_drain_secs() -> u64 {
+    match std::env::var("CQS_DAEMON_DRAIN_SECS") {
+        Ok(v) => match v.parse::<u64>() {
+            Ok(n) => n.min(DAEMON_DRAIN_SECS_MAX),
+            Err(_) => {
+                tracing::warn!(
+                    env = "CQS_DAEMON_DRAIN_SECS",
+                    value = %v,
+                    "Invalid env var (must be a non-negative integer), using default {DAEMON_DRAIN_SECS_DEFAULT}"
+                );
+                DAEMON_DRAIN_SECS_DEFAULT
+            }
+        },
+        Err(_) => DAEMON_DRAIN_SECS_DEFAULT,
+    }
+}
+
 // ============ cqs serve idle eviction ============
 
 /// Default idle-shutdown threshold for `cqs serve` in minutes. After this
@@ -681,6 +816,48 @@ pub fn serve_max_concurrent_requests() -> usize {
     )
 }
 
+// ============ cqs serve per-client rate limit ============
+
+/// Default sustained request rate per client (token or peer IP) in
+/// `cqs serve`, in requests per second. An interactive user clicking around
+/// the graph stays far below it; a script looping on `/api/search` does not.
+pub const SERVE_RATE_LIMIT_DEFAULT: f64 = 20.0;
+
+/// Default burst per client: how many requests a client that has been idle
+/// may fire back-to-back before the sustained rate applies. Covers a page
+/// load plus its fan-out of JSON fetches.
+pub const SERVE_RATE_BURST_DEFAULT: usize = 100;
+
+/// Resolve the per-client sustained rate for `cqs serve`'s token-bucket
+/// limiter (`src/serve/ratelimit.rs`).
+///
+/// `CQS_SERVE_RATE_LIMIT` is requests per second (fractions allowed, e.g.
+/// `0.5` = one every two seconds). `0` disables rate limiting. Garbage,
+/// negative, or non-finite values warn and fall back to
+/// [`SERVE_RATE_LIMIT_DEFAULT`].
+pub fn serve_rate_limit_per_sec() -> f64 {
+    match std::env::var("CQS_SERVE_RATE_LIMIT") {
+        Ok(v) => match v.parse::<f64>() {
+            Ok(n) if n.is_finite() && n >= 0.0 => n,
+            _ => {
+                tracing::warn!(
+                    env = "CQS_SERVE_RATE_LIMIT",
+                    value = %v,
+                    "Invalid env var (must be a finite non-negative number), using default {SERVE_RATE_LIMIT_DEFAULT}"
+                );
+                SERVE_RATE_LIMIT_DEFAULT
+            }
+        },
+        Err(_) => SERVE_RATE_LIMIT_DEFAULT,
+    }
+}
+
+/// Resolve the per-client burst for `cqs serve`'s rate limiter.
+/// `CQS_SERVE_RATE_BURST` overrides, clamped to `[1, 100000]`.
+pub fn serve_rate_burst() -> usize {
+    parse_env_usize_clamped("CQS_SERVE_RATE_BURST", SERVE_RATE_BURST_DEFAULT, 1, 100_000)
+}
+
 /// Resolve the idle-shutdown threshold for `cqs serve` in minutes.
 /// `CQS_SERVE_IDLE_MINUTES=0` disables idle eviction (server runs until
 /// killed). Garbage / missing values fall back to
@@ -1088,4 +1265,36 @@ mod tests {
             })
             .unwrap();
     }
+
+    #[test]
+    #[serial]
+    fn serve_rate_limit_zero_disables_and_garbage_falls_back() {
+        std::env::set_var("CQS_SERVE_RATE_LIMIT", "0");
+        assert_eq!(serve_rate_limit_per_sec(), 0.0);
+        std::env::set_var("CQS_SERVE_RATE_LIMIT", "0.5");
+        assert_eq!(serve_rate_limit_per_sec(), 0.5);
+        for bad in ["-1", "NaN", "inf", "fast"] {
+            std::env::set_var("CQS_SERVE_RATE_LIMIT", bad);
+            assert_eq!(
+                serve_rate_limit_per_sec(),
+                SERVE_RATE_LIMIT_DEFAULT,
+                "accepted {bad:?}"
+            );
+        }
+        std::env::remove_var("CQS_SERVE_RATE_LIMIT");
+    }
+
+    #[test]
+    #[serial]
+    fn daemon_drain_secs_zero_allowed_and_clamped() {
+        std::env::remove_var("CQS_DAEMON_DRAIN_SECS");
+        assert_eq!(daemon_drain_secs(), DAEMON_DRAIN_SECS_DEFAULT);
+        std::env::set_var("CQS_DAEMON_DRAIN_SECS", "0");
+        assert_eq!(daemon_drain_secs(), 0);
+        std::env::set_var("CQS_DAEMON_DRAIN_SECS", "100000");
+        assert_eq!(daemon_drain_secs(), DAEMON_DRAIN_SECS_MAX);
+        std::env::set_var("CQS_DAEMON_DRAIN_SECS", "-3");
+        assert_eq!(daemon_drain_secs(), DAEMON_DRAIN_SECS_DEFAULT);
+        std::env::remove_var("CQS_DAEMON_DRAIN_SECS");
+    }
 }
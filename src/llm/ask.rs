@@ -0,0 +1,219 @@
+//! Answer synthesis (`cqs ask "<question>"`).
+//!
+//! The caller retrieves the chunks (plain `cqs search` ranking); this module
+//! numbers them, asks the configured provider to answer from those sources
+//! only with a `[N]` citation per claim, and reports which sources the
+//! answer cited. Like the paraphrase pass nothing is persisted: one
+//! single-item batch in, one answer out. The answer arrives whole when the
+//! batch ends — no provider here streams.
+//!
+//! Sources from files matched by `llm_exclude` keep their number but are
+//! sent as a placeholder, so citations still line up with the local list.
+
+use std::path::PathBuf;
+
+use super::exclude::LlmExclusion;
+use super::provider::{BatchKind, BatchProvider, BatchSubmitItem};
+use super::{LlmClient, LlmConfig, LlmError};
+
+/// Max tokens per answer — a few cited paragraphs.
+const ASK_MAX_TOKENS: u32 = 1024;
+
+/// `custom_id` of the single batch item.
+const ASK_ITEM_ID: &str = "ask";
+
+/// One retrieved chunk offered to the model as a numbered source.
+#[derive(Clone)]
+pub struct AskSource {
+    /// Normalized origin path
+    pub origin: String,
+    pub name: String,
+    pub line_start: u32,
+    pub line_end: u32,
+    pub language: String,
+    pub content: String,
+}
+
+impl AskSource {
+    /// `origin:start-end`, the form the answer's citations resolve to.
+    pub fn location(&self) -> String {
+        format!("{}:{}-{}", self.origin, self.line_start, self.line_end)
+    }
+}
+
+/// The model's answer and the sources it cited.
+#[derive(Debug, Clone, PartialEq)]
+pub struct Answer {
+    pub text: String,
+    /// 1-based source numbers cited in `text`, in first-citation order
+    pub cited: Vec<usize>,
+}
+
+/// Answer `question` from `sources` with the configured provider.
+pub fn answer_question(
+    config: &crate::config::Config,
+    question: &str,
+    sources: &[AskSource],
+    quiet: bool,
+) -> Result<Answer, LlmError> {
+    let _span = tracing::info_span!("answer_question", sources = sources.len()).entered();
+    let llm_config = LlmConfig::resolve(config)?;
+    tracing::info!(model = %llm_config.model, "Answer synthesis starting");
+    let client = super::create_client(llm_config, None)?;
+    answer_with(
+        client.as_ref(),
+        question,
+        sources,
+        super::exclude::active(),
+        quiet,
+    )
+}
+
+fn answer_with(
+    client: &dyn BatchProvider,
+    question: &str,
+    sources: &[AskSource],
+    policy: &LlmExclusion,
+    quiet: bool,
+) -> Result<Answer, LlmError> {
+    let (shown, origins) = withhold_excluded(sources, policy);
+    if origins.is_empty() && !sources.is_empty() {
+        return Err(LlmError::Excluded(sources.len()));
+    }
+    let item = BatchSubmitItem {
+        custom_id: ASK_ITEM_ID.to_string(),
+        content: LlmClient::build_ask_prompt(question, &shown),
+        context: String::new(),
+        language: String::new(),
+        sources: origins,
+    };
+    let batch_id = client.submit_batch(BatchKind::Prebuilt, &[item], ASK_MAX_TOKENS)?;
+    client.wait_for_batch(&batch_id, quiet)?;
+    let text = client
+        .fetch_batch_results(&batch_id)?
+        .remove(ASK_ITEM_ID)
+        .map(|t| t.trim().to_string())
+        .filter(|t| !t.is_empty())
+        .ok_or_else(|| LlmError::BatchFailed("provider returned no answer".to_string()))?;
+    let cited = cited_sources(&text, sources.len());
+    Ok(Answer { text, cited })
+}
+
+/// Replace sources whose file is excluded by `policy` with a placeholder,
+/// keeping numbering. Returns the sources to show and the origins of the
+/// ones actually sent.
+fn withhold_excluded(
+    sources: &[AskSource],
+    policy: &LlmExclusion,
+) -> (Vec<AskSource>, Vec<PathBuf>) {
+    let mut origins = Vec::new();
+    let shown = sources
+        .iter()
+        .map(|s| {
+            let origin = PathBuf::from(&s.origin);
+            if policy.is_excluded(&origin) {
+                tracing::info!(origin = %s.origin, "llm_exclude withheld ask source");
+                AskSource {
+                    origin: "withheld".to_string(),
+                    name: String::new(),
+                    line_start: 0,
+                    line_end: 0,
+                    language: String::new(),
+                    content: "(withheld: excluded by llm_exclude)".to_string(),
+                }
+            } else {
+                origins.push(origin);
+                s.clone()
+            }
+        })
+        .collect();
+    (shown, origins)
+}
+
+/// `[N]` markers in `text` that name one of `count` sources, deduplicated in
+/// first-citation order. Also reads grouped markers like `[1, 3]`.
+fn cited_sources(text: &str, count: usize) -> Vec<usize> {
+    let mut cited = Vec::new();
+    let mut rest = text;
+    while let Some(open) = rest.find('[') {
+        rest = &rest[open + 1..];
+        let Some(close) = rest.find(']') else { break };
+        let inner = &rest[..close];
+        let numbers: Option<Vec<usize>> = inner
+            .split(',')
+            .map(|n| n.trim().parse::<usize>().ok())
+            .collect();
+        for n in numbers.into_iter().flatten() {
+            if (1..=count).contains(&n) && !cited.contains(&n) {
+                cited.push(n);
+            }
+        }
+        rest = &rest[close + 1..];
+    }
+    cited
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::llm::provider::MockBatchProvider;
+    use std::collections::HashMap;
+
+    fn source(origin: &str, name: &str) -> AskSource {
+        AskSource {
+            origin: origin.to_string(),
+            name: name.to_string(),
+            line_start: 10,
+            line_end: 24,
+            language: "rust".to_string(),
+            content: format!("fn {name}() {{}}"),
+        }
+    }
+
+    #[test]
+    fn cited_sources_reads_single_and_grouped_markers() {
+        let text = "Signatures are checked in [2], using the key from [1, 2]. \
+                    See also [7] and [x] and arr[0].";
+        assert_eq!(cited_sources(text, 3), vec![2, 1]);
+        assert!(cited_sources("no citations", 3).is_empty());
+    }
+
+    #[test]
+    fn answer_with_returns_text_and_citations() {
+        let mut results = HashMap::new();
+        results.insert(
+            ASK_ITEM_ID.to_string(),
+            "\n`verify_signature` does it [1].\n".to_string(),
+        );
+        let mock = MockBatchProvider::new("msgbatch_ask", results);
+        let sources = [source("src/webhook.rs", "verify_signature")];
+        let none = LlmExclusion::default();
+        let answer =
+            answer_with(&mock, "where are webhooks verified?", &sources, &none, true).unwrap();
+        assert_eq!(answer.text, "`verify_signature` does it [1].");
+        assert_eq!(answer.cited, vec![1]);
+        assert_eq!(sources[0].location(), "src/webhook.rs:10-24");
+
+        let empty = MockBatchProvider::new("msgbatch_ask", HashMap::new());
+        assert!(answer_with(&empty, "q", &sources, &none, true).is_err());
+    }
+
+    #[test]
+    fn excluded_sources_keep_their_number_but_not_their_content() {
+        let (policy, _) = LlmExclusion::new(&["internal/crypto/**".to_string()]);
+        let sources = [
+            source("internal/crypto/aes.rs", "encrypt"),
+            source("src/api.rs", "handler"),
+        ];
+        let (shown, origins) = withhold_excluded(&sources, &policy);
+        assert_eq!(shown.len(), 2);
+        assert_eq!(shown[0].origin, "withheld");
+        assert!(!shown[0].content.contains("encrypt"));
+        assert_eq!(shown[1].content, "fn handler() {}");
+        assert_eq!(origins, [PathBuf::from("src/api.rs")]);
+
+        let mock = MockBatchProvider::new("msgbatch_ask", HashMap::new());
+        let err = answer_with(&mock, "q", &sources[..1], &policy, true).unwrap_err();
+        assert!(matches!(err, LlmError::Excluded(1)), "{err}");
+    }
+}
@@ -568,7 +568,7 @@ impl BatchPhase2<'_> {
                             purpose = self.purpose,
                             "Pending batch still active, resuming"
                         );
-                        pending
+                        Some(pending)
                     }
                     Ok(status) => {
                         // Log the actual status so we can diagnose
@@ -606,6 +606,12 @@ impl BatchPhase2<'_> {
             }
             _ => self.submit_fresh(client, store, batch_items, set_pending, submit)?,
         };
+        let Some(batch_id) = batch_id else {
+            // `llm_exclude` withheld every item; nothing was submitted.
+            drop(_batch_lock);
+            self.cleanup_batch_lock();
+            return Ok(HashMap::new());
+        };
 
         let result = self.resume(client, store, &batch_id, set_pending);
         // Clean up lock file after batch operation completes
@@ -707,7 +713,8 @@ impl BatchPhase2<'_> {
         Ok(valid_results)
     }
 
-    /// Submit a fresh batch and store its pending ID.
+    /// Submit a fresh batch and store its pending ID. `None` when the
+    /// `llm_exclude` guard withheld every item, so nothing was submitted.
     fn submit_fresh(
         &self,
         client: &dyn BatchProvider,
@@ -715,14 +722,25 @@ impl BatchPhase2<'_> {
         batch_items: &[super::provider::BatchSubmitItem],
         set_pending: &PendingFn,
         submit: &SubmitFn,
-    ) -> Result<String, LlmError> {
+    ) -> Result<Option<String>, LlmError> {
         let _span = tracing::info_span!("submit_fresh", purpose = self.purpose).entered();
         tracing::info!(
             count = batch_items.len(),
             purpose = self.purpose,
             "Submitting batch to LLM provider"
         );
-        let id = submit(client, batch_items, self.max_tokens)?;
+        let id = match submit(client, batch_items, self.max_tokens) {
+            Ok(id) => id,
+            Err(LlmError::Excluded(withheld)) => {
+                tracing::info!(
+                    withheld,
+                    purpose = self.purpose,
+                    "Every batch item is excluded by llm_exclude, nothing submitted"
+                );
+                return Ok(None);
+            }
+            Err(e) => return Err(e),
+        };
         set_pending(store, Some(&id)).map_err(|e| {
             tracing::error!(
                 error = %e,
@@ -735,7 +753,7 @@ impl BatchPhase2<'_> {
             LlmError::Store(e)
         })?;
         tracing::info!(batch_id = %id, purpose = self.purpose, "Batch submitted, waiting for results");
-        Ok(id)
+        Ok(Some(id))
     }
 }
 
@@ -884,6 +902,7 @@ mod tests {
             content: "fn aaa() {}".to_string(),
             context: "function".to_string(),
             language: "rust".to_string(),
+            sources: Vec::new(),
         }];
 
         let result = phase2.submit_or_resume(
@@ -1007,6 +1026,7 @@ mod tests {
             content: "fn ghost() {}".to_string(),
             context: "function".to_string(),
             language: "rust".to_string(),
+            sources: Vec::new(),
         }];
 
         let result = phase2.submit_or_resume(
@@ -132,11 +132,14 @@ pub fn needs_doc_comment(chunk: &ChunkSummary) -> bool {
 /// and returns the results. Cached results are returned without an API call.
 /// `max_docs` limits how many functions to process (0 = unlimited).
 /// `improve_all` regenerates docs for all functions, even those with existing adequate docs.
+/// `public_only` restricts candidates to public symbols by their language's visibility
+/// rule (`where_to_add::is_public_symbol`).
 pub fn doc_comment_pass(
     store: &Store,
     config: &crate::config::Config,
     max_docs: usize,
     improve_all: bool,
+    public_only: bool,
     lock_dir: Option<&std::path::Path>,
 ) -> Result<Vec<crate::doc_writer::DocCommentResult>, LlmError> {
     let _span = tracing::info_span!("doc_comment_pass").entered();
@@ -184,6 +187,9 @@ pub fn doc_comment_pass(
         cursor = next;
 
         for cs in chunks {
+            if public_only && !crate::where_to_add::is_public_symbol(&cs) {
+                continue;
+            }
             if improve_all {
                 // In improve-all mode, include all callable non-test source chunks
                 if cs.chunk_type.is_callable()
@@ -275,6 +281,7 @@ pub fn doc_comment_pass(
                     content,
                     context: cs.chunk_type.to_string(),
                     language: cs.language.to_string(),
+                    sources: vec![cs.file.clone()],
                 });
                 if items.len() >= max_batch_size {
                     // Surface the truncation hint on stderr so agents can re-run.
@@ -392,6 +399,8 @@ mod tests {
             parent_type_name: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 
@@ -413,6 +422,8 @@ mod tests {
             parent_type_name: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 
@@ -655,7 +666,7 @@ mod tests {
             .expect("init store");
 
         let config = crate::config::Config::default();
-        let result = doc_comment_pass(&store, &config, 0, false, None);
+        let result = doc_comment_pass(&store, &config, 0, false, false, None);
 
         match prev_provider {
             Some(v) => std::env::set_var("CQS_LLM_PROVIDER", v),
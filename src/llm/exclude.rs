@@ -0,0 +1,295 @@
+//! Per-path LLM exclusion policy (`llm_exclude` in `.cqs.toml`).
+//!
+//! ```toml
+//! llm_exclude = ["internal/crypto/**", "*.pem", "secrets/*.yaml"]
+//! ```
+//!
+//! Chunks from a matching file are never sent to any provider. The policy
+//! is enforced once, at the provider boundary: [`crate::llm::create_client`]
+//! wraps every provider in [`ExclusionGuard`], which drops each
+//! [`BatchSubmitItem`] whose `sources` name an excluded file before the
+//! inner provider sees it. Callers only have to say which files an item's
+//! text came from; summaries, doc comments, HyDE, paraphrase, and
+//! `cqs ask` all go through the same guard.
+//!
+//! A pattern without `/` matches the file name in any directory; one with
+//! `/` matches the path from the project root. Paths are compared in
+//! normalized, forward-slash form. A project `.cqs.toml` adds to the user
+//! config's list rather than replacing it.
+
+use std::collections::HashMap;
+use std::path::Path;
+use std::sync::OnceLock;
+
+use super::provider::{BatchKind, BatchProvider, BatchSubmitItem};
+use super::LlmError;
+
+/// Compiled `llm_exclude` globs.
+#[derive(Debug, Default)]
+pub struct LlmExclusion {
+    set: globset::GlobSet,
+    patterns: Vec<String>,
+}
+
+impl LlmExclusion {
+    /// Compile `patterns`. Bad globs are returned and left out.
+    pub fn new(patterns: &[String]) -> (Self, Vec<(String, globset::Error)>) {
+        let mut builder = globset::GlobSetBuilder::new();
+        let mut accepted = Vec::new();
+        let mut errors = Vec::new();
+        for pattern in patterns {
+            let trimmed = pattern.trim_start_matches("./").trim_start_matches('/');
+            let anchored = if trimmed.contains('/') {
+                trimmed.to_string()
+            } else {
+                format!("**/{trimmed}")
+            };
+            match globset::GlobBuilder::new(&anchored)
+                .literal_separator(true)
+                .build()
+            {
+                Ok(glob) => {
+                    builder.add(glob);
+                    accepted.push(pattern.clone());
+                }
+                Err(e) => errors.push((pattern.clone(), e)),
+            }
+        }
+        let set = builder.build().unwrap_or_else(|e| {
+            tracing::warn!(error = %e, "llm_exclude glob set failed to build; excluding nothing");
+            accepted.clear();
+            globset::GlobSet::empty()
+        });
+        (
+            Self {
+                set,
+                patterns: accepted,
+            },
+            errors,
+        )
+    }
+
+    /// No patterns: every file may be sent.
+    pub fn is_empty(&self) -> bool {
+        self.patterns.is_empty()
+    }
+
+    /// The accepted patterns, as written.
+    pub fn patterns(&self) -> &[String] {
+        &self.patterns
+    }
+
+    /// Whether chunks from `path` must stay local.
+    pub fn is_excluded(&self, path: &Path) -> bool {
+        if self.is_empty() {
+            return false;
+        }
+        let normalized = crate::normalize_path(path);
+        let relative = normalized.trim_start_matches("./");
+        self.set.is_match(relative)
+    }
+}
+
+/// `llm_exclude` from `.cqs.toml`, pushed in once at startup.
+static CONFIG: OnceLock<Vec<String>> = OnceLock::new();
+
+/// Install `llm_exclude`. Must run before the first provider call; later
+/// calls are no-ops (OnceLock).
+pub fn set_from_config(patterns: &[String]) {
+    let _ = CONFIG.set(patterns.to_vec());
+}
+
+/// The policy for this process. Built on first use; bad globs are logged
+/// and skipped.
+pub fn active() -> &'static LlmExclusion {
+    static POLICY: OnceLock<LlmExclusion> = OnceLock::new();
+    POLICY.get_or_init(|| {
+        let (policy, errors) = LlmExclusion::new(CONFIG.get().map_or(&[], Vec::as_slice));
+        for (pattern, error) in &errors {
+            tracing::warn!(pattern, error = %error, "Ignoring llm_exclude pattern");
+        }
+        policy
+    })
+}
+
+/// Shorthand for `active().is_excluded(path)`.
+pub fn is_excluded(path: &Path) -> bool {
+    active().is_excluded(path)
+}
+
+/// Provider wrapper that enforces an [`LlmExclusion`] on every submission.
+/// Everything except `submit_batch` passes straight through.
+pub(crate) struct ExclusionGuard {
+    inner: Box<dyn BatchProvider>,
+    policy: &'static LlmExclusion,
+}
+
+impl ExclusionGuard {
+    pub(crate) fn new(inner: Box<dyn BatchProvider>, policy: &'static LlmExclusion) -> Self {
+        Self { inner, policy }
+    }
+}
+
+impl BatchProvider for ExclusionGuard {
+    fn submit_batch(
+        &self,
+        kind: BatchKind,
+        items: &[BatchSubmitItem],
+        max_tokens: u32,
+    ) -> Result<String, LlmError> {
+        let blocked = |item: &BatchSubmitItem| {
+            item.sources
+                .iter()
+                .any(|source| self.policy.is_excluded(source))
+        };
+        if !items.iter().any(blocked) {
+            return self.inner.submit_batch(kind, items, max_tokens);
+        }
+        let allowed: Vec<BatchSubmitItem> = items.iter().filter(|i| !blocked(i)).cloned().collect();
+        let withheld = items.len() - allowed.len();
+        tracing::info!(
+            purpose = kind.purpose_label(),
+            withheld,
+            sent = allowed.len(),
+            "llm_exclude withheld batch items"
+        );
+        if allowed.is_empty() {
+            return Err(LlmError::Excluded(withheld));
+        }
+        self.inner.submit_batch(kind, &allowed, max_tokens)
+    }
+
+    fn check_batch_status(&self, batch_id: &str) -> Result<String, LlmError> {
+        self.inner.check_batch_status(batch_id)
+    }
+
+    fn wait_for_batch(&self, batch_id: &str, quiet: bool) -> Result<(), LlmError> {
+        self.inner.wait_for_batch(batch_id, quiet)
+    }
+
+    fn fetch_batch_results(&self, batch_id: &str) -> Result<HashMap<String, String>, LlmError> {
+        self.inner.fetch_batch_results(batch_id)
+    }
+
+    fn is_valid_batch_id(&self, id: &str) -> bool {
+        self.inner.is_valid_batch_id(id)
+    }
+
+    fn model_name(&self) -> &str {
+        self.inner.model_name()
+    }
+
+    fn validate_model(&self, model: &str) -> Result<(), LlmError> {
+        self.inner.validate_model(model)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::llm::provider::MockBatchProvider;
+    use std::path::PathBuf;
+    use std::sync::{Arc, Mutex};
+
+    fn policy(patterns: &[&str]) -> &'static LlmExclusion {
+        let patterns: Vec<String> = patterns.iter().map(|p| p.to_string()).collect();
+        let (policy, errors) = LlmExclusion::new(&patterns);
+        assert!(errors.is_empty());
+        Box::leak(Box::new(policy))
+    }
+
+    fn item(id: &str, sources: &[&str]) -> BatchSubmitItem {
+        BatchSubmitItem {
+            custom_id: id.to_string(),
+            content: format!("fn {id}() {{}}"),
+            context: String::new(),
+            language: "rust".to_string(),
+            sources: sources.iter().map(PathBuf::from).collect(),
+        }
+    }
+
+    /// Records what reached the inner provider.
+    struct Recording {
+        inner: MockBatchProvider,
+        seen: Arc<Mutex<Vec<String>>>,
+    }
+
+    impl BatchProvider for Recording {
+        fn submit_batch(
+            &self,
+            kind: BatchKind,
+            items: &[BatchSubmitItem],
+            max_tokens: u32,
+        ) -> Result<String, LlmError> {
+            self.seen
+                .lock()
+                .unwrap()
+                .extend(items.iter().map(|i| i.custom_id.clone()));
+            self.inner.submit_batch(kind, items, max_tokens)
+        }
+        fn check_batch_status(&self, id: &str) -> Result<String, LlmError> {
+            self.inner.check_batch_status(id)
+        }
+        fn wait_for_batch(&self, id: &str, quiet: bool) -> Result<(), LlmError> {
+            self.inner.wait_for_batch(id, quiet)
+        }
+        fn fetch_batch_results(&self, id: &str) -> Result<HashMap<String, String>, LlmError> {
+            self.inner.fetch_batch_results(id)
+        }
+        fn model_name(&self) -> &str {
+            self.inner.model_name()
+        }
+    }
+
+    #[test]
+    fn patterns_match_rooted_paths_and_bare_names() {
+        let p = policy(&["internal/crypto/**", "*.pem", "./secrets/*.yaml"]);
+        assert!(p.is_excluded(Path::new("internal/crypto/aes.rs")));
+        assert!(p.is_excluded(Path::new("internal/crypto/kdf/hkdf.rs")));
+        assert!(p.is_excluded(Path::new("deploy/certs/server.pem")));
+        assert!(p.is_excluded(Path::new("./secrets/prod.yaml")));
+        assert!(!p.is_excluded(Path::new("vendor/internal/crypto/aes.rs")));
+        assert!(!p.is_excluded(Path::new("secrets/nested/prod.yaml")));
+        assert!(!p.is_excluded(Path::new("src/crypto.rs")));
+    }
+
+    #[test]
+    fn bad_globs_are_reported_and_dropped() {
+        let (p, errors) = LlmExclusion::new(&["src/[".to_string(), "*.key".to_string()]);
+        assert_eq!(errors.len(), 1);
+        assert_eq!(p.patterns(), ["*.key"]);
+        assert!(p.is_excluded(Path::new("a/b.key")));
+    }
+
+    #[test]
+    fn guard_withholds_items_with_an_excluded_source() {
+        let seen = Arc::new(Mutex::new(Vec::new()));
+        let recording = Recording {
+            inner: MockBatchProvider::new("msgbatch_guard", HashMap::new()),
+            seen: Arc::clone(&seen),
+        };
+        let guard = ExclusionGuard::new(Box::new(recording), policy(&["internal/crypto/**"]));
+        let items = [
+            item("open", &["src/lib.rs"]),
+            item("secret", &["internal/crypto/aes.rs"]),
+            item("mixed", &["src/a.rs", "internal/crypto/kdf.rs"]),
+            item("query", &[]),
+        ];
+        guard
+            .submit_batch(BatchKind::Prebuilt, &items, 100)
+            .unwrap();
+        assert_eq!(*seen.lock().unwrap(), ["open", "query"]);
+    }
+
+    #[test]
+    fn guard_refuses_a_batch_that_is_entirely_excluded() {
+        let guard = ExclusionGuard::new(
+            Box::new(MockBatchProvider::new("msgbatch_guard", HashMap::new())),
+            policy(&["*.pem"]),
+        );
+        let err = guard
+            .submit_batch(BatchKind::Prebuilt, &[item("k", &["certs/a.pem"])], 100)
+            .unwrap_err();
+        assert!(matches!(err, LlmError::Excluded(1)), "{err}");
+    }
+}
@@ -60,6 +60,7 @@ pub fn hyde_query_pass(
                 content,
                 context: ec.signature,
                 language: ec.language,
+                sources: vec![ec.file],
             }
         })
         .collect();
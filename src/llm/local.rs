@@ -847,6 +847,7 @@ mod tests {
                 content: format!("fn foo_{}() {{}}", i),
                 context: "function".to_string(),
                 language: "rust".to_string(),
+                sources: Vec::new(),
             })
             .collect()
     }
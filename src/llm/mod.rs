@@ -12,13 +12,23 @@
 //! - `summary` - llm_summary_pass orchestration
 //! - `doc_comments` - doc comment generation pass + needs_doc_comment
 //! - `hyde` - HyDE query prediction pass
+//! - `query_hyde` - query-time HyDE document for `cqs search --hyde`
+//! - `query_translate` - English rewrite of non-English search queries
+//! - `paraphrase` - doc comment → eval query rewrite (`cqs eval generate --llm`)
+//! - `ask` - cited answer synthesis over retrieved chunks (`cqs ask`)
+//! - `exclude` - `llm_exclude` path policy, enforced around every provider
 
+mod ask;
 mod batch;
 mod doc_comments;
+pub mod exclude;
 mod hyde;
 pub mod local;
+mod paraphrase;
 mod prompts;
 pub mod provider;
+mod query_hyde;
+mod query_translate;
 pub mod redirect;
 mod summary;
 pub mod validation;
@@ -48,10 +58,14 @@ use std::time::Duration;
 use serde::{Deserialize, Serialize};
 
 // Re-export public API
+pub use ask::{answer_question, Answer, AskSource};
 pub use doc_comments::needs_doc_comment;
 pub use hyde::hyde_query_pass;
 pub use local::LocalProvider;
+pub use paraphrase::{paraphrase_doc_queries, ParaphraseItem};
 pub use provider::BatchProvider;
+pub use query_hyde::{hypothetical_document, HydeDocument};
+pub use query_translate::{translate_query, QueryTranslation};
 pub use summary::llm_summary_pass;
 
 use provider::ProviderRegistry;
@@ -64,6 +78,8 @@ use crate::Store;
 /// content_hash (dedup key), content, chunk_type string, and language string.
 pub(crate) struct EligibleChunk {
     pub content_hash: String,
+    /// Origin of the first chunk seen with this hash
+    pub file: std::path::PathBuf,
     pub content: String,
     pub chunk_type: String,
     pub language: String,
@@ -149,6 +165,7 @@ pub(crate) fn collect_eligible_chunks(
             if queued_hashes.insert(cs.content_hash.clone()) {
                 items.push(EligibleChunk {
                     content_hash: cs.content_hash.clone(),
+                    file: cs.file.clone(),
                     content: cs.content.clone(),
                     chunk_type: cs.chunk_type.to_string(),
                     language: cs.language.to_string(),
@@ -206,6 +223,14 @@ pub enum LlmError {
     Store(#[from] crate::store::StoreError),
     #[error("IO error: {0}")]
     Io(#[from] std::io::Error),
+    /// Offline mode refused the provider's endpoint (`offline = true` /
+    /// `CQS_OFFLINE`). Loopback endpoints are still allowed.
+    #[error(transparent)]
+    Offline(#[from] crate::offline::OfflineError),
+    /// Every item in a submission came from a file matching `llm_exclude`,
+    /// so nothing was sent.
+    #[error("all {0} item(s) withheld by llm_exclude")]
+    Excluded(usize),
 }
 
 const API_BASE: &str = "https://api.anthropic.com/v1";
@@ -492,7 +517,19 @@ pub fn create_client(
                 message: format!("unknown LLM provider: {}", llm_config.provider),
             }
         })?;
-    registry.build(llm_config, on_item)
+    // Offline mode: refuse before any client exists. Only a loopback
+    // endpoint (a `local` server on this machine) passes.
+    crate::offline::check_url(
+        &format!("LLM provider \"{}\"", llm_config.provider),
+        &llm_config.api_base,
+    )?;
+    let provider = registry.build(llm_config, on_item)?;
+    // `llm_exclude`: every provider goes out behind the guard, so no caller
+    // can send an excluded file's chunks by forgetting a check.
+    Ok(Box::new(exclude::ExclusionGuard::new(
+        provider,
+        exclude::active(),
+    )))
 }
 
 /// Static registry of available LLM providers.
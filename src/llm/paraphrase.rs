@@ -0,0 +1,117 @@
+//! Doc-comment paraphrase for synthetic eval queries (`cqs eval generate --llm`).
+//!
+//! Unlike the summary / HyDE passes nothing is persisted to the store: the
+//! results only feed an eval file, so there is no pending-batch resume and
+//! no summary-table write. One batch in, one map of query strings out.
+
+use std::collections::HashMap;
+
+use super::provider::{BatchKind, BatchProvider, BatchSubmitItem};
+use super::{LlmClient, LlmConfig, LlmError};
+
+/// Max tokens per paraphrase — one short query.
+const PARAPHRASE_MAX_TOKENS: u32 = 40;
+
+/// One doc comment to rewrite as a query.
+pub struct ParaphraseItem {
+    /// Caller's key, echoed back in the result map
+    pub id: String,
+    /// Raw doc comment, markers included
+    pub doc: String,
+    /// Name of the documented chunk — the model is told not to repeat it
+    pub name: String,
+    pub language: String,
+    /// File the doc comment came from, checked against `llm_exclude`
+    pub origin: std::path::PathBuf,
+}
+
+/// Paraphrase every item through the configured provider. Returns
+/// `id -> query`; items the provider dropped or answered with an empty or
+/// multi-paragraph reply are absent.
+pub fn paraphrase_doc_queries(
+    config: &crate::config::Config,
+    items: &[ParaphraseItem],
+    quiet: bool,
+) -> Result<HashMap<String, String>, LlmError> {
+    let _span = tracing::info_span!("paraphrase_doc_queries", count = items.len()).entered();
+    if items.is_empty() {
+        return Ok(HashMap::new());
+    }
+    let llm_config = LlmConfig::resolve(config)?;
+    tracing::info!(model = %llm_config.model, "Doc paraphrase batch starting");
+    let client = super::create_client(llm_config, None)?;
+    paraphrase_with(client.as_ref(), items, quiet)
+}
+
+fn paraphrase_with(
+    client: &dyn BatchProvider,
+    items: &[ParaphraseItem],
+    quiet: bool,
+) -> Result<HashMap<String, String>, LlmError> {
+    let batch_items: Vec<BatchSubmitItem> = items
+        .iter()
+        .map(|it| BatchSubmitItem {
+            custom_id: it.id.clone(),
+            content: LlmClient::build_paraphrase_prompt(&it.doc, &it.name, &it.language),
+            context: String::new(),
+            language: it.language.clone(),
+            sources: vec![it.origin.clone()],
+        })
+        .collect();
+    let batch_id =
+        match client.submit_batch(BatchKind::Prebuilt, &batch_items, PARAPHRASE_MAX_TOKENS) {
+            Ok(id) => id,
+            // Every doc came from an excluded file: nothing to paraphrase.
+            Err(LlmError::Excluded(_)) => return Ok(HashMap::new()),
+            Err(e) => return Err(e),
+        };
+    client.wait_for_batch(&batch_id, quiet)?;
+    let raw = client.fetch_batch_results(&batch_id)?;
+    Ok(raw
+        .into_iter()
+        .filter_map(|(id, text)| clean_reply(&text).map(|q| (id, q)))
+        .collect())
+}
+
+/// First non-empty line, with quotes and a trailing period stripped.
+fn clean_reply(text: &str) -> Option<String> {
+    let line = text.lines().map(str::trim).find(|l| !l.is_empty())?;
+    let line = line
+        .trim_matches(|c| c == '"' || c == '\'' || c == '`')
+        .trim_end_matches('.')
+        .trim();
+    (!line.is_empty()).then(|| line.to_string())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::llm::provider::MockBatchProvider;
+
+    #[test]
+    fn clean_reply_takes_first_line_and_strips_quotes() {
+        assert_eq!(
+            clean_reply("\n\"retry a request with growing delays.\"\nextra"),
+            Some("retry a request with growing delays".to_string())
+        );
+        assert_eq!(clean_reply("  \n \n"), None);
+    }
+
+    #[test]
+    fn paraphrase_with_maps_results_by_id() {
+        let mut results = HashMap::new();
+        results.insert("a".to_string(), "parse a config file".to_string());
+        results.insert("b".to_string(), "   ".to_string());
+        let mock = MockBatchProvider::new("msgbatch_para", results);
+        let items = vec![ParaphraseItem {
+            id: "a".into(),
+            doc: "/// Load the config.".into(),
+            name: "load_config".into(),
+            language: "rust".into(),
+            origin: "src/config.rs".into(),
+        }];
+        let out = paraphrase_with(&mock, &items, true).unwrap();
+        assert_eq!(out.len(), 1);
+        assert_eq!(out["a"], "parse a config file");
+    }
+}
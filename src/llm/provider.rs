@@ -42,6 +42,7 @@ pub type OnItemCallback = Box<dyn Fn(&str, &str) + Send + Sync>;
 /// A single item in a batch submission.
 /// Named fields replace the opaque `(String, String, String, String)` tuple
 /// to prevent positional errors at call sites.
+#[derive(Clone)]
 pub struct BatchSubmitItem {
     /// Unique identifier for correlating results (typically content_hash)
     pub custom_id: String,
@@ -69,6 +70,10 @@ pub struct BatchSubmitItem {
     pub context: String,
     /// Programming language name
     pub language: String,
+    /// Files whose text is in `content` / `context`. The `llm_exclude`
+    /// guard ([`super::exclude`]) withholds the item when any of them is
+    /// excluded. Empty for items carrying no file text (a user query).
+    pub sources: Vec<std::path::PathBuf>,
 }
 
 /// Which prompt builder a batch submission uses.
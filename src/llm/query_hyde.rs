@@ -0,0 +1,133 @@
+//! Query-time HyDE (`cqs search --hyde`).
+//!
+//! The index-time pass in `hyde` predicts queries for chunks; this goes the
+//! other way: given a query, the model writes a short hypothetical code
+//! snippet that would answer it, and search blends that snippet's embedding
+//! into the query's. Snippets are cached by query hash + model in
+//! [`crate::cache::HydeCache`], so only the first run of a query pays the
+//! LLM call.
+
+use super::provider::{BatchKind, BatchProvider, BatchSubmitItem};
+use super::{LlmClient, LlmConfig, LlmError};
+use crate::cache::HydeCache;
+
+/// Max tokens per hypothetical document — a short snippet.
+const QUERY_HYDE_MAX_TOKENS: u32 = 300;
+
+/// `custom_id` of the single batch item.
+const HYDE_ITEM_ID: &str = "hyde-query";
+
+/// A hypothetical document for one query.
+#[derive(Debug, Clone, PartialEq)]
+pub struct HydeDocument {
+    pub document: String,
+    pub model: String,
+    /// Served from the cache, no LLM call made
+    pub cached: bool,
+}
+
+/// Hypothetical document for `query`, from the cache or the configured
+/// provider. A cache that can't be opened is skipped, not an error.
+pub fn hypothetical_document(
+    config: &crate::config::Config,
+    query: &str,
+    quiet: bool,
+) -> Result<HydeDocument, LlmError> {
+    let _span = tracing::info_span!("hypothetical_document").entered();
+    let llm_config = LlmConfig::resolve(config)?;
+    let cache = match HydeCache::open(&HydeCache::default_path()) {
+        Ok(cache) => Some(cache),
+        Err(e) => {
+            tracing::warn!(error = %e, "HyDE cache unavailable, generating uncached");
+            None
+        }
+    };
+    let model = llm_config.model.clone();
+    if let Some(document) = cache.as_ref().and_then(|c| c.get(query, &model)) {
+        tracing::debug!(model = %model, "HyDE cache hit");
+        return Ok(HydeDocument {
+            document,
+            model,
+            cached: true,
+        });
+    }
+    let client = super::create_client(llm_config, None)?;
+    let document = generate_with(client.as_ref(), query, QUERY_HYDE_MAX_TOKENS, quiet)?;
+    if let Some(cache) = &cache {
+        cache.put(query, &model, &document);
+    }
+    Ok(HydeDocument {
+        document,
+        model,
+        cached: false,
+    })
+}
+
+fn generate_with(
+    client: &dyn BatchProvider,
+    query: &str,
+    max_tokens: u32,
+    quiet: bool,
+) -> Result<String, LlmError> {
+    let item = BatchSubmitItem {
+        custom_id: HYDE_ITEM_ID.to_string(),
+        content: LlmClient::build_query_hyde_prompt(query),
+        context: String::new(),
+        language: String::new(),
+        sources: Vec::new(),
+    };
+    let batch_id = client.submit_batch(BatchKind::Prebuilt, &[item], max_tokens)?;
+    client.wait_for_batch(&batch_id, quiet)?;
+    client
+        .fetch_batch_results(&batch_id)?
+        .remove(HYDE_ITEM_ID)
+        .map(|t| strip_fences(&t))
+        .filter(|t| !t.is_empty())
+        .ok_or_else(|| LlmError::BatchFailed("provider returned no HyDE document".to_string()))
+}
+
+/// Drop a surrounding markdown code fence, if the model added one.
+fn strip_fences(text: &str) -> String {
+    let trimmed = text.trim();
+    let Some(body) = trimmed.strip_prefix("```") else {
+        return trimmed.to_string();
+    };
+    // Skip the info string (`rust`, `python`, …) on the opening line.
+    let body = body.split_once('\n').map_or("", |(_, rest)| rest);
+    body.trim_end()
+        .strip_suffix("```")
+        .unwrap_or(body)
+        .trim()
+        .to_string()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::llm::provider::MockBatchProvider;
+    use std::collections::HashMap;
+
+    #[test]
+    fn strip_fences_removes_code_fence() {
+        assert_eq!(
+            strip_fences("```rust\nfn retry() {}\n```\n"),
+            "fn retry() {}"
+        );
+        assert_eq!(strip_fences("  fn retry() {}  "), "fn retry() {}");
+    }
+
+    #[test]
+    fn generate_with_returns_document() {
+        let mut results = HashMap::new();
+        results.insert(
+            HYDE_ITEM_ID.to_string(),
+            "```\nfn verify(sig: &[u8]) -> bool { hmac_eq(sig) }\n```".to_string(),
+        );
+        let mock = MockBatchProvider::new("msgbatch_hyde", results);
+        let doc = generate_with(&mock, "verify webhook signature", 150, true).unwrap();
+        assert_eq!(doc, "fn verify(sig: &[u8]) -> bool { hmac_eq(sig) }");
+
+        let empty = MockBatchProvider::new("msgbatch_hyde", HashMap::new());
+        assert!(generate_with(&empty, "q", 150, true).is_err());
+    }
+}
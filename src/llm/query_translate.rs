@@ -0,0 +1,132 @@
+//! Query translation (`llm_query_translate`, `cqs search --translate`).
+//!
+//! Embedders and the FTS analyzer are tuned for English queries; a query in
+//! Japanese or German ranks far worse against the same code. When
+//! translation is on, the model rewrites the query in English, keeping
+//! identifiers as written, and search runs on the rewrite. Translations are
+//! cached by query hash + model in [`crate::cache::TranslationCache`], so
+//! only the first run of a query pays the LLM call.
+
+use super::provider::{BatchKind, BatchProvider, BatchSubmitItem};
+use super::{LlmClient, LlmConfig, LlmError};
+use crate::cache::TranslationCache;
+
+/// Max tokens per translation — one short query.
+const QUERY_TRANSLATE_MAX_TOKENS: u32 = 100;
+
+/// `custom_id` of the single batch item.
+const TRANSLATE_ITEM_ID: &str = "translate-query";
+
+/// An English rewrite of one query.
+#[derive(Debug, Clone, PartialEq)]
+pub struct QueryTranslation {
+    pub text: String,
+    pub model: String,
+    /// Served from the cache, no LLM call made
+    pub cached: bool,
+}
+
+/// English rewrite of `query`, from the cache or the configured provider.
+/// A cache that can't be opened is skipped, not an error.
+pub fn translate_query(
+    config: &crate::config::Config,
+    query: &str,
+    quiet: bool,
+) -> Result<QueryTranslation, LlmError> {
+    let _span = tracing::info_span!("translate_query").entered();
+    let llm_config = LlmConfig::resolve(config)?;
+    let cache = match TranslationCache::open(&TranslationCache::default_path()) {
+        Ok(cache) => Some(cache),
+        Err(e) => {
+            tracing::warn!(error = %e, "Translation cache unavailable, translating uncached");
+            None
+        }
+    };
+    let model = llm_config.model.clone();
+    if let Some(text) = cache.as_ref().and_then(|c| c.get(query, &model)) {
+        tracing::debug!(model = %model, "Translation cache hit");
+        return Ok(QueryTranslation {
+            text,
+            model,
+            cached: true,
+        });
+    }
+    let client = super::create_client(llm_config, None)?;
+    let text = translate_with(client.as_ref(), query, QUERY_TRANSLATE_MAX_TOKENS, quiet)?;
+    if let Some(cache) = &cache {
+        cache.put(query, &model, &text);
+    }
+    Ok(QueryTranslation {
+        text,
+        model,
+        cached: false,
+    })
+}
+
+fn translate_with(
+    client: &dyn BatchProvider,
+    query: &str,
+    max_tokens: u32,
+    quiet: bool,
+) -> Result<String, LlmError> {
+    let item = BatchSubmitItem {
+        custom_id: TRANSLATE_ITEM_ID.to_string(),
+        content: LlmClient::build_query_translate_prompt(query),
+        context: String::new(),
+        language: String::new(),
+        sources: Vec::new(),
+    };
+    let batch_id = client.submit_batch(BatchKind::Prebuilt, &[item], max_tokens)?;
+    client.wait_for_batch(&batch_id, quiet)?;
+    client
+        .fetch_batch_results(&batch_id)?
+        .remove(TRANSLATE_ITEM_ID)
+        .map(|t| clean_translation(&t))
+        .filter(|t| !t.is_empty())
+        .ok_or_else(|| LlmError::BatchFailed("provider returned no translation".to_string()))
+}
+
+/// First non-empty line, without the quotes models like to wrap it in.
+fn clean_translation(text: &str) -> String {
+    let line = text.lines().map(str::trim).find(|l| !l.is_empty());
+    line.unwrap_or_default()
+        .trim_matches(|c: char| matches!(c, '"' | '\'' | '`' | '“' | '”' | '「' | '」'))
+        .trim()
+        .to_string()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::llm::provider::MockBatchProvider;
+    use std::collections::HashMap;
+
+    #[test]
+    fn clean_translation_keeps_first_line_unquoted() {
+        assert_eq!(
+            clean_translation("\n\"load the config file\"\nTranslated from German."),
+            "load the config file"
+        );
+        assert_eq!(
+            clean_translation("「retry on timeout」"),
+            "retry on timeout"
+        );
+        assert_eq!(clean_translation("  \n "), "");
+    }
+
+    #[test]
+    fn translate_with_returns_rewrite() {
+        let mut results = HashMap::new();
+        results.insert(
+            TRANSLATE_ITEM_ID.to_string(),
+            "where is the config file read".to_string(),
+        );
+        let mock = MockBatchProvider::new("msgbatch_translate", results);
+        let text =
+            translate_with(&mock, "wo wird die Konfigurationsdatei gelesen", 100, true).unwrap();
+        assert_eq!(text, "where is the config file read");
+
+        let empty = MockBatchProvider::new("msgbatch_translate", HashMap::new());
+        assert!(translate_with(&empty, "q", 100, true).is_err());
+    }
+}
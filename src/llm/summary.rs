@@ -46,19 +46,25 @@ pub fn llm_summary_pass(
     let on_item = Some(store.stream_summary_writer(model_name, "summary".to_string()));
     let client = super::create_client(llm_config, on_item)?;
 
-    // Phase 0: Precompute contrastive neighbors from embedding similarity
-    let neighbor_map = match find_contrastive_neighbors(store, 3) {
-        Ok(map) => map,
-        Err(e) => {
-            tracing::warn!(error = %e, "Contrastive neighbor computation failed, falling back to discriminating-only");
-            HashMap::new()
-        }
-    };
-
     // Phase 1: Collect chunks needing summaries via shared filter
     let max_batch_size = crate::limits::llm_max_batch_size();
     let (eligible, cached, skipped) = collect_eligible_chunks(store, "summary", max_batch_size)?;
 
+    // Precompute contrastive neighbors from embedding similarity.
+    // Skipped when nothing needs a summary — the N×N matrix is the pass's
+    // dominant cost, and watch re-runs this pass after every edit burst.
+    let neighbor_map = if eligible.is_empty() {
+        HashMap::new()
+    } else {
+        match find_contrastive_neighbors(store, 3) {
+            Ok(map) => map,
+            Err(e) => {
+                tracing::warn!(error = %e, "Contrastive neighbor computation failed, falling back to discriminating-only");
+                HashMap::new()
+            }
+        }
+    };
+
     // EH-23: Warn when contrastive neighbors are empty but eligible chunks exist
     if neighbor_map.is_empty() && !eligible.is_empty() {
         tracing::warn!(
@@ -89,6 +95,7 @@ pub fn llm_summary_pass(
             content: prompt,
             context: ec.chunk_type.clone(),
             language: ec.language.clone(),
+            sources: vec![ec.file.clone()],
         });
     }
     if batch_items.len() >= max_batch_size {
@@ -355,6 +362,8 @@ mod tests {
             window_idx,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 
@@ -0,0 +1,585 @@
+//! Log output: level filter, line format, and sink.
+//!
+//! The subscriber is installed once in `main` with the flags and env; the
+//! config then adjusts it in place. Each of the three parts can change while
+//! the process runs, so a daemon picks up a `[log]` edit on
+//! `cqs reload-config` without a restart:
+//!
+//! - **Filter** — `RUST_LOG` directives behind a reload layer. `--verbose`
+//!   or `RUST_LOG` pins it; otherwise `[log.commands] <command>` or
+//!   `[log] filter` replaces the built-in [`DEFAULT_FILTER`].
+//! - **Format** — `text` (the usual fmt lines) or `json` (one object per
+//!   line: `timestamp`, `level`, `target`, `message`, `fields`, `spans`).
+//!   `--log-format` / `CQS_LOG_FORMAT` pin it over `[log] format`.
+//! - **Sink** — stderr, or a file rotated by size: once a write would take
+//!   it past `max_bytes` (default 10 MiB) it is renamed to `<file>.1`,
+//!   older ones shift up, and only `max_files` (default 5) are kept.
+//!   `--log-file` / `CQS_LOG_FILE` pin it over `[log] file`;
+//!   `CQS_LOG_MAX_BYTES` / `CQS_LOG_MAX_FILES` pin the caps.
+//!
+//! A log file that can't be opened leaves the sink on stderr with a
+//! warning; logging never stops the command.
+//!
+//! `--trace` adds a second layer, with its own filter, that records span
+//! timings instead of lines; see [`crate::span_trace`].
+
+use std::fs::{File, OpenOptions};
+use std::io::{self, Write};
+use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::{Mutex, OnceLock};
+
+use serde::{Deserialize, Serialize};
+use serde_json::{Map, Value};
+use tracing::field::{Field, Visit};
+use tracing::{Event, Subscriber};
+use tracing_subscriber::fmt::format::{self, FormatEvent, FormatFields, Writer};
+use tracing_subscriber::fmt::{FmtContext, FormattedFields, MakeWriter};
+use tracing_subscriber::layer::{Layer as _, SubscriberExt};
+use tracing_subscriber::registry::LookupSpan;
+use tracing_subscriber::util::SubscriberInitExt;
+use tracing_subscriber::{reload, EnvFilter, Registry};
+
+use crate::config::LogConfig;
+
+/// Filter when nothing else is set: cqs at info so the span instrumentation
+/// renders, third-party crates at warn, and ONNX Runtime's chatter off.
+pub const DEFAULT_FILTER: &str = "cqs=info,warn,ort=error";
+
+/// Filter `--verbose` pins.
+const VERBOSE_FILTER: &str = "cqs=debug,info";
+
+/// Rotate once the file would pass this many bytes.
+const DEFAULT_MAX_BYTES: u64 = 10 * 1024 * 1024;
+
+/// Rotated files kept beside the live one.
+const DEFAULT_MAX_FILES: usize = 5;
+
+/// Log line format.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize, clap::ValueEnum)]
+#[serde(rename_all = "lowercase")]
+pub enum LogFormat {
+    /// Human-readable fmt lines.
+    #[default]
+    Text,
+    /// One JSON object per line, for log shippers.
+    Json,
+}
+
+impl std::str::FromStr for LogFormat {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s.trim().to_ascii_lowercase().as_str() {
+            "text" => Ok(LogFormat::Text),
+            "json" => Ok(LogFormat::Json),
+            other => Err(format!(
+                "unknown log format '{other}' (expected text or json)"
+            )),
+        }
+    }
+}
+
+/// What `main` resolved from flags and env before the config is read.
+#[derive(Debug, Clone, Default)]
+pub struct LogOptions {
+    /// `--verbose`.
+    pub verbose: bool,
+    /// `--log-format`.
+    pub format: Option<LogFormat>,
+    /// `--log-file`.
+    pub file: Option<PathBuf>,
+    /// `--trace`: also record span timings for [`crate::span_trace`].
+    pub trace: Option<PathBuf>,
+}
+
+/// Which parts the flags or env fixed; the config leaves those alone.
+#[derive(Debug, Clone, Copy, Default)]
+struct Pinned {
+    filter: bool,
+    format: bool,
+    file: bool,
+}
+
+static FILTER: OnceLock<reload::Handle<EnvFilter, Registry>> = OnceLock::new();
+static PINNED: OnceLock<Pinned> = OnceLock::new();
+/// `variant_name()` of the running command, for `[log.commands]` on reload.
+static COMMAND: OnceLock<String> = OnceLock::new();
+static JSON: AtomicBool = AtomicBool::new(false);
+static SINK: Mutex<Option<RotatingFile>> = Mutex::new(None);
+
+/// Install the global subscriber. Flags win over env (`RUST_LOG`,
+/// `CQS_LOG_FORMAT`, `CQS_LOG_FILE`); whatever neither sets stays open for
+/// [`set_from_config`].
+pub fn init(opts: &LogOptions) {
+    let env_filter = std::env::var("RUST_LOG")
+        .ok()
+        .filter(|v| !v.is_empty())
+        .and_then(|v| EnvFilter::try_new(v).ok());
+    let env_pinned = env_filter.is_some();
+    let filter = if opts.verbose {
+        EnvFilter::new(VERBOSE_FILTER)
+    } else {
+        env_filter.unwrap_or_else(|| EnvFilter::new(DEFAULT_FILTER))
+    };
+    let env_format = std::env::var("CQS_LOG_FORMAT").ok().and_then(|v| {
+        v.parse::<LogFormat>()
+            .map_err(|e| eprintln!("cqs: CQS_LOG_FORMAT: {e}"))
+            .ok()
+    });
+    let format = opts.format.or(env_format);
+    let file = opts
+        .file
+        .clone()
+        .or_else(|| std::env::var_os("CQS_LOG_FILE").map(PathBuf::from))
+        .filter(|p| !p.as_os_str().is_empty());
+    let _ = PINNED.set(Pinned {
+        filter: opts.verbose || env_pinned,
+        format: format.is_some(),
+        file: file.is_some(),
+    });
+    JSON.store(format == Some(LogFormat::Json), Ordering::Relaxed);
+
+    let (filter_layer, handle) = reload::Layer::new(filter);
+    let _ = FILTER.set(handle);
+    // Colour only for a terminal; file and JSON output strip escapes anyway.
+    let ansi =
+        std::io::IsTerminal::is_terminal(&io::stderr()) && std::env::var_os("NO_COLOR").is_none();
+    // FmtSpan::CLOSE emits a synthetic event on span close with elapsed time —
+    // turns every `info_span!("foo", ...).entered()` into a "foo" + latency
+    // line in the journal automatically. Without it, only events emitted
+    // *inside* a span produce log lines; entry/exit pairs disappear.
+    let fmt_layer = tracing_subscriber::fmt::layer()
+        .with_span_events(tracing_subscriber::fmt::format::FmtSpan::CLOSE)
+        .event_format(LineFormat::default())
+        .with_ansi(ansi)
+        .with_writer(SinkWriter)
+        .with_filter(filter_layer);
+    // Both filters are per-layer: `--trace` wants debug spans that the log
+    // filter would otherwise switch off for every layer.
+    let trace_layer = opts
+        .trace
+        .as_deref()
+        .map(|path| crate::span_trace::layer(path).with_filter(crate::span_trace::filter()));
+    tracing_subscriber::registry()
+        .with(fmt_layer)
+        .with(trace_layer)
+        .init();
+
+    if let Some(path) = file {
+        open_sink(&path, rotation(None));
+    }
+}
+
+/// Apply `[log]` for `command` (`Commands::variant_name()`), resolving a
+/// relative `file` against `root`. Parts pinned by flags or env are kept.
+pub fn set_from_config(config: &LogConfig, command: &str, root: &Path) {
+    let _ = COMMAND.set(command.to_string());
+    apply(config, command, root);
+}
+
+/// Re-apply `[log]` after a config reload, for the command recorded by
+/// [`set_from_config`]. A removed section restores the defaults.
+pub fn reload_from_config(config: Option<&LogConfig>, root: &Path) {
+    let command = COMMAND.get().map_or("watch", String::as_str);
+    apply(&config.cloned().unwrap_or_default(), command, root);
+}
+
+fn apply(config: &LogConfig, command: &str, root: &Path) {
+    let pinned = PINNED.get().copied().unwrap_or_default();
+    if !pinned.filter {
+        let directives = config
+            .commands
+            .get(command)
+            .or(config.filter.as_ref())
+            .map_or(DEFAULT_FILTER, String::as_str);
+        if let Err(e) = set_filter(directives) {
+            tracing::warn!(error = %e, directives, "Ignoring [log] filter");
+        }
+    }
+    if !pinned.format {
+        JSON.store(config.format == Some(LogFormat::Json), Ordering::Relaxed);
+    }
+    if !pinned.file {
+        match &config.file {
+            Some(file) => open_sink(&root.join(file), rotation(Some(config))),
+            None => *SINK.lock().unwrap_or_else(|e| e.into_inner()) = None,
+        }
+    } else if let Some(sink) = SINK.lock().unwrap_or_else(|e| e.into_inner()).as_mut() {
+        (sink.max_bytes, sink.max_files) = rotation(Some(config));
+    }
+}
+
+/// Replace the level filter with `directives` (`RUST_LOG` syntax).
+pub fn set_filter(directives: &str) -> Result<(), String> {
+    let filter = EnvFilter::try_new(directives).map_err(|e| e.to_string())?;
+    let Some(handle) = FILTER.get() else {
+        return Err("logging is not initialized".to_string());
+    };
+    handle.reload(filter).map_err(|e| e.to_string())?;
+    tracing::debug!(directives, "Log filter replaced");
+    Ok(())
+}
+
+/// `(max_bytes, max_files)`: env, then `[log]`, then the defaults.
+fn rotation(config: Option<&LogConfig>) -> (u64, usize) {
+    let env = |name: &str| std::env::var(name).ok().and_then(|v| v.parse::<u64>().ok());
+    let max_bytes = env("CQS_LOG_MAX_BYTES")
+        .or_else(|| config.and_then(|c| c.max_bytes))
+        .filter(|&n| n > 0)
+        .unwrap_or(DEFAULT_MAX_BYTES);
+    let max_files = env("CQS_LOG_MAX_FILES")
+        .and_then(|n| usize::try_from(n).ok())
+        .or_else(|| config.and_then(|c| c.max_files))
+        .unwrap_or(DEFAULT_MAX_FILES);
+    (max_bytes, max_files)
+}
+
+/// Point the sink at `path`, keeping the current one when it can't open.
+fn open_sink(path: &Path, (max_bytes, max_files): (u64, usize)) {
+    let mut sink = SINK.lock().unwrap_or_else(|e| e.into_inner());
+    if let Some(current) = sink.as_mut().filter(|s| s.path == path) {
+        current.max_bytes = max_bytes;
+        current.max_files = max_files;
+        return;
+    }
+    match RotatingFile::open(path, max_bytes, max_files) {
+        Ok(file) => *sink = Some(file),
+        Err(e) => {
+            drop(sink);
+            tracing::warn!(path = %path.display(), error = %e, "Cannot open log file; logging to stderr");
+        }
+    }
+}
+
+// ── Sink ────────────────────────────────────────────────────────────────────
+
+/// A log file that rotates by size.
+struct RotatingFile {
+    path: PathBuf,
+    file: File,
+    written: u64,
+    max_bytes: u64,
+    max_files: usize,
+}
+
+impl RotatingFile {
+    fn open(path: &Path, max_bytes: u64, max_files: usize) -> io::Result<Self> {
+        if let Some(parent) = path.parent().filter(|p| !p.as_os_str().is_empty()) {
+            std::fs::create_dir_all(parent)?;
+        }
+        let file = open_append(path)?;
+        let written = file.metadata()?.len();
+        Ok(Self {
+            path: path.to_path_buf(),
+            file,
+            written,
+            max_bytes,
+            max_files,
+        })
+    }
+
+    fn rotated(&self, n: usize) -> PathBuf {
+        let mut name = self.path.as_os_str().to_os_string();
+        name.push(format!(".{n}"));
+        PathBuf::from(name)
+    }
+
+    /// `<file>` → `<file>.1` → … → `<file>.<max_files>`, dropping the oldest.
+    fn rotate(&mut self) -> io::Result<()> {
+        let ignore_missing = |r: io::Result<()>| match r {
+            Err(e) if e.kind() != io::ErrorKind::NotFound => Err(e),
+            _ => Ok(()),
+        };
+        if self.max_files == 0 {
+            ignore_missing(std::fs::remove_file(&self.path))?;
+        } else {
+            ignore_missing(std::fs::remove_file(self.rotated(self.max_files)))?;
+            for n in (1..self.max_files).rev() {
+                ignore_missing(std::fs::rename(self.rotated(n), self.rotated(n + 1)))?;
+            }
+            std::fs::rename(&self.path, self.rotated(1))?;
+        }
+        self.file = open_append(&self.path)?;
+        self.written = 0;
+        Ok(())
+    }
+}
+
+impl Write for RotatingFile {
+    fn write(&mut self, buf: &[u8]) -> io::Result<usize> {
+        if self.written > 0 && self.written + buf.len() as u64 > self.max_bytes {
+            if let Err(e) = self.rotate() {
+                // Keep appending to the current file; a failed rotation must
+                // not lose the line.
+                eprintln!("cqs: log rotation failed for {}: {e}", self.path.display());
+            }
+        }
+        let n = self.file.write(buf)?;
+        self.written += n as u64;
+        Ok(n)
+    }
+
+    fn flush(&mut self) -> io::Result<()> {
+        self.file.flush()
+    }
+}
+
+/// Owner-only: log lines carry queries and paths.
+fn open_append(path: &Path) -> io::Result<File> {
+    let mut options = OpenOptions::new();
+    options.create(true).append(true);
+    #[cfg(unix)]
+    {
+        use std::os::unix::fs::OpenOptionsExt;
+        options.mode(0o600);
+    }
+    options.open(path)
+}
+
+/// Writes each formatted line to the file sink when one is open, else
+/// stderr.
+struct SinkWriter;
+
+/// One line's writer; see [`SinkWriter`].
+struct SinkLine;
+
+impl<'a> MakeWriter<'a> for SinkWriter {
+    type Writer = SinkLine;
+
+    fn make_writer(&'a self) -> Self::Writer {
+        SinkLine
+    }
+}
+
+impl Write for SinkLine {
+    fn write(&mut self, buf: &[u8]) -> io::Result<usize> {
+        match SINK.lock().unwrap_or_else(|e| e.into_inner()).as_mut() {
+            Some(file) => file.write(buf),
+            None => io::stderr().write(buf),
+        }
+    }
+
+    fn flush(&mut self) -> io::Result<()> {
+        match SINK.lock().unwrap_or_else(|e| e.into_inner()).as_mut() {
+            Some(file) => file.flush(),
+            None => io::stderr().flush(),
+        }
+    }
+}
+
+fn sink_is_file() -> bool {
+    SINK.lock().unwrap_or_else(|e| e.into_inner()).is_some()
+}
+
+// ── Format ──────────────────────────────────────────────────────────────────
+
+/// Text or JSON, switched at runtime by [`JSON`].
+#[derive(Default)]
+struct LineFormat {
+    text: format::Format,
+}
+
+impl<S, N> FormatEvent<S, N> for LineFormat
+where
+    S: Subscriber + for<'a> LookupSpan<'a>,
+    N: for<'a> FormatFields<'a> + 'static,
+{
+    fn format_event(
+        &self,
+        ctx: &FmtContext<'_, S, N>,
+        mut writer: Writer<'_>,
+        event: &Event<'_>,
+    ) -> std::fmt::Result {
+        if JSON.load(Ordering::Relaxed) {
+            return writeln!(writer, "{}", json_line(ctx, event));
+        }
+        if !sink_is_file() {
+            return self.text.format_event(ctx, writer, event);
+        }
+        // Files get plain text; span fields were formatted with the
+        // layer's colour setting, so strip what they carry too.
+        let mut line = String::new();
+        self.text.format_event(ctx, Writer::new(&mut line), event)?;
+        writer.write_str(&strip_ansi(&line))
+    }
+}
+
+fn json_line<S, N>(ctx: &FmtContext<'_, S, N>, event: &Event<'_>) -> Value
+where
+    S: Subscriber + for<'a> LookupSpan<'a>,
+    N: for<'a> FormatFields<'a> + 'static,
+{
+    let meta = event.metadata();
+    let mut fields = JsonFields::default();
+    event.record(&mut fields);
+    let mut line = Map::new();
+    line.insert(
+        "timestamp".into(),
+        chrono::Utc::now()
+            .to_rfc3339_opts(chrono::SecondsFormat::Micros, true)
+            .into(),
+    );
+    line.insert("level".into(), meta.level().to_string().into());
+    line.insert("target".into(), meta.target().into());
+    if let Some(message) = fields.0.remove("message") {
+        line.insert("message".into(), message);
+    }
+    if !fields.0.is_empty() {
+        line.insert("fields".into(), Value::Object(fields.0));
+    }
+    if let Some(scope) = ctx.event_scope() {
+        let spans: Vec<Value> = scope
+            .from_root()
+            .map(|span| {
+                let mut entry = Map::new();
+                entry.insert("name".into(), span.name().into());
+                if let Some(recorded) = span.extensions().get::<FormattedFields<N>>() {
+                    if !recorded.is_empty() {
+                        entry.insert("fields".into(), strip_ansi(recorded).into());
+                    }
+                }
+                Value::Object(entry)
+            })
+            .collect();
+        if !spans.is_empty() {
+            line.insert("spans".into(), Value::Array(spans));
+        }
+    }
+    Value::Object(line)
+}
+
+/// Event fields as JSON values; `Debug`-only values become strings.
+#[derive(Default)]
+pub(crate) struct JsonFields(pub(crate) Map<String, Value>);
+
+impl Visit for JsonFields {
+    fn record_f64(&mut self, field: &Field, value: f64) {
+        self.0.insert(field.name().into(), value.into());
+    }
+    fn record_i64(&mut self, field: &Field, value: i64) {
+        self.0.insert(field.name().into(), value.into());
+    }
+    fn record_u64(&mut self, field: &Field, value: u64) {
+        self.0.insert(field.name().into(), value.into());
+    }
+    fn record_bool(&mut self, field: &Field, value: bool) {
+        self.0.insert(field.name().into(), value.into());
+    }
+    fn record_str(&mut self, field: &Field, value: &str) {
+        self.0.insert(field.name().into(), value.into());
+    }
+    fn record_debug(&mut self, field: &Field, value: &dyn std::fmt::Debug) {
+        self.0
+            .insert(field.name().into(), format!("{value:?}").into());
+    }
+}
+
+/// Drop ANSI CSI sequences (`ESC [ … final-byte`).
+fn strip_ansi(s: &str) -> std::borrow::Cow<'_, str> {
+    if !s.contains('\x1b') {
+        return std::borrow::Cow::Borrowed(s);
+    }
+    let mut out = String::with_capacity(s.len());
+    let mut chars = s.chars().peekable();
+    while let Some(c) = chars.next() {
+        if c == '\x1b' && chars.peek() == Some(&'[') {
+            chars.next();
+            for c in chars.by_ref() {
+                if ('\x40'..='\x7e').contains(&c) {
+                    break;
+                }
+            }
+        } else {
+            out.push(c);
+        }
+    }
+    std::borrow::Cow::Owned(out)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn rotating_file_keeps_max_files() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("logs/cqs.log");
+        let mut file = RotatingFile::open(&path, 10, 2).unwrap();
+        for line in ["aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"] {
+            file.write_all(line.as_bytes()).unwrap();
+        }
+        let read = |p: PathBuf| std::fs::read_to_string(p).unwrap();
+        assert_eq!(read(path.clone()), "dddddddd\n");
+        assert_eq!(read(file.rotated(1)), "cccccccc\n");
+        assert_eq!(read(file.rotated(2)), "bbbbbbbb\n");
+        assert!(!file.rotated(3).exists(), "oldest file must be dropped");
+    }
+
+    #[test]
+    fn rotating_file_resumes_size_of_existing_file() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("cqs.log");
+        std::fs::write(&path, "0123456789").unwrap();
+        let mut file = RotatingFile::open(&path, 12, 1).unwrap();
+        file.write_all(b"abc\n").unwrap();
+        assert_eq!(std::fs::read_to_string(&path).unwrap(), "abc\n");
+        assert_eq!(
+            std::fs::read_to_string(file.rotated(1)).unwrap(),
+            "0123456789"
+        );
+    }
+
+    #[test]
+    fn log_format_parses() {
+        assert_eq!("JSON".parse::<LogFormat>(), Ok(LogFormat::Json));
+        assert_eq!("text".parse::<LogFormat>(), Ok(LogFormat::Text));
+        assert!("xml".parse::<LogFormat>().is_err());
+    }
+
+    #[test]
+    fn strip_ansi_removes_escapes_only() {
+        assert_eq!(strip_ansi("\x1b[2mcmd\x1b[0m{path=a}"), "cmd{path=a}");
+        assert_eq!(strip_ansi("plain"), "plain");
+    }
+
+    #[test]
+    fn json_line_carries_fields_and_spans() {
+        #[derive(Clone, Default)]
+        struct Capture(std::sync::Arc<Mutex<Vec<u8>>>);
+        impl Write for Capture {
+            fn write(&mut self, buf: &[u8]) -> io::Result<usize> {
+                self.0.lock().unwrap().extend_from_slice(buf);
+                Ok(buf.len())
+            }
+            fn flush(&mut self) -> io::Result<()> {
+                Ok(())
+            }
+        }
+
+        let capture = Capture::default();
+        let writer = capture.clone();
+        let subscriber = tracing_subscriber::registry().with(
+            tracing_subscriber::fmt::layer()
+                .event_format(LineFormat::default())
+                .with_ansi(false)
+                .with_writer(move || writer.clone()),
+        );
+        JSON.store(true, Ordering::Relaxed);
+        tracing::subscriber::with_default(subscriber, || {
+            let _span = tracing::info_span!("cmd_search", limit = 5).entered();
+            tracing::info!(count = 3u64, hit = true, "Search done");
+        });
+        JSON.store(false, Ordering::Relaxed);
+
+        let out = String::from_utf8(capture.0.lock().unwrap().clone()).unwrap();
+        let line: Value = serde_json::from_str(out.lines().next().unwrap()).unwrap();
+        assert_eq!(line["level"], "INFO");
+        assert_eq!(line["message"], "Search done");
+        assert_eq!(line["fields"]["count"], 3);
+        assert_eq!(line["fields"]["hit"], true);
+        assert_eq!(line["spans"][0]["name"], "cmd_search");
+        assert_eq!(line["spans"][0]["fields"], "limit=5");
+        assert!(line["timestamp"].as_str().unwrap().ends_with('Z'));
+    }
+}
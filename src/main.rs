@@ -1,12 +1,11 @@
 #![allow(clippy::doc_lazy_continuation)]
 use anyhow::Result;
 use clap::Parser;
-use tracing_subscriber::EnvFilter;
 
 mod cli;
 
 /// Initializes logging and runs the CLI application.
-/// Parses command-line arguments and configures a tracing subscriber that logs to stderr. The log level is set to debug if the `--verbose` flag is provided, otherwise it uses the `RUST_LOG` environment variable or defaults to warn level (with ort module set to error).
+/// Parses command-line arguments and installs the tracing subscriber (see [`cqs::logging`]): stderr by default, keeping stdout clean for structured output. `--verbose` sets debug level for cqs, otherwise `RUST_LOG` or the `[log]` config section decide, defaulting to `cqs=info,warn,ort=error`.
 /// # Returns
 /// Returns a `Result<()>` indicating success or failure of the CLI execution.
 /// # Errors
@@ -15,26 +14,16 @@ fn main() -> Result<()> {
     // Parse CLI first to check verbose flag
     let cli = cli::Cli::parse();
 
-    // Log to stderr to keep stdout clean for structured output.
-    // --verbose sets debug level for cqs (everything else stays at info),
-    // otherwise honour RUST_LOG, defaulting to "cqs=info,warn,ort=error" so
-    // the span instrumentation sites render without third-party noise.
-    let filter = if cli.verbose {
-        EnvFilter::new("cqs=debug,info")
-    } else {
-        EnvFilter::try_from_default_env()
-            .unwrap_or_else(|_| EnvFilter::new("cqs=info,warn,ort=error"))
-    };
+    // Flags and env pin what they set; the config fills in the rest once the
+    // project root is known (`cqs::logging::set_from_config` in dispatch).
+    cqs::logging::init(&cqs::logging::LogOptions {
+        verbose: cli.verbose,
+        format: cli.log_format,
+        file: cli.log_file.clone(),
+        trace: cli.trace_out.clone(),
+    });
 
-    // FmtSpan::CLOSE emits a synthetic event on span close with elapsed time —
-    // turns every `info_span!("foo", ...).entered()` into a "foo" + latency
-    // line in the journal automatically. Without it, only events emitted
-    // *inside* a span produce log lines; entry/exit pairs disappear.
-    tracing_subscriber::fmt()
-        .with_env_filter(filter)
-        .with_span_events(tracing_subscriber::fmt::format::FmtSpan::CLOSE)
-        .with_writer(std::io::stderr)
-        .init();
-
-    cli::run_with(cli)
+    let result = cli::run_with(cli);
+    cqs::span_trace::finish();
+    result
 }
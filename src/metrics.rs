@@ -0,0 +1,512 @@
+//! Process-wide operational metrics in the Prometheus text exposition format.
+//!
+//! `cqs serve` answers `GET /metrics` with [`render`]; `cqs watch --metrics
+//! <ADDR>` runs [`spawn_exporter`], a small listener answering the same path,
+//! so existing scrape infrastructure can watch a daemon without OTLP.
+//!
+//! There is no client library and no registry: every instrument is a static
+//! over atomics, so recording on a hot path (daemon dispatch, embed batches)
+//! is one relaxed `fetch_add`. Values are per process and start from zero on
+//! restart, which is what Prometheus expects of counters.
+
+use std::fmt::Write as _;
+use std::io::{Read, Write};
+use std::net::{SocketAddr, TcpListener, TcpStream};
+use std::sync::atomic::{AtomicI64, AtomicU64, Ordering};
+use std::time::Duration;
+
+/// `Content-Type` of a [`render`] body.
+pub const CONTENT_TYPE: &str = "text/plain; version=0.0.4; charset=utf-8";
+
+/// Upper bounds (seconds) of the latency histogram buckets. Spans a cached
+/// daemon lookup (single-digit ms) to a cold reranked search or a large
+/// reindex pass.
+pub const LATENCY_BUCKETS: [f64; 12] = [
+    0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0, 30.0,
+];
+
+/// Monotonic counter.
+pub struct Counter(AtomicU64);
+
+impl Counter {
+    pub const fn new() -> Self {
+        Self(AtomicU64::new(0))
+    }
+
+    pub fn inc(&self) {
+        self.add(1);
+    }
+
+    pub fn add(&self, n: u64) {
+        self.0.fetch_add(n, Ordering::Relaxed);
+    }
+
+    pub fn get(&self) -> u64 {
+        self.0.load(Ordering::Relaxed)
+    }
+}
+
+impl Default for Counter {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+/// Value that can go up and down.
+pub struct Gauge(AtomicI64);
+
+impl Gauge {
+    pub const fn new() -> Self {
+        Self(AtomicI64::new(0))
+    }
+
+    pub fn set(&self, v: i64) {
+        self.0.store(v, Ordering::Relaxed);
+    }
+
+    pub fn get(&self) -> i64 {
+        self.0.load(Ordering::Relaxed)
+    }
+}
+
+impl Default for Gauge {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+/// Accumulated time, kept in microseconds and rendered as seconds.
+pub struct SecondsCounter(AtomicU64);
+
+impl SecondsCounter {
+    pub const fn new() -> Self {
+        Self(AtomicU64::new(0))
+    }
+
+    pub fn add(&self, d: Duration) {
+        let micros = u64::try_from(d.as_micros()).unwrap_or(u64::MAX);
+        self.0.fetch_add(micros, Ordering::Relaxed);
+    }
+
+    pub fn seconds(&self) -> f64 {
+        self.0.load(Ordering::Relaxed) as f64 / 1e6
+    }
+}
+
+impl Default for SecondsCounter {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+/// Latency histogram over [`LATENCY_BUCKETS`]. Buckets are stored
+/// per-interval and made cumulative at render time.
+pub struct Histogram {
+    buckets: [AtomicU64; LATENCY_BUCKETS.len()],
+    count: AtomicU64,
+    sum: SecondsCounter,
+}
+
+impl Histogram {
+    pub const fn new() -> Self {
+        Self {
+            buckets: [const { AtomicU64::new(0) }; LATENCY_BUCKETS.len()],
+            count: AtomicU64::new(0),
+            sum: SecondsCounter::new(),
+        }
+    }
+
+    pub fn observe(&self, d: Duration) {
+        let secs = d.as_secs_f64();
+        // Past the last bound the sample only lands in `+Inf` (= `count`).
+        if let Some(i) = LATENCY_BUCKETS.iter().position(|&le| secs <= le) {
+            self.buckets[i].fetch_add(1, Ordering::Relaxed);
+        }
+        self.count.fetch_add(1, Ordering::Relaxed);
+        self.sum.add(d);
+    }
+
+    pub fn count(&self) -> u64 {
+        self.count.load(Ordering::Relaxed)
+    }
+
+    /// Append the `_bucket`/`_sum`/`_count` lines for one label set.
+    /// `labels` is either empty or `key="value"` pairs without braces.
+    fn write_samples(&self, out: &mut String, name: &str, labels: &str) {
+        let sep = if labels.is_empty() { "" } else { "," };
+        let mut cumulative = 0;
+        for (le, bucket) in LATENCY_BUCKETS.iter().zip(&self.buckets) {
+            cumulative += bucket.load(Ordering::Relaxed);
+            let _ = writeln!(
+                out,
+                "{name}_bucket{{{labels}{sep}le=\"{le}\"}} {cumulative}"
+            );
+        }
+        let count = self.count();
+        let _ = writeln!(out, "{name}_bucket{{{labels}{sep}le=\"+Inf\"}} {count}");
+        let braces = |labels: &str| {
+            if labels.is_empty() {
+                String::new()
+            } else {
+                format!("{{{labels}}}")
+            }
+        };
+        let _ = writeln!(out, "{name}_sum{} {}", braces(labels), self.sum.seconds());
+        let _ = writeln!(out, "{name}_count{} {count}", braces(labels));
+    }
+}
+
+impl Default for Histogram {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+/// Where a query arrived.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum QuerySurface {
+    /// `cqs serve` `/api/*` routes.
+    Http,
+    /// The `cqs watch --serve` daemon socket.
+    Daemon,
+}
+
+impl QuerySurface {
+    pub fn as_str(self) -> &'static str {
+        match self {
+            QuerySurface::Http => "http",
+            QuerySurface::Daemon => "daemon",
+        }
+    }
+}
+
+struct QueryMetrics {
+    latency: Histogram,
+    errors: Counter,
+}
+
+impl QueryMetrics {
+    const fn new() -> Self {
+        Self {
+            latency: Histogram::new(),
+            errors: Counter::new(),
+        }
+    }
+}
+
+static HTTP_QUERIES: QueryMetrics = QueryMetrics::new();
+static DAEMON_QUERIES: QueryMetrics = QueryMetrics::new();
+
+/// Record one finished query on `surface`. `ok == false` also counts it in
+/// `cqs_query_errors_total`.
+pub fn record_query(surface: QuerySurface, elapsed: Duration, ok: bool) {
+    let m = match surface {
+        QuerySurface::Http => &HTTP_QUERIES,
+        QuerySurface::Daemon => &DAEMON_QUERIES,
+    };
+    m.latency.observe(elapsed);
+    if !ok {
+        m.errors.inc();
+    }
+}
+
+/// The index's change counter (`splade_generation`), which every chunk write
+/// or delete bumps. Set by the watch loop after each reindex pass and by
+/// `cqs serve` at scrape time.
+pub static INDEX_GENERATION: Gauge = Gauge::new();
+
+/// Files queued for reindex in the watch loop, published every tick.
+pub static WATCH_QUEUE_DEPTH: Gauge = Gauge::new();
+
+/// File events the watch loop dropped because its queue was full.
+pub static WATCH_DROPPED_EVENTS: Counter = Counter::new();
+
+/// File events the watch loop folded into an already-queued path.
+pub static WATCH_COALESCED_EVENTS: Counter = Counter::new();
+
+/// File event storms that switched the watch loop to a bulk pass.
+pub static WATCH_EVENT_STORMS: Counter = Counter::new();
+
+/// Wall-clock time of each completed watch reindex pass.
+pub static REINDEX_DURATION: Histogram = Histogram::new();
+
+/// Texts embedded by `Embedder::embed_documents`.
+pub static EMBED_TEXTS: Counter = Counter::new();
+
+/// Time spent in `Embedder::embed_documents`. Throughput is
+/// `rate(cqs_embed_texts_total) / rate(cqs_embed_seconds_total)`.
+pub static EMBED_SECONDS: SecondsCounter = SecondsCounter::new();
+
+/// SQLite statements that still failed with `SQLITE_BUSY`/`SQLITE_LOCKED`
+/// after the connection's busy-timeout retries ran out.
+pub static SQLITE_BUSY: Counter = Counter::new();
+
+/// Queries rerun on a fresh read snapshot after a busy failure.
+pub static SQLITE_BUSY_RETRIES: Counter = Counter::new();
+
+/// Chunk rows whose stored text no longer hashes to their `content_hash`.
+pub static CHUNK_CHECKSUM_MISMATCHES: Counter = Counter::new();
+
+/// Append one counter with its `HELP`/`TYPE` header. `name` carries the
+/// `_total` suffix.
+pub fn write_counter(out: &mut String, name: &str, help: &str, value: impl std::fmt::Display) {
+    let _ = writeln!(out, "# HELP {name} {help}");
+    let _ = writeln!(out, "# TYPE {name} counter");
+    let _ = writeln!(out, "{name} {value}");
+}
+
+/// Append one gauge with its `HELP`/`TYPE` header.
+pub fn write_gauge(out: &mut String, name: &str, help: &str, value: impl std::fmt::Display) {
+    let _ = writeln!(out, "# HELP {name} {help}");
+    let _ = writeln!(out, "# TYPE {name} gauge");
+    let _ = writeln!(out, "{name} {value}");
+}
+
+/// Every process-wide metric, in the text exposition format.
+pub fn render() -> String {
+    let mut out = String::with_capacity(4096);
+
+    let _ = writeln!(out, "# HELP cqs_build_info cqs version of this process.");
+    let _ = writeln!(out, "# TYPE cqs_build_info gauge");
+    let _ = writeln!(
+        out,
+        "cqs_build_info{{version=\"{}\"}} 1",
+        env!("CARGO_PKG_VERSION")
+    );
+
+    let name = "cqs_query_duration_seconds";
+    let _ = writeln!(
+        out,
+        "# HELP {name} Query latency by surface (http, daemon)."
+    );
+    let _ = writeln!(out, "# TYPE {name} histogram");
+    for surface in [QuerySurface::Http, QuerySurface::Daemon] {
+        let m = match surface {
+            QuerySurface::Http => &HTTP_QUERIES,
+            QuerySurface::Daemon => &DAEMON_QUERIES,
+        };
+        m.latency
+            .write_samples(&mut out, name, &format!("surface=\"{}\"", surface.as_str()));
+    }
+    let name = "cqs_query_errors_total";
+    let _ = writeln!(
+        out,
+        "# HELP {name} Queries that ended in an error, by surface."
+    );
+    let _ = writeln!(out, "# TYPE {name} counter");
+    let _ = writeln!(
+        out,
+        "{name}{{surface=\"http\"}} {}",
+        HTTP_QUERIES.errors.get()
+    );
+    let _ = writeln!(
+        out,
+        "{name}{{surface=\"daemon\"}} {}",
+        DAEMON_QUERIES.errors.get()
+    );
+
+    write_gauge(
+        &mut out,
+        "cqs_index_generation",
+        "Index change counter; bumps on every chunk write or delete.",
+        INDEX_GENERATION.get(),
+    );
+    write_gauge(
+        &mut out,
+        "cqs_watch_queue_depth",
+        "Files queued for reindex by the watch loop.",
+        WATCH_QUEUE_DEPTH.get(),
+    );
+    write_counter(
+        &mut out,
+        "cqs_watch_dropped_events_total",
+        "File events dropped because the watch queue was full.",
+        WATCH_DROPPED_EVENTS.get(),
+    );
+    write_counter(
+        &mut out,
+        "cqs_watch_coalesced_events_total",
+        "File events folded into a path already queued for reindex.",
+        WATCH_COALESCED_EVENTS.get(),
+    );
+    write_counter(
+        &mut out,
+        "cqs_watch_event_storms_total",
+        "File event storms handled with one bulk pass instead of per-file reindex.",
+        WATCH_EVENT_STORMS.get(),
+    );
+    let name = "cqs_reindex_duration_seconds";
+    let _ = writeln!(
+        out,
+        "# HELP {name} Duration of completed watch reindex passes."
+    );
+    let _ = writeln!(out, "# TYPE {name} histogram");
+    REINDEX_DURATION.write_samples(&mut out, name, "");
+    write_counter(
+        &mut out,
+        "cqs_embed_texts_total",
+        "Texts embedded.",
+        EMBED_TEXTS.get(),
+    );
+    write_counter(
+        &mut out,
+        "cqs_embed_seconds_total",
+        "Time spent embedding texts.",
+        EMBED_SECONDS.seconds(),
+    );
+    write_counter(
+        &mut out,
+        "cqs_sqlite_busy_total",
+        "SQLite statements that failed with SQLITE_BUSY or SQLITE_LOCKED after busy-timeout retries.",
+        SQLITE_BUSY.get(),
+    );
+    write_counter(
+        &mut out,
+        "cqs_sqlite_busy_retries_total",
+        "Queries rerun on a fresh read snapshot after SQLITE_BUSY or SQLITE_LOCKED.",
+        SQLITE_BUSY_RETRIES.get(),
+    );
+    write_counter(
+        &mut out,
+        "cqs_chunk_checksum_mismatches_total",
+        "Chunk rows read back whose content no longer matches their content_hash.",
+        CHUNK_CHECKSUM_MISMATCHES.get(),
+    );
+    out
+}
+
+/// Largest request head the exporter reads before giving up on a client.
+const MAX_REQUEST_HEAD: usize = 8 * 1024;
+
+/// Bind `addr` and answer `GET /metrics` with [`render`] on a background
+/// thread; any other path is `404`. Returns the bound address (useful with
+/// port `0`).
+///
+/// Connections are served one at a time with a short I/O timeout: a scraper
+/// every few seconds is the whole workload. There is no authentication —
+/// bind a loopback address, or put the port behind the same network policy
+/// as the scraper.
+pub fn spawn_exporter(addr: SocketAddr) -> std::io::Result<SocketAddr> {
+    let listener = TcpListener::bind(addr)?;
+    let bound = listener.local_addr()?;
+    if !bound.ip().is_loopback() {
+        tracing::warn!(
+            addr = %bound,
+            "metrics exporter bound to a non-loopback address; /metrics is unauthenticated"
+        );
+    }
+    std::thread::Builder::new()
+        .name("cqs-metrics".to_string())
+        .spawn(move || {
+            for stream in listener.incoming() {
+                match stream {
+                    Ok(s) => {
+                        if let Err(e) = answer(s) {
+                            tracing::debug!(error = %e, "metrics exporter: client I/O failed");
+                        }
+                    }
+                    Err(e) => tracing::debug!(error = %e, "metrics exporter: accept failed"),
+                }
+            }
+        })?;
+    tracing::info!(addr = %bound, "metrics exporter listening");
+    Ok(bound)
+}
+
+/// Serve one exporter connection.
+fn answer(mut stream: TcpStream) -> std::io::Result<()> {
+    let timeout = Some(Duration::from_secs(5));
+    stream.set_read_timeout(timeout)?;
+    stream.set_write_timeout(timeout)?;
+
+    let mut head = Vec::with_capacity(512);
+    let mut buf = [0u8; 512];
+    while !head.windows(4).any(|w| w == b"\r\n\r\n") {
+        let n = stream.read(&mut buf)?;
+        if n == 0 || head.len() + n > MAX_REQUEST_HEAD {
+            break;
+        }
+        head.extend_from_slice(&buf[..n]);
+    }
+    let request_line = head.split(|&b| b == b'\n').next().unwrap_or_default();
+    let (status, content_type, body) = if is_metrics_request(request_line) {
+        ("200 OK", CONTENT_TYPE, render())
+    } else {
+        ("404 Not Found", "text/plain", "not found\n".to_string())
+    };
+    write!(
+        stream,
+        "HTTP/1.1 {status}\r\nContent-Type: {content_type}\r\nContent-Length: {}\r\nConnection: close\r\n\r\n{body}",
+        body.len()
+    )?;
+    stream.flush()
+}
+
+/// `true` for `GET /metrics` (query string ignored).
+fn is_metrics_request(request_line: &[u8]) -> bool {
+    let line = String::from_utf8_lossy(request_line);
+    let mut parts = line.split_whitespace();
+    let (Some(method), Some(target)) = (parts.next(), parts.next()) else {
+        return false;
+    };
+    let path = target.split('?').next().unwrap_or(target);
+    method == "GET" && path == "/metrics"
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn histogram_buckets_are_cumulative() {
+        let h = Histogram::new();
+        h.observe(Duration::from_millis(3));
+        h.observe(Duration::from_millis(40));
+        h.observe(Duration::from_secs(60));
+        let mut out = String::new();
+        h.write_samples(&mut out, "t", "surface=\"x\"");
+        assert!(out.contains("t_bucket{surface=\"x\",le=\"0.005\"} 1\n"));
+        assert!(out.contains("t_bucket{surface=\"x\",le=\"0.05\"} 2\n"));
+        assert!(out.contains("t_bucket{surface=\"x\",le=\"30\"} 2\n"));
+        assert!(out.contains("t_bucket{surface=\"x\",le=\"+Inf\"} 3\n"));
+        assert!(out.contains("t_count{surface=\"x\"} 3\n"));
+    }
+
+    #[test]
+    fn render_has_a_type_line_per_family() {
+        let out = render();
+        for family in [
+            "cqs_query_duration_seconds histogram",
+            "cqs_query_errors_total counter",
+            "cqs_index_generation gauge",
+            "cqs_watch_queue_depth gauge",
+            "cqs_reindex_duration_seconds histogram",
+            "cqs_embed_texts_total counter",
+            "cqs_sqlite_busy_total counter",
+            "cqs_sqlite_busy_retries_total counter",
+            "cqs_chunk_checksum_mismatches_total counter",
+        ] {
+            assert!(
+                out.contains(&format!("# TYPE {family}\n")),
+                "missing {family}"
+            );
+        }
+        assert!(out.contains("cqs_query_duration_seconds_count{surface=\"daemon\"}"));
+    }
+
+    #[test]
+    fn exporter_serves_metrics_and_404s_elsewhere() {
+        let addr = spawn_exporter("127.0.0.1:0".parse().unwrap()).unwrap();
+        let get = |path: &str| {
+            let mut s = TcpStream::connect(addr).unwrap();
+            write!(s, "GET {path} HTTP/1.1\r\nHost: x\r\n\r\n").unwrap();
+            let mut resp = String::new();
+            s.read_to_string(&mut resp).unwrap();
+            resp
+        };
+        let resp = get("/metrics");
+        assert!(resp.starts_with("HTTP/1.1 200 OK"), "{resp}");
+        assert!(resp.contains("# TYPE cqs_build_info gauge"));
+        assert!(get("/other").starts_with("HTTP/1.1 404"));
+    }
+}
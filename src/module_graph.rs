@@ -0,0 +1,313 @@
+//! Module dependency graph — call edges aggregated to directory granularity
+//!
+//! Each call edge in `function_calls` is `(caller file, callee name)`. The
+//! callee name is resolved to its defining file through the callable chunks
+//! in the index; both ends are then mapped to their module (the parent
+//! directory, optionally truncated to `depth` components) and counted.
+//!
+//! Resolution is deliberately conservative so the graph shows coupling the
+//! index can vouch for:
+//! - a callee defined in the caller's own file or module is intra-module and
+//!   never produces an edge, even when other modules define the same name;
+//! - a callee defined in exactly one other module produces an edge;
+//! - a callee defined nowhere (std / third-party) is dropped;
+//! - a callee defined in several other modules is ambiguous and only counted
+//!   in [`ModuleGraph::unresolved_calls`].
+
+use std::collections::{BTreeMap, BTreeSet, HashMap};
+
+use crate::store::{Store, StoreError};
+
+/// Module label for files at the project root.
+pub const ROOT_MODULE: &str = ".";
+
+/// A module in the graph.
+#[derive(Debug, Clone, PartialEq, Eq, serde::Serialize)]
+pub struct ModuleNode {
+    /// Module path (`src/store`, or `.` for the project root)
+    pub name: String,
+    /// Number of indexed files (with callable chunks) in the module
+    pub files: usize,
+}
+
+/// Aggregated calls from one module into another.
+#[derive(Debug, Clone, PartialEq, Eq, serde::Serialize)]
+pub struct ModuleEdge {
+    pub from: String,
+    pub to: String,
+    /// Number of call sites behind this edge
+    pub calls: u64,
+}
+
+/// Cross-module coupling for the whole index.
+#[derive(Debug, Clone, serde::Serialize)]
+pub struct ModuleGraph {
+    /// Every module that owns a callable or makes a cross-module call, sorted
+    pub modules: Vec<ModuleNode>,
+    /// Edges sorted by `(from, to)`
+    pub edges: Vec<ModuleEdge>,
+    /// Calls whose callee name is defined in more than one other module
+    pub unresolved_calls: u64,
+    /// Directory depth modules were truncated to (`None` = full parent dir)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub depth: Option<usize>,
+}
+
+/// Module of a file path: its parent directory, truncated to the first
+/// `depth` components when given. Root-level files map to [`ROOT_MODULE`].
+pub fn module_of(path: &str, depth: Option<usize>) -> String {
+    let path = crate::normalize_slashes(path);
+    let Some((dir, _file)) = path.rsplit_once('/') else {
+        return ROOT_MODULE.to_string();
+    };
+    let dir = dir.trim_start_matches("./");
+    if dir.is_empty() || dir == "." {
+        return ROOT_MODULE.to_string();
+    }
+    match depth {
+        Some(n) => dir.split('/').take(n.max(1)).collect::<Vec<_>>().join("/"),
+        None => dir.to_string(),
+    }
+}
+
+/// Aggregate `(caller file, callee name, count)` rows into a module graph,
+/// resolving callee names through `defs` (`(name, defining file)` pairs).
+pub fn aggregate(
+    calls: &[(String, String, u64)],
+    defs: &[(String, String)],
+    depth: Option<usize>,
+) -> ModuleGraph {
+    let _span = tracing::debug_span!("module_graph_aggregate", calls = calls.len()).entered();
+
+    // name -> defining files
+    let mut defined_in: HashMap<&str, BTreeSet<&str>> = HashMap::new();
+    // module -> files with callables
+    let mut module_files: BTreeMap<String, BTreeSet<&str>> = BTreeMap::new();
+    for (name, origin) in defs {
+        defined_in
+            .entry(name.as_str())
+            .or_default()
+            .insert(origin.as_str());
+        module_files
+            .entry(module_of(origin, depth))
+            .or_default()
+            .insert(origin.as_str());
+    }
+
+    let mut edges: BTreeMap<(String, String), u64> = BTreeMap::new();
+    let mut unresolved_calls = 0u64;
+    for (file, callee, count) in calls {
+        let Some(origins) = defined_in.get(callee.as_str()) else {
+            continue; // external: std, third-party, or unindexed
+        };
+        if origins.contains(file.as_str()) {
+            continue;
+        }
+        let from = module_of(file, depth);
+        let targets: BTreeSet<String> = origins.iter().map(|o| module_of(o, depth)).collect();
+        if targets.contains(&from) {
+            continue;
+        }
+        if targets.len() > 1 {
+            unresolved_calls += count;
+            continue;
+        }
+        if let Some(to) = targets.into_iter().next() {
+            *edges.entry((from, to)).or_default() += count;
+        }
+    }
+
+    for (from, to) in edges.keys() {
+        module_files.entry(from.clone()).or_default();
+        module_files.entry(to.clone()).or_default();
+    }
+    let modules = module_files
+        .into_iter()
+        .map(|(name, files)| ModuleNode {
+            name,
+            files: files.len(),
+        })
+        .collect();
+    let edges = edges
+        .into_iter()
+        .map(|((from, to), calls)| ModuleEdge { from, to, calls })
+        .collect();
+
+    ModuleGraph {
+        modules,
+        edges,
+        unresolved_calls,
+        depth,
+    }
+}
+
+/// Build the module graph for the whole index.
+pub fn build_module_graph<Mode>(
+    store: &Store<Mode>,
+    depth: Option<usize>,
+) -> Result<ModuleGraph, StoreError> {
+    let _span = tracing::info_span!("build_module_graph", ?depth).entered();
+    let calls = store.call_counts_by_file()?;
+    let defs = store.callable_definition_origins()?;
+    Ok(aggregate(&calls, &defs, depth))
+}
+
+impl ModuleGraph {
+    /// Graphviz DOT rendering. Edge labels and pen widths carry call counts.
+    pub fn to_dot(&self) -> String {
+        let mut out = String::from("digraph modules {\n");
+        out.push_str("    rankdir=LR;\n");
+        out.push_str("    node [shape=box, fontname=\"monospace\"];\n");
+        for m in &self.modules {
+            out.push_str(&format!(
+                "    \"{}\" [label=\"{}\\n{} file{}\"];\n",
+                dot_escape(&m.name),
+                dot_escape(&m.name),
+                m.files,
+                if m.files == 1 { "" } else { "s" }
+            ));
+        }
+        for e in &self.edges {
+            // log-ish width so one hot edge doesn't swamp the picture
+            let width = 1.0 + (e.calls as f64).log10().max(0.0);
+            out.push_str(&format!(
+                "    \"{}\" -> \"{}\" [label=\"{}\", penwidth={:.1}];\n",
+                dot_escape(&e.from),
+                dot_escape(&e.to),
+                e.calls,
+                width
+            ));
+        }
+        out.push_str("}\n");
+        out
+    }
+
+    /// Mermaid flowchart rendering, paste-ready for markdown.
+    pub fn to_mermaid(&self) -> String {
+        let ids: HashMap<&str, String> = self
+            .modules
+            .iter()
+            .enumerate()
+            .map(|(i, m)| (m.name.as_str(), format!("M{i}")))
+            .collect();
+        let mut out = String::from("graph LR\n");
+        for m in &self.modules {
+            out.push_str(&format!(
+                "    {}[\"{}\"]\n",
+                ids[m.name.as_str()],
+                mermaid_escape(&m.name)
+            ));
+        }
+        for e in &self.edges {
+            out.push_str(&format!(
+                "    {} -->|{}| {}\n",
+                ids[e.from.as_str()],
+                e.calls,
+                ids[e.to.as_str()]
+            ));
+        }
+        out
+    }
+}
+
+fn dot_escape(s: &str) -> String {
+    s.replace('\\', "\\\\").replace('"', "\\\"")
+}
+
+fn mermaid_escape(s: &str) -> String {
+    s.replace('"', "&quot;")
+        .replace('<', "&lt;")
+        .replace('>', "&gt;")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn call(file: &str, callee: &str, n: u64) -> (String, String, u64) {
+        (file.to_string(), callee.to_string(), n)
+    }
+
+    fn def(name: &str, origin: &str) -> (String, String) {
+        (name.to_string(), origin.to_string())
+    }
+
+    #[test]
+    fn module_of_uses_parent_dir_and_depth() {
+        assert_eq!(
+            module_of("src/store/calls/query.rs", None),
+            "src/store/calls"
+        );
+        assert_eq!(module_of("src/store/calls/query.rs", Some(2)), "src/store");
+        assert_eq!(module_of("src\\cli\\mod.rs", None), "src/cli");
+        assert_eq!(module_of("build.rs", None), ROOT_MODULE);
+        assert_eq!(module_of("./build.rs", Some(1)), ROOT_MODULE);
+    }
+
+    #[test]
+    fn aggregate_counts_cross_module_calls() {
+        let calls = [
+            call("src/cli/search.rs", "open_store", 3),
+            call("src/cli/index.rs", "open_store", 2),
+            call("src/cli/search.rs", "parse_args", 5), // same module
+        ];
+        let defs = [
+            def("open_store", "src/store/mod.rs"),
+            def("parse_args", "src/cli/args.rs"),
+        ];
+        let g = aggregate(&calls, &defs, None);
+        assert_eq!(
+            g.edges,
+            vec![ModuleEdge {
+                from: "src/cli".into(),
+                to: "src/store".into(),
+                calls: 5
+            }]
+        );
+        assert_eq!(g.unresolved_calls, 0);
+        let names: Vec<_> = g.modules.iter().map(|m| m.name.as_str()).collect();
+        assert_eq!(names, vec!["src/cli", "src/store"]);
+    }
+
+    #[test]
+    fn aggregate_skips_external_and_counts_ambiguous() {
+        let calls = [
+            call("src/a/x.rs", "unwrap", 10), // not indexed
+            call("src/a/x.rs", "new", 4),     // defined in two other modules
+        ];
+        let defs = [def("new", "src/b/y.rs"), def("new", "src/c/z.rs")];
+        let g = aggregate(&calls, &defs, None);
+        assert!(g.edges.is_empty());
+        assert_eq!(g.unresolved_calls, 4);
+    }
+
+    #[test]
+    fn aggregate_prefers_local_definition() {
+        let calls = [call("src/a/x.rs", "helper", 1)];
+        let defs = [def("helper", "src/a/x.rs"), def("helper", "src/b/y.rs")];
+        let g = aggregate(&calls, &defs, None);
+        assert!(g.edges.is_empty());
+        assert_eq!(g.unresolved_calls, 0);
+    }
+
+    #[test]
+    fn aggregate_depth_folds_submodules() {
+        let calls = [call("src/store/calls/query.rs", "chunk_by_id", 1)];
+        let defs = [def("chunk_by_id", "src/store/chunks/crud.rs")];
+        assert_eq!(aggregate(&calls, &defs, None).edges.len(), 1);
+        assert!(aggregate(&calls, &defs, Some(2)).edges.is_empty());
+    }
+
+    #[test]
+    fn renderers_emit_every_edge() {
+        let calls = [call("src/cli/search.rs", "open_store", 3)];
+        let defs = [def("open_store", "src/store/mod.rs")];
+        let g = aggregate(&calls, &defs, None);
+        let dot = g.to_dot();
+        assert!(dot.starts_with("digraph modules {"));
+        assert!(dot.contains("\"src/cli\" -> \"src/store\" [label=\"3\""));
+        let mermaid = g.to_mermaid();
+        assert!(mermaid.starts_with("graph LR\n"));
+        assert!(mermaid.contains("M0 -->|3| M1"));
+    }
+}
@@ -1,4 +1,4 @@
-//! FTS normalization and identifier tokenization.
+//! FTS normalization, the FTS text analyzer, and identifier tokenization.
 
 /// Returns true for CJK Unified Ideographs and common CJK ranges.
 /// Covers Chinese, Japanese kanji, Korean hanja, and extensions.
@@ -111,8 +111,110 @@ impl<'a> Iterator for TokenizeIdentifierIter<'a> {
 #[allow(dead_code)]
 const MAX_FTS_OUTPUT_LEN: usize = crate::limits::FTS_NORMALIZE_MAX;
 
+/// Common code abbreviations and the words they stand for. Expansions are
+/// appended to indexed text (see [`normalize_for_fts_index`]) so a query
+/// spelling the word out still finds the abbreviated identifier. Kept to
+/// unambiguous pairs — every entry costs index bytes on every chunk that
+/// uses it, and a loose one (`res` → result or response?) adds noise.
+const ABBREVIATIONS: &[(&str, &[&str])] = &[
+    ("addr", &["address"]),
+    ("alloc", &["allocate"]),
+    ("arg", &["argument"]),
+    ("args", &["arguments"]),
+    ("auth", &["authentication"]),
+    ("btn", &["button"]),
+    ("buf", &["buffer"]),
+    ("cfg", &["config", "configuration"]),
+    ("cmd", &["command"]),
+    ("cnt", &["count"]),
+    ("config", &["configuration"]),
+    ("conn", &["connection"]),
+    ("ctx", &["context"]),
+    ("db", &["database"]),
+    ("dir", &["directory"]),
+    ("dst", &["destination"]),
+    ("env", &["environment"]),
+    ("err", &["error"]),
+    ("fmt", &["format"]),
+    ("id", &["identifier"]),
+    ("ids", &["identifiers"]),
+    ("idx", &["index"]),
+    ("impl", &["implementation"]),
+    ("init", &["initialize"]),
+    ("len", &["length"]),
+    ("mgr", &["manager"]),
+    ("msg", &["message"]),
+    ("num", &["number"]),
+    ("param", &["parameter"]),
+    ("params", &["parameters"]),
+    ("pkg", &["package"]),
+    ("prev", &["previous"]),
+    ("pwd", &["password"]),
+    ("repo", &["repository"]),
+    ("req", &["request"]),
+    ("resp", &["response"]),
+    ("src", &["source"]),
+    ("str", &["string"]),
+    ("tmp", &["temporary"]),
+    ("ts", &["timestamp"]),
+    ("util", &["utility"]),
+];
+
+/// Split one word (letters, digits, `_`) into lowercase FTS tokens.
+///
+/// Unlike [`tokenize_identifier`], an uppercase run stays one token, so an
+/// acronym reads the way a person types it: `getUserByID` → `get user by
+/// id`, `XMLParser` → `xml parser`, `parseURLs` → `parse urls`. Digits stay
+/// on the token they follow (`utf8Decode` → `utf8 decode`); CJK characters
+/// are one token each.
+fn split_code_word(word: &str, emit: &mut impl FnMut(String)) {
+    fn flush(current: &mut String, emit: &mut impl FnMut(String)) {
+        if !current.is_empty() {
+            emit(std::mem::take(current));
+        }
+    }
+
+    let chars: Vec<char> = word.chars().collect();
+    let mut current = String::new();
+    for (i, &c) in chars.iter().enumerate() {
+        if c == '_' {
+            flush(&mut current, emit);
+            continue;
+        }
+        if is_cjk(c) {
+            flush(&mut current, emit);
+            emit(c.to_string());
+            continue;
+        }
+        if c.is_uppercase() && i > 0 {
+            let prev = chars[i - 1];
+            let next = chars.get(i + 1).copied();
+            // camel hump: `userId`, `utf8Decode`
+            let hump = prev.is_lowercase() || prev.is_numeric();
+            // end of an acronym run: the `P` in `XMLParser`. A lone trailing
+            // `s` is a plural (`URLs`), not the start of a word.
+            let acronym_end = prev.is_uppercase()
+                && next.is_some_and(char::is_lowercase)
+                && !(next == Some('s') && !chars.get(i + 2).is_some_and(|c| c.is_lowercase()));
+            if hump || acronym_end {
+                flush(&mut current, emit);
+            }
+        }
+        current.extend(c.to_lowercase());
+    }
+    flush(&mut current, emit);
+}
+
 /// Normalize code text for FTS5 indexing.
-/// Splits identifiers on camelCase/snake_case boundaries and joins with spaces.
+/// Splits identifiers on camelCase/snake_case boundaries and joins with spaces;
+/// acronym runs stay whole (`getUserByID` → `get user by id`).
+///
+/// This is the analyzer for both sides of a match: FTS5 itself only runs its
+/// stock `unicode61` tokenizer over the output, which keeps the database
+/// readable by any SQLite build (no custom tokenizer to register). Text
+/// being stored goes through [`normalize_for_fts_index`], which adds
+/// abbreviation expansions; queries must not, or every expansion would
+/// become a required term.
 /// Used to make code searchable with natural language queries.
 /// Output is capped (default 16 KiB; override via `CQS_FTS_NORMALIZE_MAX`)
 /// to prevent memory issues with pathological inputs. Truncation is
@@ -130,6 +232,7 @@ const MAX_FTS_OUTPUT_LEN: usize = crate::limits::FTS_NORMALIZE_MAX;
 /// use cqs::normalize_for_fts;
 /// assert_eq!(normalize_for_fts("parseConfigFile"), "parse config file");
 /// assert_eq!(normalize_for_fts("fn get_user() {}"), "fn get user");
+/// assert_eq!(normalize_for_fts("getUserByID"), "get user by id");
 /// ```
 pub fn normalize_for_fts(text: &str) -> String {
     let mut result = String::new();
@@ -139,12 +242,12 @@ pub fn normalize_for_fts(text: &str) -> String {
     let input_len = text.len();
 
     let flush_word = |word: &str, result: &mut String| {
-        for token in tokenize_identifier_iter(word) {
+        split_code_word(word, &mut |token| {
             if !result.is_empty() {
                 result.push(' ');
             }
             result.push_str(&token);
-        }
+        });
     };
 
     for c in text.chars() {
@@ -188,6 +291,350 @@ pub fn normalize_for_fts(text: &str) -> String {
     result
 }
 
+/// [`normalize_for_fts`] for text written to an FTS table: the same tokens,
+/// then the expansion of each distinct abbreviation among them (`userId` →
+/// `user id identifier`). Expansions go after every original token so phrase
+/// and prefix queries over the original text (`search_by_name`) still match,
+/// and are dropped rather than pushing the output past the length cap.
+///
+/// This is the default [`FtsAnalyzer`]'s index side.
+pub fn normalize_for_fts_index(text: &str) -> String {
+    FtsAnalyzer::default().index(text)
+}
+
+/// Space-separated expansions of the distinct abbreviations among `tokens`
+/// (output of [`normalize_for_fts`]), in first-seen order.
+fn abbreviation_expansions(tokens: &str) -> String {
+    let mut seen: Vec<&str> = Vec::new();
+    let mut extra = String::new();
+    for token in tokens.split(' ') {
+        let Some((abbr, expansions)) = ABBREVIATIONS.iter().find(|(a, _)| *a == token) else {
+            continue;
+        };
+        if seen.contains(abbr) {
+            continue;
+        }
+        seen.push(abbr);
+        for word in *expansions {
+            if !extra.is_empty() {
+                extra.push(' ');
+            }
+            extra.push_str(word);
+        }
+    }
+    extra
+}
+
+/// A natural language the FTS analyzer can stem or drop stop words for.
+///
+/// Code identifiers are English-ish everywhere, but doc comments and
+/// markdown are not; a German or Japanese codebase gets better keyword
+/// recall when its own function words are dropped and its inflections
+/// folded. Japanese has no stemmer — the analyzer emits kana and kanji one
+/// character per token, so only its particles are worth filtering.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, serde::Serialize, serde::Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum FtsLanguage {
+    English,
+    German,
+    French,
+    Spanish,
+    Japanese,
+}
+
+impl FtsLanguage {
+    /// Every supported language, in the order `signature` lists them.
+    pub const ALL: [FtsLanguage; 5] = [
+        FtsLanguage::English,
+        FtsLanguage::German,
+        FtsLanguage::French,
+        FtsLanguage::Spanish,
+        FtsLanguage::Japanese,
+    ];
+
+    /// Lowercase name, as written in `.cqs.toml` and the index metadata.
+    pub fn as_str(self) -> &'static str {
+        match self {
+            FtsLanguage::English => "english",
+            FtsLanguage::German => "german",
+            FtsLanguage::French => "french",
+            FtsLanguage::Spanish => "spanish",
+            FtsLanguage::Japanese => "japanese",
+        }
+    }
+
+    /// Snowball algorithm for this language, `None` where there is none.
+    fn stemmer(self) -> Option<rust_stemmers::Algorithm> {
+        match self {
+            FtsLanguage::English => Some(rust_stemmers::Algorithm::English),
+            FtsLanguage::German => Some(rust_stemmers::Algorithm::German),
+            FtsLanguage::French => Some(rust_stemmers::Algorithm::French),
+            FtsLanguage::Spanish => Some(rust_stemmers::Algorithm::Spanish),
+            FtsLanguage::Japanese => None,
+        }
+    }
+
+    /// Whether [`FtsAnalyzer`] can stem this language.
+    pub fn has_stemmer(self) -> bool {
+        self.stemmer().is_some()
+    }
+
+    /// Function words dropped from FTS text. Lowercase, matching analyzer
+    /// tokens — so the German list carries its umlauts and the Japanese one
+    /// is single-character particles. Kept short: these are the words that
+    /// dominate prose, not an exhaustive list.
+    fn stop_words(self) -> &'static [&'static str] {
+        match self {
+            FtsLanguage::English => &[
+                "a", "an", "and", "are", "as", "at", "be", "but", "by", "for", "if", "in", "into",
+                "is", "it", "no", "not", "of", "on", "or", "such", "that", "the", "their", "then",
+                "there", "these", "they", "this", "to", "was", "will", "with",
+            ],
+            FtsLanguage::German => &[
+                "aber", "als", "am", "an", "auch", "auf", "aus", "bei", "bis", "das", "dass",
+                "dem", "den", "der", "des", "die", "ein", "eine", "einem", "einen", "einer",
+                "eines", "es", "für", "ist", "im", "in", "mit", "nach", "nicht", "noch", "nur",
+                "oder", "sich", "sie", "sind", "so", "um", "und", "von", "vom", "wenn", "werden",
+                "wird", "wie", "zu", "zum", "zur", "über",
+            ],
+            FtsLanguage::French => &[
+                "au", "aux", "avec", "ce", "ces", "cette", "dans", "de", "des", "du", "elle", "en",
+                "est", "et", "il", "la", "le", "les", "leur", "mais", "ne", "ou", "par", "pas",
+                "pour", "qui", "que", "sa", "se", "si", "son", "ses", "sont", "sur", "un", "une",
+            ],
+            FtsLanguage::Spanish => &[
+                "al", "como", "con", "de", "del", "el", "en", "es", "la", "las", "lo", "los", "no",
+                "o", "para", "pero", "por", "que", "se", "si", "son", "su", "sus", "un", "una",
+                "unas", "unos", "y",
+            ],
+            FtsLanguage::Japanese => &[
+                "の", "に", "は", "を", "が", "で", "て", "と", "も", "へ", "や", "か", "な", "た",
+                "し", "だ", "す", "ま",
+            ],
+        }
+    }
+
+    /// Best guess at the language `text` is written in, for deciding whether
+    /// a search query needs translating. CJK script reads as Japanese
+    /// (Chinese and Korean land here too — either way it isn't English);
+    /// otherwise it's the language whose stop words the text uses most, with
+    /// ties going to English. Identifiers and bare keyword queries carry no
+    /// stop words and read as English, which is what they usually are.
+    pub fn detect(text: &str) -> FtsLanguage {
+        if text.chars().any(is_cjk) {
+            return FtsLanguage::Japanese;
+        }
+        let lower = text.to_lowercase();
+        let words: Vec<&str> = lower
+            .split(|c: char| !c.is_alphanumeric())
+            .filter(|w| !w.is_empty())
+            .collect();
+        let hits = |lang: FtsLanguage| {
+            words
+                .iter()
+                .filter(|w| lang.stop_words().contains(*w))
+                .count()
+        };
+        let english = hits(FtsLanguage::English);
+        [
+            FtsLanguage::German,
+            FtsLanguage::French,
+            FtsLanguage::Spanish,
+        ]
+        .into_iter()
+        .map(|lang| (lang, hits(lang)))
+        .filter(|&(_, n)| n > english)
+        .max_by_key(|&(_, n)| n)
+        .map_or(FtsLanguage::English, |(lang, _)| lang)
+    }
+}
+
+impl std::str::FromStr for FtsLanguage {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        FtsLanguage::ALL
+            .into_iter()
+            .find(|l| l.as_str() == s)
+            .ok_or_else(|| {
+                format!(
+                    "unknown FTS language '{s}' (expected one of: {})",
+                    FtsLanguage::ALL.map(FtsLanguage::as_str).join(", ")
+                )
+            })
+    }
+}
+
+/// The text analyzer behind both sides of an FTS match: [`normalize_for_fts`]
+/// tokenization, then optional stop-word removal and stemming.
+///
+/// The analyzer is a property of the index, not of a query — a stemmed
+/// index only matches stemmed queries. The store records the analyzer it
+/// was built with in its metadata ([`FtsAnalyzer::signature`]) and analyzes
+/// queries with that one; changing `[index.fts]` in `.cqs.toml` takes
+/// effect at the next `cqs index`, which rebuilds the FTS tables.
+///
+/// The default analyzer stems nothing and drops nothing.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct FtsAnalyzer {
+    stemmer: Option<FtsLanguage>,
+    stop_words: Vec<FtsLanguage>,
+}
+
+impl FtsAnalyzer {
+    /// Build an analyzer. A `stemmer` language without a stemmer (Japanese)
+    /// stems nothing; config validation warns about it before it gets here.
+    /// Stop-word languages are deduplicated and kept in a canonical order
+    /// so equal settings have equal signatures.
+    pub fn new(stemmer: Option<FtsLanguage>, stop_words: &[FtsLanguage]) -> Self {
+        let stemmer = stemmer.filter(|l| l.has_stemmer());
+        let stop_words = FtsLanguage::ALL
+            .into_iter()
+            .filter(|l| stop_words.contains(l))
+            .collect();
+        Self {
+            stemmer,
+            stop_words,
+        }
+    }
+
+    /// True when this analyzer is plain [`normalize_for_fts`].
+    pub fn is_default(&self) -> bool {
+        self.stemmer.is_none() && self.stop_words.is_empty()
+    }
+
+    /// Stable description stored under the `fts_analyzer` metadata key,
+    /// e.g. `stemmer=german;stop_words=german,japanese`.
+    pub fn signature(&self) -> String {
+        let stop_words = if self.stop_words.is_empty() {
+            "none".to_string()
+        } else {
+            self.stop_words
+                .iter()
+                .map(|l| l.as_str())
+                .collect::<Vec<_>>()
+                .join(",")
+        };
+        format!(
+            "stemmer={};stop_words={stop_words}",
+            self.stemmer.map_or("none", FtsLanguage::as_str)
+        )
+    }
+
+    /// Parse a [`signature`](Self::signature). `None` for anything this
+    /// version did not write (an index from a newer cqs, or a damaged row).
+    pub fn from_signature(signature: &str) -> Option<Self> {
+        let (stemmer, stop_words) = signature.split_once(';')?;
+        let stemmer = match stemmer.strip_prefix("stemmer=")? {
+            "none" => None,
+            lang => Some(lang.parse::<FtsLanguage>().ok()?),
+        };
+        let stop_words = match stop_words.strip_prefix("stop_words=")? {
+            "none" => Vec::new(),
+            langs => langs
+                .split(',')
+                .map(|l| l.parse::<FtsLanguage>().ok())
+                .collect::<Option<Vec<_>>>()?,
+        };
+        Some(Self::new(stemmer, &stop_words))
+    }
+
+    /// Analyze query text for an FTS5 MATCH: tokenize and drop stop words,
+    /// then stem.
+    pub fn query(&self, text: &str) -> String {
+        self.stem_query(&self.query_terms(text))
+    }
+
+    /// [`query`](Self::query) without the stemming step, for callers that
+    /// rewrite the terms (synonym expansion) before handing them to
+    /// [`stem_query`](Self::stem_query).
+    pub fn query_terms(&self, text: &str) -> String {
+        self.drop_stop_words(&normalize_for_fts(text), true)
+    }
+
+    /// Stem the terms of an FTS5 query built from
+    /// [`query_terms`](Self::query_terms) output. Uppercase operators
+    /// (`OR`, `AND`) and grouping punctuation pass through, so a synonym-
+    /// expanded query keeps its shape.
+    pub fn stem_query(&self, query: &str) -> String {
+        let Some(stemmer) = self.snowball() else {
+            return query.to_string();
+        };
+        let mut out = String::with_capacity(query.len());
+        let mut word = String::new();
+        let flush = |word: &mut String, out: &mut String| {
+            if !word.is_empty() {
+                if word == "OR" || word == "AND" || word == "NOT" {
+                    out.push_str(word);
+                } else {
+                    out.push_str(&stemmer.stem(word));
+                }
+                word.clear();
+            }
+        };
+        for c in query.chars() {
+            if c.is_alphanumeric() || c == '_' {
+                word.push(c);
+            } else {
+                flush(&mut word, &mut out);
+                out.push(c);
+            }
+        }
+        flush(&mut word, &mut out);
+        out
+    }
+
+    /// Analyze text written to an FTS table: the query-side tokens, then the
+    /// analyzed abbreviation expansions (see [`normalize_for_fts_index`]),
+    /// which are dropped rather than pushing the output past the length cap.
+    pub fn index(&self, text: &str) -> String {
+        let tokens = normalize_for_fts(text);
+        // Expansions key off the raw tokens — `ids` must be looked up
+        // before a stemmer folds it to `id`.
+        let extra = abbreviation_expansions(&tokens);
+        let mut result = self.stem_query(&self.drop_stop_words(&tokens, true));
+        if !extra.is_empty() {
+            let extra = self.stem_query(&self.drop_stop_words(&extra, false));
+            let cap = crate::limits::fts_normalize_max();
+            if !extra.is_empty() && result.len() + 1 + extra.len() <= cap {
+                result.push(' ');
+                result.push_str(&extra);
+            }
+        }
+        result
+    }
+
+    /// Remove stop words from space-separated `tokens`. With `keep_all_stop`,
+    /// text made only of stop words (a function named `is_in`) is returned
+    /// unchanged instead of vanishing — the same rule on both sides, so such
+    /// a name still matches itself.
+    fn drop_stop_words(&self, tokens: &str, keep_all_stop: bool) -> String {
+        if self.stop_words.is_empty() {
+            return tokens.to_string();
+        }
+        let kept: Vec<&str> = tokens
+            .split(' ')
+            .filter(|t| !t.is_empty() && !self.is_stop_word(t))
+            .collect();
+        if kept.is_empty() && keep_all_stop {
+            return tokens.to_string();
+        }
+        kept.join(" ")
+    }
+
+    fn is_stop_word(&self, token: &str) -> bool {
+        self.stop_words
+            .iter()
+            .any(|l| l.stop_words().contains(&token))
+    }
+
+    fn snowball(&self) -> Option<rust_stemmers::Stemmer> {
+        self.stemmer
+            .and_then(FtsLanguage::stemmer)
+            .map(rust_stemmers::Stemmer::create)
+    }
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -242,9 +689,9 @@ mod tests {
 
     #[test]
     fn test_normalize_for_fts_output_bounded() {
-        // Pathological input: all uppercase chars tokenize to "a b c d ..."
-        // which roughly doubles the length
-        let long_upper = "A".repeat(20000);
+        // Pathological input: every hump is a token boundary, so "AbAb..."
+        // tokenizes to "ab ab ..." and grows by half
+        let long_upper = "Ab".repeat(10000);
         let result = normalize_for_fts(&long_upper);
         assert!(
             result.len() <= MAX_FTS_OUTPUT_LEN,
@@ -280,6 +727,129 @@ mod tests {
         assert!(result.is_char_boundary(result.len()));
     }
 
+    #[test]
+    fn test_normalize_for_fts_keeps_acronyms_whole() {
+        assert_eq!(normalize_for_fts("getUserByID"), "get user by id");
+        assert_eq!(normalize_for_fts("XMLParser"), "xml parser");
+        assert_eq!(normalize_for_fts("parseURLs"), "parse urls");
+        assert_eq!(normalize_for_fts("IDsForUser"), "ids for user");
+        assert_eq!(normalize_for_fts("utf8Decode"), "utf8 decode");
+        assert_eq!(normalize_for_fts("HTTP_STATUS_OK"), "http status ok");
+        // The query side of the issue that motivated this: same tokens.
+        assert_eq!(normalize_for_fts("get user by id"), "get user by id");
+    }
+
+    #[test]
+    fn test_normalize_for_fts_index_appends_expansions() {
+        assert_eq!(
+            normalize_for_fts_index("fn getUserById(ctx: &Ctx, id: u64)"),
+            "fn get user by id ctx ctx id u64 identifier context"
+        );
+        // No abbreviation, no change.
+        assert_eq!(normalize_for_fts_index("parseFile"), "parse file");
+        // Expansions never split the original phrase.
+        assert!(normalize_for_fts_index("load_cfg_file").starts_with("load cfg file "));
+    }
+
+    #[test]
+    fn test_fts_analyzer_default_is_plain_normalization() {
+        let a = FtsAnalyzer::default();
+        assert!(a.is_default());
+        let text = "fn getUserById(ctx: &Ctx) -> the user";
+        assert_eq!(a.query(text), normalize_for_fts(text));
+        assert_eq!(a.index(text), normalize_for_fts_index(text));
+    }
+
+    #[test]
+    fn test_fts_analyzer_drops_stop_words() {
+        let de = FtsAnalyzer::new(None, &[FtsLanguage::German]);
+        assert_eq!(de.query("die Datei wird gelesen"), "datei gelesen");
+        let ja = FtsAnalyzer::new(None, &[FtsLanguage::Japanese]);
+        assert_eq!(ja.query("設定の読み込み"), "設 定 読 み 込 み");
+        // A name made only of stop words survives on both sides.
+        let en = FtsAnalyzer::new(None, &[FtsLanguage::English]);
+        assert_eq!(en.query("is_in"), "is in");
+        assert_eq!(en.index("is_in"), "is in");
+    }
+
+    #[test]
+    fn test_fts_analyzer_stems_both_sides() {
+        let en = FtsAnalyzer::new(Some(FtsLanguage::English), &[]);
+        assert_eq!(en.query("parsing"), en.query("parses"));
+        let indexed = en.index("fn parse_files(cfg: &Cfg)");
+        for term in en.query("parsing file").split(' ') {
+            assert!(indexed.split(' ').any(|t| t == term), "{term} in {indexed}");
+        }
+        // Expansions are looked up before stemming, then stemmed.
+        assert!(indexed.ends_with(&en.query("config configuration")));
+
+        let de = FtsAnalyzer::new(Some(FtsLanguage::German), &[]);
+        assert_eq!(de.query("Benutzer"), de.query("Benutzern"));
+    }
+
+    #[test]
+    fn test_fts_analyzer_stem_query_keeps_operators() {
+        let en = FtsAnalyzer::new(Some(FtsLanguage::English), &[]);
+        assert_eq!(
+            en.stem_query("(parsing OR parser) AND files"),
+            format!(
+                "({} OR {}) AND {}",
+                en.query("parsing"),
+                en.query("parser"),
+                en.query("files")
+            )
+        );
+    }
+
+    #[test]
+    fn test_detect_language() {
+        use FtsLanguage::*;
+        assert_eq!(
+            FtsLanguage::detect("where is the config file loaded"),
+            English
+        );
+        assert_eq!(FtsLanguage::detect("parse_config"), English);
+        assert_eq!(
+            FtsLanguage::detect("wo wird die Konfiguration gelesen"),
+            German
+        );
+        assert_eq!(
+            FtsLanguage::detect("fonction qui lit le fichier de configuration"),
+            French
+        );
+        assert_eq!(
+            FtsLanguage::detect("función que lee el archivo de configuración"),
+            Spanish
+        );
+        assert_eq!(FtsLanguage::detect("設定ファイルを読み込む関数"), Japanese);
+    }
+
+    #[test]
+    fn test_fts_analyzer_signature_round_trip() {
+        assert_eq!(
+            FtsAnalyzer::default().signature(),
+            "stemmer=none;stop_words=none"
+        );
+        // Order and duplicates in the input don't change the signature.
+        let a = FtsAnalyzer::new(
+            Some(FtsLanguage::German),
+            &[
+                FtsLanguage::Japanese,
+                FtsLanguage::German,
+                FtsLanguage::Japanese,
+            ],
+        );
+        assert_eq!(a.signature(), "stemmer=german;stop_words=german,japanese");
+        assert_eq!(FtsAnalyzer::from_signature(&a.signature()), Some(a));
+        // Japanese has no stemmer; asking for one is a no-op.
+        assert!(FtsAnalyzer::new(Some(FtsLanguage::Japanese), &[]).is_default());
+        assert_eq!(
+            FtsAnalyzer::from_signature("stemmer=klingon;stop_words=none"),
+            None
+        );
+        assert_eq!(FtsAnalyzer::from_signature("garbage"), None);
+    }
+
     mod fuzz {
         use super::*;
         use proptest::prelude::*;
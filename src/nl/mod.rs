@@ -8,7 +8,9 @@ pub mod fts;
 mod markdown;
 
 pub use fields::extract_body_keywords;
-pub use fts::{normalize_for_fts, tokenize_identifier};
+pub use fts::{
+    normalize_for_fts, normalize_for_fts_index, tokenize_identifier, FtsAnalyzer, FtsLanguage,
+};
 #[allow(unused_imports)]
 pub use markdown::{parse_jsdoc_tags, strip_markdown_noise, JsDocInfo};
 
@@ -303,6 +305,12 @@ pub fn generate_nl_with_template_and_seq_len(
     // Name line (no prefix)
     parts.push(name_words);
 
+    // Continuation marker: a later window of a symbol too long for one
+    // embedding carries no doc, so say which symbol it continues.
+    if let Some(idx) = chunk.window_idx.filter(|&i| i > 0) {
+        parts.push(format!("continued, part {}", idx + 1));
+    }
+
     // Extension: "extension of {name}" label
     if chunk.chunk_type == ChunkType::Extension {
         let name_tokens = tokenize_identifier(&chunk.name).join(" ");
@@ -607,6 +615,36 @@ mod tests {
         assert!(nl.contains("Returns config"));
     }
 
+    #[test]
+    fn test_generate_nl_marks_continuation_windows() {
+        let mut chunk = Chunk {
+            id: "test.rs:1:0:abcd1234:w0".to_string(),
+            file: PathBuf::from("test.rs"),
+            language: Language::Rust,
+            chunk_type: ChunkType::Function,
+            name: "parseConfig".to_string(),
+            signature: "fn parseConfig(path: &str) -> Config".to_string(),
+            content: "{}".to_string(),
+            line_start: 1,
+            line_end: 900,
+            byte_start: 0,
+            doc: None,
+            content_hash: "abcd1234".to_string(),
+            canonical_hash: String::new(),
+            parent_id: Some("test.rs:1:0:abcd1234".to_string()),
+            window_idx: Some(0),
+            parent_type_name: None,
+            parser_version: 0,
+        };
+        let first = generate_nl_description_with_seq_len(&chunk, 512);
+        assert!(!first.contains("continued"));
+
+        chunk.window_idx = Some(2);
+        let later = generate_nl_description_with_seq_len(&chunk, 512);
+        assert!(later.contains("parse config. continued, part 3"));
+        assert!(later.contains("Signature: fn parseConfig"));
+    }
+
     #[test]
     fn test_generate_nl_with_jsdoc() {
         // JavaScript function with JSDoc - params from signature, return from JSDoc
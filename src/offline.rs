@@ -0,0 +1,165 @@
+//! Offline (air-gapped) mode.
+//!
+//! `offline = true` in `.cqs.toml` (or `CQS_OFFLINE=1`) turns every path
+//! that would reach the network into a hard error instead of an attempt:
+//! Hugging Face model downloads for the embedder and reranker, the Anthropic
+//! batch API, external vector stores, and `cqs doctor`'s endpoint probe. Models already in the
+//! Hugging Face cache (or under `CQS_ONNX_DIR`) still load, resolved from
+//! disk without contacting the Hub.
+//!
+//! The one exception is an endpoint on a loopback address (`localhost`,
+//! `127.0.0.0/8`, `[::1]`) — a `local` LLM provider, or a Qdrant or
+//! pgvector server: that traffic never leaves the machine, and it is how an
+//! air-gapped site runs summaries.
+//!
+//! Call sites check with [`check`] (or [`check_url`] for endpoints) before
+//! building a client, so nothing is opened and then refused.
+
+use std::path::PathBuf;
+use std::sync::OnceLock;
+
+/// A network operation refused because offline mode is on.
+#[derive(Debug, thiserror::Error)]
+#[error("offline mode: refusing network access for {what}{}", hint_suffix(.hint))]
+pub struct OfflineError {
+    /// What tried to reach the network, e.g. `"model download (BAAI/bge-large-en-v1.5)"`.
+    pub what: String,
+    /// How to get what was wanted without the network, when there is a way.
+    pub hint: Option<String>,
+}
+
+fn hint_suffix(hint: &Option<String>) -> String {
+    hint.as_deref()
+        .map(|h| format!(" ({h})"))
+        .unwrap_or_default()
+}
+
+/// `offline` from `.cqs.toml`, pushed in once at startup.
+static OFFLINE: OnceLock<bool> = OnceLock::new();
+
+/// Install the config value. Later calls are no-ops (OnceLock).
+pub fn set_from_config(offline: bool) {
+    let _ = OFFLINE.set(offline);
+}
+
+/// Whether offline mode is on. Resolution: `CQS_OFFLINE` env (`0` / `false`
+/// / `no` off, anything else on) > config `offline` > off.
+pub fn enabled() -> bool {
+    match std::env::var("CQS_OFFLINE").as_deref() {
+        Ok("0") | Ok("false") | Ok("no") => false,
+        Ok(_) => true,
+        Err(_) => OFFLINE.get().copied().unwrap_or(false),
+    }
+}
+
+/// Refuse `what` when offline mode is on.
+pub fn check(what: &str) -> Result<(), OfflineError> {
+    if enabled() {
+        tracing::warn!(what, "Offline mode blocked network access");
+        return Err(OfflineError {
+            what: what.to_string(),
+            hint: None,
+        });
+    }
+    Ok(())
+}
+
+/// Refuse a request to `url` when offline mode is on, unless its host is a
+/// loopback address.
+pub fn check_url(what: &str, url: &str) -> Result<(), OfflineError> {
+    if !enabled() || is_loopback_url(url) {
+        return Ok(());
+    }
+    tracing::warn!(what, url, "Offline mode blocked network access");
+    Err(OfflineError {
+        what: format!("{what} at {url}"),
+        hint: Some("only loopback endpoints are allowed offline".to_string()),
+    })
+}
+
+/// Resolve `file` from the Hugging Face cache for `repo` without touching
+/// the network. Errors when the file was never downloaded.
+pub fn hf_cached(repo: &str, file: &str) -> Result<PathBuf, OfflineError> {
+    hf_hub::Cache::from_env()
+        .model(repo.to_string())
+        .get(file)
+        .ok_or_else(|| OfflineError {
+            what: format!("model download ({repo}/{file})"),
+            hint: Some(
+                "not in the Hugging Face cache; fetch it on a networked machine and copy \
+                 HF_HOME over, or point CQS_ONNX_DIR at a local copy"
+                    .to_string(),
+            ),
+        })
+}
+
+/// Host of an `http(s)://` or `postgres(ql)://` URL is `localhost`,
+/// `127.x.x.x`, or `::1`.
+fn is_loopback_url(url: &str) -> bool {
+    let lower = url.to_ascii_lowercase();
+    let Some(rest) = ["http://", "https://", "postgres://", "postgresql://"]
+        .iter()
+        .find_map(|scheme| lower.strip_prefix(scheme))
+    else {
+        return false;
+    };
+    let authority = rest.split(['/', '?', '#']).next().unwrap_or("");
+    // Userinfo (`user:pass@host`) is not the host.
+    let host_port = authority.rsplit('@').next().unwrap_or("");
+    let host = if let Some(v6) = host_port.strip_prefix('[') {
+        v6.split(']').next().unwrap_or("")
+    } else {
+        host_port.split(':').next().unwrap_or("")
+    };
+    if host == "localhost" {
+        return true;
+    }
+    host.parse::<std::net::IpAddr>()
+        .is_ok_and(|ip| ip.is_loopback())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn loopback_urls_are_recognised() {
+        for url in [
+            "http://localhost:8080/v1",
+            "http://LOCALHOST/v1",
+            "https://127.0.0.1/v1",
+            "http://127.3.4.5:11434",
+            "http://[::1]:8000/v1",
+            "http://user:pw@localhost:8080/v1",
+            "postgres://cqs@localhost/cqs",
+            "postgresql://cqs:pw@127.0.0.1:5432/cqs",
+        ] {
+            assert!(is_loopback_url(url), "{url}");
+        }
+        for url in [
+            "https://api.anthropic.com/v1",
+            "http://10.0.0.5:8080/v1",
+            "http://localhost.evil.com/v1",
+            "http://llm.localhost/v1",
+            "http://evil.com/?localhost",
+            "http://localhost@evil.com/v1",
+            "ftp://localhost/",
+            "postgres://cqs@db.internal/cqs",
+            "localhost:8080",
+        ] {
+            assert!(!is_loopback_url(url), "{url}");
+        }
+    }
+
+    #[test]
+    fn error_names_what_was_refused() {
+        let err = OfflineError {
+            what: "model download (org/model/model.onnx)".to_string(),
+            hint: Some("copy it over".to_string()),
+        };
+        assert_eq!(
+            err.to_string(),
+            "offline mode: refusing network access for model download (org/model/model.onnx) (copy it over)"
+        );
+    }
+}
@@ -0,0 +1,561 @@
+//! Code ownership: CODEOWNERS rules and per-chunk `git blame` summaries.
+//!
+//! Two sources answer "who owns this code":
+//!
+//! - **CODEOWNERS** — the team a path is assigned to. Read from the working
+//!   tree at query time ([`CodeOwners::load`]), so an edited file applies to
+//!   the next search without a reindex.
+//! - **Blame** — the people who last touched most of a chunk's lines. Each
+//!   file is blamed once at index time ([`refresh_chunk_authors`]) and the top
+//!   authors per chunk are stored in `chunk_authors` (schema v42).
+//!
+//! `search --owner <NAME>` keeps chunks whose file CODEOWNERS assigns to
+//! `NAME` or whose stored authors include `NAME`; `--with-owners` attaches
+//! both to each result.
+
+use std::collections::{HashMap, HashSet};
+use std::path::Path;
+use std::sync::OnceLock;
+
+use rayon::prelude::*;
+use serde::{Deserialize, Serialize};
+
+use crate::store::{BlameTarget, ReadWrite, Store, StoreError};
+
+/// CODEOWNERS locations, in the order GitHub searches them (plus GitLab's
+/// `.gitlab/`). The first file found is the only one used.
+pub const CODEOWNERS_LOCATIONS: &[&str] = &[
+    ".github/CODEOWNERS",
+    "CODEOWNERS",
+    "docs/CODEOWNERS",
+    ".gitlab/CODEOWNERS",
+];
+
+/// Authors kept per chunk, by share of the chunk's lines.
+pub const MAX_CHUNK_AUTHORS: usize = 3;
+
+/// One CODEOWNERS line: the globs its pattern expands to and its owners.
+#[derive(Debug, Clone)]
+struct OwnerRule {
+    matcher: globset::GlobSet,
+    owners: Vec<String>,
+}
+
+/// Parsed CODEOWNERS rules. The last matching rule wins, as on GitHub; a
+/// matching rule with no owners leaves the path unowned.
+#[derive(Debug, Clone, Default)]
+pub struct CodeOwners {
+    rules: Vec<OwnerRule>,
+}
+
+impl CodeOwners {
+    /// Load the project's CODEOWNERS file from the first of
+    /// [`CODEOWNERS_LOCATIONS`] that exists. `None` when there is none or
+    /// it can't be read.
+    pub fn load(root: &Path) -> Option<Self> {
+        let _span = tracing::debug_span!("codeowners_load").entered();
+        for rel in CODEOWNERS_LOCATIONS {
+            let path = root.join(rel);
+            if !path.is_file() {
+                continue;
+            }
+            return match std::fs::read_to_string(&path) {
+                Ok(text) => {
+                    let owners = Self::parse(&text);
+                    tracing::debug!(file = rel, rules = owners.rules.len(), "Loaded CODEOWNERS");
+                    Some(owners)
+                }
+                Err(e) => {
+                    tracing::warn!(path = %path.display(), error = %e, "Failed to read CODEOWNERS");
+                    None
+                }
+            };
+        }
+        None
+    }
+
+    /// Parse CODEOWNERS text. Comments, blank lines, GitLab `[Section]`
+    /// headers, and patterns that don't compile are skipped.
+    pub fn parse(text: &str) -> Self {
+        let mut rules = Vec::new();
+        for line in text.lines() {
+            let line = match line.find(" #") {
+                Some(i) => &line[..i],
+                None => line,
+            }
+            .trim();
+            if line.is_empty() || line.starts_with('#') || line.starts_with('[') {
+                continue;
+            }
+            let mut tokens = line.split_whitespace();
+            let Some(pattern) = tokens.next() else {
+                continue;
+            };
+            let owners: Vec<String> = tokens.map(String::from).collect();
+            let mut builder = globset::GlobSetBuilder::new();
+            let mut ok = true;
+            for glob in pattern_globs(pattern) {
+                match globset::GlobBuilder::new(&glob)
+                    .literal_separator(true)
+                    .build()
+                {
+                    Ok(g) => {
+                        builder.add(g);
+                    }
+                    Err(e) => {
+                        tracing::debug!(pattern, error = %e, "Skipping CODEOWNERS pattern");
+                        ok = false;
+                        break;
+                    }
+                }
+            }
+            if !ok {
+                continue;
+            }
+            if let Ok(matcher) = builder.build() {
+                rules.push(OwnerRule { matcher, owners });
+            }
+        }
+        Self { rules }
+    }
+
+    /// Owners of `path` (project-relative, `/`-separated). Empty when no
+    /// rule matches or the last matching rule lists no owners.
+    pub fn owners_of(&self, path: &str) -> &[String] {
+        self.rules
+            .iter()
+            .rev()
+            .find(|r| r.matcher.is_match(path))
+            .map(|r| r.owners.as_slice())
+            .unwrap_or_default()
+    }
+
+    /// The subset of `origins` whose owners include `name`
+    /// ([`owner_matches`]).
+    pub fn origins_owned_by<'a>(
+        &self,
+        name: &str,
+        origins: impl IntoIterator<Item = &'a str>,
+    ) -> HashSet<String> {
+        origins
+            .into_iter()
+            .filter(|origin| {
+                self.owners_of(origin)
+                    .iter()
+                    .any(|owner| owner_matches(name, owner))
+            })
+            .map(String::from)
+            .collect()
+    }
+}
+
+/// Expand a CODEOWNERS pattern into globs over project-relative paths.
+///
+/// Follows gitignore anchoring: a pattern with a leading or inner `/` is
+/// relative to the root, anything else matches at any depth. A pattern
+/// also matches everything under the directory it names, except when its
+/// last segment ends in `*` (`docs/*` owns `docs/a.md`, not `docs/a/b.md`).
+fn pattern_globs(pattern: &str) -> Vec<String> {
+    let dir_only = pattern.ends_with('/');
+    let core = pattern.trim_start_matches('/').trim_end_matches('/');
+    if core.is_empty() {
+        return vec!["**".to_string()];
+    }
+    let anchored = pattern.starts_with('/') || core.contains('/');
+    let base = if anchored {
+        core.to_string()
+    } else {
+        format!("**/{core}")
+    };
+    if dir_only {
+        vec![format!("{base}/**")]
+    } else if core.ends_with('*') {
+        vec![base]
+    } else {
+        vec![format!("{base}/**"), base]
+    }
+}
+
+/// The `--owner` restriction, resolved for [`crate::store::SearchFilter`].
+///
+/// CODEOWNERS lives in the working tree, not the index, so the caller turns
+/// it into `origins` ([`CodeOwners::origins_owned_by`]); the store adds the
+/// chunks whose blame authors match `name`.
+#[derive(Debug, Clone, Default, PartialEq)]
+pub struct OwnerFilter {
+    pub name: String,
+    /// Origins CODEOWNERS assigns to `name`.
+    pub origins: HashSet<String>,
+}
+
+/// `true` when `query` names `owner`. Case-insensitive, `@` optional, and a
+/// bare team name matches the org-qualified handle (`payments` matches
+/// `@acme/payments`).
+pub fn owner_matches(query: &str, owner: &str) -> bool {
+    let query = query.trim().trim_start_matches('@').to_lowercase();
+    let owner = owner.trim_start_matches('@').to_lowercase();
+    if query.is_empty() {
+        return false;
+    }
+    owner == query
+        || owner
+            .rsplit_once('/')
+            .is_some_and(|(_, team)| team == query)
+}
+
+/// One of a chunk's top blame authors.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct AuthorShare {
+    pub name: String,
+    pub email: String,
+    /// Lines of the chunk this author last touched.
+    pub lines: u32,
+    /// `lines` as a fraction of the chunk's lines, rounded to two places.
+    pub share: f32,
+}
+
+impl AuthorShare {
+    /// `true` when `query` is this author's name, email, or email local
+    /// part ([`author_matches`]).
+    pub fn matches(&self, query: &str) -> bool {
+        author_matches(query, &self.name, &self.email)
+    }
+}
+
+/// `true` when `query` is the author's name, email, or email local part,
+/// compared case-insensitively. `@` optional.
+pub fn author_matches(query: &str, name: &str, email: &str) -> bool {
+    let query = query.trim().trim_start_matches('@').to_lowercase();
+    if query.is_empty() {
+        return false;
+    }
+    let email = email.to_lowercase();
+    name.to_lowercase() == query
+        || email == query
+        || email
+            .split_once('@')
+            .is_some_and(|(local, _)| local == query)
+}
+
+/// Everything known about who owns one chunk: its CODEOWNERS owners and its
+/// stored top blame authors. Either list may be empty.
+#[derive(Debug, Clone, Default, PartialEq, Serialize)]
+pub struct ChunkOwnership {
+    pub owners: Vec<String>,
+    pub authors: Vec<AuthorShare>,
+}
+
+impl ChunkOwnership {
+    /// `true` when neither source knows an owner.
+    pub fn is_empty(&self) -> bool {
+        self.owners.is_empty() && self.authors.is_empty()
+    }
+}
+
+/// `[index] blame_authors`, pushed in once at startup.
+static BLAME_AUTHORS: OnceLock<bool> = OnceLock::new();
+
+/// Push `[index] blame_authors` into the index pipeline. Later calls are
+/// no-ops (OnceLock).
+pub fn set_blame_from_config(enabled: bool) {
+    let _ = BLAME_AUTHORS.set(enabled);
+}
+
+/// Whether `cqs index` records blame summaries. Resolution:
+/// `CQS_BLAME_AUTHORS` env (`0` / `false` / `no` off, anything else on) >
+/// `[index] blame_authors` > on.
+pub fn blame_enabled() -> bool {
+    match std::env::var("CQS_BLAME_AUTHORS").as_deref() {
+        Ok("0") | Ok("false") | Ok("no") => false,
+        Ok(_) => true,
+        Err(_) => BLAME_AUTHORS.get().copied().unwrap_or(true),
+    }
+}
+
+/// Who last touched one committed line, and when.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct BlameLine {
+    pub name: String,
+    pub email: String,
+    /// Author time, Unix seconds.
+    pub time: i64,
+}
+
+/// Author of one blamed line; `None` for lines not committed yet.
+pub type LineAuthor = Option<BlameLine>;
+
+/// Per-line authors from `git blame --line-porcelain`, in file order.
+fn parse_line_porcelain(text: &str) -> Vec<LineAuthor> {
+    let mut lines = Vec::new();
+    let mut uncommitted = false;
+    let mut name = String::new();
+    let mut email = String::new();
+    let mut time = 0;
+    let mut in_header = false;
+    for line in text.lines() {
+        if line.starts_with('\t') {
+            lines.push((!uncommitted).then(|| BlameLine {
+                name: name.clone(),
+                email: email.clone(),
+                time,
+            }));
+            in_header = false;
+            continue;
+        }
+        if !in_header {
+            // Header: `<sha> <orig-line> <final-line> [<group-size>]`.
+            let sha = line.split(' ').next().unwrap_or_default();
+            uncommitted = !sha.is_empty() && sha.bytes().all(|b| b == b'0');
+            name.clear();
+            email.clear();
+            time = 0;
+            in_header = true;
+        } else if let Some(v) = line.strip_prefix("author-mail ") {
+            email = v.trim_start_matches('<').trim_end_matches('>').to_string();
+        } else if let Some(v) = line.strip_prefix("author-time ") {
+            time = v.trim().parse().unwrap_or_default();
+        } else if let Some(v) = line.strip_prefix("author ") {
+            name = v.to_string();
+        }
+    }
+    lines
+}
+
+/// Top authors of lines `start..=end` (1-based). `None` when the range runs
+/// past the blamed file or holds uncommitted lines — the file changed since
+/// it was indexed, or the summary would be missing the pending author, so
+/// the chunk is left for a later index.
+fn summarize_authors(lines: &[LineAuthor], start: u32, end: u32) -> Option<Vec<AuthorShare>> {
+    let (start, end) = (start.max(1) as usize, end as usize);
+    if end < start || end > lines.len() {
+        return None;
+    }
+    let mut counts: HashMap<(&str, &str), u32> = HashMap::new();
+    for author in &lines[start - 1..end] {
+        let author = author.as_ref()?;
+        *counts
+            .entry((author.name.as_str(), author.email.as_str()))
+            .or_default() += 1;
+    }
+    let total = (end - start + 1) as f32;
+    let mut authors: Vec<AuthorShare> = counts
+        .into_iter()
+        .map(|((name, email), n)| AuthorShare {
+            name: name.to_string(),
+            email: email.to_string(),
+            lines: n,
+            share: (n as f32 / total * 100.0).round() / 100.0,
+        })
+        .collect();
+    authors.sort_by(|a, b| b.lines.cmp(&a.lines).then_with(|| a.name.cmp(&b.name)));
+    authors.truncate(MAX_CHUNK_AUTHORS);
+    Some(authors)
+}
+
+/// `git blame --line-porcelain` of `origin` in `root`, parsed per line.
+/// `None` when git fails (untracked file, file gone).
+pub fn blame_file(root: &Path, origin: &str) -> Option<Vec<LineAuthor>> {
+    let output = std::process::Command::new("git")
+        .args(["blame", "--line-porcelain", "--", origin])
+        .current_dir(root)
+        .output()
+        .ok()?;
+    if !output.status.success() {
+        tracing::debug!(
+            origin,
+            stderr = %String::from_utf8_lossy(&output.stderr).trim(),
+            "git blame failed, skipping file"
+        );
+        return None;
+    }
+    Some(parse_line_porcelain(&String::from_utf8_lossy(
+        &output.stdout,
+    )))
+}
+
+/// `true` when `root` is inside a git work tree.
+pub fn in_git_work_tree(root: &Path) -> bool {
+    std::process::Command::new("git")
+        .args(["rev-parse", "--is-inside-work-tree"])
+        .current_dir(root)
+        .output()
+        .is_ok_and(|o| o.status.success())
+}
+
+/// Blame every file with chunks that lack a current summary and store the
+/// top authors of each chunk. Returns the number of chunks summarized.
+///
+/// A no-op outside a git work tree or with [`blame_enabled`] off. Files git
+/// can't blame (untracked, deleted since indexing) and chunks with
+/// uncommitted lines are skipped and retried by the next index.
+pub fn refresh_chunk_authors(store: &Store<ReadWrite>, root: &Path) -> Result<usize, StoreError> {
+    let _span = tracing::info_span!("refresh_chunk_authors").entered();
+    if !blame_enabled() {
+        tracing::debug!("Blame summaries disabled");
+        return Ok(0);
+    }
+    if !in_git_work_tree(root) {
+        tracing::debug!("Not a git work tree, skipping blame summaries");
+        return Ok(0);
+    }
+    let targets = store.chunks_missing_authors()?;
+    if targets.is_empty() {
+        return Ok(0);
+    }
+    // Targets come ordered by origin: one group, and one blame, per file.
+    let by_origin: Vec<&[BlameTarget]> = targets.chunk_by(|a, b| a.origin == b.origin).collect();
+
+    let rows: Vec<(String, String, Vec<AuthorShare>)> = by_origin
+        .par_iter()
+        .flat_map_iter(|group| {
+            let lines = blame_file(root, &group[0].origin).unwrap_or_default();
+            group
+                .iter()
+                .filter_map(|t| {
+                    summarize_authors(&lines, t.line_start, t.line_end)
+                        .map(|authors| (t.id.clone(), t.content_hash.clone(), authors))
+                })
+                .collect::<Vec<_>>()
+        })
+        .collect();
+    let written = store.upsert_chunk_authors(&rows)?;
+    tracing::info!(
+        files = by_origin.len(),
+        chunks = written,
+        "Blame summaries refreshed"
+    );
+    Ok(written as usize)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const CODEOWNERS: &str = "\
+# Default owners
+*                       @acme/platform
+*.md                    @acme/docs  # docs team
+/src/payments/          @acme/payments @ada
+src/ui/*                @acme/frontend
+/src/payments/gen.rs
+";
+
+    #[test]
+    fn last_matching_rule_wins() {
+        let owners = CodeOwners::parse(CODEOWNERS);
+        assert_eq!(owners.owners_of("src/lib.rs"), ["@acme/platform"]);
+        assert_eq!(owners.owners_of("guide/intro.md"), ["@acme/docs"]);
+        assert_eq!(
+            owners.owners_of("src/payments/retry/stream.rs"),
+            ["@acme/payments", "@ada"]
+        );
+        assert_eq!(owners.owners_of("src/ui/button.tsx"), ["@acme/frontend"]);
+        // `src/ui/*` doesn't reach into subdirectories; the default applies.
+        assert_eq!(
+            owners.owners_of("src/ui/forms/input.tsx"),
+            ["@acme/platform"]
+        );
+        // An owner-less rule unassigns.
+        assert!(owners.owners_of("src/payments/gen.rs").is_empty());
+    }
+
+    #[test]
+    fn unanchored_pattern_matches_at_any_depth() {
+        let owners = CodeOwners::parse("migrations/ @acme/db\nMakefile @acme/build\n");
+        assert_eq!(owners.owners_of("migrations/001.sql"), ["@acme/db"]);
+        assert_eq!(owners.owners_of("svc/api/migrations/002.sql"), ["@acme/db"]);
+        assert_eq!(owners.owners_of("tools/Makefile"), ["@acme/build"]);
+        assert!(owners.owners_of("src/migrations.rs").is_empty());
+    }
+
+    #[test]
+    fn origins_owned_by_accepts_bare_team_names() {
+        let owners = CodeOwners::parse(CODEOWNERS);
+        let origins = ["src/payments/retry.rs", "src/lib.rs", "README.md"];
+        let owned = owners.origins_owned_by("payments", origins);
+        assert_eq!(owned, HashSet::from(["src/payments/retry.rs".to_string()]));
+        let owned = owners.origins_owned_by("@ACME/Platform", origins);
+        assert_eq!(owned, HashSet::from(["src/lib.rs".to_string()]));
+    }
+
+    #[test]
+    fn owner_matching_rules() {
+        assert!(owner_matches("payments", "@acme/payments"));
+        assert!(owner_matches("@acme/payments", "@acme/payments"));
+        assert!(owner_matches("ada", "@ada"));
+        assert!(owner_matches("ada@acme.example", "ada@acme.example"));
+        assert!(!owner_matches("pay", "@acme/payments"));
+        assert!(!owner_matches("", "@acme/payments"));
+    }
+
+    const PORCELAIN: &str = "\
+1111111111111111111111111111111111111111 1 1 2
+author Ada Lovelace
+author-mail <ada@acme.example>
+author-time 1700000000
+summary retry
+filename src/pay.rs
+\tfn retry() {
+1111111111111111111111111111111111111111 2 2
+author Ada Lovelace
+author-mail <ada@acme.example>
+author-time 1700000000
+summary retry
+filename src/pay.rs
+\t    backoff();
+2222222222222222222222222222222222222222 3 3 1
+author Grace Hopper
+author-mail <grace@acme.example>
+author-time 1700000500
+summary fix
+filename src/pay.rs
+\t}
+0000000000000000000000000000000000000000 4 4 1
+author Not Committed Yet
+author-mail <not.committed.yet>
+author-time 1700001000
+summary Version of src/pay.rs from src/pay.rs
+filename src/pay.rs
+\t// wip
+";
+
+    #[test]
+    fn porcelain_summary_ranks_authors_by_lines() {
+        let lines = parse_line_porcelain(PORCELAIN);
+        assert_eq!(lines.len(), 4);
+        assert!(lines[3].is_none());
+        assert_eq!(lines[2].as_ref().unwrap().time, 1700000500);
+
+        let authors = summarize_authors(&lines, 1, 3).unwrap();
+        let got: Vec<(&str, u32, f32)> = authors
+            .iter()
+            .map(|a| (a.name.as_str(), a.lines, a.share))
+            .collect();
+        assert_eq!(
+            got,
+            vec![("Ada Lovelace", 2, 0.67), ("Grace Hopper", 1, 0.33)]
+        );
+        assert_eq!(authors[0].email, "ada@acme.example");
+    }
+
+    #[test]
+    fn uncommitted_or_out_of_range_lines_defer_the_chunk() {
+        let lines = parse_line_porcelain(PORCELAIN);
+        assert!(summarize_authors(&lines, 3, 4).is_none());
+        assert!(summarize_authors(&lines, 2, 9).is_none());
+    }
+
+    #[test]
+    fn author_share_matches_name_email_and_local_part() {
+        let a = AuthorShare {
+            name: "Ada Lovelace".to_string(),
+            email: "ada@acme.example".to_string(),
+            lines: 1,
+            share: 1.0,
+        };
+        assert!(a.matches("ada lovelace"));
+        assert!(a.matches("ADA@acme.example"));
+        assert!(a.matches("@ada"));
+        assert!(!a.matches("lovelace"));
+    }
+}
@@ -1441,7 +1441,7 @@ impl Parser {
 
         let ext_raw = path.extension().and_then(|e| e.to_str()).unwrap_or("");
         let ext = ext_raw.to_ascii_lowercase();
-        let language = Language::from_extension(&ext)
+        let language = Language::from_path(path)
             .ok_or_else(|| ParserError::UnsupportedFileType(ext.to_string()))?;
 
         // Grammar-less languages use custom reference extraction:
@@ -64,7 +64,27 @@ use super::Parser;
 /// bare-list L5X/L5K file re-parsed under v14 produces call + type edges it did
 /// not under v13, so a refresh is required even when the file's bytes are
 /// unchanged.
-pub const PARSER_VERSION: u32 = 14;
+/// 15: Leading doc comments are found past attributes and decorators
+/// (`#[derive]`, `@decorator`) and from wrapper nodes (`export`,
+/// `decorated_definition`, `template<>`), per language via
+/// `doc_skip_nodes` / `doc_wrapper_nodes`. A byte-identical file re-parsed
+/// under v15 can carry a `doc` it did not under v14, and the NL description
+/// embedded from it changes, so a refresh is required even when the file's
+/// bytes are unchanged.
+/// 16: Secrets are masked in the source before extraction (`crate::secrets`).
+/// A byte-identical file holding an AWS key or token re-parsed under v16 stores
+/// `[REDACTED:<kind>]` where v15 stored the credential, so a refresh is
+/// required to scrub secrets already in the index.
+/// 17: `ffi_boundary` call edges (`parser::ffi`) — renamed Rust FFI symbols,
+/// cgo `C.` references, protobuf codegen names. A byte-identical Rust, Go, or
+/// `.proto` file re-parsed under v17 produces edges it did not under v16, so
+/// a refresh is required even when the file's bytes are unchanged.
+/// 18: Deprecation attributes and decorators (`#[deprecated]`,
+/// `@deprecated`) skipped on the way to the doc comment are kept in `doc`
+/// (`parser::deprecation`). A byte-identical file with a deprecated item
+/// re-parsed under v18 carries a `doc` it did not under v17, so a refresh is
+/// required even when the file's bytes are unchanged.
+pub const PARSER_VERSION: u32 = 18;
 
 /// Build the canonical chunk id from its identifying coordinates.
 ///
@@ -381,6 +401,9 @@ impl Parser {
         // the walk. Fall back to a comment-only preceding-lines scan in that
         // case so the embedding gets a richer NL signal.
         let doc = extract_doc_comment(node, source, language).or_else(|| {
+            if !doc_comments_attached() {
+                return None;
+            }
             extract_doc_fallback_for_short_chunk(node, source, line_start, line_end, language, path)
         });
 
@@ -541,18 +564,63 @@ pub(crate) fn extract_signature(content: &str, language: Language) -> String {
 
 /// Extracts documentation comments associated with a syntax node.
 /// Searches backwards through sibling nodes to find documentation comments
-/// matching the language's doc node types. Skips over non-doc comments AND
-/// whitespace-only siblings (some grammars surface blank-line gaps as explicit
-/// nodes that would otherwise stop the walk before the comment block). Stops
-/// at the first sibling whose text contains substantive non-whitespace,
-/// non-comment content. For Python, also checks for a docstring as the first
-/// statement in the node's body if no preceding comments are found.
+/// matching the language's doc node types, then — if the definition sits in
+/// one of the language's `doc_wrapper_nodes` (an `export` statement, a
+/// decorator list, a template header) — repeats the search from the wrapper,
+/// since that is where the comment lives. For Python, also checks for a
+/// docstring as the first statement in the node's body if no preceding
+/// comments are found.
+///
+/// Leading comments are skipped entirely when attachment is switched off
+/// (`[index] attach_doc_comments = false`); the docstring is part of the
+/// chunk's own body and is kept either way.
 fn extract_doc_comment(
     node: tree_sitter::Node,
     source: &str,
     language: Language,
 ) -> Option<String> {
-    let doc_nodes = language.def().doc_nodes;
+    if doc_comments_attached() {
+        let def = language.def();
+        let mut anchor = Some(node);
+        while let Some(n) = anchor {
+            if let Some(doc) = leading_doc_comments(n, source, def) {
+                return Some(doc);
+            }
+            anchor = n
+                .parent()
+                .filter(|p| def.doc_wrapper_nodes.contains(&p.kind()));
+        }
+    }
+
+    // For Python, also check for docstring as first statement in body
+    if language == Language::Python {
+        if let Some(body) = node.child_by_field_name("body") {
+            if let Some(first) = body.named_child(0) {
+                if first.kind() == "expression_statement" {
+                    if let Some(string) = first.named_child(0) {
+                        if string.kind() == "string" {
+                            return Some(source[string.byte_range()].to_string());
+                        }
+                    }
+                }
+            }
+        }
+    }
+    None
+}
+
+/// Collect the doc comments directly above `node`. Walks back over siblings,
+/// skipping non-doc comments, the language's `doc_skip_nodes` (attributes,
+/// decorators), AND whitespace-only siblings (some grammars surface
+/// blank-line gaps as explicit nodes that would otherwise stop the walk
+/// before the comment block). Stops at the first sibling whose text contains
+/// substantive non-whitespace, non-comment content.
+fn leading_doc_comments(
+    node: tree_sitter::Node,
+    source: &str,
+    def: &crate::language::LanguageDef,
+) -> Option<String> {
+    let doc_nodes = def.doc_nodes;
 
     // Walk backwards through siblings looking for comments
     let mut comments = Vec::new();
@@ -566,6 +634,15 @@ fn extract_doc_comment(
             let text = &source[sibling.byte_range()];
             comments.push(text.to_string());
             current = sibling.prev_sibling();
+        } else if def.doc_skip_nodes.contains(&kind) {
+            // Keep looking past attributes/decorators. A deprecation marker
+            // (`#[deprecated]`, `@deprecated`) is kept as part of the doc so
+            // `chunks.deprecated` can see it; the rest are dropped.
+            let text = &source[sibling.byte_range()];
+            if text.lines().any(super::deprecation::is_marker_line) {
+                comments.push(text.to_string());
+            }
+            current = sibling.prev_sibling();
         } else if kind.contains("comment") {
             // Keep looking past non-doc comments
             current = sibling.prev_sibling();
@@ -595,27 +672,34 @@ fn extract_doc_comment(
     }
 
     if comments.is_empty() {
-        // For Python, also check for docstring as first statement in body
-        if language == Language::Python {
-            if let Some(body) = node.child_by_field_name("body") {
-                if let Some(first) = body.named_child(0) {
-                    if first.kind() == "expression_statement" {
-                        if let Some(string) = first.named_child(0) {
-                            if string.kind() == "string" {
-                                return Some(source[string.byte_range()].to_string());
-                            }
-                        }
-                    }
-                }
-            }
-        }
         return None;
     }
-
     comments.reverse();
     Some(comments.join("\n"))
 }
 
+/// `[index] attach_doc_comments` from `.cqs.toml`, pushed in once at startup
+/// by [`set_doc_attachment_from_config`]. Unset means attach.
+static ATTACH_DOC_COMMENTS: std::sync::OnceLock<bool> = std::sync::OnceLock::new();
+
+/// Push `[index] attach_doc_comments` into the parser. Must be called before
+/// the first parse; later calls are no-ops (OnceLock).
+pub fn set_doc_attachment_from_config(attach: bool) {
+    let _ = ATTACH_DOC_COMMENTS.set(attach);
+}
+
+/// Whether leading comments are attached to the following definition's
+/// chunk as its `doc`. Resolution: `CQS_ATTACH_DOC_COMMENTS` env (`0` /
+/// `false` / `no` detach, anything else attaches) > `[index]
+/// attach_doc_comments` > attach.
+fn doc_comments_attached() -> bool {
+    match std::env::var("CQS_ATTACH_DOC_COMMENTS").as_deref() {
+        Ok("0") | Ok("false") | Ok("no") => false,
+        Ok(_) => true,
+        Err(_) => ATTACH_DOC_COMMENTS.get().copied().unwrap_or(true),
+    }
+}
+
 /// Maximum line count for a chunk to be considered "short" and eligible for
 /// the leading-comment fallback. The check is `line_end - line_start <
 /// SHORT_CHUNK_LINE_THRESHOLD`, so chunks spanning **5 or fewer lines**
@@ -717,6 +801,148 @@ fn line_looks_comment_like(line: &str, lang: Language) -> bool {
     false
 }
 
+/// Block-comment delimiters [`split_code_comments`] follows across lines,
+/// keyed by the opener in a language's `line_comment_prefixes`.
+const BLOCK_COMMENT_DELIMITERS: &[(&str, &str)] = &[
+    ("/*", "*/"),
+    ("<!--", "-->"),
+    ("(*", "*)"),
+    ("<#", "#>"),
+    ("<%--", "--%>"),
+    ("@*", "*@"),
+];
+
+/// One non-blank line of chunk content, as [`split_lines`] divides it.
+pub(crate) struct SplitLine<'a> {
+    /// 0-based line index within the content.
+    pub index: usize,
+    /// Code text, trimmed; `None` for a comment-only line.
+    pub code: Option<&'a str>,
+    /// Comment text, trimmed; `None` for a code-only line.
+    pub comment: Option<&'a str>,
+}
+
+impl<'a> SplitLine<'a> {
+    fn comment_only(index: usize, text: &'a str) -> Self {
+        Self {
+            index,
+            code: None,
+            comment: Some(text.trim()),
+        }
+    }
+}
+
+/// Divide `content` into code and comment text line by line.
+///
+/// Line-based, like [`line_looks_comment_like`]: full-line comments and
+/// multi-line block comments go to the comment side whole, and a trailing
+/// `//`, `#`, or `--` comment after code (outside a double-quoted string) is
+/// cut off its line. Blank lines are dropped. Text the heuristic can't place
+/// stays with the code, so no token is ever lost.
+pub(crate) fn split_lines(content: &str, lang: Language) -> Vec<SplitLine<'_>> {
+    let prefixes = lang.def().line_comment_prefixes;
+    let mut out = Vec::new();
+    let mut block_end: Option<&str> = None;
+    for (index, line) in content.lines().enumerate() {
+        let t = line.trim_start();
+        if t.is_empty() {
+            continue;
+        }
+        if let Some(end) = block_end {
+            out.push(SplitLine::comment_only(index, t));
+            if t.contains(end) {
+                block_end = None;
+            }
+            continue;
+        }
+        if let Some((open, close)) = BLOCK_COMMENT_DELIMITERS
+            .iter()
+            .find(|(open, _)| prefixes.contains(open) && t.starts_with(open))
+        {
+            out.push(SplitLine::comment_only(index, t));
+            if !t[open.len()..].contains(close) {
+                block_end = Some(close);
+            }
+            continue;
+        }
+        if line_looks_comment_like(line, lang) {
+            out.push(SplitLine::comment_only(index, t));
+            continue;
+        }
+        out.push(match trailing_comment_start(t, prefixes) {
+            Some(at) => SplitLine {
+                index,
+                code: Some(t[..at].trim()),
+                comment: Some(t[at..].trim()),
+            },
+            None => SplitLine {
+                index,
+                code: Some(t.trim()),
+                comment: None,
+            },
+        });
+    }
+    out
+}
+
+/// Split chunk `content` into its code text and its comment text, for the
+/// separate `content` and `comments` FTS columns ([`split_lines`], joined).
+pub(crate) fn split_code_comments(content: &str, lang: Language) -> (String, String) {
+    let mut code = String::new();
+    let mut comments = String::new();
+    let push = |buf: &mut String, text: &str| {
+        if !buf.is_empty() {
+            buf.push('\n');
+        }
+        buf.push_str(text);
+    };
+    for line in split_lines(content, lang) {
+        if let Some(text) = line.code {
+            push(&mut code, text);
+        }
+        if let Some(text) = line.comment {
+            push(&mut comments, text);
+        }
+    }
+    (code, comments)
+}
+
+/// Byte offset of a trailing line comment in `line`: a `//`, `#`, or `--`
+/// opener from `prefixes` that follows whitespace and sits outside a
+/// double-quoted string. `#` and `--` also need a space after them, so
+/// `x--` and `#[attr]` never match.
+fn trailing_comment_start(line: &str, prefixes: &[&str]) -> Option<usize> {
+    let markers: Vec<&str> = prefixes
+        .iter()
+        .copied()
+        .filter(|p| matches!(*p, "//" | "#" | "--"))
+        .collect();
+    if markers.is_empty() {
+        return None;
+    }
+    let bytes = line.as_bytes();
+    let mut in_string = false;
+    let mut i = 0;
+    while i < bytes.len() {
+        match bytes[i] {
+            b'\\' if in_string => i += 1,
+            b'"' => in_string = !in_string,
+            _ if !in_string && i > 0 && bytes[i - 1].is_ascii_whitespace() => {
+                let rest = &line[i..];
+                if let Some(marker) = markers.iter().find(|m| rest.starts_with(**m)) {
+                    let after = &rest[marker.len()..];
+                    if *marker == "//" || after.is_empty() || after.starts_with([' ', '\t']) {
+                        return Some(i);
+                    }
+                }
+            }
+            _ => {}
+        }
+        i += 1;
+    }
+    None
+}
+
 /// Fallback `doc` enrichment for short chunks (chunks spanning 5 or fewer
 /// source lines, gated by [`SHORT_CHUNK_LINE_THRESHOLD`]) that have no
 /// tree-sitter-attached doc comment. Walks back up to
@@ -2463,6 +2689,33 @@ type WithDoc = u8;
             );
         }
 
+        // ─── split_code_comments ──────────────────────────────────────────
+
+        #[test]
+        fn split_code_comments_separates_rust_comments_from_code() {
+            let content = "fn retry(n: u32) {\n    // Back off so the server can recover.\n    let url = \"http://x\"; // not a URL comment\n    /* block\n       spans lines */\n    sleep(n);\n}\n";
+            let (code, comments) = split_code_comments(content, Language::Rust);
+            assert_eq!(
+                code,
+                "fn retry(n: u32) {\nlet url = \"http://x\";\nsleep(n);\n}"
+            );
+            assert_eq!(
+                comments,
+                "// Back off so the server can recover.\n// not a URL comment\n/* block\nspans lines */"
+            );
+        }
+
+        #[test]
+        fn split_code_comments_keeps_attributes_and_decrements_as_code() {
+            let (code, comments) = split_code_comments("#[inline]\nx -= 1; y--;\n", Language::Rust);
+            assert_eq!(code, "#[inline]\nx -= 1; y--;");
+            assert!(comments.is_empty());
+            let (code, comments) =
+                split_code_comments("total += n  # keep the running sum\n", Language::Python);
+            assert_eq!(code, "total += n");
+            assert_eq!(comments, "# keep the running sum");
+        }
+
         // ─── line_looks_comment_like (attribute / preprocessor) ───────────
 
         #[test]
@@ -2816,6 +3069,112 @@ CREATE TABLE five_line (
         }
     }
 
+    mod doc_attachment_tests {
+        use super::*;
+
+        /// Parse `content` and return the doc of the chunk named `name`.
+        /// Bodies in these fixtures run past `SHORT_CHUNK_LINE_THRESHOLD` so
+        /// only the sibling walk (not the short-chunk fallback) can attach.
+        fn doc_of(content: &str, ext: &str, name: &str) -> Option<String> {
+            let file = write_temp_file(content, ext);
+            let parser = Parser::new().unwrap();
+            let chunks = parser.parse_file(file.path()).unwrap();
+            chunks
+                .into_iter()
+                .find(|c| c.name == name)
+                .unwrap_or_else(|| panic!("no chunk named {name}"))
+                .doc
+        }
+
+        #[test]
+        fn rust_doc_found_past_attributes() {
+            let content = "\
+/// Connection settings.
+#[derive(Debug, Clone)]
+#[non_exhaustive]
+pub struct Settings {
+    host: String,
+    port: u16,
+    timeout_ms: u64,
+    retries: u32,
+    verbose: bool,
+}
+";
+            let doc = doc_of(content, "rs", "Settings");
+            assert_eq!(doc.as_deref(), Some("/// Connection settings."));
+        }
+
+        #[test]
+        fn rust_deprecated_attribute_kept_in_doc() {
+            let content = "\
+/// Old loader.
+#[deprecated(note = \"use load_v2\")]
+#[inline]
+pub fn load() -> u32 {
+    let a = 1;
+    let b = 2;
+    let c = 3;
+    a + b + c
+}
+";
+            let doc = doc_of(content, "rs", "load");
+            assert_eq!(
+                doc.as_deref(),
+                Some("/// Old loader.\n#[deprecated(note = \"use load_v2\")]")
+            );
+        }
+
+        #[test]
+        fn python_comment_found_above_decorator() {
+            let content = "\
+# Cache lookups for the session.
+@functools.lru_cache(maxsize=None)
+def lookup(key):
+    a = 1
+    b = 2
+    c = 3
+    d = 4
+    return key
+";
+            let doc = doc_of(content, "py", "lookup");
+            assert_eq!(doc.as_deref(), Some("# Cache lookups for the session."));
+        }
+
+        #[test]
+        fn typescript_jsdoc_found_above_export() {
+            let content = "\
+/** Resolve a route to its handler. */
+export function resolve(path: string): string {
+    const a = 1;
+    const b = 2;
+    const c = 3;
+    const d = 4;
+    return path;
+}
+";
+            let doc = doc_of(content, "ts", "resolve");
+            assert_eq!(
+                doc.as_deref(),
+                Some("/** Resolve a route to its handler. */")
+            );
+        }
+
+        #[test]
+        fn code_between_comment_and_symbol_blocks_attachment() {
+            let content = "\
+/// Belongs to FIRST.
+const FIRST: u32 = 1;
+pub fn second() {
+    let a = 1;
+    let b = 2;
+    let c = 3;
+    let d = 4;
+}
+";
+            assert_eq!(doc_of(content, "rs", "second"), None);
+        }
+    }
+
     /// Stack-safety: the tree walkers must be iterative so a pathologically
     /// deep parse tree (minified code, thousands of nested parens) doesn't
     /// overflow a (2 MiB) rayon worker stack and SIGSEGV the indexer.
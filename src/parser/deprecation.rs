@@ -0,0 +1,177 @@
+//! Deprecation markers (`--hide-deprecated`, the `deprecated` result flag).
+//!
+//! Each language has its own way of saying "don't use this any more", and
+//! most of them sit where the parser already looks: in the doc comment or in
+//! the declaration head above the name.
+//!
+//! ```text
+//! #[deprecated(note = "use load_v2")]          Rust (kept in the chunk's doc)
+//! @Deprecated / @Deprecated("...")             Java, Kotlin
+//! [Obsolete("use Load2")]                      C#
+//! [[deprecated]] / __attribute__((deprecated)) C, C++
+//! @available(*, deprecated, ...)               Swift
+//! @deprecated                                  JSDoc, PHPDoc, Python 3.13 decorator
+//! // Deprecated: use LoadV2.                   Go, and any doc comment
+//! .. deprecated:: 2.1                          Sphinx docstrings
+//! warnings.warn(..., DeprecationWarning)       Python function bodies
+//! ```
+//!
+//! [`is_deprecated`] is evaluated when a chunk is written and stored in
+//! `chunks.deprecated` (schema v46). Only the doc and the declaration head
+//! are scanned, so a module that merely contains a deprecated item — or code
+//! that mentions `@deprecated` in a string — is not itself flagged.
+
+use super::{ChunkType, Language};
+
+/// Most lines of a chunk's content scanned for an attribute or annotation
+/// before giving up on finding the declaration line.
+const MAX_HEAD_LINES: usize = 24;
+
+/// Whether the chunk carries a deprecation marker in its doc comment, its
+/// declaration head (the lines up to the one naming it), or — for Python
+/// callables — a `DeprecationWarning` raised from its body.
+pub fn is_deprecated(
+    chunk_type: ChunkType,
+    name: &str,
+    content: &str,
+    doc: Option<&str>,
+    lang: Language,
+) -> bool {
+    if doc.is_some_and(|d| d.lines().any(is_marker_line)) {
+        return true;
+    }
+    for line in content.lines().take(MAX_HEAD_LINES) {
+        if is_marker_line(line) {
+            return true;
+        }
+        if !name.is_empty() && line.contains(name) {
+            break;
+        }
+    }
+    lang == Language::Python
+        && chunk_type.is_callable()
+        && content.contains("DeprecationWarning")
+        && content.contains("warn(")
+}
+
+/// Whether one source or comment line is a deprecation marker. Comment
+/// leaders (`///`, `//`, `#`, `*`, `/**`, `--`) are stripped first, so the
+/// same check serves doc text and attribute lines.
+pub fn is_marker_line(line: &str) -> bool {
+    let text = strip_comment_leader(line.trim());
+    if let Some(rest) = text.strip_prefix('@') {
+        // `@Deprecated`, `@deprecated`, `@warnings.deprecated("...")`,
+        // `@available(*, deprecated)`.
+        let ident: &str = rest
+            .split(|c: char| !(c.is_alphanumeric() || c == '_' || c == '.'))
+            .next()
+            .unwrap_or("");
+        let last = ident.rsplit('.').next().unwrap_or(ident);
+        return last.eq_ignore_ascii_case("deprecated")
+            || (ident == "available" && rest.contains("deprecated"));
+    }
+    let lower = text.to_ascii_lowercase();
+    lower.starts_with("#[deprecated")
+        || lower.starts_with("#[\\deprecated")
+        || lower.starts_with("[obsolete")
+        || lower.starts_with("[system.obsolete")
+        || lower.starts_with("[[deprecated")
+        || lower.starts_with("__attribute__((deprecated")
+        || lower.starts_with("deprecated:")
+        || lower.starts_with(".. deprecated::")
+}
+
+/// `text` without a leading line- or block-comment marker. A `#` is only a
+/// comment leader when it doesn't open an attribute (`#[...]`).
+fn strip_comment_leader(text: &str) -> &str {
+    for leader in ["/**", "/*", "///", "//!", "//", "--", "*"] {
+        if let Some(rest) = text.strip_prefix(leader) {
+            return rest.trim_start();
+        }
+    }
+    match text.strip_prefix('#') {
+        Some(rest) if !rest.starts_with('[') => rest.trim_start_matches('#').trim_start(),
+        _ => text,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn deprecated(lang: Language, name: &str, content: &str, doc: Option<&str>) -> bool {
+        is_deprecated(ChunkType::Function, name, content, doc, lang)
+    }
+
+    #[test]
+    fn markers_in_docs_are_found() {
+        for doc in [
+            "#[deprecated(since = \"2.0\", note = \"use load_v2\")]",
+            "// Deprecated: use LoadV2 instead.",
+            "/**\n * Sends the request.\n * @deprecated use sendV2\n */",
+            "\"\"\"Load it.\n\n.. deprecated:: 2.1\n   Use load_v2.\n\"\"\"",
+        ] {
+            assert!(
+                deprecated(Language::Rust, "load", "fn load() {}", Some(doc)),
+                "{doc}"
+            );
+        }
+        assert!(!deprecated(
+            Language::Go,
+            "Load",
+            "func Load() {}",
+            Some("// Load reads the config. Not deprecated.")
+        ));
+    }
+
+    #[test]
+    fn markers_in_declaration_head_are_found() {
+        assert!(deprecated(
+            Language::Java,
+            "send",
+            "@Deprecated\npublic void send(Request r) {\n}",
+            None
+        ));
+        assert!(deprecated(
+            Language::CSharp,
+            "Load",
+            "[Obsolete(\"use Load2\")]\npublic void Load() {}",
+            None
+        ));
+        assert!(deprecated(
+            Language::Swift,
+            "load",
+            "@available(*, deprecated, renamed: \"load2\")\nfunc load() {}",
+            None
+        ));
+        assert!(deprecated(
+            Language::Python,
+            "load",
+            "@warnings.deprecated(\"use load2\")\ndef load():\n    pass",
+            None
+        ));
+    }
+
+    #[test]
+    fn body_mentions_do_not_flag_the_container() {
+        let content = "impl Client {\n    #[deprecated]\n    fn old(&self) {}\n}";
+        assert!(!is_deprecated(
+            ChunkType::Impl,
+            "Client",
+            content,
+            None,
+            Language::Rust
+        ));
+        let js = "function check(tag) {\n  return tag === \"@deprecated\";\n}";
+        assert!(!deprecated(Language::JavaScript, "check", js, None));
+        // `@Deprecated` on a different annotation name is not a marker.
+        assert!(!is_marker_line("@DeprecatedApiScanner"));
+    }
+
+    #[test]
+    fn python_deprecation_warning_in_body() {
+        let body = "def load(path):\n    warnings.warn(\"use load_v2\", DeprecationWarning)\n    return _load(path)";
+        assert!(deprecated(Language::Python, "load", body, None));
+        assert!(!deprecated(Language::Rust, "load", body, None));
+    }
+}
@@ -0,0 +1,408 @@
+//! Cross-language boundary edges (`CallEdgeKind::FfiBoundary`).
+//!
+//! The call graph is keyed by name, so an FFI pair that shares a name — a
+//! `#[no_mangle] extern "C" fn cqs_open` and the C code calling `cqs_open`, a
+//! cgo `C.compress(...)` and the C `compress` — is already one node. Impact
+//! analysis stops at the boundary only where the two sides are spelled
+//! differently. This module emits one edge per such rename, attributed to the
+//! file that declares it, so it is replaced whenever that file is reindexed:
+//!
+//! - Rust `#[export_name = "sym"]` on `fn f`: `sym → f` (C callers of `sym`
+//!   reach `f`); `#[link_name = "sym"]` on a foreign `fn f`: `f → sym` (Rust
+//!   callers of `f` reach the C definition).
+//! - cgo: in a Go file that imports `"C"`, a `C.struct_x` / `C.union_x` /
+//!   `C.enum_x` mention links to the C type `x`, and a non-call `C.name`
+//!   reference (a constant, a callback passed by value, a typedef) links to
+//!   `name`. Calls through `C.` are already ordinary call edges.
+//! - Protobuf: an rpc links to the snake_case and lowerCamelCase methods
+//!   codegen emits for it, a service to its `Client` / `Server` /
+//!   `Servicer` / `Stub` / `Grpc` stubs, and a message or enum to its
+//!   UpperCamelCase struct when prost renames it (`HTTPRequest` →
+//!   `HttpRequest`).
+//!
+//! Edges whose two ends would carry the same name are never emitted: they
+//! would be self-loops in the name graph.
+
+use std::sync::LazyLock;
+
+use regex::Regex;
+
+use super::types::{CallEdgeKind, CallSite, Chunk, ChunkType, FunctionCalls, Language};
+
+/// `#[export_name = "sym"]` / `#[link_name = "sym"]`, including the
+/// edition-2024 `#[unsafe(export_name = "sym")]` spelling.
+static LINK_ATTR_RE: LazyLock<Regex> = LazyLock::new(|| {
+    Regex::new(r#"#\[\s*(?:unsafe\s*\(\s*)?(export_name|link_name)\s*=\s*"([^"]+)""#)
+        .expect("valid regex")
+});
+
+/// The first `fn name` after an attribute.
+static FN_NAME_RE: LazyLock<Regex> =
+    LazyLock::new(|| Regex::new(r"\bfn\s+([A-Za-z_][A-Za-z0-9_]*)").expect("valid regex"));
+
+/// A cgo `import "C"` on its own line.
+static CGO_IMPORT_RE: LazyLock<Regex> =
+    LazyLock::new(|| Regex::new(r#"(?m)^\s*import\s+"C"\s*$"#).expect("valid regex"));
+
+/// A `C.name` reference in Go, with whether it is immediately called.
+static CGO_REF_RE: LazyLock<Regex> =
+    LazyLock::new(|| Regex::new(r"\bC\.([A-Za-z_][A-Za-z0-9_]*)(\s*\()?").expect("valid regex"));
+
+/// cgo pseudo-functions and the numeric types cgo maps onto Go, which name
+/// nothing in the C sources.
+const CGO_BUILTINS: &[&str] = &[
+    "CString",
+    "CBytes",
+    "GoString",
+    "GoStringN",
+    "GoBytes",
+    "char",
+    "schar",
+    "uchar",
+    "short",
+    "ushort",
+    "int",
+    "uint",
+    "long",
+    "ulong",
+    "longlong",
+    "ulonglong",
+    "float",
+    "double",
+    "complexfloat",
+    "complexdouble",
+    "size_t",
+];
+
+/// Stub suffixes protobuf codegen derives from a service name across the
+/// common targets: Go / Rust tonic (`Client`, `Server`), Python
+/// (`Servicer`, `Stub`), Java (`Grpc`).
+const SERVICE_STUB_SUFFIXES: &[&str] = &["Client", "Server", "Servicer", "Stub", "Grpc"];
+
+/// Boundary edges declared in one file. `chunks` are the file's parsed
+/// chunks; `source` is its full text (Rust link attributes sit outside the
+/// chunk they annotate).
+pub(crate) fn boundary_edges(
+    source: &str,
+    language: Language,
+    chunks: &[Chunk],
+) -> Vec<FunctionCalls> {
+    match language {
+        Language::Rust => rust_link_edges(source),
+        Language::Go if CGO_IMPORT_RE.is_match(source) => cgo_edges(chunks),
+        Language::Protobuf => protobuf_edges(chunks),
+        _ => Vec::new(),
+    }
+}
+
+fn edge(caller: &str, line: u32, callee: String) -> FunctionCalls {
+    FunctionCalls {
+        name: caller.to_string(),
+        line_start: line,
+        calls: vec![CallSite {
+            callee_name: callee,
+            line_number: line,
+            kind: CallEdgeKind::FfiBoundary,
+        }],
+    }
+}
+
+/// 1-indexed line of byte offset `at` in `text`.
+fn line_of(text: &str, at: usize) -> u32 {
+    text[..at].bytes().filter(|&b| b == b'\n').count() as u32 + 1
+}
+
+fn rust_link_edges(source: &str) -> Vec<FunctionCalls> {
+    let mut out = Vec::new();
+    for caps in LINK_ATTR_RE.captures_iter(source) {
+        let attr = caps.get(0).expect("whole match");
+        let symbol = &caps[2];
+        // The annotated item is the next `fn`, as long as nothing ends an
+        // item first (a `static` carrying the attribute, say).
+        let rest = &source[attr.end()..];
+        let Some(f) = FN_NAME_RE.captures(rest) else {
+            continue;
+        };
+        let fn_match = f.get(0).expect("whole match");
+        if rest[..fn_match.start()].contains([';', '{', '}']) {
+            continue;
+        }
+        let name = &f[1];
+        if name == symbol {
+            continue;
+        }
+        let line = line_of(source, attr.end() + fn_match.start());
+        out.push(match &caps[1] {
+            "export_name" => edge(symbol, line, name.to_string()),
+            _ => edge(name, line, symbol.to_string()),
+        });
+    }
+    out
+}
+
+fn cgo_edges(chunks: &[Chunk]) -> Vec<FunctionCalls> {
+    let mut out = Vec::new();
+    for chunk in chunks.iter().filter(|c| c.window_idx.is_none()) {
+        let mut calls: Vec<CallSite> = Vec::new();
+        for caps in CGO_REF_RE.captures_iter(&chunk.content) {
+            let ident = &caps[1];
+            let tagged = ["struct_", "union_", "enum_"]
+                .iter()
+                .find_map(|p| ident.strip_prefix(p));
+            let target = match tagged {
+                Some(t) => t,
+                // A call is already an ordinary call edge.
+                None if caps.get(2).is_some() => continue,
+                None if CGO_BUILTINS.contains(&ident) => continue,
+                None => ident,
+            };
+            if target.is_empty()
+                || target == chunk.name
+                || calls.iter().any(|c| c.callee_name == target)
+            {
+                continue;
+            }
+            let at = caps.get(0).expect("whole match").start();
+            calls.push(CallSite {
+                callee_name: target.to_string(),
+                line_number: chunk.line_start + line_of(&chunk.content, at) - 1,
+                kind: CallEdgeKind::FfiBoundary,
+            });
+        }
+        if !calls.is_empty() {
+            out.push(FunctionCalls {
+                name: chunk.name.clone(),
+                line_start: chunk.line_start,
+                calls,
+            });
+        }
+    }
+    out
+}
+
+fn protobuf_edges(chunks: &[Chunk]) -> Vec<FunctionCalls> {
+    let mut out = Vec::new();
+    for chunk in chunks {
+        let name = chunk.name.as_str();
+        let targets: Vec<String> = match chunk.chunk_type {
+            ChunkType::Method | ChunkType::Function => {
+                vec![snake_case(name), lower_camel_case(name)]
+            }
+            ChunkType::Service => SERVICE_STUB_SUFFIXES
+                .iter()
+                .map(|s| format!("{name}{s}"))
+                .collect(),
+            ChunkType::Struct | ChunkType::Enum => vec![upper_camel_case(name)],
+            _ => continue,
+        };
+        let mut seen = std::collections::HashSet::new();
+        let calls: Vec<CallSite> = targets
+            .into_iter()
+            .filter(|t| t != name && !t.is_empty() && seen.insert(t.clone()))
+            .map(|t| CallSite {
+                callee_name: t,
+                line_number: chunk.line_start,
+                kind: CallEdgeKind::FfiBoundary,
+            })
+            .collect();
+        if !calls.is_empty() {
+            out.push(FunctionCalls {
+                name: chunk.name.clone(),
+                line_start: chunk.line_start,
+                calls,
+            });
+        }
+    }
+    out
+}
+
+/// Split an identifier into words at underscores, lower→upper transitions,
+/// and the end of an acronym (`HTTPRequest` → `HTTP`, `Request`).
+fn words(ident: &str) -> Vec<String> {
+    let chars: Vec<char> = ident.chars().collect();
+    let mut words = Vec::new();
+    let mut cur = String::new();
+    for (i, &c) in chars.iter().enumerate() {
+        if c == '_' {
+            if !cur.is_empty() {
+                words.push(std::mem::take(&mut cur));
+            }
+            continue;
+        }
+        if c.is_uppercase() && !cur.is_empty() {
+            let prev = chars[i - 1];
+            let next_lower = chars.get(i + 1).is_some_and(|n| n.is_lowercase());
+            if prev.is_lowercase() || prev.is_ascii_digit() || (prev.is_uppercase() && next_lower) {
+                words.push(std::mem::take(&mut cur));
+            }
+        }
+        cur.push(c);
+    }
+    if !cur.is_empty() {
+        words.push(cur);
+    }
+    words
+}
+
+fn capitalize(word: &str) -> String {
+    let mut chars = word.chars();
+    match chars.next() {
+        Some(first) => first
+            .to_uppercase()
+            .chain(chars.flat_map(char::to_lowercase))
+            .collect(),
+        None => String::new(),
+    }
+}
+
+/// `GetHTTPStatus` → `get_http_status` (Rust tonic, Python methods).
+fn snake_case(ident: &str) -> String {
+    words(ident)
+        .iter()
+        .map(|w| w.to_lowercase())
+        .collect::<Vec<_>>()
+        .join("_")
+}
+
+/// `GetUser` → `getUser` (Java, Kotlin, JS stubs lower only the first
+/// character).
+fn lower_camel_case(ident: &str) -> String {
+    let mut chars = ident.chars();
+    match chars.next() {
+        Some(first) => first.to_lowercase().chain(chars).collect(),
+        None => String::new(),
+    }
+}
+
+/// `HTTPRequest` → `HttpRequest`, `user_profile` → `UserProfile` (prost).
+fn upper_camel_case(ident: &str) -> String {
+    words(ident).iter().map(|w| capitalize(w)).collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn pairs(edges: &[FunctionCalls]) -> Vec<(String, String)> {
+        edges
+            .iter()
+            .flat_map(|fc| {
+                fc.calls
+                    .iter()
+                    .map(|c| (fc.name.clone(), c.callee_name.clone()))
+            })
+            .collect()
+    }
+
+    fn chunk(lang: Language, chunk_type: ChunkType, name: &str, content: &str) -> Chunk {
+        Chunk {
+            id: format!("f:1:0:{name}"),
+            file: "f".into(),
+            language: lang,
+            chunk_type,
+            name: name.to_string(),
+            signature: String::new(),
+            content: content.to_string(),
+            doc: None,
+            line_start: 10,
+            line_end: 20,
+            byte_start: 0,
+            content_hash: String::new(),
+            canonical_hash: String::new(),
+            parent_id: None,
+            window_idx: None,
+            parent_type_name: None,
+            parser_version: 0,
+        }
+    }
+
+    #[test]
+    fn rust_link_attributes_bridge_renamed_symbols() {
+        let src = r#"
+#[unsafe(export_name = "cqs_open_v2")]
+pub extern "C" fn open_index(path: *const c_char) -> *mut Index { todo!() }
+
+#[no_mangle]
+pub extern "C" fn cqs_close(index: *mut Index) {}
+
+extern "C" {
+    #[link_name = "zstd_compress"]
+    fn compress(dst: *mut u8, src: *const u8) -> usize;
+    #[link_name = "errno_location"]
+    static ERRNO: i32;
+}
+"#;
+        let edges = boundary_edges(src, Language::Rust, &[]);
+        assert_eq!(
+            pairs(&edges),
+            vec![
+                ("cqs_open_v2".to_string(), "open_index".to_string()),
+                ("compress".to_string(), "zstd_compress".to_string()),
+            ]
+        );
+        assert_eq!(edges[0].line_start, 3);
+        assert!(edges
+            .iter()
+            .all(|fc| fc.calls[0].kind == CallEdgeKind::FfiBoundary));
+    }
+
+    #[test]
+    fn cgo_references_link_to_c_names() {
+        let src = "package zip\n\n// #include \"zip.h\"\nimport \"C\"\n";
+        let body = "func Open(p string) *C.struct_zip_archive {\n\
+                    \tcs := C.CString(p)\n\
+                    \tC.zip_set_callback(C.progress_cb)\n\
+                    \treturn C.zip_open(cs, C.ZIP_RDONLY, nil)\n}";
+        let chunks = [chunk(Language::Go, ChunkType::Function, "Open", body)];
+        let edges = boundary_edges(src, Language::Go, &chunks);
+        assert_eq!(
+            pairs(&edges),
+            vec![
+                ("Open".to_string(), "zip_archive".to_string()),
+                ("Open".to_string(), "progress_cb".to_string()),
+                ("Open".to_string(), "ZIP_RDONLY".to_string()),
+            ]
+        );
+        assert_eq!(edges[0].calls[1].line_number, 12);
+
+        // Without `import "C"` the same text is ordinary Go.
+        assert!(boundary_edges("package zip\n", Language::Go, &chunks).is_empty());
+    }
+
+    #[test]
+    fn protobuf_elements_link_to_generated_names() {
+        let chunks = [
+            chunk(Language::Protobuf, ChunkType::Service, "Users", ""),
+            chunk(Language::Protobuf, ChunkType::Method, "GetHTTPStatus", ""),
+            chunk(Language::Protobuf, ChunkType::Struct, "HTTPRequest", ""),
+            chunk(Language::Protobuf, ChunkType::Struct, "User", ""),
+        ];
+        let edges = boundary_edges("", Language::Protobuf, &chunks);
+        let got = pairs(&edges);
+        for want in [
+            ("Users", "UsersClient"),
+            ("Users", "UsersServicer"),
+            ("GetHTTPStatus", "get_http_status"),
+            ("GetHTTPStatus", "getHTTPStatus"),
+            ("HTTPRequest", "HttpRequest"),
+        ] {
+            assert!(
+                got.contains(&(want.0.to_string(), want.1.to_string())),
+                "missing {want:?} in {got:?}"
+            );
+        }
+        assert!(
+            !got.iter().any(|(caller, _)| caller == "User"),
+            "a message prost doesn't rename needs no edge"
+        );
+    }
+
+    #[test]
+    fn case_conversions() {
+        assert_eq!(snake_case("GetUser"), "get_user");
+        assert_eq!(snake_case("ListV2Items"), "list_v2_items");
+        assert_eq!(upper_camel_case("user_profile"), "UserProfile");
+        assert_eq!(upper_camel_case("HTTPRequest"), "HttpRequest");
+        assert_eq!(lower_camel_case("GetUser"), "getUser");
+    }
+}
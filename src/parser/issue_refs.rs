@@ -0,0 +1,273 @@
+//! Issue and PR references in comments (`search --issue`).
+//!
+//! Comments are where code gets linked to its tracker history: `// see
+//! JIRA-1234`, `# fixes #567`, a pasted GitHub URL. [`comment_issue_refs`]
+//! pulls those out of a chunk's comment text and doc comment, each in one
+//! canonical form:
+//!
+//! ```text
+//! see JIRA-1234                                  →  JIRA-1234
+//! fixes #567, GH-567                             →  #567
+//! acme/billing#42                                →  acme/billing#42
+//! https://github.com/Acme/Billing/pull/42        →  acme/billing#42
+//! ```
+//!
+//! The references are stored per chunk in `chunk_issue_refs` (schema v43),
+//! re-derived whenever the chunk's content changes. Code text is never
+//! scanned — `#123` in a string literal or `ABC-1` in an identifier is not a
+//! reference.
+
+use std::sync::LazyLock;
+
+use regex::Regex;
+use serde::{Deserialize, Serialize};
+
+use super::Language;
+
+/// Tracker keys, GitHub shorthand, and GitHub issue/PR URLs, leftmost first.
+/// The URL branch comes first so a URL isn't also read as `owner/repo#N`.
+static ISSUE_REF_RE: LazyLock<Regex> = LazyLock::new(|| {
+    Regex::new(
+        r"(?x)
+        (?:https?://)?(?:www\.)?github\.com/(?P<url_owner>[\w.-]+)/(?P<url_repo>[\w.-]+)/(?:issues|pull)/(?P<url_num>\d{1,7})\b
+        | \b(?P<owner>[A-Za-z0-9][\w.-]*)/(?P<repo>[\w.-]+)\#(?P<qual_num>\d{1,7})\b
+        | \#(?P<hash_num>\d{1,7})\b
+        | \b(?P<project>[A-Z][A-Z0-9]{1,9})-(?P<key_num>\d{1,7})\b
+        ",
+    )
+    .expect("valid regex")
+});
+
+/// A closing keyword directly before a reference: `fixes #1`, `Closes: X-2`.
+static CLOSING_KEYWORD_RE: LazyLock<Regex> = LazyLock::new(|| {
+    Regex::new(r"(?i)\b(?:fix(?:e[sd])?|close[sd]?|resolve[sd]?)\s*:?\s*$").expect("valid regex")
+});
+
+/// Prefixes that look like tracker keys but name encodings, algorithms, and
+/// standards (`UTF-8`, `SHA-256`, `ISO-8601`).
+const NOT_TRACKER_KEYS: &[&str] = &[
+    "AES", "CP", "CRC", "DES", "ECMA", "HTTP", "IEEE", "IPV", "ISO", "MD", "RSA", "SHA", "SSL",
+    "TLS", "UCS", "UTF", "WIN",
+];
+
+/// How a comment refers to an issue.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum IssueRelation {
+    /// Preceded by a closing keyword (`fixes`, `closes`, `resolves`).
+    Fixes,
+    /// Any other mention (`see #12`, a bare `JIRA-1234`).
+    Mentions,
+}
+
+impl IssueRelation {
+    /// Stored text form.
+    pub fn as_str(self) -> &'static str {
+        match self {
+            Self::Fixes => "fixes",
+            Self::Mentions => "mentions",
+        }
+    }
+
+    /// Parse the stored text form. Anything unrecognised reads as a mention.
+    pub fn from_stored(s: &str) -> Self {
+        if s == "fixes" {
+            Self::Fixes
+        } else {
+            Self::Mentions
+        }
+    }
+}
+
+/// One issue or PR reference, in canonical form.
+#[derive(Debug, Clone, PartialEq, Eq, Hash, Serialize, Deserialize)]
+pub struct IssueRef {
+    /// `PROJ-123`, `#123`, or `owner/repo#123`.
+    pub id: String,
+    pub relation: IssueRelation,
+}
+
+impl IssueRef {
+    /// Whether this reference answers `query`, itself canonical
+    /// ([`canonical_issue_id`]). A bare `#N` also matches any repo's `#N`.
+    pub fn matches(&self, query: &str) -> bool {
+        self.id == query || (query.starts_with('#') && self.id.ends_with(query))
+    }
+}
+
+/// Every issue reference in `text`, deduplicated in order of first
+/// appearance. A reference mentioned several times counts as a fix if any
+/// mention is.
+pub fn extract_issue_refs(text: &str) -> Vec<IssueRef> {
+    let mut refs: Vec<IssueRef> = Vec::new();
+    for caps in ISSUE_REF_RE.captures_iter(text) {
+        let whole = caps.get(0).expect("match 0 always present");
+        let id = if let Some(num) = caps.name("url_num") {
+            format!(
+                "{}/{}#{}",
+                caps["url_owner"].to_ascii_lowercase(),
+                caps["url_repo"].to_ascii_lowercase(),
+                num.as_str()
+            )
+        } else if let Some(num) = caps.name("qual_num") {
+            format!(
+                "{}/{}#{}",
+                caps["owner"].to_ascii_lowercase(),
+                caps["repo"].to_ascii_lowercase(),
+                num.as_str()
+            )
+        } else if let Some(num) = caps.name("hash_num") {
+            // `&#123;` is an HTML entity and `x#1` part of a word, not a
+            // reference. The regex crate has no lookbehind, so check here.
+            let before = text[..whole.start()].chars().next_back();
+            if before.is_some_and(|c| c.is_alphanumeric() || matches!(c, '&' | '#' | '_')) {
+                continue;
+            }
+            format!("#{}", num.as_str())
+        } else {
+            let project = &caps["project"];
+            if NOT_TRACKER_KEYS.contains(&project) {
+                continue;
+            }
+            if project == "GH" {
+                format!("#{}", &caps["key_num"])
+            } else {
+                format!("{project}-{}", &caps["key_num"])
+            }
+        };
+
+        let line_start = text[..whole.start()].rfind('\n').map_or(0, |i| i + 1);
+        let relation = if CLOSING_KEYWORD_RE.is_match(&text[line_start..whole.start()]) {
+            IssueRelation::Fixes
+        } else {
+            IssueRelation::Mentions
+        };
+        match refs.iter_mut().find(|r| r.id == id) {
+            Some(seen) => {
+                if relation == IssueRelation::Fixes {
+                    seen.relation = relation;
+                }
+            }
+            None => refs.push(IssueRef { id, relation }),
+        }
+    }
+    refs
+}
+
+/// The issue references in a chunk's comments and doc comment.
+pub fn comment_issue_refs(content: &str, doc: Option<&str>, lang: Language) -> Vec<IssueRef> {
+    let (_, mut comments) = super::chunk::split_code_comments(content, lang);
+    if let Some(doc) = doc {
+        comments.push('\n');
+        comments.push_str(doc);
+    }
+    extract_issue_refs(&comments)
+}
+
+/// Canonical form of a user-typed reference, as stored: `jira-1234` →
+/// `JIRA-1234`, `567` → `#567`, a GitHub URL → `owner/repo#N`. `None` when
+/// `query` isn't a single reference.
+pub fn canonical_issue_id(query: &str) -> Option<String> {
+    let query = query.trim();
+    if !query.is_empty() && query.bytes().all(|b| b.is_ascii_digit()) {
+        return Some(format!("#{query}"));
+    }
+    let single = |refs: Vec<IssueRef>| match refs.as_slice() {
+        [only] => Some(only.id.clone()),
+        _ => None,
+    };
+    single(extract_issue_refs(query))
+        .or_else(|| single(extract_issue_refs(&query.to_ascii_uppercase())))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn ids(text: &str) -> Vec<String> {
+        extract_issue_refs(text).into_iter().map(|r| r.id).collect()
+    }
+
+    #[test]
+    fn extracts_each_reference_form() {
+        assert_eq!(ids("see JIRA-1234 for context"), vec!["JIRA-1234"]);
+        assert_eq!(ids("fixes #567"), vec!["#567"]);
+        assert_eq!(ids("GH-567"), vec!["#567"]);
+        assert_eq!(ids("upstream: acme/billing#42"), vec!["acme/billing#42"]);
+        assert_eq!(
+            ids("https://github.com/Acme/Billing/pull/42"),
+            vec!["acme/billing#42"]
+        );
+        assert_eq!(
+            ids("github.com/acme/billing/issues/7#issuecomment-1"),
+            vec!["acme/billing#7"]
+        );
+    }
+
+    #[test]
+    fn skips_lookalikes() {
+        assert!(ids("decode UTF-8, hash with SHA-256, dates as ISO-8601").is_empty());
+        assert!(ids("&#123; and x#1 and ##2").is_empty());
+        assert!(ids("color #123abc").is_empty());
+        assert!(ids("lowercase abc-123").is_empty());
+    }
+
+    #[test]
+    fn closing_keyword_marks_fixes_and_wins_over_mentions() {
+        let refs = extract_issue_refs("see #12 and PAY-3\nCloses: #12\nresolved PAY-4");
+        assert_eq!(
+            refs,
+            vec![
+                IssueRef {
+                    id: "#12".into(),
+                    relation: IssueRelation::Fixes
+                },
+                IssueRef {
+                    id: "PAY-3".into(),
+                    relation: IssueRelation::Mentions
+                },
+                IssueRef {
+                    id: "PAY-4".into(),
+                    relation: IssueRelation::Fixes
+                },
+            ]
+        );
+    }
+
+    #[test]
+    fn comment_refs_ignore_code_text() {
+        let content = "// see PAY-9\nfn retry() {\n    let s = \"#404\"; // fixes #88\n}";
+        let refs = comment_issue_refs(content, Some("Tracked in OPS-2."), Language::Rust);
+        let ids: Vec<&str> = refs.iter().map(|r| r.id.as_str()).collect();
+        assert_eq!(ids, vec!["PAY-9", "#88", "OPS-2"]);
+        assert_eq!(refs[1].relation, IssueRelation::Fixes);
+    }
+
+    #[test]
+    fn canonical_issue_id_normalizes_queries() {
+        assert_eq!(
+            canonical_issue_id("jira-1234").as_deref(),
+            Some("JIRA-1234")
+        );
+        assert_eq!(canonical_issue_id("567").as_deref(), Some("#567"));
+        assert_eq!(canonical_issue_id("#567").as_deref(), Some("#567"));
+        assert_eq!(
+            canonical_issue_id("https://github.com/Acme/Billing/issues/9").as_deref(),
+            Some("acme/billing#9")
+        );
+        assert_eq!(canonical_issue_id("not a ref"), None);
+        assert_eq!(canonical_issue_id("#1 #2"), None);
+    }
+
+    #[test]
+    fn bare_number_matches_any_repo() {
+        let r = IssueRef {
+            id: "acme/billing#42".into(),
+            relation: IssueRelation::Mentions,
+        };
+        assert!(r.matches("#42"));
+        assert!(r.matches("acme/billing#42"));
+        assert!(!r.matches("#2"));
+        assert!(!r.matches("other/repo#42"));
+    }
+}
@@ -4,19 +4,33 @@
 //! - `types` — data structures and error types
 //! - `chunk` — chunk extraction from parse trees
 //! - `calls` — call site extraction for call graph
+//! - `deprecation` — deprecation markers (`#[deprecated]`, `@Deprecated`, `// Deprecated:`)
 //! - `injection` — multi-grammar injection (HTML→JS/CSS via `set_included_ranges()`)
 //! - `markdown` — heading-based Markdown parser with cross-reference extraction
 //! - `aspx` — ASP.NET Web Forms parser (delegates to C#/VB.NET grammars)
+//! - `plain` — overlapping line windows for text files with no grammar
+//! - `plugin` — chunk extraction for `[[grammar]]` plugins loaded at runtime
+//! - `sandbox` — out-of-process parsing with per-file time and memory limits
 
 pub mod aspx;
 mod calls;
 pub(crate) mod chunk;
+pub mod deprecation;
+mod ffi;
 pub(crate) mod injection;
+pub mod issue_refs;
 pub mod l5x;
 pub mod markdown;
+pub mod plain;
+pub mod plugin;
+pub mod sandbox;
+pub mod signature;
 pub mod types;
 
-pub use chunk::{canonical_hash_fallback, chunk_id, chunk_id_suffixed, collapse_whitespace};
+pub use chunk::{
+    canonical_hash_fallback, chunk_id, chunk_id_suffixed, collapse_whitespace,
+    set_doc_attachment_from_config,
+};
 
 /// The current parser-logic version stamped onto every chunk this build
 /// extracts (`chunk::PARSER_VERSION`). Re-exported so the binary crate's
@@ -162,6 +176,8 @@ pub struct Parser {
     call_queries: HashMap<Language, OnceCell<tree_sitter::Query>>,
     /// Lazily compiled type extraction queries per language
     type_queries: HashMap<Language, OnceCell<tree_sitter::Query>>,
+    /// Secrets masked since the last `take_redactions` (see `crate::secrets`)
+    redactions: std::sync::Mutex<Vec<crate::secrets::FileRedactions>>,
 }
 
 // Note: Default impl intentionally omitted to prevent hidden panics.
@@ -202,9 +218,31 @@ impl Parser {
             queries,
             call_queries,
             type_queries,
+            redactions: std::sync::Mutex::new(Vec::new()),
         })
     }
 
+    /// Mask secrets in a file's source before extraction, recording what was
+    /// masked. Runs on the CRLF-normalized text so offsets match what gets
+    /// stored.
+    fn redact_secrets(&self, source: String, path: &Path) -> String {
+        let (masked, report) = crate::secrets::redact_source(&source, path);
+        let Some(report) = report else {
+            return source;
+        };
+        let masked = masked.into_owned();
+        self.redactions
+            .lock()
+            .unwrap_or_else(|p| p.into_inner())
+            .push(report);
+        masked
+    }
+
+    /// Drain the per-file redaction reports gathered since the last call.
+    pub fn take_redactions(&self) -> Vec<crate::secrets::FileRedactions> {
+        std::mem::take(&mut *self.redactions.lock().unwrap_or_else(|p| p.into_inner()))
+    }
+
     /// Get or compile the chunk extraction query for a language
     fn get_query(&self, language: Language) -> Result<&tree_sitter::Query, ParserError> {
         let cell = self.queries.get(&language).ok_or_else(|| {
@@ -277,9 +315,11 @@ impl Parser {
     pub fn parse_file(&self, path: &Path) -> Result<Vec<Chunk>, ParserError> {
         let _span = tracing::info_span!("parse_file", path = %path.display()).entered();
 
+        // Deep trees on Windows exceed MAX_PATH without the `\\?\` prefix.
+        let io_path = crate::paths::long_path(path);
         // Cap is env-overridable (CQS_PARSER_MAX_FILE_SIZE).
         let max_file_size = crate::limits::parser_max_file_size();
-        match std::fs::metadata(path) {
+        match std::fs::metadata(&io_path) {
             Ok(meta) if meta.len() > max_file_size => {
                 tracing::warn!(
                     size_mb = meta.len() / (1024 * 1024),
@@ -294,7 +334,7 @@ impl Parser {
         }
 
         // Gracefully handle non-UTF8 files
-        let source = match std::fs::read_to_string(path) {
+        let source = match std::fs::read_to_string(&io_path) {
             Ok(s) => s,
             Err(e) if e.kind() == std::io::ErrorKind::InvalidData => {
                 tracing::warn!(path = %path.display(), "Skipping non-UTF8 file");
@@ -312,6 +352,7 @@ impl Parser {
         } else {
             source
         };
+        let source = self.redact_secrets(source, path);
 
         let ext_raw = path.extension().and_then(|e| e.to_str()).unwrap_or("");
         let ext = ext_raw.to_ascii_lowercase();
@@ -319,7 +360,7 @@ impl Parser {
         // Rockwell PLC exports (.l5x/.l5k) route through their grammar-less
         // LanguageDef's custom_chunk_parser via parse_source — a single
         // declarative routing path shared with parse_file_all_inner.
-        let language = Language::from_extension(&ext)
+        let language = Language::from_path(path)
             .ok_or_else(|| ParserError::UnsupportedFileType(ext.to_string()))?;
 
         self.parse_source(&source, language, path)
@@ -539,9 +580,11 @@ impl Parser {
     ) -> Result<ParseAllWithChunkCallsResult, ParserError> {
         let _span = tracing::info_span!("parse_file_all", path = %path.display()).entered();
 
+        // Deep trees on Windows exceed MAX_PATH without the `\\?\` prefix.
+        let io_path = crate::paths::long_path(path);
         // Env-overridable cap (CQS_PARSER_MAX_FILE_SIZE).
         let max_file_size = crate::limits::parser_max_file_size();
-        match std::fs::metadata(path) {
+        match std::fs::metadata(&io_path) {
             Ok(meta) if meta.len() > max_file_size => {
                 tracing::warn!(
                     size_mb = meta.len() / (1024 * 1024),
@@ -556,7 +599,7 @@ impl Parser {
         }
 
         // Read file once
-        let source = match std::fs::read_to_string(path) {
+        let source = match std::fs::read_to_string(&io_path) {
             Ok(s) => s,
             Err(e) if e.kind() == std::io::ErrorKind::InvalidData => {
                 tracing::warn!(path = %path.display(), "Skipping non-UTF8 file");
@@ -574,11 +617,12 @@ impl Parser {
         } else {
             source
         };
+        let source = self.redact_secrets(source, path);
 
         let ext_raw = path.extension().and_then(|e| e.to_str()).unwrap_or("");
         let ext = ext_raw.to_ascii_lowercase();
 
-        let language = Language::from_extension(&ext)
+        let language = Language::from_path(path)
             .ok_or_else(|| ParserError::UnsupportedFileType(ext.to_string()))?;
 
         // Grammar-less languages use custom parsers: routing is declarative
@@ -1055,6 +1099,10 @@ impl Parser {
             );
         }
 
+        // Cross-language boundaries (renamed FFI symbols, cgo references,
+        // protobuf codegen names) the name-keyed call graph can't join alone.
+        call_results.extend(ffi::boundary_edges(&source, language, &chunks));
+
         Ok((chunks, call_results, type_results, chunk_calls, candidates))
     }
 
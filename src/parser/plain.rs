@@ -0,0 +1,240 @@
+//! Plain-text fallback chunker
+//!
+//! Files with no grammar and no custom extractor (`.txt`, `.rst`, `.adoc`,
+//! `.org`, build scripts in DSLs we don't parse, …) would otherwise contribute
+//! nothing to the index. This module cuts them into overlapping windows of
+//! whole lines so they are at least semantically searchable. Windows are
+//! sized in estimated tokens (`CQS_PLAIN_WINDOW_TOKENS`) and share a tail of
+//! `CQS_PLAIN_WINDOW_OVERLAP` tokens with their successor, so a paragraph cut
+//! at a window boundary is still embedded whole in one of the two.
+//!
+//! Every chunk carries `Language::Plain` and `ChunkType::Section` — prose, not
+//! code — so plain windows join markdown under `--include-docs`. There are no
+//! call or type edges to extract.
+
+use std::path::Path;
+
+use super::types::{Chunk, ChunkType, Language, ParserError};
+use super::ParseAllResult;
+use super::Parser;
+
+/// Longest signature kept for a window (the first non-blank line, trimmed).
+const MAX_SIGNATURE_CHARS: usize = 200;
+
+/// Estimated token count of one line: the repo-wide `len / 4` heuristic, with
+/// a floor of one so blank lines still advance the budget.
+fn line_tokens(line: &str) -> usize {
+    (line.len() / 4).max(1)
+}
+
+/// Plan `[start, end)` line ranges covering `tokens` (per-line estimates).
+///
+/// Each window takes lines until the next one would exceed `budget`; a single
+/// line over budget becomes a window of its own. The next window starts far
+/// enough back to repeat up to `overlap` tokens, but always at least one line
+/// past the previous start, so the plan terminates for any input.
+fn plan_windows(tokens: &[usize], budget: usize, overlap: usize) -> Vec<(usize, usize)> {
+    let mut windows = Vec::new();
+    let mut start = 0;
+    while start < tokens.len() {
+        let mut end = start;
+        let mut used = 0;
+        while end < tokens.len() && (end == start || used + tokens[end] <= budget) {
+            used += tokens[end];
+            end += 1;
+        }
+        windows.push((start, end));
+        if end == tokens.len() {
+            break;
+        }
+        let mut next = end;
+        let mut carried = 0;
+        while next > start + 1 && carried + tokens[next - 1] <= overlap {
+            carried += tokens[next - 1];
+            next -= 1;
+        }
+        start = next;
+    }
+    windows
+}
+
+/// First non-blank line of `content`, trimmed and capped.
+fn window_signature(content: &str) -> String {
+    let line = content
+        .lines()
+        .map(str::trim)
+        .find(|l| !l.is_empty())
+        .unwrap_or_default();
+    line.chars().take(MAX_SIGNATURE_CHARS).collect()
+}
+
+/// Cut `source` into windows with explicit sizing. Split from
+/// [`parse_plain_chunks`] so tests don't depend on the env knobs.
+fn window_chunks(source: &str, path: &Path, budget: usize, overlap: usize) -> Vec<Chunk> {
+    // Byte offset of each line start, plus the end of the source as a sentinel.
+    let mut offsets = vec![0usize];
+    let mut lines = Vec::new();
+    for line in source.split_inclusive('\n') {
+        lines.push(line);
+        offsets.push(offsets.last().copied().unwrap_or(0) + line.len());
+    }
+    let tokens: Vec<usize> = lines.iter().map(|l| line_tokens(l)).collect();
+    let plan: Vec<(usize, usize)> = plan_windows(&tokens, budget, overlap)
+        .into_iter()
+        .filter(|&(s, e)| lines[s..e].iter().any(|l| !l.trim().is_empty()))
+        .collect();
+
+    let file_name = path
+        .file_name()
+        .and_then(|s| s.to_str())
+        .unwrap_or("untitled")
+        .to_string();
+    let path_display = path.display().to_string();
+    let multiple = plan.len() > 1;
+
+    plan.iter()
+        .enumerate()
+        .map(|(n, &(s, e))| {
+            let byte_start = offsets[s];
+            let content = source[byte_start..offsets[e]]
+                .trim_end_matches('\n')
+                .to_string();
+            let content_hash = blake3::hash(content.as_bytes()).to_hex().to_string();
+            let line_start = s as u32 + 1;
+            let name = if multiple {
+                format!("{file_name}#{}", n + 1)
+            } else {
+                file_name.clone()
+            };
+            Chunk {
+                id: super::chunk::chunk_id(
+                    &path_display,
+                    line_start,
+                    byte_start as u32,
+                    &content_hash,
+                ),
+                file: path.to_path_buf(),
+                language: Language::Plain,
+                chunk_type: ChunkType::Section,
+                name,
+                signature: window_signature(&content),
+                // No tree to strip comments from; whitespace-collapse is the
+                // best cache key available for prose.
+                canonical_hash: super::chunk::canonical_hash_fallback(&content),
+                content,
+                doc: None,
+                line_start,
+                line_end: e as u32,
+                byte_start: byte_start as u32,
+                content_hash,
+                parent_id: None,
+                window_idx: None,
+                parent_type_name: None,
+                parser_version: super::chunk::PARSER_VERSION,
+            }
+        })
+        .collect()
+}
+
+/// Parse a plain-text file into overlapping line windows.
+///
+/// Expects CRLF already normalized to LF by the caller. Blank files (and
+/// windows that are entirely blank) produce no chunks.
+pub fn parse_plain_chunks(
+    source: &str,
+    path: &Path,
+    _parser: &Parser,
+) -> Result<Vec<Chunk>, ParserError> {
+    let _span = tracing::debug_span!("parse_plain_chunks", path = %path.display()).entered();
+    let chunks = window_chunks(
+        source,
+        path,
+        crate::limits::plain_window_tokens(),
+        crate::limits::plain_window_overlap(),
+    );
+    tracing::debug!(windows = chunks.len(), "plain-text windows");
+    Ok(chunks)
+}
+
+/// Combined-path entry for `parse_file_all`: the windows, and no call or
+/// type edges (plain text has neither).
+pub fn parse_plain_all(
+    source: &str,
+    path: &Path,
+    parser: &Parser,
+) -> Result<ParseAllResult, ParserError> {
+    Ok((parse_plain_chunks(source, path, parser)?, vec![], vec![]))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn plan_single_window_when_under_budget() {
+        assert_eq!(plan_windows(&[3, 3, 3], 100, 10), vec![(0, 3)]);
+    }
+
+    #[test]
+    fn plan_windows_overlap_and_cover() {
+        let tokens = vec![10; 20];
+        let plan = plan_windows(&tokens, 50, 20);
+        assert_eq!(plan.first().map(|w| w.0), Some(0));
+        assert_eq!(plan.last().map(|w| w.1), Some(20));
+        for pair in plan.windows(2) {
+            let ((s0, e0), (s1, _)) = (pair[0], pair[1]);
+            assert!(s1 > s0, "every window advances: {plan:?}");
+            assert!(s1 < e0, "consecutive windows overlap: {plan:?}");
+        }
+        assert_eq!(plan[1], (3, 8), "two 10-token lines carried over");
+    }
+
+    #[test]
+    fn plan_oversized_line_is_its_own_window() {
+        let plan = plan_windows(&[5, 500, 5], 50, 40);
+        assert_eq!(plan, vec![(0, 1), (1, 2), (2, 3)]);
+    }
+
+    #[test]
+    fn plan_zero_overlap_is_disjoint() {
+        let plan = plan_windows(&[10; 6], 20, 0);
+        assert_eq!(plan, vec![(0, 2), (2, 4), (4, 6)]);
+    }
+
+    #[test]
+    fn windows_carry_plain_language_and_unique_ids() {
+        let source: String = (0..200)
+            .map(|i| format!("line {i} of some plain prose that wraps around\n"))
+            .collect();
+        let chunks = window_chunks(&source, Path::new("docs/notes.txt"), 64, 16);
+        assert!(chunks.len() > 1);
+        let mut ids: Vec<&str> = chunks.iter().map(|c| c.id.as_str()).collect();
+        ids.sort_unstable();
+        ids.dedup();
+        assert_eq!(ids.len(), chunks.len());
+        for c in &chunks {
+            assert_eq!(c.language, Language::Plain);
+            assert_eq!(c.chunk_type, ChunkType::Section);
+            assert!(c.name.starts_with("notes.txt#"));
+            assert!(c.content.starts_with(&c.signature));
+        }
+        assert_eq!(chunks[0].line_start, 1);
+        assert_eq!(chunks.last().unwrap().line_end, 200);
+        assert!(chunks[1].line_start <= chunks[0].line_end);
+    }
+
+    #[test]
+    fn small_file_is_one_window_named_after_file() {
+        let chunks = window_chunks("\nhello world\n", Path::new("README.rst"), 64, 16);
+        assert_eq!(chunks.len(), 1);
+        assert_eq!(chunks[0].name, "README.rst");
+        assert_eq!(chunks[0].signature, "hello world");
+        assert_eq!((chunks[0].line_start, chunks[0].line_end), (1, 2));
+    }
+
+    #[test]
+    fn blank_source_yields_nothing() {
+        assert!(window_chunks("", Path::new("a.txt"), 64, 16).is_empty());
+        assert!(window_chunks("\n \n\n", Path::new("a.txt"), 64, 16).is_empty());
+    }
+}
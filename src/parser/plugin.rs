@@ -0,0 +1,73 @@
+//! Chunk extraction for runtime-loaded grammars
+//!
+//! Files claimed by a `[[grammar]]` plugin route here through
+//! `Language::Plugin`'s custom parsers. The grammar and its query come from
+//! [`crate::language::plugin`], picked by the file's extension; matches go
+//! through the same `extract_chunk` as compiled-in grammars, so names,
+//! signatures, doc comments, and ids behave the same. Every chunk carries
+//! `Language::Plugin`.
+
+use std::path::Path;
+
+use tree_sitter::StreamingIterator;
+
+use super::types::{Chunk, Language, ParserError};
+use super::ParseAllResult;
+use super::Parser;
+
+/// Parse a file with the plugin grammar its extension maps to.
+///
+/// Expects CRLF already normalized to LF by the caller.
+pub fn parse_plugin_chunks(
+    source: &str,
+    path: &Path,
+    parser: &Parser,
+) -> Result<Vec<Chunk>, ParserError> {
+    let plugin = crate::language::plugin::for_path(path).ok_or_else(|| {
+        let ext = path.extension().and_then(|e| e.to_str()).unwrap_or("");
+        ParserError::UnsupportedFileType(ext.to_string())
+    })?;
+    let _span = tracing::debug_span!(
+        "parse_plugin_chunks",
+        grammar = %plugin.name,
+        path = %path.display()
+    )
+    .entered();
+
+    let mut ts = tree_sitter::Parser::new();
+    ts.set_language(&plugin.language)
+        .map_err(|e| ParserError::ParseFailed(format!("{}", e)))?;
+    let tree = super::parse_with_timeout(&mut ts, source)
+        .ok_or_else(|| ParserError::ParseFailed(path.display().to_string()))?;
+
+    let max_chunk_bytes = crate::limits::parser_max_chunk_bytes();
+    let mut cursor = tree_sitter::QueryCursor::new();
+    let mut matches = cursor.matches(&plugin.query, tree.root_node(), source.as_bytes());
+    let mut chunks = Vec::new();
+    while let Some(m) = matches.next() {
+        match parser.extract_chunk(source, m, &plugin.query, Language::Plugin, path) {
+            Ok(chunk) if chunk.content.len() > max_chunk_bytes => {
+                tracing::debug!(
+                    "Skipping {} ({} bytes > {} max)",
+                    chunk.id,
+                    chunk.content.len(),
+                    max_chunk_bytes
+                );
+            }
+            Ok(chunk) => chunks.push(chunk),
+            Err(e) => tracing::debug!(error = %e, "Failed to extract plugin chunk"),
+        }
+    }
+    tracing::debug!(chunks = chunks.len(), "plugin grammar chunks");
+    Ok(chunks)
+}
+
+/// Combined-path entry for `parse_file_all`: chunks only. Plugin queries
+/// don't describe calls or type references yet.
+pub fn parse_plugin_all(
+    source: &str,
+    path: &Path,
+    parser: &Parser,
+) -> Result<ParseAllResult, ParserError> {
+    Ok((parse_plugin_chunks(source, path, parser)?, vec![], vec![]))
+}
@@ -0,0 +1,516 @@
+//! Out-of-process parsing for untrusted trees
+//!
+//! Grammars are native code, so a crafted file that trips a grammar bug can
+//! crash, hang, or exhaust the process that parses it. With sandboxing on
+//! (`cqs index --sandbox`, `[index] sandbox_parsing`, `CQS_SANDBOX_PARSE`)
+//! the index pipeline hands each file to a `cqs parse-worker` child instead
+//! of parsing in-process. A worker:
+//!
+//! - parses one file at a time, answering JSON lines over stdin/stdout;
+//! - caps its address space at `CQS_SANDBOX_MAX_MEMORY_MB` (unix), takes no
+//!   core dumps, and sets `no_new_privs` (Linux) before it parses anything;
+//! - is killed when one file runs past `CQS_SANDBOX_TIMEOUT_MS`.
+//!
+//! A file that times out or takes its worker down is skipped and recorded as
+//! a [`SandboxViolation`]; the next file gets a fresh worker. Ordinary parse
+//! errors come back over the pipe and leave the worker running.
+//!
+//! Running the grammars as WASM would be a tighter box, but cqs ships them
+//! as native libraries; the process boundary isolates the same code.
+
+use std::io::{BufRead, BufReader, Read, Write};
+use std::path::{Path, PathBuf};
+use std::process::{Child, ChildStdin, Command, Stdio};
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::{Mutex, OnceLock};
+use std::time::Duration;
+
+use crossbeam_channel::{Receiver, RecvTimeoutError};
+use serde::{Deserialize, Serialize};
+
+use super::{ParseAllWithChunkCallsResult, Parser};
+use crate::secrets::FileRedactions;
+
+/// Subcommand the pool runs to start a worker.
+pub const WORKER_SUBCOMMAND: &str = "parse-worker";
+
+/// Errors from a sandboxed parse.
+#[derive(Debug, thiserror::Error)]
+pub enum SandboxError {
+    #[error("failed to start parse worker: {0}")]
+    Spawn(#[source] std::io::Error),
+    /// The worker parsed the file and reported an ordinary parse error.
+    #[error("{0}")]
+    Parse(String),
+    /// The file broke a sandbox limit and was skipped.
+    #[error("skipped by parse sandbox: {0}")]
+    Violation(ViolationKind),
+}
+
+/// Why the sandbox gave up on a file.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum ViolationKind {
+    /// Ran past the per-file wall-clock budget.
+    Timeout { ms: u64 },
+    /// The worker died mid-file: a crash, or an allocation refused under
+    /// the memory cap.
+    Crashed { status: String },
+    /// The worker answered with something that isn't a response.
+    Protocol { detail: String },
+}
+
+impl std::fmt::Display for ViolationKind {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        match self {
+            ViolationKind::Timeout { ms } => write!(f, "timed out after {ms} ms"),
+            ViolationKind::Crashed { status } => write!(f, "worker died ({status})"),
+            ViolationKind::Protocol { detail } => write!(f, "bad worker response ({detail})"),
+        }
+    }
+}
+
+/// One file the sandbox skipped.
+#[derive(Debug, Clone)]
+pub struct SandboxViolation {
+    /// Path as handed to the pool.
+    pub path: PathBuf,
+    pub kind: ViolationKind,
+}
+
+/// Per-file limits for a worker.
+#[derive(Debug, Clone, Copy)]
+pub struct SandboxLimits {
+    pub timeout: Duration,
+    pub max_memory_bytes: u64,
+}
+
+impl SandboxLimits {
+    /// `CQS_SANDBOX_TIMEOUT_MS` and `CQS_SANDBOX_MAX_MEMORY_MB`, or defaults.
+    pub fn from_env() -> Self {
+        Self {
+            timeout: Duration::from_millis(crate::limits::sandbox_timeout_ms()),
+            max_memory_bytes: crate::limits::sandbox_max_memory_mb().saturating_mul(1024 * 1024),
+        }
+    }
+}
+
+/// `[index] sandbox_parsing`, pushed in once at startup.
+static SANDBOX_PARSING: OnceLock<bool> = OnceLock::new();
+
+/// Set by `cqs index --sandbox`.
+static FORCED: AtomicBool = AtomicBool::new(false);
+
+/// Push `[index] sandbox_parsing` into the pipeline. Later calls are no-ops
+/// (OnceLock).
+pub fn set_enabled_from_config(enabled: bool) {
+    let _ = SANDBOX_PARSING.set(enabled);
+}
+
+/// Turn sandboxing on for the rest of the process regardless of env and
+/// config (`cqs index --sandbox`).
+pub fn force_enable() {
+    FORCED.store(true, Ordering::Relaxed);
+}
+
+/// Whether indexing should parse through a [`SandboxPool`]. Resolution:
+/// `--sandbox` > `CQS_SANDBOX_PARSE` env (`0` / `false` / `no` off,
+/// anything else on) > `[index] sandbox_parsing` > off.
+pub fn enabled() -> bool {
+    if FORCED.load(Ordering::Relaxed) {
+        return true;
+    }
+    match std::env::var("CQS_SANDBOX_PARSE").as_deref() {
+        Ok("0") | Ok("false") | Ok("no") => false,
+        Ok(_) => true,
+        Err(_) => SANDBOX_PARSING.get().copied().unwrap_or(false),
+    }
+}
+
+/// One request line: the file to parse.
+#[derive(Serialize, Deserialize)]
+struct Request {
+    path: PathBuf,
+}
+
+/// One response line: the parse result (or the parse error's message) and
+/// the secrets the worker masked while producing it.
+#[derive(Serialize, Deserialize)]
+struct Response {
+    result: Result<ParseAllWithChunkCallsResult, String>,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    redactions: Vec<FileRedactions>,
+}
+
+/// Largest response line the parent accepts. Chunk contents repeat the
+/// file (nested chunks overlap) and JSON escaping inflates them, so allow
+/// a generous multiple of the largest file the parser takes.
+fn response_max_bytes() -> u64 {
+    crate::limits::parser_max_file_size().saturating_mul(16)
+}
+
+/// A running worker process.
+struct Worker {
+    child: Child,
+    /// `None` once closed, which tells the worker to exit.
+    stdin: Option<ChildStdin>,
+    lines: Receiver<std::io::Result<String>>,
+}
+
+impl Worker {
+    fn spawn(exe: &Path) -> std::io::Result<Self> {
+        let mut child = Command::new(exe)
+            .arg(WORKER_SUBCOMMAND)
+            // One telemetry line per worker would drown the real commands.
+            .env("CQS_TELEMETRY", "0")
+            .stdin(Stdio::piped())
+            .stdout(Stdio::piped())
+            .stderr(Stdio::inherit())
+            .spawn()?;
+        let stdin = child.stdin.take();
+        let Some(stdout) = child.stdout.take() else {
+            let _ = child.kill();
+            let _ = child.wait();
+            return Err(std::io::Error::other("parse worker stdout not captured"));
+        };
+
+        // Responses are read on their own thread so the parent can wait on
+        // them with a deadline.
+        let (tx, lines) = crossbeam_channel::bounded(1);
+        let cap = response_max_bytes();
+        std::thread::Builder::new()
+            .name("cqs-parse-worker-io".to_string())
+            .spawn(move || {
+                let mut reader = BufReader::new(stdout);
+                loop {
+                    let mut buf = Vec::new();
+                    let line = match (&mut reader)
+                        .take(cap.saturating_add(1))
+                        .read_until(b'\n', &mut buf)
+                    {
+                        Ok(0) => break,
+                        Ok(_) if buf.last() != Some(&b'\n') => Err(std::io::Error::other(format!(
+                            "response over {cap} bytes or truncated"
+                        ))),
+                        Ok(_) => String::from_utf8(buf).map_err(std::io::Error::other),
+                        Err(e) => Err(e),
+                    };
+                    let failed = line.is_err();
+                    if tx.send(line).is_err() || failed {
+                        break;
+                    }
+                }
+            })?;
+
+        Ok(Self {
+            child,
+            stdin,
+            lines,
+        })
+    }
+
+    /// Send one file and wait for its response.
+    fn parse(&mut self, path: &Path, timeout: Duration) -> Result<Response, ViolationKind> {
+        let mut line = serde_json::to_string(&Request {
+            path: path.to_path_buf(),
+        })
+        .map_err(|e| ViolationKind::Protocol {
+            detail: e.to_string(),
+        })?;
+        line.push('\n');
+        let sent = match self.stdin.as_mut() {
+            Some(stdin) => stdin
+                .write_all(line.as_bytes())
+                .and_then(|()| stdin.flush()),
+            None => Err(std::io::Error::from(std::io::ErrorKind::BrokenPipe)),
+        };
+        if sent.is_err() {
+            return Err(ViolationKind::Crashed {
+                status: self.reap(),
+            });
+        }
+
+        match self.lines.recv_timeout(timeout) {
+            Ok(Ok(line)) => serde_json::from_str(&line).map_err(|e| ViolationKind::Protocol {
+                detail: e.to_string(),
+            }),
+            Ok(Err(e)) => Err(ViolationKind::Protocol {
+                detail: e.to_string(),
+            }),
+            Err(RecvTimeoutError::Timeout) => Err(ViolationKind::Timeout {
+                ms: timeout.as_millis() as u64,
+            }),
+            Err(RecvTimeoutError::Disconnected) => Err(ViolationKind::Crashed {
+                status: self.reap(),
+            }),
+        }
+    }
+
+    /// Exit status of a worker whose stdout closed. Gives it a moment to
+    /// finish exiting before killing it, so the status says why it died.
+    fn reap(&mut self) -> String {
+        for _ in 0..50 {
+            match self.child.try_wait() {
+                Ok(Some(status)) => return status.to_string(),
+                Ok(None) => std::thread::sleep(Duration::from_millis(10)),
+                Err(e) => return e.to_string(),
+            }
+        }
+        let _ = self.child.kill();
+        match self.child.wait() {
+            Ok(status) => status.to_string(),
+            Err(e) => e.to_string(),
+        }
+    }
+
+    fn kill(mut self) {
+        let _ = self.child.kill();
+    }
+}
+
+impl Drop for Worker {
+    fn drop(&mut self) {
+        // Closing stdin ends the worker's loop; an idle worker exits at once
+        // and a killed one is already gone. Wait so neither is left a zombie.
+        drop(self.stdin.take());
+        let _ = self.child.wait();
+    }
+}
+
+/// Pool of parse workers shared by the pipeline's parse threads. Each call
+/// checks out an idle worker (spawning one when none is free), so up to one
+/// worker runs per parse thread.
+pub struct SandboxPool {
+    exe: PathBuf,
+    limits: SandboxLimits,
+    idle: Mutex<Vec<Worker>>,
+    violations: Mutex<Vec<SandboxViolation>>,
+    redactions: Mutex<Vec<FileRedactions>>,
+}
+
+impl SandboxPool {
+    /// `exe` is the `cqs` binary to run workers from, normally
+    /// `std::env::current_exe()`.
+    pub fn new(exe: PathBuf, limits: SandboxLimits) -> Self {
+        Self {
+            exe,
+            limits,
+            idle: Mutex::new(Vec::new()),
+            violations: Mutex::new(Vec::new()),
+            redactions: Mutex::new(Vec::new()),
+        }
+    }
+
+    /// Sandboxed [`Parser::parse_file_all_with_chunk_calls`].
+    pub fn parse_file_all_with_chunk_calls(
+        &self,
+        path: &Path,
+    ) -> Result<ParseAllWithChunkCallsResult, SandboxError> {
+        let idle = self.idle.lock().unwrap_or_else(|p| p.into_inner()).pop();
+        let mut worker = match idle {
+            Some(worker) => worker,
+            None => Worker::spawn(&self.exe).map_err(SandboxError::Spawn)?,
+        };
+        match worker.parse(path, self.limits.timeout) {
+            Ok(response) => {
+                self.idle
+                    .lock()
+                    .unwrap_or_else(|p| p.into_inner())
+                    .push(worker);
+                if !response.redactions.is_empty() {
+                    self.redactions
+                        .lock()
+                        .unwrap_or_else(|p| p.into_inner())
+                        .extend(response.redactions);
+                }
+                response.result.map_err(SandboxError::Parse)
+            }
+            Err(kind) => {
+                worker.kill();
+                tracing::warn!(
+                    path = %path.display(),
+                    reason = %kind,
+                    "Parse sandbox skipped file"
+                );
+                self.violations
+                    .lock()
+                    .unwrap_or_else(|p| p.into_inner())
+                    .push(SandboxViolation {
+                        path: path.to_path_buf(),
+                        kind: kind.clone(),
+                    });
+                Err(SandboxError::Violation(kind))
+            }
+        }
+    }
+
+    /// Files skipped so far, in the order they were skipped.
+    pub fn violations(&self) -> Vec<SandboxViolation> {
+        self.violations
+            .lock()
+            .unwrap_or_else(|p| p.into_inner())
+            .clone()
+    }
+
+    /// Drain the redaction reports workers have sent since the last call
+    /// (the sandboxed counterpart of [`Parser::take_redactions`]).
+    pub fn take_redactions(&self) -> Vec<FileRedactions> {
+        std::mem::take(&mut *self.redactions.lock().unwrap_or_else(|p| p.into_inner()))
+    }
+}
+
+/// Worker loop: one [`Request`] per line on `input`, one response line per
+/// request on `output`. Returns at end of input.
+pub fn serve(parser: &Parser, input: impl BufRead, mut output: impl Write) -> std::io::Result<()> {
+    for line in input.lines() {
+        let line = line?;
+        if line.trim().is_empty() {
+            continue;
+        }
+        let result = match serde_json::from_str::<Request>(&line) {
+            Ok(request) => parser
+                .parse_file_all_with_chunk_calls(&request.path)
+                .map_err(|e| e.to_string()),
+            Err(e) => Err(format!("bad request: {e}")),
+        };
+        let response = Response {
+            result,
+            redactions: parser.take_redactions(),
+        };
+        serde_json::to_writer(&mut output, &response).map_err(std::io::Error::from)?;
+        output.write_all(b"\n")?;
+        output.flush()?;
+    }
+    Ok(())
+}
+
+/// Confine the calling process before it parses anything: cap its address
+/// space at `limits.max_memory_bytes`, disable core dumps, and on Linux
+/// forbid gaining privileges through exec. Hard limits are lowered too, so
+/// a compromised worker can't raise them back. Elsewhere only the parent's
+/// timeout applies.
+pub fn restrict_current_process(limits: &SandboxLimits) -> std::io::Result<()> {
+    #[cfg(unix)]
+    {
+        lower_rlimit(libc::RLIMIT_CORE, 0)?;
+        lower_rlimit(libc::RLIMIT_AS, limits.max_memory_bytes)?;
+    }
+    #[cfg(target_os = "linux")]
+    {
+        // SAFETY: PR_SET_NO_NEW_PRIVS takes only integer arguments and
+        // touches no caller memory.
+        if unsafe { libc::prctl(libc::PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0) } != 0 {
+            return Err(std::io::Error::last_os_error());
+        }
+    }
+    #[cfg(not(unix))]
+    {
+        let _ = limits;
+        tracing::debug!("No OS resource limits on this platform; parse sandbox relies on timeouts");
+    }
+    Ok(())
+}
+
+#[cfg(all(target_os = "linux", target_env = "gnu"))]
+type RlimitResource = libc::__rlimit_resource_t;
+#[cfg(all(unix, not(all(target_os = "linux", target_env = "gnu"))))]
+type RlimitResource = libc::c_int;
+
+/// Lower both limits of `resource` to `value`, never raising either.
+#[cfg(unix)]
+fn lower_rlimit(resource: RlimitResource, value: u64) -> std::io::Result<()> {
+    let mut current = libc::rlimit {
+        rlim_cur: 0,
+        rlim_max: 0,
+    };
+    // SAFETY: `current` is a valid, writable rlimit for the call's duration.
+    if unsafe { libc::getrlimit(resource, &mut current) } != 0 {
+        return Err(std::io::Error::last_os_error());
+    }
+    let value = value as libc::rlim_t;
+    let lowered = libc::rlimit {
+        rlim_cur: current.rlim_cur.min(value),
+        rlim_max: current.rlim_max.min(value),
+    };
+    // SAFETY: `lowered` is a valid rlimit read by the call.
+    if unsafe { libc::setrlimit(resource, &lowered) } != 0 {
+        return Err(std::io::Error::last_os_error());
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn serve_answers_each_request_on_its_own_line() {
+        let dir = tempfile::tempdir().unwrap();
+        let file = dir.path().join("lib.rs");
+        std::fs::write(&file, "fn alpha() { beta(); }\nfn beta() {}\n").unwrap();
+        let missing = dir.path().join("missing.rs");
+
+        let mut input = String::new();
+        for path in [&file, &missing] {
+            input.push_str(
+                &serde_json::to_string(&Request {
+                    path: path.to_path_buf(),
+                })
+                .unwrap(),
+            );
+            input.push('\n');
+        }
+        input.push_str("not json\n");
+
+        let parser = Parser::new().unwrap();
+        let mut output = Vec::new();
+        serve(&parser, input.as_bytes(), &mut output).unwrap();
+
+        let lines: Vec<Response> = String::from_utf8(output)
+            .unwrap()
+            .lines()
+            .map(|l| serde_json::from_str(l).unwrap())
+            .collect();
+        assert_eq!(lines.len(), 3);
+        let (chunks, calls, ..) = lines[0].result.as_ref().unwrap();
+        let names: Vec<&str> = chunks.iter().map(|c| c.name.as_str()).collect();
+        assert_eq!(names, ["alpha", "beta"]);
+        assert!(calls
+            .iter()
+            .any(|fc| fc.name == "alpha" && fc.calls.iter().any(|c| c.callee_name == "beta")));
+        assert!(lines[1].result.is_err());
+        assert!(lines[2]
+            .result
+            .as_ref()
+            .unwrap_err()
+            .starts_with("bad request"));
+    }
+
+    #[test]
+    fn parse_results_round_trip_through_json() {
+        let parser = Parser::new().unwrap();
+        let dir = tempfile::tempdir().unwrap();
+        let file = dir.path().join("a.py");
+        std::fs::write(&file, "def f(x: int) -> int:\n    return g(x)\n").unwrap();
+        let parsed = parser.parse_file_all_with_chunk_calls(&file).unwrap();
+        let back: ParseAllWithChunkCallsResult =
+            serde_json::from_str(&serde_json::to_string(&parsed).unwrap()).unwrap();
+        assert_eq!(back.0.len(), parsed.0.len());
+        assert_eq!(back.0[0].id, parsed.0[0].id);
+        assert_eq!(back.0[0].language, parsed.0[0].language);
+        assert_eq!(back.0[0].chunk_type, parsed.0[0].chunk_type);
+        assert_eq!(back.3.len(), parsed.3.len());
+    }
+
+    #[test]
+    fn violation_kinds_describe_themselves() {
+        assert_eq!(
+            ViolationKind::Timeout { ms: 30_000 }.to_string(),
+            "timed out after 30000 ms"
+        );
+        assert!(ViolationKind::Crashed {
+            status: "signal: 11 (SIGSEGV)".into()
+        }
+        .to_string()
+        .contains("SIGSEGV"));
+    }
+}
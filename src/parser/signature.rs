@@ -0,0 +1,701 @@
+//! Structured function signatures for shape search (`--signature`).
+//!
+//! A chunk's `signature` is free text in its language's syntax. For callables
+//! in typed languages, [`SignatureShape::extract`] pulls out the parameter
+//! types in order and the return type, normalized so one shape reads the same
+//! way whatever the language spelled around it:
+//!
+//! ```text
+//! func Write(ctx context.Context, data []byte) error   →  (context.Context, []byte) -> error
+//! pub fn load<'a>(path: &'a Path) -> Result<Config>    →  (&Path) -> Result<Config>
+//! public static int parse(String s, int radix)         →  (String, int) -> int
+//! ```
+//!
+//! The shape is stored per chunk in that canonical text form
+//! (`chunks.signature_shape`, schema v41). A [`SignaturePattern`] is parsed
+//! from the same syntax, with `_` for any one type and a trailing `..` for any
+//! remaining parameters. Parameter names never take part in a match.
+
+use std::fmt;
+
+use super::{ChunkType, Language};
+
+/// How a language writes one typed parameter.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum ParamStyle {
+    /// `name: Type` — Rust, TypeScript, Python, Kotlin, Swift, Scala, ...
+    NameColonType,
+    /// `name Type` — Go.
+    NameType,
+    /// `Type name` — the C family, Java, C#, PHP, Solidity.
+    TypeName,
+}
+
+/// Parameter style of `lang`, or `None` for languages whose signatures carry
+/// no parameter types (JavaScript, Ruby, Lua, ...) or no parameter list.
+fn param_style(lang: Language) -> Option<ParamStyle> {
+    match lang {
+        Language::Rust
+        | Language::TypeScript
+        | Language::Python
+        | Language::Kotlin
+        | Language::Swift
+        | Language::Scala
+        | Language::Gleam
+        | Language::Zig
+        | Language::FSharp
+        | Language::Julia => Some(ParamStyle::NameColonType),
+        Language::Go => Some(ParamStyle::NameType),
+        Language::C
+        | Language::Cpp
+        | Language::Cuda
+        | Language::Glsl
+        | Language::Java
+        | Language::CSharp
+        | Language::ObjC
+        | Language::Php
+        | Language::Solidity => Some(ParamStyle::TypeName),
+        _ => None,
+    }
+}
+
+/// Declaration keywords that precede a `Type name(...)` return type without
+/// being part of it.
+const DECL_MODIFIERS: &[&str] = &[
+    "public",
+    "private",
+    "protected",
+    "internal",
+    "static",
+    "final",
+    "abstract",
+    "virtual",
+    "override",
+    "async",
+    "extern",
+    "inline",
+    "sealed",
+    "synchronized",
+    "native",
+    "default",
+    "unsafe",
+    "new",
+    "partial",
+    "readonly",
+    "constexpr",
+    "explicit",
+    "friend",
+    "function",
+    "__global__",
+    "__device__",
+    "__host__",
+];
+
+/// Parameter types and return type of one callable, normalized.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct SignatureShape {
+    /// Parameter types in declaration order. Receivers (`self`, `this`) are
+    /// left out; an untyped parameter is `_`.
+    pub params: Vec<String>,
+    /// Return type, `None` when the signature declares none.
+    pub returns: Option<String>,
+}
+
+impl SignatureShape {
+    /// Extract the shape of callable `name` from its `signature` text.
+    /// `None` when the language carries no parameter types or the parameter
+    /// list can't be located.
+    pub fn extract(signature: &str, name: &str, lang: Language) -> Option<Self> {
+        let style = param_style(lang)?;
+        let (name_start, open) = find_param_list(signature, name)?;
+        let close = matching_close(signature, open)?;
+        let params = split_top_level(&signature[open + 1..close], ',')
+            .into_iter()
+            .filter_map(|p| param_type(p, style, lang))
+            .collect::<Vec<_>>();
+        let params = spread_go_groups(params);
+        let tail = &signature[close + 1..];
+        let returns = match style {
+            ParamStyle::TypeName => trailing_return(tail, style, lang)
+                .or_else(|| leading_return(&signature[..name_start])),
+            _ => trailing_return(tail, style, lang),
+        }
+        .map(|r| normalize_type(&r, lang == Language::Rust))
+        .filter(|r| !r.is_empty());
+        // A C `(void)` list declares no parameters.
+        let params = if params.len() == 1 && params[0] == "void" {
+            Vec::new()
+        } else {
+            params
+        };
+        Some(Self { params, returns })
+    }
+
+    /// Parse the canonical text form written by `Display`.
+    pub fn parse(s: &str) -> Option<Self> {
+        let (params, returns) = parse_shape_text(s).ok()?;
+        Some(Self { params, returns })
+    }
+}
+
+/// The text stored in `chunks.signature_shape`: the canonical shape of a
+/// callable chunk, `None` for other chunk types and unreadable signatures.
+pub fn stored_shape(
+    chunk_type: ChunkType,
+    signature: &str,
+    name: &str,
+    lang: Language,
+) -> Option<String> {
+    if !chunk_type.is_callable() {
+        return None;
+    }
+    SignatureShape::extract(signature, name, lang).map(|s| s.to_string())
+}
+
+impl fmt::Display for SignatureShape {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "({})", self.params.join(", "))?;
+        if let Some(r) = &self.returns {
+            write!(f, " -> {r}")?;
+        }
+        Ok(())
+    }
+}
+
+/// A `--signature` query: `(T1, T2) -> R`.
+///
+/// `_` matches any one type and a trailing `..` any remaining parameters. A
+/// type matches its path-qualified form too (`Context` matches
+/// `context.Context`, `Path` matches `std::path::Path`). Omitting the return
+/// type matches any return; `()` or `void` matches a callable that declares
+/// none. The arrow may also be written `:` or left out.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct SignaturePattern {
+    params: Vec<String>,
+    open_ended: bool,
+    returns: Option<String>,
+}
+
+impl SignaturePattern {
+    pub fn parse(s: &str) -> Result<Self, String> {
+        let (mut params, returns) = parse_shape_text(s)?;
+        let open_ended = params.last().is_some_and(|p| p == "..");
+        if open_ended {
+            params.pop();
+        }
+        if params.iter().any(|p| p == "..") {
+            return Err(format!(
+                "signature pattern '{s}': `..` is only allowed as the last parameter"
+            ));
+        }
+        Ok(Self {
+            params,
+            open_ended,
+            returns,
+        })
+    }
+
+    pub fn matches(&self, shape: &SignatureShape) -> bool {
+        let arity_ok = if self.open_ended {
+            shape.params.len() >= self.params.len()
+        } else {
+            shape.params.len() == self.params.len()
+        };
+        if !arity_ok {
+            return false;
+        }
+        if !self
+            .params
+            .iter()
+            .zip(&shape.params)
+            .all(|(want, have)| type_matches(want, have))
+        {
+            return false;
+        }
+        match (&self.returns, &shape.returns) {
+            (None, _) => true,
+            (Some(want), None) => matches!(want.as_str(), "()" | "void" | "_"),
+            (Some(want), Some(have)) => type_matches(want, have),
+        }
+    }
+}
+
+fn type_matches(want: &str, have: &str) -> bool {
+    want == "_"
+        || want == have
+        || have
+            .strip_suffix(want)
+            .is_some_and(|head| head.ends_with('.') || head.ends_with("::"))
+}
+
+/// Split `(T1, T2) -> R` into normalized parameter and return types.
+fn parse_shape_text(s: &str) -> Result<(Vec<String>, Option<String>), String> {
+    let s = s.trim();
+    if !s.starts_with('(') {
+        return Err(format!(
+            "signature pattern '{s}' must start with a parameter list, e.g. '(int, string) -> bool'"
+        ));
+    }
+    let close = matching_close(s, 0)
+        .ok_or_else(|| format!("signature pattern '{s}' has an unbalanced parameter list"))?;
+    let params = split_top_level(&s[1..close], ',')
+        .into_iter()
+        .map(|p| normalize_type(p, true))
+        .filter(|p| !p.is_empty())
+        .collect();
+    let tail = s[close + 1..].trim();
+    let tail = tail
+        .strip_prefix("->")
+        .or_else(|| tail.strip_prefix("::"))
+        .or_else(|| tail.strip_prefix(':'))
+        .unwrap_or(tail);
+    let returns = Some(normalize_type(tail, true)).filter(|r| !r.is_empty());
+    Ok((params, returns))
+}
+
+/// Locate `name` as a whole word followed (after optional generics) by `(`.
+/// Returns the byte offsets of the name and of the `(`.
+fn find_param_list(signature: &str, name: &str) -> Option<(usize, usize)> {
+    if name.is_empty() {
+        return None;
+    }
+    let bytes = signature.as_bytes();
+    let mut from = 0;
+    while let Some(rel) = signature[from..].find(name) {
+        let start = from + rel;
+        let end = start + name.len();
+        from = end;
+        if start > 0 && is_ident_byte(bytes[start - 1]) {
+            continue;
+        }
+        let mut i = end;
+        while i < bytes.len() && bytes[i].is_ascii_whitespace() {
+            i += 1;
+        }
+        if i < bytes.len() && bytes[i] == b'<' {
+            i = matching_close(signature, i)? + 1;
+            while i < bytes.len() && bytes[i].is_ascii_whitespace() {
+                i += 1;
+            }
+        }
+        if i < bytes.len() && bytes[i] == b'(' {
+            return Some((start, i));
+        }
+    }
+    None
+}
+
+fn is_ident_byte(b: u8) -> bool {
+    b.is_ascii_alphanumeric() || b == b'_' || b == b'$'
+}
+
+/// Byte offset of the bracket closing the one at `open` (`(`, `[`, `{`, or
+/// `<`), skipping nested brackets and the `>` of `->` / `=>`.
+fn matching_close(s: &str, open: usize) -> Option<usize> {
+    let bytes = s.as_bytes();
+    let mut depth = 0usize;
+    for (i, &b) in bytes.iter().enumerate().skip(open) {
+        match b {
+            b'(' | b'[' | b'{' | b'<' => depth += 1,
+            b'>' if i > 0 && matches!(bytes[i - 1], b'-' | b'=') => {}
+            b')' | b']' | b'}' | b'>' => {
+                depth = depth.checked_sub(1)?;
+                if depth == 0 {
+                    return Some(i);
+                }
+            }
+            _ => {}
+        }
+    }
+    None
+}
+
+/// Split `s` on `sep` outside any brackets. Empty pieces are dropped.
+fn split_top_level(s: &str, sep: char) -> Vec<&str> {
+    let bytes = s.as_bytes();
+    let mut parts = Vec::new();
+    let mut depth = 0i32;
+    let mut start = 0;
+    for (i, c) in s.char_indices() {
+        match c {
+            '(' | '[' | '{' | '<' => depth += 1,
+            '>' if i > 0 && matches!(bytes[i - 1], b'-' | b'=') => {}
+            ')' | ']' | '}' | '>' => depth -= 1,
+            _ if c == sep && depth == 0 => {
+                parts.push(s[start..i].trim());
+                start = i + c.len_utf8();
+            }
+            _ => {}
+        }
+    }
+    parts.push(s[start..].trim());
+    parts.retain(|p| !p.is_empty());
+    parts
+}
+
+/// Cut a default value (`= ...`) off a parameter.
+fn strip_default(p: &str) -> &str {
+    let bytes = p.as_bytes();
+    let mut depth = 0i32;
+    for (i, &b) in bytes.iter().enumerate() {
+        match b {
+            b'(' | b'[' | b'{' | b'<' => depth += 1,
+            b')' | b']' | b'}' => depth -= 1,
+            b'>' if i > 0 && !matches!(bytes[i - 1], b'-' | b'=') => depth -= 1,
+            b'=' if depth == 0 => {
+                let next = bytes.get(i + 1).copied();
+                let prev = if i > 0 { bytes[i - 1] } else { b' ' };
+                if next != Some(b'>') && next != Some(b'=') && !matches!(prev, b'!' | b'<' | b'>') {
+                    return p[..i].trim_end();
+                }
+            }
+            _ => {}
+        }
+    }
+    p
+}
+
+/// One entry of a parameter list.
+enum Param {
+    /// A parameter whose type is known.
+    Typed(String),
+    /// A lone Go token: an unnamed parameter's type, or a name whose type
+    /// comes from a later parameter of its group (`a, b int`).
+    Bare(String),
+}
+
+/// Type of one parameter, `None` for a receiver that should not count.
+fn param_type(raw: &str, style: ParamStyle, lang: Language) -> Option<Param> {
+    let p = strip_default(raw).trim();
+    if p.is_empty() {
+        return None;
+    }
+    match style {
+        ParamStyle::NameColonType => {
+            let (name, ty) = match p.split_once(':') {
+                Some((name, ty)) => (name, Some(ty.trim_start_matches(':'))),
+                None => (p, None),
+            };
+            if is_receiver(name, lang) {
+                return None;
+            }
+            let ty = ty
+                .map(|t| normalize_type(t, lang == Language::Rust))
+                .filter(|t| !t.is_empty())
+                .unwrap_or_else(|| "_".to_string());
+            Some(Param::Typed(ty))
+        }
+        ParamStyle::NameType => Some(match p.split_once(char::is_whitespace) {
+            Some((_name, ty)) => Param::Typed(normalize_type(ty, false)),
+            None => Param::Bare(p.to_string()),
+        }),
+        ParamStyle::TypeName => {
+            let p = p
+                .split_whitespace()
+                .filter(|t| !t.starts_with('@') && !matches!(*t, "final" | "this" | "params"))
+                .collect::<Vec<_>>()
+                .join(" ");
+            Some(Param::Typed(type_name_param(&p)))
+        }
+    }
+}
+
+/// Whether the name half of a `name: Type` parameter is the method
+/// receiver (`self`, `&'a mut self`, Python's `cls`).
+fn is_receiver(name: &str, lang: Language) -> bool {
+    let last = name
+        .split_whitespace()
+        .next_back()
+        .unwrap_or("")
+        .trim_start_matches('&');
+    match lang {
+        Language::Rust => last == "self",
+        Language::Python => matches!(last, "self" | "cls"),
+        _ => false,
+    }
+}
+
+/// The type of a `Type name` parameter: everything but the trailing name,
+/// with array brackets on the name moved onto the type.
+fn type_name_param(p: &str) -> String {
+    let bytes = p.as_bytes();
+    let mut end = bytes.len();
+    let mut suffix = String::new();
+    while end >= 2 && bytes[end - 1] == b']' {
+        let Some(open) = p[..end].rfind('[') else {
+            break;
+        };
+        suffix.insert_str(0, &p[open..end]);
+        end = open;
+    }
+    let name_end = p[..end].trim_end().len();
+    let mut name_start = name_end;
+    while name_start > 0 && is_ident_byte(bytes[name_start - 1]) {
+        name_start -= 1;
+    }
+    let ty = p[..name_start].trim();
+    if ty.is_empty() || ty.ends_with("::") {
+        // One token: an unnamed parameter's type, or an untyped PHP `$x`.
+        if p.starts_with('$') {
+            return "_".to_string();
+        }
+        return normalize_type(p, false);
+    }
+    normalize_type(&format!("{ty}{suffix}"), false)
+}
+
+/// Resolve Go's grouped parameters: in `a, b int` the bare names take the
+/// type of the next typed parameter. When no parameter is named, every lone
+/// token is a type (`func(int, string)`). Other languages never produce
+/// [`Param::Bare`].
+fn spread_go_groups(params: Vec<Param>) -> Vec<String> {
+    let any_typed = params.iter().any(|p| matches!(p, Param::Typed(_)));
+    let mut out = Vec::with_capacity(params.len());
+    let mut group_type: Option<String> = None;
+    for p in params.into_iter().rev() {
+        out.push(match p {
+            Param::Typed(t) => {
+                group_type = Some(t.clone());
+                t
+            }
+            Param::Bare(tok) if any_typed => group_type
+                .clone()
+                .unwrap_or_else(|| normalize_type(&tok, false)),
+            Param::Bare(tok) => normalize_type(&tok, false),
+        });
+    }
+    out.reverse();
+    out
+}
+
+/// Return type written after the parameter list: `-> T`, `: T`, `::T`,
+/// Go's bare `T`, or Solidity's `returns (T)`.
+fn trailing_return(tail: &str, style: ParamStyle, lang: Language) -> Option<String> {
+    let collapsed = tail.split_whitespace().collect::<Vec<_>>().join(" ");
+    let mut t = collapsed.as_str();
+    for cut in [" where ", "{", " = ", " throws ", " throws"] {
+        if let Some(at) = t.find(cut) {
+            t = t[..at].trim_end();
+        }
+    }
+    if lang == Language::Solidity {
+        let at = t.find("returns")?;
+        return Some(t[at + "returns".len()..].trim().to_string());
+    }
+    loop {
+        let before = t;
+        for word in ["async", "throws", "rethrows"] {
+            if let Some(rest) = t.strip_prefix(word) {
+                if rest.is_empty() || rest.starts_with(char::is_whitespace) {
+                    t = rest.trim_start();
+                }
+            }
+        }
+        if t == before {
+            break;
+        }
+    }
+    let t = t.trim_end_matches(':').trim();
+    if let Some(r) = t
+        .strip_prefix("->")
+        .or_else(|| t.strip_prefix("::"))
+        .or_else(|| t.strip_prefix(':'))
+    {
+        return Some(r.trim().to_string());
+    }
+    let bare = match style {
+        ParamStyle::NameType => true,
+        ParamStyle::NameColonType => lang == Language::Zig,
+        ParamStyle::TypeName => false,
+    };
+    (bare && !t.is_empty()).then(|| t.to_string())
+}
+
+/// Return type written before the name in `Type name(...)` languages.
+fn leading_return(prefix: &str) -> Option<String> {
+    let mut p = prefix.trim_end();
+    // `int Foo::bar(` — drop the qualifier of an out-of-line definition.
+    while let Some(head) = p.strip_suffix("::") {
+        let cut = head.trim_end_matches(|c: char| c.is_alphanumeric() || c == '_');
+        p = cut.trim_end();
+    }
+    let tokens: Vec<&str> = p
+        .split_whitespace()
+        .filter(|t| !t.starts_with('@') && !DECL_MODIFIERS.contains(t))
+        .collect();
+    let mut ty = tokens.join(" ");
+    // Java / C# method type parameters: `<T> List<T>`.
+    if ty.starts_with('<') {
+        if let Some(close) = matching_close(&ty, 0) {
+            ty = ty[close + 1..].trim().to_string();
+        }
+    }
+    (!ty.is_empty()).then_some(ty)
+}
+
+/// Collapse whitespace in a type to the spaces that separate two words
+/// (`unsigned int`, `chan int`) or follow a comma, and, for Rust, drop
+/// lifetimes (`&'a str` → `&str`).
+fn normalize_type(raw: &str, strip_lifetimes: bool) -> String {
+    let mut out = String::with_capacity(raw.len());
+    let mut pending_space = false;
+    let mut chars = raw.trim().chars().peekable();
+    while let Some(c) = chars.next() {
+        if strip_lifetimes && c == '\'' && chars.peek().is_some_and(|n| n.is_alphabetic()) {
+            while chars
+                .peek()
+                .is_some_and(|n| n.is_alphanumeric() || *n == '_')
+            {
+                chars.next();
+            }
+            while chars.peek().is_some_and(|n| n.is_whitespace()) {
+                chars.next();
+            }
+            continue;
+        }
+        if c.is_whitespace() {
+            pending_space = true;
+            continue;
+        }
+        if c == ',' {
+            out.push_str(", ");
+            pending_space = false;
+            continue;
+        }
+        if pending_space && out.chars().last().is_some_and(is_ident_char) && is_ident_char(c) {
+            out.push(' ');
+        }
+        pending_space = false;
+        out.push(c);
+    }
+    out
+}
+
+fn is_ident_char(c: char) -> bool {
+    c.is_alphanumeric() || c == '_' || c == '$'
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn shape(sig: &str, name: &str, lang: Language) -> String {
+        SignatureShape::extract(sig, name, lang)
+            .unwrap_or_else(|| panic!("no shape for {sig:?}"))
+            .to_string()
+    }
+
+    #[test]
+    fn extracts_shapes_across_param_styles() {
+        assert_eq!(
+            shape(
+                "func (s *Server) Write(ctx context.Context, data []byte) error",
+                "Write",
+                Language::Go
+            ),
+            "(context.Context, []byte) -> error"
+        );
+        assert_eq!(
+            shape(
+                "func Copy(dst, src []byte) (int, error)",
+                "Copy",
+                Language::Go
+            ),
+            "([]byte, []byte) -> (int, error)"
+        );
+        assert_eq!(
+            shape(
+                "pub(crate) fn load<'a>(&self, path: &'a Path, retries: u32) -> Result<Config, Error>",
+                "load",
+                Language::Rust
+            ),
+            "(&Path, u32) -> Result<Config, Error>"
+        );
+        assert_eq!(
+            shape(
+                "def fetch(self, url: str, timeout=5) -> bytes",
+                "fetch",
+                Language::Python
+            ),
+            "(str, _) -> bytes"
+        );
+        assert_eq!(
+            shape(
+                "public static <T> List<T> parse(final String s, int[] radix) throws IOException",
+                "parse",
+                Language::Java
+            ),
+            "(String, int[]) -> List<T>"
+        );
+        assert_eq!(
+            shape(
+                "static inline const char *name_of(int id, void *ctx)",
+                "name_of",
+                Language::C
+            ),
+            "(int, void*) -> const char*"
+        );
+        assert_eq!(
+            shape(
+                "export async function send(msg: Message, opts?: Options): Promise<void>",
+                "send",
+                Language::TypeScript
+            ),
+            "(Message, Options) -> Promise<void>"
+        );
+        assert_eq!(shape("int main(void)", "main", Language::C), "() -> int");
+    }
+
+    #[test]
+    fn untyped_languages_have_no_shape() {
+        assert!(
+            SignatureShape::extract("function send(msg)", "send", Language::JavaScript).is_none()
+        );
+        assert!(SignatureShape::extract("fn other()", "missing", Language::Rust).is_none());
+    }
+
+    #[test]
+    fn canonical_form_round_trips() {
+        let s = SignatureShape::extract(
+            "func Copy(dst, src []byte) (int, error)",
+            "Copy",
+            Language::Go,
+        )
+        .unwrap();
+        assert_eq!(SignatureShape::parse(&s.to_string()), Some(s));
+    }
+
+    #[test]
+    fn pattern_matching() {
+        let go = SignatureShape::parse("(context.Context, []byte) -> error").unwrap();
+        let p = |s: &str| SignaturePattern::parse(s).unwrap();
+        assert!(p("(context.Context, []byte) error").matches(&go));
+        assert!(
+            p("(Context, []byte) -> error").matches(&go),
+            "unqualified type"
+        );
+        assert!(
+            p("(context.Context, _)").matches(&go),
+            "wildcard, any return"
+        );
+        assert!(p("(context.Context, ..)").matches(&go), "open-ended");
+        assert!(!p("(context.Context)").matches(&go), "arity");
+        assert!(!p("([]byte, context.Context) error").matches(&go), "order");
+        assert!(!p("(context.Context, []byte) -> bool").matches(&go));
+        assert!(
+            !p("(text.Context, []byte)").matches(&go),
+            "qualifier must match"
+        );
+
+        let unit = SignatureShape::parse("(u32)").unwrap();
+        assert!(p("(u32) -> ()").matches(&unit));
+        assert!(p("(u32)").matches(&unit));
+    }
+
+    #[test]
+    fn malformed_patterns_are_rejected() {
+        assert!(SignaturePattern::parse("int, string").is_err());
+        assert!(SignaturePattern::parse("(int, string").is_err());
+        assert!(SignaturePattern::parse("(.., int)").is_err());
+    }
+}
@@ -1,6 +1,6 @@
 //! Data types for the parser module
 
-use serde::Serialize;
+use serde::{Deserialize, Serialize};
 use std::path::PathBuf;
 use thiserror::Error;
 
@@ -29,7 +29,7 @@ pub enum ParserError {
 /// A parsed code chunk (function, method, class, etc.)
 /// Chunks are the basic unit of indexing and search in cqs.
 /// Each chunk represents a single code element extracted by tree-sitter.
-#[derive(Debug, Clone)]
+#[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct Chunk {
     /// Unique identifier: `{file}:{line_start}:{byte_start}:{hash8}` (see
     /// `parser::chunk::chunk_id`), with a structural suffix for chunks that
@@ -97,13 +97,16 @@ pub struct Chunk {
 /// `Call` is the default and the overwhelming majority — it is the
 /// skip-when-default value, so a present `edge_kind` always signals a
 /// non-syntactic edge worth extra scrutiny.
-#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Default, schemars::JsonSchema)]
+#[derive(
+    Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize, Default, schemars::JsonSchema,
+)]
 // schemars-only snake_case so the SCHEMA matches the stored / wire strings
-// (`serde_callback`, `macro_heuristic`, `fn_pointer`, `doc_reference`) that the
-// `parse_edge_kind` deserializer accepts. Scoped to schemars (not serde) so the
-// derived `Serialize` stays untouched — every output struct already routes the
-// JSON through `serialize_edge_kind` / `as_str`, so this only shapes the input
-// schema and leaves the (unused) derived `Serialize` byte-identical.
+// (`serde_callback`, `ffi_boundary`, `macro_heuristic`, `fn_pointer`,
+// `doc_reference`) that the `parse_edge_kind` deserializer accepts. Scoped to
+// schemars (not serde) so the derived `Serialize` stays untouched — every
+// output struct already routes the JSON through `serialize_edge_kind` /
+// `as_str`, so this only shapes the input schema and leaves the (unused)
+// derived `Serialize` byte-identical.
 #[schemars(rename_all = "snake_case")]
 pub enum CallEdgeKind {
     /// Syntactic `call_expression` — ground truth.
@@ -112,6 +115,12 @@ pub enum CallEdgeKind {
     /// serde string-callback attribute (`#[serde(with = "...")]` etc.) — the
     /// attribute grammar is explicit, high confidence.
     SerdeCallback,
+    /// A binding across a language boundary whose two sides are named
+    /// differently: a Rust `#[export_name]` / `#[link_name]` symbol, a cgo
+    /// `C.struct_x` reference, or a protobuf rpc / service / message and the
+    /// stub or struct its codegen emits (`parser::ffi`). The name graph links
+    /// same-named FFI pairs on its own; these edges carry it across a rename.
+    FfiBoundary,
     /// `ident(`-shape match inside an opaque Rust macro token-tree —
     /// heuristic, no semantic resolution.
     MacroHeuristic,
@@ -140,25 +149,28 @@ pub enum CallEdgeKind {
 /// Rank order, best (most trusted) to worst:
 /// 0 `call` — syntactic call expression, ground truth.
 /// 1 `serde_callback` — explicit attribute grammar, trusted.
-/// 2 `macro_heuristic` — `ident(`-shape inside an opaque macro token-tree.
-/// 3 `fn_pointer` — bare name in argument position, intra-file precision only.
-/// 4 `doc_reference` — prose mention, weakest of all.
+/// 2 `ffi_boundary` — link attribute or codegen naming rule, trusted.
+/// 3 `macro_heuristic` — `ident(`-shape inside an opaque macro token-tree.
+/// 4 `fn_pointer` — bare name in argument position, intra-file precision only.
+/// 5 `doc_reference` — prose mention, weakest of all.
 const fn call_edge_trust_rank(kind: CallEdgeKind) -> u8 {
     match kind {
         CallEdgeKind::Call => 0,
         CallEdgeKind::SerdeCallback => 1,
-        CallEdgeKind::MacroHeuristic => 2,
-        CallEdgeKind::FnPointer => 3,
-        CallEdgeKind::DocReference => 4,
+        CallEdgeKind::FfiBoundary => 2,
+        CallEdgeKind::MacroHeuristic => 3,
+        CallEdgeKind::FnPointer => 4,
+        CallEdgeKind::DocReference => 5,
     }
 }
 
 impl CallEdgeKind {
     /// Every variant, in trust-rank order. Single source for SQL-list
     /// generators so a new kind cannot drift out of sync with the queries.
-    pub const ALL: [CallEdgeKind; 5] = [
+    pub const ALL: [CallEdgeKind; 6] = [
         CallEdgeKind::Call,
         CallEdgeKind::SerdeCallback,
+        CallEdgeKind::FfiBoundary,
         CallEdgeKind::MacroHeuristic,
         CallEdgeKind::FnPointer,
         CallEdgeKind::DocReference,
@@ -169,6 +181,7 @@ impl CallEdgeKind {
         match self {
             CallEdgeKind::Call => "call",
             CallEdgeKind::SerdeCallback => "serde_callback",
+            CallEdgeKind::FfiBoundary => "ffi_boundary",
             CallEdgeKind::MacroHeuristic => "macro_heuristic",
             CallEdgeKind::FnPointer => "fn_pointer",
             CallEdgeKind::DocReference => "doc_reference",
@@ -182,6 +195,7 @@ impl CallEdgeKind {
     pub fn from_str_or_default(s: &str) -> Self {
         match s {
             "serde_callback" => CallEdgeKind::SerdeCallback,
+            "ffi_boundary" => CallEdgeKind::FfiBoundary,
             "macro_heuristic" => CallEdgeKind::MacroHeuristic,
             "fn_pointer" => CallEdgeKind::FnPointer,
             "doc_reference" => CallEdgeKind::DocReference,
@@ -199,12 +213,15 @@ impl CallEdgeKind {
     }
 
     /// Whether this edge proves the callee genuinely live — a syntactic call or
-    /// a trusted serde callback. A function with at least one trusted edge is
+    /// a trusted serde callback or FFI boundary. A function with at least one trusted edge is
     /// never dead and never `low-confidence-live`. Heuristic and doc-reference
     /// edges do NOT count: doc references are prose, so a doc mention cannot
     /// disqualify a function from `low-confidence-live`.
     pub fn is_trusted(&self) -> bool {
-        matches!(self, CallEdgeKind::Call | CallEdgeKind::SerdeCallback)
+        matches!(
+            self,
+            CallEdgeKind::Call | CallEdgeKind::SerdeCallback | CallEdgeKind::FfiBoundary
+        )
     }
 
     /// Whether this edge is an actual invocation of the callee — a real caller
@@ -239,7 +256,7 @@ impl CallEdgeKind {
     }
 
     /// Comma-separated quoted SQL string list of the `is_trusted()` kinds,
-    /// e.g. `'call', 'serde_callback'`. Generated from the enum (single source).
+    /// e.g. `'call', 'serde_callback', 'ffi_boundary'`. Generated from the enum (single source).
     pub fn trusted_kinds_sql() -> String {
         Self::ALL
             .iter()
@@ -293,7 +310,7 @@ impl std::fmt::Display for CallEdgeKind {
 }
 
 /// A function call site extracted from code
-#[derive(Debug, Clone)]
+#[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct CallSite {
     /// Name of the called function/method
     pub callee_name: String,
@@ -305,7 +322,7 @@ pub struct CallSite {
 }
 
 /// A function with its call sites (for full call graph coverage)
-#[derive(Debug, Clone)]
+#[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct FunctionCalls {
     /// Function name
     pub name: String,
@@ -325,7 +342,7 @@ pub struct FunctionCalls {
 /// cannot move a callee into the wrong dead tier the way a new
 /// [`CallEdgeKind`] variant would (that enum's `is_real_caller` is the
 /// complement of `doc_reference`, so any new kind defaults to "real caller").
-#[derive(Debug, Clone)]
+#[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct CandidateSite {
     /// Source file the candidate reference lives in.
     pub file: PathBuf,
@@ -340,7 +357,7 @@ pub struct CandidateSite {
 /// Classification of how a type is referenced in code.
 /// Used for type-level dependency tracking.
 /// Stored as string in SQLite `type_edges.edge_kind` column.
-#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize)]
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
 pub enum TypeEdgeKind {
     /// Function/method parameter type: `fn foo(x: Config)`
     Param,
@@ -404,7 +421,7 @@ impl std::str::FromStr for TypeEdgeKind {
 /// A type reference extracted from source code.
 /// Captured by tree-sitter type queries with classified edge kinds.
 /// The catch-all pattern captures types inside generics with `kind = None`.
-#[derive(Debug, Clone, PartialEq, Eq)]
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
 pub struct TypeRef {
     /// Name of the referenced type (e.g., "Config", "Store", "SqlitePool")
     pub type_name: String,
@@ -417,7 +434,7 @@ pub struct TypeRef {
 /// A code element with its type references (for full-file type graph).
 /// One entry per chunk (function/struct/enum/trait/class) in a file.
 /// Produced by `Parser::parse_file_relationships()`.
-#[derive(Debug, Clone)]
+#[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct ChunkTypeRefs {
     /// Chunk name (function/struct/enum/trait/class)
     pub name: String,
@@ -448,6 +465,10 @@ mod tests {
             serde_json::to_string(&CallEdgeKind::SerdeCallback).unwrap(),
             "\"SerdeCallback\""
         );
+        assert_eq!(
+            serde_json::to_string(&CallEdgeKind::FfiBoundary).unwrap(),
+            "\"FfiBoundary\""
+        );
         assert_eq!(
             serde_json::to_string(&CallEdgeKind::MacroHeuristic).unwrap(),
             "\"MacroHeuristic\""
@@ -492,6 +513,7 @@ mod tests {
     fn is_real_caller_excludes_only_doc_reference() {
         assert!(CallEdgeKind::Call.is_real_caller());
         assert!(CallEdgeKind::SerdeCallback.is_real_caller());
+        assert!(CallEdgeKind::FfiBoundary.is_real_caller());
         assert!(CallEdgeKind::MacroHeuristic.is_real_caller());
         assert!(CallEdgeKind::FnPointer.is_real_caller());
         assert!(!CallEdgeKind::DocReference.is_real_caller());
@@ -505,6 +527,7 @@ mod tests {
         let sql = CallEdgeKind::real_caller_kinds_sql();
         assert!(sql.contains("'call'"));
         assert!(sql.contains("'serde_callback'"));
+        assert!(sql.contains("'ffi_boundary'"));
         assert!(sql.contains("'macro_heuristic'"));
         assert!(sql.contains("'fn_pointer'"));
         assert!(
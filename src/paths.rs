@@ -0,0 +1,246 @@
+//! Path handling for case-insensitive and Windows filesystems.
+//!
+//! Origins are stored project-relative with forward slashes (see
+//! [`crate::normalize_path`]). Three things break that invariant on Windows
+//! and, for case, on default macOS volumes:
+//!
+//! - **Case.** NTFS and APFS/HFS+ match `Src\Lib.rs` and `src/lib.rs` to the
+//!   same file, but `Path::strip_prefix` compares bytes. A watch event or an
+//!   `--only` path whose case differs from the canonical root is either
+//!   dropped or indexed as a second origin. [`strip_root`] compares the root
+//!   case-insensitively where the filesystem does, and [`on_disk_case`]
+//!   rewrites a relative origin to the casing the filesystem reports.
+//! - **Separators.** Handled by [`crate::normalize_slashes`]; store read
+//!   APIs route caller-supplied origins through it so a `src\lib.rs` lookup
+//!   finds the `src/lib.rs` rows written at index time.
+//! - **Length.** Win32 APIs reject paths of 260+ characters unless they
+//!   carry the `\\?\` verbatim prefix. [`long_path`] adds it for file reads
+//!   and writes under deep trees.
+
+use std::borrow::Cow;
+use std::path::{Component, Path, PathBuf};
+
+/// Whether path comparisons should ignore ASCII case on this platform.
+/// Matches the default filesystem: NTFS and APFS/HFS+ are case-insensitive,
+/// Linux filesystems are not.
+pub const CASE_INSENSITIVE_FS: bool = cfg!(any(windows, target_os = "macos"));
+
+/// Win32 `MAX_PATH` minus room for an 8.3 file name; directory APIs fail
+/// past this, so [`long_path`] prefixes anything at or above it.
+const WIN_LONG_PATH_THRESHOLD: usize = 248;
+
+/// Strip `root` from `path`, ignoring ASCII case on case-insensitive
+/// filesystems. Falls back to [`Path::strip_prefix`] semantics elsewhere.
+pub fn strip_root<'a>(path: &'a Path, root: &Path) -> Option<&'a Path> {
+    strip_root_with(path, root, CASE_INSENSITIVE_FS)
+}
+
+fn strip_root_with<'a>(path: &'a Path, root: &Path, ignore_case: bool) -> Option<&'a Path> {
+    if let Ok(rel) = path.strip_prefix(root) {
+        return Some(rel);
+    }
+    if !ignore_case {
+        return None;
+    }
+    let mut components = path.components();
+    for want in root.components() {
+        let got = components.next()?;
+        if !component_eq_ignore_case(got, want) {
+            return None;
+        }
+    }
+    Some(components.as_path())
+}
+
+fn component_eq_ignore_case(a: Component<'_>, b: Component<'_>) -> bool {
+    match (a.as_os_str().to_str(), b.as_os_str().to_str()) {
+        (Some(a), Some(b)) => a.eq_ignore_ascii_case(b),
+        _ => a == b,
+    }
+}
+
+/// Relativize `path` against `root` with [`strip_root`] and normalize it to
+/// a forward-slash origin string. `None` when `path` is outside `root`.
+pub fn origin_of(path: &Path, root: &Path) -> Option<String> {
+    strip_root(path, root).map(crate::normalize_path)
+}
+
+/// Rewrite the project-relative `rel` to the casing the filesystem reports,
+/// so `SRC/Lib.rs` typed on Windows indexes as the existing `src/lib.rs`
+/// origin instead of a duplicate. Returns `rel` unchanged when the file does
+/// not exist (a deleted file keeps the casing it was reported with) or on
+/// case-sensitive filesystems, where a case mismatch is a different file.
+pub fn on_disk_case(root: &Path, rel: &Path) -> PathBuf {
+    if !cfg!(windows) {
+        return rel.to_path_buf();
+    }
+    let (Ok(canon_root), Ok(canon)) = (
+        dunce::canonicalize(root),
+        dunce::canonicalize(root.join(rel)),
+    ) else {
+        return rel.to_path_buf();
+    };
+    match strip_root(&canon, &canon_root) {
+        Some(resolved) if !resolved.as_os_str().is_empty() => resolved.to_path_buf(),
+        _ => rel.to_path_buf(),
+    }
+}
+
+/// Prefix an absolute Windows path with `\\?\` (or `\\?\UNC\` for shares)
+/// once it is long enough to trip `MAX_PATH`. Borrowed unchanged on other
+/// platforms, for relative paths, and for paths already in verbatim form.
+pub fn long_path(path: &Path) -> Cow<'_, Path> {
+    if !cfg!(windows) {
+        return Cow::Borrowed(path);
+    }
+    match path.to_str().and_then(verbatim_long_path) {
+        Some(long) => Cow::Owned(PathBuf::from(long)),
+        None => Cow::Borrowed(path),
+    }
+}
+
+/// String form of [`long_path`], kept platform-independent so the rules are
+/// tested on every CI runner. Verbatim paths skip Win32 normalization, so
+/// the result uses backslashes only and is refused for paths with `.`/`..`
+/// segments, which would no longer be resolved.
+fn verbatim_long_path(path: &str) -> Option<String> {
+    if path.len() < WIN_LONG_PATH_THRESHOLD || path.starts_with(r"\\?\") {
+        return None;
+    }
+    let backslashed = path.replace('/', "\\");
+    if backslashed
+        .split('\\')
+        .any(|segment| segment == "." || segment == "..")
+    {
+        return None;
+    }
+    if let Some(share) = backslashed.strip_prefix(r"\\") {
+        return Some(format!(r"\\?\UNC\{share}"));
+    }
+    let bytes = backslashed.as_bytes();
+    let is_drive_absolute =
+        bytes.len() >= 3 && bytes[0].is_ascii_alphabetic() && bytes[1] == b':' && bytes[2] == b'\\';
+    is_drive_absolute.then(|| format!(r"\\?\{backslashed}"))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn strip_root_exact_match_on_every_platform() {
+        let rel = strip_root(Path::new("/proj/src/lib.rs"), Path::new("/proj")).unwrap();
+        assert_eq!(rel, Path::new("src/lib.rs"));
+        assert!(strip_root(Path::new("/other/lib.rs"), Path::new("/proj")).is_none());
+    }
+
+    #[test]
+    fn strip_root_ignores_case_only_when_asked() {
+        let path = Path::new("/Users/Me/Proj/src/lib.rs");
+        let root = Path::new("/users/me/proj");
+        assert_eq!(
+            strip_root_with(path, root, true).unwrap(),
+            Path::new("src/lib.rs")
+        );
+        assert!(strip_root_with(path, root, false).is_none());
+        // Case folding never lets a sibling directory through.
+        assert!(strip_root_with(Path::new("/users/me/proj2/a.rs"), root, true).is_none());
+    }
+
+    #[test]
+    fn verbatim_long_path_rules() {
+        let short = r"C:\proj\src\lib.rs";
+        assert_eq!(verbatim_long_path(short), None);
+
+        let deep = format!(r"C:\proj\{}\lib.rs", "d".repeat(WIN_LONG_PATH_THRESHOLD));
+        assert_eq!(verbatim_long_path(&deep), Some(format!(r"\\?\{deep}")));
+
+        let mixed = deep.replace('\\', "/");
+        assert_eq!(verbatim_long_path(&mixed), Some(format!(r"\\?\{deep}")));
+
+        let unc = format!(r"\\server\share\{}", "d".repeat(WIN_LONG_PATH_THRESHOLD));
+        assert_eq!(
+            verbatim_long_path(&unc),
+            Some(format!(
+                r"\\?\UNC\server\share\{}",
+                "d".repeat(WIN_LONG_PATH_THRESHOLD)
+            ))
+        );
+
+        // Already verbatim, relative, or carrying `..` → left alone.
+        assert_eq!(verbatim_long_path(&format!(r"\\?\{deep}")), None);
+        assert_eq!(verbatim_long_path(&"d/".repeat(200)), None);
+        let dotted = format!(r"C:\proj\..\{}", "d".repeat(WIN_LONG_PATH_THRESHOLD));
+        assert_eq!(verbatim_long_path(&dotted), None);
+    }
+
+    #[cfg(not(windows))]
+    #[test]
+    fn long_path_is_identity_off_windows() {
+        let deep = format!("/proj/{}/lib.rs", "d".repeat(300));
+        assert!(matches!(long_path(Path::new(&deep)), Cow::Borrowed(_)));
+    }
+
+    #[cfg(windows)]
+    mod windows {
+        use super::super::*;
+
+        #[test]
+        fn strip_root_mixed_case_and_separators() {
+            let rel = strip_root(
+                Path::new(r"C:\Users\Dev\Proj\Src\Lib.rs"),
+                Path::new(r"c:\users\dev\proj"),
+            )
+            .unwrap();
+            assert_eq!(crate::normalize_path(rel), "Src/Lib.rs");
+            assert_eq!(
+                origin_of(
+                    Path::new(r"C:/Users/Dev/Proj/src/lib.rs"),
+                    Path::new(r"C:\Users\Dev\Proj")
+                )
+                .as_deref(),
+                Some("src/lib.rs")
+            );
+        }
+
+        #[test]
+        fn on_disk_case_resolves_existing_file() {
+            let dir = tempfile::TempDir::new().unwrap();
+            std::fs::create_dir(dir.path().join("src")).unwrap();
+            std::fs::write(dir.path().join("src").join("lib.rs"), "fn a() {}").unwrap();
+            let resolved = on_disk_case(dir.path(), Path::new(r"SRC\Lib.RS"));
+            assert_eq!(crate::normalize_path(&resolved), "src/lib.rs");
+            // Missing files keep the casing they were given.
+            let missing = on_disk_case(dir.path(), Path::new(r"src\Gone.rs"));
+            assert_eq!(crate::normalize_path(&missing), "src/Gone.rs");
+        }
+
+        #[test]
+        fn long_path_reads_deep_file() {
+            let dir = tempfile::TempDir::new().unwrap();
+            let mut deep = dunce::canonicalize(dir.path()).unwrap();
+            while deep.as_os_str().len() < 300 {
+                deep.push("a".repeat(40));
+            }
+            std::fs::create_dir_all(long_path(&deep)).unwrap();
+            let file = deep.join("lib.rs");
+            std::fs::write(long_path(&file), "fn deep() {}").unwrap();
+            assert_eq!(
+                std::fs::read_to_string(long_path(&file)).unwrap(),
+                "fn deep() {}"
+            );
+        }
+
+        #[test]
+        fn normalize_path_strips_verbatim_and_backslashes() {
+            assert_eq!(
+                crate::normalize_path(Path::new(r"\\?\C:\proj\src\lib.rs")),
+                "C:/proj/src/lib.rs"
+            );
+            assert_eq!(
+                crate::normalize_slashes(r"src\cli\mod.rs"),
+                "src/cli/mod.rs"
+            );
+        }
+    }
+}
@@ -6,8 +6,10 @@
 //! - macOS: `~/Library/Application Support/cqs/projects.toml`
 //! - Windows: `%APPDATA%\cqs\projects.toml`
 //!
-//! Enables searching across all registered projects from anywhere.
+//! Enables searching across all registered projects from anywhere, or
+//! across a named subset of them ([`search_federated`], `--index`).
 
+use std::collections::{HashMap, HashSet};
 use std::path::{Path, PathBuf};
 use std::sync::atomic::{AtomicBool, Ordering};
 
@@ -33,6 +35,8 @@ pub enum ProjectError {
     FileTooLarge(String),
     #[error("No projects registered")]
     NoProjects,
+    #[error("Embedding failed: {0}")]
+    Embedder(#[from] crate::embedder::EmbedderError),
 }
 
 /// Whether the WSL advisory locking warning has been emitted (once per process)
@@ -52,6 +56,59 @@ pub struct ProjectEntry {
     pub path: PathBuf,
 }
 
+impl ProjectEntry {
+    /// The project's index database. Prefers .cqs (with slot resolution),
+    /// falls back to legacy .cq. `resolve_index_db` handles
+    /// slots/<active>/index.db AND pre-slots `.cqs/index.db` for unmigrated
+    /// entries.
+    pub fn index_path(&self) -> PathBuf {
+        let cqs_dir = self.path.join(".cqs");
+        if cqs_dir.exists() {
+            crate::resolve_index_db(&cqs_dir)
+        } else {
+            self.path.join(".cq/index.db")
+        }
+    }
+}
+
+/// How current a registered project's index is, for `cqs project list`.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+pub struct ProjectFreshness {
+    /// Files in the index.
+    pub files: u64,
+    /// Indexed files changed on disk since they were indexed.
+    pub stale: u64,
+    /// Indexed files gone from disk.
+    pub missing: u64,
+    /// Last write to the index database, Unix seconds.
+    pub indexed_at: Option<i64>,
+}
+
+/// Check a project's index against its files on disk. `Ok(None)` when the
+/// project has no index.
+pub fn project_freshness(entry: &ProjectEntry) -> Result<Option<ProjectFreshness>, anyhow::Error> {
+    let _span = tracing::debug_span!("project_freshness", project = %entry.name).entered();
+    let index_path = entry.index_path();
+    if !index_path.exists() {
+        return Ok(None);
+    }
+    let indexed_at = std::fs::metadata(&index_path)
+        .and_then(|m| m.modified())
+        .ok()
+        .and_then(|t| t.duration_since(std::time::UNIX_EPOCH).ok())
+        .map(|d| d.as_secs() as i64);
+    let store = crate::Store::open_readonly(&index_path)?;
+    // No enumerated file set: existence falls back to a per-origin stat,
+    // which is what a list of a few projects can afford.
+    let report = store.list_stale_files(&HashSet::new(), &entry.path)?;
+    Ok(Some(ProjectFreshness {
+        files: report.total_indexed,
+        stale: report.stale.len() as u64,
+        missing: report.missing.len() as u64,
+        indexed_at,
+    }))
+}
+
 impl ProjectRegistry {
     /// Load registry from default location (~/.config/cqs/projects.toml)
     pub fn load() -> Result<Self, ProjectError> {
@@ -197,6 +254,15 @@ impl ProjectRegistry {
     pub fn get(&self, name: &str) -> Option<&ProjectEntry> {
         self.project.iter().find(|p| p.name == name)
     }
+
+    /// Like [`Self::get`], but an unknown name is a
+    /// [`ProjectError::NotFound`] that lists the registered ones.
+    pub fn lookup(&self, name: &str) -> Result<&ProjectEntry, ProjectError> {
+        self.get(name).ok_or_else(|| {
+            let known: Vec<&str> = self.project.iter().map(|p| p.name.as_str()).collect();
+            ProjectError::NotFound(format!("{name} (registered: {})", known.join(", ")))
+        })
+    }
 }
 
 /// Get the registry file path.
@@ -218,7 +284,11 @@ pub struct CrossProjectResult {
     pub file: PathBuf,
     pub line_start: u32,
     pub signature: Option<String>,
+    /// Ranking score. Equal to `raw_score` unless [`search_federated`]
+    /// normalized it across embedding models.
     pub score: f32,
+    /// Similarity as the project's own store scored it.
+    pub raw_score: f32,
 }
 
 /// Search across all registered projects.
@@ -242,6 +312,187 @@ pub fn search_across_projects(
         return Err(ProjectError::NoProjects);
     }
 
+    let targets: Vec<(&ProjectEntry, &crate::Embedding)> = registry
+        .project
+        .iter()
+        .map(|entry| (entry, query_embedding))
+        .collect();
+    let mut all_results = search_entries(&targets, filter, limit, threshold);
+    rank_and_truncate(&mut all_results, limit);
+
+    tracing::info!(
+        result_count = all_results.len(),
+        "Cross-project search complete"
+    );
+
+    Ok(all_results)
+}
+
+/// Federated search over the registered projects named in `names` (every
+/// registered project when empty): query each store, normalize, merge, and
+/// label every result with its project.
+///
+/// Each project is queried with the model its index was built with, read
+/// from the store's metadata. `embedder` serves every project on its model;
+/// projects on another preset model get a query embedding of their own, and
+/// projects on a model that can't be resolved are skipped with a warning.
+/// Cosine scores from one model share a scale, so a single-model federation
+/// ranks by raw score. Scores from different models don't, so once more
+/// than one model is involved each model's results are min-max normalized
+/// to `[0, 1]` before the merge (`raw_score` keeps the original).
+pub fn search_federated(
+    names: &[String],
+    query: &str,
+    embedder: &crate::Embedder,
+    filter: &crate::SearchFilter,
+    limit: usize,
+    threshold: f32,
+) -> Result<Vec<CrossProjectResult>, ProjectError> {
+    let registry = ProjectRegistry::load()?;
+    let _span = tracing::info_span!("search_federated", indexes = names.len(), limit).entered();
+    if registry.project.is_empty() {
+        return Err(ProjectError::NoProjects);
+    }
+    let entries = select_projects(&registry, names)?;
+
+    // Model per project, then one query embedding per model.
+    let own_model = embedder.model_config().repo.clone();
+    let mut model_of: HashMap<&str, String> = HashMap::new();
+    for entry in &entries {
+        match project_model(entry) {
+            Ok(model) => {
+                model_of.insert(
+                    entry.name.as_str(),
+                    model.unwrap_or_else(|| own_model.clone()),
+                );
+            }
+            Err(e) => tracing::warn!(project = %entry.name, error = %e, "Skipping project"),
+        }
+    }
+    let mut embeddings: HashMap<String, crate::Embedding> = HashMap::new();
+    for model in model_of.values().collect::<HashSet<_>>() {
+        let embedding = if *model == own_model {
+            embedder.embed_query(query)?
+        } else if let Some(cfg) = crate::embedder::ModelConfig::from_preset(model) {
+            crate::Embedder::new(cfg)?.embed_query(query)?
+        } else {
+            tracing::warn!(model = %model, "Unknown embedding model, skipping its projects");
+            continue;
+        };
+        embeddings.insert(model.clone(), embedding);
+    }
+
+    let targets: Vec<(&ProjectEntry, &crate::Embedding)> = entries
+        .iter()
+        .filter_map(|entry| {
+            let model = model_of.get(entry.name.as_str())?;
+            Some((*entry, embeddings.get(model)?))
+        })
+        .collect();
+    let mut results = search_entries(&targets, filter, limit, threshold);
+    normalize_across_models(&mut results, &model_of);
+    rank_and_truncate(&mut results, limit);
+
+    tracing::info!(
+        result_count = results.len(),
+        models = embeddings.len(),
+        "Federated search complete"
+    );
+    Ok(results)
+}
+
+/// The registered projects named in `names`, in that order (all of them
+/// when empty). Unknown names are an error that lists the registered ones.
+fn select_projects<'a>(
+    registry: &'a ProjectRegistry,
+    names: &[String],
+) -> Result<Vec<&'a ProjectEntry>, ProjectError> {
+    if names.is_empty() {
+        return Ok(registry.project.iter().collect());
+    }
+    let mut seen = HashSet::new();
+    let mut entries = Vec::new();
+    for name in names {
+        if !seen.insert(name.as_str()) {
+            continue;
+        }
+        entries.push(registry.lookup(name)?);
+    }
+    Ok(entries)
+}
+
+/// Embedding model recorded in a project's index, `None` for indexes that
+/// predate the record.
+fn project_model(entry: &ProjectEntry) -> Result<Option<String>, anyhow::Error> {
+    let index_path = entry.index_path();
+    if !index_path.exists() {
+        anyhow::bail!("index not found at {}", index_path.display());
+    }
+    let store = crate::Store::open_readonly(&index_path)?;
+    Ok(store.stored_model_name())
+}
+
+/// Min-max normalize each model's scores to `[0, 1]` when results come from
+/// more than one embedding model. A no-op for a single model.
+fn normalize_across_models(results: &mut [CrossProjectResult], model_of: &HashMap<&str, String>) {
+    let model = |r: &CrossProjectResult| model_of.get(r.project_name.as_str()).cloned();
+    let models: HashSet<Option<String>> = results.iter().map(model).collect();
+    if models.len() < 2 {
+        return;
+    }
+    for m in models {
+        let (lo, hi) = results
+            .iter()
+            .filter(|r| model(r) == m)
+            .fold((f32::INFINITY, f32::NEG_INFINITY), |(lo, hi), r| {
+                (lo.min(r.raw_score), hi.max(r.raw_score))
+            });
+        for r in results.iter_mut().filter(|r| model(r) == m) {
+            r.score = if hi > lo {
+                (r.raw_score - lo) / (hi - lo)
+            } else {
+                1.0
+            };
+        }
+    }
+}
+
+/// Sort by score descending and keep the top `limit`. The secondary sort on
+/// (raw score, project, file, line_start, name) keeps equal-score results
+/// deterministically ordered across process invocations so the truncate()
+/// picks the same survivors on every run.
+fn rank_and_truncate(results: &mut Vec<CrossProjectResult>, limit: usize) {
+    results.sort_by(|a, b| {
+        b.score
+            .total_cmp(&a.score)
+            .then(b.raw_score.total_cmp(&a.raw_score))
+            .then(a.project_name.cmp(&b.project_name))
+            .then(a.file.cmp(&b.file))
+            .then(a.line_start.cmp(&b.line_start))
+            .then(a.name.cmp(&b.name))
+    });
+    results.truncate(limit);
+}
+
+/// Search each `(project, query embedding)` pair and pool the results.
+/// Projects that fail are logged and skipped.
+fn search_entries(
+    targets: &[(&ProjectEntry, &crate::Embedding)],
+    filter: &crate::SearchFilter,
+    limit: usize,
+    threshold: f32,
+) -> Vec<CrossProjectResult> {
+    let search =
+        |&(entry, embedding): &(&ProjectEntry, &crate::Embedding)| match search_single_project(
+            entry, embedding, filter, limit, threshold,
+        ) {
+            Ok(v) => Some(v),
+            Err(e) => {
+                tracing::warn!(project = %entry.name, error = %e, "Search failed for project");
+                None
+            }
+        };
+
     // Cap concurrency to bound memory — each project opens its own
     // Store + HNSW (~200 MB resident per project on cqs-sized corpora). With
     // N projects loaded in parallel, peak RSS scales as N × 200 MB, so the
@@ -267,82 +518,17 @@ pub fn search_across_projects(
                 .unwrap_or(1)
                 .min(8)
         });
-    let pool = match rayon::ThreadPoolBuilder::new().num_threads(threads).build() {
-        Ok(p) => p,
+    let project_results: Vec<Vec<CrossProjectResult>> = match rayon::ThreadPoolBuilder::new()
+        .num_threads(threads)
+        .build()
+    {
+        Ok(pool) => pool.install(|| targets.par_iter().filter_map(search).collect()),
         Err(e) => {
             tracing::warn!(error = %e, "Failed to build rayon thread pool, falling back to sequential");
-            // Execute sequentially without a custom pool
-            let project_results: Vec<Vec<CrossProjectResult>> = registry
-                .project
-                .iter()
-                .filter_map(|entry| {
-                    match search_single_project(entry, query_embedding, filter, limit, threshold) {
-                        Ok(v) => Some(v),
-                        Err(e) => {
-                            tracing::warn!(project = %entry.name, error = %e, "Search failed for project");
-                            None
-                        }
-                    }
-                })
-                .collect();
-            let mut all_results: Vec<CrossProjectResult> =
-                project_results.into_iter().flatten().collect();
-            // Secondary sort on (project, file, line_start, name) keeps equal-
-            // score results deterministically ordered across process invocations.
-            all_results.sort_by(|a, b| {
-                b.score
-                    .total_cmp(&a.score)
-                    .then(a.project_name.cmp(&b.project_name))
-                    .then(a.file.cmp(&b.file))
-                    .then(a.line_start.cmp(&b.line_start))
-                    .then(a.name.cmp(&b.name))
-            });
-            all_results.truncate(limit);
-            tracing::info!(
-                result_count = all_results.len(),
-                "Cross-project search complete (sequential fallback)"
-            );
-            return Ok(all_results);
+            targets.iter().filter_map(search).collect()
         }
     };
-    let project_results: Vec<Vec<CrossProjectResult>> = pool.install(|| {
-        registry
-            .project
-            .par_iter()
-            .filter_map(|entry| {
-                match search_single_project(entry, query_embedding, filter, limit, threshold) {
-                    Ok(v) => Some(v),
-                    Err(e) => {
-                        tracing::warn!(project = %entry.name, error = %e, "Search failed for project");
-                        None
-                    }
-                }
-            })
-            .collect()
-    });
-
-    let mut all_results: Vec<CrossProjectResult> = project_results.into_iter().flatten().collect();
-
-    // Sort by score descending, take top N. Secondary sort on
-    // (project, file, line_start, name) keeps equal-score results
-    // deterministically ordered across process invocations so the
-    // truncate() picks the same survivors on every run.
-    all_results.sort_by(|a, b| {
-        b.score
-            .total_cmp(&a.score)
-            .then(a.project_name.cmp(&b.project_name))
-            .then(a.file.cmp(&b.file))
-            .then(a.line_start.cmp(&b.line_start))
-            .then(a.name.cmp(&b.name))
-    });
-    all_results.truncate(limit);
-
-    tracing::info!(
-        result_count = all_results.len(),
-        "Cross-project search complete"
-    );
-
-    Ok(all_results)
+    project_results.into_iter().flatten().collect()
 }
 
 /// Search a single project entry, returning results or an error on failure.
@@ -356,17 +542,7 @@ fn search_single_project(
     threshold: f32,
 ) -> Result<Vec<CrossProjectResult>, anyhow::Error> {
     let _span = tracing::info_span!("search_single_project", project = %entry.name).entered();
-    // Prefer .cqs (with slot resolution), fall back to legacy .cq.
-    // `resolve_index_db` handles slots/<active>/index.db AND pre-slots
-    // `.cqs/index.db` for unmigrated cross-project entries.
-    let index_path = {
-        let cqs_dir = entry.path.join(".cqs");
-        if cqs_dir.exists() {
-            crate::resolve_index_db(&cqs_dir)
-        } else {
-            entry.path.join(".cq/index.db")
-        }
-    };
+    let index_path = entry.index_path();
     if !index_path.exists() {
         anyhow::bail!(
             "Skipping project '{}' — index not found at {}",
@@ -394,6 +570,7 @@ fn search_single_project(
             line_start: r.chunk.line_start,
             signature: Some(r.chunk.signature.clone()),
             score: r.score,
+            raw_score: r.score,
         })
         .collect();
     Ok(mapped)
@@ -635,6 +812,52 @@ mod tests {
         assert_eq!(results[0].chunk.name, "test_function");
     }
 
+    #[test]
+    fn test_project_freshness_counts_missing_files() {
+        use crate::store::helpers::ModelInfo;
+
+        let dir = tempfile::tempdir().unwrap();
+        let entry = ProjectEntry {
+            name: "proj".into(),
+            path: dir.path().to_path_buf(),
+        };
+        assert_eq!(project_freshness(&entry).unwrap(), None);
+
+        let cqs_dir = dir.path().join(".cqs");
+        std::fs::create_dir_all(&cqs_dir).unwrap();
+        let store = crate::Store::open(&cqs_dir.join(crate::INDEX_DB_FILENAME)).unwrap();
+        store.init(&ModelInfo::default()).unwrap();
+        let content = "fn gone() {}".to_string();
+        let chunk = crate::parser::Chunk {
+            id: "gone.rs:1:0000".to_string(),
+            file: PathBuf::from("gone.rs"),
+            chunk_type: crate::parser::ChunkType::Function,
+            name: "gone".to_string(),
+            signature: "fn gone()".to_string(),
+            content_hash: blake3::hash(content.as_bytes()).to_hex().to_string(),
+            content,
+            doc: None,
+            line_start: 1,
+            line_end: 1,
+            byte_start: 0,
+            language: crate::parser::Language::Rust,
+            canonical_hash: String::new(),
+            parent_id: None,
+            window_idx: None,
+            parent_type_name: None,
+            parser_version: 0,
+        };
+        let embedding = crate::Embedding::new(vec![0.1; crate::EMBEDDING_DIM]);
+        store.upsert_chunk(&chunk, &embedding, None).unwrap();
+        drop(store);
+
+        let fresh = project_freshness(&entry).unwrap().unwrap();
+        assert_eq!(fresh.files, 1);
+        assert_eq!(fresh.missing, 1, "gone.rs was never written to disk");
+        assert_eq!(fresh.stale, 0);
+        assert!(fresh.indexed_at.is_some());
+    }
+
     #[test]
     fn test_search_across_projects_sort_and_truncate() {
         // Verify the sort-by-score-descending + truncate logic
@@ -646,6 +869,7 @@ mod tests {
                 line_start: 1,
                 signature: None,
                 score: 0.1,
+                raw_score: 0.1,
             },
             CrossProjectResult {
                 project_name: "b".into(),
@@ -654,6 +878,7 @@ mod tests {
                 line_start: 1,
                 signature: None,
                 score: 0.9,
+                raw_score: 0.9,
             },
             CrossProjectResult {
                 project_name: "c".into(),
@@ -662,6 +887,7 @@ mod tests {
                 line_start: 1,
                 signature: None,
                 score: 0.5,
+                raw_score: 0.5,
             },
         ];
 
@@ -673,4 +899,68 @@ mod tests {
         assert_eq!(results[0].name, "high");
         assert_eq!(results[1].name, "mid");
     }
+
+    fn result(project: &str, name: &str, raw: f32) -> CrossProjectResult {
+        CrossProjectResult {
+            project_name: project.into(),
+            name: name.into(),
+            file: PathBuf::from("lib.rs"),
+            line_start: 1,
+            signature: None,
+            score: raw,
+            raw_score: raw,
+        }
+    }
+
+    #[test]
+    fn test_select_projects_keeps_order_and_rejects_unknown() {
+        let registry = ProjectRegistry {
+            project: ["svc-a", "svc-b", "svc-c"]
+                .iter()
+                .map(|n| ProjectEntry {
+                    name: n.to_string(),
+                    path: PathBuf::from(format!("/srv/{n}")),
+                })
+                .collect(),
+        };
+        let names = |v: &[&str]| v.iter().map(|s| s.to_string()).collect::<Vec<_>>();
+
+        let picked = select_projects(&registry, &names(&["svc-c", "svc-a", "svc-c"])).unwrap();
+        let picked: Vec<&str> = picked.iter().map(|e| e.name.as_str()).collect();
+        assert_eq!(picked, ["svc-c", "svc-a"]);
+        assert_eq!(select_projects(&registry, &[]).unwrap().len(), 3);
+
+        let err = select_projects(&registry, &names(&["svc-x"])).unwrap_err();
+        assert!(err
+            .to_string()
+            .contains("svc-x (registered: svc-a, svc-b, svc-c)"));
+    }
+
+    #[test]
+    fn test_normalize_across_models_only_when_models_differ() {
+        let one_model: HashMap<&str, String> =
+            HashMap::from([("a", "m1".to_string()), ("b", "m1".to_string())]);
+        let mut same = vec![result("a", "x", 0.8), result("b", "y", 0.4)];
+        normalize_across_models(&mut same, &one_model);
+        assert_eq!(same[0].score, 0.8);
+        assert_eq!(same[1].score, 0.4);
+
+        // m2 scores run hotter; normalized per model, its best ties m1's best
+        // and raw score breaks the tie.
+        let two_models: HashMap<&str, String> =
+            HashMap::from([("a", "m1".to_string()), ("b", "m2".to_string())]);
+        let mut mixed = vec![
+            result("a", "a-top", 0.5),
+            result("a", "a-low", 0.3),
+            result("b", "b-top", 0.9),
+            result("b", "b-mid", 0.85),
+            result("b", "b-low", 0.8),
+        ];
+        normalize_across_models(&mut mixed, &two_models);
+        rank_and_truncate(&mut mixed, 3);
+        let names: Vec<&str> = mixed.iter().map(|r| r.name.as_str()).collect();
+        assert_eq!(names, ["b-top", "a-top", "b-mid"]);
+        assert!((mixed[2].score - 0.5).abs() < 1e-5);
+        assert_eq!(mixed[2].raw_score, 0.85);
+    }
 }
@@ -615,6 +615,8 @@ mod tests {
                 window_idx: None,
                 parser_version: 0,
                 vendored: false,
+                deprecated: false,
+                boilerplate: None,
             },
             score,
         )
@@ -766,6 +768,8 @@ mod tests {
                 window_idx: None,
                 parser_version: 0,
                 vendored: false,
+                deprecated: false,
+                boilerplate: None,
             },
             0.9,
         ))];
@@ -789,6 +793,8 @@ mod tests {
                     window_idx: None,
                     parser_version: 0,
                     vendored: false,
+                    deprecated: false,
+                    boilerplate: None,
                 },
                 0.7,
             )],
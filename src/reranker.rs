@@ -168,6 +168,9 @@ pub enum RerankerError {
     /// Distinct from `Inference` so callers can pattern-match the bug.
     #[error("Invalid arguments: {0}")]
     InvalidArguments(String),
+    /// Offline mode refused a download (`offline = true` / `CQS_OFFLINE`).
+    #[error(transparent)]
+    Offline(#[from] crate::offline::OfflineError),
 }
 
 /// Route a stringified ORT message into
@@ -587,16 +590,23 @@ impl OnnxReranker {
                 .repo
                 .as_deref()
                 .expect("repo.is_some() checked above");
-            use hf_hub::api::sync::Api;
-            let api = Api::new().map_err(|e| RerankerError::ModelDownload(e.to_string()))?;
-            let repo = api.model(repo_id.to_string());
-
-            let model_path = repo
-                .get(MODEL_FILE)
-                .map_err(|e| RerankerError::ModelDownload(e.to_string()))?;
-            let tokenizer_path = repo
-                .get(TOKENIZER_FILE)
-                .map_err(|e| RerankerError::ModelDownload(e.to_string()))?;
+            let (model_path, tokenizer_path) = if crate::offline::enabled() {
+                // Offline mode: cached files only, never the Hub.
+                (
+                    crate::offline::hf_cached(repo_id, MODEL_FILE)?,
+                    crate::offline::hf_cached(repo_id, TOKENIZER_FILE)?,
+                )
+            } else {
+                use hf_hub::api::sync::Api;
+                let api = Api::new().map_err(|e| RerankerError::ModelDownload(e.to_string()))?;
+                let repo = api.model(repo_id.to_string());
+                (
+                    repo.get(MODEL_FILE)
+                        .map_err(|e| RerankerError::ModelDownload(e.to_string()))?,
+                    repo.get(TOKENIZER_FILE)
+                        .map_err(|e| RerankerError::ModelDownload(e.to_string()))?,
+                )
+            };
 
             // Verify checksums (skip if already verified via marker file)
             if !MODEL_BLAKE3.is_empty() || !TOKENIZER_BLAKE3.is_empty() {
@@ -695,10 +705,14 @@ impl OnnxReranker {
                 .repo
                 .as_deref()
                 .expect("repo.is_some() checked above");
-            let api = Api::new().map_err(|e| format!("hf api: {e}"))?;
-            api.model(repo_id.to_string())
-                .get(CONFIG_FILE)
-                .map_err(|e| format!("hf get config.json: {e}"))?
+            if crate::offline::enabled() {
+                crate::offline::hf_cached(repo_id, CONFIG_FILE).map_err(|e| e.to_string())?
+            } else {
+                let api = Api::new().map_err(|e| format!("hf api: {e}"))?;
+                api.model(repo_id.to_string())
+                    .get(CONFIG_FILE)
+                    .map_err(|e| format!("hf get config.json: {e}"))?
+            }
         } else {
             // Local bundle: `model_path` is `{dir}/onnx/model.onnx`,
             // `config.json` is at `{dir}/config.json` per HF convention.
@@ -1334,6 +1348,8 @@ mod tests {
             parent_type_name: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         };
         SearchResult::new(chunk, 0.0)
     }
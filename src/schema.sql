@@ -1,4 +1,70 @@
--- cq index schema v32 (see src/store/helpers/mod.rs::CURRENT_SCHEMA_VERSION; v22+v23+v24+v25+v26+v27+v28+v29+v30+v31+v32 columns annotated inline below)
+-- cq index schema v51 (see src/store/helpers/mod.rs::CURRENT_SCHEMA_VERSION; v22+v23+v24+v25+v26+v27+v28+v29+v30+v31+v32+v33+v35+v41+v46+v47 columns annotated inline below)
+-- v51: chunk_token_embeddings table — per-token vectors of each chunk
+--      (crate::late_interaction), i8-quantized, for MaxSim rescoring under
+--      `--reranker late`. Filled only when [late_interaction] is enabled.
+--      Cascades with the chunk. Empty on migrate.
+-- v50: chunk_facet_embeddings table — the name and body of each chunk
+--      embedded on their own (crate::facets), blended with the whole-chunk
+--      vector at query time when [facets] is enabled. Cascades with the
+--      chunk. Empty on migrate; filled by the next index.
+-- v49: content_dicts table — zstd dictionaries trained by `cqs compress`.
+--      chunks.content holds plain TEXT or a zstd frame (BLOB) naming its
+--      dictionary in the frame header (store::compression). Empty on migrate.
+-- v48: triggers on chunks and notes advance the `index_generation` metadata
+--      counter reported as `_meta.index_generation`.
+-- v47: chunks.boilerplate TEXT — 'generated' for codegen output, 'license'
+--      for license text, NULL otherwise (crate::boilerplate). Excluded from
+--      search unless --include-generated. Backfilled on migrate.
+-- v46: chunks.deprecated INTEGER — 1 when the chunk's doc or declaration
+--      head carries a deprecation marker (`#[deprecated]`, `@Deprecated`,
+--      `// Deprecated:`). Backfilled from content and doc on migrate.
+-- v45: chunk_embedding_versions table — vectors from a second embedding
+--      model staged per chunk while `cqs model upgrade` runs in the
+--      background. Search never reads it; the cutover copies the vectors
+--      into chunks.embedding and empties it. Cascades with the chunk.
+-- v44: commits + commit_files tables — recent commit messages indexed as
+--      chunks (chunk_type 'commit', source_type 'commit') with their sha,
+--      author, and time, plus the origins each commit touched. Rows cascade
+--      with the commit chunk. Empty on migrate; filled by the next index.
+-- v43: chunk_issue_refs table — issue/PR references (`JIRA-1234`, `#567`,
+--      `owner/repo#8`) found in each chunk's comments, with relation `fixes`
+--      or `mentions`. Rewritten with the chunk's FTS row; cascades with it.
+--      Backfilled from chunk comments on migrate.
+-- v42: chunk_authors table — per-chunk `git blame` summary (top authors by
+--      share of the chunk's lines, JSON) keyed to the chunk's content_hash so
+--      an edited chunk is re-blamed. Rows cascade with their chunk. Empty on
+--      migrate; filled by the next `cqs index`.
+-- v41: chunks.signature_shape TEXT (nullable) — canonical `(T1, T2) -> R`
+--      parameter/return types of typed callables for `search --signature`.
+--      Backfilled from chunks.signature on migrate.
+-- v40: chunks_fts.comments — comment text split out of chunks_fts.content
+--      (now code only) so keyword search can weight or scope comments and
+--      code separately. chunks_fts recreated and re-derived on migrate.
+-- v39: watch_journal table — the pending reindex queue of `cqs watch`
+--      (path, reason, queued_at), kept in step with the in-memory queue so a
+--      restarted daemon resumes what a crash cut off. Cleared per path once
+--      the path is reindexed.
+-- v38: llm_summaries.superseded_at INTEGER (nullable) — unix seconds when the
+--      summarized content_hash stopped matching any chunk (the code changed);
+--      NULL = live. Cleared when the hash comes back (revert, checkout).
+-- v37: chunk_tombstones table — chunks of files deleted from disk, kept with
+--      their embedding for a retention window (CQS_TOMBSTONE_RETENTION_DAYS,
+--      default 7) so a restored file reuses its vectors instead of
+--      re-embedding. Not searched; written by the missing-file prune paths,
+--      cleared when the content_hash is upserted again or the window lapses.
+-- v36: schema_migrations table — one row per applied migration step (up or
+--      down), written inside the migration transaction (store::migrations).
+-- v35: chunks.container_id TEXT (nullable) + idx_chunks_container. Structural
+--      parent: the smallest same-origin chunk whose line range covers this one
+--      (method → impl/class); NULL = top-level, the file is the root. Assigned
+--      per file by the reindex tx (store::chunks::hierarchy); backfilled on
+--      migrate. Distinct from parent_id, which stays the window/table parent.
+-- v34: no shape change — chunks_fts / notes_fts contents rebuilt with the
+--      identifier-aware analyzer (nl::fts: acronym runs kept whole,
+--      abbreviation expansions appended to indexed text).
+-- v33: symbol_renames table — rename edges (old_name → new_name within one
+--      origin) detected by the per-file reindex tx. No FK to chunks: an edge
+--      is history and outlives both chunk rows. Empty on migrate.
 -- v32: candidate_edges side-table (file, callee_name, ref_line, candidate_kind)
 --      for low-confidence call-graph candidates that must NOT surface as
 --      callers. SEPARATE table, not a function_calls.edge_kind value — the graph
@@ -11,7 +77,7 @@
 --      whose marker == the current parser version, so an unparseable file is
 --      not re-queued every reconcile tick until its content changes.
 -- v30: function_calls.edge_kind TEXT NOT NULL DEFAULT 'call' — call-graph edge
---      provenance (call|serde_callback|macro_heuristic|fn_pointer|doc_reference).
+--      provenance (call|serde_callback|ffi_boundary|macro_heuristic|fn_pointer|doc_reference).
 -- v29: file_registry table (origin → source_mtime/size/content_hash) persists
 --      the reconcile fingerprint for files that parse to ZERO chunks, where the
 --      chunk-row fingerprint columns have no row to live on. Without it, a
@@ -98,9 +164,19 @@ CREATE TABLE IF NOT EXISTS chunks (
     source_content_hash BLOB,                 -- v23: BLAKE3 hash of file bytes for reconcile fingerprint (#1219); nullable on pre-migration rows
     vendored INTEGER NOT NULL DEFAULT 0,      -- v24: 1 if origin matches a vendored-path prefix at index time (#1221); search emits trust_level="vendored-code" for these
     needs_embedding INTEGER NOT NULL DEFAULT 0, -- v27: 1 when chunk was written without a real embedding (#1452 first-pass-skip); cleared by enrichment_pass
-    canonical_hash TEXT                       -- v28: blake3 of comment-/whitespace-normalized content; embedding-reuse cache key so comment-only edits reuse the prior embedding. Nullable: NULL = not computed (clean cache miss)
+    canonical_hash TEXT,                      -- v28: blake3 of comment-/whitespace-normalized content; embedding-reuse cache key so comment-only edits reuse the prior embedding. Nullable: NULL = not computed (clean cache miss)
+    container_id TEXT,                        -- v35: id of the smallest same-origin chunk enclosing this one (method → class); NULL = top-level
+    signature_shape TEXT,                     -- v41: canonical `(T1, T2) -> R` of a typed callable (parser::signature); NULL = not a callable or no types
+    deprecated INTEGER NOT NULL DEFAULT 0,    -- v46: 1 if the doc or declaration head carries a deprecation marker (parser::deprecation); downranked in search, dropped by --hide-deprecated
+    boilerplate TEXT                          -- v47: 'generated' | 'license' (crate::boilerplate); NULL = ordinary code. Left out of search unless --include-generated
 );
 
+-- v46: partial index so `--hide-deprecated` reads only the flagged rows.
+CREATE INDEX IF NOT EXISTS idx_chunks_deprecated ON chunks(id) WHERE deprecated = 1;
+
+-- v47: partial index so the default search exclusion reads only tagged rows.
+CREATE INDEX IF NOT EXISTS idx_chunks_boilerplate ON chunks(id) WHERE boilerplate IS NOT NULL;
+
 CREATE INDEX IF NOT EXISTS idx_chunks_needs_embedding
     ON chunks(needs_embedding) WHERE needs_embedding = 1;
 
@@ -124,6 +200,7 @@ CREATE INDEX IF NOT EXISTS idx_chunks_content_hash ON chunks(content_hash);
 CREATE INDEX IF NOT EXISTS idx_chunks_name ON chunks(name);
 CREATE INDEX IF NOT EXISTS idx_chunks_language ON chunks(language);
 CREATE INDEX IF NOT EXISTS idx_chunks_parent ON chunks(parent_id);
+CREATE INDEX IF NOT EXISTS idx_chunks_container ON chunks(container_id);
 
 -- v29 (#1774): per-origin reconcile fingerprint that persists INDEPENDENT of
 -- chunk rows. The reconcile fingerprint normally lives on chunk rows
@@ -145,13 +222,15 @@ CREATE TABLE IF NOT EXISTS file_registry (
 );
 
 -- FTS5 virtual table for keyword search (RRF hybrid search)
--- Normalized text (camelCase/snake_case split to words) populated by application
+-- Normalized text (camelCase/snake_case split to words, acronym runs kept
+-- whole, abbreviation expansions appended) populated by application
 CREATE VIRTUAL TABLE IF NOT EXISTS chunks_fts USING fts5(
     id UNINDEXED,  -- chunk ID for joining (not searchable)
     name,          -- normalized function/method name
     signature,     -- normalized signature
-    content,       -- normalized code content
+    content,       -- normalized code content, comments stripped (v40)
     doc,           -- documentation text
+    comments,      -- v40: normalized comment text from the chunk body
     tokenize='unicode61'
 );
 
@@ -175,7 +254,7 @@ CREATE TABLE IF NOT EXISTS function_calls (
     caller_line INTEGER NOT NULL, -- line where function starts
     callee_name TEXT NOT NULL,    -- name of the called function
     call_line INTEGER NOT NULL,   -- line where call occurs
-    edge_kind TEXT NOT NULL DEFAULT 'call'  -- provenance: call|serde_callback|macro_heuristic|fn_pointer|doc_reference (v30)
+    edge_kind TEXT NOT NULL DEFAULT 'call'  -- provenance: call|serde_callback|ffi_boundary|macro_heuristic|fn_pointer|doc_reference (v30)
 );
 CREATE INDEX IF NOT EXISTS idx_fcalls_file ON function_calls(file);
 CREATE INDEX IF NOT EXISTS idx_fcalls_caller ON function_calls(caller_name);
@@ -198,6 +277,150 @@ CREATE TABLE IF NOT EXISTS candidate_edges (
 CREATE INDEX IF NOT EXISTS idx_candidate_edges_callee ON candidate_edges(callee_name);
 CREATE INDEX IF NOT EXISTS idx_candidate_edges_file ON candidate_edges(file);
 
+-- Symbol rename edges (v33). One row per detected rename: the chunk named
+-- old_name vanished from origin in the same reindex that added a chunk of the
+-- same type named new_name whose body matches up to the name. similarity is
+-- 1.0 for an exact rename, otherwise the body's token Jaccard similarity.
+CREATE TABLE IF NOT EXISTS symbol_renames (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    origin TEXT NOT NULL,
+    chunk_type TEXT NOT NULL,
+    old_name TEXT NOT NULL,
+    new_name TEXT NOT NULL,
+    old_content_hash TEXT NOT NULL,
+    new_content_hash TEXT NOT NULL,
+    similarity REAL NOT NULL,       -- 1.0 = identical up to the name
+    detected_at TEXT NOT NULL       -- RFC 3339
+);
+CREATE INDEX IF NOT EXISTS idx_symbol_renames_old ON symbol_renames(old_name);
+CREATE INDEX IF NOT EXISTS idx_symbol_renames_new ON symbol_renames(new_name);
+
+-- Migration history (v36). Each schema step that ran against this index,
+-- in order: to_version < from_version is a down step. Fresh indexes start
+-- empty — init() writes the current schema directly.
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    from_version INTEGER NOT NULL,
+    to_version INTEGER NOT NULL,
+    applied_at TEXT NOT NULL,       -- RFC 3339
+    cqs_version TEXT NOT NULL       -- CARGO_PKG_VERSION of the binary that ran it
+);
+
+-- Tombstones of deleted files' chunks (v37). The row leaves `chunks` (so
+-- search, FTS and HNSW never see it) but its embedding is kept here until
+-- `deleted_at` + the retention window, for embedding reuse on reindex.
+CREATE TABLE IF NOT EXISTS chunk_tombstones (
+    id TEXT PRIMARY KEY,            -- chunk id at deletion
+    origin TEXT NOT NULL,
+    name TEXT NOT NULL,
+    content_hash TEXT NOT NULL,
+    canonical_hash TEXT NOT NULL,
+    embedding BLOB NOT NULL,
+    deleted_at INTEGER NOT NULL     -- unix seconds
+);
+CREATE INDEX IF NOT EXISTS idx_chunk_tombstones_canonical ON chunk_tombstones(canonical_hash);
+CREATE INDEX IF NOT EXISTS idx_chunk_tombstones_content ON chunk_tombstones(content_hash);
+CREATE INDEX IF NOT EXISTS idx_chunk_tombstones_deleted_at ON chunk_tombstones(deleted_at);
+
+-- Crash-recovery journal for the `cqs watch` pending queue (v39).
+-- path is the queued relative path (or the notes file for reason 'notes').
+CREATE TABLE IF NOT EXISTS watch_journal (
+    path TEXT PRIMARY KEY,
+    reason TEXT NOT NULL,           -- 'event' | 'reconcile' | 'notes'
+    queued_at INTEGER NOT NULL      -- unix seconds
+);
+
+-- Blame ownership summary per chunk (v42). authors is a JSON array of
+-- {name, email, lines, share} for the top authors of the chunk's lines;
+-- content_hash is the chunk's hash when it was blamed, so a changed chunk
+-- reads as missing and is re-blamed.
+CREATE TABLE IF NOT EXISTS chunk_authors (
+    chunk_id TEXT PRIMARY KEY,
+    content_hash TEXT NOT NULL,
+    authors TEXT NOT NULL,
+    FOREIGN KEY (chunk_id) REFERENCES chunks(id) ON DELETE CASCADE
+);
+
+-- Issue/PR references in chunk comments (v43): canonical id (`JIRA-1234`,
+-- `#567`, `owner/repo#8`) and whether the comment fixes or mentions it.
+CREATE TABLE IF NOT EXISTS chunk_issue_refs (
+    chunk_id TEXT NOT NULL,
+    issue TEXT NOT NULL,
+    relation TEXT NOT NULL,
+    PRIMARY KEY (chunk_id, issue),
+    FOREIGN KEY (chunk_id) REFERENCES chunks(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_chunk_issue_refs_issue ON chunk_issue_refs(issue);
+
+-- Indexed commit messages (v44). The message itself is the chunk; this is
+-- the commit behind it (author time in Unix seconds).
+CREATE TABLE IF NOT EXISTS commits (
+    chunk_id TEXT PRIMARY KEY,
+    sha TEXT NOT NULL UNIQUE,
+    author TEXT NOT NULL,
+    email TEXT NOT NULL,
+    committed_at INTEGER NOT NULL,
+    FOREIGN KEY (chunk_id) REFERENCES chunks(id) ON DELETE CASCADE
+);
+
+-- Origins each indexed commit touched (v44), project-relative.
+CREATE TABLE IF NOT EXISTS commit_files (
+    chunk_id TEXT NOT NULL,
+    origin TEXT NOT NULL,
+    PRIMARY KEY (chunk_id, origin),
+    FOREIGN KEY (chunk_id) REFERENCES chunks(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_commit_files_origin ON commit_files(origin);
+
+-- Embeddings from an upgrade model, staged beside the served ones (v45).
+-- `model` is the repo the vector came from; `content_hash` is the chunk's
+-- content when it was embedded, so an edited chunk is staged again. Only
+-- one upgrade runs at a time (metadata `upgrade_model`).
+CREATE TABLE IF NOT EXISTS chunk_embedding_versions (
+    chunk_id TEXT NOT NULL,
+    model TEXT NOT NULL,
+    content_hash TEXT NOT NULL,
+    embedding BLOB NOT NULL,
+    PRIMARY KEY (chunk_id, model),
+    FOREIGN KEY (chunk_id) REFERENCES chunks(id) ON DELETE CASCADE
+);
+
+-- Per-token embeddings of each chunk (v51): `token_count` rows of a scale
+-- and `dim` signed bytes each (crate::late_interaction::TokenEmbeddings).
+-- `content_hash` is the chunk's content when it was embedded, as for the
+-- facet rows below. Emptied at a model cutover.
+CREATE TABLE IF NOT EXISTS chunk_token_embeddings (
+    chunk_id TEXT PRIMARY KEY,
+    content_hash TEXT NOT NULL,
+    token_count INTEGER NOT NULL,
+    embeddings BLOB NOT NULL,
+    FOREIGN KEY (chunk_id) REFERENCES chunks(id) ON DELETE CASCADE
+);
+
+-- Per-facet embeddings of each chunk (v50): `facet` is 'name' or 'body'
+-- (crate::facets::Facet). `content_hash` is the chunk's content when it was
+-- embedded, so an edited chunk's rows stop counting until it is embedded
+-- again. Emptied at a model cutover.
+CREATE TABLE IF NOT EXISTS chunk_facet_embeddings (
+    chunk_id TEXT NOT NULL,
+    facet TEXT NOT NULL,
+    content_hash TEXT NOT NULL,
+    embedding BLOB NOT NULL,
+    PRIMARY KEY (chunk_id, facet),
+    FOREIGN KEY (chunk_id) REFERENCES chunks(id) ON DELETE CASCADE
+);
+
+-- Chunk text compression dictionaries (v49). `id` is the zstd dictionary ID
+-- each compressed `chunks.content` frame carries in its header; metadata
+-- `content_dict` names the one new writes use. `cqs compress` replaces the
+-- set, `cqs compress --off` empties it.
+CREATE TABLE IF NOT EXISTS content_dicts (
+    id INTEGER PRIMARY KEY,
+    dict BLOB NOT NULL,
+    sample_count INTEGER NOT NULL,
+    created_at TEXT NOT NULL
+);
+
 -- Type dependency edges: which chunks reference which types (Phase 2b)
 -- Source is chunk-level for precise dependency tracking.
 -- edge_kind stores TypeEdgeKind classification (Param, Return, Field, Impl, Bound, Alias)
@@ -279,6 +502,53 @@ BEGIN
         value = CAST((CAST(value AS INTEGER) + 1) AS TEXT);
 END;
 
+-- v48: index generation counter (`_meta.index_generation` on search output).
+-- Any row written to or removed from chunks or notes advances it, so a search
+-- that reads it on its own snapshot can name the exact index state behind its
+-- ranking. Row-level like the SPLADE trigger above: a bulk index pays one
+-- metadata upsert per chunk, well under a second per 100k chunks.
+-- `INSERT OR REPLACE INTO notes` fires the INSERT trigger.
+
+CREATE TRIGGER IF NOT EXISTS bump_generation_on_chunks_insert
+AFTER INSERT ON chunks
+BEGIN
+    INSERT INTO metadata (key, value) VALUES ('index_generation', '1')
+    ON CONFLICT(key) DO UPDATE SET
+        value = CAST((CAST(value AS INTEGER) + 1) AS TEXT);
+END;
+
+CREATE TRIGGER IF NOT EXISTS bump_generation_on_chunks_update
+AFTER UPDATE ON chunks
+BEGIN
+    INSERT INTO metadata (key, value) VALUES ('index_generation', '1')
+    ON CONFLICT(key) DO UPDATE SET
+        value = CAST((CAST(value AS INTEGER) + 1) AS TEXT);
+END;
+
+CREATE TRIGGER IF NOT EXISTS bump_generation_on_chunks_delete
+AFTER DELETE ON chunks
+BEGIN
+    INSERT INTO metadata (key, value) VALUES ('index_generation', '1')
+    ON CONFLICT(key) DO UPDATE SET
+        value = CAST((CAST(value AS INTEGER) + 1) AS TEXT);
+END;
+
+CREATE TRIGGER IF NOT EXISTS bump_generation_on_notes_insert
+AFTER INSERT ON notes
+BEGIN
+    INSERT INTO metadata (key, value) VALUES ('index_generation', '1')
+    ON CONFLICT(key) DO UPDATE SET
+        value = CAST((CAST(value AS INTEGER) + 1) AS TEXT);
+END;
+
+CREATE TRIGGER IF NOT EXISTS bump_generation_on_notes_delete
+AFTER DELETE ON notes
+BEGIN
+    INSERT INTO metadata (key, value) VALUES ('index_generation', '1')
+    ON CONFLICT(key) DO UPDATE SET
+        value = CAST((CAST(value AS INTEGER) + 1) AS TEXT);
+END;
+
 -- LLM-generated summaries cache (SQ-6, v16: composite PK)
 -- Keyed by (content_hash, purpose) so the same code can have multiple summary types
 -- (e.g., 'summary', 'doc-comment'). Summaries survive chunk deletion and --force rebuilds.
@@ -288,5 +558,6 @@ CREATE TABLE IF NOT EXISTS llm_summaries (
     summary TEXT NOT NULL,
     model TEXT NOT NULL,
     created_at TEXT NOT NULL,
+    superseded_at INTEGER,          -- v38: unix seconds once no chunk has this hash; NULL = live
     PRIMARY KEY (content_hash, purpose)
 );
@@ -5,6 +5,7 @@
 
 mod mmr;
 mod query;
+pub mod rank_hook;
 pub mod router;
 pub mod scoring;
 pub mod synonyms;
@@ -111,6 +112,62 @@ pub fn resolve_target<Mode>(
     })
 }
 
+/// Resolve a target that names one chunk rather than one function name.
+/// Supports, in order:
+/// - a chunk id, as printed in JSON output (`"src/lib.rs:12:ab34cd56"`)
+/// - `"path/to/file.rs:42"` -> the innermost chunk in that file covering line 42
+/// - anything [`resolve_target`] accepts (`name`, `file:name`)
+///
+/// Used by `cqs similar` so a neighbor query can start from a location
+/// (an editor cursor, a grep hit) without knowing the enclosing name.
+pub fn resolve_chunk_target<Mode>(
+    store: &Store<Mode>,
+    target: &str,
+) -> Result<ResolvedTarget, StoreError> {
+    let _span = tracing::info_span!("resolve_chunk_target", target).entered();
+    if let Some(chunk) = store.get_chunks_by_ids(&[target])?.remove(target) {
+        return Ok(ResolvedTarget {
+            chunk,
+            alternatives: Vec::new(),
+        });
+    }
+    if let Some((file, line)) = parse_file_line(target) {
+        let chunks = store.get_chunks_by_origin(file.trim_start_matches("./"))?;
+        // Innermost: a method wins over its enclosing impl/class.
+        let chunk = chunks
+            .into_iter()
+            .filter(|c| c.line_start <= line && line <= c.line_end)
+            .min_by_key(|c| {
+                (
+                    c.line_end.saturating_sub(c.line_start),
+                    c.window_idx.unwrap_or(0),
+                )
+            })
+            .ok_or_else(|| {
+                StoreError::NotFound(format!(
+                    "No indexed chunk covers {}:{}. \
+                     Check the path (relative to the project root) and line.",
+                    file, line
+                ))
+            })?;
+        return Ok(ResolvedTarget {
+            chunk,
+            alternatives: Vec::new(),
+        });
+    }
+    resolve_target(store, target)
+}
+
+/// Split `"file:42"` into `("file", 42)`. `None` unless the part after the
+/// last colon is a line number and the file part is non-empty.
+fn parse_file_line(target: &str) -> Option<(&str, u32)> {
+    let (file, line) = target.rsplit_once(':')?;
+    if file.is_empty() || line.is_empty() || !line.bytes().all(|b| b.is_ascii_digit()) {
+        return None;
+    }
+    Some((file, line.parse().ok()?))
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -239,4 +296,45 @@ mod tests {
             other => panic!("Expected NotFound, got: {:?}", other),
         }
     }
+
+    // ===== resolve_chunk_target tests =====
+
+    #[test]
+    fn resolve_chunk_target_accepts_chunk_id() {
+        let (store, _dir) = setup_store();
+        insert_chunk(&store, "parse", "src/parser/mod.rs");
+        insert_chunk(&store, "parse", "src/search/mod.rs");
+
+        let result = resolve_chunk_target(&store, "src/search/mod.rs:1:abcd1234").unwrap();
+        assert_eq!(result.chunk.file, PathBuf::from("src/search/mod.rs"));
+    }
+
+    #[test]
+    fn resolve_chunk_target_accepts_file_line() {
+        let (store, _dir) = setup_store();
+        insert_chunk(&store, "my_func", "src/lib.rs");
+
+        let result = resolve_chunk_target(&store, "src/lib.rs:3").unwrap();
+        assert_eq!(result.chunk.name, "my_func");
+
+        let err = resolve_chunk_target(&store, "src/lib.rs:40").unwrap_err();
+        assert!(matches!(err, StoreError::NotFound(_)), "got: {:?}", err);
+    }
+
+    #[test]
+    fn resolve_chunk_target_falls_back_to_name() {
+        let (store, _dir) = setup_store();
+        insert_chunk(&store, "my_func", "src/lib.rs");
+
+        let result = resolve_chunk_target(&store, "src/lib.rs:my_func").unwrap();
+        assert_eq!(result.chunk.name, "my_func");
+    }
+
+    #[test]
+    fn parse_file_line_requires_numeric_line() {
+        assert_eq!(parse_file_line("src/a.rs:42"), Some(("src/a.rs", 42)));
+        assert_eq!(parse_file_line("src/a.rs:parse"), None);
+        assert_eq!(parse_file_line(":42"), None);
+        assert_eq!(parse_file_line("src/a.rs:"), None);
+    }
 }
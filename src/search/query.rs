@@ -6,22 +6,25 @@
 
 use std::collections::{HashMap, HashSet};
 
+use sqlx::sqlite::SqliteConnection;
+use tracing::Instrument as _;
+
 use crate::embedder::Embedding;
 use crate::index::VectorIndex;
 use crate::limits::candidate_count_for;
-use crate::nl::normalize_for_fts;
 use crate::parser::ChunkType;
 use crate::store::helpers::{
-    embedding_slice, CandidateRow, ChunkSummary, SearchFilter, SearchResult,
+    embedding_slice, CandidateRow, FtsScope, RankSignal, SearchFilter, SearchResult,
 };
 use crate::store::sanitize_fts_query;
 use crate::store::{NoteSummary, Store, StoreError};
 
 use super::mmr::{mmr_lambda_from_env, mmr_rerank, MmrCandidate};
+use super::rank_hook::{apply_rank_hook, RankHook};
 use super::scoring::{
-    apply_parent_boost, apply_scoring_pipeline, build_filter_sql, compile_glob_filter, rrf_fuse,
-    score_candidate, signals_for, BoundedScoreHeap, NameMatcher, NoteBoost, RankSignalCtx,
-    RankSignalInputs, ScoringContext,
+    apply_parent_boost, apply_scoring_pipeline, build_filter_sql, compile_glob_filter,
+    dedup_and_merge_windows, rrf_fuse, score_candidate, signals_for, BoundedScoreHeap, NameMatcher,
+    NoteBoost, RankSignalCtx, RankSignalInputs, ScoringContext,
 };
 use super::synonyms::expand_query_for_fts;
 
@@ -219,6 +222,35 @@ pub(crate) fn type_boost_factor() -> f32 {
     crate::search::scoring::knob::resolve_knob("type_boost")
 }
 
+/// Multiply every deprecated result by the `deprecated_penalty` knob and
+/// re-sort. Results a project boost rule already adjusted (`boosted`) are
+/// left alone — an explicit `cqs-boosts.toml` entry wins over the marker.
+/// Returns the applied factor per chunk id for `rank_signals`.
+fn apply_deprecated_penalty(
+    results: &mut [SearchResult],
+    boosted: &HashMap<String, f32>,
+) -> HashMap<String, f32> {
+    let penalty = crate::search::scoring::knob::resolve_knob("deprecated_penalty");
+    let mut factors = HashMap::new();
+    if penalty >= 1.0 {
+        return factors;
+    }
+    for r in results.iter_mut() {
+        if r.chunk.deprecated && !boosted.contains_key(&r.chunk.id) {
+            r.score *= penalty;
+            factors.insert(r.chunk.id.clone(), penalty);
+        }
+    }
+    if !factors.is_empty() {
+        results.sort_by(|a, b| {
+            b.score
+                .total_cmp(&a.score)
+                .then(a.chunk.id.cmp(&b.chunk.id))
+        });
+    }
+    factors
+}
+
 /// Trim `k` to the backend's reported `max_k` cap.
 ///
 /// CAGRA enforces `itopk_size >= k` and `itopk_size <= itopk_max`, where
@@ -280,6 +312,58 @@ impl<Mode> Store<Mode> {
         })
     }
 
+    /// Resolve the filter's chunk allowlists — `origins` (`--changed-since`),
+    /// `content_regex` (`--grep`), `signature` (`--signature`), `owner`
+    /// (`--owner`), and `issue` (`--issue`) — to one set of chunk IDs, or
+    /// `None` when none is set.
+    ///
+    /// Resolved once per search and pushed into the vector-index traversal
+    /// predicate, the brute-force scan, and the FTS keyword leg, so a narrow
+    /// allowlist still fills to `limit` and no leg can reintroduce a chunk
+    /// the allowlist excluded.
+    pub(crate) fn chunk_id_allowlist(
+        &self,
+        filter: &SearchFilter,
+    ) -> Result<Option<HashSet<String>>, StoreError> {
+        let by_origin = match filter.origins {
+            Some(ref origins) => Some(self.chunk_ids_for_origins(origins)?),
+            None => None,
+        };
+        let by_content = match filter.content_regex {
+            Some(ref pattern) => {
+                let re = regex::Regex::new(pattern).map_err(|e| {
+                    StoreError::Runtime(format!("Invalid content regex '{pattern}': {e}"))
+                })?;
+                Some(self.chunk_ids_matching_content(&re)?)
+            }
+            None => None,
+        };
+        let by_signature = match filter.signature {
+            Some(ref shape) => {
+                let pattern = crate::parser::signature::SignaturePattern::parse(shape)
+                    .map_err(StoreError::Runtime)?;
+                Some(self.chunk_ids_matching_signature(&pattern)?)
+            }
+            None => None,
+        };
+        let by_owner = match filter.owner {
+            Some(ref owner) => {
+                let mut ids = self.chunk_ids_for_origins(&owner.origins)?;
+                ids.extend(self.chunk_ids_by_author(&owner.name)?);
+                Some(ids)
+            }
+            None => None,
+        };
+        let by_issue = match filter.issue {
+            Some(ref issue) => Some(self.chunk_ids_by_issue(issue)?),
+            None => None,
+        };
+        Ok([by_origin, by_content, by_signature, by_owner, by_issue]
+            .into_iter()
+            .flatten()
+            .reduce(|a, b| a.intersection(&b).cloned().collect()))
+    }
+
     /// Get the cached note-boost index, building from
     /// [`Store::cached_notes_summaries`] on first access or after invalidation.
     ///
@@ -341,10 +425,19 @@ impl<Mode> Store<Mode> {
                 std::sync::Arc::new(Vec::new())
             }
         };
-        self.search_filtered_with_notes(query, filter, limit, threshold, &notes)
+        let allowed_ids = self.chunk_id_allowlist(filter)?;
+        self.search_filtered_with_notes(
+            query,
+            filter,
+            limit,
+            threshold,
+            &notes,
+            allowed_ids.as_ref(),
+        )
     }
 
-    /// Inner implementation of `search_filtered` that accepts pre-loaded notes.
+    /// Inner implementation of `search_filtered` that accepts pre-loaded notes
+    /// and the filter's resolved chunk allowlist ([`Self::chunk_id_allowlist`]).
     fn search_filtered_with_notes(
         &self,
         query: &Embedding,
@@ -352,6 +445,7 @@ impl<Mode> Store<Mode> {
         limit: usize,
         threshold: f32,
         notes: &[NoteSummary],
+        allowed_ids: Option<&HashSet<String>>,
     ) -> Result<Vec<SearchResult>, StoreError> {
         // No nested `info_span!("search_filtered", ...)` here — the
         // `search_filtered` public wrapper already opens the span for the whole
@@ -359,8 +453,14 @@ impl<Mode> Store<Mode> {
         // `search_index_guided` span first. A duplicate span here would double
         // the per-query span allocation on the hottest daemon path and make
         // flame graphs look like recursion.
-        self.block_on(async {
+        //
+        // One read snapshot for the scan, the keyword leg and the content
+        // fetch; a busy failure reruns the whole query on a fresh one.
+        self.read_retrying("search_filtered", || async {
+            let mut snap = self.begin_read().await?;
+            self.record_search_generation(&mut snap).await?;
             let fsql = build_filter_sql(filter);
+            let hidden = self.hidden_chunk_ids(&mut snap, filter).await?;
             // Saturating mul matches sibling paths; overflow on pathological
             // `limit` would panic in debug.
             let semantic_limit = if fsql.use_rrf {
@@ -453,6 +553,7 @@ impl<Mode> Store<Mode> {
             loop {
                 let batch = self
                     .fetch_brute_force_batch(
+                        &mut snap,
                         sql.as_str(),
                         &fsql.bind_values,
                         last_rowid,
@@ -467,6 +568,11 @@ impl<Mode> Store<Mode> {
                 last_rowid = batch.last().expect("batch non-empty checked above").rowid;
 
                 for row in &batch {
+                    if allowed_ids.is_some_and(|ids| !ids.contains(&row.id))
+                        || hidden.contains(&row.id)
+                    {
+                        continue;
+                    }
                     let embedding = match embedding_slice(&row.embedding_bytes, self.dim) {
                         Ok(e) => e,
                         Err(_) => continue,
@@ -488,28 +594,40 @@ impl<Mode> Store<Mode> {
                 name_matcher: name_matcher.as_ref(),
                 enable_demotion: filter.enable_demotion,
                 suppress_note_boost: filter.suppress_note_boost,
-                // Brute-force scan has no sparse leg.
+                // Brute-force scan has no sparse leg, and scores on the
+                // whole-chunk vector alone.
                 sparse_ranks: HashMap::new(),
+                facet_scores: HashMap::new(),
+                late_scores: HashMap::new(),
+                popularity: filter.popularity.as_deref(),
+                feedback: filter.feedback.as_deref(),
             });
             let results = self
                 .finalize_results(
+                    &mut snap,
                     scored,
                     &filter.query_text,
                     fsql.use_rrf,
+                    filter.fts_scope,
                     limit,
                     glob_matcher.as_ref(),
+                    allowed_ids,
+                    &hidden,
                     filter.type_boost_types.as_deref(),
+                    filter.boosts.as_deref(),
                     filter.mmr_lambda,
                     signal_inputs,
                 )
                 .await?;
+            snap.commit().await?;
 
             tracing::debug!(count = results.len(), "search_filtered complete");
             Ok(results)
         })
     }
 
-    /// Post-scoring pipeline: RRF fusion, content fetch, parent dedup, boost, truncate.
+    /// Post-scoring pipeline: RRF fusion, content fetch, parent dedup, boost,
+    /// project boost list, deprecation penalty, ranking hook, truncate.
     ///
     /// Shared by `search_filtered` and `search_by_candidate_ids`. Both produce
     /// `Vec<(chunk_id, score)>` through different scoring paths (brute-force vs
@@ -521,12 +639,17 @@ impl<Mode> Store<Mode> {
     #[allow(clippy::too_many_arguments)] // The function joins per-call routing flags (use_rrf, mmr_lambda) with per-search inputs; the optional signal_inputs rides the same shape.
     async fn finalize_results(
         &self,
+        conn: &mut SqliteConnection,
         mut scored: Vec<(String, f32)>,
         query_text: &str,
         use_rrf: bool,
+        fts_scope: FtsScope,
         limit: usize,
         glob_matcher: Option<&globset::GlobMatcher>,
+        allowed_ids: Option<&HashSet<String>>,
+        hidden: &HashSet<String>,
         type_boost_types: Option<&[ChunkType]>,
+        boosts: Option<&crate::boosts::BoostRules>,
         mmr_lambda: Option<f32>,
         signal_inputs: Option<RankSignalInputs<'_>>,
     ) -> Result<Vec<SearchResult>, StoreError> {
@@ -542,6 +665,11 @@ impl<Mode> Store<Mode> {
         // direction.
         let mmr_lambda = mmr_lambda.or_else(mmr_lambda_from_env);
 
+        // Hidden chunks (`--hide-deprecated`, generated/license boilerplate
+        // without `--include-generated`) never reach the pool; the keyword
+        // leg below drops them before anything is truncated to `limit`.
+        debug_assert!(scored.iter().all(|(id, _)| !hidden.contains(id)));
+
         // Ranking-provenance leg ranks. Built from the *incoming* `scored`
         // (the semantic/base ordering fed to fusion) before it is consumed
         // below, plus the FTS leg captured inside the RRF branch. Owned keys
@@ -559,13 +687,21 @@ impl<Mode> Store<Mode> {
 
         // Step 1: RRF fusion with FTS keyword search, or plain truncate
         let final_scored: Vec<(String, f32)> = if use_rrf {
-            let normalized = normalize_for_fts(query_text);
+            // Synonyms are keyed by unstemmed words, so expand the analyzer's
+            // terms first and stem the expanded query after.
+            let normalized = self.fts_analyzer.query_terms(query_text);
             let sanitized = sanitize_fts_query(&normalized);
             let expanded = expand_query_for_fts(&sanitized);
-            let fts_query = if expanded.is_empty() {
-                sanitized.clone()
+            let fts_query = self.fts_analyzer.stem_query(if expanded.is_empty() {
+                &sanitized
+            } else {
+                &expanded
+            });
+            // `--in code|comments` narrows the leg to those columns.
+            let fts_query = if fts_query.is_empty() {
+                fts_query
             } else {
-                expanded
+                fts_scope.apply(&fts_query)
             };
             let fts_ids = if fts_query.is_empty() {
                 vec![]
@@ -577,21 +713,21 @@ impl<Mode> Store<Mode> {
                 // surface through the keyword leg. Returns each id with its
                 // `origin` (authoritative file path) for the glob filter.
                 let fts_all: Vec<(String, String)> = self
-                    .fts_match_id_origins(&fts_query, limit.saturating_mul(3))
+                    .fts_match_id_origins(conn, &fts_query, limit.saturating_mul(3))
                     .await?;
                 // Apply path filter to FTS results (the glob filter isn't
                 // expressible in the FTS query). Glob matches the real `origin`,
                 // never a substring parsed out of the id. Reuses the caller's
-                // pre-compiled glob matcher.
-                if let Some(gm) = glob_matcher {
-                    fts_all
-                        .into_iter()
-                        .filter(|(_id, origin)| gm.is_match(origin))
-                        .map(|(id, _origin)| id)
-                        .collect()
-                } else {
-                    fts_all.into_iter().map(|(id, _origin)| id).collect()
-                }
+                // pre-compiled glob matcher. The chunk allowlist applies here
+                // too, or the keyword leg would reintroduce chunks the dense
+                // leg was restricted away from.
+                fts_all
+                    .into_iter()
+                    .filter(|(_id, origin)| glob_matcher.is_none_or(|gm| gm.is_match(origin)))
+                    .filter(|(id, _origin)| allowed_ids.is_none_or(|ids| ids.contains(id)))
+                    .filter(|(id, _origin)| !hidden.contains(id))
+                    .map(|(id, _origin)| id)
+                    .collect()
             };
             if signal_inputs.is_some() {
                 // FTS leg ranks mirror `rrf_fuse`'s per-list dedup: first
@@ -618,23 +754,15 @@ impl<Mode> Store<Mode> {
         // Step 2: Fetch full content only for top-N results — heavy
         // content/doc/signature columns loaded only for winners.
         let ids: Vec<&str> = final_scored.iter().map(|(id, _)| id.as_str()).collect();
-        let mut rows_map = self.fetch_chunks_by_ids_async(&ids).await?;
+        let mut rows_map = self
+            .fetch_chunks_by_ids_in(conn, &ids)
+            .instrument(tracing::debug_span!("fetch_content", count = ids.len()))
+            .await?;
 
-        // Step 3: Parent dedup — keep first occurrence per parent_id.
-        // remove() instead of get()+clone() avoids copying 10+ Strings per result.
-        let mut seen_parents: HashSet<String> = HashSet::new();
-        let mut results: Vec<SearchResult> = final_scored
-            .into_iter()
-            .filter_map(|(id, score)| {
-                let row = rows_map.remove(&id)?;
-                let dedup_key = row.parent_id.clone().unwrap_or_else(|| row.id.clone());
-                if seen_parents.insert(dedup_key) {
-                    Some(SearchResult::new(ChunkSummary::from(row), score))
-                } else {
-                    None
-                }
-            })
-            .collect();
+        // Step 3: Parent dedup — one result per parent_id, ranked by its
+        // best hit. Neighbouring windows of a long symbol that also hit are
+        // stitched into that result rather than dropped.
+        let mut results: Vec<SearchResult> = dedup_and_merge_windows(final_scored, &mut rows_map);
 
         // Step 4: Boost container chunks when multiple child methods appear.
         // Returns the per-result boost multiplier for the `rank_signals`
@@ -667,7 +795,30 @@ impl<Mode> Store<Mode> {
             });
         }
 
-        // Step 4c: MMR re-rank for diversity (opt-in via SearchFilter or
+        // Step 4c: Project boost list (`cqs-boosts.toml`). Pins and
+        // deprecations multiply the whole post-boost pool, so a pinned chunk
+        // can climb onto the page and a deprecated one fall off it. See
+        // `crate::boosts`.
+        let boost_factors = match boosts {
+            Some(rules) => rules.apply(query_text, &mut results),
+            None => HashMap::new(),
+        };
+
+        // Step 4c': Deprecation markers (`chunks.deprecated`). Downranked by
+        // the `deprecated_penalty` knob unless a project boost rule already
+        // decided the chunk's fate.
+        let deprecated_factors = apply_deprecated_penalty(&mut results, &boost_factors);
+
+        // Step 4d: User ranking hook (opt-in via `[rank_hook]` or
+        // CQS_RANK_HOOK). Sees the whole post-boost pool so a demotion can
+        // push a result past the truncate; fails open. See
+        // `search::rank_hook`.
+        let hook_factors = match RankHook::configured() {
+            Some(hook) => apply_rank_hook(&hook, query_text, &mut results),
+            None => HashMap::new(),
+        };
+
+        // Step 4e: MMR re-rank for diversity (opt-in via SearchFilter or
         // CQS_MMR_LAMBDA env). Runs against the full post-dedup pool, which
         // is up to `limit * 2` (RRF) or `limit * 3` (no-RRF expansion). Pure
         // no-op when lambda is None or >= 1.0 — see `search::mmr::mmr_rerank`.
@@ -737,9 +888,38 @@ impl<Mode> Store<Mode> {
                 is_rrf: use_rrf,
                 suppress_note_boost: inputs.suppress_note_boost,
                 parent_boosts: &parent_boost_ref,
+                popularity: inputs.popularity,
+                feedback: inputs.feedback,
             };
             for r in &mut results {
                 r.rank_signals = signals_for(r, &signal_ctx);
+                if let Some(&factor) = boost_factors.get(&r.chunk.id) {
+                    r.rank_signals.push(RankSignal {
+                        signal: "boost",
+                        value: factor,
+                    });
+                }
+                if let Some(&factor) = deprecated_factors.get(&r.chunk.id) {
+                    r.rank_signals.push(RankSignal {
+                        signal: "deprecated",
+                        value: factor,
+                    });
+                }
+                if let Some(&factor) = hook_factors.get(&r.chunk.id).filter(|&&f| f != 1.0) {
+                    r.rank_signals.push(RankSignal {
+                        signal: "rank_hook",
+                        value: factor,
+                    });
+                }
+                if let Some(scores) = inputs.facet_scores.get(&r.chunk.id) {
+                    r.rank_signals.extend(scores.signals());
+                }
+                if let Some(&value) = inputs.late_scores.get(&r.chunk.id) {
+                    r.rank_signals.push(RankSignal {
+                        signal: "late_interaction",
+                        value,
+                    });
+                }
             }
         }
 
@@ -857,12 +1037,20 @@ impl<Mode> Store<Mode> {
         // saturating-muls to guard pathological `limit >= usize::MAX / 5`.
         let candidate_count = candidate_count_for(limit);
 
-        // Build chunk filter predicate
+        // Build chunk filter predicate. The origin / content allowlist
+        // resolves to chunk IDs up front, same as the index-guided path.
         let meta = self.chunk_type_language_map()?;
         let include_types = filter.include_types.as_ref();
         let exclude_types = filter.exclude_types.as_ref();
         let languages = filter.languages.as_ref();
+        let allowed_ids = self.chunk_id_allowlist(filter)?;
         let predicate = |chunk_id: &str| -> bool {
+            if allowed_ids
+                .as_ref()
+                .is_some_and(|ids| !ids.contains(chunk_id))
+            {
+                return false;
+            }
             if include_types.is_none() && exclude_types.is_none() && languages.is_none() {
                 return true;
             }
@@ -1069,7 +1257,11 @@ impl<Mode> Store<Mode> {
             threshold,
             &notes,
             Some(&fused_map),
+            // The fused base is not a cosine, so facet cosines can't blend
+            // into it.
+            None,
             sparse_ranks,
+            allowed_ids.as_ref(),
         )?;
         Ok((results, legs))
     }
@@ -1110,13 +1302,24 @@ impl<Mode> Store<Mode> {
             // empty results. Apply once for both filtered and unfiltered
             // arms below.
             let effective_k = cap_k_to_backend(idx, candidate_count);
-            let index_results = if has_type_or_lang_filter {
+            // Chunk allowlist (`--changed-since`, `--grep`): resolve to chunk
+            // IDs once so the traversal skips everything outside it. Left to
+            // a post-hoc gate, a narrow allowlist would starve the candidate
+            // pool long before it filled to `limit`.
+            let allowed_ids = self.chunk_id_allowlist(filter)?;
+            let index_results = if has_type_or_lang_filter || allowed_ids.is_some() {
                 // Build traversal-time filter from chunk metadata
                 let meta = self.chunk_type_language_map()?;
                 let include_types = filter.include_types.as_ref();
                 let exclude_types = filter.exclude_types.as_ref();
                 let languages = filter.languages.as_ref();
                 let predicate = |chunk_id: &str| -> bool {
+                    if allowed_ids
+                        .as_ref()
+                        .is_some_and(|ids| !ids.contains(chunk_id))
+                    {
+                        return false;
+                    }
                     if let Some((ct, lang)) = meta.get(chunk_id) {
                         let type_ok = include_types.is_none_or(|types| types.contains(ct));
                         let exclude_ok = exclude_types.is_none_or(|types| !types.contains(ct));
@@ -1139,7 +1342,14 @@ impl<Mode> Store<Mode> {
                     limit,
                     "Index returned no candidates — falling back to brute-force scan"
                 );
-                return self.search_filtered_with_notes(query, filter, limit, threshold, &notes);
+                return self.search_filtered_with_notes(
+                    query,
+                    filter,
+                    limit,
+                    threshold,
+                    &notes,
+                    allowed_ids.as_ref(),
+                );
             }
 
             tracing::debug!(
@@ -1179,12 +1389,22 @@ impl<Mode> Store<Mode> {
                 threshold,
                 &notes,
                 fused_scores.as_ref(),
+                filter.facet_weights.as_ref(),
                 // Index-guided dense path has no sparse leg.
                 None,
+                allowed_ids.as_ref(),
             );
         }
 
-        self.search_filtered_with_notes(query, filter, limit, threshold, &notes)
+        let allowed_ids = self.chunk_id_allowlist(filter)?;
+        self.search_filtered_with_notes(
+            query,
+            filter,
+            limit,
+            threshold,
+            &notes,
+            allowed_ids.as_ref(),
+        )
     }
 
     /// Search within a set of candidate IDs (for HNSW-guided filtered search)
@@ -1206,6 +1426,7 @@ impl<Mode> Store<Mode> {
                 std::sync::Arc::new(Vec::new())
             }
         };
+        let allowed_ids = self.chunk_id_allowlist(filter)?;
         self.search_by_candidate_ids_with_notes(
             candidate_ids,
             query,
@@ -1214,7 +1435,9 @@ impl<Mode> Store<Mode> {
             threshold,
             &notes,
             None,
+            filter.facet_weights.as_ref(),
             None,
+            allowed_ids.as_ref(),
         )
     }
 
@@ -1224,6 +1447,14 @@ impl<Mode> Store<Mode> {
     /// When `fused_scores` is `Some`, candidates with a fused score entry use that
     /// score as the base (replacing cosine similarity) while still applying name
     /// boost, note boost, demotion, and threshold filtering.
+    ///
+    /// When `facet_weights` is `Some`, a candidate with stored facet vectors
+    /// takes the weighted mean of its cosines (`crate::facets`) as the base
+    /// instead. Callers pass it only where the base is a cosine.
+    ///
+    /// When `filter.late_interaction` is `Some`, the ranked pool is cut to
+    /// its top `candidates` (at least `limit`) and each one with token
+    /// vectors is scored again from its MaxSim (`crate::late_interaction`).
     #[allow(clippy::too_many_arguments)]
     fn search_by_candidate_ids_with_notes(
         &self,
@@ -1234,9 +1465,14 @@ impl<Mode> Store<Mode> {
         threshold: f32,
         notes: &[NoteSummary],
         fused_scores: Option<&std::collections::HashMap<String, f32>>,
+        facet_weights: Option<&crate::facets::FacetWeights>,
         // Per-result sparse (SPLADE) leg rank, threaded from `search_hybrid`
         // for the `rank_signals` recorder. `None` on the non-SPLADE paths.
         sparse_ranks: Option<std::collections::HashMap<String, usize>>,
+        // The filter's resolved chunk allowlist (`chunk_id_allowlist`). The
+        // index paths already traversed under it; it gates the candidates of
+        // a direct `search_by_candidate_ids` call and the FTS leg.
+        allowed_ids: Option<&HashSet<String>>,
     ) -> Result<Vec<SearchResult>, StoreError> {
         let _span = tracing::info_span!(
             "search_by_candidates",
@@ -1254,7 +1490,10 @@ impl<Mode> Store<Mode> {
         let use_hybrid = flags.use_hybrid;
         let use_rrf = flags.use_rrf;
 
-        self.block_on(async {
+        self.read_retrying("search_by_candidates", || async {
+            let mut snap = self.begin_read().await?;
+            self.record_search_generation(&mut snap).await?;
+            let hidden = self.hidden_chunk_ids(&mut snap, filter).await?;
             // Phase 1: Lightweight candidate fetch — only scoring fields.
             // Excludes heavy content/doc/signature columns. The embedding
             // BLOB is fetched only when there are no pre-fused scores:
@@ -1263,8 +1502,20 @@ impl<Mode> Store<Mode> {
             // construction and the cosine fallback below never needs the
             // embedding on that path.
             let candidates = self
-                .fetch_candidates_by_ids_async(candidate_ids, fused_scores.is_none())
+                .fetch_candidates_by_ids_async(&mut snap, candidate_ids, fused_scores.is_none())
+                .instrument(tracing::debug_span!(
+                    "fetch_candidates",
+                    count = candidate_ids.len()
+                ))
                 .await?;
+            let facet_vectors = match facet_weights {
+                Some(_) => {
+                    self.fetch_facet_vectors_async(&mut snap, candidate_ids)
+                        .await?
+                }
+                None => HashMap::new(),
+            };
+            let mut facet_scores: HashMap<String, crate::facets::FacetScores> = HashMap::new();
 
             // Compile glob pattern once outside the loop (not per-chunk).
             let glob_matcher = compile_glob_filter(filter.path_pattern.as_ref());
@@ -1314,6 +1565,11 @@ impl<Mode> Store<Mode> {
             let mut scored: Vec<(CandidateRow, f32)> = candidates
                 .into_iter()
                 .filter_map(|(candidate, embedding_bytes)| {
+                    if allowed_ids.is_some_and(|ids| !ids.contains(&candidate.id))
+                        || hidden.contains(&candidate.id)
+                    {
+                        return None;
+                    }
                     // DB values are already canonical lowercase from
                     // Language::to_string / ChunkType::to_string, so use direct
                     // contains on the pre-lowercased set (no per-candidate
@@ -1330,14 +1586,9 @@ impl<Mode> Store<Mode> {
                         }
                     }
 
-                    let score =
+                    let base =
                         if let Some(&fused) = fused_scores.and_then(|fs| fs.get(&candidate.id)) {
-                            apply_scoring_pipeline(
-                                fused,
-                                Some(&candidate.name),
-                                &candidate.origin,
-                                &scoring_ctx,
-                            )?
+                            fused
                         } else {
                             // `embedding_bytes` is `Some` whenever
                             // `fused_scores` is `None` (the fetch above keys
@@ -1353,13 +1604,24 @@ impl<Mode> Store<Mode> {
                                 return None;
                             };
                             let embedding = embedding_slice(bytes, self.dim).ok()?;
-                            score_candidate(
-                                embedding,
-                                Some(&candidate.name),
-                                &candidate.origin,
-                                &scoring_ctx,
-                            )?
+                            crate::math::cosine_similarity(query.as_slice(), embedding)?
                         };
+                    let base = match (facet_weights, facet_vectors.get(&candidate.id)) {
+                        (Some(weights), Some(vectors)) => {
+                            let scores = vectors.scores(query.as_slice(), base);
+                            if filter.record_rank_signals {
+                                facet_scores.insert(candidate.id.clone(), scores);
+                            }
+                            scores.blend(weights)
+                        }
+                        _ => base,
+                    };
+                    let score = apply_scoring_pipeline(
+                        base,
+                        Some(&candidate.name),
+                        &candidate.origin,
+                        &scoring_ctx,
+                    )?;
 
                     Some((candidate, score))
                 })
@@ -1369,27 +1631,72 @@ impl<Mode> Store<Mode> {
             // process invocations.
             scored.sort_by(|a, b| b.1.total_cmp(&a.1).then(a.0.id.cmp(&b.0.id)));
 
+            let mut late_scores: HashMap<String, f32> = HashMap::new();
+            if let Some(late) = filter.late_interaction.as_deref() {
+                scored.truncate(late.candidates.max(limit));
+                let ids: Vec<&str> = scored.iter().map(|(c, _)| c.id.as_str()).collect();
+                let tokens = self.fetch_token_embeddings_async(&mut snap, &ids).await?;
+                // A candidate without token vectors keeps its first-stage
+                // score; one whose MaxSim falls under the threshold drops.
+                scored.retain_mut(|(candidate, score)| {
+                    let Some(maxsim) = tokens
+                        .get(&candidate.id)
+                        .and_then(|doc| late.tokens.maxsim(doc))
+                    else {
+                        return true;
+                    };
+                    if filter.record_rank_signals {
+                        late_scores.insert(candidate.id.clone(), maxsim);
+                    }
+                    match apply_scoring_pipeline(
+                        maxsim,
+                        Some(&candidate.name),
+                        &candidate.origin,
+                        &scoring_ctx,
+                    ) {
+                        Some(rescored) => {
+                            *score = rescored;
+                            true
+                        }
+                        None => false,
+                    }
+                });
+                scored.sort_by(|a, b| b.1.total_cmp(&a.1).then(a.0.id.cmp(&b.0.id)));
+            }
+
             let scored: Vec<(String, f32)> =
                 scored.into_iter().map(|(c, score)| (c.id, score)).collect();
 
-            let signal_inputs = filter.record_rank_signals.then_some(RankSignalInputs {
+            let signal_inputs = filter.record_rank_signals.then(|| RankSignalInputs {
                 note_index: &note_boost,
                 name_matcher: name_matcher.as_ref(),
                 enable_demotion: filter.enable_demotion,
                 suppress_note_boost: filter.suppress_note_boost,
-                sparse_ranks: sparse_ranks.unwrap_or_default(),
+                sparse_ranks: sparse_ranks.clone().unwrap_or_default(),
+                facet_scores: std::mem::take(&mut facet_scores),
+                late_scores: std::mem::take(&mut late_scores),
+                popularity: filter.popularity.as_deref(),
+                feedback: filter.feedback.as_deref(),
             });
-            self.finalize_results(
-                scored,
-                &filter.query_text,
-                use_rrf,
-                limit,
-                glob_matcher.as_ref(),
-                filter.type_boost_types.as_deref(),
-                filter.mmr_lambda,
-                signal_inputs,
-            )
-            .await
+            let results = self
+                .finalize_results(
+                    &mut snap,
+                    scored,
+                    &filter.query_text,
+                    use_rrf,
+                    filter.fts_scope,
+                    limit,
+                    glob_matcher.as_ref(),
+                    allowed_ids,
+                    &hidden,
+                    filter.type_boost_types.as_deref(),
+                    filter.boosts.as_deref(),
+                    filter.mmr_lambda,
+                    signal_inputs,
+                )
+                .await?;
+            snap.commit().await?;
+            Ok(results)
         })
     }
 
@@ -1587,6 +1894,135 @@ mod tests {
         assert_eq!(results[0].chunk.name, "rs_src");
     }
 
+    /// `--grep`: only chunks whose text matches the regex are ranked, and the
+    /// RRF keyword leg can't bring back a chunk the regex excluded.
+    #[test]
+    fn test_search_filtered_content_regex() {
+        let (store, _dir) = setup_store();
+
+        let with_rand = make_chunk(
+            "retry_rand_jitter",
+            "src/a.rs",
+            Language::Rust,
+            ChunkType::Function,
+        );
+        let without = make_chunk(
+            "retry_fixed_delay",
+            "src/b.rs",
+            Language::Rust,
+            ChunkType::Function,
+        );
+        let emb = mock_embedding(1.0);
+        store
+            .upsert_chunks_batch(
+                &[(with_rand, emb.clone()), (without, emb.clone())],
+                Some(12345),
+            )
+            .unwrap();
+
+        let filter = SearchFilter {
+            enable_rrf: true,
+            query_text: "retry".to_string(),
+            content_regex: Some(r"rand_\w+\(".to_string()),
+            ..Default::default()
+        };
+        let results = store.search_filtered(&emb, &filter, 10, 0.0).unwrap();
+        let names: Vec<&str> = results.iter().map(|r| r.chunk.name.as_str()).collect();
+        assert_eq!(names, ["retry_rand_jitter"]);
+    }
+
+    /// `--owner`: chunks in CODEOWNERS-owned origins and chunks whose blame
+    /// authors match both survive; everything else is filtered before ranking.
+    #[test]
+    fn test_search_filtered_owner() {
+        let (store, _dir) = setup_store();
+
+        let owned = make_chunk(
+            "retry_owned",
+            "src/pay.rs",
+            Language::Rust,
+            ChunkType::Function,
+        );
+        let authored = make_chunk(
+            "retry_authored",
+            "src/net.rs",
+            Language::Rust,
+            ChunkType::Function,
+        );
+        let other = make_chunk(
+            "retry_other",
+            "src/ui.rs",
+            Language::Rust,
+            ChunkType::Function,
+        );
+        let emb = mock_embedding(1.0);
+        store
+            .upsert_chunks_batch(
+                &[
+                    (owned, emb.clone()),
+                    (authored.clone(), emb.clone()),
+                    (other, emb.clone()),
+                ],
+                Some(12345),
+            )
+            .unwrap();
+        store
+            .upsert_chunk_authors(&[(
+                authored.id.clone(),
+                authored.content_hash.clone(),
+                vec![crate::ownership::AuthorShare {
+                    name: "payments".to_string(),
+                    email: "payments@acme.example".to_string(),
+                    lines: 1,
+                    share: 1.0,
+                }],
+            )])
+            .unwrap();
+
+        let filter = SearchFilter {
+            owner: Some(crate::ownership::OwnerFilter {
+                name: "payments".to_string(),
+                origins: ["src/pay.rs".to_string()].into_iter().collect(),
+            }),
+            ..Default::default()
+        };
+        let results = store.search_filtered(&emb, &filter, 10, 0.0).unwrap();
+        let mut names: Vec<&str> = results.iter().map(|r| r.chunk.name.as_str()).collect();
+        names.sort_unstable();
+        assert_eq!(names, ["retry_authored", "retry_owned"]);
+    }
+
+    #[test]
+    fn test_search_filtered_issue() {
+        let (store, _dir) = setup_store();
+
+        let mut linked = make_chunk(
+            "retry_linked",
+            "src/pay.rs",
+            Language::Rust,
+            ChunkType::Function,
+        );
+        linked.content = format!("// fixes acme/pay#567\n{}", linked.content);
+        let other = make_chunk(
+            "retry_other",
+            "src/ui.rs",
+            Language::Rust,
+            ChunkType::Function,
+        );
+        let emb = mock_embedding(1.0);
+        store
+            .upsert_chunks_batch(&[(linked, emb.clone()), (other, emb.clone())], Some(12345))
+            .unwrap();
+
+        let filter = SearchFilter {
+            issue: Some("#567".to_string()),
+            ..Default::default()
+        };
+        let results = store.search_filtered(&emb, &filter, 10, 0.0).unwrap();
+        let names: Vec<&str> = results.iter().map(|r| r.chunk.name.as_str()).collect();
+        assert_eq!(names, ["retry_linked"]);
+    }
+
     #[test]
     fn test_search_filtered_rrf_hybrid() {
         let (store, _dir) = setup_store();
@@ -1611,6 +2047,49 @@ mod tests {
         assert!(!results.is_empty(), "RRF hybrid should return results");
     }
 
+    /// An origin allowlist (`--changed-since`) holds on the RRF keyword leg
+    /// too: a keyword hit in an unchanged file must not be fused back in.
+    #[test]
+    fn test_search_filtered_rrf_respects_origin_allowlist() {
+        let (store, _dir) = setup_store();
+
+        let changed = make_chunk(
+            "retryBackoff",
+            "src/changed.rs",
+            Language::Rust,
+            ChunkType::Function,
+        );
+        let unchanged = make_chunk(
+            "zetaUniqueKeyword",
+            "src/unchanged.rs",
+            Language::Rust,
+            ChunkType::Function,
+        );
+        let emb = mock_embedding(1.0);
+        store
+            .upsert_chunks_batch(
+                &[(changed, emb.clone()), (unchanged, emb.clone())],
+                Some(12345),
+            )
+            .unwrap();
+
+        let filter = SearchFilter {
+            enable_rrf: true,
+            query_text: "zetaUniqueKeyword".to_string(),
+            origins: Some(["src/changed.rs".to_string()].into_iter().collect()),
+            ..Default::default()
+        };
+        let results = store.search_filtered(&emb, &filter, 10, 0.0).unwrap();
+        assert!(
+            results
+                .iter()
+                .all(|r| r.chunk.file == std::path::Path::new("src/changed.rs")),
+            "keyword leg must not surface unchanged files, got {:?}",
+            results.iter().map(|r| &r.chunk.file).collect::<Vec<_>>()
+        );
+        assert!(results.iter().any(|r| r.chunk.name == "retryBackoff"));
+    }
+
     /// The RRF keyword leg shares `fts_match_ids`' `needs_embedding = 0`
     /// gate: a zero-vec sentinel chunk (parser-stage write during a
     /// `--llm-summaries` reindex) whose text matches the FTS query must NOT
@@ -1695,6 +2174,135 @@ mod tests {
         assert_eq!(results[0].chunk.name, "parseConfig");
     }
 
+    /// A chunk whose doc carries a deprecation marker is stored flagged,
+    /// ranks below an otherwise identical live chunk, and is dropped under
+    /// `hide_deprecated` — from the RRF keyword leg as well.
+    #[test]
+    fn test_search_filtered_deprecated_downranked_or_hidden() {
+        let (store, _dir) = setup_store();
+
+        let mut old = make_chunk(
+            "loadConfig",
+            "src/a.rs",
+            Language::Rust,
+            ChunkType::Function,
+        );
+        old.doc = Some("// Deprecated: use loadConfigV2.".to_string());
+        let live = make_chunk(
+            "loadSettings",
+            "src/b.rs",
+            Language::Rust,
+            ChunkType::Function,
+        );
+        let emb = mock_embedding(1.0);
+        store
+            .upsert_chunks_batch(&[(old, emb.clone()), (live, emb.clone())], Some(12345))
+            .unwrap();
+
+        // Identical embeddings: only the penalty separates the two.
+        let results = store
+            .search_filtered(&emb, &SearchFilter::default(), 10, 0.0)
+            .unwrap();
+        let names: Vec<&str> = results.iter().map(|r| r.chunk.name.as_str()).collect();
+        assert_eq!(names, vec!["loadSettings", "loadConfig"]);
+        assert!(results[1].chunk.deprecated);
+        assert!(results[1].score < results[0].score);
+
+        let filter = SearchFilter {
+            enable_rrf: true,
+            query_text: "loadConfig".to_string(),
+            hide_deprecated: true,
+            ..Default::default()
+        };
+        let results = store.search_filtered(&emb, &filter, 10, 0.0).unwrap();
+        assert!(
+            results.iter().all(|r| r.chunk.name != "loadConfig"),
+            "hidden chunk surfaced: {:?}",
+            results.iter().map(|r| &r.chunk.name).collect::<Vec<_>>()
+        );
+    }
+
+    /// Codegen output is tagged at write time and left out of results
+    /// unless `include_generated`, on the keyword leg too.
+    #[test]
+    fn test_search_filtered_excludes_generated_by_default() {
+        let (store, _dir) = setup_store();
+
+        let generated = make_chunk(
+            "Reset",
+            "api/service.pb.go",
+            Language::Go,
+            ChunkType::Method,
+        );
+        let live = make_chunk(
+            "ResetCache",
+            "src/cache.go",
+            Language::Go,
+            ChunkType::Method,
+        );
+        let emb = mock_embedding(1.0);
+        store
+            .upsert_chunks_batch(
+                &[(generated, emb.clone()), (live, emb.clone())],
+                Some(12345),
+            )
+            .unwrap();
+        assert_eq!(store.boilerplate_chunk_counts().unwrap(), (1, 0));
+
+        let filter = SearchFilter {
+            enable_rrf: true,
+            query_text: "Reset".to_string(),
+            ..Default::default()
+        };
+        let results = store.search_filtered(&emb, &filter, 10, 0.0).unwrap();
+        let names: Vec<&str> = results.iter().map(|r| r.chunk.name.as_str()).collect();
+        assert_eq!(names, vec!["ResetCache"]);
+
+        let filter = SearchFilter {
+            include_generated: true,
+            ..filter
+        };
+        let results = store.search_filtered(&emb, &filter, 10, 0.0).unwrap();
+        let generated = results
+            .iter()
+            .find(|r| r.chunk.name == "Reset")
+            .expect("--include-generated brings the chunk back");
+        assert_eq!(
+            generated.chunk.boilerplate,
+            Some(crate::boilerplate::Boilerplate::Generated)
+        );
+    }
+
+    #[test]
+    fn test_search_records_index_generation() {
+        let (store, _dir) = setup_store();
+        let emb = mock_embedding(1.0);
+        let chunk = make_chunk("load", "src/lib.rs", Language::Rust, ChunkType::Function);
+        store
+            .upsert_chunks_batch(&[(chunk, emb.clone())], Some(12345))
+            .unwrap();
+
+        crate::store::clear_search_generation();
+        store
+            .search_filtered(&emb, &SearchFilter::default(), 10, 0.0)
+            .unwrap();
+        let first = crate::store::take_search_generation().expect("search records it");
+        assert_eq!(first, store.index_generation().unwrap());
+        assert!(crate::store::take_search_generation().is_none());
+
+        // Indexing another chunk is a new generation of the same index.
+        let other = make_chunk("save", "src/lib.rs", Language::Rust, ChunkType::Function);
+        store
+            .upsert_chunks_batch(&[(other, emb.clone())], Some(12345))
+            .unwrap();
+        store
+            .search_filtered(&emb, &SearchFilter::default(), 10, 0.0)
+            .unwrap();
+        let second = crate::store::take_search_generation().unwrap();
+        assert_eq!(second.epoch, first.epoch);
+        assert!(second.counter > first.counter);
+    }
+
     #[test]
     fn test_search_filtered_empty_store() {
         let (store, _dir) = setup_store();
@@ -0,0 +1,384 @@
+//! User-provided ranking hook.
+//!
+//! Org-specific rules ("deprioritize `legacy/`", "prefer the new client")
+//! don't belong in the shared scoring code. A hook is an external command
+//! that sees the fused candidate pool and may rescore it. It runs once per
+//! search, after the type boost and before MMR and the final truncate, so
+//! it sees up to `limit * 2` candidates and a demoted one can fall out of
+//! the page.
+//!
+//! The command gets one JSON object per candidate on stdin, best first:
+//!
+//! ```json
+//! {"query":"retry with backoff","rank":1,"id":"src/net.rs:40:1a2b3c4d","file":"src/net.rs","name":"retry","chunk_type":"function","language":"rust","line_start":40,"line_end":71,"score":0.83}
+//! ```
+//!
+//! and answers with one `{"id": ..., "score": ...}` line per candidate it
+//! wants to rescore. Candidates it doesn't mention keep their score; ids it
+//! makes up are ignored. Results are re-sorted by the new scores.
+//!
+//! The hook fails open: if it can't start, exits non-zero, outlives its
+//! timeout, or prints anything that isn't a valid score line, the search
+//! goes ahead with the original ranking and a warning is logged.
+//!
+//! Configured by `[rank_hook] command = [...]` (argv, no shell) and
+//! `timeout_ms`, or `CQS_RANK_HOOK` (a whitespace-split command line; `0`
+//! disables a configured hook) and `CQS_RANK_HOOK_TIMEOUT_MS`. Env wins.
+
+use std::collections::HashMap;
+use std::io::{Read, Write};
+use std::process::{Command, Stdio};
+use std::sync::OnceLock;
+use std::time::{Duration, Instant};
+
+use serde::{Deserialize, Serialize};
+
+use crate::config::RankHookConfig;
+use crate::store::helpers::SearchResult;
+
+/// Wall-clock budget for one hook run unless configured otherwise.
+const DEFAULT_TIMEOUT: Duration = Duration::from_millis(500);
+
+/// Cap on the hook's stdout. A few hundred score lines fit in a fraction
+/// of this.
+const MAX_OUTPUT_BYTES: u64 = 4 * 1024 * 1024;
+
+static RANK_HOOK_CONFIG: OnceLock<RankHookConfig> = OnceLock::new();
+
+/// Install `[rank_hook]` from the config file. Env still wins.
+pub fn set_from_config(config: &RankHookConfig) {
+    let _ = RANK_HOOK_CONFIG.set(config.clone());
+}
+
+#[derive(Debug, thiserror::Error)]
+pub(crate) enum RankHookError {
+    #[error("failed to run: {0}")]
+    Spawn(std::io::Error),
+    #[error("timed out after {0:?}")]
+    Timeout(Duration),
+    #[error("exited with {status}: {stderr}")]
+    Exit {
+        status: std::process::ExitStatus,
+        stderr: String,
+    },
+    #[error("bad output line {line}: {reason}")]
+    Output { line: usize, reason: String },
+}
+
+/// A resolved hook: what to run and how long to wait for it.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub(crate) struct RankHook {
+    pub(crate) argv: Vec<String>,
+    pub(crate) timeout: Duration,
+}
+
+impl RankHook {
+    /// The active hook, if any. `CQS_RANK_HOOK` / `CQS_RANK_HOOK_TIMEOUT_MS`
+    /// env > `[rank_hook]` > none.
+    pub(crate) fn configured() -> Option<Self> {
+        let config = RANK_HOOK_CONFIG.get();
+        let argv: Vec<String> = match std::env::var("CQS_RANK_HOOK") {
+            Ok(v) if v.trim() == "0" => return None,
+            Ok(v) => v.split_whitespace().map(str::to_string).collect(),
+            Err(_) => config.map(|c| c.command.clone()).unwrap_or_default(),
+        };
+        if argv.is_empty() {
+            return None;
+        }
+        let timeout = std::env::var("CQS_RANK_HOOK_TIMEOUT_MS")
+            .ok()
+            .and_then(|v| v.parse::<u64>().ok())
+            .or_else(|| config.and_then(|c| c.timeout_ms))
+            .filter(|&ms| ms > 0)
+            .map_or(DEFAULT_TIMEOUT, Duration::from_millis);
+        Some(Self { argv, timeout })
+    }
+}
+
+/// One candidate line on the hook's stdin.
+#[derive(Serialize)]
+struct HookCandidate<'a> {
+    query: &'a str,
+    rank: usize,
+    id: &'a str,
+    file: String,
+    name: &'a str,
+    chunk_type: String,
+    language: String,
+    line_start: u32,
+    line_end: u32,
+    score: f32,
+}
+
+/// One score line on the hook's stdout.
+#[derive(Deserialize)]
+struct HookScore {
+    id: String,
+    score: f32,
+}
+
+/// Run `hook` over `results` and apply the scores it returns, re-sorting
+/// by score (chunk id breaks ties). Returns `new / old` per rescored chunk
+/// for the `rank_hook` provenance signal. On any hook failure `results`
+/// are left as they were.
+pub(crate) fn apply_rank_hook(
+    hook: &RankHook,
+    query: &str,
+    results: &mut [SearchResult],
+) -> HashMap<String, f32> {
+    let _span = tracing::debug_span!("rank_hook", candidates = results.len()).entered();
+    if results.is_empty() {
+        return HashMap::new();
+    }
+    let scores = match run_hook(hook, &candidate_lines(query, results)) {
+        Ok(out) => match parse_scores(&out) {
+            Ok(scores) => scores,
+            Err(e) => {
+                tracing::warn!(command = %hook.argv[0], error = %e, "Rank hook ignored");
+                return HashMap::new();
+            }
+        },
+        Err(e) => {
+            tracing::warn!(command = %hook.argv[0], error = %e, "Rank hook ignored");
+            return HashMap::new();
+        }
+    };
+
+    let mut factors = HashMap::new();
+    for r in results.iter_mut() {
+        if let Some(&score) = scores.get(r.chunk.id.as_str()) {
+            if r.score != 0.0 {
+                factors.insert(r.chunk.id.clone(), score / r.score);
+            }
+            r.score = score;
+        }
+    }
+    results.sort_by(|a, b| {
+        b.score
+            .total_cmp(&a.score)
+            .then(a.chunk.id.cmp(&b.chunk.id))
+    });
+    tracing::debug!(rescored = factors.len(), "Rank hook applied");
+    factors
+}
+
+/// The NDJSON fed to the hook, one candidate per line in rank order.
+fn candidate_lines(query: &str, results: &[SearchResult]) -> Vec<u8> {
+    let mut out = Vec::new();
+    for (i, r) in results.iter().enumerate() {
+        let line = HookCandidate {
+            query,
+            rank: i + 1,
+            id: &r.chunk.id,
+            file: crate::normalize_path(&r.chunk.file),
+            name: &r.chunk.name,
+            chunk_type: r.chunk.chunk_type.to_string(),
+            language: r.chunk.language.to_string(),
+            line_start: r.chunk.line_start,
+            line_end: r.chunk.line_end,
+            score: r.score,
+        };
+        // Serializing plain strings and numbers cannot fail.
+        if serde_json::to_writer(&mut out, &line).is_ok() {
+            out.push(b'\n');
+        }
+    }
+    out
+}
+
+/// Score lines by chunk id. Blank lines are skipped; anything else that
+/// isn't a `{"id", "score"}` object with a finite score rejects the whole
+/// answer, since a half-understood hook is worse than none.
+fn parse_scores(out: &[u8]) -> Result<HashMap<String, f32>, RankHookError> {
+    let text = String::from_utf8_lossy(out);
+    let mut scores = HashMap::new();
+    for (i, line) in text.lines().enumerate() {
+        if line.trim().is_empty() {
+            continue;
+        }
+        let bad = |reason: String| RankHookError::Output {
+            line: i + 1,
+            reason,
+        };
+        let parsed: HookScore = serde_json::from_str(line).map_err(|e| bad(e.to_string()))?;
+        if !parsed.score.is_finite() {
+            return Err(bad(format!("score {} is not finite", parsed.score)));
+        }
+        scores.insert(parsed.id, parsed.score);
+    }
+    Ok(scores)
+}
+
+/// Run the hook with `input` on stdin and return its stdout. Stdin is
+/// written and stdout drained on their own threads, so a hook that answers
+/// before reading everything can't deadlock; the caller's thread polls for
+/// exit and kills the hook at its deadline.
+fn run_hook(hook: &RankHook, input: &[u8]) -> Result<Vec<u8>, RankHookError> {
+    let mut child = Command::new(&hook.argv[0])
+        .args(&hook.argv[1..])
+        .stdin(Stdio::piped())
+        .stdout(Stdio::piped())
+        .stderr(Stdio::piped())
+        .spawn()
+        .map_err(RankHookError::Spawn)?;
+
+    let stdin = child.stdin.take();
+    let input = input.to_vec();
+    let writer = std::thread::spawn(move || {
+        if let Some(mut stdin) = stdin {
+            // A hook that exits without reading closes the pipe; that's its
+            // business, not an error.
+            let _ = stdin.write_all(&input);
+        }
+    });
+    let stdout = child.stdout.take();
+    let reader = std::thread::spawn(move || {
+        let mut buf = Vec::new();
+        if let Some(s) = stdout {
+            let _ = s.take(MAX_OUTPUT_BYTES).read_to_end(&mut buf);
+        }
+        buf
+    });
+    let stderr = child.stderr.take();
+    let err_reader = std::thread::spawn(move || {
+        let mut buf = Vec::new();
+        if let Some(s) = stderr {
+            let _ = s.take(64 * 1024).read_to_end(&mut buf);
+        }
+        buf
+    });
+
+    // On a timeout or wait error the pipe threads are left detached rather
+    // than joined: a grandchild the hook started may still hold the pipes,
+    // and the search must not wait on it.
+    let deadline = Instant::now() + hook.timeout;
+    let status = loop {
+        match child.try_wait() {
+            Ok(Some(status)) => break status,
+            Ok(None) if Instant::now() >= deadline => {
+                let _ = child.kill();
+                let _ = child.wait();
+                return Err(RankHookError::Timeout(hook.timeout));
+            }
+            Ok(None) => std::thread::sleep(Duration::from_millis(2)),
+            Err(e) => {
+                let _ = child.kill();
+                let _ = child.wait();
+                return Err(RankHookError::Spawn(e));
+            }
+        }
+    };
+    let _ = writer.join();
+    let stdout = reader.join().unwrap_or_default();
+    let stderr = err_reader.join().unwrap_or_default();
+    if !status.success() {
+        return Err(RankHookError::Exit {
+            status,
+            stderr: String::from_utf8_lossy(&stderr).trim().to_string(),
+        });
+    }
+    Ok(stdout)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::parser::{ChunkType, Language};
+    use crate::store::helpers::ChunkSummary;
+
+    fn result(file: &str, name: &str, score: f32) -> SearchResult {
+        SearchResult::new(
+            ChunkSummary {
+                id: format!("{file}:1:{name}"),
+                file: std::path::PathBuf::from(file),
+                language: Language::Rust,
+                chunk_type: ChunkType::Function,
+                name: name.to_string(),
+                signature: String::new(),
+                content: String::new(),
+                doc: None,
+                line_start: 1,
+                line_end: 5,
+                parent_id: None,
+                parent_type_name: None,
+                content_hash: String::new(),
+                window_idx: None,
+                parser_version: 0,
+                vendored: false,
+                deprecated: false,
+                boilerplate: None,
+            },
+            score,
+        )
+    }
+
+    fn sh(script: &str, timeout_ms: u64) -> RankHook {
+        RankHook {
+            argv: vec!["sh".to_string(), "-c".to_string(), script.to_string()],
+            timeout: Duration::from_millis(timeout_ms),
+        }
+    }
+
+    fn names(results: &[SearchResult]) -> Vec<&str> {
+        results.iter().map(|r| r.chunk.name.as_str()).collect()
+    }
+
+    #[test]
+    fn candidate_lines_carry_query_rank_and_chunk_fields() {
+        let lines = candidate_lines("retry", &[result("src/net.rs", "retry", 0.8)]);
+        let v: serde_json::Value = serde_json::from_slice(&lines).unwrap();
+        assert_eq!(v["query"], "retry");
+        assert_eq!(v["rank"], 1);
+        assert_eq!(v["file"], "src/net.rs");
+        assert_eq!(v["chunk_type"], "function");
+        assert_eq!(v["language"], "rust");
+    }
+
+    #[test]
+    fn parse_scores_rejects_malformed_answers() {
+        let ok =
+            parse_scores(b"{\"id\":\"a\",\"score\":0.5}\n\n{\"id\":\"b\",\"score\":2}\n").unwrap();
+        assert_eq!(ok.len(), 2);
+        assert_eq!(ok["b"], 2.0);
+        assert!(parse_scores(b"{\"id\":\"a\",\"score\":0.5}\nnot json\n").is_err());
+        assert!(parse_scores(b"{\"id\":\"a\"}\n").is_err());
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn hook_rescores_and_reorders() {
+        let mut results = vec![
+            result("legacy/net.rs", "old_retry", 0.9),
+            result("src/net.rs", "retry", 0.7),
+        ];
+        // Sink everything under legacy/.
+        let hook = sh(
+            r#"grep '"file":"legacy/' | sed 's/.*"id":"\([^"]*\)".*/{"id":"\1","score":0.1}/'"#,
+            5_000,
+        );
+        let factors = apply_rank_hook(&hook, "retry", &mut results);
+        assert_eq!(names(&results), ["retry", "old_retry"]);
+        assert!((results[1].score - 0.1).abs() < 1e-6);
+        assert_eq!(factors.len(), 1);
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn failing_hooks_leave_the_ranking_alone() {
+        let original = vec![result("a.rs", "a", 0.9), result("b.rs", "b", 0.5)];
+        for hook in [
+            sh("exit 3", 5_000),
+            sh("sleep 5", 100),
+            sh("echo nonsense", 5_000),
+            RankHook {
+                argv: vec!["/nonexistent/cqs-rank-hook".to_string()],
+                timeout: DEFAULT_TIMEOUT,
+            },
+        ] {
+            let mut results = original.clone();
+            assert!(apply_rank_hook(&hook, "q", &mut results).is_empty());
+            assert_eq!(names(&results), ["a", "b"]);
+            assert_eq!(results[0].score, 0.9);
+        }
+    }
+}
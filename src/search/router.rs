@@ -393,7 +393,14 @@ const LANGUAGE_ALIASES: &[&str] = &["c++", "c#"];
 /// alias list is a compile-time constant, so every subsequent call
 /// returns a borrow of the same `Vec`.
 static LANGUAGE_NAMES: LazyLock<Vec<&'static str>> = LazyLock::new(|| {
-    let mut names: Vec<&'static str> = REGISTRY.all().map(|def| def.name).collect();
+    // "plain" is the grammar-less text fallback, not a language anyone
+    // translates between — as a query word it means "simple". "plugin" is
+    // the umbrella for runtime-loaded grammars, not a language either.
+    let mut names: Vec<&'static str> = REGISTRY
+        .all()
+        .map(|def| def.name)
+        .filter(|name| !matches!(*name, "plain" | "plugin"))
+        .collect();
     for alias in LANGUAGE_ALIASES {
         if !names.contains(alias) {
             names.push(alias);
@@ -457,6 +457,24 @@ impl ScoreSignal for GlobGate {
     }
 }
 
+/// Hard gate: reject candidates whose origin isn't in `filter.origins`
+/// (`--changed-since`). Active when an origin allowlist is present; both
+/// paths use it. A pure gate — it never moves a surviving score.
+struct OriginGate;
+
+impl ScoreSignal for OriginGate {
+    fn enabled(&self, ctx: &ScoringContext<'_>) -> bool {
+        ctx.filter.origins.is_some()
+    }
+
+    fn apply(&self, current: f32, ctx: &ScoringContext<'_>, chunk: &ChunkMeta<'_>) -> Option<f32> {
+        match ctx.filter.origins {
+            Some(ref origins) if !origins.contains(chunk.file) => None,
+            _ => Some(current),
+        }
+    }
+}
+
 /// Multiply by the note-sentiment boost (`1.0 + sentiment * factor`).
 ///
 /// Also floors the running score at 0.0 first — `current.max(0.0)` is part
@@ -499,6 +517,63 @@ impl ScoreSignal for ImportanceDemotion {
     }
 }
 
+/// Multiply by the access-stats popularity prior
+/// (`1.0 + popularity_weight * popularity`, see [`crate::access`]). Active
+/// when the caller loaded a prior into `filter.popularity`, which it does
+/// only when the `popularity_weight` knob is above 0; both paths use it.
+struct PopularityBoost;
+
+impl ScoreSignal for PopularityBoost {
+    fn enabled(&self, ctx: &ScoringContext<'_>) -> bool {
+        ctx.filter.popularity.is_some()
+    }
+
+    fn apply(&self, current: f32, ctx: &ScoringContext<'_>, chunk: &ChunkMeta<'_>) -> Option<f32> {
+        match ctx.filter.popularity {
+            Some(ref prior) => {
+                Some(current * popularity_multiplier(prior, chunk.file, chunk.name_or_empty()))
+            }
+            None => Some(current),
+        }
+    }
+}
+
+/// `1.0 + popularity_weight * popularity` for one chunk. Shared with the
+/// `rank_signals` recorder.
+pub(crate) fn popularity_multiplier(
+    prior: &crate::access::PopularityPrior,
+    file: &str,
+    name: &str,
+) -> f32 {
+    1.0 + ScoringConfig::current().popularity_weight * prior.get(file, name)
+}
+
+/// Multiply by the relevance-feedback prior learned from `cqs feedback`
+/// (see [`crate::feedback`]). Active when the caller loaded a prior into
+/// `filter.feedback`, which it does only when the `feedback_weight` knob is
+/// above 0; both paths use it.
+struct FeedbackBoost;
+
+impl ScoreSignal for FeedbackBoost {
+    fn enabled(&self, ctx: &ScoringContext<'_>) -> bool {
+        ctx.filter.feedback.is_some()
+    }
+
+    fn apply(&self, current: f32, ctx: &ScoringContext<'_>, chunk: &ChunkMeta<'_>) -> Option<f32> {
+        match ctx.filter.feedback {
+            Some(ref prior) => Some(current * feedback_multiplier(prior, chunk.file)),
+            None => Some(current),
+        }
+    }
+}
+
+/// `(1.0 + w * path_score) * (1.0 + w * language_score)` with
+/// `w = feedback_weight`. Shared with the `rank_signals` recorder.
+pub(crate) fn feedback_multiplier(prior: &crate::feedback::FeedbackPrior, file: &str) -> f32 {
+    let w = ScoringConfig::current().feedback_weight;
+    (1.0 + w * prior.path_score(file)) * (1.0 + w * prior.language_score(file))
+}
+
 /// Hard gate: reject candidates scoring below the threshold (inclusive
 /// boundary — `score >= threshold` passes). Always enabled; both paths use
 /// it. Must remain the final signal so every boost/demotion is reflected in
@@ -524,14 +599,17 @@ impl ScoreSignal for ThresholdGate {
 pub(crate) const SCORE_SIGNALS: &[&dyn ScoreSignal] = &[
     &NameBlend,
     &GlobGate,
+    &OriginGate,
     &NoteBoostSignal,
     &ImportanceDemotion,
+    &PopularityBoost,
+    &FeedbackBoost,
     &ThresholdGate,
 ];
 
 /// Apply the scoring pipeline to a pre-computed base score: a fold over
-/// [`SCORE_SIGNALS`] (name blend → glob gate → note boost → demotion →
-/// threshold gate).
+/// [`SCORE_SIGNALS`] (name blend → glob gate → origin gate → note boost →
+/// demotion → popularity → feedback → threshold gate).
 ///
 /// Used by `score_candidate` (base = cosine) and the hybrid search path
 /// (base = alpha-weighted dense+sparse fusion).
@@ -733,6 +811,8 @@ mod tests {
                 window_idx: None,
                 parser_version: 0,
                 vendored: false,
+                deprecated: false,
+                boilerplate: None,
             },
             score,
         )
@@ -1195,6 +1275,37 @@ mod tests {
         assert!(score.is_none());
     }
 
+    #[test]
+    fn test_score_candidate_origin_allowlist_filters() {
+        let emb = test_embedding(1.0);
+        let query = test_embedding(1.0);
+        let filter = SearchFilter {
+            origins: Some(["src/lib.rs".to_string()].into_iter().collect()),
+            ..Default::default()
+        };
+        let note_index = NoteBoost::Borrowed(NoteBoostIndex::new(&[]));
+        let ctx = ScoringContext {
+            query: &query,
+            filter: &filter,
+            name_matcher: None,
+            glob_matcher: None,
+            note_index: &note_index,
+            threshold: 0.0,
+        };
+        let unrestricted = SearchFilter::default();
+        let base_ctx = ScoringContext {
+            filter: &unrestricted,
+            ..ctx
+        };
+
+        // The gate passes allowed origins through at their unrestricted score.
+        assert_eq!(
+            score_candidate(&emb, None, "src/lib.rs", &ctx),
+            score_candidate(&emb, None, "src/lib.rs", &base_ctx)
+        );
+        assert!(score_candidate(&emb, None, "src/main.rs", &ctx).is_none());
+    }
+
     #[test]
     fn test_score_candidate_name_boost() {
         let emb = test_embedding(1.0);
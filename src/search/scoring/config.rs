@@ -2,17 +2,21 @@
 //!
 //! `ScoringConfig` is the per-search snapshot of every score-tier knob
 //! (name match tiers, note boost factor, importance demotion weights,
-//! parent boost). The values come from
+//! parent boost, popularity and feedback weights). The values come from
 //! [`crate::search::scoring::knob::resolve_knob`] — adding a new knob
 //! is one row in `SCORING_KNOBS`, not a field here.
 //!
 //! Consumers should call [`ScoringConfig::current`] to get the live
-//! snapshot (cached process-wide) and read fields off the result.
+//! snapshot (cached process-wide, swapped on a config reload) and read
+//! fields off the result.
 //! `DEFAULT` is preserved as a const so tests and reference paths can
 //! anchor against the unchanged baseline values without going through
 //! the resolver.
 
-use std::sync::OnceLock;
+use std::sync::atomic::{AtomicPtr, Ordering};
+
+/// The snapshot [`ScoringConfig::current`] hands out; null until first use.
+static CURRENT: AtomicPtr<ScoringConfig> = AtomicPtr::new(std::ptr::null_mut());
 
 /// Per-search snapshot of score-tier knobs.
 pub(crate) struct ScoringConfig {
@@ -25,6 +29,8 @@ pub(crate) struct ScoringConfig {
     pub importance_private: f32,
     pub parent_boost_per_child: f32,
     pub parent_boost_cap: f32,
+    pub popularity_weight: f32,
+    pub feedback_weight: f32,
 }
 
 impl ScoringConfig {
@@ -44,30 +50,62 @@ impl ScoringConfig {
         importance_private: 0.80,
         parent_boost_per_child: 0.05,
         parent_boost_cap: 1.15,
+        popularity_weight: 0.0,
+        feedback_weight: 0.0,
     };
 
     /// Live snapshot of all score-tier knobs, resolved through
     /// [`crate::search::scoring::knob::resolve_knob`]. Cached
     /// process-wide on first call (every score-tier knob is
-    /// `cache: true` in `SCORING_KNOBS`).
+    /// `cache: true` in `SCORING_KNOBS`) and replaced on a config
+    /// reload.
     ///
     /// Returns `&'static Self` so callers can store the reference
     /// across a search without copying the struct.
     pub fn current() -> &'static Self {
-        static CURRENT: OnceLock<ScoringConfig> = OnceLock::new();
-        CURRENT.get_or_init(|| {
-            use super::knob::resolve_knob;
-            Self {
-                name_exact: resolve_knob("name_exact"),
-                name_contains: resolve_knob("name_contains"),
-                name_contained_by: resolve_knob("name_contained_by"),
-                name_max_overlap: resolve_knob("name_max_overlap"),
-                note_boost_factor: resolve_knob("note_boost_factor"),
-                importance_test: resolve_knob("importance_test"),
-                importance_private: resolve_knob("importance_private"),
-                parent_boost_per_child: resolve_knob("parent_boost_per_child"),
-                parent_boost_cap: resolve_knob("parent_boost_cap"),
-            }
-        })
+        let ptr = CURRENT.load(Ordering::Acquire);
+        if !ptr.is_null() {
+            // SAFETY: every non-null pointer stored in CURRENT comes from
+            // `Box::leak` in `leaked` and is never freed.
+            return unsafe { &*ptr };
+        }
+        let fresh = Self::leaked(super::knob::resolve_knob);
+        // First use only: lose to a concurrent first use or a reload that
+        // already published, rather than overwrite a newer snapshot.
+        match CURRENT.compare_exchange(
+            std::ptr::null_mut(),
+            std::ptr::from_ref(fresh).cast_mut(),
+            Ordering::AcqRel,
+            Ordering::Acquire,
+        ) {
+            Ok(_) => fresh,
+            // SAFETY: as above — a leaked, never-freed snapshot.
+            Err(existing) => unsafe { &*existing },
+        }
+    }
+
+    /// Build a snapshot from `resolve` and make it the one [`Self::current`]
+    /// returns. The previous snapshot is leaked, not freed: a search that
+    /// started before the swap may still hold it. That is one small struct
+    /// per reload, and reloads are operator-triggered.
+    pub(super) fn publish(resolve: impl Fn(&str) -> f32) {
+        let fresh = Self::leaked(resolve);
+        CURRENT.store(std::ptr::from_ref(fresh).cast_mut(), Ordering::Release);
+    }
+
+    fn leaked(resolve: impl Fn(&str) -> f32) -> &'static Self {
+        Box::leak(Box::new(Self {
+            name_exact: resolve("name_exact"),
+            name_contains: resolve("name_contains"),
+            name_contained_by: resolve("name_contained_by"),
+            name_max_overlap: resolve("name_max_overlap"),
+            note_boost_factor: resolve("note_boost_factor"),
+            importance_test: resolve("importance_test"),
+            importance_private: resolve("importance_private"),
+            parent_boost_per_child: resolve("parent_boost_per_child"),
+            parent_boost_cap: resolve("parent_boost_cap"),
+            popularity_weight: resolve("popularity_weight"),
+            feedback_weight: resolve("feedback_weight"),
+        }))
     }
 }
@@ -68,10 +68,16 @@ pub(crate) fn build_filter_sql(filter: &SearchFilter) -> FilterSql {
         }
     }
 
+    if filter.hide_deprecated {
+        conditions.push("deprecated = 0".to_string());
+    }
+
     let use_hybrid = filter.name_boost > 0.0
         && !filter.query_text.is_empty()
         && is_name_like_query(&filter.query_text);
-    let use_rrf = filter.enable_rrf && !filter.query_text.is_empty();
+    // A scoped keyword leg (`--in`) implies RRF: the scope only acts there.
+    let use_rrf = (filter.enable_rrf || filter.fts_scope != crate::store::FtsScope::Both)
+        && !filter.query_text.is_empty();
 
     // Select columns: always id + origin + embedding, optionally name for
     // hybrid scoring or demotion (test function detection needs the name).
@@ -174,6 +180,17 @@ mod tests {
         assert_eq!(fsql.bind_values.len(), 1);
     }
 
+    #[test]
+    fn test_build_filter_sql_hide_deprecated() {
+        let filter = SearchFilter {
+            hide_deprecated: true,
+            ..Default::default()
+        };
+        let fsql = build_filter_sql(&filter);
+        assert_eq!(fsql.conditions, vec!["deprecated = 0".to_string()]);
+        assert!(fsql.bind_values.is_empty());
+    }
+
     #[test]
     fn test_build_filter_sql_combined_filters() {
         use crate::parser::{ChunkType, Language};
@@ -229,6 +246,16 @@ mod tests {
         assert!(!fsql.use_rrf);
     }
 
+    #[test]
+    fn test_build_filter_sql_fts_scope_implies_rrf() {
+        let filter = SearchFilter {
+            query_text: "why retry".to_string(),
+            fts_scope: crate::store::FtsScope::Comments,
+            ..Default::default()
+        };
+        assert!(build_filter_sql(&filter).use_rrf);
+    }
+
     // ===== language/chunk_type filter set tests =====
 
     #[test]
@@ -7,11 +7,17 @@ use super::BoundedScoreHeap;
 
 /// Push a `[scoring]` section's knob overrides into the shared resolver.
 /// Must be called before the first search; subsequent calls are no-ops
-/// (delegates to [`knob::set_overrides_from_config`], which is OnceLock).
+/// (delegates to [`knob::set_overrides_from_config`], where the first set wins).
 pub fn set_rrf_k_from_config(overrides: &crate::config::ScoringOverrides) {
     knob::set_overrides_from_config(&overrides.knobs);
 }
 
+/// Swap in a reloaded `[scoring]` section (`None` = section removed) for a
+/// long-running process. See [`knob::replace_overrides_from_config`].
+pub fn reload_scoring_from_config(overrides: Option<&crate::config::ScoringOverrides>) {
+    knob::replace_overrides_from_config(overrides.map(|o| &o.knobs));
+}
+
 /// RRF constant K. Resolved via the shared knob table — see
 /// `src/search/scoring/knob.rs` for the resolution order
 /// (config → `CQS_RRF_K` env → default 60.0).
@@ -8,7 +8,8 @@
 //!
 //! For each knob, [`resolve_knob`] walks:
 //!
-//! 1. Config override (set via [`set_overrides_from_config`])
+//! 1. Config override (set via [`set_overrides_from_config`], swapped by
+//!    [`replace_overrides_from_config`] on a config reload)
 //! 2. Environment variable (per-knob — `None` skips this step)
 //! 3. Default value
 //!
@@ -17,14 +18,15 @@
 //! Each knob declares `cache: bool`:
 //!
 //! - `true` — value cached at first read across the process. Faster, but
-//!   means env-var changes after the first call are ignored. Use for
-//!   knobs that don't need to track per-search env changes.
+//!   means env-var changes after the first call are ignored until the next
+//!   config reload. Use for knobs that don't need to track per-search env
+//!   changes.
 //! - `false` — re-resolved on every call. Required for knobs swept by
 //!   `evals/run_sweep.py`, where each run mutates `CQS_<KNOB>` between
 //!   queries within the same `cqs` process.
 
 use std::collections::HashMap;
-use std::sync::OnceLock;
+use std::sync::RwLock;
 
 /// One row in [`SCORING_KNOBS`].
 #[derive(Debug, Clone, Copy)]
@@ -149,17 +151,94 @@ pub static SCORING_KNOBS: &[ScoringKnob] = &[
         max: 2.0,
         cache: true,
     },
+    // Per-column bm25 weights for the RRF keyword leg: `content` (code
+    // with comments stripped) and `doc` + `comments` (schema v40). Raise
+    // the comment weight to favour "why" explanations over code mentions.
+    ScoringKnob {
+        name: "fts_code_weight",
+        env_var: Some("CQS_FTS_CODE_WEIGHT"),
+        default: 1.0,
+        min: 0.0,
+        max: 10.0,
+        cache: true,
+    },
+    ScoringKnob {
+        name: "fts_comment_weight",
+        env_var: Some("CQS_FTS_COMMENT_WEIGHT"),
+        default: 1.0,
+        min: 0.0,
+        max: 10.0,
+        cache: true,
+    },
+    // Popularity prior from `.cqs/access_stats.db` (`crate::access`).
+    // 0.0 leaves ranking untouched and skips loading the prior.
+    ScoringKnob {
+        name: "popularity_weight",
+        env_var: Some("CQS_POPULARITY_WEIGHT"),
+        default: 0.0,
+        min: 0.0,
+        max: 1.0,
+        cache: true,
+    },
+    // Relevance-feedback prior from `.cqs/feedback.db` (`crate::feedback`).
+    // 0.0 (opt-in) leaves ranking untouched and skips loading the prior.
+    ScoringKnob {
+        name: "feedback_weight",
+        env_var: Some("CQS_FEEDBACK_WEIGHT"),
+        default: 0.0,
+        min: 0.0,
+        max: 0.5,
+        cache: true,
+    },
+    // Multiplier for chunks carrying a deprecation marker
+    // (`chunks.deprecated`). 1.0 turns the downrank off; `--hide-deprecated`
+    // drops them instead.
+    ScoringKnob {
+        name: "deprecated_penalty",
+        env_var: Some("CQS_DEPRECATED_PENALTY"),
+        default: 0.7,
+        min: 0.0001,
+        max: 1.0,
+        cache: true,
+    },
 ];
 
-/// Config-override map, populated once via [`set_overrides_from_config`].
-/// Read-only after first set (OnceLock semantics).
-static CONFIG_OVERRIDES: OnceLock<HashMap<&'static str, f32>> = OnceLock::new();
+/// Config overrides plus the resolved values of every `cache: true` knob,
+/// behind one lock so [`replace_overrides_from_config`] swaps both in a
+/// single step — a reader never pairs new overrides with stale cached
+/// values.
+struct KnobState {
+    /// Config-override map, set via [`set_overrides_from_config`] (first
+    /// call wins) or [`replace_overrides_from_config`] (hot reload).
+    overrides: Option<HashMap<&'static str, f32>>,
+    /// Cached resolved values for `cache: true` knobs. Populated lazily on
+    /// the first call to [`resolve_knob`] for any cached knob — at which
+    /// point the overrides and env vars are read for every cached knob and
+    /// frozen until the next reload.
+    cached: Option<HashMap<&'static str, f32>>,
+}
+
+static STATE: RwLock<KnobState> = RwLock::new(KnobState {
+    overrides: None,
+    cached: None,
+});
+
+fn read_state() -> std::sync::RwLockReadGuard<'static, KnobState> {
+    STATE.read().unwrap_or_else(|e| e.into_inner())
+}
+
+fn write_state() -> std::sync::RwLockWriteGuard<'static, KnobState> {
+    STATE.write().unwrap_or_else(|e| e.into_inner())
+}
 
-/// Cached resolved values for `cache: true` knobs. Populated lazily on
-/// the first call to [`resolve_knob`] for any cached knob — at which
-/// point [`CONFIG_OVERRIDES`] and env vars are read for every cached
-/// knob and frozen for the rest of the process lifetime.
-static CACHED: OnceLock<HashMap<&'static str, f32>> = OnceLock::new();
+/// Resolve every `cache: true` knob against `overrides`.
+fn build_cached(overrides: Option<&HashMap<&'static str, f32>>) -> HashMap<&'static str, f32> {
+    SCORING_KNOBS
+        .iter()
+        .filter(|k| k.cache)
+        .map(|k| (k.name, resolve_with(k, overrides)))
+        .collect()
+}
 
 /// Look up a knob by name. Panics on unknown name — every consumer
 /// should reference a name that appears in [`SCORING_KNOBS`].
@@ -175,24 +254,27 @@ pub fn knob(name: &str) -> &'static ScoringKnob {
 /// See module-level docs for resolution order and caching semantics.
 pub fn resolve_knob(name: &str) -> f32 {
     let knob = knob(name);
-    if knob.cache {
-        *CACHED
-            .get_or_init(|| {
-                SCORING_KNOBS
-                    .iter()
-                    .filter(|k| k.cache)
-                    .map(|k| (k.name, resolve_uncached(k)))
-                    .collect()
-            })
-            .get(knob.name)
-            .expect("knob present in CACHED — initialized by get_or_init above")
-    } else {
-        resolve_uncached(knob)
+    if !knob.cache {
+        return resolve_uncached(knob);
+    }
+    if let Some(cached) = read_state().cached.as_ref() {
+        return cached[knob.name];
     }
+    let mut state = write_state();
+    let state = &mut *state;
+    // Another thread may have filled the cache between the two locks.
+    let cached = state
+        .cached
+        .get_or_insert_with(|| build_cached(state.overrides.as_ref()));
+    cached[knob.name]
 }
 
 fn resolve_uncached(knob: &ScoringKnob) -> f32 {
-    if let Some(&v) = CONFIG_OVERRIDES.get().and_then(|m| m.get(knob.name)) {
+    resolve_with(knob, read_state().overrides.as_ref())
+}
+
+fn resolve_with(knob: &ScoringKnob, overrides: Option<&HashMap<&'static str, f32>>) -> f32 {
+    if let Some(&v) = overrides.and_then(|m| m.get(knob.name)) {
         // Match env-path validation — reject NaN/Inf and out-of-range
         // before the value can flow into BM25/RRF math (`f32::clamp` propagates NaN).
         if v.is_finite() && v >= knob.min && v <= knob.max {
@@ -236,17 +318,36 @@ fn resolve_uncached(knob: &ScoringKnob) -> f32 {
 ///
 /// Must be called once before the first [`resolve_knob`] call to any
 /// `cache: true` knob (currently: from CLI dispatch, before searches
-/// run). Subsequent calls are no-ops (OnceLock).
+/// run). Subsequent calls are no-ops — a running process changes its
+/// overrides only through [`replace_overrides_from_config`].
 ///
 /// Unknown keys (not matching any knob in [`SCORING_KNOBS`]) are
 /// logged at WARN. Out-of-range values are clamped to `[min, max]`
 /// at resolve time, not here.
 pub fn set_overrides_from_config(overrides: &HashMap<String, f32>) {
-    let _ = CONFIG_OVERRIDES.set(build_override_map(overrides));
+    let mut state = write_state();
+    if state.overrides.is_none() {
+        state.overrides = Some(build_override_map(overrides));
+    }
+}
+
+/// Hot-reload entry: replace the config overrides (`None` = the reloaded
+/// config has no `[scoring]` section) and re-resolve every cached knob,
+/// including the [`super::config::ScoringConfig`] snapshot, under one
+/// write lock. Searches already running keep the values they read; the
+/// next read sees the new set as a whole.
+pub fn replace_overrides_from_config(overrides: Option<&HashMap<String, f32>>) {
+    let overrides = build_override_map(overrides.unwrap_or(&HashMap::new()));
+    let cached = build_cached(Some(&overrides));
+    let mut state = write_state();
+    super::config::ScoringConfig::publish(|name| cached[name]);
+    state.overrides = Some(overrides);
+    state.cached = Some(cached);
+    tracing::info!("Scoring knob overrides reloaded");
 }
 
 /// Pure helper extracted from [`set_overrides_from_config`] for unit
-/// testing without `OnceLock` pollution. Filters `overrides` down to
+/// testing without touching the process-wide state. Filters `overrides` down to
 /// known knob names and warns on unknown keys.
 fn build_override_map(overrides: &HashMap<String, f32>) -> HashMap<&'static str, f32> {
     let mut map: HashMap<&'static str, f32> = HashMap::new();
@@ -333,7 +434,7 @@ mod tests {
 
     #[test]
     fn build_override_map_keeps_known_drops_unknown() {
-        // Pure function, no OnceLock side effects — safe to call
+        // Pure function, no process-wide side effects — safe to call
         // freely from tests. `set_overrides_from_config` is tested
         // indirectly via the config-load integration tests in
         // `src/config.rs`.
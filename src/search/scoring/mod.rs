@@ -7,6 +7,7 @@
 //! - `note_boost` - note-based score boosting
 //! - `filter` - SQL filter building, glob compilation, chunk ID parsing
 //! - `candidate` - candidate scoring, importance demotion, parent boost, bounded heap
+//! - `windows` - parent dedup, merging adjacent window hits into one result
 
 mod candidate;
 mod config;
@@ -16,6 +17,7 @@ pub mod knob;
 mod name_match;
 mod note_boost;
 mod provenance;
+mod windows;
 
 pub(crate) use candidate::{
     apply_parent_boost, apply_scoring_pipeline, score_candidate, BoundedScoreHeap, ScoringContext,
@@ -24,7 +26,8 @@ pub(crate) use candidate::{
 pub(crate) use config::ScoringConfig;
 pub(crate) use filter::{build_filter_sql, compile_glob_filter};
 pub(crate) use fusion::rrf_fuse;
-pub use fusion::set_rrf_k_from_config;
+pub use fusion::{reload_scoring_from_config, set_rrf_k_from_config};
 pub(crate) use name_match::NameMatcher;
 pub(crate) use note_boost::{NoteBoost, NoteBoostCache, NoteBoostIndex, OwnedNoteBoostIndex};
 pub(crate) use provenance::{signals_for, RankSignalCtx, RankSignalInputs};
+pub(crate) use windows::dedup_and_merge_windows;
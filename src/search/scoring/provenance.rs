@@ -5,7 +5,13 @@
 //! names — no new taxonomy. Each entry's `value` is in the signal's native
 //! unit: a 1-indexed rank for the retrieval legs (`dense`, `fts`, `sparse`), a
 //! multiplier for the boost signals (`name_match`, `note_boost`, `type_boost`,
-//! `parent_boost`).
+//! `parent_boost`, `popularity`, `feedback`). `rank_hook` is the one name from
+//! outside that vocabulary: the `new / old` score ratio a user ranking hook
+//! applied (see [`crate::search::rank_hook`]), recorded by `finalize_results`;
+//! `boost` is the combined factor of the project boost list's matching rules
+//! (see [`crate::boosts`]), recorded the same way. `facet_whole`,
+//! `facet_name` and `facet_body` are the query's cosine against each vector
+//! behind a facet-blended base score (see [`crate::facets`]).
 //!
 //! **Side channel, never a scoring change.** Recording reads the same inputs the
 //! scoring fold consults but reproduces them in a separate pass that never feeds
@@ -32,7 +38,7 @@ use std::collections::HashMap;
 use crate::language::ChunkType;
 use crate::store::helpers::{RankSignal, SearchResult};
 
-use super::candidate::chunk_importance;
+use super::candidate::{chunk_importance, feedback_multiplier, popularity_multiplier};
 use super::name_match::NameMatcher;
 use super::note_boost::NoteBoost;
 
@@ -93,6 +99,12 @@ pub(crate) struct RankSignalCtx<'a> {
     /// moving but applied in `finalize_results` after the scoring fold, so it's
     /// recorded here from the caller-computed map rather than reconstructed.
     pub parent_boosts: &'a HashMap<&'a str, f32>,
+    /// Access-stats popularity prior, present only when the scoring fold's
+    /// `PopularityBoost` stage ran (mirrors `filter.popularity`).
+    pub popularity: Option<&'a crate::access::PopularityPrior>,
+    /// Relevance-feedback prior, present only when the `FeedbackBoost` stage
+    /// ran (mirrors `filter.feedback`).
+    pub feedback: Option<&'a crate::feedback::FeedbackPrior>,
 }
 
 /// Caller-supplied half of the provenance inputs: the boost-lookup pieces that
@@ -115,6 +127,16 @@ pub(crate) struct RankSignalInputs<'a> {
     /// per-result rank is captured there and threaded in (the dense/FTS legs are
     /// built inside `finalize_results`). Empty on the non-SPLADE paths.
     pub sparse_ranks: HashMap<String, usize>,
+    /// Per-vector cosines of the results whose base score blended facet
+    /// vectors (`crate::facets`). Empty when no blend ran.
+    pub facet_scores: HashMap<String, crate::facets::FacetScores>,
+    /// MaxSim of the results rescored by late interaction
+    /// (`crate::late_interaction`). Empty when that stage did not run.
+    pub late_scores: HashMap<String, f32>,
+    /// Popularity prior — mirrors `filter.popularity`.
+    pub popularity: Option<&'a crate::access::PopularityPrior>,
+    /// Feedback prior — mirrors `filter.feedback`.
+    pub feedback: Option<&'a crate::feedback::FeedbackPrior>,
 }
 
 /// Derive the `rank_signals` array for one result. Pure function of the result
@@ -225,6 +247,30 @@ pub(crate) fn signals_for(result: &SearchResult, ctx: &RankSignalCtx<'_>) -> Vec
                 });
             }
         }
+
+        // popularity: the access-stats prior multiplier. Only when a prior
+        // was loaded and this chunk has recorded accesses.
+        if let Some(prior) = ctx.popularity {
+            let mult = popularity_multiplier(prior, file_part, name);
+            if mult != 1.0 {
+                discriminative.push(RankSignal {
+                    signal: "popularity",
+                    value: mult,
+                });
+            }
+        }
+
+        // feedback: the learned per-path / per-language multiplier. Only
+        // when a prior was loaded and it has votes touching this chunk.
+        if let Some(prior) = ctx.feedback {
+            let mult = feedback_multiplier(prior, file_part);
+            if mult != 1.0 {
+                discriminative.push(RankSignal {
+                    signal: "feedback",
+                    value: mult,
+                });
+            }
+        }
     }
 
     // parent_boost: the container hub-boost applied in `finalize_results` after
@@ -291,6 +337,8 @@ mod tests {
             window_idx: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 
@@ -336,6 +384,8 @@ mod tests {
             is_rrf,
             suppress_note_boost,
             parent_boosts,
+            popularity: None,
+            feedback: None,
         }
     }
 
@@ -0,0 +1,218 @@
+//! Parent dedup and window merging for search results.
+//!
+//! Symbols too long for the embedder's context are split at index time into
+//! overlapping windows (`{parent_id}:w{idx}`, see
+//! `cli/pipeline/windowing.rs`) that share the symbol's name, signature, and
+//! line range. A query that matches the middle of a long function often hits
+//! several neighbouring windows; returning each one separately would crowd
+//! out other results, and keeping only the best one drops the context next
+//! to it. Instead the best window absorbs the run of consecutive windows that
+//! also hit, with the overlapping text stitched out, so the caller gets one
+//! result covering the whole matched stretch.
+
+use std::collections::HashMap;
+
+use crate::store::helpers::{ChunkRow, ChunkSummary, SearchResult};
+
+/// Whether `row` is a code window (`{parent_id}:w{idx}`) rather than a
+/// markdown table window, which shares `window_idx` but repeats its header
+/// rows and so must not be stitched.
+fn code_window_idx(row: &ChunkRow) -> Option<i32> {
+    let idx = row.window_idx?;
+    let parent = row.parent_id.as_deref()?;
+    (row.id.strip_prefix(parent)? == format!(":w{idx}")).then_some(idx)
+}
+
+/// Byte length of the longest suffix of `a` that is a prefix of `b`.
+///
+/// Windows are exact slices of the parent's source, so neighbours share a
+/// literal run; the longest match is the real overlap (a shorter spurious
+/// match can't beat it).
+fn overlap_len(a: &str, b: &str) -> usize {
+    let first = a.len().saturating_sub(b.len());
+    a.char_indices()
+        .map(|(p, _)| p)
+        .filter(|&p| p >= first)
+        .find(|&p| b.starts_with(&a[p..]))
+        .map_or(0, |p| a.len() - p)
+}
+
+/// Fold the consecutive windows around `best` into it.
+///
+/// `others` are the other hits sharing `best`'s parent. Only the unbroken
+/// run of window indices through `best` is merged; a window further away is
+/// dropped, as the plain parent dedup always did. The merged result keeps
+/// `best`'s id, so downstream lookups resolve to a real row.
+fn merge_window_run(mut best: ChunkRow, others: Vec<ChunkRow>) -> ChunkRow {
+    let Some(best_idx) = code_window_idx(&best) else {
+        return best;
+    };
+    let mut by_idx: HashMap<i32, ChunkRow> = others
+        .into_iter()
+        .filter_map(|r| code_window_idx(&r).map(|i| (i, r)))
+        .collect();
+    let mut before = Vec::new();
+    let mut idx = best_idx;
+    while let Some(r) = by_idx.remove(&(idx - 1)) {
+        before.push(r);
+        idx -= 1;
+    }
+    let mut after = Vec::new();
+    let mut idx = best_idx;
+    while let Some(r) = by_idx.remove(&(idx + 1)) {
+        after.push(r);
+        idx += 1;
+    }
+    if before.is_empty() && after.is_empty() {
+        return best;
+    }
+
+    before.reverse();
+    let mut content = String::new();
+    let mut doc = None;
+    let (mut line_start, mut line_end) = (best.line_start, best.line_end);
+    for r in before.iter().chain(std::iter::once(&best)).chain(&after) {
+        let skip = overlap_len(&content, &r.content);
+        content.push_str(&r.content[skip..]);
+        doc = doc.or_else(|| r.doc.clone());
+        line_start = line_start.min(r.line_start);
+        line_end = line_end.max(r.line_end);
+    }
+    tracing::debug!(
+        id = %best.id,
+        merged = before.len() + after.len() + 1,
+        "merged adjacent window hits"
+    );
+    best.content = content;
+    best.doc = doc;
+    best.line_start = line_start;
+    best.line_end = line_end;
+    best
+}
+
+/// One result per parent, in score order, with adjacent window hits merged.
+///
+/// `scored` is the fused `(id, score)` ranking; rows come out of `rows`
+/// (ids without a row are skipped). Chunks with no `parent_id` are their own
+/// parent. The first (best-scoring) hit of each parent keeps its rank and
+/// score.
+pub(crate) fn dedup_and_merge_windows(
+    scored: Vec<(String, f32)>,
+    rows: &mut HashMap<String, ChunkRow>,
+) -> Vec<SearchResult> {
+    let mut slot: HashMap<String, usize> = HashMap::new();
+    let mut kept: Vec<(ChunkRow, f32, Vec<ChunkRow>)> = Vec::new();
+    for (id, score) in scored {
+        let Some(row) = rows.remove(&id) else {
+            continue;
+        };
+        let key = row.parent_id.clone().unwrap_or_else(|| row.id.clone());
+        match slot.get(&key) {
+            Some(&i) => kept[i].2.push(row),
+            None => {
+                slot.insert(key, kept.len());
+                kept.push((row, score, Vec::new()));
+            }
+        }
+    }
+    kept.into_iter()
+        .map(|(row, score, others)| {
+            SearchResult::new(ChunkSummary::from(merge_window_run(row, others)), score)
+        })
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn window(parent: &str, idx: i32, content: &str) -> ChunkRow {
+        ChunkRow {
+            id: format!("{parent}:w{idx}"),
+            origin: "src/big.rs".to_string(),
+            language: "rust".to_string(),
+            chunk_type: "function".to_string(),
+            name: "big".to_string(),
+            signature: "fn big()".to_string(),
+            content: content.to_string(),
+            doc: (idx == 0).then(|| "Big function.".to_string()),
+            line_start: 10,
+            line_end: 900,
+            content_hash: format!("h{idx}"),
+            window_idx: Some(idx),
+            parent_id: Some(parent.to_string()),
+            parent_type_name: None,
+            parser_version: 0,
+            vendored: false,
+            deprecated: false,
+            boilerplate: None,
+        }
+    }
+
+    fn plain(id: &str) -> ChunkRow {
+        ChunkRow {
+            id: id.to_string(),
+            window_idx: None,
+            parent_id: None,
+            doc: None,
+            ..window("unused", 0, "fn other() {}")
+        }
+    }
+
+    fn run(hits: Vec<(ChunkRow, f32)>) -> Vec<SearchResult> {
+        let scored = hits.iter().map(|(r, s)| (r.id.clone(), *s)).collect();
+        let mut rows = hits.into_iter().map(|(r, _)| (r.id.clone(), r)).collect();
+        dedup_and_merge_windows(scored, &mut rows)
+    }
+
+    #[test]
+    fn overlap_len_finds_shared_run() {
+        assert_eq!(overlap_len("abcdef", "defghi"), 3);
+        assert_eq!(overlap_len("abc", "xyz"), 0);
+        assert_eq!(overlap_len("", "xyz"), 0);
+        assert_eq!(overlap_len("aaaa", "aab"), 2);
+    }
+
+    #[test]
+    fn adjacent_windows_merge_into_best() {
+        let p = "src/big.rs:10:0:abcd1234";
+        let results = run(vec![
+            (window(p, 1, "let b = 2;\nlet c = 3;\n"), 0.9),
+            (plain("other"), 0.8),
+            (window(p, 0, "fn big() {\nlet a = 1;\nlet b = 2;\n"), 0.7),
+            (window(p, 2, "let c = 3;\n}"), 0.6),
+        ]);
+        assert_eq!(results.len(), 2);
+        let merged = &results[0];
+        assert_eq!(merged.chunk.id, format!("{p}:w1"));
+        assert_eq!(merged.score, 0.9);
+        assert_eq!(
+            merged.chunk.content,
+            "fn big() {\nlet a = 1;\nlet b = 2;\nlet c = 3;\n}"
+        );
+        assert_eq!(merged.chunk.doc.as_deref(), Some("Big function."));
+        assert_eq!(results[1].chunk.id, "other");
+    }
+
+    #[test]
+    fn distant_window_is_dropped_not_merged() {
+        let p = "src/big.rs:10:0:abcd1234";
+        let results = run(vec![
+            (window(p, 0, "fn big() {\n"), 0.9),
+            (window(p, 3, "    tail();\n}"), 0.8),
+        ]);
+        assert_eq!(results.len(), 1);
+        assert_eq!(results[0].chunk.content, "fn big() {\n");
+    }
+
+    #[test]
+    fn table_windows_are_not_stitched() {
+        let mut a = window("sec", 0, "| h |\n| 1 |");
+        a.id = "sec:t0w0".to_string();
+        let mut b = window("sec", 1, "| h |\n| 2 |");
+        b.id = "sec:t0w1".to_string();
+        let results = run(vec![(a, 0.9), (b, 0.8)]);
+        assert_eq!(results.len(), 1);
+        assert_eq!(results[0].chunk.content, "| h |\n| 1 |");
+    }
+}
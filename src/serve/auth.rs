@@ -25,6 +25,13 @@
 //! random bytes URL-safe base64-encoded (no padding) — 256 bits of
 //! entropy in a paste-friendly 43-character string.
 //!
+//! Named tokens from `.cqs/serve_tokens.json` ([`super::tokens`]) are
+//! accepted on the same three channels. Each request authenticates as a
+//! [`Principal`] — the token's name and [`Scope`] — which the middleware
+//! hands to the handlers (request extension) and to the access log
+//! (response extension). The per-launch token is principal `launch`, scope
+//! `admin`.
+//!
 //! `--no-auth` opts out for scripted automation; the caller is responsible for
 //! emitting the loud-warning banner.
 
@@ -40,6 +47,8 @@ use axum::{
 use base64::Engine;
 use subtle::ConstantTimeEq;
 
+use super::tokens::{hash_token, NamedCredential, Scope, TokenStore, LAUNCH_TOKEN_NAME};
+
 /// Cookie-name prefix. The full cookie name is
 /// [`cookie_name_for_port`], which appends the bind port so two
 /// instances of `cqs serve` running on the same host with different
@@ -162,7 +171,13 @@ pub enum AuthMode {
     /// Auth required on every request. `cookie_port` is the bind
     /// port; appended to the cookie name so two `cqs serve`
     /// instances on the same host don't share a cookie jar entry.
-    Required { token: AuthToken, cookie_port: u16 },
+    /// `named` holds the registry tokens accepted alongside `token`
+    /// (see [`AuthMode::with_tokens`]).
+    Required {
+        token: AuthToken,
+        cookie_port: u16,
+        named: Arc<[NamedCredential]>,
+    },
     /// Auth disabled. The contained [`NoAuthAcknowledgement`] is a
     /// proof-of-intent token — the only way to construct it is via
     /// the explicit `--no-auth` CLI flag (or, in tests, the
@@ -174,7 +189,44 @@ pub enum AuthMode {
 impl AuthMode {
     /// Build an auth-required mode bound to the given port.
     pub fn required(token: AuthToken, cookie_port: u16) -> Self {
-        Self::Required { token, cookie_port }
+        Self::Required {
+            token,
+            cookie_port,
+            named: Arc::from(Vec::new()),
+        }
+    }
+
+    /// Also accept the named tokens in `store`. No-op on `Disabled`.
+    pub fn with_tokens(self, store: &TokenStore) -> Self {
+        match self {
+            Self::Required {
+                token, cookie_port, ..
+            } => Self::Required {
+                token,
+                cookie_port,
+                named: Arc::from(store.credentials()),
+            },
+            disabled @ Self::Disabled(_) => disabled,
+        }
+    }
+
+    /// Number of named tokens accepted besides the per-launch one.
+    pub fn named_token_count(&self) -> usize {
+        match self {
+            Self::Required { named, .. } => named.len(),
+            Self::Disabled(_) => 0,
+        }
+    }
+
+    /// Every accepted credential, for transports that authenticate outside
+    /// the axum middleware (gRPC). `None` when auth is disabled.
+    pub(crate) fn credentials(&self) -> Option<Credentials> {
+        match self {
+            Self::Required { token, named, .. } => {
+                Some(Credentials::new(token.clone(), Arc::clone(named)))
+            }
+            Self::Disabled(_) => None,
+        }
     }
 
     /// Build an auth-disabled mode. Requires the caller to supply a
@@ -196,6 +248,65 @@ impl AuthMode {
     }
 }
 
+/// Who a request authenticated as: the token's name and scope. Inserted into
+/// the request extensions by [`enforce_auth`] (read by [`require_scope`]) and
+/// into the response extensions (read by the access log). Absent when auth is
+/// disabled.
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub(crate) struct Principal {
+    pub(crate) name: Arc<str>,
+    pub(crate) scope: Scope,
+}
+
+/// Everything a request may authenticate with: the per-launch token plus the
+/// named registry tokens. Cheap to clone (two `Arc`s).
+#[derive(Clone, Debug)]
+pub(crate) struct Credentials {
+    launch: AuthToken,
+    named: Arc<[NamedCredential]>,
+}
+
+impl Credentials {
+    pub(crate) fn new(launch: AuthToken, named: Arc<[NamedCredential]>) -> Self {
+        Self { launch, named }
+    }
+
+    /// Only the per-launch token — no registry.
+    pub(crate) fn launch_only(token: AuthToken) -> Self {
+        Self::new(token, Arc::from(Vec::new()))
+    }
+
+    /// The principal `presented` authenticates as, if any.
+    ///
+    /// The launch token is compared directly, named tokens by blake3 hash;
+    /// both compares are constant-time and every named entry is visited, so
+    /// the timing says nothing about which token (if any) matched. A value
+    /// outside the token alphabet never matches a named token — which keeps
+    /// it out of the cookie set on the `?token=` handoff.
+    pub(crate) fn verify(&self, presented: &str) -> Option<Principal> {
+        if ct_eq(presented, self.launch.as_str()) {
+            return Some(Principal {
+                name: Arc::from(LAUNCH_TOKEN_NAME),
+                scope: Scope::Admin,
+            });
+        }
+        if self.named.is_empty() || !is_valid_token_alphabet(presented) {
+            return None;
+        }
+        let hash = hash_token(presented);
+        let mut matched = None;
+        for cred in self.named.iter() {
+            if bool::from(hash[..].ct_eq(&cred.hash[..])) {
+                matched = Some(Principal {
+                    name: Arc::clone(&cred.name),
+                    scope: cred.scope,
+                });
+            }
+        }
+        matched
+    }
+}
+
 /// Proof token: `AuthMode::Disabled` requires you to construct one
 /// of these. The struct is `pub` so callers can use the type, but
 /// the inner zero-sized field is private — the only paths that can
@@ -299,11 +410,15 @@ pub(crate) enum UnauthorizedReason {
 /// The channel is responsible only for "did *this* channel pass". Whether the
 /// overall request authenticates and which `UnauthorizedReason` to surface
 /// is decided by [`check_request`] from the combination of channel outcomes.
-#[derive(Debug, Clone, Copy, PartialEq, Eq)]
-enum ChannelOutcome {
+#[derive(Debug, Clone, PartialEq, Eq)]
+enum ChannelOutcome<'r> {
     /// Channel observed a credential and it matched — request authenticated
-    /// via this channel.
-    Authenticated,
+    /// via this channel, as `principal`. `secret` is the matching value, for
+    /// the cookie set on the `?token=` handoff.
+    Authenticated {
+        principal: Principal,
+        secret: &'r str,
+    },
     /// Channel observed a credential but it did NOT match (e.g. stale
     /// Bearer header, expired cookie, wrong `?token=…`). Counts as an
     /// attempt for `UnauthorizedReason` classification.
@@ -325,10 +440,10 @@ enum ChannelOutcome {
 /// Priority is (header > cookie > query), documented at the channel array's
 /// construction site, not implicit in the trait.
 trait AuthChannel {
-    /// Inspect the request for this channel's credential and compare
-    /// constant-time against `expected`. Returns
+    /// Inspect the request for this channel's credential and verify it
+    /// against `creds` (constant-time). Returns
     /// [`ChannelOutcome::Authenticated`] / `Mismatch` / `NotPresent`.
-    fn check(&self, req: &Request, expected: &AuthToken) -> ChannelOutcome;
+    fn check<'r>(&self, req: &'r Request, creds: &Credentials) -> ChannelOutcome<'r>;
 
     /// `UnauthorizedReason` to surface on the 401 telemetry warn when
     /// *this* channel was the most-specific channel that attempted
@@ -354,7 +469,7 @@ trait AuthChannel {
 struct BearerHeaderChannel;
 
 impl AuthChannel for BearerHeaderChannel {
-    fn check(&self, req: &Request, expected: &AuthToken) -> ChannelOutcome {
+    fn check<'r>(&self, req: &'r Request, creds: &Credentials) -> ChannelOutcome<'r> {
         let bearer = req
             .headers()
             .get(header::AUTHORIZATION)
@@ -363,10 +478,12 @@ impl AuthChannel for BearerHeaderChannel {
         match bearer {
             Some(value) => {
                 let stripped = value.strip_prefix("Bearer ").unwrap_or("");
-                if ct_eq(stripped, expected.as_str()) {
-                    ChannelOutcome::Authenticated
-                } else {
-                    ChannelOutcome::Mismatch
+                match creds.verify(stripped) {
+                    Some(principal) => ChannelOutcome::Authenticated {
+                        principal,
+                        secret: stripped,
+                    },
+                    None => ChannelOutcome::Mismatch,
                 }
             }
             None => ChannelOutcome::NotPresent,
@@ -389,7 +506,7 @@ struct CookieChannel<'a> {
 }
 
 impl AuthChannel for CookieChannel<'_> {
-    fn check(&self, req: &Request, expected: &AuthToken) -> ChannelOutcome {
+    fn check<'r>(&self, req: &'r Request, creds: &Credentials) -> ChannelOutcome<'r> {
         let cookie_header = req
             .headers()
             .get(header::COOKIE)
@@ -401,8 +518,11 @@ impl AuthChannel for CookieChannel<'_> {
         for pair in cookie_header.split(';').map(str::trim) {
             if let Some(value) = pair.strip_prefix(self.needle) {
                 attempted = true;
-                if ct_eq(value, expected.as_str()) {
-                    return ChannelOutcome::Authenticated;
+                if let Some(principal) = creds.verify(value) {
+                    return ChannelOutcome::Authenticated {
+                        principal,
+                        secret: value,
+                    };
                 }
             }
         }
@@ -425,7 +545,7 @@ impl AuthChannel for CookieChannel<'_> {
 struct QueryParamChannel;
 
 impl AuthChannel for QueryParamChannel {
-    fn check(&self, req: &Request, expected: &AuthToken) -> ChannelOutcome {
+    fn check<'r>(&self, req: &'r Request, creds: &Credentials) -> ChannelOutcome<'r> {
         let Some(query) = req.uri().query() else {
             return ChannelOutcome::NotPresent;
         };
@@ -433,8 +553,11 @@ impl AuthChannel for QueryParamChannel {
         for pair in query.split('&').filter(|p| pair_key_is_token(p)) {
             attempted = true;
             let value = pair.split_once('=').map(|(_, v)| v).unwrap_or("");
-            if ct_eq(value, expected.as_str()) {
-                return ChannelOutcome::Authenticated;
+            if let Some(principal) = creds.verify(value) {
+                return ChannelOutcome::Authenticated {
+                    principal,
+                    secret: value,
+                };
             }
         }
         if attempted {
@@ -472,10 +595,13 @@ impl AuthChannel for QueryParamChannel {
 /// trigger the redirect so the URL bar is scrubbed — `needs_url_strip` is OR'd
 /// across channels for that reason.
 ///
+/// When more than one channel authenticates, the highest-priority one decides
+/// the principal.
+///
 /// `cookie_lookup_needle` is the pre-built `cqs_token_<port>=` string from
 /// [`AuthMiddlewareState`]. Passed by reference so the happy path doesn't
 /// `format!()` per request.
-fn check_request(req: &Request, expected: &AuthToken, cookie_lookup_needle: &str) -> AuthOutcome {
+fn check_request(req: &Request, creds: &Credentials, cookie_lookup_needle: &str) -> AuthOutcome {
     // Priority order: header > cookie > query. Asserted by
     // `channel_priority_is_bearer_cookie_query` below.
     let bearer = BearerHeaderChannel;
@@ -485,16 +611,18 @@ fn check_request(req: &Request, expected: &AuthToken, cookie_lookup_needle: &str
     let query = QueryParamChannel;
     let channels: [&dyn AuthChannel; 3] = [&bearer, &cookie, &query];
 
-    let mut authenticated = false;
+    let mut authenticated: Option<(Principal, &str)> = None;
     // Pick the highest-priority channel that *attempted* (returned
     // `Mismatch`). Walking in priority order means the first mismatch we hit is
     // the most-specific reason.
     let mut first_mismatch: Option<UnauthorizedReason> = None;
     let mut needs_url_strip = false;
     for ch in &channels {
-        match ch.check(req, expected) {
-            ChannelOutcome::Authenticated => {
-                authenticated = true;
+        match ch.check(req, creds) {
+            ChannelOutcome::Authenticated { principal, secret } => {
+                if authenticated.is_none() {
+                    authenticated = Some((principal, secret));
+                }
             }
             ChannelOutcome::Mismatch => {
                 if first_mismatch.is_none() {
@@ -508,34 +636,53 @@ fn check_request(req: &Request, expected: &AuthToken, cookie_lookup_needle: &str
         }
     }
 
-    if authenticated {
-        if needs_url_strip {
-            AuthOutcome::OkViaQueryParam
-        } else {
-            AuthOutcome::Ok
+    match authenticated {
+        Some((principal, secret)) if needs_url_strip => AuthOutcome::OkViaQueryParam {
+            principal,
+            secret: secret.to_string(),
+        },
+        Some((principal, _)) => AuthOutcome::Ok(principal),
+        None => AuthOutcome::Unauthorized(first_mismatch.unwrap_or(UnauthorizedReason::MissingAll)),
+    }
+}
+
+/// The principal `req` authenticates as under `creds`, without acting on it
+/// — no redirect, no 401. The multi-project dispatcher (`projects.rs`) uses
+/// it to route a request by the token it carries.
+pub(crate) fn principal_for(
+    req: &Request,
+    creds: &Credentials,
+    cookie_lookup_needle: &str,
+) -> Option<Principal> {
+    match check_request(req, creds, cookie_lookup_needle) {
+        AuthOutcome::Ok(principal) | AuthOutcome::OkViaQueryParam { principal, .. } => {
+            Some(principal)
         }
-    } else {
-        AuthOutcome::Unauthorized(first_mismatch.unwrap_or(UnauthorizedReason::MissingAll))
+        AuthOutcome::Unauthorized(_) => None,
     }
 }
 
 #[derive(Debug)]
 enum AuthOutcome {
-    /// Token matched via header or cookie — pass the request through
-    /// unchanged.
-    Ok,
-    /// Token matched via `?token=<…>` query param — set the cookie and
-    /// 302-redirect to the same URL with the token stripped.
-    OkViaQueryParam,
+    /// Token matched via header or cookie — pass the request through,
+    /// tagged with the principal.
+    Ok(Principal),
+    /// Token matched, and the URL carries a `?token=<…>` — set the cookie to
+    /// `secret` (the matching credential) and 302-redirect to the same URL
+    /// with the token stripped.
+    OkViaQueryParam {
+        principal: Principal,
+        secret: String,
+    },
     /// No token matched. Reject with 401. Carries a reason classification so
     /// the rejection warn can distinguish "no auth at all" from "stale
     /// credential" / "wrong channel" without logging the material itself.
     Unauthorized(UnauthorizedReason),
 }
 
-/// State plumbed into the auth middleware. Holds the per-launch
-/// [`AuthToken`] and the pre-built cookie strings. Cloned cheaply on
-/// every request — `AuthToken` is `Arc<String>` and the cookie strings
+/// State plumbed into the auth middleware. Holds the accepted
+/// [`Credentials`] and the pre-built cookie strings. Cloned cheaply on
+/// every request — the credentials are `Arc`s and the cookie strings
 /// are `Arc<str>`.
 ///
 /// The cookie name (`cqs_token_<port>`) and the lookup needle
@@ -544,7 +691,7 @@ enum AuthOutcome {
 /// the state. `cookie_port` is not stored: it's only needed at construction.
 #[derive(Clone, Debug)]
 pub(crate) struct AuthMiddlewareState {
-    pub(crate) token: AuthToken,
+    pub(crate) credentials: Credentials,
     /// Full cookie name, computed once: `cqs_token_<port>`.
     pub(crate) cookie_name: Arc<str>,
     /// Lookup needle, computed once: `cqs_token_<port>=`. Used by
@@ -555,14 +702,14 @@ pub(crate) struct AuthMiddlewareState {
 }
 
 impl AuthMiddlewareState {
-    /// Construct from a launched token + bind port. Builds the cookie
-    /// name and lookup needle once, so the per-request middleware path
-    /// is allocation-free.
-    pub(crate) fn new(token: AuthToken, cookie_port: u16) -> Self {
+    /// Construct from the accepted credentials + bind port. Builds the
+    /// cookie name and lookup needle once, so the per-request middleware
+    /// path is allocation-free.
+    pub(crate) fn new(credentials: Credentials, cookie_port: u16) -> Self {
         let cookie_name = cookie_name_for_port(cookie_port);
         let cookie_lookup_needle = format!("{cookie_name}=");
         Self {
-            token,
+            credentials,
             cookie_name: Arc::from(cookie_name.as_str()),
             cookie_lookup_needle: Arc::from(cookie_lookup_needle.as_str()),
         }
@@ -571,6 +718,9 @@ impl AuthMiddlewareState {
 
 /// axum middleware: enforce per-launch token on every request.
 ///
+/// On success the request carries its [`Principal`] as an extension, and
+/// so does the response, for the access log outside this layer.
+///
 /// Wired via [`axum::middleware::from_fn_with_state`] with a cloned
 /// [`AuthMiddlewareState`] as state. Sits inside the host-header
 /// allowlist (so rejected hosts skip the auth check entirely — saves
@@ -579,12 +729,17 @@ impl AuthMiddlewareState {
 /// gzip/trace).
 pub(crate) async fn enforce_auth(
     State(state): State<AuthMiddlewareState>,
-    req: Request,
+    mut req: Request,
     next: Next,
 ) -> Response<Body> {
-    match check_request(&req, &state.token, &state.cookie_lookup_needle) {
-        AuthOutcome::Ok => next.run(req).await,
-        AuthOutcome::OkViaQueryParam => {
+    match check_request(&req, &state.credentials, &state.cookie_lookup_needle) {
+        AuthOutcome::Ok(principal) => {
+            req.extensions_mut().insert(principal.clone());
+            let mut resp = next.run(req).await;
+            resp.extensions_mut().insert(principal);
+            resp
+        }
+        AuthOutcome::OkViaQueryParam { principal, secret } => {
             let clean_uri = strip_token_param(req.uri());
             let mut resp = Redirect::to(&clean_uri).into_response();
             // SameSite=Strict so a third-party page can't replay the
@@ -597,20 +752,22 @@ pub(crate) async fn enforce_auth(
             // per-port so concurrent instances don't collide.
             let cookie = format!(
                 "{}={}; Path=/; HttpOnly; SameSite=Strict",
-                state.cookie_name,
-                state.token.as_str()
+                state.cookie_name, secret
             );
-            // The token alphabet is enforced at construction time
-            // (`AuthToken::try_from_string` / `random()`), and the cookie name
-            // is `cqs_token_<port>` (digits + ASCII letters + underscore). Both
-            // fall inside the HTTP-header-byte set, so `HeaderValue::from_str`
-            // succeeds by structural guarantee. The `.expect` is runtime
-            // evidence that the type contract holds — a panic here means the
-            // type system was bypassed.
+            // `secret` verified against a credential: either equal to the
+            // launch token (alphabet enforced at construction,
+            // `AuthToken::try_from_string` / `random()`) or a named token,
+            // which `Credentials::verify` only accepts inside the alphabet.
+            // The cookie name is `cqs_token_<port>` (digits + ASCII letters +
+            // underscore). Both fall inside the HTTP-header-byte set, so
+            // `HeaderValue::from_str` succeeds by structural guarantee. The
+            // `.expect` is runtime evidence that the contract holds — a panic
+            // here means the verifier was bypassed.
             let value = HeaderValue::from_str(&cookie).expect(
                 "AuthToken alphabet + cookie-name byte set are HTTP-header-safe by construction",
             );
             resp.headers_mut().insert(header::SET_COOKIE, value);
+            resp.extensions_mut().insert(principal);
             resp
         }
         AuthOutcome::Unauthorized(reason) => {
@@ -630,6 +787,38 @@ pub(crate) async fn enforce_auth(
     }
 }
 
+/// axum middleware: reject a request whose [`Principal`] lacks `needed`.
+///
+/// Layered on the `/api/admin/*` routes with
+/// [`axum::middleware::from_fn_with_state`]; runs inside [`enforce_auth`],
+/// so with auth on every request reaching it has a principal. With auth
+/// disabled there is none and the request passes — `--no-auth` opens every
+/// route, as it always has.
+pub(crate) async fn require_scope(
+    State(needed): State<Scope>,
+    req: Request,
+    next: Next,
+) -> Response<Body> {
+    match req.extensions().get::<Principal>() {
+        Some(principal) if !principal.scope.allows(needed) => {
+            tracing::warn!(
+                method = %req.method(),
+                path = %req.uri().path(),
+                token = %principal.name,
+                scope = %principal.scope,
+                needed = %needed,
+                "serve: rejected request outside token scope",
+            );
+            super::ServeError::Forbidden(format!(
+                "token scope '{}' cannot call this route",
+                principal.scope
+            ))
+            .into_response()
+        }
+        _ => next.run(req).await,
+    }
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -860,9 +1049,9 @@ mod tests {
             .unwrap();
         // Tests pass the pre-built lookup needle directly.
         let needle = format!("{}=", cookie_name_for_port(8080));
-        match check_request(&req, &token, &needle) {
-            AuthOutcome::OkViaQueryParam => {}
-            AuthOutcome::Ok | AuthOutcome::Unauthorized(_) => {
+        match check_request(&req, &Credentials::launch_only(token.clone()), &needle) {
+            AuthOutcome::OkViaQueryParam { .. } => {}
+            AuthOutcome::Ok(_) | AuthOutcome::Unauthorized(_) => {
                 panic!(
                     "post-fix expectation: case-folded `Token=` matches and the redirect path fires"
                 );
@@ -884,8 +1073,8 @@ mod tests {
         // `check_request` takes the pre-built lookup needle (`<cookie_name>=`)
         // instead of `cookie_name` itself.
         let needle = format!("{cookie_name}=");
-        let outcome = check_request(&req, &token, &needle);
-        assert_matches!(outcome, AuthOutcome::OkViaQueryParam);
+        let outcome = check_request(&req, &Credentials::launch_only(token.clone()), &needle);
+        assert_matches!(outcome, AuthOutcome::OkViaQueryParam { .. });
     }
 
     // ===== adversarial pins for `try_from_string` length, cookie/query
@@ -920,9 +1109,9 @@ mod tests {
             .header(header::COOKIE, format!("{cookie_name}={}", token.as_str()))
             .body(axum::body::Body::empty())
             .unwrap();
-        match check_request(&req, &token, &needle) {
-            AuthOutcome::OkViaQueryParam => { /* expected: redirect path */ }
-            AuthOutcome::Ok => panic!(
+        match check_request(&req, &Credentials::launch_only(token.clone()), &needle) {
+            AuthOutcome::OkViaQueryParam { .. } => { /* expected: redirect path */ }
+            AuthOutcome::Ok(_) => panic!(
                 "expected OkViaQueryParam (any `?token=` triggers redirect, AC-V1.30.1-5), \
                  got Ok"
             ),
@@ -945,9 +1134,9 @@ mod tests {
             .header(header::COOKIE, format!("{cookie_name}=wrongvalue"))
             .body(axum::body::Body::empty())
             .unwrap();
-        let outcome = check_request(&req, &token, &needle);
+        let outcome = check_request(&req, &Credentials::launch_only(token.clone()), &needle);
         assert!(
-            matches!(outcome, AuthOutcome::OkViaQueryParam),
+            matches!(outcome, AuthOutcome::OkViaQueryParam { .. }),
             "right-query overrides wrong-cookie + redirects to scrub the URL bar"
         );
     }
@@ -970,9 +1159,9 @@ mod tests {
             )
             .body(axum::body::Body::empty())
             .unwrap();
-        match check_request(&req, &token, &needle) {
-            AuthOutcome::Ok => { /* any-match wins */ }
-            AuthOutcome::OkViaQueryParam => panic!(
+        match check_request(&req, &Credentials::launch_only(token.clone()), &needle) {
+            AuthOutcome::Ok(_) => { /* any-match wins */ }
+            AuthOutcome::OkViaQueryParam { .. } => panic!(
                 "expected Ok — any matching duplicate cookie authenticates, got OkViaQueryParam"
             ),
             AuthOutcome::Unauthorized(reason) => {
@@ -989,7 +1178,10 @@ mod tests {
             .body(axum::body::Body::empty())
             .unwrap();
         assert!(
-            matches!(check_request(&req, &token, &needle), AuthOutcome::Ok),
+            matches!(
+                check_request(&req, &Credentials::launch_only(token.clone()), &needle),
+                AuthOutcome::Ok(_)
+            ),
             "right-then-wrong duplicate cookies still authenticate via the right one"
         );
     }
@@ -1007,7 +1199,7 @@ mod tests {
             .header(header::AUTHORIZATION, format!("bearer {}", token.as_str()))
             .body(axum::body::Body::empty())
             .unwrap();
-        match check_request(&req, &token, &needle) {
+        match check_request(&req, &Credentials::launch_only(token.clone()), &needle) {
             AuthOutcome::Unauthorized(reason) => {
                 // Lowercase scheme means `starts_with("Bearer ")` is false, so
                 // no channel attempts — falls through to MissingAll.
@@ -1019,8 +1211,8 @@ mod tests {
                      become BearerMismatch on a typo, or Bearer-success)",
                 );
             }
-            AuthOutcome::Ok => panic!("expected Unauthorized today, got Ok"),
-            AuthOutcome::OkViaQueryParam => {
+            AuthOutcome::Ok(_) => panic!("expected Unauthorized today, got Ok"),
+            AuthOutcome::OkViaQueryParam { .. } => {
                 panic!("expected Unauthorized today, got OkViaQueryParam")
             }
         }
@@ -1040,7 +1232,7 @@ mod tests {
             .header(header::AUTHORIZATION, format!("Bearer  {}", token.as_str()))
             .body(axum::body::Body::empty())
             .unwrap();
-        match check_request(&req, &token, &needle) {
+        match check_request(&req, &Credentials::launch_only(token.clone()), &needle) {
             AuthOutcome::Unauthorized(reason) => {
                 // `starts_with("Bearer ")` matches (one space prefix succeeds),
                 // so `bearer_attempted` IS true. The value starts with " "
@@ -1051,8 +1243,8 @@ mod tests {
                     "double-space 'Bearer  …' is bearer_attempted but value-mismatch",
                 );
             }
-            AuthOutcome::Ok => panic!("expected Unauthorized today, got Ok"),
-            AuthOutcome::OkViaQueryParam => {
+            AuthOutcome::Ok(_) => panic!("expected Unauthorized today, got Ok"),
+            AuthOutcome::OkViaQueryParam { .. } => {
                 panic!("expected Unauthorized today, got OkViaQueryParam")
             }
         }
@@ -1071,7 +1263,7 @@ mod tests {
             .header(header::AUTHORIZATION, format!("Bearer{}", token.as_str()))
             .body(axum::body::Body::empty())
             .unwrap();
-        match check_request(&req, &token, &needle) {
+        match check_request(&req, &Credentials::launch_only(token.clone()), &needle) {
             AuthOutcome::Unauthorized(reason) => {
                 assert_eq!(
                     reason,
@@ -1080,8 +1272,8 @@ mod tests {
                      bearer_attempted is false → MissingAll today",
                 );
             }
-            AuthOutcome::Ok => panic!("expected Unauthorized today, got Ok"),
-            AuthOutcome::OkViaQueryParam => {
+            AuthOutcome::Ok(_) => panic!("expected Unauthorized today, got Ok"),
+            AuthOutcome::OkViaQueryParam { .. } => {
                 panic!("expected Unauthorized today, got OkViaQueryParam")
             }
         }
@@ -1105,7 +1297,7 @@ mod tests {
             .header(header::COOKIE, format!("{cookie_name}=wrongcookie"))
             .body(axum::body::Body::empty())
             .unwrap();
-        match check_request(&req, &token, &needle) {
+        match check_request(&req, &Credentials::launch_only(token.clone()), &needle) {
             AuthOutcome::Unauthorized(reason) => {
                 assert_eq!(
                     reason,
@@ -1113,8 +1305,8 @@ mod tests {
                     "Bearer must win priority over Cookie and QueryParam when all three mismatch"
                 );
             }
-            AuthOutcome::Ok => panic!("expected Unauthorized(BearerMismatch), got Ok"),
-            AuthOutcome::OkViaQueryParam => {
+            AuthOutcome::Ok(_) => panic!("expected Unauthorized(BearerMismatch), got Ok"),
+            AuthOutcome::OkViaQueryParam { .. } => {
                 panic!("expected Unauthorized(BearerMismatch), got OkViaQueryParam")
             }
         }
@@ -1125,7 +1317,7 @@ mod tests {
             .header(header::COOKIE, format!("{cookie_name}=wrongcookie"))
             .body(axum::body::Body::empty())
             .unwrap();
-        match check_request(&req2, &token, &needle) {
+        match check_request(&req2, &Credentials::launch_only(token.clone()), &needle) {
             AuthOutcome::Unauthorized(reason) => {
                 assert_eq!(
                     reason,
@@ -1133,8 +1325,8 @@ mod tests {
                     "Cookie wins when Bearer is absent"
                 );
             }
-            AuthOutcome::Ok => panic!("expected Unauthorized(CookieMismatch), got Ok"),
-            AuthOutcome::OkViaQueryParam => {
+            AuthOutcome::Ok(_) => panic!("expected Unauthorized(CookieMismatch), got Ok"),
+            AuthOutcome::OkViaQueryParam { .. } => {
                 panic!("expected Unauthorized(CookieMismatch), got OkViaQueryParam")
             }
         }
@@ -1144,7 +1336,7 @@ mod tests {
             .uri("/api/graph?token=wrongquery")
             .body(axum::body::Body::empty())
             .unwrap();
-        match check_request(&req3, &token, &needle) {
+        match check_request(&req3, &Credentials::launch_only(token.clone()), &needle) {
             AuthOutcome::Unauthorized(reason) => {
                 assert_eq!(
                     reason,
@@ -1152,10 +1344,57 @@ mod tests {
                     "QueryParam wins when nothing else attempts"
                 );
             }
-            AuthOutcome::Ok => panic!("expected Unauthorized(QueryParamMismatch), got Ok"),
-            AuthOutcome::OkViaQueryParam => {
+            AuthOutcome::Ok(_) => panic!("expected Unauthorized(QueryParamMismatch), got Ok"),
+            AuthOutcome::OkViaQueryParam { .. } => {
                 panic!("expected Unauthorized(QueryParamMismatch), got OkViaQueryParam")
             }
         }
     }
+
+    /// A registry token authenticates as its own name and scope on any
+    /// channel; the `?token=` handoff sets the cookie to that token, not the
+    /// launch token.
+    #[test]
+    fn named_token_authenticates_with_its_scope() {
+        let launch = AuthToken::try_from_string("launchvalue").expect("test alphabet");
+        let mut store = TokenStore::default();
+        let bot = store.create("bot", Scope::Read, 0).unwrap();
+        let mode = AuthMode::required(launch, 8080).with_tokens(&store);
+        assert_eq!(mode.named_token_count(), 1);
+        let creds = mode.credentials().expect("auth required");
+        let needle = format!("{}=", cookie_name_for_port(8080));
+
+        let req = Request::builder()
+            .uri("/api/graph")
+            .header(header::AUTHORIZATION, format!("Bearer {}", bot.as_str()))
+            .body(axum::body::Body::empty())
+            .unwrap();
+        match check_request(&req, &creds, &needle) {
+            AuthOutcome::Ok(principal) => {
+                assert_eq!(&*principal.name, "bot");
+                assert_eq!(principal.scope, Scope::Read);
+            }
+            other => panic!("expected Ok(bot), got {other:?}"),
+        }
+
+        let req = Request::builder()
+            .uri(format!("/?token={}", bot.as_str()))
+            .body(axum::body::Body::empty())
+            .unwrap();
+        match check_request(&req, &creds, &needle) {
+            AuthOutcome::OkViaQueryParam { principal, secret } => {
+                assert_eq!(&*principal.name, "bot");
+                assert_eq!(secret, bot.as_str());
+            }
+            other => panic!("expected OkViaQueryParam(bot), got {other:?}"),
+        }
+
+        assert_eq!(
+            creds.verify("launchvalue").map(|p| p.scope),
+            Some(Scope::Admin),
+            "the launch token is admin"
+        );
+        assert!(creds.verify("not;a-token").is_none());
+        assert!(creds.verify("unknownvalue").is_none());
+    }
 }
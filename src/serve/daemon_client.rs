@@ -2,10 +2,11 @@
 //!
 //! The serve process holds no embedder, vector index, or SPLADE index — it
 //! opens the store read-only for the graph/chunk views. The SPLADE-leg
-//! inspector (`/api/search_legs`) needs the live retrieval stack, so instead of
-//! loading the models into the web process it forwards the query to the
+//! inspector (`/api/search_legs`) and the streaming semantic search
+//! (`/api/search/stream`) need the live retrieval stack, so instead of
+//! loading the models into the web process they forward the query to the
 //! retrieval daemon (`cqs watch --serve`) over the SAME Unix socket the CLI
-//! client uses, and returns the daemon's three-leg response.
+//! client uses, and return the daemon's response.
 //!
 //! The wire protocol mirrors the CLI client (`src/cli/dispatch.rs`): a single
 //! request line `{"command": <verb>, "args": [<argv>...]}` followed by a single
@@ -18,7 +19,16 @@ use std::path::Path;
 use super::error::ServeError;
 
 /// Forward a `search-legs` query to the retrieval daemon and return the clean
-/// handler payload (`{query, legs, results}`).
+/// handler payload (`{query, legs, results}`). See [`query_daemon`].
+pub(crate) fn query_search_legs(
+    socket: &Path,
+    args: &[String],
+) -> Result<serde_json::Value, ServeError> {
+    query_daemon(socket, "search-legs", "mechanism mode", args)
+}
+
+/// Forward one `verb` request to the retrieval daemon and return the clean
+/// handler payload.
 ///
 /// The daemon's socket-layer `output` is the dispatch ENVELOPE
 /// (`{"data": <payload>, "error": null, "version": N, "_meta": …}`), not the
@@ -29,26 +39,28 @@ use super::error::ServeError;
 ///
 /// Returns [`ServeError::ServiceUnavailable`] when the socket is absent or the
 /// daemon is unresponsive — the caller renders that as a 503 with the
-/// "mechanism mode requires `cqs watch --serve`" hint rather than silently
+/// "`<feature>` requires `cqs watch --serve`" hint rather than silently
 /// falling back to a degraded surface. A daemon error response (status != ok)
 /// or a non-null `error` inside the dispatch envelope surfaces as
 /// [`ServeError::Internal`].
 ///
-/// `args` are the argv tokens after the verb (e.g. `["my query", "--limit",
-/// "5"]`). This function builds the request frame, runs the round-trip on the
-/// CALLING thread (it does blocking socket I/O — call it from a blocking
-/// context), and parses one response line.
-pub(crate) fn query_search_legs(
+/// `args` are the argv tokens after the verb (e.g. `["--limit", "5", "--",
+/// "my query"]`). This function builds the request frame, runs the round-trip
+/// on the CALLING thread (it does blocking socket I/O — call it from a
+/// blocking context), and parses one response line.
+pub(crate) fn query_daemon(
     socket: &Path,
+    verb: &str,
+    feature: &str,
     args: &[String],
 ) -> Result<serde_json::Value, ServeError> {
-    let _span = tracing::info_span!("serve_daemon_client", verb = "search-legs").entered();
+    let _span = tracing::info_span!("serve_daemon_client", verb).entered();
 
     // Socket-absent is the normal "no daemon running" case — a clean 503, no
     // warn. The hint names the fix.
     if !socket.exists() {
-        tracing::debug!(path = %socket.display(), "search-legs: daemon socket absent");
-        return Err(daemon_down_error());
+        tracing::debug!(path = %socket.display(), "daemon client: daemon socket absent");
+        return Err(daemon_down_error(feature));
     }
 
     let stream = match UnixStream::connect(socket) {
@@ -60,34 +72,34 @@ pub(crate) fn query_search_legs(
                 path = %socket.display(),
                 error = %e,
                 stage = "connect",
-                "search-legs: daemon connect failed"
+                "daemon client: daemon connect failed"
             );
-            return Err(daemon_down_error());
+            return Err(daemon_down_error(feature));
         }
     };
 
     // Single shared timeout knob across CLI client and daemon.
     let timeout = crate::daemon_translate::resolve_daemon_timeout_ms();
     if let Err(e) = stream.set_read_timeout(Some(timeout)) {
-        tracing::warn!(error = %e, "search-legs: failed to set read timeout");
+        tracing::warn!(error = %e, "daemon client: failed to set read timeout");
     }
     if let Err(e) = stream.set_write_timeout(Some(timeout)) {
-        tracing::warn!(error = %e, "search-legs: failed to set write timeout");
+        tracing::warn!(error = %e, "daemon client: failed to set write timeout");
     }
 
     let request = serde_json::json!({
-        "command": "search-legs",
+        "command": verb,
         "args": args,
     });
 
     let mut stream = stream;
     if let Err(e) = writeln!(stream, "{request}") {
-        tracing::warn!(error = %e, stage = "write", "search-legs: daemon write failed");
-        return Err(daemon_down_error());
+        tracing::warn!(error = %e, stage = "write", "daemon client: daemon write failed");
+        return Err(daemon_down_error(feature));
     }
     if let Err(e) = stream.flush() {
-        tracing::warn!(error = %e, stage = "flush", "search-legs: daemon flush failed");
-        return Err(daemon_down_error());
+        tracing::warn!(error = %e, stage = "flush", "daemon client: daemon flush failed");
+        return Err(daemon_down_error(feature));
     }
 
     // Bound the response so a rogue daemon can't force an unbounded allocation.
@@ -98,14 +110,14 @@ pub(crate) fn query_search_legs(
     let bytes_read = match reader.read_line(&mut response_line) {
         Ok(n) => n,
         Err(e) => {
-            tracing::warn!(error = %e, stage = "read", "search-legs: daemon read failed");
-            return Err(daemon_down_error());
+            tracing::warn!(error = %e, stage = "read", "daemon client: daemon read failed");
+            return Err(daemon_down_error(feature));
         }
     };
     if bytes_read as u64 > max_response {
         tracing::warn!(
             bytes = bytes_read,
-            "search-legs: daemon response exceeded cap"
+            "daemon client: daemon response exceeded cap"
         );
         return Err(ServeError::Internal(
             "daemon response exceeded size cap".to_string(),
@@ -122,13 +134,13 @@ pub(crate) fn query_search_legs(
             // not the handler payload. Peel it via the shared helper so this
             // surface matches the rest of the codebase (and `/api/search`):
             // a non-null envelope `error` surfaces as an error rather than
-            // returning null `data`; otherwise the inner `{query, legs,
-            // results}` payload is returned clean.
+            // returning null `data`; otherwise the inner payload is returned
+            // clean.
             let output = resp.get("output").ok_or_else(|| {
                 ServeError::Internal("daemon ok response missing 'output'".to_string())
             })?;
-            crate::daemon_translate::unwrap_dispatch_payload(output, "search-legs").map_err(|e| {
-                tracing::warn!(detail = %e, "search-legs: dispatch envelope carried an error");
+            crate::daemon_translate::unwrap_dispatch_payload(output, verb).map_err(|e| {
+                tracing::warn!(detail = %e, "daemon client: dispatch envelope carried an error");
                 ServeError::Internal(format!("daemon error: {e}"))
             })
         }
@@ -141,7 +153,7 @@ pub(crate) fn query_search_legs(
                 .and_then(|v| v.as_str())
                 .unwrap_or(other)
                 .to_string();
-            tracing::warn!(status = other, detail = %detail, "search-legs: daemon error response");
+            tracing::warn!(status = other, detail = %detail, "daemon client: daemon error response");
             Err(ServeError::Internal(format!("daemon error: {detail}")))
         }
         None => Err(ServeError::Internal(
@@ -150,10 +162,9 @@ pub(crate) fn query_search_legs(
     }
 }
 
-/// The canonical "mechanism mode needs the daemon" 503 error.
-fn daemon_down_error() -> ServeError {
-    ServeError::ServiceUnavailable(
-        "mechanism mode requires the retrieval daemon — start it with `cqs watch --serve`"
-            .to_string(),
-    )
+/// The canonical "`<feature>` needs the daemon" 503 error.
+fn daemon_down_error(feature: &str) -> ServeError {
+    ServeError::ServiceUnavailable(format!(
+        "{feature} requires the retrieval daemon — start it with `cqs watch --serve`"
+    ))
 }
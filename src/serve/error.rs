@@ -6,7 +6,7 @@
 //! so handler functions can `?`-propagate.
 
 use axum::{
-    http::StatusCode,
+    http::{header, HeaderValue, StatusCode},
     response::{IntoResponse, Response},
     Json,
 };
@@ -24,6 +24,16 @@ pub enum ServeError {
     #[error("bad request: {0}")]
     BadRequest(String),
 
+    /// Authenticated, but the token's scope doesn't cover the route (a `read`
+    /// token on `/api/admin/*`). Renders 403.
+    #[error("forbidden: {0}")]
+    Forbidden(String),
+
+    /// The client's rate-limit bucket is empty (`ratelimit.rs`). Renders 429
+    /// with a `Retry-After` header.
+    #[error("rate limited: retry after {retry_after_secs}s")]
+    RateLimited { retry_after_secs: u64 },
+
     #[error("internal error: {0}")]
     Internal(String),
 
@@ -41,18 +51,34 @@ struct ErrorBody {
     detail: String,
 }
 
-impl IntoResponse for ServeError {
-    fn into_response(self) -> Response {
-        let (status, code) = match &self {
+impl ServeError {
+    /// HTTP status and the short machine-readable code for the error body.
+    fn status_and_code(&self) -> (StatusCode, &'static str) {
+        match self {
             ServeError::NotFound(_) => (StatusCode::NOT_FOUND, "not_found"),
             ServeError::BadRequest(_) => (StatusCode::BAD_REQUEST, "bad_request"),
+            ServeError::Forbidden(_) => (StatusCode::FORBIDDEN, "forbidden"),
+            ServeError::RateLimited { .. } => (StatusCode::TOO_MANY_REQUESTS, "rate_limited"),
             ServeError::ServiceUnavailable(_) => {
                 (StatusCode::SERVICE_UNAVAILABLE, "service_unavailable")
             }
             ServeError::Store(_) | ServeError::Internal(_) => {
                 (StatusCode::INTERNAL_SERVER_ERROR, "internal")
             }
-        };
+        }
+    }
+
+    /// The `error` code of the JSON body (`not_found`, `internal`, ...). Also
+    /// used for the `error` event of a server-sent event stream, where the
+    /// HTTP status has already gone out.
+    pub(crate) fn code(&self) -> &'static str {
+        self.status_and_code().1
+    }
+}
+
+impl IntoResponse for ServeError {
+    fn into_response(self) -> Response {
+        let (status, code) = self.status_and_code();
         // Log internal failures so they reach the journal even when the
         // browser sees a generic 500. NotFound and BadRequest are user-facing
         // and don't warrant a warn-level log.
@@ -65,7 +91,12 @@ impl IntoResponse for ServeError {
             error: code.to_string(),
             detail: self.to_string(),
         };
-        (status, Json(body)).into_response()
+        let mut resp = (status, Json(body)).into_response();
+        if let ServeError::RateLimited { retry_after_secs } = self {
+            resp.headers_mut()
+                .insert(header::RETRY_AFTER, HeaderValue::from(retry_after_secs));
+        }
+        resp
     }
 }
 
@@ -87,6 +118,23 @@ mod tests {
         assert_eq!(resp.status(), StatusCode::BAD_REQUEST);
     }
 
+    #[test]
+    fn forbidden_is_403() {
+        let err = ServeError::Forbidden("read scope".to_string());
+        let resp = err.into_response();
+        assert_eq!(resp.status(), StatusCode::FORBIDDEN);
+    }
+
+    #[test]
+    fn rate_limited_is_429_with_retry_after() {
+        let err = ServeError::RateLimited {
+            retry_after_secs: 3,
+        };
+        let resp = err.into_response();
+        assert_eq!(resp.status(), StatusCode::TOO_MANY_REQUESTS);
+        assert_eq!(resp.headers()[header::RETRY_AFTER], "3");
+    }
+
     #[test]
     fn internal_is_500() {
         let err = ServeError::Internal("oops".to_string());
@@ -0,0 +1,308 @@
+//! gRPC service for `cqs serve --grpc`.
+//!
+//! The service is defined in `proto/cqs.proto` and generated by `build.rs`.
+//! Every method delegates to the same code the HTTP `/api/*` routes use
+//! ([`super::handlers::search_names`], [`super::data::build_chunk_detail`],
+//! [`super::data::build_stats`]) through the same `spawn_blocking` +
+//! semaphore scaffolding, so the two transports cannot drift apart and share
+//! one concurrency budget.
+//!
+//! # Auth
+//! When the HTTP server requires auth, so does this one: each call must carry
+//! `authorization: Bearer <token>` metadata — the per-launch token or a named
+//! one, verified in constant time. Every method is read-only, so any scope
+//! will do. There is no cookie or query-string channel. The `Host`
+//! allowlist is HTTP-only — it exists for DNS rebinding from a browser, and
+//! browsers cannot speak native gRPC.
+
+use std::net::SocketAddr;
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::Arc;
+
+use anyhow::Context;
+use tokio::net::TcpListener;
+use tonic::{Request, Response, Status};
+
+use super::auth::Credentials;
+use super::data::{ChunkDetail, NodeRef, StatsResponse};
+use super::error::ServeError;
+use super::AppState;
+
+/// Generated messages and service traits (package `cqs.v1`).
+pub mod proto {
+    tonic::include_proto!("cqs.v1");
+}
+
+use proto::cqs_server::{Cqs, CqsServer};
+
+/// The `cqs.v1.Cqs` service over the shared [`AppState`].
+pub(crate) struct CqsService {
+    state: AppState,
+}
+
+impl CqsService {
+    pub(crate) fn new(state: AppState) -> Self {
+        Self { state }
+    }
+}
+
+/// The service as mounted: [`CqsService`] behind [`GrpcAuth`].
+pub(crate) type GrpcService =
+    tonic::service::interceptor::InterceptedService<CqsServer<CqsService>, GrpcAuth>;
+
+/// Build the tonic service with the auth interceptor in front of it.
+/// `credentials` is `None` when auth is disabled.
+pub(crate) fn build_service(state: AppState, credentials: Option<Credentials>) -> GrpcService {
+    let auth = GrpcAuth {
+        credentials,
+        last_request_epoch: Arc::clone(&state.last_request_epoch),
+    };
+    CqsServer::with_interceptor(CqsService::new(state), auth)
+}
+
+/// Bind `addr` and serve `service` until `stop` flips to `true` (the HTTP
+/// server's shutdown signal).
+pub(crate) async fn serve(
+    service: GrpcService,
+    addr: SocketAddr,
+    quiet: bool,
+    mut stop: tokio::sync::watch::Receiver<bool>,
+) -> anyhow::Result<()> {
+    let listener = TcpListener::bind(addr)
+        .await
+        .with_context(|| format!("Failed to bind gRPC {addr}"))?;
+    let actual = listener
+        .local_addr()
+        .with_context(|| format!("Failed to read local_addr after bind gRPC {addr}"))?;
+    if !quiet {
+        println!("cqs serve gRPC listening on {actual}");
+    }
+    tracing::info!(addr = %actual, "cqs serve gRPC started");
+    tonic::transport::Server::builder()
+        .concurrency_limit_per_connection(crate::limits::serve_max_concurrent_requests())
+        .add_service(service)
+        .serve_with_incoming_shutdown(
+            tokio_stream::wrappers::TcpListenerStream::new(listener),
+            async move {
+                let _ = stop.wait_for(|stop| *stop).await;
+            },
+        )
+        .await
+        .context("gRPC server failed")?;
+    tracing::info!("cqs serve gRPC shut down cleanly");
+    Ok(())
+}
+
+/// Per-call interceptor: touches the idle clock (like the HTTP
+/// `touch_idle_clock` layer) and enforces the bearer token when auth is on.
+#[derive(Clone)]
+pub(crate) struct GrpcAuth {
+    credentials: Option<Credentials>,
+    last_request_epoch: Arc<AtomicU64>,
+}
+
+#[cfg(test)]
+impl GrpcAuth {
+    pub(crate) fn for_test(
+        token: Option<super::AuthToken>,
+        last_request_epoch: Arc<AtomicU64>,
+    ) -> Self {
+        Self {
+            credentials: token.map(Credentials::launch_only),
+            last_request_epoch,
+        }
+    }
+}
+
+impl tonic::service::Interceptor for GrpcAuth {
+    fn call(&mut self, request: Request<()>) -> Result<Request<()>, Status> {
+        self.last_request_epoch
+            .store(super::now_epoch_secs(), Ordering::Relaxed);
+        let Some(credentials) = &self.credentials else {
+            return Ok(request);
+        };
+        let presented = request
+            .metadata()
+            .get("authorization")
+            .and_then(|v| v.to_str().ok())
+            .and_then(|v| v.strip_prefix("Bearer "));
+        match presented.map(|token| credentials.verify(token)) {
+            Some(Some(principal)) => {
+                tracing::info!(token = %principal.name, "serve::grpc call authenticated");
+                Ok(request)
+            }
+            Some(None) => {
+                tracing::warn!(
+                    reason = "bad_token",
+                    "serve: rejected unauthenticated gRPC call"
+                );
+                Err(Status::unauthenticated("invalid token"))
+            }
+            None => {
+                tracing::warn!(
+                    reason = "missing",
+                    "serve: rejected unauthenticated gRPC call"
+                );
+                Err(Status::unauthenticated("missing bearer token"))
+            }
+        }
+    }
+}
+
+impl From<ServeError> for Status {
+    fn from(err: ServeError) -> Self {
+        match err {
+            ServeError::NotFound(msg) => Status::not_found(msg),
+            ServeError::BadRequest(msg) => Status::invalid_argument(msg),
+            ServeError::Forbidden(msg) => Status::permission_denied(msg),
+            ServeError::ServiceUnavailable(msg) => Status::unavailable(msg),
+            err @ ServeError::RateLimited { .. } => Status::resource_exhausted(err.to_string()),
+            ServeError::Store(e) => {
+                tracing::warn!(error = %e, "serve gRPC call failed: store");
+                Status::internal("store error")
+            }
+            ServeError::Internal(e) => {
+                tracing::warn!(error = %e, "serve gRPC call failed: internal");
+                Status::internal("internal error")
+            }
+        }
+    }
+}
+
+impl From<NodeRef> for proto::ChunkRef {
+    fn from(n: NodeRef) -> Self {
+        Self {
+            id: n.id,
+            name: n.name,
+            file: n.file,
+            line_start: n.line_start,
+        }
+    }
+}
+
+impl From<ChunkDetail> for proto::Chunk {
+    fn from(d: ChunkDetail) -> Self {
+        Self {
+            id: d.id,
+            name: d.name,
+            kind: d.kind,
+            language: d.language,
+            file: d.file,
+            line_start: d.line_start,
+            line_end: d.line_end,
+            signature: d.signature,
+            doc: d.doc,
+            content_preview: d.content_preview,
+            trust_level: d.trust_level,
+            injection_flags: d.injection_flags,
+            callers: d.callers.into_iter().map(Into::into).collect(),
+            callees: d.callees.into_iter().map(Into::into).collect(),
+            tests: d.tests.into_iter().map(Into::into).collect(),
+        }
+    }
+}
+
+impl From<StatsResponse> for proto::IndexStatusResponse {
+    fn from(s: StatsResponse) -> Self {
+        Self {
+            total_chunks: s.total_chunks,
+            total_files: s.total_files,
+            call_edges: s.call_edges,
+            type_edges: s.type_edges,
+        }
+    }
+}
+
+impl CqsService {
+    async fn search_matches(&self, req: proto::SearchRequest) -> Result<Vec<NodeRef>, Status> {
+        tracing::debug!(query = %req.query, "serve::grpc search query received");
+        tracing::info!(
+            q_len = req.query.len(),
+            limit = req.limit,
+            "serve::grpc search"
+        );
+        let limit = match req.limit {
+            0 => super::handlers::default_search_limit(),
+            n => n as usize,
+        };
+        let response = super::handlers::search_names(&self.state, req.query, limit).await?;
+        Ok(response.matches)
+    }
+
+    async fn chunk_detail(&self, id: String) -> Result<ChunkDetail, Status> {
+        if id.is_empty() {
+            return Err(Status::invalid_argument("chunk id is required"));
+        }
+        let lookup = id.clone();
+        let detail = super::handlers::with_blocking(&self.state, "grpc chunk", move |store| {
+            super::data::build_chunk_detail(store, &lookup)
+        })
+        .await?;
+        detail.ok_or_else(|| ServeError::NotFound(format!("chunk: {id}")).into())
+    }
+}
+
+#[tonic::async_trait]
+impl Cqs for CqsService {
+    async fn search(
+        &self,
+        request: Request<proto::SearchRequest>,
+    ) -> Result<Response<proto::SearchResponse>, Status> {
+        let matches = self.search_matches(request.into_inner()).await?;
+        Ok(Response::new(proto::SearchResponse {
+            matches: matches.into_iter().map(Into::into).collect(),
+        }))
+    }
+
+    type SearchStreamStream =
+        tokio_stream::Iter<std::vec::IntoIter<Result<proto::ChunkRef, Status>>>;
+
+    async fn search_stream(
+        &self,
+        request: Request<proto::SearchRequest>,
+    ) -> Result<Response<Self::SearchStreamStream>, Status> {
+        let matches = self.search_matches(request.into_inner()).await?;
+        let items: Vec<_> = matches.into_iter().map(|m| Ok(m.into())).collect();
+        Ok(Response::new(tokio_stream::iter(items)))
+    }
+
+    async fn get_chunk(
+        &self,
+        request: Request<proto::GetChunkRequest>,
+    ) -> Result<Response<proto::Chunk>, Status> {
+        let id = request.into_inner().id;
+        tracing::info!(
+            chunk_id = %id.chars().take(256).collect::<String>(),
+            id_len = id.len(),
+            "serve::grpc get_chunk"
+        );
+        Ok(Response::new(self.chunk_detail(id).await?.into()))
+    }
+
+    async fn callers(
+        &self,
+        request: Request<proto::CallersRequest>,
+    ) -> Result<Response<proto::CallersResponse>, Status> {
+        let id = request.into_inner().id;
+        tracing::info!(
+            chunk_id = %id.chars().take(256).collect::<String>(),
+            id_len = id.len(),
+            "serve::grpc callers"
+        );
+        let detail = self.chunk_detail(id).await?;
+        Ok(Response::new(proto::CallersResponse {
+            callers: detail.callers.into_iter().map(Into::into).collect(),
+        }))
+    }
+
+    async fn index_status(
+        &self,
+        _request: Request<proto::IndexStatusRequest>,
+    ) -> Result<Response<proto::IndexStatusResponse>, Status> {
+        tracing::info!("serve::grpc index_status");
+        let stats =
+            super::handlers::with_blocking(&self.state, "grpc stats", super::data::build_stats)
+                .await?;
+        Ok(Response::new(stats.into()))
+    }
+}
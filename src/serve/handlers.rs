@@ -12,13 +12,14 @@ use axum::{
     http::StatusCode,
     Json,
 };
-use serde::Deserialize;
+use serde::{Deserialize, Serialize};
 
 use super::data::{
     ChunkDetail, ClusterResponse, EvalGoldResponse, GraphResponse, HierarchyDirection,
     HierarchyResponse, NodeRef, SearchResponse, StatsResponse,
 };
 use super::error::ServeError;
+use super::ratelimit::RateLimitMetrics;
 use super::AppState;
 
 /// Shared scaffolding for every async handler that runs a sync `Store` call
@@ -39,7 +40,7 @@ use super::AppState;
 /// `build_*` functions (which return `Result<_, StoreError>`) or
 /// direct `store.search_by_name(...)` calls without per-call-site error
 /// conversions.
-async fn with_blocking<T, E, F>(
+pub(super) async fn with_blocking<T, E, F>(
     state: &AppState,
     label: &'static str,
     f: F,
@@ -129,10 +130,22 @@ pub(crate) struct SearchQuery {
     pub limit: usize,
 }
 
-fn default_search_limit() -> usize {
+pub(super) fn default_search_limit() -> usize {
     20
 }
 
+#[derive(Debug, Deserialize)]
+pub(crate) struct SearchStreamQuery {
+    /// User query text.
+    pub q: String,
+    #[serde(default = "default_search_limit")]
+    pub limit: usize,
+    /// Run the cross-encoder. The first-stage hits arrive first as a
+    /// `candidates` event; the reranked list follows as `results`.
+    #[serde(default)]
+    pub rerank: bool,
+}
+
 #[derive(Debug, Deserialize)]
 pub(crate) struct SearchLegsQuery {
     /// The search query.
@@ -175,6 +188,53 @@ pub(crate) async fn health() -> (StatusCode, &'static str) {
     (StatusCode::OK, "ok")
 }
 
+/// Response for `GET /api/metrics`.
+#[derive(Debug, Serialize)]
+pub(crate) struct MetricsResponse {
+    pub rate_limit: RateLimitMetrics,
+}
+
+/// `GET /metrics` — Prometheus text exposition: the process-wide
+/// [`crate::metrics`] families, with the index generation read from the
+/// store at scrape time, plus the rate-limit counters.
+pub(crate) async fn prometheus_metrics(
+    State(state): State<AppState>,
+) -> Result<axum::response::Response, ServeError> {
+    use axum::response::IntoResponse;
+    let generation = with_blocking(&state, "metrics", |store| store.splade_generation()).await?;
+    crate::metrics::INDEX_GENERATION.set(i64::try_from(generation).unwrap_or(i64::MAX));
+    let mut body = crate::metrics::render();
+    let limits = state.rate_limiter.metrics();
+    crate::metrics::write_counter(
+        &mut body,
+        "cqs_serve_rate_limit_allowed_total",
+        "Requests admitted by the per-client rate limiter.",
+        limits.allowed,
+    );
+    crate::metrics::write_counter(
+        &mut body,
+        "cqs_serve_rate_limit_throttled_total",
+        "Requests answered 429 by the per-client rate limiter.",
+        limits.throttled,
+    );
+    Ok((
+        [(
+            axum::http::header::CONTENT_TYPE,
+            crate::metrics::CONTENT_TYPE,
+        )],
+        body,
+    )
+        .into_response())
+}
+
+/// `GET /api/metrics` — server counters: per-client rate-limit totals and
+/// the most-throttled clients. In-memory, reset on restart.
+pub(crate) async fn metrics(State(state): State<AppState>) -> Json<MetricsResponse> {
+    Json(MetricsResponse {
+        rate_limit: state.rate_limiter.metrics(),
+    })
+}
+
 /// `GET /api/stats` — small payload for the header bar.
 pub(crate) async fn stats(
     State(state): State<AppState>,
@@ -254,15 +314,26 @@ pub(crate) async fn search(
         "serve::search"
     );
 
-    if params.q.trim().is_empty() {
-        return Ok(Json(SearchResponse {
+    let response = search_names(&state, params.q, params.limit).await?;
+    Ok(Json(response))
+}
+
+/// Name search shared by `GET /api/search` and the gRPC `Search` /
+/// `SearchStream` calls. Blank queries return no matches; `limit` is
+/// clamped to 1..=200.
+pub(super) async fn search_names(
+    state: &AppState,
+    q: String,
+    limit: usize,
+) -> Result<SearchResponse, ServeError> {
+    if q.trim().is_empty() {
+        return Ok(SearchResponse {
             matches: Vec::new(),
-        }));
+        });
     }
 
-    let q = params.q.clone();
-    let limit = params.limit.clamp(1, 200);
-    let results = with_blocking(&state, "search", move |store| {
+    let limit = limit.clamp(1, 200);
+    let results = with_blocking(state, "search", move |store| {
         store.search_by_name(&q, limit)
     })
     .await?;
@@ -278,7 +349,7 @@ pub(crate) async fn search(
         .collect();
 
     tracing::info!(matches = matches.len(), "search returned");
-    Ok(Json(SearchResponse { matches }))
+    Ok(SearchResponse { matches })
 }
 
 /// `GET /api/search_legs?q=…&k=…[&splade_alpha=…]` — the SPLADE-fusion
@@ -396,6 +467,223 @@ async fn search_legs_dispatch(
     ))
 }
 
+/// `GET /api/search/stream?q=…[&limit=…][&rerank=true]` — semantic search
+/// as server-sent events, for slow reranked queries.
+///
+/// Like [`search_legs`], the retrieval runs in the daemon (`cqs watch
+/// --serve`). With `rerank=true` the handler forwards the query twice: first
+/// without the reranker, sent as a `candidates` event as soon as it returns,
+/// then with it, sent as `results`. Without `rerank` there is a single
+/// `results` event. A `done` event closes the stream; a failure mid-stream is
+/// an `error` event carrying the same `{error, detail}` body as a JSON error.
+/// Each event's data has the `cqs search --json` payload shape.
+///
+/// A missing query is a 400 and a missing daemon socket a 503, both before
+/// the stream opens.
+pub(crate) async fn search_stream(
+    State(state): State<AppState>,
+    Query(params): Query<SearchStreamQuery>,
+) -> Result<axum::response::Response, ServeError> {
+    tracing::debug!(query = %params.q, "serve::search_stream query received");
+    tracing::info!(
+        q_len = params.q.len(),
+        limit = params.limit,
+        rerank = params.rerank,
+        "serve::search_stream"
+    );
+
+    if params.q.trim().is_empty() {
+        return Err(ServeError::BadRequest(
+            "missing query parameter 'q'".to_string(),
+        ));
+    }
+    let Some(socket) = state.daemon_socket.clone() else {
+        return Err(ServeError::ServiceUnavailable(
+            "streaming search requires the retrieval daemon — start it with `cqs watch --serve`"
+                .to_string(),
+        ));
+    };
+    search_stream_dispatch(state, socket, params)
+}
+
+/// Daemon argv for one `search` forward. Same `--` terminator rule as
+/// [`build_search_legs_daemon_args`]: the untrusted query can never be read
+/// as a flag.
+fn build_search_daemon_args(q: &str, limit: usize, rerank: bool) -> Vec<String> {
+    let mut args = vec!["--limit".to_string(), limit.to_string()];
+    if rerank {
+        args.push("--reranker".to_string());
+        args.push("onnx".to_string());
+    }
+    args.push("--".to_string());
+    args.push(q.to_string());
+    args
+}
+
+/// Open the event stream for [`search_stream`]. The daemon round-trips run
+/// on a spawned task that feeds a small channel, so the first event goes out
+/// while the reranked search is still running. A client that disconnects
+/// closes the channel and the task stops after its current stage.
+#[cfg(unix)]
+fn search_stream_dispatch(
+    state: AppState,
+    socket: std::sync::Arc<std::path::PathBuf>,
+    params: SearchStreamQuery,
+) -> Result<axum::response::Response, ServeError> {
+    use axum::response::sse::{Event, KeepAlive, Sse};
+    use axum::response::IntoResponse;
+
+    let (tx, rx) = tokio::sync::mpsc::channel::<Result<Event, std::convert::Infallible>>(4);
+    let span = tracing::Span::current();
+    tokio::spawn(async move {
+        let limit = params.limit.clamp(1, 200);
+        let stages: &[(&str, bool)] = if params.rerank {
+            &[("candidates", false), ("results", true)]
+        } else {
+            &[("results", false)]
+        };
+        for &(name, rerank) in stages {
+            let args = build_search_daemon_args(&params.q, limit, rerank);
+            let event = match forward_to_daemon(
+                &state,
+                &socket,
+                span.clone(),
+                "search",
+                "streaming search",
+                args,
+            )
+            .await
+            {
+                Ok(payload) => Event::default().event(name).json_data(payload),
+                Err(e) => {
+                    tracing::warn!(stage = name, error = %e, "serve::search_stream stage failed");
+                    let body = serde_json::json!({"error": e.code(), "detail": e.to_string()});
+                    let _ = tx
+                        .send(Ok(Event::default().event("error").data(body.to_string())))
+                        .await;
+                    return;
+                }
+            };
+            let event = event.unwrap_or_else(|e| {
+                tracing::warn!(error = %e, "serve::search_stream event encoding failed");
+                Event::default()
+                    .event("error")
+                    .data(r#"{"error":"internal"}"#)
+            });
+            if tx.send(Ok(event)).await.is_err() {
+                tracing::debug!(stage = name, "serve::search_stream client disconnected");
+                return;
+            }
+        }
+        let _ = tx.send(Ok(Event::default().event("done").data("{}"))).await;
+    });
+
+    let stream = tokio_stream::wrappers::ReceiverStream::new(rx);
+    Ok(Sse::new(stream)
+        .keep_alive(KeepAlive::default())
+        .into_response())
+}
+
+/// Non-unix stand-in for [`search_stream_dispatch`]: no daemon socket to reach.
+#[cfg(not(unix))]
+fn search_stream_dispatch(
+    _state: AppState,
+    _socket: std::sync::Arc<std::path::PathBuf>,
+    _params: SearchStreamQuery,
+) -> Result<axum::response::Response, ServeError> {
+    Err(ServeError::ServiceUnavailable(
+        "streaming search requires the retrieval daemon over a unix socket; not available on this platform"
+            .to_string(),
+    ))
+}
+
+/// One `verb` round-trip to the daemon under a blocking permit, the same
+/// budget the SQL handlers draw from. `feature` names the route in the 503
+/// hint.
+#[cfg(unix)]
+async fn forward_to_daemon(
+    state: &AppState,
+    socket: &std::sync::Arc<std::path::PathBuf>,
+    span: tracing::Span,
+    verb: &'static str,
+    feature: &'static str,
+    args: Vec<String>,
+) -> Result<serde_json::Value, ServeError> {
+    let permit = state
+        .blocking_permits
+        .clone()
+        .acquire_owned()
+        .await
+        .map_err(|e| ServeError::Internal(format!("blocking permit: {e}")))?;
+    let socket = socket.clone();
+    tokio::task::spawn_blocking(move || {
+        let _permit = permit;
+        let _entered = span.enter();
+        super::daemon_client::query_daemon(&socket, verb, feature, &args)
+    })
+    .await
+    .map_err(|e| ServeError::Internal(format!("{verb} join: {e}")))?
+}
+
+/// `POST /api/admin/reindex` — ask the retrieval daemon to re-index now.
+///
+/// Forwards the daemon's `reconcile` verb, the same signal the git hooks
+/// send: the watch loop walks the tree on its next tick and re-embeds what
+/// changed. Returns as soon as the daemon has queued it (`was_pending` says
+/// whether a pass was already queued). Admin scope only (see
+/// [`super::auth::require_scope`]); 503 without a daemon.
+pub(crate) async fn admin_reindex(
+    State(state): State<AppState>,
+    principal: Option<axum::Extension<super::auth::Principal>>,
+) -> Result<Json<serde_json::Value>, ServeError> {
+    let requested_by = principal.map_or_else(|| "anonymous".to_string(), |p| p.name.to_string());
+    tracing::info!(requested_by = %requested_by, "serve::admin_reindex");
+    let Some(socket) = state.daemon_socket.clone() else {
+        return Err(ServeError::ServiceUnavailable(
+            "reindex requires the retrieval daemon — start it with `cqs watch --serve`".to_string(),
+        ));
+    };
+    admin_reindex_dispatch(state, socket, requested_by).await
+}
+
+#[cfg(unix)]
+async fn admin_reindex_dispatch(
+    state: AppState,
+    socket: std::sync::Arc<std::path::PathBuf>,
+    requested_by: String,
+) -> Result<Json<serde_json::Value>, ServeError> {
+    // `--arg` rides along to the daemon's reconcile log line only.
+    let args = vec![
+        "--hook".to_string(),
+        "serve".to_string(),
+        "--arg".to_string(),
+        requested_by,
+    ];
+    let output = forward_to_daemon(
+        &state,
+        &socket,
+        tracing::Span::current(),
+        "reconcile",
+        "reindex",
+        args,
+    )
+    .await?;
+    Ok(Json(output))
+}
+
+/// Non-unix stand-in for [`admin_reindex_dispatch`]: no daemon socket to reach.
+#[cfg(not(unix))]
+async fn admin_reindex_dispatch(
+    _state: AppState,
+    _socket: std::sync::Arc<std::path::PathBuf>,
+    _requested_by: String,
+) -> Result<Json<serde_json::Value>, ServeError> {
+    Err(ServeError::ServiceUnavailable(
+        "reindex requires the retrieval daemon over a unix socket; not available on this platform"
+            .to_string(),
+    ))
+}
+
 /// `GET /api/eval_gold` — the eval query set that drives the Stage-2b
 /// "where hybrid wins" tour. Returns each eval query with its category, split,
 /// and gold `(origin, name)` resolved to current chunk ids against the served
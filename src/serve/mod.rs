@@ -11,8 +11,21 @@
 //!   via `include_str!` / `include_bytes!`
 //! - Per-launch 256-bit auth token gates every request; 3 credential channels
 //!   (Bearer / cookie / `?token=` query); `--no-auth` requires a
-//!   `NoAuthAcknowledgement` proof token. No WebSocket, no live updates —
-//!   single-user local exploration
+//!   `NoAuthAcknowledgement` proof token. Named `read`/`admin` tokens from
+//!   `.cqs/serve_tokens.json` (`tokens.rs`) are accepted alongside it, and
+//!   every request is access-logged with the token name. No WebSocket; the one streaming
+//!   route is `/api/search/stream` (server-sent events) — single-user local
+//!   exploration
+//! - Per-client token-bucket rate limit (`ratelimit.rs`): `429` with
+//!   `Retry-After` past the burst; counters at `/api/metrics`
+//! - Prometheus text exposition at `/metrics` (`crate::metrics`), behind
+//!   the same auth as every other route
+//! - Optional gRPC listener (`--grpc`, `grpc` feature) on its own port,
+//!   serving `proto/cqs.proto` from the same handlers and `AppState`
+//!   (`grpc.rs`)
+//! - Optional multi-project mode (`--projects`, `projects.rs`): one listener
+//!   routing `/p/<name>/...`, named tokens and a project cookie to
+//!   per-project states, each with its own store, quotas and tokens
 //!
 //! # Threading
 //! `run_server` is async-friendly but synchronous from the caller's
@@ -29,7 +42,7 @@ use axum::{
     http::{header, StatusCode},
     middleware::{from_fn_with_state, Next},
     response::{IntoResponse, Response},
-    routing::get,
+    routing::{get, post},
     Router,
 };
 use tokio::net::TcpListener;
@@ -47,13 +60,25 @@ mod auth;
 mod daemon_client;
 mod data;
 mod error;
+#[cfg(feature = "grpc")]
+pub mod grpc;
 mod handlers;
+mod projects;
+mod ratelimit;
+mod tokens;
 
 #[cfg(test)]
 mod tests;
 
 pub use auth::{AuthMode, AuthToken, InvalidTokenAlphabet, NoAuthAcknowledgement};
 pub use error::ServeError;
+pub use projects::{
+    load_projects, run_projects_server, ProjectSpec, ProjectsError, ServedProject,
+    PROJECT_PATH_PREFIX,
+};
+pub use tokens::{
+    tokens_path, validate_token_name, Scope, TokenRecord, TokenStore, TokenStoreError,
+};
 
 /// Shared state passed to every axum handler. Wraps a read-only store
 /// behind an `Arc` so the handler tree can read concurrently.
@@ -87,6 +112,9 @@ pub(crate) struct AppState {
     /// `None` disables the tour route — it then returns a clean 503 instead of
     /// reading from an unknown root.
     pub(crate) eval_root: Option<Arc<std::path::PathBuf>>,
+    /// Per-client token buckets (`ratelimit.rs`). Shared by the
+    /// [`ratelimit::enforce_rate_limit`] layer and `/api/metrics`.
+    pub(crate) rate_limiter: Arc<ratelimit::RateLimiter>,
 }
 
 /// Allowed `Host` header values, built at router-build time from the
@@ -106,7 +134,13 @@ pub(crate) type AllowedHosts = Arc<HashSet<String>>;
 ///
 /// Binds to `bind_addr` (default `127.0.0.1:8080`), serves the embedded
 /// HTML shell at `/`, and answers JSON queries against `store` for
-/// `/api/graph`, `/api/chunk/:id`, `/api/search`, `/api/stats`.
+/// `/api/graph`, `/api/chunk/:id`, `/api/search`, `/api/stats`, plus the
+/// daemon-backed `/api/search_legs` and `/api/search/stream` (SSE).
+///
+/// With `grpc_addr`, also serves the `cqs.v1.Cqs` gRPC service on that
+/// address from the same state, auth token, and shutdown signal; either
+/// listener failing stops both. Without the `grpc` feature a `Some` here is
+/// an error.
 ///
 /// Returns when the listener fails or the process is interrupted.
 /// `quiet` suppresses the "listening on" stdout banner so test code
@@ -127,9 +161,15 @@ pub fn run_server(
     auth: AuthMode,
     daemon_socket: Option<std::path::PathBuf>,
     eval_root: Option<std::path::PathBuf>,
+    grpc_addr: Option<SocketAddr>,
 ) -> Result<()> {
     let _span = tracing::info_span!("serve", addr = %bind_addr).entered();
 
+    #[cfg(not(feature = "grpc"))]
+    if grpc_addr.is_some() {
+        anyhow::bail!("gRPC support is not compiled in; rebuild with `--features grpc`");
+    }
+
     // Bound concurrent `spawn_blocking` jobs across all handlers. See
     // `AppState` doc comment.
     let permits = crate::limits::serve_blocking_permits();
@@ -143,11 +183,43 @@ pub fn run_server(
         last_request_epoch: Arc::clone(&last_request_epoch),
         daemon_socket: daemon_socket.map(Arc::new),
         eval_root: eval_root.map(Arc::new),
+        rate_limiter: Arc::new(ratelimit::RateLimiter::from_env()),
     };
+    let limits = state.rate_limiter.metrics();
+    tracing::info!(
+        enabled = limits.enabled,
+        rate_per_sec = limits.rate_per_sec,
+        burst = limits.burst,
+        "serve: per-client rate limit initialised"
+    );
     let allowed_hosts = allowed_host_set(&bind_addr);
-    let idle_minutes = crate::limits::serve_idle_minutes();
+    #[cfg(feature = "grpc")]
+    let grpc = grpc_addr.map(|addr| (grpc::build_service(state.clone(), auth.credentials()), addr));
+    #[cfg(not(feature = "grpc"))]
+    let grpc: GrpcListener = None;
     let app = build_router(state, allowed_hosts, auth.clone());
+    serve_app(app, bind_addr, quiet, &auth, last_request_epoch, grpc)
+}
 
+/// The gRPC service and address [`serve_app`] runs beside the HTTP
+/// listener. Uninhabited without the `grpc` feature.
+#[cfg(feature = "grpc")]
+type GrpcListener = Option<(grpc::GrpcService, SocketAddr)>;
+#[cfg(not(feature = "grpc"))]
+type GrpcListener = Option<std::convert::Infallible>;
+
+/// Bind `bind_addr`, print the banner, and serve `app` (plus `grpc`) on a
+/// fresh runtime until a signal or idle eviction. Shared by [`run_server`]
+/// and [`run_projects_server`].
+fn serve_app(
+    app: Router,
+    bind_addr: SocketAddr,
+    quiet: bool,
+    auth: &AuthMode,
+    last_request_epoch: Arc<std::sync::atomic::AtomicU64>,
+    grpc: GrpcListener,
+) -> Result<()> {
+    let idle_minutes = crate::limits::serve_idle_minutes();
     let runtime = tokio::runtime::Builder::new_multi_thread()
         .enable_all()
         .build()
@@ -196,15 +268,47 @@ pub fn run_server(
             }
             println!("press Ctrl-C to stop");
         }
-        tracing::info!(addr = %actual, auth_enabled = auth.token().is_some(), "cqs serve started");
+        tracing::info!(
+            addr = %actual,
+            auth_enabled = auth.token().is_some(),
+            named_tokens = auth.named_token_count(),
+            "cqs serve started"
+        );
 
         // Race the SIGINT/SIGTERM signal future against an idle-eviction
         // future. With `idle_minutes == 0` the idle future is
-        // `pending::<()>()` and the server only exits on signal.
-        axum::serve(listener, app)
-            .with_graceful_shutdown(idle_or_signal(last_request_epoch, idle_minutes))
+        // `pending::<()>()` and the server only exits on signal. The watch
+        // channel relays that one shutdown to the gRPC listener.
+        let (stop_tx, stop_rx) = tokio::sync::watch::channel(false);
+        let http = async move {
+            // Record the peer address so the rate limiter can key
+            // unauthenticated (`--no-auth`) clients by IP.
+            axum::serve(
+                listener,
+                app.into_make_service_with_connect_info::<SocketAddr>(),
+            )
+            .with_graceful_shutdown(async move {
+                idle_or_signal(last_request_epoch, idle_minutes).await;
+                let _ = stop_tx.send(true);
+            })
             .await
-            .context("axum server failed")?;
+            .context("axum server failed")
+        };
+
+        #[cfg(feature = "grpc")]
+        if let Some((service, grpc_addr)) = grpc {
+            tokio::try_join!(http, grpc::serve(service, grpc_addr, quiet, stop_rx))?;
+        } else {
+            http.await?;
+        }
+        #[cfg(not(feature = "grpc"))]
+        {
+            if let Some(never) = grpc {
+                match never {}
+            }
+            drop(stop_rx);
+            http.await?;
+        }
 
         tracing::info!("cqs serve shut down cleanly");
         Ok::<_, anyhow::Error>(())
@@ -261,7 +365,8 @@ fn auth_banner_non_tty(actual: SocketAddr) -> Vec<String> {
          avoid persisting it into journald/container logs)"
             .to_string(),
         "(the per-launch token is generated fresh each start and only shown on an interactive \
-         terminal; relaunch in a terminal to see it, or pass --no-auth for unattended use)"
+         terminal; relaunch in a terminal to see it, or create a named token with \
+         `cqs serve token create <name>` for unattended use)"
             .to_string(),
     ]
 }
@@ -378,21 +483,70 @@ async fn enforce_concurrency_cap(
     response
 }
 
+/// Access log: one `cqs::serve::access` event per request with method, path
+/// (never the query string — it may carry `?token=`), status, latency, and
+/// the token name and scope the request authenticated as (`-` when it
+/// didn't, or auth is off). Sits outside auth and reads the
+/// [`auth::Principal`] that [`auth::enforce_auth`] leaves on the response.
+async fn log_access(request: Request, next: Next) -> Response {
+    let method = request.method().clone();
+    let path = request.uri().path().to_string();
+    let started = std::time::Instant::now();
+    let response = next.run(request).await;
+    if path.starts_with("/api/") {
+        let status = response.status();
+        crate::metrics::record_query(
+            crate::metrics::QuerySurface::Http,
+            started.elapsed(),
+            status.is_success() || status.is_redirection(),
+        );
+    }
+    let principal = response.extensions().get::<auth::Principal>();
+    tracing::info!(
+        target: "cqs::serve::access",
+        method = %method,
+        path = %path,
+        status = response.status().as_u16(),
+        latency_ms = started.elapsed().as_millis() as u64,
+        token = principal.map_or("-", |p| &*p.name),
+        scope = principal.map_or("-", |p| p.scope.as_str()),
+        "serve request"
+    );
+    response
+}
+
 pub(crate) fn build_router(state: AppState, allowed_hosts: AllowedHosts, auth: AuthMode) -> Router {
+    with_edge_layers(api_router(state, auth), allowed_hosts)
+}
+
+/// One project's routes with the layers that depend on its state: idle
+/// clock, rate limit, and auth against its credentials. [`build_router`]
+/// serves one of these at the root; `projects.rs` dispatches between several.
+pub(crate) fn api_router(state: AppState, auth: AuthMode) -> Router {
     let touch_state = state.clone();
-    let conn_sem = Arc::new(tokio::sync::Semaphore::new(
-        crate::limits::serve_max_concurrent_requests(),
-    ));
+    let rate_limiter = Arc::clone(&state.rate_limiter);
     let mut app = Router::new()
         .route("/health", get(handlers::health))
         .route("/api/stats", get(handlers::stats))
+        .route("/api/metrics", get(handlers::metrics))
+        .route("/metrics", get(handlers::prometheus_metrics))
         .route("/api/graph", get(handlers::graph))
         .route("/api/chunk/{id}", get(handlers::chunk_detail))
         .route("/api/hierarchy/{id}", get(handlers::hierarchy))
         .route("/api/embed/2d", get(handlers::cluster_2d))
         .route("/api/search", get(handlers::search))
+        .route("/api/search/stream", get(handlers::search_stream))
         .route("/api/search_legs", get(handlers::search_legs))
         .route("/api/eval_gold", get(handlers::eval_gold))
+        // Admin routes: the scope check runs inside auth (layered below), so
+        // the principal is already on the request.
+        .route(
+            "/api/admin/reindex",
+            post(handlers::admin_reindex).route_layer(from_fn_with_state(
+                tokens::Scope::Admin,
+                auth::require_scope,
+            )),
+        )
         .route("/", get(assets::index_html))
         .route("/static/{*path}", get(assets::static_asset))
         .with_state(state);
@@ -403,15 +557,28 @@ pub(crate) fn build_router(state: AppState, allowed_hosts: AllowedHosts, auth: A
     // the server alive."
     app = app.layer(from_fn_with_state(touch_state, touch_idle_clock));
 
+    // Per-client rate limit. Sits inside auth so the bucket is keyed by the
+    // authenticated token name, and a request with bad credentials is
+    // refused before it can drain anyone's bucket.
+    app = app.layer(from_fn_with_state(
+        rate_limiter,
+        ratelimit::enforce_rate_limit,
+    ));
+
     // Per-launch auth. Sits inside the host-header allowlist (rejected hosts
     // skip auth — saves a constant-time compare on a request we'd reject
     // anyway) and outside the compression/trace layers (so 401 responses are
     // still gzipped and traced).
     match auth {
-        AuthMode::Required { token, cookie_port } => {
+        AuthMode::Required {
+            token,
+            cookie_port,
+            named,
+        } => {
             // `new()` pre-builds the cookie name and lookup needle once so the
             // per-request middleware path doesn't allocate.
-            let middleware_state = auth::AuthMiddlewareState::new(token, cookie_port);
+            let credentials = auth::Credentials::new(token, named);
+            let middleware_state = auth::AuthMiddlewareState::new(credentials, cookie_port);
             app = app.layer(from_fn_with_state(middleware_state, auth::enforce_auth));
         }
         AuthMode::Disabled(_ack) => {
@@ -424,12 +591,25 @@ pub(crate) fn build_router(state: AppState, allowed_hosts: AllowedHosts, auth: A
         }
     }
 
+    app
+}
+
+/// The request-edge layers shared by every route, whichever project it
+/// belongs to: host allowlist, access log, body limit, compression, trace,
+/// and the process-wide concurrency cap.
+pub(crate) fn with_edge_layers(app: Router, allowed_hosts: AllowedHosts) -> Router {
+    let conn_sem = Arc::new(tokio::sync::Semaphore::new(
+        crate::limits::serve_max_concurrent_requests(),
+    ));
     app
         // Host-header allowlist closes the DNS-rebinding class. Must sit
         // inside the compression layer so rejections skip the gzip round-trip.
         .layer(from_fn_with_state(allowed_hosts, enforce_host_allowlist))
-        // Cap request bodies. Every route is GET; legitimate
-        // clients never send a body. 64 KiB is plenty for query strings
+        // One access-log event per request that passed the host check,
+        // including 401/403s.
+        .layer(axum::middleware::from_fn(log_access))
+        // Cap request bodies. Every route is GET except the body-less admin
+        // POSTs; legitimate clients never send a body. 64 KiB is plenty for query strings
         // and cookies (which travel in headers, not body); axum rejects
         // bodies larger than this with 413 Payload Too Large before
         // allocating. Layer sits *outside* auth/host-allowlist so the
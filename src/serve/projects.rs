@@ -0,0 +1,526 @@
+//! Several projects behind one `cqs serve` (`--projects projects.toml`).
+//!
+//! A team server shouldn't need one process and one port per repository.
+//! Each `[[project]]` in the file gets its own read-only store, its own
+//! `spawn_blocking` quota and rate limiter, its own named tokens
+//! (`<root>/.cqs/serve_tokens.json`) and its own retrieval-daemon socket —
+//! so `/api/admin/reindex` reaches that project's daemon, which holds that
+//! project's index lock and no other. Projects share only the listener, the
+//! edge layers (host allowlist, body limit, concurrency cap) and the
+//! per-launch token, which is `admin` everywhere.
+//!
+//! ```toml
+//! [[project]]
+//! name = "api"
+//! root = "/srv/src/api"   # relative paths resolve against this file
+//! blocking_permits = 8    # default: CQS_SERVE_BLOCKING_PERMITS
+//! rate_limit = 20.0       # default: CQS_SERVE_RATE_LIMIT
+//! rate_burst = 40         # default: CQS_SERVE_RATE_BURST
+//! ```
+//!
+//! A request reaches a project by, in order:
+//! 1. path prefix — `/p/<name>/...` is served as `/...` of that project;
+//! 2. token — a named token belongs to one project;
+//! 3. the `cqs_project_<port>` cookie, set on every `/p/<name>/` response, so
+//!    the web UI's `/api/*` fetches follow the page they came from;
+//! 4. the only project, when there is one.
+//!
+//! `GET /api/projects` lists the projects the caller's credentials reach.
+
+use std::collections::HashSet;
+use std::net::SocketAddr;
+use std::path::{Path, PathBuf};
+use std::sync::atomic::AtomicU64;
+use std::sync::Arc;
+
+use axum::{
+    extract::{Request, State},
+    http::{header, HeaderValue, StatusCode, Uri},
+    response::{IntoResponse, Response},
+    routing::get,
+    Json, Router,
+};
+use serde::{Deserialize, Serialize};
+use thiserror::Error;
+use tower::util::ServiceExt;
+
+use super::auth::{self, Credentials};
+use super::error::ServeError;
+use super::ratelimit::RateLimiter;
+use super::tokens::{TokenStore, LAUNCH_TOKEN_NAME};
+use super::{
+    allowed_host_set, api_router, now_epoch_secs, serve_app, with_edge_layers, AllowedHosts,
+    AppState, AuthMode,
+};
+use crate::store::{ReadOnly, Store};
+
+/// Path prefix that selects a project: `/p/<name>/...`.
+pub const PROJECT_PATH_PREFIX: &str = "/p/";
+
+/// Cookie remembering the last project opened, suffixed with the port like
+/// the token cookie.
+const PROJECT_COOKIE_PREFIX: &str = "cqs_project";
+
+const MAX_PROJECT_NAME_LEN: usize = 64;
+
+#[derive(Debug, Error)]
+pub enum ProjectsError {
+    #[error("Cannot read projects file {path}: {source}")]
+    Io {
+        path: PathBuf,
+        #[source]
+        source: std::io::Error,
+    },
+    #[error("Malformed projects file {path}: {source}")]
+    Toml {
+        path: PathBuf,
+        #[source]
+        source: toml::de::Error,
+    },
+    #[error("Projects file {0} lists no [[project]]")]
+    Empty(PathBuf),
+    #[error("Invalid project name '{0}': use letters, digits, '_', '-' or '.' (max 64, not starting with '.' or '-')")]
+    InvalidName(String),
+    #[error("Project '{0}' is listed twice")]
+    Duplicate(String),
+}
+
+/// One `[[project]]` entry. Unset quotas fall back to the process-wide
+/// `CQS_SERVE_*` values.
+#[derive(Debug, Clone, PartialEq, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct ProjectSpec {
+    /// Routing name, the `<name>` of `/p/<name>/`.
+    pub name: String,
+    /// Project root; its index is resolved as for any cqs command run there.
+    pub root: PathBuf,
+    /// `spawn_blocking` slots for this project's handlers (1..=1024).
+    #[serde(default)]
+    pub blocking_permits: Option<usize>,
+    /// Requests per second per client; `0` disables the limit.
+    #[serde(default)]
+    pub rate_limit: Option<f64>,
+    /// Requests a client may burst past the rate.
+    #[serde(default)]
+    pub rate_burst: Option<usize>,
+}
+
+impl ProjectSpec {
+    /// The project's blocking-permit quota.
+    pub fn permits(&self) -> usize {
+        self.blocking_permits
+            .unwrap_or_else(crate::limits::serve_blocking_permits)
+            .clamp(1, 1024)
+    }
+
+    fn rate_limiter(&self) -> RateLimiter {
+        let rate = self
+            .rate_limit
+            .filter(|r| r.is_finite())
+            .unwrap_or_else(crate::limits::serve_rate_limit_per_sec)
+            .max(0.0);
+        let burst = self
+            .rate_burst
+            .unwrap_or_else(crate::limits::serve_rate_burst);
+        RateLimiter::new(rate, burst)
+    }
+}
+
+#[derive(Deserialize)]
+#[serde(deny_unknown_fields)]
+struct ProjectsFile {
+    #[serde(default)]
+    project: Vec<ProjectSpec>,
+}
+
+/// Read and check a projects file. Relative roots resolve against the
+/// file's directory.
+pub fn load_projects(path: &Path) -> Result<Vec<ProjectSpec>, ProjectsError> {
+    let text = std::fs::read_to_string(path).map_err(|source| ProjectsError::Io {
+        path: path.to_path_buf(),
+        source,
+    })?;
+    let file: ProjectsFile = toml::from_str(&text).map_err(|source| ProjectsError::Toml {
+        path: path.to_path_buf(),
+        source,
+    })?;
+    if file.project.is_empty() {
+        return Err(ProjectsError::Empty(path.to_path_buf()));
+    }
+    let base = path.parent().unwrap_or_else(|| Path::new(""));
+    let mut seen = HashSet::new();
+    let mut projects = file.project;
+    for project in &mut projects {
+        if !valid_project_name(&project.name) {
+            return Err(ProjectsError::InvalidName(project.name.clone()));
+        }
+        if !seen.insert(project.name.clone()) {
+            return Err(ProjectsError::Duplicate(project.name.clone()));
+        }
+        if project.root.is_relative() {
+            project.root = base.join(&project.root);
+        }
+    }
+    Ok(projects)
+}
+
+/// Same alphabet as token names: the name lands in a URL path and a cookie.
+fn valid_project_name(name: &str) -> bool {
+    !name.is_empty()
+        && name.len() <= MAX_PROJECT_NAME_LEN
+        && !name.starts_with('.')
+        && !name.starts_with('-')
+        && name
+            .bytes()
+            .all(|b| b.is_ascii_alphanumeric() || matches!(b, b'_' | b'-' | b'.'))
+}
+
+/// A project opened and ready to serve.
+pub struct ServedProject {
+    pub spec: ProjectSpec,
+    pub store: Store<ReadOnly>,
+    /// The project's named tokens; they open this project only.
+    pub tokens: TokenStore,
+    /// The project's retrieval-daemon socket (`None` off unix).
+    pub daemon_socket: Option<PathBuf>,
+}
+
+/// Run `cqs serve --projects`: one listener, one router per project.
+///
+/// `auth` is the server-wide mode; each project adds its own named tokens
+/// to it for its routes only. There is no gRPC listener — `--grpc` serves a
+/// single index.
+pub fn run_projects_server(
+    projects: Vec<ServedProject>,
+    bind_addr: SocketAddr,
+    quiet: bool,
+    auth: AuthMode,
+) -> anyhow::Result<()> {
+    let _span = tracing::info_span!("serve_projects", addr = %bind_addr, projects = projects.len())
+        .entered();
+    let last_request_epoch = Arc::new(AtomicU64::new(now_epoch_secs()));
+    let mut served = Vec::with_capacity(projects.len());
+    for project in projects {
+        let spec = project.spec;
+        let permits = spec.permits();
+        let rate_limiter = spec.rate_limiter();
+        let limits = rate_limiter.metrics();
+        tracing::info!(
+            project = %spec.name,
+            root = %spec.root.display(),
+            permits,
+            rate_per_sec = limits.rate_per_sec,
+            burst = limits.burst,
+            named_tokens = project.tokens.records().len(),
+            "serve: project loaded"
+        );
+        if !quiet {
+            println!(
+                "project {} at {}{}/",
+                spec.name, PROJECT_PATH_PREFIX, spec.name
+            );
+        }
+        let state = AppState {
+            store: Arc::new(project.store),
+            blocking_permits: Arc::new(tokio::sync::Semaphore::new(permits)),
+            last_request_epoch: Arc::clone(&last_request_epoch),
+            daemon_socket: project.daemon_socket.map(Arc::new),
+            eval_root: Some(Arc::new(spec.root)),
+            rate_limiter: Arc::new(rate_limiter),
+        };
+        served.push((spec.name, state, project.tokens));
+    }
+    let app = build_projects_router(
+        served,
+        allowed_host_set(&bind_addr),
+        auth.clone(),
+        bind_addr.port(),
+    );
+    serve_app(app, bind_addr, quiet, &auth, last_request_epoch, None)
+}
+
+/// One project as the dispatcher sees it.
+struct Project {
+    name: Arc<str>,
+    /// The project's routes, its auth layer included.
+    router: Router,
+    /// What that auth layer accepts; `None` with auth off.
+    credentials: Option<Credentials>,
+}
+
+impl Project {
+    async fn forward(&self, req: Request) -> Response {
+        match self.router.clone().oneshot(req).await {
+            Ok(resp) => resp,
+            Err(never) => match never {},
+        }
+    }
+}
+
+/// Dispatcher state: every project plus the cookie needles routing reads.
+pub(crate) struct Projects {
+    projects: Vec<Project>,
+    auth_required: bool,
+    /// `cqs_token_<port>=`, to read the token cookie while routing.
+    token_needle: String,
+    /// `cqs_project_<port>`.
+    cookie_name: String,
+    /// `cqs_project_<port>=`.
+    cookie_needle: String,
+}
+
+impl Projects {
+    fn get(&self, name: &str) -> Option<&Project> {
+        self.projects.iter().find(|p| &*p.name == name)
+    }
+
+    /// The principal `req` authenticates as on `project`. With auth off
+    /// there is none, and every project admits every request.
+    fn principal(&self, project: &Project, req: &Request) -> Option<auth::Principal> {
+        let creds = project.credentials.as_ref()?;
+        auth::principal_for(req, creds, &self.token_needle)
+    }
+
+    fn admits(&self, project: &Project, req: &Request) -> bool {
+        !self.auth_required || self.principal(project, req).is_some()
+    }
+
+    /// The project an unprefixed request goes to: its named token's, else
+    /// the cookie's, else the only one.
+    fn select(&self, req: &Request) -> Option<&Project> {
+        self.projects
+            .iter()
+            .find(|p| {
+                self.principal(p, req)
+                    .is_some_and(|principal| &*principal.name != LAUNCH_TOKEN_NAME)
+            })
+            .or_else(|| cookie_value(req, &self.cookie_needle).and_then(|name| self.get(name)))
+            .or_else(|| match self.projects.as_slice() {
+                [only] => Some(only),
+                _ => None,
+            })
+    }
+
+    /// 401 when `req` opens no project at all, so an unauthenticated client
+    /// can't probe for project names; otherwise `err`.
+    fn refuse(&self, req: &Request, err: ServeError) -> Response {
+        if self.projects.iter().any(|p| self.admits(p, req)) {
+            err.into_response()
+        } else {
+            (StatusCode::UNAUTHORIZED, "Unauthorized").into_response()
+        }
+    }
+
+    fn project_cookie(&self, name: &str) -> Option<HeaderValue> {
+        HeaderValue::from_str(&format!(
+            "{}={name}; Path=/; HttpOnly; SameSite=Strict",
+            self.cookie_name
+        ))
+        .ok()
+    }
+}
+
+/// Build the multi-project router. Each entry is a project's name, state
+/// and named tokens; `auth` is the server-wide mode and `port` the bind
+/// port, which names the cookies.
+pub(crate) fn build_projects_router(
+    projects: Vec<(String, AppState, TokenStore)>,
+    allowed_hosts: AllowedHosts,
+    auth: AuthMode,
+    port: u16,
+) -> Router {
+    let auth_required = auth.token().is_some();
+    let projects = projects
+        .into_iter()
+        .map(|(name, state, tokens)| {
+            let auth = auth.clone().with_tokens(&tokens);
+            Project {
+                name: Arc::from(name),
+                credentials: auth.credentials(),
+                router: api_router(state, auth),
+            }
+        })
+        .collect();
+    let cookie_name = format!("{PROJECT_COOKIE_PREFIX}_{port}");
+    let dispatcher = Arc::new(Projects {
+        projects,
+        auth_required,
+        token_needle: format!("{}=", auth::cookie_name_for_port(port)),
+        cookie_needle: format!("{cookie_name}="),
+        cookie_name,
+    });
+    let app = Router::new()
+        .route("/api/projects", get(list_projects))
+        .fallback(dispatch)
+        .with_state(dispatcher);
+    with_edge_layers(app, allowed_hosts)
+}
+
+#[derive(Debug, Serialize)]
+struct ProjectEntry {
+    name: String,
+    path: String,
+}
+
+#[derive(Debug, Serialize)]
+struct ProjectsResponse {
+    projects: Vec<ProjectEntry>,
+}
+
+/// `GET /api/projects` — the projects the caller's credentials open.
+async fn list_projects(State(projects): State<Arc<Projects>>, req: Request) -> Response {
+    let reachable: Vec<ProjectEntry> = projects
+        .projects
+        .iter()
+        .filter(|p| projects.admits(p, &req))
+        .map(|p| ProjectEntry {
+            name: p.name.to_string(),
+            path: format!("{PROJECT_PATH_PREFIX}{}/", p.name),
+        })
+        .collect();
+    if reachable.is_empty() {
+        return (StatusCode::UNAUTHORIZED, "Unauthorized").into_response();
+    }
+    Json(ProjectsResponse {
+        projects: reachable,
+    })
+    .into_response()
+}
+
+/// Route a request to its project. A `/p/<name>` prefix is stripped before
+/// the project's router sees the request, and put back on any redirect it
+/// answers with (the `?token=` handoff redirects to the bare path).
+async fn dispatch(State(projects): State<Arc<Projects>>, mut req: Request) -> Response {
+    let Some((name, rest)) = split_project_path(req.uri().path()) else {
+        return match projects.select(&req) {
+            Some(project) => project.forward(req).await,
+            None => projects.refuse(
+                &req,
+                ServeError::NotFound(format!(
+                    "no project selected: prefix the path with {PROJECT_PATH_PREFIX}<name>/ \
+                     (see /api/projects)"
+                )),
+            ),
+        };
+    };
+    let Some(project) = projects.get(name) else {
+        let err = ServeError::NotFound(format!("project '{name}'"));
+        return projects.refuse(&req, err);
+    };
+    let Some(uri) = rebase_uri(req.uri(), rest) else {
+        return ServeError::BadRequest("malformed request path".to_string()).into_response();
+    };
+    *req.uri_mut() = uri;
+
+    let mut resp = project.forward(req).await;
+    let prefix = format!("{PROJECT_PATH_PREFIX}{}", project.name);
+    let location = resp
+        .headers()
+        .get(header::LOCATION)
+        .and_then(|v| v.to_str().ok())
+        .filter(|loc| loc.starts_with('/'))
+        .and_then(|loc| HeaderValue::from_str(&format!("{prefix}{loc}")).ok());
+    if let Some(location) = location {
+        resp.headers_mut().insert(header::LOCATION, location);
+    }
+    if resp.status() != StatusCode::UNAUTHORIZED {
+        if let Some(cookie) = projects.project_cookie(&project.name) {
+            resp.headers_mut().append(header::SET_COOKIE, cookie);
+        }
+    }
+    resp
+}
+
+/// `/p/<name>/<rest>` → `(name, "/<rest>")`; `/p/<name>` → `(name, "/")`.
+fn split_project_path(path: &str) -> Option<(&str, &str)> {
+    let tail = path.strip_prefix(PROJECT_PATH_PREFIX)?;
+    Some(match tail.find('/') {
+        Some(i) => (&tail[..i], &tail[i..]),
+        None => (tail, "/"),
+    })
+}
+
+/// `uri` with its path replaced by `path`, query kept.
+fn rebase_uri(uri: &Uri, path: &str) -> Option<Uri> {
+    let path_and_query = match uri.query() {
+        Some(query) => format!("{path}?{query}"),
+        None => path.to_string(),
+    };
+    Uri::builder().path_and_query(path_and_query).build().ok()
+}
+
+fn cookie_value<'r>(req: &'r Request, needle: &str) -> Option<&'r str> {
+    req.headers()
+        .get_all(header::COOKIE)
+        .iter()
+        .filter_map(|v| v.to_str().ok())
+        .flat_map(|v| v.split(';'))
+        .find_map(|pair| pair.trim().strip_prefix(needle))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn write(dir: &Path, body: &str) -> PathBuf {
+        let path = dir.join("projects.toml");
+        std::fs::write(&path, body).unwrap();
+        path
+    }
+
+    #[test]
+    fn loads_projects_and_resolves_relative_roots() {
+        let dir = tempfile::TempDir::new().unwrap();
+        let path = write(
+            dir.path(),
+            r#"
+[[project]]
+name = "api"
+root = "repos/api"
+blocking_permits = 4
+rate_limit = 2.5
+
+[[project]]
+name = "web"
+root = "/srv/web"
+"#,
+        );
+        let projects = load_projects(&path).unwrap();
+        assert_eq!(projects.len(), 2);
+        assert_eq!(projects[0].root, dir.path().join("repos/api"));
+        assert_eq!(projects[0].permits(), 4);
+        assert_eq!(projects[0].rate_limiter().metrics().rate_per_sec, 2.5);
+        assert_eq!(projects[1].root, PathBuf::from("/srv/web"));
+        assert_eq!(projects[1].blocking_permits, None);
+    }
+
+    #[test]
+    fn rejects_bad_projects_files() {
+        let dir = tempfile::TempDir::new().unwrap();
+        let cases = [
+            ("", "lists no"),
+            ("[[project]]\nname = \"a b\"\nroot = \"x\"\n", "Invalid project name"),
+            (
+                "[[project]]\nname = \"a\"\nroot = \"x\"\n[[project]]\nname = \"a\"\nroot = \"y\"\n",
+                "listed twice",
+            ),
+            ("[[project]]\nname = \"a\"\nroot = \"x\"\nquota = 1\n", "Malformed"),
+        ];
+        for (body, expected) in cases {
+            let err = load_projects(&write(dir.path(), body)).unwrap_err();
+            assert!(err.to_string().contains(expected), "{body:?}: {err}");
+        }
+    }
+
+    #[test]
+    fn splits_project_prefix() {
+        assert_eq!(
+            split_project_path("/p/api/api/stats"),
+            Some(("api", "/api/stats"))
+        );
+        assert_eq!(split_project_path("/p/api"), Some(("api", "/")));
+        assert_eq!(split_project_path("/api/stats"), None);
+        let uri: Uri = "/p/api/api/search?q=x".parse().unwrap();
+        assert_eq!(rebase_uri(&uri, "/api/search").unwrap(), "/api/search?q=x");
+    }
+}
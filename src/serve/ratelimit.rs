@@ -0,0 +1,278 @@
+//! Per-client token-bucket rate limiting for `cqs serve`.
+//!
+//! The concurrency cap (`enforce_concurrency_cap`) bounds how much work is
+//! in flight at once, but not who it belongs to: one script looping on
+//! `/api/search` can keep the blocking permits busy and starve everyone
+//! else. This limiter gives every client its own bucket of `burst` requests
+//! that refills at `rate` per second; an empty bucket answers
+//! `429 Too Many Requests` with a `Retry-After` header.
+//!
+//! A client is the named token the request authenticated as (the per-launch
+//! token counts as `launch`), or the peer IP when auth is off. The middleware
+//! sits inside auth, so requests with bad credentials are refused there and
+//! never consume a bucket. `/health` and `/static/*` are exempt.
+//!
+//! Counters (allowed, throttled, per-client throttles) are served at
+//! `GET /api/metrics`.
+
+use std::collections::HashMap;
+use std::net::SocketAddr;
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::{Arc, Mutex};
+use std::time::{Duration, Instant};
+
+use axum::{
+    extract::{ConnectInfo, Request, State},
+    middleware::Next,
+    response::{IntoResponse, Response},
+};
+use serde::Serialize;
+
+use super::auth::Principal;
+use super::error::ServeError;
+
+/// Buckets kept before idle ones are dropped. Past this, with no bucket idle
+/// long enough to drop, new clients share one overflow bucket — memory stays
+/// bounded however many source addresses a flood uses.
+const MAX_TRACKED_CLIENTS: usize = 4096;
+
+/// Key of the shared bucket used once [`MAX_TRACKED_CLIENTS`] is reached.
+const OVERFLOW_CLIENT: &str = "overflow";
+
+/// How many clients `/api/metrics` lists under `top_throttled`.
+const TOP_THROTTLED: usize = 10;
+
+struct Bucket {
+    tokens: f64,
+    last: Instant,
+    throttled: u64,
+}
+
+/// Token buckets for every client seen, plus global counters.
+pub(crate) struct RateLimiter {
+    /// Refill rate in requests per second; `0.0` disables the limiter.
+    rate: f64,
+    burst: f64,
+    buckets: Mutex<HashMap<String, Bucket>>,
+    allowed: AtomicU64,
+    throttled: AtomicU64,
+}
+
+/// `rate_limit` section of `GET /api/metrics`.
+#[derive(Debug, Serialize)]
+pub(crate) struct RateLimitMetrics {
+    pub enabled: bool,
+    pub rate_per_sec: f64,
+    pub burst: u64,
+    pub allowed: u64,
+    pub throttled: u64,
+    pub clients_tracked: usize,
+    /// Clients with at least one throttled request, most throttled first.
+    pub top_throttled: Vec<ClientThrottles>,
+}
+
+#[derive(Debug, Serialize)]
+pub(crate) struct ClientThrottles {
+    pub client: String,
+    pub throttled: u64,
+}
+
+impl RateLimiter {
+    /// A limiter refilling each client's bucket at `rate` requests per second
+    /// up to `burst`. `rate == 0.0` disables limiting.
+    pub(crate) fn new(rate: f64, burst: usize) -> Self {
+        Self {
+            rate,
+            burst: burst.max(1) as f64,
+            buckets: Mutex::new(HashMap::new()),
+            allowed: AtomicU64::new(0),
+            throttled: AtomicU64::new(0),
+        }
+    }
+
+    /// A limiter sized from `CQS_SERVE_RATE_LIMIT` / `CQS_SERVE_RATE_BURST`.
+    pub(crate) fn from_env() -> Self {
+        Self::new(
+            crate::limits::serve_rate_limit_per_sec(),
+            crate::limits::serve_rate_burst(),
+        )
+    }
+
+    pub(crate) fn enabled(&self) -> bool {
+        self.rate > 0.0
+    }
+
+    /// Take one token from `client`'s bucket. `Err` carries how long until a
+    /// token is available.
+    pub(crate) fn check(&self, client: &str, now: Instant) -> Result<(), Duration> {
+        if !self.enabled() {
+            return Ok(());
+        }
+        let mut buckets = self.buckets.lock().unwrap_or_else(|p| p.into_inner());
+        if !buckets.contains_key(client) && buckets.len() >= MAX_TRACKED_CLIENTS {
+            self.prune(&mut buckets, now);
+        }
+        let key = if buckets.contains_key(client) || buckets.len() < MAX_TRACKED_CLIENTS {
+            client
+        } else {
+            OVERFLOW_CLIENT
+        };
+        let bucket = buckets.entry(key.to_string()).or_insert(Bucket {
+            tokens: self.burst,
+            last: now,
+            throttled: 0,
+        });
+        let elapsed = now.saturating_duration_since(bucket.last).as_secs_f64();
+        bucket.tokens = (bucket.tokens + elapsed * self.rate).min(self.burst);
+        bucket.last = now;
+        if bucket.tokens >= 1.0 {
+            bucket.tokens -= 1.0;
+            self.allowed.fetch_add(1, Ordering::Relaxed);
+            Ok(())
+        } else {
+            bucket.throttled += 1;
+            self.throttled.fetch_add(1, Ordering::Relaxed);
+            Err(Duration::from_secs_f64((1.0 - bucket.tokens) / self.rate))
+        }
+    }
+
+    /// Drop buckets that have refilled completely — their client has been
+    /// idle long enough that a fresh bucket is indistinguishable.
+    fn prune(&self, buckets: &mut HashMap<String, Bucket>, now: Instant) {
+        let refill = Duration::from_secs_f64(self.burst / self.rate);
+        let before = buckets.len();
+        buckets.retain(|_, b| now.saturating_duration_since(b.last) < refill);
+        tracing::debug!(
+            dropped = before - buckets.len(),
+            "serve: pruned idle rate-limit buckets"
+        );
+    }
+
+    pub(crate) fn metrics(&self) -> RateLimitMetrics {
+        let buckets = self.buckets.lock().unwrap_or_else(|p| p.into_inner());
+        let mut top_throttled: Vec<ClientThrottles> = buckets
+            .iter()
+            .filter(|(_, b)| b.throttled > 0)
+            .map(|(client, b)| ClientThrottles {
+                client: client.clone(),
+                throttled: b.throttled,
+            })
+            .collect();
+        top_throttled.sort_by(|a, b| {
+            b.throttled
+                .cmp(&a.throttled)
+                .then_with(|| a.client.cmp(&b.client))
+        });
+        top_throttled.truncate(TOP_THROTTLED);
+        RateLimitMetrics {
+            enabled: self.enabled(),
+            rate_per_sec: self.rate,
+            burst: self.burst as u64,
+            allowed: self.allowed.load(Ordering::Relaxed),
+            throttled: self.throttled.load(Ordering::Relaxed),
+            clients_tracked: buckets.len(),
+            top_throttled,
+        }
+    }
+}
+
+/// Bucket key for a request: `token:<name>` when it authenticated, else
+/// `ip:<peer>` when the listener recorded the peer address, else
+/// `anonymous` (in-process test routers have no peer).
+fn client_key(req: &Request) -> String {
+    if let Some(principal) = req.extensions().get::<Principal>() {
+        return format!("token:{}", principal.name);
+    }
+    match req.extensions().get::<ConnectInfo<SocketAddr>>() {
+        Some(ConnectInfo(addr)) => format!("ip:{}", addr.ip()),
+        None => "anonymous".to_string(),
+    }
+}
+
+/// Routes that never count against a bucket: liveness probes and the
+/// embedded frontend assets.
+fn is_exempt(path: &str) -> bool {
+    path == "/health" || path.starts_with("/static/")
+}
+
+/// axum middleware: charge the request to its client's bucket, or answer
+/// `429` with `Retry-After` when the bucket is empty.
+pub(crate) async fn enforce_rate_limit(
+    State(limiter): State<Arc<RateLimiter>>,
+    req: Request,
+    next: Next,
+) -> Response {
+    if !limiter.enabled() || is_exempt(req.uri().path()) {
+        return next.run(req).await;
+    }
+    let client = client_key(&req);
+    match limiter.check(&client, Instant::now()) {
+        Ok(()) => next.run(req).await,
+        Err(wait) => {
+            tracing::debug!(client = %client, wait_ms = wait.as_millis() as u64, "serve: rate limited");
+            ServeError::RateLimited {
+                retry_after_secs: wait.as_secs_f64().ceil().max(1.0) as u64,
+            }
+            .into_response()
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn burst_then_throttle_then_refill() {
+        let limiter = RateLimiter::new(2.0, 3);
+        let t0 = Instant::now();
+        for _ in 0..3 {
+            assert!(limiter.check("a", t0).is_ok());
+        }
+        let wait = limiter.check("a", t0).unwrap_err();
+        assert_eq!(wait, Duration::from_millis(500));
+        // Half a second at 2/s refills one token.
+        assert!(limiter.check("a", t0 + Duration::from_millis(500)).is_ok());
+        assert!(limiter.check("a", t0 + Duration::from_millis(500)).is_err());
+
+        let m = limiter.metrics();
+        assert_eq!((m.allowed, m.throttled), (4, 2));
+        assert_eq!(m.top_throttled[0].client, "a");
+    }
+
+    #[test]
+    fn clients_have_separate_buckets() {
+        let limiter = RateLimiter::new(1.0, 1);
+        let t0 = Instant::now();
+        assert!(limiter.check("token:bot", t0).is_ok());
+        assert!(limiter.check("token:bot", t0).is_err());
+        assert!(limiter.check("token:alice", t0).is_ok());
+    }
+
+    #[test]
+    fn zero_rate_disables() {
+        let limiter = RateLimiter::new(0.0, 1);
+        let t0 = Instant::now();
+        for _ in 0..10 {
+            assert!(limiter.check("a", t0).is_ok());
+        }
+        assert!(!limiter.metrics().enabled);
+        assert_eq!(limiter.metrics().clients_tracked, 0);
+    }
+
+    #[test]
+    fn client_table_is_bounded() {
+        let limiter = RateLimiter::new(1.0, 1);
+        let t0 = Instant::now();
+        for i in 0..MAX_TRACKED_CLIENTS + 10 {
+            let _ = limiter.check(&format!("ip:{i}"), t0);
+        }
+        let m = limiter.metrics();
+        assert_eq!(m.clients_tracked, MAX_TRACKED_CLIENTS + 1);
+        // Once the idle buckets have refilled, a new client prunes them.
+        assert!(limiter
+            .check("ip:late", t0 + Duration::from_secs(2))
+            .is_ok());
+        assert_eq!(limiter.metrics().clients_tracked, 1);
+    }
+}
@@ -102,6 +102,7 @@ fn fixture_state() -> Fixture {
             // a temp `eval_root`; the handler-shape fixtures leave it unset so
             // `/api/eval_gold` 503s (its structurally-unavailable path).
             eval_root: None,
+            rate_limiter: Arc::new(super::ratelimit::RateLimiter::from_env()),
         }),
         _dir: Some(dir),
     }
@@ -248,6 +249,7 @@ fn populated_fixture(n_chunks: usize, with_umap: bool) -> Fixture {
             // a temp `eval_root`; the handler-shape fixtures leave it unset so
             // `/api/eval_gold` 503s (its structurally-unavailable path).
             eval_root: None,
+            rate_limiter: Arc::new(super::ratelimit::RateLimiter::from_env()),
         }),
         _dir: Some(dir),
     }
@@ -336,6 +338,7 @@ fn single_chunk_fixture(content: &str, vendored: bool) -> (Fixture, String) {
             // a temp `eval_root`; the handler-shape fixtures leave it unset so
             // `/api/eval_gold` 503s (its structurally-unavailable path).
             eval_root: None,
+            rate_limiter: Arc::new(super::ratelimit::RateLimiter::from_env()),
         }),
         _dir: Some(dir),
     };
@@ -797,6 +800,45 @@ async fn stats_endpoint_returns_chunks_count() {
     assert!(json.get("total_chunks").is_some(), "total_chunks missing");
 }
 
+/// `/metrics` speaks the Prometheus text format and counts the `/api/*`
+/// request that preceded it.
+#[tokio::test(flavor = "multi_thread")]
+async fn prometheus_metrics_endpoint_renders_text_format() {
+    let fixture = fixture_state();
+    let app = test_router(fixture.state());
+    let (status, _) = get_json(app.clone(), "/api/stats").await;
+    assert_eq!(status, StatusCode::OK);
+
+    let resp = app
+        .oneshot(
+            Request::builder()
+                .uri("/metrics")
+                .header("host", "127.0.0.1:8080")
+                .body(Body::empty())
+                .unwrap(),
+        )
+        .await
+        .expect("oneshot");
+    assert_eq!(resp.status(), StatusCode::OK);
+    assert_eq!(
+        resp.headers()[axum::http::header::CONTENT_TYPE],
+        crate::metrics::CONTENT_TYPE
+    );
+    let bytes = axum::body::to_bytes(resp.into_body(), 1 << 20)
+        .await
+        .unwrap();
+    let text = String::from_utf8(bytes.to_vec()).unwrap();
+    assert!(text.contains("# TYPE cqs_query_duration_seconds histogram"));
+    assert!(text.contains("# TYPE cqs_serve_rate_limit_throttled_total counter"));
+    let http_count: u64 = text
+        .lines()
+        .find_map(|l| l.strip_prefix("cqs_query_duration_seconds_count{surface=\"http\"} "))
+        .expect("http query count line")
+        .parse()
+        .unwrap();
+    assert!(http_count >= 1);
+}
+
 #[tokio::test(flavor = "multi_thread")]
 async fn graph_returns_empty_for_fresh_store() {
     // Fresh store has no chunks → /api/graph returns the shape but with
@@ -1875,6 +1917,115 @@ async fn search_legs_envelope_error_surfaces_as_500() {
     let _ = handle.join();
 }
 
+// ===== /api/search/stream (server-sent events) =====
+//
+// Same daemon-forwarding shape as `/api/search_legs`: bad input and a missing
+// daemon are ordinary 4xx/5xx responses before the stream opens; once open,
+// each stage is one SSE event.
+
+#[tokio::test(flavor = "multi_thread")]
+async fn search_stream_rejects_empty_query() {
+    let fixture = fixture_state();
+    let app = test_router(fixture.state());
+
+    let resp = app
+        .oneshot(
+            Request::builder()
+                .uri("/api/search/stream?q=+")
+                .header("host", "127.0.0.1:8080")
+                .body(Body::empty())
+                .unwrap(),
+        )
+        .await
+        .expect("oneshot");
+
+    assert_eq!(resp.status(), StatusCode::BAD_REQUEST);
+}
+
+#[tokio::test(flavor = "multi_thread")]
+async fn search_stream_503_when_no_daemon_socket() {
+    let fixture = fixture_state(); // daemon_socket: None
+    let app = test_router(fixture.state());
+
+    let resp = app
+        .oneshot(
+            Request::builder()
+                .uri("/api/search/stream?q=parse+config")
+                .header("host", "127.0.0.1:8080")
+                .body(Body::empty())
+                .unwrap(),
+        )
+        .await
+        .expect("oneshot");
+
+    assert_eq!(resp.status(), StatusCode::SERVICE_UNAVAILABLE);
+    let bytes = axum::body::to_bytes(resp.into_body(), 4096).await.unwrap();
+    let body = std::str::from_utf8(&bytes).expect("utf8");
+    assert!(body.contains("cqs watch --serve"), "got {body}");
+}
+
+#[cfg(unix)]
+#[tokio::test(flavor = "multi_thread")]
+async fn search_stream_emits_results_then_done() {
+    let dir = TempDir::new().expect("tempdir");
+    let socket = dir.path().join("daemon.sock");
+
+    let payload = serde_json::json!({
+        "query": "parse config",
+        "results": [{"name": "parse_config", "file": "src/lib.rs", "line_start": 1, "score": 0.8}],
+        "total": 1
+    });
+    let envelope = serde_json::json!({"data": payload, "error": null, "version": 1});
+    let handle = spawn_fake_daemon(
+        socket.clone(),
+        serde_json::json!({"status": "ok", "output": envelope}),
+    );
+    std::thread::sleep(std::time::Duration::from_millis(50));
+
+    let fixture = fixture_state_with_socket(socket);
+    let app = test_router(fixture.state());
+
+    let resp = app
+        .oneshot(
+            Request::builder()
+                .uri("/api/search/stream?q=--parse+config&limit=3")
+                .header("host", "127.0.0.1:8080")
+                .body(Body::empty())
+                .unwrap(),
+        )
+        .await
+        .expect("oneshot");
+
+    assert_eq!(resp.status(), StatusCode::OK);
+    assert_eq!(
+        resp.headers()
+            .get(axum::http::header::CONTENT_TYPE)
+            .and_then(|v| v.to_str().ok()),
+        Some("text/event-stream")
+    );
+    let bytes = axum::body::to_bytes(resp.into_body(), 65536).await.unwrap();
+    let body = std::str::from_utf8(&bytes).expect("utf8");
+    let results_at = body.find("event: results").expect("results event");
+    let done_at = body.find("event: done").expect("done event");
+    assert!(results_at < done_at, "results must precede done: {body}");
+    assert!(
+        !body.contains("event: candidates"),
+        "no rerank, no candidates"
+    );
+    assert!(body.contains("parse_config"), "payload forwarded: {body}");
+
+    // A leading `--` in the query stays a positional behind the terminator.
+    let request = handle.join().expect("fake daemon join");
+    assert_eq!(request["command"], "search");
+    let args: Vec<&str> = request["args"]
+        .as_array()
+        .expect("args array")
+        .iter()
+        .map(|v| v.as_str().unwrap())
+        .collect();
+    assert_eq!(args, ["--limit", "3", "--", "--parse config"]);
+}
+
 // ===== per-launch auth token integration tests =====
 //
 // Pins the auth middleware behavior end-to-end through the same
@@ -2526,6 +2677,332 @@ mod auth_tests {
         );
         drop(fixture);
     }
+
+    // ----- named, scoped tokens -----
+
+    /// A router accepting the launch token "launchvalue" plus a `read`-scope
+    /// token and an `admin`-scope token from a registry. Returns the router
+    /// and the two minted tokens.
+    fn scoped_router(
+        state: AppState,
+    ) -> (
+        axum::Router,
+        super::super::AuthToken,
+        super::super::AuthToken,
+    ) {
+        let mut store = super::super::TokenStore::default();
+        let reader = store
+            .create("reader", super::super::Scope::Read, 0)
+            .expect("create reader");
+        let admin = store
+            .create("ops", super::super::Scope::Admin, 0)
+            .expect("create ops");
+        let launch = super::super::AuthToken::try_from_string("launchvalue").unwrap();
+        let app = build_router(
+            state,
+            test_allowed_hosts(),
+            AuthMode::required(launch, 8080).with_tokens(&store),
+        );
+        (app, reader, admin)
+    }
+
+    fn bearer_request(method: &str, uri: &str, token: &str) -> Request<Body> {
+        Request::builder()
+            .method(method)
+            .uri(uri)
+            .header("host", "127.0.0.1:8080")
+            .header(header::AUTHORIZATION, format!("Bearer {token}"))
+            .body(Body::empty())
+            .unwrap()
+    }
+
+    #[tokio::test(flavor = "multi_thread")]
+    async fn read_token_reads_but_cannot_reach_admin_routes() {
+        let fixture = fixture_state();
+        let (app, reader, _) = scoped_router(fixture.state());
+
+        let resp = app
+            .clone()
+            .oneshot(bearer_request("GET", "/api/stats", reader.as_str()))
+            .await
+            .expect("oneshot");
+        assert_eq!(resp.status(), StatusCode::OK);
+
+        let resp = app
+            .oneshot(bearer_request(
+                "POST",
+                "/api/admin/reindex",
+                reader.as_str(),
+            ))
+            .await
+            .expect("oneshot");
+        assert_eq!(resp.status(), StatusCode::FORBIDDEN);
+        let bytes = axum::body::to_bytes(resp.into_body(), 4096).await.unwrap();
+        let body: serde_json::Value = serde_json::from_slice(&bytes).unwrap();
+        assert_eq!(body["error"], "forbidden");
+    }
+
+    #[tokio::test(flavor = "multi_thread")]
+    async fn admin_and_launch_tokens_pass_the_scope_check() {
+        // No daemon socket in the fixture, so a request that clears auth and
+        // scope lands on the handler's 503 — distinct from 401/403.
+        let fixture = fixture_state();
+        let (app, _, admin) = scoped_router(fixture.state());
+        for token in [admin.as_str(), "launchvalue"] {
+            let resp = app
+                .clone()
+                .oneshot(bearer_request("POST", "/api/admin/reindex", token))
+                .await
+                .expect("oneshot");
+            assert_eq!(resp.status(), StatusCode::SERVICE_UNAVAILABLE);
+        }
+    }
+
+    #[tokio::test(flavor = "multi_thread")]
+    async fn revoked_or_unknown_named_token_is_401() {
+        let fixture = fixture_state();
+        let (app, _, _) = scoped_router(fixture.state());
+        let stray = super::super::AuthToken::random();
+        let resp = app
+            .oneshot(bearer_request("GET", "/api/stats", stray.as_str()))
+            .await
+            .expect("oneshot");
+        assert_eq!(resp.status(), StatusCode::UNAUTHORIZED);
+    }
+
+    /// Each token drains its own bucket: once `reader` is out, it gets 429
+    /// with `Retry-After` while `ops` still passes; `/health` stays exempt and
+    /// `/api/metrics` names the throttled token.
+    #[tokio::test(flavor = "multi_thread")]
+    async fn rate_limit_is_per_token_and_surfaces_in_metrics() {
+        let fixture = fixture_state();
+        let mut state = fixture.state();
+        state.rate_limiter = Arc::new(super::super::ratelimit::RateLimiter::new(0.001, 2));
+        let (app, reader, admin) = scoped_router(state);
+
+        for _ in 0..2 {
+            let resp = app
+                .clone()
+                .oneshot(bearer_request("GET", "/api/stats", reader.as_str()))
+                .await
+                .expect("oneshot");
+            assert_eq!(resp.status(), StatusCode::OK);
+        }
+        let resp = app
+            .clone()
+            .oneshot(bearer_request("GET", "/api/stats", reader.as_str()))
+            .await
+            .expect("oneshot");
+        assert_eq!(resp.status(), StatusCode::TOO_MANY_REQUESTS);
+        let retry_after: u64 = resp.headers()[header::RETRY_AFTER]
+            .to_str()
+            .unwrap()
+            .parse()
+            .unwrap();
+        assert!(retry_after >= 1);
+
+        let resp = app
+            .clone()
+            .oneshot(bearer_request("GET", "/health", reader.as_str()))
+            .await
+            .expect("oneshot");
+        assert_eq!(resp.status(), StatusCode::OK);
+
+        let resp = app
+            .oneshot(bearer_request("GET", "/api/metrics", admin.as_str()))
+            .await
+            .expect("oneshot");
+        assert_eq!(resp.status(), StatusCode::OK);
+        let bytes = axum::body::to_bytes(resp.into_body(), 1 << 16)
+            .await
+            .unwrap();
+        let body: serde_json::Value = serde_json::from_slice(&bytes).unwrap();
+        let limits = &body["rate_limit"];
+        assert_eq!(limits["throttled"], 1);
+        assert_eq!(limits["allowed"], 3);
+        assert_eq!(limits["top_throttled"][0]["client"], "token:reader");
+    }
+}
+
+// ===== multi-project serving (`--projects`) =====
+//
+// Two populated fixtures of different sizes behind one dispatcher: the chunk
+// count in `/api/stats` tells which store answered.
+
+mod projects_tests {
+    use super::*;
+    use crate::serve::projects::build_projects_router;
+    use crate::serve::{Scope, TokenStore};
+    use axum::http::header;
+
+    fn request(uri: &str, token: Option<&str>, cookie: Option<&str>) -> Request<Body> {
+        let mut builder = Request::builder().uri(uri).header("host", "127.0.0.1:8080");
+        if let Some(token) = token {
+            builder = builder.header(header::AUTHORIZATION, format!("Bearer {token}"));
+        }
+        if let Some(cookie) = cookie {
+            builder = builder.header(header::COOKIE, cookie);
+        }
+        builder.body(Body::empty()).unwrap()
+    }
+
+    async fn send(app: &axum::Router, req: Request<Body>) -> axum::response::Response {
+        app.clone().oneshot(req).await.expect("oneshot")
+    }
+
+    async fn chunks_of(resp: axum::response::Response) -> u64 {
+        assert_eq!(resp.status(), StatusCode::OK);
+        let bytes = axum::body::to_bytes(resp.into_body(), 1 << 16)
+            .await
+            .unwrap();
+        let json: serde_json::Value = serde_json::from_slice(&bytes).unwrap();
+        json["total_chunks"].as_u64().expect("total_chunks")
+    }
+
+    /// Project names `/api/projects` returns for `token`.
+    async fn listed(app: &axum::Router, token: &str) -> Vec<String> {
+        let resp = send(app, request("/api/projects", Some(token), None)).await;
+        assert_eq!(resp.status(), StatusCode::OK);
+        let bytes = axum::body::to_bytes(resp.into_body(), 4096).await.unwrap();
+        let json: serde_json::Value = serde_json::from_slice(&bytes).unwrap();
+        json["projects"]
+            .as_array()
+            .unwrap()
+            .iter()
+            .map(|p| p["name"].as_str().unwrap().to_string())
+            .collect()
+    }
+
+    fn router(
+        api: &Fixture,
+        web: &Fixture,
+        api_tokens: TokenStore,
+        auth: AuthMode,
+    ) -> axum::Router {
+        build_projects_router(
+            vec![
+                ("api".to_string(), api.state(), api_tokens),
+                ("web".to_string(), web.state(), TokenStore::default()),
+            ],
+            test_allowed_hosts(),
+            auth,
+            8080,
+        )
+    }
+
+    #[tokio::test(flavor = "multi_thread")]
+    async fn prefix_and_cookie_route_to_the_right_store() {
+        let (api, web) = (populated_fixture(3, false), populated_fixture(5, false));
+        let app = router(
+            &api,
+            &web,
+            TokenStore::default(),
+            AuthMode::disabled(NoAuthAcknowledgement::for_test()),
+        );
+
+        let resp = send(&app, request("/p/web/api/stats", None, None)).await;
+        let cookie = resp.headers()[header::SET_COOKIE].to_str().unwrap();
+        assert!(cookie.starts_with("cqs_project_8080=web;"), "{cookie}");
+        assert_eq!(chunks_of(resp).await, 5);
+        let resp = send(&app, request("/p/api/api/stats", None, None)).await;
+        assert_eq!(chunks_of(resp).await, 3);
+
+        // Unprefixed requests follow the cookie the page set.
+        let resp = send(
+            &app,
+            request("/api/stats", None, Some("cqs_project_8080=web")),
+        )
+        .await;
+        assert_eq!(chunks_of(resp).await, 5);
+
+        for uri in ["/api/stats", "/p/nope/api/stats"] {
+            let resp = send(&app, request(uri, None, None)).await;
+            assert_eq!(resp.status(), StatusCode::NOT_FOUND, "{uri}");
+        }
+    }
+
+    #[tokio::test(flavor = "multi_thread")]
+    async fn named_tokens_open_only_their_project() {
+        let (api, web) = (populated_fixture(3, false), populated_fixture(5, false));
+        let mut tokens = TokenStore::default();
+        let reader = tokens.create("ci", Scope::Read, 0).expect("create");
+        let launch = crate::serve::AuthToken::try_from_string("launchvalue").unwrap();
+        let app = router(&api, &web, tokens, AuthMode::required(launch, 8080));
+
+        // The token picks its project without a prefix, and nowhere else.
+        let resp = send(&app, request("/api/stats", Some(reader.as_str()), None)).await;
+        assert_eq!(chunks_of(resp).await, 3);
+        let resp = send(
+            &app,
+            request("/p/web/api/stats", Some(reader.as_str()), None),
+        )
+        .await;
+        assert_eq!(resp.status(), StatusCode::UNAUTHORIZED);
+        assert!(resp.headers().get(header::SET_COOKIE).is_none());
+
+        let resp = send(&app, request("/p/web/api/stats", Some("launchvalue"), None)).await;
+        assert_eq!(chunks_of(resp).await, 5);
+
+        assert_eq!(listed(&app, "launchvalue").await, ["api", "web"]);
+        assert_eq!(listed(&app, reader.as_str()).await, ["api"]);
+
+        // Without credentials nothing is listed or confirmed to exist.
+        for uri in ["/api/projects", "/p/nope/api/stats", "/api/stats"] {
+            let resp = send(&app, request(uri, None, None)).await;
+            assert_eq!(resp.status(), StatusCode::UNAUTHORIZED, "{uri}");
+        }
+    }
+
+    #[tokio::test(flavor = "multi_thread")]
+    async fn token_handoff_redirect_stays_inside_the_project() {
+        let (api, web) = (fixture_state(), fixture_state());
+        let launch = crate::serve::AuthToken::try_from_string("launchvalue").unwrap();
+        let app = router(
+            &api,
+            &web,
+            TokenStore::default(),
+            AuthMode::required(launch, 8080),
+        );
+
+        let resp = send(&app, request("/p/web/?token=launchvalue", None, None)).await;
+        assert!(resp.status().is_redirection());
+        assert_eq!(resp.headers()[header::LOCATION], "/p/web/");
+        let cookies: Vec<&str> = resp
+            .headers()
+            .get_all(header::SET_COOKIE)
+            .iter()
+            .map(|v| v.to_str().unwrap())
+            .collect();
+        assert!(cookies.iter().any(|c| c.starts_with("cqs_token_8080=")));
+        assert!(cookies
+            .iter()
+            .any(|c| c.starts_with("cqs_project_8080=web;")));
+    }
+
+    /// One project's exhausted rate limit leaves the other untouched.
+    #[tokio::test(flavor = "multi_thread")]
+    async fn quotas_are_per_project() {
+        let (api, web) = (fixture_state(), fixture_state());
+        let mut api_state = api.state();
+        api_state.rate_limiter = Arc::new(super::super::ratelimit::RateLimiter::new(0.001, 1));
+        let app = build_projects_router(
+            vec![
+                ("api".to_string(), api_state, TokenStore::default()),
+                ("web".to_string(), web.state(), TokenStore::default()),
+            ],
+            test_allowed_hosts(),
+            AuthMode::disabled(NoAuthAcknowledgement::for_test()),
+            8080,
+        );
+        let api_stats = || request("/p/api/api/stats", None, None);
+        assert_eq!(send(&app, api_stats()).await.status(), StatusCode::OK);
+        assert_eq!(
+            send(&app, api_stats()).await.status(),
+            StatusCode::TOO_MANY_REQUESTS
+        );
+        let resp = send(&app, request("/p/web/api/stats", None, None)).await;
+        assert_eq!(resp.status(), StatusCode::OK);
+    }
 }
 
 // ===== loopback URL mapping for `--open` / the no-auth banner =====
@@ -2670,6 +3147,7 @@ fn eval_gold_fixture(chunks: &[(&str, &str)], eval_json: &str) -> (Fixture, Temp
             last_request_epoch: Arc::new(std::sync::atomic::AtomicU64::new(0)),
             daemon_socket: None,
             eval_root: Some(Arc::new(eval_dir.path().to_path_buf())),
+            rate_limiter: Arc::new(super::ratelimit::RateLimiter::from_env()),
         }),
         _dir: Some(store_dir),
     };
@@ -2801,6 +3279,7 @@ async fn eval_gold_503_when_fixtures_absent() {
             last_request_epoch: Arc::new(std::sync::atomic::AtomicU64::new(0)),
             daemon_socket: None,
             eval_root: Some(Arc::new(empty_root.path().to_path_buf())),
+            rate_limiter: Arc::new(super::ratelimit::RateLimiter::from_env()),
         }),
         _dir: Some(store_dir),
     };
@@ -2808,3 +3287,139 @@ async fn eval_gold_503_when_fixtures_absent() {
     let (status, _json) = get_json(app, "/api/eval_gold").await;
     assert_eq!(status, StatusCode::SERVICE_UNAVAILABLE);
 }
+
+// ─── gRPC (`cqs serve --grpc`) ──────────────────────────────────────────────
+//
+// Drives `CqsService` directly through the generated trait rather than over a
+// socket: the transport is tonic's, the behavior under test is that each call
+// lands on the shared HTTP handlers and maps errors to the right status.
+
+#[cfg(feature = "grpc")]
+mod grpc_tests {
+    use super::*;
+    use crate::serve::grpc::proto::{self, cqs_server::Cqs};
+    use crate::serve::grpc::CqsService;
+    use tonic::service::Interceptor;
+
+    #[tokio::test(flavor = "multi_thread")]
+    async fn search_and_stream_agree_with_http_search() {
+        let fixture = populated_fixture(12, false);
+        let svc = CqsService::new(fixture.state());
+        let request = || {
+            tonic::Request::new(proto::SearchRequest {
+                query: "func_000".to_string(),
+                limit: 5,
+            })
+        };
+        let unary = svc.search(request()).await.unwrap().into_inner().matches;
+        assert!(!unary.is_empty());
+        assert!(unary.len() <= 5);
+
+        let http =
+            crate::serve::handlers::search_names(&fixture.state(), "func_000".to_string(), 5)
+                .await
+                .unwrap();
+        let http_ids: Vec<String> = http.matches.into_iter().map(|m| m.id).collect();
+        let unary_ids: Vec<String> = unary.iter().map(|m| m.id.clone()).collect();
+        assert_eq!(unary_ids, http_ids);
+
+        use tokio_stream::StreamExt;
+        let streamed: Vec<proto::ChunkRef> = svc
+            .search_stream(request())
+            .await
+            .unwrap()
+            .into_inner()
+            .map(|r| r.unwrap())
+            .collect()
+            .await;
+        assert_eq!(streamed, unary);
+    }
+
+    #[tokio::test(flavor = "multi_thread")]
+    async fn get_chunk_and_callers_map_missing_ids_to_not_found() {
+        let fixture = fixture_state();
+        let svc = CqsService::new(fixture.state());
+        let err = svc
+            .get_chunk(tonic::Request::new(proto::GetChunkRequest {
+                id: "nope".to_string(),
+            }))
+            .await
+            .unwrap_err();
+        assert_eq!(err.code(), tonic::Code::NotFound);
+        let err = svc
+            .callers(tonic::Request::new(proto::CallersRequest {
+                id: String::new(),
+            }))
+            .await
+            .unwrap_err();
+        assert_eq!(err.code(), tonic::Code::InvalidArgument);
+    }
+
+    #[tokio::test(flavor = "multi_thread")]
+    async fn get_chunk_returns_detail() {
+        let (fixture, id) = single_chunk_fixture("fn lone() {}", false);
+        let svc = CqsService::new(fixture.state());
+        let chunk = svc
+            .get_chunk(tonic::Request::new(proto::GetChunkRequest {
+                id: id.clone(),
+            }))
+            .await
+            .unwrap()
+            .into_inner();
+        assert_eq!(chunk.id, id);
+        assert!(chunk.trust_level.is_none());
+    }
+
+    #[tokio::test(flavor = "multi_thread")]
+    async fn index_status_reports_totals() {
+        let fixture = populated_fixture(4, false);
+        let svc = CqsService::new(fixture.state());
+        let status = svc
+            .index_status(tonic::Request::new(proto::IndexStatusRequest {}))
+            .await
+            .unwrap()
+            .into_inner();
+        assert_eq!(status.total_chunks, 4);
+    }
+
+    #[test]
+    fn interceptor_requires_the_bearer_token() {
+        let fixture = fixture_state();
+        let token = crate::serve::AuthToken::random();
+        let mut auth = crate::serve::grpc::GrpcAuth::for_test(
+            Some(token.clone()),
+            Arc::clone(&fixture.state().last_request_epoch),
+        );
+
+        let err = auth.call(tonic::Request::new(())).unwrap_err();
+        assert_eq!(err.code(), tonic::Code::Unauthenticated);
+
+        let mut wrong = tonic::Request::new(());
+        wrong
+            .metadata_mut()
+            .insert("authorization", "Bearer not-the-token".parse().unwrap());
+        assert_eq!(
+            auth.call(wrong).unwrap_err().code(),
+            tonic::Code::Unauthenticated
+        );
+
+        let mut right = tonic::Request::new(());
+        right.metadata_mut().insert(
+            "authorization",
+            format!("Bearer {}", token.as_str()).parse().unwrap(),
+        );
+        assert!(auth.call(right).is_ok());
+        assert!(
+            fixture
+                .state()
+                .last_request_epoch
+                .load(std::sync::atomic::Ordering::Relaxed)
+                > 0,
+            "interceptor touches the idle clock"
+        );
+
+        let mut open =
+            crate::serve::grpc::GrpcAuth::for_test(None, fixture.state().last_request_epoch);
+        assert!(open.call(tonic::Request::new(())).is_ok());
+    }
+}
@@ -0,0 +1,323 @@
+//! Named, scoped API tokens for a shared `cqs serve`.
+//!
+//! The per-launch token ([`super::AuthToken::random`]) suits one person on
+//! one machine: it rotates on every start and is only printed to a terminal.
+//! A team server needs credentials that survive restarts, can be handed out
+//! one per person or script, and can be withdrawn individually. Those live
+//! in `.cqs/serve_tokens.json`, managed by `cqs serve token
+//! create|list|revoke`.
+//!
+//! Only a blake3 hash of each token is stored; the token itself is shown
+//! once, at creation. Each token carries a [`Scope`]: `read` covers every
+//! read-only route, `admin` additionally covers the `/api/admin/*` routes
+//! (index trigger). The per-launch token is always `admin`.
+//!
+//! The file is read once at `cqs serve` start — restart the server to pick
+//! up a new or revoked token.
+
+use std::path::{Path, PathBuf};
+use std::sync::Arc;
+
+use serde::{Deserialize, Serialize};
+use thiserror::Error;
+
+use super::auth::AuthToken;
+
+/// Token registry inside the project `.cqs/` directory.
+pub const TOKENS_FILENAME: &str = "serve_tokens.json";
+
+/// Name the per-launch token authenticates as. Reserved: no named token may
+/// use it, so the access log can't confuse the two.
+pub const LAUNCH_TOKEN_NAME: &str = "launch";
+
+const MAX_TOKEN_NAME_LEN: usize = 64;
+
+#[derive(Debug, Error)]
+pub enum TokenStoreError {
+    #[error("Token file I/O error on {path}: {source}")]
+    Io {
+        path: PathBuf,
+        #[source]
+        source: std::io::Error,
+    },
+    #[error("Malformed token file {path}: {source}")]
+    Json {
+        path: PathBuf,
+        #[source]
+        source: serde_json::Error,
+    },
+    #[error("Invalid token name '{0}': use letters, digits, '_', '-' or '.' (max 64, not starting with '.' or '-'; 'launch' is reserved)")]
+    InvalidName(String),
+    #[error("No token named '{0}'")]
+    NotFound(String),
+    #[error("Token '{0}' already exists")]
+    Exists(String),
+}
+
+/// What a token may do. Ordered: a scope allows everything the scopes below
+/// it allow.
+#[derive(
+    Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Serialize, Deserialize, clap::ValueEnum,
+)]
+#[serde(rename_all = "lowercase")]
+pub enum Scope {
+    /// Every read-only route (graph, chunk, search, stats, ...).
+    Read,
+    /// Read, plus the `/api/admin/*` routes.
+    Admin,
+}
+
+impl Scope {
+    /// `true` if a holder of `self` may call a route that needs `needed`.
+    pub fn allows(self, needed: Scope) -> bool {
+        self >= needed
+    }
+
+    pub fn as_str(self) -> &'static str {
+        match self {
+            Scope::Read => "read",
+            Scope::Admin => "admin",
+        }
+    }
+}
+
+impl std::fmt::Display for Scope {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        f.write_str(self.as_str())
+    }
+}
+
+/// One named token as stored: never the token itself.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct TokenRecord {
+    pub name: String,
+    pub scope: Scope,
+    /// Unix seconds at creation
+    pub created_at: i64,
+    /// Hex blake3 of the token string
+    hash: String,
+}
+
+/// The token registry of one project.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct TokenStore {
+    #[serde(default)]
+    tokens: Vec<TokenRecord>,
+}
+
+impl TokenStore {
+    /// Read the registry; an absent file is an empty registry.
+    pub fn load(path: &Path) -> Result<Self, TokenStoreError> {
+        let bytes = match std::fs::read(path) {
+            Ok(b) => b,
+            Err(e) if e.kind() == std::io::ErrorKind::NotFound => return Ok(Self::default()),
+            Err(source) => {
+                return Err(TokenStoreError::Io {
+                    path: path.to_path_buf(),
+                    source,
+                })
+            }
+        };
+        serde_json::from_slice(&bytes).map_err(|source| TokenStoreError::Json {
+            path: path.to_path_buf(),
+            source,
+        })
+    }
+
+    /// Write the registry to `path` atomically, owner-only on unix.
+    pub fn save(&self, path: &Path) -> Result<(), TokenStoreError> {
+        let io_err = |source| TokenStoreError::Io {
+            path: path.to_path_buf(),
+            source,
+        };
+        if let Some(parent) = path.parent() {
+            std::fs::create_dir_all(parent).map_err(io_err)?;
+        }
+        let json = serde_json::to_vec_pretty(self).map_err(|source| TokenStoreError::Json {
+            path: path.to_path_buf(),
+            source,
+        })?;
+        let tmp = path.with_extension(format!("json.{}.tmp", std::process::id()));
+        std::fs::write(&tmp, json).map_err(io_err)?;
+        // Hashes only, but the file still says who has access at which
+        // scope — keep it out of other users' reach.
+        #[cfg(unix)]
+        {
+            use std::os::unix::fs::PermissionsExt;
+            if let Err(e) = std::fs::set_permissions(&tmp, std::fs::Permissions::from_mode(0o600)) {
+                tracing::warn!(path = %tmp.display(), error = %e, "Failed to restrict token file permissions");
+            }
+        }
+        crate::fs::atomic_replace(&tmp, path).map_err(|e| {
+            let _ = std::fs::remove_file(&tmp);
+            io_err(e)
+        })
+    }
+
+    /// Stored tokens, in creation order.
+    pub fn records(&self) -> &[TokenRecord] {
+        &self.tokens
+    }
+
+    /// Mint a token called `name` with `scope`. Returns the token — the only
+    /// time it is available; the registry keeps its hash.
+    pub fn create(
+        &mut self,
+        name: &str,
+        scope: Scope,
+        now: i64,
+    ) -> Result<AuthToken, TokenStoreError> {
+        validate_token_name(name)?;
+        if self.tokens.iter().any(|t| t.name == name) {
+            return Err(TokenStoreError::Exists(name.to_string()));
+        }
+        let token = AuthToken::random();
+        self.tokens.push(TokenRecord {
+            name: name.to_string(),
+            scope,
+            created_at: now,
+            hash: hash_hex(token.as_str()),
+        });
+        Ok(token)
+    }
+
+    /// Remove the token called `name` and return its record.
+    pub fn revoke(&mut self, name: &str) -> Result<TokenRecord, TokenStoreError> {
+        let idx = self
+            .tokens
+            .iter()
+            .position(|t| t.name == name)
+            .ok_or_else(|| TokenStoreError::NotFound(name.to_string()))?;
+        Ok(self.tokens.remove(idx))
+    }
+
+    /// The verifier-side view of the registry. Records whose hash doesn't
+    /// decode (hand-edited file) are skipped with a warning rather than
+    /// failing the server start.
+    pub(crate) fn credentials(&self) -> Vec<NamedCredential> {
+        self.tokens
+            .iter()
+            .filter_map(|t| match decode_hash(&t.hash) {
+                Some(hash) => Some(NamedCredential {
+                    name: Arc::from(t.name.as_str()),
+                    scope: t.scope,
+                    hash,
+                }),
+                None => {
+                    tracing::warn!(name = %t.name, "serve: skipping token with malformed hash");
+                    None
+                }
+            })
+            .collect()
+    }
+}
+
+/// A named token as the auth middleware sees it. Public only because
+/// [`super::AuthMode::Required`] carries it; the fields stay crate-private.
+#[derive(Debug, Clone)]
+pub struct NamedCredential {
+    pub(crate) name: Arc<str>,
+    pub(crate) scope: Scope,
+    pub(crate) hash: [u8; 32],
+}
+
+/// Path of the token registry under the project `.cqs/` dir.
+pub fn tokens_path(project_cqs_dir: &Path) -> PathBuf {
+    project_cqs_dir.join(TOKENS_FILENAME)
+}
+
+/// Check a token name: ASCII letters, digits, `_`, `-`, `.`; no leading `.`
+/// or `-`; at most 64 characters; not [`LAUNCH_TOKEN_NAME`].
+pub fn validate_token_name(name: &str) -> Result<(), TokenStoreError> {
+    let valid = !name.is_empty()
+        && name.len() <= MAX_TOKEN_NAME_LEN
+        && name != LAUNCH_TOKEN_NAME
+        && !name.starts_with('.')
+        && !name.starts_with('-')
+        && name
+            .bytes()
+            .all(|b| b.is_ascii_alphanumeric() || matches!(b, b'_' | b'-' | b'.'));
+    if valid {
+        Ok(())
+    } else {
+        Err(TokenStoreError::InvalidName(name.to_string()))
+    }
+}
+
+/// blake3 of a presented token, compared against [`NamedCredential::hash`].
+pub(crate) fn hash_token(token: &str) -> [u8; 32] {
+    *blake3::hash(token.as_bytes()).as_bytes()
+}
+
+fn hash_hex(token: &str) -> String {
+    blake3::hash(token.as_bytes()).to_hex().to_string()
+}
+
+fn decode_hash(hex: &str) -> Option<[u8; 32]> {
+    blake3::Hash::from_hex(hex).ok().map(|h| *h.as_bytes())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn create_stores_hash_not_token() {
+        let mut store = TokenStore::default();
+        let token = store.create("ci", Scope::Read, 100).unwrap();
+        let json = serde_json::to_string(&store).unwrap();
+        assert!(!json.contains(token.as_str()), "token must not be stored");
+        let creds = store.credentials();
+        assert_eq!(creds.len(), 1);
+        assert_eq!(creds[0].hash, hash_token(token.as_str()));
+        assert_eq!(creds[0].scope, Scope::Read);
+    }
+
+    #[test]
+    fn names_are_unique_validated_and_launch_is_reserved() {
+        let mut store = TokenStore::default();
+        store.create("alice", Scope::Admin, 0).unwrap();
+        assert!(matches!(
+            store.create("alice", Scope::Read, 0),
+            Err(TokenStoreError::Exists(_))
+        ));
+        for bad in ["", "launch", "-x", ".x", "a b", "a/b"] {
+            assert!(
+                matches!(
+                    store.create(bad, Scope::Read, 0),
+                    Err(TokenStoreError::InvalidName(_))
+                ),
+                "accepted {bad:?}"
+            );
+        }
+    }
+
+    #[test]
+    fn revoke_removes_and_round_trips_through_file() {
+        let dir = tempfile::TempDir::new().unwrap();
+        let path = tokens_path(dir.path());
+        assert!(TokenStore::load(&path).unwrap().records().is_empty());
+
+        let mut store = TokenStore::default();
+        store.create("alice", Scope::Admin, 1).unwrap();
+        store.create("bot", Scope::Read, 2).unwrap();
+        store.save(&path).unwrap();
+
+        let mut loaded = TokenStore::load(&path).unwrap();
+        assert_eq!(loaded, store);
+        assert_eq!(loaded.revoke("alice").unwrap().scope, Scope::Admin);
+        assert!(matches!(
+            loaded.revoke("alice"),
+            Err(TokenStoreError::NotFound(_))
+        ));
+        assert_eq!(loaded.records().len(), 1);
+    }
+
+    #[test]
+    fn admin_scope_covers_read() {
+        assert!(Scope::Admin.allows(Scope::Read));
+        assert!(Scope::Admin.allows(Scope::Admin));
+        assert!(Scope::Read.allows(Scope::Read));
+        assert!(!Scope::Read.allows(Scope::Admin));
+    }
+}
@@ -0,0 +1,362 @@
+//! Search sessions — the queries, filters and pinned chunks of an
+//! investigation that spans more than one sitting.
+//!
+//! Every search (CLI, daemon, `cqs chat`) appends its query and filter flags
+//! to the *working session*, `.cqs/session.json`, keeping the
+//! [`MAX_SESSION_QUERIES`] most recent distinct ones. `cqs session pin`
+//! adds chunks to it. `cqs session save <name>` copies it to
+//! `.cqs/sessions/<name>.json`; `cqs session open <name>` copies a saved
+//! session back over the working one. Sessions are project-scoped (they sit
+//! in the project `.cqs/`, not a slot dir).
+//!
+//! Filters are kept as the `search` flags that reproduce them (`--lang rust`,
+//! `--path 'src/**'`, …), so a query replays through the same parser as
+//! `cqs chat` / `cqs batch`.
+//!
+//! The working session also keeps the results of the latest search, in
+//! rank order, so `cqs open <N>` can jump to the Nth of them.
+//!
+//! Recording is best-effort and `CQS_SESSION_TRACKING=0` turns it off. Two
+//! processes recording at the same instant can drop one of the two queries;
+//! pins and saves are explicit and not affected.
+
+use std::path::{Path, PathBuf};
+use std::sync::Mutex;
+
+use serde::{Deserialize, Serialize};
+use thiserror::Error;
+
+/// Working session file inside the project `.cqs/` directory.
+pub const WORKING_SESSION_FILENAME: &str = "session.json";
+
+/// Directory of saved sessions inside the project `.cqs/` directory.
+pub const SESSIONS_DIR: &str = "sessions";
+
+/// Most recent distinct queries a session keeps.
+pub const MAX_SESSION_QUERIES: usize = 50;
+
+const MAX_SESSION_NAME_LEN: usize = 64;
+
+#[derive(Debug, Error)]
+pub enum SessionError {
+    #[error("Session I/O error on {path}: {source}")]
+    Io {
+        path: PathBuf,
+        #[source]
+        source: std::io::Error,
+    },
+    #[error("Malformed session file {path}: {source}")]
+    Json {
+        path: PathBuf,
+        #[source]
+        source: serde_json::Error,
+    },
+    #[error("Invalid session name '{0}': use letters, digits, '_', '-' or '.' (max 64, not starting with '.' or '-')")]
+    InvalidName(String),
+    #[error("No session named '{0}'")]
+    NotFound(String),
+    #[error("Session '{0}' already exists")]
+    Exists(String),
+}
+
+/// One query of a session.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct SessionQuery {
+    pub query: String,
+    /// `search` flags that reproduce the query's filters
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub args: Vec<String>,
+    /// Unix seconds of the latest run
+    pub last_run: i64,
+}
+
+/// A chunk pinned to a session. Kept by origin and name, which survive edits
+/// and reindexes; `id` is the chunk id at pin time.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct PinnedChunk {
+    pub origin: String,
+    pub name: String,
+    pub id: String,
+    pub pinned_at: i64,
+}
+
+impl PinnedChunk {
+    /// `file:name` target that `resolve_target` accepts.
+    pub fn target(&self) -> String {
+        format!("{}:{}", self.origin, self.name)
+    }
+}
+
+/// One result of the latest search, as `cqs open` needs it.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct SessionResult {
+    /// Forward-slash path, relative to the project root
+    pub file: String,
+    pub line: u32,
+    pub name: String,
+}
+
+/// A working or saved session.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct Session {
+    /// Name it was saved or opened under; `None` for a fresh working session
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub name: Option<String>,
+    #[serde(default)]
+    pub updated_at: i64,
+    #[serde(default)]
+    pub queries: Vec<SessionQuery>,
+    #[serde(default)]
+    pub pinned: Vec<PinnedChunk>,
+    /// Results of the latest search, best first
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub last_results: Vec<SessionResult>,
+}
+
+impl Session {
+    /// Read a session file; `Ok(None)` when it does not exist.
+    pub fn load(path: &Path) -> Result<Option<Self>, SessionError> {
+        let bytes = match std::fs::read(path) {
+            Ok(b) => b,
+            Err(e) if e.kind() == std::io::ErrorKind::NotFound => return Ok(None),
+            Err(source) => {
+                return Err(SessionError::Io {
+                    path: path.to_path_buf(),
+                    source,
+                })
+            }
+        };
+        serde_json::from_slice(&bytes)
+            .map(Some)
+            .map_err(|source| SessionError::Json {
+                path: path.to_path_buf(),
+                source,
+            })
+    }
+
+    /// Write the session to `path` atomically, creating the parent directory.
+    pub fn save(&self, path: &Path) -> Result<(), SessionError> {
+        let io_err = |source| SessionError::Io {
+            path: path.to_path_buf(),
+            source,
+        };
+        if let Some(parent) = path.parent() {
+            std::fs::create_dir_all(parent).map_err(io_err)?;
+        }
+        let json = serde_json::to_vec_pretty(self).map_err(|source| SessionError::Json {
+            path: path.to_path_buf(),
+            source,
+        })?;
+        let tmp = path.with_extension(format!("json.{}.tmp", std::process::id()));
+        std::fs::write(&tmp, json).map_err(io_err)?;
+        crate::fs::atomic_replace(&tmp, path).map_err(|e| {
+            let _ = std::fs::remove_file(&tmp);
+            io_err(e)
+        })
+    }
+
+    /// Record a run of `query` with `args`. A repeat moves to the end with a
+    /// fresh `last_run`; past [`MAX_SESSION_QUERIES`] the oldest drop off.
+    pub fn push_query(&mut self, query: &str, args: Vec<String>, now: i64) {
+        self.queries
+            .retain(|q| !(q.query == query && q.args == args));
+        self.queries.push(SessionQuery {
+            query: query.to_string(),
+            args,
+            last_run: now,
+        });
+        let excess = self.queries.len().saturating_sub(MAX_SESSION_QUERIES);
+        self.queries.drain(..excess);
+        self.updated_at = now;
+    }
+
+    /// Pin a chunk. Returns `false` if `(origin, name)` was already pinned.
+    pub fn pin(&mut self, chunk: PinnedChunk) -> bool {
+        if self
+            .pinned
+            .iter()
+            .any(|p| p.origin == chunk.origin && p.name == chunk.name)
+        {
+            return false;
+        }
+        self.updated_at = chunk.pinned_at;
+        self.pinned.push(chunk);
+        true
+    }
+
+    /// Unpin by `(origin, name)`. Returns `false` if it was not pinned.
+    pub fn unpin(&mut self, origin: &str, name: &str, now: i64) -> bool {
+        let before = self.pinned.len();
+        self.pinned
+            .retain(|p| !(p.origin == origin && p.name == name));
+        let removed = self.pinned.len() != before;
+        if removed {
+            self.updated_at = now;
+        }
+        removed
+    }
+}
+
+/// Check a session name: ASCII letters, digits, `_`, `-`, `.`; no leading
+/// `.` or `-`; at most 64 characters.
+pub fn validate_session_name(name: &str) -> Result<(), SessionError> {
+    let valid = !name.is_empty()
+        && name.len() <= MAX_SESSION_NAME_LEN
+        && !name.starts_with('.')
+        && !name.starts_with('-')
+        && name
+            .bytes()
+            .all(|b| b.is_ascii_alphanumeric() || matches!(b, b'_' | b'-' | b'.'));
+    if valid {
+        Ok(())
+    } else {
+        Err(SessionError::InvalidName(name.to_string()))
+    }
+}
+
+/// Path of the working session under the project `.cqs/` dir.
+pub fn working_session_path(project_cqs_dir: &Path) -> PathBuf {
+    project_cqs_dir.join(WORKING_SESSION_FILENAME)
+}
+
+/// Path of the saved session `name`, after validating the name.
+pub fn session_path(project_cqs_dir: &Path, name: &str) -> Result<PathBuf, SessionError> {
+    validate_session_name(name)?;
+    Ok(project_cqs_dir
+        .join(SESSIONS_DIR)
+        .join(format!("{name}.json")))
+}
+
+/// Saved sessions, sorted by name. Unreadable files are skipped with a warning.
+pub fn list_sessions(project_cqs_dir: &Path) -> Result<Vec<(String, Session)>, SessionError> {
+    let dir = project_cqs_dir.join(SESSIONS_DIR);
+    let entries = match std::fs::read_dir(&dir) {
+        Ok(entries) => entries,
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => return Ok(Vec::new()),
+        Err(source) => return Err(SessionError::Io { path: dir, source }),
+    };
+    let mut sessions = Vec::new();
+    for entry in entries.flatten() {
+        let path = entry.path();
+        let Some(name) = path
+            .file_name()
+            .and_then(|n| n.to_str())
+            .and_then(|n| n.strip_suffix(".json"))
+        else {
+            continue;
+        };
+        if validate_session_name(name).is_err() {
+            continue;
+        }
+        match Session::load(&path) {
+            Ok(Some(session)) => sessions.push((name.to_string(), session)),
+            Ok(None) => {}
+            Err(e) => tracing::warn!(error = %e, "Skipping unreadable session"),
+        }
+    }
+    sessions.sort_by(|a, b| a.0.cmp(&b.0));
+    Ok(sessions)
+}
+
+/// Serializes working-session updates within one process (the daemon runs
+/// searches concurrently).
+static WORKING_LOCK: Mutex<()> = Mutex::new(());
+
+/// Load the working session, apply `update`, and write it back.
+pub fn update_working_session<T>(
+    project_cqs_dir: &Path,
+    update: impl FnOnce(&mut Session) -> T,
+) -> Result<T, SessionError> {
+    let _guard = WORKING_LOCK.lock().unwrap_or_else(|p| p.into_inner());
+    let path = working_session_path(project_cqs_dir);
+    let mut session = Session::load(&path)?.unwrap_or_default();
+    let out = update(&mut session);
+    session.save(&path)?;
+    Ok(out)
+}
+
+/// Best-effort: append a search and its results to the working session.
+/// Never fails — errors are logged at debug.
+pub fn record_query(
+    project_cqs_dir: &Path,
+    query: &str,
+    args: Vec<String>,
+    results: Vec<SessionResult>,
+) {
+    if query.trim().is_empty() || !crate::limits::session_tracking_enabled() {
+        return;
+    }
+    let now = crate::unix_secs_i64().unwrap_or(0);
+    if let Err(e) = update_working_session(project_cqs_dir, |s| {
+        s.push_query(query, args, now);
+        s.last_results = results;
+    }) {
+        tracing::debug!(error = %e, "Failed to record query in working session");
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn pinned(origin: &str, name: &str) -> PinnedChunk {
+        PinnedChunk {
+            origin: origin.to_string(),
+            name: name.to_string(),
+            id: format!("{origin}:1:abc"),
+            pinned_at: 1,
+        }
+    }
+
+    #[test]
+    fn push_query_dedupes_and_caps() {
+        let mut s = Session::default();
+        s.push_query("auth", vec!["--lang".into(), "rust".into()], 1);
+        s.push_query("auth", vec![], 2);
+        s.push_query("auth", vec!["--lang".into(), "rust".into()], 3);
+        assert_eq!(s.queries.len(), 2);
+        assert_eq!((s.queries[1].args.len(), s.queries[1].last_run), (2, 3));
+
+        for i in 0..MAX_SESSION_QUERIES + 5 {
+            s.push_query(&format!("q{i}"), vec![], 10 + i as i64);
+        }
+        assert_eq!(s.queries.len(), MAX_SESSION_QUERIES);
+        assert_eq!(s.queries[0].query, "q5");
+    }
+
+    #[test]
+    fn pin_save_load_roundtrip() {
+        let dir = tempfile::tempdir().unwrap();
+        let mut s = Session::default();
+        assert!(s.pin(pinned("src/auth.rs", "refresh")));
+        assert!(!s.pin(pinned("src/auth.rs", "refresh")));
+        assert!(s.pin(pinned("src/auth.rs", "revoke")));
+        assert!(s.unpin("src/auth.rs", "revoke", 2));
+        s.push_query("token refresh", vec![], 3);
+        s.last_results = vec![SessionResult {
+            file: "src/auth.rs".into(),
+            line: 42,
+            name: "refresh".into(),
+        }];
+        s.name = Some("auth-refactor".into());
+
+        let path = session_path(dir.path(), "auth-refactor").unwrap();
+        s.save(&path).unwrap();
+        assert_eq!(Session::load(&path).unwrap(), Some(s));
+        let listed = list_sessions(dir.path()).unwrap();
+        assert_eq!(listed.len(), 1);
+        assert_eq!(listed[0].0, "auth-refactor");
+        assert!(Session::load(&dir.path().join("missing.json"))
+            .unwrap()
+            .is_none());
+    }
+
+    #[test]
+    fn session_names_are_path_safe() {
+        assert!(validate_session_name("auth-refactor.v2").is_ok());
+        let long = "x".repeat(65);
+        for bad in ["", "../x", "a/b", ".hidden", "-flag", long.as_str()] {
+            assert!(validate_session_name(bad).is_err(), "{bad:?}");
+        }
+    }
+}
@@ -0,0 +1,423 @@
+//! Path-prefix sharding of the project index.
+//!
+//! A single `index.db` past a couple of million chunks runs into SQLite's
+//! practical limits: multi-GB WAL checkpoints, minutes-long `prune_missing`
+//! transactions, and one write lock serializing every service's reindex.
+//! `[index.shards]` in `.cqs.toml` splits the store by path prefix (see
+//! [`crate::config::ShardsConfig`]).
+//!
+//! Layout, under the active slot dir:
+//!
+//! ```text
+//! .cqs/slots/<slot>/index.db                 root shard (unmatched files)
+//! .cqs/slots/<slot>/shards/<name>/index.db   one store per prefix
+//! .cqs/slots/<slot>/shards/<name>/shard.toml records the prefix
+//! ```
+//!
+//! Each shard dir is a complete index (HNSW, SPLADE, `index.lock`), so
+//! `cqs index --shard services/billing` holds only that shard's lock and
+//! never blocks a concurrent reindex of another shard. Search fans the query
+//! out to every shard with the same prepared embedding and filter, then
+//! merges the ranked hits with the root shard's by score. `callers`,
+//! `callees`, `impact`, `trace`, `test-map` and `dead` walk the union of
+//! every shard's call graph ([`graph_context`]), so an edge from one shard
+//! into another is found.
+
+use std::collections::BTreeMap;
+use std::path::{Component, Path, PathBuf};
+
+use serde::{Deserialize, Serialize};
+use thiserror::Error;
+
+use crate::config::ShardsConfig;
+use crate::cross_project::{CrossProjectContext, NamedStore};
+use crate::hnsw::HnswIndex;
+use crate::reference::ReferenceIndex;
+use crate::store::helpers::StoreError;
+use crate::store::Store;
+
+/// Bare directory name under the slot dir that holds per-shard dirs.
+pub const SHARDS_DIR: &str = "shards";
+
+/// Bare file name of the manifest in `shards/<name>/`.
+pub const SHARD_MANIFEST_FILE: &str = "shard.toml";
+
+/// Errors from validating `[index.shards]` or writing a shard manifest.
+#[derive(Debug, Error)]
+pub enum ShardError {
+    #[error(
+        "Shard prefix '{0}' must be a project-relative directory without '.' or '..' segments"
+    )]
+    InvalidPrefix(String),
+
+    #[error("Shard prefixes '{0}' and '{1}' map to the same shard directory")]
+    DuplicateName(String, String),
+
+    #[error("Failed to write shard manifest {path}: {source}")]
+    Manifest {
+        path: PathBuf,
+        source: std::io::Error,
+    },
+}
+
+/// `shard.toml`: which prefix a shard dir holds, so search and `--shard`
+/// can list shards without re-reading `.cqs.toml`.
+#[derive(Debug, Serialize, Deserialize)]
+struct ShardManifest {
+    prefix: String,
+}
+
+/// Validated `[index.shards]`: routes an origin to its shard.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct ShardLayout {
+    /// Normalized prefixes, longest first so the most specific wins.
+    prefixes: Vec<String>,
+    top_level: bool,
+}
+
+impl ShardLayout {
+    /// Build the layout from config. `Ok(None)` when the section asks for no
+    /// sharding (no prefixes and `top_level = false`).
+    pub fn from_config(cfg: &ShardsConfig) -> Result<Option<Self>, ShardError> {
+        let mut prefixes = Vec::with_capacity(cfg.prefixes.len());
+        for raw in &cfg.prefixes {
+            prefixes.push(normalize_prefix(raw)?);
+        }
+        prefixes.sort_by(|a, b| b.len().cmp(&a.len()).then_with(|| a.cmp(b)));
+        prefixes.dedup();
+        for (i, a) in prefixes.iter().enumerate() {
+            if let Some(b) = prefixes[i + 1..]
+                .iter()
+                .find(|b| shard_name(a) == shard_name(b))
+            {
+                return Err(ShardError::DuplicateName(a.clone(), b.clone()));
+            }
+        }
+        if prefixes.is_empty() && !cfg.top_level {
+            return Ok(None);
+        }
+        Ok(Some(Self {
+            prefixes,
+            top_level: cfg.top_level,
+        }))
+    }
+
+    /// Prefix of the shard that owns `origin` (project-relative), or `None`
+    /// for the root shard.
+    pub fn shard_of(&self, origin: &Path) -> Option<String> {
+        let origin = crate::normalize_path(origin);
+        if let Some(prefix) = self.prefixes.iter().find(|p| {
+            origin
+                .strip_prefix(p.as_str())
+                .is_some_and(|rest| rest.starts_with('/'))
+        }) {
+            return Some(prefix.clone());
+        }
+        if self.top_level {
+            // Root-level files have no directory component to shard on.
+            if let Some((first, _)) = origin.split_once('/') {
+                return Some(first.to_string());
+            }
+        }
+        None
+    }
+
+    /// Group `files` by owning shard. The root shard (`None`) is always
+    /// present, as is every configured prefix, so a shard whose files were
+    /// all deleted still gets a pass that prunes them.
+    pub fn partition(&self, files: Vec<PathBuf>) -> BTreeMap<Option<String>, Vec<PathBuf>> {
+        let mut groups: BTreeMap<Option<String>, Vec<PathBuf>> = BTreeMap::new();
+        groups.insert(None, Vec::new());
+        for prefix in &self.prefixes {
+            groups.insert(Some(prefix.clone()), Vec::new());
+        }
+        for file in files {
+            groups.entry(self.shard_of(&file)).or_default().push(file);
+        }
+        groups
+    }
+}
+
+/// Validate a configured prefix and normalize it to the origin form
+/// (forward slashes, no leading `./` or trailing `/`).
+fn normalize_prefix(raw: &str) -> Result<String, ShardError> {
+    let path = Path::new(raw.trim());
+    let mut parts = Vec::new();
+    for component in path.components() {
+        match component {
+            Component::Normal(part) => match part.to_str() {
+                Some(part) => parts.push(part),
+                None => return Err(ShardError::InvalidPrefix(raw.to_string())),
+            },
+            Component::CurDir if parts.is_empty() => {}
+            _ => return Err(ShardError::InvalidPrefix(raw.to_string())),
+        }
+    }
+    if parts.is_empty() {
+        return Err(ShardError::InvalidPrefix(raw.to_string()));
+    }
+    Ok(parts.join("/"))
+}
+
+/// Directory name for the shard holding `prefix`: path separators become
+/// `--` so nested prefixes stay one level under `shards/`.
+pub fn shard_name(prefix: &str) -> String {
+    prefix.replace('/', "--")
+}
+
+/// `<slot_dir>/shards/<name>` for `prefix`.
+pub fn shard_dir(slot_dir: &Path, prefix: &str) -> PathBuf {
+    slot_dir.join(SHARDS_DIR).join(shard_name(prefix))
+}
+
+/// Record `prefix` in `dir/shard.toml`. Called by `cqs index` each time it
+/// writes the shard, so the manifest always matches the store beside it.
+pub fn write_manifest(dir: &Path, prefix: &str) -> Result<(), ShardError> {
+    let path = dir.join(SHARD_MANIFEST_FILE);
+    let body = toml::to_string(&ShardManifest {
+        prefix: prefix.to_string(),
+    })
+    .expect("shard manifest serializes");
+    std::fs::write(&path, body).map_err(|source| ShardError::Manifest { path, source })
+}
+
+/// A shard found on disk under a slot dir.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct ShardInfo {
+    /// Directory name under `shards/`.
+    pub name: String,
+    /// Path prefix the shard holds.
+    pub prefix: String,
+    /// The shard dir (`index.db`, HNSW files, `index.lock`).
+    pub dir: PathBuf,
+}
+
+/// Shards present under `slot_dir`, sorted by name. Dirs without a readable
+/// manifest are skipped with a warning. Empty when the index is not sharded.
+pub fn list_shards(slot_dir: &Path) -> Vec<ShardInfo> {
+    let Ok(entries) = std::fs::read_dir(slot_dir.join(SHARDS_DIR)) else {
+        return Vec::new();
+    };
+    let mut shards: Vec<ShardInfo> = entries
+        .filter_map(|entry| {
+            let dir = entry.ok()?.path();
+            if !dir.is_dir() {
+                return None;
+            }
+            let name = dir.file_name()?.to_str()?.to_string();
+            let manifest = dir.join(SHARD_MANIFEST_FILE);
+            let parsed = std::fs::read_to_string(&manifest)
+                .ok()
+                .and_then(|s| toml::from_str::<ShardManifest>(&s).ok());
+            match parsed {
+                Some(m) => Some(ShardInfo {
+                    name,
+                    prefix: m.prefix,
+                    dir,
+                }),
+                None => {
+                    tracing::warn!(path = %manifest.display(), "Skipping shard dir without a readable shard.toml");
+                    None
+                }
+            }
+        })
+        .collect();
+    shards.sort_by(|a, b| a.name.cmp(&b.name));
+    shards
+}
+
+/// Open one shard read-only for federated search, as a [`ReferenceIndex`]
+/// with weight 1.0 so its scores compare directly with the root shard's.
+///
+/// Returns `None` (with a warning) when the shard has no `index.db` yet or
+/// was built with a different embedding dim than `dim`, the root shard's:
+/// its vectors would not be comparable with the query embedding.
+pub fn load_shard(info: &ShardInfo, dim: usize) -> Option<ReferenceIndex> {
+    let _span = tracing::info_span!("load_shard", name = %info.name).entered();
+    let db_path = info.dir.join(crate::INDEX_DB_FILENAME);
+    if !db_path.exists() {
+        tracing::debug!(path = %db_path.display(), "Shard not indexed yet");
+        return None;
+    }
+    let store = match Store::open_readonly_small(&db_path) {
+        Ok(s) => s,
+        Err(e) => {
+            tracing::warn!(shard = %info.name, error = %e, "Skipping shard: failed to open index.db");
+            return None;
+        }
+    };
+    if store.dim() != dim {
+        tracing::warn!(
+            shard = %info.name,
+            shard_dim = store.dim(),
+            dim,
+            "Skipping shard built with a different embedding dim; run `cqs index --shard {}`",
+            info.prefix
+        );
+        return None;
+    }
+    let index = HnswIndex::try_load_with_ef(&info.dir, None, dim);
+    Some(ReferenceIndex::new_loaded(
+        info.name.clone(),
+        store,
+        index,
+        1.0,
+        db_path,
+    ))
+}
+
+/// Open every indexed shard under `slot_dir`. See [`load_shard`].
+pub fn load_shards(slot_dir: &Path, dim: usize) -> Vec<ReferenceIndex> {
+    list_shards(slot_dir)
+        .iter()
+        .filter_map(|info| load_shard(info, dim))
+        .collect()
+}
+
+/// Read-only handles on every indexed shard under `slot_dir`, named by shard
+/// dir, for call-graph queries. Unlike [`load_shard`] no shard is skipped
+/// for its embedding dim: call edges don't depend on it.
+pub(crate) fn open_shard_stores(slot_dir: &Path) -> Vec<NamedStore> {
+    list_shards(slot_dir)
+        .into_iter()
+        .filter_map(|info| {
+            let db_path = info.dir.join(crate::INDEX_DB_FILENAME);
+            if !db_path.exists() {
+                return None;
+            }
+            match Store::open_readonly_small(&db_path) {
+                Ok(store) => Some(NamedStore::new(info.name, store, db_path)),
+                Err(e) => {
+                    tracing::warn!(shard = %info.name, error = %e, "Skipping shard: failed to open index.db");
+                    None
+                }
+            }
+        })
+        .collect()
+}
+
+/// Call graph of a sharded index: the root shard (named `root`) plus every
+/// indexed shard under `slot_dir`, so the graph commands see edges whose
+/// caller and callee live in different shards. `Ok(None)` when
+/// the index is not sharded.
+pub fn graph_context(slot_dir: &Path) -> Result<Option<CrossProjectContext>, StoreError> {
+    let shards = open_shard_stores(slot_dir);
+    if shards.is_empty() {
+        return Ok(None);
+    }
+    let _span = tracing::info_span!("shard_graph_context", shards = shards.len()).entered();
+    let db_path = slot_dir.join(crate::INDEX_DB_FILENAME);
+    let root = Store::open_readonly(&db_path)?;
+    let mut stores = vec![NamedStore::new("root".to_string(), root, db_path)];
+    stores.extend(shards);
+    Ok(Some(CrossProjectContext::new(stores)))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn layout(top_level: bool, prefixes: &[&str]) -> Option<ShardLayout> {
+        ShardLayout::from_config(&ShardsConfig {
+            top_level,
+            prefixes: prefixes.iter().map(|s| s.to_string()).collect(),
+        })
+        .unwrap()
+    }
+
+    #[test]
+    fn empty_config_is_unsharded() {
+        assert!(layout(false, &[]).is_none());
+        assert!(layout(true, &[]).is_some());
+    }
+
+    #[test]
+    fn longest_prefix_wins() {
+        let l = layout(false, &["services", "./services/billing/"]).unwrap();
+        assert_eq!(
+            l.shard_of(Path::new("services/billing/src/lib.rs"))
+                .as_deref(),
+            Some("services/billing")
+        );
+        assert_eq!(
+            l.shard_of(Path::new("services/search/main.go")).as_deref(),
+            Some("services")
+        );
+        // Prefix match is per path segment, not per byte.
+        assert_eq!(l.shard_of(Path::new("services2/a.rs")), None);
+        assert_eq!(l.shard_of(Path::new("README.md")), None);
+    }
+
+    #[test]
+    fn top_level_shards_by_first_component() {
+        let l = layout(true, &["libs/core"]).unwrap();
+        assert_eq!(l.shard_of(Path::new("api/main.rs")).as_deref(), Some("api"));
+        assert_eq!(
+            l.shard_of(Path::new("libs/core/x.rs")).as_deref(),
+            Some("libs/core")
+        );
+        assert_eq!(
+            l.shard_of(Path::new("libs/util/y.rs")).as_deref(),
+            Some("libs")
+        );
+        assert_eq!(l.shard_of(Path::new("build.rs")), None);
+    }
+
+    #[test]
+    fn partition_keeps_root_and_configured_shards() {
+        let l = layout(false, &["a", "b"]).unwrap();
+        let groups = l.partition(vec![PathBuf::from("a/x.rs"), PathBuf::from("top.rs")]);
+        assert_eq!(groups.len(), 3);
+        assert_eq!(groups[&None], vec![PathBuf::from("top.rs")]);
+        assert_eq!(
+            groups[&Some("a".to_string())],
+            vec![PathBuf::from("a/x.rs")]
+        );
+        assert!(groups[&Some("b".to_string())].is_empty());
+    }
+
+    #[test]
+    fn rejects_escaping_and_colliding_prefixes() {
+        for bad in ["", "..", "a/../b", "/abs"] {
+            let cfg = ShardsConfig {
+                top_level: false,
+                prefixes: vec![bad.to_string()],
+            };
+            assert!(
+                matches!(
+                    ShardLayout::from_config(&cfg),
+                    Err(ShardError::InvalidPrefix(_))
+                ),
+                "{bad:?} should be rejected"
+            );
+        }
+        let cfg = ShardsConfig {
+            top_level: false,
+            prefixes: vec!["a/b".into(), "a--b".into()],
+        };
+        assert!(matches!(
+            ShardLayout::from_config(&cfg),
+            Err(ShardError::DuplicateName(_, _))
+        ));
+    }
+
+    #[test]
+    fn manifest_round_trips_through_list_shards() {
+        let dir = tempfile::TempDir::new().unwrap();
+        let shard = shard_dir(dir.path(), "services/billing");
+        std::fs::create_dir_all(&shard).unwrap();
+        write_manifest(&shard, "services/billing").unwrap();
+        std::fs::create_dir_all(dir.path().join(SHARDS_DIR).join("stray")).unwrap();
+
+        let shards = list_shards(dir.path());
+        assert_eq!(
+            shards,
+            vec![ShardInfo {
+                name: "services--billing".into(),
+                prefix: "services/billing".into(),
+                dir: shard,
+            }]
+        );
+        assert!(list_shards(&dir.path().join("missing")).is_empty());
+    }
+}
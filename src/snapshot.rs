@@ -0,0 +1,218 @@
+//! Git-rev snapshot indexes for time-travel search.
+//!
+//! A snapshot is a standard cqs index (SQLite DB + HNSW files) built from the
+//! tree of one commit and stored under `.cqs/snapshots/<sha>/`. Snapshots are
+//! keyed by the full commit SHA, so `--at v2.3.0` and `--at <sha-of-v2.3.0>`
+//! share one index. A commit's tree never changes, so a completed snapshot
+//! never goes stale — it is built once on first use and reused after that.
+//!
+//! Search treats a snapshot exactly like a reference index (read-only store,
+//! optional HNSW), so the retrieval path is the `--ref` path pointed at a
+//! different directory. This module owns only the git side: resolving a rev to
+//! a commit and exporting that commit's tree to a scratch directory for the
+//! indexing pipeline.
+
+use std::path::{Path, PathBuf};
+use std::process::Command;
+
+/// Subdirectory of the project `.cqs/` that holds one directory per snapshot.
+pub const SNAPSHOTS_DIR: &str = "snapshots";
+
+/// Marker written into a snapshot directory after its index finished
+/// building. A directory without it is a crashed or interrupted build and is
+/// rebuilt from scratch on next use.
+pub const SNAPSHOT_COMPLETE_MARKER: &str = ".complete";
+
+/// Errors from resolving or exporting a git revision.
+#[derive(Debug, thiserror::Error)]
+pub enum SnapshotError {
+    #[error("invalid revision '{0}': {1}")]
+    InvalidRev(String, &'static str),
+    #[error("git error: {0}")]
+    Git(String),
+    #[error(transparent)]
+    Io(#[from] std::io::Error),
+}
+
+/// Reject revision strings that git would parse as something other than a
+/// revision: option-looking input (`--output=...`), NUL bytes, `rev:path`
+/// blob specs, and `a..b` ranges.
+pub fn validate_rev(rev: &str) -> Result<(), SnapshotError> {
+    let invalid = |why| Err(SnapshotError::InvalidRev(rev.to_string(), why));
+    if rev.trim().is_empty() {
+        return invalid("must not be empty");
+    }
+    if rev.starts_with('-') {
+        return invalid("must not start with '-'");
+    }
+    if rev.contains('\0') {
+        return invalid("must not contain null bytes");
+    }
+    if rev.contains(':') {
+        return invalid("must name a commit, not a 'rev:path' blob");
+    }
+    if rev.contains("..") {
+        return invalid("must name a single commit, not a range");
+    }
+    Ok(())
+}
+
+/// Resolve `rev` (tag, branch, `HEAD~3`, abbreviated SHA, ...) to the full
+/// commit SHA in the repository containing `root`.
+///
+/// Uses `rev-parse --verify <rev>^{commit}` so an annotated tag peels to its
+/// commit and a tree/blob rev is rejected rather than silently indexed.
+pub fn resolve_rev(root: &Path, rev: &str) -> Result<String, SnapshotError> {
+    let _span = tracing::info_span!("resolve_rev", rev).entered();
+    validate_rev(rev)?;
+
+    let spec = format!("{rev}^{{commit}}");
+    let output = Command::new("git")
+        .arg("-C")
+        .arg(root)
+        .args(["rev-parse", "--verify", "--quiet", &spec])
+        .output()
+        .map_err(|e| {
+            tracing::warn!(error = %e, "Failed to spawn git rev-parse");
+            SnapshotError::Git(format!("failed to run git: {e}"))
+        })?;
+
+    if !output.status.success() {
+        return Err(SnapshotError::Git(format!(
+            "'{rev}' does not name a commit in {}",
+            root.display()
+        )));
+    }
+
+    let sha = String::from_utf8_lossy(&output.stdout).trim().to_string();
+    if !is_full_sha(&sha) {
+        return Err(SnapshotError::Git(format!(
+            "git rev-parse returned unexpected output for '{rev}': {sha:?}"
+        )));
+    }
+    Ok(sha)
+}
+
+/// `true` for a full SHA-1 (40) or SHA-256 (64) lowercase hex object name.
+fn is_full_sha(s: &str) -> bool {
+    matches!(s.len(), 40 | 64) && s.bytes().all(|b| b.is_ascii_hexdigit())
+}
+
+/// Directory holding the snapshot index for commit `sha`.
+pub fn snapshot_dir(project_cqs_dir: &Path, sha: &str) -> PathBuf {
+    project_cqs_dir.join(SNAPSHOTS_DIR).join(sha)
+}
+
+/// `true` when `dir` holds a fully built snapshot index.
+pub fn is_complete(dir: &Path) -> bool {
+    dir.join(crate::INDEX_DB_FILENAME).exists() && dir.join(SNAPSHOT_COMPLETE_MARKER).exists()
+}
+
+/// Record that the snapshot in `dir` finished building.
+pub fn mark_complete(dir: &Path) -> Result<(), SnapshotError> {
+    std::fs::write(dir.join(SNAPSHOT_COMPLETE_MARKER), b"")?;
+    Ok(())
+}
+
+/// Export the tracked tree of commit `sha` into `dest` (which must exist).
+///
+/// Reads the commit into a throwaway index file (`GIT_INDEX_FILE`) and runs
+/// `checkout-index` against it, so the user's real index and working tree are
+/// never touched. Only tracked files are exported, which is why the snapshot
+/// needs no `.gitignore` handling downstream.
+pub fn export_tree(root: &Path, sha: &str, dest: &Path) -> Result<(), SnapshotError> {
+    let _span = tracing::info_span!("export_tree", sha, dest = %dest.display()).entered();
+    if !is_full_sha(sha) {
+        return Err(SnapshotError::InvalidRev(
+            sha.to_string(),
+            "export_tree requires a resolved full commit SHA",
+        ));
+    }
+
+    let scratch = tempfile::tempdir()?;
+    let index_file = scratch.path().join("index");
+
+    let run = |args: &[&str]| -> Result<(), SnapshotError> {
+        let output = Command::new("git")
+            .arg("-C")
+            .arg(root)
+            .args(args)
+            .env("GIT_INDEX_FILE", &index_file)
+            .output()
+            .map_err(|e| SnapshotError::Git(format!("failed to run git: {e}")))?;
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(SnapshotError::Git(format!(
+                "git {} failed: {}",
+                args.first().copied().unwrap_or_default(),
+                stderr.trim()
+            )));
+        }
+        Ok(())
+    };
+
+    run(&["read-tree", sha])?;
+
+    // `--prefix` is a string prefix, not a directory: the trailing separator
+    // is what makes git write `dest/src/lib.rs` instead of `destsrc/lib.rs`.
+    let mut prefix = dest.to_string_lossy().into_owned();
+    if !prefix.ends_with('/') && !prefix.ends_with(std::path::MAIN_SEPARATOR) {
+        prefix.push('/');
+    }
+    let prefix_arg = format!("--prefix={prefix}");
+    run(&["checkout-index", "--all", "--force", &prefix_arg])?;
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn validate_rev_accepts_common_revisions() {
+        for rev in [
+            "v2.3.0",
+            "HEAD",
+            "HEAD~3",
+            "origin/main",
+            "abc1234",
+            "main@{1}",
+        ] {
+            assert!(validate_rev(rev).is_ok(), "{rev} should be accepted");
+        }
+    }
+
+    #[test]
+    fn validate_rev_rejects_non_commit_input() {
+        for rev in ["", "  ", "--output=/tmp/x", "v1:src/lib.rs", "a..b", "v1\0"] {
+            assert!(validate_rev(rev).is_err(), "{rev:?} should be rejected");
+        }
+    }
+
+    #[test]
+    fn is_full_sha_checks_length_and_hex() {
+        assert!(is_full_sha(&"a".repeat(40)));
+        assert!(is_full_sha(&"0".repeat(64)));
+        assert!(!is_full_sha("abc1234"));
+        assert!(!is_full_sha(&"g".repeat(40)));
+    }
+
+    #[test]
+    fn snapshot_dir_is_keyed_by_sha() {
+        let dir = snapshot_dir(Path::new("/p/.cqs"), "deadbeef");
+        assert_eq!(dir, Path::new("/p/.cqs/snapshots/deadbeef"));
+    }
+
+    #[test]
+    fn is_complete_requires_marker_and_db() {
+        let tmp = tempfile::tempdir().unwrap();
+        assert!(!is_complete(tmp.path()));
+        std::fs::write(tmp.path().join(crate::INDEX_DB_FILENAME), b"").unwrap();
+        assert!(
+            !is_complete(tmp.path()),
+            "db without marker is a crashed build"
+        );
+        mark_complete(tmp.path()).unwrap();
+        assert!(is_complete(tmp.path()));
+    }
+}
@@ -0,0 +1,267 @@
+//! Result snippets: a chunk's text cut to a line budget.
+//!
+//! Search output shows the head of a chunk, not all 200 lines of it. A cut
+//! at a fixed line count lands mid-expression as often as not, so
+//! [`truncate`] backs up from the budget to the last line that ends a
+//! statement or closes a block, and the caller appends a marker saying how
+//! many lines were cut:
+//!
+//! ```text
+//!     let report = summarize(&store);
+//!     ... 12 more lines
+//! ```
+//!
+//! The boundary test is lexical and the same for every language: brackets
+//! balanced since the start of the chunk, no string left open, the line not
+//! ending in an opener or operator, and the next line not a continuation
+//! (a closing brace, `.method()`, `else`, a leading `&&`). It backs up at
+//! most half the budget; when nothing in that range qualifies the cut falls
+//! at the budget, still on a line boundary.
+
+/// Line endings that leave the statement open: an opener, a separator, or a
+/// binary operator waiting for its right-hand side. `:` covers Python and
+/// YAML headers, whose body the cut would drop.
+const OPEN_ENDINGS: &[&str] = &[
+    "{", "(", "[", ",", "\\", "=", "+", "&&", "||", "->", "=>", ".", ":", "|",
+];
+
+/// Line starts that continue the line above.
+const CONTINUATION_PREFIXES: &[&str] = &["}", ")", "]", ".", "?", "&&", "||", "|", "+"];
+
+/// Keywords that continue the statement above when they start a line.
+const CONTINUATION_KEYWORDS: &[&str] = &["else", "elif", "except", "finally", "catch", "where"];
+
+/// A chunk's text cut to a line budget.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Snippet {
+    /// The kept lines, without the marker.
+    pub text: String,
+    /// Lines of the chunk kept.
+    pub shown_lines: usize,
+    /// Lines cut after them; 0 when the chunk fit.
+    pub cut_lines: usize,
+    /// Indentation of the first cut line, which the marker lines up with.
+    indent: String,
+}
+
+impl Snippet {
+    /// The line standing in for the cut lines (`... 12 more lines`), or
+    /// `None` when nothing was cut.
+    pub fn marker(&self) -> Option<String> {
+        (self.cut_lines > 0).then(|| {
+            format!(
+                "{}... {} more line{}",
+                self.indent,
+                self.cut_lines,
+                if self.cut_lines == 1 { "" } else { "s" }
+            )
+        })
+    }
+
+    /// The kept lines followed by the marker line, if any.
+    pub fn with_marker(&self) -> String {
+        match self.marker() {
+            Some(marker) => format!("{}\n{marker}", self.text),
+            None => self.text.clone(),
+        }
+    }
+}
+
+/// Cut `content` to at most `max_lines` lines (at least one), ending at a
+/// statement or block boundary when one falls in the back half of the
+/// budget. Content that fits comes back whole.
+pub fn truncate(content: &str, max_lines: usize) -> Snippet {
+    let max_lines = max_lines.max(1);
+    let lines: Vec<&str> = content.lines().collect();
+    if lines.len() <= max_lines {
+        return Snippet {
+            text: content.to_string(),
+            shown_lines: lines.len(),
+            cut_lines: 0,
+            indent: String::new(),
+        };
+    }
+    let keep = cut_point(&lines, max_lines);
+    let next = lines[keep];
+    Snippet {
+        text: lines[..keep].join("\n"),
+        shown_lines: keep,
+        cut_lines: lines.len() - keep,
+        indent: next[..next.len() - next.trim_start().len()].to_string(),
+    }
+}
+
+/// How many lines to keep: the most, within `max_lines` and no fewer than
+/// half of it, that end on a boundary; `max_lines` when none do. `lines` is
+/// longer than `max_lines`, so every candidate has a next line.
+fn cut_point(lines: &[&str], max_lines: usize) -> usize {
+    let floor = max_lines.div_ceil(2);
+    let mut scanner = Scanner::default();
+    let mut best = None;
+    for keep in 1..=max_lines {
+        let last = lines[keep - 1];
+        scanner.feed(last);
+        if keep >= floor && scanner.balanced() && is_boundary(last, lines[keep]) {
+            best = Some(keep);
+        }
+    }
+    best.unwrap_or(max_lines)
+}
+
+/// Whether a cut between `last` and `next` falls between two statements.
+fn is_boundary(last: &str, next: &str) -> bool {
+    let last = last.trim();
+    if !last.is_empty() && OPEN_ENDINGS.iter().any(|e| last.ends_with(e)) {
+        return false;
+    }
+    let next = next.trim_start();
+    if CONTINUATION_PREFIXES.iter().any(|p| next.starts_with(p)) {
+        return false;
+    }
+    !CONTINUATION_KEYWORDS.iter().any(|k| {
+        next.strip_prefix(k)
+            .is_some_and(|rest| !rest.starts_with(|c: char| c.is_alphanumeric() || c == '_'))
+    })
+}
+
+/// Bracket and string state carried from the start of the chunk.
+#[derive(Default)]
+struct Scanner {
+    /// Unclosed `(` and `[`. Braces are left out: a cut inside a function
+    /// body is the normal case, a cut inside an argument list is not.
+    depth: usize,
+    in_string: bool,
+}
+
+impl Scanner {
+    fn balanced(&self) -> bool {
+        self.depth == 0 && !self.in_string
+    }
+
+    fn feed(&mut self, line: &str) {
+        let mut chars = line.chars().peekable();
+        while let Some(c) = chars.next() {
+            if self.in_string {
+                match c {
+                    '\\' => {
+                        chars.next();
+                    }
+                    '"' => self.in_string = false,
+                    _ => {}
+                }
+                continue;
+            }
+            match c {
+                '"' => self.in_string = true,
+                '/' if chars.peek() == Some(&'/') => break,
+                // A character literal such as '(' is not a bracket.
+                '\'' => {
+                    let mut ahead = chars.clone();
+                    if ahead.next().is_some() && ahead.next() == Some('\'') {
+                        chars.next();
+                        chars.next();
+                    }
+                }
+                '(' | '[' => self.depth += 1,
+                ')' | ']' => self.depth = self.depth.saturating_sub(1),
+                _ => {}
+            }
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const RUST: &str = "\
+fn run(config: &Config) -> Result<()> {
+    let store = open(config)?;
+    for file in files {
+        index(file)?;
+    }
+    let report = summarize(
+        &store,
+        config.verbose,
+    );
+    println!(\"{report}\");
+    Ok(())
+}";
+
+    #[test]
+    fn short_content_is_whole() {
+        let s = truncate(RUST, 12);
+        assert_eq!(s.text, RUST);
+        assert_eq!((s.shown_lines, s.cut_lines), (12, 0));
+        assert_eq!(s.marker(), None);
+        assert_eq!(s.with_marker(), RUST);
+    }
+
+    #[test]
+    fn backs_up_out_of_an_argument_list() {
+        // Line 8 is inside `summarize(`; the last boundary before it is the
+        // loop's closing brace.
+        let s = truncate(RUST, 8);
+        assert_eq!((s.shown_lines, s.cut_lines), (5, 7));
+        assert!(s.text.ends_with("        index(file)?;\n    }"));
+        assert_eq!(s.marker().as_deref(), Some("    ... 7 more lines"));
+        assert!(s.with_marker().ends_with("    }\n    ... 7 more lines"));
+    }
+
+    #[test]
+    fn keeps_the_latest_statement_end() {
+        let s = truncate(RUST, 10);
+        assert_eq!((s.shown_lines, s.cut_lines), (10, 2));
+        assert!(s.text.ends_with("println!(\"{report}\");"));
+    }
+
+    #[test]
+    fn never_cuts_before_a_continuation() {
+        let content = "\
+prepare();
+check();
+if ready {
+    go();
+}
+else {
+    wait();
+}
+done();";
+        // Not before the `}` of line 5, and not between it and the `else`.
+        assert_eq!(truncate(content, 4).shown_lines, 2);
+        assert_eq!(truncate(content, 8).shown_lines, 8);
+        let chain = "let x = items\n    .iter()\n    .map(f)\n    .collect();\nuse_it(x);";
+        assert_eq!(truncate(chain, 3).shown_lines, 3);
+        assert_eq!(truncate(chain, 4).shown_lines, 4);
+    }
+
+    #[test]
+    fn python_headers_and_open_brackets() {
+        let content = "\
+def load(path):
+    with open(path) as f:
+        data = json.load(f)
+    items = [
+        x for x in data
+    ]
+    return items";
+        assert_eq!(truncate(content, 5).shown_lines, 3);
+        assert_eq!(truncate(content, 6).shown_lines, 6);
+    }
+
+    #[test]
+    fn strings_and_char_literals_do_not_count_as_brackets() {
+        let content = "let open = '(';\nlet s = \"[not a bracket\";\nnext();\nmore();";
+        assert_eq!(truncate(content, 3).shown_lines, 3);
+    }
+
+    #[test]
+    fn hard_cut_when_no_boundary_fits() {
+        let content = format!("data = [\n{}]", "    1,\n".repeat(20));
+        let s = truncate(&content, 5);
+        assert_eq!((s.shown_lines, s.cut_lines), (5, 17));
+        assert_eq!(s.marker().as_deref(), Some("    ... 17 more lines"));
+        let one = truncate("a();\nb();", 1);
+        assert_eq!(one.marker().as_deref(), Some("... 1 more line"));
+    }
+}
@@ -0,0 +1,377 @@
+//! Per-stage timing for one command, written as a trace file (`--trace`).
+//!
+//! With `--trace PATH` the subscriber gets one more layer that times every
+//! enter/exit of a cqs span and keeps the timings in memory. Its filter is
+//! separate from the log filter (`cqs=debug` unless `CQS_TRACE_FILTER` says
+//! otherwise), so the search stages — `embed_query`, `search_fts`,
+//! `hnsw_search`, `fetch_candidates`, `fetch_content`, `rerank` — are all
+//! there even when the logs are quiet. [`finish`] writes the file when the
+//! command returns:
+//!
+//! - `*.folded` / `*.txt` — folded stacks (`a;b;c <self µs>`), the input of
+//!   `flamegraph.pl` and `inferno-flamegraph`;
+//! - anything else — Chrome trace-event JSON, for `chrome://tracing`,
+//!   Perfetto, or speedscope.
+//!
+//! A span entered several times yields one timing per entry. Spans past
+//! [`MAX_SPANS`] are counted and dropped so a long-running command can't
+//! grow the buffer without bound.
+
+use std::collections::{BTreeMap, HashMap};
+use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::{Arc, Mutex, OnceLock};
+use std::time::Instant;
+
+use serde_json::{json, Map, Value};
+use tracing::span::{Attributes, Id, Record};
+use tracing::Subscriber;
+use tracing_subscriber::layer::{Context, Layer};
+use tracing_subscriber::registry::LookupSpan;
+use tracing_subscriber::EnvFilter;
+
+use crate::logging::JsonFields;
+
+/// Span filter when `CQS_TRACE_FILTER` is unset.
+pub const DEFAULT_TRACE_FILTER: &str = "cqs=debug";
+
+/// Timings kept before further spans are dropped.
+pub const MAX_SPANS: usize = 1_000_000;
+
+static RECORDER: OnceLock<Arc<Recorder>> = OnceLock::new();
+
+/// One closed entry of a span.
+#[derive(Debug, Clone)]
+struct SpanTiming {
+    name: &'static str,
+    target: &'static str,
+    /// `root;…;parent;name`.
+    stack: Arc<str>,
+    thread: u64,
+    start_us: u64,
+    dur_us: u64,
+    fields: Arc<Map<String, Value>>,
+}
+
+#[derive(Default)]
+struct State {
+    spans: Vec<SpanTiming>,
+    threads: BTreeMap<u64, String>,
+    dropped: u64,
+}
+
+/// Shared between the layer and [`finish`].
+struct Recorder {
+    path: PathBuf,
+    epoch: Instant,
+    state: Mutex<State>,
+}
+
+impl Recorder {
+    fn new(path: &Path) -> Self {
+        Self {
+            path: path.to_path_buf(),
+            epoch: Instant::now(),
+            state: Mutex::new(State::default()),
+        }
+    }
+
+    fn take(&self) -> State {
+        std::mem::take(&mut *self.state.lock().unwrap_or_else(|e| e.into_inner()))
+    }
+}
+
+/// Per-span bookkeeping stored in the span's extensions.
+struct Open {
+    stack: Arc<str>,
+    fields: Arc<Map<String, Value>>,
+    entered: Vec<Instant>,
+}
+
+/// Records span timings for [`finish`].
+pub struct TraceLayer {
+    recorder: Arc<Recorder>,
+}
+
+/// The layer for `--trace PATH`. Only the first call registers with
+/// [`finish`]; the process has one subscriber.
+pub fn layer(path: &Path) -> TraceLayer {
+    let recorder = RECORDER
+        .get_or_init(|| Arc::new(Recorder::new(path)))
+        .clone();
+    TraceLayer { recorder }
+}
+
+/// The trace layer's span filter: `CQS_TRACE_FILTER`, else
+/// [`DEFAULT_TRACE_FILTER`].
+pub fn filter() -> EnvFilter {
+    std::env::var("CQS_TRACE_FILTER")
+        .ok()
+        .filter(|v| !v.is_empty())
+        .and_then(|v| {
+            EnvFilter::try_new(&v)
+                .map_err(|e| eprintln!("cqs: CQS_TRACE_FILTER: {e}"))
+                .ok()
+        })
+        .unwrap_or_else(|| EnvFilter::new(DEFAULT_TRACE_FILTER))
+}
+
+/// Small stable per-thread number for the trace (`ThreadId` has no stable
+/// integer form).
+fn thread_index() -> u64 {
+    static NEXT: AtomicU64 = AtomicU64::new(1);
+    thread_local! {
+        static INDEX: u64 = NEXT.fetch_add(1, Ordering::Relaxed);
+    }
+    INDEX.with(|i| *i)
+}
+
+impl<S> Layer<S> for TraceLayer
+where
+    S: Subscriber + for<'a> LookupSpan<'a>,
+{
+    fn on_new_span(&self, attrs: &Attributes<'_>, id: &Id, ctx: Context<'_, S>) {
+        let Some(span) = ctx.span(id) else { return };
+        let mut fields = JsonFields::default();
+        attrs.record(&mut fields);
+        let name = span.name();
+        let parent_stack = span
+            .parent()
+            .and_then(|parent| parent.extensions().get::<Open>().map(|o| o.stack.clone()));
+        let stack: Arc<str> = match parent_stack {
+            Some(parent) => format!("{parent};{name}").into(),
+            None => name.into(),
+        };
+        span.extensions_mut().insert(Open {
+            stack,
+            fields: Arc::new(fields.0),
+            entered: Vec::new(),
+        });
+    }
+
+    fn on_record(&self, id: &Id, values: &Record<'_>, ctx: Context<'_, S>) {
+        let Some(span) = ctx.span(id) else { return };
+        let mut ext = span.extensions_mut();
+        if let Some(open) = ext.get_mut::<Open>() {
+            let map = Arc::make_mut(&mut open.fields);
+            let mut fields = JsonFields(std::mem::take(map));
+            values.record(&mut fields);
+            *map = fields.0;
+        }
+    }
+
+    fn on_enter(&self, id: &Id, ctx: Context<'_, S>) {
+        let Some(span) = ctx.span(id) else { return };
+        let mut ext = span.extensions_mut();
+        if let Some(open) = ext.get_mut::<Open>() {
+            open.entered.push(Instant::now());
+        }
+    }
+
+    fn on_exit(&self, id: &Id, ctx: Context<'_, S>) {
+        let now = Instant::now();
+        let Some(span) = ctx.span(id) else { return };
+        let timing = {
+            let mut ext = span.extensions_mut();
+            let Some(open) = ext.get_mut::<Open>() else {
+                return;
+            };
+            let Some(entered) = open.entered.pop() else {
+                return;
+            };
+            SpanTiming {
+                name: span.name(),
+                target: span.metadata().target(),
+                stack: open.stack.clone(),
+                thread: thread_index(),
+                start_us: entered
+                    .saturating_duration_since(self.recorder.epoch)
+                    .as_micros() as u64,
+                dur_us: now.saturating_duration_since(entered).as_micros() as u64,
+                fields: open.fields.clone(),
+            }
+        };
+        let mut state = self
+            .recorder
+            .state
+            .lock()
+            .unwrap_or_else(|e| e.into_inner());
+        if state.spans.len() >= MAX_SPANS {
+            state.dropped += 1;
+            return;
+        }
+        state.threads.entry(timing.thread).or_insert_with(|| {
+            std::thread::current()
+                .name()
+                .unwrap_or("thread")
+                .to_string()
+        });
+        state.spans.push(timing);
+    }
+}
+
+/// Write the recorded timings to the `--trace` path, if tracing is on.
+/// Called once, after the command returns; a write failure is reported on
+/// stderr and never fails the command.
+pub fn finish() {
+    let Some(recorder) = RECORDER.get() else {
+        return;
+    };
+    let state = recorder.take();
+    let path = &recorder.path;
+    let folded_output = matches!(
+        path.extension().and_then(|e| e.to_str()),
+        Some("folded" | "txt")
+    );
+    let body = if folded_output {
+        folded(&state.spans)
+    } else {
+        chrome_json(&state).to_string()
+    };
+    match std::fs::write(path, body) {
+        Ok(()) => {
+            eprintln!(
+                "cqs: wrote {} span timings to {}",
+                state.spans.len(),
+                path.display()
+            );
+            if state.dropped > 0 {
+                eprintln!(
+                    "cqs: {} later spans were dropped past the {MAX_SPANS}-span cap",
+                    state.dropped
+                );
+            }
+        }
+        Err(e) => eprintln!("cqs: cannot write trace to {}: {e}", path.display()),
+    }
+}
+
+/// Chrome trace-event JSON: one complete (`X`) event per span entry plus a
+/// `thread_name` record per thread.
+fn chrome_json(state: &State) -> Value {
+    let mut events: Vec<Value> = state
+        .threads
+        .iter()
+        .map(|(tid, name)| {
+            json!({
+                "name": "thread_name",
+                "ph": "M",
+                "pid": 1,
+                "tid": tid,
+                "args": { "name": name },
+            })
+        })
+        .collect();
+    events.extend(state.spans.iter().map(|s| {
+        json!({
+            "name": s.name,
+            "cat": s.target,
+            "ph": "X",
+            "ts": s.start_us,
+            "dur": s.dur_us,
+            "pid": 1,
+            "tid": s.thread,
+            "args": &*s.fields,
+        })
+    }));
+    json!({
+        "traceEvents": events,
+        "displayTimeUnit": "ms",
+        "otherData": {
+            "cqs_version": env!("CARGO_PKG_VERSION"),
+            "dropped_spans": state.dropped,
+        },
+    })
+}
+
+/// Folded stacks weighted by self time in microseconds: a stack's total
+/// minus the time of the spans directly under it. Spans that ran on other
+/// threads in parallel can sum past their parent; self time floors at zero.
+fn folded(spans: &[SpanTiming]) -> String {
+    let mut total: BTreeMap<&str, u64> = BTreeMap::new();
+    let mut children: HashMap<&str, u64> = HashMap::new();
+    for s in spans {
+        *total.entry(&*s.stack).or_default() += s.dur_us;
+        if let Some((parent, _)) = s.stack.rsplit_once(';') {
+            *children.entry(parent).or_default() += s.dur_us;
+        }
+    }
+    let mut out = String::new();
+    for (stack, us) in total {
+        let own = us.saturating_sub(children.get(stack).copied().unwrap_or(0));
+        if own > 0 {
+            out.push_str(&format!("{stack} {own}\n"));
+        }
+    }
+    out
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tracing_subscriber::layer::SubscriberExt;
+
+    fn timing(stack: &str, dur_us: u64) -> SpanTiming {
+        SpanTiming {
+            name: "x",
+            target: "cqs",
+            stack: stack.into(),
+            thread: 1,
+            start_us: 0,
+            dur_us,
+            fields: Arc::default(),
+        }
+    }
+
+    #[test]
+    fn records_nested_spans_with_fields() {
+        let recorder = Arc::new(Recorder::new(Path::new("unused.json")));
+        let subscriber = tracing_subscriber::registry().with(TraceLayer {
+            recorder: recorder.clone(),
+        });
+        tracing::subscriber::with_default(subscriber, || {
+            let _outer = tracing::info_span!("cmd_query", limit = 5).entered();
+            for _ in 0..2 {
+                let _inner = tracing::debug_span!("embed_query").entered();
+            }
+        });
+        let state = recorder.take();
+        let stacks: Vec<&str> = state.spans.iter().map(|s| &*s.stack).collect();
+        assert_eq!(
+            stacks,
+            [
+                "cmd_query;embed_query",
+                "cmd_query;embed_query",
+                "cmd_query"
+            ]
+        );
+        assert_eq!(state.spans[2].fields["limit"], 5);
+        assert_eq!(state.threads.len(), 1);
+
+        let json = chrome_json(&state);
+        let events = json["traceEvents"].as_array().unwrap();
+        assert_eq!(events[0]["ph"], "M");
+        assert_eq!(events.len(), 4);
+        assert_eq!(events[3]["name"], "cmd_query");
+        assert_eq!(events[3]["args"]["limit"], 5);
+    }
+
+    #[test]
+    fn folded_stacks_carry_self_time() {
+        let spans = [
+            timing("search;embed", 30),
+            timing("search;fts", 20),
+            timing("search", 100),
+            timing("search;embed", 10),
+        ];
+        assert_eq!(
+            folded(&spans),
+            "search 40\nsearch;embed 40\nsearch;fts 20\n"
+        );
+        // Parallel children that outlast the parent leave it no self time.
+        assert_eq!(
+            folded(&[timing("a", 10), timing("a;b", 8), timing("a;b", 8)]),
+            "a;b 16\n"
+        );
+    }
+}
@@ -1,4 +1,4 @@
-//! Filesystem snapshots of `index.db` taken before schema migrations run.
+//! Filesystem snapshots of `index.db`: pre-migration backups and `cqs backup`.
 //!
 //! `migrate()` wraps all DDL/DML in a single `pool.begin()` transaction. SQLite
 //! rolls back the transaction if any step fails, which covers the happy path.
@@ -22,13 +22,19 @@
 //!
 //! `src/hnsw/persist.rs` uses an identical save-with-backup-and-rollback
 //! pattern for HNSW graph files.
+//!
+//! The same `VACUUM INTO` snapshot backs `cqs backup`; `cqs restore` vets a
+//! snapshot with [`inspect_snapshot`] and swaps it in with
+//! [`restore_snapshot`]. `cqs index --force --swap` builds a fresh DB beside
+//! the live one and renames it over with [`swap_in_db`], and `cqs replica`
+//! installs a synced primary the same way with [`install_replica`].
 
 use std::path::{Path, PathBuf};
 use std::time::{SystemTime, UNIX_EPOCH};
 
 use sqlx::SqlitePool;
 
-use super::helpers::StoreError;
+use super::helpers::{StoreError, CURRENT_SCHEMA_VERSION};
 use super::Store;
 
 impl<Mode> Store<Mode> {
@@ -64,6 +70,296 @@ impl<Mode> Store<Mode> {
     }
 }
 
+/// What a snapshot file records, read without opening it as a live store.
+///
+/// `Store::open*` migrates an older schema (or, read-only, refuses it), which
+/// is the wrong thing to do to a file that is only being vetted for
+/// [`restore_snapshot`].
+#[derive(Debug, Clone)]
+pub struct SnapshotInfo {
+    /// `metadata.schema_version`.
+    pub schema_version: i32,
+    /// Recorded embedding model, `None` when the key is absent or empty.
+    pub model_name: Option<String>,
+    /// Recorded embedding dimension, `None` when absent.
+    pub dim: Option<usize>,
+    /// Rows in `chunks`.
+    pub chunk_count: u64,
+}
+
+/// Vet a snapshot (a `cqs backup` / [`Store::snapshot_to`] output) before it
+/// replaces a live index.
+///
+/// Runs `PRAGMA quick_check` and reads the metadata over a read-only
+/// connection, so the file is never migrated or written. Errors:
+/// - `Corruption` if the B-tree check fails or the file has no metadata;
+/// - `SchemaNewerThanCq` if a newer cqs wrote it;
+/// - `MigrationNotSupported` if it is older than the oldest schema `migrate`
+///   can bring forward.
+///
+/// An older but migratable schema is accepted: the next read-write open
+/// migrates it with the usual backup. A snapshot carrying a `-wal` sidecar
+/// is refused — the main file alone would drop the committed frames, and
+/// [`restore_snapshot`] copies only the main file.
+pub fn inspect_snapshot(path: &Path) -> Result<SnapshotInfo, StoreError> {
+    let _span = tracing::info_span!("inspect_snapshot", path = %path.display()).entered();
+    if !path.is_file() {
+        return Err(StoreError::NotFound(format!(
+            "snapshot {} does not exist",
+            path.display()
+        )));
+    }
+    if sidecar_path(path, "-wal").exists() {
+        return Err(StoreError::Runtime(format!(
+            "snapshot {} has a -wal sidecar; checkpoint it first or take a fresh `cqs backup`",
+            path.display()
+        )));
+    }
+
+    let rt = tokio::runtime::Builder::new_current_thread()
+        .enable_all()
+        .build()?;
+    rt.block_on(async {
+        let opts = sqlx::sqlite::SqliteConnectOptions::new()
+            .filename(path)
+            .read_only(true);
+        let pool = sqlx::sqlite::SqlitePoolOptions::new()
+            .max_connections(1)
+            .connect_with(opts)
+            .await?;
+        let info = read_snapshot_info(&pool).await;
+        pool.close().await;
+        info
+    })
+}
+
+async fn read_snapshot_info(pool: &SqlitePool) -> Result<SnapshotInfo, StoreError> {
+    let (check,): (String,) = sqlx::query_as("PRAGMA quick_check(1)")
+        .fetch_one(pool)
+        .await?;
+    if check != "ok" {
+        return Err(StoreError::Corruption(check));
+    }
+
+    let rows: Vec<(String, String)> = match sqlx::query_as(
+        "SELECT key, value FROM metadata \
+         WHERE key IN ('schema_version', 'model_name', 'dimensions')",
+    )
+    .fetch_all(pool)
+    .await
+    {
+        Ok(rows) => rows,
+        Err(sqlx::Error::Database(e)) if e.message().contains("no such table") => {
+            return Err(StoreError::Corruption(
+                "snapshot has no metadata table — not a cqs index".to_string(),
+            ));
+        }
+        Err(e) => return Err(e.into()),
+    };
+    let get = |key: &str| {
+        rows.iter()
+            .find(|(k, _)| k == key)
+            .map(|(_, v)| v.as_str())
+            .filter(|v| !v.is_empty())
+    };
+
+    let schema_version: i32 = match get("schema_version") {
+        Some(v) => v.parse().map_err(|e| {
+            StoreError::Corruption(format!(
+                "schema_version '{}' is not a valid integer: {}",
+                v, e
+            ))
+        })?,
+        None => {
+            return Err(StoreError::Corruption(
+                "snapshot has no schema_version".to_string(),
+            ))
+        }
+    };
+    if schema_version > CURRENT_SCHEMA_VERSION {
+        return Err(StoreError::SchemaNewerThanCq(schema_version));
+    }
+    if schema_version < super::migrations::oldest_migratable_version() {
+        return Err(StoreError::MigrationNotSupported {
+            from: schema_version,
+            to: CURRENT_SCHEMA_VERSION,
+        });
+    }
+
+    let (chunk_count,): (i64,) = sqlx::query_as("SELECT COUNT(*) FROM chunks")
+        .fetch_one(pool)
+        .await?;
+    Ok(SnapshotInfo {
+        schema_version,
+        model_name: get("model_name").map(str::to_string),
+        dim: get("dimensions").and_then(|v| v.parse().ok()),
+        chunk_count: chunk_count.max(0) as u64,
+    })
+}
+
+/// Replace the live DB at `db_path` with `snapshot`.
+///
+/// The snapshot is first copied next to `db_path`, so a failed copy leaves
+/// the live index untouched; the copy then goes through [`swap_in_db`].
+///
+/// Same caller contract as [`restore_from_backup`]: every pool open against
+/// `db_path` must be closed first (stop the daemon, hold `index.lock`), and
+/// the snapshot should have passed [`inspect_snapshot`].
+pub fn restore_snapshot(db_path: &Path, snapshot: &Path) -> Result<(), StoreError> {
+    let _span = tracing::info_span!("restore_snapshot").entered();
+    let tmp_path = stage_copy(snapshot, db_path)?;
+    if let Err(e) = swap_in_db(db_path, &tmp_path) {
+        let _ = std::fs::remove_file(&tmp_path);
+        return Err(e);
+    }
+    tracing::info!(
+        db = %db_path.display(),
+        snapshot = %snapshot.display(),
+        "Restored DB from snapshot"
+    );
+    Ok(())
+}
+
+/// Rename the closed database `built` over `db_path`.
+///
+/// `built` must sit in the same directory (so the rename is atomic) and
+/// must have been closed cleanly: a non-empty `-wal` of its own is refused,
+/// since those frames would stay behind under the old name. The live
+/// `-wal`/`-shm` sidecars are removed first: they belong to the replaced
+/// file and SQLite would replay them against the new one. A kill between the two steps leaves the old main file
+/// without its WAL tail, which is being replaced anyway. On error `built`
+/// is left in place for the caller to clean up.
+///
+/// Readers notice the swap through the inode change ([`FileIdentity`]) and
+/// reopen; a writer holding a pool on the old file must be stopped or
+/// locked out by the caller.
+///
+/// [`FileIdentity`]: super::FileIdentity
+pub fn swap_in_db(db_path: &Path, built: &Path) -> Result<(), StoreError> {
+    let _span = tracing::info_span!("swap_in_db", db = %db_path.display()).entered();
+    let built_wal = sidecar_path(built, "-wal");
+    if std::fs::metadata(&built_wal).is_ok_and(|m| m.len() > 0) {
+        return Err(StoreError::Runtime(format!(
+            "{} still has un-checkpointed WAL frames; refusing to swap it in",
+            built.display()
+        )));
+    }
+    remove_triplet_sidecars(built);
+    for ext in ["-wal", "-shm"] {
+        if let Err(e) = std::fs::remove_file(sidecar_path(db_path, ext)) {
+            if e.kind() != std::io::ErrorKind::NotFound {
+                return Err(StoreError::Io(e));
+            }
+        }
+    }
+    crate::fs::atomic_replace(built, db_path)?;
+    Ok(())
+}
+
+/// Install a synced copy of a primary index (`cqs replica`) over `db_path`
+/// while readers keep serving.
+///
+/// `source` is a closed or quiescent copy of the primary's `index.db`, as
+/// left by `rsync` or an artifact download; its `-wal`, when present, is
+/// copied with it. The pair is staged next to `db_path`, checkpointed there
+/// so the WAL frames land in the main file, vetted with
+/// [`inspect_snapshot`], and renamed over the live DB with [`swap_in_db`].
+/// The source itself is never written.
+///
+/// Unlike [`restore_snapshot`], nothing has to be stopped: the rename gives
+/// the live path a new inode, the daemon's [`FileIdentity`] check reopens on
+/// its next query, and queries already running finish against the old file
+/// they hold open. Because the daemon opens read-only and cannot migrate, a
+/// source older than [`CURRENT_SCHEMA_VERSION`] is refused with
+/// `SchemaMismatch`. The caller holds `index.lock` so no local writer
+/// commits into the file being replaced.
+///
+/// `before_swap` runs on the vetted copy just before the rename: the place
+/// to refuse it (a model check) or to put files derived from it in place,
+/// so a reader that reopens on the new DB finds them already there. An
+/// error from it aborts the install.
+///
+/// [`FileIdentity`]: super::FileIdentity
+pub fn install_replica(
+    db_path: &Path,
+    source: &Path,
+    before_swap: impl FnOnce(&SnapshotInfo) -> Result<(), StoreError>,
+) -> Result<SnapshotInfo, StoreError> {
+    let _span = tracing::info_span!("install_replica", source = %source.display()).entered();
+    if !source.is_file() {
+        return Err(StoreError::NotFound(format!(
+            "replica {} does not exist",
+            source.display()
+        )));
+    }
+    let staged = stage_copy(source, db_path)?;
+    let installed = (|| -> Result<SnapshotInfo, StoreError> {
+        let source_wal = sidecar_path(source, "-wal");
+        if std::fs::metadata(&source_wal).is_ok_and(|m| m.len() > 0) {
+            std::fs::copy(&source_wal, sidecar_path(&staged, "-wal"))?;
+            checkpoint_staged(&staged)?;
+        }
+        remove_triplet_sidecars(&staged);
+
+        let info = inspect_snapshot(&staged)?;
+        if info.schema_version < CURRENT_SCHEMA_VERSION {
+            return Err(StoreError::SchemaMismatch {
+                db_path: source.display().to_string(),
+                found: info.schema_version,
+                expected: CURRENT_SCHEMA_VERSION,
+            });
+        }
+        before_swap(&info)?;
+        swap_in_db(db_path, &staged)?;
+        Ok(info)
+    })();
+    match installed {
+        Ok(info) => {
+            tracing::info!(
+                db = %db_path.display(),
+                source = %source.display(),
+                chunks = info.chunk_count,
+                "Installed replica"
+            );
+            Ok(info)
+        }
+        Err(e) => {
+            remove_triplet(&staged);
+            Err(e)
+        }
+    }
+}
+
+/// Fold the `-wal` copied beside a staged replica into its main file.
+///
+/// A busy result can only mean another process opened the private staging
+/// file, so it is an error rather than something to wait out.
+fn checkpoint_staged(staged: &Path) -> Result<(), StoreError> {
+    let rt = tokio::runtime::Builder::new_current_thread()
+        .enable_all()
+        .build()?;
+    rt.block_on(async {
+        let opts = sqlx::sqlite::SqliteConnectOptions::new().filename(staged);
+        let pool = sqlx::sqlite::SqlitePoolOptions::new()
+            .max_connections(1)
+            .connect_with(opts)
+            .await?;
+        let result: Result<(i64, i64, i64), sqlx::Error> =
+            sqlx::query_as("PRAGMA wal_checkpoint(TRUNCATE)")
+                .fetch_one(&pool)
+                .await;
+        pool.close().await;
+        let (busy, _, _) = result?;
+        if busy != 0 {
+            return Err(StoreError::Runtime(format!(
+                "could not checkpoint the WAL copied with {}",
+                staged.display()
+            )));
+        }
+        Ok(())
+    })
+}
+
 /// Env var that controls whether a failed migration-time DB backup is a hard
 /// error (default) or a warn-and-continue.
 ///
@@ -460,6 +756,17 @@ fn copy_triplet(src: &Path, dst: &Path) -> Result<(), StoreError> {
 /// Atomically copy `src` -> `dst` by staging to a same-directory temp file
 /// then handing off to `atomic_replace` (fsync temp + rename + fsync parent).
 fn copy_file_atomic(src: &Path, dst: &Path) -> Result<(), StoreError> {
+    let tmp_path = stage_copy(src, dst)?;
+    if let Err(e) = crate::fs::atomic_replace(&tmp_path, dst) {
+        let _ = std::fs::remove_file(&tmp_path);
+        return Err(StoreError::Io(e));
+    }
+    Ok(())
+}
+
+/// Copy `src` to a fresh temp file next to `dst`, ready for `atomic_replace`.
+/// The temp is removed again if the copy fails.
+fn stage_copy(src: &Path, dst: &Path) -> Result<PathBuf, StoreError> {
     let dir = dst.parent().unwrap_or(Path::new("."));
     let name = dst
         .file_name()
@@ -473,18 +780,18 @@ fn copy_file_atomic(src: &Path, dst: &Path) -> Result<(), StoreError> {
         let _ = std::fs::remove_file(&tmp_path);
         return Err(StoreError::Io(e));
     }
-
-    if let Err(e) = crate::fs::atomic_replace(&tmp_path, dst) {
-        let _ = std::fs::remove_file(&tmp_path);
-        return Err(StoreError::Io(e));
-    }
-    Ok(())
+    Ok(tmp_path)
 }
 
 /// Best-effort removal of a `.db` backup and its `-wal`/`-shm` sidecars.
 /// Used when a partial backup failed and we want to clean up before returning.
 fn remove_triplet(db: &Path) {
     let _ = std::fs::remove_file(db);
+    remove_triplet_sidecars(db);
+}
+
+/// Best-effort removal of just the `-wal`/`-shm` sidecars of `db`.
+fn remove_triplet_sidecars(db: &Path) {
     for ext in ["-wal", "-shm"] {
         let _ = std::fs::remove_file(sidecar_path(db, ext));
     }
@@ -538,6 +845,167 @@ mod tests {
         assert_eq!(reopened.dim(), 8, "snapshot must preserve the source dim");
     }
 
+    /// `inspect_snapshot` reports a `cqs backup` output's schema, model and
+    /// size without migrating it, and refuses a snapshot from a newer cqs.
+    #[test]
+    fn inspect_snapshot_reads_metadata_and_rejects_newer_schema() {
+        let dir = tempfile::tempdir().unwrap();
+        let store = Store::open(&dir.path().join("index.db")).expect("open store");
+        store
+            .init(&ModelInfo::new("test/model", 8))
+            .expect("init store");
+        let snapshot = dir.path().join("snapshot.db");
+        store.snapshot_to(&snapshot).expect("snapshot must succeed");
+
+        let info = inspect_snapshot(&snapshot).expect("fresh snapshot must pass");
+        assert_eq!(info.schema_version, CURRENT_SCHEMA_VERSION);
+        assert_eq!(info.model_name.as_deref(), Some("test/model"));
+        assert_eq!(info.dim, Some(8));
+        assert_eq!(info.chunk_count, 0);
+
+        let newer = (CURRENT_SCHEMA_VERSION + 1).to_string();
+        let reopened = Store::open(&snapshot).expect("snapshot must re-open");
+        reopened
+            .set_metadata_opt("schema_version", Some(&newer))
+            .unwrap();
+        reopened.close().unwrap();
+        assert_matches!(
+            inspect_snapshot(&snapshot),
+            Err(StoreError::SchemaNewerThanCq(_))
+        );
+        assert_matches!(
+            inspect_snapshot(&dir.path().join("missing.db")),
+            Err(StoreError::NotFound(_))
+        );
+    }
+
+    /// `restore_snapshot` swaps the snapshot in and drops the live sidecars,
+    /// which belong to the replaced file.
+    #[test]
+    fn restore_snapshot_replaces_main_and_drops_live_sidecars() {
+        let dir = tempfile::tempdir().unwrap();
+        let snapshot = dir.path().join("snapshot.db");
+        std::fs::write(&snapshot, b"snapshot-main").unwrap();
+
+        let live = dir.path().join("index.db");
+        std::fs::write(&live, b"live-main").unwrap();
+        std::fs::write(sidecar_path(&live, "-wal"), b"live-wal").unwrap();
+        std::fs::write(sidecar_path(&live, "-shm"), b"live-shm").unwrap();
+
+        restore_snapshot(&live, &snapshot).unwrap();
+
+        assert_eq!(std::fs::read(&live).unwrap(), b"snapshot-main");
+        assert!(!sidecar_path(&live, "-wal").exists());
+        assert!(!sidecar_path(&live, "-shm").exists());
+        assert_eq!(
+            std::fs::read(&snapshot).unwrap(),
+            b"snapshot-main",
+            "the snapshot itself must be left in place"
+        );
+    }
+
+    /// `swap_in_db` renames a closed build over the live DB: the path
+    /// reports a new identity (what watch and the daemon key their reopen
+    /// on) and opens as the new database.
+    #[test]
+    fn swap_in_db_replaces_live_db_and_changes_identity() {
+        let dir = tempfile::tempdir().unwrap();
+        let live = dir.path().join("index.db");
+        let old = Store::open(&live).expect("open live");
+        old.init(&ModelInfo::new("old/model", 8)).unwrap();
+        old.close().unwrap();
+        let before = super::super::FileIdentity::from_path(&live).unwrap();
+
+        let built = dir.path().join("index.db.next");
+        let new = Store::open(&built).expect("open build");
+        new.init(&ModelInfo::new("new/model", 16)).unwrap();
+        new.close().unwrap();
+        std::fs::write(sidecar_path(&live, "-wal"), b"stale").unwrap();
+
+        swap_in_db(&live, &built).unwrap();
+
+        assert!(!built.exists(), "the build file is renamed, not copied");
+        assert!(!sidecar_path(&live, "-wal").exists());
+        assert_ne!(
+            super::super::FileIdentity::from_path(&live).unwrap(),
+            before
+        );
+        let reopened = Store::open(&live).unwrap();
+        assert_eq!(reopened.dim(), 16);
+    }
+
+    /// `install_replica` folds a synced primary's `-wal` into the installed
+    /// copy, changes the live identity, and refuses a schema the read-only
+    /// daemon could not open, leaving the live DB alone.
+    #[test]
+    fn install_replica_checkpoints_wal_and_refuses_older_schema() {
+        let dir = tempfile::tempdir().unwrap();
+        let live = dir.path().join("index.db");
+        let old = Store::open(&live).expect("open live");
+        old.init(&ModelInfo::new("old/model", 8)).unwrap();
+        old.close().unwrap();
+        let before = super::super::FileIdentity::from_path(&live).unwrap();
+
+        // A primary that is still open: its last commit is only in the WAL.
+        let primary_dir = dir.path().join("primary");
+        std::fs::create_dir(&primary_dir).unwrap();
+        let primary_db = primary_dir.join("index.db");
+        let primary = Store::open(&primary_db).expect("open primary");
+        primary.init(&ModelInfo::new("new/model", 8)).unwrap();
+        primary
+            .set_metadata_opt("model_name", Some("new/model-v2"))
+            .unwrap();
+        let synced = dir.path().join("synced.db");
+        std::fs::copy(&primary_db, &synced).unwrap();
+        std::fs::copy(
+            sidecar_path(&primary_db, "-wal"),
+            sidecar_path(&synced, "-wal"),
+        )
+        .unwrap();
+        drop(primary);
+
+        let info = install_replica(&live, &synced, |_| Ok(())).expect("replica installs");
+        assert_eq!(info.model_name.as_deref(), Some("new/model-v2"));
+        assert!(!sidecar_path(&live, "-wal").exists());
+        assert!(
+            sidecar_path(&synced, "-wal").exists(),
+            "source is untouched"
+        );
+        assert_ne!(
+            super::super::FileIdentity::from_path(&live).unwrap(),
+            before
+        );
+
+        let stale = Store::open(&synced).expect("open synced copy");
+        let older = (CURRENT_SCHEMA_VERSION - 1).to_string();
+        stale
+            .set_metadata_opt("schema_version", Some(&older))
+            .unwrap();
+        stale.close().unwrap();
+        let installed = std::fs::read(&live).unwrap();
+        let mut vetted = false;
+        assert_matches!(
+            install_replica(&live, &synced, |_| {
+                vetted = true;
+                Ok(())
+            }),
+            Err(StoreError::SchemaMismatch { .. })
+        );
+        assert!(
+            !vetted,
+            "before_swap only sees a copy that passed the checks"
+        );
+        assert_eq!(std::fs::read(&live).unwrap(), installed);
+        let leftovers = std::fs::read_dir(dir.path())
+            .unwrap()
+            .filter(|e| {
+                let name = e.as_ref().unwrap().file_name();
+                name.to_string_lossy().ends_with(".tmp")
+            })
+            .count();
+        assert_eq!(leftovers, 0, "a refused replica leaves no staging file");
+    }
+
     /// `keep_backups()` returns the compiled default when unset, the env
     /// value when set (including `0`, which is a valid "prune all" choice),
     /// and the default on a garbage value.
@@ -3,11 +3,12 @@
 //! Wraps multiple `Store` instances and merges their call graphs
 //! for cross-boundary caller/callee/test queries.
 
-use std::collections::HashMap;
+use std::collections::{HashMap, HashSet};
 use std::sync::Arc;
 
 use tokio::runtime::Runtime;
 
+use super::{DeadFunction, LowConfidenceLiveInfo};
 use crate::store::helpers::{CallGraph, CallerInfo, ChunkSummary, StoreError};
 use crate::store::{DataVersionProbe, FileIdentity};
 use crate::Store;
@@ -170,6 +171,21 @@ pub struct CrossProjectTestChunk {
     pub chunk: ChunkSummary,
 }
 
+/// Dead-code populations across every project of a context — the shape
+/// `dead_core` assembles from one store, with the edges of the other projects
+/// taken into account.
+#[derive(Debug, Default)]
+pub struct CrossProjectDeadCode {
+    /// Zero-caller and heuristic-only functions (as [`Store::find_dead_code`]
+    /// unioned with [`Store::find_low_confidence_live_functions`]).
+    pub confident: Vec<DeadFunction>,
+    /// Public functions with no callers in any project.
+    pub possibly_dead_pub: Vec<DeadFunction>,
+    /// Heuristic/candidate-edge breakdown per callee name, summed over every
+    /// project (as [`Store::find_low_confidence_live_names`]).
+    pub low_confidence_live: HashMap<String, LowConfidenceLiveInfo>,
+}
+
 /// Context holding multiple project stores for cross-project graph queries.
 ///
 /// Lazily loads and caches call graphs per store on first access.
@@ -238,7 +254,12 @@ impl CrossProjectContext {
         // (it backs the BFS traversals), so it keeps the 64MB-mmap
         // `open_readonly`. Reference stores below use `open_readonly_small`.
         let local_store = Store::open_readonly(&db_path)?;
+        let slot_dir = db_path.parent().map(std::path::Path::to_path_buf);
         let mut stores = vec![NamedStore::new("local".to_string(), local_store, db_path)];
+        // A sharded local index contributes every shard's graph too.
+        if let Some(slot_dir) = slot_dir {
+            stores.extend(crate::shard::open_shard_stores(&slot_dir));
+        }
 
         for ref_cfg in &config.references {
             let db_path = ref_cfg.path.join(crate::INDEX_DB_FILENAME);
@@ -440,6 +461,56 @@ impl CrossProjectContext {
         Ok(all_tests)
     }
 
+    /// Dead code across all projects. Each store reports the functions no
+    /// edge *in that store* reaches, so a function called only from another
+    /// project (another shard of a sharded index) would be a false positive:
+    /// candidates that a trusted edge in any project reaches are dropped, and
+    /// the heuristic/candidate breakdowns are summed so a function reached
+    /// only heuristically from elsewhere still classifies
+    /// `low-confidence-live`.
+    pub fn find_dead_code_cross(
+        &mut self,
+        include_pub: bool,
+    ) -> Result<CrossProjectDeadCode, StoreError> {
+        let _span = tracing::info_span!(
+            "find_dead_code_cross",
+            projects = self.stores.len(),
+            include_pub
+        )
+        .entered();
+
+        self.ensure_all_graphs()?;
+        let trusted: HashSet<&str> = self
+            .graphs
+            .values()
+            .flat_map(|graph| graph.edges.iter())
+            .filter(|(_, meta)| meta.edge_kind.is_trusted())
+            .map(|((_, callee), _)| callee.as_ref())
+            .collect();
+        let live = |d: &DeadFunction| !trusted.contains(d.chunk.name.as_str());
+
+        let mut out = CrossProjectDeadCode::default();
+        for ns in &self.stores {
+            let (confident, possibly_pub) = ns.store.find_dead_code(include_pub)?;
+            let (low_confident, low_possibly_pub) =
+                ns.store.find_low_confidence_live_functions(include_pub)?;
+            out.confident
+                .extend(confident.into_iter().chain(low_confident).filter(live));
+            out.possibly_dead_pub.extend(
+                possibly_pub
+                    .into_iter()
+                    .chain(low_possibly_pub)
+                    .filter(live),
+            );
+            for (name, info) in ns.store.find_low_confidence_live_names()? {
+                if !trusted.contains(name.as_str()) {
+                    merge_low_confidence(out.low_confidence_live.entry(name).or_default(), info);
+                }
+            }
+        }
+        Ok(out)
+    }
+
     /// Build a merged call graph from all projects.
     ///
     /// Unions the forward and reverse adjacency lists from every project's
@@ -495,6 +566,24 @@ impl CrossProjectContext {
     }
 }
 
+/// Fold one project's heuristic/candidate breakdown for a callee into the
+/// running total, keeping the per-kind counts sorted for stable reasons.
+fn merge_low_confidence(into: &mut LowConfidenceLiveInfo, from: LowConfidenceLiveInfo) {
+    fn add(counts: &mut Vec<(String, u64)>, more: Vec<(String, u64)>) {
+        for (kind, n) in more {
+            match counts.iter_mut().find(|(k, _)| *k == kind) {
+                Some((_, total)) => *total += n,
+                None => counts.push((kind, n)),
+            }
+        }
+        counts.sort();
+    }
+    into.total += from.total;
+    into.candidate_total += from.candidate_total;
+    add(&mut into.kind_counts, from.kind_counts);
+    add(&mut into.candidate_counts, from.candidate_counts);
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -652,7 +741,7 @@ mod tests {
         assert_eq!(
             callers[0].caller.edge_kind,
             CallEdgeKind::Call,
-            "call (rank 0) outranks doc_reference (rank 4)"
+            "call (rank 0) outranks doc_reference (rank 5)"
         );
     }
 
@@ -959,4 +1048,119 @@ mod tests {
         let ctx = CrossProjectContext::new(vec![]);
         assert_eq!(ctx.fingerprint(), None);
     }
+
+    /// A NamedStore holding `functions` (`(name, file)`) plus the
+    /// `(caller, callee, kind)` edges, for the dead-code queries.
+    fn make_named_store_with_functions(
+        name: &str,
+        functions: &[(&str, &str)],
+        edges: &[(&str, &str, crate::parser::CallEdgeKind)],
+    ) -> NamedStore {
+        let dir = tempfile::tempdir().unwrap();
+        let db_path = dir.path().join(crate::INDEX_DB_FILENAME);
+        let model_info = crate::store::helpers::ModelInfo::default();
+        let emb = crate::embedder::Embedding::new(vec![0.0; crate::EMBEDDING_DIM]);
+
+        let store = Store::<crate::store::ReadOnly>::open_readonly_after_init(&db_path, |store| {
+            store.init(&model_info)?;
+            for (function, file) in functions {
+                let chunk = crate::store::test_utils::make_chunk(function, file);
+                store.upsert_chunk(&chunk, &emb, Some(1))?;
+            }
+            for (caller, callee, kind) in edges {
+                store.rt.block_on(async {
+                    sqlx::query(
+                        "INSERT INTO function_calls (file, caller_name, callee_name, caller_line, call_line, edge_kind)
+                         VALUES ('src/other.rs', ?1, ?2, 1, 2, ?3)",
+                    )
+                    .bind(caller)
+                    .bind(callee)
+                    .bind(kind.as_str())
+                    .execute(&store.pool)
+                    .await
+                })?;
+            }
+            Ok(())
+        })
+        .unwrap();
+
+        let _keep = dir.keep();
+        NamedStore::new(name.to_string(), store, db_path)
+    }
+
+    /// Each shard's store sees only its own edges: `charge` is called from
+    /// the orders shard, so it is live; `hooked` is reached only through a
+    /// fn pointer there, so it stays a candidate with that evidence summed
+    /// in; `orphan` is dead everywhere.
+    #[test]
+    fn dead_code_cross_drops_functions_called_from_another_store() {
+        use crate::parser::CallEdgeKind;
+        let root = make_named_store_with_functions(
+            "root",
+            &[
+                ("charge", "src/billing.rs"),
+                ("hooked", "src/hook.rs"),
+                ("orphan", "src/util.rs"),
+            ],
+            &[],
+        );
+        let orders = make_named_store_with_functions(
+            "orders",
+            &[("refund", "orders/refund.rs")],
+            &[
+                ("serve", "refund", CallEdgeKind::Call),
+                ("refund", "charge", CallEdgeKind::Call),
+                ("refund", "hooked", CallEdgeKind::FnPointer),
+            ],
+        );
+
+        let alone = root.store.find_dead_code(true).unwrap().0;
+        assert!(alone.iter().any(|d| d.chunk.name == "charge"));
+
+        let mut ctx = CrossProjectContext::new(vec![root, orders]);
+        let dead = ctx.find_dead_code_cross(true).unwrap();
+        let mut names: Vec<&str> = dead
+            .confident
+            .iter()
+            .chain(&dead.possibly_dead_pub)
+            .map(|d| d.chunk.name.as_str())
+            .collect();
+        names.sort_unstable();
+        assert_eq!(names, vec!["hooked", "orphan"]);
+        let hooked = &dead.low_confidence_live["hooked"];
+        assert_eq!(hooked.total, 1);
+        assert_eq!(hooked.kind_counts, vec![("fn_pointer".to_string(), 1)]);
+        assert!(!dead.low_confidence_live.contains_key("charge"));
+    }
+
+    #[test]
+    fn merge_low_confidence_sums_counts_per_kind() {
+        let mut into = LowConfidenceLiveInfo {
+            total: 2,
+            kind_counts: vec![("macro_heuristic".into(), 2)],
+            ..Default::default()
+        };
+        merge_low_confidence(
+            &mut into,
+            LowConfidenceLiveInfo {
+                total: 3,
+                kind_counts: vec![("fn_pointer".into(), 1), ("macro_heuristic".into(), 2)],
+                candidate_total: 1,
+                candidate_counts: vec![("serde_container".into(), 1)],
+            },
+        );
+        assert_eq!(into.total, 5);
+        assert_eq!(
+            into.kind_counts,
+            vec![
+                ("fn_pointer".to_string(), 1),
+                ("macro_heuristic".to_string(), 4)
+            ]
+        );
+        assert_eq!(into.candidate_total, 1);
+        assert_eq!(
+            into.candidate_counts,
+            vec![("serde_container".to_string(), 1)]
+        );
+    }
 }
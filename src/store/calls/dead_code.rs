@@ -541,8 +541,8 @@ impl<Mode> Store<Mode> {
         let mut last_rowid: i64 = 0;
         let mut remaining = invoked.len();
         'scan: loop {
-            let rows: Vec<(i64, String, String)> = sqlx::query_as(
-                "SELECT rowid, id, content FROM chunks
+            let rows: Vec<(i64, String, Vec<u8>)> = sqlx::query_as(
+                "SELECT rowid, id, CAST(content AS BLOB) FROM chunks
                  WHERE rowid > ?1
                  ORDER BY rowid
                  LIMIT ?2",
@@ -556,10 +556,11 @@ impl<Mode> Store<Mode> {
                 break;
             }
 
-            for (rowid, chunk_id, content) in &rows {
-                last_rowid = *rowid;
+            for (rowid, chunk_id, stored) in rows {
+                last_rowid = rowid;
+                let content = crate::store::compression::decode_or_empty(stored, &chunk_id);
                 for (k, (token, def_id)) in invoked_tokens.iter().enumerate() {
-                    if invoked[k] || chunk_id == def_id {
+                    if invoked[k] || &chunk_id == def_id {
                         continue;
                     }
                     if content.contains(token.as_str()) {
@@ -708,9 +709,11 @@ impl<Mode> Store<Mode> {
         const PAGE: i64 = 2048;
         let mut last_rowid: i64 = 0;
         loop {
-            let rows: Vec<(i64, String)> = sqlx::query_as(
-                "SELECT rowid, content FROM chunks
-                 WHERE rowid > ?1 AND content LIKE '%serde%'
+            // Compressed rows can't be prefiltered by `LIKE`; they're
+            // decoded and left to the regex.
+            let rows: Vec<(i64, String, Vec<u8>)> = sqlx::query_as(
+                "SELECT rowid, id, CAST(content AS BLOB) FROM chunks
+                 WHERE rowid > ?1 AND (typeof(content) = 'blob' OR content LIKE '%serde%')
                  ORDER BY rowid
                  LIMIT ?2",
             )
@@ -723,9 +726,10 @@ impl<Mode> Store<Mode> {
                 break;
             }
 
-            for (rowid, content) in &rows {
-                last_rowid = *rowid;
-                for cap in SERDE_CALLBACK_RE.captures_iter(content) {
+            for (rowid, chunk_id, stored) in rows {
+                last_rowid = rowid;
+                let content = crate::store::compression::decode_or_empty(stored, &chunk_id);
+                for cap in SERDE_CALLBACK_RE.captures_iter(&content) {
                     let path = &cap[1];
                     // Terminal path segment: `crate::a::b::f` → `f`.
                     let terminal = path.rsplit("::").next().unwrap_or(path);
@@ -841,7 +845,7 @@ impl<Mode> Store<Mode> {
         for batch in candidate_ids.chunks(batch_size) {
             let placeholders = super::super::helpers::make_placeholders(batch.len());
             let sql = format!(
-                "SELECT id, content, doc FROM chunks WHERE id IN ({})",
+                "SELECT id, CAST(content AS BLOB), doc FROM chunks WHERE id IN ({})",
                 placeholders
             );
             let mut q = sqlx::query(sqlx::AssertSqlSafe(sql.as_str()));
@@ -851,7 +855,7 @@ impl<Mode> Store<Mode> {
             let rows: Vec<_> = q.fetch_all(&self.pool).await?;
             for row in rows {
                 let id: String = row.get(0);
-                let content: String = row.get(1);
+                let content = crate::store::compression::decode_or_empty(row.get(1), &id);
                 let doc: Option<String> = row.get(2);
                 content_map.insert(id, (content, doc));
             }
@@ -259,6 +259,19 @@ fn build_test_content_markers() -> Vec<&'static str> {
     }
 }
 
+/// Test content markers the test-chunk SQL filter matches with `LIKE`.
+///
+/// Attribute-shaped Rust markers (`#[…]`) are skipped: they match in comments
+/// and string literals (the comment-spoof against the dead sweep) and are
+/// redundant with the `chunk_type = 'test'` tag. Chunks carrying one of these
+/// are never compressed (`store::compression`), so the `LIKE` still sees them.
+pub(crate) fn test_content_like_markers() -> Vec<&'static str> {
+    build_test_content_markers()
+        .into_iter()
+        .filter(|marker| !marker.starts_with("#["))
+        .collect()
+}
+
 /// Build unified test path patterns from all enabled language definitions.
 /// Falls back to `FALLBACK_TEST_PATH_PATTERNS` if no language provides any.
 fn build_test_path_patterns() -> Vec<&'static str> {
@@ -325,14 +338,7 @@ fn build_test_chunk_filter() -> String {
             clauses.push(format!("name LIKE '{pat}'"));
         }
     }
-    for marker in build_test_content_markers() {
-        // Skip attribute-shaped Rust markers (`#[…]`): they match in comments and
-        // string literals (the comment-spoof against the dead sweep) and are
-        // redundant with the `chunk_type = 'test'` tag above. Genuine content
-        // markers stay.
-        if marker.starts_with("#[") {
-            continue;
-        }
+    for marker in test_content_like_markers() {
         clauses.push(format!("content LIKE '%{marker}%'"));
     }
     for pat in build_test_path_patterns() {
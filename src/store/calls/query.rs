@@ -249,7 +249,7 @@ impl<Mode> Store<Mode> {
             // The GROUP BY (file, caller_name, caller_line) already yields one
             // row per call site, collapsing a co-located bare code edge and
             // exact doc edge into a single min-trust-rank row — so the Call
-            // edge (rank 0) wins over the doc edge (rank 4) and the site is
+            // edge (rank 0) wins over the doc edge (rank 5) and the site is
             // attributed via its enclosing type. No further de-dupe needed.
             for (file, name, line, kind, _rank, parent_type, callee) in rows {
                 // An exact-qualified edge names the receiver — proven self.
@@ -718,4 +718,43 @@ impl<Mode> Store<Mode> {
             Ok(out)
         })
     }
+
+    /// Real-caller call counts per `(caller file, callee name)` over the whole
+    /// `function_calls` table. Input to the module dependency graph, which
+    /// resolves each callee name to its defining file.
+    pub fn call_counts_by_file(&self) -> Result<Vec<(String, String, u64)>, StoreError> {
+        let _span = tracing::debug_span!("call_counts_by_file").entered();
+        let real_callers = crate::parser::CallEdgeKind::real_caller_kinds_sql();
+        self.rt.block_on(async {
+            let sql = format!(
+                "SELECT file, callee_name, COUNT(*) FROM function_calls
+                 WHERE edge_kind IN ({real_callers})
+                 GROUP BY file, callee_name"
+            );
+            let rows: Vec<(String, String, i64)> =
+                sqlx::query_as(sqlx::AssertSqlSafe(sql.as_str()))
+                    .fetch_all(&self.pool)
+                    .await?;
+            Ok(rows
+                .into_iter()
+                .map(|(file, callee, n)| (file, callee, n.max(0) as u64))
+                .collect())
+        })
+    }
+
+    /// Distinct `(name, origin)` pairs for every callable chunk — where each
+    /// function/method name is defined.
+    pub fn callable_definition_origins(&self) -> Result<Vec<(String, String)>, StoreError> {
+        let _span = tracing::debug_span!("callable_definition_origins").entered();
+        let callable = crate::parser::ChunkType::callable_sql_list();
+        self.rt.block_on(async {
+            let sql = format!(
+                "SELECT DISTINCT name, origin FROM chunks WHERE chunk_type IN ({callable})"
+            );
+            let rows: Vec<(String, String)> = sqlx::query_as(sqlx::AssertSqlSafe(sql.as_str()))
+                .fetch_all(&self.pool)
+                .await?;
+            Ok(rows)
+        })
+    }
 }
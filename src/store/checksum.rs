@@ -0,0 +1,226 @@
+//! Chunk content verification against `content_hash`.
+//!
+//! Every chunk row stores the blake3 hex of its `content`, computed at parse
+//! time. A row whose text no longer hashes to it has been damaged after the
+//! write — bitrot on the disk or a network filesystem under `.cqs/`, or a
+//! torn page — and SQLite's own checks won't notice, because the page is
+//! structurally fine.
+//!
+//! [`verify_on_read`] runs on each row [`ChunkRow::from_row`] hydrates.
+//! Under the default `verify_reads = "sample"` it rehashes one row in
+//! [`VERIFY_SAMPLE_EVERY`]; `"full"` rehashes every row, `"off"` none (see
+//! [`super::pragmas`]). A mismatch is logged and counted
+//! (`cqs_chunk_checksum_mismatches_total`) — the read still returns, since
+//! the text is usually still usable and failing a search over it would be
+//! worse. [`Store::verify_chunk_content`] checks every row for `cqs doctor`.
+
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::OnceLock;
+
+use serde::Serialize;
+
+use super::helpers::{ChunkRow, StoreError};
+use super::{pragmas, Store};
+use crate::config::VerifyReads;
+
+/// In `sample` mode, one read in this many is rehashed.
+pub const VERIFY_SAMPLE_EVERY: u64 = 64;
+
+/// Rows per page of the full scan.
+const SCAN_BATCH: i64 = 1000;
+
+/// A chunk whose stored text no longer matches its hash.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct CorruptChunk {
+    pub id: String,
+    pub origin: String,
+}
+
+/// Result of [`Store::verify_chunk_content`].
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct ChecksumReport {
+    /// Rows rehashed.
+    pub checked: u64,
+    /// Rows whose text didn't match.
+    pub corrupt: Vec<CorruptChunk>,
+}
+
+/// Whether `content` still hashes to the stored hex `hash`.
+pub(crate) fn content_matches(content: &str, hash: &str) -> bool {
+    blake3::hash(content.as_bytes()).to_hex().as_str() == hash
+}
+
+/// The process-wide mode, resolved once; reads are too hot for an env
+/// lookup each.
+fn mode() -> VerifyReads {
+    static MODE: OnceLock<VerifyReads> = OnceLock::new();
+    *MODE.get_or_init(pragmas::verify_reads)
+}
+
+/// Check a freshly read row under the configured mode.
+pub(crate) fn verify_on_read(row: &ChunkRow) {
+    static READS: AtomicU64 = AtomicU64::new(0);
+    verify_with(mode(), &READS, row);
+}
+
+/// Split from [`verify_on_read`] so tests pick the mode and own the sample
+/// counter. Returns whether the row was rehashed and found damaged.
+fn verify_with(mode: VerifyReads, reads: &AtomicU64, row: &ChunkRow) -> bool {
+    let check = match mode {
+        VerifyReads::Off => false,
+        VerifyReads::Sample => reads.fetch_add(1, Ordering::Relaxed) % VERIFY_SAMPLE_EVERY == 0,
+        VerifyReads::Full => true,
+    };
+    // Rows hydrated without a hash have nothing to compare against.
+    if !check || row.content_hash.is_empty() || content_matches(&row.content, &row.content_hash) {
+        return false;
+    }
+    crate::metrics::CHUNK_CHECKSUM_MISMATCHES.inc();
+    tracing::warn!(
+        chunk = %row.id,
+        origin = %row.origin,
+        "Chunk content does not match its content_hash; the index may be corrupt \
+         (run `cqs doctor`, then `cqs index --force`)"
+    );
+    true
+}
+
+impl<Mode> Store<Mode> {
+    /// Rehash every chunk's stored text, regardless of `verify_reads`.
+    /// Pages through `chunks` by rowid so memory stays flat on large
+    /// indexes.
+    pub fn verify_chunk_content(&self) -> Result<ChecksumReport, StoreError> {
+        let _span = tracing::info_span!("verify_chunk_content").entered();
+        self.rt.block_on(async {
+            let mut report = ChecksumReport::default();
+            let mut after = 0i64;
+            loop {
+                let rows: Vec<(i64, String, String, Vec<u8>, String)> = sqlx::query_as(
+                    "SELECT rowid, id, origin, CAST(content AS BLOB), content_hash FROM chunks \
+                     WHERE rowid > ?1 ORDER BY rowid LIMIT ?2",
+                )
+                .bind(after)
+                .bind(SCAN_BATCH)
+                .fetch_all(&self.pool)
+                .await?;
+                let Some(last) = rows.last() else { break };
+                after = last.0;
+                for (_, id, origin, stored, hash) in rows {
+                    report.checked += 1;
+                    // A frame that no longer decodes is as damaged as text
+                    // that no longer hashes.
+                    let intact = super::compression::decode(stored)
+                        .is_ok_and(|content| content_matches(&content, &hash));
+                    if !intact {
+                        report.corrupt.push(CorruptChunk { id, origin });
+                    }
+                }
+            }
+            if !report.corrupt.is_empty() {
+                crate::metrics::CHUNK_CHECKSUM_MISMATCHES.add(report.corrupt.len() as u64);
+                tracing::warn!(
+                    checked = report.checked,
+                    corrupt = report.corrupt.len(),
+                    "Chunk content verification found mismatches"
+                );
+            }
+            Ok(report)
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::store::test_utils::make_chunk;
+    use crate::test_helpers::{mock_embedding, setup_store};
+
+    fn corrupt(store: &Store, id: &str) {
+        store.rt.block_on(async {
+            sqlx::query("UPDATE chunks SET content = content || ' ' WHERE id = ?1")
+                .bind(id)
+                .execute(&store.pool)
+                .await
+                .unwrap();
+        });
+    }
+
+    fn row(content: &str, hash: &str) -> ChunkRow {
+        ChunkRow {
+            id: "a.rs:1:00000000".to_string(),
+            origin: "a.rs".to_string(),
+            language: "rust".to_string(),
+            chunk_type: "function".to_string(),
+            name: "a".to_string(),
+            signature: String::new(),
+            content: content.to_string(),
+            doc: None,
+            line_start: 1,
+            line_end: 1,
+            content_hash: hash.to_string(),
+            window_idx: None,
+            parent_id: None,
+            parent_type_name: None,
+            parser_version: 0,
+            vendored: false,
+            deprecated: false,
+            boilerplate: None,
+        }
+    }
+
+    #[test]
+    fn read_check_flags_only_damaged_rows_it_samples() {
+        let reads = AtomicU64::new(0);
+        let good = blake3::hash(b"fn a() {}").to_hex().to_string();
+        let damaged = row("fn a() {!", &good);
+        assert!(!verify_with(
+            VerifyReads::Full,
+            &reads,
+            &row("fn a() {}", &good)
+        ));
+        assert!(verify_with(VerifyReads::Full, &reads, &damaged));
+        assert!(!verify_with(VerifyReads::Off, &reads, &damaged));
+        // No stored hash, nothing to compare.
+        assert!(!verify_with(
+            VerifyReads::Full,
+            &reads,
+            &row("fn a() {!", "")
+        ));
+
+        let flagged = (0..VERIFY_SAMPLE_EVERY)
+            .filter(|_| verify_with(VerifyReads::Sample, &reads, &damaged))
+            .count();
+        assert_eq!(flagged, 1, "sample mode checks one read per window");
+    }
+
+    #[test]
+    fn full_scan_reports_corrupt_chunks() {
+        let (store, _dir) = setup_store();
+        let chunks: Vec<_> = ["one", "two", "three"]
+            .iter()
+            .map(|name| {
+                (
+                    make_chunk(name, &format!("src/{name}.rs")),
+                    mock_embedding(1.0),
+                )
+            })
+            .collect();
+        store.upsert_chunks_batch(&chunks, Some(100)).unwrap();
+
+        let clean = store.verify_chunk_content().unwrap();
+        assert_eq!(clean.checked, 3);
+        assert!(clean.corrupt.is_empty());
+
+        let damaged = &chunks[1].0;
+        corrupt(&store, &damaged.id);
+        let report = store.verify_chunk_content().unwrap();
+        assert_eq!(report.checked, 3);
+        assert_eq!(
+            report.corrupt,
+            [CorruptChunk {
+                id: damaged.id.clone(),
+                origin: damaged.file.display().to_string(),
+            }]
+        );
+    }
+}
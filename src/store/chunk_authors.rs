@@ -0,0 +1,297 @@
+// WRITE_LOCK guard is held across .await inside block_on(). Safe because
+// block_on runs single-threaded — no concurrent tasks can deadlock.
+#![allow(clippy::await_holding_lock)]
+//! Per-chunk `git blame` summaries (schema v42).
+//!
+//! `cqs index` blames each file once and stores, per chunk, the top authors
+//! of the chunk's lines (`crate::ownership::refresh_chunk_authors`). Rows
+//! carry the chunk's content hash at blame time: a chunk whose hash moved on
+//! reads as missing and is re-blamed, and rows cascade away with their chunk.
+//! Read back by `search --owner` (as an allowlist) and `--with-owners`.
+
+use std::collections::{HashMap, HashSet};
+
+use super::helpers::{make_placeholders, sql::max_rows_per_statement, StoreError};
+use super::{ReadWrite, Store};
+use crate::ownership::AuthorShare;
+
+/// A chunk with no blame summary for its current content.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct BlameTarget {
+    pub id: String,
+    pub origin: String,
+    pub line_start: u32,
+    pub line_end: u32,
+    pub content_hash: String,
+}
+
+/// Decode a stored `authors` column. A row a newer binary wrote in a shape
+/// this one can't read counts as no authors rather than failing the read.
+fn decode_authors(chunk_id: &str, json: &str) -> Vec<AuthorShare> {
+    serde_json::from_str(json).unwrap_or_else(|e| {
+        tracing::warn!(chunk_id, error = %e, "Unreadable chunk_authors row");
+        Vec::new()
+    })
+}
+
+impl<Mode> Store<Mode> {
+    /// File chunks with no blame summary, or one taken from an older
+    /// version of their content. Ordered by origin so callers can blame
+    /// each file once.
+    pub fn chunks_missing_authors(&self) -> Result<Vec<BlameTarget>, StoreError> {
+        let _span = tracing::debug_span!("chunks_missing_authors").entered();
+        self.rt.block_on(async {
+            let rows: Vec<(String, String, i64, i64, String)> = sqlx::query_as(
+                "SELECT c.id, c.origin, c.line_start, c.line_end, c.content_hash
+                 FROM chunks c
+                 LEFT JOIN chunk_authors a ON a.chunk_id = c.id
+                 WHERE c.source_type = 'file'
+                   AND (a.chunk_id IS NULL OR a.content_hash != c.content_hash)
+                 ORDER BY c.origin, c.line_start",
+            )
+            .fetch_all(&self.pool)
+            .await?;
+            Ok(rows
+                .into_iter()
+                .map(
+                    |(id, origin, line_start, line_end, content_hash)| BlameTarget {
+                        id,
+                        origin,
+                        line_start: line_start.max(0) as u32,
+                        line_end: line_end.max(0) as u32,
+                        content_hash,
+                    },
+                )
+                .collect())
+        })
+    }
+
+    /// Stored blame summaries for `ids`. Chunks without one are absent.
+    pub fn chunk_authors(
+        &self,
+        ids: &[&str],
+    ) -> Result<HashMap<String, Vec<AuthorShare>>, StoreError> {
+        let _span = tracing::debug_span!("chunk_authors", count = ids.len()).entered();
+        if ids.is_empty() {
+            return Ok(HashMap::new());
+        }
+        self.rt.block_on(async {
+            let mut out = HashMap::new();
+            for batch in ids.chunks(max_rows_per_statement(1)) {
+                let sql = format!(
+                    "SELECT chunk_id, authors FROM chunk_authors WHERE chunk_id IN ({})",
+                    make_placeholders(batch.len())
+                );
+                let mut query =
+                    sqlx::query_as::<_, (String, String)>(sqlx::AssertSqlSafe(sql.as_str()));
+                for id in batch {
+                    query = query.bind(*id);
+                }
+                for (id, json) in query.fetch_all(&self.pool).await? {
+                    let authors = decode_authors(&id, &json);
+                    out.insert(id, authors);
+                }
+            }
+            Ok(out)
+        })
+    }
+
+    /// IDs of every chunk with `name` among its stored top authors
+    /// ([`AuthorShare::matches`]). Pages by rowid like
+    /// [`Self::chunk_ids_matching_content`]. Used to build the candidate
+    /// allowlist for `search --owner`.
+    pub fn chunk_ids_by_author(&self, name: &str) -> Result<HashSet<String>, StoreError> {
+        let _span = tracing::debug_span!("chunk_ids_by_author", name).entered();
+        self.rt.block_on(async {
+            let mut ids = HashSet::new();
+            let mut tx = self.pool.begin().await?;
+            const PAGE: i64 = 2048;
+            let mut last_rowid: i64 = 0;
+            loop {
+                let rows: Vec<(i64, String, String)> = sqlx::query_as(
+                    "SELECT rowid, chunk_id, authors FROM chunk_authors
+                     WHERE rowid > ?1
+                     ORDER BY rowid
+                     LIMIT ?2",
+                )
+                .bind(last_rowid)
+                .bind(PAGE)
+                .fetch_all(&mut *tx)
+                .await?;
+
+                let Some((rowid, _, _)) = rows.last() else {
+                    break;
+                };
+                last_rowid = *rowid;
+                ids.extend(
+                    rows.into_iter()
+                        .filter(|(_, id, json)| {
+                            decode_authors(id, json).iter().any(|a| a.matches(name))
+                        })
+                        .map(|(_, id, _)| id),
+                );
+            }
+            drop(tx);
+            tracing::debug!(matched = ids.len(), "Author scan complete");
+            Ok(ids)
+        })
+    }
+}
+
+impl Store<ReadWrite> {
+    /// Store blame summaries as `(chunk_id, content_hash, authors)`,
+    /// replacing any older summary for the same chunk. Rows for chunks that
+    /// vanished since they were blamed are skipped. Returns the number of
+    /// rows written.
+    pub fn upsert_chunk_authors(
+        &self,
+        rows: &[(String, String, Vec<AuthorShare>)],
+    ) -> Result<u64, StoreError> {
+        let _span = tracing::debug_span!("upsert_chunk_authors", count = rows.len()).entered();
+        if rows.is_empty() {
+            return Ok(0);
+        }
+        let encoded: Vec<(&str, &str, String)> = rows
+            .iter()
+            .map(|(id, hash, authors)| {
+                serde_json::to_string(authors)
+                    .map(|json| (id.as_str(), hash.as_str(), json))
+                    .map_err(|e| StoreError::Runtime(format!("Failed to encode authors: {e}")))
+            })
+            .collect::<Result<_, _>>()?;
+        self.rt.block_on(async {
+            let (_guard, mut tx) = self.begin_write().await?;
+            let mut written = 0;
+            const ROWS_PER_INSERT: usize = max_rows_per_statement(3);
+            for batch in encoded.chunks(ROWS_PER_INSERT) {
+                let mut qb: sqlx::QueryBuilder<sqlx::Sqlite> = sqlx::QueryBuilder::new(
+                    "INSERT OR REPLACE INTO chunk_authors (chunk_id, content_hash, authors) \
+                     SELECT v.column1, v.column2, v.column3 FROM (",
+                );
+                qb.push_values(batch.iter(), |mut b, (id, hash, json)| {
+                    b.push_bind(*id).push_bind(*hash).push_bind(json.as_str());
+                });
+                qb.push(") v JOIN chunks c ON c.id = v.column1");
+                written += qb.build().execute(&mut *tx).await?.rows_affected();
+            }
+            tx.commit().await?;
+            Ok(written)
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::store::test_utils::make_chunk_with;
+    use crate::test_helpers::{mock_embedding, setup_store};
+
+    fn share(name: &str, email: &str, lines: u32, share: f32) -> AuthorShare {
+        AuthorShare {
+            name: name.to_string(),
+            email: email.to_string(),
+            lines,
+            share,
+        }
+    }
+
+    #[test]
+    fn authors_round_trip_and_drop_out_of_missing() {
+        let (store, _dir) = setup_store();
+        let a = make_chunk_with("retry", "src/pay.rs", 1, 10, "fn retry() {}");
+        let b = make_chunk_with("stream", "src/pay.rs", 12, 20, "fn stream() {}");
+        store
+            .upsert_chunks_batch(
+                &[
+                    (a.clone(), mock_embedding(1.0)),
+                    (b.clone(), mock_embedding(2.0)),
+                ],
+                Some(100),
+            )
+            .unwrap();
+
+        let missing = store.chunks_missing_authors().unwrap();
+        assert_eq!(missing.len(), 2);
+        assert_eq!(missing[0].id, a.id);
+        assert_eq!((missing[0].line_start, missing[0].line_end), (1, 10));
+
+        let written = store
+            .upsert_chunk_authors(&[
+                (
+                    a.id.clone(),
+                    a.content_hash.clone(),
+                    vec![share("Ada", "ada@pay.example", 7, 0.7)],
+                ),
+                (
+                    "src/gone.rs:1:deadbeef".to_string(),
+                    "h".to_string(),
+                    vec![share("Ada", "ada@pay.example", 1, 1.0)],
+                ),
+            ])
+            .unwrap();
+        assert_eq!(written, 1, "rows for unknown chunks are skipped");
+
+        let missing: Vec<String> = store
+            .chunks_missing_authors()
+            .unwrap()
+            .into_iter()
+            .map(|t| t.id)
+            .collect();
+        assert_eq!(missing, vec![b.id.clone()]);
+
+        let stored = store
+            .chunk_authors(&[a.id.as_str(), b.id.as_str()])
+            .unwrap();
+        assert_eq!(stored.len(), 1);
+        assert_eq!(stored[&a.id][0].name, "Ada");
+    }
+
+    #[test]
+    fn stale_hash_reads_as_missing() {
+        let (store, _dir) = setup_store();
+        let a = make_chunk_with("retry", "src/pay.rs", 1, 10, "fn retry() {}");
+        store
+            .upsert_chunks_batch(&[(a.clone(), mock_embedding(1.0))], Some(100))
+            .unwrap();
+        store
+            .upsert_chunk_authors(&[(a.id.clone(), "old-hash".to_string(), Vec::new())])
+            .unwrap();
+        assert_eq!(store.chunks_missing_authors().unwrap().len(), 1);
+    }
+
+    #[test]
+    fn chunk_ids_by_author_matches_name_or_email() {
+        let (store, _dir) = setup_store();
+        let a = make_chunk_with("retry", "src/pay.rs", 1, 10, "fn retry() {}");
+        let b = make_chunk_with("render", "src/ui.rs", 1, 10, "fn render() {}");
+        store
+            .upsert_chunks_batch(
+                &[
+                    (a.clone(), mock_embedding(1.0)),
+                    (b.clone(), mock_embedding(2.0)),
+                ],
+                Some(100),
+            )
+            .unwrap();
+        store
+            .upsert_chunk_authors(&[
+                (
+                    a.id.clone(),
+                    a.content_hash.clone(),
+                    vec![share("Ada Lovelace", "ada@pay.example", 9, 0.9)],
+                ),
+                (
+                    b.id.clone(),
+                    b.content_hash.clone(),
+                    vec![share("Grace Hopper", "grace@ui.example", 9, 0.9)],
+                ),
+            ])
+            .unwrap();
+
+        let by_name = store.chunk_ids_by_author("ada lovelace").unwrap();
+        assert_eq!(by_name, HashSet::from([a.id.clone()]));
+        let by_email = store.chunk_ids_by_author("grace@ui.example").unwrap();
+        assert_eq!(by_email, HashSet::from([b.id.clone()]));
+        assert!(store.chunk_ids_by_author("nobody").unwrap().is_empty());
+    }
+}
@@ -2,10 +2,11 @@
 
 use std::collections::HashMap;
 
+use sqlx::sqlite::SqliteConnection;
 use sqlx::Row;
 
 use crate::embedder::Embedding;
-use crate::nl::normalize_for_fts;
+use crate::nl::FtsAnalyzer;
 use crate::parser::Chunk;
 use crate::store::helpers::{bytes_to_embedding, CandidateRow, ChunkRow, StoreError};
 use crate::store::Store;
@@ -14,8 +15,8 @@ impl<Mode> Store<Mode> {
     /// Fetch chunks by IDs (without embeddings) — async version.
     ///
     /// Returns a map of chunk ID → ChunkRow for the given IDs.
-    /// Used by search to hydrate top-N results after scoring.
-    /// Batch size derives from the SQLite variable limit
+    /// Takes its own pooled connection; search hydrates top-N results
+    /// through [`Self::fetch_chunks_by_ids_in`] instead. Batch size derives from the SQLite variable limit
     /// (`max_rows_per_statement(1)`).
     pub(crate) async fn fetch_chunks_by_ids_async(
         &self,
@@ -24,6 +25,20 @@ impl<Mode> Store<Mode> {
         if ids.is_empty() {
             return Ok(HashMap::new());
         }
+        let mut conn = self.pool.acquire().await?;
+        self.fetch_chunks_by_ids_in(&mut conn, ids).await
+    }
+
+    /// [`Self::fetch_chunks_by_ids_async`] on the caller's connection, so a
+    /// search hydrates its winners from the snapshot it scored them in.
+    pub(crate) async fn fetch_chunks_by_ids_in(
+        &self,
+        conn: &mut SqliteConnection,
+        ids: &[&str],
+    ) -> Result<HashMap<String, ChunkRow>, StoreError> {
+        if ids.is_empty() {
+            return Ok(HashMap::new());
+        }
 
         const BATCH_SIZE: usize = crate::store::helpers::sql::max_rows_per_statement(1);
         let mut result = HashMap::with_capacity(ids.len());
@@ -40,7 +55,7 @@ impl<Mode> Store<Mode> {
                 for id in batch {
                     q = q.bind(*id);
                 }
-                q.fetch_all(&self.pool).await?
+                q.fetch_all(&mut *conn).await?
             };
 
             for r in &rows {
@@ -64,8 +79,11 @@ impl<Mode> Store<Mode> {
     /// scores from pre-fused dense+sparse scores and never reads the
     /// embedding, so fetching ~candidate_count BLOBs per query (768-dim ×
     /// 4 bytes × ~450 candidates ≈ 1.4 MB) was pure waste there.
+    ///
+    /// Runs on the caller's read snapshot (see `store::snapshot`).
     pub(crate) async fn fetch_candidates_by_ids_async(
         &self,
+        conn: &mut SqliteConnection,
         ids: &[&str],
         with_embeddings: bool,
     ) -> Result<Vec<(CandidateRow, Option<Vec<u8>>)>, StoreError> {
@@ -110,7 +128,7 @@ impl<Mode> Store<Mode> {
                 for id in batch {
                     q = q.bind(*id);
                 }
-                q.fetch_all(&self.pool).await?
+                q.fetch_all(&mut *conn).await?
             };
 
             for r in &rows {
@@ -308,8 +326,8 @@ pub(super) async fn snapshot_content_hashes(
 
 /// Batch INSERT chunks — batch size derived from the SQLite variable limit.
 ///
-/// The 32766-variable limit permits ~1622 rows per statement (20 params per
-/// row), so a 12k-chunk reindex runs in ~8 INSERTs.
+/// The 32766-variable limit permits ~1160 rows per statement (28 params per
+/// row), so a 12k-chunk reindex runs in ~11 INSERTs.
 ///
 /// Uses `ON CONFLICT(id) DO UPDATE` (upsert), not `INSERT OR REPLACE`, to
 /// preserve `enrichment_hash` and `enrichment_version` columns set by the
@@ -339,9 +357,11 @@ pub(super) async fn batch_insert_chunks(
     now: &str,
     needs_embedding: bool,
 ) -> Result<(), StoreError> {
+    use crate::store::compression::{self, ContentDict};
     use crate::store::helpers::sql::max_rows_per_statement;
-    // 24 binds per row (canonical_hash comes after needs_embedding).
-    const CHUNK_INSERT_BATCH: usize = max_rows_per_statement(24);
+    // 28 binds per row (canonical_hash comes after needs_embedding, then
+    // signature_shape, deprecated and boilerplate; content takes two).
+    const CHUNK_INSERT_BATCH: usize = max_rows_per_statement(28);
     debug_assert_eq!(
         chunks.len(),
         vendored_per_chunk.len(),
@@ -353,20 +373,55 @@ pub(super) async fn batch_insert_chunks(
         "source_mtimes must align 1:1 with chunks"
     );
     let needs_embedding_i64: i64 = if needs_embedding { 1 } else { 0 };
+    // v49: chunk text is compressed against the store's dictionary once
+    // `cqs compress` has trained one.
+    let dict = compression::active_dict(&mut **tx).await?;
+    let mut compressor = dict.as_deref().map(ContentDict::compressor).transpose()?;
     for (batch_idx, batch) in chunks.chunks(CHUNK_INSERT_BATCH).enumerate() {
         let emb_offset = batch_idx * CHUNK_INSERT_BATCH;
+        let frames: Vec<Option<Vec<u8>>> = batch
+            .iter()
+            .map(|(chunk, _)| {
+                compression::pack(
+                    compressor.as_mut(),
+                    &chunk.chunk_type.to_string(),
+                    &chunk.content,
+                )
+            })
+            .collect::<Result<_, _>>()?;
         let mut qb: sqlx::QueryBuilder<sqlx::Sqlite> = sqlx::QueryBuilder::new(
-            "INSERT INTO chunks (id, origin, source_type, language, chunk_type, name, signature, content, content_hash, doc, line_start, line_end, embedding, embedding_base, source_mtime, created_at, updated_at, parent_id, window_idx, parent_type_name, parser_version, vendored, needs_embedding, canonical_hash)",
+            "INSERT INTO chunks (id, origin, source_type, language, chunk_type, name, signature, content, content_hash, doc, line_start, line_end, embedding, embedding_base, source_mtime, created_at, updated_at, parent_id, window_idx, parent_type_name, parser_version, vendored, needs_embedding, canonical_hash, signature_shape, deprecated, boilerplate)",
         );
         qb.push_values(batch.iter().enumerate(), |mut b, (i, (chunk, _))| {
+            let origin = crate::normalize_path(&chunk.file);
+            // v47: codegen output and license text, left out of search by
+            // default.
+            let boilerplate = crate::boilerplate::detect(
+                &origin,
+                chunk.chunk_type,
+                &chunk.content,
+                chunk.doc.as_deref(),
+            )
+            .map(crate::boilerplate::Boilerplate::as_str);
             b.push_bind(&chunk.id)
-                .push_bind(crate::normalize_path(&chunk.file))
-                .push_bind("file")
+                .push_bind(origin)
+                // Commit messages (v44) are their own source, so the file
+                // walk, staleness, and prune (`source_type = 'file'`) skip them.
+                .push_bind(if chunk.chunk_type == crate::parser::ChunkType::Commit {
+                    "commit"
+                } else {
+                    "file"
+                })
                 .push_bind(chunk.language.to_string())
                 .push_bind(chunk.chunk_type.to_string())
                 .push_bind(&chunk.name)
                 .push_bind(&chunk.signature)
-                .push_bind(&chunk.content)
+                // Plain text or its zstd frame: exactly one bind is non-NULL.
+                .push("COALESCE(")
+                .push_bind_unseparated(frames[i].is_none().then_some(chunk.content.as_str()))
+                .push_unseparated(", ")
+                .push_bind_unseparated(frames[i].as_deref())
+                .push_unseparated(")")
                 .push_bind(&chunk.content_hash)
                 .push_bind(&chunk.doc)
                 .push_bind(chunk.line_start as i64)
@@ -421,7 +476,30 @@ pub(super) async fn batch_insert_chunks(
                     None::<&str>
                 } else {
                     Some(chunk.canonical_hash.as_str())
-                });
+                })
+                // v41: canonical parameter/return types for `--signature`.
+                // NULL for non-callables and signatures without types.
+                .push_bind(crate::parser::signature::stored_shape(
+                    chunk.chunk_type,
+                    &chunk.signature,
+                    &chunk.name,
+                    chunk.language,
+                ))
+                // v46: deprecation marker in the doc or declaration head.
+                .push_bind(
+                    if crate::parser::deprecation::is_deprecated(
+                        chunk.chunk_type,
+                        &chunk.name,
+                        &chunk.content,
+                        chunk.doc.as_deref(),
+                        chunk.language,
+                    ) {
+                        1_i64
+                    } else {
+                        0_i64
+                    },
+                )
+                .push_bind(boilerplate);
         });
         // ON CONFLICT upsert preserves enrichment_hash and enrichment_version.
         //
@@ -462,6 +540,9 @@ pub(super) async fn batch_insert_chunks(
              vendored=excluded.vendored, \
              needs_embedding=excluded.needs_embedding, \
              canonical_hash=excluded.canonical_hash, \
+             signature_shape=excluded.signature_shape, \
+             deprecated=excluded.deprecated, \
+             boilerplate=excluded.boilerplate, \
              umap_x=CASE WHEN chunks.content_hash != excluded.content_hash \
                          THEN NULL ELSE chunks.umap_x END, \
              umap_y=CASE WHEN chunks.content_hash != excluded.content_hash \
@@ -471,6 +552,14 @@ pub(super) async fn batch_insert_chunks(
                 OR chunks.vendored != excluded.vendored",
         );
         qb.build().execute(&mut **tx).await?;
+
+        // v37: content that came back with a real embedding no longer needs
+        // its tombstone. A zero-vec sentinel write leaves it in place so the
+        // enrichment pass can still reuse the tombstoned vector.
+        if !needs_embedding {
+            let hashes: Vec<&str> = batch.iter().map(|(c, _)| c.content_hash.as_str()).collect();
+            super::tombstones::clear_tombstones_in_tx(tx, &hashes).await?;
+        }
     }
     Ok(())
 }
@@ -480,14 +569,18 @@ pub(super) async fn batch_insert_chunks(
 ///
 /// The parser_version OR mirrors the UPSERT WHERE filter in
 /// `batch_insert_chunks`. A parser bump that updates `doc` without touching
-/// source bytes still needs the FTS row refreshed — `normalize_for_fts` is
-/// applied to `doc` and the FTS would otherwise serve stale text.
+/// source bytes still needs the FTS row refreshed — the store's FTS analyzer
+/// is applied to `doc` and the FTS would otherwise serve stale text.
+///
+/// The same changed set has its issue references re-derived from its
+/// comments (v43), so `chunk_issue_refs` never lags the FTS row.
 ///
 /// Batches DELETE and INSERT for efficiency.
 pub(super) async fn upsert_fts_conditional(
     tx: &mut sqlx::Transaction<'_, sqlx::Sqlite>,
     chunks: &[(Chunk, Embedding)],
     old: &HashMap<String, ChunkSnapshot>,
+    analyzer: &FtsAnalyzer,
 ) -> Result<(), StoreError> {
     // Collect changed chunks (content OR parser_version differs vs. snapshot)
     let changed: Vec<&Chunk> = chunks
@@ -525,26 +618,34 @@ pub(super) async fn upsert_fts_conditional(
         query.execute(&mut **tx).await?;
     }
 
-    // Batch INSERT: add new FTS entries
-    for batch in changed.chunks(max_rows_per_statement(5)) {
-        let mut qb: sqlx::QueryBuilder<sqlx::Sqlite> =
-            sqlx::QueryBuilder::new("INSERT INTO chunks_fts (id, name, signature, content, doc) ");
+    // Batch INSERT: add new FTS entries. Code and comment text go to
+    // separate columns so `--in comments|code` and the per-column bm25
+    // weights can tell them apart.
+    for batch in changed.chunks(max_rows_per_statement(6)) {
+        let mut qb: sqlx::QueryBuilder<sqlx::Sqlite> = sqlx::QueryBuilder::new(
+            "INSERT INTO chunks_fts (id, name, signature, content, doc, comments) ",
+        );
         qb.push_values(batch.iter(), |mut b, chunk| {
+            let (code, comments) =
+                crate::parser::chunk::split_code_comments(&chunk.content, chunk.language);
             b.push_bind(&chunk.id)
-                .push_bind(normalize_for_fts(&chunk.name))
-                .push_bind(normalize_for_fts(&chunk.signature))
-                .push_bind(normalize_for_fts(&chunk.content))
+                .push_bind(analyzer.index(&chunk.name))
+                .push_bind(analyzer.index(&chunk.signature))
+                .push_bind(analyzer.index(&code))
                 .push_bind(
                     chunk
                         .doc
                         .as_ref()
-                        .map(|d| normalize_for_fts(d))
+                        .map(|d| analyzer.index(d))
                         .unwrap_or_default(),
-                );
+                )
+                .push_bind(analyzer.index(&comments));
         });
         qb.build().execute(&mut **tx).await?;
     }
 
+    crate::store::issue_refs::replace_issue_refs_in_tx(tx, &changed).await?;
+
     Ok(())
 }
 
@@ -1033,4 +1134,40 @@ mod tests {
         assert_eq!(enriched[0].1.as_slice(), new_embedding.as_slice());
         assert_eq!(base[0].1.as_slice(), new_embedding.as_slice());
     }
+
+    // ===== FTS analyzer =====
+
+    #[test]
+    fn test_set_fts_analyzer_rebuilds_and_persists() {
+        use crate::nl::{FtsAnalyzer, FtsLanguage};
+
+        let (mut store, dir) = setup_store();
+        let a = make_chunk("parseFiles", "src/a.rs");
+        store
+            .upsert_chunks_batch(&[(a, mock_embedding(0.1))], Some(100))
+            .unwrap();
+        // Default analyzer: an inflected form doesn't match.
+        assert!(store.search_fts("parsing", 10).unwrap().is_empty());
+
+        let en = FtsAnalyzer::new(Some(FtsLanguage::English), &[FtsLanguage::English]);
+        assert!(store.set_fts_analyzer(en.clone()).unwrap());
+        assert!(
+            !store.set_fts_analyzer(en.clone()).unwrap(),
+            "unchanged analyzer must not rebuild"
+        );
+        assert_eq!(store.search_fts("parsing", 10).unwrap().len(), 1);
+
+        // Later writes go through the new analyzer too.
+        let b = make_chunk("parseConfigs", "src/b.rs");
+        store
+            .upsert_chunks_batch(&[(b, mock_embedding(0.2))], Some(100))
+            .unwrap();
+        assert_eq!(store.search_fts("config", 10).unwrap().len(), 1);
+
+        // The analyzer is recorded; a reopened store queries with it.
+        drop(store);
+        let store = crate::Store::open(&dir.path().join(crate::INDEX_DB_FILENAME)).unwrap();
+        assert_eq!(store.fts_analyzer(), &en);
+        assert_eq!(store.search_fts("parsing", 10).unwrap().len(), 2);
+    }
 }
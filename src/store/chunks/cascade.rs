@@ -0,0 +1,249 @@
+//! One delete routine for chunks and everything hanging off them.
+//!
+//! Every per-chunk table declares `FOREIGN KEY (…) REFERENCES chunks(id)
+//! ON DELETE CASCADE` and the pool opens with `foreign_keys = ON`, so a
+//! `DELETE FROM chunks` takes `calls`, `type_edges`, `sparse_vectors`, the
+//! git metadata tables, `chunk_embedding_versions` and the facet and token
+//! embeddings with it. The one exception is `chunks_fts`: an FTS5 table
+//! can't carry a foreign key and its `id` column is UNINDEXED, so a per-row
+//! trigger would scan the whole index on every delete.
+//! [`delete_chunks_where`] pairs the two deletes so no caller can do one
+//! without the other.
+//!
+//! Left out on purpose:
+//! - `function_calls` / `candidate_edges` are file-keyed and parse-driven;
+//!   each delete path decides whether the file's edges go too.
+//! - `llm_summaries` are keyed by content hash and outlive their chunks
+//!   ([`Store::mark_superseded_summaries`] stamps them instead).
+//! - `chunk_tombstones` exist to outlive their chunks.
+//!
+//! Indexes built before a table gained its foreign key, or written with
+//! enforcement off, can still hold dependents of chunks that are gone.
+//! [`Store::dependent_orphans`] counts them for `cqs doctor`;
+//! [`purge_dependent_orphans`] removes them during `cqs gc`.
+
+use sqlx::sqlite::SqliteConnection;
+use sqlx::{Sqlite, Transaction};
+
+use crate::store::helpers::StoreError;
+use crate::store::Store;
+
+/// `(table, column)` pairs that hold a `chunks.id`. `chunks_fts` first: it
+/// is the one the cascade doesn't reach.
+pub(crate) const CHUNK_DEPENDENTS: &[(&str, &str)] = &[
+    ("chunks_fts", "id"),
+    ("calls", "caller_id"),
+    ("type_edges", "source_chunk_id"),
+    ("sparse_vectors", "chunk_id"),
+    ("chunk_authors", "chunk_id"),
+    ("chunk_issue_refs", "chunk_id"),
+    ("commits", "chunk_id"),
+    ("commit_files", "chunk_id"),
+    ("chunk_embedding_versions", "chunk_id"),
+    ("chunk_token_embeddings", "chunk_id"),
+    ("chunk_facet_embeddings", "chunk_id"),
+];
+
+/// Rows in one dependent table whose chunk no longer exists.
+#[derive(Debug, Clone, PartialEq, Eq, serde::Serialize)]
+pub struct DependentOrphans {
+    pub table: &'static str,
+    pub rows: u64,
+}
+
+/// Delete the chunks matching `predicate` together with their FTS rows;
+/// foreign keys take the other dependents. `predicate` is a trusted SQL
+/// fragment over `chunks` using `?1..?N` for `binds`. Run it on a write
+/// transaction so the pair commits or rolls back as one. Returns the number
+/// of chunks deleted.
+pub(crate) async fn delete_chunks_where(
+    conn: &mut SqliteConnection,
+    predicate: &str,
+    binds: &[&str],
+) -> Result<u64, StoreError> {
+    let fts_sql =
+        format!("DELETE FROM chunks_fts WHERE id IN (SELECT id FROM chunks WHERE {predicate})");
+    let mut fts = sqlx::query(sqlx::AssertSqlSafe(fts_sql.as_str()));
+    for b in binds {
+        fts = fts.bind(*b);
+    }
+    fts.execute(&mut *conn).await?;
+
+    let chunks_sql = format!("DELETE FROM chunks WHERE {predicate}");
+    let mut chunks = sqlx::query(sqlx::AssertSqlSafe(chunks_sql.as_str()));
+    for b in binds {
+        chunks = chunks.bind(*b);
+    }
+    Ok(chunks.execute(&mut *conn).await?.rows_affected())
+}
+
+fn orphan_predicate(table: &str, column: &str) -> String {
+    format!("FROM {table} WHERE {column} NOT IN (SELECT id FROM chunks)")
+}
+
+/// Delete every dependent row whose chunk is gone, in `tx`. Returns the
+/// tables that had any, with their counts. Removing sparse rows bumps the
+/// SPLADE generation, as [`Store::prune_orphan_sparse_vectors`] does.
+pub(crate) async fn purge_dependent_orphans(
+    tx: &mut Transaction<'_, Sqlite>,
+) -> Result<Vec<DependentOrphans>, StoreError> {
+    let mut purged = Vec::new();
+    for &(table, column) in CHUNK_DEPENDENTS {
+        let sql = format!("DELETE {}", orphan_predicate(table, column));
+        let rows = sqlx::query(sqlx::AssertSqlSafe(sql.as_str()))
+            .execute(&mut **tx)
+            .await?
+            .rows_affected();
+        if rows == 0 {
+            continue;
+        }
+        if table == "sparse_vectors" {
+            crate::store::sparse::bump_splade_generation_tx(tx).await?;
+        }
+        tracing::info!(table, rows, "Purged orphan chunk dependents");
+        purged.push(DependentOrphans { table, rows });
+    }
+    Ok(purged)
+}
+
+impl<Mode> Store<Mode> {
+    /// Count dependent rows whose chunk no longer exists, per table. Only
+    /// tables with orphans are returned, so an empty result is a clean index.
+    pub fn dependent_orphans(&self) -> Result<Vec<DependentOrphans>, StoreError> {
+        let _span = tracing::debug_span!("dependent_orphans").entered();
+        self.rt.block_on(async {
+            let mut found = Vec::new();
+            for &(table, column) in CHUNK_DEPENDENTS {
+                let sql = format!("SELECT COUNT(*) {}", orphan_predicate(table, column));
+                let (rows,): (i64,) = sqlx::query_as(sqlx::AssertSqlSafe(sql.as_str()))
+                    .fetch_one(&self.pool)
+                    .await?;
+                if rows > 0 {
+                    found.push(DependentOrphans {
+                        table,
+                        rows: rows as u64,
+                    });
+                }
+            }
+            Ok(found)
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::super::test_utils::make_chunk;
+    use super::*;
+    use crate::test_helpers::{mock_embedding, setup_store};
+    use std::collections::HashSet;
+    use std::path::Path;
+
+    fn count(store: &Store, table: &str) -> i64 {
+        let sql = format!("SELECT COUNT(*) FROM {table}");
+        store.rt.block_on(async {
+            let (n,): (i64,) = sqlx::query_as(sqlx::AssertSqlSafe(sql.as_str()))
+                .fetch_one(&store.pool)
+                .await
+                .unwrap();
+            n
+        })
+    }
+
+    /// Insert dependents of `chunk_id` with foreign-key enforcement off on
+    /// the connection — what a pre-FK index or a bypassed writer leaves.
+    fn insert_unchecked(store: &Store, chunk_id: &str) {
+        store.rt.block_on(async {
+            let mut conn = store.pool.acquire().await.unwrap();
+            sqlx::query("PRAGMA foreign_keys = OFF")
+                .execute(&mut *conn)
+                .await
+                .unwrap();
+            for sql in [
+                "INSERT INTO chunks_fts (id, name, signature, content, doc, comments) \
+                 VALUES (?1, 'n', '', '', '', '')",
+                "INSERT INTO calls (caller_id, callee_name, line_number) VALUES (?1, 'callee', 1)",
+                "INSERT INTO sparse_vectors (chunk_id, token_id, weight) VALUES (?1, 7, 0.5)",
+            ] {
+                sqlx::query(sql)
+                    .bind(chunk_id)
+                    .execute(&mut *conn)
+                    .await
+                    .unwrap();
+            }
+            sqlx::query("PRAGMA foreign_keys = ON")
+                .execute(&mut *conn)
+                .await
+                .unwrap();
+        });
+    }
+
+    #[test]
+    fn deleting_a_chunk_leaves_no_dependents() {
+        let (store, _dir) = setup_store();
+        let chunk = make_chunk("gone", "src/gone.rs");
+        let id = chunk.id.clone();
+        store
+            .upsert_chunks_batch(&[(chunk, mock_embedding(1.0))], Some(100))
+            .unwrap();
+        store.rt.block_on(async {
+            sqlx::query(
+                "INSERT INTO calls (caller_id, callee_name, line_number) VALUES (?1, 'callee', 1)",
+            )
+            .bind(&id)
+            .execute(&store.pool)
+            .await
+            .unwrap();
+            sqlx::query(
+                "INSERT INTO sparse_vectors (chunk_id, token_id, weight) VALUES (?1, 7, 0.5)",
+            )
+            .bind(&id)
+            .execute(&store.pool)
+            .await
+            .unwrap();
+        });
+        assert_eq!(count(&store, "chunks_fts"), 1);
+
+        assert_eq!(store.delete_by_origin(Path::new("src/gone.rs")).unwrap(), 1);
+
+        for &(table, _) in CHUNK_DEPENDENTS {
+            assert_eq!(count(&store, table), 0, "{table} kept rows");
+        }
+        assert!(store.dependent_orphans().unwrap().is_empty());
+    }
+
+    #[test]
+    fn every_chunk_foreign_key_is_a_dependent() {
+        let (store, _dir) = setup_store();
+        let keys: Vec<(String, String)> = store.rt.block_on(async {
+            sqlx::query_as(
+                "SELECT m.name, f.\"from\" FROM sqlite_master m, pragma_foreign_key_list(m.name) f \
+                 WHERE m.type = 'table' AND f.\"table\" = 'chunks'",
+            )
+            .fetch_all(&store.pool)
+            .await
+            .unwrap()
+        });
+        assert!(!keys.is_empty());
+        for (table, column) in &keys {
+            assert!(
+                CHUNK_DEPENDENTS.contains(&(table.as_str(), column.as_str())),
+                "{table}.{column} references chunks but is not in CHUNK_DEPENDENTS"
+            );
+        }
+    }
+
+    #[test]
+    fn legacy_orphans_are_counted_then_purged_by_prune_all() {
+        let (store, dir) = setup_store();
+        insert_unchecked(&store, "src/old.rs:1:deadbeef");
+
+        let found = store.dependent_orphans().unwrap();
+        let tables: Vec<_> = found.iter().map(|o| o.table).collect();
+        assert_eq!(tables, ["chunks_fts", "calls", "sparse_vectors"]);
+        assert!(found.iter().all(|o| o.rows == 1));
+
+        let result = store.prune_all(&HashSet::new(), dir.path()).unwrap();
+        assert_eq!(result.pruned_dependents, 3);
+        assert!(store.dependent_orphans().unwrap().is_empty());
+    }
+}
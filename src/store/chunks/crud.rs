@@ -328,7 +328,7 @@ impl Store<ReadWrite> {
                 false, // real embeddings → needs_embedding=0
             )
             .await?;
-            upsert_fts_conditional(&mut tx, chunks, &old_hashes).await?;
+            upsert_fts_conditional(&mut tx, chunks, &old_hashes, &self.fts_analyzer).await?;
             tx.commit().await?;
             Ok(chunks.len())
         })
@@ -734,13 +734,58 @@ impl Store<ReadWrite> {
         })
     }
 
-    /// Delete orphan LLM summaries whose content_hash doesn't exist in any chunk.
+    /// Reconcile `llm_summaries.superseded_at` with the live chunks.
+    ///
+    /// A summary is keyed by the content_hash it was generated from, so once
+    /// the code changes no chunk carries that hash and the summary describes
+    /// code that no longer exists. Such rows are stamped with the current
+    /// time rather than deleted: a revert or branch switch brings the hash
+    /// back, which clears the stamp and reuses the summary for free.
+    /// [`prune_orphan_summaries`](Self::prune_orphan_summaries) still owns
+    /// deletion.
+    ///
+    /// Returns `(newly_superseded, revived)`.
+    pub fn mark_superseded_summaries(&self) -> Result<(usize, usize), StoreError> {
+        let _span = tracing::debug_span!("mark_superseded_summaries").entered();
+        let Some(now) = crate::unix_secs_i64() else {
+            return Ok((0, 0));
+        };
+        self.rt.block_on(async {
+            let (_guard, mut tx) = self.begin_write().await?;
+            let superseded = sqlx::query(
+                "UPDATE llm_summaries SET superseded_at = ?1 \
+                 WHERE superseded_at IS NULL \
+                 AND content_hash NOT IN (SELECT content_hash FROM chunks)",
+            )
+            .bind(now)
+            .execute(&mut *tx)
+            .await?
+            .rows_affected() as usize;
+            let revived = sqlx::query(
+                "UPDATE llm_summaries SET superseded_at = NULL \
+                 WHERE superseded_at IS NOT NULL \
+                 AND content_hash IN (SELECT content_hash FROM chunks)",
+            )
+            .execute(&mut *tx)
+            .await?
+            .rows_affected() as usize;
+            tx.commit().await?;
+            if superseded > 0 || revived > 0 {
+                tracing::info!(superseded, revived, "Summary freshness updated");
+            }
+            Ok((superseded, revived))
+        })
+    }
+
+    /// Delete orphan LLM summaries whose content_hash doesn't exist in any
+    /// chunk or chunk tombstone.
     pub fn prune_orphan_summaries(&self) -> Result<usize, StoreError> {
         let _span = tracing::debug_span!("prune_orphan_summaries").entered();
         self.rt.block_on(async {
             let result = sqlx::query(
                 "DELETE FROM llm_summaries WHERE content_hash NOT IN \
-                 (SELECT DISTINCT content_hash FROM chunks)",
+                 (SELECT content_hash FROM chunks \
+                  UNION SELECT content_hash FROM chunk_tombstones)",
             )
             .execute(&self.pool)
             .await?;
@@ -756,17 +801,9 @@ impl Store<ReadWrite> {
         self.rt.block_on(async {
             let (_guard, mut tx) = self.begin_write().await?;
 
-            sqlx::query(
-                "DELETE FROM chunks_fts WHERE id IN (SELECT id FROM chunks WHERE origin = ?1)",
-            )
-            .bind(&origin_str)
-            .execute(&mut *tx)
-            .await?;
-
-            let result = sqlx::query("DELETE FROM chunks WHERE origin = ?1")
-                .bind(&origin_str)
-                .execute(&mut *tx)
-                .await?;
+            let deleted =
+                super::cascade::delete_chunks_where(&mut *tx, "origin = ?1", &[&origin_str])
+                    .await?;
 
             // `function_calls` has no FK to `chunks` (it stores `caller_name`
             // strings, not chunk IDs), so deleting chunks does not cascade.
@@ -799,7 +836,7 @@ impl Store<ReadWrite> {
                 .await?;
 
             tx.commit().await?;
-            Ok(result.rows_affected() as u32)
+            Ok(deleted as u32)
         })
     }
 
@@ -1162,7 +1199,7 @@ impl Store<ReadWrite> {
                     false, // real embeddings → needs_embedding=0
                 )
                 .await?;
-                upsert_fts_conditional(&mut tx, real, &old_hashes).await?;
+                upsert_fts_conditional(&mut tx, real, &old_hashes, &self.fts_analyzer).await?;
             }
             if !sentinel_pairs.is_empty() {
                 let old_hashes = snapshot_content_hashes(&mut tx, &sentinel_pairs).await?;
@@ -1176,7 +1213,8 @@ impl Store<ReadWrite> {
                     true, // zero-vec sentinel → needs_embedding=1
                 )
                 .await?;
-                upsert_fts_conditional(&mut tx, &sentinel_pairs, &old_hashes).await?;
+                upsert_fts_conditional(&mut tx, &sentinel_pairs, &old_hashes, &self.fts_analyzer)
+                    .await?;
             }
             for file in batch_files {
                 if let Some(fp) = fingerprints.get(file) {
@@ -1423,7 +1461,11 @@ impl Store<ReadWrite> {
                 false, // real embeddings → needs_embedding=0
             )
             .await?;
-            upsert_fts_conditional(&mut tx, chunks, &old_hashes).await?;
+            upsert_fts_conditional(&mut tx, chunks, &old_hashes, &self.fts_analyzer).await?;
+            // v33: ids that were already indexed before this write; everything
+            // else is a newly appeared chunk and a rename-detection candidate.
+            let mut existed: std::collections::HashSet<String> =
+                old_hashes.keys().cloned().collect();
             if !sentinel_pairs.is_empty() {
                 let sentinel_old = snapshot_content_hashes(&mut tx, &sentinel_pairs).await?;
                 batch_insert_chunks(
@@ -1436,7 +1478,9 @@ impl Store<ReadWrite> {
                     true, // zero-vec sentinel → needs_embedding=1
                 )
                 .await?;
-                upsert_fts_conditional(&mut tx, &sentinel_pairs, &sentinel_old).await?;
+                upsert_fts_conditional(&mut tx, &sentinel_pairs, &sentinel_old, &self.fts_analyzer)
+                    .await?;
+                existed.extend(sentinel_old.into_keys());
             }
 
             // Upsert calls: delete old calls for these chunk IDs, insert new ones
@@ -1512,16 +1556,7 @@ impl Store<ReadWrite> {
                 if live_ids.is_empty() {
                     // Whole file was emptied — inline `delete_by_origin`
                     // logic so the write stays in this tx.
-                    sqlx::query(
-                        "DELETE FROM chunks_fts WHERE id IN \
-                         (SELECT id FROM chunks WHERE origin = ?1)",
-                    )
-                    .bind(&origin_str)
-                    .execute(&mut *tx)
-                    .await?;
-                    sqlx::query("DELETE FROM chunks WHERE origin = ?1")
-                        .bind(&origin_str)
-                        .execute(&mut *tx)
+                    super::cascade::delete_chunks_where(&mut *tx, "origin = ?1", &[&origin_str])
                         .await?;
                     // NOTE: function_calls cleanup is handled by the watch
                     // loop's `upsert_function_calls` which DELETE-then-INSERTs
@@ -1556,21 +1591,28 @@ impl Store<ReadWrite> {
                         stmt.execute(&mut *tx).await?;
                     }
 
-                    let fts_query = "DELETE FROM chunks_fts WHERE id IN \
-                         (SELECT id FROM chunks WHERE origin = ?1 \
-                          AND id NOT IN (SELECT id FROM _live_ids))";
-                    sqlx::query(fts_query)
-                        .bind(&origin_str)
-                        .execute(&mut *tx)
-                        .await?;
+                    // v33: pair the chunks about to be pruned with the ones
+                    // that just appeared before the prune erases the old side.
+                    let appeared: Vec<&Chunk> = chunks
+                        .iter()
+                        .map(|(c, _)| c)
+                        .chain(sentinel.iter())
+                        .filter(|c| !existed.contains(&c.id))
+                        .collect();
+                    crate::store::renames::record_renames_in_tx(
+                        &mut tx,
+                        &origin_str,
+                        &appeared,
+                        &now,
+                    )
+                    .await?;
 
-                    let chunks_query = "DELETE FROM chunks WHERE origin = ?1 \
-                         AND id NOT IN (SELECT id FROM _live_ids)";
-                    let result = sqlx::query(chunks_query)
-                        .bind(&origin_str)
-                        .execute(&mut *tx)
-                        .await?;
-                    let deleted = result.rows_affected();
+                    let deleted = super::cascade::delete_chunks_where(
+                        &mut *tx,
+                        "origin = ?1 AND id NOT IN (SELECT id FROM _live_ids)",
+                        &[&origin_str],
+                    )
+                    .await?;
                     if deleted > 0 {
                         tracing::info!(
                             origin = %origin_str,
@@ -1588,6 +1630,13 @@ impl Store<ReadWrite> {
                 }
             }
 
+            // v35: recompute the file's containment tree after the prune so a
+            // removed class never lingers as a container.
+            if let Some(file) = prune_file {
+                let origin_str = crate::normalize_path(file);
+                super::hierarchy::assign_containers_in_tx(&mut tx, &origin_str).await?;
+            }
+
             // Fold the file-level `function_calls` write into the same tx
             // as chunks/FTS/calls so a crash here can't leave the tables in
             // an asymmetric state where the call graph knows about a function
@@ -1675,20 +1724,12 @@ impl Store<ReadWrite> {
                 stmt.execute(&mut *tx).await?;
             }
 
-            let fts_query =
-                "DELETE FROM chunks_fts WHERE id IN \
-                 (SELECT id FROM chunks WHERE origin = ?1 AND id NOT IN (SELECT id FROM _live_ids))";
-            sqlx::query(fts_query)
-                .bind(&origin_str)
-                .execute(&mut *tx)
-                .await?;
-
-            let chunks_query =
-                "DELETE FROM chunks WHERE origin = ?1 AND id NOT IN (SELECT id FROM _live_ids)";
-            let result = sqlx::query(chunks_query)
-                .bind(&origin_str)
-                .execute(&mut *tx)
-                .await?;
+            let deleted = super::cascade::delete_chunks_where(
+                &mut *tx,
+                "origin = ?1 AND id NOT IN (SELECT id FROM _live_ids)",
+                &[&origin_str],
+            )
+            .await? as u32;
 
             // NOTE on `function_calls` cleanup: `delete_phantom_chunks` is
             // called by `cli/watch.rs` AFTER `upsert_function_calls`
@@ -1699,7 +1740,6 @@ impl Store<ReadWrite> {
             // entirely — DO have an explicit `function_calls` DELETE because
             // no upsert follows; see those functions.
             tx.commit().await?;
-            let deleted = result.rows_affected() as u32;
             if deleted > 0 {
                 tracing::info!(origin = %origin_str, deleted, "Removed phantom chunks");
             }
@@ -1755,17 +1795,12 @@ impl Store<ReadWrite> {
 
                 if live_ids.is_empty() {
                     // Whole file emptied — delete every chunk for the origin.
-                    sqlx::query(
-                        "DELETE FROM chunks_fts WHERE id IN \
-                         (SELECT id FROM chunks WHERE origin = ?1)",
+                    let deleted = super::cascade::delete_chunks_where(
+                        &mut *tx,
+                        "origin = ?1",
+                        &[&origin_str],
                     )
-                    .bind(&origin_str)
-                    .execute(&mut *tx)
                     .await?;
-                    let result = sqlx::query("DELETE FROM chunks WHERE origin = ?1")
-                        .bind(&origin_str)
-                        .execute(&mut *tx)
-                        .await?;
                     // Chunks + FTS only. This primitive makes NO call-graph
                     // decision: `function_calls` is replaced per-file from the
                     // parsed set by the single parse-driven writer, never gated
@@ -1774,7 +1809,7 @@ impl Store<ReadWrite> {
                     // destroy legitimate edges). No `file_registry` DELETE
                     // either: zero-chunk finalization stamps the registry right
                     // after this prune.
-                    deleted_total += result.rows_affected() as u32;
+                    deleted_total += deleted as u32;
                     continue;
                 }
 
@@ -1799,21 +1834,12 @@ impl Store<ReadWrite> {
                     stmt.execute(&mut *tx).await?;
                 }
 
-                let fts_query = "DELETE FROM chunks_fts WHERE id IN \
-                     (SELECT id FROM chunks WHERE origin = ?1 \
-                      AND id NOT IN (SELECT id FROM _live_ids))";
-                sqlx::query(fts_query)
-                    .bind(&origin_str)
-                    .execute(&mut *tx)
-                    .await?;
-
-                let chunks_query = "DELETE FROM chunks WHERE origin = ?1 \
-                     AND id NOT IN (SELECT id FROM _live_ids)";
-                let result = sqlx::query(chunks_query)
-                    .bind(&origin_str)
-                    .execute(&mut *tx)
-                    .await?;
-                let deleted = result.rows_affected() as u32;
+                let deleted = super::cascade::delete_chunks_where(
+                    &mut *tx,
+                    "origin = ?1 AND id NOT IN (SELECT id FROM _live_ids)",
+                    &[&origin_str],
+                )
+                .await? as u32;
                 if deleted > 0 {
                     tracing::info!(
                         origin = %origin_str,
@@ -1902,6 +1928,69 @@ mod tests {
         );
     }
 
+    /// v33: reindexing a file where `load_cfg` became `read_cfg` (body
+    /// otherwise identical) records a rename edge, carries the LLM summary to
+    /// the new content hash, and resolves the old name forward.
+    #[test]
+    fn test_fused_reindex_records_rename_and_carries_summary() {
+        let (store, _dir) = setup_store();
+        let emb = mock_embedding(1.0);
+        let file = std::path::Path::new("src/r.rs");
+
+        let old = make_chunk("load_cfg", "src/r.rs");
+        store
+            .upsert_chunks_calls_and_prune(
+                &[(old.clone(), emb.clone())],
+                Some(1),
+                &[],
+                Some(file),
+                &[old.id.as_str()],
+            )
+            .unwrap();
+        store
+            .upsert_summaries_batch(&[(
+                old.content_hash.clone(),
+                "Loads the config.".to_string(),
+                "test-model".to_string(),
+                "summary".to_string(),
+            )])
+            .unwrap();
+
+        let new = make_chunk("read_cfg", "src/r.rs");
+        store
+            .upsert_chunks_calls_and_prune(
+                &[(new.clone(), emb)],
+                Some(2),
+                &[],
+                Some(file),
+                &[new.id.as_str()],
+            )
+            .unwrap();
+
+        let history = store.rename_history("load_cfg").unwrap();
+        assert_eq!(history.len(), 1);
+        assert_eq!(history[0].old_name, "load_cfg");
+        assert_eq!(history[0].new_name, "read_cfg");
+        assert_eq!(history[0].similarity, 1.0);
+        assert_eq!(store.rename_history("read_cfg").unwrap(), history);
+        assert_eq!(
+            store
+                .current_name("src/r.rs", "load_cfg")
+                .unwrap()
+                .as_deref(),
+            Some("read_cfg")
+        );
+
+        let summaries = store
+            .get_summaries_by_hashes(&[new.content_hash.as_str()], "summary")
+            .unwrap();
+        assert_eq!(
+            summaries.get(&new.content_hash).map(String::as_str),
+            Some("Loads the config."),
+            "summary must follow the rename to the new content hash"
+        );
+    }
+
     /// #1835 `upsert_file_fused`: the bulk per-file fused write stamps the
     /// reconcile fingerprint (chunk-row columns + `file_registry` shadow) INSIDE
     /// the same transaction as chunks/FTS/calls/function_calls/prune. After a
@@ -92,6 +92,9 @@ impl<Mode> Store<Mode> {
     /// (pre-v28, or never written) are skipped by the `IS NOT NULL` filter so a
     /// NULL is a clean cache miss, never a wrong hit on the empty-string key.
     ///
+    /// Chunk tombstones (v37) are searched too: a deleted file restored
+    /// within the retention window reuses its old vectors.
+    ///
     /// Mirrors `get_embeddings_by_hashes` (same finiteness guard, same
     /// batching) but selects + keys by `canonical_hash`.
     pub fn get_embeddings_by_canonical_hashes(
@@ -107,25 +110,34 @@ impl<Mode> Store<Mode> {
             return Ok(HashMap::new());
         }
 
-        const BATCH_SIZE: usize = max_rows_per_statement(1);
+        // Each hash is bound twice, once per arm of the UNION.
+        const BATCH_SIZE: usize = max_rows_per_statement(2);
         let dim = self.dim;
         let mut result = HashMap::new();
 
         self.rt.block_on(async {
             for batch in canonical_hashes.chunks(BATCH_SIZE) {
                 let placeholders = crate::store::helpers::make_placeholders(batch.len());
+                let tomb_placeholders =
+                    crate::store::helpers::make_placeholders_offset(batch.len(), batch.len() + 1);
                 // Same `needs_embedding = 0` gate as `get_embeddings_by_hashes`:
                 // zero-vec sentinels must be a cache miss, never a reuse hit.
+                // Tombstones only ever hold real embeddings. They sort first
+                // so a live row with the same hash overwrites them below.
                 let sql = format!(
-                    "SELECT canonical_hash, embedding FROM chunks \
+                    "SELECT canonical_hash, embedding, 0 AS tomb FROM chunks \
                      WHERE needs_embedding = 0 \
-                       AND canonical_hash IS NOT NULL AND canonical_hash IN ({})",
-                    placeholders
+                       AND canonical_hash IS NOT NULL AND canonical_hash IN ({}) \
+                     UNION ALL \
+                     SELECT canonical_hash, embedding, 1 AS tomb FROM chunk_tombstones \
+                     WHERE canonical_hash IN ({}) \
+                     ORDER BY tomb DESC",
+                    placeholders, tomb_placeholders
                 );
 
                 let rows: Vec<_> = {
                     let mut q = sqlx::query(sqlx::AssertSqlSafe(sql.as_str()));
-                    for hash in batch {
+                    for hash in batch.iter().chain(batch) {
                         q = q.bind(*hash);
                     }
                     q.fetch_all(&self.pool).await?
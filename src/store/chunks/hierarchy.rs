@@ -0,0 +1,179 @@
+//! Chunk containment hierarchy (schema v35).
+//!
+//! The parser emits one flat chunk per symbol: a class and each of its methods
+//! are separate rows with overlapping line ranges. `chunks.container_id`
+//! records the tree those ranges imply — method → class/impl → (file) — so a
+//! search hit on a method can reach its enclosing class in one lookup, and a
+//! class chunk can be summarized independently of its methods.
+//!
+//! A chunk's container is the smallest chunk in the same origin whose line
+//! range covers it and spans strictly more lines. The strict span comparison
+//! keeps siblings with identical ranges (a decorator and its function) from
+//! pointing at each other and makes cycles impossible. Windows are never
+//! containers — they overlap each other and their logical parent is already
+//! `parent_id`. Top-level chunks keep a NULL container: the file itself is the
+//! root, addressed by `origin`.
+//!
+//! `chunks.parent_id` is unchanged: it still names the logical parent of a
+//! window or a table chunk. `container_id` is the structural parent.
+
+use std::collections::HashMap;
+
+use sqlx::Row;
+
+use crate::store::helpers::sql::max_rows_per_statement;
+use crate::store::helpers::StoreError;
+use crate::store::Store;
+
+/// Assignment shared by the per-file write path and the v35 backfill. The
+/// caller appends the row scope (`WHERE origin = ?1` or nothing).
+pub(in crate::store) const ASSIGN_CONTAINERS_SQL: &str = "UPDATE chunks SET container_id = (\
+     SELECT p.id FROM chunks p \
+     WHERE p.origin = chunks.origin \
+       AND p.id != chunks.id \
+       AND p.window_idx IS NULL \
+       AND p.line_start <= chunks.line_start \
+       AND p.line_end >= chunks.line_end \
+       AND (p.line_end - p.line_start) > (chunks.line_end - chunks.line_start) \
+     ORDER BY (p.line_end - p.line_start) ASC, p.line_start DESC, p.id ASC \
+     LIMIT 1)";
+
+/// Recompute `container_id` for every chunk of `origin`. Runs inside the
+/// per-file reindex transaction after the phantom prune, so a removed class
+/// never lingers as a container and a newly added one is picked up at once.
+pub(super) async fn assign_containers_in_tx(
+    tx: &mut sqlx::Transaction<'_, sqlx::Sqlite>,
+    origin: &str,
+) -> Result<u64, StoreError> {
+    let sql = format!("{ASSIGN_CONTAINERS_SQL} WHERE origin = ?1");
+    let result = sqlx::query(sqlx::AssertSqlSafe(sql.as_str()))
+        .bind(origin)
+        .execute(&mut **tx)
+        .await?;
+    Ok(result.rows_affected())
+}
+
+impl<Mode> Store<Mode> {
+    /// Batch-fetch the enclosing container of each chunk id.
+    ///
+    /// Returns chunk id → container id for the ids that have one; top-level
+    /// chunks and unknown ids are absent. Pair with
+    /// [`get_chunks_by_ids`](Self::get_chunks_by_ids) to load the containers.
+    pub fn get_container_ids(&self, ids: &[&str]) -> Result<HashMap<String, String>, StoreError> {
+        let _span = tracing::debug_span!("get_container_ids", count = ids.len()).entered();
+        let mut out = HashMap::new();
+        if ids.is_empty() {
+            return Ok(out);
+        }
+        self.rt.block_on(async {
+            for batch in ids.chunks(max_rows_per_statement(1)) {
+                let placeholders = crate::store::helpers::make_placeholders(batch.len());
+                let sql = format!(
+                    "SELECT id, container_id FROM chunks \
+                     WHERE id IN ({placeholders}) AND container_id IS NOT NULL"
+                );
+                let mut q = sqlx::query(sqlx::AssertSqlSafe(sql.as_str()));
+                for id in batch {
+                    q = q.bind(*id);
+                }
+                for row in q.fetch_all(&self.pool).await? {
+                    out.insert(row.get::<String, _>(0), row.get::<String, _>(1));
+                }
+            }
+            Ok(out)
+        })
+    }
+
+    /// Ids of the chunks directly contained by `container_id`, in source
+    /// order. Empty for a leaf or an unknown id.
+    pub fn get_contained_ids(&self, container_id: &str) -> Result<Vec<String>, StoreError> {
+        let _span = tracing::debug_span!("get_contained_ids", container_id).entered();
+        self.rt.block_on(async {
+            let rows: Vec<(String,)> = sqlx::query_as(
+                "SELECT id FROM chunks WHERE container_id = ?1 ORDER BY line_start, id",
+            )
+            .bind(container_id)
+            .fetch_all(&self.pool)
+            .await?;
+            Ok(rows.into_iter().map(|(id,)| id).collect())
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::super::test_utils::make_chunk;
+    use crate::parser::{Chunk, ChunkType};
+    use crate::test_helpers::{mock_embedding, setup_store};
+
+    fn ranged(name: &str, chunk_type: ChunkType, start: u32, end: u32) -> Chunk {
+        let mut c = make_chunk(name, "src/h.rs");
+        c.id = format!("src/h.rs:{start}:{name}");
+        c.chunk_type = chunk_type;
+        c.line_start = start;
+        c.line_end = end;
+        c
+    }
+
+    fn write(store: &crate::Store, chunks: &[Chunk]) {
+        let emb = mock_embedding(1.0);
+        let pairs: Vec<_> = chunks.iter().map(|c| (c.clone(), emb.clone())).collect();
+        let ids: Vec<&str> = chunks.iter().map(|c| c.id.as_str()).collect();
+        store
+            .upsert_chunks_calls_and_prune(
+                &pairs,
+                Some(1),
+                &[],
+                Some(std::path::Path::new("src/h.rs")),
+                &ids,
+            )
+            .unwrap();
+    }
+
+    /// file → impl → method: the method's container is the impl (the smallest
+    /// covering chunk), the impl and the free function are top-level.
+    #[test]
+    fn containers_follow_smallest_covering_range() {
+        let (store, _dir) = setup_store();
+        let imp = ranged("Parser", ChunkType::Impl, 10, 40);
+        let method = ranged("parse", ChunkType::Method, 12, 20);
+        let free = ranged("helper", ChunkType::Function, 50, 55);
+        write(&store, &[imp.clone(), method.clone(), free.clone()]);
+
+        let ids = [imp.id.as_str(), method.id.as_str(), free.id.as_str()];
+        let containers = store.get_container_ids(&ids).unwrap();
+        assert_eq!(containers.len(), 1);
+        assert_eq!(containers.get(&method.id), Some(&imp.id));
+        assert_eq!(store.get_contained_ids(&imp.id).unwrap(), vec![method.id]);
+    }
+
+    /// Removing the enclosing chunk on reindex clears the child's container
+    /// in the same write.
+    #[test]
+    fn container_cleared_when_parent_removed() {
+        let (store, _dir) = setup_store();
+        let imp = ranged("Parser", ChunkType::Impl, 10, 40);
+        let method = ranged("parse", ChunkType::Method, 12, 20);
+        write(&store, &[imp, method.clone()]);
+        write(&store, std::slice::from_ref(&method));
+
+        assert!(store
+            .get_container_ids(&[method.id.as_str()])
+            .unwrap()
+            .is_empty());
+    }
+
+    /// Equal ranges never contain each other.
+    #[test]
+    fn equal_ranges_are_siblings() {
+        let (store, _dir) = setup_store();
+        let a = ranged("a", ChunkType::Function, 5, 9);
+        let b = ranged("b", ChunkType::Function, 5, 9);
+        write(&store, &[a.clone(), b.clone()]);
+
+        assert!(store
+            .get_container_ids(&[a.id.as_str(), b.id.as_str()])
+            .unwrap()
+            .is_empty());
+    }
+}
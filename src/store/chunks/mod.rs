@@ -6,13 +6,20 @@
 //! - `embeddings` - embedding retrieval by hash
 //! - `query` - chunk retrieval, search, identity, stats
 //! - `async_helpers` - async fetch, batch insert, EmbeddingBatchIterator
+//! - `hierarchy` - containment tree (method → class → file), schema v35
+//! - `tombstones` - deleted files' chunks kept for embedding reuse, schema v37
+//! - `cascade` - the one chunk delete routine, dependent-orphan checks
 
 mod async_helpers;
+pub(crate) mod cascade;
 mod crud;
 mod embeddings;
+pub(super) mod hierarchy;
 mod query;
 pub mod staleness;
+mod tombstones;
 
+pub use cascade::DependentOrphans;
 pub use query::GET_CHUNKS_BY_NAME_LIMIT;
 pub use staleness::PruneAllResult;
 
@@ -20,25 +27,37 @@ pub use staleness::PruneAllResult;
 // to sibling modules (crud.rs) via `super::async_helpers::`.
 
 #[cfg(test)]
-pub(super) mod test_utils {
+pub(crate) mod test_utils {
     use crate::parser::{Chunk, ChunkType, Language};
     use std::path::PathBuf;
 
     /// Creates a mock Rust function chunk with generated content and hash.
-    pub(super) fn make_chunk(name: &str, file: &str) -> Chunk {
+    pub(crate) fn make_chunk(name: &str, file: &str) -> Chunk {
         let content = format!("fn {}() {{ /* body */ }}", name);
+        make_chunk_with(name, file, 1, 5, &content)
+    }
+
+    /// [`make_chunk`] spanning `line_start..=line_end` with `content`; the
+    /// id and hash follow both.
+    pub(crate) fn make_chunk_with(
+        name: &str,
+        file: &str,
+        line_start: u32,
+        line_end: u32,
+        content: &str,
+    ) -> Chunk {
         let hash = blake3::hash(content.as_bytes()).to_hex().to_string();
         Chunk {
-            id: format!("{}:1:{}", file, &hash[..8]),
+            id: format!("{}:{}:{}", file, line_start, &hash[..8]),
             file: PathBuf::from(file),
             language: Language::Rust,
             chunk_type: ChunkType::Function,
             name: name.to_string(),
             signature: format!("fn {}()", name),
-            content,
+            content: content.to_string(),
             doc: None,
-            line_start: 1,
-            line_end: 5,
+            line_start,
+            line_end,
             byte_start: 0,
             content_hash: hash,
             canonical_hash: String::new(),
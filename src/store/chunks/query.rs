@@ -7,7 +7,6 @@ use std::sync::LazyLock;
 use sqlx::Row;
 
 use crate::embedder::Embedding;
-use crate::nl::normalize_for_fts;
 use crate::parser::{ChunkType, Language};
 use crate::store::helpers::sql::max_rows_per_statement;
 use crate::store::helpers::{
@@ -147,7 +146,7 @@ impl<Mode> Store<Mode> {
             let (total_chunks, total_files): (i64, i64) = sqlx::query_as(
                 "SELECT
                     (SELECT COUNT(*) FROM chunks),
-                    (SELECT COUNT(DISTINCT origin) FROM chunks)",
+                    (SELECT COUNT(DISTINCT origin) FROM chunks WHERE source_type = 'file')",
             )
             .fetch_one(&self.pool)
             .await?;
@@ -241,15 +240,18 @@ impl<Mode> Store<Mode> {
     /// Get all chunks for a given file (origin).
     /// Returns chunks sorted by line_start. Used by `cqs context` to list
     /// all functions/types in a file.
+    /// `origin` is slash-normalized first, so a Windows-style `src\lib.rs`
+    /// finds the `src/lib.rs` rows written at index time.
     pub fn get_chunks_by_origin(&self, origin: &str) -> Result<Vec<ChunkSummary>, StoreError> {
         let _span = tracing::debug_span!("get_chunks_by_origin", origin = %origin).entered();
+        let origin = crate::normalize_slashes(origin);
         self.rt.block_on(async {
             let sql = format!(
                 "SELECT {cols} FROM chunks WHERE origin = ?1 ORDER BY line_start",
                 cols = crate::store::helpers::CHUNK_ROW_SELECT_COLUMNS,
             );
             let rows: Vec<_> = sqlx::query(sqlx::AssertSqlSafe(sql.as_str()))
-                .bind(origin)
+                .bind(&origin)
                 .fetch_all(&self.pool)
                 .await?;
 
@@ -261,7 +263,8 @@ impl<Mode> Store<Mode> {
     }
 
     /// Batch-fetch chunks by multiple origin paths.
-    /// Returns a map of origin -> Vec<ChunkSummary> for all found origins.
+    /// Returns a map of origin -> Vec<ChunkSummary> for all found origins,
+    /// keyed by the stored (forward-slash) origin.
     /// Batches queries in groups of 500 to stay within SQLite's parameter limit (~999).
     /// Used by `cqs where` to avoid N+1 `get_chunks_by_origin` calls.
     pub fn get_chunks_by_origins_batch(
@@ -288,7 +291,7 @@ impl<Mode> Store<Mode> {
 
                 let mut query = sqlx::query(sqlx::AssertSqlSafe(sql.as_str()));
                 for origin in batch {
-                    query = query.bind(*origin);
+                    query = query.bind(crate::normalize_slashes(origin));
                 }
 
                 let rows: Vec<_> = query.fetch_all(&self.pool).await?;
@@ -304,6 +307,132 @@ impl<Mode> Store<Mode> {
         })
     }
 
+    /// IDs of every chunk whose origin is in `origins`.
+    /// Batches queries like [`Self::get_chunks_by_origins_batch`] but selects
+    /// only the `id` column. Used to build the vector-index traversal
+    /// predicate for an origin-restricted search (`SearchFilter::origins`).
+    pub fn chunk_ids_for_origins(
+        &self,
+        origins: &std::collections::HashSet<String>,
+    ) -> Result<std::collections::HashSet<String>, StoreError> {
+        let _span = tracing::debug_span!("chunk_ids_for_origins", count = origins.len()).entered();
+        if origins.is_empty() {
+            return Ok(std::collections::HashSet::new());
+        }
+        let origins: Vec<&str> = origins.iter().map(String::as_str).collect();
+
+        self.rt.block_on(async {
+            let mut ids = std::collections::HashSet::new();
+
+            const BATCH_SIZE: usize = max_rows_per_statement(1);
+            for batch in origins.chunks(BATCH_SIZE) {
+                let placeholders = crate::store::helpers::make_placeholders(batch.len());
+                let sql = format!("SELECT id FROM chunks WHERE origin IN ({placeholders})");
+
+                let mut query = sqlx::query(sqlx::AssertSqlSafe(sql.as_str()));
+                for origin in batch {
+                    query = query.bind(crate::normalize_slashes(origin));
+                }
+
+                let rows: Vec<_> = query.fetch_all(&self.pool).await?;
+                ids.extend(rows.iter().map(|row| row.get::<String, _>(0)));
+            }
+
+            Ok(ids)
+        })
+    }
+
+    /// IDs of every chunk whose stored source text matches `pattern`.
+    ///
+    /// One read transaction paged by rowid, so peak heap is bounded by the
+    /// page size rather than the total content bytes. Used to build the
+    /// candidate allowlist for a regex-restricted search
+    /// (`SearchFilter::content_regex`).
+    pub fn chunk_ids_matching_content(
+        &self,
+        pattern: &regex::Regex,
+    ) -> Result<std::collections::HashSet<String>, StoreError> {
+        let _span = tracing::debug_span!("chunk_ids_matching_content", pattern = pattern.as_str())
+            .entered();
+        self.rt.block_on(async {
+            let mut ids = std::collections::HashSet::new();
+            let mut tx = self.pool.begin().await?;
+            const PAGE: i64 = 2048;
+            let mut last_rowid: i64 = 0;
+            loop {
+                let rows: Vec<(i64, String, Vec<u8>)> = sqlx::query_as(
+                    "SELECT rowid, id, CAST(content AS BLOB) FROM chunks
+                     WHERE rowid > ?1
+                     ORDER BY rowid
+                     LIMIT ?2",
+                )
+                .bind(last_rowid)
+                .bind(PAGE)
+                .fetch_all(&mut *tx)
+                .await?;
+
+                let Some((rowid, _, _)) = rows.last() else {
+                    break;
+                };
+                last_rowid = *rowid;
+                ids.extend(rows.into_iter().filter_map(|(_, id, stored)| {
+                    let content = crate::store::compression::decode_or_empty(stored, &id);
+                    pattern.is_match(&content).then_some(id)
+                }));
+            }
+            drop(tx);
+            tracing::debug!(matched = ids.len(), "Content regex scan complete");
+            Ok(ids)
+        })
+    }
+
+    /// IDs of every chunk whose stored signature shape matches `pattern`.
+    ///
+    /// Pages by rowid over the rows that have a shape (typed callables,
+    /// schema v41) like [`Self::chunk_ids_matching_content`]. Used to build
+    /// the candidate allowlist for a shape-restricted search
+    /// (`SearchFilter::signature`).
+    pub fn chunk_ids_matching_signature(
+        &self,
+        pattern: &crate::parser::signature::SignaturePattern,
+    ) -> Result<std::collections::HashSet<String>, StoreError> {
+        let _span = tracing::debug_span!("chunk_ids_matching_signature").entered();
+        self.rt.block_on(async {
+            let mut ids = std::collections::HashSet::new();
+            let mut tx = self.pool.begin().await?;
+            const PAGE: i64 = 2048;
+            let mut last_rowid: i64 = 0;
+            loop {
+                let rows: Vec<(i64, String, String)> = sqlx::query_as(
+                    "SELECT rowid, id, signature_shape FROM chunks
+                     WHERE rowid > ?1 AND signature_shape IS NOT NULL
+                     ORDER BY rowid
+                     LIMIT ?2",
+                )
+                .bind(last_rowid)
+                .bind(PAGE)
+                .fetch_all(&mut *tx)
+                .await?;
+
+                let Some((rowid, _, _)) = rows.last() else {
+                    break;
+                };
+                last_rowid = *rowid;
+                ids.extend(
+                    rows.into_iter()
+                        .filter(|(_, _, shape)| {
+                            crate::parser::signature::SignatureShape::parse(shape)
+                                .is_some_and(|s| pattern.matches(&s))
+                        })
+                        .map(|(_, id, _)| id),
+                );
+            }
+            drop(tx);
+            tracing::debug!(matched = ids.len(), "Signature shape scan complete");
+            Ok(ids)
+        })
+    }
+
     /// Exact-match lookup: return chunks whose `name` equals `name`,
     /// ordered by routing priority (callables, then types, then consts,
     /// then modules — see [`crate::kind::routing_priority`]) with
@@ -517,7 +646,12 @@ impl<Mode> Store<Mode> {
             // Normalize and sanitize all names upfront, keeping originals for scoring
             let normalized_names: Vec<(&str, String)> = names
                 .iter()
-                .map(|n| (*n, crate::store::sanitize_fts_query(&normalize_for_fts(n))))
+                .map(|n| {
+                    (
+                        *n,
+                        crate::store::sanitize_fts_query(&self.fts_analyzer.query(n)),
+                    )
+                })
                 .filter(|(_, norm)| !norm.is_empty())
                 .collect();
 
@@ -676,7 +810,7 @@ impl<Mode> Store<Mode> {
         let _span = tracing::debug_span!("chunks_paged", after_rowid, limit).entered();
         self.rt.block_on(async {
             // rowid appended AFTER the pinned ChunkRow columns so the
-            // ordinal contract for `ChunkRow::from_row` (0..15) holds.
+            // ordinal contract for `ChunkRow::from_row` (0..17) holds.
             let sql = format!(
                 "SELECT {cols}, rowid FROM chunks WHERE rowid > ?1 \
                  ORDER BY rowid ASC LIMIT ?2",
@@ -692,7 +826,7 @@ impl<Mode> Store<Mode> {
             let chunks: Vec<ChunkSummary> = rows
                 .iter()
                 .map(|row| {
-                    let rowid: i64 = row.get(16);
+                    let rowid: i64 = row.get(18);
                     if rowid > max_rowid {
                         max_rowid = rowid;
                     }
@@ -716,14 +850,14 @@ impl<Mode> Store<Mode> {
         self.rt.block_on(async {
             let rows: Vec<_> = if let Some(lang) = language {
                 sqlx::query(
-                    "SELECT id, origin, name, chunk_type, language, line_start, parent_id, window_idx FROM chunks WHERE language = ?1",
+                    "SELECT id, origin, name, chunk_type, language, line_start, parent_id, window_idx, content_hash FROM chunks WHERE language = ?1",
                 )
                 .bind(lang)
                 .fetch_all(&self.pool)
                 .await?
             } else {
                 sqlx::query(
-                    "SELECT id, origin, name, chunk_type, language, line_start, parent_id, window_idx FROM chunks",
+                    "SELECT id, origin, name, chunk_type, language, line_start, parent_id, window_idx, content_hash FROM chunks",
                 )
                 .fetch_all(&self.pool)
                 .await?
@@ -754,6 +888,7 @@ impl<Mode> Store<Mode> {
                     window_idx: row
                         .get::<Option<i64>, _>("window_idx")
                         .map(|i| i.clamp(0, u32::MAX as i64) as u32),
+                    content_hash: row.get("content_hash"),
                 })
                 .collect())
         })
@@ -837,6 +972,24 @@ mod tests {
         assert!(chunks.is_empty());
     }
 
+    #[test]
+    fn test_get_chunks_by_origin_backslash_lookup() {
+        let (store, _dir) = setup_store();
+        let c = make_chunk("fn_a", "src/cli/mod.rs");
+        store
+            .upsert_chunks_batch(&[(c, mock_embedding(1.0))], Some(100))
+            .unwrap();
+
+        assert_eq!(
+            store.get_chunks_by_origin(r"src\cli\mod.rs").unwrap().len(),
+            1
+        );
+        let batch = store
+            .get_chunks_by_origins_batch(&[r"src\cli\mod.rs"])
+            .unwrap();
+        assert_eq!(batch.get("src/cli/mod.rs").map(Vec::len), Some(1));
+    }
+
     // ===== TC-11: chunks_paged =====
 
     #[test]
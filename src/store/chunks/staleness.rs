@@ -266,40 +266,43 @@ fn build_normalized_set(existing_files: &HashSet<PathBuf>) -> HashSet<String> {
 /// over the same `file` values is required. The sparse sweep mirrors the FK
 /// CASCADE the table can't express via SQL alone.
 ///
+/// With `tombstone`, the origins' embedded chunks are first copied into
+/// `chunk_tombstones` (v37) and expired tombstones purged — the paths that
+/// remove files gone from disk pass `true`; `prune_gitignored` passes `false`
+/// because the content still exists.
+///
 /// `origins` must be non-empty; callers short-circuit on the empty case.
-async fn delete_origins_in_tx(
+pub(super) async fn delete_origins_in_tx(
     tx: &mut sqlx::Transaction<'_, sqlx::Sqlite>,
     origins: &[String],
     span_label: &'static str,
+    tombstone: bool,
 ) -> Result<u32, StoreError> {
     // Single-bind IN-list batched at the modern SQLite variable limit. The
     // caller's single transaction wraps ALL batches — a partial prune on
     // crash would leave the index inconsistent with disk.
     const BATCH_SIZE: usize = max_rows_per_statement(1);
     let mut deleted = 0u32;
+    let mut tombstoned = 0u64;
+
+    let tombstone_at = if tombstone {
+        super::tombstones::begin_tombstoning(tx).await?
+    } else {
+        None
+    };
 
     for batch in origins.chunks(BATCH_SIZE) {
         let placeholder_str = crate::store::helpers::make_placeholders(batch.len());
 
-        // Delete from FTS first.
-        let fts_query = format!(
-            "DELETE FROM chunks_fts WHERE id IN (SELECT id FROM chunks WHERE origin IN ({}))",
-            placeholder_str
-        );
-        let mut fts_stmt = sqlx::query(sqlx::AssertSqlSafe(fts_query.as_str()));
-        for origin in batch {
-            fts_stmt = fts_stmt.bind(origin);
+        // Copy before the DELETE below takes the rows away.
+        if let Some(now) = tombstone_at {
+            tombstoned += super::tombstones::tombstone_origins_in_tx(tx, batch, now).await?;
         }
-        fts_stmt.execute(&mut **tx).await?;
 
-        // Delete from chunks.
-        let chunks_query = format!("DELETE FROM chunks WHERE origin IN ({})", placeholder_str);
-        let mut chunks_stmt = sqlx::query(sqlx::AssertSqlSafe(chunks_query.as_str()));
-        for origin in batch {
-            chunks_stmt = chunks_stmt.bind(origin);
-        }
-        let result = chunks_stmt.execute(&mut **tx).await?;
-        deleted += result.rows_affected() as u32;
+        // Chunks with their FTS rows; the FK cascade takes the rest.
+        let predicate = format!("origin IN ({})", placeholder_str);
+        let binds: Vec<&str> = batch.iter().map(String::as_str).collect();
+        deleted += super::cascade::delete_chunks_where(&mut **tx, &predicate, &binds).await? as u32;
 
         // Delete orphan `function_calls` for the same files. Without this,
         // prune leaves call-graph rows that surface as ghost callers in
@@ -344,6 +347,10 @@ async fn delete_origins_in_tx(
         registry_stmt.execute(&mut **tx).await?;
     }
 
+    if tombstoned > 0 {
+        tracing::debug!(tombstoned, label = span_label, "Tombstoned deleted chunks");
+    }
+
     // Sweep orphan sparse_vectors inside the same transaction so no window
     // exists where stale sparse vectors inflate the SPLADE index.
     if deleted > 0 {
@@ -375,6 +382,9 @@ pub struct PruneAllResult {
     pub pruned_calls: u64,
     /// Orphan `type_edges` rows removed.
     pub pruned_type_edges: u64,
+    /// Other chunk dependents (FTS, `calls`, sparse, git metadata, embedding
+    /// versions) removed because their chunk was already gone.
+    pub pruned_dependents: u64,
     /// Orphan `llm_summaries` rows removed.
     pub pruned_summaries: usize,
 }
@@ -436,7 +446,7 @@ impl Store<ReadWrite> {
 
             // Delete FTS + chunks + function_calls per batch and sweep orphan
             // sparse_vectors — shared with prune_all / prune_gitignored.
-            let deleted = delete_origins_in_tx(&mut tx, &missing, "prune_missing").await?;
+            let deleted = delete_origins_in_tx(&mut tx, &missing, "prune_missing", true).await?;
 
             tx.commit().await?;
 
@@ -538,21 +548,29 @@ impl Store<ReadWrite> {
             let pruned_chunks = if missing.is_empty() {
                 0u32
             } else {
-                delete_origins_in_tx(&mut tx, &missing, "prune_all").await?
+                delete_origins_in_tx(&mut tx, &missing, "prune_all", true).await?
             };
 
-            // 2c. Delete orphan type_edges (source_chunk_id no longer in chunks)
-            let types_result = sqlx::query(
-                "DELETE FROM type_edges WHERE source_chunk_id NOT IN (SELECT id FROM chunks)",
-            )
-            .execute(&mut *tx)
-            .await?;
-            let pruned_type_edges = types_result.rows_affected();
+            // 2c. Delete dependents whose chunk is gone — rows the FK cascade
+            // never saw (pre-FK indexes, enforcement off). type_edges keeps
+            // its own count; the rest roll up into `pruned_dependents`.
+            let mut pruned_type_edges = 0;
+            let mut pruned_dependents = 0;
+            for orphans in super::cascade::purge_dependent_orphans(&mut tx).await? {
+                if orphans.table == "type_edges" {
+                    pruned_type_edges = orphans.rows;
+                } else {
+                    pruned_dependents += orphans.rows;
+                }
+            }
 
-            // 2d. Delete orphan LLM summaries (content_hash no longer in any chunk)
+            // 2d. Delete orphan LLM summaries (content_hash no longer in any
+            // chunk). A tombstoned chunk keeps its summary so a restored file
+            // comes back whole.
             let summaries_result = sqlx::query(
                 "DELETE FROM llm_summaries WHERE content_hash NOT IN \
-                 (SELECT DISTINCT content_hash FROM chunks)",
+                 (SELECT content_hash FROM chunks \
+                  UNION SELECT content_hash FROM chunk_tombstones)",
             )
             .execute(&mut *tx)
             .await?;
@@ -584,6 +602,7 @@ impl Store<ReadWrite> {
                 pruned_chunks,
                 pruned_calls,
                 pruned_type_edges,
+                pruned_dependents,
                 pruned_summaries,
             })
         })
@@ -673,7 +692,8 @@ impl Store<ReadWrite> {
             // this routed through the shared helper, prune_gitignored skipped
             // the function_calls DELETE entirely, leaving orphan call-graph
             // rows (ghost callers) for gitignore-driven prunes.
-            let deleted = delete_origins_in_tx(&mut tx, &ignored, "prune_gitignored").await?;
+            let deleted =
+                delete_origins_in_tx(&mut tx, &ignored, "prune_gitignored", false).await?;
 
             tx.commit().await?;
 
@@ -690,6 +710,21 @@ impl Store<ReadWrite> {
     }
 }
 
+/// Everything the index records about one file origin, for
+/// `cqs explain-index`.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct OriginRecord {
+    /// File chunks stored for the origin.
+    pub chunk_count: u64,
+    /// `updated_at` (RFC 3339) of the most recently written chunk.
+    pub last_indexed: Option<String>,
+    /// Stored fingerprint from the chunks or `file_registry`; `None` when
+    /// neither has a row for the origin.
+    pub fingerprint: Option<FileFingerprint>,
+    /// Parser version the file last failed to parse at (v31 marker).
+    pub parse_failed_parser_version: Option<u32>,
+}
+
 impl<Mode> Store<Mode> {
     /// Count files that are stale (fingerprint diverged) or missing from disk.
     /// Compares the stored fingerprint against current filesystem state.
@@ -1098,7 +1133,8 @@ impl<Mode> Store<Mode> {
                 // passed to the per-query staleness check resolves against its
                 // persisted fingerprint instead of being absent (which would
                 // silently skip the staleness warning). Two `origin IN (...)`
-                // placeholder runs, bound in order below.
+                // placeholder runs, bound in order below. Commit chunks
+                // (`source_type = 'commit'`) have no file behind them.
                 let sql = format!(
                     "SELECT origin, \
                             MAX(source_mtime) AS mtime, \
@@ -1106,7 +1142,7 @@ impl<Mode> Store<Mode> {
                             MAX(source_content_hash) AS content_hash \
                      FROM ( \
                          SELECT origin, source_mtime, source_size, source_content_hash \
-                         FROM chunks WHERE origin IN ({}) \
+                         FROM chunks WHERE origin IN ({}) AND source_type = 'file' \
                          UNION ALL \
                          SELECT origin, source_mtime, source_size, source_content_hash \
                          FROM file_registry WHERE origin IN ({}) \
@@ -1165,6 +1201,42 @@ impl<Mode> Store<Mode> {
             Ok(stale)
         })
     }
+
+    /// What the index holds for a single origin: chunk count, last write,
+    /// stored fingerprint, and any recorded parse failure. Same chunks ∪
+    /// `file_registry` fingerprint union as [`Self::fingerprints_for_origins`],
+    /// so a file that parsed to zero chunks still reports its fingerprint.
+    pub fn origin_record(&self, origin: &str) -> Result<OriginRecord, StoreError> {
+        let _span = tracing::debug_span!("origin_record", origin = %origin).entered();
+        let origin = crate::normalize_slashes(origin);
+        let (chunk_count, last_indexed, parse_failed) = self.rt.block_on(async {
+            let (chunk_count, last_indexed): (i64, Option<String>) = sqlx::query_as(
+                "SELECT COUNT(*), MAX(updated_at) FROM chunks \
+                 WHERE source_type = 'file' AND origin = ?1",
+            )
+            .bind(&origin)
+            .fetch_one(&self.pool)
+            .await?;
+            let parse_failed: Option<(Option<i64>,)> = sqlx::query_as(
+                "SELECT parse_failed_parser_version FROM file_registry WHERE origin = ?1",
+            )
+            .bind(&origin)
+            .fetch_optional(&self.pool)
+            .await?;
+            Ok::<_, StoreError>((chunk_count, last_indexed, parse_failed))
+        })?;
+        let fingerprint = self
+            .fingerprints_for_origins(&[origin.as_str()])?
+            .remove(&origin);
+        Ok(OriginRecord {
+            chunk_count: u64::try_from(chunk_count).unwrap_or(0),
+            last_indexed,
+            fingerprint,
+            parse_failed_parser_version: parse_failed
+                .and_then(|(v,)| v)
+                .and_then(|v| u32::try_from(v).ok()),
+        })
+    }
 }
 
 #[cfg(test)]
@@ -2662,4 +2734,27 @@ mod tests {
             "metadata() failure must surface as None — the leave-to-GC signal that reconcile.rs:188 relies on"
         );
     }
+
+    #[test]
+    fn test_origin_record_counts_chunks_and_fingerprint() {
+        let (store, _dir) = setup_store();
+        let empty = store.origin_record("src/none.rs").unwrap();
+        assert_eq!(empty, super::OriginRecord::default());
+
+        let a = make_chunk("one", "src/rec.rs");
+        let mut b = make_chunk("two", "src/rec.rs");
+        b.id = "src/rec.rs:9:two".to_string();
+        store
+            .upsert_chunks_batch(
+                &[(a, mock_embedding(1.0)), (b, mock_embedding(1.0))],
+                Some(1234),
+            )
+            .unwrap();
+
+        let rec = store.origin_record("src\\rec.rs").unwrap();
+        assert_eq!(rec.chunk_count, 2);
+        assert!(rec.last_indexed.is_some());
+        assert_eq!(rec.fingerprint.and_then(|fp| fp.mtime), Some(1234));
+        assert_eq!(rec.parse_failed_parser_version, None);
+    }
 }
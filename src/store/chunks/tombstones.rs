@@ -0,0 +1,218 @@
+// WRITE_LOCK guard is held across .await inside block_on(). Safe because
+// block_on runs single-threaded — no concurrent tasks can deadlock.
+#![allow(clippy::await_holding_lock)]
+//! Tombstones for chunks of deleted files (schema v37).
+//!
+//! When a file disappears from disk its chunks still leave `chunks` at once —
+//! search, FTS and the HNSW build never see them again — but the embedded
+//! ones are first copied into `chunk_tombstones` with the time of deletion.
+//! For `CQS_TOMBSTONE_RETENTION_DAYS` (default 7) the embedding-reuse lookup
+//! (`get_embeddings_by_canonical_hashes`) still finds them, so restoring the
+//! file after an accidental `rm -rf` or a branch switch re-embeds nothing.
+//! A tombstone is dropped when a chunk with its `content_hash` is written
+//! again with a real embedding, or by the next prune after its window lapses.
+//!
+//! Only the missing-file paths (`prune_missing`, `prune_all`, the watch
+//! delete event) tombstone. A gitignore prune, a model swap or a slot
+//! teardown deletes outright: the content either still exists or its vectors
+//! belong to another model.
+
+use std::path::Path;
+
+use crate::store::helpers::{make_placeholders, make_placeholders_offset, StoreError};
+use crate::store::{ReadWrite, Store};
+
+const SECS_PER_DAY: i64 = 86_400;
+
+/// Purge tombstones older than the retention window and return the stamp for
+/// new ones — `None` when tombstoning is off (`CQS_TOMBSTONE_RETENTION_DAYS=0`,
+/// which purges every tombstone) or the clock is unusable.
+pub(super) async fn begin_tombstoning(
+    tx: &mut sqlx::Transaction<'_, sqlx::Sqlite>,
+) -> Result<Option<i64>, StoreError> {
+    let Some(now) = crate::unix_secs_i64() else {
+        return Ok(None);
+    };
+    let days = crate::limits::tombstone_retention_days();
+    let window = i64::try_from(days)
+        .unwrap_or(i64::MAX)
+        .saturating_mul(SECS_PER_DAY);
+    let purged = sqlx::query("DELETE FROM chunk_tombstones WHERE deleted_at <= ?1")
+        .bind(now.saturating_sub(window))
+        .execute(&mut **tx)
+        .await?
+        .rows_affected();
+    if purged > 0 {
+        tracing::debug!(purged, days, "Purged expired chunk tombstones");
+    }
+    Ok((days > 0).then_some(now))
+}
+
+/// Copy the embedded chunks of `origins` into `chunk_tombstones`, stamped
+/// `now`. Must run before the chunk rows are deleted, in the same
+/// transaction. Chunks without a real embedding (`needs_embedding = 1`) or a
+/// canonical hash could never be reused, so they are not kept.
+pub(super) async fn tombstone_origins_in_tx(
+    tx: &mut sqlx::Transaction<'_, sqlx::Sqlite>,
+    origins: &[String],
+    now: i64,
+) -> Result<u64, StoreError> {
+    let sql = format!(
+        "INSERT OR REPLACE INTO chunk_tombstones \
+             (id, origin, name, content_hash, canonical_hash, embedding, deleted_at) \
+         SELECT id, origin, name, content_hash, canonical_hash, embedding, ?1 FROM chunks \
+         WHERE needs_embedding = 0 AND canonical_hash IS NOT NULL AND origin IN ({})",
+        make_placeholders_offset(origins.len(), 2)
+    );
+    let mut stmt = sqlx::query(sqlx::AssertSqlSafe(sql.as_str())).bind(now);
+    for origin in origins {
+        stmt = stmt.bind(origin);
+    }
+    Ok(stmt.execute(&mut **tx).await?.rows_affected())
+}
+
+/// Drop the tombstones of `content_hashes` — the chunks were just written
+/// again with real embeddings, so the live rows carry the vectors now.
+pub(super) async fn clear_tombstones_in_tx(
+    tx: &mut sqlx::Transaction<'_, sqlx::Sqlite>,
+    content_hashes: &[&str],
+) -> Result<u64, StoreError> {
+    let sql = format!(
+        "DELETE FROM chunk_tombstones WHERE content_hash IN ({})",
+        make_placeholders(content_hashes.len())
+    );
+    let mut stmt = sqlx::query(sqlx::AssertSqlSafe(sql.as_str()));
+    for hash in content_hashes {
+        stmt = stmt.bind(*hash);
+    }
+    Ok(stmt.execute(&mut **tx).await?.rows_affected())
+}
+
+impl<Mode> Store<Mode> {
+    /// Number of chunk tombstones currently held.
+    pub fn tombstone_count(&self) -> Result<u64, StoreError> {
+        let _span = tracing::debug_span!("tombstone_count").entered();
+        self.rt.block_on(async {
+            let (n,): (i64,) = sqlx::query_as("SELECT COUNT(*) FROM chunk_tombstones")
+                .fetch_one(&self.pool)
+                .await?;
+            Ok(n as u64)
+        })
+    }
+}
+
+impl Store<ReadWrite> {
+    /// Remove a file that was deleted from disk: tombstone its embedded
+    /// chunks, then delete everything the origin owns (same rows as
+    /// `delete_by_origin`). Returns the number of chunk rows deleted.
+    pub fn tombstone_by_origin(&self, origin: &Path) -> Result<u32, StoreError> {
+        let _span =
+            tracing::info_span!("tombstone_by_origin", origin = %origin.display()).entered();
+        let origins = [crate::normalize_path(origin)];
+
+        self.rt.block_on(async {
+            let (_guard, mut tx) = self.begin_write().await?;
+            let deleted = super::staleness::delete_origins_in_tx(
+                &mut tx,
+                &origins,
+                "tombstone_by_origin",
+                true,
+            )
+            .await?;
+            tx.commit().await?;
+            Ok(deleted)
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::super::test_utils::make_chunk;
+    use crate::test_helpers::{mock_embedding, setup_store};
+    use serial_test::serial;
+    use std::collections::HashSet;
+
+    #[test]
+    #[serial]
+    fn prune_missing_tombstones_and_reindex_reuses_embedding() {
+        std::env::remove_var("CQS_TOMBSTONE_RETENTION_DAYS");
+        let (store, dir) = setup_store();
+        let mut chunk = make_chunk("restored", "src/gone.rs");
+        chunk.canonical_hash = "canon_restored".to_string();
+        store
+            .upsert_chunks_batch(&[(chunk.clone(), mock_embedding(1.0))], Some(100))
+            .unwrap();
+
+        let deleted = store.prune_missing(&HashSet::new(), dir.path()).unwrap();
+        assert_eq!(deleted, 1);
+        assert_eq!(
+            store.chunk_count().unwrap(),
+            0,
+            "tombstoned chunk is not searchable"
+        );
+        assert_eq!(store.tombstone_count().unwrap(), 1);
+
+        let hits = store
+            .get_embeddings_by_canonical_hashes(&["canon_restored"])
+            .unwrap();
+        assert!(
+            hits.contains_key("canon_restored"),
+            "tombstone serves reuse"
+        );
+
+        store
+            .upsert_chunks_batch(&[(chunk, mock_embedding(1.0))], Some(200))
+            .unwrap();
+        assert_eq!(
+            store.tombstone_count().unwrap(),
+            0,
+            "re-upserted content clears its tombstone"
+        );
+    }
+
+    #[test]
+    #[serial]
+    fn zero_retention_deletes_outright_and_purges() {
+        std::env::remove_var("CQS_TOMBSTONE_RETENTION_DAYS");
+        let (store, dir) = setup_store();
+        let mut a = make_chunk("a", "src/a.rs");
+        a.canonical_hash = "canon_a".to_string();
+        store
+            .upsert_chunks_batch(&[(a, mock_embedding(1.0))], Some(100))
+            .unwrap();
+        store
+            .tombstone_by_origin(std::path::Path::new("src/a.rs"))
+            .unwrap();
+        assert_eq!(store.tombstone_count().unwrap(), 1);
+
+        std::env::set_var("CQS_TOMBSTONE_RETENTION_DAYS", "0");
+        let mut b = make_chunk("b", "src/b.rs");
+        b.canonical_hash = "canon_b".to_string();
+        store
+            .upsert_chunks_batch(&[(b, mock_embedding(1.0))], Some(100))
+            .unwrap();
+        store.prune_missing(&HashSet::new(), dir.path()).unwrap();
+        std::env::remove_var("CQS_TOMBSTONE_RETENTION_DAYS");
+        assert_eq!(store.tombstone_count().unwrap(), 0);
+    }
+
+    #[test]
+    #[serial]
+    fn gitignore_prune_does_not_tombstone() {
+        std::env::remove_var("CQS_TOMBSTONE_RETENTION_DAYS");
+        let (store, dir) = setup_store();
+        let mut chunk = make_chunk("ignored", "build/gen.rs");
+        chunk.canonical_hash = "canon_ignored".to_string();
+        store
+            .upsert_chunks_batch(&[(chunk, mock_embedding(1.0))], Some(100))
+            .unwrap();
+        let mut builder = ignore::gitignore::GitignoreBuilder::new(dir.path());
+        builder.add_line(None, "build/").unwrap();
+        let matcher = builder.build().unwrap();
+        assert_eq!(
+            store.prune_gitignored(&matcher, dir.path(), None).unwrap(),
+            1
+        );
+        assert_eq!(store.tombstone_count().unwrap(), 0);
+    }
+}
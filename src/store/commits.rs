@@ -0,0 +1,308 @@
+// WRITE_LOCK guard is held across .await inside block_on(). Safe because
+// block_on runs single-threaded — no concurrent tasks can deadlock.
+#![allow(clippy::await_holding_lock)]
+//! Indexed commit messages (schema v44).
+//!
+//! Each commit is a chunk (`chunk_type = 'commit'`, `source_type =
+//! 'commit'`) plus a `commits` row with its sha, author, and time, and one
+//! `commit_files` row per origin it touched. Both cascade away with the
+//! chunk. Written by [`crate::commits::index_commits`]; read back to
+//! describe commit hits in search results.
+
+use std::collections::{HashMap, HashSet};
+
+use serde::Serialize;
+
+use super::chunks::cascade::delete_chunks_where;
+use super::helpers::{make_placeholders, sql::max_rows_per_statement, StoreError};
+use super::{ReadWrite, Store};
+use crate::commits::CommitRecord;
+use crate::embedder::Embedding;
+
+/// A stored commit, as attached to its search hit.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct CommitMeta {
+    pub sha: String,
+    pub author: String,
+    pub email: String,
+    /// Author time, Unix seconds.
+    pub committed_at: i64,
+    /// Origins the commit touched, in path order.
+    pub files: Vec<String>,
+}
+
+impl<Mode> Store<Mode> {
+    /// Full hashes of every indexed commit.
+    pub fn indexed_commit_shas(&self) -> Result<HashSet<String>, StoreError> {
+        let _span = tracing::debug_span!("indexed_commit_shas").entered();
+        self.rt.block_on(async {
+            let rows: Vec<(String,)> = sqlx::query_as("SELECT sha FROM commits")
+                .fetch_all(&self.pool)
+                .await?;
+            Ok(rows.into_iter().map(|(sha,)| sha).collect())
+        })
+    }
+
+    /// Commit metadata for the commit chunks among `ids`. Other ids are
+    /// absent.
+    pub fn commits_by_chunk(
+        &self,
+        ids: &[&str],
+    ) -> Result<HashMap<String, CommitMeta>, StoreError> {
+        let _span = tracing::debug_span!("commits_by_chunk", count = ids.len()).entered();
+        if ids.is_empty() {
+            return Ok(HashMap::new());
+        }
+        self.rt.block_on(async {
+            let mut out: HashMap<String, CommitMeta> = HashMap::new();
+            for batch in ids.chunks(max_rows_per_statement(1)) {
+                let placeholders = make_placeholders(batch.len());
+                let sql = format!(
+                    "SELECT chunk_id, sha, author, email, committed_at FROM commits
+                     WHERE chunk_id IN ({placeholders})"
+                );
+                let mut query = sqlx::query_as::<_, (String, String, String, String, i64)>(
+                    sqlx::AssertSqlSafe(sql.as_str()),
+                );
+                for id in batch {
+                    query = query.bind(*id);
+                }
+                for (chunk_id, sha, author, email, committed_at) in
+                    query.fetch_all(&self.pool).await?
+                {
+                    out.insert(
+                        chunk_id,
+                        CommitMeta {
+                            sha,
+                            author,
+                            email,
+                            committed_at,
+                            files: Vec::new(),
+                        },
+                    );
+                }
+
+                let sql = format!(
+                    "SELECT chunk_id, origin FROM commit_files
+                     WHERE chunk_id IN ({placeholders})
+                     ORDER BY origin"
+                );
+                let mut query =
+                    sqlx::query_as::<_, (String, String)>(sqlx::AssertSqlSafe(sql.as_str()));
+                for id in batch {
+                    query = query.bind(*id);
+                }
+                for (chunk_id, origin) in query.fetch_all(&self.pool).await? {
+                    if let Some(meta) = out.get_mut(&chunk_id) {
+                        meta.files.push(origin);
+                    }
+                }
+            }
+            Ok(out)
+        })
+    }
+
+    /// Indexed commits that touched `origin`, newest first.
+    pub fn commits_touching(&self, origin: &str) -> Result<Vec<CommitMeta>, StoreError> {
+        let _span = tracing::debug_span!("commits_touching", origin).entered();
+        let ids: Vec<String> = self.rt.block_on(async {
+            let rows: Vec<(String,)> = sqlx::query_as(
+                "SELECT m.chunk_id FROM commit_files f
+                 JOIN commits m ON m.chunk_id = f.chunk_id
+                 WHERE f.origin = ?1
+                 ORDER BY m.committed_at DESC",
+            )
+            .bind(origin)
+            .fetch_all(&self.pool)
+            .await?;
+            Ok::<_, StoreError>(rows.into_iter().map(|(id,)| id).collect())
+        })?;
+        let refs: Vec<&str> = ids.iter().map(String::as_str).collect();
+        let mut by_id = self.commits_by_chunk(&refs)?;
+        Ok(ids.iter().filter_map(|id| by_id.remove(id)).collect())
+    }
+}
+
+impl Store<ReadWrite> {
+    /// Store `commits` as chunks with their embeddings, then their sha,
+    /// author, and touched files. Returns the number of commits written.
+    ///
+    /// The chunk rows go through the regular chunk upsert (FTS, issue
+    /// references); the metadata follows in its own transaction. A commit
+    /// whose metadata write failed isn't listed by
+    /// [`Self::indexed_commit_shas`], so the next index writes it again.
+    pub fn upsert_commits(
+        &self,
+        commits: &[(CommitRecord, Embedding)],
+    ) -> Result<usize, StoreError> {
+        let _span = tracing::info_span!("upsert_commits", count = commits.len()).entered();
+        if commits.is_empty() {
+            return Ok(0);
+        }
+        let chunks: Vec<_> = commits
+            .iter()
+            .map(|(c, emb)| (c.to_chunk(), emb.clone()))
+            .collect();
+        self.upsert_chunks_batch(&chunks, None)?;
+
+        let ids: Vec<String> = commits.iter().map(|(c, _)| c.chunk_id()).collect();
+        self.rt.block_on(async {
+            let (_guard, mut tx) = self.begin_write().await?;
+            for batch in ids.chunks(max_rows_per_statement(1)) {
+                let sql = format!(
+                    "DELETE FROM commit_files WHERE chunk_id IN ({})",
+                    make_placeholders(batch.len())
+                );
+                let mut query = sqlx::query(sqlx::AssertSqlSafe(sql.as_str()));
+                for id in batch {
+                    query = query.bind(id);
+                }
+                query.execute(&mut *tx).await?;
+            }
+
+            let rows: Vec<(&str, &CommitRecord)> = ids
+                .iter()
+                .map(String::as_str)
+                .zip(commits.iter().map(|(c, _)| c))
+                .collect();
+            for batch in rows.chunks(max_rows_per_statement(5)) {
+                let mut qb: sqlx::QueryBuilder<sqlx::Sqlite> = sqlx::QueryBuilder::new(
+                    "INSERT OR REPLACE INTO commits (chunk_id, sha, author, email, committed_at) ",
+                );
+                qb.push_values(batch.iter(), |mut b, (id, c)| {
+                    b.push_bind(*id)
+                        .push_bind(c.sha.as_str())
+                        .push_bind(c.author.as_str())
+                        .push_bind(c.email.as_str())
+                        .push_bind(c.time);
+                });
+                qb.build().execute(&mut *tx).await?;
+            }
+
+            let files: Vec<(&str, &str)> = rows
+                .iter()
+                .flat_map(|(id, c)| c.files.iter().map(move |f| (*id, f.as_str())))
+                .collect();
+            for batch in files.chunks(max_rows_per_statement(2)) {
+                let mut qb: sqlx::QueryBuilder<sqlx::Sqlite> = sqlx::QueryBuilder::new(
+                    "INSERT OR IGNORE INTO commit_files (chunk_id, origin) ",
+                );
+                qb.push_values(batch.iter(), |mut b, (id, origin)| {
+                    b.push_bind(*id).push_bind(*origin);
+                });
+                qb.build().execute(&mut *tx).await?;
+            }
+            tx.commit().await?;
+            Ok(commits.len())
+        })
+    }
+
+    /// Delete every commit chunk whose sha is not in `keep`, including chunk
+    /// rows left without metadata. Returns the number of chunks deleted.
+    pub fn prune_commits(&self, keep: &HashSet<String>) -> Result<usize, StoreError> {
+        let _span = tracing::info_span!("prune_commits", keep = keep.len()).entered();
+        self.rt.block_on(async {
+            let (_guard, mut tx) = self.begin_write().await?;
+            let rows: Vec<(String, Option<String>)> = sqlx::query_as(
+                "SELECT c.id, m.sha FROM chunks c
+                 LEFT JOIN commits m ON m.chunk_id = c.id
+                 WHERE c.source_type = 'commit'",
+            )
+            .fetch_all(&mut *tx)
+            .await?;
+            let gone: Vec<String> = rows
+                .into_iter()
+                .filter(|(_, sha)| !sha.as_ref().is_some_and(|s| keep.contains(s)))
+                .map(|(id, _)| id)
+                .collect();
+            if gone.is_empty() {
+                return Ok(0);
+            }
+
+            let mut deleted = 0;
+            for batch in gone.chunks(max_rows_per_statement(1)) {
+                // The metadata rows cascade with the chunk.
+                let predicate = format!("id IN ({})", make_placeholders(batch.len()));
+                let binds: Vec<&str> = batch.iter().map(String::as_str).collect();
+                deleted += delete_chunks_where(&mut *tx, &predicate, &binds).await? as usize;
+            }
+            tx.commit().await?;
+            Ok(deleted)
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::test_helpers::{mock_embedding, setup_store};
+
+    fn record(sha: &str, time: i64, message: &str, files: &[&str]) -> CommitRecord {
+        CommitRecord {
+            sha: sha.to_string(),
+            author: "Ada".to_string(),
+            email: "ada@example.com".to_string(),
+            time,
+            message: message.to_string(),
+            files: files.iter().map(|f| f.to_string()).collect(),
+        }
+    }
+
+    #[test]
+    fn commits_round_trip_and_prune() {
+        let (store, _dir) = setup_store();
+        let old = record(
+            "1111111111111111111111111111111111111111",
+            100,
+            "Add retry",
+            &["src/retry.rs"],
+        );
+        let new = record(
+            "2222222222222222222222222222222222222222",
+            200,
+            "Jitter retry backoff\n\nfixes #12",
+            &["src/retry.rs", "src/lib.rs"],
+        );
+        store
+            .upsert_commits(&[
+                (old.clone(), mock_embedding(1.0)),
+                (new.clone(), mock_embedding(2.0)),
+            ])
+            .unwrap();
+
+        assert_eq!(
+            store.indexed_commit_shas().unwrap(),
+            HashSet::from([old.sha.clone(), new.sha.clone()])
+        );
+        let metas = store.commits_by_chunk(&[new.chunk_id().as_str()]).unwrap();
+        let meta = &metas[&new.chunk_id()];
+        assert_eq!(meta.committed_at, 200);
+        assert_eq!(meta.files, ["src/lib.rs", "src/retry.rs"]);
+
+        let touching: Vec<String> = store
+            .commits_touching("src/retry.rs")
+            .unwrap()
+            .into_iter()
+            .map(|m| m.sha)
+            .collect();
+        assert_eq!(touching, [new.sha.clone(), old.sha.clone()]);
+
+        // Commit chunks are not files: staleness never sees them.
+        let report = store
+            .list_stale_files(&HashSet::new(), std::path::Path::new("/"))
+            .unwrap();
+        assert_eq!(report.total_indexed, 0);
+
+        assert_eq!(
+            store
+                .prune_commits(&HashSet::from([new.sha.clone()]))
+                .unwrap(),
+            1
+        );
+        assert_eq!(
+            store.indexed_commit_shas().unwrap(),
+            HashSet::from([new.sha.clone()])
+        );
+        assert_eq!(store.commits_touching("src/retry.rs").unwrap().len(), 1);
+    }
+}
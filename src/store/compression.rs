@@ -0,0 +1,657 @@
+//! Chunk text compression (`cqs compress`, schema v49).
+//!
+//! `chunks.content` holds either plain TEXT or a zstd frame (BLOB) compressed
+//! against a dictionary trained on the index's own chunks. Chunks are short
+//! and share most of their vocabulary — keywords, the project's identifiers
+//! and idioms — which is exactly what a dictionary captures and what plain
+//! zstd can't learn from a few hundred bytes of input. Dictionaries live in
+//! `content_dicts`; each frame header names the dictionary it was built with,
+//! and every dictionary of a store is loaded into a process-wide registry
+//! when the store opens, so decoding a row needs no extra query.
+//!
+//! Reads are transparent: every SELECT that hydrates chunk text casts
+//! `content` to BLOB and passes it through [`decode`]. Writes compress
+//! against the dictionary named by the `content_dict` metadata key, when
+//! there is one, and only when the frame is smaller than the text. Chunks
+//! that SQL filters match by content substring — test chunks and chunks
+//! carrying a test marker — always stay plain so `LIKE` still sees them.
+//!
+//! The price is read latency: each chunk read pays a decompression on top of
+//! the row fetch. `cqs bench content` measures both sides on a real index;
+//! `cqs compress --off` writes everything back as plain text.
+
+use std::cell::RefCell;
+use std::collections::HashMap;
+use std::sync::{Arc, LazyLock, RwLock};
+
+use serde::Serialize;
+use sqlx::sqlite::SqliteConnection;
+use zstd::dict::{DecoderDictionary, EncoderDictionary};
+use zstd::zstd_safe;
+
+use super::helpers::StoreError;
+use super::{ReadWrite, Store};
+use crate::parser::ChunkType;
+
+/// First bytes of every zstd frame. No UTF-8 text starts with them (0xB5 is
+/// a continuation byte), so a stored value is a frame iff it begins with
+/// these.
+const ZSTD_MAGIC: [u8; 4] = [0x28, 0xB5, 0x2F, 0xFD];
+
+/// Compression level. Dictionary compression of short inputs gains little
+/// above the default, and write throughput matters during `cqs index`.
+const LEVEL: i32 = 3;
+
+/// Default trained dictionary size, the zstd CLI's default.
+pub const DEFAULT_DICT_KIB: u16 = 110;
+
+/// Most chunks sampled to train a dictionary; spread evenly by rowid.
+const MAX_TRAINING_SAMPLES: i64 = 4096;
+
+/// Fewest chunks worth training on. Below this the dictionary learns the
+/// chunks themselves rather than the codebase.
+const MIN_TRAINING_SAMPLES: usize = 32;
+
+/// Largest decompressed chunk accepted. Chunks are capped far below this at
+/// parse time; the cap only guards against a damaged frame header.
+const MAX_CONTENT_BYTES: u64 = 64 << 20;
+
+/// Rows rewritten per page by [`Store::compress_content`].
+const REWRITE_PAGE: i64 = 1000;
+
+/// Metadata key naming the dictionary new writes compress against.
+const ACTIVE_DICT_KEY: &str = "content_dict";
+
+/// A trained dictionary, prepared for both directions.
+pub(crate) struct ContentDict {
+    id: u32,
+    encoder: EncoderDictionary<'static>,
+    decoder: DecoderDictionary<'static>,
+}
+
+impl ContentDict {
+    /// A compressor bound to this dictionary, reused across a write batch.
+    pub(crate) fn compressor(&self) -> Result<zstd::bulk::Compressor<'_>, StoreError> {
+        Ok(zstd::bulk::Compressor::with_prepared_dictionary(
+            &self.encoder,
+        )?)
+    }
+}
+
+/// Every dictionary this process has loaded, by zstd dictionary ID.
+/// Dictionaries are immutable once written, so entries never go stale.
+static DICTS: LazyLock<RwLock<HashMap<u32, Arc<ContentDict>>>> = LazyLock::new(Default::default);
+
+thread_local! {
+    /// Decompression context reused across reads on this thread; creating
+    /// one per row would dominate the cost of decoding a short chunk.
+    static DCTX: RefCell<Option<zstd_safe::DCtx<'static>>> = const { RefCell::new(None) };
+}
+
+fn dict_id(bytes: &[u8]) -> Result<u32, StoreError> {
+    zstd_safe::get_dict_id_from_dict(bytes)
+        .map(|id| id.get())
+        .ok_or_else(|| {
+            StoreError::Corruption("content dictionary has no zstd dictionary header".into())
+        })
+}
+
+/// Prepare `bytes` and add them to the registry (no-op when already there).
+fn register(bytes: &[u8]) -> Result<Arc<ContentDict>, StoreError> {
+    let id = dict_id(bytes)?;
+    if let Some(dict) = lookup(id) {
+        return Ok(dict);
+    }
+    let dict = Arc::new(ContentDict {
+        id,
+        encoder: EncoderDictionary::copy(bytes, LEVEL),
+        decoder: DecoderDictionary::copy(bytes),
+    });
+    DICTS
+        .write()
+        .unwrap_or_else(|p| p.into_inner())
+        .insert(id, Arc::clone(&dict));
+    Ok(dict)
+}
+
+fn lookup(id: u32) -> Option<Arc<ContentDict>> {
+    DICTS
+        .read()
+        .unwrap_or_else(|p| p.into_inner())
+        .get(&id)
+        .cloned()
+}
+
+/// Register every dictionary in `content_dicts`. Indexes older than v49
+/// have no table and nothing to load.
+pub(crate) async fn load_dicts(conn: &mut SqliteConnection) -> Result<usize, StoreError> {
+    let rows: Vec<(Vec<u8>,)> = match sqlx::query_as("SELECT dict FROM content_dicts")
+        .fetch_all(&mut *conn)
+        .await
+    {
+        Ok(rows) => rows,
+        Err(sqlx::Error::Database(e)) if e.message().contains("no such table") => return Ok(0),
+        Err(e) => return Err(e.into()),
+    };
+    for (bytes,) in &rows {
+        register(bytes)?;
+    }
+    Ok(rows.len())
+}
+
+/// The dictionary new chunk writes compress against, or `None` while
+/// compression is off.
+pub(crate) async fn active_dict(
+    conn: &mut SqliteConnection,
+) -> Result<Option<Arc<ContentDict>>, StoreError> {
+    let value: Option<(String,)> = sqlx::query_as("SELECT value FROM metadata WHERE key = ?1")
+        .bind(ACTIVE_DICT_KEY)
+        .fetch_optional(&mut *conn)
+        .await?;
+    let Some((value,)) = value else {
+        return Ok(None);
+    };
+    let id: u32 = value.parse().map_err(|_| {
+        StoreError::Corruption(format!(
+            "{ACTIVE_DICT_KEY} metadata is not a dictionary ID: {value}"
+        ))
+    })?;
+    if let Some(dict) = lookup(id) {
+        return Ok(Some(dict));
+    }
+    // Trained by another process after this one opened the store.
+    let (bytes,): (Vec<u8>,) = sqlx::query_as("SELECT dict FROM content_dicts WHERE id = ?1")
+        .bind(i64::from(id))
+        .fetch_optional(&mut *conn)
+        .await?
+        .ok_or_else(|| {
+            StoreError::Corruption(format!("{ACTIVE_DICT_KEY} names missing dictionary {id}"))
+        })?;
+    register(&bytes).map(Some)
+}
+
+/// Patterns of the content clauses in the test-chunk SQL filter, with
+/// `LIKE` semantics: ASCII case-insensitive, `_` matching any character.
+static TEST_MARKERS: LazyLock<regex::RegexSet> = LazyLock::new(|| {
+    let patterns = super::calls::test_content_like_markers()
+        .into_iter()
+        .map(|m| format!("(?is){}", regex::escape(m).replace('_', ".")));
+    regex::RegexSet::new(patterns).expect("escaped test markers are valid regexes")
+});
+
+/// Whether a chunk's text must stay plain: SQL matches these by content
+/// substring (`chunk_type = 'test' AND content LIKE …` for tests of a name,
+/// the marker clauses of the test-chunk filter, which only runs over
+/// callables). Some markers are short (`it `, `test `), so this keeps a
+/// share of functions plain; the report counts them.
+pub(crate) fn keep_plain(chunk_type: &str, content: &str) -> bool {
+    if chunk_type == "test" {
+        return true;
+    }
+    let callable = chunk_type
+        .parse::<ChunkType>()
+        .map_or(true, ChunkType::is_callable);
+    callable && TEST_MARKERS.is_match(content)
+}
+
+/// Stored form of a chunk's text: the frame when `compressor` is set, the
+/// chunk may be compressed, and the frame is smaller; `None` keeps it plain.
+pub(crate) fn pack(
+    compressor: Option<&mut zstd::bulk::Compressor<'_>>,
+    chunk_type: &str,
+    content: &str,
+) -> Result<Option<Vec<u8>>, StoreError> {
+    let Some(compressor) = compressor else {
+        return Ok(None);
+    };
+    if keep_plain(chunk_type, content) {
+        return Ok(None);
+    }
+    let frame = compressor.compress(content.as_bytes())?;
+    Ok((frame.len() < content.len()).then_some(frame))
+}
+
+/// Chunk text from a stored `content` value read as BLOB.
+pub(crate) fn decode(bytes: Vec<u8>) -> Result<String, StoreError> {
+    if !bytes.starts_with(&ZSTD_MAGIC) {
+        return String::from_utf8(bytes)
+            .map_err(|e| StoreError::Corruption(format!("chunk text is not UTF-8: {e}")));
+    }
+    let id = zstd_safe::get_dict_id_from_frame(&bytes)
+        .map(|id| id.get())
+        .ok_or_else(|| StoreError::Corruption("chunk text frame names no dictionary".into()))?;
+    let dict = lookup(id).ok_or_else(|| {
+        StoreError::Corruption(format!(
+            "chunk text needs content dictionary {id}, which is not loaded"
+        ))
+    })?;
+    let size = match zstd_safe::get_frame_content_size(&bytes) {
+        Ok(Some(n)) if n <= MAX_CONTENT_BYTES => n as usize,
+        _ => {
+            return Err(StoreError::Corruption(
+                "chunk text frame has no valid content size".into(),
+            ))
+        }
+    };
+    let text = DCTX.with(|cell| {
+        let mut cell = cell.borrow_mut();
+        let dctx = cell.get_or_insert_with(zstd_safe::DCtx::create);
+        let mut text: Vec<u8> = Vec::with_capacity(size);
+        dctx.decompress_using_ddict(&mut text, &bytes, dict.decoder.as_ddict())
+            .map(|_| text)
+            .map_err(|code| {
+                StoreError::Corruption(format!(
+                    "chunk text frame failed to decompress: {}",
+                    zstd_safe::get_error_name(code)
+                ))
+            })
+    })?;
+    String::from_utf8(text)
+        .map_err(|e| StoreError::Corruption(format!("chunk text is not UTF-8: {e}")))
+}
+
+/// [`decode`] for read paths that can't fail a whole query over one row:
+/// logs and yields empty text, the same degradation a checksum mismatch
+/// gets.
+pub(crate) fn decode_or_empty(bytes: Vec<u8>, chunk_id: &str) -> String {
+    decode(bytes).unwrap_or_else(|e| {
+        tracing::error!(chunk = %chunk_id, error = %e, "Failed to decode chunk text");
+        String::new()
+    })
+}
+
+/// Result of [`Store::compress_content`] / [`Store::decompress_content`].
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct CompressionReport {
+    /// Dictionary writes now compress against; `None` once compression is
+    /// off.
+    pub dict_id: Option<u32>,
+    /// Size of that dictionary.
+    pub dict_bytes: u64,
+    /// Chunks the dictionary was trained on.
+    pub samples: u64,
+    /// Chunks scanned.
+    pub chunks: u64,
+    /// Chunks stored compressed afterwards.
+    pub compressed: u64,
+    /// Chunks left plain because SQL matches their text ([`keep_plain`]).
+    pub kept_plain: u64,
+    /// Chunks whose stored form changed.
+    pub rewritten: u64,
+    /// Chunk text size, uncompressed.
+    pub raw_bytes: u64,
+    /// Stored `content` bytes before the pass.
+    pub stored_bytes_before: u64,
+    /// Stored `content` bytes after it. The file itself shrinks only once
+    /// SQLite reuses or vacuums the freed pages.
+    pub stored_bytes_after: u64,
+}
+
+/// How chunk text is stored right now (`cqs bench content`).
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct ContentStorage {
+    /// Dictionary new writes compress against, if any.
+    pub dict_id: Option<u32>,
+    pub chunks: u64,
+    /// Chunks stored as zstd frames.
+    pub compressed: u64,
+    /// Stored `content` bytes.
+    pub stored_bytes: u64,
+}
+
+/// Re-store every chunk's text: compressed against `dict` where that pays,
+/// plain otherwise. Pages by rowid so memory stays flat. Also the body of
+/// the v49 revert, which first registers the dictionaries it decodes with.
+pub(crate) async fn rewrite_content(
+    conn: &mut SqliteConnection,
+    dict: Option<&ContentDict>,
+    report: &mut CompressionReport,
+) -> Result<(), StoreError> {
+    let mut compressor = dict.map(ContentDict::compressor).transpose()?;
+    let mut after = 0i64;
+    loop {
+        let rows: Vec<(i64, String, Vec<u8>)> = sqlx::query_as(
+            "SELECT rowid, chunk_type, CAST(content AS BLOB) FROM chunks \
+             WHERE rowid > ?1 ORDER BY rowid LIMIT ?2",
+        )
+        .bind(after)
+        .bind(REWRITE_PAGE)
+        .fetch_all(&mut *conn)
+        .await?;
+        let Some(last) = rows.last() else { break };
+        after = last.0;
+        for (rowid, chunk_type, stored) in rows {
+            let was_frame = stored.starts_with(&ZSTD_MAGIC);
+            let stored_len = stored.len() as u64;
+            let text = decode(stored.clone())?;
+            report.chunks += 1;
+            report.raw_bytes += text.len() as u64;
+            report.stored_bytes_before += stored_len;
+            if compressor.is_some() && keep_plain(&chunk_type, &text) {
+                report.kept_plain += 1;
+            }
+            match pack(compressor.as_mut(), &chunk_type, &text)? {
+                Some(frame) => {
+                    report.compressed += 1;
+                    report.stored_bytes_after += frame.len() as u64;
+                    if frame != stored {
+                        report.rewritten += 1;
+                        sqlx::query("UPDATE chunks SET content = ?1 WHERE rowid = ?2")
+                            .bind(frame)
+                            .bind(rowid)
+                            .execute(&mut *conn)
+                            .await?;
+                    }
+                }
+                None => {
+                    report.stored_bytes_after += text.len() as u64;
+                    if was_frame {
+                        report.rewritten += 1;
+                        sqlx::query("UPDATE chunks SET content = ?1 WHERE rowid = ?2")
+                            .bind(text)
+                            .bind(rowid)
+                            .execute(&mut *conn)
+                            .await?;
+                    }
+                }
+            }
+        }
+    }
+    Ok(())
+}
+
+/// Chunk texts to train on: up to [`MAX_TRAINING_SAMPLES`], evenly spread
+/// by rowid, skipping chunks that stay plain anyway.
+async fn training_samples(conn: &mut SqliteConnection) -> Result<Vec<Vec<u8>>, StoreError> {
+    let (count,): (i64,) = sqlx::query_as("SELECT COUNT(*) FROM chunks")
+        .fetch_one(&mut *conn)
+        .await?;
+    let stride = (count / MAX_TRAINING_SAMPLES).max(1);
+    let rows: Vec<(String, String, Vec<u8>)> = sqlx::query_as(
+        "SELECT id, chunk_type, CAST(content AS BLOB) FROM chunks \
+         WHERE rowid % ?1 = 0 LIMIT ?2",
+    )
+    .bind(stride)
+    .bind(MAX_TRAINING_SAMPLES)
+    .fetch_all(&mut *conn)
+    .await?;
+    Ok(rows
+        .into_iter()
+        .map(|(id, chunk_type, stored)| (chunk_type, decode_or_empty(stored, &id)))
+        .filter(|(chunk_type, text)| !text.is_empty() && !keep_plain(chunk_type, text))
+        .map(|(_, text)| text.into_bytes())
+        .collect())
+}
+
+impl<Mode> Store<Mode> {
+    /// How chunk text is stored right now.
+    pub fn content_storage(&self) -> Result<ContentStorage, StoreError> {
+        self.read_retrying("content_storage", || async {
+            let mut conn = self.pool.acquire().await?;
+            let (chunks, compressed, stored_bytes): (i64, i64, i64) = sqlx::query_as(
+                "SELECT COUNT(*), \
+                        COALESCE(SUM(typeof(content) = 'blob'), 0), \
+                        COALESCE(SUM(length(CAST(content AS BLOB))), 0) \
+                 FROM chunks",
+            )
+            .fetch_one(&mut *conn)
+            .await?;
+            Ok(ContentStorage {
+                dict_id: active_dict(&mut conn).await?.map(|d| d.id),
+                chunks: chunks as u64,
+                compressed: compressed as u64,
+                stored_bytes: stored_bytes as u64,
+            })
+        })
+    }
+}
+
+impl Store<ReadWrite> {
+    /// Train a `dict_kib` KiB dictionary on this index's chunks, make it the
+    /// one writes use, and recompress every chunk against it. Dictionaries
+    /// from earlier runs are dropped once no row needs them. One transaction:
+    /// readers see either the old storage or the new.
+    pub fn compress_content(&self, dict_kib: u16) -> Result<CompressionReport, StoreError> {
+        let _span = tracing::info_span!("compress_content", dict_kib).entered();
+        self.rt.block_on(async {
+            let (_guard, mut tx) = self.begin_write().await?;
+            load_dicts(&mut tx).await?;
+            let samples = training_samples(&mut tx).await?;
+            if samples.len() < MIN_TRAINING_SAMPLES {
+                return Err(StoreError::Runtime(format!(
+                    "only {} chunks to train a dictionary on (need {MIN_TRAINING_SAMPLES}); \
+                     index more code first",
+                    samples.len()
+                )));
+            }
+            let bytes = zstd::dict::from_samples(&samples, usize::from(dict_kib.max(1)) * 1024)
+                .map_err(|e| StoreError::Runtime(format!("dictionary training failed: {e}")))?;
+            let dict = register(&bytes)?;
+
+            sqlx::query(
+                "INSERT OR IGNORE INTO content_dicts (id, dict, sample_count, created_at) \
+                 VALUES (?1, ?2, ?3, ?4)",
+            )
+            .bind(i64::from(dict.id))
+            .bind(&bytes)
+            .bind(samples.len() as i64)
+            .bind(chrono::Utc::now().to_rfc3339())
+            .execute(&mut *tx)
+            .await?;
+            sqlx::query("INSERT OR REPLACE INTO metadata (key, value) VALUES (?1, ?2)")
+                .bind(ACTIVE_DICT_KEY)
+                .bind(dict.id.to_string())
+                .execute(&mut *tx)
+                .await?;
+
+            let mut report = CompressionReport {
+                dict_id: Some(dict.id),
+                dict_bytes: bytes.len() as u64,
+                samples: samples.len() as u64,
+                ..Default::default()
+            };
+            rewrite_content(&mut tx, Some(&*dict), &mut report).await?;
+            sqlx::query("DELETE FROM content_dicts WHERE id != ?1")
+                .bind(i64::from(dict.id))
+                .execute(&mut *tx)
+                .await?;
+            tx.commit().await?;
+            tracing::info!(
+                dict_id = dict.id,
+                chunks = report.chunks,
+                compressed = report.compressed,
+                raw_bytes = report.raw_bytes,
+                stored_bytes = report.stored_bytes_after,
+                "Chunk text compressed"
+            );
+            Ok(report)
+        })
+    }
+
+    /// Store every chunk's text plain again and stop compressing new writes
+    /// (`cqs compress --off`).
+    pub fn decompress_content(&self) -> Result<CompressionReport, StoreError> {
+        let _span = tracing::info_span!("decompress_content").entered();
+        self.rt.block_on(async {
+            let (_guard, mut tx) = self.begin_write().await?;
+            load_dicts(&mut tx).await?;
+            let mut report = CompressionReport::default();
+            rewrite_content(&mut tx, None, &mut report).await?;
+            sqlx::query("DELETE FROM metadata WHERE key = ?1")
+                .bind(ACTIVE_DICT_KEY)
+                .execute(&mut *tx)
+                .await?;
+            sqlx::query("DELETE FROM content_dicts")
+                .execute(&mut *tx)
+                .await?;
+            tx.commit().await?;
+            tracing::info!(
+                chunks = report.chunks,
+                rewritten = report.rewritten,
+                "Chunk text decompressed"
+            );
+            Ok(report)
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::parser::Chunk;
+    use crate::store::test_utils::make_chunk_with;
+    use crate::test_helpers::{mock_embedding, setup_store};
+
+    /// A chunk whose text shares its shape with [`trained`]'s samples.
+    fn chunk(name: &str, chunk_type: ChunkType) -> Chunk {
+        let content = format!(
+            "pub fn {name}(store: &Store) -> Result<Vec<Row>, StoreError> {{\n    \
+             let rows = store.rows_for(\"{name}\")?;\n    \
+             tracing::debug!(count = rows.len(), \"loaded\");\n    Ok(rows)\n}}"
+        );
+        Chunk {
+            chunk_type,
+            ..make_chunk_with(name, &format!("src/{name}.rs"), 1, 5, &content)
+        }
+    }
+
+    fn stored_type(store: &Store, id: &str) -> String {
+        store.rt.block_on(async {
+            let (kind,): (String,) =
+                sqlx::query_as("SELECT typeof(content) FROM chunks WHERE id = ?1")
+                    .bind(id)
+                    .fetch_one(&store.pool)
+                    .await
+                    .unwrap();
+            kind
+        })
+    }
+
+    fn trained() -> Arc<ContentDict> {
+        let samples: Vec<Vec<u8>> = (0..200)
+            .map(|i| {
+                format!(
+                    "pub fn handler_{i}(store: &Store) -> Result<usize, StoreError> {{\n    \
+                     let rows = store.fetch_rows({i})?;\n    \
+                     tracing::debug!(count = rows.len(), \"fetched\");\n    \
+                     Ok(rows.len())\n}}"
+                )
+                .into_bytes()
+            })
+            .collect();
+        register(&zstd::dict::from_samples(&samples, 4096).unwrap()).unwrap()
+    }
+
+    #[test]
+    fn frames_round_trip_through_the_registry() {
+        let dict = trained();
+        let mut compressor = dict.compressor().unwrap();
+        let text = "pub fn handler_900(store: &Store) -> Result<usize, StoreError> {\n    \
+                    let rows = store.fetch_rows(900)?;\n    Ok(rows.len())\n}";
+        let frame = pack(Some(&mut compressor), "function", text)
+            .unwrap()
+            .expect("dictionary frame beats plain text");
+        assert!(frame.starts_with(&ZSTD_MAGIC));
+        assert!(frame.len() < text.len() / 2, "{} bytes", frame.len());
+        assert_eq!(decode(frame).unwrap(), text);
+        assert_eq!(decode(text.as_bytes().to_vec()).unwrap(), text);
+    }
+
+    #[test]
+    fn test_chunks_and_tiny_text_stay_plain() {
+        let dict = trained();
+        let mut compressor = dict.compressor().unwrap();
+        assert!(keep_plain("test", "fn it_works() {}"));
+        // `TEST(` marks a C++ test; only callables are matched by it.
+        assert!(keep_plain("function", "TEST(Parser, Empty) { }"));
+        assert!(!keep_plain("struct", "struct TEST(Parser);"));
+        assert_eq!(
+            pack(Some(&mut compressor), "test", "fn x() {}").unwrap(),
+            None
+        );
+        assert_eq!(pack(Some(&mut compressor), "function", "x").unwrap(), None);
+        assert_eq!(pack(None, "function", "fn x() { 1 + 1 }").unwrap(), None);
+    }
+
+    #[test]
+    fn damaged_or_dictionaryless_frames_are_errors() {
+        let mut frame = zstd::bulk::compress(b"fn lonely() {}", 3).unwrap();
+        // No dictionary ID in the header.
+        assert!(decode(frame.clone()).is_err());
+        frame.truncate(6);
+        assert!(decode(frame).is_err());
+    }
+
+    #[test]
+    fn compress_then_read_write_and_turn_off() {
+        let (store, _dir) = setup_store();
+        let mut chunks: Vec<_> = (0..80)
+            .map(|i| {
+                (
+                    chunk(&format!("load_{i}"), ChunkType::Function),
+                    mock_embedding(1.0),
+                )
+            })
+            .collect();
+        chunks.push((chunk("loads_rows", ChunkType::Test), mock_embedding(1.0)));
+        store.upsert_chunks_batch(&chunks, Some(100)).unwrap();
+
+        let report = store.compress_content(4).unwrap();
+        assert_eq!(report.chunks, 81);
+        assert_eq!(report.compressed, 80);
+        assert_eq!(report.kept_plain, 1, "the test chunk stays plain");
+        assert!(report.stored_bytes_after < report.raw_bytes);
+        assert_eq!(report.stored_bytes_before, report.raw_bytes);
+        assert_eq!(stored_type(&store, &chunks[0].0.id), "blob");
+        assert_eq!(stored_type(&store, &chunks[80].0.id), "text");
+
+        let ids: Vec<&str> = chunks.iter().map(|(c, _)| c.id.as_str()).collect();
+        let read = store.get_chunks_by_ids(&ids).unwrap();
+        for (c, _) in &chunks {
+            assert_eq!(read[&c.id].content, c.content);
+        }
+        assert!(store.verify_chunk_content().unwrap().corrupt.is_empty());
+
+        // New writes compress against the active dictionary.
+        let late = (chunk("load_late", ChunkType::Function), mock_embedding(1.0));
+        store
+            .upsert_chunks_batch(std::slice::from_ref(&late), Some(100))
+            .unwrap();
+        assert_eq!(stored_type(&store, &late.0.id), "blob");
+        let storage = store.content_storage().unwrap();
+        assert_eq!(storage.dict_id, report.dict_id);
+        assert_eq!(storage.compressed, 81);
+
+        let off = store.decompress_content().unwrap();
+        assert_eq!(off.rewritten, 81);
+        assert_eq!(off.stored_bytes_after, off.raw_bytes);
+        assert_eq!(stored_type(&store, &late.0.id), "text");
+        let storage = store.content_storage().unwrap();
+        assert_eq!((storage.dict_id, storage.compressed), (None, 0));
+        let read = store.get_chunks_by_ids(&[late.0.id.as_str()]).unwrap();
+        assert_eq!(read[&late.0.id].content, late.0.content);
+    }
+
+    #[test]
+    fn fts_rebuild_reads_compressed_text() {
+        use crate::nl::{FtsAnalyzer, FtsLanguage};
+
+        let (mut store, _dir) = setup_store();
+        let chunks: Vec<_> = (0..60)
+            .map(|i| {
+                (
+                    chunk(&format!("load_{i}"), ChunkType::Function),
+                    mock_embedding(1.0),
+                )
+            })
+            .collect();
+        store.upsert_chunks_batch(&chunks, Some(100)).unwrap();
+        assert_eq!(store.compress_content(4).unwrap().compressed, 60);
+
+        // Rebuilding `chunks_fts` re-reads every chunk's text; the body-only
+        // term must come back from the frames, stemmed by the new analyzer.
+        let en = FtsAnalyzer::new(Some(FtsLanguage::English), &[FtsLanguage::English]);
+        assert!(store.set_fts_analyzer(en).unwrap());
+        assert_eq!(store.search_fts("traced", 100).unwrap().len(), 60);
+    }
+}
@@ -0,0 +1,392 @@
+// WRITE_LOCK guard is held across .await inside block_on(). Safe because
+// block_on runs single-threaded — no concurrent tasks can deadlock.
+#![allow(clippy::await_holding_lock)]
+//! Embeddings from a second model, staged per chunk (schema v45).
+//!
+//! `cqs model upgrade <model>` records the target in metadata
+//! (`upgrade_model`, `upgrade_dim`). Watch then embeds chunks with it in its
+//! idle time and stages the vectors here, keyed `(chunk_id, model)` with the
+//! chunk's `content_hash` at embedding time. Search and the HNSW builds only
+//! read `chunks.embedding`, so results always come from one model — the one
+//! named by `model_name` — while both sets of vectors sit in the database.
+//! [`Store::finish_embedding_upgrade`] swaps the staged vectors in with a
+//! single transaction once every chunk has one. A chunk edited after it was
+//! staged no longer matches its hash and is staged again.
+
+use super::helpers::{embedding_to_bytes, ChunkRow, ChunkSummary, StoreError};
+use super::{ReadWrite, Store};
+use crate::embedder::Embedding;
+
+/// Metadata key naming the model a pending upgrade moves to.
+const UPGRADE_MODEL_KEY: &str = "upgrade_model";
+/// Metadata key holding that model's embedding dimension.
+const UPGRADE_DIM_KEY: &str = "upgrade_dim";
+
+/// A queued switch to another embedding model.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct EmbeddingUpgrade {
+    /// Model repo the new vectors come from.
+    pub model: String,
+    /// Its embedding dimension.
+    pub dim: usize,
+}
+
+/// How far a pending upgrade has got.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct UpgradeProgress {
+    /// Chunks with a current staged vector.
+    pub staged: u64,
+    /// Chunks in the index.
+    pub total: u64,
+}
+
+impl UpgradeProgress {
+    /// Every chunk has a staged vector, so the cutover can run.
+    pub fn is_complete(&self) -> bool {
+        self.staged >= self.total
+    }
+}
+
+impl<Mode> Store<Mode> {
+    /// The queued upgrade, if `cqs model upgrade` started one.
+    pub fn pending_embedding_upgrade(&self) -> Result<Option<EmbeddingUpgrade>, StoreError> {
+        let Some(model) = self.get_metadata_opt(UPGRADE_MODEL_KEY)? else {
+            return Ok(None);
+        };
+        let dim = self
+            .get_metadata_opt(UPGRADE_DIM_KEY)?
+            .and_then(|d| d.parse::<usize>().ok())
+            .ok_or_else(|| {
+                StoreError::Runtime(format!("upgrade to {model} has no valid {UPGRADE_DIM_KEY}"))
+            })?;
+        Ok(Some(EmbeddingUpgrade { model, dim }))
+    }
+
+    /// Staged and total chunk counts for an upgrade to `model`. A staged
+    /// vector whose chunk has since changed doesn't count.
+    pub fn embedding_upgrade_progress(&self, model: &str) -> Result<UpgradeProgress, StoreError> {
+        let _span = tracing::debug_span!("embedding_upgrade_progress", model).entered();
+        self.rt.block_on(async {
+            let (total,): (i64,) = sqlx::query_as("SELECT COUNT(*) FROM chunks")
+                .fetch_one(&self.pool)
+                .await?;
+            let (staged,): (i64,) = sqlx::query_as(
+                "SELECT COUNT(*) FROM chunk_embedding_versions v
+                 JOIN chunks c ON c.id = v.chunk_id
+                 WHERE v.model = ?1 AND v.content_hash = c.content_hash",
+            )
+            .bind(model)
+            .fetch_one(&self.pool)
+            .await?;
+            Ok(UpgradeProgress {
+                staged: staged as u64,
+                total: total as u64,
+            })
+        })
+    }
+
+    /// Up to `limit` chunks with no current vector from `model`: never
+    /// staged, or edited since they were.
+    pub fn chunks_awaiting_upgrade(
+        &self,
+        model: &str,
+        limit: usize,
+    ) -> Result<Vec<ChunkSummary>, StoreError> {
+        let _span = tracing::debug_span!("chunks_awaiting_upgrade", model, limit).entered();
+        self.rt.block_on(async {
+            let sql = format!(
+                "SELECT {cols} FROM chunks c
+                 LEFT JOIN chunk_embedding_versions v
+                   ON v.chunk_id = c.id AND v.model = ?1
+                 WHERE v.chunk_id IS NULL OR v.content_hash != c.content_hash
+                 ORDER BY c.rowid
+                 LIMIT ?2",
+                cols = super::helpers::CHUNK_ROW_SELECT_COLUMNS_PREFIXED,
+            );
+            let rows: Vec<_> = sqlx::query(sqlx::AssertSqlSafe(sql.as_str()))
+                .bind(model)
+                .bind(limit as i64)
+                .fetch_all(&self.pool)
+                .await?;
+            Ok(rows
+                .iter()
+                .map(|r| ChunkSummary::from(ChunkRow::from_row(r)))
+                .collect())
+        })
+    }
+}
+
+impl Store<ReadWrite> {
+    /// Queue an upgrade to `model`. Vectors staged for any other model are
+    /// dropped; ones already staged for `model` are kept, so re-queueing
+    /// the same upgrade resumes it.
+    pub fn begin_embedding_upgrade(&self, model: &str, dim: usize) -> Result<(), StoreError> {
+        let _span = tracing::info_span!("begin_embedding_upgrade", model, dim).entered();
+        self.rt.block_on(async {
+            let (_guard, mut tx) = self.begin_write().await?;
+            sqlx::query("DELETE FROM chunk_embedding_versions WHERE model != ?1")
+                .bind(model)
+                .execute(&mut *tx)
+                .await?;
+            for (key, value) in [
+                (UPGRADE_MODEL_KEY, model.to_string()),
+                (UPGRADE_DIM_KEY, dim.to_string()),
+            ] {
+                sqlx::query("INSERT OR REPLACE INTO metadata (key, value) VALUES (?1, ?2)")
+                    .bind(key)
+                    .bind(value)
+                    .execute(&mut *tx)
+                    .await?;
+            }
+            tx.commit().await?;
+            Ok(())
+        })
+    }
+
+    /// Stage `(chunk_id, content_hash, embedding)` rows from `model`.
+    /// Returns the number written; a chunk deleted since it was read is
+    /// skipped.
+    pub fn stage_upgrade_embeddings(
+        &self,
+        model: &str,
+        dim: usize,
+        rows: &[(String, String, Embedding)],
+    ) -> Result<usize, StoreError> {
+        let _span =
+            tracing::debug_span!("stage_upgrade_embeddings", model, count = rows.len()).entered();
+        if rows.is_empty() {
+            return Ok(0);
+        }
+        let bytes: Vec<Vec<u8>> = rows
+            .iter()
+            .map(|(_, _, emb)| embedding_to_bytes(emb, dim))
+            .collect::<Result<Vec<_>, _>>()?;
+        self.rt.block_on(async {
+            let (_guard, mut tx) = self.begin_write().await?;
+            let mut written = 0;
+            for ((id, hash, _), blob) in rows.iter().zip(&bytes) {
+                written += sqlx::query(
+                    "INSERT OR REPLACE INTO chunk_embedding_versions
+                         (chunk_id, model, content_hash, embedding)
+                     SELECT id, ?2, ?3, ?4 FROM chunks WHERE id = ?1",
+                )
+                .bind(id)
+                .bind(model)
+                .bind(hash)
+                .bind(blob)
+                .execute(&mut *tx)
+                .await?
+                .rows_affected() as usize;
+            }
+            tx.commit().await?;
+            Ok(written)
+        })
+    }
+
+    /// Switch the index to the pending upgrade's model. Returns the number
+    /// of chunks swapped.
+    ///
+    /// In one transaction: every chunk's `embedding` and `embedding_base`
+    /// take the staged vector, enrichment hashes and UMAP coordinates are
+    /// cleared (both described the old vectors), tombstones, facet vectors
+    /// and token vectors are dropped (their embeddings are the old model's;
+    /// the next `cqs index` re-embeds them), `model_name` / `dimensions`
+    /// name the new model, both HNSW indexes are marked dirty, and the
+    /// staging table and upgrade keys are emptied. Fails without changing
+    /// anything while a chunk has no current staged vector. The next
+    /// `cqs index` re-enriches with call context.
+    pub fn finish_embedding_upgrade(&mut self) -> Result<usize, StoreError> {
+        let _span = tracing::info_span!("finish_embedding_upgrade").entered();
+        let upgrade = self
+            .pending_embedding_upgrade()?
+            .ok_or_else(|| StoreError::Runtime("no embedding upgrade is pending".to_string()))?;
+        let swapped = self.rt.block_on(async {
+            let (_guard, mut tx) = self.begin_write().await?;
+            let (missing,): (i64,) = sqlx::query_as(
+                "SELECT COUNT(*) FROM chunks c
+                 LEFT JOIN chunk_embedding_versions v
+                   ON v.chunk_id = c.id AND v.model = ?1
+                 WHERE v.chunk_id IS NULL OR v.content_hash != c.content_hash",
+            )
+            .bind(&upgrade.model)
+            .fetch_one(&mut *tx)
+            .await?;
+            if missing > 0 {
+                return Err(StoreError::Runtime(format!(
+                    "upgrade to {} is incomplete: {missing} chunks have no staged embedding",
+                    upgrade.model
+                )));
+            }
+            let swapped = sqlx::query(
+                "UPDATE chunks SET
+                     embedding = v.embedding,
+                     embedding_base = v.embedding,
+                     enrichment_hash = NULL,
+                     needs_embedding = 0,
+                     umap_x = NULL,
+                     umap_y = NULL
+                 FROM chunk_embedding_versions v
+                 WHERE v.chunk_id = chunks.id AND v.model = ?1",
+            )
+            .bind(&upgrade.model)
+            .execute(&mut *tx)
+            .await?
+            .rows_affected() as usize;
+            for stmt in [
+                "DELETE FROM chunk_tombstones",
+                "DELETE FROM chunk_facet_embeddings",
+                "DELETE FROM chunk_token_embeddings",
+                "DELETE FROM chunk_embedding_versions",
+                "DELETE FROM metadata WHERE key IN ('upgrade_model', 'upgrade_dim')",
+            ] {
+                sqlx::query(stmt).execute(&mut *tx).await?;
+            }
+            for (key, value) in [
+                ("model_name", upgrade.model.clone()),
+                ("dimensions", upgrade.dim.to_string()),
+                ("hnsw_dirty_enriched", "1".to_string()),
+                ("hnsw_dirty_base", "1".to_string()),
+            ] {
+                sqlx::query("INSERT OR REPLACE INTO metadata (key, value) VALUES (?1, ?2)")
+                    .bind(key)
+                    .bind(value)
+                    .execute(&mut *tx)
+                    .await?;
+            }
+            tx.commit().await?;
+            Ok::<_, StoreError>(swapped)
+        })?;
+        self.set_dim(upgrade.dim);
+        tracing::info!(model = %upgrade.model, swapped, "Embedding upgrade finished");
+        Ok(swapped)
+    }
+
+    /// Drop a pending upgrade and everything staged for it. Returns whether
+    /// one was pending.
+    pub fn cancel_embedding_upgrade(&self) -> Result<bool, StoreError> {
+        let _span = tracing::info_span!("cancel_embedding_upgrade").entered();
+        let pending = self.get_metadata_opt(UPGRADE_MODEL_KEY)?.is_some();
+        self.rt.block_on(async {
+            let (_guard, mut tx) = self.begin_write().await?;
+            for stmt in [
+                "DELETE FROM chunk_embedding_versions",
+                "DELETE FROM metadata WHERE key IN ('upgrade_model', 'upgrade_dim')",
+            ] {
+                sqlx::query(stmt).execute(&mut *tx).await?;
+            }
+            tx.commit().await?;
+            Ok::<_, StoreError>(())
+        })?;
+        Ok(pending)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::store::test_utils::make_chunk;
+    use crate::test_helpers::{mock_embedding, setup_store};
+
+    fn staged(chunks: &[ChunkSummary]) -> Vec<(String, String, Embedding)> {
+        chunks
+            .iter()
+            .map(|c| {
+                (
+                    c.id.clone(),
+                    c.content_hash.clone(),
+                    Embedding::new(vec![0.5; 4]),
+                )
+            })
+            .collect()
+    }
+
+    #[test]
+    fn staged_vectors_swap_in_only_when_complete() {
+        let (mut store, _dir) = setup_store();
+        store
+            .upsert_chunks_batch(
+                &[
+                    (make_chunk("retry", "src/a.rs"), mock_embedding(1.0)),
+                    (make_chunk("stream", "src/a.rs"), mock_embedding(2.0)),
+                ],
+                Some(100),
+            )
+            .unwrap();
+        assert_eq!(store.pending_embedding_upgrade().unwrap(), None);
+        store.begin_embedding_upgrade("next/model", 4).unwrap();
+        assert_eq!(
+            store.pending_embedding_upgrade().unwrap(),
+            Some(EmbeddingUpgrade {
+                model: "next/model".to_string(),
+                dim: 4,
+            })
+        );
+
+        let waiting = store.chunks_awaiting_upgrade("next/model", 1).unwrap();
+        assert_eq!(waiting.len(), 1);
+        store
+            .stage_upgrade_embeddings("next/model", 4, &staged(&waiting))
+            .unwrap();
+        let progress = store.embedding_upgrade_progress("next/model").unwrap();
+        assert_eq!((progress.staged, progress.total), (1, 2));
+        assert!(store.finish_embedding_upgrade().is_err());
+
+        let rest = store.chunks_awaiting_upgrade("next/model", 10).unwrap();
+        assert_eq!(rest.len(), 1);
+        assert_ne!(rest[0].id, waiting[0].id);
+        store
+            .stage_upgrade_embeddings("next/model", 4, &staged(&rest))
+            .unwrap();
+        assert!(store
+            .embedding_upgrade_progress("next/model")
+            .unwrap()
+            .is_complete());
+
+        assert_eq!(store.finish_embedding_upgrade().unwrap(), 2);
+        assert_eq!(store.dim(), 4);
+        assert_eq!(store.pending_embedding_upgrade().unwrap(), None);
+        assert_eq!(store.stats().unwrap().model_name, "next/model");
+    }
+
+    #[test]
+    fn edited_chunks_are_staged_again_and_cancel_drops_all() {
+        let (store, _dir) = setup_store();
+        let mut c = make_chunk("retry", "src/a.rs");
+        store
+            .upsert_chunks_batch(&[(c.clone(), mock_embedding(1.0))], Some(100))
+            .unwrap();
+        store.begin_embedding_upgrade("next/model", 4).unwrap();
+        let waiting = store.chunks_awaiting_upgrade("next/model", 10).unwrap();
+        store
+            .stage_upgrade_embeddings("next/model", 4, &staged(&waiting))
+            .unwrap();
+        assert!(store
+            .chunks_awaiting_upgrade("next/model", 10)
+            .unwrap()
+            .is_empty());
+
+        c.content = "fn retry() { loop {} }".to_string();
+        c.content_hash = blake3::hash(c.content.as_bytes()).to_hex().to_string();
+        store
+            .upsert_chunks_batch(&[(c, mock_embedding(1.0))], Some(200))
+            .unwrap();
+        assert_eq!(
+            store
+                .chunks_awaiting_upgrade("next/model", 10)
+                .unwrap()
+                .len(),
+            1
+        );
+
+        assert!(store.cancel_embedding_upgrade().unwrap());
+        assert_eq!(store.pending_embedding_upgrade().unwrap(), None);
+        assert_eq!(
+            store
+                .embedding_upgrade_progress("next/model")
+                .unwrap()
+                .staged,
+            0
+        );
+        assert!(!store.cancel_embedding_upgrade().unwrap());
+    }
+}
@@ -0,0 +1,250 @@
+// WRITE_LOCK guard is held across .await inside block_on(). Safe because
+// block_on runs single-threaded — no concurrent tasks can deadlock.
+#![allow(clippy::await_holding_lock)]
+//! Facet embeddings per chunk (schema v50, see `crate::facets`).
+//!
+//! `chunk_facet_embeddings` holds one row per `(chunk_id, facet)` with the
+//! chunk's `content_hash` at embedding time, so a row whose chunk has since
+//! changed reads as missing and is embedded again. Rows cascade with their
+//! chunk, and a model cutover (`finish_embedding_upgrade`) empties the table,
+//! since the vectors belong to the old model.
+
+use std::collections::HashMap;
+
+use sqlx::{Row, SqliteConnection};
+
+use super::helpers::{embedding_slice, embedding_to_bytes, ChunkRow, ChunkSummary, StoreError};
+use super::{ReadWrite, Store};
+use crate::embedder::Embedding;
+use crate::facets::{Facet, FacetVectors};
+
+/// How many chunks have current vectors for every facet.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct FacetProgress {
+    /// Chunks with a current vector for each facet.
+    pub embedded: u64,
+    /// Chunks in the index.
+    pub total: u64,
+}
+
+impl<Mode> Store<Mode> {
+    /// Facet coverage of the index.
+    pub fn facet_progress(&self) -> Result<FacetProgress, StoreError> {
+        let _span = tracing::debug_span!("facet_progress").entered();
+        self.rt.block_on(async {
+            let (total,): (i64,) = sqlx::query_as("SELECT COUNT(*) FROM chunks")
+                .fetch_one(&self.pool)
+                .await?;
+            let (embedded,): (i64,) = sqlx::query_as(
+                "SELECT COUNT(*) FROM (
+                     SELECT f.chunk_id FROM chunk_facet_embeddings f
+                     JOIN chunks c ON c.id = f.chunk_id AND c.content_hash = f.content_hash
+                     GROUP BY f.chunk_id HAVING COUNT(*) = ?1
+                 )",
+            )
+            .bind(Facet::ALL.len() as i64)
+            .fetch_one(&self.pool)
+            .await?;
+            Ok(FacetProgress {
+                embedded: embedded as u64,
+                total: total as u64,
+            })
+        })
+    }
+
+    /// Up to `limit` embedded chunks missing a current vector for at least
+    /// one facet. Chunks still awaiting their first embedding are left for
+    /// the pass that gives them one.
+    pub fn chunks_awaiting_facets(&self, limit: usize) -> Result<Vec<ChunkSummary>, StoreError> {
+        let _span = tracing::debug_span!("chunks_awaiting_facets", limit).entered();
+        self.rt.block_on(async {
+            let sql = format!(
+                "SELECT {cols} FROM chunks c
+                 WHERE c.needs_embedding = 0
+                   AND (SELECT COUNT(*) FROM chunk_facet_embeddings f
+                        WHERE f.chunk_id = c.id AND f.content_hash = c.content_hash) < ?1
+                 ORDER BY c.rowid
+                 LIMIT ?2",
+                cols = super::helpers::CHUNK_ROW_SELECT_COLUMNS_PREFIXED,
+            );
+            let rows: Vec<_> = sqlx::query(sqlx::AssertSqlSafe(sql.as_str()))
+                .bind(Facet::ALL.len() as i64)
+                .bind(limit as i64)
+                .fetch_all(&self.pool)
+                .await?;
+            Ok(rows
+                .iter()
+                .map(|r| ChunkSummary::from(ChunkRow::from_row(r)))
+                .collect())
+        })
+    }
+
+    /// Current facet vectors of the chunks in `ids` that have any, keyed by
+    /// chunk id. A vector of the wrong size is skipped with a warning.
+    pub(crate) async fn fetch_facet_vectors_async(
+        &self,
+        conn: &mut SqliteConnection,
+        ids: &[&str],
+    ) -> Result<HashMap<String, FacetVectors>, StoreError> {
+        const BATCH_SIZE: usize = super::helpers::sql::max_rows_per_statement(1);
+        let mut vectors: HashMap<String, FacetVectors> = HashMap::new();
+        for batch in ids.chunks(BATCH_SIZE) {
+            let sql = format!(
+                "SELECT f.chunk_id, f.facet, f.embedding FROM chunk_facet_embeddings f
+                 JOIN chunks c ON c.id = f.chunk_id AND c.content_hash = f.content_hash
+                 WHERE f.chunk_id IN ({})",
+                super::helpers::make_placeholders(batch.len())
+            );
+            let mut q = sqlx::query(sqlx::AssertSqlSafe(sql.as_str()));
+            for id in batch {
+                q = q.bind(*id);
+            }
+            for row in q.fetch_all(&mut *conn).await? {
+                let id: String = row.get("chunk_id");
+                let facet: String = row.get("facet");
+                let bytes: Vec<u8> = row.get("embedding");
+                let Some(facet) = Facet::parse(&facet) else {
+                    tracing::debug!(facet = %facet, "Unknown facet row");
+                    continue;
+                };
+                let vector = match embedding_slice(&bytes, self.dim) {
+                    Ok(v) => v.to_vec(),
+                    Err(e) => {
+                        tracing::warn!(id = %id, facet = facet.as_str(), error = %e, "Skipping facet vector");
+                        continue;
+                    }
+                };
+                let entry = vectors.entry(id).or_default();
+                match facet {
+                    Facet::Name => entry.name = Some(vector),
+                    Facet::Body => entry.body = Some(vector),
+                }
+            }
+        }
+        Ok(vectors)
+    }
+}
+
+impl Store<ReadWrite> {
+    /// Write `(chunk_id, content_hash, facet, embedding)` rows, replacing
+    /// the chunk's earlier vector for that facet. Returns the number
+    /// written; a chunk deleted since it was read is skipped.
+    pub fn upsert_facet_embeddings(
+        &self,
+        rows: &[(String, String, Facet, Embedding)],
+    ) -> Result<usize, StoreError> {
+        let _span = tracing::debug_span!("upsert_facet_embeddings", count = rows.len()).entered();
+        if rows.is_empty() {
+            return Ok(0);
+        }
+        let bytes: Vec<Vec<u8>> = rows
+            .iter()
+            .map(|(_, _, _, emb)| embedding_to_bytes(emb, self.dim))
+            .collect::<Result<Vec<_>, _>>()?;
+        self.rt.block_on(async {
+            let (_guard, mut tx) = self.begin_write().await?;
+            let mut written = 0;
+            for ((id, hash, facet, _), blob) in rows.iter().zip(&bytes) {
+                written += sqlx::query(
+                    "INSERT OR REPLACE INTO chunk_facet_embeddings
+                         (chunk_id, facet, content_hash, embedding)
+                     SELECT id, ?2, ?3, ?4 FROM chunks WHERE id = ?1",
+                )
+                .bind(id)
+                .bind(facet.as_str())
+                .bind(hash)
+                .bind(blob)
+                .execute(&mut *tx)
+                .await?
+                .rows_affected() as usize;
+            }
+            tx.commit().await?;
+            Ok(written)
+        })
+    }
+
+    /// Drop every facet vector. Returns the number of rows removed.
+    pub fn clear_facet_embeddings(&self) -> Result<u64, StoreError> {
+        let _span = tracing::info_span!("clear_facet_embeddings").entered();
+        self.rt.block_on(async {
+            let (_guard, mut tx) = self.begin_write().await?;
+            let removed = sqlx::query("DELETE FROM chunk_facet_embeddings")
+                .execute(&mut *tx)
+                .await?
+                .rows_affected();
+            tx.commit().await?;
+            Ok(removed)
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::store::test_utils::make_chunk;
+    use crate::test_helpers::{mock_embedding, setup_store};
+
+    #[test]
+    fn facet_vectors_track_chunk_content() {
+        let (store, _dir) = setup_store();
+        store
+            .upsert_chunks_batch(
+                &[
+                    (make_chunk("retry", "src/a.rs"), mock_embedding(1.0)),
+                    (make_chunk("stream", "src/a.rs"), mock_embedding(2.0)),
+                ],
+                Some(100),
+            )
+            .unwrap();
+        assert_eq!(store.chunks_awaiting_facets(10).unwrap().len(), 2);
+
+        let retry = &store.chunks_awaiting_facets(1).unwrap()[0];
+        let rows: Vec<_> = Facet::ALL
+            .into_iter()
+            .map(|f| {
+                (
+                    retry.id.clone(),
+                    retry.content_hash.clone(),
+                    f,
+                    mock_embedding(3.0),
+                )
+            })
+            .collect();
+        assert_eq!(store.upsert_facet_embeddings(&rows).unwrap(), 2);
+        // Only a chunk with both facets counts as embedded.
+        store
+            .upsert_facet_embeddings(&[(
+                "src/a.rs:5:gone".to_string(),
+                "h".to_string(),
+                Facet::Name,
+                mock_embedding(3.0),
+            )])
+            .unwrap();
+        assert_eq!(
+            store.facet_progress().unwrap(),
+            FacetProgress {
+                embedded: 1,
+                total: 2
+            }
+        );
+        let awaiting = store.chunks_awaiting_facets(10).unwrap();
+        assert_eq!(awaiting.len(), 1);
+        assert_ne!(awaiting[0].id, retry.id);
+
+        let vectors = store
+            .rt
+            .block_on(async {
+                let mut conn = store.pool.acquire().await?;
+                store
+                    .fetch_facet_vectors_async(&mut conn, &[retry.id.as_str(), "missing"])
+                    .await
+            })
+            .unwrap();
+        assert_eq!(vectors.len(), 1);
+        let retry_vectors = &vectors[&retry.id];
+        assert!(retry_vectors.name.is_some() && retry_vectors.body.is_some());
+
+        assert_eq!(store.clear_facet_embeddings().unwrap(), 2);
+        assert_eq!(store.chunks_awaiting_facets(10).unwrap().len(), 2);
+    }
+}
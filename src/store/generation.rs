@@ -0,0 +1,163 @@
+//! Index generation IDs (`_meta.index_generation`).
+//!
+//! Every committed write that can change a ranking — a chunk inserted,
+//! rewritten or deleted, a note added or removed, a SPLADE pass — advances
+//! the `index_generation` counter in `metadata` (triggers since schema v48,
+//! plus [`super::sparse::bump_splade_generation_tx`]). A search reads the
+//! counter on the same read snapshot as its rows, so the ID it reports names
+//! exactly the index state that produced the ranking.
+//!
+//! The wire form is `<epoch>-<counter>`: `epoch` is a short hash of the
+//! index's `created_at`, so a `cqs index --force` rebuild — which starts the
+//! counter over — never repeats an ID the old index handed out. Consumers
+//! compare IDs for equality only; a paginating agent that sees the ID change
+//! between pages re-queries from the start.
+//!
+//! The search path records the ID per thread ([`take_search_generation`]),
+//! the same take-on-read shape as the worktree overlay outcome, so the JSON
+//! envelope of whichever command ran the search can carry it.
+
+use sqlx::sqlite::SqliteConnection;
+
+use super::{Store, StoreError};
+
+/// Hex digits of the `created_at` hash kept in the ID.
+const EPOCH_HEX_LEN: usize = 8;
+
+/// Advance the counter by one; the body of the v48 triggers in `schema.sql`.
+pub(crate) const BUMP_INDEX_GENERATION_SQL: &str =
+    "INSERT INTO metadata (key, value) VALUES ('index_generation', '1') \
+     ON CONFLICT(key) DO UPDATE SET value = CAST((CAST(value AS INTEGER) + 1) AS TEXT)";
+
+/// Which index state a search read. Serializes as its `<epoch>-<counter>`
+/// string.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct IndexGeneration {
+    /// Short hash of the index's `created_at`; changes on a full rebuild.
+    pub epoch: String,
+    /// Writes committed since the index was created (0 before the first).
+    pub counter: u64,
+}
+
+impl IndexGeneration {
+    fn from_metadata(created_at: Option<&str>, counter: Option<&str>) -> Self {
+        let hash = blake3::hash(created_at.unwrap_or("").as_bytes()).to_hex();
+        let hash = hash.as_str();
+        let counter = match counter.map(str::parse::<u64>) {
+            Some(Ok(n)) => n,
+            Some(Err(e)) => {
+                tracing::warn!(
+                    error = %e,
+                    "index_generation metadata is not a valid u64, reporting 0"
+                );
+                0
+            }
+            None => 0,
+        };
+        Self {
+            epoch: hash[..EPOCH_HEX_LEN].to_string(),
+            counter,
+        }
+    }
+}
+
+impl std::fmt::Display for IndexGeneration {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        write!(f, "{}-{}", self.epoch, self.counter)
+    }
+}
+
+impl serde::Serialize for IndexGeneration {
+    fn serialize<S: serde::Serializer>(&self, serializer: S) -> Result<S::Ok, S::Error> {
+        serializer.collect_str(self)
+    }
+}
+
+thread_local! {
+    /// Generation read by the last search on this thread, taken by the JSON
+    /// envelope. Cleared at the start of every query (the daemon reuses
+    /// threads).
+    static SEARCH_GENERATION: std::cell::RefCell<Option<IndexGeneration>> =
+        const { std::cell::RefCell::new(None) };
+}
+
+/// Clear any generation left over from a previous query on this thread.
+pub fn clear_search_generation() {
+    SEARCH_GENERATION.with(|cell| *cell.borrow_mut() = None);
+}
+
+/// Read (and clear) the generation the current query's search ran against;
+/// `None` when no search ran on this thread since the last clear.
+pub fn take_search_generation() -> Option<IndexGeneration> {
+    SEARCH_GENERATION.with(|cell| cell.borrow_mut().take())
+}
+
+/// Read the generation through `conn`, which may be a read snapshot.
+pub(crate) async fn read_index_generation(
+    conn: &mut SqliteConnection,
+) -> Result<IndexGeneration, StoreError> {
+    let rows: Vec<(String, String)> = sqlx::query_as(
+        "SELECT key, value FROM metadata WHERE key IN ('created_at', 'index_generation')",
+    )
+    .fetch_all(&mut *conn)
+    .await?;
+    let value = |key: &str| rows.iter().find(|(k, _)| k == key).map(|(_, v)| v.as_str());
+    Ok(IndexGeneration::from_metadata(
+        value("created_at"),
+        value("index_generation"),
+    ))
+}
+
+impl<Mode> Store<Mode> {
+    /// Current index generation (`cqs stats`, bug reports).
+    pub fn index_generation(&self) -> Result<IndexGeneration, StoreError> {
+        self.read_retrying("index_generation", || async {
+            let mut conn = self.pool.acquire().await?;
+            read_index_generation(&mut conn).await
+        })
+    }
+
+    /// Read the generation on a search's snapshot and record it for the
+    /// envelope of the command that ran the search.
+    pub(crate) async fn record_search_generation(
+        &self,
+        conn: &mut SqliteConnection,
+    ) -> Result<(), StoreError> {
+        let generation = read_index_generation(conn).await?;
+        SEARCH_GENERATION.with(|cell| *cell.borrow_mut() = Some(generation));
+        Ok(())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn id_changes_with_counter_and_rebuild() {
+        let a = IndexGeneration::from_metadata(Some("2026-01-02T03:04:05Z"), Some("41"));
+        let b = IndexGeneration::from_metadata(Some("2026-01-02T03:04:05Z"), Some("42"));
+        let rebuilt = IndexGeneration::from_metadata(Some("2026-03-01T00:00:00Z"), Some("41"));
+        assert_eq!(a.epoch.len(), EPOCH_HEX_LEN);
+        assert_eq!(a.to_string(), format!("{}-41", a.epoch));
+        assert_ne!(a, b);
+        assert_ne!(a.to_string(), rebuilt.to_string());
+        assert_eq!(
+            serde_json::to_value(&b).unwrap(),
+            serde_json::Value::String(b.to_string())
+        );
+        assert_eq!(
+            IndexGeneration::from_metadata(None, Some("junk")).counter,
+            0
+        );
+    }
+
+    #[test]
+    fn take_clears() {
+        SEARCH_GENERATION.with(|cell| {
+            *cell.borrow_mut() = Some(IndexGeneration::from_metadata(None, Some("3")))
+        });
+        assert_eq!(take_search_generation().map(|g| g.counter), Some(3));
+        assert!(take_search_generation().is_none());
+    }
+}
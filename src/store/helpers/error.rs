@@ -4,8 +4,10 @@ use thiserror::Error;
 
 #[derive(Error, Debug)]
 pub enum StoreError {
+    /// Built through the `From` impl below, which also counts busy/locked
+    /// failures for `cqs_sqlite_busy_total`.
     #[error("Database error: {0}")]
-    Database(#[from] sqlx::Error),
+    Database(#[source] sqlx::Error),
     #[error("IO error: {0}")]
     Io(#[from] std::io::Error),
     #[error("System time error: file mtime before Unix epoch")]
@@ -60,6 +62,11 @@ pub enum StoreError {
         index_model: String,
         query_model: String,
     },
+    /// A query still hit `SQLITE_BUSY` / `SQLITE_LOCKED` after its retries
+    /// ran out (see `store::snapshot`). The underlying error is logged, not
+    /// carried, so the user sees this message rather than SQLite's.
+    #[error("Index is busy: {op} gave up after {attempts} attempts while another cqs process held a lock. Retry shortly, or raise CQS_BUSY_TIMEOUT_MS / CQS_READ_RETRIES.")]
+    Busy { op: &'static str, attempts: u32 },
     #[error("Database integrity check failed: {0}")]
     Corruption(String),
     #[error("Embedding blob dimension mismatch: expected {expected}-dim ({expected_bytes} bytes), got {actual_bytes} bytes")]
@@ -69,3 +76,31 @@ pub enum StoreError {
         actual_bytes: usize,
     },
 }
+
+impl From<sqlx::Error> for StoreError {
+    fn from(e: sqlx::Error) -> Self {
+        if is_busy(&e) {
+            crate::metrics::SQLITE_BUSY.inc();
+        }
+        StoreError::Database(e)
+    }
+}
+
+impl StoreError {
+    /// A `SQLITE_BUSY` / `SQLITE_LOCKED` database error: another connection
+    /// held a lock past the busy timeout. Safe to retry on a fresh snapshot.
+    pub fn is_busy(&self) -> bool {
+        matches!(self, StoreError::Database(e) if is_busy(e))
+    }
+}
+
+/// `true` for `SQLITE_BUSY` / `SQLITE_LOCKED` and their extended codes
+/// (`SQLITE_BUSY_SNAPSHOT` = 517, ...): the primary code is the low byte.
+fn is_busy(e: &sqlx::Error) -> bool {
+    let sqlx::Error::Database(db) = e else {
+        return false;
+    };
+    db.code()
+        .and_then(|c| c.parse::<u32>().ok())
+        .is_some_and(|code| matches!(code & 0xff, 5 | 6))
+}
@@ -48,7 +48,7 @@ pub use types::{
 };
 
 // Search filter
-pub use search_filter::{SearchFilter, DEFAULT_NAME_BOOST};
+pub use search_filter::{FtsScope, SearchFilter, DEFAULT_NAME_BOOST};
 
 // Scoring functions
 pub(crate) use scoring::score_name_match_ascii;
@@ -61,34 +61,52 @@ pub(crate) use sql::{make_placeholders, make_placeholders_offset};
 pub use embeddings::{bytes_to_embedding, embedding_slice, embedding_to_bytes};
 
 // ============ BM25 FTS5 column weights ============
-// Single source of truth for the FTS5 `bm25(chunks_fts, name, sig, content, doc)`
-// argument vector. Two production query paths (`store::search::search_by_name`
-// and `chunks::query::search_by_names_batch`) need to agree byte-for-byte —
-// hoisted here so a tuning sweep is a one-line edit instead of two-site grep.
-// Order matches the chunks_fts column order in `schema.sql:73-77`.
+// Single source of truth for the FTS5 `bm25(chunks_fts, name, sig, content,
+// doc, comments)` argument vector. Two production query paths
+// (`store::search::search_by_name` and `chunks::query::search_by_names_batch`)
+// need to agree byte-for-byte — hoisted here so a tuning sweep is a one-line
+// edit instead of two-site grep. Order matches the chunks_fts column order in
+// `schema.sql`.
 //
 // `name` weighted 10× to prefer definition matches over content mentions when
-// callers pass a function/struct name. `signature`, `content`, `doc` get the
-// FTS5 default weight (1.0) — no per-column rationale yet, so the relative
-// weighting is the load-bearing knob.
+// callers pass a function/struct name. `signature`, `content`, `doc`,
+// `comments` get the FTS5 default weight (1.0) — no per-column rationale yet,
+// so the relative weighting is the load-bearing knob.
 
 /// Weight applied to the `name` column in `bm25()` ordering — heavy enough to
 /// pin the definition of `parse_diff` above other chunks that mention it.
 pub(crate) const BM25_NAME_WEIGHT: f32 = 10.0;
 /// Weight applied to the `signature` column in `bm25()`.
 pub(crate) const BM25_SIGNATURE_WEIGHT: f32 = 1.0;
-/// Weight applied to the `content` column in `bm25()`.
+/// Weight applied to the `content` (code) column in `bm25()`.
 pub(crate) const BM25_CONTENT_WEIGHT: f32 = 1.0;
 /// Weight applied to the `doc` column in `bm25()`.
 pub(crate) const BM25_DOC_WEIGHT: f32 = 1.0;
+/// Weight applied to the `comments` column in `bm25()`.
+pub(crate) const BM25_COMMENTS_WEIGHT: f32 = 1.0;
 
 /// Render the `bm25(chunks_fts, ...)` ordering expression with the canonical
 /// column weights. Both production sites that need the heavy-name weighting
 /// must call this so a tuning sweep stays single-source.
 pub(crate) fn bm25_ordering_expr() -> String {
     format!(
-        "bm25(chunks_fts, {}, {}, {}, {})",
-        BM25_NAME_WEIGHT, BM25_SIGNATURE_WEIGHT, BM25_CONTENT_WEIGHT, BM25_DOC_WEIGHT
+        "bm25(chunks_fts, {}, {}, {}, {}, {})",
+        BM25_NAME_WEIGHT,
+        BM25_SIGNATURE_WEIGHT,
+        BM25_CONTENT_WEIGHT,
+        BM25_DOC_WEIGHT,
+        BM25_COMMENTS_WEIGHT
+    )
+}
+
+/// Render the `bm25(chunks_fts, ...)` ordering for the RRF keyword leg:
+/// default weights on `name` and `signature`, `code_weight` on `content`,
+/// and `comment_weight` on `doc` and `comments`. The weights come from the
+/// `fts_code_weight` / `fts_comment_weight` scoring knobs.
+pub(crate) fn bm25_keyword_expr(code_weight: f32, comment_weight: f32) -> String {
+    format!(
+        "bm25(chunks_fts, 1.0, 1.0, {}, {}, {})",
+        code_weight, comment_weight, comment_weight
     )
 }
 
@@ -184,7 +202,85 @@ pub(crate) fn bm25_ordering_expr() -> String {
 ///   exclusion, so a candidate stored there would read as a false caller. This
 ///   table is callee-name-keyed and never joined by a graph query. Empty on
 ///   migrate; no PARSER_VERSION bump (nothing emits rows yet).
-pub const CURRENT_SCHEMA_VERSION: i32 = 32;
+/// - v33: symbol_renames table (origin, chunk_type, old_name, new_name, old/new
+///   content_hash, similarity, detected_at). Rename edges recorded by the
+///   per-file reindex tx so `cqs history` and eval gold resolution can follow a
+///   symbol across renames. No FK to chunks — edges outlive both ends. Empty on
+///   migrate; no PARSER_VERSION bump.
+/// - v34: no shape change. `chunks_fts` / `notes_fts` rebuilt on migrate with
+///   the identifier-aware FTS analyzer (acronym runs kept whole, abbreviation
+///   expansions appended to indexed text); rows written by the old analyzer
+///   would otherwise persist until their chunk changed.
+/// - v35: chunks.container_id column + idx_chunks_container. The structural
+///   parent of a chunk — the smallest same-origin chunk whose line range covers
+///   it (method → impl/class) — assigned per file inside the reindex tx and
+///   backfilled on migrate. NULL for top-level chunks. `parent_id` keeps its
+///   window/table meaning. No PARSER_VERSION bump.
+/// - v36: schema_migrations table (from_version, to_version, applied_at,
+///   cqs_version). One row per up or down step, written in the migration
+///   transaction, so an index carries its own upgrade history. Holds only the
+///   v35→v36 step on migrate (earlier steps were never recorded).
+/// - v37: chunk_tombstones table (id, origin, name, content_hash,
+///   canonical_hash, embedding, deleted_at). Chunks of files deleted from disk
+///   are copied here by the missing-file prune paths and kept for the
+///   retention window, so a restored file reuses their embeddings. Empty on
+///   migrate; no PARSER_VERSION bump.
+/// - v38: llm_summaries.superseded_at INTEGER (nullable, unix seconds). Set
+///   when no chunk carries the summary's content_hash any more (the code it
+///   described changed or went away), cleared if the hash comes back. NULL on
+///   migrate; the next index or watch cycle marks stale rows.
+/// - v39: watch_journal table (path, reason, queued_at). `cqs watch` mirrors
+///   its pending reindex queue here so a restarted daemon resumes the work a
+///   crash cut off. Empty on migrate; no PARSER_VERSION bump.
+/// - v40: chunks_fts.comments column. Comment text is split out of `content`
+///   (which now holds code only) so keyword search can weight or scope the
+///   two separately (`--in comments|code`). The FTS table is recreated and
+///   re-derived from `chunks` on migrate; no PARSER_VERSION bump.
+/// - v41: chunks.signature_shape TEXT (nullable). Canonical `(T1, T2) -> R`
+///   parameter/return types of callables in typed languages, matched by
+///   `search --signature`. Backfilled from `signature` on migrate; NULL for
+///   non-callables and untyped signatures. No PARSER_VERSION bump.
+/// - v42: chunk_authors table (chunk_id, content_hash, authors JSON). The
+///   dominant `git blame` authors of each chunk's lines, for `search --owner`
+///   and `--with-owners`. Empty on migrate; the next index fills it in. No
+///   PARSER_VERSION bump.
+/// - v43: chunk_issue_refs table (chunk_id, issue, relation). Tracker keys,
+///   `#N`, and GitHub issue/PR links found in each chunk's comments, for
+///   `search --issue`. Backfilled from chunk comments on migrate and kept in
+///   step with the FTS row after that. No PARSER_VERSION bump.
+/// - v44: commits + commit_files tables. Recent commit messages stored as
+///   `chunk_type = 'commit'`, `source_type = 'commit'` chunks, with their
+///   sha/author/time and the origins each commit touched. Empty on migrate;
+///   the next index fills them in. No PARSER_VERSION bump.
+/// - v45: chunk_embedding_versions table (chunk_id, model, content_hash,
+///   embedding). Vectors from the target model of a pending `cqs model
+///   upgrade`, staged during watch idle time and swapped into
+///   `chunks.embedding` at cutover. Empty on migrate. No PARSER_VERSION bump.
+/// - v46: chunks.deprecated INTEGER NOT NULL DEFAULT 0. 1 when the chunk's
+///   doc or declaration head carries a deprecation marker
+///   (`parser::deprecation`); downranked in search and dropped by
+///   `--hide-deprecated`. Backfilled from content and doc on migrate; the
+///   PARSER_VERSION 18 bump brings in Rust attributes and decorators.
+/// - v47: chunks.boilerplate TEXT (nullable). `generated` for codegen output,
+///   `license` for license text (`crate::boilerplate`); such chunks are left
+///   out of search unless `--include-generated`. Backfilled on migrate from
+///   origin, content and doc. No PARSER_VERSION bump.
+/// - v48: triggers on chunks and notes advance the `index_generation`
+///   metadata counter, which searches report as `_meta.index_generation`
+///   (`store::generation`). No column changes, no PARSER_VERSION bump.
+/// - v49: content_dicts table — zstd dictionaries for chunk text compression
+///   (`store::compression`, `cqs compress`). `chunks.content` may now hold a
+///   zstd frame (BLOB) instead of TEXT; reads decode either. Empty on
+///   migrate; the revert decompresses every row first.
+/// - v50: chunk_facet_embeddings table (chunk_id, facet, content_hash,
+///   embedding). Name and body vectors per chunk (`crate::facets`), blended
+///   into dense scores when `[facets]` is enabled. Empty on migrate; `cqs
+///   index` fills it. No PARSER_VERSION bump.
+/// - v51: chunk_token_embeddings table (chunk_id, content_hash, token_count,
+///   embeddings). Quantized per-token vectors (`crate::late_interaction`)
+///   for `--reranker late`, stored only when `[late_interaction]` is
+///   enabled. Empty on migrate. No PARSER_VERSION bump.
+pub const CURRENT_SCHEMA_VERSION: i32 = 51;
 
 /// Default model name for metadata checks (used by test-only `check_model_version`).
 /// Canonical definition is `embedder::DEFAULT_MODEL_REPO`.
@@ -11,22 +11,26 @@
 ///   0 id, 1 origin, 2 language, 3 chunk_type, 4 name, 5 signature,
 ///   6 content, 7 doc, 8 line_start, 9 line_end, 10 content_hash,
 ///   11 window_idx, 12 parent_id, 13 parent_type_name,
-///   14 parser_version, 15 vendored
+///   14 parser_version, 15 vendored, 16 deprecated, 17 boilerplate
 ///
 /// SELECTs that need additional columns (rowid, embedding, target_type_name)
-/// must append them AFTER the 16 pinned columns so the ChunkRow ordinals
+/// must append them AFTER the 18 pinned columns so the ChunkRow ordinals
 /// stay stable.
+///
+/// `content` is read as BLOB: it holds plain text or a zstd frame (schema
+/// v49, [`crate::store::compression`]), and `from_row` decodes either.
 pub(crate) const CHUNK_ROW_SELECT_COLUMNS: &str =
-    "id, origin, language, chunk_type, name, signature, content, doc, \
+    "id, origin, language, chunk_type, name, signature, CAST(content AS BLOB) AS content, doc, \
      line_start, line_end, content_hash, window_idx, parent_id, parent_type_name, \
-     parser_version, vendored";
+     parser_version, vendored, deprecated, boilerplate";
 
 /// `c.`-prefixed variant of [`CHUNK_ROW_SELECT_COLUMNS`] for joins where
 /// `chunks` is aliased as `c`. Same ordinal contract.
 pub(crate) const CHUNK_ROW_SELECT_COLUMNS_PREFIXED: &str =
-    "c.id, c.origin, c.language, c.chunk_type, c.name, c.signature, c.content, c.doc, \
+    "c.id, c.origin, c.language, c.chunk_type, c.name, c.signature, \
+     CAST(c.content AS BLOB) AS content, c.doc, \
      c.line_start, c.line_end, c.content_hash, c.window_idx, c.parent_id, c.parent_type_name, \
-     c.parser_version, c.vendored";
+     c.parser_version, c.vendored, c.deprecated, c.boilerplate";
 
 /// Clamp i64 to valid u32 line number range (1-indexed)
 ///
@@ -91,26 +95,39 @@ pub(crate) struct ChunkRow {
     /// `try_get` so SELECTs that omit the column still construct a valid row
     /// (defaults to false).
     pub vendored: bool,
+    /// True if the doc or declaration head carries a deprecation marker
+    /// (schema v46). Defaults to false where the SELECT omits the column.
+    pub deprecated: bool,
+    /// `generated` / `license` (schema v47); `None` for ordinary code and
+    /// where the SELECT omits the column.
+    pub boilerplate: Option<String>,
 }
 
 impl ChunkRow {
     /// Construct from a SQLite row whose SELECT list begins with the pinned
-    /// 16 columns from [`CHUNK_ROW_SELECT_COLUMNS`] (or its prefixed sibling).
+    /// 18 columns from [`CHUNK_ROW_SELECT_COLUMNS`] (or its prefixed sibling).
     /// Reads via ordinal access — no column-name strcmp scan per row.
     ///
     /// SELECTs may append additional columns (rowid, embedding,
-    /// target_type_name) AFTER ordinal 15; those are read by their named
+    /// target_type_name) AFTER ordinal 17; those are read by their named
     /// callers, not here.
+    ///
+    /// `content` is decoded from its stored form first (a row that fails to
+    /// decode comes back with empty text and an error logged). Each row then
+    /// passes through [`crate::store::checksum`]'s read check, which rehashes
+    /// `content` when the `verify_reads` mode selects it.
     pub(crate) fn from_row(row: &sqlx::sqlite::SqliteRow) -> Self {
         use sqlx::Row;
-        ChunkRow {
-            id: row.get(0),
+        let id: String = row.get(0);
+        let content = crate::store::compression::decode_or_empty(row.get(6), &id);
+        let chunk = ChunkRow {
+            id,
             origin: row.get(1),
             language: row.get(2),
             chunk_type: row.get(3),
             name: row.get(4),
             signature: row.get(5),
-            content: row.get(6),
+            content,
             doc: row.get(7),
             line_start: clamp_line_number(row.get::<i64, _>(8)),
             line_end: clamp_line_number(row.get::<i64, _>(9)),
@@ -126,7 +143,14 @@ impl ChunkRow {
                 let v: i64 = row.get(15);
                 v != 0
             },
-        }
+            deprecated: {
+                let v: i64 = row.get(16);
+                v != 0
+            },
+            boilerplate: row.get(17),
+        };
+        crate::store::checksum::verify_on_read(&chunk);
+        chunk
     }
 
     /// Construct from a SQLite row that omits content/doc columns.
@@ -156,6 +180,11 @@ impl ChunkRow {
                 .try_get::<i64, _>("vendored")
                 .map(|v| v != 0)
                 .unwrap_or(false),
+            deprecated: row
+                .try_get::<i64, _>("deprecated")
+                .map(|v| v != 0)
+                .unwrap_or(false),
+            boilerplate: row.try_get("boilerplate").unwrap_or(None),
         }
     }
 
@@ -185,6 +214,8 @@ impl ChunkRow {
             parent_type_name: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 }
@@ -6,6 +6,30 @@ use crate::parser::{ChunkType, Language};
 /// The struct default is 0.0 (no name boost) for API callers; CLI applies this.
 pub const DEFAULT_NAME_BOOST: f32 = 0.2;
 
+/// Which `chunks_fts` columns the keyword leg matches (`--in`).
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub enum FtsScope {
+    /// Every column (the default).
+    #[default]
+    Both,
+    /// Name, signature, and code text, with comments stripped.
+    Code,
+    /// Doc comments and the comments inside the chunk body.
+    Comments,
+}
+
+impl FtsScope {
+    /// Wrap an already-sanitized FTS5 MATCH query in this scope's column
+    /// filter. `Both` returns the query unchanged.
+    pub(crate) fn apply(self, fts_query: &str) -> String {
+        match self {
+            FtsScope::Both => fts_query.to_string(),
+            FtsScope::Code => format!("{{name signature content}} : ({fts_query})"),
+            FtsScope::Comments => format!("{{doc comments}} : ({fts_query})"),
+        }
+    }
+}
+
 /// Filter and scoring options for search.
 ///
 /// Fields are public for direct construction via struct literals.
@@ -95,6 +119,95 @@ pub struct SearchFilter {
     /// audit-mode state so audits genuinely examine code without note priors
     /// steering the ranking (the feature's stated purpose).
     pub suppress_note_boost: bool,
+    /// Restrict results to chunks whose origin (index-relative file path) is
+    /// in this set.
+    ///
+    /// `None` disables the restriction; `Some` of an empty set matches
+    /// nothing. Set by `--changed-since <ref>` to the files a diff touched.
+    /// Enforced as a hard scoring gate on every path and pushed into the
+    /// vector-index traversal predicate, so a narrow origin set still fills
+    /// to `limit` instead of being starved by the index's candidate pool.
+    pub origins: Option<std::collections::HashSet<String>>,
+    /// Restrict results to chunks whose source text matches this regex.
+    ///
+    /// Set by `--grep <REGEX>`. Resolved to a chunk-ID allowlist before
+    /// ranking, like `origins`, so the structural constraint narrows the
+    /// candidate pool and the semantic ranking orders what survives.
+    pub content_regex: Option<String>,
+    /// Restrict results to callables whose parameter and return types match
+    /// this shape pattern (`(T1, T2) -> R`, see
+    /// [`crate::parser::signature::SignaturePattern`]).
+    ///
+    /// Set by `--signature <SHAPE>`. Resolved to a chunk-ID allowlist from
+    /// `chunks.signature_shape` before ranking, like `content_regex`.
+    pub signature: Option<String>,
+    /// Restrict results to code owned by a team or person.
+    ///
+    /// Set by `--owner <NAME>`. Resolved to a chunk-ID allowlist before
+    /// ranking: chunks in the CODEOWNERS-owned origins the caller resolved,
+    /// plus chunks whose stored blame authors include the name.
+    pub owner: Option<crate::ownership::OwnerFilter>,
+    /// Restrict results to chunks whose comments reference this issue or
+    /// PR, in canonical form (`JIRA-1234`, `#567`, `owner/repo#8`; see
+    /// [`crate::parser::issue_refs::canonical_issue_id`]).
+    ///
+    /// Set by `--issue <ID>`. Resolved to a chunk-ID allowlist from
+    /// `chunk_issue_refs` before ranking.
+    pub issue: Option<String>,
+    /// Restrict the FTS keyword leg to code or comment text.
+    ///
+    /// Set by `--in code|comments`. Anything but `Both` turns the RRF
+    /// keyword leg on (there is nothing to scope otherwise); the dense leg
+    /// is unchanged, so the scope reorders results rather than filtering
+    /// them.
+    pub fts_scope: FtsScope,
+    /// Access-stats popularity prior (`crate::access`).
+    ///
+    /// `None` (the default) leaves ranking untouched. Set by the CLI and
+    /// daemon when the `popularity_weight` scoring knob is above 0; the
+    /// `PopularityBoost` stage then multiplies each candidate by
+    /// `1.0 + weight * popularity`.
+    pub popularity: Option<std::sync::Arc<crate::access::PopularityPrior>>,
+    /// Relevance-feedback prior (`crate::feedback`).
+    ///
+    /// `None` (the default) leaves ranking untouched. Set by the CLI and
+    /// daemon when the `feedback_weight` scoring knob is above 0; the
+    /// `FeedbackBoost` stage then applies the per-path and per-language
+    /// multipliers learned from `cqs feedback`.
+    pub feedback: Option<std::sync::Arc<crate::feedback::FeedbackPrior>>,
+    /// Project boost list (`crate::boosts`).
+    ///
+    /// `None` (the default) leaves ranking untouched. Set by the CLI and
+    /// daemon from the project's `cqs-boosts.toml`; its pin and deprecate
+    /// factors are applied once after fusion and the type boost.
+    pub boosts: Option<std::sync::Arc<crate::boosts::BoostRules>>,
+    /// Drop chunks flagged deprecated (`chunks.deprecated`) instead of
+    /// downranking them.
+    ///
+    /// Set by `--hide-deprecated`. Applied to the brute-force scan's SQL and
+    /// to the candidate pool before fusion, so hidden chunks never take a
+    /// slot from a live one.
+    pub hide_deprecated: bool,
+    /// Keep generated code and license text (`chunks.boilerplate`) in the
+    /// results.
+    ///
+    /// `false` (the default) leaves them out; set by `--include-generated`.
+    /// Like `hide_deprecated`, hidden chunks are skipped while the candidate
+    /// pool is scored and dropped from the keyword leg.
+    pub include_generated: bool,
+    /// Blend weights for facet vectors (`crate::facets`).
+    ///
+    /// `None` (the default) scores every candidate on its whole-chunk
+    /// vector. Set by the CLI and daemon when the project's `[facets]` is
+    /// enabled; chunks with stored facet vectors then score as the weighted
+    /// mean of the query's cosine against each.
+    pub facet_weights: Option<crate::facets::FacetWeights>,
+    /// Late-interaction stage (`crate::late_interaction`).
+    ///
+    /// `None` (the default) keeps first-stage scores. Set by the CLI and
+    /// daemon for `--reranker late`; the top candidates with stored token
+    /// vectors are then rescored by MaxSim against the query's tokens.
+    pub late_interaction: Option<std::sync::Arc<crate::late_interaction::LateQuery>>,
 }
 
 impl Default for SearchFilter {
@@ -114,6 +227,19 @@ impl Default for SearchFilter {
             mmr_lambda: None,
             record_rank_signals: false,
             suppress_note_boost: false,
+            origins: None,
+            content_regex: None,
+            signature: None,
+            owner: None,
+            issue: None,
+            fts_scope: FtsScope::Both,
+            popularity: None,
+            feedback: None,
+            boosts: None,
+            hide_deprecated: false,
+            include_generated: false,
+            facet_weights: None,
+            late_interaction: None,
         }
     }
 }
@@ -178,6 +304,16 @@ impl SearchFilter {
             }
         }
 
+        if let Some(ref pattern) = self.content_regex {
+            if let Err(e) = regex::Regex::new(pattern) {
+                return Err(format!("content_regex is not a valid regex: {e}"));
+            }
+        }
+
+        if let Some(ref shape) = self.signature {
+            crate::parser::signature::SignaturePattern::parse(shape)?;
+        }
+
         // splade_alpha must be in [0.0, 1.0] when SPLADE is enabled
         if self.enable_splade && !(0.0..=1.0).contains(&self.splade_alpha) {
             return Err(format!(
@@ -400,4 +536,45 @@ mod tests {
             "Disjoint include/exclude is fine"
         );
     }
+
+    #[test]
+    fn test_search_filter_content_regex_validated() {
+        let ok = SearchFilter {
+            content_regex: Some(r"rand\.".to_string()),
+            ..Default::default()
+        };
+        assert!(ok.validate().is_ok());
+        let bad = SearchFilter {
+            content_regex: Some("rand(".to_string()),
+            ..Default::default()
+        };
+        assert!(bad.validate().unwrap_err().contains("content_regex"));
+    }
+
+    #[test]
+    fn test_search_filter_signature_validated() {
+        let ok = SearchFilter {
+            signature: Some("(context.Context, []byte) -> error".to_string()),
+            ..Default::default()
+        };
+        assert!(ok.validate().is_ok());
+        let bad = SearchFilter {
+            signature: Some("context.Context".to_string()),
+            ..Default::default()
+        };
+        assert!(bad.validate().unwrap_err().contains("signature pattern"));
+    }
+
+    #[test]
+    fn test_fts_scope_wraps_query_in_column_filter() {
+        assert_eq!(FtsScope::Both.apply("retry OR backoff"), "retry OR backoff");
+        assert_eq!(
+            FtsScope::Comments.apply("retry OR backoff"),
+            "{doc comments} : (retry OR backoff)"
+        );
+        assert_eq!(
+            FtsScope::Code.apply("retry"),
+            "{name signature content} : (retry)"
+        );
+    }
 }
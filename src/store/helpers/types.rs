@@ -75,6 +75,18 @@ pub struct ChunkSummary {
     /// SELECT omits the column.
     #[serde(default, skip_serializing_if = "crate::serde_helpers::is_false")]
     pub vendored: bool,
+    /// True if the doc or declaration head carries a deprecation marker
+    /// (`#[deprecated]`, `@Deprecated`, `// Deprecated:`; see
+    /// `parser::deprecation`). Search downranks these and emits
+    /// `deprecated: true`. Defaults to false when the loading SELECT omits
+    /// the column.
+    #[serde(default, skip_serializing_if = "crate::serde_helpers::is_false")]
+    pub deprecated: bool,
+    /// Codegen output or license text (`crate::boilerplate`, schema v47).
+    /// Search leaves these out unless `--include-generated`. `None` for
+    /// ordinary code and when the loading SELECT omits the column.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub boilerplate: Option<crate::boilerplate::Boilerplate>,
 }
 
 #[inline]
@@ -149,6 +161,11 @@ impl From<ChunkRow> for ChunkSummary {
             parent_type_name: row.parent_type_name,
             parser_version: row.parser_version,
             vendored: row.vendored,
+            deprecated: row.deprecated,
+            boilerplate: row
+                .boilerplate
+                .as_deref()
+                .and_then(crate::boilerplate::Boilerplate::parse),
         }
     }
 }
@@ -170,7 +187,7 @@ pub struct RankSignal {
     /// Signal name from the scoring vocabulary (no new taxonomy).
     pub signal: &'static str,
     /// Contribution in the signal's native unit: rank for retrieval legs,
-    /// multiplier for boosts.
+    /// multiplier for boosts, cosine for the `facet_*` signals.
     pub value: f32,
 }
 
@@ -270,8 +287,11 @@ impl SearchResult {
     /// **Per-result wire shape:**
     /// - Always emit: `file`, `line_start`, `line_end`, `name`, `signature`,
     ///   `language`, `chunk_type`, `score`, `content`.
-    /// - Conditional: `has_parent` skipped when `false`; `reference_name`
-    ///   only when `ref_name = Some(_)`.
+    /// - Conditional: `has_parent` skipped when `false`; `has_doc` skipped
+    ///   when no leading doc comment was attached to the chunk; `deprecated`
+    ///   skipped when the chunk carries no deprecation marker; `boilerplate`
+    ///   only for generated or license chunks (`--include-generated`);
+    ///   `reference_name` only when `ref_name = Some(_)`.
     /// - Skip-when-default: `trust_level` is skipped when `"user-code"` and
     ///   `injection_flags` is skipped when empty (the no-signal cases). The
     ///   security-relevant signals are always emitted when meaningful —
@@ -316,6 +336,21 @@ impl SearchResult {
         if has_parent {
             map.insert("has_parent".to_string(), serde_json::json!(true));
         }
+        // Skip-when-default: has_doc marks a chunk whose leading doc comment
+        // was attached at parse time (see `[index] attach_doc_comments`).
+        if self.chunk.doc.as_deref().is_some_and(|d| !d.is_empty()) {
+            map.insert("has_doc".to_string(), serde_json::json!(true));
+        }
+        // Skip-when-default: deprecated marks a chunk whose doc or
+        // declaration carries a deprecation marker (`chunks.deprecated`).
+        if self.chunk.deprecated {
+            map.insert("deprecated".to_string(), serde_json::json!(true));
+        }
+        // Skip-when-default: boilerplate names why a chunk is normally kept
+        // out of search; only reachable with `--include-generated`.
+        if let Some(kind) = self.chunk.boilerplate {
+            map.insert("boilerplate".to_string(), serde_json::json!(kind.as_str()));
+        }
         // Skip-when-default: trust_level default is "user-code"; emit
         // non-default values (reference-code / vendored-code) always.
         if trust_level != "user-code" {
@@ -610,6 +645,8 @@ pub struct ChunkIdentity {
     pub parent_id: Option<String>,
     /// Window index within parent (for long functions split into windows)
     pub window_idx: Option<u32>,
+    /// Content hash (blake3) — equal hashes mean byte-identical source
+    pub content_hash: String,
 }
 
 /// Note statistics (total count and categorized counts)
@@ -678,6 +715,9 @@ pub struct StaleReport {
 /// Parent context for expanded search results (small-to-big retrieval)
 #[derive(Debug, Clone)]
 pub struct ParentContext {
+    /// Parent chunk id: the window/table `parent_id`, or the enclosing
+    /// container (v35) for a method. A window's logical parent has no row.
+    pub id: String,
     /// Parent chunk name
     pub name: String,
     /// Parent content (full section text)
@@ -794,6 +834,8 @@ mod tests {
             window_idx: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 
@@ -857,6 +899,8 @@ mod tests {
                 window_idx: None,
                 parser_version: 0,
                 vendored: false,
+                deprecated: false,
+                boilerplate: None,
             },
             0.9375,
         )
@@ -870,6 +914,7 @@ mod tests {
         // - trust_level skipped (default "user-code")
         // - injection_flags skipped (default empty)
         // - has_parent emitted (non-default true)
+        // - has_doc emitted (chunk carries a doc comment)
         let result = make_detailed_result();
         let json = result.to_json();
         let obj = json.as_object().expect("to_json should return an object");
@@ -885,6 +930,7 @@ mod tests {
             "score",
             "content",
             "has_parent",
+            "has_doc",
         ]
         .iter()
         .copied()
@@ -930,6 +976,7 @@ mod tests {
         assert_eq!(json["language"], "rust");
         assert_eq!(json["chunk_type"], "function");
         assert_eq!(json["has_parent"], true);
+        assert_eq!(json["has_doc"], true);
         assert_eq!(
             json["content"],
             "pub fn search_filtered(query: &str) -> Vec<Result> { todo!() }"
@@ -952,6 +999,7 @@ mod tests {
             json.get("has_parent").is_none(),
             "has_parent absent when default (false) in lean shape; got: {json}"
         );
+        assert!(json.get("has_doc").is_none(), "no doc, no has_doc");
         // parent_id itself should NOT leak into JSON
         assert!(
             json.get("parent_id").is_none(),
@@ -981,6 +1029,7 @@ mod tests {
             "score",
             "content",
             "has_parent",
+            "has_doc",
         ]
         .iter()
         .copied()
@@ -1134,6 +1183,32 @@ mod tests {
         assert_eq!(json["reference_name"], "rust-stdlib");
     }
 
+    /// `deprecated` is emitted only for flagged chunks.
+    #[test]
+    fn test_to_json_deprecated_skip_when_default() {
+        let mut chunk = make_chunk("foo", None);
+        assert!(SearchResult::new(chunk.clone(), 0.7)
+            .to_json()
+            .get("deprecated")
+            .is_none());
+        chunk.deprecated = true;
+        let json = SearchResult::new(chunk, 0.7).to_json();
+        assert_eq!(json["deprecated"], true);
+    }
+
+    /// `boilerplate` is emitted only for generated or license chunks.
+    #[test]
+    fn test_to_json_boilerplate_skip_when_default() {
+        let mut chunk = make_chunk("foo", None);
+        assert!(SearchResult::new(chunk.clone(), 0.7)
+            .to_json()
+            .get("boilerplate")
+            .is_none());
+        chunk.boilerplate = Some(crate::boilerplate::Boilerplate::Generated);
+        let json = SearchResult::new(chunk, 0.7).to_json();
+        assert_eq!(json["boilerplate"], "generated");
+    }
+
     /// `chunk.vendored = true` with no `ref_name` emits the `vendored-code`
     /// tier — the structural signal that the chunk came from the project
     /// store but matched a vendored-path prefix at index time. Protects the
@@ -0,0 +1,174 @@
+//! Issue and PR references per chunk (schema v43).
+//!
+//! Written alongside the FTS row for every chunk whose content or parser
+//! version changed ([`replace_issue_refs_in_tx`]), so the references always
+//! match the stored comments; rows cascade away with their chunk. Read back
+//! by `search --issue` (as an allowlist) and attached to JSON results.
+
+use std::collections::{HashMap, HashSet};
+
+use super::helpers::{make_placeholders, sql::max_rows_per_statement, StoreError};
+use super::Store;
+use crate::parser::issue_refs::{comment_issue_refs, IssueRef, IssueRelation};
+use crate::parser::Chunk;
+
+/// Re-derive the issue references of `chunks` from their comments,
+/// replacing whatever was stored for them.
+pub(super) async fn replace_issue_refs_in_tx(
+    tx: &mut sqlx::Transaction<'_, sqlx::Sqlite>,
+    chunks: &[&Chunk],
+) -> Result<(), StoreError> {
+    for batch in chunks.chunks(max_rows_per_statement(1)) {
+        let sql = format!(
+            "DELETE FROM chunk_issue_refs WHERE chunk_id IN ({})",
+            make_placeholders(batch.len())
+        );
+        let mut query = sqlx::query(sqlx::AssertSqlSafe(sql.as_str()));
+        for chunk in batch {
+            query = query.bind(&chunk.id);
+        }
+        query.execute(&mut **tx).await?;
+    }
+
+    let rows: Vec<(&str, IssueRef)> = chunks
+        .iter()
+        .flat_map(|chunk| {
+            comment_issue_refs(&chunk.content, chunk.doc.as_deref(), chunk.language)
+                .into_iter()
+                .map(|r| (chunk.id.as_str(), r))
+        })
+        .collect();
+    for batch in rows.chunks(max_rows_per_statement(3)) {
+        let mut qb: sqlx::QueryBuilder<sqlx::Sqlite> =
+            sqlx::QueryBuilder::new("INSERT INTO chunk_issue_refs (chunk_id, issue, relation) ");
+        qb.push_values(batch.iter(), |mut b, (id, r)| {
+            b.push_bind(*id)
+                .push_bind(r.id.as_str())
+                .push_bind(r.relation.as_str());
+        });
+        qb.build().execute(&mut **tx).await?;
+    }
+    Ok(())
+}
+
+impl<Mode> Store<Mode> {
+    /// Stored issue references for `ids`, in the order they appear in each
+    /// chunk. Chunks without any are absent.
+    pub fn chunk_issue_refs(
+        &self,
+        ids: &[&str],
+    ) -> Result<HashMap<String, Vec<IssueRef>>, StoreError> {
+        let _span = tracing::debug_span!("chunk_issue_refs", count = ids.len()).entered();
+        if ids.is_empty() {
+            return Ok(HashMap::new());
+        }
+        self.rt.block_on(async {
+            let mut out: HashMap<String, Vec<IssueRef>> = HashMap::new();
+            for batch in ids.chunks(max_rows_per_statement(1)) {
+                let sql = format!(
+                    "SELECT chunk_id, issue, relation FROM chunk_issue_refs
+                     WHERE chunk_id IN ({})
+                     ORDER BY rowid",
+                    make_placeholders(batch.len())
+                );
+                let mut query = sqlx::query_as::<_, (String, String, String)>(sqlx::AssertSqlSafe(
+                    sql.as_str(),
+                ));
+                for id in batch {
+                    query = query.bind(*id);
+                }
+                for (chunk_id, issue, relation) in query.fetch_all(&self.pool).await? {
+                    out.entry(chunk_id).or_default().push(IssueRef {
+                        id: issue,
+                        relation: IssueRelation::from_stored(&relation),
+                    });
+                }
+            }
+            Ok(out)
+        })
+    }
+
+    /// IDs of every chunk whose comments reference `issue`, a canonical id
+    /// ([`crate::parser::issue_refs::canonical_issue_id`]). A bare `#N` also
+    /// matches `owner/repo#N`, as [`IssueRef::matches`] does. Used to build
+    /// the candidate allowlist for `search --issue`.
+    pub fn chunk_ids_by_issue(&self, issue: &str) -> Result<HashSet<String>, StoreError> {
+        let _span = tracing::debug_span!("chunk_ids_by_issue", issue).entered();
+        self.rt.block_on(async {
+            let suffix = if issue.starts_with('#') {
+                format!("%/%{issue}")
+            } else {
+                issue.to_string()
+            };
+            let rows: Vec<(String,)> = sqlx::query_as(
+                "SELECT DISTINCT chunk_id FROM chunk_issue_refs
+                 WHERE issue = ?1 OR issue LIKE ?2",
+            )
+            .bind(issue)
+            .bind(suffix)
+            .fetch_all(&self.pool)
+            .await?;
+            Ok(rows.into_iter().map(|(id,)| id).collect())
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::store::test_utils::make_chunk_with;
+    use crate::test_helpers::{mock_embedding, setup_store};
+
+    #[test]
+    fn refs_written_with_chunks_and_replaced_on_edit() {
+        let (store, _dir) = setup_store();
+        let a = make_chunk_with("retry", "src/pay.rs", 1, 5, "// see PAY-12\nfn retry() {}");
+        let b = make_chunk_with(
+            "stream",
+            "src/io.rs",
+            1,
+            5,
+            "// fixes acme/io#7\nfn stream() { let s = \"#9\"; }",
+        );
+        store
+            .upsert_chunks_batch(
+                &[
+                    (a.clone(), mock_embedding(1.0)),
+                    (b.clone(), mock_embedding(2.0)),
+                ],
+                Some(100),
+            )
+            .unwrap();
+
+        let refs = store
+            .chunk_issue_refs(&[a.id.as_str(), b.id.as_str()])
+            .unwrap();
+        assert_eq!(refs[&a.id][0].id, "PAY-12");
+        assert_eq!(refs[&a.id][0].relation, IssueRelation::Mentions);
+        assert_eq!(refs[&b.id].len(), 1, "string literals are not scanned");
+        assert_eq!(refs[&b.id][0].relation, IssueRelation::Fixes);
+
+        assert_eq!(
+            store.chunk_ids_by_issue("PAY-12").unwrap(),
+            HashSet::from([a.id.clone()])
+        );
+        assert_eq!(
+            store.chunk_ids_by_issue("#7").unwrap(),
+            HashSet::from([b.id.clone()])
+        );
+        assert!(store.chunk_ids_by_issue("#9").unwrap().is_empty());
+
+        // Same id, new content: the old reference goes, the new one lands.
+        let mut edited = a.clone();
+        edited.content = "// see PAY-13\nfn retry() {}".to_string();
+        edited.content_hash = "edited".to_string();
+        store
+            .upsert_chunks_batch(&[(edited, mock_embedding(1.0))], Some(200))
+            .unwrap();
+        assert!(store.chunk_ids_by_issue("PAY-12").unwrap().is_empty());
+        assert_eq!(
+            store.chunk_ids_by_issue("PAY-13").unwrap(),
+            HashSet::from([a.id.clone()])
+        );
+    }
+}
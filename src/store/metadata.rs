@@ -205,6 +205,25 @@ impl<Mode> Store<Mode> {
         None
     }
 
+    /// Count chunks tagged as boilerplate (`chunks.boilerplate`, schema v47),
+    /// returned as `(generated, license)`.
+    ///
+    /// Used by `cqs stats` to report how much of the index search leaves out
+    /// by default.
+    pub fn boilerplate_chunk_counts(&self) -> Result<(u64, u64), StoreError> {
+        let _span = tracing::debug_span!("boilerplate_chunk_counts").entered();
+        self.rt.block_on(async {
+            let row: (i64, i64) = sqlx::query_as(
+                "SELECT COALESCE(SUM(boilerplate = 'generated'), 0), \
+                        COALESCE(SUM(boilerplate = 'license'), 0) \
+                 FROM chunks WHERE boilerplate IS NOT NULL",
+            )
+            .fetch_one(&self.pool)
+            .await?;
+            Ok((row.0 as u64, row.1 as u64))
+        })
+    }
+
     /// Count distinct chunk_ids that have at least one row in `sparse_vectors`.
     ///
     /// Used by `cqs stats --json` to compute SPLADE coverage as
@@ -261,6 +280,22 @@ impl<Mode> Store<Mode> {
         })
     }
 
+    /// Count `llm_summaries` rows marked superseded — their content_hash no
+    /// longer matches any chunk, so they describe code that has since
+    /// changed. Only as current as the last
+    /// [`mark_superseded_summaries`](Self::mark_superseded_summaries) run.
+    pub fn llm_summary_superseded_count(&self) -> Result<u64, StoreError> {
+        let _span = tracing::debug_span!("llm_summary_superseded_count").entered();
+        self.rt.block_on(async {
+            let row: (i64,) = sqlx::query_as(
+                "SELECT COUNT(*) FROM llm_summaries WHERE superseded_at IS NOT NULL",
+            )
+            .fetch_one(&self.pool)
+            .await?;
+            Ok(row.0 as u64)
+        })
+    }
+
     /// Checks if the stored CQL version in the metadata table matches the current application version.
     /// Retrieves the `cq_version` value from the metadata table and compares it against the current package version. If versions differ, logs an informational message. Errors during version retrieval are logged at debug level but do not propagate, allowing the application to continue.
     /// # Arguments
@@ -482,6 +517,46 @@ impl Store<ReadWrite> {
         })
     }
 
+    /// Switch the index to a different FTS analyzer.
+    ///
+    /// A no-op when `analyzer` matches the one the index was built with.
+    /// Otherwise both FTS tables are re-derived from the stored chunk and
+    /// note text and the new signature is written to the `fts_analyzer`
+    /// metadata key, in one transaction — a crash leaves the old analyzer
+    /// and the old FTS rows together. No re-embedding is involved. Returns
+    /// whether a rebuild happened.
+    ///
+    /// Takes `&mut self` because every later FTS write and query on this
+    /// handle must see the new analyzer; call it before sharing the store.
+    pub fn set_fts_analyzer(
+        &mut self,
+        analyzer: crate::nl::FtsAnalyzer,
+    ) -> Result<bool, StoreError> {
+        if analyzer == self.fts_analyzer {
+            return Ok(false);
+        }
+        let _span = tracing::info_span!(
+            "set_fts_analyzer",
+            from = %self.fts_analyzer.signature(),
+            to = %analyzer.signature()
+        )
+        .entered();
+        let (chunks, notes) = self.rt.block_on(async {
+            let (_guard, mut tx) = self.begin_write().await?;
+            let chunks = super::migrations::rebuild_chunks_fts(&mut *tx, &analyzer).await?;
+            let notes = super::migrations::rebuild_notes_fts(&mut *tx, &analyzer).await?;
+            sqlx::query("INSERT OR REPLACE INTO metadata (key, value) VALUES ('fts_analyzer', ?1)")
+                .bind(analyzer.signature())
+                .execute(&mut *tx)
+                .await?;
+            tx.commit().await?;
+            Ok::<_, StoreError>((chunks, notes))
+        })?;
+        tracing::info!(chunks, notes, "FTS tables rebuilt with the new analyzer");
+        self.fts_analyzer = analyzer;
+        Ok(true)
+    }
+
     /// Set a metadata key/value pair, or delete it if `value` is `None`.
     pub(crate) fn set_metadata_opt(
         &self,
@@ -548,7 +623,8 @@ impl Store<ReadWrite> {
         self.rt.block_on(async {
             let result = sqlx::query(
                 "DELETE FROM llm_summaries \
-                 WHERE content_hash NOT IN (SELECT content_hash FROM chunks)",
+                 WHERE content_hash NOT IN (SELECT content_hash FROM chunks \
+                                            UNION SELECT content_hash FROM chunk_tombstones)",
             )
             .execute(&self.pool)
             .await?;
@@ -1174,6 +1250,71 @@ mod tests {
         assert_eq!(store.prune_orphaned_llm_summaries().unwrap(), 0);
     }
 
+    /// A summary whose hash leaves `chunks` is stamped superseded (not
+    /// deleted), and the stamp clears when the hash comes back.
+    #[test]
+    fn test_mark_superseded_summaries_roundtrip() {
+        let (store, _dir) = make_test_store_initialized();
+        let now = chrono::Utc::now().to_rfc3339();
+        let dim = ModelInfo::default().dimensions;
+        let embedding_bytes: Vec<u8> = bytemuck::cast_slice(&vec![0.1f32; dim]).to_vec();
+        let insert_chunk = |hash: &str| {
+            store.rt.block_on(async {
+                sqlx::query(
+                    "INSERT INTO chunks (id, origin, source_type, language, chunk_type, name,
+                         signature, content, content_hash, doc, line_start, line_end, embedding,
+                         source_mtime, created_at, updated_at)
+                         VALUES ('c1', 'c1', 'file', 'rust', 'function', 'c1',
+                         '', '', ?1, NULL, 1, 10, ?2, 0, ?3, ?3)",
+                )
+                .bind(hash)
+                .bind(&embedding_bytes)
+                .bind(&now)
+                .execute(&store.pool)
+                .await
+                .unwrap();
+            })
+        };
+
+        insert_chunk("hash_old");
+        store.rt.block_on(async {
+            sqlx::query(
+                "INSERT INTO llm_summaries (content_hash, purpose, summary, model, created_at)
+                 VALUES ('hash_old', 'summary', 'test', 'test-model', ?1)",
+            )
+            .bind(&now)
+            .execute(&store.pool)
+            .await
+            .unwrap();
+        });
+        assert_eq!(store.mark_superseded_summaries().unwrap(), (0, 0));
+
+        // The chunk's code changes: same id, new hash.
+        store.rt.block_on(async {
+            sqlx::query("DELETE FROM chunks")
+                .execute(&store.pool)
+                .await
+                .unwrap();
+        });
+        insert_chunk("hash_new");
+        assert_eq!(store.mark_superseded_summaries().unwrap(), (1, 0));
+        assert_eq!(store.llm_summary_superseded_count().unwrap(), 1);
+        // Idempotent, and the row is kept for reuse.
+        assert_eq!(store.mark_superseded_summaries().unwrap(), (0, 0));
+        assert_eq!(store.llm_summary_count().unwrap(), 1);
+
+        // Reverting the edit brings the hash (and its summary) back.
+        store.rt.block_on(async {
+            sqlx::query("DELETE FROM chunks")
+                .execute(&store.pool)
+                .await
+                .unwrap();
+        });
+        insert_chunk("hash_old");
+        assert_eq!(store.mark_superseded_summaries().unwrap(), (0, 1));
+        assert_eq!(store.llm_summary_superseded_count().unwrap(), 0);
+    }
+
     /// `llm_summary_chunk_coverage` must reflect how many CHUNKS have a
     /// summary, not how many summary rows reference chunks.
     #[test]
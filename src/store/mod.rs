@@ -13,21 +13,40 @@
 //! - `notes` - Note CRUD and search
 //! - `calls` - Call graph storage and queries
 //! - `types` - Type dependency storage and queries
+//! - `renames` - Symbol rename detection and history
 //! - `migrations` - Database schema migrations
 //! - `metadata` - Metadata get/set and version validation
 //! - `search` - FTS search, name search, RRF fusion
+//! - `snapshot` - Per-query read snapshots and busy retries
+//! - `checksum` - Chunk content verification against `content_hash`
 
 mod backup;
 pub mod calls;
+mod checksum;
+mod chunk_authors;
 mod chunks;
+mod commits;
+pub(crate) mod compression;
+mod embedding_versions;
+mod facets;
+mod generation;
+mod issue_refs;
 mod metadata;
 mod migrations;
 mod notes;
+mod pragmas;
+mod renames;
 mod search;
 pub(crate) mod serve_queries;
+mod snapshot;
 mod sparse;
 mod summary_queue;
+mod token_embeddings;
 mod types;
+mod watch_journal;
+
+#[cfg(test)]
+pub(crate) use chunks::test_utils;
 
 /// Helper types and embedding conversion functions.
 /// This module is `pub(crate)` - external consumers should use the re-exported
@@ -108,7 +127,7 @@ pub use helpers::NoteStats;
 pub use helpers::NoteSummary;
 
 /// Filter and scoring options for search.
-pub use helpers::SearchFilter;
+pub use helpers::{FtsScope, SearchFilter};
 
 /// A code chunk search result with similarity score.
 pub use helpers::SearchResult;
@@ -132,6 +151,9 @@ pub use helpers::{DataVersionProbe, FileIdentity};
 /// Unified search result (code chunk or note).
 pub use helpers::UnifiedResult;
 
+/// Index generation a search ran against (`_meta.index_generation`).
+pub use generation::{clear_search_generation, take_search_generation, IndexGeneration};
+
 /// Current database schema version.
 pub use helpers::CURRENT_SCHEMA_VERSION;
 
@@ -162,6 +184,9 @@ pub use helpers::score_name_match_pre_lower;
 /// Result of atomic GC prune (all 4 operations in one transaction).
 pub use chunks::PruneAllResult;
 
+/// Per-table count of chunk dependents whose chunk is gone (`cqs doctor`).
+pub use chunks::DependentOrphans;
+
 /// Row cap for `Store::get_chunks_by_name` (kind-detection lookup).
 pub use chunks::GET_CHUNKS_BY_NAME_LIMIT;
 
@@ -171,6 +196,9 @@ pub use chunks::GET_CHUNKS_BY_NAME_LIMIT;
 /// flips.
 pub use chunks::staleness::{FileFingerprint, FingerprintPolicy};
 
+/// Per-origin index record for `cqs explain-index`.
+pub use chunks::staleness::OriginRecord;
+
 /// Statistics about call graph entries (chunk-level calls table).
 pub use calls::CallStats;
 
@@ -208,6 +236,43 @@ pub use types::TypeGraph;
 /// A type usage relationship from a chunk.
 pub use types::TypeUsage;
 
+/// A detected symbol rename (symbol_renames table).
+pub use renames::RenameEdge;
+
+/// Crash-recovery journal entries for the `cqs watch` pending queue.
+pub use watch_journal::{JournalEntry, JournalReason};
+
+/// A chunk awaiting a blame summary (chunk_authors table).
+pub use chunk_authors::BlameTarget;
+
+/// Stored commit metadata attached to commit search hits (schema v44).
+pub use commits::CommitMeta;
+
+/// A queued embedding-model upgrade and its staging progress (schema v45).
+pub use embedding_versions::{EmbeddingUpgrade, UpgradeProgress};
+
+/// Facet-vector coverage of the index (schema v50).
+pub use facets::FacetProgress;
+
+/// Token-vector coverage of the index (schema v51).
+pub use token_embeddings::TokenEmbeddingProgress;
+
+/// Snapshot vetting and DB swap-in for `cqs restore` / `cqs replica` /
+/// `cqs index --swap`.
+pub use backup::{inspect_snapshot, install_replica, restore_snapshot, swap_in_db, SnapshotInfo};
+
+/// Per-mode SQLite pragma defaults and the `[store]` config hook.
+pub use pragmas::{configure_store, StoreProfile};
+
+/// Full-scan result of chunk content verification (`cqs doctor`).
+pub use checksum::{ChecksumReport, CorruptChunk, VERIFY_SAMPLE_EVERY};
+
+/// Chunk text compression (`cqs compress`).
+pub use compression::{CompressionReport, ContentStorage, DEFAULT_DICT_KIB};
+
+/// One recorded schema migration step (`schema_migrations`, v36+).
+pub use migrations::AppliedMigration;
+
 /// Defense-in-depth sanitization for FTS5 query strings.
 /// Strips or escapes FTS5 special characters that could alter query semantics.
 /// Applied after `normalize_for_fts()` as an extra safety layer — if `normalize_for_fts`
@@ -308,7 +373,7 @@ impl ClearHnswDirty for ReadOnly {
 /// # Memory-mapped I/O
 /// `open()` sets `PRAGMA mmap_size = 256MB` per connection with a 4-connection pool,
 /// reserving up to 1GB of virtual address space. `open_readonly_pooled()` keeps the
-/// full 256MB × 1. `open_readonly()` uses 64MB × 1. `open_readonly_small()` uses
+/// full 256MB × `[store] read_pool_size` (1 outside the daemon). `open_readonly()` uses 64MB × 1. `open_readonly_small()` uses
 /// 16MB × 1 for reference indexes where virtual address fragmentation matters more
 /// than search throughput. This is intentional and benign on 64-bit systems (128TB
 /// virtual address space). Mmap pages are demand-paged from the database file and
@@ -341,6 +406,10 @@ pub struct Store<Mode = ReadWrite> {
     rt: Arc<Runtime>,
     /// Embedding dimension for this store (read from metadata on open, default `EMBEDDING_DIM`).
     pub(crate) dim: usize,
+    /// FTS text analyzer the index was built with (read from the
+    /// `fts_analyzer` metadata key on open, default when absent). Every FTS
+    /// write and MATCH query goes through it; see [`Store::set_fts_analyzer`].
+    pub(crate) fts_analyzer: crate::nl::FtsAnalyzer,
     /// Whether close() has already been called (skip WAL checkpoint in Drop)
     closed: AtomicBool,
     notes_summaries_cache: RwLock<Option<Arc<Vec<NoteSummary>>>>,
@@ -393,6 +462,10 @@ struct StoreOpenConfig {
     /// multiple consumers (Store + EmbeddingCache + QueryCache).
     /// If `None`, creates a new one per `use_current_thread`.
     runtime: Option<Arc<Runtime>>,
+    /// `Some(v)` steps the schema down to `v` on open instead of upgrading
+    /// it to [`helpers::CURRENT_SCHEMA_VERSION`]. Set only by
+    /// [`Store::downgrade_schema`].
+    schema_target: Option<i32>,
 }
 
 /// Filesystem types where SQLite `mmap_size > 0` hurts performance.
@@ -436,14 +509,17 @@ fn mmap_size_env_override() -> Option<String> {
 ///
 /// Precedence (highest first):
 /// 1. `CQS_MMAP_SIZE` env var — explicit user override always wins.
-/// 2. Slow-FS auto-detection — if `db_path` is on 9P/NTFS/SMB/NFS, return `"0"`
+/// 2. `[store] mmap_size` in `.cqs.toml` — also an explicit choice.
+/// 3. Slow-FS auto-detection — if `db_path` is on 9P/NTFS/SMB/NFS, return `"0"`
 ///    (disables mmap) and log an info message.
-/// 3. `default_bytes` — the mode-specific default (e.g. 256 MB pooled, 64 MB
+/// 4. `default_bytes` — the mode-specific default (e.g. 256 MB pooled, 64 MB
 ///    read-only).
 ///
 /// The env var is the raw byte count (e.g. `268435456` for 256 MB).
 fn resolve_mmap_size(default_bytes: &str, db_path: &Path) -> String {
-    if let Some(explicit) = mmap_size_env_override() {
+    if let Some(explicit) =
+        mmap_size_env_override().or_else(|| pragmas::configured_mmap_size().map(|n| n.to_string()))
+    {
         return explicit;
     }
     if is_slow_mmap_fs(db_path) {
@@ -970,16 +1046,19 @@ mod slow_fs_tests {
     }
 }
 
-/// Read `CQS_SQLITE_CACHE_SIZE` env var, falling back to `default_kib`.
+/// Resolve the SQLite `cache_size` PRAGMA: `CQS_SQLITE_CACHE_SIZE` env var,
+/// then `[store] cache_size`, then `default_kib`.
 /// SQLite `cache_size` PRAGMA uses a negative kibibyte count (e.g. `-16384`
 /// for 16 MB). The env var should be a signed integer in that same format —
 /// negative means kibibytes, positive means a page count. Accepting only
 /// i64 keeps parsing simple while letting tuners pick either convention.
-fn cache_size_from_env(default_kib: &str) -> String {
+fn resolve_cache_size(default_kib: i64) -> String {
     std::env::var("CQS_SQLITE_CACHE_SIZE")
         .ok()
-        .and_then(|v| v.trim().parse::<i64>().ok().map(|n| n.to_string()))
-        .unwrap_or_else(|| default_kib.to_string())
+        .and_then(|v| v.trim().parse::<i64>().ok())
+        .or_else(pragmas::configured_cache_size)
+        .unwrap_or(default_kib)
+        .to_string()
 }
 
 impl<Mode> Store<Mode> {
@@ -995,6 +1074,11 @@ impl<Mode> Store<Mode> {
         self.dim = dim;
     }
 
+    /// FTS text analyzer this index was built with.
+    pub fn fts_analyzer(&self) -> &crate::nl::FtsAnalyzer {
+        &self.fts_analyzer
+    }
+
     /// Borrow the underlying tokio runtime. Callers that want to share this
     /// runtime with `EmbeddingCache::open_with_runtime` or
     /// `QueryCache::open_with_runtime` call `Arc::clone(store.runtime())`.
@@ -1076,7 +1160,7 @@ impl Store<ReadWrite> {
             .synchronous(SqliteSynchronous::Normal)
             .log_slow_statements(log::LevelFilter::Warn, std::time::Duration::from_secs(5));
 
-        let cache_pragma = format!("PRAGMA cache_size = {}", cache_size_from_env("-16384"));
+        let cache_pragma = format!("PRAGMA cache_size = {}", resolve_cache_size(-16384));
 
         let pool = rt.block_on(async {
             SqlitePoolOptions::new()
@@ -1122,6 +1206,7 @@ impl Store<ReadWrite> {
             pool,
             rt,
             dim: crate::EMBEDDING_DIM,
+            fts_analyzer: crate::nl::FtsAnalyzer::default(),
             closed: AtomicBool::new(false),
             notes_summaries_cache: RwLock::new(None),
             note_boost_cache: crate::search::scoring::NoteBoostCache::default(),
@@ -1137,6 +1222,22 @@ impl Store<ReadWrite> {
         Ok(store)
     }
 
+    /// Step the index at `path` down to schema `target` so an older cqs can
+    /// open it without `cqs index --force`, then close it. Runs the
+    /// registered down steps under the same backup-and-restore protocol as
+    /// an upgrade and records them in `schema_migrations` while the table
+    /// still exists. Errors with `MigrationNotSupported` (DB untouched) when
+    /// `target` is below the oldest reversible step. Returns the version the
+    /// index ends at.
+    pub fn downgrade_schema(path: &Path, target: i32) -> Result<i32, StoreError> {
+        let mut config = Self::default_open_config(path, None);
+        config.schema_target = Some(target);
+        let store = Self::open_with_config(path, config)?;
+        let version = store.stats()?.schema_version;
+        store.close()?;
+        Ok(version)
+    }
+
     /// Shared config builder for `open` / `open_with_runtime`. Keeping the
     /// defaults in one place guarantees the runtime-sharing variant stays in
     /// lockstep with the standalone version as pool / mmap / cache defaults
@@ -1159,8 +1260,9 @@ impl Store<ReadWrite> {
             use_current_thread: false,
             max_connections,
             mmap_size: resolve_mmap_size("268435456", path), // 256MB default
-            cache_size: cache_size_from_env("-16384"),       // 16MB
+            cache_size: resolve_cache_size(pragmas::primary_cache_size()),
             runtime,
+            schema_target: None,
         }
     }
 }
@@ -1177,10 +1279,11 @@ impl Store<ReadOnly> {
         StoreOpenConfig {
             read_only: true,
             use_current_thread: true,
-            max_connections: 1,
+            max_connections: pragmas::read_pool_size(),
             mmap_size: resolve_mmap_size("268435456", path), // 256MB default
-            cache_size: cache_size_from_env("-16384"),       // 16MB
+            cache_size: resolve_cache_size(pragmas::primary_cache_size()),
             runtime,
+            schema_target: None,
         }
     }
 
@@ -1189,7 +1292,8 @@ impl Store<ReadOnly> {
     /// instead of 4) while keeping the full 256MB mmap and 16MB cache of
     /// `open()`. Ideal for read-only CLI commands on the primary project index
     /// where we need full search performance but don't need multi-threaded
-    /// async.
+    /// async. The pool holds `[store] read_pool_size` connections (1 by
+    /// default, 4 in the daemon, which shares a multi-thread runtime).
     pub fn open_readonly_pooled(path: &Path) -> Result<Self, StoreError> {
         open_with_config_impl::<ReadOnly>(path, Self::default_readonly_pooled_config(path, None))
     }
@@ -1208,8 +1312,9 @@ impl Store<ReadOnly> {
                 use_current_thread: true,
                 max_connections: 1,
                 mmap_size: resolve_mmap_size("67108864", path), // 64MB default
-                cache_size: cache_size_from_env("-4096"),       // 4MB
+                cache_size: resolve_cache_size(-4096),          // 4MB
                 runtime: None,
+                schema_target: None,
             },
         )
     }
@@ -1253,8 +1358,9 @@ impl Store<ReadOnly> {
                 use_current_thread: true,
                 max_connections: 1,
                 mmap_size: resolve_mmap_size("16777216", path), // 16MB default
-                cache_size: cache_size_from_env("-1024"),       // 1MB
+                cache_size: resolve_cache_size(-1024),          // 1MB
                 runtime: None,
+                schema_target: None,
             },
         )
     }
@@ -1349,13 +1455,14 @@ fn open_with_config_impl<Mode>(
         .filename(path)
         .foreign_keys(true)
         .journal_mode(SqliteJournalMode::Wal)
-        .busy_timeout(helpers::sql::busy_timeout_from_env(30_000))
-        // NORMAL synchronous in WAL mode: fsync on checkpoint, not every commit.
-        // Trade-off: a crash can lose the last few committed transactions (WAL
-        // tail not yet fsynced), but the database remains consistent. Acceptable
-        // for a rebuildable search index — `cqs index --force` recovers fully.
-        // FULL would fsync every commit, ~2x slower on spinning disk / WSL-NTFS.
-        .synchronous(SqliteSynchronous::Normal)
+        .busy_timeout(pragmas::busy_timeout())
+        // NORMAL synchronous (the default) in WAL mode: fsync on checkpoint,
+        // not every commit. Trade-off: a crash can lose the last few committed
+        // transactions (WAL tail not yet fsynced), but the database remains
+        // consistent. Acceptable for a rebuildable search index — `cqs index
+        // --force` recovers fully. FULL would fsync every commit, ~2x slower
+        // on spinning disk / WSL-NTFS; `[store] synchronous` picks it anyway.
+        .synchronous(pragmas::synchronous())
         .pragma("mmap_size", config.mmap_size)
         .log_slow_statements(log::LevelFilter::Warn, std::time::Duration::from_secs(5));
 
@@ -1373,16 +1480,14 @@ fn open_with_config_impl<Mode>(
     // for the next open to replay. Without this, a long-lived read-only
     // `cqs serve` that never commits never triggers SQLite's default 1000-
     // page autocheckpoint, and the on-disk WAL grows to whatever the kernel
-    // buffered between explicit `wal_checkpoint(TRUNCATE)` calls. 1000 pages
-    // is the SQLite default — set explicitly so it actually applies to
-    // read-mostly connections that rarely COMMIT. Override via
-    // `CQS_WAL_AUTOCHECKPOINT_PAGES` (e.g. for WSL-NTFS boxes where each
-    // checkpoint is expensive — set higher; for tighter recovery — set
-    // lower).
-    let wal_autocheckpoint_pages: u32 = std::env::var("CQS_WAL_AUTOCHECKPOINT_PAGES")
-        .ok()
-        .and_then(|s| s.parse().ok())
-        .unwrap_or(1000);
+    // buffered between explicit `wal_checkpoint(TRUNCATE)` calls. Set
+    // explicitly so it actually applies to read-mostly connections that
+    // rarely COMMIT. The page count is per profile (1000, SQLite's default,
+    // except 10000 for bulk `cqs index`); override via `[store]
+    // wal_autocheckpoint` or `CQS_WAL_AUTOCHECKPOINT_PAGES` (e.g. for WSL-NTFS
+    // boxes where each checkpoint is expensive — set higher; for tighter
+    // recovery — set lower).
+    let wal_autocheckpoint_pages = pragmas::wal_autocheckpoint();
     let wal_pragma = format!("PRAGMA wal_autocheckpoint = {}", wal_autocheckpoint_pages);
 
     // Tighten umask to 0o077 around pool creation so the DB (and WAL/SHM
@@ -1505,12 +1610,15 @@ fn open_with_config_impl<Mode>(
     // we propagate the error and let the caller handle it. On migration
     // failure the pool was consumed and the DB has been restored from the
     // backup; the caller (typically `cqs index --force`) needs to retry.
-    let pool = rt.block_on(migrations::check_and_migrate_schema(
-        pool,
-        path,
-        helpers::CURRENT_SCHEMA_VERSION,
-        config.read_only,
-    ))?;
+    let pool = match config.schema_target {
+        Some(target) => rt.block_on(migrations::downgrade_schema(pool, path, target))?,
+        None => rt.block_on(migrations::check_and_migrate_schema(
+            pool,
+            path,
+            helpers::CURRENT_SCHEMA_VERSION,
+            config.read_only,
+        ))?,
+    };
 
     // Read dim from metadata before constructing Store (avoid unsafe mutation).
     // Defaults to EMBEDDING_DIM for fresh/pre-v15 databases without dimensions key.
@@ -1544,6 +1652,40 @@ fn open_with_config_impl<Mode>(
         })?
         .unwrap_or(crate::EMBEDDING_DIM);
 
+    // Read the FTS analyzer signature the same way. Fresh DBs and indexes
+    // built before the key existed were analyzed with the default.
+    let fts_analyzer = rt
+        .block_on(async {
+            match sqlx::query_as::<_, (String,)>(
+                "SELECT value FROM metadata WHERE key = 'fts_analyzer'",
+            )
+            .fetch_optional(&pool)
+            .await
+            {
+                Ok(row) => Ok(row.map(|(s,)| s)),
+                Err(sqlx::Error::Database(e)) if e.message().contains("no such table") => Ok(None),
+                Err(e) => Err(StoreError::from(e)),
+            }
+        })?
+        .map(|s| {
+            crate::nl::FtsAnalyzer::from_signature(&s).unwrap_or_else(|| {
+                tracing::warn!(
+                    raw = %s,
+                    "fts_analyzer metadata not recognised, using the default analyzer; \
+                     run `cqs index` to rebuild the FTS tables"
+                );
+                crate::nl::FtsAnalyzer::default()
+            })
+        })
+        .unwrap_or_default();
+
+    // Chunk text may be stored as zstd frames (v49); decoding them needs the
+    // store's dictionaries in the process registry before the first read.
+    rt.block_on(async {
+        let mut conn = pool.acquire().await?;
+        compression::load_dicts(&mut conn).await
+    })?;
+
     let summary_queue = Arc::new(summary_queue::PendingSummaryQueue::new(
         pool.clone(),
         Arc::clone(&rt),
@@ -1553,6 +1695,7 @@ fn open_with_config_impl<Mode>(
         pool,
         rt,
         dim,
+        fts_analyzer,
         closed: AtomicBool::new(false),
         notes_summaries_cache: RwLock::new(None),
         note_boost_cache: crate::search::scoring::NoteBoostCache::default(),
@@ -1750,6 +1893,26 @@ impl<Mode> Store<Mode> {
         })
     }
 
+    /// [`Store::close`] for a long-running writer's shutdown path (the watch
+    /// daemon on SIGTERM): commit whatever the coalescing summary queue still
+    /// holds, then run the checkpoint with `WRITE_LOCK` held so no in-process
+    /// write transaction can open underneath it. A write already in progress
+    /// finishes and commits first — shutdown waits for it rather than cutting
+    /// it off. Returns the number of queued rows flushed.
+    ///
+    /// A failed flush does not skip the checkpoint; its error is returned
+    /// after the store is closed.
+    pub fn shutdown(self) -> Result<usize, StoreError> {
+        let _span = tracing::info_span!("store_shutdown").entered();
+        let flushed = self.summary_queue.flush();
+        if let Err(ref e) = flushed {
+            tracing::warn!(error = %e, "Summary queue flush failed during shutdown");
+        }
+        let _guard = WRITE_LOCK.lock().unwrap_or_else(|e| e.into_inner());
+        self.close()?;
+        flushed
+    }
+
     /// Best-effort, non-blocking WAL drain: `PRAGMA wal_checkpoint(PASSIVE)`,
     /// bounded by a short timeout, with every failure mode swallowed.
     ///
@@ -2201,6 +2364,7 @@ mod tests {
             .expect("blocker should acquire the write reservation");
 
         // The store's write must error (locked) rather than hang or panic.
+        let busy_before = crate::metrics::SQLITE_BUSY.get();
         let result = store.upsert_function_calls(
             std::path::Path::new("busy.rs"),
             &[crate::parser::FunctionCalls {
@@ -2216,6 +2380,10 @@ mod tests {
             msg.contains("locked") || msg.contains("busy"),
             "expected a recognizable locked/busy error, got: {msg}"
         );
+        assert!(
+            crate::metrics::SQLITE_BUSY.get() > busy_before,
+            "busy failure should count toward cqs_sqlite_busy_total"
+        );
 
         // Release the reservation; the store can then write successfully.
         store
@@ -2287,6 +2455,52 @@ mod tests {
             .expect("blocker rollback should succeed");
     }
 
+    /// `shutdown` commits rows still sitting in the summary queue and leaves
+    /// an empty WAL behind — nothing queued is lost on a daemon stop.
+    #[test]
+    fn shutdown_flushes_queue_and_truncates_wal() {
+        let (store, dir) = make_test_store_initialized();
+        let db_path = dir.path().join(crate::INDEX_DB_FILENAME);
+        store.summary_queue.push(summary_queue::PendingSummary {
+            custom_id: "queued-at-shutdown".to_string(),
+            text: "body".to_string(),
+            model: "test-model".to_string(),
+            purpose: "summary".to_string(),
+        });
+        assert_eq!(store.summary_queue.pending_len(), 1);
+
+        assert_eq!(store.shutdown().expect("shutdown"), 1);
+
+        let wal = db_path.with_extension("db-wal");
+        let wal_len = std::fs::metadata(&wal).map(|m| m.len()).unwrap_or(0);
+        assert_eq!(wal_len, 0, "TRUNCATE checkpoint must empty the WAL");
+        let reopened = Store::open(&db_path).unwrap();
+        let got = reopened
+            .get_summaries_by_hashes(&["queued-at-shutdown"], "summary")
+            .unwrap();
+        assert_eq!(got.len(), 1, "queued row must survive shutdown");
+    }
+
+    /// A write transaction in flight when shutdown starts (here: a holder of
+    /// `WRITE_LOCK`) must finish before the checkpoint runs — shutdown waits
+    /// instead of closing the pool under it.
+    #[test]
+    #[serial_test::serial]
+    fn shutdown_waits_for_in_progress_write() {
+        let (store, _dir) = make_test_store_initialized();
+        let writer = WRITE_LOCK.lock().unwrap_or_else(|e| e.into_inner());
+
+        let handle = std::thread::spawn(move || store.shutdown());
+        std::thread::sleep(std::time::Duration::from_millis(200));
+        assert!(
+            !handle.is_finished(),
+            "shutdown must block while a write holds WRITE_LOCK"
+        );
+
+        drop(writer);
+        assert_eq!(handle.join().unwrap().expect("shutdown"), 0);
+    }
+
     #[test]
     fn onclock_cache_not_invalidated_by_writes() {
         // get_call_graph() populates the OnceLock cache on first call.
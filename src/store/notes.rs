@@ -10,7 +10,7 @@ use sqlx::Row;
 use super::helpers::sql::max_rows_per_statement;
 use super::helpers::{NoteStats, NoteSummary, StoreError};
 use super::{ReadWrite, Store};
-use crate::nl::normalize_for_fts;
+use crate::nl::FtsAnalyzer;
 use crate::note::Note;
 use crate::note::{SENTIMENT_NEGATIVE_THRESHOLD, SENTIMENT_POSITIVE_THRESHOLD};
 
@@ -29,6 +29,7 @@ async fn insert_notes_with_fts_batched(
     source_str: &str,
     file_mtime: i64,
     now: &str,
+    analyzer: &FtsAnalyzer,
 ) -> Result<(), StoreError> {
     if notes.is_empty() {
         return Ok(());
@@ -45,7 +46,7 @@ async fn insert_notes_with_fts_batched(
         .iter()
         .map(|n| serde_json::to_string(&n.mentions))
         .collect::<Result<Vec<_>, _>>()?;
-    let fts_text: Vec<String> = notes.iter().map(|n| normalize_for_fts(&n.text)).collect();
+    let fts_text: Vec<String> = notes.iter().map(|n| analyzer.index(&n.text)).collect();
 
     // Step 1: INSERT OR REPLACE INTO notes (10 cols per row, including kind).
     const NOTES_BATCH: usize = max_rows_per_statement(10);
@@ -116,7 +117,15 @@ impl Store<ReadWrite> {
             let (_guard, mut tx) = self.begin_write().await?;
 
             let now = chrono::Utc::now().to_rfc3339();
-            insert_notes_with_fts_batched(&mut tx, notes, &source_str, file_mtime, &now).await?;
+            insert_notes_with_fts_batched(
+                &mut tx,
+                notes,
+                &source_str,
+                file_mtime,
+                &now,
+                &self.fts_analyzer,
+            )
+            .await?;
 
             tx.commit().await?;
             self.invalidate_notes_cache();
@@ -210,8 +219,15 @@ impl Store<ReadWrite> {
             let (_guard, mut tx) = self.begin_write().await?;
             let now = chrono::Utc::now().to_rfc3339();
             for (source_str, file_mtime, notes) in &groups {
-                insert_notes_with_fts_batched(&mut tx, notes, source_str, *file_mtime, &now)
-                    .await?;
+                insert_notes_with_fts_batched(
+                    &mut tx,
+                    notes,
+                    source_str,
+                    *file_mtime,
+                    &now,
+                    &self.fts_analyzer,
+                )
+                .await?;
             }
             tx.commit().await?;
             self.invalidate_notes_cache();
@@ -262,7 +278,15 @@ impl Store<ReadWrite> {
 
             // Step 2: Insert new notes + FTS (batched).
             let now = chrono::Utc::now().to_rfc3339();
-            insert_notes_with_fts_batched(&mut tx, notes, &source_str, file_mtime, &now).await?;
+            insert_notes_with_fts_batched(
+                &mut tx,
+                notes,
+                &source_str,
+                file_mtime,
+                &now,
+                &self.fts_analyzer,
+            )
+            .await?;
 
             tx.commit().await?;
             self.invalidate_notes_cache();
@@ -0,0 +1,266 @@
+//! SQLite pragma and pool-size resolution for store opens.
+//!
+//! Every `Store::open*` variant takes its busy timeout, WAL autocheckpoint,
+//! mmap size, page cache, synchronous level and read-pool size from here;
+//! query paths take their busy-retry budget and chunk-read verification
+//! mode from here too.
+//! Each knob resolves env var > `[store.<mode>]` > `[store]` > the built-in
+//! default of the process's [`StoreProfile`]:
+//!
+//! | knob                 | env                            | search  | index   | daemon  |
+//! |----------------------|--------------------------------|---------|---------|---------|
+//! | `busy_timeout_ms`    | `CQS_BUSY_TIMEOUT_MS`          | 10000   | 30000   | 30000   |
+//! | `wal_autocheckpoint` | `CQS_WAL_AUTOCHECKPOINT_PAGES` | 1000    | 10000   | 1000    |
+//! | `cache_size`         | `CQS_SQLITE_CACHE_SIZE`        | 16 MiB  | 64 MiB  | 32 MiB  |
+//! | `synchronous`        | `CQS_SQLITE_SYNCHRONOUS`       | normal  | normal  | normal  |
+//! | `read_pool_size`     | `CQS_READ_POOL_SIZE`           | 1       | 1       | 4       |
+//! | `read_retries`       | `CQS_READ_RETRIES`             | 3       | 3       | 5       |
+//! | `verify_reads`       | `CQS_VERIFY_READS`             | sample  | sample  | sample  |
+//! | `mmap_size`          | `CQS_MMAP_SIZE`                | per handle (256 / 64 / 16 MiB) |||
+//!
+//! Interactive commands give up on a locked database sooner; a bulk index
+//! checkpoints less often and keeps more pages hot; the daemon answers
+//! concurrent queries from a wider read pool and retries a busy read longer. The `cache_size` column is the
+//! default for the primary handles (`open`, `open_readonly_pooled`); the
+//! smaller `open_readonly` / `open_readonly_small` handles keep their own
+//! unless `[store] cache_size` or the env var says otherwise.
+//!
+//! The CLI calls [`configure_store`] once at startup, before the first open.
+//! Library callers that never do get the `Search` defaults and no config.
+
+use std::sync::OnceLock;
+use std::time::Duration;
+
+use sqlx::sqlite::SqliteSynchronous;
+
+use crate::config::{StoreConfig, StorePragmas, SynchronousLevel, VerifyReads};
+
+/// Which kind of process is opening the index. Picks the built-in defaults
+/// and the `[store.<mode>]` sub-table.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub enum StoreProfile {
+    /// One-shot CLI commands: search, callers, notes, gc, ….
+    #[default]
+    Search,
+    /// `cqs index`: bulk writes.
+    Index,
+    /// `cqs watch`, with or without `--serve`.
+    Daemon,
+}
+
+/// Built-in values for one profile.
+struct Defaults {
+    busy_timeout_ms: u64,
+    wal_autocheckpoint: u32,
+    cache_size: i64,
+    read_pool_size: u32,
+    read_retries: u32,
+}
+
+impl StoreProfile {
+    fn defaults(self) -> Defaults {
+        match self {
+            StoreProfile::Search => Defaults {
+                busy_timeout_ms: 10_000,
+                wal_autocheckpoint: 1000,
+                cache_size: -16384,
+                read_pool_size: 1,
+                read_retries: 3,
+            },
+            StoreProfile::Index => Defaults {
+                busy_timeout_ms: 30_000,
+                wal_autocheckpoint: 10_000,
+                cache_size: -65536,
+                read_pool_size: 1,
+                read_retries: 3,
+            },
+            StoreProfile::Daemon => Defaults {
+                busy_timeout_ms: 30_000,
+                wal_autocheckpoint: 1000,
+                cache_size: -32768,
+                read_pool_size: 4,
+                read_retries: 5,
+            },
+        }
+    }
+}
+
+static PROFILE: OnceLock<StoreProfile> = OnceLock::new();
+static CONFIG: OnceLock<StoreConfig> = OnceLock::new();
+
+/// Record the process profile and the `[store]` section. Must be called
+/// before the first store open; later calls are no-ops (OnceLock).
+pub fn configure_store(profile: StoreProfile, config: Option<&StoreConfig>) {
+    let _ = PROFILE.set(profile);
+    if let Some(config) = config {
+        let _ = CONFIG.set(config.clone());
+    }
+}
+
+fn profile() -> StoreProfile {
+    PROFILE.get().copied().unwrap_or_default()
+}
+
+/// `[store.<mode>]`, then `[store]`.
+fn configured<T>(pick: impl Fn(&StorePragmas) -> Option<T>) -> Option<T> {
+    let config = CONFIG.get()?;
+    resolve_layers(config, profile(), pick)
+}
+
+/// Split from [`configured`] so tests don't depend on the process globals.
+fn resolve_layers<T>(
+    config: &StoreConfig,
+    profile: StoreProfile,
+    pick: impl Fn(&StorePragmas) -> Option<T>,
+) -> Option<T> {
+    let mode = match profile {
+        StoreProfile::Search => config.search.as_ref(),
+        StoreProfile::Index => config.index.as_ref(),
+        StoreProfile::Daemon => config.daemon.as_ref(),
+    };
+    mode.and_then(&pick).or_else(|| pick(&config.pragmas))
+}
+
+fn env<T: std::str::FromStr>(key: &str) -> Option<T> {
+    std::env::var(key).ok().and_then(|v| v.trim().parse().ok())
+}
+
+/// Busy timeout for store pools.
+pub(crate) fn busy_timeout() -> Duration {
+    let default =
+        configured(|p| p.busy_timeout_ms).unwrap_or_else(|| profile().defaults().busy_timeout_ms);
+    super::helpers::sql::busy_timeout_from_env(default)
+}
+
+/// `PRAGMA wal_autocheckpoint` page count.
+pub(crate) fn wal_autocheckpoint() -> u32 {
+    env("CQS_WAL_AUTOCHECKPOINT_PAGES")
+        .or_else(|| configured(|p| p.wal_autocheckpoint))
+        .unwrap_or(profile().defaults().wal_autocheckpoint)
+}
+
+/// `PRAGMA synchronous` level.
+pub(crate) fn synchronous() -> SqliteSynchronous {
+    let level = std::env::var("CQS_SQLITE_SYNCHRONOUS")
+        .ok()
+        .and_then(|v| SynchronousLevel::parse(&v))
+        .or_else(|| configured(|p| p.synchronous))
+        .unwrap_or(SynchronousLevel::Normal);
+    match level {
+        SynchronousLevel::Off => SqliteSynchronous::Off,
+        SynchronousLevel::Normal => SqliteSynchronous::Normal,
+        SynchronousLevel::Full => SqliteSynchronous::Full,
+        SynchronousLevel::Extra => SqliteSynchronous::Extra,
+    }
+}
+
+/// Max connections of the read-only pooled handle.
+pub(crate) fn read_pool_size() -> u32 {
+    env::<u32>("CQS_READ_POOL_SIZE")
+        .filter(|&n| n > 0)
+        .or_else(|| configured(|p| p.read_pool_size))
+        .unwrap_or(profile().defaults().read_pool_size)
+        .clamp(1, 64)
+}
+
+/// Reruns of a query that failed with `SQLITE_BUSY` / `SQLITE_LOCKED`
+/// (see [`super::snapshot`]). `0` surfaces the first busy error.
+pub(crate) fn read_retries() -> u32 {
+    env::<u32>("CQS_READ_RETRIES")
+        .or_else(|| configured(|p| p.read_retries))
+        .unwrap_or(profile().defaults().read_retries)
+        .min(MAX_READ_RETRIES)
+}
+
+/// Which chunk reads recheck their `content_hash` (see [`super::checksum`]).
+/// Same in every profile; an unparsable env value falls through.
+pub(crate) fn verify_reads() -> VerifyReads {
+    std::env::var("CQS_VERIFY_READS")
+        .ok()
+        .and_then(|v| VerifyReads::parse(&v))
+        .or_else(|| configured(|p| p.verify_reads))
+        .unwrap_or(VerifyReads::Sample)
+}
+
+/// Upper bound on [`read_retries`]; with the backoff cap that is at most a
+/// few seconds of waiting on top of the busy timeout.
+pub(crate) const MAX_READ_RETRIES: u32 = 20;
+
+/// `cache_size` default for the primary handles under this profile.
+pub(crate) fn primary_cache_size() -> i64 {
+    profile().defaults().cache_size
+}
+
+/// `[store] mmap_size`, if set. The env var is checked by the caller.
+pub(crate) fn configured_mmap_size() -> Option<u64> {
+    configured(|p| p.mmap_size)
+}
+
+/// `[store] cache_size`, if set. The env var is checked by the caller.
+pub(crate) fn configured_cache_size() -> Option<i64> {
+    configured(|p| p.cache_size)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn mode_table_beats_top_level() {
+        let config: StoreConfig = toml::from_str(
+            r#"
+            busy_timeout_ms = 5000
+            read_pool_size = 2
+            [index]
+            busy_timeout_ms = 60000
+            "#,
+        )
+        .unwrap();
+        let busy = |profile| resolve_layers(&config, profile, |p| p.busy_timeout_ms);
+        assert_eq!(busy(StoreProfile::Index), Some(60_000));
+        assert_eq!(busy(StoreProfile::Search), Some(5000));
+        assert_eq!(
+            resolve_layers(&config, StoreProfile::Index, |p| p.read_pool_size),
+            Some(2)
+        );
+        assert_eq!(
+            resolve_layers(&config, StoreProfile::Daemon, |p| p.wal_autocheckpoint),
+            None
+        );
+    }
+
+    #[test]
+    fn synchronous_parses_sqlite_spelling() {
+        let config: StoreConfig = toml::from_str("synchronous = \"full\"").unwrap();
+        assert_eq!(config.pragmas.synchronous, Some(SynchronousLevel::Full));
+        assert_eq!(
+            SynchronousLevel::parse(" OFF "),
+            Some(SynchronousLevel::Off)
+        );
+        assert_eq!(SynchronousLevel::parse("fast"), None);
+        assert!(toml::from_str::<StoreConfig>("synchronous = \"fast\"").is_err());
+    }
+
+    #[test]
+    fn verify_reads_parses_all_modes() {
+        assert_eq!(VerifyReads::parse(" Full "), Some(VerifyReads::Full));
+        assert_eq!(VerifyReads::parse("sample"), Some(VerifyReads::Sample));
+        assert_eq!(VerifyReads::parse("OFF"), Some(VerifyReads::Off));
+        assert_eq!(VerifyReads::parse("always"), None);
+        assert!(toml::from_str::<StoreConfig>("verify_reads = \"always\"").is_err());
+    }
+
+    #[test]
+    fn profiles_differ_where_documented() {
+        let (search, index, daemon) = (
+            StoreProfile::Search.defaults(),
+            StoreProfile::Index.defaults(),
+            StoreProfile::Daemon.defaults(),
+        );
+        assert!(search.busy_timeout_ms < index.busy_timeout_ms);
+        assert!(index.wal_autocheckpoint > search.wal_autocheckpoint);
+        assert!(index.cache_size < daemon.cache_size && daemon.cache_size < search.cache_size);
+        assert!(daemon.read_pool_size > search.read_pool_size);
+        assert!(daemon.read_retries > search.read_retries);
+    }
+}
@@ -0,0 +1,429 @@
+//! Symbol rename tracking across reindexes (schema v33).
+//!
+//! A chunk's id and content hash both change when its function is renamed, so
+//! without help a rename looks like "delete `old`, add `new`": the LLM summary
+//! keyed by the old content hash is orphaned (and later pruned), and eval gold
+//! labels that name `old` silently stop matching anything.
+//!
+//! The per-file write path (`upsert_chunks_calls_and_prune_inner`) calls
+//! [`record_renames_in_tx`] after the new chunks are written and before the
+//! phantom prune. It pairs chunks that are about to vanish with chunks that
+//! just appeared in the same file, records each pair as a `symbol_renames`
+//! edge, and copies the old hash's `llm_summaries` rows onto the new hash.
+//! Embeddings are NOT carried forward: the name is part of the embedded text,
+//! so the new chunk is embedded fresh like any other changed chunk.
+//!
+//! Edges have no FK to `chunks` — they are history and must outlive both ends.
+
+use std::collections::{HashMap, HashSet};
+
+use super::helpers::StoreError;
+use super::Store;
+use crate::parser::Chunk;
+
+/// Minimum token-set Jaccard similarity for a fuzzy rename match, applied to
+/// both the name-normalized signature and the name-normalized body.
+pub const RENAME_SIMILARITY_THRESHOLD: f32 = 0.8;
+
+/// One recorded rename: `old_name` became `new_name` in `origin`.
+#[derive(Debug, Clone, PartialEq, serde::Serialize)]
+pub struct RenameEdge {
+    pub origin: String,
+    pub chunk_type: String,
+    pub old_name: String,
+    pub new_name: String,
+    /// 1.0 when the body is identical up to the name; otherwise the body's
+    /// token-set Jaccard similarity after substituting the new name.
+    pub similarity: f32,
+    /// RFC 3339 timestamp of the reindex that detected the rename.
+    pub detected_at: String,
+}
+
+/// One side of a candidate rename pair.
+#[derive(Debug, Clone)]
+pub(crate) struct RenameSide {
+    pub name: String,
+    pub chunk_type: String,
+    pub signature: String,
+    pub content: String,
+    pub content_hash: String,
+}
+
+impl RenameSide {
+    fn from_chunk(chunk: &Chunk) -> Self {
+        Self {
+            name: chunk.name.clone(),
+            chunk_type: chunk.chunk_type.to_string(),
+            signature: chunk.signature.clone(),
+            content: chunk.content.clone(),
+            content_hash: chunk.content_hash.clone(),
+        }
+    }
+}
+
+fn is_ident_char(c: char) -> bool {
+    c.is_alphanumeric() || c == '_'
+}
+
+/// Replace whole-identifier occurrences of `old` with `new` in `text`.
+/// `get_user` inside `get_user_id` is left alone.
+fn substitute_name(text: &str, old: &str, new: &str) -> String {
+    if old.is_empty() {
+        return text.to_string();
+    }
+    let mut out = String::with_capacity(text.len());
+    let mut rest = text;
+    let mut prev: Option<char> = None;
+    while let Some(pos) = rest.find(old) {
+        let before = rest[..pos].chars().next_back().or(prev);
+        let after = rest[pos + old.len()..].chars().next();
+        out.push_str(&rest[..pos]);
+        if before.is_some_and(is_ident_char) || after.is_some_and(is_ident_char) {
+            out.push_str(old);
+        } else {
+            out.push_str(new);
+        }
+        prev = old.chars().next_back();
+        rest = &rest[pos + old.len()..];
+    }
+    out.push_str(rest);
+    out
+}
+
+fn tokens(text: &str) -> HashSet<&str> {
+    text.split(|c: char| !is_ident_char(c))
+        .filter(|t| !t.is_empty())
+        .collect()
+}
+
+/// Token-set Jaccard similarity. Two empty sets count as identical so a chunk
+/// kind with no signature is judged on its body alone.
+fn jaccard(a: &str, b: &str) -> f32 {
+    let (ta, tb) = (tokens(a), tokens(b));
+    if ta.is_empty() && tb.is_empty() {
+        return 1.0;
+    }
+    let inter = ta.intersection(&tb).count();
+    let union = ta.len() + tb.len() - inter;
+    inter as f32 / union as f32
+}
+
+/// Similarity of `old` renamed to `new.name` against `new`, or `None` when the
+/// pair isn't a rename.
+fn rename_similarity(old: &RenameSide, new: &RenameSide) -> Option<f32> {
+    if old.chunk_type != new.chunk_type || old.name == new.name {
+        return None;
+    }
+    let renamed_body = substitute_name(&old.content, &old.name, &new.name);
+    if blake3::hash(renamed_body.as_bytes()).to_hex().as_str() == new.content_hash {
+        return Some(1.0);
+    }
+    let renamed_sig = substitute_name(&old.signature, &old.name, &new.name);
+    if jaccard(&renamed_sig, &new.signature) < RENAME_SIMILARITY_THRESHOLD {
+        return None;
+    }
+    let body = jaccard(&renamed_body, &new.content);
+    (body >= RENAME_SIMILARITY_THRESHOLD).then_some(body)
+}
+
+/// Pair vanished chunks with appeared chunks of one file.
+///
+/// Returns `(vanished_idx, appeared_idx, similarity)` triples, one-to-one,
+/// best similarity first. A name present on both sides is an edit, not a
+/// rename, so it is excluded from pairing on either side.
+pub(crate) fn match_renames(
+    vanished: &[RenameSide],
+    appeared: &[RenameSide],
+) -> Vec<(usize, usize, f32)> {
+    let vanished_names: HashSet<&str> = vanished.iter().map(|s| s.name.as_str()).collect();
+    let appeared_names: HashSet<&str> = appeared.iter().map(|s| s.name.as_str()).collect();
+
+    let mut candidates = Vec::new();
+    for (vi, old) in vanished.iter().enumerate() {
+        if appeared_names.contains(old.name.as_str()) {
+            continue;
+        }
+        for (ai, new) in appeared.iter().enumerate() {
+            if vanished_names.contains(new.name.as_str()) {
+                continue;
+            }
+            if let Some(sim) = rename_similarity(old, new) {
+                candidates.push((vi, ai, sim));
+            }
+        }
+    }
+    candidates.sort_by(|a, b| b.2.total_cmp(&a.2).then(a.0.cmp(&b.0)).then(a.1.cmp(&b.1)));
+
+    let (mut used_v, mut used_a) = (HashSet::new(), HashSet::new());
+    candidates
+        .into_iter()
+        .filter(|(vi, ai, _)| {
+            if used_v.contains(vi) || used_a.contains(ai) {
+                return false;
+            }
+            used_v.insert(*vi);
+            used_a.insert(*ai);
+            true
+        })
+        .collect()
+}
+
+/// Detect renames between the chunks of `origin` that are about to be pruned
+/// (present in `chunks`, absent from the `_live_ids` temp table) and the
+/// `appeared` chunks just written for the same file, then record the edges and
+/// copy LLM summaries forward. Runs inside the caller's per-file transaction,
+/// so the edges commit atomically with the reindex that caused them.
+///
+/// Only unwindowed chunks and the first window of a windowed chunk take part,
+/// so a long renamed function yields one edge rather than one per window.
+pub(crate) async fn record_renames_in_tx(
+    tx: &mut sqlx::Transaction<'_, sqlx::Sqlite>,
+    origin: &str,
+    appeared: &[&Chunk],
+    now: &str,
+) -> Result<usize, StoreError> {
+    let appeared: Vec<RenameSide> = appeared
+        .iter()
+        .filter(|c| c.window_idx.unwrap_or(0) == 0)
+        .map(|c| RenameSide::from_chunk(c))
+        .collect();
+    if appeared.is_empty() {
+        return Ok(0);
+    }
+
+    let rows: Vec<(String, String, String, Vec<u8>, String)> = sqlx::query_as(
+        "SELECT name, chunk_type, signature, CAST(content AS BLOB), content_hash FROM chunks \
+         WHERE origin = ?1 AND id NOT IN (SELECT id FROM _live_ids) \
+         AND COALESCE(window_idx, 0) = 0",
+    )
+    .bind(origin)
+    .fetch_all(&mut **tx)
+    .await?;
+    if rows.is_empty() {
+        return Ok(0);
+    }
+    let vanished: Vec<RenameSide> = rows
+        .into_iter()
+        .map(
+            |(name, chunk_type, signature, stored, content_hash)| RenameSide {
+                content: super::compression::decode_or_empty(stored, &name),
+                name,
+                chunk_type,
+                signature,
+                content_hash,
+            },
+        )
+        .collect();
+
+    let pairs = match_renames(&vanished, &appeared);
+    for &(vi, ai, similarity) in &pairs {
+        let (old, new) = (&vanished[vi], &appeared[ai]);
+        sqlx::query(
+            "INSERT INTO symbol_renames \
+             (origin, chunk_type, old_name, new_name, old_content_hash, \
+              new_content_hash, similarity, detected_at) \
+             VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8)",
+        )
+        .bind(origin)
+        .bind(&new.chunk_type)
+        .bind(&old.name)
+        .bind(&new.name)
+        .bind(&old.content_hash)
+        .bind(&new.content_hash)
+        .bind(f64::from(similarity))
+        .bind(now)
+        .execute(&mut **tx)
+        .await?;
+
+        // The old hash's summaries describe the same code; carry them so the
+        // enrichment pass doesn't pay to regenerate them. An existing row for
+        // the new hash wins.
+        sqlx::query(
+            "INSERT OR IGNORE INTO llm_summaries \
+             (content_hash, purpose, summary, model, created_at) \
+             SELECT ?1, purpose, summary, model, created_at \
+             FROM llm_summaries WHERE content_hash = ?2",
+        )
+        .bind(&new.content_hash)
+        .bind(&old.content_hash)
+        .execute(&mut **tx)
+        .await?;
+
+        tracing::info!(
+            origin,
+            old = %old.name,
+            new = %new.name,
+            similarity,
+            "Detected symbol rename"
+        );
+    }
+    Ok(pairs.len())
+}
+
+type RenameRow = (String, String, String, String, f64, String);
+
+fn edge_from_row(row: RenameRow) -> RenameEdge {
+    let (origin, chunk_type, old_name, new_name, similarity, detected_at) = row;
+    RenameEdge {
+        origin,
+        chunk_type,
+        old_name,
+        new_name,
+        similarity: similarity as f32,
+        detected_at,
+    }
+}
+
+impl<Mode> Store<Mode> {
+    /// Every rename edge in the chain(s) `name` belongs to, oldest first.
+    ///
+    /// Follows edges both ways, so asking about any name a symbol has ever
+    /// had returns its whole history (`a → b → c` for `a`, `b`, or `c`).
+    pub fn rename_history(&self, name: &str) -> Result<Vec<RenameEdge>, StoreError> {
+        let _span = tracing::info_span!("rename_history", name).entered();
+        self.rt.block_on(async {
+            let mut seen_names: HashSet<String> = HashSet::new();
+            let mut frontier = vec![name.to_string()];
+            let mut edges: HashMap<i64, RenameEdge> = HashMap::new();
+            while let Some(current) = frontier.pop() {
+                if !seen_names.insert(current.clone()) {
+                    continue;
+                }
+                let rows: Vec<(i64, String, String, String, String, f64, String)> = sqlx::query_as(
+                    "SELECT id, origin, chunk_type, old_name, new_name, similarity, \
+                         detected_at FROM symbol_renames \
+                         WHERE old_name = ?1 OR new_name = ?1",
+                )
+                .bind(&current)
+                .fetch_all(&self.pool)
+                .await?;
+                for (id, origin, chunk_type, old_name, new_name, similarity, detected_at) in rows {
+                    frontier.push(old_name.clone());
+                    frontier.push(new_name.clone());
+                    edges.entry(id).or_insert_with(|| {
+                        edge_from_row((
+                            origin,
+                            chunk_type,
+                            old_name,
+                            new_name,
+                            similarity,
+                            detected_at,
+                        ))
+                    });
+                }
+            }
+            let mut ordered: Vec<(i64, RenameEdge)> = edges.into_iter().collect();
+            ordered.sort_by_key(|(id, _)| *id);
+            Ok(ordered.into_iter().map(|(_, e)| e).collect())
+        })
+    }
+
+    /// The name `name` in `origin` goes by now, following rename edges
+    /// forward. `None` when it was never renamed. A cycle (`a → b → a`)
+    /// resolves to the last name reached before repeating.
+    pub fn current_name(&self, origin: &str, name: &str) -> Result<Option<String>, StoreError> {
+        let _span = tracing::info_span!("current_name", origin, name).entered();
+        let origin = crate::normalize_slashes(origin);
+        self.rt.block_on(async {
+            let mut seen: HashSet<String> = HashSet::from([name.to_string()]);
+            let mut current = name.to_string();
+            loop {
+                let next: Option<(String,)> = sqlx::query_as(
+                    "SELECT new_name FROM symbol_renames \
+                     WHERE origin = ?1 AND old_name = ?2 ORDER BY id DESC LIMIT 1",
+                )
+                .bind(&origin)
+                .bind(&current)
+                .fetch_optional(&self.pool)
+                .await?;
+                match next {
+                    Some((new_name,)) if seen.insert(new_name.clone()) => current = new_name,
+                    _ => break,
+                }
+            }
+            Ok((current != name).then_some(current))
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn side(name: &str, signature: &str, content: &str) -> RenameSide {
+        RenameSide {
+            name: name.to_string(),
+            chunk_type: "function".to_string(),
+            signature: signature.to_string(),
+            content: content.to_string(),
+            content_hash: blake3::hash(content.as_bytes()).to_hex().to_string(),
+        }
+    }
+
+    #[test]
+    fn substitute_name_respects_identifier_boundaries() {
+        assert_eq!(
+            substitute_name(
+                "fn get_user() { get_user_id(); get_user() }",
+                "get_user",
+                "fetch"
+            ),
+            "fn fetch() { get_user_id(); fetch() }"
+        );
+        assert_eq!(
+            substitute_name("my_get_user", "get_user", "x"),
+            "my_get_user"
+        );
+    }
+
+    #[test]
+    fn pure_rename_matches_exactly() {
+        let old = side("load", "fn load(p: &Path)", "fn load(p: &Path) { read(p) }");
+        let new = side("open", "fn open(p: &Path)", "fn open(p: &Path) { read(p) }");
+        assert_eq!(match_renames(&[old], &[new]), vec![(0, 0, 1.0)]);
+    }
+
+    #[test]
+    fn rename_with_small_edit_matches_fuzzily() {
+        let old = side(
+            "load",
+            "fn load(path: &Path, strict: bool) -> Result<Config>",
+            "fn load(path: &Path, strict: bool) -> Result<Config> { let text = read(path)?; \
+             let cfg = parse(&text, strict)?; validate(&cfg)?; Ok(cfg) }",
+        );
+        let new = side(
+            "load_config",
+            "fn load_config(path: &Path, strict: bool) -> Result<Config>",
+            "fn load_config(path: &Path, strict: bool) -> Result<Config> { let text = read(path)?; \
+             let cfg = parse(&text, strict)?; validate(&cfg)?; log(&cfg); Ok(cfg) }",
+        );
+        let pairs = match_renames(&[old], &[new]);
+        assert_eq!(pairs.len(), 1);
+        assert!(pairs[0].2 >= RENAME_SIMILARITY_THRESHOLD && pairs[0].2 < 1.0);
+    }
+
+    #[test]
+    fn unrelated_or_same_name_chunks_do_not_match() {
+        let old = side("load", "fn load()", "fn load() { read_config() }");
+        let unrelated = side(
+            "draw",
+            "fn draw(c: &Canvas)",
+            "fn draw(c: &Canvas) { c.fill() }",
+        );
+        assert!(match_renames(&[old.clone()], &[unrelated]).is_empty());
+
+        // `load` still exists after the reindex → edited, not renamed.
+        let edited = side("load", "fn load()", "fn load() { read_config(); log() }");
+        let copy = side("reload", "fn reload()", "fn reload() { read_config() }");
+        assert!(match_renames(&[old], &[edited, copy]).is_empty());
+    }
+
+    #[test]
+    fn match_renames_is_one_to_one_best_first() {
+        let a = side("a", "fn a()", "fn a() { step_one(); step_two() }");
+        let b = side("b", "fn b()", "fn b() { step_one(); step_two() }");
+        let c = side("c", "fn c()", "fn c() { step_one(); step_two() }");
+        let pairs = match_renames(&[a, b], &[c]);
+        assert_eq!(pairs, vec![(0, 0, 1.0)], "one appeared chunk pairs once");
+    }
+}
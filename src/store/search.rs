@@ -2,11 +2,11 @@
 
 use std::future::Future;
 
+use sqlx::sqlite::SqliteConnection;
 use sqlx::Row;
 
 use super::helpers::{self, ChunkRow, SearchResult};
 use super::{sanitize_fts_query, ChunkSummary, Store, StoreError};
-use crate::nl::normalize_for_fts;
 
 /// One row of the brute-force candidate scan: cursor position plus the
 /// scoring-relevant columns. `name` is populated only when the caller asked
@@ -25,11 +25,11 @@ pub(crate) struct BruteForceRow {
 impl<Mode> Store<Mode> {
     /// Drive an async future to completion on the store's runtime.
     ///
-    /// The store owns the tokio runtime; search drives its algorithmic
-    /// pipeline (which interleaves store async SQL methods) through this
-    /// wrapper rather than reaching the private `rt` field directly. Keeps
-    /// the runtime an implementation detail of the store while letting the
-    /// search module compose store async calls.
+    /// The store owns the tokio runtime; callers outside the store drive
+    /// composed store async calls through this wrapper rather than reaching
+    /// the private `rt` field directly. Keeps the runtime an implementation
+    /// detail of the store. Search pipelines go through
+    /// [`Self::read_retrying`] instead, which adds busy retries.
     pub(crate) fn block_on<F: Future>(&self, fut: F) -> F::Output {
         self.rt.block_on(fut)
     }
@@ -42,9 +42,11 @@ impl<Mode> Store<Mode> {
     /// limit. `need_name` controls whether the `name` column is read into the
     /// returned rows. Store-executed SQL — the template is still composed
     /// search-side (its conditions come from the search filter), and the
-    /// scoring loop reads the returned rows search-side.
+    /// scoring loop reads the returned rows search-side. Runs on the caller's
+    /// read snapshot so every batch of one scan sees the same rows.
     pub(crate) async fn fetch_brute_force_batch(
         &self,
+        conn: &mut SqliteConnection,
         sql: &str,
         bind_values: &[String],
         last_rowid: i64,
@@ -57,7 +59,7 @@ impl<Mode> Store<Mode> {
         }
         q = q.bind(last_rowid);
         q = q.bind(batch_size);
-        let rows = q.fetch_all(&self.pool).await?;
+        let rows = q.fetch_all(&mut *conn).await?;
 
         Ok(rows
             .iter()
@@ -95,7 +97,7 @@ impl<Mode> Store<Mode> {
     ///   brute force. Best for: Large indexes (>5k chunks) where brute force is slow.
     pub fn search_fts(&self, query: &str, limit: usize) -> Result<Vec<String>, StoreError> {
         let _span = tracing::info_span!("search_fts", limit).entered();
-        let normalized_query = sanitize_fts_query(&normalize_for_fts(query));
+        let normalized_query = sanitize_fts_query(&self.fts_analyzer.query(query));
         if normalized_query.is_empty() {
             tracing::debug!(
                 original_query = %query,
@@ -104,8 +106,9 @@ impl<Mode> Store<Mode> {
             return Ok(vec![]);
         }
 
-        self.rt
-            .block_on(async { self.fts_match_ids(&normalized_query, limit).await })
+        self.read_retrying("search_fts", || {
+            self.fts_match_ids(&normalized_query, limit)
+        })
     }
 
     /// Run an already-sanitized FTS5 MATCH query and return chunk IDs in
@@ -119,21 +122,48 @@ impl<Mode> Store<Mode> {
     /// `--llm-summaries` reindex's partial state.
     ///
     /// `fts_query` must already be normalized/sanitized (callers run
-    /// `normalize_for_fts` + `sanitize_fts_query`, optionally
+    /// the store's FTS analyzer + `sanitize_fts_query`, optionally
     /// `expand_query_for_fts`); this helper binds it to MATCH verbatim.
     pub(crate) async fn fts_match_ids(
         &self,
         fts_query: &str,
         limit: usize,
     ) -> Result<Vec<String>, StoreError> {
+        let mut conn = self.pool.acquire().await?;
         Ok(self
-            .fts_match_id_origins(fts_query, limit)
+            .fts_match_id_origins(&mut conn, fts_query, limit)
             .await?
             .into_iter()
             .map(|(id, _origin)| id)
             .collect())
     }
 
+    /// IDs of the chunks a search must not return: deprecated chunks under
+    /// `--hide-deprecated` (`chunks.deprecated`, schema v46), and generated
+    /// or license boilerplate unless `--include-generated`
+    /// (`chunks.boilerplate`, schema v47). Both columns have partial
+    /// indexes, so the cost tracks the number of hidden chunks, not the
+    /// index size. No query when nothing is hidden.
+    pub(crate) async fn hidden_chunk_ids(
+        &self,
+        conn: &mut SqliteConnection,
+        filter: &crate::store::SearchFilter,
+    ) -> Result<std::collections::HashSet<String>, StoreError> {
+        let mut conditions = Vec::new();
+        if filter.hide_deprecated {
+            conditions.push("deprecated = 1");
+        }
+        if !filter.include_generated {
+            conditions.push("boilerplate IS NOT NULL");
+        }
+        if conditions.is_empty() {
+            return Ok(std::collections::HashSet::new());
+        }
+        let sql = format!("SELECT id FROM chunks WHERE {}", conditions.join(" OR "));
+        let rows: Vec<(String,)> = sqlx::query_as(&sql).fetch_all(&mut *conn).await?;
+        Ok(rows.into_iter().map(|(id,)| id).collect())
+    }
+
     /// Like [`Self::fts_match_ids`] but also returns each chunk's `origin`
     /// (authoritative file path) alongside its id, in the same bm25 order.
     ///
@@ -142,21 +172,32 @@ impl<Mode> Store<Mode> {
     /// out of the chunk id (the id is `path:line_start:byte_start:hash8` and
     /// is NOT a reliable path source). The JOIN to `chunks` is already present
     /// for the `needs_embedding` gate, so `c.origin` is free.
+    ///
+    /// Ranked with the `fts_code_weight` / `fts_comment_weight` knobs on the
+    /// code and comment columns. Runs on `conn` so the keyword leg reads the
+    /// same snapshot as the rest of the search.
     pub(crate) async fn fts_match_id_origins(
         &self,
+        conn: &mut SqliteConnection,
         fts_query: &str,
         limit: usize,
     ) -> Result<Vec<(String, String)>, StoreError> {
-        let rows: Vec<(String, String)> = sqlx::query_as(
+        use crate::search::scoring::knob::resolve_knob;
+        let sql = format!(
             "SELECT f.id, c.origin FROM chunks_fts f \
              JOIN chunks c ON c.id = f.id \
              WHERE chunks_fts MATCH ?1 AND c.needs_embedding = 0 \
-             ORDER BY bm25(chunks_fts) LIMIT ?2",
-        )
-        .bind(fts_query)
-        .bind(limit as i64)
-        .fetch_all(&self.pool)
-        .await?;
+             ORDER BY {ord} LIMIT ?2",
+            ord = super::helpers::bm25_keyword_expr(
+                resolve_knob("fts_code_weight"),
+                resolve_knob("fts_comment_weight"),
+            ),
+        );
+        let rows: Vec<(String, String)> = sqlx::query_as(sqlx::AssertSqlSafe(sql.as_str()))
+            .bind(fts_query)
+            .bind(limit as i64)
+            .fetch_all(&mut *conn)
+            .await?;
 
         Ok(rows)
     }
@@ -190,7 +231,7 @@ impl<Mode> Store<Mode> {
             );
         }
         let limit = limit.min(NAME_SEARCH_CAP);
-        let normalized = sanitize_fts_query(&normalize_for_fts(name));
+        let normalized = sanitize_fts_query(&self.fts_analyzer.query(name));
         if normalized.is_empty() {
             return Ok(vec![]);
         }
@@ -232,11 +273,13 @@ impl<Mode> Store<Mode> {
             ord = super::helpers::bm25_ordering_expr(),
         );
 
-        self.rt.block_on(async {
+        self.read_retrying("search_by_name", || async {
+            let mut snap = self.begin_read().await?;
+            self.record_search_generation(&mut snap).await?;
             let rows: Vec<_> = sqlx::query(sqlx::AssertSqlSafe(sql.as_str()))
                 .bind(&fts_query)
                 .bind(limit as i64)
-                .fetch_all(&self.pool)
+                .fetch_all(&mut *snap)
                 .await?;
 
             // Skip the per-row `to_lowercase()` allocation when both query
@@ -320,11 +363,11 @@ mod tests {
             .unwrap();
 
             // FTS5 join target — `search_by_name` matches against `chunks_fts`
-            // and the join is by `id`. Use the same `normalize_for_fts` the
-            // real upsert uses so query tokens match.
+            // and the join is by `id`. Use the same `normalize_for_fts_index`
+            // the real upsert uses so query tokens match.
             sqlx::query("INSERT INTO chunks_fts (id, name, signature, content, doc) VALUES (?1, ?2, '', '', '')")
                 .bind(id)
-                .bind(crate::nl::normalize_for_fts(name))
+                .bind(crate::nl::normalize_for_fts_index(name))
                 .execute(&store.pool)
                 .await
                 .unwrap();
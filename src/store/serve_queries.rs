@@ -242,7 +242,7 @@ impl<Mode> Store<Mode> {
         self.rt.block_on(async {
             let row = sqlx::query(
                 "SELECT id, name, chunk_type, language, origin, line_start, line_end, \
-                        signature, doc, content, vendored \
+                        signature, doc, CAST(content AS BLOB) AS content, vendored \
                  FROM chunks WHERE id = ?",
             )
             .bind(chunk_id)
@@ -264,7 +264,9 @@ impl<Mode> Store<Mode> {
                 // rather than flattening to `""`.
                 signature: row.get("signature"),
                 doc: row.get("doc"),
-                content: row.get("content"),
+                content: row
+                    .get::<Option<Vec<u8>>, _>("content")
+                    .map(|stored| super::compression::decode_or_empty(stored, chunk_id)),
                 // `vendored` is INTEGER NOT NULL DEFAULT 0 (schema v24).
                 vendored: row.get::<i64, _>("vendored") != 0,
             }))
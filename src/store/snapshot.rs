@@ -0,0 +1,257 @@
+//! Read snapshots and busy retries for query paths.
+//!
+//! A search runs several statements — the brute-force cursor scan or the
+//! candidate fetch, the RRF keyword leg, the content fetch. Search runs them
+//! on one pooled connection inside a deferred read transaction
+//! ([`Store::begin_read`]). Under WAL that pins one snapshot for the whole
+//! query: a `cqs index` or `cqs watch` commit landing mid-query can't hand it
+//! half-old, half-new rows, and a checkpoint can't restart the log under it.
+//!
+//! WAL readers don't wait on writers, but a reader can still get
+//! `SQLITE_BUSY` / `SQLITE_LOCKED`: WAL recovery after a killed writer, a
+//! `wal_checkpoint(TRUNCATE)` holding the log, a lock held past the busy
+//! timeout. [`Store::read_retrying`] reruns the whole query on a fresh
+//! snapshot after such a failure, with capped exponential backoff and
+//! jitter so concurrent readers don't retry in lockstep. After
+//! `read_retries` reruns (see [`super::pragmas`]) it returns
+//! [`StoreError::Busy`] rather than the raw SQLite error.
+
+use std::future::Future;
+use std::time::Duration;
+
+use sqlx::{Sqlite, Transaction};
+
+use super::{pragmas, Store, StoreError};
+
+/// First backoff ceiling; doubles per retry.
+const BACKOFF_BASE_MS: u64 = 10;
+
+/// Largest single backoff.
+const BACKOFF_MAX_MS: u64 = 500;
+
+impl<Mode> Store<Mode> {
+    /// Open a read transaction on one pooled connection. Statements run
+    /// through it (`&mut *snap`) share one snapshot, taken at the first read
+    /// and released when the transaction ends or is dropped.
+    pub(crate) async fn begin_read(&self) -> Result<Transaction<'static, Sqlite>, StoreError> {
+        Ok(self.pool.begin().await?)
+    }
+
+    /// Drive a read query on the store's runtime, rerunning it after a busy
+    /// failure. `query` builds a fresh future per attempt, so each rerun
+    /// opens its own snapshot; `op` names the query in logs and in
+    /// [`StoreError::Busy`].
+    pub(crate) fn read_retrying<T, F, Fut>(
+        &self,
+        op: &'static str,
+        query: F,
+    ) -> Result<T, StoreError>
+    where
+        F: FnMut() -> Fut,
+        Fut: Future<Output = Result<T, StoreError>>,
+    {
+        self.rt
+            .block_on(retry_busy(op, pragmas::read_retries(), query))
+    }
+}
+
+/// Run `query`, rerunning it up to `retries` times while it fails busy.
+pub(crate) async fn retry_busy<T, F, Fut>(
+    op: &'static str,
+    retries: u32,
+    mut query: F,
+) -> Result<T, StoreError>
+where
+    F: FnMut() -> Fut,
+    Fut: Future<Output = Result<T, StoreError>>,
+{
+    let mut attempt = 0;
+    loop {
+        match query().await {
+            Err(e) if e.is_busy() => {
+                if attempt >= retries {
+                    tracing::warn!(op, attempts = attempt + 1, error = %e, "query still busy, giving up");
+                    return Err(StoreError::Busy {
+                        op,
+                        attempts: attempt + 1,
+                    });
+                }
+                let delay = backoff(attempt);
+                tracing::debug!(op, attempt, delay_ms = delay.as_millis() as u64, error = %e, "query busy, retrying");
+                crate::metrics::SQLITE_BUSY_RETRIES.inc();
+                tokio::time::sleep(delay).await;
+                attempt += 1;
+            }
+            result => return result,
+        }
+    }
+}
+
+/// Wait before retry `attempt` (0-based): a random point in the upper half
+/// of `min(BASE × 2^attempt, MAX)`. The floor keeps a retry from firing
+/// straight back into the same lock; the spread keeps readers apart.
+fn backoff(attempt: u32) -> Duration {
+    let ceiling = BACKOFF_BASE_MS
+        .saturating_mul(1 << attempt.min(16))
+        .min(BACKOFF_MAX_MS);
+    let floor = ceiling / 2;
+    Duration::from_millis(floor + rand::random::<u64>() % (ceiling - floor + 1))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::store::ModelInfo;
+    use sqlx::sqlite::{SqliteConnectOptions, SqliteConnection, SqliteJournalMode};
+    use sqlx::{ConnectOptions, Connection, Executor};
+    use std::sync::atomic::{AtomicU32, Ordering};
+    use std::sync::Mutex;
+
+    fn make_test_store_initialized() -> (Store, tempfile::TempDir) {
+        let dir = tempfile::TempDir::new().unwrap();
+        let db_path = dir.path().join(crate::INDEX_DB_FILENAME);
+        let store = Store::open(&db_path).unwrap();
+        store.init(&ModelInfo::default()).unwrap();
+        (store, dir)
+    }
+
+    /// A raw second connection — the other process — holding an open
+    /// write transaction on the index.
+    async fn open_writer(dir: &tempfile::TempDir) -> Result<SqliteConnection, sqlx::Error> {
+        let mut conn = SqliteConnectOptions::new()
+            .filename(dir.path().join(crate::INDEX_DB_FILENAME))
+            .journal_mode(SqliteJournalMode::Wal)
+            .connect()
+            .await?;
+        conn.execute("BEGIN IMMEDIATE").await?;
+        conn.execute("INSERT INTO metadata (key, value) VALUES ('busy_probe', '1')")
+            .await?;
+        Ok(conn)
+    }
+
+    fn hold_write_lock(store: &Store, dir: &tempfile::TempDir) -> SqliteConnection {
+        store
+            .rt
+            .block_on(open_writer(dir))
+            .expect("blocker should acquire the write reservation")
+    }
+
+    async fn count_probe(conn: &mut SqliteConnection) -> Result<i64, sqlx::Error> {
+        sqlx::query_scalar("SELECT COUNT(*) FROM metadata WHERE key = 'busy_probe'")
+            .fetch_one(conn)
+            .await
+    }
+
+    /// A write through the store's pool: fails busy while the blocker holds
+    /// its reservation and `CQS_BUSY_TIMEOUT_MS=0`.
+    async fn probe_write(store: &Store) -> Result<(), StoreError> {
+        sqlx::query("INSERT OR REPLACE INTO metadata (key, value) VALUES ('retry_probe', '1')")
+            .execute(&store.pool)
+            .await?;
+        Ok(())
+    }
+
+    #[test]
+    fn backoff_stays_in_the_upper_half_of_a_capped_ceiling() {
+        for attempt in 0..40 {
+            let ceiling = (BACKOFF_BASE_MS << attempt.min(16)).min(BACKOFF_MAX_MS);
+            let ms = backoff(attempt).as_millis() as u64;
+            assert!(
+                (ceiling / 2..=ceiling).contains(&ms),
+                "attempt {attempt}: {ms} ms outside [{}, {ceiling}]",
+                ceiling / 2
+            );
+        }
+    }
+
+    #[test]
+    #[serial_test::serial]
+    fn busy_query_reruns_until_the_lock_clears() {
+        std::env::set_var("CQS_BUSY_TIMEOUT_MS", "0");
+        std::env::set_var("CQS_READ_RETRIES", "3");
+        let (store, dir) = make_test_store_initialized();
+        let blocker = Mutex::new(Some(hold_write_lock(&store, &dir)));
+        let attempts = AtomicU32::new(0);
+        let retries_before = crate::metrics::SQLITE_BUSY_RETRIES.get();
+
+        // The second attempt releases the lock first, so it succeeds.
+        let result = store.read_retrying("probe", || async {
+            if attempts.fetch_add(1, Ordering::SeqCst) == 1 {
+                let conn = blocker.lock().unwrap().take();
+                if let Some(mut conn) = conn {
+                    conn.execute("ROLLBACK").await?;
+                    let _ = conn.close().await;
+                }
+            }
+            probe_write(&store).await
+        });
+
+        std::env::remove_var("CQS_BUSY_TIMEOUT_MS");
+        std::env::remove_var("CQS_READ_RETRIES");
+        result.expect("query should succeed once the lock clears");
+        assert_eq!(attempts.load(Ordering::SeqCst), 2);
+        assert!(crate::metrics::SQLITE_BUSY_RETRIES.get() > retries_before);
+    }
+
+    #[test]
+    #[serial_test::serial]
+    fn busy_query_gives_up_with_a_busy_error() {
+        std::env::set_var("CQS_BUSY_TIMEOUT_MS", "0");
+        std::env::set_var("CQS_READ_RETRIES", "1");
+        let (store, dir) = make_test_store_initialized();
+        let blocker = hold_write_lock(&store, &dir);
+
+        let result = store.read_retrying("probe", || probe_write(&store));
+
+        std::env::remove_var("CQS_BUSY_TIMEOUT_MS");
+        std::env::remove_var("CQS_READ_RETRIES");
+        let err = result.expect_err("query must fail while the lock is held");
+        assert!(
+            matches!(
+                err,
+                StoreError::Busy {
+                    op: "probe",
+                    attempts: 2
+                }
+            ),
+            "expected StoreError::Busy, got {err:?}"
+        );
+        let msg = err.to_string();
+        assert!(msg.contains("Index is busy"), "{msg}");
+        assert!(!msg.contains("database is locked"), "{msg}");
+
+        store
+            .rt
+            .block_on(async move {
+                let mut b = blocker;
+                b.execute("ROLLBACK").await?;
+                b.close().await
+            })
+            .expect("blocker rollback should succeed");
+    }
+
+    /// A read transaction keeps seeing the rows it started with while
+    /// another connection commits; the next snapshot sees the commit.
+    #[test]
+    fn read_snapshot_is_stable_across_a_concurrent_commit() {
+        let (store, dir) = make_test_store_initialized();
+        let (inside, after) = store
+            .rt
+            .block_on(async {
+                let mut snap = store.begin_read().await?;
+                assert_eq!(count_probe(&mut snap).await?, 0);
+
+                let mut writer = open_writer(&dir).await?;
+                writer.execute("COMMIT").await?;
+                writer.close().await?;
+
+                let inside = count_probe(&mut snap).await?;
+                snap.commit().await?;
+                let mut fresh = store.begin_read().await?;
+                let after = count_probe(&mut fresh).await?;
+                Ok::<_, StoreError>((inside, after))
+            })
+            .unwrap();
+        assert_eq!((inside, after), (0, 1));
+    }
+}
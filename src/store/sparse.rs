@@ -94,6 +94,11 @@ pub(crate) async fn bump_splade_generation_tx(
     .bind(next.to_string())
     .execute(&mut **tx)
     .await?;
+    // Sparse vectors feed hybrid ranking, so a SPLADE rewrite is also a new
+    // index generation. Chunk and note writes bump it through triggers.
+    sqlx::query(super::generation::BUMP_INDEX_GENERATION_SQL)
+        .execute(&mut **tx)
+        .await?;
     Ok(())
 }
 
@@ -0,0 +1,229 @@
+// WRITE_LOCK guard is held across .await inside block_on(). Safe because
+// block_on runs single-threaded — no concurrent tasks can deadlock.
+#![allow(clippy::await_holding_lock)]
+//! Per-token chunk vectors (schema v51, see `crate::late_interaction`).
+//!
+//! `chunk_token_embeddings` holds one row per chunk: its quantized token
+//! vectors and the chunk's `content_hash` at embedding time. As with facet
+//! rows, a row whose chunk has since changed reads as missing. Rows cascade
+//! with their chunk and are dropped at a model cutover.
+
+use std::collections::HashMap;
+
+use sqlx::{Row, SqliteConnection};
+
+use super::helpers::{ChunkRow, ChunkSummary, StoreError};
+use super::{ReadWrite, Store};
+use crate::late_interaction::TokenEmbeddings;
+
+/// How many chunks have current token vectors.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct TokenEmbeddingProgress {
+    /// Chunks with current token vectors.
+    pub embedded: u64,
+    /// Chunks in the index.
+    pub total: u64,
+}
+
+impl<Mode> Store<Mode> {
+    /// Token-vector coverage of the index.
+    pub fn token_embedding_progress(&self) -> Result<TokenEmbeddingProgress, StoreError> {
+        let _span = tracing::debug_span!("token_embedding_progress").entered();
+        self.rt.block_on(async {
+            let (total,): (i64,) = sqlx::query_as("SELECT COUNT(*) FROM chunks")
+                .fetch_one(&self.pool)
+                .await?;
+            let (embedded,): (i64,) = sqlx::query_as(
+                "SELECT COUNT(*) FROM chunk_token_embeddings t
+                 JOIN chunks c ON c.id = t.chunk_id AND c.content_hash = t.content_hash",
+            )
+            .fetch_one(&self.pool)
+            .await?;
+            Ok(TokenEmbeddingProgress {
+                embedded: embedded as u64,
+                total: total as u64,
+            })
+        })
+    }
+
+    /// Up to `limit` embedded chunks without current token vectors. Chunks
+    /// still awaiting their first embedding are left for the pass that
+    /// gives them one.
+    pub fn chunks_awaiting_token_embeddings(
+        &self,
+        limit: usize,
+    ) -> Result<Vec<ChunkSummary>, StoreError> {
+        let _span = tracing::debug_span!("chunks_awaiting_token_embeddings", limit).entered();
+        self.rt.block_on(async {
+            let sql = format!(
+                "SELECT {cols} FROM chunks c
+                 WHERE c.needs_embedding = 0
+                   AND NOT EXISTS (SELECT 1 FROM chunk_token_embeddings t
+                        WHERE t.chunk_id = c.id AND t.content_hash = c.content_hash)
+                 ORDER BY c.rowid
+                 LIMIT ?1",
+                cols = super::helpers::CHUNK_ROW_SELECT_COLUMNS_PREFIXED,
+            );
+            let rows: Vec<_> = sqlx::query(sqlx::AssertSqlSafe(sql.as_str()))
+                .bind(limit as i64)
+                .fetch_all(&self.pool)
+                .await?;
+            Ok(rows
+                .iter()
+                .map(|r| ChunkSummary::from(ChunkRow::from_row(r)))
+                .collect())
+        })
+    }
+
+    /// Current token vectors of the chunks in `ids` that have them, keyed
+    /// by chunk id. A malformed row is skipped with a warning.
+    pub(crate) async fn fetch_token_embeddings_async(
+        &self,
+        conn: &mut SqliteConnection,
+        ids: &[&str],
+    ) -> Result<HashMap<String, TokenEmbeddings>, StoreError> {
+        const BATCH_SIZE: usize = super::helpers::sql::max_rows_per_statement(1);
+        let mut vectors = HashMap::new();
+        for batch in ids.chunks(BATCH_SIZE) {
+            let sql = format!(
+                "SELECT t.chunk_id, t.token_count, t.embeddings FROM chunk_token_embeddings t
+                 JOIN chunks c ON c.id = t.chunk_id AND c.content_hash = t.content_hash
+                 WHERE t.chunk_id IN ({})",
+                super::helpers::make_placeholders(batch.len())
+            );
+            let mut q = sqlx::query(sqlx::AssertSqlSafe(sql.as_str()));
+            for id in batch {
+                q = q.bind(*id);
+            }
+            for row in q.fetch_all(&mut *conn).await? {
+                let id: String = row.get("chunk_id");
+                let tokens: i64 = row.get("token_count");
+                let bytes: Vec<u8> = row.get("embeddings");
+                match TokenEmbeddings::from_bytes(&bytes, tokens.max(0) as usize) {
+                    Some(t) => {
+                        vectors.insert(id, t);
+                    }
+                    None => {
+                        tracing::warn!(id = %id, tokens, len = bytes.len(), "Skipping malformed token vectors");
+                    }
+                }
+            }
+        }
+        Ok(vectors)
+    }
+}
+
+impl Store<ReadWrite> {
+    /// Write `(chunk_id, content_hash, tokens)` rows, replacing the chunk's
+    /// earlier vectors. Returns the number written; a chunk deleted since it
+    /// was read is skipped, and so is one with no tokens.
+    pub fn upsert_token_embeddings(
+        &self,
+        rows: &[(String, String, TokenEmbeddings)],
+    ) -> Result<usize, StoreError> {
+        let _span = tracing::debug_span!("upsert_token_embeddings", count = rows.len()).entered();
+        let rows: Vec<_> = rows.iter().filter(|(_, _, t)| !t.is_empty()).collect();
+        if rows.is_empty() {
+            return Ok(0);
+        }
+        self.rt.block_on(async {
+            let (_guard, mut tx) = self.begin_write().await?;
+            let mut written = 0;
+            for (id, hash, tokens) in rows {
+                written += sqlx::query(
+                    "INSERT OR REPLACE INTO chunk_token_embeddings
+                         (chunk_id, content_hash, token_count, embeddings)
+                     SELECT id, ?2, ?3, ?4 FROM chunks WHERE id = ?1",
+                )
+                .bind(id)
+                .bind(hash)
+                .bind(tokens.len() as i64)
+                .bind(tokens.to_bytes())
+                .execute(&mut *tx)
+                .await?
+                .rows_affected() as usize;
+            }
+            tx.commit().await?;
+            Ok(written)
+        })
+    }
+
+    /// Drop every chunk's token vectors. Returns the number of rows removed.
+    pub fn clear_token_embeddings(&self) -> Result<u64, StoreError> {
+        let _span = tracing::info_span!("clear_token_embeddings").entered();
+        self.rt.block_on(async {
+            let (_guard, mut tx) = self.begin_write().await?;
+            let removed = sqlx::query("DELETE FROM chunk_token_embeddings")
+                .execute(&mut *tx)
+                .await?
+                .rows_affected();
+            tx.commit().await?;
+            Ok(removed)
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::store::test_utils::make_chunk;
+    use crate::test_helpers::{mock_embedding, setup_store};
+
+    #[test]
+    fn token_vectors_track_chunk_content() {
+        let (store, _dir) = setup_store();
+        store
+            .upsert_chunks_batch(
+                &[
+                    (make_chunk("retry", "src/a.rs"), mock_embedding(1.0)),
+                    (make_chunk("stream", "src/a.rs"), mock_embedding(2.0)),
+                ],
+                Some(100),
+            )
+            .unwrap();
+        assert_eq!(store.chunks_awaiting_token_embeddings(10).unwrap().len(), 2);
+
+        let retry = &store.chunks_awaiting_token_embeddings(1).unwrap()[0];
+        let tokens = TokenEmbeddings::new(2, vec![0.6, 0.8, 1.0, 0.0]);
+        let rows = [
+            (retry.id.clone(), retry.content_hash.clone(), tokens),
+            (
+                "src/a.rs:5:gone".to_string(),
+                "h".to_string(),
+                TokenEmbeddings::new(2, vec![1.0, 0.0]),
+            ),
+            (
+                "src/a.rs:9:empty".to_string(),
+                "h".to_string(),
+                TokenEmbeddings::new(2, vec![]),
+            ),
+        ];
+        assert_eq!(store.upsert_token_embeddings(&rows).unwrap(), 1);
+        assert_eq!(
+            store.token_embedding_progress().unwrap(),
+            TokenEmbeddingProgress {
+                embedded: 1,
+                total: 2
+            }
+        );
+        let awaiting = store.chunks_awaiting_token_embeddings(10).unwrap();
+        assert_eq!(awaiting.len(), 1);
+        assert_ne!(awaiting[0].id, retry.id);
+
+        let vectors = store
+            .rt
+            .block_on(async {
+                let mut conn = store.pool.acquire().await?;
+                store
+                    .fetch_token_embeddings_async(&mut conn, &[retry.id.as_str(), "missing"])
+                    .await
+            })
+            .unwrap();
+        assert_eq!(vectors.len(), 1);
+        assert_eq!(vectors[&retry.id].len(), 2);
+        assert_eq!(vectors[&retry.id].dim(), 2);
+
+        assert_eq!(store.clear_token_embeddings().unwrap(), 1);
+        assert_eq!(store.chunks_awaiting_token_embeddings(10).unwrap().len(), 2);
+    }
+}
@@ -0,0 +1,219 @@
+// WRITE_LOCK guard is held across .await inside block_on(). Safe because
+// block_on runs single-threaded — no concurrent tasks can deadlock.
+#![allow(clippy::await_holding_lock)]
+//! Crash-recovery journal for the `cqs watch` pending queue (schema v39).
+//!
+//! The watch loop coalesces file events into an in-memory set and reindexes
+//! it after a quiet gap. A crash between the two used to lose the whole set
+//! until the next periodic reconcile noticed the divergence — minutes later,
+//! or never for a `--no-serve` watch with reconcile off. The loop now mirrors
+//! the set into `watch_journal`: new paths are journaled on the next tick,
+//! reindexed paths are cleared after the flush, and a restarted daemon
+//! re-queues whatever is left before it reads its first event.
+//!
+//! One row per path; re-queueing a journaled path keeps its first reason and
+//! timestamp. The table is not read by search and holds only relative paths.
+
+use super::helpers::{make_placeholders, sql::max_rows_per_statement, StoreError};
+use super::{ReadWrite, Store};
+
+/// Why a path is in the watch queue.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]
+pub enum JournalReason {
+    /// A filesystem event for the path.
+    Event,
+    /// Queued by a reconcile walk (periodic, on-demand, or hook fallback).
+    Reconcile,
+    /// The notes file changed; replays as a notes re-sync, not a reindex.
+    Notes,
+}
+
+impl JournalReason {
+    /// Stored spelling in `watch_journal.reason`.
+    pub fn as_str(self) -> &'static str {
+        match self {
+            JournalReason::Event => "event",
+            JournalReason::Reconcile => "reconcile",
+            JournalReason::Notes => "notes",
+        }
+    }
+
+    /// Parse a stored reason. Unknown spellings (a newer binary's journal)
+    /// read back as `Event` — replaying them as a plain reindex is always safe.
+    pub fn from_stored(s: &str) -> Self {
+        match s {
+            "reconcile" => JournalReason::Reconcile,
+            "notes" => JournalReason::Notes,
+            _ => JournalReason::Event,
+        }
+    }
+}
+
+/// One journaled queue entry.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct JournalEntry {
+    /// Project-relative, slash-normalized path.
+    pub path: String,
+    pub reason: JournalReason,
+    /// Unix seconds when the path was first journaled.
+    pub queued_at: i64,
+}
+
+impl<Mode> Store<Mode> {
+    /// Every journaled queue entry, oldest first.
+    pub fn watch_journal_entries(&self) -> Result<Vec<JournalEntry>, StoreError> {
+        let _span = tracing::debug_span!("watch_journal_entries").entered();
+        self.rt.block_on(async {
+            let rows: Vec<(String, String, i64)> = sqlx::query_as(
+                "SELECT path, reason, queued_at FROM watch_journal ORDER BY queued_at, path",
+            )
+            .fetch_all(&self.pool)
+            .await?;
+            Ok(rows
+                .into_iter()
+                .map(|(path, reason, queued_at)| JournalEntry {
+                    path,
+                    reason: JournalReason::from_stored(&reason),
+                    queued_at,
+                })
+                .collect())
+        })
+    }
+}
+
+impl Store<ReadWrite> {
+    /// Journal queued paths. Paths already journaled keep their original
+    /// reason and timestamp. Returns the number of new rows.
+    pub fn journal_watch_paths(
+        &self,
+        entries: &[(String, JournalReason)],
+    ) -> Result<u64, StoreError> {
+        let _span = tracing::debug_span!("journal_watch_paths", count = entries.len()).entered();
+        if entries.is_empty() {
+            return Ok(0);
+        }
+        let now = crate::unix_secs_i64().unwrap_or(0);
+        self.rt.block_on(async {
+            let (_guard, mut tx) = self.begin_write().await?;
+            let mut inserted = 0;
+            const ROWS_PER_INSERT: usize = max_rows_per_statement(3);
+            for batch in entries.chunks(ROWS_PER_INSERT) {
+                let mut qb: sqlx::QueryBuilder<sqlx::Sqlite> = sqlx::QueryBuilder::new(
+                    "INSERT OR IGNORE INTO watch_journal (path, reason, queued_at) ",
+                );
+                qb.push_values(batch.iter(), |mut b, (path, reason)| {
+                    b.push_bind(path).push_bind(reason.as_str()).push_bind(now);
+                });
+                inserted += qb.build().execute(&mut *tx).await?.rows_affected();
+            }
+            tx.commit().await?;
+            Ok(inserted)
+        })
+    }
+
+    /// Drop the journal rows for `paths` — they were reindexed (or re-synced,
+    /// for the notes row). Returns the number of rows removed.
+    pub fn clear_watch_journal_paths(&self, paths: &[String]) -> Result<u64, StoreError> {
+        let _span =
+            tracing::debug_span!("clear_watch_journal_paths", count = paths.len()).entered();
+        if paths.is_empty() {
+            return Ok(0);
+        }
+        self.rt.block_on(async {
+            let (_guard, mut tx) = self.begin_write().await?;
+            let mut removed = 0;
+            for batch in paths.chunks(max_rows_per_statement(1)) {
+                let sql = format!(
+                    "DELETE FROM watch_journal WHERE path IN ({})",
+                    make_placeholders(batch.len())
+                );
+                let mut stmt = sqlx::query(sqlx::AssertSqlSafe(sql.as_str()));
+                for path in batch {
+                    stmt = stmt.bind(path);
+                }
+                removed += stmt.execute(&mut *tx).await?.rows_affected();
+            }
+            tx.commit().await?;
+            Ok(removed)
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::test_helpers::setup_store;
+
+    fn owned(entries: &[(&str, JournalReason)]) -> Vec<(String, JournalReason)> {
+        entries.iter().map(|(p, r)| (p.to_string(), *r)).collect()
+    }
+
+    #[test]
+    fn journal_round_trips_and_keeps_first_reason() {
+        let (store, _dir) = setup_store();
+        let added = store
+            .journal_watch_paths(&owned(&[
+                ("src/a.rs", JournalReason::Event),
+                ("docs/notes.toml", JournalReason::Notes),
+            ]))
+            .unwrap();
+        assert_eq!(added, 2);
+        // Re-queueing a journaled path is a no-op.
+        let added = store
+            .journal_watch_paths(&owned(&[
+                ("src/a.rs", JournalReason::Reconcile),
+                ("src/b.rs", JournalReason::Reconcile),
+            ]))
+            .unwrap();
+        assert_eq!(added, 1);
+
+        let mut entries = store.watch_journal_entries().unwrap();
+        entries.sort_by(|a, b| a.path.cmp(&b.path));
+        let got: Vec<(&str, JournalReason)> = entries
+            .iter()
+            .map(|e| (e.path.as_str(), e.reason))
+            .collect();
+        assert_eq!(
+            got,
+            vec![
+                ("docs/notes.toml", JournalReason::Notes),
+                ("src/a.rs", JournalReason::Event),
+                ("src/b.rs", JournalReason::Reconcile),
+            ]
+        );
+    }
+
+    #[test]
+    fn clear_removes_only_named_paths() {
+        let (store, _dir) = setup_store();
+        store
+            .journal_watch_paths(&owned(&[
+                ("src/a.rs", JournalReason::Event),
+                ("src/b.rs", JournalReason::Event),
+            ]))
+            .unwrap();
+        let removed = store
+            .clear_watch_journal_paths(&["src/a.rs".to_string(), "src/gone.rs".to_string()])
+            .unwrap();
+        assert_eq!(removed, 1);
+        let left: Vec<String> = store
+            .watch_journal_entries()
+            .unwrap()
+            .into_iter()
+            .map(|e| e.path)
+            .collect();
+        assert_eq!(left, vec!["src/b.rs".to_string()]);
+    }
+
+    #[test]
+    fn unknown_reason_reads_as_event() {
+        assert_eq!(JournalReason::from_stored("fanotify"), JournalReason::Event);
+        for reason in [
+            JournalReason::Event,
+            JournalReason::Reconcile,
+            JournalReason::Notes,
+        ] {
+            assert_eq!(JournalReason::from_stored(reason.as_str()), reason);
+        }
+    }
+}
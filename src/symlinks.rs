@@ -0,0 +1,277 @@
+//! Symlink policy shared by the file walk and the watcher.
+//!
+//! `[index] follow_symlinks` in `.cqs.toml` (env override
+//! `CQS_FOLLOW_SYMLINKS`) picks one of:
+//!
+//! - `false` (default): symlinks are never followed. A symlinked file or
+//!   directory is skipped by `cqs index`, and `cqs watch` drops events that
+//!   arrive through one, so the two agree on what is indexed.
+//! - `"within_root"`: links are followed when their target resolves inside
+//!   the project. The file is indexed once, under its real path, no matter
+//!   how many links reach it; targets outside the project are skipped.
+//! - `true`: links are followed wherever they point. A target inside the
+//!   project is still indexed under its real path; one outside is indexed
+//!   under the path through the link.
+//!
+//! Following links can reach a directory cycle (`a/loop -> ..`). The walk
+//! detects it per branch, logs the loop once, and does not descend again.
+
+use std::path::{Path, PathBuf};
+
+use serde::{Deserialize, Serialize};
+
+/// How the walk and the watcher treat symbolic links.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum SymlinkPolicy {
+    /// Never follow links (`follow_symlinks = false`).
+    #[default]
+    Never,
+    /// Follow links whose target resolves inside the project root.
+    WithinRoot,
+    /// Follow every link (`follow_symlinks = true`).
+    Always,
+}
+
+impl SymlinkPolicy {
+    /// Whether the walker and watcher should descend through links at all.
+    pub fn follows(self) -> bool {
+        self != SymlinkPolicy::Never
+    }
+
+    /// Parse the `CQS_FOLLOW_SYMLINKS` spelling: `0`/`false`/`no`,
+    /// `1`/`true`/`yes`, or `within_root`.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.trim().to_ascii_lowercase().as_str() {
+            "0" | "false" | "no" => Some(SymlinkPolicy::Never),
+            "1" | "true" | "yes" => Some(SymlinkPolicy::Always),
+            "within_root" => Some(SymlinkPolicy::WithinRoot),
+            _ => None,
+        }
+    }
+}
+
+impl std::fmt::Display for SymlinkPolicy {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        f.write_str(match self {
+            SymlinkPolicy::Never => "false",
+            SymlinkPolicy::WithinRoot => "within_root",
+            SymlinkPolicy::Always => "true",
+        })
+    }
+}
+
+/// TOML spelling: a boolean, or the string `"within_root"`.
+#[derive(Deserialize)]
+#[serde(untagged)]
+enum RawPolicy {
+    Bool(bool),
+    Str(String),
+}
+
+impl<'de> Deserialize<'de> for SymlinkPolicy {
+    fn deserialize<D: serde::Deserializer<'de>>(deserializer: D) -> Result<Self, D::Error> {
+        match RawPolicy::deserialize(deserializer)? {
+            RawPolicy::Bool(true) => Ok(SymlinkPolicy::Always),
+            RawPolicy::Bool(false) => Ok(SymlinkPolicy::Never),
+            RawPolicy::Str(s) if s == "within_root" => Ok(SymlinkPolicy::WithinRoot),
+            RawPolicy::Str(s) => Err(serde::de::Error::custom(format!(
+                "follow_symlinks must be true, false, or \"within_root\", got \"{s}\""
+            ))),
+        }
+    }
+}
+
+impl Serialize for SymlinkPolicy {
+    fn serialize<S: serde::Serializer>(&self, serializer: S) -> Result<S::Ok, S::Error> {
+        match self {
+            SymlinkPolicy::Never => serializer.serialize_bool(false),
+            SymlinkPolicy::Always => serializer.serialize_bool(true),
+            SymlinkPolicy::WithinRoot => serializer.serialize_str("within_root"),
+        }
+    }
+}
+
+/// `[index] follow_symlinks` from `.cqs.toml`, pushed in once at startup by
+/// [`set_policy_from_config`]. Unset means never follow.
+static FOLLOW_SYMLINKS: std::sync::OnceLock<SymlinkPolicy> = std::sync::OnceLock::new();
+
+/// Push `[index] follow_symlinks` into the walker. Must be called before the
+/// first walk; later calls are no-ops (OnceLock).
+pub fn set_policy_from_config(policy: SymlinkPolicy) {
+    let _ = FOLLOW_SYMLINKS.set(policy);
+}
+
+/// Effective policy. Resolution: `CQS_FOLLOW_SYMLINKS` env > `[index]
+/// follow_symlinks` > never. An unrecognized env value warns and falls
+/// through to the config.
+pub fn policy() -> SymlinkPolicy {
+    if let Ok(raw) = std::env::var("CQS_FOLLOW_SYMLINKS") {
+        match SymlinkPolicy::parse(&raw) {
+            Some(p) => return p,
+            None => tracing::warn!(
+                value = %raw,
+                "Ignoring CQS_FOLLOW_SYMLINKS: expected 0, 1, or within_root"
+            ),
+        }
+    }
+    FOLLOW_SYMLINKS.get().copied().unwrap_or_default()
+}
+
+/// Origin for a file reached at `link_path` whose real location is `real`,
+/// both absolute, under the canonical project `root`. `None` means the
+/// policy excludes it.
+///
+/// A target inside the root always maps to its real relative path, so a
+/// file reachable through several links is one origin. A target outside the
+/// root is kept only under [`SymlinkPolicy::Always`], under the link path.
+pub fn origin_for(
+    root: &Path,
+    link_path: &Path,
+    real: &Path,
+    policy: SymlinkPolicy,
+) -> Option<PathBuf> {
+    if let Some(rel) = crate::paths::strip_root(real, root) {
+        return Some(rel.to_path_buf());
+    }
+    match policy {
+        SymlinkPolicy::Always => crate::paths::strip_root(link_path, root).map(Path::to_path_buf),
+        SymlinkPolicy::Never | SymlinkPolicy::WithinRoot => None,
+    }
+}
+
+/// Whether any component of `rel` below `root` is a symlink. The leaf need
+/// not exist (a deleted file), its existing ancestors are still checked.
+pub fn through_symlink(root: &Path, rel: &Path) -> bool {
+    let mut cur = root.to_path_buf();
+    rel.components().any(|c| {
+        cur.push(c);
+        std::fs::symlink_metadata(&cur).is_ok_and(|m| m.file_type().is_symlink())
+    })
+}
+
+/// Apply `policy` to a path the watcher reported, `path` absolute under the
+/// canonical `root`. Returns the origin to queue, or `None` when the walk
+/// would not index the file either.
+///
+/// Paths with no symlink on the way are returned as-is. A deleted file
+/// reached through a link resolves through its parent directory.
+pub fn resolve_event_path(root: &Path, path: &Path, policy: SymlinkPolicy) -> Option<PathBuf> {
+    let link_rel = crate::paths::strip_root(path, root)?;
+    if !through_symlink(root, link_rel) {
+        return Some(link_rel.to_path_buf());
+    }
+    if !policy.follows() {
+        return None;
+    }
+    let real = match dunce::canonicalize(path) {
+        Ok(real) => real,
+        Err(_) => {
+            let parent = dunce::canonicalize(path.parent()?).ok()?;
+            parent.join(path.file_name()?)
+        }
+    };
+    origin_for(root, path, &real, policy)
+}
+
+/// Whether a walk error is a directory cycle found while following links.
+pub fn is_walk_loop(err: &ignore::Error) -> bool {
+    match err {
+        ignore::Error::Loop { .. } => true,
+        ignore::Error::WithPath { err, .. }
+        | ignore::Error::WithDepth { err, .. }
+        | ignore::Error::WithLineNumber { err, .. } => is_walk_loop(err),
+        _ => false,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[derive(Deserialize)]
+    struct Wrap {
+        follow_symlinks: SymlinkPolicy,
+    }
+
+    #[test]
+    fn policy_parses_toml_spellings() {
+        let parse = |s: &str| toml::from_str::<Wrap>(s).map(|w| w.follow_symlinks);
+        assert_eq!(
+            parse("follow_symlinks = false").unwrap(),
+            SymlinkPolicy::Never
+        );
+        assert_eq!(
+            parse("follow_symlinks = true").unwrap(),
+            SymlinkPolicy::Always
+        );
+        assert_eq!(
+            parse("follow_symlinks = \"within_root\"").unwrap(),
+            SymlinkPolicy::WithinRoot
+        );
+        assert!(parse("follow_symlinks = \"sometimes\"").is_err());
+    }
+
+    #[test]
+    fn policy_parses_env_spellings() {
+        assert_eq!(SymlinkPolicy::parse("0"), Some(SymlinkPolicy::Never));
+        assert_eq!(SymlinkPolicy::parse("TRUE"), Some(SymlinkPolicy::Always));
+        assert_eq!(
+            SymlinkPolicy::parse("within_root"),
+            Some(SymlinkPolicy::WithinRoot)
+        );
+        assert_eq!(SymlinkPolicy::parse("maybe"), None);
+    }
+
+    #[test]
+    fn origin_for_prefers_real_path_inside_root() {
+        let root = Path::new("/proj");
+        let link = Path::new("/proj/alias/lib.rs");
+        let inside = Path::new("/proj/src/lib.rs");
+        let outside = Path::new("/shared/lib.rs");
+        for p in [SymlinkPolicy::WithinRoot, SymlinkPolicy::Always] {
+            assert_eq!(
+                origin_for(root, link, inside, p),
+                Some(PathBuf::from("src/lib.rs"))
+            );
+        }
+        assert_eq!(
+            origin_for(root, link, outside, SymlinkPolicy::WithinRoot),
+            None
+        );
+        assert_eq!(
+            origin_for(root, link, outside, SymlinkPolicy::Always),
+            Some(PathBuf::from("alias/lib.rs"))
+        );
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn resolve_event_path_matches_walk_policy() {
+        use std::os::unix::fs::symlink;
+        let dir = tempfile::TempDir::new().unwrap();
+        let root = dunce::canonicalize(dir.path()).unwrap();
+        std::fs::create_dir(root.join("src")).unwrap();
+        std::fs::write(root.join("src/lib.rs"), "fn a() {}").unwrap();
+        symlink(root.join("src"), root.join("alias")).unwrap();
+
+        let plain = root.join("src/lib.rs");
+        let via_link = root.join("alias/lib.rs");
+        assert_eq!(
+            resolve_event_path(&root, &plain, SymlinkPolicy::Never),
+            Some(PathBuf::from("src/lib.rs"))
+        );
+        assert_eq!(
+            resolve_event_path(&root, &via_link, SymlinkPolicy::Never),
+            None
+        );
+        assert_eq!(
+            resolve_event_path(&root, &via_link, SymlinkPolicy::WithinRoot),
+            Some(PathBuf::from("src/lib.rs"))
+        );
+        // Deleted file under a link still resolves through its parent.
+        assert_eq!(
+            resolve_event_path(&root, &root.join("alias/gone.rs"), SymlinkPolicy::Always),
+            Some(PathBuf::from("src/gone.rs"))
+        );
+    }
+}
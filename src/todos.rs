@@ -0,0 +1,386 @@
+//! TODO/FIXME/XXX markers across the indexed files (`cqs todos`).
+//!
+//! The index decides which files count and in which language each is read;
+//! the comment rules are the ones the FTS `comments` column uses
+//! (`parser::chunk::split_lines`), so a marker in code or a string literal is
+//! never reported. Lines are read from the working tree, so a marker above a
+//! definition (outside every chunk) is still found, and each hit lines up
+//! with `git blame` of the same file — which gives the marker's author and
+//! age. Only files with markers are blamed.
+//!
+//! Ownership follows `search --owner`: a marker belongs to the CODEOWNERS
+//! owners of its file and to the author who wrote its line.
+
+use std::collections::{BTreeMap, HashSet};
+use std::path::Path;
+use std::sync::LazyLock;
+
+use rayon::prelude::*;
+use regex::Regex;
+use serde::Serialize;
+
+use crate::language::Language;
+use crate::ownership::{author_matches, owner_matches, BlameLine, CodeOwners};
+use crate::store::{Store, StoreError};
+
+/// A marker word at a word boundary, an optional `(tag)`, then the note.
+/// Uppercase only, so prose like "todo list" stays out.
+static TODO_RE: LazyLock<Regex> = LazyLock::new(|| {
+    Regex::new(r"\b(TODO|FIXME|XXX)\b(?:\(([^)]*)\))?:?\s*(.*)").expect("valid regex")
+});
+
+/// Block-comment closers trimmed off the end of a marker's note.
+const COMMENT_CLOSERS: &[&str] = &["*/", "-->", "*)", "#>", "--%>", "*@"];
+
+/// Seconds per day, for [`TodoItem::age_days`].
+const SECS_PER_DAY: i64 = 86_400;
+
+/// Which marker a comment carries.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, PartialOrd, Ord, Serialize)]
+#[serde(rename_all = "UPPERCASE")]
+pub enum TodoKind {
+    Todo,
+    Fixme,
+    Xxx,
+}
+
+impl TodoKind {
+    fn parse(word: &str) -> Option<Self> {
+        match word {
+            "TODO" => Some(Self::Todo),
+            "FIXME" => Some(Self::Fixme),
+            "XXX" => Some(Self::Xxx),
+            _ => None,
+        }
+    }
+
+    pub fn as_str(self) -> &'static str {
+        match self {
+            Self::Todo => "TODO",
+            Self::Fixme => "FIXME",
+            Self::Xxx => "XXX",
+        }
+    }
+}
+
+/// One marker found in a comment.
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub struct TodoItem {
+    /// Indexed origin (project-relative path).
+    pub file: String,
+    /// 1-based line.
+    pub line: u32,
+    pub kind: TodoKind,
+    /// `TODO(tag)` — usually an assignee or ticket.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub tag: Option<String>,
+    /// The note after the marker.
+    pub text: String,
+    /// Blame author of the line; `None` outside git or for uncommitted lines.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub author: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub email: Option<String>,
+    /// Author time of the line, Unix seconds.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub committed_at: Option<i64>,
+    /// Whole days since `committed_at`.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub age_days: Option<i64>,
+    /// CODEOWNERS owners of the file.
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub owners: Vec<String>,
+}
+
+impl TodoItem {
+    /// The owner this marker is grouped under: the file's first CODEOWNERS
+    /// owner, else the line's blame author, else `unowned`.
+    pub fn owner_key(&self) -> &str {
+        self.owners
+            .first()
+            .map(String::as_str)
+            .or(self.author.as_deref())
+            .unwrap_or("unowned")
+    }
+
+    /// `true` when `query` names a CODEOWNERS owner of the file or the
+    /// line's author ([`owner_matches`], [`author_matches`]).
+    pub fn owned_by(&self, query: &str) -> bool {
+        self.owners.iter().any(|o| owner_matches(query, o))
+            || self.author.as_deref().is_some_and(|name| {
+                author_matches(query, name, self.email.as_deref().unwrap_or(""))
+            })
+    }
+}
+
+/// How [`group_todos`] buckets markers.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum TodoGrouping {
+    #[default]
+    File,
+    Owner,
+}
+
+/// Filters for [`find_todos`].
+#[derive(Debug, Clone, Default)]
+pub struct TodoOptions {
+    /// Only files under this directory, or matching this glob.
+    pub path: Option<String>,
+    /// Only markers owned by this team or person ([`TodoItem::owned_by`]).
+    pub owner: Option<String>,
+}
+
+/// The first marker in one comment line's text. The note runs to the end
+/// of the line, so a line holds at most one.
+fn marker_in(comment: &str) -> Option<(TodoKind, Option<String>, String)> {
+    let caps = TODO_RE.captures(comment)?;
+    let kind = TodoKind::parse(&caps[1])?;
+    let mut text = caps[3].trim();
+    for closer in COMMENT_CLOSERS {
+        text = text.strip_suffix(closer).unwrap_or(text).trim_end();
+    }
+    let tag = caps
+        .get(2)
+        .map(|t| t.as_str().trim().to_string())
+        .filter(|t| !t.is_empty());
+    Some((kind, tag, text.to_string()))
+}
+
+/// Markers in the comments of `source`, as `(line, kind, tag, text)`.
+pub fn scan_source(source: &str, lang: Language) -> Vec<(u32, TodoKind, Option<String>, String)> {
+    crate::parser::chunk::split_lines(source, lang)
+        .into_iter()
+        .filter_map(|line| {
+            let (kind, tag, text) = marker_in(line.comment?)?;
+            Some((line.index as u32 + 1, kind, tag, text))
+        })
+        .collect()
+}
+
+/// Matcher for [`TodoOptions::path`]: a glob when it has glob syntax,
+/// otherwise the path itself and everything under it.
+fn path_matcher(path: &str) -> Result<globset::GlobSet, StoreError> {
+    let path = path.trim_end_matches('/');
+    let patterns = if path.contains(['*', '?', '[', '{']) {
+        vec![path.to_string()]
+    } else {
+        vec![path.to_string(), format!("{path}/**")]
+    };
+    let mut builder = globset::GlobSetBuilder::new();
+    for p in &patterns {
+        builder.add(
+            globset::Glob::new(p)
+                .map_err(|e| StoreError::Runtime(format!("Invalid --path '{p}': {e}")))?,
+        );
+    }
+    builder
+        .build()
+        .map_err(|e| StoreError::Runtime(format!("Invalid --path '{path}': {e}")))
+}
+
+/// The current user for `--owner me`: git's `user.email`, else `user.name`.
+pub fn git_user(root: &Path) -> Option<String> {
+    ["user.email", "user.name"].iter().find_map(|key| {
+        let output = std::process::Command::new("git")
+            .args(["config", key])
+            .current_dir(root)
+            .output()
+            .ok()?;
+        let value = String::from_utf8_lossy(&output.stdout).trim().to_string();
+        (output.status.success() && !value.is_empty()).then_some(value)
+    })
+}
+
+/// Find the TODO/FIXME/XXX markers in the indexed files under `root`,
+/// sorted by file and line.
+pub fn find_todos<Mode>(
+    store: &Store<Mode>,
+    root: &Path,
+    opts: &TodoOptions,
+) -> Result<Vec<TodoItem>, StoreError> {
+    let _span = tracing::info_span!("find_todos").entered();
+    let matcher = opts.path.as_deref().map(path_matcher).transpose()?;
+    let mut origins: Vec<String> = store
+        .indexed_file_origins()?
+        .into_keys()
+        .filter(|o| matcher.as_ref().is_none_or(|m| m.is_match(o)))
+        .collect();
+    origins.sort_unstable();
+
+    let codeowners = CodeOwners::load(root).unwrap_or_default();
+    let blame = crate::ownership::in_git_work_tree(root);
+    let now = chrono::Utc::now().timestamp();
+
+    let mut items: Vec<TodoItem> = origins
+        .par_iter()
+        .flat_map_iter(|origin| {
+            let found = Language::from_path(Path::new(origin))
+                .and_then(|lang| {
+                    let source = std::fs::read_to_string(root.join(origin)).ok()?;
+                    Some(scan_source(&source, lang))
+                })
+                .unwrap_or_default();
+            if found.is_empty() {
+                return Vec::new();
+            }
+            let lines = if blame {
+                crate::ownership::blame_file(root, origin).unwrap_or_default()
+            } else {
+                Vec::new()
+            };
+            let owners = codeowners.owners_of(origin).to_vec();
+            found
+                .into_iter()
+                .map(|(line, kind, tag, text)| {
+                    let blamed: Option<&BlameLine> =
+                        lines.get(line as usize - 1).and_then(Option::as_ref);
+                    TodoItem {
+                        file: origin.clone(),
+                        line,
+                        kind,
+                        tag,
+                        text,
+                        author: blamed.map(|b| b.name.clone()),
+                        email: blamed.map(|b| b.email.clone()),
+                        committed_at: blamed.map(|b| b.time),
+                        age_days: blamed.map(|b| (now - b.time).max(0) / SECS_PER_DAY),
+                        owners: owners.clone(),
+                    }
+                })
+                .collect()
+        })
+        .collect();
+
+    if let Some(owner) = opts.owner.as_deref() {
+        items.retain(|t| t.owned_by(owner));
+    }
+    items.sort_by(|a, b| a.file.cmp(&b.file).then(a.line.cmp(&b.line)));
+    tracing::info!(
+        files = origins.len(),
+        todos = items.len(),
+        "TODO scan complete"
+    );
+    Ok(items)
+}
+
+/// Bucket `items` by file or by [`TodoItem::owner_key`], keys sorted.
+pub fn group_todos(items: Vec<TodoItem>, by: TodoGrouping) -> BTreeMap<String, Vec<TodoItem>> {
+    let mut groups: BTreeMap<String, Vec<TodoItem>> = BTreeMap::new();
+    for item in items {
+        let key = match by {
+            TodoGrouping::File => item.file.clone(),
+            TodoGrouping::Owner => item.owner_key().to_string(),
+        };
+        groups.entry(key).or_default().push(item);
+    }
+    groups
+}
+
+/// Distinct files among `items`.
+pub fn file_count(items: &[TodoItem]) -> usize {
+    items
+        .iter()
+        .map(|t| t.file.as_str())
+        .collect::<HashSet<_>>()
+        .len()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn scan_finds_markers_in_comments_only() {
+        let source = "\
+// TODO: make this async
+fn retry() {
+    let s = \"TODO not a comment\";
+    backoff(); // FIXME(ada): jitter
+    /* XXX remove after v2 */
+    // todo lowercase is prose
+}
+";
+        let found = scan_source(source, Language::Rust);
+        assert_eq!(
+            found,
+            vec![
+                (1, TodoKind::Todo, None, "make this async".to_string()),
+                (
+                    4,
+                    TodoKind::Fixme,
+                    Some("ada".to_string()),
+                    "jitter".to_string()
+                ),
+                (5, TodoKind::Xxx, None, "remove after v2".to_string()),
+            ]
+        );
+    }
+
+    #[test]
+    fn scan_python_hash_comments() {
+        let found = scan_source(
+            "def f():\n    # TODO handle None\n    return 1\n",
+            Language::Python,
+        );
+        assert_eq!(found.len(), 1);
+        assert_eq!(found[0].0, 2);
+        assert_eq!(found[0].3, "handle None");
+    }
+
+    #[test]
+    fn path_matcher_takes_directories_and_globs() {
+        let dir = path_matcher("src/store/").unwrap();
+        assert!(dir.is_match("src/store/mod.rs"));
+        assert!(dir.is_match("src/store/chunks/crud.rs"));
+        assert!(!dir.is_match("src/storefront.rs"));
+        let glob = path_matcher("src/**/*.py").unwrap();
+        assert!(glob.is_match("src/a/b.py"));
+        assert!(!glob.is_match("src/a/b.rs"));
+    }
+
+    fn item(file: &str, owners: &[&str], author: Option<(&str, &str)>) -> TodoItem {
+        TodoItem {
+            file: file.to_string(),
+            line: 1,
+            kind: TodoKind::Todo,
+            tag: None,
+            text: String::new(),
+            author: author.map(|(n, _)| n.to_string()),
+            email: author.map(|(_, e)| e.to_string()),
+            committed_at: None,
+            age_days: None,
+            owners: owners.iter().map(|o| o.to_string()).collect(),
+        }
+    }
+
+    #[test]
+    fn ownership_by_codeowners_or_author() {
+        let t = item(
+            "src/pay.rs",
+            &["@acme/payments"],
+            Some(("Ada Lovelace", "ada@acme.example")),
+        );
+        assert!(t.owned_by("payments"));
+        assert!(t.owned_by("ada@acme.example"));
+        assert!(t.owned_by("ada"));
+        assert!(!t.owned_by("grace"));
+        assert_eq!(t.owner_key(), "@acme/payments");
+        assert_eq!(item("a.rs", &[], Some(("Ada", "a@x"))).owner_key(), "Ada");
+        assert_eq!(item("a.rs", &[], None).owner_key(), "unowned");
+    }
+
+    #[test]
+    fn grouping_by_owner_buckets_across_files() {
+        let groups = group_todos(
+            vec![
+                item("b.rs", &["@web"], None),
+                item("a.rs", &["@web"], None),
+                item("c.rs", &[], None),
+            ],
+            TodoGrouping::Owner,
+        );
+        let keys: Vec<&str> = groups.keys().map(String::as_str).collect();
+        assert_eq!(keys, ["@web", "unowned"]);
+        assert_eq!(groups["@web"].len(), 2);
+    }
+}
@@ -0,0 +1,599 @@
+//! External vector stores for the dense search leg.
+//!
+//! By default the vectors live in SQLite beside the chunks and are searched
+//! through HNSW (or CAGRA). For very large or shared deployments,
+//! `[index.vector] backend = "qdrant"` or `"pgvector"` mirrors them into an
+//! external store instead. Chunk text, FTS, the call graph, and all other
+//! metadata stay in SQLite, which remains the source of truth: the external
+//! store only holds `(chunk id, vector)` pairs.
+//!
+//! `cqs index` and the watch daemon's periodic rebuild run
+//! [`sync_from_store`], which uploads vectors the external store lacks (or
+//! holds a different version of) and deletes ids the index no longer has.
+//! Search reaches the store through [`ExternalBackend`], the
+//! highest-priority [`IndexBackend`]. When the store is unreachable, or its
+//! vector count no longer matches the index (edits since the last sync),
+//! selection falls through to HNSW: a stale or down store costs speed, never
+//! results.
+//!
+//! Each client sits behind a cargo feature (`qdrant`, `pgvector`), so the
+//! default single binary carries neither. Both store cosine similarity;
+//! `CQS_DISTANCE_METRIC=dot` applies to the built-in indexes only.
+
+use std::collections::{HashMap, HashSet};
+use std::path::Path;
+use std::sync::OnceLock;
+
+use serde::{Deserialize, Serialize};
+
+use crate::config::VectorConfig;
+use crate::embedder::Embedding;
+use crate::index::{BackendContext, IndexBackend, IndexResult, VectorIndex};
+use crate::store::{ClearHnswDirty, Store, StoreError};
+
+#[cfg(feature = "pgvector")]
+mod pgvector;
+#[cfg(feature = "qdrant")]
+mod qdrant;
+
+/// Vectors read from the store per batch during a sync.
+const SYNC_READ_BATCH: usize = 10_000;
+
+/// Vectors sent to the external store per request.
+const SYNC_WRITE_BATCH: usize = 256;
+
+/// Default Qdrant endpoint (its REST port on localhost).
+pub const DEFAULT_QDRANT_URL: &str = "http://localhost:6333";
+
+/// Errors talking to an external vector store.
+#[derive(Debug, thiserror::Error)]
+pub enum VectorBackendError {
+    #[error("unknown vector backend {0:?} (supported: sqlite, qdrant, pgvector)")]
+    UnknownBackend(String),
+    #[error("cqs was built without the `{0}` feature; rebuild with `--features {0}`")]
+    FeatureDisabled(&'static str),
+    #[error("[index.vector] backend = \"{0}\" needs a url (or CQS_VECTOR_URL)")]
+    MissingUrl(&'static str),
+    #[error("invalid collection name {0:?}: use letters, digits, and `_`")]
+    InvalidCollection(String),
+    #[error(transparent)]
+    Offline(#[from] crate::offline::OfflineError),
+    #[error("vector store request failed: {0}")]
+    Request(String),
+    #[error("unexpected vector store response: {0}")]
+    Response(String),
+    #[error(transparent)]
+    Store(#[from] StoreError),
+}
+
+/// Where the dense vectors are searched.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum VectorBackendKind {
+    /// Built-in: vectors in SQLite, searched through HNSW / CAGRA.
+    #[default]
+    Sqlite,
+    /// A Qdrant collection, over its REST API.
+    Qdrant,
+    /// A PostgreSQL table with the `vector` extension.
+    Pgvector,
+}
+
+impl VectorBackendKind {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Sqlite => "sqlite",
+            Self::Qdrant => "qdrant",
+            Self::Pgvector => "pgvector",
+        }
+    }
+}
+
+impl std::str::FromStr for VectorBackendKind {
+    type Err = VectorBackendError;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s.trim().to_ascii_lowercase().as_str() {
+            "" | "sqlite" | "hnsw" | "builtin" => Ok(Self::Sqlite),
+            "qdrant" => Ok(Self::Qdrant),
+            "pgvector" | "postgres" => Ok(Self::Pgvector),
+            other => Err(VectorBackendError::UnknownBackend(other.to_string())),
+        }
+    }
+}
+
+/// `[index.vector]`, pushed in once at startup.
+static VECTOR_CONFIG: OnceLock<VectorConfig> = OnceLock::new();
+
+/// Push `[index.vector]` into backend selection and the index pipeline.
+/// Later calls are no-ops (OnceLock).
+pub fn set_from_config(config: &VectorConfig) {
+    let _ = VECTOR_CONFIG.set(config.clone());
+}
+
+/// An external store to mirror vectors into, resolved from env and config.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct ExternalTarget {
+    pub kind: VectorBackendKind,
+    /// Qdrant base URL, or a PostgreSQL connection string.
+    pub url: String,
+    /// Explicit collection (Qdrant) / table (pgvector) name.
+    pub collection: Option<String>,
+}
+
+/// The configured external store, `None` for the built-in SQLite backend.
+/// Each setting resolves `CQS_VECTOR_BACKEND` / `CQS_VECTOR_URL` /
+/// `CQS_VECTOR_COLLECTION` env > `[index.vector]` > default.
+pub fn configured() -> Result<Option<ExternalTarget>, VectorBackendError> {
+    let config = VECTOR_CONFIG.get();
+    let kind = match std::env::var("CQS_VECTOR_BACKEND") {
+        Ok(v) => v.parse()?,
+        Err(_) => config.and_then(|c| c.backend).unwrap_or_default(),
+    };
+    let env_or = |var: &str, from_config: Option<&String>| {
+        std::env::var(var)
+            .ok()
+            .filter(|v| !v.trim().is_empty())
+            .or_else(|| from_config.cloned())
+    };
+    let url = env_or("CQS_VECTOR_URL", config.and_then(|c| c.url.as_ref()));
+    let collection = env_or(
+        "CQS_VECTOR_COLLECTION",
+        config.and_then(|c| c.collection.as_ref()),
+    );
+    let url = match kind {
+        VectorBackendKind::Sqlite => return Ok(None),
+        VectorBackendKind::Qdrant => url.unwrap_or_else(|| DEFAULT_QDRANT_URL.to_string()),
+        VectorBackendKind::Pgvector => url.ok_or(VectorBackendError::MissingUrl("pgvector"))?,
+    };
+    Ok(Some(ExternalTarget {
+        kind,
+        url,
+        collection,
+    }))
+}
+
+/// Collection (or table) holding the vectors of the index in `cqs_dir`:
+/// the configured name, else `cqs_<hash of the index directory>` so slots,
+/// shards, and projects sharing one server never collide.
+pub fn collection_name(
+    configured: Option<&str>,
+    cqs_dir: &Path,
+) -> Result<String, VectorBackendError> {
+    let name = match configured {
+        Some(name) => name.to_string(),
+        None => {
+            let dir = dunce::canonicalize(cqs_dir).unwrap_or_else(|_| cqs_dir.to_path_buf());
+            let hash = blake3::hash(crate::normalize_path(&dir).as_bytes()).to_hex();
+            format!("cqs_{}", &hash[..12])
+        }
+    };
+    // The name is spliced into URLs and SQL identifiers, so keep it plain.
+    if name.is_empty()
+        || name.len() > 63
+        || !name.chars().all(|c| c.is_ascii_alphanumeric() || c == '_')
+    {
+        return Err(VectorBackendError::InvalidCollection(name));
+    }
+    Ok(name)
+}
+
+/// Version tag of a stored vector, so a sync re-uploads vectors that were
+/// re-embedded under an unchanged chunk id (LLM summaries, model swaps).
+pub fn fingerprint(embedding: &Embedding) -> String {
+    let mut hasher = blake3::Hasher::new();
+    for v in embedding.as_slice() {
+        hasher.update(&v.to_le_bytes());
+    }
+    hasher.finalize().to_hex()[..16].to_string()
+}
+
+/// An external vector store. Holds `(chunk id, vector)` pairs for one index
+/// and answers nearest-neighbour queries over them with cosine similarity.
+pub trait VectorBackend: Send + Sync {
+    /// Stable identifier for logs and reports (`qdrant`, `pgvector`).
+    fn name(&self) -> &'static str;
+
+    /// Vector width the collection was created with; `None` when it doesn't
+    /// exist yet.
+    fn collection_dim(&self) -> Result<Option<usize>, VectorBackendError>;
+
+    /// Drop the collection, if any, and create it empty for `dim`-wide
+    /// vectors.
+    fn reset(&self, dim: usize) -> Result<(), VectorBackendError>;
+
+    /// Number of stored vectors.
+    fn len(&self) -> Result<usize, VectorBackendError>;
+
+    /// Every stored chunk id with the [`fingerprint`] of its vector.
+    fn fingerprints(&self) -> Result<HashMap<String, String>, VectorBackendError>;
+
+    /// Insert or replace these vectors.
+    fn upsert(&self, rows: &[(String, Embedding)]) -> Result<(), VectorBackendError>;
+
+    /// Delete these chunk ids. Ids not stored are ignored.
+    fn delete(&self, ids: &[String]) -> Result<(), VectorBackendError>;
+
+    /// The `k` nearest vectors to `query`, most similar first.
+    fn search(&self, query: &Embedding, k: usize) -> Result<Vec<IndexResult>, VectorBackendError>;
+}
+
+/// Connect to `target`'s store for the index in `cqs_dir`. Offline mode
+/// refuses any store that is not on a loopback address.
+pub fn open(
+    target: &ExternalTarget,
+    cqs_dir: &Path,
+) -> Result<Box<dyn VectorBackend>, VectorBackendError> {
+    let _span = tracing::info_span!("vector_backend_open", kind = target.kind.as_str()).entered();
+    let collection = collection_name(target.collection.as_deref(), cqs_dir)?;
+    if target.kind != VectorBackendKind::Sqlite {
+        crate::offline::check_url(
+            &format!("{} vector store", target.kind.as_str()),
+            &target.url,
+        )?;
+    }
+    match target.kind {
+        VectorBackendKind::Sqlite => Err(VectorBackendError::UnknownBackend(
+            "sqlite is built in, not external".to_string(),
+        )),
+        VectorBackendKind::Qdrant => {
+            #[cfg(feature = "qdrant")]
+            {
+                Ok(Box::new(qdrant::QdrantStore::new(&target.url, collection)?))
+            }
+            #[cfg(not(feature = "qdrant"))]
+            {
+                let _ = collection;
+                Err(VectorBackendError::FeatureDisabled("qdrant"))
+            }
+        }
+        VectorBackendKind::Pgvector => {
+            #[cfg(feature = "pgvector")]
+            {
+                Ok(Box::new(pgvector::PgVectorStore::connect(
+                    &target.url,
+                    collection,
+                )?))
+            }
+            #[cfg(not(feature = "pgvector"))]
+            {
+                let _ = collection;
+                Err(VectorBackendError::FeatureDisabled("pgvector"))
+            }
+        }
+    }
+}
+
+/// What one [`sync_from_store`] run changed.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct SyncStats {
+    /// Vectors uploaded: new chunks and re-embedded ones.
+    pub upserted: usize,
+    /// Ids deleted because the index no longer has them.
+    pub deleted: usize,
+    /// Vectors in the store after the sync.
+    pub total: usize,
+}
+
+/// Bring `backend` in line with the vectors in `store`. A collection of the
+/// wrong width (model change) is recreated.
+pub fn sync_from_store<Mode>(
+    backend: &dyn VectorBackend,
+    store: &Store<Mode>,
+) -> Result<SyncStats, VectorBackendError> {
+    let _span = tracing::info_span!("vector_backend_sync", backend = backend.name()).entered();
+    let dim = store.dim();
+    let remote = match backend.collection_dim()? {
+        Some(d) if d == dim => backend.fingerprints()?,
+        existing => {
+            if let Some(d) = existing {
+                tracing::warn!(
+                    stored = d,
+                    dim,
+                    "External vector collection has another width, recreating it"
+                );
+            }
+            backend.reset(dim)?;
+            HashMap::new()
+        }
+    };
+
+    let mut seen: HashSet<String> = HashSet::with_capacity(remote.len());
+    let mut pending: Vec<(String, Embedding)> = Vec::new();
+    let mut upserted = 0;
+    for batch in store.embedding_batches(SYNC_READ_BATCH) {
+        for (id, embedding) in batch? {
+            if remote.get(&id) != Some(&fingerprint(&embedding)) {
+                pending.push((id.clone(), embedding));
+            }
+            seen.insert(id);
+            if pending.len() >= SYNC_WRITE_BATCH {
+                backend.upsert(&pending)?;
+                upserted += pending.len();
+                pending.clear();
+            }
+        }
+    }
+    if !pending.is_empty() {
+        backend.upsert(&pending)?;
+        upserted += pending.len();
+    }
+
+    let gone: Vec<String> = remote.into_keys().filter(|id| !seen.contains(id)).collect();
+    for batch in gone.chunks(SYNC_WRITE_BATCH) {
+        backend.delete(batch)?;
+    }
+
+    tracing::info!(
+        upserted,
+        deleted = gone.len(),
+        total = seen.len(),
+        "External vector store synced"
+    );
+    Ok(SyncStats {
+        upserted,
+        deleted: gone.len(),
+        total: seen.len(),
+    })
+}
+
+/// Sync the configured external store, if any, with the index in `cqs_dir`.
+/// `Ok(None)` when the built-in backend is configured.
+pub fn sync_configured<Mode>(
+    store: &Store<Mode>,
+    cqs_dir: &Path,
+) -> Result<Option<(VectorBackendKind, SyncStats)>, VectorBackendError> {
+    let Some(target) = configured()? else {
+        return Ok(None);
+    };
+    let backend = open(&target, cqs_dir)?;
+    Ok(Some((
+        target.kind,
+        sync_from_store(backend.as_ref(), store)?,
+    )))
+}
+
+/// [`VectorIndex`] over an external store. A failed query logs and returns
+/// nothing, like a GPU index that lost its device.
+pub struct ExternalIndex {
+    backend: Box<dyn VectorBackend>,
+    len: usize,
+    dim: usize,
+}
+
+impl VectorIndex for ExternalIndex {
+    fn search(&self, query: &Embedding, k: usize) -> Vec<IndexResult> {
+        match self.backend.search(query, k) {
+            Ok(results) => results,
+            Err(e) => {
+                tracing::warn!(
+                    backend = self.backend.name(),
+                    error = %e,
+                    "External vector search failed, dense leg empty"
+                );
+                Vec::new()
+            }
+        }
+    }
+
+    fn len(&self) -> usize {
+        self.len
+    }
+
+    fn name(&self) -> &'static str {
+        self.backend.name()
+    }
+
+    fn dim(&self) -> usize {
+        self.dim
+    }
+}
+
+/// Backend that serves the dense leg from the configured external store.
+///
+/// Priority 200, above every built-in index, but only eligible when
+/// `[index.vector]` (or `CQS_VECTOR_BACKEND`) names an external store that
+/// answers, has the store's width, and holds as many vectors as the index
+/// has chunks. Otherwise selection falls through to CAGRA / HNSW.
+pub struct ExternalBackend;
+
+impl<Mode: ClearHnswDirty> IndexBackend<Mode> for ExternalBackend {
+    fn name(&self) -> &'static str {
+        "external"
+    }
+
+    fn priority(&self) -> i32 {
+        200
+    }
+
+    fn try_open(
+        &self,
+        ctx: &BackendContext<'_, Mode>,
+    ) -> Result<Option<Box<dyn VectorIndex>>, StoreError> {
+        let target = match configured() {
+            Ok(Some(target)) => target,
+            Ok(None) => return Ok(None),
+            Err(e) => {
+                tracing::warn!(error = %e, "Invalid external vector backend config, falling through");
+                return Ok(None);
+            }
+        };
+        let backend = match open(&target, ctx.cqs_dir) {
+            Ok(backend) => backend,
+            Err(e) => {
+                tracing::warn!(error = %e, "External vector store unavailable, falling through");
+                return Ok(None);
+            }
+        };
+        let dim = ctx.store.dim();
+        let expected = ctx.store.chunk_count()? as usize;
+        match (backend.collection_dim(), backend.len()) {
+            (Ok(Some(d)), Ok(len)) if d == dim && len == expected => {
+                tracing::info!(
+                    backend = backend.name(),
+                    source = "external",
+                    vectors = len,
+                    "Vector index backend selected"
+                );
+                Ok(Some(Box::new(ExternalIndex { backend, len, dim })))
+            }
+            (Ok(stored_dim), Ok(len)) => {
+                tracing::warn!(
+                    backend = backend.name(),
+                    vectors = len,
+                    expected,
+                    ?stored_dim,
+                    dim,
+                    "External vector store out of sync with the index (run `cqs index`), falling through"
+                );
+                Ok(None)
+            }
+            (Err(e), _) | (_, Err(e)) => {
+                tracing::warn!(error = %e, "External vector store unavailable, falling through");
+                Ok(None)
+            }
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::store::test_utils::make_chunk;
+    use std::sync::Mutex;
+
+    #[test]
+    fn backend_kind_parses_aliases() {
+        assert_eq!(
+            "Qdrant".parse::<VectorBackendKind>().unwrap(),
+            VectorBackendKind::Qdrant
+        );
+        assert_eq!(
+            "postgres".parse::<VectorBackendKind>().unwrap(),
+            VectorBackendKind::Pgvector
+        );
+        assert_eq!(
+            "hnsw".parse::<VectorBackendKind>().unwrap(),
+            VectorBackendKind::Sqlite
+        );
+        assert!("faiss".parse::<VectorBackendKind>().is_err());
+    }
+
+    #[test]
+    fn collection_name_defaults_per_index_and_validates() {
+        let a = collection_name(None, Path::new("/p/a/.cqs")).unwrap();
+        let b = collection_name(None, Path::new("/p/b/.cqs")).unwrap();
+        assert!(a.starts_with("cqs_") && a.len() == 16, "{a}");
+        assert_ne!(a, b);
+        assert_eq!(
+            collection_name(Some("billing_v2"), Path::new("/x")).unwrap(),
+            "billing_v2"
+        );
+        assert!(collection_name(Some("drop table;"), Path::new("/x")).is_err());
+        assert!(collection_name(Some(""), Path::new("/x")).is_err());
+    }
+
+    /// In-memory [`VectorBackend`] keeping only fingerprints.
+    #[derive(Default)]
+    struct MemoryBackend {
+        dim: Mutex<Option<usize>>,
+        rows: Mutex<HashMap<String, String>>,
+    }
+
+    impl VectorBackend for MemoryBackend {
+        fn name(&self) -> &'static str {
+            "memory"
+        }
+        fn collection_dim(&self) -> Result<Option<usize>, VectorBackendError> {
+            Ok(*self.dim.lock().unwrap())
+        }
+        fn reset(&self, dim: usize) -> Result<(), VectorBackendError> {
+            *self.dim.lock().unwrap() = Some(dim);
+            self.rows.lock().unwrap().clear();
+            Ok(())
+        }
+        fn len(&self) -> Result<usize, VectorBackendError> {
+            Ok(self.rows.lock().unwrap().len())
+        }
+        fn fingerprints(&self) -> Result<HashMap<String, String>, VectorBackendError> {
+            Ok(self.rows.lock().unwrap().clone())
+        }
+        fn upsert(&self, rows: &[(String, Embedding)]) -> Result<(), VectorBackendError> {
+            let mut stored = self.rows.lock().unwrap();
+            for (id, emb) in rows {
+                stored.insert(id.clone(), fingerprint(emb));
+            }
+            Ok(())
+        }
+        fn delete(&self, ids: &[String]) -> Result<(), VectorBackendError> {
+            let mut stored = self.rows.lock().unwrap();
+            for id in ids {
+                stored.remove(id);
+            }
+            Ok(())
+        }
+        fn search(&self, _: &Embedding, _: usize) -> Result<Vec<IndexResult>, VectorBackendError> {
+            Ok(Vec::new())
+        }
+    }
+
+    /// Unit vector along axis `i`: distinct fingerprints per axis.
+    fn axis(i: usize) -> Embedding {
+        let mut v = vec![0.0; crate::EMBEDDING_DIM];
+        v[i] = 1.0;
+        Embedding::new(v)
+    }
+
+    #[test]
+    fn sync_uploads_new_and_changed_and_deletes_gone() {
+        use crate::test_helpers::setup_store;
+
+        let (store, _dir) = setup_store();
+        let a = make_chunk("alpha", "src/a.rs");
+        let b = make_chunk("beta", "src/b.rs");
+        store.upsert_chunk(&a, &axis(0), None).unwrap();
+        store.upsert_chunk(&b, &axis(1), None).unwrap();
+
+        let backend = MemoryBackend::default();
+        backend.reset(store.dim()).unwrap();
+        backend
+            .rows
+            .lock()
+            .unwrap()
+            .insert("gone".to_string(), "0".to_string());
+
+        let stats = sync_from_store(&backend, &store).unwrap();
+        assert_eq!(stats.upserted, 2);
+        assert_eq!(stats.deleted, 1);
+        assert_eq!(stats.total, 2);
+        assert_eq!(backend.len().unwrap(), 2);
+
+        // Nothing changed: nothing is sent.
+        let stats = sync_from_store(&backend, &store).unwrap();
+        assert_eq!((stats.upserted, stats.deleted), (0, 0));
+
+        // A re-embedded chunk (same id, new vector) is sent again.
+        store
+            .update_embeddings_batch(&[(a.id.clone(), axis(2))])
+            .unwrap();
+        let stats = sync_from_store(&backend, &store).unwrap();
+        assert_eq!(stats.upserted, 1);
+    }
+
+    #[test]
+    fn sync_recreates_a_collection_of_another_width() {
+        use crate::test_helpers::setup_store;
+
+        let (store, _dir) = setup_store();
+        let backend = MemoryBackend::default();
+        backend.reset(store.dim() + 1).unwrap();
+        backend
+            .rows
+            .lock()
+            .unwrap()
+            .insert("old".to_string(), "0".to_string());
+        sync_from_store(&backend, &store).unwrap();
+        assert_eq!(backend.collection_dim().unwrap(), Some(store.dim()));
+        assert_eq!(backend.len().unwrap(), 0);
+    }
+}
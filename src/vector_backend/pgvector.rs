@@ -0,0 +1,184 @@
+//! PostgreSQL with the `vector` extension (`pgvector` feature).
+//!
+//! One table per index: `(id TEXT PRIMARY KEY, fp TEXT, embedding
+//! vector(dim))` with an HNSW index on cosine distance. Vectors travel as
+//! text literals cast to `vector`, so no pgvector client type is needed.
+//! The table name comes from [`super::collection_name`], which limits it to
+//! `[A-Za-z0-9_]`; that is what makes splicing it into SQL safe.
+
+use std::collections::HashMap;
+
+use sqlx::postgres::{PgPool, PgPoolOptions};
+
+use super::{fingerprint, VectorBackend, VectorBackendError};
+use crate::embedder::Embedding;
+use crate::index::IndexResult;
+
+pub(super) struct PgVectorStore {
+    rt: tokio::runtime::Runtime,
+    pool: PgPool,
+    table: String,
+}
+
+fn request_err(e: sqlx::Error) -> VectorBackendError {
+    VectorBackendError::Request(e.to_string())
+}
+
+/// `[v1,v2,...]`, pgvector's text input format.
+fn vector_literal(embedding: &Embedding) -> String {
+    let values: Vec<String> = embedding.as_slice().iter().map(f32::to_string).collect();
+    format!("[{}]", values.join(","))
+}
+
+impl PgVectorStore {
+    pub(super) fn connect(url: &str, table: String) -> Result<Self, VectorBackendError> {
+        let rt = tokio::runtime::Builder::new_current_thread()
+            .enable_all()
+            .build()
+            .map_err(|e| VectorBackendError::Request(e.to_string()))?;
+        let pool = rt
+            .block_on(
+                PgPoolOptions::new()
+                    .max_connections(2)
+                    .acquire_timeout(std::time::Duration::from_secs(10))
+                    .connect(url),
+            )
+            .map_err(request_err)?;
+        Ok(Self { rt, pool, table })
+    }
+}
+
+impl VectorBackend for PgVectorStore {
+    fn name(&self) -> &'static str {
+        "pgvector"
+    }
+
+    fn collection_dim(&self) -> Result<Option<usize>, VectorBackendError> {
+        // A `vector(n)` column records `n` as its type modifier.
+        let row: Option<(i32,)> = self
+            .rt
+            .block_on(
+                sqlx::query_as(
+                    "SELECT atttypmod FROM pg_attribute
+                     WHERE attrelid = to_regclass($1) AND attname = 'embedding'",
+                )
+                .bind(&self.table)
+                .fetch_optional(&self.pool),
+            )
+            .map_err(request_err)?;
+        Ok(row.and_then(|(n,)| usize::try_from(n).ok()))
+    }
+
+    fn reset(&self, dim: usize) -> Result<(), VectorBackendError> {
+        let table = &self.table;
+        let statements = [
+            "CREATE EXTENSION IF NOT EXISTS vector".to_string(),
+            format!("DROP TABLE IF EXISTS {table}"),
+            format!(
+                "CREATE TABLE {table} (
+                     id TEXT PRIMARY KEY,
+                     fp TEXT NOT NULL,
+                     embedding vector({dim}) NOT NULL
+                 )"
+            ),
+            format!("CREATE INDEX ON {table} USING hnsw (embedding vector_cosine_ops)"),
+        ];
+        self.rt.block_on(async {
+            for sql in &statements {
+                sqlx::query(sqlx::AssertSqlSafe(sql.as_str()))
+                    .execute(&self.pool)
+                    .await
+                    .map_err(request_err)?;
+            }
+            Ok(())
+        })
+    }
+
+    fn len(&self) -> Result<usize, VectorBackendError> {
+        let sql = format!("SELECT COUNT(*) FROM {}", self.table);
+        let (n,): (i64,) = self
+            .rt
+            .block_on(sqlx::query_as(sqlx::AssertSqlSafe(sql.as_str())).fetch_one(&self.pool))
+            .map_err(request_err)?;
+        Ok(n as usize)
+    }
+
+    fn fingerprints(&self) -> Result<HashMap<String, String>, VectorBackendError> {
+        let sql = format!("SELECT id, fp FROM {}", self.table);
+        let rows: Vec<(String, String)> = self
+            .rt
+            .block_on(sqlx::query_as(sqlx::AssertSqlSafe(sql.as_str())).fetch_all(&self.pool))
+            .map_err(request_err)?;
+        Ok(rows.into_iter().collect())
+    }
+
+    fn upsert(&self, rows: &[(String, Embedding)]) -> Result<(), VectorBackendError> {
+        if rows.is_empty() {
+            return Ok(());
+        }
+        let mut qb: sqlx::QueryBuilder<sqlx::Postgres> =
+            sqlx::QueryBuilder::new(format!("INSERT INTO {} (id, fp, embedding) ", self.table));
+        qb.push_values(rows, |mut b, (id, embedding)| {
+            b.push_bind(id.as_str())
+                .push_bind(fingerprint(embedding))
+                .push_bind(vector_literal(embedding))
+                .push_unseparated("::vector");
+        });
+        qb.push(" ON CONFLICT (id) DO UPDATE SET fp = excluded.fp, embedding = excluded.embedding");
+        self.rt
+            .block_on(qb.build().execute(&self.pool))
+            .map_err(request_err)?;
+        Ok(())
+    }
+
+    fn delete(&self, ids: &[String]) -> Result<(), VectorBackendError> {
+        let sql = format!("DELETE FROM {} WHERE id = ANY($1)", self.table);
+        self.rt
+            .block_on(
+                sqlx::query(sqlx::AssertSqlSafe(sql.as_str()))
+                    .bind(ids)
+                    .execute(&self.pool),
+            )
+            .map_err(request_err)?;
+        Ok(())
+    }
+
+    fn search(&self, query: &Embedding, k: usize) -> Result<Vec<IndexResult>, VectorBackendError> {
+        // `<=>` is cosine distance; similarity is its complement.
+        let sql = format!(
+            "SELECT id, 1 - (embedding <=> $1::vector) FROM {}
+             ORDER BY embedding <=> $1::vector
+             LIMIT $2",
+            self.table
+        );
+        let rows: Vec<(String, f64)> = self
+            .rt
+            .block_on(
+                sqlx::query_as(sqlx::AssertSqlSafe(sql.as_str()))
+                    .bind(vector_literal(query))
+                    .bind(k as i64)
+                    .fetch_all(&self.pool),
+            )
+            .map_err(request_err)?;
+        Ok(rows
+            .into_iter()
+            .map(|(id, score)| IndexResult {
+                id,
+                score: score as f32,
+            })
+            .collect())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn vector_literal_is_pgvector_text_input() {
+        assert_eq!(
+            vector_literal(&Embedding::new(vec![0.5, -1.0, 0.25])),
+            "[0.5,-1,0.25]"
+        );
+    }
+}
@@ -0,0 +1,280 @@
+//! Qdrant over its REST API (`qdrant` feature).
+//!
+//! Qdrant point ids must be integers or UUIDs, so each chunk id maps to a
+//! UUID derived from its blake3 hash; the chunk id itself rides in the
+//! payload with the vector's fingerprint. `CQS_QDRANT_API_KEY` is sent as
+//! the `api-key` header when set.
+
+use std::collections::HashMap;
+use std::time::Duration;
+
+use serde_json::{json, Value};
+
+use super::{fingerprint, VectorBackend, VectorBackendError};
+use crate::embedder::Embedding;
+use crate::index::IndexResult;
+
+/// Points fetched per scroll page.
+const SCROLL_PAGE: usize = 1000;
+
+/// Per-request timeout. Uploads are batched small enough to fit.
+const REQUEST_TIMEOUT: Duration = Duration::from_secs(30);
+
+pub(super) struct QdrantStore {
+    client: reqwest::blocking::Client,
+    /// `<url>/collections/<collection>`, no trailing slash.
+    base: String,
+    api_key: Option<String>,
+}
+
+/// Qdrant point id for a chunk id: the first 16 bytes of its blake3 hash,
+/// formatted as a UUID.
+fn point_id(chunk_id: &str) -> String {
+    let hex = blake3::hash(chunk_id.as_bytes()).to_hex();
+    format!(
+        "{}-{}-{}-{}-{}",
+        &hex[0..8],
+        &hex[8..12],
+        &hex[12..16],
+        &hex[16..20],
+        &hex[20..32]
+    )
+}
+
+impl QdrantStore {
+    pub(super) fn new(url: &str, collection: String) -> Result<Self, VectorBackendError> {
+        let client = reqwest::blocking::Client::builder()
+            .timeout(REQUEST_TIMEOUT)
+            .build()
+            .map_err(|e| VectorBackendError::Request(e.to_string()))?;
+        Ok(Self {
+            client,
+            base: format!("{}/collections/{collection}", url.trim_end_matches('/')),
+            api_key: std::env::var("CQS_QDRANT_API_KEY")
+                .ok()
+                .filter(|k| !k.is_empty()),
+        })
+    }
+
+    /// Send a request and return the response's `result`. `Ok(None)` on 404.
+    fn call(
+        &self,
+        method: reqwest::Method,
+        path: &str,
+        body: Option<Value>,
+    ) -> Result<Option<Value>, VectorBackendError> {
+        let mut request = self.client.request(method, format!("{}{path}", self.base));
+        if let Some(key) = &self.api_key {
+            request = request.header("api-key", key);
+        }
+        if let Some(body) = body {
+            request = request.json(&body);
+        }
+        let response = request
+            .send()
+            .map_err(|e| VectorBackendError::Request(e.to_string()))?;
+        let status = response.status();
+        if status == reqwest::StatusCode::NOT_FOUND {
+            return Ok(None);
+        }
+        let text = response
+            .text()
+            .map_err(|e| VectorBackendError::Request(e.to_string()))?;
+        if !status.is_success() {
+            return Err(VectorBackendError::Request(format!(
+                "HTTP {status}: {text}"
+            )));
+        }
+        let value: Value =
+            serde_json::from_str(&text).map_err(|e| VectorBackendError::Response(e.to_string()))?;
+        Ok(Some(value.get("result").cloned().unwrap_or(Value::Null)))
+    }
+
+    /// Like [`Self::call`], but a missing collection is an error.
+    fn call_existing(
+        &self,
+        method: reqwest::Method,
+        path: &str,
+        body: Option<Value>,
+    ) -> Result<Value, VectorBackendError> {
+        self.call(method, path, body)?
+            .ok_or_else(|| VectorBackendError::Response(format!("no collection at {}", self.base)))
+    }
+}
+
+impl VectorBackend for QdrantStore {
+    fn name(&self) -> &'static str {
+        "qdrant"
+    }
+
+    fn collection_dim(&self) -> Result<Option<usize>, VectorBackendError> {
+        let Some(info) = self.call(reqwest::Method::GET, "", None)? else {
+            return Ok(None);
+        };
+        info.pointer("/config/params/vectors/size")
+            .and_then(Value::as_u64)
+            .map(|d| Some(d as usize))
+            .ok_or_else(|| {
+                VectorBackendError::Response("collection has no single unnamed vector".to_string())
+            })
+    }
+
+    fn reset(&self, dim: usize) -> Result<(), VectorBackendError> {
+        self.call(reqwest::Method::DELETE, "", None)?;
+        self.call_existing(
+            reqwest::Method::PUT,
+            "",
+            Some(json!({ "vectors": { "size": dim, "distance": "Cosine" } })),
+        )?;
+        Ok(())
+    }
+
+    fn len(&self) -> Result<usize, VectorBackendError> {
+        let result = self.call_existing(
+            reqwest::Method::POST,
+            "/points/count",
+            Some(json!({ "exact": true })),
+        )?;
+        result
+            .get("count")
+            .and_then(Value::as_u64)
+            .map(|n| n as usize)
+            .ok_or_else(|| VectorBackendError::Response("count missing".to_string()))
+    }
+
+    fn fingerprints(&self) -> Result<HashMap<String, String>, VectorBackendError> {
+        let mut out = HashMap::new();
+        let mut offset = Value::Null;
+        loop {
+            let mut body = json!({
+                "limit": SCROLL_PAGE,
+                "with_payload": ["chunk_id", "fp"],
+                "with_vector": false,
+            });
+            if !offset.is_null() {
+                body["offset"] = offset;
+            }
+            let page = self.call_existing(reqwest::Method::POST, "/points/scroll", Some(body))?;
+            let points = page
+                .get("points")
+                .and_then(Value::as_array)
+                .ok_or_else(|| VectorBackendError::Response("scroll without points".to_string()))?;
+            for point in points {
+                let payload = point.get("payload");
+                let field = |name: &str| {
+                    payload
+                        .and_then(|p| p.get(name))
+                        .and_then(Value::as_str)
+                        .map(str::to_string)
+                };
+                if let Some(id) = field("chunk_id") {
+                    out.insert(id, field("fp").unwrap_or_default());
+                }
+            }
+            offset = page.get("next_page_offset").cloned().unwrap_or(Value::Null);
+            if offset.is_null() {
+                return Ok(out);
+            }
+        }
+    }
+
+    fn upsert(&self, rows: &[(String, Embedding)]) -> Result<(), VectorBackendError> {
+        let points: Vec<Value> = rows
+            .iter()
+            .map(|(id, embedding)| {
+                json!({
+                    "id": point_id(id),
+                    "vector": embedding.as_slice(),
+                    "payload": { "chunk_id": id, "fp": fingerprint(embedding) },
+                })
+            })
+            .collect();
+        self.call_existing(
+            reqwest::Method::PUT,
+            "/points?wait=true",
+            Some(json!({ "points": points })),
+        )?;
+        Ok(())
+    }
+
+    fn delete(&self, ids: &[String]) -> Result<(), VectorBackendError> {
+        let points: Vec<String> = ids.iter().map(|id| point_id(id)).collect();
+        self.call_existing(
+            reqwest::Method::POST,
+            "/points/delete?wait=true",
+            Some(json!({ "points": points })),
+        )?;
+        Ok(())
+    }
+
+    fn search(&self, query: &Embedding, k: usize) -> Result<Vec<IndexResult>, VectorBackendError> {
+        let hits = self.call_existing(
+            reqwest::Method::POST,
+            "/points/search",
+            Some(json!({
+                "vector": query.as_slice(),
+                "limit": k,
+                "with_payload": ["chunk_id"],
+            })),
+        )?;
+        let hits = hits.as_array().ok_or_else(|| {
+            VectorBackendError::Response("search result is not a list".to_string())
+        })?;
+        Ok(hits
+            .iter()
+            .filter_map(|hit| {
+                Some(IndexResult {
+                    id: hit.pointer("/payload/chunk_id")?.as_str()?.to_string(),
+                    score: hit.get("score")?.as_f64()? as f32,
+                })
+            })
+            .collect())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn point_id_is_a_stable_uuid() {
+        let id = point_id("src/lib.rs:10:abcd1234");
+        assert_eq!(id.len(), 36);
+        assert_eq!(id.matches('-').count(), 4);
+        assert_eq!(id, point_id("src/lib.rs:10:abcd1234"));
+        assert_ne!(id, point_id("src/lib.rs:11:abcd1234"));
+    }
+
+    #[test]
+    fn search_maps_payload_ids_and_scores() {
+        let server = httpmock::MockServer::start();
+        let mock = server.mock(|when, then| {
+            when.method("POST")
+                .path("/collections/cqs_test/points/search");
+            then.status(200).json_body(json!({
+                "result": [
+                    { "id": point_id("a"), "score": 0.9, "payload": { "chunk_id": "a" } },
+                    { "id": point_id("b"), "score": 0.5, "payload": { "chunk_id": "b" } },
+                ],
+                "status": "ok",
+            }));
+        });
+        let store = QdrantStore::new(&server.base_url(), "cqs_test".to_string()).unwrap();
+        let results = store.search(&Embedding::new(vec![1.0, 0.0]), 2).unwrap();
+        mock.assert();
+        assert_eq!(results.len(), 2);
+        assert_eq!(results[0].id, "a");
+        assert!((results[0].score - 0.9).abs() < 1e-6);
+    }
+
+    #[test]
+    fn missing_collection_has_no_dim() {
+        let server = httpmock::MockServer::start();
+        server.mock(|when, then| {
+            when.method("GET").path("/collections/cqs_test");
+            then.status(404);
+        });
+        let store = QdrantStore::new(&server.base_url(), "cqs_test".to_string()).unwrap();
+        assert_eq!(store.collection_dim().unwrap(), None);
+    }
+}
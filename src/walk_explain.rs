@@ -0,0 +1,314 @@
+//! Why the file walk does or doesn't pick up a path.
+//!
+//! [`crate::enumerate_files_iter`] answers "which files are indexed" for a
+//! whole tree but can't say why one file is missing. The functions here
+//! replay its per-path decisions for a single file — hidden components,
+//! nested worktrees, ignore rules, language detection, the size cap — and
+//! report the first one that excludes it. `cqs explain-index` is the
+//! consumer.
+//!
+//! Ignore rules are resolved with the `ignore` crate's precedence:
+//! `.cqsignore` beats `.ignore` beats `.gitignore` beats
+//! `.git/info/exclude` beats the global excludes file, and within each kind
+//! a deeper directory beats a shallower one. `.gitignore`, `exclude`, and
+//! the global file only count inside a git repository, as in the walk. A
+//! directory that is excluded hides everything below it, so each ancestor
+//! is checked before the file itself.
+
+use std::path::{Component, Path, PathBuf};
+
+use ignore::gitignore::{Gitignore, GitignoreBuilder};
+
+use crate::language::{overrides, Language};
+
+/// The first walk filter that drops a path.
+#[derive(Debug, Clone, PartialEq, Eq, serde::Serialize)]
+#[serde(tag = "kind", rename_all = "snake_case")]
+pub enum WalkExclusion {
+    /// A path component starts with `.` (hidden files are skipped).
+    Hidden { component: String },
+    /// A directory on the path is a linked git worktree (its `.git` is a file).
+    NestedWorktree { dir: String },
+    /// An ignore rule matched the path or one of its directories.
+    Ignored {
+        /// Ignore file holding the rule, project-relative when inside the
+        /// project.
+        file: String,
+        /// 1-based line of the rule; `None` when it can't be located.
+        line: Option<usize>,
+        /// The rule as written.
+        pattern: String,
+        /// Which path the rule matched (the file or an ancestor directory).
+        matched: String,
+    },
+}
+
+/// How a path's language was decided.
+#[derive(Debug, Clone, PartialEq, Eq, serde::Serialize)]
+#[serde(tag = "via", rename_all = "snake_case")]
+pub enum LanguageSource {
+    /// A `[languages] overrides` rule.
+    Override { pattern: String, target: String },
+    /// The extension table.
+    Extension { extension: String },
+    /// No override and no (supported) extension.
+    Unsupported,
+}
+
+/// Outcome of language detection for one path.
+#[derive(Debug, Clone, PartialEq, Eq, serde::Serialize)]
+pub struct LanguageDetection {
+    /// Detected language; `None` means the walk skips the file.
+    pub language: Option<String>,
+    #[serde(flatten)]
+    pub source: LanguageSource,
+}
+
+/// Detect `path`'s language the way the walk does: an override rule wins,
+/// then the extension table.
+pub fn detect_language(path: &Path) -> LanguageDetection {
+    if let Some(rule) = overrides::active().lookup(path) {
+        return LanguageDetection {
+            language: rule.language.map(|l| l.to_string()),
+            source: LanguageSource::Override {
+                pattern: rule.pattern.clone(),
+                target: rule.target.clone(),
+            },
+        };
+    }
+    let Some(ext) = path.extension().and_then(|e| e.to_str()) else {
+        return LanguageDetection {
+            language: None,
+            source: LanguageSource::Unsupported,
+        };
+    };
+    let ext = ext.to_ascii_lowercase();
+    match Language::from_extension(&ext) {
+        Some(language) => LanguageDetection {
+            language: Some(language.to_string()),
+            source: LanguageSource::Extension { extension: ext },
+        },
+        None => LanguageDetection {
+            language: None,
+            source: LanguageSource::Unsupported,
+        },
+    }
+}
+
+/// The walk filter that excludes `rel` (project-relative) under `root`, or
+/// `None` when the walk would yield it. Only the hidden, worktree, and
+/// ignore filters are checked here; language and size are reported
+/// separately by [`detect_language`] and [`crate::max_file_size`].
+pub fn walk_exclusion(root: &Path, rel: &Path) -> Option<WalkExclusion> {
+    let _span = tracing::debug_span!("walk_exclusion", path = %rel.display()).entered();
+    let root = dunce::canonicalize(root).unwrap_or_else(|_| root.to_path_buf());
+    let components: Vec<&std::ffi::OsStr> = rel
+        .components()
+        .filter_map(|c| match c {
+            Component::Normal(name) => Some(name),
+            _ => None,
+        })
+        .collect();
+    let git_top = root
+        .ancestors()
+        .find(|dir| dir.join(".git").exists())
+        .map(Path::to_path_buf);
+    // Directories whose ignore files apply: from the repository top (the
+    // walk reads parent ignore files up to it) down to the project root,
+    // then each directory along `rel`.
+    let mut dirs: Vec<PathBuf> = match &git_top {
+        Some(top) => {
+            let mut above: Vec<PathBuf> = root
+                .ancestors()
+                .take_while(|dir| *dir != top.as_path())
+                .map(Path::to_path_buf)
+                .collect();
+            above.push(top.clone());
+            above.reverse();
+            above
+        }
+        None => vec![root.clone()],
+    };
+
+    let mut path = root.clone();
+    for (i, name) in components.iter().enumerate() {
+        path.push(name);
+        let is_last = i + 1 == components.len();
+        let is_dir = !is_last || path.is_dir();
+        let display = crate::rel_display(&path, &root);
+
+        if name.to_string_lossy().starts_with('.') {
+            return Some(WalkExclusion::Hidden {
+                component: name.to_string_lossy().into_owned(),
+            });
+        }
+        if is_dir && !is_last && path.join(".git").is_file() {
+            return Some(WalkExclusion::NestedWorktree { dir: display });
+        }
+        if let Some((file, line, pattern)) = ignore_match(&dirs, git_top.as_deref(), &path, is_dir)
+        {
+            return Some(WalkExclusion::Ignored {
+                file: crate::rel_display(&file, &root),
+                line: line,
+                pattern,
+                matched: display,
+            });
+        }
+        if is_dir {
+            dirs.push(path.clone());
+        }
+    }
+    None
+}
+
+/// Resolve the ignore rules in effect for `path`. Returns the ignoring
+/// rule's file, line, and text; a whitelist (`!pattern`) match or no match
+/// yields `None`.
+fn ignore_match(
+    dirs: &[PathBuf],
+    git_top: Option<&Path>,
+    path: &Path,
+    is_dir: bool,
+) -> Option<(PathBuf, Option<usize>, String)> {
+    const KINDS: [(&str, bool); 3] = [
+        (".cqsignore", false),
+        (".ignore", false),
+        (".gitignore", true),
+    ];
+    let mut matchers: Vec<Gitignore> = Vec::new();
+    for (name, needs_git) in KINDS {
+        if needs_git && git_top.is_none() {
+            continue;
+        }
+        // Deepest directory first: it takes precedence within the kind.
+        for dir in dirs.iter().rev() {
+            let file = dir.join(name);
+            if file.is_file() {
+                matchers.push(build_matcher(dir, &file));
+            }
+        }
+    }
+    if let Some(top) = git_top {
+        let exclude = top.join(".git").join("info").join("exclude");
+        if exclude.is_file() {
+            matchers.push(build_matcher(top, &exclude));
+        }
+        let (global, err) = Gitignore::global();
+        if let Some(err) = err {
+            tracing::debug!(error = %err, "Global gitignore could not be read");
+        }
+        matchers.push(global);
+    }
+
+    for matcher in &matchers {
+        match matcher.matched(path, is_dir) {
+            ignore::Match::None => continue,
+            ignore::Match::Whitelist(_) => return None,
+            ignore::Match::Ignore(glob) => {
+                let file = glob
+                    .from()
+                    .map(Path::to_path_buf)
+                    .unwrap_or_else(|| matcher.path().to_path_buf());
+                let line = glob.from().and_then(|f| rule_line(f, glob.original()));
+                return Some((file, line, glob.original().to_string()));
+            }
+        }
+    }
+    None
+}
+
+/// Compile one ignore file rooted at `dir`. A malformed line drops only
+/// that line; the walk behaves the same way.
+fn build_matcher(dir: &Path, file: &Path) -> Gitignore {
+    let mut builder = GitignoreBuilder::new(dir);
+    if let Some(err) = builder.add(file) {
+        tracing::debug!(path = %file.display(), error = %err, "Ignore file partially unreadable");
+    }
+    builder.build().unwrap_or_else(|err| {
+        tracing::debug!(path = %file.display(), error = %err, "Ignore file failed to compile");
+        Gitignore::empty()
+    })
+}
+
+/// 1-based line of `rule` in `file`. The last occurrence wins, matching
+/// gitignore's last-rule-wins semantics.
+fn rule_line(file: &Path, rule: &str) -> Option<usize> {
+    let text = std::fs::read_to_string(file).ok()?;
+    text.lines()
+        .enumerate()
+        .filter(|(_, line)| line.trim_end() == rule)
+        .map(|(i, _)| i + 1)
+        .last()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn reports_ignore_rule_with_file_and_line() {
+        let dir = tempfile::tempdir().unwrap();
+        let root = dir.path();
+        std::fs::create_dir_all(root.join("gen")).unwrap();
+        std::fs::write(root.join("gen/out.rs"), "fn a() {}").unwrap();
+        std::fs::write(root.join(".cqsignore"), "# generated\n*.tmp\ngen/\n").unwrap();
+
+        match walk_exclusion(root, Path::new("gen/out.rs")) {
+            Some(WalkExclusion::Ignored {
+                file,
+                line,
+                pattern,
+                matched,
+            }) => {
+                assert_eq!(file, ".cqsignore");
+                assert_eq!(line, Some(3));
+                assert_eq!(pattern, "gen/");
+                assert_eq!(matched, "gen");
+            }
+            other => panic!("expected an ignore match, got {other:?}"),
+        }
+    }
+
+    #[test]
+    fn whitelist_and_deeper_files_take_precedence() {
+        let dir = tempfile::tempdir().unwrap();
+        let root = dir.path();
+        std::fs::create_dir_all(root.join("src")).unwrap();
+        std::fs::write(root.join("src/keep.rs"), "").unwrap();
+        std::fs::write(root.join("src/drop.rs"), "").unwrap();
+        std::fs::write(root.join(".cqsignore"), "*.rs\n").unwrap();
+        std::fs::write(root.join("src/.cqsignore"), "!keep.rs\n").unwrap();
+
+        assert_eq!(walk_exclusion(root, Path::new("src/keep.rs")), None);
+        assert!(matches!(
+            walk_exclusion(root, Path::new("src/drop.rs")),
+            Some(WalkExclusion::Ignored { .. })
+        ));
+    }
+
+    #[test]
+    fn hidden_components_are_excluded() {
+        let dir = tempfile::tempdir().unwrap();
+        assert_eq!(
+            walk_exclusion(dir.path(), Path::new(".config/x.rs")),
+            Some(WalkExclusion::Hidden {
+                component: ".config".to_string()
+            })
+        );
+    }
+
+    #[test]
+    fn language_by_extension_or_unsupported() {
+        let rs = detect_language(Path::new("src/lib.RS"));
+        assert_eq!(rs.language.as_deref(), Some("rust"));
+        assert_eq!(
+            rs.source,
+            LanguageSource::Extension {
+                extension: "rs".to_string()
+            }
+        );
+        let bin = detect_language(Path::new("assets/logo.png"));
+        assert_eq!(bin.language, None);
+        assert_eq!(bin.source, LanguageSource::Unsupported);
+    }
+}
@@ -381,6 +381,46 @@ pub enum VisibilityRule {
     },
 }
 
+impl VisibilityRule {
+    /// Whether a single chunk is part of its module's public surface under
+    /// this rule — the per-chunk reading of the majority rules above.
+    /// `Fixed` languages have no visibility keyword, so the leading-underscore
+    /// convention (Python, Ruby, Lua, …) decides.
+    pub fn is_public(&self, chunk: &ChunkSummary) -> bool {
+        let sig = chunk.signature.as_str();
+        match self {
+            VisibilityRule::Fixed(_) => !chunk.name.starts_with('_'),
+            VisibilityRule::SigContainsMajority { keyword, .. } => sig.contains(keyword),
+            // The prefix marks either the public form (`pub `) or the private
+            // one (`static `, `defp `); the majority label says which.
+            VisibilityRule::SigStartsMajority {
+                prefix,
+                if_majority,
+                ..
+            } => sig.starts_with(prefix) == matches!(*if_majority, "pub" | "public"),
+            VisibilityRule::TwoKeywordCompare { pub_keyword, .. } => sig.contains(pub_keyword),
+            VisibilityRule::SigContainsEitherMajority {
+                keyword_a,
+                keyword_b,
+                ..
+            } => sig.contains(keyword_a) || sig.contains(keyword_b),
+            VisibilityRule::SigStartsTriage { b, .. } => sig.starts_with(b),
+            VisibilityRule::RegexImportSet { .. } => sig.contains("export"),
+            VisibilityRule::NameCase { .. } => chunk.name.starts_with(|ch: char| ch.is_uppercase()),
+        }
+    }
+}
+
+/// Whether `chunk` is a public symbol by its language's visibility rule.
+/// Languages without a pattern row fall back to the leading-underscore
+/// convention.
+pub fn is_public_symbol(chunk: &ChunkSummary) -> bool {
+    match pattern_def_for(chunk.language) {
+        Some(def) => def.visibility.is_public(chunk),
+        None => !chunk.name.starts_with('_'),
+    }
+}
+
 /// Data-driven definition for per-language pattern extraction.
 ///
 /// Stored on `LanguageDef::patterns` so adding a new language with patterns
@@ -933,9 +973,49 @@ mod tests {
             window_idx: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         }
     }
 
+    #[test]
+    fn test_is_public_symbol_per_language() {
+        let rust = |sig: &str| make_chunk("f", sig, "", Language::Rust);
+        assert!(is_public_symbol(&rust("pub fn f()")));
+        assert!(!is_public_symbol(&rust("pub(crate) fn f()")));
+        assert!(!is_public_symbol(&rust("fn f()")));
+        assert!(!is_public_symbol(&make_chunk(
+            "f",
+            "static int f(void)",
+            "",
+            Language::C
+        )));
+        assert!(is_public_symbol(&make_chunk(
+            "f",
+            "int f(void)",
+            "",
+            Language::C
+        )));
+        assert!(is_public_symbol(&make_chunk(
+            "Serve",
+            "func Serve()",
+            "",
+            Language::Go
+        )));
+        assert!(!is_public_symbol(&make_chunk(
+            "serve",
+            "func serve()",
+            "",
+            Language::Go
+        )));
+        assert!(!is_public_symbol(&make_chunk(
+            "_helper",
+            "def _helper():",
+            "",
+            Language::Python
+        )));
+    }
+
     #[test]
     fn test_detect_naming_snake_case() {
         let chunks = vec![
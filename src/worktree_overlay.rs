@@ -1661,6 +1661,8 @@ mod tests {
                 parent_type_name: None,
                 parser_version: 0,
                 vendored: false,
+                deprecated: false,
+                boilerplate: None,
             };
             SearchResult::new(summary, score)
         }
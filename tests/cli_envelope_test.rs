@@ -202,6 +202,43 @@ fn test_project_search_top_level_json_emits_envelope() {
     );
 }
 
+/// `--schema v2` opts into the versioned shape: the array payload is
+/// wrapped as `{"items": [...], "schema_version": 2}` so it can carry the
+/// marker. The default above stays the bare array.
+#[test]
+#[serial]
+fn test_project_search_schema_v2_wraps_array_payload() {
+    let dir = TempDir::new().expect("tempdir");
+
+    let output = cqs_no_daemon()
+        .args(["--json", "--schema", "v2", "project", "search", "anything"])
+        .env("HOME", dir.path())
+        .env("XDG_DATA_HOME", dir.path())
+        .env("XDG_CACHE_HOME", dir.path())
+        .output()
+        .expect("cqs --json --schema v2 project search failed to spawn");
+
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    let stderr = String::from_utf8_lossy(&output.stderr);
+
+    if !output.status.success() {
+        // Same soft pass as above when no model is on disk.
+        assert!(
+            !stderr.contains("error: unrecognized") && !stderr.contains("error: invalid"),
+            "args must parse — got CLI parse error. stderr={stderr}"
+        );
+        return;
+    }
+
+    let parsed: serde_json::Value = serde_json::from_str(stdout.trim())
+        .unwrap_or_else(|e| panic!("expected bare JSON, parse failed: {e}\nstdout={stdout}"));
+    assert_eq!(parsed["schema_version"], 2, "stdout={stdout}");
+    assert!(
+        parsed["items"].is_array(),
+        "v2 payload must carry the search results array, got: {stdout}"
+    );
+}
+
 // =============================================================================
 // B.3 — `cqs ping --json` emits envelope error on no-daemon failure
 // =============================================================================
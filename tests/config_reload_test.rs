@@ -0,0 +1,73 @@
+//! Integration tests for `cqs::config_reload`.
+//!
+//! Hot reload swaps process-global state (the resolved scoring knobs and
+//! `ScoringConfig::current()`), so these run in their own test binary
+//! rather than beside the parallel unit tests, and `#[serial]` among
+//! themselves.
+
+use cqs::config::Config;
+use cqs::config_reload;
+use cqs::search::scoring::knob::resolve_knob;
+use serial_test::serial;
+use std::fs;
+use tempfile::TempDir;
+
+fn project(toml: &str) -> TempDir {
+    let dir = TempDir::new().expect("tempdir");
+    fs::write(dir.path().join(".cqs.toml"), toml).expect("write .cqs.toml");
+    dir
+}
+
+#[test]
+#[serial]
+fn scoring_edit_applies_and_index_edit_is_kept() {
+    let dir = project("[scoring]\nrrf_k = 40.0\n");
+    config_reload::init(Config::load(dir.path()));
+    cqs::search::scoring::reload_scoring_from_config(Config::load(dir.path()).scoring.as_ref());
+    assert_eq!(resolve_knob("rrf_k"), 40.0);
+
+    fs::write(
+        dir.path().join(".cqs.toml"),
+        "[scoring]\nrrf_k = 25.0\n[index]\nattach_doc_comments = false\n",
+    )
+    .unwrap();
+    let before = config_reload::generation();
+    let outcome = config_reload::reload(dir.path()).expect("reload");
+
+    assert_eq!(outcome.applied, vec!["scoring"]);
+    assert_eq!(outcome.needs_reindex, vec!["index"]);
+    assert_eq!(outcome.generation, before + 1);
+    assert_eq!(resolve_knob("rrf_k"), 25.0);
+    let live = config_reload::current().expect("initialised above");
+    assert!(
+        live.index.is_none(),
+        "rejected [index] change must not reach the running config"
+    );
+}
+
+#[test]
+#[serial]
+fn malformed_config_keeps_running_values() {
+    let dir = project("limit = 7\n[scoring]\nrrf_k = 33.0\n");
+    config_reload::init(Config::load(dir.path()));
+    cqs::search::scoring::reload_scoring_from_config(Config::load(dir.path()).scoring.as_ref());
+
+    fs::write(dir.path().join(".cqs.toml"), "limit = [not toml\n").unwrap();
+    let before = config_reload::generation();
+    assert!(config_reload::reload(dir.path()).is_err());
+
+    assert_eq!(config_reload::generation(), before);
+    assert_eq!(resolve_knob("rrf_k"), 33.0);
+    assert_eq!(config_reload::current().unwrap().limit, Some(7));
+}
+
+#[test]
+#[serial]
+fn unchanged_config_is_a_noop() {
+    let dir = project("limit = 4\n");
+    config_reload::init(Config::load(dir.path()));
+    let before = config_reload::generation();
+    let outcome = config_reload::reload(dir.path()).expect("reload");
+    assert!(outcome.is_noop());
+    assert_eq!(outcome.generation, before);
+}
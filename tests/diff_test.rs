@@ -210,3 +210,62 @@ fn test_semantic_diff_language_filter() {
         "Python function should not be in removed list (filtered out)"
     );
 }
+
+#[test]
+fn test_index_diff_keys_modified_on_content_and_carries_summaries() {
+    let source_store = TestStore::new();
+    let target_store = TestStore::new();
+    let emb = mock_embedding(1.0);
+
+    let a = test_chunk("func_a", "fn func_a() { 1 }");
+    let b_old = test_chunk("func_b", "fn func_b() { 2 }");
+    let c = test_chunk("func_c", "fn func_c() { 3 }");
+    for chunk in [&a, &b_old, &c] {
+        source_store.upsert_chunk(chunk, &emb, Some(12345)).unwrap();
+    }
+
+    // func_b changes source but keeps its embedding: modified, not moved
+    let b_new = test_chunk("func_b", "fn func_b() { 2 + 0 }");
+    let d = test_chunk("func_d", "fn func_d() { 4 }");
+    for chunk in [&a, &b_new, &d] {
+        target_store.upsert_chunk(chunk, &emb, Some(12345)).unwrap();
+    }
+
+    let summary = |hash: &str, text: &str| {
+        (
+            hash.to_string(),
+            text.to_string(),
+            "test-model".to_string(),
+            "summary".to_string(),
+        )
+    };
+    source_store
+        .upsert_summaries_batch(&[summary(&b_old.content_hash, "Returns two.")])
+        .unwrap();
+    target_store
+        .upsert_summaries_batch(&[summary(&b_new.content_hash, "Returns two, padded.")])
+        .unwrap();
+
+    let diff = cqs::index_diff(
+        &source_store.store,
+        &target_store.store,
+        "old",
+        "new",
+        0.95,
+        None,
+    )
+    .unwrap();
+
+    let names = |v: &[cqs::DiffEntry]| v.iter().map(|e| e.name.clone()).collect::<Vec<_>>();
+    assert_eq!(names(&diff.added), vec!["func_d"]);
+    assert_eq!(names(&diff.removed), vec!["func_c"]);
+    assert_eq!(diff.unchanged_count, 1, "func_a is byte-identical");
+
+    assert_eq!(diff.modified.len(), 1);
+    let m = &diff.modified[0];
+    assert_eq!(m.name, "func_b");
+    assert!(!m.moved, "identical embeddings must not count as moved");
+    assert!(m.summary_changed());
+    assert_eq!(m.old_summary.as_deref(), Some("Returns two."));
+    assert_eq!(m.new_summary.as_deref(), Some("Returns two, padded."));
+}
@@ -321,6 +321,8 @@ const EVAL_QUERY_KNOWN_FIELDS: &[&str] = &[
     "gold_chunk_source",
     "tags",
     "_unresolved",
+    "hard_negatives",
+    "query_language",
 ];
 
 /// Same idea for `GoldChunk` — every key in the on-disk gold chunk must
@@ -1,15 +1,24 @@
 //! Frozen-artifact guard: the FULL schema migration chain from the OLDEST
-//! migratable version (v10) to current (v32), run against a hand-built v10 DB.
+//! migratable version (v10) to current (v51), run against a hand-built v10 DB.
 //!
 //! # The version boundary
 //!
 //! Every fresh-state fixture is born at the current schema. The existing
 //! migration tests each exercise ONE step (or at most a 3-step v12→v14 chain)
-//! against a minimal per-step setup helper. NONE runs the complete v10→v32
+//! against a minimal per-step setup helper. NONE runs the complete v10→v51
 //! chain against a faithfully-seeded oldest DB. That chain crosses every
 //! destructive in-place TABLE REBUILD — v15→v16 (llm_summaries composite PK),
 //! v18→v19 (sparse_vectors FK), v28→v29 (notes CHECK + clamp) — plus the recent
-//! v29→v30 (function_calls.edge_kind), v30→v31, v31→v32 (candidate_edges) steps.
+//! v29→v30 (function_calls.edge_kind), v30→v31, v31→v32 (candidate_edges),
+//! v32→v33 (symbol_renames), v33→v34 (FTS rebuild), v34→v35 (container_id
+//! backfill), v35→v36 (schema_migrations history), v36→v37 (chunk_tombstones),
+//! v37→v38 (llm_summaries.superseded_at), v38→v39 (watch_journal), v39→v40
+//! (chunks_fts comments column), v40→v41 (chunks.signature_shape backfill),
+//! v41→v42 (chunk_authors), v42→v43 (chunk_issue_refs backfill), v43→v44
+//! (commits + commit_files), v44→v45 (chunk_embedding_versions), v45→v46
+//! (chunks.deprecated backfill), v46→v47 (chunks.boilerplate backfill), v47→v48
+//! (index_generation triggers), v48→v49 (content_dicts), v49→v50
+//! (chunk_facet_embeddings), v50→v51 (chunk_token_embeddings) steps.
 //! A bug where a later step assumes a column/shape an old DB lacks, or a
 //! rebuild silently drops legacy rows, would only fire on the full chain from
 //! the oldest shape — exactly the state no born-at-HEAD fixture can construct.
@@ -28,13 +37,16 @@
 //!     shape the v29→v30 default ('call') + the `from_str_or_default` read must
 //!     coerce correctly.
 //!   - `type_edges`, `sparse_vectors`, `llm_summaries`, `file_registry`,
-//!     `candidate_edges` all ABSENT (created by later migrations).
+//!     `candidate_edges`, `symbol_renames`, `chunk_tombstones`, `watch_journal`,
+//!     `chunk_authors`, `chunk_issue_refs`, `commits`, `commit_files`,
+//!     `chunk_embedding_versions` all ABSENT
+//!     (created by later migrations).
 //!
-//! The current code can never emit this DB: it always writes schema 32.
+//! The current code can never emit this DB: it always writes schema 51.
 //!
 //! # What it asserts (current code reads/migrates the old shape correctly)
 //!
-//!   (1) `Store::open` runs the whole v10→v32 chain without error and stamps 32.
+//!   (1) `Store::open` runs the whole v10→v51 chain without error and stamps 51.
 //!   (2) Every v10 chunk row SURVIVES the chain (no silent loss across the
 //!       three destructive rebuilds). `chunk_count` and the rows match.
 //!   (3) The off-grid note (-0.8) is CLAMPED to the nearest legal discrete
@@ -42,6 +54,8 @@
 //!   (4) The pre-v30 function_calls edge reads back through the real caller
 //!       query with `edge_kind = Call` (the default-on-absence coercion).
 //!   (5) All the tables a later migration adds exist after the chain.
+//!   (6) The v33→v34 FTS rebuild indexes the legacy chunks, which the v10
+//!       artifact carried no `chunks_fts` for.
 //!
 //! # Calibration (proven RED on a mutated assertion)
 //!
@@ -261,7 +275,7 @@ fn build_v10_artifact(db_path: &Path) {
     });
 }
 
-/// GUARD: the full v10→v32 chain reads/migrates the frozen v10 artifact
+/// GUARD: the full v10→v51 chain reads/migrates the frozen v10 artifact
 /// correctly through the real `Store::open` path (which auto-migrates).
 ///
 /// Calibration (RED proof): mutate the v28→v29 clamp in
@@ -279,17 +293,17 @@ fn v10_full_chain_migrates_and_reads_legacy_shapes() {
     build_v10_artifact(&db_path);
 
     // (1) Open through the real path — this runs check_and_migrate_schema, i.e.
-    // the entire v10→v32 chain. A mis-migration surfaces as an Err here.
-    let store = Store::open(&db_path).expect("Store::open must migrate v10 → v32 without error");
+    // the entire v10→v51 chain. A mis-migration surfaces as an Err here.
+    let store = Store::open(&db_path).expect("Store::open must migrate v10 → v51 without error");
 
-    // schema_version is stamped 32. (Probe the file directly; Store is open
+    // schema_version is stamped 51. (Probe the file directly; Store is open
     // read-write but we re-read via a separate read-only connection.)
     let sv = query_scalar_string(
         &db_path,
         "SELECT value FROM metadata WHERE key = 'schema_version'".to_string(),
     )
     .expect("schema_version row must exist after migrate");
-    assert_eq!(sv, "32", "full chain must stamp schema_version = 32");
+    assert_eq!(sv, "51", "full chain must stamp schema_version = 51");
 
     // (2) Every v10 chunk row survived all three destructive rebuilds.
     let count = store.chunk_count().expect("chunk_count after migrate");
@@ -337,6 +351,16 @@ fn v10_full_chain_migrates_and_reads_legacy_shapes() {
         cqs::parser::CallEdgeKind::Call,
         "a pre-v30 edge (no edge_kind column) must read as the default Call"
     );
+    // (6) The v33→v34 rebuild gave both legacy chunks an FTS row.
+    let fts_rows = query_scalar_string(
+        &db_path,
+        "SELECT CAST(COUNT(*) AS TEXT) FROM chunks_fts".to_string(),
+    );
+    assert_eq!(
+        fts_rows.as_deref(),
+        Some("2"),
+        "v33→v34 must index every legacy chunk into chunks_fts"
+    );
 }
 
 /// GUARD (companion): every table a later migration introduces EXISTS after the
@@ -350,14 +374,26 @@ fn v10_full_chain_creates_all_later_tables() {
     let db_path = tmp.path().join("index.db");
     build_v10_artifact(&db_path);
 
-    let _store = Store::open(&db_path).expect("Store::open must migrate v10 → v32");
+    let _store = Store::open(&db_path).expect("Store::open must migrate v10 → v51");
 
     for table in [
-        "type_edges",      // v10→v11
-        "llm_summaries",   // v13→v14 (rebuilt v15→v16)
-        "sparse_vectors",  // v16→v17 (rebuilt v18→v19)
-        "file_registry",   // v28→v29
-        "candidate_edges", // v31→v32
+        "type_edges",               // v10→v11
+        "llm_summaries",            // v13→v14 (rebuilt v15→v16)
+        "sparse_vectors",           // v16→v17 (rebuilt v18→v19)
+        "file_registry",            // v28→v29
+        "candidate_edges",          // v31→v32
+        "symbol_renames",           // v32→v33
+        "schema_migrations",        // v35→v36
+        "chunk_tombstones",         // v36→v37
+        "watch_journal",            // v38→v39
+        "chunk_authors",            // v41→v42
+        "chunk_issue_refs",         // v42→v43
+        "commits",                  // v43→v44
+        "commit_files",             // v43→v44
+        "chunk_embedding_versions", // v44→v45
+        "content_dicts",            // v48→v49
+        "chunk_facet_embeddings",   // v49→v50
+        "chunk_token_embeddings",   // v50→v51
     ] {
         let found = query_scalar_string(
             &db_path,
@@ -366,7 +402,7 @@ fn v10_full_chain_creates_all_later_tables() {
         assert_eq!(
             found.as_deref(),
             Some(table),
-            "table {table} must exist after the full v10→v32 chain"
+            "table {table} must exist after the full v10→v51 chain"
         );
     }
 }
@@ -1503,6 +1503,8 @@ fn test_hard_reranker_comparison() {
                             parent_type_name: None,
                             parser_version: 0,
                             vendored: false,
+                            deprecated: false,
+                            boilerplate: None,
                         },
                         *score,
                     )
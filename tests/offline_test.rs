@@ -0,0 +1,157 @@
+//! Offline-mode harness: with `CQS_OFFLINE=1`, every path that would reach
+//! the network must fail with an offline error before opening a connection.
+//!
+//! Runs as its own test binary so the process-global switch can't leak into
+//! unrelated tests. `HF_HOME` points at an empty directory, so nothing is
+//! cached and every model lookup has to go through the offline guard.
+
+use std::path::PathBuf;
+use std::sync::Once;
+use std::time::{Duration, Instant};
+
+use cqs::embedder::{EmbedderError, ModelConfig};
+use cqs::parser::{ChunkType, Language};
+use cqs::reranker::RerankerError;
+use cqs::store::{ChunkSummary, SearchResult};
+use cqs::{Embedder, OnnxReranker, Reranker};
+
+/// A refusal is immediate; anything slower suggests a connection attempt.
+const REFUSAL_BUDGET: Duration = Duration::from_secs(2);
+
+fn offline() {
+    static INIT: Once = Once::new();
+    INIT.call_once(|| {
+        let hf_home = tempfile::tempdir().unwrap();
+        std::env::set_var("CQS_OFFLINE", "1");
+        std::env::set_var("HF_HOME", hf_home.path());
+        std::env::remove_var("CQS_ONNX_DIR");
+        std::env::remove_var("CQS_RERANKER_MODEL");
+        // Lives for the rest of the process.
+        std::mem::forget(hf_home);
+    });
+    assert!(cqs::offline::enabled());
+}
+
+fn summary(id: &str, content: &str) -> SearchResult {
+    SearchResult::new(
+        ChunkSummary {
+            id: id.to_string(),
+            file: PathBuf::from("lib.rs"),
+            language: Language::Rust,
+            chunk_type: ChunkType::Function,
+            name: id.to_string(),
+            signature: format!("fn {id}()"),
+            content: content.to_string(),
+            doc: None,
+            line_start: 1,
+            line_end: 1,
+            content_hash: id.to_string(),
+            parent_id: None,
+            window_idx: None,
+            parent_type_name: None,
+            parser_version: 0,
+            vendored: false,
+            deprecated: false,
+            boilerplate: None,
+        },
+        0.5,
+    )
+}
+
+#[test]
+fn embedder_download_is_refused() {
+    offline();
+    let mut config = ModelConfig::from_preset("bge-large").unwrap();
+    config.repo = "cqs-offline-test/never-downloaded".to_string();
+    let embedder = Embedder::new_cpu(config).unwrap();
+    let start = Instant::now();
+    let err = embedder.embed_query("parse config").unwrap_err();
+    assert!(start.elapsed() < REFUSAL_BUDGET);
+    assert!(matches!(err, EmbedderError::Offline(_)), "{err}");
+    assert!(err
+        .to_string()
+        .contains("cqs-offline-test/never-downloaded"));
+}
+
+#[test]
+fn reranker_download_is_refused() {
+    offline();
+    let reranker = OnnxReranker::new().unwrap();
+    let mut results = vec![summary("a", "fn a() {}"), summary("b", "fn b() {}")];
+    let start = Instant::now();
+    let err = reranker.rerank("query", &mut results, 2).unwrap_err();
+    assert!(start.elapsed() < REFUSAL_BUDGET);
+    assert!(matches!(err, RerankerError::Offline(_)), "{err}");
+}
+
+#[test]
+fn remote_vector_stores_are_refused() {
+    use cqs::vector_backend::{open, ExternalTarget, VectorBackendError, VectorBackendKind};
+
+    offline();
+    let dir = tempfile::tempdir().unwrap();
+    for (kind, url) in [
+        (VectorBackendKind::Qdrant, "http://192.0.2.1:6333"),
+        (VectorBackendKind::Qdrant, "https://vectors.example.com"),
+        (
+            VectorBackendKind::Pgvector,
+            "postgres://cqs@db.example.com/cqs",
+        ),
+    ] {
+        let target = ExternalTarget {
+            kind,
+            url: url.to_string(),
+            collection: None,
+        };
+        let start = Instant::now();
+        let err = open(&target, dir.path())
+            .err()
+            .unwrap_or_else(|| panic!("{url} was allowed offline"));
+        assert!(start.elapsed() < REFUSAL_BUDGET);
+        assert!(matches!(err, VectorBackendError::Offline(_)), "{err}");
+    }
+}
+
+#[cfg(feature = "llm-summaries")]
+mod llm {
+    use super::*;
+    use cqs::llm::{create_client, LlmConfig, LlmError};
+
+    fn config(provider: &'static str, api_base: &str) -> LlmConfig {
+        LlmConfig {
+            provider,
+            api_base: api_base.to_string(),
+            model: "offline-test-model".to_string(),
+            max_tokens: 100,
+            hyde_max_tokens: 150,
+        }
+    }
+
+    #[test]
+    fn remote_providers_are_refused() {
+        offline();
+        for (provider, base) in [
+            ("anthropic", "https://api.anthropic.com/v1"),
+            ("local", "http://192.0.2.1:8080/v1"),
+            ("local", "https://llm.internal.example/v1"),
+        ] {
+            let start = Instant::now();
+            let err = create_client(config(provider, base), None)
+                .err()
+                .unwrap_or_else(|| panic!("{provider} at {base} was allowed offline"));
+            assert!(start.elapsed() < REFUSAL_BUDGET);
+            assert!(matches!(err, LlmError::Offline(_)), "{err}");
+        }
+    }
+
+    #[test]
+    fn loopback_local_provider_is_allowed() {
+        offline();
+        for base in ["http://localhost:8080/v1", "http://127.0.0.1:11434/v1"] {
+            assert!(
+                create_client(config("local", base), None).is_ok(),
+                "{base} refused offline"
+            );
+        }
+    }
+}
@@ -32,6 +32,8 @@ fn make_code_result(name: &str, score: f32) -> SearchResult {
             window_idx: None,
             parser_version: 0,
             vendored: false,
+            deprecated: false,
+            boilerplate: None,
         },
         score,
     )
@@ -17,7 +17,7 @@ fn test_store_init() {
     let stats = store.stats().unwrap();
     assert_eq!(stats.total_chunks, 0);
     assert_eq!(stats.total_files, 0);
-    assert_eq!(stats.schema_version, 32); // v32: candidate_edges side-table (call-graph candidates that must not surface as callers)
+    assert_eq!(stats.schema_version, 51); // v51: chunk_token_embeddings
     assert_eq!(stats.model_name, cqs::embedder::DEFAULT_MODEL_REPO);
 }
 
@@ -437,14 +437,10 @@ fn test_normalize_for_fts_strips_fts5_special_chars() {
     // Slash - stripped
     assert_eq!(normalize_for_fts("test/other"), "test other");
 
-    // Mixed potentially malicious input - all special chars stripped
-    // Note: ALL_CAPS words get split letter-by-letter by tokenize_identifier
-    // (designed for camelCase, treats each capital as word boundary)
+    // Mixed potentially malicious input - all special chars stripped.
+    // ALL_CAPS words stay whole (acronym runs are one token) and lowercase.
     assert_eq!(normalize_for_fts("*\"content:*\""), "content");
-    assert_eq!(
-        normalize_for_fts("test; DROP TABLE--"),
-        "test d r o p t a b l e"
-    );
+    assert_eq!(normalize_for_fts("test; DROP TABLE--"), "test drop table");
 }
 
 // ===== Schema Error Path Tests =====
@@ -1178,7 +1174,7 @@ fn test_open_readonly_on_initialized_store() {
     let ro = cqs::store::Store::open_readonly(&db_path).unwrap();
     let stats = ro.stats().unwrap();
     assert_eq!(stats.total_chunks, 0);
-    assert_eq!(stats.schema_version, 32);
+    assert_eq!(stats.schema_version, 51);
     assert_eq!(stats.model_name, cqs::embedder::DEFAULT_MODEL_REPO);
 }
 
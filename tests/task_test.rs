@@ -258,6 +258,8 @@ fn test_compute_risk_and_tests_integration() {
         window_idx: None,
         parser_version: 0,
         vendored: false,
+        deprecated: false,
+        boilerplate: None,
     }];
 
     let (scores, tests) = compute_risk_and_tests(